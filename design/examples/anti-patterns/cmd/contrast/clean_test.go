@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/anti-patterns-example/internal/sandbox"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// The checks of main.go against clean-architecture. None of them needs the
+// sandbox; it only runs because mud is in the same package.
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.RemoveAll(sandbox.Dir)
+	os.Exit(code)
+}
+
+// TestEveryCheckHasACleanTest keeps the check table and this file in step
+func TestEveryCheckHasACleanTest(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"TestTitleRuleWithoutHTTPOrDatabase": TestTitleRuleWithoutHTTPOrDatabase,
+		"TestEachTestStartsEmpty":            TestEachTestStartsEmpty,
+		"TestErrorsCarryACode":               TestErrorsCarryACode,
+		"TestCreateAndUpdateShareLimits":     TestCreateAndUpdateShareLimits,
+		"TestPathParameterCannotChangeQuery": TestPathParameterCannotChangeQuery,
+		"TestStorageFailureIs500":            TestStorageFailureIs500,
+	}
+	for _, c := range checks {
+		if tests[c.clean] == nil {
+			t.Errorf("check %q names %q, which is not a test in clean_test.go", c.title, c.clean)
+		}
+	}
+}
+
+func TestTitleRuleWithoutHTTPOrDatabase(t *testing.T) {
+	if err := domain.ValidateTitle(""); !errors.Is(err, domain.ErrEmptyTitle) {
+		t.Errorf(`domain.ValidateTitle("") = %v, want TASK_TITLE_EMPTY`, err)
+	}
+}
+
+func TestEachTestStartsEmpty(t *testing.T) {
+	testA, testB := cleanServer(repository.NewMemoryTaskRepository()), cleanServer(repository.NewMemoryTaskRepository())
+	call(testA, http.MethodPost, "/tasks", `{"title":"from test A"}`)
+	if _, body := call(testB, http.MethodGet, "/tasks", ""); body != "[]" {
+		t.Errorf("test B sees %s; each test should build its own repository", body)
+	}
+}
+
+func TestErrorsCarryACode(t *testing.T) {
+	e := cleanServer(repository.NewMemoryTaskRepository())
+	call(e, http.MethodPost, "/tasks", `{"title":"t"}`)
+	_, created := call(e, http.MethodPost, "/tasks", `{"title":""}`)
+	_, updated := call(e, http.MethodPut, "/tasks/1", `{"title":""}`)
+	want := `"code":"TASK_TITLE_EMPTY"`
+	if !strings.Contains(created, want) || !strings.Contains(updated, want) {
+		t.Errorf("create answers %s and update %s; want both to carry %s", created, updated, want)
+	}
+}
+
+func TestCreateAndUpdateShareLimits(t *testing.T) {
+	e := cleanServer(repository.NewMemoryTaskRepository())
+	long := strings.Repeat("x", 1001)
+	created, _ := call(e, http.MethodPost, "/tasks", `{"title":"t","description":"`+long+`"}`)
+	call(e, http.MethodPost, "/tasks", `{"title":"t"}`)
+	updated, _ := call(e, http.MethodPut, "/tasks/1", `{"title":"t","description":"`+long+`"}`)
+	if created != http.StatusBadRequest || updated != http.StatusBadRequest {
+		t.Errorf("a 1001-character description: create answers %d, update %d; want 400 for both", created, updated)
+	}
+}
+
+func TestPathParameterCannotChangeQuery(t *testing.T) {
+	e := cleanServer(repository.NewMemoryTaskRepository())
+	call(e, http.MethodPost, "/tasks", `{"title":"t"}`)
+	if status, body := call(e, http.MethodGet, "/tasks/0%20OR%201=1", ""); status != http.StatusBadRequest {
+		t.Errorf("GET /tasks/0 OR 1=1 answers %d %s, want 400", status, body)
+	}
+}
+
+func TestStorageFailureIs500(t *testing.T) {
+	status, body := call(cleanServer(failingRepo{}), http.MethodGet, "/tasks", "")
+	if status != http.StatusInternalServerError || !strings.Contains(body, "INTERNAL_ERROR") || strings.Contains(body, "locked") {
+		t.Errorf("a failing repository gives %d %s; want 500 INTERNAL_ERROR, with the cause only logged", status, body)
+	}
+}
+
+// cleanServer wires the clean-architecture layers the way its main.go does,
+// over whatever repository the test provides. mud has no accounts, so every
+// request is authenticated as one user instead of through a token.
+func cleanServer(repo domain.TaskRepository) *echo.Echo {
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(repo))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(handler.AsUser(&domain.User{ID: 1}))
+	e.POST("/tasks", h.CreateTask)
+	e.GET("/tasks/:id", h.GetTask)
+	e.GET("/tasks", h.GetAllTasks)
+	e.PUT("/tasks/:id", h.UpdateTask)
+	e.DELETE("/tasks/:id", h.DeleteTask)
+	return e
+}
+
+var errLocked = errors.New("database is locked")
+
+type failingRepo struct{}
+
+func (failingRepo) Create(context.Context, *domain.Task) error           { return errLocked }
+func (failingRepo) CreateBatch(context.Context, []*domain.Task) error    { return errLocked }
+func (failingRepo) GetByID(context.Context, int64) (*domain.Task, error) { return nil, errLocked }
+func (failingRepo) GetByIDs(context.Context, []int64) ([]*domain.Task, error) {
+	return nil, errLocked
+}
+func (failingRepo) List(context.Context, domain.ListTasksQuery) ([]*domain.Task, error) {
+	return nil, errLocked
+}
+func (failingRepo) Update(context.Context, *domain.Task) error { return errLocked }
+func (failingRepo) Delete(context.Context, int64) error        { return errLocked }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// sandbox must be initialized before mud; see its package comment
+	"github.com/dong-tran/docs/anti-patterns-example/internal/sandbox"
+	"github.com/dong-tran/docs/anti-patterns-example/mud"
+
+	"github.com/labstack/echo/v4"
+)
+
+// contrast runs the checks a test suite for the task API would make against
+// the big ball of mud in mud/. Each check is something a reviewer would
+// reasonably expect to be able to test. The mud version fails each one, and
+// the output says why. The same checks against clean-architecture/ are
+// ordinary tests in clean_test.go, with in-memory fakes, and the output names
+// the test for each.
+//
+// It exits 0 when the contrast holds, and 1 if a mud check unexpectedly
+// passes.
+
+type check struct {
+	title string
+	clean string // the test in clean_test.go that makes it against clean-architecture
+	mud   func() (bool, string)
+}
+
+var checks = []check{
+	{
+		title: "The title rule can be checked without HTTP or a database",
+		clean: "TestTitleRuleWithoutHTTPOrDatabase",
+		mud: func() (bool, string) {
+			_, err := os.Stat(filepath.Join(sandbox.Dir, "tasks.db"))
+			if err != nil {
+				return true, "no database file was created"
+			}
+			return false, "the rule only exists inside createTask, so checking it takes an HTTP request through " +
+				"mud.Server and a write to tasks.db, which importing the package already created in the working directory"
+		},
+	},
+	{
+		title: "Each test starts from an empty store",
+		clean: "TestEachTestStartsEmpty",
+		mud: func() (bool, string) {
+			call(mud.Server, http.MethodPost, "/tasks", `{"title":"from test A"}`) // test A
+			_, body := call(mud.Server, http.MethodGet, "/tasks", "")              // test B
+			if body == "[]" {
+				return true, "test B saw an empty store"
+			}
+			return false, fmt.Sprintf("test B sees %d task(s) left behind by test A: there is one global DB, "+
+				"so results depend on which tests ran before", strings.Count(body, `"id"`))
+		},
+	},
+	{
+		title: "Errors carry a stable code that clients and tests can match",
+		clean: "TestErrorsCarryACode",
+		mud: func() (bool, string) {
+			_, created := call(mud.Server, http.MethodPost, "/tasks", `{"title":""}`)
+			_, updated := call(mud.Server, http.MethodPut, "/tasks/1", `{"title":""}`)
+			if strings.Contains(created, `"code"`) {
+				return true, "the response has a code"
+			}
+			return false, fmt.Sprintf("only wording to match on, and it differs by endpoint: create says %s, update says %s",
+				strings.TrimSpace(created), strings.TrimSpace(updated))
+		},
+	},
+	{
+		title: "Create and update enforce the same limits",
+		clean: "TestCreateAndUpdateShareLimits",
+		mud: func() (bool, string) {
+			long := strings.Repeat("x", 1001)
+			created, _ := call(mud.Server, http.MethodPost, "/tasks", `{"title":"t","description":"`+long+`"}`)
+			updated, _ := call(mud.Server, http.MethodPut, "/tasks/1", `{"title":"t","description":"`+long+`"}`)
+			if created == updated {
+				return true, fmt.Sprintf("both answer %d", created)
+			}
+			return false, fmt.Sprintf("a 1001-character description: create answers %d, update answers %d; "+
+				"each handler has its own copy of the rules", created, updated)
+		},
+	},
+	{
+		title: "A path parameter cannot change the query",
+		clean: "TestPathParameterCannotChangeQuery",
+		mud: func() (bool, string) {
+			status, body := call(mud.Server, http.MethodGet, "/tasks/0%20OR%201=1", "")
+			if status != http.StatusOK {
+				return true, fmt.Sprintf("GET /tasks/0 OR 1=1 answers %d", status)
+			}
+			return false, "GET /tasks/0 OR 1=1 answers 200 with " + strings.TrimSpace(body)
+		},
+	},
+	{
+		// Keep this last: the mud side can only fail the database for everyone
+		title: "A storage failure answers 500 without leaking internals or breaking other tests",
+		clean: "TestStorageFailureIs500",
+		mud: func() (bool, string) {
+			// There is no seam to inject a failure, so break the shared handle
+			mud.DB.Close()
+			_, body := call(mud.Server, http.MethodGet, "/tasks", "")
+			if !strings.Contains(body, "sql:") {
+				return true, "the error was not leaked"
+			}
+			return false, "the only way to fail storage is closing mud.DB, which breaks every later test; " +
+				"the client receives " + strings.TrimSpace(body)
+		},
+	},
+}
+
+func main() {
+	fmt.Println("Checks a test suite would make, against both implementations of the task API")
+	fmt.Println()
+	unexpected := 0
+	for i, c := range checks {
+		fmt.Printf("%d. %s\n", i+1, c.title)
+		fmt.Printf("   clean  go test -run %s ./cmd/contrast\n", c.clean)
+		ok, note := c.mud()
+		fmt.Printf("   mud    %s  %s\n", verdict(ok), note)
+		if ok {
+			unexpected++
+		}
+		fmt.Println()
+	}
+	fmt.Printf("mud also counts requests in a plain int from concurrent handlers; `go run -race .` and a load test will show it.\n")
+
+	os.RemoveAll(sandbox.Dir)
+	if unexpected > 0 {
+		fmt.Fprintf(os.Stderr, "%d check(s) did not come out as the contrast expects\n", unexpected)
+		os.Exit(1)
+	}
+}
+
+func verdict(ok bool) string {
+	if ok {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+func call(h http.Handler, method, path, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
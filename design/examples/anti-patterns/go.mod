@@ -0,0 +1,36 @@
+module github.com/dong-tran/docs/anti-patterns-example
+
+go 1.22
+
+require (
+	github.com/dong-tran/docs/clean-architecture-example v0.0.0
+	github.com/jmoiron/sqlx v1.3.5
+	github.com/labstack/echo/v4 v4.11.3
+	github.com/mattn/go-sqlite3 v1.14.18
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/graph-gophers/graphql-go v1.5.0 // indirect
+	github.com/labstack/gommon v0.4.0 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+)
+
+// The contrast command runs the same checks against the clean version
+replace github.com/dong-tran/docs/clean-architecture-example => ../clean-architecture
+
+// The clean version's own replace does not apply here, so repeat it for the
+// concurrency module it uses
+replace github.com/dong-tran/docs/concurrency-example => ../concurrency
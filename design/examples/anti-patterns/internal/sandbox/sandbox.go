@@ -0,0 +1,26 @@
+// Package sandbox moves the process into a fresh temporary directory when it
+// is initialized, so that mud's init() creates its tasks.db there instead of
+// in the reader's checkout.
+//
+// Go initializes imported packages in import path order (when dependencies
+// allow), and "internal/sandbox" sorts before "mud", so importing this package
+// from the same file runs it first. A test suite for mud would need the same
+// trick, and would break silently if the package were renamed.
+package sandbox
+
+import (
+	"log"
+	"os"
+)
+
+var Dir string
+
+func init() {
+	var err error
+	if Dir, err = os.MkdirTemp("", "mud"); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(Dir); err != nil {
+		log.Fatal(err)
+	}
+}
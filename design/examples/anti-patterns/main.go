@@ -0,0 +1,14 @@
+package main
+
+import (
+	"log"
+
+	"github.com/dong-tran/docs/anti-patterns-example/mud"
+)
+
+// The big-ball-of-mud task API. It serves the same routes as
+// clean-architecture/main.go; run `go run ./cmd/contrast` to see what it costs.
+func main() {
+	log.Println("Server starting on :8080")
+	log.Fatal(mud.Server.Start(":8080"))
+}
@@ -0,0 +1,147 @@
+// Package mud is the task API from clean-architecture/ written as a big ball
+// of mud, on purpose. Every endpoint answers roughly like the original, and
+// for a demo nobody could tell the difference. The differences show up when
+// you try to test it or change it; cmd/contrast runs the same checks against
+// both versions.
+//
+// Each shortcut is marked SMELL. Do not copy this code.
+package mud
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SMELL: package-level state. Every caller, and every test, shares one
+// database handle and one server; nothing can be swapped for a fake.
+var (
+	DB     *sqlx.DB
+	Server = echo.New()
+
+	// SMELL: unsynchronized counter written by concurrent requests
+	RequestCount int
+)
+
+// SMELL: importing the package opens ./tasks.db in whatever directory the
+// importer runs from, before any of its code gets a say.
+func init() {
+	var err error
+	DB, err = sqlx.Open("sqlite3", "./tasks.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	DB.MustExec(`
+	CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		description TEXT,
+		completed BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`)
+
+	Server.POST("/tasks", createTask)
+	Server.GET("/tasks/:id", getTask)
+	Server.GET("/tasks", listTasks)
+	Server.PUT("/tasks/:id", updateTask)
+	Server.DELETE("/tasks/:id", deleteTask)
+}
+
+type task struct {
+	ID          int64     `db:"id" json:"id"`
+	Title       string    `db:"title" json:"title"`
+	Description string    `db:"description" json:"description"`
+	Completed   bool      `db:"completed" json:"completed"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// SMELL: the handler is the business rule, the repository and the presenter
+func createTask(c echo.Context) error {
+	RequestCount++
+	body := map[string]interface{}{}
+	c.Bind(&body)
+	title, _ := body["title"].(string)
+	description, _ := body["description"].(string)
+
+	// SMELL: the only copy of the title rule is inside an HTTP handler, and
+	// the description limit was forgotten here (updateTask has it)
+	if title == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "title required"})
+	}
+	if len(title) > 200 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "title too long"})
+	}
+
+	now := time.Now()
+	res, err := DB.Exec("INSERT INTO tasks (title, description, completed, created_at, updated_at) VALUES (?, ?, 0, ?, ?)",
+		title, description, now, now)
+	if err != nil {
+		// SMELL: the driver's error text goes straight to the client
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	id, _ := res.LastInsertId()
+	return c.JSON(http.StatusCreated, task{ID: id, Title: title, Description: description, CreatedAt: now, UpdatedAt: now})
+}
+
+func getTask(c echo.Context) error {
+	RequestCount++
+	var t task
+	// SMELL: the path parameter is pasted into the SQL
+	err := DB.Get(&t, fmt.Sprintf("SELECT * FROM tasks WHERE id = %s", c.Param("id")))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+	return c.JSON(http.StatusOK, t)
+}
+
+func listTasks(c echo.Context) error {
+	RequestCount++
+	var tasks []task
+	if err := DB.Select(&tasks, "SELECT * FROM tasks ORDER BY created_at DESC"); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, tasks)
+}
+
+func updateTask(c echo.Context) error {
+	RequestCount++
+	body := map[string]interface{}{}
+	c.Bind(&body)
+	title, _ := body["title"].(string)
+	description, _ := body["description"].(string)
+	completed, _ := body["completed"].(bool)
+
+	// SMELL: the rules again, copied from createTask and already drifting
+	if title == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "title is required"})
+	}
+	if len(title) > 255 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "title too long"})
+	}
+	if len(description) > 1000 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "description too long"})
+	}
+
+	res, err := DB.Exec("UPDATE tasks SET title = ?, description = ?, completed = ?, updated_at = ? WHERE id = ?",
+		title, description, completed, time.Now(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+	return getTask(c)
+}
+
+func deleteTask(c echo.Context) error {
+	RequestCount++
+	DB.Exec("DELETE FROM tasks WHERE id = ?", c.Param("id")) // SMELL: error ignored
+	return c.NoContent(http.StatusNoContent)
+}
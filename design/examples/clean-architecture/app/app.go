@@ -0,0 +1,375 @@
+// Package app wires the server together: every layer from the stores to the
+// routes, and the components to start and stop around them. main.go serves
+// it on a port; app_test.go serves the same thing from httptest, in memory.
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/i18n"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/fulltext"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/mongodb"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/dong-tran/docs/concurrency-example/shutdown"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Options change how New wires the server. The zero value is what main.go
+// runs.
+type Options struct {
+	// Memory keeps tasks, accounts, idempotency keys, the outbox and
+	// attachments in memory, instead of in the configured database, MongoDB
+	// and attachments directory
+	Memory bool
+	// Logf logs what the server does, log.Printf if nil
+	Logf func(format string, args ...any)
+	// AccessLog is where each request is logged, os.Stdout if nil
+	AccessLog io.Writer
+	// Clock is what the use cases and background jobs take the time from,
+	// domain.SystemClock if nil
+	Clock domain.Clock
+}
+
+// App is the wired server. Lifecycle holds every component it depends on;
+// the caller serves Echo, on a listener it registers as a component that
+// depends on Dependencies, or otherwise between Lifecycle's Start and Stop.
+type App struct {
+	Echo      *echo.Echo
+	Lifecycle *shutdown.Orchestrator
+	// Dependencies are the components the HTTP server must start after and
+	// stop before
+	Dependencies []string
+	Health       *infrastructure.Health
+}
+
+// New wires the server from cfg
+func New(cfg config.Config, opts Options) (*App, error) {
+	logf := opts.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+	accessLog := opts.AccessLog
+	if accessLog == nil {
+		accessLog = os.Stdout
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = domain.SystemClock
+	}
+
+	// Stores the server depends on: it stops before they are closed. Each is
+	// also a readiness check of GET /readyz.
+	var stores []shutdown.Component
+	health := infrastructure.NewHealth()
+
+	var (
+		taskRepo    domain.TaskRepository
+		outbox      domain.Outbox
+		archive     domain.TaskArchive
+		userRepo    domain.UserRepository
+		idempotency domain.IdempotencyStore
+		attachments domain.AttachmentStorage
+	)
+	if opts.Memory {
+		memory := repository.NewMemoryTaskRepository()
+		taskRepo, outbox, archive = memory, memory.Outbox(), memory
+		userRepo = repository.NewMemoryUserRepository()
+		idempotency = repository.NewMemoryIdempotencyRepository()
+		attachments = repository.NewMemoryAttachmentStorage()
+	} else {
+		// Files attached to tasks are kept in attachments.dir
+		files, err := repository.NewFileAttachmentStorage(cfg.Attachments.Dir)
+		if err != nil {
+			return nil, err
+		}
+		attachments = files
+
+		// Initialize database (outermost layer). database.driver and
+		// database.dsn pick it; unset, it is the SQLite file ./tasks.db.
+		db, err := infrastructure.InitDatabase(cfg.DatabaseConfig())
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, shutdown.Component{
+			Name: "database",
+			Stop: func(context.Context) error { return db.Close() },
+		})
+		health.AddCheck("database", db.PingContext)
+
+		// Dependency injection from outer to inner layers
+		// database.description_columns follows the description -> details
+		// rename (see cmd/migrate); unset means the column has not been renamed
+		tasks := repository.NewTaskRepositoryWithColumns(db, cfg.DescriptionColumns())
+		taskRepo, archive = tasks, tasks
+		// The SQL repository stores the domain events of each change in its
+		// outbox, in the same transaction
+		outbox = repository.NewOutboxRepository(db)
+		userRepo = repository.NewUserRepository(db)
+		// The keys live in the SQL database, even with tasks in MongoDB, so
+		// every instance sees them
+		idempotency = repository.NewIdempotencyRepository(db)
+
+		// mongodb.uri keeps the tasks in MongoDB instead, while accounts stay
+		// in the SQL database. Nothing past this block can tell.
+		if uri := cfg.MongoDB.URI; uri != "" {
+			tasksDB, err := mongodb.Connect(context.Background(), uri)
+			if err != nil {
+				db.Close()
+				return nil, err
+			}
+			if taskRepo, err = mongodb.NewTaskRepository(context.Background(), tasksDB); err != nil {
+				db.Close()
+				return nil, err
+			}
+			stores = append(stores, shutdown.Component{Name: "mongodb", Stop: tasksDB.Client().Disconnect})
+			health.AddCheck("mongodb", func(ctx context.Context) error { return tasksDB.Client().Ping(ctx, nil) })
+			logf("Tasks in MongoDB have no outbox; domain events are not delivered")
+			logf("Tasks in MongoDB have no archive; completed tasks are not archived")
+			outbox, archive = nil, nil
+		}
+	}
+
+	// Every request and repository operation is measured, for GET /metrics,
+	// and traced: tracing.exporter stdout or otlp sends the spans of each
+	// request, through the use cases to the repositories, to the exporter
+	metrics := infrastructure.NewMetrics()
+	stopTracing, err := infrastructure.InitTracing(context.Background(), cfg.TracingConfig())
+	if err != nil {
+		return nil, err
+	}
+	taskRepo = repository.NewTracedTaskRepository(repository.NewTimedTaskRepository(taskRepo, metrics.ObserveQuery))
+	userRepo = repository.NewTracedUserRepository(repository.NewTimedUserRepository(userRepo, metrics.ObserveQuery))
+	// Every change the use cases store is published here, for the streams
+	// of GET /tasks/stream
+	taskEvents := infrastructure.NewTaskEventBus()
+	taskUseCase := usecase.NewTaskUseCaseWithClock(taskRepo, taskEvents, clock)
+	taskHandler := handler.NewTaskHandler(taskUseCase)
+	attachmentHandler := handler.NewAttachmentHandler(usecase.NewAttachmentUseCase(taskUseCase, attachments))
+	assignmentHandler := handler.NewAssignmentHandler(usecase.NewAssignmentUseCase(taskUseCase, userRepo))
+	// Completed tasks older than archive.retention are moved to the archive,
+	// every archive.interval, and listed at GET /tasks/archived
+	var archiver *usecase.TaskArchiver
+	var archiveHandler *handler.TaskArchiveHandler
+	if archive != nil {
+		archiver = usecase.NewTaskArchiver(archive, taskEvents, usecase.ArchiverOptions{
+			Retention: cfg.Archive.Retention,
+			Interval:  cfg.Archive.Interval,
+			Clock:     clock,
+			Logf:      logf,
+		})
+		archiveHandler = handler.NewTaskArchiveHandler(archiver)
+	}
+	// The read side: GET /tasks/summary and /tasks/activity answer from a
+	// read model that follows the same events, filled from the stored tasks
+	// when it starts
+	taskQueries := usecase.NewTaskQueryService(repository.NewMemoryTaskReadModel(clock), taskRepo, taskEvents, logf)
+	// GET /tasks/search ranks tasks by their words, from a bleve index kept
+	// up to date from the same events: in memory, or in search.index_dir if
+	// set. An index that is empty is filled from the stored tasks when it
+	// starts.
+	var searchIndex *fulltext.TaskIndex
+	if opts.Memory || cfg.Search.IndexDir == "" {
+		searchIndex, err = fulltext.NewMemory()
+	} else {
+		searchIndex, err = fulltext.Open(cfg.Search.IndexDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	taskSearch := usecase.NewTaskSearchService(searchIndex, taskRepo, taskEvents, logf)
+	// A task created with a due date is reminded of to its owner,
+	// reminders.lead before it, by a job run from an in-process queue; the
+	// reminders are logged
+	jobs := infrastructure.NewJobQueue(infrastructure.JobQueueOptions{Logf: logf})
+	reminders := usecase.NewReminderService(jobs, taskRepo, taskEvents, infrastructure.LogReminderSender{Logf: logf}, usecase.ReminderOptions{
+		Lead:  cfg.Reminders.Lead,
+		Clock: clock,
+		Logf:  logf,
+	})
+	jobs.Handle(domain.SendReminderJob, reminders)
+
+	// auth.jwt_secret signs the access tokens. Without it a random secret is
+	// used, so tokens stop working when the server restarts.
+	secret := []byte(cfg.Auth.JWTSecret)
+	if len(secret) == 0 {
+		logf("JWT_SECRET not set; using a random secret for this run")
+		secret = make([]byte, infrastructure.MinJWTSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	tokens, err := infrastructure.NewJWTTokens(secret, cfg.Auth.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	authUseCase := usecase.NewAuthUseCase(userRepo, infrastructure.BcryptHasher{}, tokens.WithClock(clock))
+	authHandler := handler.NewAuthHandler(authUseCase)
+	userUseCase := usecase.NewUserUseCase(userRepo)
+	userHandler := handler.NewUserHandler(userUseCase)
+	graphQLHandler := handler.NewGraphQLHandler(taskUseCase, userUseCase)
+	// Error messages in English and the languages of i18n/catalogs
+	translator, err := i18n.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup Echo framework
+	e := echo.New()
+	// Handlers return errors; this answers each with a problem document
+	e.HTTPErrorHandler = handler.ErrorHandler
+
+	// Middleware
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Output: accessLog}))
+	e.Use(infrastructure.TracingMiddleware())
+	e.Use(metrics.Middleware())
+	e.Use(middleware.Recover())
+	// Cross-origin clients may read the ETag, to send it back in If-Match,
+	// and learn that a path without a version is deprecated
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		ExposeHeaders: []string{handler.HeaderETag, handler.HeaderDeprecation, handler.HeaderLink},
+	}))
+	// Error messages are in the language Accept-Language asks for, else
+	// English; codes are the same in every language
+	e.Use(handler.Localize(translator))
+	// Each request is for the tenant its X-Tenant-ID header names, or its
+	// subdomain of tenancy.domain, else the default tenant. Accounts and
+	// tasks of one tenant are invisible to every other.
+	e.Use(handler.ResolveTenant(cfg.Tenancy.Domain))
+	// Every request gets a deadline that the use cases pass down to the
+	// database; one that misses it is answered 503 REQUEST_TIMEOUT. It is
+	// under the shutdown StopTimeout, which the config checks, so requests
+	// in flight can finish. Task streams are meant to stay open, so they
+	// have none.
+	e.Use(middleware.ContextTimeoutWithConfig(middleware.ContextTimeoutConfig{
+		Skipper: func(c echo.Context) bool { return strings.HasSuffix(c.Path(), "/tasks/stream") },
+		Timeout: cfg.Server.RequestTimeout,
+	}))
+	// Streams only end when their client goes away, so shutting down ends
+	// them first: otherwise they would hold it up until the StopTimeout
+	e.Server.RegisterOnShutdown(func() { taskEvents.Close(context.Background()) })
+
+	// Routes, registered through a table that also documents them: the
+	// OpenAPI document is served at /docs/openapi.json, with Swagger UI at
+	// /docs. Each version of the API is under /api/v1, /api/v2, ...
+	routes := handler.NewRouteTable(e)
+	handler.RegisterRoutes(routes, handler.Handlers{
+		Auth:    authHandler,
+		Tasks:   taskHandler,
+		Users:   userHandler,
+		GraphQL: graphQLHandler,
+		// Every task and admin route, and GraphQL, acts for the user of the
+		// bearer token; cmd/setrole makes the first admin
+		Authenticate: handler.RequireUser(authUseCase),
+		// Hand-written encoders with pooled buffers (handler/task_json.go)
+		FastJSON: cfg.Server.JSONEncoder == config.JSONFast,
+		// POST /tasks retried with the same Idempotency-Key is answered with
+		// the first response for idempotency.ttl. A claim outlasts any
+		// request, which ends before the stop timeout.
+		Idempotency: handler.NewIdempotency(idempotency, handler.IdempotencyOptions{
+			TTL:     cfg.Idempotency.TTL,
+			Pending: cfg.Server.StopTimeout,
+			Clock:   clock,
+		}),
+		Queries:     handler.NewTaskQueryHandler(taskQueries),
+		Attachments: attachmentHandler,
+		Assignments: assignmentHandler,
+		Archive:     archiveHandler,
+		Search:      handler.NewTaskSearchHandler(taskSearch),
+		BaseURL:     strings.TrimSuffix(cfg.Server.BaseURL, "/"),
+	})
+	handler.RegisterDocs(routes, handler.Info{Title: "Tasks API", Version: "2.0.0"})
+	// For Prometheus to scrape, not for API clients, so it is left out of
+	// the route table and the document
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	// Likewise the liveness and readiness probes
+	e.GET("/healthz", health.Live)
+	e.GET("/readyz", health.Ready)
+
+	// Lifecycle: the server depends on the stores, so it stops and finishes
+	// the requests in flight before they are closed. Tracing stops after
+	// them too, flushing the last spans.
+	lifecycle := shutdown.New(shutdown.Options{StopTimeout: cfg.Server.StopTimeout, Logf: logf})
+	dependencies := []string{"tracing"}
+	for _, store := range stores {
+		dependencies = append(dependencies, store.Name)
+	}
+	lifecycle.Register(stores...)
+	lifecycle.Register(shutdown.Component{Name: "tracing", Stop: stopTracing})
+	// The read model is filled before the server answers from it
+	lifecycle.Register(shutdown.Component{
+		Name:      "read-model",
+		DependsOn: dependencies,
+		Start:     taskQueries.Start,
+		Stop:      taskQueries.Stop,
+	})
+
+	// The index is closed once nothing writes to it
+	lifecycle.Register(shutdown.Component{Name: "search-index", Stop: searchIndex.Close})
+	lifecycle.Register(shutdown.Component{
+		Name:      "search",
+		DependsOn: append([]string{"search-index"}, dependencies...),
+		Start:     taskSearch.Start,
+		Stop:      taskSearch.Stop,
+	})
+
+	// The queue stops after the reminders, which enqueue on it
+	lifecycle.Register(shutdown.Component{
+		Name:      "jobs",
+		DependsOn: dependencies,
+		Start:     jobs.Start,
+		Stop:      jobs.Stop,
+	})
+	lifecycle.Register(shutdown.Component{
+		Name:      "reminders",
+		DependsOn: append([]string{"jobs"}, dependencies...),
+		Start:     reminders.Start,
+		Stop:      reminders.Stop,
+	})
+
+	// Domain events are delivered from the outbox, at least once, to the
+	// log and to events.webhook_url if set, signed with
+	// events.webhook_secret
+	if outbox != nil {
+		handlers := []usecase.EventHandler{infrastructure.LogEventHandler{Logf: logf}}
+		if url := cfg.Events.WebhookURL; url != "" {
+			handlers = append(handlers, infrastructure.WebhookEventHandler{
+				URL:    url,
+				Secret: []byte(cfg.Events.WebhookSecret),
+			})
+		}
+		dispatcher := usecase.NewEventDispatcher(outbox, usecase.DispatcherOptions{Clock: clock, Logf: logf}, handlers...)
+		lifecycle.Register(shutdown.Component{
+			Name:      "events",
+			DependsOn: dependencies,
+			Start:     dispatcher.Start,
+			Stop:      dispatcher.Stop,
+		})
+	}
+
+	if archiver != nil {
+		lifecycle.Register(shutdown.Component{
+			Name:      "archiver",
+			DependsOn: dependencies,
+			Start:     archiver.Start,
+			Stop:      archiver.Stop,
+		})
+	}
+
+	return &App{
+		Echo:         e,
+		Lifecycle:    lifecycle,
+		Dependencies: append([]string{"read-model", "search"}, dependencies...),
+		Health:       health,
+	}, nil
+}
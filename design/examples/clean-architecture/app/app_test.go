@@ -0,0 +1,407 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/app"
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// The end-to-end test boots the whole server as main.go wires it, with every
+// store in memory (Options.Memory), serves it from httptest and drives the
+// REST API over real connections the way a client would: signing up, then a
+// task from creation through listing, updating and completing to deletion,
+// with the mistakes a client makes along the way. Every response is checked
+// for its status and its whole body.
+
+// client is a user of the API: requests carry its token once it has one
+type client struct {
+	t     *testing.T
+	base  string
+	token string
+	id    int64 // of the account logged in as
+	// tenant, if set, is named by X-Tenant-ID, and host, if set, is the Host
+	// of every request
+	tenant, host string
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// problem is the problem document of an error response
+type problem struct {
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+func (cl *client) do(method, path string, body any, headers ...string) response {
+	cl.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		if raw, ok := body.(string); ok {
+			reader = strings.NewReader(raw)
+		} else if stream, ok := body.(io.Reader); ok {
+			reader = stream
+		} else {
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				cl.t.Fatal(err)
+			}
+			reader = bytes.NewReader(encoded)
+		}
+	}
+	req, err := http.NewRequest(method, cl.base+path, reader)
+	if err != nil {
+		cl.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cl.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cl.token)
+	}
+	if cl.tenant != "" {
+		req.Header.Set(handler.HeaderTenantID, cl.tenant)
+	}
+	if cl.host != "" {
+		req.Host = cl.host
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cl.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		cl.t.Fatal(err)
+	}
+	return response{status: res.StatusCode, header: res.Header, body: data}
+}
+
+// decode reads the body into v, reporting whether it is valid JSON of v's
+// shape with nothing left over
+func (r response) decode(v any) bool {
+	decoder := json.NewDecoder(bytes.NewReader(r.body))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v) == nil && !decoder.More()
+}
+
+// isProblem reports whether r is the problem document of status and code
+func (r response) isProblem(status int, code string) bool {
+	var p problem
+	return r.status == status && strings.HasPrefix(r.header.Get("Content-Type"), "application/problem+json") &&
+		json.Unmarshal(r.body, &p) == nil && p.Status == status && p.Code == code && p.Detail != ""
+}
+
+// signUp registers an account and logs in as it
+func signUp(t *testing.T, base, email string) *client {
+	t.Helper()
+	cl := &client{t: t, base: base}
+	credentials := handler.CredentialsRequest{Email: email, Password: "correct horse"}
+	res := cl.do(http.MethodPost, "/auth/register", credentials)
+	var user handler.UserResponse
+	if !(res.status == http.StatusCreated && res.decode(&user) && user.ID > 0 && user.Email == email && user.Role == "user") {
+		t.Errorf("%s registers: 201, as a user", email)
+	}
+	res = cl.do(http.MethodPost, "/auth/login", credentials)
+	var token handler.TokenResponse
+	if !(res.status == http.StatusOK && res.decode(&token) && token.AccessToken != "" && token.TokenType == "Bearer" && token.User.ID == user.ID) {
+		t.Error("and logs in: 200, with a bearer token")
+	}
+	cl.token, cl.id = token.AccessToken, user.ID
+	return cl
+}
+
+func testAccounts(t *testing.T, base string) (alice, bob *client) {
+	anonymous := &client{t: t, base: base}
+	res := anonymous.do(http.MethodGet, "/tasks", nil)
+	if !res.isProblem(http.StatusUnauthorized, "AUTH_TOKEN_MISSING") {
+		t.Error("without a token, /tasks is 401 AUTH_TOKEN_MISSING")
+	}
+	alice = signUp(t, base, "alice@example.com")
+	bob = signUp(t, base, "bob@example.com")
+	res = anonymous.do(http.MethodPost, "/auth/register", handler.CredentialsRequest{Email: "alice@example.com", Password: "another one"})
+	if !res.isProblem(http.StatusConflict, "USER_EMAIL_TAKEN") {
+		t.Error("registering the same email again is 409 USER_EMAIL_TAKEN")
+	}
+	res = anonymous.do(http.MethodPost, "/auth/login", handler.CredentialsRequest{Email: "alice@example.com", Password: "wrong horse"})
+	if !res.isProblem(http.StatusUnauthorized, "AUTH_INVALID_CREDENTIALS") {
+		t.Error("a wrong password is 401 AUTH_INVALID_CREDENTIALS")
+	}
+	return alice, bob
+}
+
+// logs collects what the server logs, from its goroutines
+type logs struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logs) printf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *logs) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventually polls ok for up to five seconds
+func eventually(ok func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if ok() {
+			return true
+		}
+	}
+	return ok()
+}
+
+// sameTask compares everything but the times, which must be RFC 3339
+func sameTask(got, want handler.TaskResponse) bool {
+	_, created := time.Parse(time.RFC3339, got.CreatedAt)
+	_, updated := time.Parse(time.RFC3339, got.UpdatedAt)
+	got.CreatedAt, got.UpdatedAt = "", ""
+	return created == nil && updated == nil && reflect.DeepEqual(got, want)
+}
+
+func testLifecycle(t *testing.T, alice, bob *client) {
+	res := alice.do(http.MethodPost, "/tasks", handler.CreateTaskRequest{
+		Title: "Write the report", Description: "Quarterly numbers", Priority: "high", Tags: []string{"Work", "q3"},
+	})
+	var created handler.TaskResponse
+	want := handler.TaskResponse{
+		ID: 1, OwnerID: 1, Title: "Write the report", Description: "Quarterly numbers",
+		Priority: "high", Tags: []string{"q3", "work"}, Version: 1,
+	}
+	if !(res.status == http.StatusCreated && res.decode(&created) && sameTask(created, want)) {
+		t.Error("create: 201, the task as stored, tags normalized, version 1")
+	}
+	id := fmt.Sprintf("/tasks/%d", created.ID)
+	res = alice.do(http.MethodPost, "/tasks", handler.CreateTaskRequest{Title: "Buy milk"})
+	var milk handler.TaskResponse
+	if !(res.status == http.StatusCreated && res.decode(&milk) && milk.Priority == "medium" && len(milk.Tags) == 0 && milk.Tags != nil) {
+		t.Error("a second one, without labels: medium priority, and tags [] rather than null")
+	}
+
+	var got handler.TaskResponse
+	res = alice.do(http.MethodGet, id, nil)
+	if !(res.status == http.StatusOK && res.decode(&got) && sameTask(got, want)) {
+		t.Error("get: 200, the same task")
+	}
+	var listed []handler.TaskResponse
+	res = alice.do(http.MethodGet, "/tasks", nil)
+	if !(res.status == http.StatusOK && res.decode(&listed) && len(listed) == 2 && listed[0].ID == milk.ID && listed[1].ID == created.ID) {
+		t.Error("list: 200, both tasks, newest first")
+	}
+	res = alice.do(http.MethodGet, "/tasks?tag=work", nil)
+	if !(res.status == http.StatusOK && res.decode(&listed) && len(listed) == 1 && listed[0].ID == created.ID) {
+		t.Error("list ?tag=work: only the report")
+	}
+
+	update := handler.UpdateTaskRequest{Title: "Write the Q3 report", Description: "Quarterly numbers", Version: 1}
+	res = alice.do(http.MethodPut, id, update)
+	var updated handler.TaskResponse
+	want.Title, want.Version = "Write the Q3 report", 2
+	if !(res.status == http.StatusOK && res.decode(&updated) && sameTask(updated, want)) {
+		t.Error("update from version 1: 200, version 2, priority and tags kept")
+	}
+	update.Title = "Lost update"
+	res = alice.do(http.MethodPut, id, update)
+	if !res.isProblem(http.StatusConflict, "TASK_VERSION_CONFLICT") {
+		t.Error("updating from version 1 again: 409 TASK_VERSION_CONFLICT")
+	}
+
+	res = alice.do(http.MethodPut, id, handler.UpdateTaskRequest{Title: updated.Title, Description: updated.Description, Completed: true, Version: 2})
+	want.Completed, want.Version = true, 3
+	if !(res.status == http.StatusOK && res.decode(&updated) && sameTask(updated, want)) {
+		t.Error("complete: 200, completed, version 3")
+	}
+	res = alice.do(http.MethodGet, "/tasks?completed=false", nil)
+	if !(res.status == http.StatusOK && res.decode(&listed) && len(listed) == 1 && listed[0].ID == milk.ID) {
+		t.Error("list ?completed=false: only the milk")
+	}
+
+	res = bob.do(http.MethodGet, id, nil)
+	if !res.isProblem(http.StatusNotFound, "TASK_NOT_FOUND") {
+		t.Error("Bob getting Alice's task: 404 TASK_NOT_FOUND, as if it did not exist")
+	}
+	res = bob.do(http.MethodDelete, id, nil)
+	if !res.isProblem(http.StatusNotFound, "TASK_NOT_FOUND") {
+		t.Error("nor can he delete it")
+	}
+	res = bob.do(http.MethodGet, "/tasks", nil)
+	if !(res.status == http.StatusOK && string(res.body) == "[]\n") {
+		t.Error("his list is empty: [] rather than null")
+	}
+
+	res = alice.do(http.MethodDelete, id, nil)
+	if !(res.status == http.StatusNoContent && len(res.body) == 0) {
+		t.Error("delete: 204, no body")
+	}
+	res = alice.do(http.MethodGet, id, nil)
+	if !res.isProblem(http.StatusNotFound, "TASK_NOT_FOUND") {
+		t.Error("get after delete: 404 TASK_NOT_FOUND")
+	}
+	res = alice.do(http.MethodDelete, id, nil)
+	if !res.isProblem(http.StatusNotFound, "TASK_NOT_FOUND") {
+		t.Error("delete again: 404 TASK_NOT_FOUND")
+	}
+	res = alice.do(http.MethodGet, "/tasks", nil)
+	if !(res.status == http.StatusOK && res.decode(&listed) && len(listed) == 1 && listed[0].ID == milk.ID) {
+		t.Error("list: only the milk is left")
+	}
+}
+
+func testMistakes(t *testing.T, alice *client) {
+	for _, mistake := range []struct {
+		what, method, path string
+		body               any
+		status             int
+		code               string
+	}{
+		{"an empty title", http.MethodPost, "/tasks", handler.CreateTaskRequest{}, 400, "TASK_TITLE_EMPTY"},
+		{"an unknown priority", http.MethodPost, "/tasks", handler.CreateTaskRequest{Title: "x", Priority: "urgent"}, 400, "TASK_PRIORITY_INVALID"},
+		{"a body that is not JSON", http.MethodPost, "/tasks", `{"title":`, 400, "REQUEST_INVALID_BODY"},
+		{"an ID that is not a number", http.MethodGet, "/tasks/abc", nil, 400, "REQUEST_INVALID_TASK_ID"},
+		{"an unknown filter value", http.MethodGet, "/tasks?completed=maybe", nil, 400, "REQUEST_INVALID_QUERY"},
+		{"an update of a task that never was", http.MethodPut, "/tasks/999", handler.UpdateTaskRequest{Title: "x"}, 404, "TASK_NOT_FOUND"},
+		{"a route that does not exist", http.MethodGet, "/projects", nil, 404, "ROUTE_NOT_FOUND"},
+	} {
+		res := alice.do(mistake.method, mistake.path, mistake.body)
+		if !res.isProblem(mistake.status, mistake.code) {
+			t.Errorf("%s: %d %s", mistake.what, mistake.status, mistake.code)
+		}
+	}
+	var listed []handler.TaskResponse
+	res := alice.do(http.MethodGet, "/tasks", nil)
+	if !(res.decode(&listed) && len(listed) == 1) {
+		t.Error("and none of them stored anything")
+	}
+}
+
+func testAround(t *testing.T, alice *client, logged *logs) {
+	request := handler.CreateTaskRequest{Title: "Book flights"}
+	first := alice.do(http.MethodPost, "/tasks", request, handler.HeaderIdempotencyKey, "trip-1")
+	retry := alice.do(http.MethodPost, "/tasks", request, handler.HeaderIdempotencyKey, "trip-1")
+	if !(first.status == http.StatusCreated && retry.status == http.StatusCreated && bytes.Equal(first.body, retry.body) &&
+		retry.header.Get(handler.HeaderIdempotentReplayed) == "true") {
+		t.Error("a create retried with the same Idempotency-Key is replayed, not repeated")
+	}
+
+	var summary handler.TaskSummaryResponse
+	eventually(func() bool {
+		return alice.do(http.MethodGet, "/tasks/summary", nil).decode(&summary) && summary.Total == 2
+	})
+	if !(summary.Total == 2 && summary.Open == 2 && summary.ByPriority["medium"] == 2) {
+		t.Error("the summary catches up with the writes: 2 open, both medium")
+	}
+	var activity []handler.TaskActivityResponse
+	res := alice.do(http.MethodGet, "/tasks/activity?limit=3", nil)
+	var kinds []string
+	if res.decode(&activity) {
+		for _, a := range activity {
+			kinds = append(kinds, a.Kind)
+		}
+	}
+	if !(res.status == http.StatusOK && reflect.DeepEqual(kinds, []string{"created", "deleted", "completed"})) {
+		t.Errorf("and activity lists the latest changes, newest first %v", kinds)
+	}
+
+	if !eventually(func() bool { return logged.contains("TaskCompleted task 1 ") }) {
+		t.Error("the outbox delivers the domain events, to the log: the report was completed")
+	}
+
+	res = alice.do(http.MethodGet, "/docs/openapi.json", nil)
+	if !(res.status == http.StatusOK && json.Valid(res.body)) {
+		t.Error("the OpenAPI document is served")
+	}
+	res = alice.do(http.MethodGet, "/readyz", nil)
+	if !(res.status == http.StatusOK) {
+		t.Error("and the server is ready")
+	}
+}
+
+// newServer serves a fresh app from httptest until the test ends, checking
+// that every component then stops cleanly
+func newServer(t *testing.T) (*httptest.Server, *logs) {
+	t.Helper()
+	env := map[string]string{"JWT_SECRET": strings.Repeat("e2e-secret-", 4)}
+	cfg, err := config.Load(nil, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := &logs{}
+	a, err := app.New(cfg, app.Options{
+		Memory:    true,
+		Logf:      logged.printf,
+		AccessLog: io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := a.Lifecycle.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Echo)
+	t.Cleanup(func() {
+		server.Close()
+		if err := a.Lifecycle.Stop(ctx).Err(); err != nil {
+			t.Errorf("stopping the components: %v", err)
+		}
+	})
+	return server, logged
+}
+
+// TestEndToEnd runs its steps in order on one server: each starts from what
+// the ones before it left behind, so a failed step stops the test
+func TestEndToEnd(t *testing.T) {
+	server, logged := newServer(t)
+	var alice, bob *client
+	steps := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"accounts", func(t *testing.T) { alice, bob = testAccounts(t, server.URL) }},
+		{"a task from creation to deletion", func(t *testing.T) { testLifecycle(t, alice.on(t), bob.on(t)) }},
+		{"mistakes", func(t *testing.T) { testMistakes(t, alice.on(t)) }},
+		{"retries, the read side and the documents", func(t *testing.T) { testAround(t, alice.on(t), logged) }},
+	}
+	for _, step := range steps {
+		if !t.Run(step.name, step.run) {
+			t.FailNow()
+		}
+	}
+}
+
+// on returns the client reporting to t, for a later subtest
+func (cl *client) on(t *testing.T) *client {
+	return &client{t: t, base: cl.base, token: cl.token, id: cl.id, tenant: cl.tenant, host: cl.host}
+}
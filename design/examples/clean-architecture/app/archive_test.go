@@ -0,0 +1,81 @@
+package app_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// TestArchivedTasksEndpoint drives GET /tasks/archived on the server in
+// memory and on SQLite, which archive a task a moment after it is completed
+func TestArchivedTasksEndpoint(t *testing.T) {
+	env := func(dir string, memory bool) map[string]string {
+		env := map[string]string{
+			"JWT_SECRET":        strings.Repeat("archive-secret-", 3),
+			"ARCHIVE_RETENTION": "50ms",
+			"ARCHIVE_INTERVAL":  "20ms",
+		}
+		if !memory {
+			env["DB_DSN"] = filepath.Join(dir, "tasks.db")
+		}
+		return env
+	}
+	servers := []struct {
+		name   string
+		memory bool
+	}{
+		{"memory", true},
+		{"sqlite", false},
+	}
+	for _, s := range servers {
+		t.Run(s.name, func(t *testing.T) {
+			server := serveApp(t, env(t.TempDir(), s.memory), s.memory)
+			alice := signUp(t, server.URL, "alice@example.com")
+			var created handler.TaskResponse
+			if res := alice.do(http.MethodPost, "/tasks", `{"title":"Write the report","tags":["work"]}`); !res.decode(&created) {
+				t.Fatalf("creating a task = %d %s", res.status, res.body)
+			}
+			alice.do(http.MethodPost, "/tasks", `{"title":"Buy milk"}`)
+			one := fmt.Sprintf("/tasks/%d", created.ID)
+
+			if res := alice.do(http.MethodGet, "/tasks/archived", nil); res.status != http.StatusOK || string(res.body) != "[]\n" {
+				t.Errorf("GET /tasks/archived = %d %s, want 200 and empty at first", res.status, res.body)
+			}
+			alice.do(http.MethodPut, one, `{"title":"Write the report","tags":["work"],"completed":true}`)
+			var list []handler.ArchivedTaskResponse
+			eventually(func() bool {
+				return alice.do(http.MethodGet, "/tasks/archived", nil).decode(&list) && len(list) > 0
+			})
+			if len(list) != 1 || list[0].ID != created.ID || !list[0].Completed || !reflect.DeepEqual(list[0].Tags, []string{"work"}) {
+				t.Fatalf("once completed past the retention, GET /tasks/archived = %+v, want the task", list)
+			}
+			if _, err := time.Parse(time.RFC3339, list[0].ArchivedAt); err != nil {
+				t.Errorf("archived_at = %q, want RFC 3339", list[0].ArchivedAt)
+			}
+
+			if res := alice.do(http.MethodGet, one, nil); res.status != http.StatusNotFound {
+				t.Errorf("GET /tasks/:id of the archived task = %d, want 404", res.status)
+			}
+			var tasks []handler.TaskResponse
+			if res := alice.do(http.MethodGet, "/tasks", nil); !res.decode(&tasks) || len(tasks) != 1 || tasks[0].Title != "Buy milk" {
+				t.Errorf("GET /tasks = %s, want the open task only", res.body)
+			}
+			if res := alice.do(http.MethodGet, "/tasks/archived?owner=99", nil); !res.isProblem(http.StatusForbidden, "AUTH_FORBIDDEN") {
+				t.Errorf("another owner's archive = %d %s, want 403 AUTH_FORBIDDEN", res.status, res.body)
+			}
+			if res := alice.do(http.MethodGet, "/tasks/archived?owner=x", nil); res.status != http.StatusBadRequest {
+				t.Errorf("an owner that is not a number = %d, want 400", res.status)
+			}
+			anonymous := &client{t: t, base: server.URL}
+			if res := anonymous.do(http.MethodGet, "/tasks/archived", nil); res.status != http.StatusUnauthorized {
+				t.Errorf("without a token = %d, want 401", res.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package app_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// TestAssignmentEndpoints drives the assignment endpoints of the server in
+// memory and on SQLite
+func TestAssignmentEndpoints(t *testing.T) {
+	secret := strings.Repeat("assignment-secret-", 2)
+	servers := []struct {
+		name   string
+		env    func(dir string) map[string]string
+		memory bool
+	}{
+		{"memory", func(string) map[string]string { return map[string]string{"JWT_SECRET": secret} }, true},
+		{"sqlite", func(dir string) map[string]string {
+			return map[string]string{"JWT_SECRET": secret, "DB_DSN": filepath.Join(dir, "tasks.db")}
+		}, false},
+	}
+	for _, s := range servers {
+		t.Run(s.name, func(t *testing.T) {
+			server := serveApp(t, s.env(t.TempDir()), s.memory)
+			alice, bob := signUp(t, server.URL, "alice@example.com"), signUp(t, server.URL, "bob@example.com")
+			var created handler.TaskResponse
+			if res := alice.do(http.MethodPost, "/tasks", `{"title":"Write the report"}`); !res.decode(&created) || created.AssigneeID != 0 {
+				t.Fatalf("creating a task = %d %s, want it assigned to nobody", res.status, res.body)
+			}
+			one := fmt.Sprintf("/tasks/%d", created.ID)
+			var assigned handler.TaskResponse
+			res := alice.do(http.MethodPut, one+"/assignee", fmt.Sprintf(`{"assignee_id":%d,"version":1}`, bob.id))
+			if !res.decode(&assigned) || res.status != http.StatusOK || assigned.AssigneeID != bob.id || assigned.Version != 2 {
+				t.Errorf("assigning it to Bob = %d %s, want 200 and the task assigned to him, as version 2", res.status, res.body)
+			}
+
+			// The steps run in order on the one task
+			steps := []assignmentStep{
+				{"assigning it from version 1 again", alice, http.MethodPut, one + "/assignee", fmt.Sprintf(`{"assignee_id":%d,"version":1}`, bob.id), http.StatusConflict, "TASK_VERSION_CONFLICT", 0},
+				{"assigning it to no one", alice, http.MethodPut, one + "/assignee", `{"assignee_id":0}`, http.StatusBadRequest, "REQUEST_INVALID_BODY", 0},
+				{"assigning it to nobody registered", alice, http.MethodPut, one + "/assignee", `{"assignee_id":99}`, http.StatusBadRequest, "TASK_ASSIGNEE_UNKNOWN", 0},
+				{"Bob getting it", bob, http.MethodGet, one, "", http.StatusOK, "", bob.id},
+				{"Bob reassigning it", bob, http.MethodPut, one + "/assignee", fmt.Sprintf(`{"assignee_id":%d}`, alice.id), http.StatusForbidden, "AUTH_FORBIDDEN", 0},
+			}
+			runAssignmentSteps(t, steps)
+
+			var list []handler.TaskResponse
+			if res := bob.do(http.MethodGet, "/tasks/assigned", nil); !res.decode(&list) || len(list) != 1 || list[0].ID != created.ID {
+				t.Errorf("GET /tasks/assigned = %d %s, want Bob's one assigned task", res.status, res.body)
+			}
+			if res := bob.do(http.MethodGet, "/tasks/assigned?completed=true", nil); !res.decode(&list) || len(list) != 0 {
+				t.Errorf("GET /tasks/assigned?completed=true = %d %s, want none", res.status, res.body)
+			}
+
+			steps = []assignmentStep{
+				{"Alice completing it", alice, http.MethodPut, one, `{"title":"Write the report","completed":true}`, http.StatusOK, "", bob.id},
+				{"assigning it once completed", alice, http.MethodPut, one + "/assignee", fmt.Sprintf(`{"assignee_id":%d}`, alice.id), http.StatusConflict, "TASK_ASSIGN_COMPLETED", 0},
+				{"unassigning it from a stale version", alice, http.MethodDelete, one + "/assignee?version=1", "", http.StatusConflict, "TASK_VERSION_CONFLICT", 0},
+				{"unassigning it", alice, http.MethodDelete, one + "/assignee", "", http.StatusOK, "", 0},
+				{"Bob getting it once unassigned", bob, http.MethodGet, one, "", http.StatusNotFound, "TASK_NOT_FOUND", 0},
+			}
+			runAssignmentSteps(t, steps)
+		})
+	}
+}
+
+// assignmentStep is a request and the response it should get
+type assignmentStep struct {
+	name         string
+	cl           *client
+	method, path string
+	body         string
+	status       int
+	code         string // of the problem, if status is an error
+	assignee     int64  // of the task, if status is 200
+}
+
+func runAssignmentSteps(t *testing.T, steps []assignmentStep) {
+	t.Helper()
+	for _, step := range steps {
+		var body any
+		if step.body != "" {
+			body = step.body
+		}
+		res := step.cl.on(t).do(step.method, step.path, body)
+		if step.status == http.StatusOK {
+			var task handler.TaskResponse
+			if !res.decode(&task) || res.status != http.StatusOK || task.AssigneeID != step.assignee {
+				t.Errorf("%s = %d %s, want 200 and the task assigned to %d", step.name, res.status, res.body, step.assignee)
+			}
+		} else if !res.isProblem(step.status, step.code) {
+			t.Errorf("%s = %d %s, want %d %s", step.name, res.status, res.body, step.status, step.code)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/app"
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// letters is n bytes of text, made as they are read: a body of unknown
+// length, sent in chunks
+type letters struct{ n int64 }
+
+func (l *letters) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	for i := range p {
+		p[i] = 'a'
+	}
+	l.n -= int64(len(p))
+	return len(p), nil
+}
+
+// serveApp serves an app wired from env until the test ends
+func serveApp(t *testing.T, env map[string]string, memory bool) *httptest.Server {
+	t.Helper()
+	cfg, err := config.Load(nil, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.New(cfg, app.Options{Memory: memory, Logf: func(string, ...any) {}, AccessLog: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := a.Lifecycle.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Echo)
+	t.Cleanup(func() {
+		server.Close()
+		if err := a.Lifecycle.Stop(ctx).Err(); err != nil {
+			t.Errorf("stopping the components: %v", err)
+		}
+	})
+	return server
+}
+
+// TestAttachmentEndpoints drives the attachment endpoints of the server in
+// memory, and on SQLite with a directory
+func TestAttachmentEndpoints(t *testing.T) {
+	secret := strings.Repeat("attachment-secret-", 2)
+	servers := []struct {
+		name   string
+		env    func(dir string) map[string]string
+		memory bool
+	}{
+		{"memory", func(string) map[string]string { return map[string]string{"JWT_SECRET": secret} }, true},
+		{"sqlite", func(dir string) map[string]string {
+			return map[string]string{
+				"JWT_SECRET":      secret,
+				"DB_DSN":          filepath.Join(dir, "tasks.db"),
+				"ATTACHMENTS_DIR": filepath.Join(dir, "attachments"),
+			}
+		}, false},
+	}
+	for _, s := range servers {
+		t.Run(s.name, func(t *testing.T) {
+			server := serveApp(t, s.env(t.TempDir()), s.memory)
+			alice, bob := signUp(t, server.URL, "alice@example.com"), signUp(t, server.URL, "bob@example.com")
+			var task handler.TaskResponse
+			if res := alice.do(http.MethodPost, "/tasks", `{"title":"Write the report"}`); !res.decode(&task) {
+				t.Fatalf("creating the task: %d %s", res.status, res.body)
+			}
+			base := fmt.Sprintf("/tasks/%d/attachments", task.ID)
+			plain := []string{"Content-Type", "text/plain"}
+
+			res := alice.do(http.MethodPut, base+"/my%20notes.txt", "Numbers first", plain...)
+			var uploaded handler.AttachmentResponse
+			if res.status != http.StatusOK || !res.decode(&uploaded) || uploaded.Name != "my notes.txt" ||
+				uploaded.ContentType != "text/plain" || uploaded.Size != 13 || uploaded.TaskID != task.ID {
+				t.Errorf("PUT .../my%%20notes.txt = %d %s, want 200 and the attachment described", res.status, res.body)
+			}
+			res = alice.do(http.MethodGet, base+"/my%20notes.txt", nil)
+			if res.status != http.StatusOK || string(res.body) != "Numbers first" || res.header.Get("Content-Type") != "text/plain" ||
+				res.header.Get("Content-Length") != "13" || res.header.Get("X-Content-Type-Options") != "nosniff" ||
+				res.header.Get("Content-Disposition") != `attachment; filename="my notes.txt"` {
+				t.Errorf("GET = %d %q %v, want it streamed back with its type and length, as a download browsers may not sniff", res.status, res.body, res.header)
+			}
+			if res = alice.do(http.MethodPut, base+"/chart.png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "Content-Type", "image/png"); res.status != http.StatusOK {
+				t.Errorf("uploading a PNG = %d %s, want 200", res.status, res.body)
+			}
+			if res = bob.do(http.MethodGet, base, nil); !res.isProblem(http.StatusNotFound, "TASK_NOT_FOUND") {
+				t.Errorf("Bob listing them = %d %s, want 404 TASK_NOT_FOUND", res.status, res.body)
+			}
+			var list []handler.AttachmentResponse
+			res = alice.do(http.MethodGet, base, nil)
+			if res.status != http.StatusOK || !res.decode(&list) || len(list) != 2 || list[0].Name != "chart.png" || list[1].Name != "my notes.txt" {
+				t.Errorf("GET .../attachments = %d %s, want both, by name", res.status, res.body)
+			}
+
+			refused := []struct {
+				name, method, path string
+				body               any
+				contentType        string
+				status             int
+				code               string
+			}{
+				{"over 10 MiB, streamed", http.MethodPut, base + "/big.txt", &letters{n: usecase.MaxAttachmentSize + 1}, "text/plain", http.StatusBadRequest, "ATTACHMENT_TOO_LARGE"},
+				{"HTML", http.MethodPut, base + "/page.html", "<html>", "text/html", http.StatusBadRequest, "ATTACHMENT_TYPE_NOT_ALLOWED"},
+				{"a name with an escaped slash", http.MethodPut, base + "/a%2Fb.txt", "text", "text/plain", http.StatusBadRequest, "ATTACHMENT_NAME_INVALID"},
+				{"a missing one", http.MethodGet, base + "/missing.txt", nil, "", http.StatusNotFound, "ATTACHMENT_NOT_FOUND"},
+			}
+			for _, tt := range refused {
+				var headers []string
+				if tt.contentType != "" {
+					headers = []string{"Content-Type", tt.contentType}
+				}
+				if res := alice.do(tt.method, tt.path, tt.body, headers...); !res.isProblem(tt.status, tt.code) {
+					t.Errorf("%s = %d %s, want %d %s", tt.name, res.status, res.body, tt.status, tt.code)
+				}
+			}
+
+			if res = alice.do(http.MethodDelete, base+"/chart.png", nil); res.status != http.StatusNoContent {
+				t.Errorf("DELETE = %d %s, want 204", res.status, res.body)
+			}
+			if res = alice.do(http.MethodGet, base, nil); !res.decode(&list) || len(list) != 1 {
+				t.Errorf("after the delete, GET = %s, want one left", res.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/app"
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// TestServerOnAClock boots the server on app.Options.Clock: the times it
+// answers with are the clock's, and its tokens expire once the clock passes
+// auth.token_ttl
+func TestServerOnAClock(t *testing.T) {
+	env := map[string]string{"JWT_SECRET": strings.Repeat("clock-secret-", 3)}
+	cfg, err := config.Load(nil, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := clocktest.New(time.Date(2030, 7, 1, 9, 0, 0, 0, time.UTC))
+	a, err := app.New(cfg, app.Options{Memory: true, Clock: clock, Logf: func(string, ...any) {}, AccessLog: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := a.Lifecycle.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Echo)
+	defer func() {
+		server.Close()
+		if err := a.Lifecycle.Stop(ctx).Err(); err != nil {
+			t.Errorf("stopping the components: %v", err)
+		}
+	}()
+	ann := signUp(t, server.URL, "ann@example.com")
+
+	res := ann.do(http.MethodPost, "/api/v1/tasks", `{"title":"Write the report","due_at":"2030-07-02T09:00:00Z"}`)
+	var task handler.TaskResponse
+	if res.status != http.StatusCreated || !res.decode(&task) || task.CreatedAt != "2030-07-01T09:00:00Z" || task.UpdatedAt != task.CreatedAt {
+		t.Fatalf("POST /api/v1/tasks = %d %s, want 201, created at the clock's time", res.status, res.body)
+	}
+	clock.Advance(25 * time.Minute)
+	res = ann.do(http.MethodPost, fmt.Sprintf("/api/v1/tasks/%d/complete", task.ID), nil)
+	if res.status != http.StatusOK || !res.decode(&task) || task.UpdatedAt != "2030-07-01T09:25:00Z" {
+		t.Errorf("completing the task 25 minutes on = %d %s, want it updated at 09:25", res.status, res.body)
+	}
+	clock.Advance(24 * time.Hour)
+	if res := ann.do(http.MethodGet, fmt.Sprintf("/api/v1/tasks/%d", task.ID), nil); res.status != http.StatusUnauthorized {
+		t.Errorf("a day on, past auth.token_ttl, GET = %d, want 401", res.status)
+	}
+}
@@ -0,0 +1,108 @@
+package app_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// conditional is a request and what it should be answered
+type conditional struct {
+	name         string
+	cl           *client
+	method, path string
+	body         any
+	headers      []string // in pairs: name, value, ...
+	status       int
+	etag         string // if not empty, of the response
+	code         string // if not empty, of the problem
+	title        string // if not empty, of the task answered
+}
+
+// TestConditionalRequests drives If-None-Match and If-Match on one task of
+// the server in memory, on SQLite and with the fast JSON encoder
+func TestConditionalRequests(t *testing.T) {
+	secret := strings.Repeat("etag-secret-", 3)
+	servers := []struct {
+		name   string
+		env    func(dir string) map[string]string
+		memory bool
+	}{
+		{"memory", func(string) map[string]string { return map[string]string{"JWT_SECRET": secret} }, true},
+		{"sqlite", func(dir string) map[string]string {
+			return map[string]string{"JWT_SECRET": secret, "DB_DSN": filepath.Join(dir, "tasks.db")}
+		}, false},
+		{"fast JSON", func(string) map[string]string { return map[string]string{"JWT_SECRET": secret, "JSON_ENCODER": "fast"} }, true},
+	}
+	for _, s := range servers {
+		t.Run(s.name, func(t *testing.T) {
+			server := serveApp(t, s.env(t.TempDir()), s.memory)
+			alice, bob := signUp(t, server.URL, "alice@example.com"), signUp(t, server.URL, "bob@example.com")
+			res := alice.do(http.MethodPost, "/tasks", `{"title":"Write the report"}`)
+			var created handler.TaskResponse
+			if !res.decode(&created) || res.status != http.StatusCreated || res.header.Get(handler.HeaderETag) != `"1"` {
+				t.Fatalf(`POST /tasks = %d %v, want 201 with ETag "1", its version`, res.status, res.header)
+			}
+			one := fmt.Sprintf("/tasks/%d", created.ID)
+			ifNoneMatch := func(etag string) []string { return []string{handler.HeaderIfNoneMatch, etag} }
+			ifMatch := func(etag string) []string { return []string{handler.HeaderIfMatch, etag} }
+
+			// The steps run in order on the one task
+			steps := []conditional{
+				{name: "GET", cl: alice, method: http.MethodGet, path: one, status: http.StatusOK, etag: `"1"`, title: "Write the report"},
+				{name: `GET with If-None-Match "1"`, cl: alice, method: http.MethodGet, path: one, headers: ifNoneMatch(`"1"`), status: http.StatusNotModified, etag: `"1"`},
+				{name: `GET with If-None-Match W/"1", compared weakly`, cl: alice, method: http.MethodGet, path: one, headers: ifNoneMatch(`W/"1"`), status: http.StatusNotModified},
+				{name: "GET with If-None-Match of a list holding it", cl: alice, method: http.MethodGet, path: one, headers: ifNoneMatch(`"7", "1"`), status: http.StatusNotModified},
+				{name: "GET with If-None-Match *", cl: alice, method: http.MethodGet, path: one, headers: ifNoneMatch("*"), status: http.StatusNotModified},
+				{name: `GET with If-None-Match "2"`, cl: alice, method: http.MethodGet, path: one, headers: ifNoneMatch(`"2"`), status: http.StatusOK, title: "Write the report"},
+				{name: "Bob, who cannot see it, with its ETag", cl: bob, method: http.MethodGet, path: one, headers: ifNoneMatch(`"1"`), status: http.StatusNotFound},
+
+				{name: `PUT with If-Match "1"`, cl: alice, method: http.MethodPut, path: one, body: `{"title":"Write the final report"}`, headers: ifMatch(`"1"`), status: http.StatusOK, etag: `"2"`, title: "Write the final report"},
+				{name: `PUT with If-Match "1" again`, cl: alice, method: http.MethodPut, path: one, body: `{"title":"Stale"}`, headers: ifMatch(`"1"`), status: http.StatusPreconditionFailed, code: "REQUEST_PRECONDITION_FAILED"},
+				{name: `PUT with If-Match W/"2", compared strongly`, cl: alice, method: http.MethodPut, path: one, body: `{"title":"Stale"}`, headers: ifMatch(`W/"2"`), status: http.StatusPreconditionFailed, code: "REQUEST_PRECONDITION_FAILED"},
+				{name: "PUT with an If-Match that is no ETag", cl: alice, method: http.MethodPut, path: one, body: `{"title":"Stale"}`, headers: ifMatch("yesterday's"), status: http.StatusPreconditionFailed, code: "REQUEST_PRECONDITION_FAILED"},
+				{name: "PUT with several If-Match, none of them current", cl: alice, method: http.MethodPut, path: one, body: `{"title":"Stale"}`, headers: ifMatch(`"8", "9"`), status: http.StatusPreconditionFailed, code: "REQUEST_PRECONDITION_FAILED"},
+				{name: "GET after the refused PUTs", cl: alice, method: http.MethodGet, path: one, status: http.StatusOK, etag: `"2"`, title: "Write the final report"},
+				{name: "PUT with several If-Match, one of them current", cl: alice, method: http.MethodPut, path: one, body: `{"title":"Write the report, again"}`, headers: ifMatch(`"1", "2"`), status: http.StatusOK, etag: `"3"`},
+				{name: "PUT with If-Match and a body version that disagree", cl: alice, method: http.MethodPut, path: one, body: `{"title":"Write the report","version":2}`, headers: ifMatch(`"3"`), status: http.StatusConflict, code: "TASK_VERSION_CONFLICT"},
+				{name: "PUT with If-Match and a body version that agree", cl: alice, method: http.MethodPut, path: one, body: `{"title":"Write the report","version":3}`, headers: ifMatch(`"3"`), status: http.StatusOK, etag: `"4"`},
+				{name: "PUT without If-Match, from a stale body version", cl: alice, method: http.MethodPut, path: one, body: `{"title":"Write the report","version":1}`, status: http.StatusConflict, code: "TASK_VERSION_CONFLICT"},
+				{name: "PUT with If-Match *, whatever the version", cl: alice, method: http.MethodPut, path: one, body: `{"title":"Write the report"}`, headers: ifMatch("*"), status: http.StatusOK, etag: `"5"`},
+				{name: "Bob's PUT, with its current ETag", cl: bob, method: http.MethodPut, path: one, body: `{"title":"Bob's"}`, headers: ifMatch(`"5"`), status: http.StatusNotFound},
+				{name: "PUT of a task that does not exist", cl: alice, method: http.MethodPut, path: "/tasks/999", body: `{"title":"Nobody's"}`, headers: ifMatch(`"1", "2"`), status: http.StatusNotFound},
+
+				{name: `GET with the old If-None-Match "1"`, cl: alice, method: http.MethodGet, path: one, headers: ifNoneMatch(`"1"`), status: http.StatusOK, etag: `"5"`},
+				{name: "assigning it", cl: alice, method: http.MethodPut, path: one + "/assignee", body: fmt.Sprintf(`{"assignee_id":%d}`, alice.id), status: http.StatusOK, etag: `"6"`},
+			}
+			for _, step := range steps {
+				res := step.cl.do(step.method, step.path, step.body, step.headers...)
+				if res.status != step.status {
+					t.Errorf("%s = %d %s, want %d", step.name, res.status, res.body, step.status)
+					continue
+				}
+				if step.etag != "" && res.header.Get(handler.HeaderETag) != step.etag {
+					t.Errorf("%s: ETag %s, want %s", step.name, res.header.Get(handler.HeaderETag), step.etag)
+				}
+				if step.code != "" && !res.isProblem(step.status, step.code) {
+					t.Errorf("%s = %s, want the problem %s", step.name, res.body, step.code)
+				}
+				var task handler.TaskResponse
+				if step.title != "" && (!res.decode(&task) || task.Title != step.title) {
+					t.Errorf("%s = %s, want the task titled %q", step.name, res.body, step.title)
+				}
+				if step.status == http.StatusNotModified && len(res.body) != 0 {
+					t.Errorf("%s = 304 with a body, %q, want none", step.name, res.body)
+				}
+			}
+
+			res = alice.do(http.MethodGet, one, nil, "Origin", "https://app.example.com")
+			if !strings.Contains(res.header.Get("Access-Control-Expose-Headers"), handler.HeaderETag) {
+				t.Errorf("Access-Control-Expose-Headers = %q, want cross-origin clients able to read the ETag", res.header.Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+}
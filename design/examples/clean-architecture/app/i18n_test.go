@@ -0,0 +1,132 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	sdk "github.com/dong-tran/docs/clean-architecture-example/client"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/i18n"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"golang.org/x/text/language"
+)
+
+// TestLocalizedErrors asks the server for each locale in turn and checks
+// that problem details, bulk item errors and GraphQL errors are answered in
+// its language, with the same codes
+func TestLocalizedErrors(t *testing.T) {
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := serveApp(t, map[string]string{"JWT_SECRET": strings.Repeat("i18n-secret-", 3)}, true)
+	ann := signUp(t, server.URL, "ann@example.com")
+	anonymous := &client{t: t, base: server.URL}
+
+	english := func(e *domain.Error) string { return translator.Message(language.English, e) }
+	vietnamese := func(e *domain.Error) string { return translator.Message(language.Vietnamese, e) }
+	locales := []struct {
+		name     string
+		header   string
+		language string // Content-Language
+		message  func(*domain.Error) string
+		rejected string // the detail of a 405
+	}{
+		{"en, without Accept-Language", "", "en", english, "Method Not Allowed"},
+		{"en, asked for", "en-GB", "en", english, "Method Not Allowed"},
+		{"en, for a language the server does not have", "fr-FR,fr;q=0.9", "en", english, "Method Not Allowed"},
+		{"vi", "vi-VN,vi;q=0.9,en;q=0.8", "vi", vietnamese, vietnamese(handler.ErrRejected)},
+	}
+	for _, l := range locales {
+		t.Run(l.name, func(t *testing.T) {
+			var headers []string
+			if l.header != "" {
+				headers = []string{handler.HeaderAcceptLanguage, l.header}
+			}
+			problems := []struct {
+				name         string
+				cl           *client
+				method, path string
+				body         any
+				status       int
+				code         domain.Code
+				detail       string
+			}{
+				{"a validation error", anonymous, http.MethodPost, "/auth/register", `{"email":"not an email","password":"correct horse"}`,
+					http.StatusBadRequest, domain.ErrInvalidEmail.Code, l.message(domain.ErrInvalidEmail)},
+				{"POST /tasks without a title", ann, http.MethodPost, "/tasks", `{"title":"","priority":"urgent"}`,
+					http.StatusBadRequest, domain.ErrEmptyTitle.Code, l.message(domain.ErrEmptyTitle)},
+				{"without a token", anonymous, http.MethodGet, "/tasks", nil,
+					http.StatusUnauthorized, handler.ErrMissingToken.Code, l.message(handler.ErrMissingToken)},
+				{"an unknown task", ann, http.MethodGet, "/tasks/999", nil,
+					http.StatusNotFound, usecase.ErrTaskNotFound.Code, l.message(usecase.ErrTaskNotFound)},
+				{"an unknown route", anonymous, http.MethodGet, "/no/such/route", nil,
+					http.StatusNotFound, handler.ErrRouteNotFound.Code, l.message(handler.ErrRouteNotFound)},
+				{"a request echo rejects", anonymous, http.MethodDelete, "/auth/login", nil,
+					http.StatusMethodNotAllowed, handler.ErrRejected.Code, l.rejected},
+			}
+			for _, p := range problems {
+				res := p.cl.do(p.method, p.path, p.body, headers...)
+				var got handler.Problem
+				json.Unmarshal(res.body, &got)
+				if res.status != p.status || got.Code != p.code || got.Detail != p.detail {
+					t.Errorf("%s = %d %s %q, want %d %s %q", p.name, res.status, got.Code, got.Detail, p.status, p.code, p.detail)
+				}
+				if lang := res.header.Get(handler.HeaderContentLanguage); lang != l.language {
+					t.Errorf("%s: Content-Language %q, want %q", p.name, lang, l.language)
+				}
+				if !slices.Contains(res.header.Values("Vary"), handler.HeaderAcceptLanguage) {
+					t.Errorf("%s: Vary %q, want Accept-Language", p.name, res.header.Values("Vary"))
+				}
+			}
+			res := anonymous.do(http.MethodHead, "/no/such/route", nil, headers...)
+			if res.status != http.StatusNotFound || len(res.body) != 0 || res.header.Get(handler.HeaderContentLanguage) != l.language {
+				t.Errorf("HEAD of an unknown route = %d %q, Content-Language %q, want 404 without a body", res.status, res.body, res.header.Get(handler.HeaderContentLanguage))
+			}
+
+			res = ann.do(http.MethodPost, "/tasks/bulk", `{"tasks":[{"title":"fine"},{"title":"","priority":"low"}]}`, headers...)
+			var bulk handler.BulkResponse
+			json.Unmarshal(res.body, &bulk)
+			if bulk.Succeeded != 1 || bulk.Failed != 1 || bulk.Results[1].Error == nil ||
+				bulk.Results[1].Error.Code != domain.ErrEmptyTitle.Code || bulk.Results[1].Error.Detail != l.message(domain.ErrEmptyTitle) {
+				t.Errorf("POST /tasks/bulk = %s, want the failed item's error in the same language", res.body)
+			}
+
+			res = ann.do(http.MethodPost, "/graphql", `{"query":"{ task(id: \"999\") { id } }"}`, headers...)
+			var graphQL struct {
+				Errors []struct {
+					Message    string         `json:"message"`
+					Extensions map[string]any `json:"extensions"`
+				} `json:"errors"`
+			}
+			json.Unmarshal(res.body, &graphQL)
+			if len(graphQL.Errors) != 1 || graphQL.Errors[0].Message != l.message(usecase.ErrTaskNotFound) ||
+				graphQL.Errors[0].Extensions["code"] != string(usecase.ErrTaskNotFound.Code) {
+				t.Errorf("GraphQL = %s, want the error's message in the same language, with the same extensions.code", res.body)
+			}
+		})
+	}
+
+	t.Run("the Go client", func(t *testing.T) {
+		ctx := context.Background()
+		api := sdk.New(server.URL, nil).WithToken(ann.token)
+		var apiErr *sdk.APIError
+		_, err := api.WithLanguage("vi").CreateTask(ctx, "", "")
+		if !errors.As(err, &apiErr) || apiErr.Detail != vietnamese(domain.ErrEmptyTitle) {
+			t.Errorf("CreateTask WithLanguage(\"vi\") = %v, want the APIError's detail in Vietnamese", err)
+		}
+		if !errors.Is(err, domain.ErrEmptyTitle) {
+			t.Errorf("the error %v does not match %v", err, domain.ErrEmptyTitle)
+		}
+		_, err = api.CreateTask(ctx, "", "")
+		if !errors.As(err, &apiErr) || apiErr.Detail != domain.ErrEmptyTitle.Message {
+			t.Errorf("CreateTask without a language = %v, want the APIError's detail in English", err)
+		}
+	})
+}
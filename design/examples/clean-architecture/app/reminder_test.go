@@ -0,0 +1,77 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/app"
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// TestReminders creates tasks with due dates through the API, on a server
+// that reminds of them 2h ahead and logs the reminders it sends
+func TestReminders(t *testing.T) {
+	env := map[string]string{"JWT_SECRET": strings.Repeat("job-secret-", 3), "REMINDER_LEAD": "2h"}
+	cfg, err := config.Load(nil, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := &logs{}
+	a, err := app.New(cfg, app.Options{Memory: true, Logf: logged.printf, AccessLog: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := a.Lifecycle.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Echo)
+	defer func() {
+		server.Close()
+		if err := a.Lifecycle.Stop(ctx).Err(); err != nil {
+			t.Errorf("stopping the components: %v", err)
+		}
+	}()
+	ann := signUp(t, server.URL, "ann@example.com")
+
+	due := time.Now().Add(time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+	res := ann.do(http.MethodPost, "/api/v1/tasks", fmt.Sprintf(`{"title":"Pay the rent","due_at":%q}`, due))
+	var task handler.TaskResponse
+	if res.status != http.StatusCreated || !res.decode(&task) || task.DueAt != due {
+		t.Fatalf("POST /api/v1/tasks with due_at = %d %s, want 201 with due_at %s", res.status, res.body, due)
+	}
+	if !eventually(func() bool { return logged.contains(fmt.Sprintf("reminder: task %d ", task.ID)) }) {
+		t.Error("a task due within reminders.lead, 2h, was not reminded of at once")
+	}
+	var v2 handler.TaskResponseV2
+	if res := ann.do(http.MethodGet, fmt.Sprintf("/api/v2/tasks/%d", task.ID), nil); !res.decode(&v2) || v2.Timestamps.DueAt != due {
+		t.Errorf("GET /api/v2/tasks/%d = %s, want due_at among the timestamps", task.ID, res.body)
+	}
+	if res := ann.do(http.MethodPost, "/api/v1/tasks", `{"title":"No rush"}`); res.status != http.StatusCreated || bytes.Contains(res.body, []byte("due_at")) {
+		t.Errorf("creating a task that is not due = %d %s, want 201 without due_at", res.status, res.body)
+	}
+
+	refused := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"in the past", `{"title":"Too late","due_at":"2001-01-01T00:00:00Z"}`, string(domain.ErrDueBeforeCreated.Code)},
+		{"not in RFC 3339", `{"title":"Soon","due_at":"tomorrow"}`, ""},
+	}
+	for _, tt := range refused {
+		res := ann.do(http.MethodPost, "/api/v1/tasks", tt.body)
+		if res.status != http.StatusBadRequest || tt.code != "" && !res.isProblem(http.StatusBadRequest, tt.code) {
+			t.Errorf("a due_at %s = %d %s, want 400 %s", tt.name, res.status, res.body, tt.code)
+		}
+	}
+}
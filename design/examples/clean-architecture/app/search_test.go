@@ -0,0 +1,161 @@
+package app_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/fulltext"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// searched returns the hits of GET /tasks/search with query
+func (cl *client) searched(query string) handler.TaskSearchResponse {
+	var found handler.TaskSearchResponse
+	cl.do(http.MethodGet, "/tasks/search?"+query, nil).decode(&found)
+	return found
+}
+
+// TestSearchEndpoint drives GET /tasks/search on the server in memory and
+// on SQLite
+func TestSearchEndpoint(t *testing.T) {
+	secret := strings.Repeat("search-secret-", 3)
+	servers := []struct {
+		name   string
+		env    func(dir string) map[string]string
+		memory bool
+	}{
+		{"memory", func(string) map[string]string { return map[string]string{"JWT_SECRET": secret} }, true},
+		{"sqlite", func(dir string) map[string]string {
+			return map[string]string{"JWT_SECRET": secret, "DB_DSN": filepath.Join(dir, "tasks.db")}
+		}, false},
+	}
+	for _, s := range servers {
+		t.Run(s.name, func(t *testing.T) {
+			server := serveApp(t, s.env(t.TempDir()), s.memory)
+			ann := signUp(t, server.URL, "ann@example.com")
+			ann.do(http.MethodPost, "/tasks", `{"title":"Draft the launch post for the blog","description":"mention the <beta> signup"}`)
+			ann.do(http.MethodPost, "/tasks", `{"title":"Order launch cake"}`)
+
+			var found handler.TaskSearchResponse
+			if !eventually(func() bool { found = ann.searched("q=launch"); return found.Total == 2 }) {
+				t.Fatalf("q=launch = %+v, want both tasks once indexed", found)
+			}
+			if found.Hits[0].Title != "Order launch cake" || found.Hits[0].Score <= found.Hits[1].Score {
+				t.Errorf("q=launch = %+v, want the shorter title that matches first", found.Hits)
+			}
+			found = ann.searched("q=" + url.QueryEscape("beta signup"))
+			want := map[string][]string{"description": {"mention the &lt;<mark>beta</mark>&gt; <mark>signup</mark>"}}
+			if len(found.Hits) != 1 || !reflect.DeepEqual(found.Hits[0].Highlights, want) {
+				t.Errorf("q=beta signup = %+v, want one hit highlighted %q", found.Hits, want)
+			}
+			found = ann.searched("q=launch&limit=1&offset=1")
+			if found.Total != 2 || len(found.Hits) != 1 || found.Hits[0].Title != "Draft the launch post for the blog" {
+				t.Errorf("the second page of one = %+v, want the other task of 2", found)
+			}
+
+			bob := signUp(t, server.URL, "bob@example.com")
+			if found := bob.searched("q=launch"); found.Total != 0 {
+				t.Errorf("Bob finds %+v, want none of Ann's tasks", found)
+			}
+			refused := []struct {
+				name   string
+				cl     *client
+				query  string
+				status int
+				code   string
+			}{
+				{"another user's", bob, "q=launch&owner=1", http.StatusForbidden, "AUTH_FORBIDDEN"},
+				{"no q", ann, "", http.StatusBadRequest, string(domain.ErrSearchQueryEmpty.Code)},
+				{"limit=x", ann, "q=launch&limit=x", http.StatusBadRequest, string(handler.ErrInvalidQuery.Code)},
+				{"limit=500", ann, "q=launch&limit=500", http.StatusBadRequest, string(usecase.ErrInvalidSearchLimit.Code)},
+				{"without a token", &client{t: t, base: server.URL}, "q=launch", http.StatusUnauthorized, "AUTH_TOKEN_MISSING"},
+			}
+			for _, tt := range refused {
+				if res := tt.cl.do(http.MethodGet, "/tasks/search?"+tt.query, nil); !res.isProblem(tt.status, tt.code) {
+					t.Errorf("searching %s = %d %s, want %d %s", tt.name, res.status, res.body, tt.status, tt.code)
+				}
+			}
+
+			ann.do(http.MethodPost, "/tasks/bulk/delete", `{"ids":[1]}`)
+			if !eventually(func() bool { return ann.searched("q=launch").Total == 1 }) {
+				t.Error("a deleted task is still found")
+			}
+		})
+	}
+}
+
+// TestRebuildSearchIndex serves from SQLite with the index on disk, stores a
+// task behind the server's back and rebuilds the index as cmd/reindex does
+func TestRebuildSearchIndex(t *testing.T) {
+	dir := t.TempDir()
+	env := map[string]string{
+		"JWT_SECRET":       strings.Repeat("search-secret-", 3),
+		"DB_DSN":           filepath.Join(dir, "tasks.db"),
+		"SEARCH_INDEX_DIR": filepath.Join(dir, "search-index"),
+	}
+	credentials := handler.CredentialsRequest{Email: "ann@example.com", Password: "correct horse"}
+	// logIn serves until the subtest ends, as Ann
+	logIn := func(t *testing.T) *client {
+		cl := &client{t: t, base: serveApp(t, env, false).URL}
+		var token handler.TokenResponse
+		if res := cl.do(http.MethodPost, "/auth/login", credentials); !res.decode(&token) {
+			t.Fatalf("logging in = %d %s", res.status, res.body)
+		}
+		cl.token = token.AccessToken
+		return cl
+	}
+
+	t.Run("indexing", func(t *testing.T) {
+		ann := signUp(t, serveApp(t, env, false).URL, "ann@example.com")
+		ann.do(http.MethodPost, "/tasks", `{"title":"Sharpen the pencils"}`)
+		if !eventually(func() bool { return ann.searched("q=pencil").Total == 1 }) {
+			t.Error("the task was not indexed in search.index_dir")
+		}
+	})
+
+	ctx := context.Background()
+	db, err := infrastructure.OpenDatabase(env["DB_DSN"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tasks := repository.NewTaskRepositoryWithColumns(db, repository.DescriptionOnly)
+	offline, err := domain.NewTask(1, "Pencils, more of them", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tasks.Create(ctx, offline); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("restarted", func(t *testing.T) {
+		if n := logIn(t).searched("q=pencil").Total; n != 1 {
+			t.Errorf("q=pencil = %d hits, want the index kept, without the task stored meanwhile", n)
+		}
+	})
+
+	index, err := fulltext.Open(env["SEARCH_INDEX_DIR"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := usecase.NewTaskSearchService(index, tasks, nil, nil).Rebuild(ctx)
+	if closeErr := index.Close(ctx); err != nil || closeErr != nil || n != 2 {
+		t.Fatalf("Rebuild = %d, %v, %v, want the 2 stored tasks indexed", n, err, closeErr)
+	}
+
+	t.Run("rebuilt", func(t *testing.T) {
+		if n := logIn(t).searched("q=pencil").Total; n != 2 {
+			t.Errorf("q=pencil = %d hits, want both tasks", n)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sdk "github.com/dong-tran/docs/clean-architecture-example/client"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// signUpAs registers an account through anonymous, which names its tenant,
+// and logs in as it
+func signUpAs(t *testing.T, anonymous *client, email, password string) (*client, handler.UserResponse) {
+	t.Helper()
+	credentials := handler.CredentialsRequest{Email: email, Password: password}
+	var user handler.UserResponse
+	if res := anonymous.do(http.MethodPost, "/auth/register", credentials); res.status != http.StatusCreated || !res.decode(&user) {
+		t.Fatalf("registering %s = %d %s, want 201", email, res.status, res.body)
+	}
+	var token handler.TokenResponse
+	if res := anonymous.do(http.MethodPost, "/auth/login", credentials); res.status != http.StatusOK || !res.decode(&token) {
+		t.Fatalf("logging in as %s = %d %s, want 200", email, res.status, res.body)
+	}
+	cl := *anonymous
+	cl.token, cl.id = token.AccessToken, user.ID
+	return &cl, user
+}
+
+// TestTenantEndpoints drives two tenants of the server, in memory and on
+// SQLite, with acme named by X-Tenant-ID and globex by a subdomain of
+// TENANCY_DOMAIN
+func TestTenantEndpoints(t *testing.T) {
+	secret := strings.Repeat("tenant-secret-", 3)
+	servers := []struct {
+		name   string
+		env    func(dir string) map[string]string
+		memory bool
+	}{
+		{"memory", func(string) map[string]string {
+			return map[string]string{"JWT_SECRET": secret, "TENANCY_DOMAIN": "tasks.example.com"}
+		}, true},
+		{"sqlite", func(dir string) map[string]string {
+			return map[string]string{"JWT_SECRET": secret, "TENANCY_DOMAIN": "tasks.example.com", "DB_DSN": filepath.Join(dir, "tasks.db")}
+		}, false},
+	}
+	for _, s := range servers {
+		t.Run(s.name, func(t *testing.T) {
+			server := serveApp(t, s.env(t.TempDir()), s.memory)
+			inAcme := &client{t: t, base: server.URL, tenant: "acme"}
+			ann, annUser := signUpAs(t, inAcme, "ann@example.com", "acme password")
+			gus, gusUser := signUpAs(t, &client{t: t, base: server.URL, host: "globex.tasks.example.com"}, "ann@example.com", "globex password")
+			if annUser.Tenant != "acme" || gusUser.Tenant != "globex" || annUser.ID == gusUser.ID {
+				t.Errorf("registered %+v and %+v, want the same email as two accounts, in acme and globex", annUser, gusUser)
+			}
+
+			var plan handler.TaskResponse
+			if res := ann.do(http.MethodPost, "/tasks", `{"title":"Acme plan"}`); res.status != http.StatusCreated || !res.decode(&plan) {
+				t.Fatalf("Ann's POST /tasks = %d %s, want 201", res.status, res.body)
+			}
+			gus.do(http.MethodPost, "/tasks", `{"title":"Globex secret"}`)
+			var listed []handler.TaskResponse
+			if res := ann.do(http.MethodGet, "/tasks", nil); !res.decode(&listed) || len(listed) != 1 || listed[0].Title != "Acme plan" {
+				t.Errorf("Ann's GET /tasks = %s, want acme's task only", res.body)
+			}
+
+			// A token only works in its tenant
+			toGlobex, toAcme, toDefault := *ann, *gus, *gus
+			toGlobex.tenant = "globex"
+			toAcme.host = "acme.tasks.example.com"
+			toDefault.host = ""
+			one := fmt.Sprintf("/tasks/%d", plan.ID)
+			anonymous := func(tenant, host string) *client { return &client{t: t, base: server.URL, tenant: tenant, host: host} }
+			steps := []struct {
+				name         string
+				cl           *client
+				method, path string
+				body         any
+				status       int
+				code         string
+			}{
+				{"globex's password to acme", inAcme, http.MethodPost, "/auth/login", `{"email":"ann@example.com","password":"globex password"}`, http.StatusUnauthorized, ""},
+				{"acme's through its subdomain in any case and with a port", anonymous("", "ACME.tasks.example.com:8080"), http.MethodPost, "/auth/login", `{"email":"ann@example.com","password":"acme password"}`, http.StatusOK, ""},
+				{"acme's token sent to globex", &toGlobex, http.MethodGet, "/tasks", nil, http.StatusUnauthorized, "AUTH_TOKEN_INVALID"},
+				{"globex's token through acme's subdomain", &toAcme, http.MethodGet, one, nil, http.StatusUnauthorized, ""},
+				{"globex's token with no tenant, the default one", &toDefault, http.MethodGet, "/tasks", nil, http.StatusUnauthorized, ""},
+				{"globex getting acme's task", gus, http.MethodGet, one, nil, http.StatusNotFound, ""},
+				{"globex updating it", gus, http.MethodPut, one, `{"title":"Taken over"}`, http.StatusNotFound, ""},
+				{"globex deleting it", gus, http.MethodDelete, one, nil, http.StatusNotFound, ""},
+				{"globex assigning it", gus, http.MethodPut, one + "/assignee", fmt.Sprintf(`{"assignee_id":%d}`, gusUser.ID), http.StatusNotFound, ""},
+				{"Ann assigning it to globex's user, unknown in acme", ann, http.MethodPut, one + "/assignee", fmt.Sprintf(`{"assignee_id":%d}`, gusUser.ID), http.StatusBadRequest, "TASK_ASSIGNEE_UNKNOWN"},
+				{"an invalid X-Tenant-ID", anonymous("Not a tenant", ""), http.MethodPost, "/auth/login", `{"email":"ann@example.com","password":"acme password"}`, http.StatusBadRequest, "TENANT_INVALID"},
+				{"a subdomain of several labels", anonymous("", "a.b.tasks.example.com"), http.MethodGet, "/tasks", nil, http.StatusBadRequest, "TENANT_INVALID"},
+				{"every tenant, with *", anonymous("*", ""), http.MethodGet, "/tasks", nil, http.StatusBadRequest, "TENANT_INVALID"},
+				{"a host outside tasks.example.com, the default tenant", anonymous("", "elsewhere.example.org"), http.MethodPost, "/auth/login", `{"email":"ann@example.com","password":"acme password"}`, http.StatusUnauthorized, ""},
+			}
+			for _, step := range steps {
+				res := step.cl.do(step.method, step.path, step.body)
+				if res.status != step.status || step.code != "" && !res.isProblem(step.status, step.code) {
+					t.Errorf("%s = %d %s, want %d %s", step.name, res.status, res.body, step.status, step.code)
+				}
+			}
+			var got handler.TaskResponse
+			if res := ann.do(http.MethodGet, one, nil); !res.decode(&got) || got.Title != "Acme plan" || got.AssigneeID != 0 {
+				t.Errorf("Ann's task after globex's attempts = %s, want it unchanged", res.body)
+			}
+
+			var summary handler.TaskSummaryResponse
+			eventually(func() bool {
+				return gus.do(http.MethodGet, "/tasks/summary", nil).decode(&summary) && summary.Total == 1
+			})
+			if summary.Total != 1 {
+				t.Errorf("globex's summary = %+v, want its task counted only", summary)
+			}
+
+			ctx := context.Background()
+			sam, err := sdk.New(server.URL, nil).WithTenant("globex").Register(ctx, "sam@example.com", "correct horse")
+			if err != nil || sam.Tenant != "globex" {
+				t.Errorf("the Go client's Register in globex = %+v, %v, want an account in globex", sam, err)
+			}
+			if _, err := sdk.New(server.URL, nil).WithTenant("Globex").Register(ctx, "sam@example.com", "correct horse"); !errors.Is(err, domain.ErrInvalidTenant) {
+				t.Errorf("its Register in an invalid tenant = %v, want %v", err, domain.ErrInvalidTenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package app_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/dong-tran/docs/clean-architecture-example/client"
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// sameTaskV2 reports whether v2 is v1 in the other shape
+func sameTaskV2(v1 handler.TaskResponse, v2 handler.TaskResponseV2) bool {
+	return v1.ID == v2.ID && v1.OwnerID == v2.OwnerID && v1.AssigneeID == v2.AssigneeID && v1.Title == v2.Title &&
+		v1.Description == v2.Description && v1.Completed == v2.Completed && v1.Priority == v2.Priority &&
+		strings.Join(v1.Tags, ",") == strings.Join(v2.Tags, ",") && v1.Version == v2.Version &&
+		v1.CreatedAt == v2.Timestamps.CreatedAt && v1.UpdatedAt == v2.Timestamps.UpdatedAt && v2.Timestamps.ArchivedAt == ""
+}
+
+// signUpAcrossVersions registers an account through v1 and logs in through
+// v2: both versions have the same accounts
+func signUpAcrossVersions(t *testing.T, base, email string) *client {
+	t.Helper()
+	cl := &client{t: t, base: base}
+	credentials := handler.CredentialsRequest{Email: email, Password: "correct horse"}
+	cl.do(http.MethodPost, "/api/v1/auth/register", credentials)
+	var token handler.TokenResponse
+	if res := cl.do(http.MethodPost, "/api/v2/auth/login", credentials); !res.decode(&token) || token.AccessToken == "" {
+		t.Fatalf("logging in through v2 = %d %s, want the account registered through v1", res.status, res.body)
+	}
+	cl.token = token.AccessToken
+	return cl
+}
+
+// TestVersions drives v1 and v2 of one server: the same tasks, from the same
+// use cases, each in its own shape, in every response that carries tasks
+func TestVersions(t *testing.T) {
+	server := serveApp(t, map[string]string{"JWT_SECRET": strings.Repeat("version-secret-", 3)}, true)
+	ann := signUpAcrossVersions(t, server.URL, "ann@example.com")
+
+	res := ann.do(http.MethodPost, "/api/v1/tasks", `{"title":"Write the report","priority":"high","tags":["work"]}`)
+	var created handler.TaskResponse
+	if res.status != http.StatusCreated || !res.decode(&created) || created.ID == 0 || created.CreatedAt == "" {
+		t.Fatalf("POST /api/v1/tasks = %d %s, want 201 and the task in v1's shape", res.status, res.body)
+	}
+	one := fmt.Sprintf("/api/v2/tasks/%d", created.ID)
+	res = ann.do(http.MethodGet, one, nil)
+	var read handler.TaskResponseV2
+	if res.status != http.StatusOK || !res.decode(&read) || !sameTaskV2(created, read) {
+		t.Errorf("GET %s = %d %s, want the same task in v2's shape", one, res.status, res.body)
+	}
+	if res.header.Get(handler.HeaderETag) != `"1-v2"` || res.header.Get(handler.HeaderDeprecation) != "" {
+		t.Errorf("GET %s: ETag %s, Deprecation %q, want v2's ETag, \"1-v2\", and not deprecated",
+			one, res.header.Get(handler.HeaderETag), res.header.Get(handler.HeaderDeprecation))
+	}
+	if res := ann.do(http.MethodGet, one, nil, handler.HeaderIfNoneMatch, `"1-v2"`); res.status != http.StatusNotModified {
+		t.Errorf("with If-None-Match v2's ETag = %d, want 304", res.status)
+	}
+	if res := ann.do(http.MethodGet, one, nil, handler.HeaderIfNoneMatch, `"1"`); res.status != http.StatusOK {
+		t.Errorf("with If-None-Match v1's ETag, whose body differs = %d, want 200", res.status)
+	}
+
+	res = ann.do(http.MethodPut, one, `{"title":"Write the final report","priority":"high"}`, handler.HeaderIfMatch, `"1"`)
+	var updated handler.TaskResponseV2
+	if res.status != http.StatusOK || !res.decode(&updated) || updated.Version != 2 || updated.Timestamps.UpdatedAt == "" {
+		t.Errorf("PUT %s with If-Match v1's ETag = %d %s, want 200 in v2's shape", one, res.status, res.body)
+	}
+	var v1List []handler.TaskResponse
+	if res := ann.do(http.MethodGet, "/api/v1/tasks", nil); !res.decode(&v1List) || len(v1List) != 1 || v1List[0].Title != "Write the final report" {
+		t.Fatalf("GET /api/v1/tasks = %d %s, want the change in v1's shape", res.status, res.body)
+	}
+	var v2List []handler.TaskResponseV2
+	if res := ann.do(http.MethodGet, "/api/v2/tasks", nil); !res.decode(&v2List) || len(v2List) != 1 || !sameTaskV2(v1List[0], v2List[0]) {
+		t.Errorf("GET /api/v2/tasks = %d %s, want the same list in v2's shape", res.status, res.body)
+	}
+
+	res = ann.do(http.MethodPost, "/api/v2/tasks/bulk", `{"tasks":[{"title":"Book the venue"},{"title":""}]}`)
+	var bulk handler.BulkResponseV2
+	if res.status != http.StatusOK || !res.decode(&bulk) || bulk.Succeeded != 1 || bulk.Failed != 1 ||
+		bulk.Results[0].Task == nil || bulk.Results[0].Task.Timestamps.CreatedAt == "" ||
+		bulk.Results[1].Error == nil || bulk.Results[1].Error.Code != domain.ErrEmptyTitle.Code {
+		t.Fatalf("POST /api/v2/tasks/bulk = %d %s, want each created task in v2's shape, each failure as in v1", res.status, res.body)
+	}
+	res = ann.do(http.MethodPut, fmt.Sprintf("/api/v2/tasks/%d/assignee", bulk.Results[0].Task.ID), `{"assignee_id":1}`)
+	var assigned handler.TaskResponseV2
+	if res.status != http.StatusOK || !res.decode(&assigned) || assigned.AssigneeID != 1 {
+		t.Errorf("PUT /api/v2/tasks/:id/assignee = %d %s, want 200 in v2's shape", res.status, res.body)
+	}
+
+	if res := ann.do(http.MethodGet, "/api/v2/tasks/999", nil); !res.isProblem(http.StatusNotFound, "TASK_NOT_FOUND") {
+		t.Errorf("GET /api/v2/tasks/999 = %d %s, want the same problem as v1's, 404 TASK_NOT_FOUND", res.status, res.body)
+	}
+	if res := ann.do(http.MethodGet, "/api/v9/tasks", nil); res.status != http.StatusNotFound {
+		t.Errorf("a version there is not = %d, want 404", res.status)
+	}
+
+	t.Run("streams", func(t *testing.T) {
+		watcher := signUpAcrossVersions(t, server.URL, "watcher@example.com")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v2/tasks/stream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+watcher.token)
+		stream, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stream.Body.Close()
+		lines := bufio.NewReader(stream.Body)
+		lines.ReadString('\n') // ": watching", once changes are on the stream
+
+		watcher.do(http.MethodPost, "/api/v1/tasks", `{"title":"Water the plants"}`)
+		var event handler.TaskResponseV2
+		for {
+			line, err := lines.ReadString('\n')
+			if err != nil {
+				t.Fatalf("the stream ended before the event: %v", err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+				decoder := json.NewDecoder(strings.NewReader(data))
+				decoder.DisallowUnknownFields()
+				if err := decoder.Decode(&event); err != nil {
+					t.Fatalf("the event %s is not in v2's shape: %v", data, err)
+				}
+				break
+			}
+		}
+		if event.Title != "Water the plants" || event.Timestamps.CreatedAt == "" {
+			t.Errorf("the v2 stream sent %+v, want the task created through v1, in v2's shape", event)
+		}
+	})
+
+	t.Run("paths without a version", func(t *testing.T) {
+		legacy := signUpAcrossVersions(t, server.URL, "legacy@example.com")
+		legacy.do(http.MethodPost, "/api/v1/tasks", `{"title":"Renew the passport"}`)
+		versioned := legacy.do(http.MethodGet, "/api/v1/tasks", nil)
+		res := legacy.do(http.MethodGet, "/tasks", nil)
+		if res.status != http.StatusOK || !bytes.Equal(res.body, versioned.body) {
+			t.Errorf("GET /tasks = %d %s, want it answered as GET /api/v1/tasks, %s", res.status, res.body, versioned.body)
+		}
+		if !strings.HasPrefix(res.header.Get(handler.HeaderDeprecation), "@") ||
+			res.header.Get(handler.HeaderLink) != `</api/v1/tasks>; rel="successor-version"` {
+			t.Errorf("GET /tasks: Deprecation %q, Link %q, want it deprecated, with a link to the path to use",
+				res.header.Get(handler.HeaderDeprecation), res.header.Get(handler.HeaderLink))
+		}
+		if versioned.header.Get(handler.HeaderDeprecation) != "" || versioned.header.Get(handler.HeaderLink) != "" {
+			t.Errorf("GET /api/v1/tasks: Deprecation %q, Link %q, want neither",
+				versioned.header.Get(handler.HeaderDeprecation), versioned.header.Get(handler.HeaderLink))
+		}
+
+		anonymous := &client{t: t, base: server.URL}
+		res = anonymous.do(http.MethodPost, "/auth/login", `{"email":"legacy@example.com","password":"correct horse"}`)
+		if res.status != http.StatusOK || res.header.Get(handler.HeaderLink) != `</api/v1/auth/login>; rel="successor-version"` {
+			t.Errorf("POST /auth/login = %d, Link %q, want the other groups deprecated too", res.status, res.header.Get(handler.HeaderLink))
+		}
+		steps := []struct {
+			name       string
+			cl         *client
+			method     string
+			path       string
+			status     int
+			deprecated bool
+		}{
+			{"an error", legacy, http.MethodGet, "/tasks/999", http.StatusNotFound, true},
+			{"a known path with the wrong method", anonymous, http.MethodDelete, "/auth/login", http.StatusMethodNotAllowed, true},
+			{"the documents, which are not versioned", anonymous, http.MethodGet, "/docs/openapi.json", http.StatusOK, false},
+			{"a path that only starts like a group", legacy, http.MethodGet, "/tasksx", http.StatusNotFound, false},
+		}
+		for _, step := range steps {
+			res := step.cl.do(step.method, step.path, nil)
+			if deprecated := res.header.Get(handler.HeaderDeprecation) != ""; res.status != step.status || deprecated != step.deprecated {
+				t.Errorf("%s, %s %s = %d, deprecated %v; want %d, deprecated %v", step.name, step.method, step.path, res.status, deprecated, step.status, step.deprecated)
+			}
+		}
+
+		var doc handler.Document
+		json.Unmarshal(anonymous.do(http.MethodGet, "/docs/openapi.json", nil).body, &doc)
+		if doc.Paths["/api/v1/tasks"] == nil || doc.Paths["/api/v2/tasks"] == nil || doc.Paths["/tasks"] != nil {
+			t.Error("the document does not list both versions, or lists the deprecated paths")
+		}
+	})
+
+	t.Run("the Go client", func(t *testing.T) {
+		ctx := context.Background()
+		api := sdk.New(server.URL, nil)
+		if _, err := api.Register(ctx, "sdk@example.com", "correct horse"); err != nil {
+			t.Fatal(err)
+		}
+		token, err := api.Login(ctx, "sdk@example.com", "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		task, err := api.WithToken(token).CreateTask(ctx, "From the SDK", "")
+		if err != nil || task.CreatedAt.IsZero() {
+			t.Fatalf("CreateTask = %+v, %v, want v1's created_at read", task, err)
+		}
+		var read handler.TaskResponseV2
+		sdkUser := &client{t: t, base: server.URL, token: token}
+		if res := sdkUser.do(http.MethodGet, fmt.Sprintf("/api/v2/tasks/%d", task.ID), nil); !res.decode(&read) || read.Title != "From the SDK" {
+			t.Errorf("v2's GET of the task = %d %s, want it served too", res.status, res.body)
+		}
+	})
+}
+
+func TestVersionsWithFastJSON(t *testing.T) {
+	server := serveApp(t, map[string]string{"JWT_SECRET": strings.Repeat("version-secret-", 3), "JSON_ENCODER": config.JSONFast}, true)
+	ann := signUpAcrossVersions(t, server.URL, "fast@example.com")
+	ann.do(http.MethodPost, "/api/v1/tasks", `{"title":"Write the report"}`)
+	var v1 []handler.TaskResponse
+	var v2 []handler.TaskResponseV2
+	if !ann.do(http.MethodGet, "/api/v1/tasks", nil).decode(&v1) || !ann.do(http.MethodGet, "/api/v2/tasks", nil).decode(&v2) ||
+		len(v1) != 1 || len(v2) != 1 || !sameTaskV2(v1[0], v2[0]) {
+		t.Errorf("v1 lists %+v and v2 %+v, want the fast encoders serving v1, and v2 keeping its shape", v1, v2)
+	}
+}
@@ -0,0 +1,262 @@
+// Package client is a Go SDK for the task API.
+//
+// Task calls need a token: log in, then use the client WithToken returns.
+//
+//	token, err := c.Login(ctx, "ada@example.com", "correct horse")
+//	tasks := c.WithToken(token)
+//
+// Failed calls return *APIError, built from the problem+json body. Its code
+// matches the domain sentinel it came from, so callers use errors.Is exactly
+// as they would inside the service:
+//
+//	_, err := c.CreateTask(ctx, "", "")
+//	if errors.Is(err, domain.ErrEmptyTitle) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// apiPrefix is the version of the API the client speaks: its Task is that
+// version's
+const apiPrefix = "/api/v1"
+
+type Client struct {
+	baseURL  string
+	http     *http.Client
+	token    string
+	tenant   string
+	language string
+}
+
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: httpClient}
+}
+
+// WithToken returns a client that sends token as its bearer token
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = token
+	return &clone
+}
+
+// WithTenant returns a client whose calls are for tenant, sent as
+// X-Tenant-ID. A token only works in the tenant it was issued in.
+func (c *Client) WithTenant(tenant string) *Client {
+	clone := *c
+	clone.tenant = tenant
+	return &clone
+}
+
+// WithLanguage returns a client that asks for error messages in language,
+// sent as Accept-Language, such as "vi". APIError's Detail is then in that
+// language if the server has it; its Code is the same in any.
+func (c *Client) WithLanguage(language string) *Client {
+	clone := *c
+	clone.language = language
+	return &clone
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Tenant    string    `json:"tenant"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (c *Client) Register(ctx context.Context, email, password string) (*User, error) {
+	var user User
+	err := c.do(ctx, http.MethodPost, "/auth/register", map[string]string{
+		"email":    email,
+		"password": password,
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login returns an access token for WithToken
+func (c *Client) Login(ctx context.Context, email, password string) (string, error) {
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	err := c.do(ctx, http.MethodPost, "/auth/login", map[string]string{
+		"email":    email,
+		"password": password,
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.AccessToken, nil
+}
+
+type Task struct {
+	ID          int64     `json:"id"`
+	OwnerID     int64     `json:"owner_id"`
+	AssigneeID  int64     `json:"assignee_id"` // 0 for nobody
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Completed   bool      `json:"completed"`
+	Priority    string    `json:"priority"`
+	Tags        []string  `json:"tags"`
+	Version     int64     `json:"version"`
+	DueAt       time.Time `json:"due_at"` // zero if the task is not due
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// APIError is a failed call. Code is empty when the response was not a
+// problem document, e.g. from a proxy in front of the service.
+type APIError struct {
+	Status int
+	Code   domain.Code
+	Title  string
+	Detail string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("task api: %d %s", e.Status, e.Title)
+	}
+	return fmt.Sprintf("task api: %s: %s", e.Code, e.Detail)
+}
+
+// Is matches the domain error with the same code
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*domain.Error)
+	return ok && e.Code != "" && t.Code == e.Code
+}
+
+// Unwrap returns the declared domain error for the code, if this SDK knows it
+func (e *APIError) Unwrap() error {
+	if known, ok := domain.Lookup(e.Code); ok {
+		return known
+	}
+	return nil
+}
+
+func (c *Client) CreateTask(ctx context.Context, title, description string) (*Task, error) {
+	var task Task
+	err := c.do(ctx, http.MethodPost, "/tasks", map[string]string{
+		"title":       title,
+		"description": description,
+	}, &task)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) GetTask(ctx context.Context, id int64) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+strconv.FormatInt(id, 10), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) ListTasks(ctx context.Context) ([]Task, error) {
+	var tasks []Task
+	if err := c.do(ctx, http.MethodGet, "/tasks", nil, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// UpdateTask changes the task from version, the one the caller read. If the
+// task has changed since, the error matches domain.ErrVersionConflict: get it
+// again and redo the change. Version 0 overwrites whatever is stored.
+func (c *Client) UpdateTask(ctx context.Context, id, version int64, title, description string, completed bool) (*Task, error) {
+	var task Task
+	err := c.do(ctx, http.MethodPut, "/tasks/"+strconv.FormatInt(id, 10), map[string]interface{}{
+		"title":       title,
+		"description": description,
+		"completed":   completed,
+		"version":     version,
+	}, &task)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) DeleteTask(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, "/tasks/"+strconv.FormatInt(id, 10), nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+apiPrefix+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	if c.language != "" {
+		req.Header.Set("Accept-Language", c.language)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return decodeProblem(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decodeProblem(resp *http.Response) error {
+	apiErr := &APIError{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") {
+		return apiErr
+	}
+	var problem struct {
+		Title  string      `json:"title"`
+		Detail string      `json:"detail"`
+		Code   domain.Code `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		return apiErr
+	}
+	apiErr.Code, apiErr.Detail = problem.Code, problem.Detail
+	if problem.Title != "" {
+		apiErr.Title = problem.Title
+	}
+	return apiErr
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+)
+
+// migrate walks the tasks database through the description -> details rename
+// (infrastructure/migrations.go), one deploy step at a time:
+//
+//	go run ./cmd/migrate [-db tasks.db] status
+//	go run ./cmd/migrate expand
+//	go run ./cmd/migrate backfill [-batch 500]
+//	go run ./cmd/migrate contract
+//
+// Between the steps, roll the server forward with TASKS_DESCRIPTION_COLUMNS:
+// dual-write after expand, dual-write-read-details after backfill, details
+// before contract. contract refuses to run while any description is missing
+// from details. Interrupting backfill is safe; run it again to resume.
+//
+// It opens the server's database, from DB_DRIVER and DB_DSN, unless -driver
+// and -db name another.
+
+const usage = "usage: migrate [-driver sqlite3|postgres] [-db dsn] status|expand|backfill [-batch n]|contract"
+
+func main() {
+	config := infrastructure.DatabaseConfigFromEnv()
+	flag.StringVar(&config.Driver, "driver", config.Driver, "database driver: sqlite3 or postgres")
+	flag.StringVar(&config.DSN, "db", config.DSN, "SQLite file or PostgreSQL connection string")
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := infrastructure.InitDatabase(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	switch cmd := flag.Arg(0); cmd {
+	case "status":
+		applied, err := infrastructure.AppliedMigrations(db)
+		exitOn(err)
+		for _, m := range infrastructure.Migrations {
+			mark := " "
+			if applied[m.Version] {
+				mark = "x"
+			}
+			fmt.Printf("[%s] %d %s\n", mark, m.Version, m.Name)
+		}
+		p, err := infrastructure.DetailsBackfillStatus(db)
+		exitOn(err)
+		if applied[infrastructure.SchemaExpanded] {
+			fmt.Printf("details backfill: %s, done: %v\n", p, p.Done)
+		}
+	case "expand":
+		exitOn(infrastructure.Migrate(db, infrastructure.SchemaExpanded))
+		fmt.Println("expanded: tasks.details added; deploy with TASKS_DESCRIPTION_COLUMNS=dual-write, then run backfill")
+	case "backfill":
+		fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+		batch := fs.Int("batch", 500, "rows per transaction")
+		fs.Parse(flag.Args()[1:])
+		applied, err := infrastructure.AppliedMigrations(db)
+		exitOn(err)
+		if !applied[infrastructure.SchemaExpanded] || applied[infrastructure.SchemaContracted] {
+			exitOn(fmt.Errorf("backfill needs migration %d applied and %d not yet", infrastructure.SchemaExpanded, infrastructure.SchemaContracted))
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		p, err := infrastructure.BackfillDetails(ctx, db, *batch, func(p infrastructure.BackfillProgress) {
+			fmt.Println("backfill:", p)
+		})
+		if err != nil {
+			exitOn(fmt.Errorf("stopped at %s: %w (run again to resume)", p, err))
+		}
+		fmt.Println("backfill finished; deploy with TASKS_DESCRIPTION_COLUMNS=dual-write-read-details, then details")
+	case "contract":
+		exitOn(infrastructure.ContractReady(db))
+		exitOn(infrastructure.Migrate(db, infrastructure.SchemaContracted))
+		fmt.Println("contracted: tasks.description dropped")
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+func exitOn(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
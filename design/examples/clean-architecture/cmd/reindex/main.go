@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/fulltext"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/mongodb"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// reindex rebuilds the server's full-text index from the stored tasks:
+//
+//	go run ./cmd/reindex [-config tasks.yaml] [-search-index-dir ./search-index]
+//
+// It takes the server's settings, from the same sources, and needs
+// search.index_dir: an index kept in memory is rebuilt by every start. The
+// server keeps its index up to date while it runs, so this is for an index
+// that is not: one the server stopped without finishing, or older than
+// changes made to the database some other way. The server must be stopped;
+// the index cannot be opened by two processes.
+
+func main() {
+	ctx := context.Background()
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
+	}
+	exitOn(err)
+	if cfg.Search.IndexDir == "" {
+		exitOn(errors.New("search.index_dir is not set: an index in memory is rebuilt every time the server starts"))
+	}
+
+	db, err := infrastructure.InitDatabase(cfg.DatabaseConfig())
+	exitOn(err)
+	defer db.Close()
+	var tasks domain.TaskRepository = repository.NewTaskRepositoryWithColumns(db, cfg.DescriptionColumns())
+	if uri := cfg.MongoDB.URI; uri != "" {
+		tasksDB, err := mongodb.Connect(ctx, uri)
+		exitOn(err)
+		defer tasksDB.Client().Disconnect(ctx)
+		tasks, err = mongodb.NewTaskRepository(ctx, tasksDB)
+		exitOn(err)
+	}
+
+	index, err := fulltext.Open(cfg.Search.IndexDir)
+	exitOn(err)
+	defer index.Close(ctx)
+	n, err := usecase.NewTaskSearchService(index, tasks, nil, nil).Rebuild(ctx)
+	exitOn(err)
+	fmt.Printf("indexed %d tasks in %s\n", n, cfg.Search.IndexDir)
+}
+
+func exitOn(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// repogen reads a domain struct and emits repository boilerplate for it:
+//   - an sqlx store (CRUD + List with criteria) with explicit row scan mappers
+//   - an in-memory store with identical semantics, for tests and demos
+//   - a conformance check that both stores must pass, and a test running it
+//     against both
+//
+// It is meant to be run through go generate from the domain package:
+//
+//	//go:generate go run ../cmd/repogen -type Task -table tasks -out ../repository
+//
+// Column names are derived from field names (CreatedAt -> created_at), so
+// domain structs need no persistence tags. A field can be excluded with
+// `repo:"-"`, or stored in a column of another name with `repo:"column=details"`.
+//
+// A TenantID field tagged `repo:"tenant"` scopes the stores to the tenant of
+// their ctx, as every repository is (see repository/tenant.go): rows are
+// created in it, and nothing else reads, changes or deletes another tenant's.
+// The generated code calls tenantFor and andTenant of the output package for
+// it, and TenantOf, WithTenant and AllTenants of the domain package.
+//
+// The generated test is in the output package's external test package, which
+// must declare the fixtures it runs the stores on:
+//
+//	func openStoreDB(t *testing.T) *sqlx.DB     // a database with the tables, migrated
+//	func new<Type>ForStore() *<domain>.<Type>   // a fresh, valid, unsaved entity
+
+// column is a persisted struct field
+type column struct {
+	Field  string
+	Name   string
+	GoType string
+	IsTime bool
+}
+
+// Comparable reports whether the column can be used as an equality criterion
+func (c column) Comparable() bool {
+	return !c.IsTime
+}
+
+type model struct {
+	Package       string
+	Import        string // of the output package
+	DomainImport  string
+	DomainPackage string
+	Type          string
+	Table         string
+	ID            column
+	AutoID        bool
+	Columns       []column
+	Tenant        column // the `repo:"tenant"` field, if any; not in Columns
+	Source        string
+}
+
+// Tenanted reports whether the stores are scoped to a tenant
+func (m model) Tenanted() bool {
+	return m.Tenant.Field != ""
+}
+
+// HasTime reports whether the generated row type needs the time package
+func (m model) HasTime() bool {
+	for _, c := range m.Columns {
+		if c.IsTime {
+			return true
+		}
+	}
+	return false
+}
+
+// Fields returns every column except the ID
+func (m model) Fields() []column {
+	var out []column
+	for _, c := range m.Columns {
+		if c.Field != m.ID.Field {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+var supportedTypes = map[string]bool{
+	"string": true, "bool": true, "int": true, "int64": true, "float64": true, "time.Time": true,
+}
+
+func parseModel(file, typeName, table, pkg string) (model, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, 0)
+	if err != nil {
+		return model{}, err
+	}
+
+	var st *ast.StructType
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == typeName {
+			st, _ = ts.Type.(*ast.StructType)
+			return false
+		}
+		return true
+	})
+	if st == nil {
+		return model{}, fmt.Errorf("struct %s not found in %s", typeName, file)
+	}
+
+	m := model{
+		Package:       pkg,
+		DomainPackage: f.Name.Name,
+		Type:          typeName,
+		Table:         table,
+		Source:        filepath.Base(file),
+	}
+	for _, field := range st.Fields.List {
+		goType := exprString(field.Type)
+		var opts tagOptions
+		if field.Tag != nil {
+			opts, err = parseTag(reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("repo"))
+			if err != nil {
+				return model{}, fmt.Errorf("%s: %w", typeName, err)
+			}
+			if opts.skip {
+				continue
+			}
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			if opts.tenant {
+				if goType != "TenantID" || m.Tenanted() {
+					return model{}, fmt.Errorf("%s.%s: repo:\"tenant\" goes on the one TenantID field", typeName, name.Name)
+				}
+				m.Tenant = column{Field: name.Name, Name: snakeCase(name.Name), GoType: "string"}
+				if opts.column != "" {
+					m.Tenant.Name = opts.column
+				}
+				continue
+			}
+			if !supportedTypes[goType] {
+				return model{}, fmt.Errorf("%s.%s: unsupported type %s (exclude it with `repo:\"-\"`)", typeName, name.Name, goType)
+			}
+			c := column{Field: name.Name, Name: snakeCase(name.Name), GoType: goType, IsTime: goType == "time.Time"}
+			if opts.column != "" {
+				if len(field.Names) > 1 {
+					return model{}, fmt.Errorf("%s.%s: column= names one column, declare the field on its own", typeName, name.Name)
+				}
+				c.Name = opts.column
+			}
+			m.Columns = append(m.Columns, c)
+			if name.Name == "ID" {
+				m.ID = c
+			}
+		}
+	}
+
+	if m.ID.Field == "" {
+		return model{}, fmt.Errorf("struct %s has no ID field", typeName)
+	}
+	switch m.ID.GoType {
+	case "int64":
+		m.AutoID = true
+	case "string":
+	default:
+		return model{}, fmt.Errorf("%s.ID must be int64 (auto-increment) or string, got %s", typeName, m.ID.GoType)
+	}
+	return m, nil
+}
+
+// tagOptions are the options of a `repo` struct tag
+type tagOptions struct {
+	skip   bool   // "-": the field is not persisted
+	column string // "column=NAME": the field's column, instead of its snake_case name
+	tenant bool   // "tenant": the field is the row's tenant
+}
+
+func parseTag(tag string) (tagOptions, error) {
+	var opts tagOptions
+	if tag == "" {
+		return opts, nil
+	}
+	if tag == "-" {
+		opts.skip = true
+		return opts, nil
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch {
+		case key == "column" && value != "":
+			opts.column = value
+		case key == "tenant" && value == "":
+			opts.tenant = true
+		default:
+			return opts, fmt.Errorf("unknown repo tag option %q", opt)
+		}
+	}
+	return opts, nil
+}
+
+func exprString(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return exprString(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(t.X)
+	case *ast.ArrayType:
+		return "[]" + exprString(t.Elt)
+	case *ast.MapType:
+		return "map[" + exprString(t.Key) + "]" + exprString(t.Value)
+	default:
+		return fmt.Sprintf("%T", e)
+	}
+}
+
+// snakeCase converts a Go field name to a column name: CreatedAt -> created_at, ID -> id
+func snakeCase(s string) string {
+	r := []rune(s)
+	var b strings.Builder
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			prevLower := i > 0 && unicode.IsLower(r[i-1])
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if i > 0 && (prevLower || (nextLower && unicode.IsUpper(r[i-1]))) {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// modulePath finds the module path and root for dir by walking up to go.mod
+func modulePath(dir string) (string, string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		data, err := os.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(line, "module ") {
+					return strings.TrimSpace(strings.TrimPrefix(line, "module ")), d, nil
+				}
+			}
+			return "", "", fmt.Errorf("%s/go.mod has no module line", d)
+		}
+		if d == filepath.Dir(d) {
+			return "", "", errors.New("go.mod not found")
+		}
+	}
+}
+
+// importPath returns the import path of the package in dir, of module at root
+func importPath(module, root, dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", err
+	}
+	return module + "/" + filepath.ToSlash(rel), nil
+}
+
+func render(name string, m model) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"lower": func(s string) string { return strings.ToLower(s[:1]) + s[1:] },
+		"columns": func(cs []column) string {
+			names := make([]string, len(cs))
+			for i, c := range cs {
+				names[i] = c.Name
+			}
+			return strings.Join(names, ", ")
+		},
+	}).Parse(templates[name])
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, m); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return buf.Bytes(), fmt.Errorf("format %s: %w", name, err)
+	}
+	return src, nil
+}
+
+func main() {
+	typeName := flag.String("type", "", "domain struct to generate a repository for")
+	table := flag.String("table", "", "table name (default: snake_case plural of type)")
+	out := flag.String("out", "../repository", "output directory")
+	pkg := flag.String("pkg", "", "output package name (default: base name of -out)")
+	file := flag.String("file", os.Getenv("GOFILE"), "source file declaring the struct (default: $GOFILE)")
+	flag.Parse()
+
+	if *typeName == "" || *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *table == "" {
+		*table = snakeCase(*typeName) + "s"
+	}
+	if *pkg == "" {
+		*pkg = filepath.Base(*out)
+	}
+
+	m, err := parseModel(*file, *typeName, *table, *pkg)
+	if err != nil {
+		log.Fatalf("repogen: %v", err)
+	}
+
+	module, root, err := modulePath(filepath.Dir(*file))
+	if err != nil {
+		log.Fatalf("repogen: %v", err)
+	}
+	if m.DomainImport, err = importPath(module, root, filepath.Dir(*file)); err != nil {
+		log.Fatalf("repogen: %v", err)
+	}
+	if m.Import, err = importPath(module, root, *out); err != nil {
+		log.Fatalf("repogen: %v", err)
+	}
+
+	base := snakeCase(*typeName) + "_store"
+	files := map[string]string{
+		"sql":              base + "_gen.go",
+		"memory":           base + "_memory_gen.go",
+		"conformance":      base + "_conformance_gen.go",
+		"conformance_test": base + "_gen_test.go",
+	}
+	for _, name := range []string{"sql", "memory", "conformance", "conformance_test"} {
+		src, err := render(name, m)
+		if err != nil {
+			log.Fatalf("repogen: %v", err)
+		}
+		path := filepath.Join(*out, files[name])
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			log.Fatalf("repogen: %v", err)
+		}
+		fmt.Println("repogen: wrote", path)
+	}
+}
@@ -0,0 +1,565 @@
+package main
+
+var templates = map[string]string{
+	"sql":              sqlTemplate,
+	"memory":           memoryTemplate,
+	"conformance":      conformanceTemplate,
+	"conformance_test": conformanceTestTemplate,
+}
+
+const header = `// Code generated by repogen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+`
+
+const sqlTemplate = header + `
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+{{- if .HasTime}}
+	"time"
+{{- end}}
+
+	"{{.DomainImport}}"
+	"github.com/jmoiron/sqlx"
+)
+
+// {{.Type}}Store is the persistence contract implemented by the generated stores.
+// Every method returns ctx.Err() once ctx is done.
+type {{.Type}}Store interface {
+	Create(ctx context.Context, entity *{{.DomainPackage}}.{{.Type}}) error
+	GetByID(ctx context.Context, id {{.ID.GoType}}) (*{{.DomainPackage}}.{{.Type}}, error)
+	List(ctx context.Context, criteria {{.Type}}Criteria) ([]*{{.DomainPackage}}.{{.Type}}, error)
+	Update(ctx context.Context, entity *{{.DomainPackage}}.{{.Type}}) error
+	Delete(ctx context.Context, id {{.ID.GoType}}) error
+}
+
+// {{.Type}}Criteria filters List results; nil fields are ignored
+type {{.Type}}Criteria struct {
+{{- range .Fields}}{{if .Comparable}}
+	{{.Field}} *{{.GoType}}
+{{- end}}{{end}}
+	OrderBy string // column name, defaults to {{.ID.Name}}
+	Desc    bool
+	Limit   int
+	Offset  int
+}
+
+var {{lower .Type}}Columns = map[string]bool{
+{{- range .Columns}}
+	"{{.Name}}": true,
+{{- end}}
+}
+
+// {{lower .Type}}Row is the scan mapper between the {{.Table}} table and the domain struct
+type {{lower .Type}}Row struct {
+{{- range .Columns}}
+	{{.Field}} {{.GoType}} ` + "`" + `db:"{{.Name}}"` + "`" + `
+{{- end}}
+{{- if .Tenanted}}
+	{{.Tenant.Field}} string ` + "`" + `db:"{{.Tenant.Name}}"` + "`" + `
+{{- end}}
+}
+
+func {{lower .Type}}ToRow(entity *{{.DomainPackage}}.{{.Type}}) {{lower .Type}}Row {
+	return {{lower .Type}}Row{
+{{- range .Columns}}
+		{{.Field}}: entity.{{.Field}},
+{{- end}}
+{{- if .Tenanted}}
+		{{.Tenant.Field}}: string(entity.{{.Tenant.Field}}),
+{{- end}}
+	}
+}
+
+func (r {{lower .Type}}Row) toDomain() *{{.DomainPackage}}.{{.Type}} {
+	return &{{.DomainPackage}}.{{.Type}}{
+{{- range .Columns}}
+		{{.Field}}: r.{{.Field}},
+{{- end}}
+{{- if .Tenanted}}
+		{{.Tenant.Field}}: {{.DomainPackage}}.TenantID(r.{{.Tenant.Field}}),
+{{- end}}
+	}
+}
+
+// SQL{{.Type}}Store stores {{.Type}} values in the {{.Table}} table
+type SQL{{.Type}}Store struct {
+	db *sqlx.DB
+}
+
+func NewSQL{{.Type}}Store(db *sqlx.DB) *SQL{{.Type}}Store {
+	return &SQL{{.Type}}Store{db: db}
+}
+
+func (s *SQL{{.Type}}Store) Create(ctx context.Context, entity *{{.DomainPackage}}.{{.Type}}) error {
+{{- if .Tenanted}}
+	entity.{{.Tenant.Field}} = tenantFor(ctx, entity.{{.Tenant.Field}})
+{{- end}}
+	row := {{lower .Type}}ToRow(entity)
+{{- if .AutoID}}
+	// RETURNING rather than LastInsertId, which lib/pq does not support
+	query, args, err := sqlx.Named(` + "`" + `
+		INSERT INTO {{.Table}} ({{columns .Fields}}{{if .Tenanted}}, {{.Tenant.Name}}{{end}})
+		VALUES ({{range $i, $c := .Fields}}{{if $i}}, {{end}}:{{$c.Name}}{{end}}{{if .Tenanted}}, :{{.Tenant.Name}}{{end}})
+		RETURNING {{.ID.Name}}
+	` + "`" + `, row)
+	if err != nil {
+		return err
+	}
+	return s.db.QueryRowxContext(ctx, s.db.Rebind(query), args...).Scan(&entity.ID)
+{{- else}}
+	if row.ID == "" {
+		return fmt.Errorf("{{.Table}}: ID must be set before Create")
+	}
+	_, err := s.db.NamedExecContext(ctx, ` + "`" + `
+		INSERT INTO {{.Table}} ({{columns .Columns}}{{if .Tenanted}}, {{.Tenant.Name}}{{end}})
+		VALUES ({{range $i, $c := .Columns}}{{if $i}}, {{end}}:{{$c.Name}}{{end}}{{if .Tenanted}}, :{{.Tenant.Name}}{{end}})
+	` + "`" + `, row)
+	return err
+{{- end}}
+}
+
+func (s *SQL{{.Type}}Store) GetByID(ctx context.Context, id {{.ID.GoType}}) (*{{.DomainPackage}}.{{.Type}}, error) {
+{{- if .Tenanted}}
+	tenant, args := andTenant(ctx)
+	query := ` + "`" + `SELECT {{columns .Columns}}, {{.Tenant.Name}} FROM {{.Table}} WHERE {{.ID.Name}} = ?` + "`" + ` + tenant
+	args = append([]interface{}{id}, args...)
+{{- else}}
+	query := ` + "`" + `SELECT {{columns .Columns}} FROM {{.Table}} WHERE {{.ID.Name}} = ?` + "`" + `
+	args := []interface{}{id}
+{{- end}}
+	var row {{lower .Type}}Row
+	if err := s.db.GetContext(ctx, &row, s.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return row.toDomain(), nil
+}
+
+func (s *SQL{{.Type}}Store) List(ctx context.Context, criteria {{.Type}}Criteria) ([]*{{.DomainPackage}}.{{.Type}}, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+{{- if .Tenanted}}
+	if tenant := {{.DomainPackage}}.TenantOf(ctx); tenant != {{.DomainPackage}}.AllTenants {
+		where = append(where, "{{.Tenant.Name}} = ?")
+		args = append(args, string(tenant))
+	}
+{{- end}}
+{{- range .Fields}}{{if .Comparable}}
+	if criteria.{{.Field}} != nil {
+		where = append(where, "{{.Name}} = ?")
+		args = append(args, *criteria.{{.Field}})
+	}
+{{- end}}{{end}}
+
+	query := "SELECT {{columns .Columns}}{{if .Tenanted}}, {{.Tenant.Name}}{{end}} FROM {{.Table}}"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	orderBy := "{{.ID.Name}}"
+	if criteria.OrderBy != "" {
+		if !{{lower .Type}}Columns[criteria.OrderBy] {
+			return nil, fmt.Errorf("{{.Table}}: cannot order by unknown column %q", criteria.OrderBy)
+		}
+		orderBy = criteria.OrderBy
+	}
+	query += " ORDER BY " + orderBy
+	if criteria.Desc {
+		query += " DESC"
+	}
+	if criteria.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, criteria.Limit, criteria.Offset)
+	}
+
+	var rows []{{lower .Type}}Row
+	if err := s.db.SelectContext(ctx, &rows, s.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	result := make([]*{{.DomainPackage}}.{{.Type}}, len(rows))
+	for i, row := range rows {
+		result[i] = row.toDomain()
+	}
+	return result, nil
+}
+
+{{if .Tenanted -}}
+// Update leaves the row's {{.Tenant.Name}} as it was created
+{{end -}}
+func (s *SQL{{.Type}}Store) Update(ctx context.Context, entity *{{.DomainPackage}}.{{.Type}}) error {
+	query, args, err := sqlx.Named(` + "`" + `
+		UPDATE {{.Table}}
+		SET {{range $i, $c := .Fields}}{{if $i}}, {{end}}{{$c.Name}} = :{{$c.Name}}{{end}}
+		WHERE {{.ID.Name}} = :{{.ID.Name}}
+	` + "`" + `, {{lower .Type}}ToRow(entity))
+	if err != nil {
+		return err
+	}
+{{- if .Tenanted}}
+	tenant, tenantArgs := andTenant(ctx)
+	query += tenant
+	args = append(args, tenantArgs...)
+{{- end}}
+	result, err := s.db.ExecContext(ctx, s.db.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	return require{{.Type}}RowsAffected(result)
+}
+
+func (s *SQL{{.Type}}Store) Delete(ctx context.Context, id {{.ID.GoType}}) error {
+{{- if .Tenanted}}
+	tenant, args := andTenant(ctx)
+	query := ` + "`" + `DELETE FROM {{.Table}} WHERE {{.ID.Name}} = ?` + "`" + ` + tenant
+	args = append([]interface{}{id}, args...)
+{{- else}}
+	query := ` + "`" + `DELETE FROM {{.Table}} WHERE {{.ID.Name}} = ?` + "`" + `
+	args := []interface{}{id}
+{{- end}}
+	result, err := s.db.ExecContext(ctx, s.db.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	return require{{.Type}}RowsAffected(result)
+}
+
+func require{{.Type}}RowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+`
+
+const memoryTemplate = header + `
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+
+	"{{.DomainImport}}"
+)
+
+// Memory{{.Type}}Store is an in-memory {{.Type}}Store with the same semantics as SQL{{.Type}}Store.
+// Nothing in it blocks, so each method checks ctx once, before it starts.
+type Memory{{.Type}}Store struct {
+	mu     sync.RWMutex
+{{- if .AutoID}}
+	nextID int64
+{{- end}}
+	rows   map[{{.ID.GoType}}]{{.DomainPackage}}.{{.Type}}
+}
+
+func NewMemory{{.Type}}Store() *Memory{{.Type}}Store {
+	return &Memory{{.Type}}Store{rows: make(map[{{.ID.GoType}}]{{.DomainPackage}}.{{.Type}})}
+}
+
+func (s *Memory{{.Type}}Store) Create(ctx context.Context, entity *{{.DomainPackage}}.{{.Type}}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+{{- if .Tenanted}}
+	entity.{{.Tenant.Field}} = tenantFor(ctx, entity.{{.Tenant.Field}})
+{{- end}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+{{- if .AutoID}}
+	s.nextID++
+	entity.ID = s.nextID
+{{- else}}
+	if entity.ID == "" {
+		return fmt.Errorf("{{.Table}}: ID must be set before Create")
+	}
+	if _, exists := s.rows[entity.ID]; exists {
+		return fmt.Errorf("{{.Table}}: duplicate ID %q", entity.ID)
+	}
+{{- end}}
+	s.rows[entity.ID] = *entity
+	return nil
+}
+
+func (s *Memory{{.Type}}Store) GetByID(ctx context.Context, id {{.ID.GoType}}) (*{{.DomainPackage}}.{{.Type}}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entity, ok := s.rows[id]
+	if !ok{{if .Tenanted}} || !{{.DomainPackage}}.TenantOf(ctx).Includes(entity.{{.Tenant.Field}}){{end}} {
+		return nil, sql.ErrNoRows
+	}
+	return &entity, nil
+}
+
+func (s *Memory{{.Type}}Store) List(ctx context.Context, criteria {{.Type}}Criteria) ([]*{{.DomainPackage}}.{{.Type}}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	orderBy := "{{.ID.Name}}"
+	if criteria.OrderBy != "" {
+		if !{{lower .Type}}Columns[criteria.OrderBy] {
+			return nil, fmt.Errorf("{{.Table}}: cannot order by unknown column %q", criteria.OrderBy)
+		}
+		orderBy = criteria.OrderBy
+	}
+
+{{- if .Tenanted}}
+	tenant := {{.DomainPackage}}.TenantOf(ctx)
+{{- end}}
+
+	s.mu.RLock()
+	result := make([]*{{.DomainPackage}}.{{.Type}}, 0, len(s.rows))
+	for _, entity := range s.rows {
+		entity := entity
+{{- if .Tenanted}}
+		if !tenant.Includes(entity.{{.Tenant.Field}}) {
+			continue
+		}
+{{- end}}
+{{- range .Fields}}{{if .Comparable}}
+		if criteria.{{.Field}} != nil && entity.{{.Field}} != *criteria.{{.Field}} {
+			continue
+		}
+{{- end}}{{end}}
+		result = append(result, &entity)
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if criteria.Desc {
+			i, j = j, i
+		}
+		return less{{.Type}}(result[i], result[j], orderBy)
+	})
+
+	if criteria.Limit > 0 {
+		start := criteria.Offset
+		if start > len(result) {
+			start = len(result)
+		}
+		end := start + criteria.Limit
+		if end > len(result) {
+			end = len(result)
+		}
+		result = result[start:end]
+	}
+	return result, nil
+}
+
+func (s *Memory{{.Type}}Store) Update(ctx context.Context, entity *{{.DomainPackage}}.{{.Type}}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+{{- if .Tenanted}}
+	current, ok := s.rows[entity.ID]
+	if !ok || !{{.DomainPackage}}.TenantOf(ctx).Includes(current.{{.Tenant.Field}}) {
+		return sql.ErrNoRows
+	}
+	stored := *entity
+	stored.{{.Tenant.Field}} = current.{{.Tenant.Field}}
+	s.rows[entity.ID] = stored
+{{- else}}
+	if _, ok := s.rows[entity.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	s.rows[entity.ID] = *entity
+{{- end}}
+	return nil
+}
+
+func (s *Memory{{.Type}}Store) Delete(ctx context.Context, id {{.ID.GoType}}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if {{if .Tenanted}}entity{{else}}_{{end}}, ok := s.rows[id]; !ok{{if .Tenanted}} || !{{.DomainPackage}}.TenantOf(ctx).Includes(entity.{{.Tenant.Field}}){{end}} {
+		return sql.ErrNoRows
+	}
+	delete(s.rows, id)
+	return nil
+}
+
+func less{{.Type}}(a, b *{{.DomainPackage}}.{{.Type}}, column string) bool {
+	switch column {
+{{- range .Columns}}
+	case "{{.Name}}":
+{{- if .IsTime}}
+		return a.{{.Field}}.Before(b.{{.Field}})
+{{- else if eq .GoType "bool"}}
+		return !a.{{.Field}} && b.{{.Field}}
+{{- else}}
+		return a.{{.Field}} < b.{{.Field}}
+{{- end}}
+{{- end}}
+	}
+	return false
+}
+`
+
+const conformanceTemplate = header + `
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"{{.DomainImport}}"
+)
+
+// Check{{.Type}}StoreConformance exercises the {{.Type}}Store contract against an empty store.
+// Every implementation (SQL, in-memory, future drivers) must pass it, which keeps the
+// fakes used in tests honest. newEntity must return a fresh, valid, unsaved entity{{if not .AutoID}} with a unique ID{{end}}.
+func Check{{.Type}}StoreConformance(ctx context.Context, store {{.Type}}Store, newEntity func() *{{.DomainPackage}}.{{.Type}}) error {
+	first, second := newEntity(), newEntity()
+	for _, entity := range []*{{.DomainPackage}}.{{.Type}}{first, second} {
+		if err := store.Create(ctx, entity); err != nil {
+			return fmt.Errorf("Create: %w", err)
+		}
+	}
+	if first.ID == second.ID {
+		return fmt.Errorf("Create: expected distinct IDs, both are %v", first.ID)
+	}
+
+	got, err := store.GetByID(ctx, first.ID)
+	if err != nil {
+		return fmt.Errorf("GetByID(%v): %w", first.ID, err)
+	}
+	if got.ID != first.ID {
+		return fmt.Errorf("GetByID(%v): returned ID %v", first.ID, got.ID)
+	}
+{{- range .Fields}}
+{{- if .IsTime}}
+	if !got.{{.Field}}.Equal(first.{{.Field}}) {
+{{- else}}
+	if got.{{.Field}} != first.{{.Field}} {
+{{- end}}
+		return fmt.Errorf("GetByID(%v): {{.Field}} is %v, created as %v", first.ID, got.{{.Field}}, first.{{.Field}})
+	}
+{{- end}}
+{{- if .Tenanted}}
+
+	// A row of another tenant is one that does not exist
+	if tenant := {{.DomainPackage}}.TenantOf(ctx); tenant != {{.DomainPackage}}.AllTenants {
+		if first.{{.Tenant.Field}} != tenant {
+			return fmt.Errorf("Create: stored in tenant %q, not %q of ctx", first.{{.Tenant.Field}}, tenant)
+		}
+		other := {{.DomainPackage}}.WithTenant(ctx, tenant+"-other")
+		if _, err := store.GetByID(other, first.ID); !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("GetByID from another tenant: expected sql.ErrNoRows, got %v", err)
+		}
+		if rows, err := store.List(other, {{.Type}}Criteria{}); err != nil || len(rows) != 0 {
+			return fmt.Errorf("List from another tenant: expected no rows, got %d (%v)", len(rows), err)
+		}
+		if err := store.Update(other, got); !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("Update from another tenant: expected sql.ErrNoRows, got %v", err)
+		}
+		if err := store.Delete(other, first.ID); !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("Delete from another tenant: expected sql.ErrNoRows, got %v", err)
+		}
+	}
+{{- end}}
+
+	all, err := store.List(ctx, {{.Type}}Criteria{})
+	if err != nil {
+		return fmt.Errorf("List: %w", err)
+	}
+	if len(all) != 2 {
+		return fmt.Errorf("List: expected 2 rows, got %d", len(all))
+	}
+
+	page, err := store.List(ctx, {{.Type}}Criteria{OrderBy: "{{.ID.Name}}", Desc: true, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("List with limit: %w", err)
+	}
+	if len(page) != 1 || page[0].ID != all[1].ID {
+		return errors.New("List: descending order with limit 1 should return the last row")
+	}
+
+	if _, err := store.List(ctx, {{.Type}}Criteria{OrderBy: "no_such_column"}); err == nil {
+		return errors.New("List: expected an error when ordering by an unknown column")
+	}
+
+	if err := store.Update(ctx, got); err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+
+	if err := store.Delete(ctx, first.ID); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	if _, err := store.GetByID(ctx, first.ID); !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("GetByID after Delete: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := store.Delete(ctx, first.ID); !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("Delete of missing row: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := store.Update(ctx, first); !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("Update of missing row: expected sql.ErrNoRows, got %v", err)
+	}
+	return nil
+}
+`
+
+const conformanceTestTemplate = `// Code generated by repogen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}_test
+
+import (
+	"context"
+	"testing"
+{{if .Tenanted}}
+	"{{.DomainImport}}"
+{{- end}}
+	"{{.Import}}"
+)
+
+// Test{{.Type}}StoreConformance runs Check{{.Type}}StoreConformance against each
+// generated store. openStoreDB and new{{.Type}}ForStore are written by hand in
+// this package.
+func Test{{.Type}}StoreConformance(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(t *testing.T) {{.Package}}.{{.Type}}Store
+	}{
+		{"memory", func(t *testing.T) {{.Package}}.{{.Type}}Store { return {{.Package}}.NewMemory{{.Type}}Store() }},
+		{"sql", func(t *testing.T) {{.Package}}.{{.Type}}Store { return {{.Package}}.NewSQL{{.Type}}Store(openStoreDB(t)) }},
+	}
+	contexts := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"background", context.Background()},
+{{- if .Tenanted}}
+		{"tenant", {{.DomainPackage}}.WithTenant(context.Background(), "conformance")},
+		{"all tenants", {{.DomainPackage}}.WithTenant(context.Background(), {{.DomainPackage}}.AllTenants)},
+{{- end}}
+	}
+	for _, store := range stores {
+		for _, c := range contexts {
+			t.Run(store.name+"/"+c.name, func(t *testing.T) {
+				if err := {{.Package}}.Check{{.Type}}StoreConformance(c.ctx, store.open(t), new{{.Type}}ForStore); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+`
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/fixtures"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+// seed fills a database with demo data (fixtures), for demos and load
+// tests:
+//
+//	go run ./cmd/seed [-db tasks.db] [-reset] [-users 3] [-tasks 50] [-days 30]
+//
+// The accounts are ann@example.com, bob@example.com, ... with the password
+// demo-password. The tasks are theirs, created over the past -days, and
+// -completed, -due and -assigned of them are completed, due or assigned to
+// another account. The same -seed makes the same data. Seeding again adds
+// tasks to the same accounts; -reset first deletes everything in the
+// database, in every tenant.
+//
+// It opens the server's database, from DB_DRIVER and DB_DSN, unless -driver
+// and -db name another, and writes descriptions as TASKS_DESCRIPTION_COLUMNS
+// says. The server fills its read model from the tasks when it starts, and
+// its search index if that is empty; run cmd/reindex for an index kept in
+// search.index_dir that is not.
+
+const usage = "usage: seed [-driver sqlite3|postgres] [-db dsn] [-reset] [-tenant id] [-users n] [-tasks n] [-days n] [-completed share] [-due share] [-assigned share] [-seed n] [-password p]"
+
+func main() {
+	ctx := context.Background()
+	config := infrastructure.DatabaseConfigFromEnv()
+	flag.StringVar(&config.Driver, "driver", config.Driver, "database driver: sqlite3 or postgres")
+	flag.StringVar(&config.DSN, "db", config.DSN, "SQLite file or PostgreSQL connection string")
+	reset := flag.Bool("reset", false, "delete everything in the database first")
+	tenant := flag.String("tenant", string(domain.DefaultTenant), "tenant of the accounts and tasks")
+	var opts fixtures.Options
+	flag.IntVar(&opts.Users, "users", 3, "accounts")
+	flag.IntVar(&opts.Tasks, "tasks", 50, "tasks, spread evenly over the accounts")
+	days := flag.Int("days", 30, "how many days back the tasks were created")
+	flag.Float64Var(&opts.Completed, "completed", 0.4, "share of the tasks completed, from 0 to 1")
+	flag.Float64Var(&opts.Due, "due", 0.5, "share of the tasks with a due date")
+	flag.Float64Var(&opts.Assigned, "assigned", 0.2, "share of the tasks assigned to another account")
+	flag.Uint64Var(&opts.Seed, "seed", 1, "picks the data; the same seed makes the same")
+	flag.StringVar(&opts.Password, "password", fixtures.DefaultPassword, "password of every account")
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() > 0 || *days < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	t, err := domain.ParseTenantID(*tenant)
+	exitOn(err)
+	columns := repository.DescriptionOnly
+	if env := os.Getenv("TASKS_DESCRIPTION_COLUMNS"); env != "" {
+		columns, err = repository.ParseDescriptionColumns(env)
+		exitOn(err)
+	}
+	opts.Tenant = t
+	opts.Span = time.Duration(*days) * 24 * time.Hour
+	db, err := infrastructure.InitDatabase(config)
+	exitOn(err)
+	defer db.Close()
+	if *reset {
+		exitOn(infrastructure.ResetDatabase(db))
+		fmt.Printf("deleted everything in %s\n", config.DSN)
+	}
+
+	result, err := fixtures.Generate(ctx, repository.NewUserRepository(db), repository.NewTaskRepositoryWithColumns(db, columns), opts)
+	exitOn(err)
+	for _, user := range result.Users {
+		fmt.Printf("account %s (id %d)\n", user.Email, user.ID)
+	}
+	fmt.Printf("seeded %d tasks in tenant %s: %d completed, %d with a due date, %d assigned\n",
+		len(result.Tasks), opts.Tenant, result.Completed, result.Due, result.Assigned)
+}
+
+func exitOn(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
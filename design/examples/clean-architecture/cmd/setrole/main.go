@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+// setrole changes an account's role straight in the database:
+//
+//	go run ./cmd/setrole [-db tasks.db] [-tenant acme] -email alice@example.com -role admin
+//
+// Only an admin can change roles over HTTP, so the first admin is made here,
+// by someone with access to the server. An email is unique within a tenant,
+// so the account is looked up in -tenant, the default tenant if not given.
+// The database is the server's, from DB_DRIVER and DB_DSN, unless -driver and
+// -db name another.
+
+const usage = "usage: setrole [-driver sqlite3|postgres] [-db dsn] [-tenant id] -email address -role user|admin"
+
+func main() {
+	ctx := context.Background()
+	config := infrastructure.DatabaseConfigFromEnv()
+	flag.StringVar(&config.Driver, "driver", config.Driver, "database driver: sqlite3 or postgres")
+	flag.StringVar(&config.DSN, "db", config.DSN, "SQLite file or PostgreSQL connection string")
+	email := flag.String("email", "", "account to change")
+	role := flag.String("role", "", "new role: user or admin")
+	tenant := flag.String("tenant", string(domain.DefaultTenant), "tenant of the account")
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	flag.Parse()
+	if *email == "" || *role == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r, err := domain.ParseRole(*role)
+	exitOn(err)
+	t, err := domain.ParseTenantID(*tenant)
+	exitOn(err)
+	ctx = domain.WithTenant(ctx, t)
+	db, err := infrastructure.InitDatabase(config)
+	exitOn(err)
+	defer db.Close()
+
+	users := repository.NewUserRepository(db)
+	normalized, err := domain.NormalizeEmail(*email)
+	exitOn(err)
+	user, err := users.GetByEmail(ctx, normalized)
+	exitOn(err)
+	exitOn(users.SetRole(ctx, user.ID, r))
+	fmt.Printf("%s (id %d) is now %s\n", user.Email, user.ID, r)
+}
+
+func exitOn(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,402 @@
+// Package config loads the server's settings. Each comes, in increasing
+// precedence, from its default, a YAML file, an environment variable and a
+// command-line flag, so that a flag given for one run beats the file every
+// instance shares. Secrets have no flag: the command line is visible to
+// every user of the machine.
+//
+// Only main uses this package; it hands each layer the part it needs, such
+// as infrastructure.DatabaseConfig, so nothing else reads the environment.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile names the YAML file when there is no -config flag
+const EnvFile = "CONFIG_FILE"
+
+// JSON encoders the server can answer the GET endpoints with
+const (
+	JSONStandard = "standard"
+	JSONFast     = "fast" // handler/task_json.go
+)
+
+type Config struct {
+	Server      Server      `yaml:"server"`
+	Database    Database    `yaml:"database"`
+	MongoDB     MongoDB     `yaml:"mongodb"`
+	Auth        Auth        `yaml:"auth"`
+	Events      Events      `yaml:"events"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Attachments Attachments `yaml:"attachments"`
+	Archive     Archive     `yaml:"archive"`
+	Tenancy     Tenancy     `yaml:"tenancy"`
+	Search      Search      `yaml:"search"`
+	Reminders   Reminders   `yaml:"reminders"`
+}
+
+type Server struct {
+	Addr            string        `yaml:"addr"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`  // of each request but the task streams
+	StopTimeout     time.Duration `yaml:"stop_timeout"`     // of each component on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // of the whole shutdown
+	DrainDelay      time.Duration `yaml:"drain_delay"`      // unready before the server stops accepting
+	JSONEncoder     string        `yaml:"json_encoder"`
+	BaseURL         string        `yaml:"base_url"` // begins the links in responses; if empty, they are paths
+}
+
+type Database struct {
+	Driver             string `yaml:"driver"`
+	DSN                string `yaml:"dsn"` // for SQLite, by default ./tasks.db
+	DescriptionColumns string `yaml:"description_columns"`
+}
+
+type MongoDB struct {
+	URI string `yaml:"uri"` // if set, tasks are kept in MongoDB
+}
+
+type Auth struct {
+	JWTSecret string        `yaml:"jwt_secret"` // if empty, a random one for this run
+	TokenTTL  time.Duration `yaml:"token_ttl"`
+}
+
+type Events struct {
+	WebhookURL    string `yaml:"webhook_url"`
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl"` // how long the response of an Idempotency-Key is replayed
+}
+
+type Attachments struct {
+	Dir string `yaml:"dir"` // where the files attached to tasks are kept
+}
+
+type Archive struct {
+	Retention time.Duration `yaml:"retention"` // how long completed tasks are kept before they are archived
+	Interval  time.Duration `yaml:"interval"`  // between looks for tasks to archive
+}
+
+type Tenancy struct {
+	Domain string `yaml:"domain"` // if set, a request to <tenant>.<domain> is for that tenant
+}
+
+type Search struct {
+	IndexDir string `yaml:"index_dir"` // where the full-text index is kept; if empty, in memory
+}
+
+type Reminders struct {
+	Lead time.Duration `yaml:"lead"` // how long before a task is due its owner is reminded
+}
+
+type Tracing struct {
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+}
+
+// Default is the configuration with nothing set
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			RequestTimeout:  5 * time.Second,
+			StopTimeout:     10 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+			JSONEncoder:     JSONStandard,
+		},
+		Database: Database{
+			Driver:             infrastructure.DriverSQLite,
+			DescriptionColumns: repository.DescriptionOnly.String(),
+		},
+		Auth:        Auth{TokenTTL: 24 * time.Hour},
+		Tracing:     Tracing{Exporter: infrastructure.TracesNone, ServiceName: "tasks-api"},
+		Idempotency: Idempotency{TTL: 24 * time.Hour},
+		Attachments: Attachments{Dir: "./attachments"},
+		Archive:     Archive{Retention: 30 * 24 * time.Hour, Interval: time.Hour},
+		Reminders:   Reminders{Lead: time.Hour},
+	}
+}
+
+// A setting is one field of Config and the names it goes by
+type setting struct {
+	key   string // in the YAML file, and in errors
+	env   string
+	flag  string // none for secrets
+	usage string
+	field func(c *Config) any // *string or *time.Duration
+}
+
+var settings = []setting{
+	{"server.addr", "HTTP_ADDR", "addr", "address to listen on",
+		func(c *Config) any { return &c.Server.Addr }},
+	{"server.request_timeout", "REQUEST_TIMEOUT", "request-timeout", "deadline of each request",
+		func(c *Config) any { return &c.Server.RequestTimeout }},
+	{"server.stop_timeout", "STOP_TIMEOUT", "stop-timeout", "time each component has to stop",
+		func(c *Config) any { return &c.Server.StopTimeout }},
+	{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "shutdown-timeout", "time the whole shutdown has",
+		func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"server.drain_delay", "SHUTDOWN_DRAIN_DELAY", "drain-delay", "how long /readyz is 503 before the server stops accepting",
+		func(c *Config) any { return &c.Server.DrainDelay }},
+	{"server.json_encoder", "JSON_ENCODER", "json-encoder", "JSON encoder of the GET endpoints: standard or fast",
+		func(c *Config) any { return &c.Server.JSONEncoder }},
+	{"server.base_url", "BASE_URL", "base-url", "URL clients reach the server at, such as https://tasks.example.com, which links begin with",
+		func(c *Config) any { return &c.Server.BaseURL }},
+	{"database.driver", "DB_DRIVER", "driver", "database driver: sqlite3 or postgres",
+		func(c *Config) any { return &c.Database.Driver }},
+	{"database.dsn", "DB_DSN", "db", "SQLite file or PostgreSQL connection string",
+		func(c *Config) any { return &c.Database.DSN }},
+	{"database.description_columns", "TASKS_DESCRIPTION_COLUMNS", "description-columns", "step of the description -> details rename",
+		func(c *Config) any { return &c.Database.DescriptionColumns }},
+	{"mongodb.uri", "MONGODB_URI", "", "MongoDB to keep the tasks in",
+		func(c *Config) any { return &c.MongoDB.URI }},
+	{"auth.jwt_secret", "JWT_SECRET", "", "key signing the access tokens, 32 bytes or more",
+		func(c *Config) any { return &c.Auth.JWTSecret }},
+	{"auth.token_ttl", "TOKEN_TTL", "token-ttl", "lifetime of an access token",
+		func(c *Config) any { return &c.Auth.TokenTTL }},
+	{"events.webhook_url", "EVENTS_WEBHOOK_URL", "webhook-url", "URL domain events are posted to",
+		func(c *Config) any { return &c.Events.WebhookURL }},
+	{"events.webhook_secret", "EVENTS_WEBHOOK_SECRET", "", "key signing the webhook deliveries",
+		func(c *Config) any { return &c.Events.WebhookSecret }},
+	{"tracing.exporter", "OTEL_TRACES_EXPORTER", "traces-exporter", "where spans go: none, stdout or otlp",
+		func(c *Config) any { return &c.Tracing.Exporter }},
+	{"tracing.service_name", "OTEL_SERVICE_NAME", "service-name", "service.name of the spans",
+		func(c *Config) any { return &c.Tracing.ServiceName }},
+	{"idempotency.ttl", "IDEMPOTENCY_TTL", "idempotency-ttl", "how long a create retried with the same Idempotency-Key is answered from the first",
+		func(c *Config) any { return &c.Idempotency.TTL }},
+	{"attachments.dir", "ATTACHMENTS_DIR", "attachments-dir", "directory the files attached to tasks are kept in",
+		func(c *Config) any { return &c.Attachments.Dir }},
+	{"archive.retention", "ARCHIVE_RETENTION", "archive-retention", "how long a task stays completed before it is archived",
+		func(c *Config) any { return &c.Archive.Retention }},
+	{"archive.interval", "ARCHIVE_INTERVAL", "archive-interval", "how often completed tasks are looked for to archive",
+		func(c *Config) any { return &c.Archive.Interval }},
+	{"tenancy.domain", "TENANCY_DOMAIN", "tenancy-domain", "base domain whose subdomains name tenants",
+		func(c *Config) any { return &c.Tenancy.Domain }},
+	{"search.index_dir", "SEARCH_INDEX_DIR", "search-index-dir", "directory the full-text index of tasks is kept in, instead of memory",
+		func(c *Config) any { return &c.Search.IndexDir }},
+	{"reminders.lead", "REMINDER_LEAD", "reminder-lead", "how long before a task is due its owner is reminded",
+		func(c *Config) any { return &c.Reminders.Lead }},
+}
+
+// set parses value into the setting's field of c
+func (s setting) set(c *Config, value string) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration such as 5s", s.key, value)
+		}
+		*field = d
+	}
+	return nil
+}
+
+// Load reads the configuration from its four sources: the defaults, then
+// the YAML file named by -config or CONFIG_FILE, then the environment as
+// getenv reads it, then the flags in args. It returns every invalid setting
+// at once, and flag.ErrHelp for -h.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("tasks-api", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("config", "", "YAML file to read (default $"+EnvFile+")")
+	type flagged struct {
+		setting setting
+		value   string
+	}
+	var given []flagged
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		fs.Func(s.flag, s.usage, func(value string) error {
+			given = append(given, flagged{s, value})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *file == "" {
+		*file = getenv(EnvFile)
+	}
+	if *file != "" {
+		if err := c.readFile(*file); err != nil {
+			return c, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(&c, value); err != nil {
+				errs = append(errs, fmt.Errorf("$%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, f := range given {
+		if err := f.setting.set(&c, f.value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", f.setting.flag, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return c, err
+	}
+
+	if c.Database.DSN == "" && c.Database.Driver == infrastructure.DriverSQLite {
+		c.Database.DSN = "./tasks.db"
+	}
+	return c, c.Validate()
+}
+
+// readFile overlays the YAML file at path on c. A key c has no field for is
+// an error rather than a setting silently ignored.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate returns every setting of c that the server could not start with
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		invalid("server.addr", "%q is not a host:port such as :8080", c.Server.Addr)
+	}
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.request_timeout", c.Server.RequestTimeout},
+		{"server.stop_timeout", c.Server.StopTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"auth.token_ttl", c.Auth.TokenTTL},
+		{"idempotency.ttl", c.Idempotency.TTL},
+		{"archive.retention", c.Archive.Retention},
+		{"archive.interval", c.Archive.Interval},
+		{"reminders.lead", c.Reminders.Lead},
+	} {
+		if d.value <= 0 {
+			invalid(d.key, "must be positive, got %s", d.value)
+		}
+	}
+	if c.Server.DrainDelay < 0 {
+		invalid("server.drain_delay", "must not be negative, got %s", c.Server.DrainDelay)
+	}
+	if c.Server.RequestTimeout >= c.Server.StopTimeout && c.Server.StopTimeout > 0 {
+		invalid("server.request_timeout", "must be under server.stop_timeout (%s), so requests in flight can finish", c.Server.StopTimeout)
+	}
+	if c.Server.JSONEncoder != JSONStandard && c.Server.JSONEncoder != JSONFast {
+		invalid("server.json_encoder", "unknown encoder %q (want %s or %s)", c.Server.JSONEncoder, JSONStandard, JSONFast)
+	}
+	if c.Server.BaseURL != "" {
+		u, err := url.Parse(c.Server.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			invalid("server.base_url", "%q is not a URL such as https://tasks.example.com", c.Server.BaseURL)
+		}
+	}
+
+	if c.Database.Driver != infrastructure.DriverSQLite && c.Database.Driver != infrastructure.DriverPostgres {
+		invalid("database.driver", "unknown driver %q (want %s or %s)", c.Database.Driver, infrastructure.DriverSQLite, infrastructure.DriverPostgres)
+	} else if c.Database.DSN == "" {
+		invalid("database.dsn", "required for %s", c.Database.Driver)
+	}
+	if _, err := repository.ParseDescriptionColumns(c.Database.DescriptionColumns); err != nil {
+		invalid("database.description_columns", "%v", err)
+	}
+
+	if n := len(c.Auth.JWTSecret); n > 0 && n < infrastructure.MinJWTSecretLength {
+		invalid("auth.jwt_secret", "must be at least %d bytes, got %d", infrastructure.MinJWTSecretLength, n)
+	}
+	switch c.Tracing.Exporter {
+	case infrastructure.TracesNone, infrastructure.TracesStdout, infrastructure.TracesOTLP:
+	default:
+		invalid("tracing.exporter", "unknown exporter %q (want %s, %s or %s)", c.Tracing.Exporter,
+			infrastructure.TracesNone, infrastructure.TracesStdout, infrastructure.TracesOTLP)
+	}
+	if c.Tracing.ServiceName == "" {
+		invalid("tracing.service_name", "must not be empty")
+	}
+	if c.Attachments.Dir == "" {
+		invalid("attachments.dir", "must not be empty")
+	}
+	if strings.ContainsAny(c.Tenancy.Domain, ":/ ") {
+		invalid("tenancy.domain", "%q is not a domain such as tasks.example.com", c.Tenancy.Domain)
+	}
+	return errors.Join(errs...)
+}
+
+// DatabaseConfig is what infrastructure.InitDatabase opens
+func (c Config) DatabaseConfig() infrastructure.DatabaseConfig {
+	return infrastructure.DatabaseConfig{Driver: c.Database.Driver, DSN: c.Database.DSN}
+}
+
+// TracingConfig is what infrastructure.InitTracing sets up
+func (c Config) TracingConfig() infrastructure.TracingConfig {
+	return infrastructure.TracingConfig{Exporter: c.Tracing.Exporter, ServiceName: c.Tracing.ServiceName}
+}
+
+// DescriptionColumns is the step of the description -> details rename the
+// SQL task repository is at. Validate has checked it.
+func (c Config) DescriptionColumns() repository.DescriptionColumns {
+	columns, _ := repository.ParseDescriptionColumns(c.Database.DescriptionColumns)
+	return columns
+}
+
+// Usage writes every setting, with its key, variable, flag and default
+func Usage(w io.Writer) {
+	defaults := Default()
+	fmt.Fprintf(w, "usage: tasks-api [-config file.yaml] [flags]\n\n")
+	fmt.Fprintf(w, "  -config\n\tYAML file to read, or $%s\n", EnvFile)
+	for _, s := range settings {
+		names := []string{s.key, "$" + s.env}
+		if s.flag != "" {
+			names = append(names, "-"+s.flag)
+		}
+		fmt.Fprintf(w, "  %s\n\t%s", strings.Join(names, ", "), s.usage)
+		switch field := s.field(&defaults).(type) {
+		case *string:
+			if *field != "" {
+				fmt.Fprintf(w, " (default %s)", *field)
+			}
+		case *time.Duration:
+			if *field != 0 {
+				fmt.Fprintf(w, " (default %s)", *field)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
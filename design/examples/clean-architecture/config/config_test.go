@@ -0,0 +1,170 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+// env is an environment of just vars
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+var noEnv = env(nil)
+
+// writeFile writes a YAML file into a temporary directory and returns its
+// path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaults(t *testing.T) {
+	cfg, err := config.Load(nil, noEnv)
+	want := config.Default()
+	want.Database.DSN = "./tasks.db"
+	if err != nil || !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("Load with nothing set = %+v, %v; want the defaults, on the SQLite file ./tasks.db", cfg, err)
+	}
+	// What main.go had hardcoded
+	if cfg.Server.Addr != ":8080" || cfg.Server.RequestTimeout != 5*time.Second ||
+		cfg.Server.StopTimeout != 10*time.Second || cfg.Server.ShutdownTimeout != 15*time.Second {
+		t.Errorf("server = %+v, want :8080, 5s, 10s and 15s", cfg.Server)
+	}
+	if cfg.DatabaseConfig() != (infrastructure.DatabaseConfig{Driver: infrastructure.DriverSQLite, DSN: "./tasks.db"}) ||
+		cfg.DescriptionColumns() != repository.DescriptionOnly || cfg.TracingConfig().Exporter != infrastructure.TracesNone {
+		t.Error("infrastructure and the repository should be handed their own configuration")
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	file := writeFile(t, "tasks.yaml", `
+server:
+  addr: ":9000"
+  request_timeout: 2s
+database:
+  driver: postgres
+  dsn: postgres://file/tasks
+auth:
+  token_ttl: 1h
+`)
+	environment := map[string]string{
+		config.EnvFile:      file,
+		"HTTP_ADDR":         ":9100",
+		"TOKEN_TTL":         "30m",
+		"DB_DSN":            "postgres://env/tasks",
+		"OTEL_SERVICE_NAME": "",
+	}
+	other := writeFile(t, "other.yaml", "server:\n  addr: \":9001\"\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		ok   func(config.Config) bool
+	}{
+		{"the file overrides the defaults, durations included", []string{"-config", file}, nil, func(cfg config.Config) bool {
+			return cfg.Server.Addr == ":9000" && cfg.Server.RequestTimeout == 2*time.Second &&
+				cfg.Auth.TokenTTL == time.Hour && cfg.Database.DSN == "postgres://file/tasks"
+		}},
+		{"and keeps the defaults of what it leaves out", []string{"-config", file}, nil, func(cfg config.Config) bool {
+			return cfg.Server.StopTimeout == 10*time.Second && cfg.Tracing.ServiceName == "tasks-api"
+		}},
+		{"$CONFIG_FILE names the file too", nil, map[string]string{config.EnvFile: file}, func(cfg config.Config) bool {
+			return cfg.Server.Addr == ":9000"
+		}},
+		{"-config wins, and the other file is not read at all", []string{"-config", other}, map[string]string{config.EnvFile: file}, func(cfg config.Config) bool {
+			return cfg.Server.Addr == ":9001" && cfg.Database.Driver == infrastructure.DriverSQLite
+		}},
+		{"the environment overrides the file", nil, environment, func(cfg config.Config) bool {
+			return cfg.Server.Addr == ":9100" && cfg.Auth.TokenTTL == 30*time.Minute && cfg.Database.DSN == "postgres://env/tasks"
+		}},
+		{"and keeps what the file set that it does not", nil, environment, func(cfg config.Config) bool {
+			return cfg.Server.RequestTimeout == 2*time.Second && cfg.Database.Driver == infrastructure.DriverPostgres
+		}},
+		{"a variable set empty counts as unset", nil, environment, func(cfg config.Config) bool {
+			return cfg.Tracing.ServiceName == "tasks-api"
+		}},
+		{"a flag overrides the environment", []string{"-addr", ":9200", "-db", "postgres://flag/tasks"}, environment, func(cfg config.Config) bool {
+			return cfg.Server.Addr == ":9200" && cfg.Database.DSN == "postgres://flag/tasks"
+		}},
+		{"and keeps what the environment and the file set that it does not", []string{"-addr", ":9200"}, environment, func(cfg config.Config) bool {
+			return cfg.Auth.TokenTTL == 30*time.Minute && cfg.Server.RequestTimeout == 2*time.Second
+		}},
+		{"the last of a repeated flag wins", []string{"-addr", ":9200", "-addr", ":9300"}, nil, func(cfg config.Config) bool {
+			return cfg.Server.Addr == ":9300"
+		}},
+		{"a secret comes from a variable", nil, map[string]string{"JWT_SECRET": strings.Repeat("s", 32)}, func(cfg config.Config) bool {
+			return cfg.Auth.JWTSecret == strings.Repeat("s", 32)
+		}},
+		{"PostgreSQL gets the DSN it was given", []string{"-driver", "postgres", "-db", "postgres://flag/tasks"}, nil, func(cfg config.Config) bool {
+			return cfg.Database.DSN == "postgres://flag/tasks"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Load(tt.args, env(tt.env))
+			if err != nil || !tt.ok(cfg) {
+				t.Errorf("Load(%q) = %+v, %v", tt.args, cfg, err)
+			}
+		})
+	}
+}
+
+func TestInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		// want is what the error must mention, if anything
+		want []string
+	}{
+		{"secrets have no flag", []string{"-jwt-secret", strings.Repeat("s", 32)}, nil, nil},
+		{"PostgreSQL without a DSN gets no SQLite file", []string{"-driver", "postgres"}, nil, []string{"database.dsn"}},
+		{"a key the file should not have is refused, not ignored", []string{"-config", writeFile(t, "typo.yaml", "server:\n  adr: \":9000\"\n")}, nil, []string{"adr"}},
+		{"a file that is not there", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, nil, nil},
+		{"a bad variable is named", nil, map[string]string{"REQUEST_TIMEOUT": "5"}, []string{"$REQUEST_TIMEOUT"}},
+		{"a bad flag is named", []string{"-token-ttl", "tomorrow"}, nil, []string{"-token-ttl"}},
+		{"every invalid setting is reported at once", []string{
+			"-addr", "8080",
+			"-request-timeout", "30s",
+			"-json-encoder", "xml",
+			"-description-columns", "both",
+			"-traces-exporter", "jaeger",
+		}, map[string]string{"JWT_SECRET": "short"}, []string{"server.addr", "server.request_timeout", "server.json_encoder",
+			"database.description_columns", "auth.jwt_secret", "tracing.exporter"}},
+		{"a reminder lead that is not positive", []string{"-reminder-lead", "0s"}, nil, []string{"reminders.lead"}},
+		{"an argument that is not a flag", []string{"serve"}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Load(tt.args, env(tt.env))
+			if err == nil {
+				t.Fatalf("Load(%q) succeeded", tt.args)
+			}
+			for _, key := range tt.want {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("Load(%q) = %v, want it to name %s", tt.args, err, key)
+				}
+			}
+		})
+	}
+
+	if _, err := config.Load([]string{"-h"}, noEnv); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("-h = %v, want %v: it asks for the usage", err, flag.ErrHelp)
+	}
+}
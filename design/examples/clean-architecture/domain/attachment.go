@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Attachments are files kept with a task, under a name unique to it. Their
+// content is streamed through the AttachmentStorage port, never held whole
+// by the use cases, so where it is stored is up to the adapter.
+
+const MaxAttachmentNameLength = 255
+
+var (
+	ErrInvalidAttachmentName = NewError("ATTACHMENT_NAME_INVALID", KindInvalid, "attachment name must be 1 to 255 bytes of printable characters, without slashes, and not . or ..")
+	ErrAttachmentNotFound    = NewError("ATTACHMENT_NOT_FOUND", KindNotFound, "attachment not found")
+)
+
+// Attachment describes a stored file, without its content
+type Attachment struct {
+	TaskID      int64
+	Name        string
+	ContentType string // a media type without parameters, such as image/png
+	Size        int64  // in bytes
+	CreatedAt   time.Time
+}
+
+// ValidateAttachmentName checks that name can name an attachment: it is
+// used as is in URLs and by storages, so it cannot be a path
+func ValidateAttachmentName(name string) error {
+	if name == "" || len(name) > MaxAttachmentNameLength || name == "." || name == ".." ||
+		!utf8.ValidString(name) || strings.ContainsAny(name, `/\`) {
+		return ErrInvalidAttachmentName
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return ErrInvalidAttachmentName
+		}
+	}
+	return nil
+}
+
+// AttachmentStorage keeps the attachments of tasks. Each is addressed by its
+// task's ID and its name; storing one under a name in use replaces it.
+type AttachmentStorage interface {
+	// Put stores content under attachment's TaskID and Name, reading it to
+	// the end, and returns the attachment as stored, with its Size. If
+	// reading content fails, Put returns that error and stores nothing:
+	// an attachment is never seen half written.
+	Put(ctx context.Context, attachment Attachment, content io.Reader) (Attachment, error)
+	// Open returns the attachment and its content, which the caller closes,
+	// or ErrAttachmentNotFound
+	Open(ctx context.Context, taskID int64, name string) (Attachment, io.ReadCloser, error)
+	// List returns the attachments of a task, by name
+	List(ctx context.Context, taskID int64) ([]Attachment, error)
+	// Delete removes an attachment, or returns ErrAttachmentNotFound
+	Delete(ctx context.Context, taskID int64, name string) error
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// A task never reads the time itself: the time a change to it is stamped
+// with is passed in, as now, by the use case making it, which takes it from
+// its Clock. A check can then run the use cases on a fake clock
+// (domain/clocktest) and know every time they will stamp.
+
+// Clock tells the time
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the real time
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,27 @@
+package clocktest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+)
+
+var _ domain.Clock = (*clocktest.Clock)(nil)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2030, 7, 1, 9, 0, 0, 0, time.UTC)
+	clock := clocktest.New(start)
+	if !clock.Now().Equal(start) || !clock.Now().Equal(start) {
+		t.Errorf("Now = %v, want it to stay at %v", clock.Now(), start)
+	}
+	clock.Advance(90 * time.Minute)
+	if want := start.Add(90 * time.Minute); !clock.Now().Equal(want) {
+		t.Errorf("after Advance, Now = %v, want %v", clock.Now(), want)
+	}
+	clock.Set(start.Add(-time.Hour))
+	if want := start.Add(-time.Hour); !clock.Now().Equal(want) {
+		t.Errorf("after Set back, Now = %v, want %v", clock.Now(), want)
+	}
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Error taxonomy - every error a client can act on carries a stable Code.
+// Messages are for people and may be reworded; codes are part of the API
+// contract, since clients branch on them. Kind says what went wrong in
+// transport-neutral terms, and the handler layer maps it to an HTTP status.
+
+// Code is a stable, machine-readable error identifier such as TASK_TITLE_EMPTY
+type Code string
+
+type Kind int
+
+const (
+	KindInvalid         Kind = iota // the input breaks a business rule
+	KindNotFound                    // the addressed entity does not exist
+	KindConflict                    // the entity's state does not allow the operation
+	KindInternal                    // a failure the client cannot fix
+	KindUnauthenticated             // the caller has not proven who they are
+	KindForbidden                   // the caller may not do this
+	KindUnavailable                 // the work did not finish in time; retrying may succeed
+	KindPrecondition                // a condition the caller set on the operation does not hold
+)
+
+// Error is a coded error. Errors are compared by code, so an *Error
+// rebuilt from an API response matches the sentinel it came from.
+type Error struct {
+	Code    Code
+	Kind    Kind
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is makes errors.Is match any *Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var registry = map[Code]*Error{}
+
+// NewError declares a coded error. It is meant for package-level sentinels
+// and panics if the code is already taken, so a clash fails at startup.
+func NewError(code Code, kind Kind, message string) *Error {
+	if existing, ok := registry[code]; ok {
+		panic(fmt.Sprintf("domain: error code %s declared twice (%q and %q)", code, existing.Message, message))
+	}
+	e := &Error{Code: code, Kind: kind, Message: message}
+	registry[code] = e
+	return e
+}
+
+// Codes returns every declared error, ordered by code
+func Codes() []*Error {
+	errs := make([]*Error, 0, len(registry))
+	for _, e := range registry {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Code < errs[j].Code })
+	return errs
+}
+
+// Lookup returns the declared error for code
+func Lookup(code Code) (*Error, bool) {
+	e, ok := registry[code]
+	return e, ok
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Domain events are the facts other systems react to after the fact, such
+// as a webhook that a task was completed. Unlike the TaskEvents of a stream,
+// which are best effort and gone on restart, they are durable: the use cases
+// record them on the task they change, and the repository stores them in
+// its outbox in the same transaction as the change. So an event is stored
+// exactly when its change is, and is delivered later, at least once, even
+// if the process dies in between.
+
+// EventName says what happened
+type EventName string
+
+const (
+	TaskCreatedEvent   EventName = "TaskCreated"
+	TaskCompletedEvent EventName = "TaskCompleted"
+)
+
+type Event struct {
+	ID         int64 // set by the outbox; the same on every delivery
+	Name       EventName
+	TaskID     int64 // set by the outbox, for a task that had none yet
+	OwnerID    int64
+	Title      string
+	OccurredAt time.Time
+}
+
+// Record notes that name happened to the task, now. The repository stores
+// the event with the task, when the task is next created or updated.
+func (t *Task) Record(name EventName, now time.Time) {
+	t.events = append(t.events, Event{
+		Name:       name,
+		TaskID:     t.ID,
+		OwnerID:    t.OwnerID,
+		Title:      t.Title,
+		OccurredAt: now,
+	})
+}
+
+// PendingEvents returns the events recorded since the task was last stored.
+// Repositories add them to the outbox in the transaction that stores the
+// task, then call ClearEvents once it has committed.
+func (t *Task) PendingEvents() []Event {
+	return t.events
+}
+
+func (t *Task) ClearEvents() {
+	t.events = nil
+}
+
+// OutboxEvent is a stored event with its delivery so far
+type OutboxEvent struct {
+	Event
+	Attempts  int    // failed deliveries
+	LastError string // of the last failed delivery
+}
+
+// Outbox holds stored events until they are delivered. The task
+// repositories add to it; the dispatcher takes from it.
+type Outbox interface {
+	// Due returns up to limit events that are neither delivered nor given
+	// up on and whose next attempt is due at now, oldest first
+	Due(ctx context.Context, now time.Time, limit int) ([]OutboxEvent, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	// MarkFailed records a failed delivery and when to try again. A zero
+	// retryAt gives up on the event: it is kept, but never due again.
+	MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+}
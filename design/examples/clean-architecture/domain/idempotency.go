@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Idempotency keys let a client retry a create whose response it never got,
+// without creating the task twice. The HTTP layer claims the key before the
+// request runs and keeps the response once it has; a retry with the same
+// key is then answered with that response instead of running again.
+
+// IdempotencyKey is a client's key, scoped to the user who sent it, so two
+// users picking the same key never see each other's responses
+type IdempotencyKey struct {
+	OwnerID int64
+	Key     string
+}
+
+// StoredResponse is the response a request with an idempotency key was
+// answered with, as it is replayed
+type StoredResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyRecord is what is kept of a key: the fingerprint of the request
+// that claimed it, and its response once there is one
+type IdempotencyRecord struct {
+	Fingerprint string
+	Response    *StoredResponse // nil while the request is in progress
+	ExpiresAt   time.Time
+}
+
+// IdempotencyStore keeps idempotency keys until they expire. A key that has
+// expired is as good as one never used.
+type IdempotencyStore interface {
+	// Begin claims key for the request with fingerprint until expiresAt, and
+	// returns nil. If the key is held by a record that has not expired at
+	// now, it claims nothing and returns that record. Either way, records
+	// expired at now are removed.
+	Begin(ctx context.Context, key IdempotencyKey, fingerprint string, now, expiresAt time.Time) (*IdempotencyRecord, error)
+	// Finish keeps the response of the request holding key until expiresAt
+	Finish(ctx context.Context, key IdempotencyKey, response StoredResponse, expiresAt time.Time) error
+	// Abandon frees a key whose request did not succeed, so a retry runs it
+	// again. A key that already has a response is kept.
+	Abandon(ctx context.Context, key IdempotencyKey) error
+}
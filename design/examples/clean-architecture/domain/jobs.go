@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Background jobs are work the server does later than the request that
+// asked for it, such as sending a reminder when a task comes due. A use
+// case enqueues a Job on a JobQueue; the queue runs it, through the
+// JobHandler of its Kind, once its RunAt has come. A job that fails is
+// retried, and one that keeps failing ends up with the dead letters, to be
+// looked into, rather than being retried forever.
+
+// JobKind says what a job does, and so which JobHandler runs it
+type JobKind string
+
+const SendReminderJob JobKind = "send_reminder"
+
+var ErrJobKindEmpty = NewError("JOB_KIND_EMPTY", KindInvalid, "a job must have a kind")
+
+type Job struct {
+	ID      int64 // set by Enqueue
+	Kind    JobKind
+	Payload []byte    // for the handler of Kind, such as JSON
+	RunAt   time.Time // not before then; zero is as soon as possible
+	// Attempts is how many times the job has failed, and LastError why it
+	// did the last time
+	Attempts  int
+	LastError string
+}
+
+// JobQueue holds jobs until they are run. Like TaskRepository it is defined
+// here and implemented in outer layers.
+type JobQueue interface {
+	// Enqueue adds a job and returns its ID
+	Enqueue(ctx context.Context, job Job) (int64, error)
+	// DeadLetters returns the jobs given up on, in the order they were: those
+	// that failed every attempt, and those of a kind nothing handles
+	DeadLetters(ctx context.Context) ([]Job, error)
+}
+
+// JobHandler runs the jobs of a kind. Returning an error fails the attempt,
+// so the queue tries again later: a job may run more than once, and a
+// handler that must act once checks whether it already has.
+type JobHandler interface {
+	HandleJob(ctx context.Context, job Job) error
+}
+
+// Reminder is a task that comes due, for its owner
+type Reminder struct {
+	TaskID   int64
+	OwnerID  int64
+	TenantID TenantID
+	Title    string
+	DueAt    time.Time
+	Overdue  bool // it was already due when the reminder was sent
+}
+
+// ReminderSender tells a task's owner that it comes due, such as by email
+type ReminderSender interface {
+	SendReminder(ctx context.Context, reminder Reminder) error
+}
@@ -1,31 +1,60 @@
 package domain
 
 import (
-"errors"
+"context"
 "time"
 )
 
 // Task represents the core business entity
 // This is the innermost layer with no dependencies on other layers
+//
+//go:generate go run ../cmd/repogen -type Task -table tasks -out ../repository
 type Task struct {
 	ID          int64
+	OwnerID     int64 // the User the task belongs to
 	Title       string
-	Description string
+	// Description is stored in tasks.details, which replaced
+	// tasks.description (see infrastructure.SchemaContracted)
+	Description string `repo:"column=details"`
 	Completed   bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// Priority and Tags are stored by the hand-written repositories: tags live
+	// in their own table, which the generated stores do not model
+	Priority  Priority `repo:"-"`
+	Tags      []string `repo:"-"` // normalized, see NormalizeTags
+	// Version counts the task's stored changes, from 1 when it is created.
+	// TaskRepository.Update only stores a task whose Version is still the
+	// stored one, so two writers cannot silently overwrite each other.
+	Version   int64 `repo:"-"`
+	// AssigneeID is the User the task is assigned to, 0 for nobody. The
+	// assignee may view the task; it stays the owner's to change.
+	AssigneeID int64 `repo:"-"`
+	// ParentID is the task this one is a subtask of, 0 for a top-level task.
+	// It is set when the task is created, to a task of the same owner, and
+	// never changes.
+	ParentID int64 `repo:"-"`
+	// TenantID is the tenant the task belongs to, which is its owner's
+	TenantID TenantID `repo:"tenant"`
+	// DueAt is when the task is due, zero if it is not (see SetDue)
+	DueAt     time.Time `repo:"-"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// events are the domain events recorded since the task was last stored
+	// (see events.go)
+	events []Event
 }
 
 // Business rules and validations belong in the domain layer
 
 var (
-ErrEmptyTitle         = errors.New("task title cannot be empty")
-ErrTitleTooLong       = errors.New("task title cannot exceed 200 characters")
-ErrDescriptionTooLong = errors.New("task description cannot exceed 1000 characters")
+ErrEmptyTitle         = NewError("TASK_TITLE_EMPTY", KindInvalid, "task title cannot be empty")
+ErrTitleTooLong       = NewError("TASK_TITLE_TOO_LONG", KindInvalid, "task title cannot exceed 200 characters")
+ErrDescriptionTooLong = NewError("TASK_DESCRIPTION_TOO_LONG", KindInvalid, "task description cannot exceed 1000 characters")
+ErrVersionConflict    = NewError("TASK_VERSION_CONFLICT", KindConflict, "task was changed since it was read; reload it and try again")
+ErrAssignCompleted    = NewError("TASK_ASSIGN_COMPLETED", KindConflict, "a completed task cannot be assigned; reopen it first")
 )
 
-// NewTask creates a new task with validation
-func NewTask(title, description string) (*Task, error) {
+// NewTask creates a new task for its owner with validation, created now
+func NewTask(ownerID int64, title, description string, now time.Time) (*Task, error) {
 	if err := ValidateTitle(title); err != nil {
 		return nil, err
 	}
@@ -33,11 +62,13 @@ func NewTask(title, description string) (*Task, error) {
 		return nil, err
 	}
 
-	now := time.Now()
 	return &Task{
+		OwnerID:     ownerID,
 		Title:       title,
 		Description: description,
 		Completed:   false,
+		Priority:    PriorityMedium,
+		Version:     1,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}, nil
@@ -63,19 +94,36 @@ func ValidateDescription(description string) error {
 }
 
 // MarkAsCompleted marks the task as completed
-func (t *Task) MarkAsCompleted() {
+func (t *Task) MarkAsCompleted(now time.Time) {
 	t.Completed = true
-	t.UpdatedAt = time.Now()
+	t.UpdatedAt = now
 }
 
 // MarkAsIncomplete marks the task as incomplete
-func (t *Task) MarkAsIncomplete() {
+func (t *Task) MarkAsIncomplete(now time.Time) {
 	t.Completed = false
-	t.UpdatedAt = time.Now()
+	t.UpdatedAt = now
+}
+
+// Assign assigns the task to the user with userID, replacing any assignee.
+// Completed tasks cannot be assigned: there is nothing left to do.
+func (t *Task) Assign(userID int64, now time.Time) error {
+	if t.Completed {
+		return ErrAssignCompleted
+	}
+	t.AssigneeID = userID
+	t.UpdatedAt = now
+	return nil
+}
+
+// Unassign leaves the task assigned to nobody, completed or not
+func (t *Task) Unassign(now time.Time) {
+	t.AssigneeID = 0
+	t.UpdatedAt = now
 }
 
 // Update updates the task with new values
-func (t *Task) Update(title, description string, completed bool) error {
+func (t *Task) Update(title, description string, completed bool, now time.Time) error {
 	if err := ValidateTitle(title); err != nil {
 		return err
 	}
@@ -86,16 +134,29 @@ func (t *Task) Update(title, description string, completed bool) error {
 	t.Title = title
 	t.Description = description
 	t.Completed = completed
-	t.UpdatedAt = time.Now()
+	t.UpdatedAt = now
 	return nil
 }
 
 // TaskRepository defines the interface for task persistence
-// This is defined in the domain layer but implemented in outer layers
+// This is defined in the domain layer but implemented in outer layers.
+// Every method stops and returns ctx.Err() once ctx is done.
+// Every method acts within the tenant of ctx (TenantOf): a task of another
+// tenant is not found, and a task created is created in it.
 type TaskRepository interface {
-	Create(task *Task) error
-	GetByID(id int64) (*Task, error)
-	GetAll() ([]*Task, error)
-	Update(task *Task) error
-	Delete(id int64) error
+	Create(ctx context.Context, task *Task) error
+	// CreateBatch stores every task or none of them, setting their IDs
+	CreateBatch(ctx context.Context, tasks []*Task) error
+	// GetByID returns an error matching sql.ErrNoRows if there is no such task
+	GetByID(ctx context.Context, id int64) (*Task, error)
+	// GetByIDs returns the tasks among ids that exist, each once, in ID order.
+	// It reads them in one round trip, or in as few as the store's limit on
+	// query parameters allows.
+	GetByIDs(ctx context.Context, ids []int64) ([]*Task, error)
+	List(ctx context.Context, query ListTasksQuery) ([]*Task, error)
+	// Update stores the task if the stored one still has task.Version, and
+	// then increments task.Version. Otherwise, including when the task no
+	// longer exists, it stores nothing and returns ErrVersionConflict.
+	Update(ctx context.Context, task *Task) error
+	Delete(ctx context.Context, id int64) error
 }
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ArchivedTask is a task moved out of the tasks by TaskArchive. It is no
+// longer a task of TaskRepository: it cannot be got, listed or changed
+// there, only listed from the archive.
+type ArchivedTask struct {
+	Task
+	ArchivedAt time.Time
+}
+
+// TaskArchive moves old completed tasks out of the way of the live ones.
+// Like TaskRepository it is defined here and implemented in outer layers,
+// which usually store the archive next to the tasks, and it acts within the
+// tenant of ctx.
+type TaskArchive interface {
+	// Archive moves up to limit completed tasks last changed before
+	// completedBefore into the archive, those changed longest ago first,
+	// and returns them as archived at now. A task changed while it is
+	// being archived stays a task.
+	Archive(ctx context.Context, completedBefore, now time.Time, limit int) ([]ArchivedTask, error)
+	// ListArchived returns the archived tasks of ownerID, or of everyone for
+	// 0, most recently archived first
+	ListArchived(ctx context.Context, ownerID int64) ([]ArchivedTask, error)
+}
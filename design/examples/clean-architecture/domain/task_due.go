@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+var ErrDueBeforeCreated = NewError("TASK_DUE_BEFORE_CREATED", KindInvalid, "a task cannot be due before it is created")
+
+// SetDue sets when the task is due, to the second; a zero due removes it.
+// A task cannot be due before it was created.
+func (t *Task) SetDue(due, now time.Time) error {
+	if due.IsZero() {
+		t.DueAt = time.Time{}
+		return nil
+	}
+	due = due.Truncate(time.Second)
+	if due.Before(t.CreatedAt.Truncate(time.Second)) {
+		return ErrDueBeforeCreated
+	}
+	t.DueAt = due
+	t.UpdatedAt = now
+	return nil
+}
+
+// Overdue reports whether the task is still open at now, after it was due
+func (t *Task) Overdue(now time.Time) bool {
+	return !t.Completed && !t.DueAt.IsZero() && now.After(t.DueAt)
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "context"
+
+// TaskEventType is what happened to a task
+type TaskEventType string
+
+const (
+	TaskCreated TaskEventType = "created"
+	TaskUpdated TaskEventType = "updated" // completing a task is an update
+	TaskDeleted TaskEventType = "deleted"
+	// TaskArchived is a completed task moved to the TaskArchive: gone from
+	// the tasks, like a deleted one
+	TaskArchived TaskEventType = "archived"
+)
+
+var ErrTaskEventsStopped = NewError("TASK_EVENTS_STOPPED", KindUnavailable, "task events are no longer carried; the server is shutting down")
+
+// TaskEvent reports a change the use cases made. Task is a copy of the task
+// as it was stored, or for TaskDeleted and TaskArchived, as it was when it
+// was deleted or archived.
+type TaskEvent struct {
+	Type TaskEventType
+	Task Task
+}
+
+// TaskEvents carries task events from the use cases to whoever watches them.
+// Like TaskRepository it is defined here and implemented in outer layers.
+type TaskEvents interface {
+	// Publish reports an event that has happened. It does not wait for
+	// watchers, and cannot fail the change it reports.
+	Publish(ctx context.Context, event TaskEvent)
+	// Subscribe delivers the events of ownerID's tasks, or of every task for
+	// 0, in the tenant of ctx (TenantOf), in the order they were published.
+	// The channel is closed once ctx is done or the events stop being
+	// carried, as on shutdown; after that, Subscribe fails with
+	// ErrTaskEventsStopped.
+	Subscribe(ctx context.Context, ownerID int64) (<-chan TaskEvent, error)
+	// Follow delivers every event, of every owner and tenant, like Subscribe
+	// with 0 for AllTenants, but never drops one to keep up: a follower that
+	// falls far enough behind holds Publish up until it catches up. It is
+	// for projections, which must see every change.
+	Follow(ctx context.Context) (<-chan TaskEvent, error)
+}
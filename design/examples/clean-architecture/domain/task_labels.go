@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Priority ranks a task. New tasks are medium.
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+)
+
+const (
+	MaxTags      = 20
+	MaxTagLength = 50 // characters
+)
+
+var (
+	ErrInvalidPriority = NewError("TASK_PRIORITY_INVALID", KindInvalid, "task priority must be low, medium or high")
+	ErrInvalidTag      = NewError("TASK_TAG_INVALID", KindInvalid, "tags must be 1 to 50 characters")
+	ErrTooManyTags     = NewError("TASK_TOO_MANY_TAGS", KindInvalid, "a task cannot have more than 20 tags")
+)
+
+// Valid reports whether p is one of the three priorities
+func (p Priority) Valid() bool {
+	return p == PriorityLow || p == PriorityMedium || p == PriorityHigh
+}
+
+// ParsePriority accepts a priority in any letter case
+func ParsePriority(s string) (Priority, error) {
+	p := Priority(strings.ToLower(strings.TrimSpace(s)))
+	if !p.Valid() {
+		return "", ErrInvalidPriority
+	}
+	return p, nil
+}
+
+// NormalizeTags returns tags in canonical form: trimmed, lowercased, without
+// duplicates and sorted, so "Urgent" and " urgent" are the same tag. Tags are
+// otherwise free-form.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, ErrInvalidTag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	if len(out) > MaxTags {
+		return nil, ErrTooManyTags
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// SetPriority changes the task's priority
+func (t *Task) SetPriority(p Priority, now time.Time) error {
+	if !p.Valid() {
+		return ErrInvalidPriority
+	}
+	t.Priority = p
+	t.UpdatedAt = now
+	return nil
+}
+
+// SetTags replaces the task's tags with their canonical form
+func (t *Task) SetTags(tags []string, now time.Time) error {
+	normalized, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	t.Tags = normalized
+	t.UpdatedAt = now
+	return nil
+}
+
+// HasTags reports whether the task carries every one of tags, which must be
+// normalized
+func (t *Task) HasTags(tags []string) bool {
+	for _, want := range tags {
+		i := sort.SearchStrings(t.Tags, want)
+		if i == len(t.Tags) || t.Tags[i] != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package domain_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		in   string
+		want domain.Priority
+		err  error
+	}{
+		{"low", domain.PriorityLow, nil},
+		{"Medium", domain.PriorityMedium, nil},
+		{" HIGH ", domain.PriorityHigh, nil},
+		{"", "", domain.ErrInvalidPriority},
+		{"urgent", "", domain.ErrInvalidPriority},
+		{"1", "", domain.ErrInvalidPriority},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.in), func(t *testing.T) {
+			got, err := domain.ParsePriority(tt.in)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("ParsePriority = %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	many := make([]string, domain.MaxTags+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("tag-%d", i)
+	}
+	tests := []struct {
+		name string
+		tags []string
+		want string // the normalized tags, comma-separated
+		err  error
+	}{
+		{"trimmed, lowercased, deduplicated and sorted", []string{"Backend", " backend ", "API", "café"}, "api,backend,café", nil},
+		{"none", nil, "", nil},
+		{"a blank tag", []string{"ok", "  "}, "", domain.ErrInvalidTag},
+		{"a tag over the length limit in characters", []string{strings.Repeat("é", domain.MaxTagLength+1)}, "", domain.ErrInvalidTag},
+		{"a tag at the limit", []string{strings.Repeat("é", domain.MaxTagLength)}, strings.Repeat("é", domain.MaxTagLength), nil},
+		{"too many tags", many, "", domain.ErrTooManyTags},
+		{"a duplicate does not count against the limit", append(many[:domain.MaxTags:domain.MaxTags], "TAG-0"), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := domain.NormalizeTags(tt.tags)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("NormalizeTags = %v, want %v", err, tt.err)
+			}
+			if tt.want != "" && strings.Join(got, ",") != tt.want {
+				t.Errorf("NormalizeTags = %q, want %s", got, tt.want)
+			}
+			if tt.err == nil && len(got) > domain.MaxTags {
+				t.Errorf("NormalizeTags returned %d tags", len(got))
+			}
+		})
+	}
+}
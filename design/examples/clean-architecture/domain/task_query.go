@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidDateRange = NewError("TASK_QUERY_INVALID_DATE_RANGE", KindInvalid, "created date range ends before it starts")
+	ErrInvalidPage      = NewError("TASK_QUERY_INVALID_PAGE", KindInvalid, "limit must be 1 to 100, and offset not negative")
+)
+
+// MaxPageSize is the most tasks a listing's Limit may ask for
+const MaxPageSize = 100
+
+// ListTasksQuery narrows a task listing. Zero-valued fields match every task,
+// so the zero query lists them all.
+type ListTasksQuery struct {
+	// OwnerID limits the listing to one user's tasks. Only admins may leave
+	// it 0 or name someone else; the use case enforces that.
+	OwnerID int64
+	// AssigneeID limits it to the tasks assigned to one user, whoever owns
+	// them
+	AssigneeID int64
+	// ParentIDs limits it to the subtasks of those tasks
+	ParentIDs     []int64
+	Completed     *bool
+	CreatedFrom   time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+	Tags          []string  // the task has every one of them
+	// Search matches tasks whose title or description contains it, ignoring
+	// the case of ASCII letters
+	Search string
+	// Offset skips that many of the matching tasks, in the listing's order,
+	// and Limit, unless 0, keeps at most that many of the rest. Neither is
+	// part of Matches.
+	Limit  int
+	Offset int
+}
+
+// Normalize checks the query against the business rules and returns it with
+// its tags in canonical form. Repositories are given normalized queries.
+func (q ListTasksQuery) Normalize() (ListTasksQuery, error) {
+	if !q.CreatedFrom.IsZero() && !q.CreatedBefore.IsZero() && !q.CreatedFrom.Before(q.CreatedBefore) {
+		return q, ErrInvalidDateRange
+	}
+	if q.Limit < 0 || q.Limit > MaxPageSize || q.Offset < 0 {
+		return q, ErrInvalidPage
+	}
+	if len(q.Tags) > 0 {
+		tags, err := NormalizeTags(q.Tags)
+		if err != nil {
+			return q, err
+		}
+		q.Tags = tags
+	}
+	return q, nil
+}
+
+// Matches reports whether the task satisfies every condition of a normalized
+// query. Repositories that filter in their storage engine must agree with it.
+func (q ListTasksQuery) Matches(t *Task) bool {
+	if q.OwnerID != 0 && t.OwnerID != q.OwnerID {
+		return false
+	}
+	if q.AssigneeID != 0 && t.AssigneeID != q.AssigneeID {
+		return false
+	}
+	if len(q.ParentIDs) > 0 && !slices.Contains(q.ParentIDs, t.ParentID) {
+		return false
+	}
+	if q.Completed != nil && t.Completed != *q.Completed {
+		return false
+	}
+	if !q.CreatedFrom.IsZero() && t.CreatedAt.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !t.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if !t.HasTags(q.Tags) {
+		return false
+	}
+	if q.Search != "" {
+		search := foldASCII(q.Search)
+		return strings.Contains(foldASCII(t.Title), search) || strings.Contains(foldASCII(t.Description), search)
+	}
+	return true
+}
+
+// foldASCII lowercases ASCII letters only, as SQL LIKE does in SQLite
+func foldASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// The read side. TaskRepository is shaped for changing one task at a time;
+// questions about many tasks at once, such as how many are open, are
+// answered from a read model instead: projections kept up to date from the
+// TaskEvents the use cases publish. It lags the writes by however long an
+// event takes to reach it, so a task just created may not be counted yet.
+
+// TaskActivityKind is what a change did to a task, as the read model
+// tells it: an update that completes or reopens a task says so
+type TaskActivityKind string
+
+const (
+	ActivityCreated   TaskActivityKind = "created"
+	ActivityUpdated   TaskActivityKind = "updated"
+	ActivityCompleted TaskActivityKind = "completed"
+	ActivityReopened  TaskActivityKind = "reopened"
+	ActivityDeleted   TaskActivityKind = "deleted"
+	ActivityArchived  TaskActivityKind = "archived"
+)
+
+// TaskSummary counts tasks by status and by priority
+type TaskSummary struct {
+	Total      int
+	Open       int
+	Completed  int
+	ByPriority map[Priority]int // every priority, with 0 for none
+}
+
+// TaskActivity is one change, newest first in RecentActivity
+type TaskActivity struct {
+	Kind    TaskActivityKind
+	TaskID  int64
+	OwnerID int64
+	Title   string
+	Version int64
+	At      time.Time // when the read model learned of it
+}
+
+// TaskReadModel holds the projections. The query service feeds it every
+// TaskEvent, in any order and possibly more than once: a change older than
+// what it already has of a task is ignored, and a deleted or archived task
+// stays gone. Queries answer for the tenant of ctx (TenantOf), from the
+// tenants of the tasks applied.
+type TaskReadModel interface {
+	// Reset replaces the projections with ones of tasks, as they are stored
+	// now. Activity is kept.
+	Reset(ctx context.Context, tasks []*Task) error
+	Apply(ctx context.Context, event TaskEvent) error
+	// Summary counts ownerID's tasks, or every task for 0
+	Summary(ctx context.Context, ownerID int64) (TaskSummary, error)
+	// RecentActivity returns up to limit of the latest changes to ownerID's
+	// tasks, or to every task for 0, newest first
+	RecentActivity(ctx context.Context, ownerID int64, limit int) ([]TaskActivity, error)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "context"
+
+// Full-text search. ListTasksQuery.Search only tells whether a task
+// contains some text; a TaskSearchIndex ranks tasks by how well their words
+// match, and shows where. Like the read model it is a projection, kept up to
+// date from the TaskEvents, so it lags the writes by a moment.
+
+// MaxSearchQueryLength bounds the text of a search, in bytes
+const MaxSearchQueryLength = 200
+
+var (
+	ErrSearchQueryEmpty   = NewError("TASK_SEARCH_QUERY_EMPTY", KindInvalid, "search text cannot be empty")
+	ErrSearchQueryTooLong = NewError("TASK_SEARCH_QUERY_TOO_LONG", KindInvalid, "search text cannot exceed 200 bytes")
+)
+
+// TaskSearchQuery is a search: tasks whose title or description match the
+// words of Text, best first
+type TaskSearchQuery struct {
+	Text    string
+	OwnerID int64 // 0 for every owner
+	Limit   int
+	Offset  int
+}
+
+// TaskSearchHit is a task that matched, with the parts of its title and
+// description that did. Highlights map a field, "title" or "description",
+// to fragments of it with each matching word in <mark>, the rest
+// HTML-escaped.
+type TaskSearchHit struct {
+	TaskID     int64
+	OwnerID    int64
+	Title      string
+	Score      float64
+	Highlights map[string][]string
+}
+
+// TaskSearchResult is one page of hits, and how many tasks matched in all
+type TaskSearchResult struct {
+	Total int
+	Hits  []TaskSearchHit
+}
+
+// TaskSearchIndex indexes the title and description of tasks. It is fed
+// every TaskEvent like a TaskReadModel, in any order and possibly more than
+// once: a change older than what it has of a task is ignored, and a deleted
+// or archived task stays gone. Search answers for the tenant of ctx
+// (TenantOf).
+type TaskSearchIndex interface {
+	// Reset replaces everything indexed with tasks, as they are stored now
+	Reset(ctx context.Context, tasks []*Task) error
+	Apply(ctx context.Context, event TaskEvent) error
+	Search(ctx context.Context, query TaskSearchQuery) (TaskSearchResult, error)
+	// Count returns how many tasks are indexed, of every tenant
+	Count(ctx context.Context) (int, error)
+}
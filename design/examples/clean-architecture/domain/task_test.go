@@ -0,0 +1,46 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+func TestTaskAssign(t *testing.T) {
+	task, err := domain.NewTask(1, "Write the report", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.AssigneeID != 0 {
+		t.Errorf("a new task is assigned to %d, want nobody", task.AssigneeID)
+	}
+
+	// The steps run in order on the one task
+	steps := []struct {
+		name     string
+		run      func() error
+		want     error
+		assignee int64
+	}{
+		{"assigning an open task", func() error { return task.Assign(2, time.Now()) }, nil, 2},
+		{"assigning it again replaces the assignee", func() error { return task.Assign(3, time.Now()) }, nil, 3},
+		{"completing it keeps the assignee", func() error { task.MarkAsCompleted(time.Now()); return nil }, nil, 3},
+		{"assigning a completed task", func() error { return task.Assign(2, time.Now()) }, domain.ErrAssignCompleted, 3},
+		{"unassigning a completed task", func() error { task.Unassign(time.Now()); return nil }, nil, 0},
+		{"assigning it once reopened", func() error {
+			task.MarkAsIncomplete(time.Now())
+			return task.Assign(2, time.Now())
+		}, nil, 2},
+	}
+	for _, step := range steps {
+		if err := step.run(); !errors.Is(err, step.want) || task.AssigneeID != step.assignee {
+			t.Errorf("%s = %v, assigned to %d; want %v, assigned to %d", step.name, err, task.AssigneeID, step.want, step.assignee)
+		}
+	}
+
+	if !(domain.ListTasksQuery{AssigneeID: 2}).Matches(task) || (domain.ListTasksQuery{AssigneeID: 3}).Matches(task) {
+		t.Error("a query for an assignee should match their tasks only")
+	}
+}
@@ -0,0 +1,64 @@
+package domain
+
+import "context"
+
+// TenantID names a tenant: an organization whose users and tasks no other
+// tenant can see. It is a DNS label, so a tenant can be a subdomain.
+type TenantID string
+
+const (
+	// DefaultTenant is the tenant of requests that name none, and of the
+	// users and tasks stored before there were tenants
+	DefaultTenant TenantID = "default"
+	// AllTenants is the tenant of work the server does for every tenant at
+	// once, such as archiving: repositories do not scope it. It is not a
+	// valid TenantID, so no request can ask for it.
+	AllTenants TenantID = "*"
+)
+
+// MaxTenantLength is the longest DNS label
+const MaxTenantLength = 63
+
+var ErrInvalidTenant = NewError("TENANT_INVALID", KindInvalid, "tenant must be 1 to 63 lowercase letters, digits and hyphens, not starting or ending with a hyphen")
+
+// ParseTenantID checks that s is a lowercase DNS label
+func ParseTenantID(s string) (TenantID, error) {
+	if len(s) == 0 || len(s) > MaxTenantLength || s[0] == '-' || s[len(s)-1] == '-' {
+		return "", ErrInvalidTenant
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return "", ErrInvalidTenant
+		}
+	}
+	return TenantID(s), nil
+}
+
+// OrDefault returns t, or DefaultTenant for the zero TenantID, which users
+// and tasks built without a tenant have
+func (t TenantID) OrDefault() TenantID {
+	if t == "" {
+		return DefaultTenant
+	}
+	return t
+}
+
+// Includes reports whether a scope of t covers data of other: its own, or
+// anyone's for AllTenants
+func (t TenantID) Includes(other TenantID) bool {
+	return t == AllTenants || t.OrDefault() == other.OrDefault()
+}
+
+type tenantKey struct{}
+
+// WithTenant returns ctx acting for tenant. The repositories only read and
+// change that tenant's data through it, and create data in it.
+func WithTenant(ctx context.Context, tenant TenantID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantOf returns the tenant ctx acts for, DefaultTenant if none was set
+func TenantOf(ctx context.Context) TenantID {
+	tenant, _ := ctx.Value(tenantKey{}).(TenantID)
+	return tenant.OrDefault()
+}
@@ -0,0 +1,62 @@
+package domain_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+func TestParseTenantID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"acme", nil},
+		{"a", nil},
+		{"globex-2", nil},
+		{strings.Repeat("x", domain.MaxTenantLength), nil},
+		{"", domain.ErrInvalidTenant},
+		{"Acme", domain.ErrInvalidTenant},
+		{"-acme", domain.ErrInvalidTenant},
+		{"acme-", domain.ErrInvalidTenant},
+		{"acme.corp", domain.ErrInvalidTenant},
+		{"*", domain.ErrInvalidTenant},
+		{"ac me", domain.ErrInvalidTenant},
+		{strings.Repeat("x", domain.MaxTenantLength+1), domain.ErrInvalidTenant},
+	}
+	for _, tt := range tests {
+		got, err := domain.ParseTenantID(tt.in)
+		if !errors.Is(err, tt.want) || (err == nil && string(got) != tt.in) {
+			t.Errorf("ParseTenantID(%.20q) = %q, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestTenantScope(t *testing.T) {
+	if got := domain.TenantOf(context.Background()); got != domain.DefaultTenant {
+		t.Errorf("the tenant of a context without one = %q, want %q", got, domain.DefaultTenant)
+	}
+	if got := domain.TenantOf(domain.WithTenant(context.Background(), "acme")); got != "acme" {
+		t.Errorf("the tenant of a context with acme = %q", got)
+	}
+
+	tests := []struct {
+		scope, other domain.TenantID
+		want         bool
+	}{
+		{"acme", "acme", true},
+		{"acme", "globex", false},
+		{domain.AllTenants, "acme", true},
+		{"", domain.DefaultTenant, true},
+		{domain.DefaultTenant, "", true},
+		{"", "acme", false},
+	}
+	for _, tt := range tests {
+		if got := tt.scope.Includes(tt.other); got != tt.want {
+			t.Errorf("%q.Includes(%q) = %v, want %v", tt.scope, tt.other, got, tt.want)
+		}
+	}
+}
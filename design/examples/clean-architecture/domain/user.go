@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// User is an account that owns tasks. The domain only ever sees the hash of
+// a password; how it is hashed is up to the outer layers.
+type User struct {
+	ID           int64
+	Email        string // normalized, see NormalizeEmail
+	PasswordHash string
+	Role         Role
+	TenantID     TenantID // emails are unique within a tenant
+	CreatedAt    time.Time
+}
+
+// Role says what a user may do beyond working with their own tasks. New
+// accounts are RoleUser.
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole accepts a role in any letter case
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if r != RoleUser && r != RoleAdmin {
+		return "", ErrInvalidRole
+	}
+	return r, nil
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+const (
+	MinPasswordLength = 8  // characters
+	MaxPasswordLength = 72 // bytes, as bcrypt ignores the rest
+)
+
+var (
+	ErrInvalidEmail     = NewError("USER_EMAIL_INVALID", KindInvalid, "email address is invalid")
+	ErrPasswordTooShort = NewError("USER_PASSWORD_TOO_SHORT", KindInvalid, "password must be at least 8 characters")
+	ErrPasswordTooLong  = NewError("USER_PASSWORD_TOO_LONG", KindInvalid, "password cannot exceed 72 bytes")
+	ErrEmailTaken       = NewError("USER_EMAIL_TAKEN", KindConflict, "an account with this email already exists")
+	ErrUserNotFound     = NewError("USER_NOT_FOUND", KindNotFound, "user not found")
+	ErrInvalidRole      = NewError("USER_ROLE_INVALID", KindInvalid, "role must be user or admin")
+)
+
+// NormalizeEmail returns a bare address, trimmed and lowercased, so an
+// account is found whatever case it is typed in
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// ValidatePassword checks a password before it is hashed
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > MaxPasswordLength {
+		return ErrPasswordTooLong
+	}
+	return nil
+}
+
+// NewUser creates a user from a normalized email and a password hash
+func NewUser(email, passwordHash string) *User {
+	return &User{
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         RoleUser,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// UserRepository defines the interface for user persistence. Like
+// TaskRepository, it gives up with ctx.Err() once ctx is done, and acts
+// within the tenant of ctx.
+type UserRepository interface {
+	// Create stores the user and sets its ID. It returns ErrEmailTaken if
+	// the email is already registered.
+	Create(ctx context.Context, user *User) error
+	// GetByID and GetByEmail return ErrUserNotFound if there is no such user
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	// GetByIDs returns the users among ids that exist, in ID order, with one
+	// query however many IDs there are
+	GetByIDs(ctx context.Context, ids []int64) ([]*User, error)
+	// List returns every user in ID order
+	List(ctx context.Context) ([]*User, error)
+	// SetRole changes a user's role, or returns ErrUserNotFound
+	SetRole(ctx context.Context, id int64, role Role) error
+}
@@ -0,0 +1,241 @@
+// Package fixtures generates demo data: accounts, and tasks of theirs spread
+// over the past weeks, some completed, some due, some assigned to another
+// account. Everything is made through the use cases, as requests would make
+// it, on a clock stepped to the time each change is to have happened, so
+// the data passes every rule the server applies and its timestamps look
+// lived in. The same Seed makes the same data.
+//
+// cmd/seed fills a database with it, for demos and load tests.
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// Options says what to generate. Completed, Due and Assigned are shares of
+// the tasks, from 0 to 1, taken as they are: 0 means none.
+type Options struct {
+	Tenant   domain.TenantID // default domain.DefaultTenant
+	Users    int             // accounts the tasks belong to, default 3
+	Tasks    int             // tasks in all, spread evenly over the accounts
+	Span     time.Duration   // how far back the tasks were created, default 30 days
+	Now      time.Time       // when the span ends, default the time Generate runs
+	Seed     uint64          // picks the data; the same seed makes the same
+	Password string          // of every account, default "demo-password"
+	// Hasher hashes the passwords, default infrastructure.BcryptHasher{}
+	Hasher usecase.PasswordHasher
+
+	Completed float64 // completed some time after they were created
+	Due       float64 // with a due date, some of them already past
+	Assigned  float64 // assigned by their owner to another account
+}
+
+// Result is what Generate made
+type Result struct {
+	Users     []*domain.User
+	Tasks     []*domain.Task // in the order they were created, oldest first
+	Completed int
+	Due       int
+	Assigned  int
+}
+
+const DefaultPassword = "demo-password"
+
+// names are the accounts' emails, before name@example.com, then userN@
+var names = []string{"ann", "bob", "cal", "dee", "eve", "fay", "gus", "hal", "ivy", "jon"}
+
+// samples are the tasks to pick from
+var samples = []struct {
+	title, description string
+	tags               []string
+}{
+	{"Write the quarterly report", "Revenue, churn and the hiring plan", []string{"work", "reports"}},
+	{"Review the pull request for search", "", []string{"work", "code-review"}},
+	{"Prepare the slides for Monday's all-hands", "Ten slides at most", []string{"work"}},
+	{"Renew the passport", "The appointment form is on the embassy site", []string{"personal", "admin"}},
+	{"Book flights to the conference", "Arrive the day before", []string{"travel"}},
+	{"Pay the electricity bill", "", []string{"personal", "bills"}},
+	{"Fix the flaky login test", "Fails about once in twenty runs on CI", []string{"work", "bug"}},
+	{"Call the bank about the card", "", []string{"personal"}},
+	{"Plan the team offsite", "Venue, agenda and a budget for 12 people", []string{"work", "team"}},
+	{"Update the onboarding guide", "The VPN section is out of date", []string{"work", "docs"}},
+	{"Buy a birthday present for Mia", "", []string{"personal", "shopping"}},
+	{"Back up the photo library", "", []string{"personal"}},
+	{"Draft the release notes for 2.4", "", []string{"work", "docs"}},
+	{"Schedule the dentist appointment", "", []string{"personal", "health"}},
+	{"Migrate the staging database", "Take a snapshot first", []string{"work", "ops"}},
+	{"Reply to the landlord", "About the broken heater", []string{"personal"}},
+	{"Interview the backend candidate", "Thursday at 14:00", []string{"work", "hiring"}},
+	{"Clean out the garage", "", []string{"home"}},
+	{"Rotate the API keys", "", []string{"work", "security"}},
+	{"Send the invoice for March", "", []string{"work", "bills"}},
+	{"Read the design doc on caching", "", []string{"work"}},
+	{"Water the plants", "", []string{"home"}},
+	{"Order new running shoes", "", []string{"personal", "shopping"}},
+	{"Set up alerts for the job queue", "Dead letters and retries", []string{"work", "ops"}},
+}
+
+// clock is the time changes are stamped with, stepped by Generate
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+// Generate makes the accounts and their tasks in users and tasks, in the
+// tenant of opts. An account whose email is taken is used as it is, so
+// generating again adds tasks to the same accounts.
+func Generate(ctx context.Context, users domain.UserRepository, tasks domain.TaskRepository, opts Options) (Result, error) {
+	if err := opts.validate(); err != nil {
+		return Result{}, err
+	}
+	if opts.Tenant == "" {
+		opts.Tenant = domain.DefaultTenant
+	}
+	if opts.Users == 0 {
+		opts.Users = 3
+	}
+	if opts.Span == 0 {
+		opts.Span = 30 * 24 * time.Hour
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if opts.Password == "" {
+		opts.Password = DefaultPassword
+	}
+	if opts.Hasher == nil {
+		opts.Hasher = infrastructure.BcryptHasher{}
+	}
+	ctx = domain.WithTenant(ctx, opts.Tenant)
+	random := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	at := &clock{}
+	taskUseCase := usecase.NewTaskUseCaseWithClock(tasks, nil, at)
+	assignments := usecase.NewAssignmentUseCase(taskUseCase, users)
+
+	var result Result
+	auth := usecase.NewAuthUseCase(users, opts.Hasher, nil)
+	for i := range opts.Users {
+		user, err := account(ctx, auth, users, email(i), opts.Password)
+		if err != nil {
+			return result, err
+		}
+		result.Users = append(result.Users, user)
+	}
+
+	// Each share is exact: that many tasks, picked at random
+	completed := pick(random, opts.Tasks, opts.Completed)
+	due := pick(random, opts.Tasks, opts.Due)
+	assigned := pick(random, opts.Tasks, opts.Assigned)
+	created := make([]time.Time, opts.Tasks)
+	for i := range created {
+		created[i] = opts.Now.Add(-time.Duration(random.Int64N(int64(opts.Span)))).Truncate(time.Minute)
+	}
+	slices.SortFunc(created, func(a, b time.Time) int { return a.Compare(b) })
+
+	for i, createdAt := range created {
+		owner := result.Users[i%len(result.Users)]
+		sample := samples[random.IntN(len(samples))]
+		input := usecase.CreateTaskInput{
+			Title:       sample.title,
+			Description: sample.description,
+			Priority:    string(priority(random)),
+			Tags:        sample.tags,
+		}
+		if due[i] {
+			// Due 1 to 14 days after it was created, at the top of an hour
+			input.DueAt = createdAt.Add(time.Duration(24+random.IntN(13*24)) * time.Hour).Truncate(time.Hour)
+			result.Due++
+		}
+		at.now = createdAt
+		task, err := taskUseCase.CreateTask(ctx, owner, input)
+		if err != nil {
+			return result, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		if assigned[i] && len(result.Users) > 1 {
+			assignee := result.Users[(i+1+random.IntN(len(result.Users)-1))%len(result.Users)]
+			at.now = later(random, createdAt, opts.Now, time.Hour)
+			if task, err = assignments.AssignTask(ctx, owner, task.ID, assignee.ID, 0); err != nil {
+				return result, fmt.Errorf("task %d: %w", i+1, err)
+			}
+			result.Assigned++
+		}
+		if completed[i] {
+			at.now = later(random, at.now, opts.Now, 7*24*time.Hour)
+			if task, err = taskUseCase.CompleteTask(ctx, owner, task.ID); err != nil {
+				return result, fmt.Errorf("task %d: %w", i+1, err)
+			}
+			result.Completed++
+		}
+		result.Tasks = append(result.Tasks, task)
+	}
+	return result, nil
+}
+
+func (opts Options) validate() error {
+	if opts.Users < 0 || opts.Tasks < 0 || opts.Span < 0 {
+		return errors.New("fixtures: users, tasks and span cannot be negative")
+	}
+	for _, share := range []float64{opts.Completed, opts.Due, opts.Assigned} {
+		if share < 0 || share > 1 {
+			return fmt.Errorf("fixtures: a share of the tasks must be from 0 to 1, not %v", share)
+		}
+	}
+	return nil
+}
+
+func email(i int) string {
+	if i < len(names) {
+		return names[i] + "@example.com"
+	}
+	return fmt.Sprintf("user%d@example.com", i+1)
+}
+
+// account registers email, or gets the account already registered with it
+func account(ctx context.Context, auth *usecase.AuthUseCase, users domain.UserRepository, email, password string) (*domain.User, error) {
+	user, err := auth.Register(ctx, email, password)
+	if errors.Is(err, domain.ErrEmailTaken) {
+		return users.GetByEmail(ctx, email)
+	}
+	return user, err
+}
+
+// pick returns which of n items are among the share of them, at random
+func pick(random *rand.Rand, n int, share float64) []bool {
+	picked := make([]bool, n)
+	for _, i := range random.Perm(n)[:int(share*float64(n)+0.5)] {
+		picked[i] = true
+	}
+	return picked
+}
+
+// priority is mostly medium, sometimes high or low
+func priority(random *rand.Rand) domain.Priority {
+	switch n := random.IntN(10); {
+	case n < 2:
+		return domain.PriorityHigh
+	case n < 5:
+		return domain.PriorityLow
+	default:
+		return domain.PriorityMedium
+	}
+}
+
+// later returns a time whole minutes after t, up to max after it, but not
+// after now
+func later(random *rand.Rand, t, now time.Time, max time.Duration) time.Time {
+	minutes := int64(min(max, now.Sub(t)) / time.Minute)
+	if minutes < 1 {
+		return t
+	}
+	return t.Add(time.Duration(1+random.Int64N(minutes)) * time.Minute)
+}
@@ -0,0 +1,221 @@
+package fixtures_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/fixtures"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var now = time.Date(2030, 7, 1, 9, 0, 0, 0, time.UTC)
+
+// options are those of every test, with a cheap hash
+func options(seed uint64) fixtures.Options {
+	return fixtures.Options{
+		Users:     3,
+		Tasks:     40,
+		Span:      30 * 24 * time.Hour,
+		Now:       now,
+		Seed:      seed,
+		Hasher:    infrastructure.BcryptHasher{Cost: bcrypt.MinCost},
+		Completed: 0.25,
+		Due:       0.5,
+		Assigned:  0.2,
+	}
+}
+
+// generate generates into empty stores in memory
+func generate(t *testing.T, seed uint64) fixtures.Result {
+	t.Helper()
+	result, err := fixtures.Generate(context.Background(), repository.NewMemoryUserRepository(), repository.NewMemoryTaskRepository(), options(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// fingerprint is what the same seed must make the same
+func fingerprint(result fixtures.Result) string {
+	var b strings.Builder
+	for _, task := range result.Tasks {
+		fmt.Fprintf(&b, "%d %s %s %v %v %s %s %d\n", task.OwnerID, task.Title, task.Priority, task.Tags,
+			task.Completed, task.CreatedAt, task.DueAt, task.AssigneeID)
+	}
+	return b.String()
+}
+
+func TestShares(t *testing.T) {
+	result := generate(t, 1)
+	if len(result.Users) != 3 || result.Users[0].Email != "ann@example.com" || result.Users[2].Email != "cal@example.com" {
+		t.Fatalf("%d accounts, want 3: ann, bob and cal @example.com", len(result.Users))
+	}
+	if len(result.Tasks) != 40 {
+		t.Fatalf("%d tasks, want 40", len(result.Tasks))
+	}
+	count := func(ok func(*domain.Task) bool) int {
+		n := 0
+		for _, task := range result.Tasks {
+			if ok(task) {
+				n++
+			}
+		}
+		return n
+	}
+	shares := []struct {
+		name     string
+		reported int
+		counted  int
+		want     int
+	}{
+		{"completed, a quarter", result.Completed, count(func(t *domain.Task) bool { return t.Completed }), 10},
+		{"with a due date, half", result.Due, count(func(t *domain.Task) bool { return !t.DueAt.IsZero() }), 20},
+		{"assigned, a fifth", result.Assigned, count(func(t *domain.Task) bool { return t.AssigneeID != 0 }), 8},
+	}
+	for _, tt := range shares {
+		if tt.reported != tt.want || tt.counted != tt.want {
+			t.Errorf("%s: %d reported, %d generated, want %d", tt.name, tt.reported, tt.counted, tt.want)
+		}
+	}
+
+	perOwner := map[int64]int{}
+	priorities := map[domain.Priority]bool{}
+	for _, task := range result.Tasks {
+		perOwner[task.OwnerID]++
+		priorities[task.Priority] = true
+	}
+	if got := []int{perOwner[result.Users[0].ID], perOwner[result.Users[1].ID], perOwner[result.Users[2].ID]}; !slices.Equal(got, []int{14, 13, 13}) {
+		t.Errorf("tasks per account = %v, want them spread evenly", got)
+	}
+	if len(priorities) != 3 {
+		t.Errorf("priorities = %v, want every one", priorities)
+	}
+
+	for i, task := range result.Tasks {
+		if i > 0 && task.CreatedAt.Before(result.Tasks[i-1].CreatedAt) {
+			t.Errorf("task %d was created before the one before it, want oldest first", i)
+		}
+		if task.CreatedAt.Before(now.Add(-30*24*time.Hour)) || task.CreatedAt.After(now) {
+			t.Errorf("task %d was created at %v, want within the 30 days before now", i, task.CreatedAt)
+		}
+		if task.UpdatedAt.Before(task.CreatedAt) || task.UpdatedAt.After(now) ||
+			(!task.Completed && task.AssigneeID == 0 && !task.UpdatedAt.Equal(task.CreatedAt)) {
+			t.Errorf("task %d was updated at %v, want after it was created, not after now, and only if changed", i, task.UpdatedAt)
+		}
+		if !task.DueAt.IsZero() && task.DueAt.Before(task.CreatedAt) {
+			t.Errorf("task %d is due at %v, before it was created", i, task.DueAt)
+		}
+		if task.AssigneeID == task.OwnerID {
+			t.Errorf("task %d is assigned to its owner", i)
+		}
+	}
+	if !slices.ContainsFunc(result.Tasks, func(t *domain.Task) bool { return t.Overdue(now) }) {
+		t.Error("no task is overdue by now, want some")
+	}
+}
+
+func TestSeeds(t *testing.T) {
+	if fingerprint(generate(t, 7)) != fingerprint(generate(t, 7)) {
+		t.Error("the same seed made other data")
+	}
+	if fingerprint(generate(t, 7)) == fingerprint(generate(t, 8)) {
+		t.Error("another seed made the same data")
+	}
+
+	ctx := context.Background()
+	users, tasks := repository.NewMemoryUserRepository(), repository.NewMemoryTaskRepository()
+	first, err := fixtures.Generate(ctx, users, tasks, options(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := fixtures.Generate(ctx, users, tasks, options(2))
+	if err != nil || again.Users[0].ID != first.Users[0].ID || len(again.Tasks) != 40 {
+		t.Errorf("generating again = %v, want 40 more tasks for the same accounts", err)
+	}
+
+	invalid := []struct {
+		name string
+		opts fixtures.Options
+	}{
+		{"a share over 1", fixtures.Options{Completed: 1.5}},
+		{"a negative share", fixtures.Options{Due: -0.1}},
+		{"a negative task count", fixtures.Options{Tasks: -1}},
+	}
+	for _, tt := range invalid {
+		if _, err := fixtures.Generate(ctx, users, tasks, tt.opts); err == nil {
+			t.Errorf("Generate with %s = nil, want an error", tt.name)
+		}
+	}
+
+	opts := options(1)
+	opts.Tenant = "acme"
+	acme, err := fixtures.Generate(ctx, users, tasks, opts)
+	if err != nil || acme.Users[0].TenantID != "acme" || acme.Tasks[0].TenantID != "acme" || acme.Users[0].ID == first.Users[0].ID {
+		t.Errorf("Generate in tenant acme = %v, want the same emails as other accounts, in acme", err)
+	}
+}
+
+// TestSQLite seeds a SQLite database, then resets it
+func TestSQLite(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultTenant)
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "seed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	users, tasks := repository.NewUserRepository(db), repository.NewTaskRepository(db)
+	outbox := repository.NewOutboxRepository(db)
+
+	result, err := fixtures.Generate(ctx, users, tasks, options(1))
+	if err != nil || len(result.Tasks) != 40 {
+		t.Fatalf("Generate = %v, want 40 tasks stored", err)
+	}
+	stored, err := tasks.List(ctx, domain.ListTasksQuery{})
+	if err != nil || len(stored) != 40 || !stored[0].CreatedAt.Equal(result.Tasks[39].CreatedAt) {
+		t.Errorf("List = %d tasks, %v, want 40, newest first", len(stored), err)
+	}
+	if events, err := outbox.Due(ctx, now.Add(time.Hour), 1000); err != nil || len(events) != 50 {
+		t.Errorf("the outbox holds %d events, %v, want 50: 40 created, 10 completed", len(events), err)
+	}
+
+	tokens, err := infrastructure.NewJWTTokens([]byte(strings.Repeat("seed-secret-", 3)), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := usecase.NewAuthUseCase(users, infrastructure.BcryptHasher{}, tokens)
+	if _, err := auth.Login(ctx, "bob@example.com", fixtures.DefaultPassword); err != nil {
+		t.Errorf("bob@example.com logging in with %s = %v", fixtures.DefaultPassword, err)
+	}
+
+	if err := infrastructure.ResetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = tasks.List(ctx, domain.ListTasksQuery{})
+	accounts, _ := users.List(ctx)
+	events, _ := outbox.Due(ctx, now.Add(time.Hour), 1000)
+	if len(stored) != 0 || len(accounts) != 0 || len(events) != 0 {
+		t.Errorf("after a reset, %d tasks, %d accounts and %d events are left, want none", len(stored), len(accounts), len(events))
+	}
+	if _, err := auth.Login(ctx, "bob@example.com", fixtures.DefaultPassword); !errors.Is(err, usecase.ErrInvalidCredentials) {
+		t.Errorf("after a reset, bob logging in = %v, want %v", err, usecase.ErrInvalidCredentials)
+	}
+	if applied, err := infrastructure.AppliedMigrations(db); err != nil || !applied[infrastructure.SchemaDueDates] {
+		t.Errorf("after a reset, the migrations applied = %v, %v, want them kept", applied, err)
+	}
+	if _, err := fixtures.Generate(ctx, users, tasks, options(1)); err != nil {
+		t.Errorf("seeding again after a reset = %v", err)
+	}
+	if stored, _ = tasks.List(ctx, domain.ListTasksQuery{}); len(stored) != 40 {
+		t.Errorf("seeded again, List = %d tasks, want 40", len(stored))
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// AssignmentHandler serves a task's assignee at /tasks/:id/assignee, and the
+// caller's assigned tasks at /tasks/assigned
+type AssignmentHandler struct {
+	assignments *usecase.AssignmentUseCase
+}
+
+func NewAssignmentHandler(assignments *usecase.AssignmentUseCase) *AssignmentHandler {
+	return &AssignmentHandler{assignments: assignments}
+}
+
+// AssignTaskRequest names the user to assign the task to. Version works as
+// in UpdateTaskRequest.
+type AssignTaskRequest struct {
+	AssigneeID int64 `json:"assignee_id"`
+	Version    int64 `json:"version"`
+}
+
+// AssignTask handles PUT /tasks/:id/assignee
+func (h *AssignmentHandler) AssignTask(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrInvalidTaskID
+	}
+
+	var req AssignTaskRequest
+	if err := c.Bind(&req); err != nil || req.AssigneeID <= 0 {
+		return ErrInvalidBody
+	}
+
+	task, err := h.assignments.AssignTask(c.Request().Context(), actor(c), id, req.AssigneeID, req.Version)
+	if err != nil {
+		return err
+	}
+	setETag(c, task)
+	return c.JSON(http.StatusOK, presenter(c).Task(c, task))
+}
+
+// UnassignTask handles DELETE /tasks/:id/assignee. Without a body, the
+// version the client last read is the optional version query parameter.
+func (h *AssignmentHandler) UnassignTask(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrInvalidTaskID
+	}
+	var version int64
+	if v := c.QueryParam("version"); v != "" {
+		if version, err = strconv.ParseInt(v, 10, 64); err != nil || version <= 0 {
+			return ErrInvalidQuery
+		}
+	}
+
+	task, err := h.assignments.UnassignTask(c.Request().Context(), actor(c), id, version)
+	if err != nil {
+		return err
+	}
+	setETag(c, task)
+	return c.JSON(http.StatusOK, presenter(c).Task(c, task))
+}
+
+// ListAssignedTasks handles GET /tasks/assigned, with the filters of
+// GET /tasks but owner, whoever owns the tasks
+func (h *AssignmentHandler) ListAssignedTasks(c echo.Context) error {
+	query, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := h.assignments.ListAssignedTasks(c.Request().Context(), actor(c), query)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, presenter(c).Tasks(c, tasks))
+}
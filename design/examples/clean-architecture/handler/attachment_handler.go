@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// AttachmentHandler serves the files of a task at
+// /tasks/:id/attachments/:name. An upload is the raw content, with its
+// Content-Type; a download streams it back, with the same type.
+type AttachmentHandler struct {
+	attachments *usecase.AttachmentUseCase
+}
+
+func NewAttachmentHandler(attachments *usecase.AttachmentUseCase) *AttachmentHandler {
+	return &AttachmentHandler{attachments: attachments}
+}
+
+type AttachmentResponse struct {
+	TaskID      int64  `json:"task_id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func toAttachmentResponse(a domain.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		TaskID:      a.TaskID,
+		Name:        a.Name,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		CreatedAt:   a.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// attachmentParams reads the task ID and the attachment name of the path.
+// Echo leaves parameters escaped when the path has escapes it must keep,
+// such as %2F, so the name is unescaped then: a slash in it is rejected
+// rather than taken as a path separator.
+func attachmentParams(c echo.Context) (int64, string, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidTaskID
+	}
+	name := c.Param("name")
+	if c.Request().URL.RawPath != "" {
+		if name, err = url.PathUnescape(name); err != nil {
+			return 0, "", domain.ErrInvalidAttachmentName
+		}
+	}
+	return id, name, nil
+}
+
+// ListAttachments handles GET /tasks/:id/attachments
+func (h *AttachmentHandler) ListAttachments(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrInvalidTaskID
+	}
+
+	attachments, err := h.attachments.ListAttachments(c.Request().Context(), actor(c), id)
+	if err != nil {
+		return err
+	}
+
+	responses := make([]AttachmentResponse, len(attachments))
+	for i, a := range attachments {
+		responses[i] = toAttachmentResponse(a)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+// UploadAttachment handles PUT /tasks/:id/attachments/:name, streaming the
+// request body to the storage
+func (h *AttachmentHandler) UploadAttachment(c echo.Context) error {
+	id, name, err := attachmentParams(c)
+	if err != nil {
+		return err
+	}
+
+	req := c.Request()
+	attachment, err := h.attachments.UploadAttachment(req.Context(), actor(c), usecase.UploadAttachmentInput{
+		TaskID:      id,
+		Name:        name,
+		ContentType: req.Header.Get(echo.HeaderContentType),
+		Size:        req.ContentLength,
+		Content:     req.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toAttachmentResponse(attachment))
+}
+
+// DownloadAttachment handles GET /tasks/:id/attachments/:name, streaming
+// the content from the storage. It is sent as a download, and browsers are
+// told not to guess another type for it.
+func (h *AttachmentHandler) DownloadAttachment(c echo.Context) error {
+	id, name, err := attachmentParams(c)
+	if err != nil {
+		return err
+	}
+
+	attachment, content, err := h.attachments.OpenAttachment(c.Request().Context(), actor(c), id, name)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachment.Size, 10))
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	return c.Stream(http.StatusOK, attachment.ContentType, content)
+}
+
+// DeleteAttachment handles DELETE /tasks/:id/attachments/:name
+func (h *AttachmentHandler) DeleteAttachment(c echo.Context) error {
+	id, name, err := attachmentParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.attachments.DeleteAttachment(c.Request().Context(), actor(c), id, name); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
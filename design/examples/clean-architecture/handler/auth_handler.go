@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// Clients register, log in for a token, and send it on every task request
+// as "Authorization: Bearer <token>". RequireUser checks the token and puts
+// the user in the echo context, where the task handlers read it.
+
+var ErrMissingToken = domain.NewError("AUTH_TOKEN_MISSING", domain.KindUnauthenticated, "authorization bearer token required")
+
+type AuthHandler struct {
+	authUseCase *usecase.AuthUseCase
+}
+
+func NewAuthHandler(authUseCase *usecase.AuthUseCase) *AuthHandler {
+	return &AuthHandler{
+		authUseCase: authUseCase,
+	}
+}
+
+type CredentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type UserResponse struct {
+	ID        int64  `json:"id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Tenant    string `json:"tenant"`
+	CreatedAt string `json:"created_at"`
+}
+
+// TokenResponse follows the OAuth 2.0 token response field names
+type TokenResponse struct {
+	AccessToken string       `json:"access_token"`
+	TokenType   string       `json:"token_type"`
+	ExpiresIn   int64        `json:"expires_in"` // seconds
+	User        UserResponse `json:"user"`
+}
+
+func toUserResponse(user *domain.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Role:      string(user.Role),
+		Tenant:    string(user.TenantID.OrDefault()),
+		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// Register handles POST /auth/register
+func (h *AuthHandler) Register(c echo.Context) error {
+	var req CredentialsRequest
+	if err := c.Bind(&req); err != nil {
+		return ErrInvalidBody
+	}
+
+	user, err := h.authUseCase.Register(c.Request().Context(), req.Email, req.Password)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, toUserResponse(user))
+}
+
+// Login handles POST /auth/login
+func (h *AuthHandler) Login(c echo.Context) error {
+	var req CredentialsRequest
+	if err := c.Bind(&req); err != nil {
+		return ErrInvalidBody
+	}
+
+	session, err := h.authUseCase.Login(c.Request().Context(), req.Email, req.Password)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: session.Token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(session.ExpiresAt).Seconds()),
+		User:        toUserResponse(session.User),
+	})
+}
+
+const userKey = "user"
+
+// RequireUser answers 401 unless the request carries a valid bearer token,
+// and otherwise stores its user for CurrentUser
+func RequireUser(authUseCase *usecase.AuthUseCase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := bearerToken(c.Request().Header.Get(echo.HeaderAuthorization))
+			if !ok {
+				return ErrMissingToken
+			}
+			user, err := authUseCase.Authenticate(c.Request().Context(), token)
+			if err != nil {
+				return err
+			}
+			SetUser(c, user)
+			return next(c)
+		}
+	}
+}
+
+// RequireRole answers 403 unless the user RequireUser stored has one of
+// roles. It only turns callers away early: the use cases apply the full
+// policy whatever the route.
+func RequireRole(roles ...domain.Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := CurrentUser(c)
+			if user == nil {
+				return ErrMissingToken
+			}
+			for _, role := range roles {
+				if user.Role == role {
+					return next(c)
+				}
+			}
+			return usecase.ErrForbidden
+		}
+	}
+}
+
+// bearerToken extracts the token from an Authorization header. The scheme
+// is case-insensitive (RFC 7235).
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	token = strings.TrimSpace(token)
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// SetUser records the authenticated user of a request. RequireUser calls it;
+// so can other authentication middleware.
+func SetUser(c echo.Context, user *domain.User) {
+	c.Set(userKey, user)
+}
+
+// AsUser authenticates every request as user, without a token. It is for
+// checks and benchmarks that exercise the task routes on their own; a server
+// uses RequireUser.
+func AsUser(user *domain.User) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			SetUser(c, user)
+			return next(c)
+		}
+	}
+}
+
+// CurrentUser returns the authenticated user of a request, or nil
+func CurrentUser(c echo.Context) *domain.User {
+	user, _ := c.Get(userKey).(*domain.User)
+	return user
+}
+
+// actor is the user the task and user handlers act for. Those routes must
+// sit behind RequireUser: one that does not fails every request rather than
+// acting for nobody.
+func actor(c echo.Context) *domain.User {
+	user := CurrentUser(c)
+	if user == nil {
+		panic("handler: route registered without RequireUser")
+	}
+	return user
+}
@@ -0,0 +1,124 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/client"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newAuth builds the auth use case over users, hashing at the lowest bcrypt
+// cost so the tests run quickly
+func newAuth(t *testing.T, users domain.UserRepository) *usecase.AuthUseCase {
+	t.Helper()
+	tokens, err := infrastructure.NewJWTTokens([]byte("auth-test-secret-at-least-32-bytes!"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return usecase.NewAuthUseCase(users, infrastructure.BcryptHasher{Cost: bcrypt.MinCost}, tokens)
+}
+
+// authServer serves the account and task routes at the paths of v1, which
+// the client speaks
+func authServer(t *testing.T) *httptest.Server {
+	auth := newAuth(t, repository.NewMemoryUserRepository())
+	authHandler := handler.NewAuthHandler(auth)
+	taskHandler := handler.NewTaskHandler(usecase.NewTaskUseCase(repository.NewMemoryTaskRepository()))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.POST("/api/v1/auth/register", authHandler.Register)
+	e.POST("/api/v1/auth/login", authHandler.Login)
+	tasks := e.Group("/api/v1/tasks", handler.RequireUser(auth))
+	tasks.POST("", taskHandler.CreateTask)
+	tasks.GET("", taskHandler.GetAllTasks)
+	tasks.GET("/:id", taskHandler.GetTask)
+	tasks.DELETE("/:id", taskHandler.DeleteTask)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthEndpoints(t *testing.T) {
+	ctx := context.Background()
+	server := authServer(t)
+	api := client.New(server.URL, nil)
+	if _, err := api.Register(ctx, "carol@example.com", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	var apiErr *client.APIError
+	_, err := api.Register(ctx, "carol@example.com", "correct horse")
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict || !errors.Is(err, domain.ErrEmailTaken) {
+		t.Errorf("registering again = %v, want 409 %s", err, domain.ErrEmailTaken.Code)
+	}
+	_, err = api.Login(ctx, "carol@example.com", "wrong horse")
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Code != usecase.ErrInvalidCredentials.Code {
+		t.Errorf("a wrong password = %v, want 401 %s", err, usecase.ErrInvalidCredentials.Code)
+	}
+	_, err = api.ListTasks(ctx)
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Code != handler.ErrMissingToken.Code {
+		t.Errorf("a task route without a token = %v, want 401 %s", err, handler.ErrMissingToken.Code)
+	}
+	_, err = api.WithToken("not.a.token").ListTasks(ctx)
+	if !errors.As(err, &apiErr) || apiErr.Code != usecase.ErrInvalidToken.Code {
+		t.Errorf("a bad token = %v, want %s", err, usecase.ErrInvalidToken.Code)
+	}
+
+	// Basic credentials are not a token, and the 401 says to use Bearer
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/tasks", nil)
+	req.SetBasicAuth("carol@example.com", "correct horse")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Bearer") {
+		t.Errorf("Basic auth = %d with WWW-Authenticate %q, want 401 Bearer", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestTasksAreIsolatedOverHTTP(t *testing.T) {
+	ctx := context.Background()
+	api := client.New(authServer(t).URL, nil)
+	login := func(email string) *client.Client {
+		t.Helper()
+		if _, err := api.Register(ctx, email, "correct horse"); err != nil {
+			t.Fatal(err)
+		}
+		token, err := api.Login(ctx, email, "correct horse")
+		if err != nil || token == "" {
+			t.Fatalf("Login = %q, %v", token, err)
+		}
+		return api.WithToken(token)
+	}
+	carol, dave := login("carol@example.com"), login("dave@example.com")
+
+	task, err := carol.CreateTask(ctx, "Carol's task", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dave.GetTask(ctx, task.ID); !errors.Is(err, usecase.ErrTaskNotFound) {
+		t.Errorf("another user's GET = %v, want 404", err)
+	}
+	if err := dave.DeleteTask(ctx, task.ID); !errors.Is(err, usecase.ErrTaskNotFound) {
+		t.Errorf("another user's DELETE = %v, want 404", err)
+	}
+	if list, err := dave.ListTasks(ctx); err != nil || len(list) != 0 {
+		t.Errorf("another user lists %d tasks, %v", len(list), err)
+	}
+	if got, err := carol.GetTask(ctx, task.ID); err != nil || got.Title != "Carol's task" {
+		t.Errorf("the owner's GET = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,107 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// recordingList passes List through and reports the error each call returned
+type recordingList struct {
+	domain.TaskRepository
+	errs chan error
+}
+
+func (r *recordingList) List(ctx context.Context, query domain.ListTasksQuery) ([]*domain.Task, error) {
+	tasks, err := r.TaskRepository.List(ctx, query)
+	r.errs <- err
+	return tasks, err
+}
+
+// busyServer serves GET /tasks and /tasks/:id from a SQLite database whose
+// only connection is taken, so every query waits until its context ends
+func busyServer(t *testing.T, mw ...echo.MiddlewareFunc) (*echo.Echo, *recordingList) {
+	t.Helper()
+	db := openSQLite(t)
+	db.SetMaxOpenConns(1)
+	repo := &recordingList{TaskRepository: repository.NewTaskRepository(db), errs: make(chan error, 1)}
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(repo))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(mw...)
+	e.Use(handler.AsUser(&domain.User{ID: 1, Role: domain.RoleUser}))
+	e.GET("/tasks", h.GetAllTasks)
+	e.GET("/tasks/:id", h.GetTask)
+
+	// The first request runs while the database is free
+	if status, _ := getProblem(e, "/tasks"); status != http.StatusOK {
+		t.Fatalf("GET /tasks = %d while the database is free", status)
+	}
+	<-repo.errs
+	hold(t, db)
+	return e, repo
+}
+
+// hold takes the database's only connection until the test ends
+func hold(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+}
+
+func getProblem(e *echo.Echo, target string) (int, domain.Code) {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var problem handler.Problem
+	json.Unmarshal(rec.Body.Bytes(), &problem)
+	return rec.Code, problem.Code
+}
+
+func TestRequestPastItsDeadline(t *testing.T) {
+	e, repo := busyServer(t, middleware.ContextTimeout(100*time.Millisecond))
+	for _, target := range []string{"/tasks", "/tasks/1"} {
+		status, code := getProblem(e, target)
+		if status != http.StatusServiceUnavailable || code != handler.ErrTimeout.Code {
+			t.Errorf("GET %s = %d %s, want 503 %s rather than a 404 or 500", target, status, code, handler.ErrTimeout.Code)
+		}
+	}
+	if err := <-repo.errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("the list query ended with %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClientHangingUpStopsTheQuery(t *testing.T) {
+	// Without a deadline, only the client ends the request
+	e, repo := busyServer(t)
+	server := httptest.NewServer(e)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/tasks", nil)
+	if _, err := http.DefaultClient.Do(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("the client's request = %v, want context.Canceled", err)
+	}
+	select {
+	case err := <-repo.errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("the query ended with %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the query was still waiting a second after the client hung up")
+	}
+}
@@ -0,0 +1,199 @@
+package handler_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The tests below read the source of the layers a client's errors come
+// from, so that every one of them has a stable code:
+//
+//   - a package-level Err* variable must be declared with NewError
+//   - errors.New may not appear in a layer that only returns coded errors
+//     (domain, usecase)
+//   - a code must be UPPER_SNAKE_CASE, and used once
+//
+// and that the code table in the README lists exactly those codes.
+
+var codePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+
+// codedDirs are the layers scanned, relative to this package; strict ones
+// may not build uncoded errors
+var codedDirs = []struct {
+	dir    string
+	strict bool
+}{
+	{"../domain", true},
+	{"../usecase", true},
+	{".", false},
+}
+
+// declaredCode is an Err* variable declared with NewError
+type declaredCode struct {
+	code, kind, message, name string
+	pos                       token.Position
+}
+
+// scanCodes parses the coded layers, returning their codes sorted, and what
+// breaks the rules above
+func scanCodes(t *testing.T) (codes []declaredCode, problems []string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	report := func(pos token.Pos, format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("%s: %s", fset.Position(pos), fmt.Sprintf(format, args...)))
+	}
+	for _, d := range codedDirs {
+		pkgs, err := parser.ParseDir(fset, d.dir, func(fi fs.FileInfo) bool {
+			return !strings.HasSuffix(fi.Name(), "_test.go")
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pkg := range pkgs {
+			for _, file := range pkg.Files {
+				codes = append(codes, scanFile(fset, file, d.strict, report)...)
+			}
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].code < codes[j].code })
+	return codes, problems
+}
+
+func scanFile(fset *token.FileSet, file *ast.File, strict bool, report func(token.Pos, string, ...any)) []declaredCode {
+	var codes []declaredCode
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if !strings.HasPrefix(name.Name, "Err") {
+					continue
+				}
+				if i >= len(vs.Values) {
+					report(name.Pos(), "%s has no NewError value", name.Name)
+					continue
+				}
+				d, ok := newErrorCall(vs.Values[i])
+				if !ok {
+					report(name.Pos(), "%s must be declared with NewError(code, kind, message)", name.Name)
+					continue
+				}
+				d.name, d.pos = name.Name, fset.Position(name.Pos())
+				if !codePattern.MatchString(d.code) {
+					report(name.Pos(), "code %q of %s is not UPPER_SNAKE_CASE", d.code, name.Name)
+				}
+				codes = append(codes, d)
+			}
+		}
+	}
+	if strict {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok && isCall(call, "errors", "New") {
+				report(call.Pos(), "errors.New in a coded layer; declare the error with NewError")
+			}
+			return true
+		})
+	}
+	return codes
+}
+
+// newErrorCall recognizes NewError / domain.NewError with literal arguments
+func newErrorCall(expr ast.Expr) (declaredCode, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 3 {
+		return declaredCode{}, false
+	}
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		if fn.Name != "NewError" {
+			return declaredCode{}, false
+		}
+	case *ast.SelectorExpr:
+		if fn.Sel.Name != "NewError" {
+			return declaredCode{}, false
+		}
+	default:
+		return declaredCode{}, false
+	}
+	code, ok1 := stringLit(call.Args[0])
+	message, ok2 := stringLit(call.Args[2])
+	if !ok1 || !ok2 {
+		return declaredCode{}, false
+	}
+	kind := "?"
+	switch k := call.Args[1].(type) {
+	case *ast.Ident:
+		kind = k.Name
+	case *ast.SelectorExpr:
+		kind = k.Sel.Name
+	}
+	return declaredCode{code: code, kind: strings.TrimPrefix(kind, "Kind"), message: message}, true
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+func isCall(call *ast.CallExpr, pkg, fn string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != fn {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == pkg
+}
+
+func TestEveryErrorIsCoded(t *testing.T) {
+	codes, problems := scanCodes(t)
+	for i := 1; i < len(codes); i++ {
+		if codes[i].code == codes[i-1].code {
+			problems = append(problems, fmt.Sprintf("%s: code %s already used by %s at %s",
+				codes[i].pos, codes[i].code, codes[i-1].name, codes[i-1].pos))
+		}
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
+
+// TestCodeTable compares the README's code table with the codes declared,
+// printing the table to paste in when they differ
+func TestCodeTable(t *testing.T) {
+	codes, _ := scanCodes(t)
+	var want strings.Builder
+	want.WriteString("| Code | Kind | Message |\n|------|------|---------|\n")
+	for _, d := range codes {
+		fmt.Fprintf(&want, "| `%s` | %s | %s |\n", d.code, d.kind, d.message)
+	}
+
+	readme, err := os.ReadFile(filepath.Join("..", "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := strings.Index(string(readme), "| Code | Kind | Message |\n")
+	if start < 0 {
+		t.Fatal("the README has no code table")
+	}
+	got, _, _ := strings.Cut(string(readme[start:]), "\n\n")
+	if got+"\n" != want.String() {
+		t.Errorf("the README's code table is out of date; it should read:\n\n%s", want.String())
+	}
+}
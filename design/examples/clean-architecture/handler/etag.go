@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// A response with one task carries a strong ETag made of the task's version
+// and whatever else its body depends on, so two responses with the same ETag
+// have the same body:
+//
+//	"3"           version 3, as v1 presents it
+//	"3-v2"        as v2 does: later versions add their name
+//	"3-v3-modify" as v3 does to a caller who may change the task, whose
+//	"3-v3-read"   links differ from those of one who may only read it
+//
+// v3's responses also carry Vary: Authorization, since who is asking decides
+// the body. Every change to a task raises its version. Clients can then make
+// conditional requests instead of sending the version themselves. GET
+// /tasks/:id with If-None-Match is answered 304 Not Modified while its ETag
+// for the request is one of those listed. PUT /tasks/:id with If-Match only
+// applies to the version the ETag names, through the same check as the
+// version in the body, and is answered 412 Precondition Failed otherwise: it
+// guards the task, not one representation of it, so an ETag from any version
+// or caller will do.
+
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+var ErrPreconditionFailed = domain.NewError("REQUEST_PRECONDITION_FAILED", domain.KindPrecondition, "the task has changed since the version in If-Match")
+
+// etagOf returns the ETag of task's response to the request of c
+func etagOf(c echo.Context, task *domain.Task) string {
+	tag := strconv.FormatInt(task.Version, 10)
+	if v, ok := c.Get(versionKey).(APIVersion); ok {
+		if _, v1 := v.Presenter.(TaskPresenterV1); !v1 {
+			tag += "-" + v.Name
+		}
+	}
+	if _, v3 := presenter(c).(TaskPresenterV3); v3 {
+		if canModify(c, task) {
+			tag += "-modify"
+		} else {
+			tag += "-read"
+		}
+	}
+	return `"` + tag + `"`
+}
+
+func setETag(c echo.Context, task *domain.Task) {
+	header := c.Response().Header()
+	header.Set(HeaderETag, etagOf(c, task))
+	if _, v3 := presenter(c).(TaskPresenterV3); v3 {
+		header.Add(echo.HeaderVary, echo.HeaderAuthorization)
+	}
+}
+
+// parseETag returns the task version of an ETag from etagOf. A weak ETag,
+// W/"3", has none: If-Match compares strongly, so it never matches.
+func parseETag(tag string) (int64, bool) {
+	if len(tag) < 3 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	digits, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+	version, err := strconv.ParseInt(digits, 10, 64)
+	return version, err == nil && version > 0
+}
+
+// notModified answers 304 if the request's If-None-Match lists the task's
+// ETag, weak or not, or is *. It reports whether it answered.
+func notModified(c echo.Context, task *domain.Task) (bool, error) {
+	header := c.Request().Header.Get(HeaderIfNoneMatch)
+	if header == "" {
+		return false, nil
+	}
+	etag := etagOf(c, task)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			setETag(c, task)
+			return true, c.NoContent(http.StatusNotModified)
+		}
+	}
+	return false, nil
+}
+
+// ifMatch returns the version the request's If-Match lets the task with id
+// be changed from, or 0 without one or for *. A single ETag is its version,
+// which the change then checks as it is made; of several, the task's
+// current version if it is one of them. None that can match fails with
+// ErrPreconditionFailed.
+func (h *TaskHandler) ifMatch(c echo.Context, id int64) (int64, error) {
+	header := c.Request().Header.Get(HeaderIfMatch)
+	if header == "" {
+		return 0, nil
+	}
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return 0, nil
+		}
+		if version, ok := parseETag(tag); ok {
+			versions = append(versions, version)
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return 0, ErrPreconditionFailed
+	case 1:
+		return versions[0], nil
+	}
+	task, err := h.taskUseCase.GetTask(c.Request().Context(), actor(c), id)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, task.Version) {
+		return 0, ErrPreconditionFailed
+	}
+	return task.Version, nil
+}
@@ -0,0 +1,123 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// etagServer serves every API version in memory, with one task of user 1
+// assigned to user 2. A request is by the user its Authorization names,
+// "Bearer 1" or "Bearer 2".
+func etagServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	repo := repository.NewMemoryTaskRepository()
+	task, err := domain.NewTask(1, "Write the report", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.AssigneeID = 2
+	if err := repo.Create(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	users := map[string]*domain.User{
+		"Bearer 1": {ID: 1, Role: domain.RoleUser},
+		"Bearer 2": {ID: 2, Role: domain.RoleUser},
+	}
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	handler.RegisterRoutes(handler.NewRouteTable(e), handler.Handlers{
+		Tasks: handler.NewTaskHandler(usecase.NewTaskUseCase(repo)),
+		Authenticate: func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				handler.SetUser(c, users[c.Request().Header.Get(echo.HeaderAuthorization)])
+				return next(c)
+			}
+		},
+	})
+	return e
+}
+
+func etagRequest(e *echo.Echo, method, target, user, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+user)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestETagNamesWhatTheBodyDependsOn(t *testing.T) {
+	e := etagServer(t)
+	for _, tc := range []struct {
+		target, user string
+		etag         string
+		vary         bool
+	}{
+		{"/tasks/1", "1", `"1"`, false},
+		{"/api/v1/tasks/1", "1", `"1"`, false},
+		{"/api/v2/tasks/1", "1", `"1-v2"`, false},
+		{"/api/v3/tasks/1", "1", `"1-v3-modify"`, true},
+		{"/api/v3/tasks/1", "2", `"1-v3-read"`, true},
+	} {
+		rec := etagRequest(e, http.MethodGet, tc.target, tc.user, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s as %s: status %d: %s", tc.target, tc.user, rec.Code, rec.Body)
+		}
+		if etag := rec.Header().Get(handler.HeaderETag); etag != tc.etag {
+			t.Errorf("GET %s as %s: ETag %s, want %s", tc.target, tc.user, etag, tc.etag)
+		}
+		if vary := slices.Contains(rec.Header().Values(echo.HeaderVary), echo.HeaderAuthorization); vary != tc.vary {
+			t.Errorf("GET %s as %s: Vary: Authorization is %t, want %t", tc.target, tc.user, vary, tc.vary)
+		}
+	}
+}
+
+func TestIfNoneMatchWantsTheETagOfTheRequest(t *testing.T) {
+	e := etagServer(t)
+	for _, tc := range []struct {
+		target, user, ifNoneMatch string
+		status                    int
+	}{
+		{"/api/v2/tasks/1", "1", `"1-v2"`, http.StatusNotModified},
+		{"/api/v2/tasks/1", "1", `W/"1-v2"`, http.StatusNotModified},
+		{"/api/v2/tasks/1", "1", `"1"`, http.StatusOK},
+		{"/api/v1/tasks/1", "1", `"1-v2"`, http.StatusOK},
+		{"/api/v3/tasks/1", "2", `"1-v3-read"`, http.StatusNotModified},
+		{"/api/v3/tasks/1", "2", `"1-v3-modify"`, http.StatusOK},
+		{"/api/v3/tasks/1", "1", `"1-v3-read"`, http.StatusOK},
+	} {
+		rec := etagRequest(e, http.MethodGet, tc.target, tc.user, "", handler.HeaderIfNoneMatch, tc.ifNoneMatch)
+		if rec.Code != tc.status {
+			t.Errorf("GET %s as %s with If-None-Match %s: status %d, want %d", tc.target, tc.user, tc.ifNoneMatch, rec.Code, tc.status)
+		}
+	}
+}
+
+func TestIfMatchTakesAnETagOfTheTaskVersion(t *testing.T) {
+	e := etagServer(t)
+	rec := etagRequest(e, http.MethodPut, "/api/v3/tasks/1", "1", `{"title":"Write the final report"}`, handler.HeaderIfMatch, `"1-v2"`)
+	if rec.Code != http.StatusOK || rec.Header().Get(handler.HeaderETag) != `"2-v3-modify"` {
+		t.Fatalf("PUT with If-Match from v2: status %d, ETag %s; want 200 and \"2-v3-modify\"", rec.Code, rec.Header().Get(handler.HeaderETag))
+	}
+	for _, stale := range []string{`"1"`, `"1-v3-modify"`, `W/"2-v3-modify"`} {
+		rec = etagRequest(e, http.MethodPut, "/api/v3/tasks/1", "1", `{"title":"Stale"}`, handler.HeaderIfMatch, stale)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("PUT with If-Match %s: status %d, want 412", stale, rec.Code)
+		}
+	}
+}
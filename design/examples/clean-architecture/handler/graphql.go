@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// The GraphQL API is another adapter over the same use cases as the REST
+// routes. The resolvers in graphql_resolvers.go only translate arguments and
+// results; what a caller may see and do is still decided by the use cases.
+
+//go:embed schema.graphql
+var graphQLSchema string
+
+// GraphQLHandler serves POST /graphql. It must sit behind RequireUser, like
+// the task routes.
+type GraphQLHandler struct {
+	schema *graphql.Schema
+	tasks  *usecase.TaskUseCase
+	users  *usecase.UserUseCase
+}
+
+func NewGraphQLHandler(taskUseCase *usecase.TaskUseCase, userUseCase *usecase.UserUseCase) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema: graphql.MustParseSchema(graphQLSchema, &rootResolver{tasks: taskUseCase},
+			graphql.PanicHandler(panicHandler{})),
+		tasks: taskUseCase,
+		users: userUseCase,
+	}
+}
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Serve executes the operation in the body. The response is 200 whenever the
+// operation ran, with the errors of any fields that failed next to the data
+// of the others, as GraphQL has it.
+func (h *GraphQLHandler) Serve(c echo.Context) error {
+	var req GraphQLRequest
+	if err := c.Bind(&req); err != nil || req.Query == "" {
+		return ErrInvalidBody
+	}
+
+	user := actor(c)
+	op := &operation{
+		actor:    user,
+		owners:   ownerLoader(h.users, user),
+		subtasks: subtaskLoader(h.tasks, user),
+		logger:   c.Logger(),
+	}
+	ctx := context.WithValue(c.Request().Context(), operationKey{}, op)
+	return c.JSON(http.StatusOK, h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// operation is the state of one GraphQL request, shared by its resolvers
+type operation struct {
+	actor    *domain.User
+	owners   *loader[*domain.User]
+	subtasks *loader[[]*domain.Task]
+	logger   echo.Logger
+}
+
+type operationKey struct{}
+
+func operationOf(ctx context.Context) *operation {
+	return ctx.Value(operationKey{}).(*operation)
+}
+
+// graphQLError is a coded error as GraphQL reports it: the message, in the
+// language of the request, and the code in extensions.code
+type graphQLError struct {
+	coded   *domain.Error
+	message string
+}
+
+func (e graphQLError) Error() string {
+	return e.message
+}
+
+func (e graphQLError) Unwrap() error {
+	return e.coded
+}
+
+func (e graphQLError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.coded.Code}
+}
+
+// fail turns a resolver's error into a graphQLError, with the same codes and
+// the same care not to leak internal errors as ErrorHandler
+func fail(ctx context.Context, err error) error {
+	coded := codedError(ctx, operationOf(ctx).logger, err)
+	return graphQLError{coded, messageOf(ctx, coded)}
+}
+
+// panicHandler reports a resolver that panicked as INTERNAL_ERROR rather
+// than with the panic's value. The library has logged it already.
+type panicHandler struct{}
+
+func (panicHandler) MakePanicError(ctx context.Context, value interface{}) *gqlerrors.QueryError {
+	return &gqlerrors.QueryError{
+		Message:    messageOf(ctx, ErrInternal),
+		Extensions: graphQLError{coded: ErrInternal}.Extensions(),
+	}
+}
+
+// loader loads what the tasks an operation returns refer to, by ID: their
+// owners, by owner ID, and their subtasks, by parent ID. The library resolves
+// each task's fields on its own, concurrently, which looked up one by one is
+// a query per task. Instead every task is registered with the loaders as it
+// is returned, and the first value asked for fetches those of all of them
+// with one call of fetch. Lookups that arrive during the fetch wait for it,
+// then find their value loaded.
+type loader[V any] struct {
+	// fetch returns the values of ids, leaving out those that have none
+	fetch func(ctx context.Context, ids []int64) (map[int64]V, error)
+
+	mu      sync.Mutex
+	pending map[int64]bool // registered, not fetched yet
+	loaded  map[int64]V    // the zero V for an ID with none
+}
+
+func newLoader[V any](fetch func(ctx context.Context, ids []int64) (map[int64]V, error)) *loader[V] {
+	return &loader[V]{fetch: fetch, pending: map[int64]bool{}, loaded: map[int64]V{}}
+}
+
+// expect registers the ID of a value that may be asked for
+func (l *loader[V]) expect(id int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.loaded[id]; !ok {
+		l.pending[id] = true
+	}
+}
+
+// load returns the value of id, fetching it with every other pending one
+func (l *loader[V]) load(ctx context.Context, id int64) (V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if value, ok := l.loaded[id]; ok {
+		return value, nil
+	}
+
+	l.pending[id] = true
+	ids := make([]int64, 0, len(l.pending))
+	for pending := range l.pending {
+		ids = append(ids, pending)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	values, err := l.fetch(ctx, ids)
+	if err != nil {
+		var none V
+		return none, err
+	}
+	for _, id := range ids {
+		l.loaded[id] = values[id]
+	}
+	clear(l.pending)
+	return l.loaded[id], nil
+}
+
+// ownerLoader fetches owners with one GetUsers; an ID with no account is nil
+func ownerLoader(users *usecase.UserUseCase, actor *domain.User) *loader[*domain.User] {
+	return newLoader(func(ctx context.Context, ids []int64) (map[int64]*domain.User, error) {
+		found, err := users.GetUsers(ctx, actor, ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int64]*domain.User, len(found))
+		for _, user := range found {
+			byID[user.ID] = user
+		}
+		return byID, nil
+	})
+}
+
+// subtaskQueryBatch keeps the parent IDs of one listing under SQLite's limit
+// on bound parameters
+const subtaskQueryBatch = 500
+
+// subtaskLoader fetches the subtasks of many tasks with one ListTasks, or one
+// per subtaskQueryBatch parents. Each task's subtasks are those the actor may
+// list, newest first.
+func subtaskLoader(tasks *usecase.TaskUseCase, actor *domain.User) *loader[[]*domain.Task] {
+	return newLoader(func(ctx context.Context, ids []int64) (map[int64][]*domain.Task, error) {
+		byParent := make(map[int64][]*domain.Task)
+		for start := 0; start < len(ids); start += subtaskQueryBatch {
+			query := domain.ListTasksQuery{ParentIDs: ids[start:min(start+subtaskQueryBatch, len(ids))]}
+			subtasks, err := tasks.ListTasks(ctx, actor, query)
+			if err != nil {
+				return nil, err
+			}
+			for _, task := range subtasks {
+				byParent[task.ParentID] = append(byParent[task.ParentID], task)
+			}
+		}
+		return byParent, nil
+	})
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Resolvers for schema.graphql. Arguments arrive as the structs below, which
+// the library fills by field name; optional arguments are pointers.
+
+type rootResolver struct {
+	tasks *usecase.TaskUseCase
+}
+
+func parseID(id graphql.ID, invalid error) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, invalid
+	}
+	return n, nil
+}
+
+type taskFilter struct {
+	Completed     *bool
+	CreatedFrom   *graphql.Time
+	CreatedBefore *graphql.Time
+	Search        *string
+	Tags          *[]string
+	OwnerID       *graphql.ID
+}
+
+func (f *taskFilter) query() (domain.ListTasksQuery, error) {
+	var query domain.ListTasksQuery
+	if f == nil {
+		return query, nil
+	}
+	query.Completed = f.Completed
+	if f.CreatedFrom != nil {
+		query.CreatedFrom = f.CreatedFrom.Time
+	}
+	if f.CreatedBefore != nil {
+		query.CreatedBefore = f.CreatedBefore.Time
+	}
+	if f.Search != nil {
+		query.Search = *f.Search
+	}
+	if f.Tags != nil {
+		query.Tags = *f.Tags
+	}
+	if f.OwnerID != nil {
+		id, err := parseID(*f.OwnerID, ErrInvalidQuery)
+		if err != nil {
+			return query, err
+		}
+		query.OwnerID = id
+	}
+	return query, nil
+}
+
+func (r *rootResolver) Task(ctx context.Context, args struct{ ID graphql.ID }) (*taskResolver, error) {
+	id, err := parseID(args.ID, ErrInvalidTaskID)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	task, err := r.tasks.GetTask(ctx, operationOf(ctx).actor, id)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newTaskResolver(ctx, task), nil
+}
+
+func (r *rootResolver) Tasks(ctx context.Context, args struct{ Filter *taskFilter }) ([]*taskResolver, error) {
+	query, err := args.Filter.query()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	tasks, err := r.tasks.ListTasks(ctx, operationOf(ctx).actor, query)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	resolvers := make([]*taskResolver, len(tasks))
+	for i, task := range tasks {
+		resolvers[i] = newTaskResolver(ctx, task)
+	}
+	return resolvers, nil
+}
+
+type createTaskInput struct {
+	Title       string
+	Description *string
+	Priority    *string
+	Tags        *[]string
+	ParentID    *graphql.ID
+}
+
+func (r *rootResolver) CreateTask(ctx context.Context, args struct{ Input createTaskInput }) (*taskResolver, error) {
+	in := usecase.CreateTaskInput{Title: args.Input.Title}
+	if args.Input.Description != nil {
+		in.Description = *args.Input.Description
+	}
+	if args.Input.Priority != nil {
+		in.Priority = *args.Input.Priority
+	}
+	if args.Input.Tags != nil {
+		in.Tags = *args.Input.Tags
+	}
+	if args.Input.ParentID != nil {
+		parent, err := parseID(*args.Input.ParentID, ErrInvalidTaskID)
+		if err != nil {
+			return nil, fail(ctx, err)
+		}
+		in.ParentID = parent
+	}
+	task, err := r.tasks.CreateTask(ctx, operationOf(ctx).actor, in)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newTaskResolver(ctx, task), nil
+}
+
+type updateTaskInput struct {
+	Title       string
+	Description string
+	Completed   bool
+	Priority    *string
+	Tags        *[]string
+	Version     *int32
+}
+
+func (r *rootResolver) UpdateTask(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input updateTaskInput
+}) (*taskResolver, error) {
+	id, err := parseID(args.ID, ErrInvalidTaskID)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	in := usecase.UpdateTaskInput{
+		ID:          id,
+		Title:       args.Input.Title,
+		Description: args.Input.Description,
+		Completed:   args.Input.Completed,
+	}
+	if args.Input.Priority != nil {
+		in.Priority = *args.Input.Priority
+	}
+	if args.Input.Tags != nil {
+		in.Tags = *args.Input.Tags
+	}
+	if args.Input.Version != nil {
+		in.Version = int64(*args.Input.Version)
+	}
+	task, err := r.tasks.UpdateTask(ctx, operationOf(ctx).actor, in)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newTaskResolver(ctx, task), nil
+}
+
+func (r *rootResolver) CompleteTask(ctx context.Context, args struct{ ID graphql.ID }) (*taskResolver, error) {
+	id, err := parseID(args.ID, ErrInvalidTaskID)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	task, err := r.tasks.CompleteTask(ctx, operationOf(ctx).actor, id)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newTaskResolver(ctx, task), nil
+}
+
+type taskResolver struct {
+	task *domain.Task
+}
+
+// newTaskResolver registers the task's owner and subtasks with the
+// operation's loaders, so they are fetched with the others
+func newTaskResolver(ctx context.Context, task *domain.Task) *taskResolver {
+	op := operationOf(ctx)
+	op.owners.expect(task.OwnerID)
+	op.subtasks.expect(task.ID)
+	return &taskResolver{task: task}
+}
+
+func (r *taskResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.task.ID, 10))
+}
+
+func (r *taskResolver) Title() string       { return r.task.Title }
+func (r *taskResolver) Description() string { return r.task.Description }
+func (r *taskResolver) Completed() bool     { return r.task.Completed }
+func (r *taskResolver) Priority() string    { return string(r.task.Priority) }
+func (r *taskResolver) Version() int32      { return int32(r.task.Version) }
+
+func (r *taskResolver) Tags() []string {
+	if r.task.Tags == nil {
+		return []string{}
+	}
+	return r.task.Tags
+}
+
+func (r *taskResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.task.CreatedAt}
+}
+
+func (r *taskResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.task.UpdatedAt}
+}
+
+func (r *taskResolver) ParentID() *graphql.ID {
+	if r.task.ParentID == 0 {
+		return nil
+	}
+	id := graphql.ID(strconv.FormatInt(r.task.ParentID, 10))
+	return &id
+}
+
+func (r *taskResolver) Subtasks(ctx context.Context) ([]*taskResolver, error) {
+	subtasks, err := operationOf(ctx).subtasks.load(ctx, r.task.ID)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	resolvers := make([]*taskResolver, len(subtasks))
+	for i, task := range subtasks {
+		resolvers[i] = newTaskResolver(ctx, task)
+	}
+	return resolvers, nil
+}
+
+func (r *taskResolver) Owner(ctx context.Context) (*userResolver, error) {
+	user, err := operationOf(ctx).owners.load(ctx, r.task.OwnerID)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &userResolver{user: user}, nil
+}
+
+type userResolver struct {
+	user *domain.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.user.ID, 10))
+}
+
+func (r *userResolver) Email() string { return r.user.Email }
+func (r *userResolver) Role() string  { return string(r.user.Role) }
@@ -0,0 +1,460 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// countingUsers counts the batch lookups the owner loader makes
+type countingUsers struct {
+	domain.UserRepository
+	batches atomic.Int32
+}
+
+func (r *countingUsers) GetByIDs(ctx context.Context, ids []int64) ([]*domain.User, error) {
+	r.batches.Add(1)
+	return r.UserRepository.GetByIDs(ctx, ids)
+}
+
+// countingTasks counts the listings the subtask loader makes
+type countingTasks struct {
+	domain.TaskRepository
+	lists atomic.Int32
+}
+
+func (r *countingTasks) List(ctx context.Context, query domain.ListTasksQuery) ([]*domain.Task, error) {
+	r.lists.Add(1)
+	return r.TaskRepository.List(ctx, query)
+}
+
+// brokenTasks fails every listing with an error that must not reach clients
+type brokenTasks struct {
+	domain.TaskRepository
+}
+
+func (brokenTasks) List(context.Context, domain.ListTasksQuery) ([]*domain.Task, error) {
+	return nil, errors.New("tasks table is on fire")
+}
+
+// gqlAPI executes GraphQL operations as one user
+type gqlAPI struct {
+	e *echo.Echo
+}
+
+func newGQLAPI(h *handler.GraphQLHandler, user *domain.User) gqlAPI {
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Logger.SetOutput(new(bytes.Buffer))
+	e.POST("/graphql", h.Serve, handler.AsUser(user))
+	return gqlAPI{e: e}
+}
+
+type gqlResponse struct {
+	Data   json.RawMessage
+	Errors []struct {
+		Message    string
+		Path       []interface{}
+		Extensions struct{ Code domain.Code }
+	}
+}
+
+// code is the code of the first error, if any
+func (r gqlResponse) code() domain.Code {
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	return r.Errors[0].Extensions.Code
+}
+
+func (a gqlAPI) send(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	a.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// exec runs query with variables and decodes its data into data
+func (a gqlAPI) exec(t *testing.T, query string, variables map[string]interface{}, data interface{}) gqlResponse {
+	t.Helper()
+	body, _ := json.Marshal(handler.GraphQLRequest{Query: query, Variables: variables})
+	rec := a.send(string(body))
+	var resp gqlResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+	if data != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp
+}
+
+type gqlTask struct {
+	ID          string
+	Title       string
+	Description string
+	Completed   bool
+	Priority    string
+	Tags        []string
+	Version     int
+	CreatedAt   time.Time
+	Owner       *struct{ ID, Email, Role string }
+}
+
+const gqlTaskFields = `id title description completed priority tags version createdAt owner { id email role }`
+
+const gqlListQuery = `query($filter: TaskFilter) { tasks(filter: $filter) { ` + gqlTaskFields + ` } }`
+
+func gqlIDs(tasks []gqlTask) []string {
+	out := make([]string, len(tasks))
+	for i, t := range tasks {
+		out[i] = t.ID
+	}
+	return out
+}
+
+// gqlFixture holds three accounts and their tasks, one of them from before
+// accounts existed, served over GraphQL
+type gqlFixture struct {
+	users             *countingUsers
+	tasks             domain.TaskRepository
+	uc                *usecase.TaskUseCase
+	h                 *handler.GraphQLHandler
+	alice, bob, admin *domain.User
+	asAlice, asAdmin  gqlAPI
+	base              time.Time
+}
+
+func newGQLFixture(t *testing.T) *gqlFixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &gqlFixture{
+		users: &countingUsers{UserRepository: repository.NewMemoryUserRepository()},
+		tasks: repository.NewMemoryTaskRepository(),
+		base:  time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+	account := func(email string, role domain.Role) *domain.User {
+		user := domain.NewUser(email, "hash")
+		if err := f.users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.users.SetRole(ctx, user.ID, role); err != nil {
+			t.Fatal(err)
+		}
+		user.Role = role
+		return user
+	}
+	f.alice = account("alice@example.com", domain.RoleUser)
+	f.bob = account("bob@example.com", domain.RoleUser)
+	f.admin = account("admin@example.com", domain.RoleAdmin)
+
+	f.uc = usecase.NewTaskUseCase(f.tasks)
+	for i, s := range []struct {
+		owner     *domain.User
+		title     string
+		priority  string
+		tags      []string
+		completed bool
+	}{
+		{f.alice, "Write report", "high", []string{"work"}, false},
+		{f.alice, "Buy milk", "low", []string{"errands", "home"}, true},
+		{f.bob, "Review report", "", []string{"work"}, false},
+		{f.alice, "Plan trip", "", nil, false},
+		{f.bob, "Fix bike", "", []string{"home"}, true},
+	} {
+		task, err := f.uc.CreateTask(ctx, s.owner, usecase.CreateTaskInput{Title: s.title, Priority: s.priority, Tags: s.tags})
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.CreatedAt = f.base.Add(time.Duration(i) * 24 * time.Hour)
+		task.Completed = s.completed
+		if err := f.tasks.Update(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A task from before accounts existed has no owner
+	legacy, _ := domain.NewTask(0, "Written before accounts", "", time.Now())
+	legacy.CreatedAt = f.base.Add(-24 * time.Hour)
+	if err := f.tasks.Create(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	f.h = handler.NewGraphQLHandler(f.uc, usecase.NewUserUseCase(f.users))
+	f.asAlice, f.asAdmin = newGQLAPI(f.h, f.alice), newGQLAPI(f.h, f.admin)
+	return f
+}
+
+func TestGraphQLTasksAgreesWithTheUseCase(t *testing.T) {
+	f := newGQLFixture(t)
+	filters := []struct {
+		name   string
+		filter map[string]interface{}
+		query  domain.ListTasksQuery
+	}{
+		{"no filter", nil, domain.ListTasksQuery{}},
+		{"completed false", map[string]interface{}{"completed": false}, domain.ListTasksQuery{Completed: new(bool)}},
+		{"tags work", map[string]interface{}{"tags": []string{"work"}}, domain.ListTasksQuery{Tags: []string{"work"}}},
+		{"search REPORT", map[string]interface{}{"search": "REPORT"}, domain.ListTasksQuery{Search: "REPORT"}},
+		{"created in March 2-4", map[string]interface{}{"createdFrom": "2024-03-02T00:00:00Z", "createdBefore": "2024-03-04T00:00:00Z"},
+			domain.ListTasksQuery{CreatedFrom: f.base.Add(15 * time.Hour), CreatedBefore: f.base.Add(63 * time.Hour)}},
+	}
+	callers := []struct {
+		name string
+		api  gqlAPI
+		user *domain.User
+	}{{"alice", f.asAlice, f.alice}, {"admin", f.asAdmin, f.admin}}
+	for _, tt := range filters {
+		for _, caller := range callers {
+			t.Run(tt.name+" as "+caller.name, func(t *testing.T) {
+				var data struct{ Tasks []gqlTask }
+				resp := caller.api.exec(t, gqlListQuery, map[string]interface{}{"filter": tt.filter}, &data)
+				want, err := f.uc.ListTasks(context.Background(), caller.user, tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantIDs := make([]string, len(want))
+				for i, task := range want {
+					wantIDs[i] = fmt.Sprint(task.ID)
+				}
+				if len(resp.Errors) != 0 || !reflect.DeepEqual(gqlIDs(data.Tasks), wantIDs) {
+					t.Errorf("tasks = %v with errors %+v, want %v as the use case lists", gqlIDs(data.Tasks), resp.Errors, wantIDs)
+				}
+			})
+		}
+	}
+}
+
+func TestGraphQLTask(t *testing.T) {
+	f := newGQLFixture(t)
+	var one struct{ Task *gqlTask }
+	resp := f.asAlice.exec(t, `query($id: ID!) { task(id: $id) { `+gqlTaskFields+` } }`, map[string]interface{}{"id": "1"}, &one)
+	if len(resp.Errors) != 0 || one.Task == nil || one.Task.Title != "Write report" || one.Task.Priority != "high" ||
+		!reflect.DeepEqual(one.Task.Tags, []string{"work"}) || one.Task.Version != 2 || !one.Task.CreatedAt.Equal(f.base) {
+		t.Errorf("task(id: 1) = %+v, %+v; want every field", one.Task, resp.Errors)
+	}
+
+	tests := []struct {
+		name  string
+		api   gqlAPI
+		query string
+		code  domain.Code
+	}{
+		{"another user's task", f.asAlice, `{ task(id: "3") { id } }`, usecase.ErrTaskNotFound.Code},
+		{"a malformed ID", f.asAlice, `{ task(id: "abc") { id } }`, handler.ErrInvalidTaskID.Code},
+		{"a user asking for another owner's tasks", f.asAlice, fmt.Sprintf(`{ tasks(filter: {ownerId: "%d"}) { id } }`, f.bob.ID), usecase.ErrForbidden.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := tt.api.exec(t, tt.query, nil, nil)
+			if resp.code() != tt.code || string(resp.Data) != `{"task":null}` && string(resp.Data) != "null" {
+				t.Errorf("got data %s with code %s, want null with %s", resp.Data, resp.code(), tt.code)
+			}
+		})
+	}
+
+	var bobs struct{ Tasks []gqlTask }
+	f.asAdmin.exec(t, `query($owner: ID) { tasks(filter: {ownerId: $owner}) { id } }`, map[string]interface{}{"owner": fmt.Sprint(f.bob.ID)}, &bobs)
+	if !reflect.DeepEqual(gqlIDs(bobs.Tasks), []string{"5", "3"}) {
+		t.Errorf("an admin listing Bob's tasks got %v, want [5 3]", gqlIDs(bobs.Tasks))
+	}
+}
+
+func TestGraphQLOwnersAreBatched(t *testing.T) {
+	f := newGQLFixture(t)
+
+	f.users.batches.Store(0)
+	var all struct{ Tasks []gqlTask }
+	resp := f.asAdmin.exec(t, gqlListQuery, nil, &all)
+	owners := map[string]string{}
+	for _, task := range all.Tasks {
+		if task.Owner != nil {
+			owners[task.Title] = task.Owner.Email
+		} else {
+			owners[task.Title] = ""
+		}
+	}
+	if len(resp.Errors) != 0 || len(all.Tasks) != 6 || owners["Buy milk"] != f.alice.Email || owners["Fix bike"] != f.bob.Email {
+		t.Errorf("an admin's listing has owners %v, %+v", owners, resp.Errors)
+	}
+	if owner, ok := owners["Written before accounts"]; !ok || owner != "" {
+		t.Errorf("the task from before accounts has owner %q, want null", owner)
+	}
+	if n := f.users.batches.Load(); n != 1 {
+		t.Errorf("6 tasks of 3 owners took %d user lookups, want 1", n)
+	}
+
+	f.users.batches.Store(0)
+	if resp := f.asAlice.exec(t, `{ tasks { owner { email } } }`, nil, nil); len(resp.Errors) != 0 || f.users.batches.Load() != 1 {
+		t.Errorf("a user's own listing took %d user lookups, %+v; want 1", f.users.batches.Load(), resp.Errors)
+	}
+	f.users.batches.Store(0)
+	f.asAlice.exec(t, `{ tasks { id } }`, nil, nil)
+	if n := f.users.batches.Load(); n != 0 {
+		t.Errorf("a query without owners took %d user lookups", n)
+	}
+}
+
+func TestGraphQLSubtasks(t *testing.T) {
+	f := newGQLFixture(t)
+	tasks := &countingTasks{TaskRepository: f.tasks}
+	h := handler.NewGraphQLHandler(usecase.NewTaskUseCase(tasks), usecase.NewUserUseCase(f.users))
+	asAlice, asAdmin := newGQLAPI(h, f.alice), newGQLAPI(h, f.admin)
+
+	const create = `mutation($in: CreateTaskInput!) { createTask(input: $in) { id parentId } }`
+	subtask := func(parent, title string) string {
+		t.Helper()
+		var created struct{ CreateTask struct{ ID, ParentID string } }
+		resp := asAlice.exec(t, create, map[string]interface{}{"in": map[string]interface{}{"title": title, "parentId": parent}}, &created)
+		if len(resp.Errors) != 0 || created.CreateTask.ParentID != parent {
+			t.Fatalf("createTask(%s under %s) = %+v, %+v", title, parent, created.CreateTask, resp.Errors)
+		}
+		return created.CreateTask.ID
+	}
+	// Alice's tasks are 1, 2 and 4
+	outline := subtask("1", "Outline")
+	subtask("1", "Draft")
+	subtask(outline, "Find sources")
+	subtask("4", "Book flights")
+
+	type node struct {
+		Title    string
+		ParentID *string
+		Subtasks []node
+	}
+	var report struct{ Task node }
+	resp := asAlice.exec(t, `{ task(id: "1") { title parentId subtasks { title parentId subtasks { title } } } }`, nil, &report)
+	want := node{Title: "Write report", Subtasks: []node{
+		{Title: "Draft", ParentID: &[]string{"1"}[0], Subtasks: []node{}},
+		{Title: "Outline", ParentID: &[]string{"1"}[0], Subtasks: []node{{Title: "Find sources"}}},
+	}}
+	if len(resp.Errors) != 0 || !reflect.DeepEqual(report.Task, want) {
+		t.Errorf("task 1 = %+v, %+v; want its subtasks, newest first, and theirs", report.Task, resp.Errors)
+	}
+
+	t.Run("the subtasks of every task are loaded together", func(t *testing.T) {
+		tasks.lists.Store(0)
+		var all struct{ Tasks []struct{ Subtasks []struct{ ID string } } }
+		resp := asAdmin.exec(t, `{ tasks { subtasks { id } } }`, nil, &all)
+		n := 0
+		for _, task := range all.Tasks {
+			n += len(task.Subtasks)
+		}
+		if len(resp.Errors) != 0 || len(all.Tasks) != 10 || n != 4 {
+			t.Errorf("an admin's listing has %d tasks with %d subtasks, %+v; want 10 with 4", len(all.Tasks), n, resp.Errors)
+		}
+		if n := tasks.lists.Load(); n != 2 {
+			t.Errorf("10 tasks and their subtasks took %d listings, want 2", n)
+		}
+
+		tasks.lists.Store(0)
+		asAlice.exec(t, `{ task(id: "1") { subtasks { subtasks { id } } } }`, nil, nil)
+		if n := tasks.lists.Load(); n != 2 {
+			t.Errorf("two levels of subtasks took %d listings, want one per level", n)
+		}
+		// The listing holds the subtasks too, so theirs came with the first level
+		tasks.lists.Store(0)
+		asAlice.exec(t, `{ tasks { subtasks { subtasks { id } } } }`, nil, nil)
+		if n := tasks.lists.Load(); n != 2 {
+			t.Errorf("two levels of subtasks of a listing took %d listings, want 2", n)
+		}
+		tasks.lists.Store(0)
+		asAlice.exec(t, `{ tasks { id } }`, nil, nil)
+		if n := tasks.lists.Load(); n != 1 {
+			t.Errorf("a query without subtasks took %d listings", n)
+		}
+	})
+
+	t.Run("a parent the caller may not change", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			api    gqlAPI
+			parent string
+			code   domain.Code
+		}{
+			{"another user's task", newGQLAPI(h, f.bob), "1", usecase.ErrTaskNotFound.Code},
+			{"a task an admin may only view", asAdmin, "1", usecase.ErrForbidden.Code},
+			{"a missing task", asAlice, "99", usecase.ErrTaskNotFound.Code},
+			{"a malformed ID", asAlice, "abc", handler.ErrInvalidTaskID.Code},
+		}
+		for _, tt := range tests {
+			resp := tt.api.exec(t, create, map[string]interface{}{"in": map[string]interface{}{"title": "Sneak in", "parentId": tt.parent}}, nil)
+			if resp.code() != tt.code {
+				t.Errorf("%s: createTask = %s, want %s", tt.name, resp.code(), tt.code)
+			}
+		}
+	})
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	f := newGQLFixture(t)
+	var created struct{ CreateTask gqlTask }
+	resp := f.asAlice.exec(t, `mutation($in: CreateTaskInput!) { createTask(input: $in) { `+gqlTaskFields+` } }`,
+		map[string]interface{}{"in": map[string]interface{}{"title": "Ship it", "priority": "high", "tags": []string{"Release", "ops"}}}, &created)
+	task := created.CreateTask
+	if len(resp.Errors) != 0 || task.Version != 1 || task.Priority != "high" || !reflect.DeepEqual(task.Tags, []string{"ops", "release"}) ||
+		task.Owner == nil || task.Owner.Email != f.alice.Email {
+		t.Fatalf("createTask = %+v, %+v; want a labelled task for the caller, as version 1", task, resp.Errors)
+	}
+	if resp := f.asAlice.exec(t, `mutation { createTask(input: {title: ""}) { id } }`, nil, nil); resp.code() != domain.ErrEmptyTitle.Code {
+		t.Errorf("an empty title = %s, want %s", resp.code(), domain.ErrEmptyTitle.Code)
+	}
+
+	const update = `mutation($id: ID!, $in: UpdateTaskInput!) { updateTask(id: $id, input: $in) { ` + gqlTaskFields + ` } }`
+	var updated struct{ UpdateTask gqlTask }
+	resp = f.asAlice.exec(t, update, map[string]interface{}{"id": task.ID, "in": map[string]interface{}{"title": "Ship it today", "description": "", "completed": false, "version": 1}}, &updated)
+	if u := updated.UpdateTask; len(resp.Errors) != 0 || u.Title != "Ship it today" || u.Version != 2 ||
+		!reflect.DeepEqual(u.Tags, []string{"ops", "release"}) || u.Priority != "high" {
+		t.Errorf("updateTask = %+v, %+v; want it stored as version 2 with the priority and tags kept", u, resp.Errors)
+	}
+	resp = f.asAlice.exec(t, update, map[string]interface{}{"id": task.ID, "in": map[string]interface{}{"title": "Stale", "description": "", "completed": false, "version": 1}}, nil)
+	if resp.code() != domain.ErrVersionConflict.Code {
+		t.Errorf("updateTask from an old version = %s, want %s", resp.code(), domain.ErrVersionConflict.Code)
+	}
+
+	var completed struct{ CompleteTask gqlTask }
+	resp = f.asAlice.exec(t, `mutation($id: ID!) { completeTask(id: $id) { completed version } }`, map[string]interface{}{"id": task.ID}, &completed)
+	if len(resp.Errors) != 0 || !completed.CompleteTask.Completed || completed.CompleteTask.Version != 3 {
+		t.Errorf("completeTask = %+v, %+v; want completed as version 3", completed.CompleteTask, resp.Errors)
+	}
+	resp = f.asAdmin.exec(t, `mutation($id: ID!) { completeTask(id: $id) { id } }`, map[string]interface{}{"id": task.ID}, nil)
+	if resp.code() != usecase.ErrForbidden.Code {
+		t.Errorf("an admin completing it = %s, want %s as over REST", resp.code(), usecase.ErrForbidden.Code)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	f := newGQLFixture(t)
+	if resp := f.asAlice.exec(t, `{ tasks { nope } }`, nil, nil); len(resp.Errors) != 1 || len(resp.Data) != 0 || !strings.Contains(resp.Errors[0].Message, "nope") {
+		t.Errorf("a query the schema does not allow = %s, %+v; want it rejected before it runs", resp.Data, resp.Errors)
+	}
+
+	rec := f.asAlice.send(`{"query": ""}`)
+	var problem handler.Problem
+	json.Unmarshal(rec.Body.Bytes(), &problem)
+	if rec.Code != http.StatusBadRequest || problem.Code != handler.ErrInvalidBody.Code {
+		t.Errorf("a body without a query = %d %s, want a 400 %s problem", rec.Code, problem.Code, handler.ErrInvalidBody.Code)
+	}
+
+	broken := newGQLAPI(handler.NewGraphQLHandler(usecase.NewTaskUseCase(brokenTasks{f.tasks}), usecase.NewUserUseCase(f.users)), f.alice)
+	if resp := broken.exec(t, `{ tasks { id } }`, nil, nil); resp.code() != handler.ErrInternal.Code || resp.Errors[0].Message != handler.ErrInternal.Message {
+		t.Errorf("a storage failure = %+v, want %s without its message", resp.Errors, handler.ErrInternal.Code)
+	}
+}
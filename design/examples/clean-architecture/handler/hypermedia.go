@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// v3 is v2 with links: every task has _links to itself and to what the
+// caller may do with it, and a listing is a page, with links to the pages
+// before and after it. A client follows them rather than building paths.
+// Links begin with Handlers.BaseURL, the URL clients reach the server at,
+// since behind a proxy that is not the one the request came in on; without
+// it, they are paths from the root.
+
+// Link is where a related resource is, and the method to use on it
+type Link struct {
+	Href   string `json:"href"`
+	Method string `json:"method,omitempty"` // GET when absent
+}
+
+// TaskLinks are a task's own link, the listing of its subtasks, and its
+// actions. Complete and Delete are there only if the caller may change the
+// task, and Complete only while it is open.
+type TaskLinks struct {
+	Self     Link  `json:"self"`
+	Subtasks Link  `json:"subtasks"`
+	Complete *Link `json:"complete,omitempty"`
+	Delete   *Link `json:"delete,omitempty"`
+}
+
+// TaskResponseV3 is a task in v3: as in v2, with its links
+type TaskResponseV3 struct {
+	TaskResponseV2
+	Links TaskLinks `json:"_links"`
+}
+
+// PageLinks are a page's own link and those of the pages around it, which
+// differ from it in offset only. Next is there whenever the page is full, so
+// the page after a full last one is empty.
+type PageLinks struct {
+	Self Link  `json:"self"`
+	Next *Link `json:"next,omitempty"`
+	Prev *Link `json:"prev,omitempty"`
+}
+
+// TaskPageResponse is a listing in v3: the tasks from offset on, at most
+// limit of them, or all of them for a limit of 0
+type TaskPageResponse struct {
+	Items  []TaskResponseV3 `json:"items"`
+	Offset int              `json:"offset"`
+	Limit  int              `json:"limit"`
+	Links  PageLinks        `json:"_links"`
+}
+
+// BulkItemResponseV3 is BulkItemResponse with its task as in v3
+type BulkItemResponseV3 struct {
+	Index  int             `json:"index"`
+	ID     int64           `json:"id,omitempty"`
+	Status string          `json:"status"`
+	Task   *TaskResponseV3 `json:"task,omitempty"`
+	Error  *BulkItemError  `json:"error,omitempty"`
+}
+
+type BulkResponseV3 struct {
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Results   []BulkItemResponseV3 `json:"results"`
+}
+
+// TaskPresenterV3 renders tasks as TaskResponseV3, and listings as
+// TaskPageResponse
+type TaskPresenterV3 struct{}
+
+// baseURL is what the request's links begin with
+func baseURL(c echo.Context) string {
+	base, _ := c.Get(baseURLKey).(string)
+	return base
+}
+
+// linkTo links to path in the request's version: /tasks/1 is
+// <base URL>/api/v3/tasks/1
+func linkTo(c echo.Context, path, method string) Link {
+	if c == nil {
+		return Link{Href: path, Method: method}
+	}
+	v, _ := c.Get(versionKey).(APIVersion)
+	return Link{Href: baseURL(c) + v.Prefix() + path, Method: method}
+}
+
+func toResponseV3(c echo.Context, task *domain.Task) TaskResponseV3 {
+	self := "/tasks/" + strconv.FormatInt(task.ID, 10)
+	resp := TaskResponseV3{TaskResponseV2: toResponseV2(task), Links: TaskLinks{
+		Self:     linkTo(c, self, ""),
+		Subtasks: linkTo(c, "/tasks?parent="+strconv.FormatInt(task.ID, 10), ""),
+	}}
+	if c == nil {
+		return resp
+	}
+	if canModify(c, task) {
+		if !task.Completed {
+			complete := linkTo(c, self+"/complete", http.MethodPost)
+			resp.Links.Complete = &complete
+		}
+		remove := linkTo(c, self, http.MethodDelete)
+		resp.Links.Delete = &remove
+	}
+	return resp
+}
+
+// canModify reports whether the caller of c may change task, and so is
+// linked to the ways to
+func canModify(c echo.Context, task *domain.Task) bool {
+	user := CurrentUser(c)
+	return user != nil && usecase.CanModify(user, task)
+}
+
+func (TaskPresenterV3) Task(c echo.Context, task *domain.Task) interface{} {
+	return toResponseV3(c, task)
+}
+
+// Tasks is the page of the request's limit and offset, which the handler
+// has read without error
+func (TaskPresenterV3) Tasks(c echo.Context, tasks []*domain.Task) interface{} {
+	page := TaskPageResponse{Items: make([]TaskResponseV3, len(tasks))}
+	for i, task := range tasks {
+		page.Items[i] = toResponseV3(c, task)
+	}
+	if c == nil {
+		return page
+	}
+	page.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	page.Offset, _ = strconv.Atoi(c.QueryParam("offset"))
+	page.Links.Self = pageLink(c, page.Offset)
+	if page.Limit > 0 && len(tasks) == page.Limit {
+		next := pageLink(c, page.Offset+page.Limit)
+		page.Links.Next = &next
+	}
+	if page.Limit > 0 && page.Offset > 0 {
+		prev := pageLink(c, max(page.Offset-page.Limit, 0))
+		page.Links.Prev = &prev
+	}
+	return page
+}
+
+// pageLink links to the request's listing from offset on: its path and
+// query, with offset changed
+func pageLink(c echo.Context, offset int) Link {
+	u := *c.Request().URL
+	query := u.Query()
+	if offset == 0 {
+		query.Del("offset")
+	} else {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	u.RawQuery = query.Encode()
+	return Link{Href: baseURL(c) + u.RequestURI()}
+}
+
+// ArchivedTasks are as in v2, without links: archived tasks are no longer
+// at /tasks/:id, nor can anything be done with them
+func (TaskPresenterV3) ArchivedTasks(c echo.Context, archived []domain.ArchivedTask) interface{} {
+	return TaskPresenterV2{}.ArchivedTasks(c, archived)
+}
+
+func (TaskPresenterV3) Bulk(c echo.Context, result usecase.BulkResult) interface{} {
+	v1 := toBulkResponse(c, result)
+	resp := BulkResponseV3{Succeeded: v1.Succeeded, Failed: v1.Failed, Results: make([]BulkItemResponseV3, len(v1.Results))}
+	for i, item := range v1.Results {
+		resp.Results[i] = BulkItemResponseV3{Index: item.Index, ID: item.ID, Status: item.Status, Error: item.Error}
+		if item.Task != nil {
+			task := toResponseV3(c, result.Items[i].Task)
+			resp.Results[i].Task = &task
+		}
+	}
+	return resp
+}
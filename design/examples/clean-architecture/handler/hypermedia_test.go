@@ -0,0 +1,278 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/app"
+	"github.com/dong-tran/docs/clean-architecture-example/config"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+// The links of v3 are checked on the whole server, booted in memory as
+// app/app_test.go does, since the base URL they begin with is a setting.
+// Links are followed as a client would, by sending the request they name.
+
+// linkServer is a booted server and the base URL its links begin with
+type linkServer struct {
+	url  string
+	base string
+}
+
+// newLinkServer boots the server with BASE_URL set to base, unless it is
+// empty
+func newLinkServer(t *testing.T, base string) linkServer {
+	t.Helper()
+	env := map[string]string{"JWT_SECRET": strings.Repeat("link-secret-", 3), "BASE_URL": base}
+	cfg, err := config.Load(nil, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.New(cfg, app.Options{Memory: true, Logf: func(string, ...any) {}, AccessLog: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := a.Lifecycle.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(a.Echo)
+	t.Cleanup(func() {
+		s.Close()
+		a.Lifecycle.Stop(ctx)
+	})
+	return linkServer{url: s.URL, base: strings.TrimSuffix(base, "/")}
+}
+
+type linkResponse struct {
+	status int
+	body   []byte
+}
+
+// decode reads the body into v, reporting whether it is valid JSON of v's
+// shape with nothing left over
+func (r linkResponse) decode(v any) bool {
+	decoder := json.NewDecoder(bytes.NewReader(r.body))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v) == nil && !decoder.More()
+}
+
+func (s linkServer) do(t *testing.T, token, method, path, body string) linkResponse {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, s.url+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return linkResponse{status: res.StatusCode, body: data}
+}
+
+// follow sends a request as link says. The link must begin with the base
+// URL, where the server is not really, so it is sent to the server's path
+// after it.
+func (s linkServer) follow(t *testing.T, token string, link handler.Link) linkResponse {
+	t.Helper()
+	if !strings.HasPrefix(link.Href, s.base+"/") {
+		t.Fatalf("link %s does not begin with %s/", link.Href, s.base)
+	}
+	method := link.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return s.do(t, token, method, strings.TrimPrefix(link.Href, s.base), "")
+}
+
+// signUp registers email and returns its token
+func (s linkServer) signUp(t *testing.T, email string) (string, handler.UserResponse) {
+	t.Helper()
+	credentials := fmt.Sprintf(`{"email":%q,"password":"correct horse"}`, email)
+	var user handler.UserResponse
+	json.Unmarshal(s.do(t, "", http.MethodPost, "/api/v3/auth/register", credentials).body, &user)
+	var token handler.TokenResponse
+	json.Unmarshal(s.do(t, "", http.MethodPost, "/api/v3/auth/login", credentials).body, &token)
+	return token.AccessToken, user
+}
+
+func TestPresenterV3OutsideARequest(t *testing.T) {
+	task := &domain.Task{ID: 7, OwnerID: 1, Title: "Write the report", Priority: domain.PriorityHigh}
+	v3 := handler.TaskPresenterV3{}.Task(nil, task).(handler.TaskResponseV3)
+	if v3.Links.Self.Href != "/tasks/7" || v3.Links.Subtasks.Href != "/tasks?parent=7" || v3.Links.Complete != nil || v3.Links.Delete != nil {
+		t.Errorf("links = %+v, want paths to the task and its subtasks only", v3.Links)
+	}
+	encoded, _ := json.Marshal(v3)
+	if !strings.Contains(string(encoded), `"timestamps":{`) || !strings.HasSuffix(string(encoded), `"_links":{"self":{"href":"/tasks/7"},"subtasks":{"href":"/tasks?parent=7"}}}`) {
+		t.Errorf("encoded = %s, want v2's task with _links", encoded)
+	}
+	encoded, _ = json.Marshal(handler.TaskPresenterV3{}.Tasks(nil, nil))
+	if !strings.HasPrefix(string(encoded), `{"items":[],`) {
+		t.Errorf("no tasks = %s, want a page of items [], not null", encoded)
+	}
+}
+
+func TestTaskLinks(t *testing.T) {
+	for _, base := range []string{"https://tasks.example.com/app/", ""} {
+		t.Run(fmt.Sprintf("base URL %q", base), func(t *testing.T) {
+			s := newLinkServer(t, base)
+			ann, _ := s.signUp(t, "ann@example.com")
+			var task handler.TaskResponseV3
+			if res := s.do(t, ann, http.MethodPost, "/api/v3/tasks", `{"title":"Write the report"}`); res.status != http.StatusCreated || !res.decode(&task) {
+				t.Fatalf("POST /api/v3/tasks = %d %s, want 201 and the task with _links", res.status, res.body)
+			}
+			self := fmt.Sprintf("%s/api/v3/tasks/%d", s.base, task.ID)
+			if task.Links.Self != (handler.Link{Href: self}) {
+				t.Errorf("self = %+v, want %s", task.Links.Self, self)
+			}
+			if task.Links.Complete == nil || *task.Links.Complete != (handler.Link{Href: self + "/complete", Method: http.MethodPost}) {
+				t.Errorf("complete = %+v, want POST %s/complete", task.Links.Complete, self)
+			}
+			if task.Links.Delete == nil || *task.Links.Delete != (handler.Link{Href: self, Method: http.MethodDelete}) {
+				t.Fatalf("delete = %+v, want DELETE %s", task.Links.Delete, self)
+			}
+			subtasks := fmt.Sprintf("%s/api/v3/tasks?parent=%d", s.base, task.ID)
+			if task.Links.Subtasks != (handler.Link{Href: subtasks}) {
+				t.Errorf("subtasks = %+v, want %s", task.Links.Subtasks, subtasks)
+			}
+			var none handler.TaskPageResponse
+			if res := s.follow(t, ann, task.Links.Subtasks); res.status != http.StatusOK || !res.decode(&none) || len(none.Items) != 0 {
+				t.Errorf("following subtasks of a task without any = %d %s, want an empty page", res.status, res.body)
+			}
+			// Subtasks are created over GraphQL
+			gql := fmt.Sprintf(`{"query":"mutation { createTask(input: {title: \"Outline\", parentId: \"%d\"}) { id } }"}`, task.ID)
+			if res := s.do(t, ann, http.MethodPost, "/api/v3/graphql", gql); res.status != http.StatusOK || strings.Contains(string(res.body), "errors") {
+				t.Fatalf("creating a subtask = %d %s", res.status, res.body)
+			}
+			var children handler.TaskPageResponse
+			if res := s.follow(t, ann, task.Links.Subtasks); !res.decode(&children) || len(children.Items) != 1 || children.Items[0].Title != "Outline" {
+				t.Errorf("following subtasks = %d %s, want the one subtask", res.status, res.body)
+			}
+
+			var read handler.TaskResponseV3
+			before, _ := json.Marshal(task.Links)
+			ok := s.follow(t, ann, task.Links.Self).decode(&read)
+			after, _ := json.Marshal(read.Links)
+			if !ok || read.ID != task.ID || !bytes.Equal(before, after) {
+				t.Errorf("following self = %+v, want the task with the same links", read)
+			}
+			var completed handler.TaskResponseV3
+			if res := s.follow(t, ann, *task.Links.Complete); res.status != http.StatusOK || !res.decode(&completed) || !completed.Completed {
+				t.Errorf("following complete = %d %s, want the task completed", res.status, res.body)
+			}
+			if completed.Links.Complete != nil || completed.Links.Delete == nil {
+				t.Errorf("a completed task's links = %+v, want delete and no complete", completed.Links)
+			}
+
+			// An assignee may view the task, but only its owner may change it
+			cal, calUser := s.signUp(t, "cal@example.com")
+			var shared handler.TaskResponseV3
+			json.Unmarshal(s.do(t, ann, http.MethodPost, "/api/v3/tasks", `{"title":"Proofread the report"}`).body, &shared)
+			if res := s.do(t, ann, http.MethodPut, fmt.Sprintf("/api/v3/tasks/%d/assignee", shared.ID), fmt.Sprintf(`{"assignee_id":%d}`, calUser.ID)); res.status != http.StatusOK {
+				t.Fatalf("assigning a task = %d %s", res.status, res.body)
+			}
+			var viewed handler.TaskResponseV3
+			if res := s.follow(t, cal, shared.Links.Self); res.status != http.StatusOK || !res.decode(&viewed) ||
+				viewed.Links.Complete != nil || viewed.Links.Delete != nil {
+				t.Errorf("the assignee reading it by its self link = %d %s, want self as its only link", res.status, res.body)
+			}
+
+			if res := s.follow(t, ann, *completed.Links.Delete); res.status != http.StatusNoContent {
+				t.Errorf("following delete = %d, want 204", res.status)
+			}
+			if res := s.follow(t, ann, completed.Links.Self); res.status != http.StatusNotFound {
+				t.Errorf("self after deleting = %d, want 404", res.status)
+			}
+		})
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	s := newLinkServer(t, "https://tasks.example.com/app/")
+	bob, _ := s.signUp(t, "bob@example.com")
+	for i := 1; i <= 5; i++ {
+		s.do(t, bob, http.MethodPost, "/api/v3/tasks", fmt.Sprintf(`{"title":"Task %d","tags":["work"]}`, i))
+	}
+	s.do(t, bob, http.MethodPost, "/api/v3/tasks", `{"title":"Untagged"}`)
+
+	listing := s.base + "/api/v3/tasks?limit=2&tag=work"
+	var page handler.TaskPageResponse
+	res := s.do(t, bob, http.MethodGet, "/api/v3/tasks?tag=work&limit=2", "")
+	if res.status != http.StatusOK || !res.decode(&page) || len(page.Items) != 2 || page.Items[0].Title != "Task 5" ||
+		page.Limit != 2 || page.Offset != 0 {
+		t.Fatalf("the first page = %d %s, want the 2 newest tagged tasks", res.status, res.body)
+	}
+	if page.Links.Self.Href != listing || page.Links.Prev != nil {
+		t.Errorf("self = %s, prev = %+v; want %s and no prev", page.Links.Self.Href, page.Links.Prev, listing)
+	}
+	if want := s.base + "/api/v3/tasks?limit=2&offset=2&tag=work"; page.Links.Next == nil || page.Links.Next.Href != want {
+		t.Fatalf("next = %+v, want %s: the filters kept and the offset moved", page.Links.Next, want)
+	}
+
+	var titles []string
+	pages := 0
+	for link := page.Links.Next; link != nil; link = page.Links.Next {
+		page = handler.TaskPageResponse{}
+		if !s.follow(t, bob, *link).decode(&page) {
+			t.Fatalf("following %s did not give a page", link.Href)
+		}
+		pages++
+		for _, task := range page.Items {
+			titles = append(titles, task.Title)
+		}
+		if pages == 1 && (page.Links.Prev == nil || page.Links.Prev.Href != listing) {
+			t.Errorf("the second page's prev = %+v, want the first", page.Links.Prev)
+		}
+	}
+	if pages != 2 || strings.Join(titles, ",") != "Task 3,Task 2,Task 1" {
+		t.Errorf("following next read %d pages of %v, want the rest, stopping at a page that is not full", pages, titles)
+	}
+
+	page = handler.TaskPageResponse{}
+	if res := s.do(t, bob, http.MethodGet, "/api/v3/tasks", ""); res.status != http.StatusOK || !res.decode(&page) ||
+		len(page.Items) != 6 || page.Limit != 0 || page.Links.Next != nil || page.Links.Prev != nil {
+		t.Errorf("without a limit = %d %s, want one page of every task, without next or prev", res.status, res.body)
+	}
+
+	var problem handler.Problem
+	res = s.do(t, bob, http.MethodGet, "/api/v3/tasks?limit=101", "")
+	json.Unmarshal(res.body, &problem)
+	if res.status != http.StatusBadRequest || problem.Code != domain.ErrInvalidPage.Code {
+		t.Errorf("limit=101 = %d %s, want 400 %s", res.status, problem.Code, domain.ErrInvalidPage.Code)
+	}
+
+	var v1 []handler.TaskResponse
+	if res := s.do(t, bob, http.MethodGet, "/api/v1/tasks?limit=2", ""); res.status != http.StatusOK || !res.decode(&v1) || len(v1) != 2 {
+		t.Errorf("v1 with limit=2 = %d %s, want a bare array of 2, without links", res.status, res.body)
+	}
+}
+
+func TestBaseURLSetting(t *testing.T) {
+	for _, bad := range []string{"tasks.example.com", "ftp://tasks.example.com", "https://tasks.example.com/?v=1"} {
+		_, err := config.Load([]string{"-base-url", bad}, func(string) string { return "" })
+		if err == nil || !strings.Contains(err.Error(), "server.base_url") {
+			t.Errorf("-base-url %q = %v, want it rejected", bad, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// A client that sends POST /tasks and never hears back cannot tell whether
+// the task was created. With an Idempotency-Key header it can simply send
+// the request again: the first request with a key runs and its response is
+// kept, and a retry with the same key and the same body gets that response
+// back, with Idempotent-Replayed: true, instead of creating a second task.
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	MaxIdempotencyKeyLength   = 255
+	DefaultIdempotencyTTL     = 24 * time.Hour
+	DefaultIdempotencyPending = time.Minute
+)
+
+var (
+	ErrInvalidIdempotencyKey = domain.NewError("REQUEST_INVALID_IDEMPOTENCY_KEY", domain.KindInvalid, "Idempotency-Key must be 1 to 255 printable ASCII characters")
+	ErrIdempotencyKeyReused  = domain.NewError("IDEMPOTENCY_KEY_REUSED", domain.KindConflict, "Idempotency-Key was already used for a different request")
+	ErrIdempotencyInProgress = domain.NewError("IDEMPOTENCY_KEY_IN_PROGRESS", domain.KindConflict, "a request with this Idempotency-Key is still in progress")
+)
+
+type IdempotencyOptions struct {
+	// TTL is how long a response is replayed, default 24h
+	TTL time.Duration
+	// Pending is how long a key stays claimed by a request that has not
+	// answered, default 1m. It must outlast any request: a claim left by a
+	// server that died is only freed once it expires.
+	Pending time.Duration
+	Clock   domain.Clock // default domain.SystemClock
+}
+
+// Idempotency replays the responses of requests with an Idempotency-Key
+type Idempotency struct {
+	store domain.IdempotencyStore
+	opts  IdempotencyOptions
+}
+
+func NewIdempotency(store domain.IdempotencyStore, opts IdempotencyOptions) *Idempotency {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultIdempotencyTTL
+	}
+	if opts.Pending <= 0 {
+		opts.Pending = DefaultIdempotencyPending
+	}
+	if opts.Clock == nil {
+		opts.Clock = domain.SystemClock
+	}
+	return &Idempotency{store: store, opts: opts}
+}
+
+// validIdempotencyKey reports whether key is 1 to 255 printable ASCII
+// characters
+func validIdempotencyKey(key string) bool {
+	if len(key) == 0 || len(key) > MaxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies a request by its method, path and body, byte for
+// byte: a retry must send exactly what it sent the first time
+func fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, method+" "+path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Middleware makes the routes it wraps idempotent for requests with the
+// header; requests without it run as usual. It must run after RequireUser,
+// since keys belong to the user who sent them.
+//
+// Only a successful response is kept. A request that fails frees its key,
+// so the retry runs again, as it would without one. A retry with the key
+// but a different body is answered 409 IDEMPOTENCY_KEY_REUSED, and one that
+// comes while the first is still running 409 IDEMPOTENCY_KEY_IN_PROGRESS.
+func (i *Idempotency) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			if !validIdempotencyKey(key) {
+				return ErrInvalidIdempotencyKey
+			}
+			req := c.Request()
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return ErrInvalidBody
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := req.Context()
+			id := domain.IdempotencyKey{OwnerID: actor(c).ID, Key: key}
+			sum := fingerprint(req.Method, req.URL.Path, body)
+			now := i.opts.Clock.Now()
+			record, err := i.store.Begin(ctx, id, sum, now, now.Add(i.opts.Pending))
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				return replay(c, record, sum)
+			}
+
+			// The key is ours. Whatever happens to the request, a panic
+			// included, its claim is settled, even if the client has gone
+			// away meanwhile.
+			settle := context.WithoutCancel(ctx)
+			abandon := func() {
+				if err := i.store.Abandon(settle, id); err != nil {
+					c.Logger().Error(err)
+				}
+			}
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			panicked := true
+			defer func() {
+				if panicked {
+					abandon()
+				}
+			}()
+			err = next(c)
+			panicked = false
+			c.Response().Writer = recorder.ResponseWriter
+
+			res := c.Response()
+			if err != nil || !res.Committed || res.Status >= http.StatusBadRequest {
+				abandon()
+				return err
+			}
+			response := domain.StoredResponse{
+				Status:      res.Status,
+				ContentType: res.Header().Get(echo.HeaderContentType),
+				Body:        recorder.body.Bytes(),
+			}
+			if err := i.store.Finish(settle, id, response, i.opts.Clock.Now().Add(i.opts.TTL)); err != nil {
+				// The client has its answer; a retry will find the claim
+				// until it expires
+				c.Logger().Error(err)
+			}
+			return nil
+		}
+	}
+}
+
+// replay answers a request whose key is already held by record
+func replay(c echo.Context, record *domain.IdempotencyRecord, sum string) error {
+	switch {
+	case record.Fingerprint != sum:
+		return ErrIdempotencyKeyReused
+	case record.Response == nil:
+		return ErrIdempotencyInProgress
+	}
+	c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+	return c.Blob(record.Response.Status, record.Response.ContentType, record.Response.Body)
+}
+
+// responseRecorder keeps a copy of the body written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,242 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	alice = &domain.User{ID: 1, Role: domain.RoleUser}
+	bob   = &domain.User{ID: 2, Role: domain.RoleUser}
+)
+
+// idempotencyStores runs test against the in-memory and SQLite stores
+func idempotencyStores(t *testing.T, test func(t *testing.T, store domain.IdempotencyStore)) {
+	t.Run("memory", func(t *testing.T) { test(t, repository.NewMemoryIdempotencyRepository()) })
+	t.Run("sqlite", func(t *testing.T) { test(t, repository.NewIdempotencyRepository(openSQLite(t))) })
+}
+
+// keyedAPI is POST /tasks with idempotency, on its own task repository
+type keyedAPI struct {
+	e     *echo.Echo
+	tasks domain.TaskRepository
+	clock *clocktest.Clock
+}
+
+// slowTasks holds every create until hold is closed, if it is set, and
+// tells entered when one is waiting
+type slowTasks struct {
+	domain.TaskRepository
+	entered chan struct{}
+	hold    chan struct{}
+}
+
+func (r *slowTasks) Create(ctx context.Context, task *domain.Task) error {
+	if r.hold != nil {
+		r.entered <- struct{}{}
+		<-r.hold
+	}
+	return r.TaskRepository.Create(ctx, task)
+}
+
+func newKeyedAPI(store domain.IdempotencyStore, tasks *slowTasks) keyedAPI {
+	if tasks == nil {
+		tasks = &slowTasks{TaskRepository: repository.NewMemoryTaskRepository()}
+	}
+	clk := clocktest.New(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	idempotency := handler.NewIdempotency(store, handler.IdempotencyOptions{
+		TTL:     time.Hour,
+		Pending: time.Minute,
+		Clock:   clk,
+	})
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(tasks))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	for _, user := range []*domain.User{alice, bob} {
+		g := e.Group(fmt.Sprintf("/as/%d", user.ID), handler.AsUser(user))
+		g.POST("/tasks", idempotency.Middleware()(h.CreateTask))
+	}
+	return keyedAPI{e: e, tasks: tasks, clock: clk}
+}
+
+type keyedResponse struct {
+	status   int
+	code     domain.Code
+	task     handler.TaskResponse
+	replayed bool
+	body     string
+}
+
+// create sends POST /tasks as user with key, if any
+func (a keyedAPI) create(user *domain.User, key, body string) keyedResponse {
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/as/%d/tasks", user.ID), bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(handler.HeaderIdempotencyKey, key)
+	}
+	rec := httptest.NewRecorder()
+	a.e.ServeHTTP(rec, req)
+	r := keyedResponse{status: rec.Code, replayed: rec.Header().Get(handler.HeaderIdempotentReplayed) == "true", body: rec.Body.String()}
+	if rec.Code >= 400 {
+		var problem handler.Problem
+		json.Unmarshal(rec.Body.Bytes(), &problem)
+		r.code = problem.Code
+	} else {
+		json.Unmarshal(rec.Body.Bytes(), &r.task)
+	}
+	return r
+}
+
+// count is how many tasks user has
+func (a keyedAPI) count(t *testing.T, user *domain.User) int {
+	t.Helper()
+	tasks, err := a.tasks.List(context.Background(), domain.ListTasksQuery{OwnerID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(tasks)
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	idempotencyStores(t, func(t *testing.T, store domain.IdempotencyStore) {
+		a := newKeyedAPI(store, nil)
+		first := a.create(alice, "create-report", `{"title":"Write report"}`)
+		if first.status != http.StatusCreated || first.replayed {
+			t.Fatalf("the first request with a key = %d, replayed %t; want the task created", first.status, first.replayed)
+		}
+		retry := a.create(alice, "create-report", `{"title":"Write report"}`)
+		if retry.status != http.StatusCreated || !retry.replayed || retry.body != first.body {
+			t.Errorf("a retry with the same key and body = %d %s, replayed %t; want the same 201 and body, marked replayed", retry.status, retry.body, retry.replayed)
+		}
+		if n := a.count(t, alice); n != 1 {
+			t.Errorf("the retry left %d tasks, want 1", n)
+		}
+
+		other := a.create(alice, "create-report", `{"title":"Write another report"}`)
+		if other.status != http.StatusConflict || other.code != handler.ErrIdempotencyKeyReused.Code {
+			t.Errorf("the key with a different body = %d %s, want 409 %s", other.status, other.code, handler.ErrIdempotencyKeyReused.Code)
+		}
+		if n := a.count(t, alice); n != 1 {
+			t.Errorf("the reused key left %d tasks, want 1", n)
+		}
+
+		if plain := a.create(alice, "", `{"title":"Write report"}`); plain.status != http.StatusCreated || plain.task.ID == first.task.ID {
+			t.Errorf("without a key = %d, task %d; want a new task", plain.status, plain.task.ID)
+		}
+		if bobs := a.create(bob, "create-report", `{"title":"Write report"}`); bobs.status != http.StatusCreated || bobs.replayed || bobs.task.OwnerID != bob.ID {
+			t.Errorf("Bob's create-report = %d, replayed %t; want his own task: keys are per user", bobs.status, bobs.replayed)
+		}
+		invalid := a.create(alice, "key\twith a tab", `{"title":"Write report"}`)
+		if invalid.status != http.StatusBadRequest || invalid.code != handler.ErrInvalidIdempotencyKey.Code {
+			t.Errorf("a key that is not printable ASCII = %d %s, want 400 %s", invalid.status, invalid.code, handler.ErrInvalidIdempotencyKey.Code)
+		}
+	})
+}
+
+func TestFailedRequestsFreeTheirKey(t *testing.T) {
+	idempotencyStores(t, func(t *testing.T, store domain.IdempotencyStore) {
+		a := newKeyedAPI(store, nil)
+		if failed := a.create(alice, "retry-me", `{"title":""}`); failed.status != http.StatusBadRequest || failed.code != domain.ErrEmptyTitle.Code {
+			t.Errorf("a request that fails = %d %s, want it answered as usual", failed.status, failed.code)
+		}
+		if again := a.create(alice, "retry-me", `{"title":""}`); again.status != http.StatusBadRequest || again.replayed {
+			t.Errorf("its retry = %d, replayed %t; want it run again", again.status, again.replayed)
+		}
+		if fixed := a.create(alice, "retry-me", `{"title":"Now with a title"}`); fixed.status != http.StatusCreated {
+			t.Errorf("a corrected request = %d %s, want the key free for it", fixed.status, fixed.code)
+		}
+	})
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	idempotencyStores(t, func(t *testing.T, store domain.IdempotencyStore) {
+		a := newKeyedAPI(store, nil)
+		first := a.create(alice, "daily", `{"title":"Stand-up"}`)
+		a.clock.Advance(59 * time.Minute)
+		if retry := a.create(alice, "daily", `{"title":"Stand-up"}`); !retry.replayed || retry.task.ID != first.task.ID {
+			t.Errorf("within the TTL = task %d, replayed %t; want task %d replayed", retry.task.ID, retry.replayed, first.task.ID)
+		}
+		a.clock.Advance(2 * time.Minute)
+		if later := a.create(alice, "daily", `{"title":"Stand-up"}`); later.status != http.StatusCreated || later.replayed || later.task.ID == first.task.ID {
+			t.Errorf("after the TTL = %d, replayed %t; want the request run again", later.status, later.replayed)
+		}
+		if n := a.count(t, alice); n != 2 {
+			t.Errorf("%d tasks, want 2", n)
+		}
+	})
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	idempotencyStores(t, func(t *testing.T, store domain.IdempotencyStore) {
+		tasks := &slowTasks{
+			TaskRepository: repository.NewMemoryTaskRepository(),
+			entered:        make(chan struct{}),
+			hold:           make(chan struct{}),
+		}
+		a := newKeyedAPI(store, tasks)
+		done := make(chan keyedResponse)
+		go func() { done <- a.create(alice, "slow", `{"title":"Slow"}`) }()
+		<-tasks.entered
+
+		busy := a.create(alice, "slow", `{"title":"Slow"}`)
+		if busy.status != http.StatusConflict || busy.code != handler.ErrIdempotencyInProgress.Code {
+			t.Errorf("a retry while the first is running = %d %s, want 409 %s", busy.status, busy.code, handler.ErrIdempotencyInProgress.Code)
+		}
+		close(tasks.hold)
+		first := <-done
+		if retry := a.create(alice, "slow", `{"title":"Slow"}`); first.status != http.StatusCreated || !retry.replayed || retry.task.ID != first.task.ID {
+			t.Errorf("once it has answered, the retry = task %d, replayed %t; want task %d replayed", retry.task.ID, retry.replayed, first.task.ID)
+		}
+	})
+}
+
+// TestCrashedClaimExpires claims a key directly, as a server that died
+// mid-request would have left it, and retries once the claim expires
+func TestCrashedClaimExpires(t *testing.T) {
+	idempotencyStores(t, func(t *testing.T, store domain.IdempotencyStore) {
+		ctx := context.Background()
+		a := newKeyedAPI(store, nil)
+		now := a.clock.Now()
+		body := `{"title":"Interrupted"}`
+
+		answered := domain.IdempotencyKey{OwnerID: alice.ID, Key: "answered"}
+		first := a.create(alice, answered.Key, body)
+		if err := store.Abandon(ctx, answered); err != nil {
+			t.Fatal(err)
+		}
+		if retry := a.create(alice, answered.Key, body); first.status != http.StatusCreated || !retry.replayed {
+			t.Errorf("after Abandon, a key with a response = %d, replayed %t; want it kept", retry.status, retry.replayed)
+		}
+		// Begin on a held key returns its record, and so the fingerprint the
+		// middleware computed for body
+		record, err := store.Begin(ctx, answered, "", now, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crashed := domain.IdempotencyKey{OwnerID: alice.ID, Key: "crashed"}
+		if claimed, err := store.Begin(ctx, crashed, record.Fingerprint, now, now.Add(time.Minute)); err != nil || claimed != nil {
+			t.Fatalf("claiming a fresh key = %+v, %v", claimed, err)
+		}
+		if stuck := a.create(alice, crashed.Key, body); stuck.code != handler.ErrIdempotencyInProgress.Code {
+			t.Errorf("until the claim expires, a retry = %d %s, want %s", stuck.status, stuck.code, handler.ErrIdempotencyInProgress.Code)
+		}
+		a.clock.Advance(time.Minute)
+		if freed := a.create(alice, crashed.Key, body); freed.status != http.StatusCreated || freed.replayed {
+			t.Errorf("once Pending has passed, a retry = %d, replayed %t; want it run", freed.status, freed.replayed)
+		}
+	})
+}
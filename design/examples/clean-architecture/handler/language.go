@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/i18n"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+)
+
+// Error messages are answered in the language the request's Accept-Language
+// header asks for, among the ones the translator has, which Localize puts in
+// its context. Only the message changes: codes are the same in every
+// language, so clients that branch on them are unaffected.
+
+const (
+	HeaderAcceptLanguage  = "Accept-Language"
+	HeaderContentLanguage = "Content-Language"
+)
+
+type localizerKey struct{}
+
+// localizer is the language of a request, and the translator into it
+type localizer struct {
+	translator *i18n.Translator
+	language   language.Tag
+}
+
+// Localize negotiates the language of each request with translator. Every
+// response varies by Accept-Language, since any of them can be an error.
+func Localize(translator *i18n.Translator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			l := localizer{translator: translator, language: translator.Negotiate(req.Header.Get(HeaderAcceptLanguage))}
+			c.Response().Header().Add(echo.HeaderVary, HeaderAcceptLanguage)
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), localizerKey{}, l)))
+			return next(c)
+		}
+	}
+}
+
+// messageOf returns coded's message in the language of ctx, in English if
+// the request was not localized
+func messageOf(ctx context.Context, coded *domain.Error) string {
+	if l, ok := ctx.Value(localizerKey{}).(localizer); ok {
+		return l.translator.Message(l.language, coded)
+	}
+	return coded.Message
+}
+
+// languageOf returns the language of ctx, and false if the request was not
+// localized
+func languageOf(ctx context.Context) (language.Tag, bool) {
+	l, ok := ctx.Value(localizerKey{}).(localizer)
+	return l.language, ok
+}
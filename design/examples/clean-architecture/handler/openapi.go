@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// The OpenAPI 3 document of the API, built from the RouteTable. Request and
+// response bodies are described by reflecting on the types in each Route,
+// with their json tags, so a field added to TaskResponse is documented the
+// moment it is served. Errors are problem documents (problem.go), grouped
+// by status, with the codes each status can carry in x-error-codes.
+
+const openAPIVersion = "3.0.3"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	Tags       []map[string]string `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps a lower-case method to its operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+	// ErrorCodes are the codes of the problems answered with this status
+	ErrorCodes []domain.Code `json:"x-error-codes,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+const bearerScheme = "bearer"
+
+// commonErrors are the errors any route can answer with
+var commonErrors = []*domain.Error{ErrInternal, ErrTimeout, ErrCanceled}
+
+// OpenAPIPath turns an echo path into an OpenAPI one: /tasks/:id is
+// /tasks/{id}
+func OpenAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// OpenAPI returns the document of the routes registered so far
+func (t *RouteTable) OpenAPI(info Info) *Document {
+	schemas := &schemaSet{components: map[string]*Schema{}}
+	doc := &Document{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas:         schemas.components,
+			SecuritySchemes: map[string]SecurityScheme{bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"}},
+		},
+	}
+	problem := schemas.of(reflect.TypeOf(Problem{}), true)
+
+	seenTags := map[string]bool{}
+	for _, r := range t.routes {
+		if !seenTags[r.tag] {
+			seenTags[r.tag] = true
+			doc.Tags = append(doc.Tags, map[string]string{"name": r.tag})
+		}
+
+		op := &Operation{
+			OperationID: r.operationID(),
+			Summary:     r.Summary,
+			Tags:        []string{r.tag},
+			Responses:   map[string]*Response{},
+		}
+		if r.access != Public {
+			op.Security = []map[string][]string{{bearerScheme: {}}}
+		}
+		for _, segment := range strings.Split(r.fullPath, "/") {
+			if strings.HasPrefix(segment, ":") {
+				// Every path parameter is an ID, but an attachment's name
+				schema := &Schema{Type: "integer", Format: "int64"}
+				if segment == ":name" {
+					schema = &Schema{Type: "string"}
+				}
+				op.Parameters = append(op.Parameters, Parameter{
+					Name: segment[1:], In: "path", Required: true, Schema: schema,
+				})
+			}
+		}
+		for _, p := range r.Query {
+			schema := &Schema{Type: p.Type}
+			if i := strings.IndexByte(p.Type, ':'); i >= 0 {
+				schema = &Schema{Type: p.Type[:i], Format: p.Type[i+1:]}
+			}
+			if p.Repeated {
+				schema = &Schema{Type: "array", Items: schema}
+			}
+			op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: "query", Description: p.Description, Schema: schema})
+		}
+		for _, p := range r.Headers {
+			op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: "header", Description: p.Description, Schema: &Schema{Type: p.Type}})
+		}
+		if r.Body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  mediaTypes(r.BodyTypes, schemas.of(reflect.TypeOf(r.Body), false)),
+			}
+		}
+
+		success := &Response{Description: http.StatusText(r.Status)}
+		if r.Result != nil {
+			success.Content = mediaTypes(r.ContentTypes, schemas.of(reflect.TypeOf(r.Result), true))
+		}
+		op.Responses[strconv.Itoa(r.Status)] = success
+		if r.ETag {
+			success.Headers = map[string]Header{HeaderETag: {Description: "The task's version, with the API version and, in v3, whether the caller may change it: for If-Match and If-None-Match", Schema: &Schema{Type: "string"}}}
+			if r.Method == http.MethodGet {
+				op.Responses[strconv.Itoa(http.StatusNotModified)] = &Response{Description: http.StatusText(http.StatusNotModified)}
+			}
+		}
+
+		errs := append(append(append([]*domain.Error{}, r.Errors...), r.access.errors()...), commonErrors...)
+		for _, err := range errs {
+			status := strconv.Itoa(statusOf(err.Kind))
+			resp, ok := op.Responses[status]
+			if !ok {
+				resp = &Response{
+					Description: http.StatusText(statusOf(err.Kind)),
+					Content:     map[string]MediaType{ProblemContentType: {Schema: problem}},
+				}
+				op.Responses[status] = resp
+			}
+			if !containsCode(resp.ErrorCodes, err.Code) {
+				resp.ErrorCodes = append(resp.ErrorCodes, err.Code)
+			}
+		}
+		for _, resp := range op.Responses {
+			sort.Slice(resp.ErrorCodes, func(i, j int) bool { return resp.ErrorCodes[i] < resp.ErrorCodes[j] })
+		}
+
+		path := OpenAPIPath(r.fullPath)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+	return doc
+}
+
+// mediaTypes is the content of a body of schema in each of types, or as
+// JSON if there are none
+func mediaTypes(types []string, schema *Schema) map[string]MediaType {
+	if len(types) == 0 {
+		types = []string{echo.MIMEApplicationJSON}
+	}
+	content := make(map[string]MediaType, len(types))
+	for _, t := range types {
+		content[t] = MediaType{Schema: schema}
+	}
+	return content
+}
+
+func containsCode(codes []domain.Code, code domain.Code) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaSet describes Go types as schemas. Named structs become components,
+// referred to by $ref.
+type schemaSet struct {
+	components map[string]*Schema
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// of returns the schema of t. A response's fields are all present unless
+// tagged omitempty, so they are required; a request's fields are all
+// optional, as far as decoding goes, and the use cases say which are not.
+func (s *schemaSet) of(t reflect.Type, response bool) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == bytesType:
+		return &Schema{Type: "string", Format: "binary"}
+	case t.Kind() == reflect.Pointer:
+		return s.of(t.Elem(), response)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.of(t.Elem(), response)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem(), response)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t, response)
+		}
+		// A request type is documented once; a type a response returns is
+		// documented as returned, even if a request also sends it
+		if _, ok := s.components[t.Name()]; !ok || response {
+			s.components[t.Name()] = s.object(t, response)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return &Schema{} // interface{}: any value
+}
+
+func (s *schemaSet) object(t reflect.Type, response bool) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		// encoding/json flattens an embedded struct without a name
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := s.object(field.Type, response)
+			for name, property := range embedded.Properties {
+				schema.Properties[name] = property
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = s.of(field.Type, response)
+		if response && !strings.Contains(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// swaggerUI is the page at /docs. It loads Swagger UI from a CDN, so the
+// server ships no assets of its own.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Tasks API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = () => { window.ui = SwaggerUIBundle({ url: "/docs/openapi.json", dom_id: "#swagger-ui" }) }
+  </script>
+</body>
+</html>
+`
+
+// RegisterDocs serves the document of t's routes at GET /docs/openapi.json,
+// and Swagger UI on it at GET /docs. Call it after the other routes: the
+// document is built once, and documents these two as well.
+func RegisterDocs(t *RouteTable, info Info) *Document {
+	var body []byte
+	docs := t.Group("/docs", "docs", Public)
+	docs.Add(
+		Route{
+			Method: http.MethodGet, Path: "", ID: "docs", Summary: "Swagger UI",
+			Handler: func(c echo.Context) error { return c.HTML(http.StatusOK, swaggerUI) },
+			Status:  http.StatusOK, Result: "", ContentTypes: []string{echo.MIMETextHTML},
+		},
+		Route{
+			Method: http.MethodGet, Path: "/openapi.json", ID: "openAPI", Summary: "This document",
+			Handler: func(c echo.Context) error { return c.JSONBlob(http.StatusOK, body) },
+			Status:  http.StatusOK, Result: map[string]interface{}{},
+		},
+	)
+
+	doc := t.OpenAPI(info)
+	body, _ = json.Marshal(doc) // only maps, slices and strings: it cannot fail
+	return doc
+}
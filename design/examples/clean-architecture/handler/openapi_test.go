@@ -0,0 +1,532 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The OpenAPI document must list exactly the routes echo serves, be well
+// formed, and describe real responses: every operation is called, errors
+// included, and each response is validated against the schema and codes the
+// document gives its status.
+
+var docInfo = handler.Info{Title: "Tasks API", Version: "2.0.0"}
+
+// docServer wires the routes the way main.go does, and returns the document
+func docServer(t *testing.T, fastJSON bool) (*echo.Echo, *handler.Document, *usecase.AuthUseCase, domain.UserRepository) {
+	t.Helper()
+	users := repository.NewMemoryUserRepository()
+	tokens, err := infrastructure.NewJWTTokens([]byte(secret), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := usecase.NewAuthUseCase(users, infrastructure.BcryptHasher{Cost: bcrypt.MinCost}, tokens)
+	tasks := repository.NewMemoryTaskRepository()
+	taskUseCase := usecase.NewTaskUseCase(tasks)
+	userUseCase := usecase.NewUserUseCase(users)
+	// Never started, so the read model stays empty: the responses only need
+	// their shape
+	queries := usecase.NewTaskQueryService(repository.NewMemoryTaskReadModel(nil), tasks, nil, nil)
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	routes := handler.NewRouteTable(e)
+	handler.RegisterRoutes(routes, handler.Handlers{
+		Auth:         handler.NewAuthHandler(auth),
+		Tasks:        handler.NewTaskHandler(taskUseCase),
+		Users:        handler.NewUserHandler(userUseCase),
+		GraphQL:      handler.NewGraphQLHandler(taskUseCase, userUseCase),
+		Authenticate: handler.RequireUser(auth),
+		FastJSON:     fastJSON,
+		Idempotency:  handler.NewIdempotency(repository.NewMemoryIdempotencyRepository(), handler.IdempotencyOptions{}),
+		Queries:      handler.NewTaskQueryHandler(queries),
+		Attachments:  handler.NewAttachmentHandler(usecase.NewAttachmentUseCase(taskUseCase, repository.NewMemoryAttachmentStorage())),
+		Assignments:  handler.NewAssignmentHandler(usecase.NewAssignmentUseCase(taskUseCase, users)),
+		Archive:      handler.NewTaskArchiveHandler(usecase.NewTaskArchiver(tasks, nil, usecase.ArchiverOptions{})),
+	})
+	return e, handler.RegisterDocs(routes, docInfo), auth, users
+}
+
+// docOperations returns "METHOD /path" for every operation in doc
+func docOperations(doc *handler.Document) []string {
+	var ops []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// servedRoutes returns "METHOD /path" for every route echo serves, in
+// OpenAPI syntax. Groups with middleware add catch-all routes so that their
+// middleware runs for unknown paths too; those are not endpoints.
+func servedRoutes(e *echo.Echo) []string {
+	var routes []string
+	for _, r := range e.Routes() {
+		if r.Method == echo.RouteNotFound {
+			continue
+		}
+		routes = append(routes, r.Method+" "+handler.OpenAPIPath(r.Path))
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// schemaRefs returns every $ref in a JSON value
+func schemaRefs(v interface{}) []string {
+	var out []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if ref, ok := child.(string); ok && k == "$ref" {
+				out = append(out, ref)
+			}
+			out = append(out, schemaRefs(child)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			out = append(out, schemaRefs(child)...)
+		}
+	}
+	return out
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	e, doc, _, _ := docServer(t, false)
+	ops, routes := docOperations(doc), servedRoutes(e)
+	if !reflect.DeepEqual(ops, routes) {
+		t.Errorf("the document's operations are not the routes echo serves:\ndocument: %v\nserved:   %v", ops, routes)
+	}
+
+	ids := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			ids[op.OperationID] = true
+			declared := map[string]bool{}
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					declared[p.Name] = true
+				}
+			}
+			for _, segment := range strings.Split(path, "/") {
+				if strings.HasPrefix(segment, "{") && !declared[strings.Trim(segment, "{}")] {
+					t.Errorf("%s %s does not declare its parameter %s", method, path, segment)
+				}
+			}
+			unversioned := path
+			for _, v := range handler.APIVersions {
+				unversioned = strings.TrimPrefix(unversioned, v.Prefix())
+			}
+			protected := strings.HasPrefix(unversioned, "/tasks") || strings.HasPrefix(unversioned, "/admin") || unversioned == "/graphql"
+			if protected != (len(op.Security) == 1) || protected && op.Responses["401"] == nil {
+				t.Errorf("%s %s: security %v, want a bearer token and 401 exactly for the routes behind one", method, path, op.Security)
+			}
+			if op.Responses["500"] == nil || len(op.Responses["500"].ErrorCodes) == 0 {
+				t.Errorf("%s %s does not document its 500 problem", method, path)
+			}
+		}
+	}
+	if len(ids) != len(ops) {
+		t.Errorf("%d operation IDs for %d operations, want them unique", len(ids), len(ops))
+	}
+	versioned := 0
+	for _, op := range ops {
+		for _, v := range handler.APIVersions {
+			if strings.HasPrefix(op, "POST "+v.Prefix()+"/tasks") {
+				versioned++
+			}
+		}
+	}
+	// POST /tasks, /tasks/bulk, /tasks/bulk/get, /tasks/bulk/complete,
+	// /tasks/bulk/delete and /tasks/:id/complete, in each
+	if versioned != 6*len(handler.APIVersions) || doc.Paths["/tasks"] != nil {
+		t.Errorf("%d versioned POSTs under /tasks, want 6 in each version and no unversioned paths", versioned)
+	}
+
+	var raw interface{}
+	body, _ := json.Marshal(doc)
+	json.Unmarshal(body, &raw)
+	for _, ref := range schemaRefs(raw) {
+		if doc.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")] == nil {
+			t.Errorf("%s does not resolve", ref)
+		}
+	}
+	task := doc.Components.Schemas["TaskResponse"]
+	if task == nil || task.Properties["version"] == nil || task.Properties["due_at"] == nil ||
+		len(task.Required) != len(task.Properties)-1 || slices.Contains(task.Required, "due_at") {
+		t.Errorf("TaskResponse = %+v, want every field of the Go type, all required but due_at", task)
+	}
+	v2 := doc.Components.Schemas["TaskResponseV2"]
+	if v2 == nil || v2.Properties["created_at"] != nil || v2.Properties["timestamps"] == nil ||
+		v2.Properties["timestamps"].Ref != "#/components/schemas/TaskTimestamps" {
+		t.Errorf("TaskResponseV2 = %+v, want its times in timestamps", v2)
+	}
+	get := func(path string) *handler.Operation { return doc.Paths[path]["get"] }
+	if get("/api/v1/tasks/{id}").Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/TaskResponse" ||
+		get("/api/v2/tasks/{id}").Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/TaskResponseV2" ||
+		get("/api/v2/tasks/{id}").OperationID != "getTaskV2" {
+		t.Error("each version's operations should return its own task, under an operationId ending in the version")
+	}
+	create := doc.Components.Schemas["CreateTaskRequest"]
+	if create == nil || create.Properties["tags"].Type != "array" || len(create.Required) != 0 {
+		t.Errorf("CreateTaskRequest = %+v, want the request body described, its fields optional", create)
+	}
+}
+
+// validateSchema returns how v fails to match schema, as paths into v
+func validateSchema(doc *handler.Document, schema *handler.Schema, v interface{}, at string) []string {
+	if schema.Ref != "" {
+		return validateSchema(doc, doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")], v, at)
+	}
+	fail := func(format string, args ...interface{}) []string {
+		return []string{at + ": " + fmt.Sprintf(format, args...)}
+	}
+	switch schema.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("not an object")
+		}
+		var problems []string
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, at+": missing "+name)
+			}
+		}
+		for name, value := range obj {
+			property := schema.Properties[name]
+			if property == nil {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				problems = append(problems, at+": undocumented "+name)
+				continue
+			}
+			problems = append(problems, validateSchema(doc, property, value, at+"."+name)...)
+		}
+		return problems
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fail("not an array")
+		}
+		var problems []string
+		for i, item := range arr {
+			problems = append(problems, validateSchema(doc, schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fail("not a string")
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fail("not a date-time")
+			}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			return fail("not an integer")
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fail("not a number")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("not a boolean")
+		}
+	}
+	return nil
+}
+
+// docClient calls operations and checks their responses against the
+// document
+type docClient struct {
+	e *echo.Echo
+	// prefix is the version's, before every route and path
+	prefix string
+	doc    *handler.Document
+	token  string
+	// ctx is the requests' context, if not a background one
+	ctx context.Context
+	// contentType is the requests' Content-Type, if not JSON
+	contentType string
+	// header holds more request headers, if any
+	header http.Header
+	// exercised records the operations that answered with their success status
+	exercised map[string]bool
+}
+
+// call sends a request to route, the echo path the concrete path matches,
+// and checks the response against the document: its status is one the
+// operation documents, its body matches that status's schema, and a
+// problem's code is listed for it.
+func (cl docClient) call(t *testing.T, method, route, path, body string, want int) *httptest.ResponseRecorder {
+	t.Helper()
+	route, path = cl.prefix+route, cl.prefix+path
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if cl.contentType != "" {
+		req.Header.Set(echo.HeaderContentType, cl.contentType)
+	}
+	if cl.ctx != nil {
+		req = req.WithContext(cl.ctx)
+	}
+	if cl.token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+cl.token)
+	}
+	for name, values := range cl.header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	cl.e.ServeHTTP(rec, req)
+
+	what := fmt.Sprintf("%s %s = %d", method, path, rec.Code)
+	op := cl.doc.Paths[handler.OpenAPIPath(route)][strings.ToLower(method)]
+	if op == nil {
+		t.Errorf("%s: no such operation", what)
+		return rec
+	}
+	resp := op.Responses[fmt.Sprint(rec.Code)]
+	if rec.Code != want || resp == nil {
+		documented := make([]string, 0, len(op.Responses))
+		for status := range op.Responses {
+			documented = append(documented, status)
+		}
+		sort.Strings(documented)
+		t.Errorf("%s, want %d; documented %v", what, want, documented)
+		return rec
+	}
+
+	var problems []string
+	for name := range resp.Headers {
+		if rec.Header().Get(name) == "" {
+			problems = append(problems, "no "+name+" header")
+		}
+	}
+	if len(resp.Content) > 0 {
+		contentType := strings.TrimSuffix(rec.Header().Get(echo.HeaderContentType), "; charset=UTF-8")
+		media, ok := resp.Content[contentType]
+		if !ok {
+			t.Errorf("%s: %s is not documented", what, contentType)
+			return rec
+		}
+		var v interface{} = rec.Body.String()
+		if strings.HasSuffix(contentType, "json") {
+			if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+				problems = append(problems, "not JSON")
+			}
+		}
+		problems = append(problems, validateSchema(cl.doc, media.Schema, v, "body")...)
+		if contentType == handler.ProblemContentType {
+			var problem handler.Problem
+			json.Unmarshal(rec.Body.Bytes(), &problem)
+			if !slices.Contains(resp.ErrorCodes, problem.Code) {
+				problems = append(problems, string(problem.Code)+" not in x-error-codes")
+			}
+		}
+	} else if rec.Body.Len() > 0 {
+		problems = append(problems, "undocumented body")
+	}
+	if rec.Code < 400 && len(problems) == 0 {
+		cl.exercised[op.OperationID] = true
+	}
+	if len(problems) > 0 {
+		t.Errorf("%s: %s", what, strings.Join(problems, "; "))
+	}
+	return rec
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	for _, fastJSON := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fast JSON %t", fastJSON), func(t *testing.T) {
+			exercised := map[string]bool{}
+			var doc *handler.Document
+			for _, v := range handler.APIVersions {
+				t.Run(v.Prefix(), func(t *testing.T) {
+					doc = callEveryOperation(t, fastJSON, v, exercised)
+				})
+			}
+
+			var missed []string
+			for _, op := range docOperations(doc) {
+				method, path, _ := strings.Cut(op, " ")
+				if id := doc.Paths[path][strings.ToLower(method)].OperationID; !exercised[id] {
+					missed = append(missed, id)
+				}
+			}
+			if len(missed) > 0 {
+				t.Errorf("%v never answered with their documented success", missed)
+			}
+		})
+	}
+}
+
+// callEveryOperation calls every operation of v, and the docs, on a server
+// of its own, recording the operations that succeeded in exercised
+func callEveryOperation(t *testing.T, fastJSON bool, v handler.APIVersion, exercised map[string]bool) *handler.Document {
+	ctx := context.Background()
+	e, doc, auth, users := docServer(t, fastJSON)
+	anonymous := docClient{e: e, doc: doc, prefix: v.Prefix(), exercised: exercised}
+
+	anonymous.call(t, http.MethodPost, "/auth/register", "/auth/register", `{"email":"user@example.com","password":"correct horse"}`, 201)
+	anonymous.call(t, http.MethodPost, "/auth/register", "/auth/register", `{"email":"user@example.com","password":"correct horse"}`, 409)
+	anonymous.call(t, http.MethodPost, "/auth/register", "/auth/register", `{"email":"nope","password":"correct horse"}`, 400)
+	anonymous.call(t, http.MethodPost, "/auth/login", "/auth/login", `{"email":"user@example.com","password":"wrong"}`, 401)
+	rec := anonymous.call(t, http.MethodPost, "/auth/login", "/auth/login", `{"email":"user@example.com","password":"correct horse"}`, 200)
+	var session handler.TokenResponse
+	json.Unmarshal(rec.Body.Bytes(), &session)
+	asUser := anonymous
+	asUser.token = session.AccessToken
+
+	admin, err := auth.Register(ctx, "admin@example.com", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetRole(ctx, admin.ID, domain.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	adminSession, err := auth.Login(ctx, "admin@example.com", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asAdmin := anonymous
+	asAdmin.token = adminSession.Token
+
+	anonymous.call(t, http.MethodGet, "/tasks", "/tasks", "", 401)
+	rec = asUser.call(t, http.MethodPost, "/tasks", "/tasks", `{"title":"Write report","priority":"high","tags":["work"]}`, 201)
+	var task handler.TaskResponse
+	json.Unmarshal(rec.Body.Bytes(), &task)
+	one := fmt.Sprintf("/tasks/%d", task.ID)
+	asUser.call(t, http.MethodPost, "/tasks", "/tasks", `{"title":""}`, 400)
+	asUser.call(t, http.MethodPost, "/tasks", "/tasks", `{"title":`, 400)
+	asUser.call(t, http.MethodGet, "/tasks/:id", one, "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/:id", "/tasks/x", "", 400)
+	asUser.call(t, http.MethodGet, "/tasks/:id", "/tasks/999", "", 404)
+	asUser.call(t, http.MethodGet, "/tasks", "/tasks?completed=false&tag=work&q=REPORT&created_from=2024-01-01", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks", "/tasks?completed=maybe", "", 400)
+	asUser.call(t, http.MethodGet, "/tasks", "/tasks?limit=1&offset=1", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks", "/tasks?limit=101", "", 400)
+	asUser.call(t, http.MethodGet, "/tasks", fmt.Sprintf("/tasks?owner=%d", admin.ID), "", 403)
+	asUser.call(t, http.MethodPut, "/tasks/:id", one, `{"title":"Write the report","version":1}`, 200)
+	asUser.call(t, http.MethodPut, "/tasks/:id", one, `{"title":"Stale","version":1}`, 409)
+	asAdmin.call(t, http.MethodPut, "/tasks/:id", one, `{"title":"Admin's edit"}`, 403)
+	// The ETag names the API version too, so ask this one for it
+	etag := asUser.call(t, http.MethodGet, "/tasks/:id", one, "", 200).Header().Get(handler.HeaderETag)
+	conditional := asUser
+	conditional.header = http.Header{handler.HeaderIfNoneMatch: {etag}, handler.HeaderIfMatch: {`"1"`}}
+	conditional.call(t, http.MethodGet, "/tasks/:id", one, "", 304)
+	conditional.call(t, http.MethodPut, "/tasks/:id", one, `{"title":"Stale"}`, 412)
+	asAdmin.call(t, http.MethodPost, "/tasks/:id/complete", one+"/complete", "", 403)
+	asUser.call(t, http.MethodPost, "/tasks/:id/complete", one+"/complete", "", 200)
+	asUser.call(t, http.MethodPost, "/tasks/:id/complete", "/tasks/999/complete", "", 404)
+	asUser.call(t, http.MethodPost, "/tasks/bulk", "/tasks/bulk", `{"tasks":[{"title":"Buy milk"},{"title":""}]}`, 200)
+	asUser.call(t, http.MethodPost, "/tasks/bulk", "/tasks/bulk", `{"tasks":[]}`, 400)
+	asUser.call(t, http.MethodPost, "/tasks/bulk/get", "/tasks/bulk/get", fmt.Sprintf(`{"ids":[%d,999]}`, task.ID), 200)
+	asUser.call(t, http.MethodPost, "/tasks/bulk/complete", "/tasks/bulk/complete", fmt.Sprintf(`{"ids":[%d,999]}`, task.ID), 200)
+	asUser.call(t, http.MethodPost, "/tasks/bulk/delete", "/tasks/bulk/delete", `{"ids":[2]}`, 200)
+	asUser.call(t, http.MethodPost, "/graphql", "/graphql", `{"query":"{ tasks { id title owner { email } } }"}`, 200)
+	asUser.call(t, http.MethodPost, "/graphql", "/graphql", `{"query":""}`, 400)
+	attachment := one + "/attachments/notes.txt"
+	text := asUser
+	text.contentType = "text/plain"
+	text.call(t, http.MethodPut, "/tasks/:id/attachments/:name", attachment, "Numbers first", 200)
+	text.call(t, http.MethodPut, "/tasks/:id/attachments/:name", one+"/attachments/..", "Numbers first", 400)
+	image := asUser
+	image.contentType = "image/png"
+	image.call(t, http.MethodPut, "/tasks/:id/attachments/:name", one+"/attachments/chart.png", "not a PNG", 400)
+	asUser.call(t, http.MethodGet, "/tasks/:id/attachments", one+"/attachments", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/:id/attachments/:name", attachment, "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/:id/attachments/:name", one+"/attachments/chart.png", "", 404)
+	asAdmin.call(t, http.MethodDelete, "/tasks/:id/attachments/:name", attachment, "", 403)
+	asUser.call(t, http.MethodDelete, "/tasks/:id/attachments/:name", attachment, "", 204)
+	// One is completed, so it cannot be assigned
+	asUser.call(t, http.MethodPut, "/tasks/:id/assignee", one+"/assignee", fmt.Sprintf(`{"assignee_id":%d}`, admin.ID), 409)
+	rec = asUser.call(t, http.MethodPost, "/tasks", "/tasks", `{"title":"Book the venue"}`, 201)
+	json.Unmarshal(rec.Body.Bytes(), &task)
+	assignee := fmt.Sprintf("/tasks/%d/assignee", task.ID)
+	asUser.call(t, http.MethodPut, "/tasks/:id/assignee", assignee, fmt.Sprintf(`{"assignee_id":%d}`, admin.ID), 200)
+	asUser.call(t, http.MethodPut, "/tasks/:id/assignee", assignee, `{"assignee_id":999}`, 400)
+	asUser.call(t, http.MethodPut, "/tasks/:id/assignee", assignee, fmt.Sprintf(`{"assignee_id":%d,"version":1}`, admin.ID), 409)
+	asAdmin.call(t, http.MethodPut, "/tasks/:id/assignee", assignee, fmt.Sprintf(`{"assignee_id":%d}`, admin.ID), 403)
+	asAdmin.call(t, http.MethodGet, "/tasks/assigned", "/tasks/assigned?completed=false", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/assigned", "/tasks/assigned?created_from=soon", "", 400)
+	asUser.call(t, http.MethodDelete, "/tasks/:id/assignee", assignee+"?version=x", "", 400)
+	asUser.call(t, http.MethodDelete, "/tasks/:id/assignee", assignee, "", 200)
+	asUser.call(t, http.MethodDelete, "/tasks/:id", one, "", 204)
+	asUser.call(t, http.MethodGet, "/tasks/archived", "/tasks/archived", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/archived", fmt.Sprintf("/tasks/archived?owner=%d", admin.ID), "", 403)
+	// A stream ends when its client goes away: this one leaves soon
+	leaving, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	watching := asUser
+	watching.ctx = leaving
+	watching.call(t, http.MethodGet, "/tasks/stream", "/tasks/stream", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/stream", fmt.Sprintf("/tasks/stream?owner=%d", admin.ID), "", 403)
+	asUser.call(t, http.MethodGet, "/tasks/summary", "/tasks/summary", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/summary", fmt.Sprintf("/tasks/summary?owner=%d", admin.ID), "", 403)
+	asAdmin.call(t, http.MethodGet, "/tasks/activity", "/tasks/activity?limit=5", "", 200)
+	asUser.call(t, http.MethodGet, "/tasks/activity", "/tasks/activity?limit=1000", "", 400)
+
+	asUser.call(t, http.MethodGet, "/admin/users", "/admin/users", "", 403)
+	asAdmin.call(t, http.MethodGet, "/admin/users", "/admin/users", "", 200)
+	asAdmin.call(t, http.MethodPut, "/admin/users/:id/role", fmt.Sprintf("/admin/users/%d/role", admin.ID), `{"role":"user"}`, 409)
+	asAdmin.call(t, http.MethodPut, "/admin/users/:id/role", "/admin/users/1/role", `{"role":"root"}`, 400)
+	asAdmin.call(t, http.MethodPut, "/admin/users/:id/role", "/admin/users/1/role", `{"role":"admin"}`, 200)
+
+	docs := anonymous
+	docs.prefix = ""
+	docs.call(t, http.MethodGet, "/docs", "/docs", "", 200)
+	docs.call(t, http.MethodGet, "/docs/openapi.json", "/docs/openapi.json", "", 200)
+	return doc
+}
+
+func TestDocsRoutes(t *testing.T) {
+	e, doc, _, _ := docServer(t, false)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/docs")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), echo.MIMETextHTML) ||
+		!strings.Contains(rec.Body.String(), "SwaggerUIBundle") || !strings.Contains(rec.Body.String(), `"/docs/openapi.json"`) {
+		t.Errorf("/docs = %d %s, want Swagger UI pointed at /docs/openapi.json", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+
+	rec = get("/docs/openapi.json")
+	want, _ := json.Marshal(doc)
+	if rec.Code != http.StatusOK || !bytes.Equal(bytes.TrimSpace(rec.Body.Bytes()), want) {
+		t.Errorf("/docs/openapi.json = %d, want the document the routes were registered with", rec.Code)
+	}
+	var served handler.Document
+	json.Unmarshal(rec.Body.Bytes(), &served)
+	if served.OpenAPI != "3.0.3" || served.Info != docInfo || len(served.Paths) != len(doc.Paths) {
+		t.Errorf("served OpenAPI %s, %+v with %d paths; want 3.0.3, %+v with %d", served.OpenAPI, served.Info, len(served.Paths), docInfo, len(doc.Paths))
+	}
+}
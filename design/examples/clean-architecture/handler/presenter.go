@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// The API is served in versions, each under /api/<name>, from the same
+// handlers and use cases. What differs between versions is how tasks look
+// in responses: the handlers get tasks from the use cases and leave their
+// representation to the TaskPresenter of the version the request came in
+// on, so a new version changes a response's shape without a use case, or a
+// handler, knowing versions exist.
+
+// TaskPresenter renders tasks as one version of the API does, in response
+// to the request of c. Each method returns a value of the version's response
+// type; the OpenAPI document describes that type by reflection, so
+// Task(nil, &domain.Task{}) is as good as a real task for it.
+type TaskPresenter interface {
+	Task(c echo.Context, task *domain.Task) interface{}
+	// Tasks is a listing, of the page the request asked for
+	Tasks(c echo.Context, tasks []*domain.Task) interface{}
+	ArchivedTasks(c echo.Context, archived []domain.ArchivedTask) interface{}
+	// Bulk is the response to a bulk request, whose failed items' errors
+	// are coded and localized for c
+	Bulk(c echo.Context, result usecase.BulkResult) interface{}
+}
+
+// versionKey and baseURLKey are where a version's routes keep their version
+// and the base URL of their links in the echo context
+const (
+	versionKey = "handler.version"
+	baseURLKey = "handler.baseURL"
+)
+
+// presentWith is the middleware of a version's routes: it has the handlers
+// present tasks as v does, with links under baseURL
+func presentWith(v APIVersion, baseURL string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(versionKey, v)
+			c.Set(baseURLKey, baseURL)
+			return next(c)
+		}
+	}
+}
+
+// presenter returns the presenter of the request's version: v1's for a
+// handler called outside one
+func presenter(c echo.Context) TaskPresenter {
+	if v, ok := c.Get(versionKey).(APIVersion); ok {
+		return v.Presenter
+	}
+	return TaskPresenterV1{}
+}
+
+// TaskPresenterV1 renders tasks as TaskResponse, their times side by side
+// with the other fields
+type TaskPresenterV1 struct{}
+
+func (TaskPresenterV1) Task(c echo.Context, task *domain.Task) interface{} {
+	return toResponse(task)
+}
+
+func (TaskPresenterV1) Tasks(c echo.Context, tasks []*domain.Task) interface{} {
+	responses := make([]TaskResponse, len(tasks))
+	for i, task := range tasks {
+		responses[i] = toResponse(task)
+	}
+	return responses
+}
+
+func (TaskPresenterV1) ArchivedTasks(c echo.Context, archived []domain.ArchivedTask) interface{} {
+	responses := make([]ArchivedTaskResponse, len(archived))
+	for i := range archived {
+		responses[i] = ArchivedTaskResponse{
+			TaskResponse: toResponse(&archived[i].Task),
+			ArchivedAt:   archived[i].ArchivedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}
+	}
+	return responses
+}
+
+func (TaskPresenterV1) Bulk(c echo.Context, result usecase.BulkResult) interface{} {
+	return toBulkResponse(c, result)
+}
+
+// TaskResponseV2 is a task in v2: TaskResponse with its times gathered in
+// timestamps
+type TaskResponseV2 struct {
+	ID          int64          `json:"id"`
+	OwnerID     int64          `json:"owner_id"`
+	AssigneeID  int64          `json:"assignee_id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Completed   bool           `json:"completed"`
+	Priority    string         `json:"priority"`
+	Tags        []string       `json:"tags"`
+	Version     int64          `json:"version"`
+	Timestamps  TaskTimestamps `json:"timestamps"`
+}
+
+// TaskTimestamps are when a task was created, last changed, is due if it
+// is, and, in the archive only, archived
+type TaskTimestamps struct {
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+	DueAt      string `json:"due_at,omitempty"`
+	ArchivedAt string `json:"archived_at,omitempty"`
+}
+
+// BulkItemResponseV2 is BulkItemResponse with its task as in v2
+type BulkItemResponseV2 struct {
+	Index  int             `json:"index"`
+	ID     int64           `json:"id,omitempty"`
+	Status string          `json:"status"`
+	Task   *TaskResponseV2 `json:"task,omitempty"`
+	Error  *BulkItemError  `json:"error,omitempty"`
+}
+
+type BulkResponseV2 struct {
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Results   []BulkItemResponseV2 `json:"results"`
+}
+
+// TaskPresenterV2 renders tasks as TaskResponseV2
+type TaskPresenterV2 struct{}
+
+func toResponseV2(task *domain.Task) TaskResponseV2 {
+	v1 := toResponse(task)
+	return TaskResponseV2{
+		ID:          v1.ID,
+		OwnerID:     v1.OwnerID,
+		AssigneeID:  v1.AssigneeID,
+		Title:       v1.Title,
+		Description: v1.Description,
+		Completed:   v1.Completed,
+		Priority:    v1.Priority,
+		Tags:        v1.Tags,
+		Version:     v1.Version,
+		Timestamps:  TaskTimestamps{CreatedAt: v1.CreatedAt, UpdatedAt: v1.UpdatedAt, DueAt: v1.DueAt},
+	}
+}
+
+func (TaskPresenterV2) Task(c echo.Context, task *domain.Task) interface{} {
+	return toResponseV2(task)
+}
+
+func (TaskPresenterV2) Tasks(c echo.Context, tasks []*domain.Task) interface{} {
+	responses := make([]TaskResponseV2, len(tasks))
+	for i, task := range tasks {
+		responses[i] = toResponseV2(task)
+	}
+	return responses
+}
+
+// ArchivedTasks has when each task was archived among its timestamps
+func (TaskPresenterV2) ArchivedTasks(c echo.Context, archived []domain.ArchivedTask) interface{} {
+	responses := make([]TaskResponseV2, len(archived))
+	for i := range archived {
+		responses[i] = toResponseV2(&archived[i].Task)
+		responses[i].Timestamps.ArchivedAt = archived[i].ArchivedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return responses
+}
+
+func (TaskPresenterV2) Bulk(c echo.Context, result usecase.BulkResult) interface{} {
+	v1 := toBulkResponse(c, result)
+	resp := BulkResponseV2{Succeeded: v1.Succeeded, Failed: v1.Failed, Results: make([]BulkItemResponseV2, len(v1.Results))}
+	for i, item := range v1.Results {
+		resp.Results[i] = BulkItemResponseV2{Index: item.Index, ID: item.ID, Status: item.Status, Error: item.Error}
+		if item.Task != nil {
+			task := toResponseV2(result.Items[i].Task)
+			resp.Results[i].Task = &task
+		}
+	}
+	return resp
+}
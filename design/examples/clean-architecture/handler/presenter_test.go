@@ -0,0 +1,56 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+)
+
+func TestPresentersV1AndV2(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	task := &domain.Task{
+		ID: 7, OwnerID: 1, AssigneeID: 2, Title: "Write <the> report", Description: "Q1", Priority: domain.PriorityHigh,
+		Tags: []string{"work"}, Version: 3, CreatedAt: at, UpdatedAt: at.Add(time.Hour),
+	}
+	v1 := handler.TaskPresenterV1{}.Task(nil, task).(handler.TaskResponse)
+	v2 := handler.TaskPresenterV2{}.Task(nil, task).(handler.TaskResponseV2)
+	if !sameTaskV2(v1, v2) {
+		t.Errorf("v1 = %+v and v2 = %+v, want the same task", v1, v2)
+	}
+	encoded, _ := json.Marshal(v2)
+	if !strings.Contains(string(encoded), `"timestamps":{"created_at":"2024-03-01T09:30:00Z","updated_at":"2024-03-01T10:30:00Z"}`) ||
+		strings.Contains(string(encoded), `"archived_at"`) {
+		t.Errorf("v2 = %s, want the times gathered in timestamps, without archived_at outside the archive", encoded)
+	}
+	encoded, _ = json.Marshal(v1)
+	if !strings.Contains(string(encoded), `"created_at":"2024-03-01T09:30:00Z","updated_at":"2024-03-01T10:30:00Z"}`) ||
+		strings.Contains(string(encoded), "timestamps") {
+		t.Errorf("v1 = %s, want the times side by side with the other fields, as before", encoded)
+	}
+
+	archived := []domain.ArchivedTask{{Task: *task, ArchivedAt: at.Add(48 * time.Hour)}}
+	v1Archived := handler.TaskPresenterV1{}.ArchivedTasks(nil, archived).([]handler.ArchivedTaskResponse)
+	v2Archived := handler.TaskPresenterV2{}.ArchivedTasks(nil, archived).([]handler.TaskResponseV2)
+	if v1Archived[0].ArchivedAt != "2024-03-03T09:30:00Z" || v2Archived[0].Timestamps.ArchivedAt != v1Archived[0].ArchivedAt {
+		t.Errorf("archived_at = %q in v1, %q in v2, want it beside the task in v1 and among its timestamps in v2",
+			v1Archived[0].ArchivedAt, v2Archived[0].Timestamps.ArchivedAt)
+	}
+
+	for _, p := range []handler.TaskPresenter{handler.TaskPresenterV1{}, handler.TaskPresenterV2{}} {
+		if encoded, _ := json.Marshal(p.Tasks(nil, nil)); string(encoded) != "[]" {
+			t.Errorf("%T lists no tasks as %s, want [], not null", p, encoded)
+		}
+	}
+}
+
+// sameTaskV2 reports whether v2 is v1 in the other shape
+func sameTaskV2(v1 handler.TaskResponse, v2 handler.TaskResponseV2) bool {
+	return v1.ID == v2.ID && v1.OwnerID == v2.OwnerID && v1.AssigneeID == v2.AssigneeID && v1.Title == v2.Title &&
+		v1.Description == v2.Description && v1.Completed == v2.Completed && v1.Priority == v2.Priority &&
+		strings.Join(v1.Tags, ",") == strings.Join(v2.Tags, ",") && v1.Version == v2.Version &&
+		v1.CreatedAt == v2.Timestamps.CreatedAt && v1.UpdatedAt == v2.Timestamps.UpdatedAt && v2.Timestamps.ArchivedAt == ""
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// Errors are answered with RFC 7807 problem details. The code field carries
+// the domain.Code, so clients branch on it instead of parsing messages; the
+// detail is the message, in the language of the request (language.go).
+//
+// Handlers and middleware only return errors. ErrorHandler, installed as the
+// echo instance's HTTPErrorHandler, is the one place that turns them into
+// responses, so a use case error, an unknown route and a recovered panic are
+// all answered the same way.
+
+const ProblemContentType = "application/problem+json"
+
+// Errors the HTTP layer detects before a use case runs
+var (
+	ErrInvalidTaskID = domain.NewError("REQUEST_INVALID_TASK_ID", domain.KindInvalid, "invalid task id")
+	ErrInvalidUserID = domain.NewError("REQUEST_INVALID_USER_ID", domain.KindInvalid, "invalid user id")
+	ErrInvalidBody   = domain.NewError("REQUEST_INVALID_BODY", domain.KindInvalid, "invalid request body")
+	ErrInvalidQuery  = domain.NewError("REQUEST_INVALID_QUERY", domain.KindInvalid, "invalid query parameter")
+	ErrInternal      = domain.NewError("INTERNAL_ERROR", domain.KindInternal, "internal error")
+)
+
+// Errors for requests echo turns away itself. ErrRejected keeps echo's status,
+// such as 405 for a method the route does not have, and its message, which
+// is English: in any other language the detail is ErrRejected's own.
+var (
+	ErrRouteNotFound = domain.NewError("ROUTE_NOT_FOUND", domain.KindNotFound, "no route matches the request path")
+	ErrRejected      = domain.NewError("REQUEST_REJECTED", domain.KindInvalid, "the request was rejected")
+)
+
+// Errors for a request whose context ended before it was answered: the
+// server's deadline passed, or the client went away
+var (
+	ErrTimeout  = domain.NewError("REQUEST_TIMEOUT", domain.KindUnavailable, "the request did not finish in time")
+	ErrCanceled = domain.NewError("REQUEST_CANCELED", domain.KindUnavailable, "the request was canceled")
+)
+
+type Problem struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Code     domain.Code `json:"code"`
+	Instance string      `json:"instance,omitempty"`
+}
+
+func statusOf(kind domain.Kind) int {
+	switch kind {
+	case domain.KindInvalid:
+		return http.StatusBadRequest
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindUnavailable:
+		return http.StatusServiceUnavailable
+	case domain.KindPrecondition:
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusInternalServerError
+}
+
+// codedError returns err's domain error. Errors without a code are logged and
+// reported as INTERNAL_ERROR, without leaking their message to the client,
+// unless the request's context has ended: then the error is only how the
+// driver gave up, and the answer is REQUEST_TIMEOUT or REQUEST_CANCELED.
+func codedError(ctx context.Context, logger echo.Logger, err error) *domain.Error {
+	var coded *domain.Error
+	if errors.As(err, &coded) {
+		return coded
+	}
+	ctxErr := ctx.Err()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctxErr, context.DeadlineExceeded):
+		return ErrTimeout
+	case errors.Is(err, context.Canceled) || errors.Is(ctxErr, context.Canceled):
+		return ErrCanceled
+	}
+	logger.Error(err)
+	return ErrInternal
+}
+
+// problemOf returns the status and problem for err
+func problemOf(c echo.Context, err error) (int, Problem) {
+	var (
+		coded   *domain.Error
+		status  int
+		httpErr *echo.HTTPError
+	)
+	switch {
+	case errors.As(err, &httpErr) && httpErr.Code == http.StatusNotFound:
+		coded = ErrRouteNotFound
+	case errors.As(err, &httpErr) && httpErr.Code < http.StatusInternalServerError:
+		rejected := *ErrRejected
+		rejected.Message = fmt.Sprint(httpErr.Message)
+		coded, status = &rejected, httpErr.Code
+	default:
+		coded = codedError(c.Request().Context(), c.Logger(), err)
+	}
+	if status == 0 {
+		status = statusOf(coded.Kind)
+	}
+	return status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   messageOf(c.Request().Context(), coded),
+		Code:     coded.Code,
+		Instance: c.Request().URL.Path,
+	}
+}
+
+// ErrorHandler answers err with its problem document. Set it as the echo
+// instance's HTTPErrorHandler.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	status, problem := problemOf(c, err)
+	if status == http.StatusUnauthorized {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="tasks"`)
+	}
+	if lang, ok := languageOf(c.Request().Context()); ok {
+		c.Response().Header().Set(HeaderContentLanguage, lang.String())
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		var body []byte
+		if body, err = json.Marshal(problem); err == nil {
+			err = c.Blob(status, ProblemContentType, body)
+		}
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
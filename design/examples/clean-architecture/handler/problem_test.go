@@ -0,0 +1,166 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// secret is the message of an error without a code; it must never be sent
+const secret = "dial tcp 10.0.0.7:5432: connection refused"
+
+// raise returns a handler that fails with err
+func raise(err error) echo.HandlerFunc {
+	return func(echo.Context) error { return err }
+}
+
+// problemServer serves the task routes, holding one task, and one route per
+// kind the use cases do not raise here
+func problemServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	owner := &domain.User{ID: 1, Role: domain.RoleUser}
+	uc := usecase.NewTaskUseCase(repository.NewMemoryTaskRepository())
+	if _, err := uc.CreateTask(context.Background(), owner, usecase.CreateTaskInput{Title: "existing"}); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewTaskHandler(uc)
+
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Logger.SetOutput(new(bytes.Buffer))
+	e.Use(middleware.Recover())
+	e.Use(handler.AsUser(owner))
+	e.GET("/tasks", h.GetAllTasks)
+	e.POST("/tasks", h.CreateTask)
+	e.GET("/tasks/:id", h.GetTask)
+	e.PUT("/tasks/:id", h.UpdateTask)
+
+	e.GET("/raise/conflict", raise(domain.ErrEmailTaken))
+	e.GET("/raise/unauthenticated", raise(handler.ErrMissingToken))
+	e.GET("/raise/forbidden", raise(usecase.ErrForbidden))
+	e.GET("/raise/timeout", raise(fmt.Errorf("query tasks: %w", context.DeadlineExceeded)))
+	e.GET("/raise/uncoded", raise(errors.New(secret)))
+	e.GET("/raise/wrapped", raise(fmt.Errorf("load task 7: %w", usecase.ErrTaskNotFound)))
+	e.GET("/raise/panic", func(echo.Context) error { panic(secret) })
+	return e
+}
+
+type problemResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	problem handler.Problem
+}
+
+func sendProblem(e *echo.Echo, method, path, body string) problemResponse {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	r := problemResponse{status: rec.Code, header: rec.Header(), body: rec.Body.Bytes()}
+	json.Unmarshal(r.body, &r.problem)
+	return r
+}
+
+func TestErrorHandlerWritesProblems(t *testing.T) {
+	e := problemServer(t)
+	tests := []struct {
+		method, path, body string
+		status             int
+		err                *domain.Error
+	}{
+		{http.MethodGet, "/tasks/abc", "", 400, handler.ErrInvalidTaskID},
+		{http.MethodPost, "/tasks", "{", 400, handler.ErrInvalidBody},
+		{http.MethodGet, "/tasks?completed=maybe", "", 400, handler.ErrInvalidQuery},
+		{http.MethodPost, "/tasks", `{"title":""}`, 400, domain.ErrEmptyTitle},
+		{http.MethodGet, "/tasks/999", "", 404, usecase.ErrTaskNotFound},
+		{http.MethodPut, "/tasks/999", `{"title":"x"}`, 404, usecase.ErrTaskNotFound},
+		{http.MethodGet, "/raise/wrapped", "", 404, usecase.ErrTaskNotFound},
+		{http.MethodGet, "/raise/conflict", "", 409, domain.ErrEmailTaken},
+		{http.MethodGet, "/raise/unauthenticated", "", 401, handler.ErrMissingToken},
+		{http.MethodGet, "/raise/forbidden", "", 403, usecase.ErrForbidden},
+		{http.MethodGet, "/raise/timeout", "", 503, handler.ErrTimeout},
+		{http.MethodGet, "/raise/uncoded", "", 500, handler.ErrInternal},
+		{http.MethodGet, "/raise/panic", "", 500, handler.ErrInternal},
+		{http.MethodGet, "/no/such/route", "", 404, handler.ErrRouteNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r := sendProblem(e, tt.method, tt.path, tt.body)
+			path, _, _ := strings.Cut(tt.path, "?")
+			want := handler.Problem{
+				Type:     "about:blank",
+				Title:    http.StatusText(tt.status),
+				Status:   tt.status,
+				Detail:   tt.err.Message,
+				Code:     tt.err.Code,
+				Instance: path,
+			}
+			if r.status != tt.status || r.problem != want {
+				t.Errorf("got %d %+v\nwant %d %+v", r.status, r.problem, tt.status, want)
+			}
+			if ct := r.header.Get(echo.HeaderContentType); ct != handler.ProblemContentType {
+				t.Errorf("Content-Type %q, want %q", ct, handler.ProblemContentType)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerDetails(t *testing.T) {
+	e := problemServer(t)
+
+	if r := sendProblem(e, http.MethodGet, "/raise/unauthenticated", ""); !strings.HasPrefix(r.header.Get(echo.HeaderWWWAuthenticate), "Bearer") {
+		t.Errorf("a 401 has WWW-Authenticate %q, want Bearer", r.header.Get(echo.HeaderWWWAuthenticate))
+	}
+	if r := sendProblem(e, http.MethodGet, "/raise/forbidden", ""); r.header.Get(echo.HeaderWWWAuthenticate) != "" {
+		t.Error("a 403 has a WWW-Authenticate header")
+	}
+	for _, path := range []string{"/raise/uncoded", "/raise/panic"} {
+		if r := sendProblem(e, http.MethodGet, path, ""); bytes.Contains(r.body, []byte("10.0.0.7")) {
+			t.Errorf("GET %s leaked the error's message: %s", path, r.body)
+		}
+	}
+
+	// A method the route lacks keeps echo's status and Allow header
+	r := sendProblem(e, http.MethodDelete, "/tasks", "")
+	if r.status != http.StatusMethodNotAllowed || r.problem.Code != handler.ErrRejected.Code ||
+		r.problem.Status != r.status || r.problem.Detail != "Method Not Allowed" ||
+		r.header.Get(echo.HeaderContentType) != handler.ProblemContentType {
+		t.Errorf("DELETE /tasks = %d %+v", r.status, r.problem)
+	}
+	if r.header.Get(echo.HeaderAllow) == "" {
+		t.Error("the 405 has no Allow header")
+	}
+
+	if r := sendProblem(e, http.MethodHead, "/no/such/route", ""); r.status != http.StatusNotFound || len(r.body) != 0 {
+		t.Errorf("HEAD of an unknown route = %d with %d bytes, want 404 without a body", r.status, len(r.body))
+	}
+}
+
+// TestEveryDeclaredErrorKeepsItsCode raises each error domain.Codes lists:
+// each must answer with its own code, and only KindInternal with 500
+func TestEveryDeclaredErrorKeepsItsCode(t *testing.T) {
+	for _, declared := range domain.Codes() {
+		e := echo.New()
+		e.HTTPErrorHandler = handler.ErrorHandler
+		e.GET("/", raise(declared))
+		r := sendProblem(e, http.MethodGet, "/", "")
+		internal := declared.Kind == domain.KindInternal
+		if r.problem.Code != declared.Code || (r.status == http.StatusInternalServerError) != internal {
+			t.Errorf("%s answers %d %s", declared.Code, r.status, r.problem.Code)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// caller sends requests to e with its token, if any
+type caller struct {
+	e     *echo.Echo
+	token string
+}
+
+// do sends a request and returns the status and the problem code, if any
+func (c caller) do(t *testing.T, method, path, body string) (int, domain.Code) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if c.token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+c.token)
+	}
+	rec := httptest.NewRecorder()
+	c.e.ServeHTTP(rec, req)
+	var problem handler.Problem
+	json.Unmarshal(rec.Body.Bytes(), &problem)
+	return rec.Code, problem.Code
+}
+
+// create adds a task for the caller and returns its ID
+func (c caller) create(t *testing.T, title string) int64 {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"title":"`+title+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+c.token)
+	rec := httptest.NewRecorder()
+	c.e.ServeHTTP(rec, req)
+	var task handler.TaskResponse
+	if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &task) != nil {
+		t.Fatalf("creating a task: %d %s", rec.Code, rec.Body)
+	}
+	return task.ID
+}
+
+// rbacFixture is a server with a user, an admin and another user logged in
+type rbacFixture struct {
+	users                         domain.UserRepository
+	anonymous, user, admin, other caller
+	ids                           map[caller]int64 // each caller's user ID
+}
+
+// rbacServer wires the task and admin routes the way main.go does
+func rbacServer(t *testing.T) rbacFixture {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	auth := newAuth(t, users)
+	taskHandler := handler.NewTaskHandler(usecase.NewTaskUseCase(repository.NewMemoryTaskRepository()))
+	userHandler := handler.NewUserHandler(usecase.NewUserUseCase(users))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	tasks := e.Group("/tasks", handler.RequireUser(auth))
+	tasks.POST("", taskHandler.CreateTask)
+	tasks.GET("/:id", taskHandler.GetTask)
+	tasks.GET("", taskHandler.GetAllTasks)
+	tasks.PUT("/:id", taskHandler.UpdateTask)
+	tasks.DELETE("/:id", taskHandler.DeleteTask)
+	adminRoutes := e.Group("/admin", handler.RequireUser(auth), handler.RequireRole(domain.RoleAdmin))
+	adminRoutes.GET("/users", userHandler.ListUsers)
+	adminRoutes.PUT("/users/:id/role", userHandler.SetRole)
+
+	ids := make(map[caller]int64)
+	login := func(email string, role domain.Role) caller {
+		account, err := auth.Register(ctx, email, "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := users.SetRole(ctx, account.ID, role); err != nil {
+			t.Fatal(err)
+		}
+		session, err := auth.Login(ctx, email, "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := caller{e: e, token: session.Token}
+		ids[c] = account.ID
+		return c
+	}
+	return rbacFixture{
+		users:     users,
+		anonymous: caller{e: e},
+		user:      login("user@example.com", domain.RoleUser),
+		admin:     login("admin@example.com", domain.RoleAdmin),
+		other:     login("other@example.com", domain.RoleUser),
+		ids:       ids,
+	}
+}
+
+// target is what the %d in a route's path stands for
+type target int
+
+const (
+	noTarget   target = iota
+	ownTask           // a task of the caller's
+	othersTask        // a task of another user's
+	otherUser         // the other user's ID
+)
+
+func TestRoleMatrix(t *testing.T) {
+	f := rbacServer(t)
+	callers := []struct {
+		name  string
+		c     caller
+		owner caller // whose task "own" is; anonymous borrows the user's
+	}{{"anonymous", f.anonymous, f.user}, {"user", f.user, f.user}, {"admin", f.admin, f.admin}}
+
+	matrix := []struct {
+		name, method, path, body string
+		target                   target
+		want                     [3]int // anonymous, user, admin
+	}{
+		{"list", http.MethodGet, "/tasks", "", noTarget, [3]int{401, 200, 200}},
+		{"list another owner's", http.MethodGet, "/tasks?owner=%d", "", otherUser, [3]int{401, 403, 200}},
+		{"create", http.MethodPost, "/tasks", `{"title":"new"}`, noTarget, [3]int{401, 201, 201}},
+		{"view own", http.MethodGet, "/tasks/%d", "", ownTask, [3]int{401, 200, 200}},
+		{"view other's", http.MethodGet, "/tasks/%d", "", othersTask, [3]int{401, 404, 200}},
+		{"update own", http.MethodPut, "/tasks/%d", `{"title":"edited"}`, ownTask, [3]int{401, 200, 200}},
+		{"update other's", http.MethodPut, "/tasks/%d", `{"title":"edited"}`, othersTask, [3]int{401, 404, 403}},
+		{"delete other's", http.MethodDelete, "/tasks/%d", "", othersTask, [3]int{401, 404, 403}},
+		{"delete own", http.MethodDelete, "/tasks/%d", "", ownTask, [3]int{401, 204, 204}},
+		{"list users", http.MethodGet, "/admin/users", "", noTarget, [3]int{401, 403, 200}},
+	}
+	for _, row := range matrix {
+		for i, who := range callers {
+			t.Run(row.name+" as "+who.name, func(t *testing.T) {
+				// Fresh tasks for every cell, since some rows delete them
+				path := row.path
+				switch row.target {
+				case ownTask:
+					path = fmt.Sprintf(path, who.owner.create(t, "own"))
+				case othersTask:
+					path = fmt.Sprintf(path, f.other.create(t, "other's"))
+				case otherUser:
+					path = fmt.Sprintf(path, f.ids[f.other])
+				}
+				if status, code := who.c.do(t, row.method, path, row.body); status != row.want[i] {
+					t.Errorf("%s %s = %d %s, want %d", row.method, path, status, code, row.want[i])
+				}
+			})
+		}
+	}
+}
+
+func TestSetRoleRoute(t *testing.T) {
+	f := rbacServer(t)
+	promote := fmt.Sprintf("/admin/users/%d/role", f.ids[f.user])
+	tests := []struct {
+		name       string
+		c          caller
+		path, body string
+		status     int
+		code       domain.Code
+	}{
+		{"anonymous", f.anonymous, promote, `{"role":"admin"}`, http.StatusUnauthorized, handler.ErrMissingToken.Code},
+		{"a user", f.user, promote, `{"role":"admin"}`, http.StatusForbidden, usecase.ErrForbidden.Code},
+		{"an admin on their own role", f.admin, fmt.Sprintf("/admin/users/%d/role", f.ids[f.admin]), `{"role":"user"}`, http.StatusConflict, usecase.ErrOwnRole.Code},
+		{"an admin on user x", f.admin, "/admin/users/x/role", `{"role":"admin"}`, http.StatusBadRequest, handler.ErrInvalidUserID.Code},
+		{"an admin", f.admin, promote, `{"role":"admin"}`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		if status, code := tt.c.do(t, http.MethodPut, tt.path, tt.body); status != tt.status || code != tt.code {
+			t.Errorf("%s: PUT %s = %d %s, want %d %s", tt.name, tt.path, status, code, tt.status, tt.code)
+		}
+	}
+
+	// The role is read on every request, not carried in the token
+	if status, _ := f.user.do(t, http.MethodGet, "/admin/users", ""); status != http.StatusOK {
+		t.Errorf("after a promotion the user's token gets %d from /admin/users, want 200", status)
+	}
+	if err := f.users.SetRole(context.Background(), f.ids[f.user], domain.RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := f.user.do(t, http.MethodGet, "/admin/users", ""); status != http.StatusForbidden {
+		t.Errorf("after a demotion the user's token gets %d from /admin/users, want 403", status)
+	}
+}
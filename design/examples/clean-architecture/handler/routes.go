@@ -0,0 +1,460 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// Routes are registered through a RouteTable rather than on echo directly,
+// so that each carries what the OpenAPI document (openapi.go) says about
+// it. The document is built from the table the server routes by, so it
+// cannot list an endpoint the server lacks or miss one it has. The API's
+// routes are registered once per version (version.go), under its prefix.
+
+// Route is one endpoint. Body and Result are values of the request and
+// response body types, which the document describes by reflection.
+type Route struct {
+	Method  string
+	Path    string // relative to the group, in echo syntax: /:id
+	Handler echo.HandlerFunc
+	ID      string // the operationId, e.g. createTask
+	Summary string
+	Query   []Param
+	Headers []Param     // request headers the route reads
+	Body    interface{} // nil for none
+	Status  int         // of a successful response
+	Result  interface{} // nil for none
+	// ETag marks a Result that is one task, sent with its ETag (etag.go).
+	// A GET is answered 304 Not Modified if If-None-Match lists it.
+	ETag bool
+	// BodyTypes are Body's media types when it is not JSON, and
+	// ContentTypes Result's. A []byte body or result is any bytes.
+	BodyTypes    []string
+	ContentTypes []string
+	// Errors are the coded errors the route answers with, besides the ones
+	// every route of its group can (see Access) and every route at all can
+	Errors []*domain.Error
+}
+
+// Param is a query parameter or a request header. Type is an OpenAPI type: string, integer or
+// boolean, with an optional format after a colon, as in string:date-time.
+type Param struct {
+	Name        string
+	Type        string
+	Description string
+	Repeated    bool
+}
+
+// Access is who may call a group's routes
+type Access int
+
+const (
+	Public        Access = iota
+	Authenticated        // with a bearer token
+	AdminOnly            // with an admin's bearer token
+)
+
+// errors returns the coded errors turning a caller away can answer with
+func (a Access) errors() []*domain.Error {
+	switch a {
+	case Authenticated:
+		return []*domain.Error{ErrMissingToken, usecase.ErrInvalidToken}
+	case AdminOnly:
+		return []*domain.Error{ErrMissingToken, usecase.ErrInvalidToken, usecase.ErrForbidden}
+	}
+	return nil
+}
+
+// registeredRoute is a Route as registered: its full path, and its group's
+// tag, access and version
+type registeredRoute struct {
+	Route
+	fullPath string
+	tag      string
+	access   Access
+	version  string
+}
+
+// operationID is the route's ID, with its version if it has one:
+// createTaskV2
+func (r registeredRoute) operationID() string {
+	if r.version == "" {
+		return r.ID
+	}
+	return r.ID + strings.ToUpper(r.version[:1]) + r.version[1:]
+}
+
+// RouteTable registers routes on an echo instance and records them
+type RouteTable struct {
+	e      *echo.Echo
+	routes []registeredRoute
+	// baseURL begins the links of the versions that have them
+	// (Handlers.BaseURL)
+	baseURL string
+}
+
+func NewRouteTable(e *echo.Echo) *RouteTable {
+	return &RouteTable{e: e}
+}
+
+// RouteGroup is routes sharing a path prefix, a tag in the document, an
+// access level and the middleware that enforces it
+type RouteGroup struct {
+	table  *RouteTable
+	group  *echo.Group
+	prefix string
+	tag    string
+	access Access
+	// version is the API version the group is in, if any
+	version *APIVersion
+}
+
+// Group starts a group. The middleware must enforce access; the table only
+// documents it.
+func (t *RouteTable) Group(prefix, tag string, access Access, middleware ...echo.MiddlewareFunc) *RouteGroup {
+	return &RouteGroup{table: t, group: t.e.Group(prefix, middleware...), prefix: prefix, tag: tag, access: access}
+}
+
+// VersionGroup starts a group of version v's routes: under v's prefix, with
+// tasks presented as v presents them
+func (t *RouteTable) VersionGroup(v APIVersion, prefix, tag string, access Access, middleware ...echo.MiddlewareFunc) *RouteGroup {
+	g := t.Group(v.Prefix()+prefix, tag, access, middleware...)
+	g.version = &v
+	return g
+}
+
+// Add registers routes on echo and records them in the table
+func (g *RouteGroup) Add(routes ...Route) {
+	for _, r := range routes {
+		handler := r.Handler
+		version := ""
+		// On the handler rather than the group, whose middleware would
+		// answer a known path with the wrong method 404 instead of 405
+		if g.version != nil {
+			handler = presentWith(*g.version, g.table.baseURL)(handler)
+			version = g.version.Name
+		}
+		g.group.Add(r.Method, r.Path, handler)
+		g.table.routes = append(g.table.routes, registeredRoute{Route: r, fullPath: g.prefix + r.Path, tag: g.tag, access: g.access, version: version})
+	}
+}
+
+// Handlers are what RegisterRoutes routes to. Authenticate is the middleware
+// that sets the user of a request: RequireUser in a server.
+type Handlers struct {
+	Auth         *AuthHandler
+	Tasks        *TaskHandler
+	Users        *UserHandler
+	GraphQL      *GraphQLHandler
+	Authenticate echo.MiddlewareFunc
+	FastJSON     bool // serve the task GET routes with the encoders in task_json.go
+	// Idempotency, if set, replays POST /tasks retried with the same
+	// Idempotency-Key (idempotency.go)
+	Idempotency *Idempotency
+	// Queries, if set, serves the read model: /tasks/summary and
+	// /tasks/activity
+	Queries *TaskQueryHandler
+	// Attachments, if set, serves the files of each task:
+	// /tasks/:id/attachments
+	Attachments *AttachmentHandler
+	// Assignments, if set, serves /tasks/:id/assignee and /tasks/assigned
+	Assignments *AssignmentHandler
+	// Archive, if set, serves /tasks/archived
+	Archive *TaskArchiveHandler
+	// Search, if set, serves /tasks/search
+	Search *TaskSearchHandler
+	// BaseURL is the URL clients reach the server at, without a trailing
+	// slash: https://tasks.example.com. The links of v3 begin with it; if
+	// empty, they are paths from the root.
+	BaseURL string
+}
+
+// taskIDErrors are the errors of a route addressing one task
+var taskIDErrors = []*domain.Error{ErrInvalidTaskID, usecase.ErrTaskNotFound}
+
+// taskErrors are the validation errors of a task's fields
+var taskErrors = []*domain.Error{
+	ErrInvalidBody, domain.ErrEmptyTitle, domain.ErrTitleTooLong, domain.ErrDescriptionTooLong,
+	domain.ErrInvalidPriority, domain.ErrInvalidTag, domain.ErrTooManyTags,
+}
+
+var bulkErrors = []*domain.Error{ErrInvalidBody, usecase.ErrBulkEmpty, usecase.ErrBulkTooLarge}
+
+// listFilters are the query parameters of a task listing (parseListQuery),
+// but owner
+var listFilters = []Param{
+	{Name: "completed", Type: "boolean", Description: "Only completed, or only open, tasks"},
+	{Name: "created_from", Type: "string", Description: "Created on or after this RFC 3339 time or YYYY-MM-DD date"},
+	{Name: "created_before", Type: "string", Description: "Created before this RFC 3339 time or YYYY-MM-DD date"},
+	{Name: "q", Type: "string", Description: "Title or description contains this, ignoring ASCII case"},
+	{Name: "tag", Type: "string", Description: "Has this tag; repeat it to require several", Repeated: true},
+	{Name: "parent", Type: "integer:int64", Description: "Is a subtask of this task"},
+	{Name: "limit", Type: "integer", Description: "At most this many tasks, 1 to 100; all of them if absent"},
+	{Name: "offset", Type: "integer", Description: "How many tasks to skip"},
+}
+
+// RegisterRoutes registers every route of the API on t, once for each of
+// APIVersions, and answers the paths without a version as the oldest
+func RegisterRoutes(t *RouteTable, h Handlers) {
+	t.baseURL = h.BaseURL
+	for _, v := range APIVersions {
+		registerVersion(t, h, v)
+	}
+	t.e.Pre(unversioned(APIVersions[0], "/auth", "/tasks", "/graphql", "/admin"))
+}
+
+// registerVersion registers the routes of version v
+func registerVersion(t *RouteTable, h Handlers, v APIVersion) {
+	// Reflected on for the document: the response types of v's tasks
+	task, tasks := v.Presenter.Task(nil, &domain.Task{}), v.Presenter.Tasks(nil, nil)
+	bulk := v.Presenter.Bulk(nil, usecase.BulkResult{})
+
+	auth := t.VersionGroup(v, "/auth", "auth", Public)
+	auth.Add(
+		Route{
+			Method: http.MethodPost, Path: "/register", Handler: h.Auth.Register,
+			ID: "register", Summary: "Create an account",
+			Body: CredentialsRequest{}, Status: http.StatusCreated, Result: UserResponse{},
+			Errors: []*domain.Error{ErrInvalidBody, domain.ErrInvalidEmail, domain.ErrPasswordTooShort, domain.ErrPasswordTooLong, domain.ErrEmailTaken},
+		},
+		Route{
+			Method: http.MethodPost, Path: "/login", Handler: h.Auth.Login,
+			ID: "login", Summary: "Exchange email and password for an access token",
+			Body: CredentialsRequest{}, Status: http.StatusOK, Result: TokenResponse{},
+			Errors: []*domain.Error{ErrInvalidBody, usecase.ErrInvalidCredentials},
+		},
+	)
+
+	getTask, getAllTasks := h.Tasks.GetTask, h.Tasks.GetAllTasks
+	// The fast encoders write TaskResponse, v1's tasks
+	if _, v1 := v.Presenter.(TaskPresenterV1); h.FastJSON && v1 {
+		getTask, getAllTasks = h.Tasks.GetTaskFast, h.Tasks.GetAllTasksFast
+	}
+	createTask := h.Tasks.CreateTask
+	if h.Idempotency != nil {
+		createTask = h.Idempotency.Middleware()(createTask)
+	}
+	taskRoutes := t.VersionGroup(v, "/tasks", "tasks", Authenticated, h.Authenticate)
+	taskRoutes.Add(
+		Route{
+			Method: http.MethodPost, Path: "", Handler: createTask,
+			ID: "createTask", Summary: "Create a task",
+			Headers: []Param{
+				{Name: HeaderIdempotencyKey, Type: "string", Description: "Retrying with the same key and body returns the first response instead of creating another task"},
+			},
+			Body: CreateTaskRequest{}, Status: http.StatusCreated, Result: task, ETag: true,
+			Errors: append([]*domain.Error{ErrInvalidIdempotencyKey, ErrIdempotencyKeyReused, ErrIdempotencyInProgress}, taskErrors...),
+		},
+		Route{
+			Method: http.MethodPost, Path: "/bulk", Handler: h.Tasks.BulkCreateTasks,
+			ID: "bulkCreateTasks", Summary: "Create up to 100 tasks, each succeeding or failing on its own",
+			Body: BulkCreateRequest{}, Status: http.StatusOK, Result: bulk,
+			Errors: bulkErrors,
+		},
+		Route{
+			Method: http.MethodPost, Path: "/bulk/get", Handler: h.Tasks.BulkGetTasks,
+			ID: "bulkGetTasks", Summary: "Get up to 100 tasks by ID in one lookup, reporting those not found",
+			Body: BulkIDsRequest{}, Status: http.StatusOK, Result: bulk,
+			Errors: bulkErrors,
+		},
+		Route{
+			Method: http.MethodPost, Path: "/bulk/complete", Handler: h.Tasks.BulkCompleteTasks,
+			ID: "bulkCompleteTasks", Summary: "Complete up to 100 tasks by ID",
+			Body: BulkIDsRequest{}, Status: http.StatusOK, Result: bulk,
+			Errors: bulkErrors,
+		},
+		Route{
+			Method: http.MethodPost, Path: "/bulk/delete", Handler: h.Tasks.BulkDeleteTasks,
+			ID: "bulkDeleteTasks", Summary: "Delete up to 100 tasks by ID",
+			Body: BulkIDsRequest{}, Status: http.StatusOK, Result: bulk,
+			Errors: bulkErrors,
+		},
+		Route{
+			Method: http.MethodGet, Path: "/:id", Handler: getTask,
+			ID: "getTask", Summary: "Get a task",
+			Headers: []Param{
+				{Name: HeaderIfNoneMatch, Type: "string", Description: "The ETag last read: answered 304 Not Modified while the task is unchanged"},
+			},
+			Status: http.StatusOK, Result: task, ETag: true,
+			Errors: taskIDErrors,
+		},
+		Route{
+			Method: http.MethodGet, Path: "", Handler: getAllTasks,
+			ID: "listTasks", Summary: "List tasks, newest first",
+			Query: append(listFilters,
+				Param{Name: "owner", Type: "integer:int64", Description: "Belongs to this user; admins only, unless it is the caller"},
+			),
+			Status: http.StatusOK, Result: tasks,
+			Errors: []*domain.Error{ErrInvalidQuery, domain.ErrInvalidDateRange, domain.ErrInvalidPage, usecase.ErrForbidden},
+		},
+		Route{
+			Method: http.MethodGet, Path: "/stream", Handler: h.Tasks.StreamTasks,
+			ID: "streamTasks", Summary: "Watch changes to tasks as Server-Sent Events: task.created, task.updated, task.deleted and task.archived, each with the task",
+			Query: []Param{
+				{Name: "owner", Type: "integer:int64", Description: "Only this user's tasks; admins only, unless it is the caller"},
+			},
+			Status: http.StatusOK, Result: "", ContentTypes: []string{StreamContentType},
+			Errors: []*domain.Error{ErrInvalidQuery, usecase.ErrForbidden, domain.ErrTaskEventsStopped},
+		},
+		Route{
+			Method: http.MethodPut, Path: "/:id", Handler: h.Tasks.UpdateTask,
+			ID: "updateTask", Summary: "Update a task, from the version last read",
+			Headers: []Param{
+				{Name: HeaderIfMatch, Type: "string", Description: "The ETag last read, instead of the version in the body: answered 412 if the task has changed since"},
+			},
+			Body: UpdateTaskRequest{}, Status: http.StatusOK, Result: task, ETag: true,
+			Errors: append(append([]*domain.Error{usecase.ErrForbidden, domain.ErrVersionConflict, ErrPreconditionFailed}, taskIDErrors...), taskErrors...),
+		},
+		Route{
+			Method: http.MethodPost, Path: "/:id/complete", Handler: h.Tasks.CompleteTask,
+			ID: "completeTask", Summary: "Complete a task",
+			Status: http.StatusOK, Result: task, ETag: true,
+			Errors: append([]*domain.Error{usecase.ErrForbidden}, taskIDErrors...),
+		},
+		Route{
+			Method: http.MethodDelete, Path: "/:id", Handler: h.Tasks.DeleteTask,
+			ID: "deleteTask", Summary: "Delete a task",
+			Status: http.StatusNoContent,
+			Errors: append([]*domain.Error{usecase.ErrForbidden}, taskIDErrors...),
+		},
+	)
+
+	if h.Queries != nil {
+		ownerParam := Param{Name: "owner", Type: "integer:int64", Description: "Only this user's tasks; admins only, unless it is the caller"}
+		taskRoutes.Add(
+			Route{
+				Method: http.MethodGet, Path: "/summary", Handler: h.Queries.Summary,
+				ID: "taskSummary", Summary: "Count tasks by status and priority, from the read model",
+				Query:  []Param{ownerParam},
+				Status: http.StatusOK, Result: TaskSummaryResponse{},
+				Errors: []*domain.Error{ErrInvalidQuery, usecase.ErrForbidden},
+			},
+			Route{
+				Method: http.MethodGet, Path: "/activity", Handler: h.Queries.Activity,
+				ID: "taskActivity", Summary: "The latest changes to tasks, newest first, from the read model",
+				Query: []Param{
+					ownerParam,
+					{Name: "limit", Type: "integer", Description: "How many, 1 to 100; default 20"},
+				},
+				Status: http.StatusOK, Result: []TaskActivityResponse{},
+				Errors: []*domain.Error{ErrInvalidQuery, usecase.ErrInvalidActivityLimit, usecase.ErrForbidden},
+			},
+		)
+	}
+
+	if h.Search != nil {
+		taskRoutes.Add(Route{
+			Method: http.MethodGet, Path: "/search", Handler: h.Search.Search,
+			ID: "searchTasks", Summary: "Search the title and description of tasks, best match first, with the matching words highlighted",
+			Query: []Param{
+				{Name: "q", Type: "string", Description: "The words to look for, up to 200 bytes; a task matching more of them, or matching in its title, ranks higher"},
+				{Name: "owner", Type: "integer:int64", Description: "Only this user's tasks; admins only, unless it is the caller"},
+				{Name: "limit", Type: "integer", Description: "How many hits, 1 to 100; default 20"},
+				{Name: "offset", Type: "integer", Description: "How many hits to skip"},
+			},
+			Status: http.StatusOK, Result: TaskSearchResponse{},
+			Errors: []*domain.Error{ErrInvalidQuery, domain.ErrSearchQueryEmpty, domain.ErrSearchQueryTooLong, usecase.ErrInvalidSearchLimit, usecase.ErrForbidden},
+		})
+	}
+
+	if h.Attachments != nil {
+		attachmentErrors := append([]*domain.Error{domain.ErrInvalidAttachmentName}, taskIDErrors...)
+		taskRoutes.Add(
+			Route{
+				Method: http.MethodGet, Path: "/:id/attachments", Handler: h.Attachments.ListAttachments,
+				ID: "listAttachments", Summary: "List a task's attachments, by name",
+				Status: http.StatusOK, Result: []AttachmentResponse{},
+				Errors: taskIDErrors,
+			},
+			Route{
+				Method: http.MethodPut, Path: "/:id/attachments/:name", Handler: h.Attachments.UploadAttachment,
+				ID: "uploadAttachment", Summary: "Attach a file of up to 10 MiB to a task, replacing any of the same name",
+				Body: []byte{}, BodyTypes: usecase.AttachmentTypes,
+				Status: http.StatusOK, Result: AttachmentResponse{},
+				Errors: append([]*domain.Error{
+					usecase.ErrForbidden, usecase.ErrAttachmentType, usecase.ErrAttachmentTooLarge, usecase.ErrAttachmentMismatched,
+				}, attachmentErrors...),
+			},
+			Route{
+				Method: http.MethodGet, Path: "/:id/attachments/:name", Handler: h.Attachments.DownloadAttachment,
+				ID: "downloadAttachment", Summary: "Download an attachment, with the type it was uploaded with",
+				Status: http.StatusOK, Result: []byte{}, ContentTypes: usecase.AttachmentTypes,
+				Errors: append([]*domain.Error{domain.ErrAttachmentNotFound}, attachmentErrors...),
+			},
+			Route{
+				Method: http.MethodDelete, Path: "/:id/attachments/:name", Handler: h.Attachments.DeleteAttachment,
+				ID: "deleteAttachment", Summary: "Delete an attachment",
+				Status: http.StatusNoContent,
+				Errors: append([]*domain.Error{usecase.ErrForbidden, domain.ErrAttachmentNotFound}, attachmentErrors...),
+			},
+		)
+	}
+
+	if h.Assignments != nil {
+		taskRoutes.Add(
+			Route{
+				Method: http.MethodGet, Path: "/assigned", Handler: h.Assignments.ListAssignedTasks,
+				ID: "listAssignedTasks", Summary: "List the tasks assigned to the caller, whoever owns them, newest first",
+				Query:  listFilters,
+				Status: http.StatusOK, Result: tasks,
+				Errors: []*domain.Error{ErrInvalidQuery, domain.ErrInvalidDateRange, domain.ErrInvalidPage},
+			},
+			Route{
+				Method: http.MethodPut, Path: "/:id/assignee", Handler: h.Assignments.AssignTask,
+				ID: "assignTask", Summary: "Assign an open task to a user, from the version last read",
+				Body: AssignTaskRequest{}, Status: http.StatusOK, Result: task, ETag: true,
+				Errors: append([]*domain.Error{
+					ErrInvalidBody, usecase.ErrForbidden, domain.ErrVersionConflict, domain.ErrAssignCompleted, usecase.ErrUnknownAssignee,
+				}, taskIDErrors...),
+			},
+			Route{
+				Method: http.MethodDelete, Path: "/:id/assignee", Handler: h.Assignments.UnassignTask,
+				ID: "unassignTask", Summary: "Leave a task assigned to nobody",
+				Query: []Param{
+					{Name: "version", Type: "integer:int64", Description: "The version last read; the task must not have changed since"},
+				},
+				Status: http.StatusOK, Result: task, ETag: true,
+				Errors: append([]*domain.Error{ErrInvalidQuery, usecase.ErrForbidden, domain.ErrVersionConflict}, taskIDErrors...),
+			},
+		)
+	}
+
+	if h.Archive != nil {
+		taskRoutes.Add(Route{
+			Method: http.MethodGet, Path: "/archived", Handler: h.Archive.ListArchived,
+			ID: "listArchivedTasks", Summary: "List archived tasks, most recently archived first",
+			Query: []Param{
+				{Name: "owner", Type: "integer:int64", Description: "Only this user's tasks; admins only, unless it is the caller"},
+			},
+			Status: http.StatusOK, Result: v.Presenter.ArchivedTasks(nil, nil),
+			Errors: []*domain.Error{ErrInvalidQuery, usecase.ErrForbidden},
+		})
+	}
+
+	graphQL := t.VersionGroup(v, "/graphql", "graphql", Authenticated, h.Authenticate)
+	graphQL.Add(Route{
+		Method: http.MethodPost, Path: "", Handler: h.GraphQL.Serve,
+		ID: "graphql", Summary: "Run a GraphQL operation on the schema in handler/schema.graphql",
+		Body: GraphQLRequest{}, Status: http.StatusOK, Result: map[string]interface{}{},
+		Errors: []*domain.Error{ErrInvalidBody},
+	})
+
+	admin := t.VersionGroup(v, "/admin", "admin", AdminOnly, h.Authenticate, RequireRole(domain.RoleAdmin))
+	admin.Add(
+		Route{
+			Method: http.MethodGet, Path: "/users", Handler: h.Users.ListUsers,
+			ID: "listUsers", Summary: "List accounts",
+			Status: http.StatusOK, Result: []UserResponse{},
+		},
+		Route{
+			Method: http.MethodPut, Path: "/users/:id/role", Handler: h.Users.SetRole,
+			ID: "setRole", Summary: "Set an account's role",
+			Body: SetRoleRequest{}, Status: http.StatusOK, Result: UserResponse{},
+			Errors: []*domain.Error{ErrInvalidUserID, ErrInvalidBody, domain.ErrInvalidRole, usecase.ErrOwnRole, domain.ErrUserNotFound},
+		},
+	)
+}
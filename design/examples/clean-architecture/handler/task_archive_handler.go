@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// TaskArchiveHandler serves the archive of old completed tasks that
+// usecase.TaskArchiver keeps
+type TaskArchiveHandler struct {
+	archiver *usecase.TaskArchiver
+}
+
+func NewTaskArchiveHandler(archiver *usecase.TaskArchiver) *TaskArchiveHandler {
+	return &TaskArchiveHandler{archiver: archiver}
+}
+
+// ArchivedTaskResponse is the task as it was archived, and when
+type ArchivedTaskResponse struct {
+	TaskResponse
+	ArchivedAt string `json:"archived_at"`
+}
+
+// ListArchived handles GET /tasks/archived
+func (h *TaskArchiveHandler) ListArchived(c echo.Context) error {
+	owner, err := parseOwner(c)
+	if err != nil {
+		return err
+	}
+
+	archived, err := h.archiver.ListArchivedTasks(c.Request().Context(), actor(c), owner)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, presenter(c).ArchivedTasks(c, archived))
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// Bulk endpoints answer 200 once the request itself is valid, whatever
+// happened to its items: each result says whether that item succeeded and,
+// if not, why, with the same code a single-item call would return. Only a
+// request that cannot be processed at all, such as an empty or oversized
+// one, is answered with a problem.
+
+type BulkCreateRequest struct {
+	Tasks []CreateTaskRequest `json:"tasks"`
+}
+
+type BulkIDsRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+type BulkItemError struct {
+	Code   domain.Code `json:"code"`
+	Detail string      `json:"detail"`
+}
+
+type BulkItemResponse struct {
+	Index  int            `json:"index"`
+	ID     int64          `json:"id,omitempty"`
+	Status string         `json:"status"` // "succeeded" or "failed"
+	Task   *TaskResponse  `json:"task,omitempty"`
+	Error  *BulkItemError `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Results   []BulkItemResponse `json:"results"`
+}
+
+func toBulkResponse(c echo.Context, result usecase.BulkResult) BulkResponse {
+	resp := BulkResponse{
+		Succeeded: result.Succeeded,
+		Failed:    result.Failed,
+		Results:   make([]BulkItemResponse, len(result.Items)),
+	}
+	for i, item := range result.Items {
+		out := BulkItemResponse{Index: item.Index, ID: item.ID, Status: "succeeded"}
+		if item.Err != nil {
+			coded := codedError(c.Request().Context(), c.Logger(), item.Err)
+			out.Status = "failed"
+			out.Error = &BulkItemError{Code: coded.Code, Detail: messageOf(c.Request().Context(), coded)}
+		} else if item.Task != nil {
+			task := toResponse(item.Task)
+			out.Task = &task
+		}
+		resp.Results[i] = out
+	}
+	return resp
+}
+
+// BulkCreateTasks handles POST /tasks/bulk
+func (h *TaskHandler) BulkCreateTasks(c echo.Context) error {
+	var req BulkCreateRequest
+	if err := c.Bind(&req); err != nil {
+		return ErrInvalidBody
+	}
+
+	inputs := make([]usecase.CreateTaskInput, len(req.Tasks))
+	for i, task := range req.Tasks {
+		inputs[i] = usecase.CreateTaskInput{
+			Title:       task.Title,
+			Description: task.Description,
+			Priority:    task.Priority,
+			Tags:        task.Tags,
+			DueAt:       dueAt(task.DueAt),
+		}
+	}
+	result, err := h.taskUseCase.BulkCreateTasks(c.Request().Context(), actor(c), inputs)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, presenter(c).Bulk(c, result))
+}
+
+// BulkCompleteTasks handles POST /tasks/bulk/complete
+func (h *TaskHandler) BulkCompleteTasks(c echo.Context) error {
+	return h.bulkByID(c, h.taskUseCase.BulkCompleteTasks)
+}
+
+// BulkGetTasks handles POST /tasks/bulk/get
+func (h *TaskHandler) BulkGetTasks(c echo.Context) error {
+	return h.bulkByID(c, h.taskUseCase.BulkGetTasks)
+}
+
+// BulkDeleteTasks handles POST /tasks/bulk/delete
+func (h *TaskHandler) BulkDeleteTasks(c echo.Context) error {
+	return h.bulkByID(c, h.taskUseCase.BulkDeleteTasks)
+}
+
+func (h *TaskHandler) bulkByID(c echo.Context, action func(context.Context, *domain.User, []int64) (usecase.BulkResult, error)) error {
+	var req BulkIDsRequest
+	if err := c.Bind(&req); err != nil {
+		return ErrInvalidBody
+	}
+
+	result, err := action(c.Request().Context(), actor(c), req.IDs)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, presenter(c).Bulk(c, result))
+}
@@ -0,0 +1,115 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// openSQLite opens a migrated SQLite database in the test's temporary directory
+func openSQLite(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// bulkServer serves the bulk routes on SQLite for owner, where a task titled
+// Explodes fails to insert as a disk error would
+func bulkServer(t *testing.T, owner *domain.User) *echo.Echo {
+	t.Helper()
+	db := openSQLite(t)
+	if _, err := db.Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON tasks WHEN NEW.title = 'Explodes'
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END`); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(repository.NewTaskRepository(db)))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(handler.AsUser(owner))
+	e.POST("/tasks/bulk", h.BulkCreateTasks)
+	e.POST("/tasks/bulk/get", h.BulkGetTasks)
+	e.POST("/tasks/bulk/complete", h.BulkCompleteTasks)
+	e.POST("/tasks/bulk/delete", h.BulkDeleteTasks)
+	return e
+}
+
+func postBulk(t *testing.T, e *echo.Echo, target, body string) (int, handler.BulkResponse, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	var resp handler.BulkResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp, rec.Body.String()
+}
+
+func TestBulkEndpoints(t *testing.T) {
+	e := bulkServer(t, &domain.User{ID: 1, Role: domain.RoleUser})
+
+	status, resp, _ := postBulk(t, e, "/tasks/bulk", `{"tasks":[{"title":"Over HTTP","tags":["api"]},{"title":""}]}`)
+	if status != http.StatusOK || resp.Succeeded != 1 || resp.Failed != 1 {
+		t.Fatalf("create: %d with %d succeeded, %d failed; want 200 with the counts", status, resp.Succeeded, resp.Failed)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Status != "succeeded" || resp.Results[0].Task == nil ||
+		resp.Results[1].Status != "failed" || resp.Results[1].Error == nil || resp.Results[1].Error.Code != domain.ErrEmptyTitle.Code {
+		t.Fatalf("create results %+v: want each with its status, and the task or the error code", resp.Results)
+	}
+	created := resp.Results[0].ID
+
+	status, resp, _ = postBulk(t, e, "/tasks/bulk/get", fmt.Sprintf(`{"ids":[424242,%d]}`, created))
+	if status != http.StatusOK || resp.Succeeded != 1 || resp.Results[0].Error.Code != usecase.ErrTaskNotFound.Code ||
+		resp.Results[1].Task == nil || resp.Results[1].Task.Title != "Over HTTP" {
+		t.Errorf("get: %d %+v; want the missing id not found, and the task after it", status, resp.Results)
+	}
+
+	status, resp, _ = postBulk(t, e, "/tasks/bulk/complete", fmt.Sprintf(`{"ids":[%d,424242]}`, created))
+	if status != http.StatusOK || resp.Succeeded != 1 || resp.Results[1].Error.Code != usecase.ErrTaskNotFound.Code {
+		t.Errorf("complete: %d %+v; want the missing id not found", status, resp.Results)
+	}
+
+	status, resp, _ = postBulk(t, e, "/tasks/bulk/delete", fmt.Sprintf(`{"ids":[%d,%d]}`, created, created))
+	if status != http.StatusOK || resp.Succeeded != 1 || resp.Failed != 1 || resp.Results[0].Task != nil ||
+		resp.Results[1].Status != "failed" || resp.Results[1].Error.Code != usecase.ErrBulkDuplicateID.Code {
+		t.Errorf("delete: %d %+v; want success without a task, then the repeated id failed", status, resp.Results)
+	}
+}
+
+func TestBulkEndpointErrors(t *testing.T) {
+	e := bulkServer(t, &domain.User{ID: 1, Role: domain.RoleUser})
+
+	_, resp, body := postBulk(t, e, "/tasks/bulk", `{"tasks":[{"title":"Explodes"}]}`)
+	if resp.Failed != 1 || resp.Results[0].Error.Code != handler.ErrInternal.Code || strings.Contains(body, "disk I/O") {
+		t.Errorf("storage failure: %s; want INTERNAL_ERROR without its message", body)
+	}
+
+	status, _, body := postBulk(t, e, "/tasks/bulk/complete", `{"ids":[]}`)
+	if status != http.StatusBadRequest || !strings.Contains(body, string(usecase.ErrBulkEmpty.Code)) {
+		t.Errorf("empty request: %d %s; want a 400 problem", status, body)
+	}
+	ids := strings.Repeat("1,", usecase.MaxBulkItems) + "1"
+	status, _, body = postBulk(t, e, "/tasks/bulk/delete", `{"ids":[`+ids+`]}`)
+	if status != http.StatusBadRequest || !strings.Contains(body, string(usecase.ErrBulkTooLarge.Code)) {
+		t.Errorf("oversized request: %d %s; want a 400 problem", status, body)
+	}
+	status, _, body = postBulk(t, e, "/tasks/bulk", `{"tasks":`)
+	if status != http.StatusBadRequest || !strings.Contains(body, string(handler.ErrInvalidBody.Code)) {
+		t.Errorf("malformed request: %d %s; want a 400 problem", status, body)
+	}
+}
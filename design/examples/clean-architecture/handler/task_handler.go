@@ -1,8 +1,10 @@
 package handler
 
 import (
+"errors"
 "net/http"
 "strconv"
+"time"
 
 "github.com/dong-tran/docs/clean-architecture-example/domain"
 "github.com/dong-tran/docs/clean-architecture-example/usecase"
@@ -20,31 +22,72 @@ func NewTaskHandler(taskUseCase *usecase.TaskUseCase) *TaskHandler {
 }
 
 type CreateTaskRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Priority    string   `json:"priority"`
+	Tags        []string `json:"tags"`
+	// DueAt is when the task is due, in RFC 3339; its owner is reminded
+	// before then (see usecase.ReminderService)
+	DueAt *time.Time `json:"due_at,omitempty"`
 }
 
+// dueAt is the due date of a request, zero when it has none
+func dueAt(due *time.Time) time.Time {
+	if due == nil {
+		return time.Time{}
+	}
+	return *due
+}
+
+// UpdateTaskRequest keeps the current priority and tags when they are left
+// out. Version is the one the client last read: if the task has changed
+// since, the update fails with 409 rather than overwrite that change.
+// Without it, the update applies to the task as it is.
 type UpdateTaskRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Completed   bool     `json:"completed"`
+	Priority    string   `json:"priority"`
+	Tags        []string `json:"tags"`
+	Version     int64    `json:"version"`
 }
 
+// TaskResponse has an assignee_id of 0 when nobody is assigned the task
 type TaskResponse struct {
-	ID          int64  `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          int64    `json:"id"`
+	OwnerID     int64    `json:"owner_id"`
+	AssigneeID  int64    `json:"assignee_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Completed   bool     `json:"completed"`
+	Priority    string   `json:"priority"`
+	Tags        []string `json:"tags"`
+	Version     int64    `json:"version"`
+	DueAt       string   `json:"due_at,omitempty"` // absent if the task is not due
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
 }
 
 func toResponse(task *domain.Task) TaskResponse {
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{} // [] rather than null
+	}
+	var due string
+	if !task.DueAt.IsZero() {
+		due = task.DueAt.Format("2006-01-02T15:04:05Z07:00")
+	}
 	return TaskResponse{
 		ID:          task.ID,
+		OwnerID:     task.OwnerID,
+		AssigneeID:  task.AssigneeID,
 		Title:       task.Title,
 		Description: task.Description,
 		Completed:   task.Completed,
+		Priority:    string(task.Priority),
+		Tags:        tags,
+		Version:     task.Version,
+		DueAt:       due,
 		CreatedAt:   task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:   task.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
@@ -53,110 +96,186 @@ func toResponse(task *domain.Task) TaskResponse {
 func (h *TaskHandler) CreateTask(c echo.Context) error {
 	var req CreateTaskRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-"error": "invalid request body",
-})
+		return ErrInvalidBody
 	}
 
-	task, err := h.taskUseCase.CreateTask(usecase.CreateTaskInput{
+	task, err := h.taskUseCase.CreateTask(c.Request().Context(), actor(c), usecase.CreateTaskInput{
 Title:       req.Title,
 Description: req.Description,
+Priority:    req.Priority,
+Tags:        req.Tags,
+DueAt:       dueAt(req.DueAt),
 })
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-"error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(http.StatusCreated, toResponse(task))
+	setETag(c, task)
+	return c.JSON(http.StatusCreated, presenter(c).Task(c, task))
 }
 
 func (h *TaskHandler) GetTask(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-"error": "invalid task id",
-})
+		return ErrInvalidTaskID
 	}
 
-	task, err := h.taskUseCase.GetTask(id)
+	task, err := h.taskUseCase.GetTask(c.Request().Context(), actor(c), id)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-"error": "task not found",
-})
+		return err
+	}
+	if answered, err := notModified(c, task); answered {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, toResponse(task))
+	setETag(c, task)
+	return c.JSON(http.StatusOK, presenter(c).Task(c, task))
+}
+
+// parseListQuery reads the list filters:
+//
+//	completed=true|false
+//	created_from=DATE    created on or after DATE
+//	created_before=DATE  created before DATE
+//	q=TEXT               title or description contains TEXT
+//	tag=TAG              has TAG; repeat it to require several
+//	owner=ID             belongs to user ID; admins only, unless ID is the caller
+//	parent=ID            is a subtask of task ID
+//	limit=N              at most N tasks, 1 to 100; all of them if absent
+//	offset=N             skipping the first N
+//
+// DATE is RFC 3339 or YYYY-MM-DD, the latter meaning midnight UTC.
+func parseListQuery(c echo.Context) (domain.ListTasksQuery, error) {
+	var query domain.ListTasksQuery
+	if v := c.QueryParam("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return query, ErrInvalidQuery
+		}
+		query.Completed = &completed
+	}
+	var err error
+	if query.CreatedFrom, err = parseDate(c.QueryParam("created_from")); err != nil {
+		return query, ErrInvalidQuery
+	}
+	if query.CreatedBefore, err = parseDate(c.QueryParam("created_before")); err != nil {
+		return query, ErrInvalidQuery
+	}
+	query.Search = c.QueryParam("q")
+	query.Tags = c.QueryParams()["tag"]
+	if v := c.QueryParam("owner"); v != "" {
+		if query.OwnerID, err = strconv.ParseInt(v, 10, 64); err != nil || query.OwnerID <= 0 {
+			return query, ErrInvalidQuery
+		}
+	}
+	if v := c.QueryParam("parent"); v != "" {
+		parent, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parent <= 0 {
+			return query, ErrInvalidQuery
+		}
+		query.ParentIDs = []int64{parent}
+	}
+	for name, into := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if v := c.QueryParam(name); v != "" {
+			if *into, err = strconv.Atoi(v); err != nil {
+				return query, ErrInvalidQuery
+			}
+		}
+	}
+	return query, nil
+}
+
+func parseDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
 }
 
 func (h *TaskHandler) GetAllTasks(c echo.Context) error {
-	tasks, err := h.taskUseCase.GetAllTasks()
+	query, err := parseListQuery(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-"error": "failed to retrieve tasks",
-})
+		return err
 	}
 
-	responses := make([]TaskResponse, len(tasks))
-	for i, task := range tasks {
-		responses[i] = toResponse(task)
+	tasks, err := h.taskUseCase.ListTasks(c.Request().Context(), actor(c), query)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, responses)
+	return c.JSON(http.StatusOK, presenter(c).Tasks(c, tasks))
 }
 
 func (h *TaskHandler) UpdateTask(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-"error": "invalid task id",
-})
+		return ErrInvalidTaskID
 	}
 
 	var req UpdateTaskRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-"error": "invalid request body",
-})
+		return ErrInvalidBody
+	}
+	// If-Match stands in for the version in the body; both, they must agree
+	expected, err := h.ifMatch(c, id)
+	if err != nil {
+		return err
+	}
+	if expected != 0 {
+		if req.Version != 0 && req.Version != expected {
+			return domain.ErrVersionConflict
+		}
+		req.Version = expected
 	}
 
-	task, err := h.taskUseCase.UpdateTask(usecase.UpdateTaskInput{
+	task, err := h.taskUseCase.UpdateTask(c.Request().Context(), actor(c), usecase.UpdateTaskInput{
 ID:          id,
 Title:       req.Title,
 Description: req.Description,
 Completed:   req.Completed,
+Priority:    req.Priority,
+Tags:        req.Tags,
+Version:     req.Version,
 })
+	if expected != 0 && errors.Is(err, domain.ErrVersionConflict) {
+		return ErrPreconditionFailed
+	}
 	if err != nil {
-		if err == usecase.ErrTaskNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{
-"error": "task not found",
-})
-		}
-		return c.JSON(http.StatusBadRequest, map[string]string{
-"error": err.Error(),
-		})
+		return err
+	}
+
+	setETag(c, task)
+	return c.JSON(http.StatusOK, presenter(c).Task(c, task))
+}
+
+// CompleteTask handles POST /tasks/:id/complete. Completing a completed task
+// changes nothing but its version.
+func (h *TaskHandler) CompleteTask(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrInvalidTaskID
+	}
+
+	task, err := h.taskUseCase.CompleteTask(c.Request().Context(), actor(c), id)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, toResponse(task))
+	setETag(c, task)
+	return c.JSON(http.StatusOK, presenter(c).Task(c, task))
 }
 
 func (h *TaskHandler) DeleteTask(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-"error": "invalid task id",
-})
+		return ErrInvalidTaskID
 	}
 
-	if err := h.taskUseCase.DeleteTask(id); err != nil {
-		if err == usecase.ErrTaskNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{
-"error": "task not found",
-})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-"error": "failed to delete task",
-})
+	if err := h.taskUseCase.DeleteTask(c.Request().Context(), actor(c), id); err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusNoContent)
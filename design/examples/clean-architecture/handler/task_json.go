@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// Fast JSON path for the hot GET endpoints.
+// c.JSON goes through encoding/json: reflection over TaskResponse, two
+// formatted timestamp strings per task, and a fresh encoder and buffer per
+// response. The Fast handlers below write the same bytes by appending each field
+// straight into a pooled buffer, so encoding a response allocates nothing.
+// GetTask and GetAllTasks stay as the readable reference; task_json_test.go
+// checks the two write the same bytes and benchmarks them.
+
+var jsonBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
+// Buffers grown beyond this by a large list are dropped instead of pooled
+const maxPooledBuffer = 256 << 10
+
+func writeJSONBuffer(c echo.Context, status int, buf *[]byte) error {
+	err := c.JSONBlob(status, *buf)
+	if cap(*buf) <= maxPooledBuffer {
+		*buf = (*buf)[:0]
+		jsonBuffers.Put(buf)
+	}
+	return err
+}
+
+// GetTaskFast serves the same response as GetTask
+func (h *TaskHandler) GetTaskFast(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrInvalidTaskID
+	}
+
+	task, err := h.taskUseCase.GetTask(c.Request().Context(), actor(c), id)
+	if err != nil {
+		return err
+	}
+	if answered, err := notModified(c, task); answered {
+		return err
+	}
+
+	setETag(c, task)
+	buf := jsonBuffers.Get().(*[]byte)
+	*buf = appendTaskJSON(*buf, task)
+	*buf = append(*buf, '\n')
+	return writeJSONBuffer(c, http.StatusOK, buf)
+}
+
+// GetAllTasksFast serves the same response as GetAllTasks
+func (h *TaskHandler) GetAllTasksFast(c echo.Context) error {
+	query, err := parseListQuery(c)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := h.taskUseCase.ListTasks(c.Request().Context(), actor(c), query)
+	if err != nil {
+		return err
+	}
+
+	buf := jsonBuffers.Get().(*[]byte)
+	*buf = append(*buf, '[')
+	for i, task := range tasks {
+		if i > 0 {
+			*buf = append(*buf, ',')
+		}
+		*buf = appendTaskJSON(*buf, task)
+	}
+	*buf = append(*buf, ']', '\n')
+	return writeJSONBuffer(c, http.StatusOK, buf)
+}
+
+// appendTaskJSON encodes a task exactly as encoding/json encodes toResponse(task)
+func appendTaskJSON(dst []byte, task *domain.Task) []byte {
+	dst = append(dst, `{"id":`...)
+	dst = strconv.AppendInt(dst, task.ID, 10)
+	dst = append(dst, `,"owner_id":`...)
+	dst = strconv.AppendInt(dst, task.OwnerID, 10)
+	dst = append(dst, `,"assignee_id":`...)
+	dst = strconv.AppendInt(dst, task.AssigneeID, 10)
+	dst = append(dst, `,"title":`...)
+	dst = appendJSONString(dst, task.Title)
+	dst = append(dst, `,"description":`...)
+	dst = appendJSONString(dst, task.Description)
+	dst = append(dst, `,"completed":`...)
+	dst = strconv.AppendBool(dst, task.Completed)
+	dst = append(dst, `,"priority":`...)
+	dst = appendJSONString(dst, string(task.Priority))
+	dst = append(dst, `,"tags":[`...)
+	for i, tag := range task.Tags {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendJSONString(dst, tag)
+	}
+	dst = append(dst, `],"version":`...)
+	dst = strconv.AppendInt(dst, task.Version, 10)
+	if !task.DueAt.IsZero() {
+		dst = append(dst, `,"due_at":"`...)
+		dst = task.DueAt.AppendFormat(dst, time.RFC3339)
+		dst = append(dst, '"')
+	}
+	dst = append(dst, `,"created_at":"`...)
+	dst = task.CreatedAt.AppendFormat(dst, time.RFC3339)
+	dst = append(dst, `","updated_at":"`...)
+	dst = task.UpdatedAt.AppendFormat(dst, time.RFC3339)
+	return append(dst, `"}`...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString quotes s the way encoding/json does, including its HTML
+// escaping of <, > and &, U+2028/U+2029, and replacing invalid UTF-8
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,170 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// The hand-written JSON path against echo's default one (c.JSON ->
+// encoding/json) on the task GET endpoints:
+//
+//	go test -run TaskJSON -bench TaskJSON ./handler
+//
+// Requests go through a real echo router, but the repository is a fixed slice
+// and responses go to a writer that discards the body, so what the benchmark
+// measures is routing plus encoding.
+
+// jsonOwner is the user every request is authenticated as
+var jsonOwner = &domain.User{ID: 1, Role: domain.RoleUser}
+
+// fixedRepo serves preloaded tasks without allocating
+type fixedRepo struct {
+	tasks []*domain.Task
+}
+
+func (r *fixedRepo) Create(context.Context, *domain.Task) error        { return nil }
+func (r *fixedRepo) CreateBatch(context.Context, []*domain.Task) error { return nil }
+func (r *fixedRepo) Update(context.Context, *domain.Task) error        { return nil }
+func (r *fixedRepo) Delete(context.Context, int64) error               { return nil }
+
+func (r *fixedRepo) GetByID(_ context.Context, id int64) (*domain.Task, error) {
+	if id < 1 || id > int64(len(r.tasks)) {
+		return nil, usecase.ErrTaskNotFound
+	}
+	return r.tasks[id-1], nil
+}
+
+func (r *fixedRepo) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	for _, id := range slices.Compact(ids) {
+		if task, err := r.GetByID(ctx, id); err == nil {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+func (r *fixedRepo) List(context.Context, domain.ListTasksQuery) ([]*domain.Task, error) {
+	return r.tasks, nil
+}
+
+// newFixedRepo holds n tasks whose titles, descriptions and tags need every
+// kind of escaping
+func newFixedRepo(n int) *fixedRepo {
+	titles := []string{
+		"Write the quarterly report",
+		`Fix <script> & "quotes" in titles`,
+		"Tabs\tand\nnewlines",
+		"Backspace\band form\ffeed",
+		"Unicode: café, 東京,   and invalid \xff bytes",
+	}
+	priorities := []domain.Priority{domain.PriorityLow, domain.PriorityMedium, domain.PriorityHigh}
+	tagSets := [][]string{nil, {"work"}, {"ops", `needs "review" & <sign-off>`}}
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	repo := &fixedRepo{}
+	for i := 0; i < n; i++ {
+		repo.tasks = append(repo.tasks, &domain.Task{
+			ID:          int64(i + 1),
+			OwnerID:     jsonOwner.ID,
+			Title:       titles[i%len(titles)],
+			Description: strings.Repeat("Some longer description text. ", 1+i%4),
+			Completed:   i%3 == 0,
+			Priority:    priorities[i%len(priorities)],
+			Tags:        tagSets[i%len(tagSets)],
+			AssigneeID:  int64(i%2) * jsonOwner.ID,
+			CreatedAt:   created.Add(time.Duration(i) * time.Minute),
+			UpdatedAt:   created.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	return repo
+}
+
+// jsonServer serves both paths of 100 tasks, under /default and /fast
+func jsonServer() *echo.Echo {
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(newFixedRepo(100)))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(handler.AsUser(jsonOwner))
+	e.GET("/default/tasks/:id", h.GetTask)
+	e.GET("/default/tasks", h.GetAllTasks)
+	e.GET("/fast/tasks/:id", h.GetTaskFast)
+	e.GET("/fast/tasks", h.GetAllTasksFast)
+	return e
+}
+
+var jsonEndpoints = []struct {
+	name string
+	path string
+}{
+	{"task", "/tasks/2"},
+	{"tasks=100", "/tasks"},
+}
+
+func TestTaskJSONMatchesDefault(t *testing.T) {
+	e := jsonServer()
+	for _, ep := range jsonEndpoints {
+		t.Run(ep.name, func(t *testing.T) {
+			var bodies []string
+			for _, prefix := range []string{"/default", "/fast"} {
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, prefix+ep.path, nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("GET %s%s: status %d: %s", prefix, ep.path, rec.Code, rec.Body)
+				}
+				bodies = append(bodies, rec.Body.String())
+			}
+			if bodies[0] != bodies[1] {
+				t.Errorf("responses differ\ndefault: %s\nfast:    %s", bodies[0], bodies[1])
+			}
+		})
+	}
+}
+
+func TestTaskJSONNotFound(t *testing.T) {
+	e := jsonServer()
+	for _, prefix := range []string{"/default", "/fast"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, prefix+"/tasks/101", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s/tasks/101: status %d, want 404", prefix, rec.Code)
+		}
+	}
+}
+
+// discardWriter is a ResponseWriter that reuses its header map and drops the body
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func BenchmarkTaskJSON(b *testing.B) {
+	e := jsonServer()
+	for _, ep := range jsonEndpoints {
+		for _, prefix := range []string{"/default", "/fast"} {
+			b.Run(ep.name+prefix, func(b *testing.B) {
+				req := httptest.NewRequest(http.MethodGet, prefix+ep.path, nil)
+				w := &discardWriter{header: make(http.Header)}
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					clear(w.header)
+					e.ServeHTTP(w, req)
+				}
+			})
+		}
+	}
+}
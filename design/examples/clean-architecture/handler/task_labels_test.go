@@ -0,0 +1,50 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+func TestTaskLabelsOverHTTP(t *testing.T) {
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(repository.NewMemoryTaskRepository()))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(handler.AsUser(&domain.User{ID: 1, Role: domain.RoleUser}))
+	e.POST("/tasks", h.CreateTask)
+	e.GET("/tasks/:id", h.GetTask)
+	e.GET("/fast/tasks/:id", h.GetTaskFast)
+	send := func(method, target, body string) (int, string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	status, body := send(http.MethodPost, "/tasks", `{"title":"Label me","priority":"high","tags":["Ops","oncall"]}`)
+	if status != http.StatusCreated || !strings.Contains(body, `"priority":"high","tags":["oncall","ops"]`) {
+		t.Errorf("POST with labels = %d %s", status, body)
+	}
+	_, slow := send(http.MethodGet, "/tasks/1", "")
+	if _, fast := send(http.MethodGet, "/fast/tasks/1", ""); fast != slow {
+		t.Errorf("the fast encoder wrote\n%s\nwant\n%s", fast, slow)
+	}
+
+	send(http.MethodPost, "/tasks", `{"title":"No labels"}`)
+	if _, body := send(http.MethodGet, "/tasks/2", ""); !strings.Contains(body, `"priority":"medium","tags":[]`) {
+		t.Errorf("an untagged task is %s, want tags [] rather than null", body)
+	}
+
+	status, body = send(http.MethodPost, "/tasks", `{"title":"Bad","priority":"urgent"}`)
+	if status != http.StatusBadRequest || !strings.Contains(body, string(domain.ErrInvalidPriority.Code)) {
+		t.Errorf("POST with priority urgent = %d %s, want 400 %s", status, body, domain.ErrInvalidPriority.Code)
+	}
+}
@@ -0,0 +1,121 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// listServer serves GET /tasks and /fast/tasks in memory, for user 1 who owns
+// a handful of tasks created in March 2024
+func listServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	repo := repository.NewMemoryTaskRepository()
+	march1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, f := range []struct {
+		title, description string
+		completed          bool
+		created            time.Time
+		tags               []string
+	}{
+		{"Write the quarterly report", "numbers for Q1", false, march1.Add(9 * time.Hour), []string{"finance"}},
+		{"Fix 100% CPU on the worker", "a loop in the REPORT generator", false, march1.Add(5 * 24 * time.Hour), []string{"dev"}},
+		{"Rename user_id to owner_id", "", true, march1.Add(7 * 24 * time.Hour), []string{"dev", "schema"}},
+		{"Renamed userXid by mistake", "", false, march1.Add(8 * 24 * time.Hour), []string{"dev"}},
+		{"Book the Café for Friday", "team lunch", false, march1.Add(9 * 24 * time.Hour), nil},
+	} {
+		task, err := domain.NewTask(1, f.title, f.description, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Completed = f.completed
+		if err := task.SetTags(f.tags, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		task.CreatedAt, task.UpdatedAt = f.created, f.created
+		if err := repo.Create(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(repo))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(handler.AsUser(&domain.User{ID: 1, Role: domain.RoleUser}))
+	e.GET("/tasks", h.GetAllTasks)
+	e.GET("/fast/tasks", h.GetAllTasksFast)
+	return e
+}
+
+func getList(e *echo.Echo, target string) (int, string) {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestListQueryParameters(t *testing.T) {
+	e := listServer(t)
+	tests := []struct {
+		name  string
+		query string
+		want  []string // titles in the order listed
+	}{
+		{"filters reach the repository", "completed=false&q=REPORT&created_from=2024-03-01&created_before=2024-03-07T00:00:00Z",
+			[]string{"Fix 100% CPU", "Write the quarterly report"}},
+		{"tag can be repeated", "tag=dev&tag=schema", []string{"Rename user_id to owner_id"}},
+		{"q is URL-decoded", "q=Caf%C3%A9", []string{"Book the Café"}},
+		{"limit and offset page the listing", "completed=false&limit=1&offset=1", []string{"Renamed userXid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := getList(e, "/tasks?"+tt.query)
+			if status != http.StatusOK || strings.Count(body, `"id"`) != len(tt.want) {
+				t.Fatalf("%d %s, want %d tasks", status, body, len(tt.want))
+			}
+			last := -1
+			for _, title := range tt.want {
+				at := strings.Index(body, title)
+				if at < last {
+					t.Errorf("%q is missing or out of order in %s", title, body)
+				}
+				last = at
+			}
+			if _, fast := getList(e, "/fast/tasks?"+tt.query); fast != body {
+				t.Errorf("the fast handler returned\n%s\nwant the same bytes as\n%s", fast, body)
+			}
+		})
+	}
+}
+
+func TestListQueryErrors(t *testing.T) {
+	e := listServer(t)
+	tests := []struct {
+		query string
+		code  string
+	}{
+		{"tag=", string(domain.ErrInvalidTag.Code)},
+		{"completed=maybe", string(handler.ErrInvalidQuery.Code)},
+		{"created_from=yesterday", string(handler.ErrInvalidQuery.Code)},
+		{"created_before=2024-13-01", string(handler.ErrInvalidQuery.Code)},
+		{"limit=two", string(handler.ErrInvalidQuery.Code)},
+		{"parent=0", string(handler.ErrInvalidQuery.Code)},
+		{"limit=500", string(domain.ErrInvalidPage.Code)},
+		{"created_from=2024-03-10&created_before=2024-03-01", string(domain.ErrInvalidDateRange.Code)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			status, body := getList(e, "/tasks?"+tt.query)
+			if status != http.StatusBadRequest || !strings.Contains(body, tt.code) {
+				t.Errorf("%d %s, want 400 %s", status, body, tt.code)
+			}
+		})
+	}
+}
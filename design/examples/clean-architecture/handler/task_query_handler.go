@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// TaskQueryHandler serves the read side: answers from the read model of
+// usecase.TaskQueryService, which may lag the writes by a moment
+type TaskQueryHandler struct {
+	queries *usecase.TaskQueryService
+}
+
+func NewTaskQueryHandler(queries *usecase.TaskQueryService) *TaskQueryHandler {
+	return &TaskQueryHandler{queries: queries}
+}
+
+type TaskSummaryResponse struct {
+	Total      int            `json:"total"`
+	Open       int            `json:"open"`
+	Completed  int            `json:"completed"`
+	ByPriority map[string]int `json:"by_priority"`
+}
+
+type TaskActivityResponse struct {
+	Kind    string `json:"kind"` // created, updated, completed, reopened, deleted or archived
+	TaskID  int64  `json:"task_id"`
+	OwnerID int64  `json:"owner_id"`
+	Title   string `json:"title"`
+	Version int64  `json:"version"`
+	At      string `json:"at"`
+}
+
+// parseOwner reads owner=ID, 0 if it is absent
+func parseOwner(c echo.Context) (int64, error) {
+	v := c.QueryParam("owner")
+	if v == "" {
+		return 0, nil
+	}
+	owner, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || owner <= 0 {
+		return 0, ErrInvalidQuery
+	}
+	return owner, nil
+}
+
+// Summary handles GET /tasks/summary
+func (h *TaskQueryHandler) Summary(c echo.Context) error {
+	owner, err := parseOwner(c)
+	if err != nil {
+		return err
+	}
+
+	summary, err := h.queries.Summary(c.Request().Context(), actor(c), owner)
+	if err != nil {
+		return err
+	}
+
+	byPriority := make(map[string]int, len(summary.ByPriority))
+	for priority, n := range summary.ByPriority {
+		byPriority[string(priority)] = n
+	}
+	return c.JSON(http.StatusOK, TaskSummaryResponse{
+		Total:      summary.Total,
+		Open:       summary.Open,
+		Completed:  summary.Completed,
+		ByPriority: byPriority,
+	})
+}
+
+// Activity handles GET /tasks/activity
+func (h *TaskQueryHandler) Activity(c echo.Context) error {
+	owner, err := parseOwner(c)
+	if err != nil {
+		return err
+	}
+	var limit int
+	if v := c.QueryParam("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return ErrInvalidQuery
+		}
+	}
+
+	activity, err := h.queries.RecentActivity(c.Request().Context(), actor(c), owner, limit)
+	if err != nil {
+		return err
+	}
+
+	responses := make([]TaskActivityResponse, len(activity))
+	for i, a := range activity {
+		responses[i] = toActivityResponse(a)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+func toActivityResponse(a domain.TaskActivity) TaskActivityResponse {
+	return TaskActivityResponse{
+		Kind:    string(a.Kind),
+		TaskID:  a.TaskID,
+		OwnerID: a.OwnerID,
+		Title:   a.Title,
+		Version: a.Version,
+		At:      a.At.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
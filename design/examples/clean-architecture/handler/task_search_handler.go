@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// TaskSearchHandler serves full-text search, from the index of
+// usecase.TaskSearchService, which may lag the writes by a moment
+type TaskSearchHandler struct {
+	search *usecase.TaskSearchService
+}
+
+func NewTaskSearchHandler(search *usecase.TaskSearchService) *TaskSearchHandler {
+	return &TaskSearchHandler{search: search}
+}
+
+type TaskSearchHitResponse struct {
+	TaskID  int64   `json:"task_id"`
+	OwnerID int64   `json:"owner_id"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+	// Highlights are fragments of the title and description, by field, with
+	// the matching words in <mark> and the rest HTML-escaped
+	Highlights map[string][]string `json:"highlights"`
+}
+
+type TaskSearchResponse struct {
+	Total int                     `json:"total"`
+	Hits  []TaskSearchHitResponse `json:"hits"`
+}
+
+// Search handles GET /tasks/search
+func (h *TaskSearchHandler) Search(c echo.Context) error {
+	owner, err := parseOwner(c)
+	if err != nil {
+		return err
+	}
+	query := domain.TaskSearchQuery{Text: c.QueryParam("q"), OwnerID: owner}
+	for name, into := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if v := c.QueryParam(name); v != "" {
+			if *into, err = strconv.Atoi(v); err != nil {
+				return ErrInvalidQuery
+			}
+		}
+	}
+
+	result, err := h.search.Search(c.Request().Context(), actor(c), query)
+	if err != nil {
+		return err
+	}
+
+	resp := TaskSearchResponse{Total: result.Total, Hits: make([]TaskSearchHitResponse, len(result.Hits))}
+	for i, hit := range result.Hits {
+		resp.Hits[i] = TaskSearchHitResponse{
+			TaskID:     hit.TaskID,
+			OwnerID:    hit.OwnerID,
+			Title:      hit.Title,
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
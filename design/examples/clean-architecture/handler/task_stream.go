@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StreamContentType is the media type of GET /tasks/stream
+const StreamContentType = "text/event-stream"
+
+// streamHeartbeat is how often an idle stream gets a comment line, so that
+// proxies between the server and the client do not time it out
+const streamHeartbeat = 15 * time.Second
+
+// StreamTasks handles GET /tasks/stream, the changes to the tasks the caller
+// may view, as they happen, as Server-Sent Events. A user watches their own
+// tasks; an admin watches everyone's, or one user's with owner=ID as on
+// GET /tasks.
+//
+// Each event is named task.created, task.updated, task.deleted or
+// task.archived, and its data is the task as GET /tasks/:id returns it in
+// the same version; for task.deleted and task.archived, as it was. The
+// stream opens with a comment line: changes stored from then on are on it.
+// It ends when the client goes away or the server shuts down.
+func (h *TaskHandler) StreamTasks(c echo.Context) error {
+	owner, err := parseOwner(c)
+	if err != nil {
+		return err
+	}
+
+	events, err := h.taskUseCase.WatchTasks(c.Request().Context(), actor(c), owner)
+	if err != nil {
+		return err
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, StreamContentType)
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, ": watching\n\n"); err != nil {
+		return nil // the client has gone; there is nobody to tell
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data, _ := json.Marshal(presenter(c).Task(c, &event.Task))
+			_, err = fmt.Fprintf(w, "event: task.%s\ndata: %s\n\n", event.Type, data)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err != nil {
+			return nil
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,396 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GET /tasks/stream is watched over real HTTP connections, since what is
+// checked is when events arrive and when streams end.
+
+// streamWait is how long an event may take to arrive
+const streamWait = 2 * time.Second
+
+// streamApp is the server as main.go wires it, on in-memory stores
+type streamApp struct {
+	e      *echo.Echo
+	events *infrastructure.TaskEventBus
+	auth   *usecase.AuthUseCase
+	users  domain.UserRepository
+}
+
+func newStreamApp(t *testing.T) *streamApp {
+	t.Helper()
+	users := repository.NewMemoryUserRepository()
+	tokens, err := infrastructure.NewJWTTokens([]byte(secret), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := usecase.NewAuthUseCase(users, infrastructure.BcryptHasher{Cost: bcrypt.MinCost}, tokens)
+	events := infrastructure.NewTaskEventBus()
+	taskUseCase := usecase.NewTaskUseCaseWithEvents(repository.NewMemoryTaskRepository(), events)
+	userUseCase := usecase.NewUserUseCase(users)
+
+	e := echo.New()
+	e.HideBanner, e.HidePort = true, true
+	e.HTTPErrorHandler = handler.ErrorHandler
+	handler.RegisterRoutes(handler.NewRouteTable(e), handler.Handlers{
+		Auth:         handler.NewAuthHandler(auth),
+		Tasks:        handler.NewTaskHandler(taskUseCase),
+		Users:        handler.NewUserHandler(userUseCase),
+		GraphQL:      handler.NewGraphQLHandler(taskUseCase, userUseCase),
+		Authenticate: handler.RequireUser(auth),
+	})
+	e.Server.RegisterOnShutdown(func() { events.Close(context.Background()) })
+	return &streamApp{e: e, events: events, auth: auth, users: users}
+}
+
+// serve serves a from httptest and returns its URL
+func (a *streamApp) serve(t *testing.T) string {
+	srv := httptest.NewServer(a.e)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// streamClient calls a server as one account
+type streamClient struct {
+	base  string
+	token string
+	user  *domain.User
+}
+
+func (a *streamApp) login(t *testing.T, base, email string, role domain.Role) streamClient {
+	t.Helper()
+	ctx := context.Background()
+	user, err := a.auth.Register(ctx, email, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.users.SetRole(ctx, user.ID, role); err != nil {
+		t.Fatal(err)
+	}
+	session, err := a.auth.Login(ctx, email, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return streamClient{base: base, token: session.Token, user: user}
+}
+
+func (cl streamClient) do(t *testing.T, method, path, body string) (int, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(method, cl.base+path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if cl.token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+cl.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, data
+}
+
+func (cl streamClient) create(t *testing.T, title string) handler.TaskResponse {
+	t.Helper()
+	_, body := cl.do(t, http.MethodPost, "/tasks", `{"title":"`+title+`"}`)
+	var task handler.TaskResponse
+	json.Unmarshal(body, &task)
+	return task
+}
+
+type sseEvent struct {
+	name string
+	task handler.TaskResponse
+}
+
+func (e sseEvent) String() string {
+	return fmt.Sprintf("%s %q", e.name, e.task.Title)
+}
+
+// sseStream is an open GET /tasks/stream
+type sseStream struct {
+	events <-chan sseEvent
+	close  func()
+}
+
+// watch opens a stream and returns once it is open, so that changes made
+// from then on are on it. It returns the problem code if it is refused.
+func (cl streamClient) watch(t *testing.T, query string) (*sseStream, int, domain.Code) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, cl.base+"/tasks/stream"+query, nil)
+	if cl.token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+cl.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var problem handler.Problem
+		json.NewDecoder(resp.Body).Decode(&problem)
+		resp.Body.Close()
+		cancel()
+		return nil, resp.StatusCode, problem.Code
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	lines.Scan() // ": watching"
+	lines.Scan() // and the blank line ending it
+	events := make(chan sseEvent, 1024)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		var e sseEvent
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				e.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.task)
+			case line == "" && e.name != "":
+				events <- e
+				e = sseEvent{}
+			}
+		}
+	}()
+	s := &sseStream{events: events, close: cancel}
+	t.Cleanup(s.close)
+	return s, resp.StatusCode, ""
+}
+
+// next returns the next event, or false if none arrives in time or the
+// stream has ended
+func (s *sseStream) next() (sseEvent, bool) {
+	select {
+	case e, ok := <-s.events:
+		return e, ok
+	case <-time.After(streamWait):
+		return sseEvent{}, false
+	}
+}
+
+// ended reports whether the stream ends in time
+func (s *sseStream) ended() bool {
+	deadline := time.After(streamWait)
+	for {
+		select {
+		case _, ok := <-s.events:
+			if !ok {
+				return true
+			}
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// expect checks the stream's next events are named names, with titles
+func (s *sseStream) expect(t *testing.T, what string, want ...string) {
+	t.Helper()
+	var got []string
+	for range want {
+		e, ok := s.next()
+		if !ok {
+			break
+		}
+		got = append(got, e.String())
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+// watchers reports whether the bus has n watchers in time. A stream's
+// watcher goes once the server notices its client has gone.
+func (a *streamApp) watchers(n int) bool {
+	deadline := time.Now().Add(streamWait)
+	for a.events.Watchers() != n {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestStreamEachWatcherSeesWhatItMayView(t *testing.T) {
+	a := newStreamApp(t)
+	base := a.serve(t)
+	alice := a.login(t, base, "alice@example.com", domain.RoleUser)
+	bob := a.login(t, base, "bob@example.com", domain.RoleUser)
+	admin := a.login(t, base, "admin@example.com", domain.RoleAdmin)
+
+	asAlice, _, _ := alice.watch(t, "")
+	asBob, _, _ := bob.watch(t, "")
+	everyone, _, _ := admin.watch(t, "")
+	bobs, _, _ := admin.watch(t, fmt.Sprintf("?owner=%d", bob.user.ID))
+
+	report := alice.create(t, "Write report")
+	alice.do(t, http.MethodPut, fmt.Sprintf("/tasks/%d", report.ID), `{"title":"Write the report","version":1}`)
+	alice.do(t, http.MethodDelete, fmt.Sprintf("/tasks/%d", report.ID), "")
+	bob.create(t, "Fix bike")
+	alice.create(t, "Plan trip")
+
+	if e, _ := asAlice.next(); e.name != "task.created" || e.task.ID != report.ID || e.task.OwnerID != alice.user.ID || e.task.Version != 1 || len(e.task.Tags) != 0 {
+		t.Errorf("first event = %s %+v, want task.created with the task as GET /tasks/:id returns it", e.name, e.task)
+	}
+	if e, _ := asAlice.next(); e.name != "task.updated" || e.task.Title != "Write the report" || e.task.Version != 2 {
+		t.Errorf("second event = %s %+v, want task.updated with the new version", e.name, e.task)
+	}
+	if e, _ := asAlice.next(); e.name != "task.deleted" || e.task.ID != report.ID || e.task.Title != "Write the report" {
+		t.Errorf("third event = %s %+v, want task.deleted with the task as it was", e.name, e.task)
+	}
+	asAlice.expect(t, "alice then sees her next task, and none of bob's", `task.created "Plan trip"`)
+	asBob.expect(t, "bob sees only his own", `task.created "Fix bike"`)
+	everyone.expect(t, "an admin watching everyone sees every change, in order",
+		`task.created "Write report"`, `task.updated "Write the report"`, `task.deleted "Write the report"`, `task.created "Fix bike"`, `task.created "Plan trip"`)
+	bobs.expect(t, "an admin watching bob sees only bob's", `task.created "Fix bike"`)
+}
+
+func TestStreamEveryRouteThatChangesTasks(t *testing.T) {
+	a := newStreamApp(t)
+	base := a.serve(t)
+	alice := a.login(t, base, "alice@example.com", domain.RoleUser)
+	admin := a.login(t, base, "admin@example.com", domain.RoleAdmin)
+	asAlice, _, _ := alice.watch(t, "")
+
+	alice.do(t, http.MethodPost, "/tasks/bulk", `{"tasks":[{"title":"Buy milk"},{"title":""},{"title":"Buy bread"}]}`)
+	asAlice.expect(t, "a bulk create has an event per task created", `task.created "Buy milk"`, `task.created "Buy bread"`)
+	alice.do(t, http.MethodPost, "/tasks/bulk/complete", `{"ids":[999]}`)
+	alice.do(t, http.MethodPost, "/graphql", `{"query":"mutation { createTask(input: {title: \"From GraphQL\"}) { id } }"}`)
+	asAlice.expect(t, "a failed bulk item has none, and GraphQL mutations have theirs", `task.created "From GraphQL"`)
+
+	status, body := alice.do(t, http.MethodGet, "/tasks", "")
+	var tasks []handler.TaskResponse
+	json.Unmarshal(body, &tasks)
+	if status != http.StatusOK || len(tasks) == 0 {
+		t.Fatalf("GET /tasks = %d %s", status, body)
+	}
+	alice.do(t, http.MethodPost, "/tasks/bulk/complete", fmt.Sprintf(`{"ids":[%d]}`, tasks[0].ID))
+	if e, _ := asAlice.next(); e.name != "task.updated" || e.task.ID != tasks[0].ID || !e.task.Completed {
+		t.Errorf("completing a task = %s %+v, want an update with completed set", e.name, e.task)
+	}
+	alice.do(t, http.MethodPut, fmt.Sprintf("/tasks/%d", tasks[0].ID), `{"title":"Stale","version":1}`)
+	admin.do(t, http.MethodDelete, fmt.Sprintf("/tasks/%d", tasks[0].ID), "")
+	alice.create(t, "After the refusals")
+	asAlice.expect(t, "changes refused, for a stale version or by the policy, have none", `task.created "After the refusals"`)
+}
+
+func TestStreamAccess(t *testing.T) {
+	a := newStreamApp(t)
+	base := a.serve(t)
+	alice := a.login(t, base, "alice@example.com", domain.RoleUser)
+	bob := a.login(t, base, "bob@example.com", domain.RoleUser)
+	tests := []struct {
+		name   string
+		caller streamClient
+		query  string
+		status int
+		code   domain.Code
+	}{
+		{"without a token", streamClient{base: base}, "", http.StatusUnauthorized, handler.ErrMissingToken.Code},
+		{"a user watching another owner", alice, fmt.Sprintf("?owner=%d", bob.user.ID), http.StatusForbidden, usecase.ErrForbidden.Code},
+		{"a malformed owner", alice, "?owner=x", http.StatusBadRequest, handler.ErrInvalidQuery.Code},
+		{"a user naming themselves", alice, fmt.Sprintf("?owner=%d", alice.user.ID), http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, status, code := tt.caller.watch(t, tt.query)
+			if status != tt.status || code != tt.code {
+				t.Errorf("watch = %d %s, want %d %s", status, code, tt.status, tt.code)
+			}
+			if s != nil {
+				s.close()
+			}
+		})
+	}
+}
+
+func TestStreamWatcherThatStopsReadingHoldsNobodyUp(t *testing.T) {
+	a := newStreamApp(t)
+	base := a.serve(t)
+	carol := a.login(t, base, "carol@example.com", domain.RoleUser)
+	carol.watch(t, "") // never read
+	live, _, _ := carol.watch(t, "")
+
+	const burst = 500
+	start := time.Now()
+	for i := 0; i < burst; i++ {
+		carol.create(t, fmt.Sprintf("Task %d", i))
+	}
+	if took := time.Since(start); took > 10*time.Second {
+		t.Errorf("%d creates with a watcher that never reads took %v", burst, took)
+	}
+	got := 0
+	for got < burst {
+		if _, ok := live.next(); !ok {
+			break
+		}
+		got++
+	}
+	if got != burst {
+		t.Errorf("a watcher reading along got %d events, want %d", got, burst)
+	}
+}
+
+func TestStreamEndsWhenItsClientGoes(t *testing.T) {
+	a := newStreamApp(t)
+	base := a.serve(t)
+	dave := a.login(t, base, "dave@example.com", domain.RoleUser)
+	s, _, _ := dave.watch(t, "")
+	if n := a.events.Watchers(); n != 1 {
+		t.Errorf("an open stream makes %d watchers, want 1", n)
+	}
+	s.close()
+	if !a.watchers(0) {
+		t.Errorf("a client that went away is still one of %d watchers", a.events.Watchers())
+	}
+}
+
+func TestStreamEndsOnShutdown(t *testing.T) {
+	a := newStreamApp(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.e.Listener = ln
+	go a.e.Start("")
+	base := "http://" + ln.Addr().String()
+	erin := a.login(t, base, "erin@example.com", domain.RoleUser)
+	s, _, _ := erin.watch(t, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := a.e.Shutdown(ctx); err != nil || time.Since(start) > time.Second {
+		t.Errorf("shutting down with a stream open = %v after %v, want it done without waiting out the timeout", err, time.Since(start))
+	}
+	if !s.ended() {
+		t.Error("the stream did not end")
+	}
+	if _, err := a.events.Subscribe(context.Background(), 0); err != domain.ErrTaskEventsStopped {
+		t.Errorf("subscribing after shutdown = %v, want %v", err, domain.ErrTaskEventsStopped)
+	}
+}
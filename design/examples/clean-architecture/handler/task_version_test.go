@@ -0,0 +1,112 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// versionAPI sends requests to the task routes
+type versionAPI struct {
+	e *echo.Echo
+}
+
+func newVersionAPI() versionAPI {
+	h := handler.NewTaskHandler(usecase.NewTaskUseCase(repository.NewMemoryTaskRepository()))
+	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
+	tasks := e.Group("/tasks", handler.AsUser(&domain.User{ID: 1, Role: domain.RoleUser}))
+	tasks.POST("", h.CreateTask)
+	tasks.GET("/:id", h.GetTask)
+	tasks.PUT("/:id", h.UpdateTask)
+	return versionAPI{e: e}
+}
+
+// do sends body as JSON and decodes the response into out, returning the
+// status and the problem code, if any
+func (a versionAPI) do(t *testing.T, method, path string, body, out interface{}) (int, domain.Code) {
+	t.Helper()
+	var in bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&in).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &in)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	a.e.ServeHTTP(rec, req)
+	if rec.Code >= 400 {
+		var problem handler.Problem
+		json.Unmarshal(rec.Body.Bytes(), &problem)
+		return rec.Code, problem.Code
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, ""
+}
+
+// edit is a client's change to a task it read earlier
+func edit(read handler.TaskResponse, title string, completed, versioned bool) handler.UpdateTaskRequest {
+	req := handler.UpdateTaskRequest{Title: title, Description: read.Description, Completed: completed}
+	if versioned {
+		req.Version = read.Version
+	}
+	return req
+}
+
+// TestLostUpdate has two clients read a task, then Alice retitle it and Bob
+// complete it, each sending back the whole task as they read it
+func TestLostUpdate(t *testing.T) {
+	start := func(t *testing.T) (a versionAPI, path string, alice, bob handler.TaskResponse) {
+		a = newVersionAPI()
+		var created handler.TaskResponse
+		a.do(t, http.MethodPost, "/tasks", map[string]string{"title": "Write report"}, &created)
+		path = "/tasks/" + strconv.FormatInt(created.ID, 10)
+		a.do(t, http.MethodGet, path, nil, &alice)
+		a.do(t, http.MethodGet, path, nil, &bob)
+		return a, path, alice, bob
+	}
+
+	t.Run("without versions Bob's write undoes Alice's", func(t *testing.T) {
+		a, path, alice, bob := start(t)
+		if status, code := a.do(t, http.MethodPut, path, edit(alice, "Write quarterly report", false, false), nil); status != http.StatusOK {
+			t.Fatalf("Alice's retitle = %d %s", status, code)
+		}
+		var final handler.TaskResponse
+		a.do(t, http.MethodPut, path, edit(bob, bob.Title, true, false), nil)
+		a.do(t, http.MethodGet, path, nil, &final)
+		if final.Title != "Write report" || !final.Completed {
+			t.Errorf("the task is %q completed=%t; this is the lost update the version guards against", final.Title, final.Completed)
+		}
+	})
+
+	t.Run("with versions Bob conflicts and rereads", func(t *testing.T) {
+		a, path, alice, bob := start(t)
+		if status, code := a.do(t, http.MethodPut, path, edit(alice, "Write quarterly report", false, true), nil); status != http.StatusOK {
+			t.Fatalf("Alice's retitle = %d %s", status, code)
+		}
+		status, code := a.do(t, http.MethodPut, path, edit(bob, bob.Title, true, true), nil)
+		if status != http.StatusConflict || code != domain.ErrVersionConflict.Code {
+			t.Fatalf("Bob's write from version %d = %d %s, want 409 %s", bob.Version, status, code, domain.ErrVersionConflict.Code)
+		}
+		a.do(t, http.MethodGet, path, nil, &bob)
+		var final handler.TaskResponse
+		if status, code := a.do(t, http.MethodPut, path, edit(bob, bob.Title, true, true), &final); status != http.StatusOK {
+			t.Fatalf("Bob's write after rereading = %d %s", status, code)
+		}
+		if final.Title != "Write quarterly report" || !final.Completed || final.Version != 3 {
+			t.Errorf("the task is %q completed=%t version %d; want Alice's title, completed, version 3", final.Title, final.Completed, final.Version)
+		}
+	})
+}
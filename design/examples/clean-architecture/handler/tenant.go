@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net"
+	"strings"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// Every request acts for a tenant, which ResolveTenant puts in its context
+// for the use cases and repositories (domain.WithTenant). An account only
+// exists in the tenant it registered in, so a token only works there, and
+// the use cases then act for the tenant of the token's user whatever the
+// request names.
+
+// HeaderTenantID names the tenant of a request
+const HeaderTenantID = "X-Tenant-ID"
+
+// ResolveTenant sets the tenant of each request: the X-Tenant-ID header if
+// it has one, else the subdomain of its Host under baseDomain, so that
+// acme.tasks.example.com is the tenant acme for the base domain
+// tasks.example.com, else domain.DefaultTenant. An empty baseDomain turns
+// subdomains off. A tenant that is not a valid TenantID fails the request
+// with ErrInvalidTenant.
+func ResolveTenant(baseDomain string) echo.MiddlewareFunc {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			name := c.Request().Header.Get(HeaderTenantID)
+			if name == "" && suffix != "." {
+				name = subdomain(c.Request().Host, suffix)
+			}
+			tenant := domain.DefaultTenant
+			if name != "" {
+				var err error
+				if tenant, err = domain.ParseTenantID(name); err != nil {
+					return err
+				}
+			}
+			req := c.Request()
+			c.SetRequest(req.WithContext(domain.WithTenant(req.Context(), tenant)))
+			return next(c)
+		}
+	}
+}
+
+// subdomain returns what host has before suffix, "" if it is not under it.
+// A name of several labels is returned whole, to be rejected.
+func subdomain(host, suffix string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	name, ok := strings.CutSuffix(host, suffix)
+	if !ok {
+		return ""
+	}
+	return name
+}
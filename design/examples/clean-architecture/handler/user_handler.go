@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// UserHandler serves the account administration routes. They sit behind
+// RequireRole(domain.RoleAdmin), and the use case checks the role again.
+type UserHandler struct {
+	userUseCase *usecase.UserUseCase
+}
+
+func NewUserHandler(userUseCase *usecase.UserUseCase) *UserHandler {
+	return &UserHandler{
+		userUseCase: userUseCase,
+	}
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// ListUsers handles GET /admin/users
+func (h *UserHandler) ListUsers(c echo.Context) error {
+	users, err := h.userUseCase.ListUsers(c.Request().Context(), actor(c))
+	if err != nil {
+		return err
+	}
+
+	responses := make([]UserResponse, len(users))
+	for i, user := range users {
+		responses[i] = toUserResponse(user)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+// SetRole handles PUT /admin/users/:id/role
+func (h *UserHandler) SetRole(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+
+	var req SetRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return ErrInvalidBody
+	}
+
+	user, err := h.userUseCase.SetRole(c.Request().Context(), actor(c), id, req.Role)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
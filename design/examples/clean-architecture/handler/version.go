@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APIVersion is one version of the API, served under /api/<Name>
+type APIVersion struct {
+	Name      string // v1, v2, ...
+	Presenter TaskPresenter
+}
+
+// Prefix is the path the version's routes are under: /api/v1
+func (v APIVersion) Prefix() string {
+	return "/api/" + v.Name
+}
+
+// APIVersions are the versions RegisterRoutes serves, oldest first. v2
+// gathers a task's times in timestamps; v3 adds links to tasks, and pages
+// listings (hypermedia.go). Otherwise they are the same.
+var APIVersions = []APIVersion{
+	{Name: "v1", Presenter: TaskPresenterV1{}},
+	{Name: "v2", Presenter: TaskPresenterV2{}},
+	{Name: "v3", Presenter: TaskPresenterV3{}},
+}
+
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderLink        = "Link"
+)
+
+// unversionedSince is when the paths without a version were deprecated, as
+// sent in Deprecation (RFC 9745)
+var unversionedSince = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+// unversioned answers the requests for a path under one of prefixes, such
+// as /tasks/1, as if they were for v's, /api/v1/tasks/1: the API was served
+// without a version before there were several. Such responses are marked
+// deprecated, with a Link to the path to use instead. It runs before routing
+// (echo's Pre), so that the rest of the request is v's from the start.
+func unversioned(v APIVersion, prefixes ...string) echo.MiddlewareFunc {
+	deprecation := "@" + strconv.FormatInt(unversionedSince.Unix(), 10)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			url := c.Request().URL
+			for _, prefix := range prefixes {
+				if url.Path != prefix && !strings.HasPrefix(url.Path, prefix+"/") {
+					continue
+				}
+				url.Path = v.Prefix() + url.Path
+				if url.RawPath != "" {
+					url.RawPath = v.Prefix() + url.RawPath
+				}
+				header := c.Response().Header()
+				header.Set(HeaderDeprecation, deprecation)
+				header.Add(HeaderLink, "<"+url.EscapedPath()+`>; rel="successor-version"`)
+				break
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package i18n translates the messages of coded errors into the language a
+// client asks for. Errors are declared with English messages, so English
+// needs no catalog; every other language has one in catalogs/, a JSON object
+// from code to message named for the language's BCP 47 tag, such as vi.json.
+// A message a catalog lacks is answered in English.
+//
+// Codes never change with the language: only the message people read does.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"golang.org/x/text/language"
+)
+
+// Catalog holds the messages of one language, by error code
+type Catalog map[domain.Code]string
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// Translator picks a language for each request and translates messages into
+// it. It is safe for concurrent use.
+type Translator struct {
+	languages []language.Tag // English first, the fallback
+	catalogs  map[language.Tag]Catalog
+	matcher   language.Matcher
+}
+
+// New returns a Translator for English and the language of every catalog in
+// catalogs/
+func New() (*Translator, error) {
+	names, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		return nil, err
+	}
+	catalogs := map[language.Tag]Catalog{}
+	for _, entry := range names {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		tag, err := language.Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("i18n: catalog %s: %w", entry.Name(), err)
+		}
+		data, err := catalogFiles.ReadFile(path.Join("catalogs", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var catalog Catalog
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("i18n: catalog %s: %w", entry.Name(), err)
+		}
+		catalogs[tag] = catalog
+	}
+	return NewTranslator(catalogs), nil
+}
+
+// NewTranslator returns a Translator for English and the languages of
+// catalogs. A catalog for English is ignored: its messages are the ones the
+// errors are declared with.
+func NewTranslator(catalogs map[language.Tag]Catalog) *Translator {
+	t := &Translator{languages: []language.Tag{language.English}, catalogs: map[language.Tag]Catalog{}}
+	tags := make([]language.Tag, 0, len(catalogs))
+	for tag := range catalogs {
+		if tag != language.English {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].String() < tags[j].String() })
+	for _, tag := range tags {
+		t.languages = append(t.languages, tag)
+		t.catalogs[tag] = catalogs[tag]
+	}
+	t.matcher = language.NewMatcher(t.languages)
+	return t
+}
+
+// Languages returns the languages t translates into, English first
+func (t *Translator) Languages() []language.Tag {
+	return append([]language.Tag(nil), t.languages...)
+}
+
+// Catalog returns the messages of lang, nil for English or a language t
+// does not have
+func (t *Translator) Catalog(lang language.Tag) Catalog {
+	return t.catalogs[lang]
+}
+
+// Negotiate returns the language of t that best matches an Accept-Language
+// header, such as "vi-VN,vi;q=0.9,en;q=0.8": English if the header is
+// empty, malformed or names none of them
+func (t *Translator) Negotiate(acceptLanguage string) language.Tag {
+	// The matcher answers with the tag the client asked for, such as vi-VN;
+	// the index is the language of t it matched
+	_, i := language.MatchStrings(t.matcher, acceptLanguage)
+	return t.languages[i]
+}
+
+// Message returns e's message in lang, or its English message if lang has
+// none for it
+func (t *Translator) Message(lang language.Tag, e *domain.Error) string {
+	if message, ok := t.catalogs[lang][e.Code]; ok {
+		return message
+	}
+	return e.Message
+}
@@ -0,0 +1,98 @@
+package i18n_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/i18n"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"golang.org/x/text/language"
+)
+
+// Every package that declares errors is imported, so domain.Codes has them
+// all
+var _, _ = usecase.ErrForbidden, handler.ErrRejected
+
+func newTranslator(t *testing.T) *i18n.Translator {
+	t.Helper()
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return translator
+}
+
+func TestCatalogs(t *testing.T) {
+	translator := newTranslator(t)
+	languages := translator.Languages()
+	if want := []language.Tag{language.English, language.Vietnamese}; !reflect.DeepEqual(languages, want) {
+		t.Fatalf("Languages = %v, want %v, English first as the fallback", languages, want)
+	}
+	if translator.Catalog(language.English) != nil {
+		t.Error("English has a catalog, want its messages to be the declared ones")
+	}
+	for _, lang := range languages[1:] {
+		catalog := translator.Catalog(lang)
+		for _, e := range domain.Codes() {
+			if message, ok := catalog[e.Code]; !ok || message == "" {
+				t.Errorf("%s does not translate %s", lang, e.Code)
+			} else if message == e.Message {
+				t.Errorf("%s leaves %s in English", lang, e.Code)
+			}
+		}
+		for code := range catalog {
+			if _, ok := domain.Lookup(code); !ok {
+				t.Errorf("%s has a message for %s, which is not declared", lang, code)
+			}
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	translator := newTranslator(t)
+	partial := i18n.NewTranslator(map[language.Tag]i18n.Catalog{language.French: {"TASK_NOT_FOUND": "tâche introuvable"}})
+	tests := []struct {
+		name       string
+		translator *i18n.Translator
+		lang       language.Tag
+		err        *domain.Error
+		want       string
+	}{
+		{"English, the declared one", translator, language.English, domain.ErrEmptyTitle, domain.ErrEmptyTitle.Message},
+		{"Vietnamese, from vi.json", translator, language.Vietnamese, domain.ErrEmptyTitle, "tiêu đề công việc không được để trống"},
+		{"French, from its catalog", partial, language.French, usecase.ErrTaskNotFound, "tâche introuvable"},
+		{"French, which its catalog lacks", partial, language.French, domain.ErrEmptyTitle, domain.ErrEmptyTitle.Message},
+	}
+	for _, tt := range tests {
+		if got := tt.translator.Message(tt.lang, tt.err); got != tt.want {
+			t.Errorf("the message in %s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	translator := newTranslator(t)
+	tests := []struct {
+		header string
+		want   language.Tag
+	}{
+		{"", language.English},
+		{"vi", language.Vietnamese},
+		{"vi-VN", language.Vietnamese},
+		{"en-US,en;q=0.9", language.English},
+		{"vi-VN,vi;q=0.9,en;q=0.8", language.Vietnamese},
+		{"en;q=0.5, vi", language.Vietnamese},
+		{"fr", language.English},
+		{"fr-FR, vi;q=0.3", language.Vietnamese},
+		{"*", language.English},
+		{"vi;q=0, en", language.English},
+		{";;not a header", language.English},
+	}
+	for _, tt := range tests {
+		if got := translator.Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher implements usecase.PasswordHasher. Cost 0 means
+// bcrypt.DefaultCost; checks that create many accounts use bcrypt.MinCost.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	cost := h.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	return string(hash), err
+}
+
+func (h BcryptHasher) Matches(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// MinJWTSecretLength is the shortest HS256 key JWTTokens accepts: a key
+// shorter than the hash output weakens the signature
+const MinJWTSecretLength = 32
+
+const jwtIssuer = "task-api"
+
+// JWTTokens implements usecase.TokenService with HS256-signed JWTs. The
+// subject is the user ID; nothing else about the user is put in the token,
+// since its payload is only encoded, not encrypted.
+type JWTTokens struct {
+	secret []byte
+	ttl    time.Duration
+	clock  domain.Clock
+}
+
+func NewJWTTokens(secret []byte, ttl time.Duration) (*JWTTokens, error) {
+	if len(secret) < MinJWTSecretLength {
+		return nil, fmt.Errorf("JWT secret must be at least %d bytes, got %d", MinJWTSecretLength, len(secret))
+	}
+	return &JWTTokens{secret: secret, ttl: ttl, clock: domain.SystemClock}, nil
+}
+
+// WithClock returns a copy that reads the time from clock, so checks can
+// issue tokens that are already expired
+func (t *JWTTokens) WithClock(clock domain.Clock) *JWTTokens {
+	clone := *t
+	clone.clock = clock
+	return &clone
+}
+
+func (t *JWTTokens) Issue(user *domain.User) (string, time.Time, error) {
+	now := t.clock.Now()
+	expiresAt := now.Add(t.ttl)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Issuer:    jwtIssuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	signed, err := token.SignedString(t.secret)
+	return signed, expiresAt, err
+}
+
+// Verify accepts only HS256, so a token cannot pick a weaker algorithm
+// (such as "none") for itself, and requires an expiry
+func (t *JWTTokens) Verify(token string) (int64, error) {
+	var claims jwt.StandardClaims
+	parser := jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}, SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.secret, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := t.clock.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuer(jwtIssuer, true) {
+		return 0, errors.New("token expired or not issued here")
+	}
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("token subject %q is not a user id", claims.Subject)
+	}
+	return id, nil
+}
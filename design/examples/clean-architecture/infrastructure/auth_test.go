@@ -0,0 +1,86 @@
+package infrastructure_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/golang-jwt/jwt"
+)
+
+var secret = []byte("auth-test-secret-at-least-32-bytes!")
+
+func newTokens(t *testing.T) *infrastructure.JWTTokens {
+	t.Helper()
+	tokens, err := infrastructure.NewJWTTokens(secret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func TestJWTTokensVerifyWhatTheyIssue(t *testing.T) {
+	if _, err := infrastructure.NewJWTTokens([]byte("short"), time.Hour); err == nil {
+		t.Error("a secret under 32 bytes was accepted")
+	}
+	tokens := newTokens(t)
+	token, expiresAt, err := tokens.Issue(&domain.User{ID: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := tokens.Verify(token); err != nil || id != 42 {
+		t.Errorf("Verify = %d, %v, want 42", id, err)
+	}
+	if lifetime := time.Until(expiresAt); lifetime < 59*time.Minute || lifetime > time.Hour {
+		t.Errorf("the token expires in %s, want an hour", lifetime)
+	}
+}
+
+func TestJWTTokensRejectForgeries(t *testing.T) {
+	tokens := newTokens(t)
+	valid, _, err := tokens.Issue(&domain.User{ID: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(method jwt.SigningMethod, claims jwt.StandardClaims, key interface{}) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	inAnHour := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"a tampered signature", func() string { return valid[:len(valid)-2] + "xx" }},
+		{"another secret", func() string {
+			other, _ := infrastructure.NewJWTTokens([]byte("another-secret-that-is-32-bytes-long"), time.Hour)
+			token, _, _ := other.Issue(&domain.User{ID: 42})
+			return token
+		}},
+		{"expired", func() string {
+			token, _, _ := tokens.WithClock(clocktest.New(time.Now().Add(-2 * time.Hour))).Issue(&domain.User{ID: 42})
+			return token
+		}},
+		{`alg "none"`, func() string {
+			return sign(jwt.SigningMethodNone, jwt.StandardClaims{Subject: "42", Issuer: "task-api", ExpiresAt: inAnHour},
+				jwt.UnsafeAllowNoneSignatureType)
+		}},
+		{"no expiry", func() string {
+			return sign(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "42", Issuer: "task-api"}, secret)
+		}},
+		{"not a token", func() string { return "not.a.token" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if id, err := tokens.Verify(tt.token()); err == nil {
+				t.Errorf("Verify accepted it as user %d", id)
+			}
+		})
+	}
+}
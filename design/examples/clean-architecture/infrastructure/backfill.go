@@ -0,0 +1,151 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// BackfillDetails copies tasks.description into tasks.details for rows that
+// do not have details yet, batchSize rows per transaction in id order, so it
+// never holds a long lock on a live table. Rows written by dual-writing code
+// already have details and are left alone.
+//
+// Progress is checkpointed in the backfills table with each batch: a killed
+// or cancelled run resumes after the last committed batch.
+
+const detailsBackfill = "tasks.details"
+
+type BackfillProgress struct {
+	LastID  int64 // highest id processed
+	Scanned int64 // rows processed, including earlier runs
+	Total   int64 // Scanned plus rows left when this run started
+	Copied  int64 // rows whose details were filled in
+	Done    bool
+}
+
+func (p BackfillProgress) String() string {
+	pct := 100.0
+	if p.Total > 0 {
+		pct = 100 * float64(p.Scanned) / float64(p.Total)
+	}
+	return fmt.Sprintf("%d/%d rows (%.0f%%), %d copied, last id %d", p.Scanned, p.Total, pct, p.Copied, p.LastID)
+}
+
+func ensureBackfills(db *sqlx.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS backfills (
+		name TEXT PRIMARY KEY,
+		last_id BIGINT NOT NULL DEFAULT 0,
+		scanned BIGINT NOT NULL DEFAULT 0,
+		copied BIGINT NOT NULL DEFAULT 0,
+		done BOOLEAN NOT NULL DEFAULT FALSE
+	)`)
+	return err
+}
+
+// DetailsBackfillStatus returns the checkpoint of the details backfill
+func DetailsBackfillStatus(db *sqlx.DB) (BackfillProgress, error) {
+	if err := ensureBackfills(db); err != nil {
+		return BackfillProgress{}, err
+	}
+	var p BackfillProgress
+	err := db.QueryRowx(db.Rebind(`SELECT last_id, scanned, copied, done FROM backfills WHERE name = ?`), detailsBackfill).
+		Scan(&p.LastID, &p.Scanned, &p.Copied, &p.Done)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	p.Total = p.Scanned
+	return p, err
+}
+
+func BackfillDetails(ctx context.Context, db *sqlx.DB, batchSize int, report func(BackfillProgress)) (BackfillProgress, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	p, err := DetailsBackfillStatus(db)
+	if err != nil {
+		return p, err
+	}
+	var left int64
+	if err := db.Get(&left, db.Rebind(`SELECT COUNT(*) FROM tasks WHERE id > ?`), p.LastID); err != nil {
+		return p, err
+	}
+	p.Total = p.Scanned + left
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return p, err
+		}
+		var ids []int64
+		if err := db.Select(&ids, db.Rebind(`SELECT id FROM tasks WHERE id > ? ORDER BY id LIMIT ?`), p.LastID, batchSize); err != nil {
+			return p, err
+		}
+		if len(ids) == 0 {
+			p.Done = true
+		}
+
+		next := p
+		if !p.Done {
+			next.LastID = ids[len(ids)-1]
+			next.Scanned += int64(len(ids))
+		}
+		tx, err := db.Beginx()
+		if err != nil {
+			return p, err
+		}
+		if !p.Done {
+			res, err := tx.Exec(tx.Rebind(`UPDATE tasks SET details = description
+				WHERE id > ? AND id <= ? AND details IS NULL AND description IS NOT NULL`), p.LastID, next.LastID)
+			if err != nil {
+				tx.Rollback()
+				return p, err
+			}
+			n, _ := res.RowsAffected()
+			next.Copied += n
+		}
+		if _, err := tx.Exec(tx.Rebind(`INSERT INTO backfills (name, last_id, scanned, copied, done) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET last_id = excluded.last_id, scanned = excluded.scanned,
+				copied = excluded.copied, done = excluded.done`),
+			detailsBackfill, next.LastID, next.Scanned, next.Copied, next.Done); err != nil {
+			tx.Rollback()
+			return p, err
+		}
+		if err := tx.Commit(); err != nil {
+			return p, err
+		}
+		if next.Scanned > next.Total {
+			next.Total = next.Scanned // rows inserted while running
+		}
+		p = next
+		if report != nil {
+			report(p)
+		}
+		if p.Done {
+			return p, nil
+		}
+	}
+}
+
+// ContractReady returns nil when dropping tasks.description loses nothing:
+// the backfill finished and every description also exists in details. Rows
+// still written by code that only knows description fail the check.
+func ContractReady(db *sqlx.DB) error {
+	p, err := DetailsBackfillStatus(db)
+	if err != nil {
+		return err
+	}
+	if !p.Done {
+		return fmt.Errorf("details backfill has not finished (%s)", p)
+	}
+	var missing int64
+	if err := db.Get(&missing, `SELECT COUNT(*) FROM tasks WHERE details IS NULL AND description IS NOT NULL`); err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d task(s) have a description but no details; is code that writes only description still running?", missing)
+	}
+	return nil
+}
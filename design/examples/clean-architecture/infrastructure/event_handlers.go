@@ -0,0 +1,117 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// LogEventHandler implements usecase.EventHandler by logging each event
+type LogEventHandler struct {
+	Logf func(format string, args ...any)
+}
+
+func (h LogEventHandler) HandleEvent(_ context.Context, event domain.Event) error {
+	h.Logf("event %d: %s task %d (owner %d) %q", event.ID, event.Name, event.TaskID, event.OwnerID, event.Title)
+	return nil
+}
+
+// LogReminderSender implements domain.ReminderSender by logging each
+// reminder, where a real one would email the task's owner
+type LogReminderSender struct {
+	Logf func(format string, args ...any)
+}
+
+func (s LogReminderSender) SendReminder(_ context.Context, r domain.Reminder) error {
+	due := "is due"
+	if r.Overdue {
+		due = "was due"
+	}
+	s.Logf("reminder: task %d (owner %d) %q %s at %s", r.TaskID, r.OwnerID, r.Title, due, r.DueAt.Format(time.RFC3339))
+	return nil
+}
+
+// Webhook headers. X-Event-ID is the same on every delivery of an event, so
+// a receiver can skip the ones it has already handled.
+const (
+	HeaderEventID        = "X-Event-ID"
+	HeaderEventSignature = "X-Event-Signature"
+)
+
+// WebhookTimeout bounds a single delivery when the client has no timeout
+const WebhookTimeout = 10 * time.Second
+
+// WebhookPayload is the JSON body POSTed for an event
+type WebhookPayload struct {
+	ID         int64     `json:"id"`
+	Event      string    `json:"event"`
+	TaskID     int64     `json:"task_id"`
+	OwnerID    int64     `json:"owner_id"`
+	Title      string    `json:"title"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// WebhookEventHandler implements usecase.EventHandler by POSTing each event
+// to URL as a WebhookPayload. Any answer but a 2xx fails the delivery, so the
+// dispatcher retries it. With a Secret, X-Event-Signature is sha256= and the
+// hex HMAC-SHA256 of the body under it, for the receiver to check.
+type WebhookEventHandler struct {
+	URL    string
+	Secret []byte
+	Client *http.Client // nil means one with WebhookTimeout
+}
+
+func (h WebhookEventHandler) HandleEvent(ctx context.Context, event domain.Event) error {
+	body, err := json.Marshal(WebhookPayload{
+		ID:         event.ID,
+		Event:      string(event.Name),
+		TaskID:     event.TaskID,
+		OwnerID:    event.OwnerID,
+		Title:      event.Title,
+		OccurredAt: event.OccurredAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, strconv.FormatInt(event.ID, 10))
+	if len(h.Secret) > 0 {
+		req.Header.Set(HeaderEventSignature, SignWebhook(h.Secret, body))
+	}
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: WebhookTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10)) // lets the connection be reused
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// SignWebhook returns the X-Event-Signature of body under secret
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
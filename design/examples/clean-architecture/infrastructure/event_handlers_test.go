@@ -0,0 +1,95 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// webhook is a receiver that answers with the statuses it is given, then 204
+type webhook struct {
+	mu       sync.Mutex
+	statuses []int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.received = append(w.received, r)
+	w.bodies = append(w.bodies, body)
+	status := http.StatusNoContent
+	if len(w.statuses) > 0 {
+		status, w.statuses = w.statuses[0], w.statuses[1:]
+	}
+	rw.WriteHeader(status)
+}
+
+func (w *webhook) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.received)
+}
+
+func TestWebhookEventHandler(t *testing.T) {
+	ctx := context.Background()
+	webhookSecret := []byte("webhook secret")
+	receiver := &webhook{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	repo := repository.NewMemoryTaskRepository()
+	clk := clocktest.New(time.Now().Add(time.Second))
+	d := usecase.NewEventDispatcher(repo.Outbox(), usecase.DispatcherOptions{Clock: clk},
+		infrastructure.WebhookEventHandler{URL: server.URL, Secret: webhookSecret})
+	pending := func() []domain.OutboxEvent {
+		events, err := repo.Outbox().Due(ctx, time.Now().Add(24*time.Hour), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+
+	owner := &domain.User{ID: 1, Role: domain.RoleUser}
+	if _, err := usecase.NewTaskUseCase(repo).CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Announce me"}); err != nil {
+		t.Fatal(err)
+	}
+	id := pending()[0].ID
+	n, _ := d.DispatchDue(ctx)
+	if events := pending(); n != 0 || len(events) != 1 || events[0].LastError != "webhook answered 503 Service Unavailable" {
+		t.Errorf("a webhook answering 503 = %d delivered, leaving %+v; want the delivery failed", n, events)
+	}
+	clk.Advance(time.Minute)
+	if n, _ := d.DispatchDue(ctx); n != 1 || receiver.count() != 2 || len(pending()) != 0 {
+		t.Fatalf("the retry = %d delivered after %d requests, want it delivered when answered 204", n, receiver.count())
+	}
+
+	receiver.mu.Lock()
+	req, body, first := receiver.received[1], receiver.bodies[1], receiver.bodies[0]
+	receiver.mu.Unlock()
+	var payload infrastructure.WebhookPayload
+	json.Unmarshal(body, &payload)
+	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" ||
+		payload.ID != id || payload.Event != "TaskCreated" || payload.Title != "Announce me" || payload.OwnerID != owner.ID {
+		t.Errorf("%s %s %s, want the event POSTed as JSON", req.Method, req.Header.Get("Content-Type"), body)
+	}
+	if got := req.Header.Get(infrastructure.HeaderEventID); got != strconv.FormatInt(id, 10) || string(first) != string(body) {
+		t.Errorf("%s = %q, first body %s; want the event ID, and the same body on every attempt", infrastructure.HeaderEventID, got, first)
+	}
+	if got := req.Header.Get(infrastructure.HeaderEventSignature); got != infrastructure.SignWebhook(webhookSecret, body) {
+		t.Errorf("%s = %q, want the body signed with the secret", infrastructure.HeaderEventSignature, got)
+	}
+}
@@ -0,0 +1,120 @@
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthCheckTimeout bounds each readiness check, so a hung dependency
+// fails the probe rather than outlasting it
+const HealthCheckTimeout = 2 * time.Second
+
+// A HealthCheck reports whether a dependency can serve requests now, such
+// as (*sqlx.DB).PingContext
+type HealthCheck func(ctx context.Context) error
+
+// HealthStatus is the body of both probes. Checks has the outcome of each
+// readiness check, "ok" or its error.
+type HealthStatus struct {
+	Status string            `json:"status"` // ok, unavailable or draining
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Health answers the probes of an orchestrator such as Kubernetes:
+//
+//	GET /healthz  liveness: 200 for as long as the server answers at all
+//	GET /readyz   readiness: 200 if every check passes, 503 otherwise
+//
+// A dependency being down makes the server unready, not dead: restarting it
+// would not bring the database back. Once Drain is called readiness stays
+// 503, so the load balancer stops sending requests before the server stops
+// taking them.
+type Health struct {
+	mu       sync.Mutex
+	names    []string
+	checks   map[string]HealthCheck
+	draining atomic.Bool
+}
+
+func NewHealth() *Health {
+	return &Health{checks: make(map[string]HealthCheck)}
+}
+
+// AddCheck adds a readiness check, reported under name
+func (h *Health) AddCheck(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// Live answers GET /healthz
+func (h *Health) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, HealthStatus{Status: "ok"})
+}
+
+// Ready answers GET /readyz, running every check at once
+func (h *Health) Ready(c echo.Context) error {
+	if h.draining.Load() {
+		return c.JSON(http.StatusServiceUnavailable, HealthStatus{Status: "draining"})
+	}
+	h.mu.Lock()
+	names := append([]string(nil), h.names...)
+	checks := make([]HealthCheck, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), HealthCheckTimeout)
+	defer cancel()
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := HealthStatus{Status: "ok", Checks: make(map[string]string, len(names))}
+	code := http.StatusOK
+	for i, name := range names {
+		status.Checks[name] = "ok"
+		if errs[i] != nil {
+			status.Checks[name] = errs[i].Error()
+			status.Status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	return c.JSON(code, status)
+}
+
+// Drain makes the server unready for good, then waits delay, or until ctx
+// ends, for the load balancer to see it. Run it as the Stop of a shutdown
+// component that depends on the HTTP server, so that it happens before the
+// server stops accepting connections.
+func (h *Health) Drain(ctx context.Context, delay time.Duration) error {
+	h.draining.Store(true)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Draining reports whether Drain has been called
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
@@ -0,0 +1,268 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/concurrency-example/shutdown"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// The probes and the order of a shutdown are checked on the server's
+// lifecycle as main.go wires it, on SQLite and a real listener.
+
+const drainDelay = 300 * time.Millisecond
+
+// timeline is what happened during the shutdown, in order
+type timeline struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (tl *timeline) record(event string) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.events = append(tl.events, event)
+}
+
+func (tl *timeline) has(event string) bool {
+	return tl.index(event) >= 0
+}
+
+func (tl *timeline) index(event string) int {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	for i, e := range tl.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func (tl *timeline) String() string {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return strings.Join(tl.events, ", ")
+}
+
+// before reports whether a and b both happened, a first
+func (tl *timeline) before(a, b string) bool {
+	i, j := tl.index(a), tl.index(b)
+	return i >= 0 && j >= 0 && i < j
+}
+
+// lifecycleServer is the lifecycle of main.go around a database, with one
+// more route that holds its request until release is closed and then
+// queries the database
+type lifecycleServer struct {
+	app      *shutdown.Orchestrator
+	addr     string
+	timeline *timeline
+	started  chan struct{} // closed once the slow request is in flight
+	release  chan struct{}
+}
+
+// startServer starts the lifecycle on a new SQLite database, and stops it
+// when the test ends unless the test already has
+func startServer(t *testing.T) (*lifecycleServer, *sqlx.DB) {
+	t.Helper()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &lifecycleServer{timeline: &timeline{}, started: make(chan struct{}), release: make(chan struct{})}
+	health := infrastructure.NewHealth()
+	health.AddCheck("database", db.PingContext)
+
+	e := echo.New()
+	e.HideBanner, e.HidePort = true, true
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.GET("/healthz", health.Live)
+	e.GET("/readyz", health.Ready)
+	e.GET("/slow", func(c echo.Context) error {
+		close(s.started)
+		<-s.release
+		var tasks int
+		if err := db.GetContext(c.Request().Context(), &tasks, "SELECT COUNT(*) FROM tasks"); err != nil {
+			return err
+		}
+		s.timeline.record("slow request answered")
+		return c.JSON(http.StatusOK, map[string]int{"tasks": tasks})
+	})
+
+	s.app = shutdown.New(shutdown.Options{StopTimeout: 5 * time.Second})
+	err = s.app.Register(
+		shutdown.Component{
+			Name: "database",
+			Stop: func(context.Context) error {
+				s.timeline.record("database closed")
+				return db.Close()
+			},
+		},
+		shutdown.Component{
+			Name:      "http",
+			DependsOn: []string{"database"},
+			Start: func(context.Context) error {
+				ln, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					return err
+				}
+				e.Listener = ln
+				s.addr = ln.Addr().String()
+				go e.Start("")
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				err := e.Shutdown(ctx)
+				s.timeline.record("http stopped")
+				return err
+			},
+		},
+		shutdown.Component{
+			Name:      "readiness",
+			DependsOn: []string{"http"},
+			Stop: func(ctx context.Context) error {
+				s.timeline.record("draining")
+				err := health.Drain(ctx, drainDelay)
+				s.timeline.record("drained")
+				return err
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.app.Stop(context.Background()) })
+	return s, db
+}
+
+// fresh opens a new connection for every request, so that one refused
+// shows the server is no longer accepting
+var fresh = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+
+// get returns the status and the decoded body of GET path
+func (s *lifecycleServer) get(path string) (int, infrastructure.HealthStatus, error) {
+	var status infrastructure.HealthStatus
+	res, err := fresh.Get("http://" + s.addr + path)
+	if err != nil {
+		return 0, status, err
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	json.Unmarshal(body, &status)
+	return res.StatusCode, status, nil
+}
+
+func TestProbes(t *testing.T) {
+	s, _ := startServer(t)
+	if code, status, err := s.get("/healthz"); err != nil || code != http.StatusOK || status.Status != "ok" {
+		t.Errorf("GET /healthz = %d %+v, %v; want 200 ok", code, status, err)
+	}
+	if code, status, err := s.get("/readyz"); err != nil || code != http.StatusOK || status.Status != "ok" || status.Checks["database"] != "ok" {
+		t.Errorf("GET /readyz = %d %+v, %v; want 200, with the database check ok", code, status, err)
+	}
+}
+
+// TestShutdownOrder checks that readiness turns 503 first while the server
+// still answers, then the server stops accepting connections and finishes
+// the request in flight, and only then is the database closed
+func TestShutdownOrder(t *testing.T) {
+	s, _ := startServer(t)
+	slow := make(chan int, 1)
+	go func() {
+		code, _, _ := s.get("/slow")
+		slow <- code
+	}()
+	<-s.started
+
+	stopped := make(chan shutdown.Report, 1)
+	go func() { stopped <- s.app.Stop(context.Background()) }()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && !s.timeline.has("draining"); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if code, status, err := s.get("/readyz"); err != nil || code != http.StatusServiceUnavailable || status.Status != "draining" {
+		t.Errorf("GET /readyz once the shutdown began = %d %+v, %v; want 503 draining", code, status, err)
+	}
+	if code, _, err := s.get("/healthz"); err != nil || code != http.StatusOK {
+		t.Errorf("GET /healthz while draining = %d, %v; want 200: the process is alive, and still answering", code, err)
+	}
+	if s.timeline.has("http stopped") {
+		t.Error("the server stopped before the drain delay was over")
+	}
+
+	refused := false
+	for deadline := time.Now().Add(2 * drainDelay); time.Now().Before(deadline) && !refused; time.Sleep(5 * time.Millisecond) {
+		_, _, err := s.get("/healthz")
+		refused = err != nil
+	}
+	if !refused || !s.timeline.has("drained") {
+		t.Errorf("after the delay the server still accepts connections: %s", s.timeline)
+	}
+	if s.timeline.has("http stopped") || s.timeline.has("database closed") {
+		t.Errorf("stopped with a request in flight: %s", s.timeline)
+	}
+
+	close(s.release)
+	if code := <-slow; code != http.StatusOK {
+		t.Errorf("the request in flight = %d, want 200, from the database", code)
+	}
+
+	select {
+	case report := <-stopped:
+		if err := report.Err(); err != nil {
+			t.Errorf("stopping = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shutdown did not finish")
+	}
+	if !s.timeline.before("drained", "slow request answered") ||
+		!s.timeline.before("slow request answered", "http stopped") ||
+		!s.timeline.before("http stopped", "database closed") {
+		t.Errorf("shutdown order: %s", s.timeline)
+	}
+}
+
+func TestUnready(t *testing.T) {
+	s, db := startServer(t)
+	db.Close()
+	code, status, err := s.get("/readyz")
+	if err != nil || code != http.StatusServiceUnavailable || status.Status != "unavailable" || !strings.Contains(status.Checks["database"], "closed") {
+		t.Errorf("GET /readyz with the database closed = %d %+v, %v; want 503 saying why", code, status, err)
+	}
+	if code, _, err := s.get("/healthz"); err != nil || code != http.StatusOK {
+		t.Errorf("GET /healthz with the database closed = %d, %v; want 200: restarting would not help", code, err)
+	}
+}
+
+func TestHungCheckFailsReadiness(t *testing.T) {
+	health := infrastructure.NewHealth()
+	health.AddCheck("hung", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	e := echo.New()
+	e.GET("/readyz", health.Ready)
+	began := time.Now()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if took := time.Since(began); rec.Code != http.StatusServiceUnavailable || took > infrastructure.HealthCheckTimeout+time.Second {
+		t.Errorf("GET /readyz with a hung check = %d after %v, want 503 after %v rather than hanging", rec.Code, took, infrastructure.HealthCheckTimeout)
+	}
+}
@@ -0,0 +1,275 @@
+package infrastructure
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// JobQueue implements domain.JobQueue in process: jobs wait in memory, by
+// RunAt, for one of Workers goroutines to run them. They are lost when the
+// process ends, like the events of the TaskEventBus; a queue that must keep
+// them would store them, as the outbox does its events.
+type JobQueue struct {
+	opts JobQueueOptions
+
+	mu       sync.Mutex
+	handlers map[domain.JobKind]domain.JobHandler
+	nextID   int64
+	pending  jobHeap
+	dead     []domain.Job
+	wake     chan struct{} // a job was added
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+type JobQueueOptions struct {
+	Workers int // goroutines running jobs, default 4
+	// MaxAttempts is how many times a job may fail before it is given up on,
+	// default 5
+	MaxAttempts int
+	// Backoff is the wait before retrying a job that has failed attempts
+	// times. The default doubles from 1s up to 5m.
+	Backoff func(attempts int) time.Duration
+	Logf    func(format string, args ...any) // default discards
+}
+
+func jobBackoff(attempts int) time.Duration {
+	wait := time.Second << min(attempts-1, 9)
+	return min(wait, 5*time.Minute)
+}
+
+func NewJobQueue(opts JobQueueOptions) *JobQueue {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = jobBackoff
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	return &JobQueue{opts: opts, handlers: map[domain.JobKind]domain.JobHandler{}, wake: make(chan struct{}, 1)}
+}
+
+// Handle runs the jobs of kind through handler, from then on
+func (q *JobQueue) Handle(kind domain.JobKind, handler domain.JobHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = handler
+}
+
+// Enqueue adds a job, which runs once Start has been called and its RunAt
+// has come
+func (q *JobQueue) Enqueue(ctx context.Context, job domain.Job) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if job.Kind == "" {
+		return 0, domain.ErrJobKindEmpty
+	}
+	q.mu.Lock()
+	q.nextID++
+	job.ID = q.nextID
+	heap.Push(&q.pending, job)
+	q.mu.Unlock()
+	q.signal()
+	return job.ID, nil
+}
+
+func (q *JobQueue) DeadLetters(ctx context.Context) ([]domain.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]domain.Job(nil), q.dead...), nil
+}
+
+// Pending is how many jobs wait to run, or to be retried
+func (q *JobQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+func (q *JobQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start starts the workers, and the goroutine handing them the jobs that
+// are due. Its signature is that of a shutdown.Component's Start.
+func (q *JobQueue) Start(context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cancel != nil {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	due := make(chan domain.Job)
+	var wg sync.WaitGroup
+	wg.Add(1 + q.opts.Workers)
+	go func() {
+		defer wg.Done()
+		q.schedule(runCtx, due)
+	}()
+	for range q.opts.Workers {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-due:
+					q.run(runCtx, job)
+				case <-runCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	q.cancel, q.done = cancel, done
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return nil
+}
+
+// schedule hands each job to a worker once it is due, earliest first
+func (q *JobQueue) schedule(ctx context.Context, due chan<- domain.Job) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		wait := time.Duration(-1)
+		var job domain.Job
+		if len(q.pending) > 0 {
+			if wait = time.Until(q.pending[0].RunAt); wait <= 0 {
+				job = heap.Pop(&q.pending).(domain.Job)
+			}
+		}
+		q.mu.Unlock()
+
+		if job.ID != 0 {
+			select {
+			case due <- job:
+				continue
+			case <-ctx.Done():
+				q.requeue(job)
+				return
+			}
+		}
+		var tick <-chan time.Time
+		if wait > 0 {
+			timer.Reset(wait)
+			tick = timer.C
+		}
+		select {
+		case <-tick:
+		case <-q.wake:
+		case <-ctx.Done():
+			return
+		}
+		if tick != nil && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// run runs a job through the handler of its kind. A job that fails is
+// retried after its Backoff, until it has failed MaxAttempts times; then it
+// joins the dead letters, as does one of a kind nothing handles. A run cut
+// short by Stop is no attempt: the job waits for the next Start.
+func (q *JobQueue) run(ctx context.Context, job domain.Job) {
+	q.mu.Lock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.Unlock()
+	if !ok {
+		job.LastError = fmt.Sprintf("no handler for jobs of kind %q", job.Kind)
+		q.bury(job)
+		return
+	}
+	err := handler.HandleJob(ctx, job)
+	if err == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		q.requeue(job)
+		return
+	}
+	job.Attempts++
+	job.LastError = err.Error()
+	if job.Attempts >= q.opts.MaxAttempts {
+		q.bury(job)
+		return
+	}
+	q.opts.Logf("jobs: %s job %d failed (attempt %d), retrying: %v", job.Kind, job.ID, job.Attempts, err)
+	job.RunAt = time.Now().Add(q.opts.Backoff(job.Attempts))
+	q.requeue(job)
+}
+
+func (q *JobQueue) requeue(job domain.Job) {
+	q.mu.Lock()
+	heap.Push(&q.pending, job)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// bury gives up on a job
+func (q *JobQueue) bury(job domain.Job) {
+	q.opts.Logf("jobs: giving up on %s job %d after %d attempt(s): %s", job.Kind, job.ID, job.Attempts, job.LastError)
+	q.mu.Lock()
+	q.dead = append(q.dead, job)
+	q.mu.Unlock()
+}
+
+// Stop stops the workers, waiting for the jobs they are running to return,
+// or for ctx. Jobs not run yet stay pending.
+func (q *JobQueue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	cancel, done := q.cancel, q.done
+	q.cancel = nil
+	q.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jobHeap orders jobs by RunAt, then by ID
+type jobHeap []domain.Job
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	if !h[i].RunAt.Equal(h[j].RunAt) {
+		return h[i].RunAt.Before(h[j].RunAt)
+	}
+	return h[i].ID < h[j].ID
+}
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x any)   { *h = append(*h, x.(domain.Job)) }
+func (h *jobHeap) Pop() any {
+	old := *h
+	job := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return job
+}
@@ -0,0 +1,203 @@
+package infrastructure_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+)
+
+// jobHandlerFunc is a fake JobHandler
+type jobHandlerFunc func(ctx context.Context, job domain.Job) error
+
+func (f jobHandlerFunc) HandleJob(ctx context.Context, job domain.Job) error {
+	return f(ctx, job)
+}
+
+func quickBackoff(int) time.Duration { return 10 * time.Millisecond }
+
+// startQueue starts a queue of workers that tries each job three times,
+// stopping it when the test ends
+func startQueue(t *testing.T, workers int) *infrastructure.JobQueue {
+	t.Helper()
+	queue := infrastructure.NewJobQueue(infrastructure.JobQueueOptions{Workers: workers, MaxAttempts: 3, Backoff: quickBackoff})
+	if err := queue.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queue.Stop(context.Background()) })
+	return queue
+}
+
+// waitFor polls ok for up to two seconds: jobs run a moment after they
+// are due
+func waitFor(ok func() bool) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if ok() {
+			return true
+		}
+	}
+	return ok()
+}
+
+// next returns the next job sent on ran, failing the test after two seconds
+func next(t *testing.T, ran <-chan domain.Job) domain.Job {
+	t.Helper()
+	select {
+	case job := <-ran:
+		return job
+	case <-time.After(2 * time.Second):
+		t.Fatal("no job ran")
+		return domain.Job{}
+	}
+}
+
+func TestJobQueueRetries(t *testing.T) {
+	ctx := context.Background()
+	queue := infrastructure.NewJobQueue(infrastructure.JobQueueOptions{Workers: 3, MaxAttempts: 3, Backoff: quickBackoff})
+	var attempts atomic.Int32
+	ran := make(chan domain.Job, 1)
+	queue.Handle("flaky", jobHandlerFunc(func(_ context.Context, job domain.Job) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		ran <- job
+		return nil
+	}))
+
+	if _, err := queue.Enqueue(ctx, domain.Job{}); !errors.Is(err, domain.ErrJobKindEmpty) {
+		t.Errorf("Enqueue of a job without a kind = %v, want %v", err, domain.ErrJobKindEmpty)
+	}
+	id, err := queue.Enqueue(ctx, domain.Job{Kind: "flaky", Payload: []byte("hello")})
+	if err != nil || id == 0 || queue.Pending() != 1 {
+		t.Fatalf("Enqueue before Start = %d, %v, with %d pending, want the job waiting", id, err, queue.Pending())
+	}
+	if err := queue.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Stop(ctx)
+
+	job := next(t, ran)
+	if job.ID != id || string(job.Payload) != "hello" || job.Attempts != 2 || job.LastError != "not yet" {
+		t.Errorf("the job ran as %+v, want it retried with its attempts so far and the last error", job)
+	}
+}
+
+func TestJobQueueDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	queue := startQueue(t, 3)
+	var attempts atomic.Int32
+	queue.Handle("doomed", jobHandlerFunc(func(context.Context, domain.Job) error {
+		attempts.Add(1)
+		return errors.New("always fails")
+	}))
+	queue.Enqueue(ctx, domain.Job{Kind: "doomed"})
+	queue.Enqueue(ctx, domain.Job{Kind: "unknown"})
+
+	var dead []domain.Job
+	if !waitFor(func() bool {
+		dead, _ = queue.DeadLetters(ctx)
+		return len(dead) == 2
+	}) {
+		t.Fatalf("dead letters = %+v, want both jobs given up on", dead)
+	}
+	byKind := map[domain.JobKind]domain.Job{}
+	for _, job := range dead {
+		byKind[job.Kind] = job
+	}
+	if unknown := byKind["unknown"]; unknown.Attempts != 0 || !strings.Contains(unknown.LastError, "no handler") {
+		t.Errorf("the job of a kind nothing handles = %+v, want it given up on at once", unknown)
+	}
+	if doomed := byKind["doomed"]; doomed.Attempts != 3 || doomed.LastError != "always fails" || attempts.Load() != 3 {
+		t.Errorf("the job that keeps failing = %+v after %d runs, want it given up on after MaxAttempts, 3", doomed, attempts.Load())
+	}
+}
+
+func TestJobQueueRunAt(t *testing.T) {
+	ctx := context.Background()
+	queue := startQueue(t, 1)
+	ran := make(chan domain.Job, 2)
+	queue.Handle("later", jobHandlerFunc(func(_ context.Context, job domain.Job) error {
+		ran <- job
+		return nil
+	}))
+
+	start := time.Now()
+	queue.Enqueue(ctx, domain.Job{Kind: "later", Payload: []byte("second"), RunAt: start.Add(150 * time.Millisecond)})
+	queue.Enqueue(ctx, domain.Job{Kind: "later", Payload: []byte("first")})
+	if job := next(t, ran); string(job.Payload) != "first" {
+		t.Errorf("the first job to run = %q, want the one due now before the one enqueued earlier but due later", job.Payload)
+	}
+	next(t, ran)
+	if waited := time.Since(start); waited < 150*time.Millisecond {
+		t.Errorf("the job due in 150ms ran after %v", waited)
+	}
+}
+
+// TestJobQueueWorkers runs three jobs that each wait for the other two
+func TestJobQueueWorkers(t *testing.T) {
+	ctx := context.Background()
+	queue := startQueue(t, 3)
+	var running sync.WaitGroup
+	running.Add(3)
+	finished := make(chan struct{}, 3)
+	queue.Handle("together", jobHandlerFunc(func(context.Context, domain.Job) error {
+		running.Done()
+		running.Wait()
+		finished <- struct{}{}
+		return nil
+	}))
+	for range 3 {
+		queue.Enqueue(ctx, domain.Job{Kind: "together"})
+	}
+	timeout := time.After(2 * time.Second)
+	for range 3 {
+		select {
+		case <-finished:
+		case <-timeout:
+			t.Fatal("three workers did not run three jobs at once")
+		}
+	}
+}
+
+// TestJobQueueStop stops the queue while a job runs: that is not an
+// attempt, and the job runs again once the queue starts again
+func TestJobQueueStop(t *testing.T) {
+	ctx := context.Background()
+	queue := infrastructure.NewJobQueue(infrastructure.JobQueueOptions{Backoff: quickBackoff})
+	started := make(chan struct{})
+	ran := make(chan domain.Job, 1)
+	var interrupted atomic.Bool
+	queue.Handle("long", jobHandlerFunc(func(ctx context.Context, job domain.Job) error {
+		if interrupted.CompareAndSwap(false, true) {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		ran <- job
+		return nil
+	}))
+	if err := queue.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	queue.Enqueue(ctx, domain.Job{Kind: "long"})
+	<-started
+	if err := queue.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := queue.Pending(); n != 1 {
+		t.Errorf("after Stop, %d jobs pending, want the one cut short", n)
+	}
+	if err := queue.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Stop(ctx)
+	if job := next(t, ran); job.Attempts != 0 {
+		t.Errorf("the job ran again after %d attempts, want none counted", job.Attempts)
+	}
+}
@@ -0,0 +1,110 @@
+package infrastructure
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// UnmatchedRoute is the route label of requests no route matched. Labelling
+// them by their path instead would let any client add series at will.
+const UnmatchedRoute = "unmatched"
+
+// Metrics are the server's Prometheus metrics, on a registry of their own
+// rather than the global one, so that each server or check counts only its
+// own traffic:
+//
+//	http_requests_total{method,route,status}
+//	http_request_duration_seconds{method,route,status}
+//	http_requests_in_flight{method,route}
+//	repository_query_duration_seconds{repository,operation,outcome}
+//
+// plus the Go runtime and process collectors. route is the route as
+// registered, such as /tasks/:id, never the path requested. outcome is ok
+// or error.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	queries  *prometheus.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests answered, by route and status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to answer HTTP requests, by route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being answered, by route.",
+		}, []string{"method", "route"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "repository_query_duration_seconds",
+			Help:    "Time taken by repository operations, by outcome.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms to 4s
+		}, []string{"repository", "operation", "outcome"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.duration, m.inFlight, m.queries,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Middleware measures every request. It answers a handler's error itself,
+// through the server's HTTPErrorHandler, so that the status it records is
+// the one sent; the error is still returned for the middleware around it,
+// and answering it again does nothing. Add it outside Recover, so that a
+// panic is counted as the 500 it becomes.
+func (m *Metrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			method, route := c.Request().Method, c.Path()
+			if route == "" {
+				route = UnmatchedRoute
+			}
+			inFlight := m.inFlight.WithLabelValues(method, route)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+			status := strconv.Itoa(c.Response().Status)
+			m.requests.WithLabelValues(method, route, status).Inc()
+			m.duration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// ObserveQuery records a repository operation. Its signature is that of
+// repository.QueryObserver.
+func (m *Metrics) ObserveQuery(repository, operation string, took time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.queries.WithLabelValues(repository, operation, outcome).Observe(took.Seconds())
+}
+
+// Handler serves the metrics in the Prometheus text format, for GET /metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
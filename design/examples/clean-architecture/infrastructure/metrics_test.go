@@ -0,0 +1,215 @@
+package infrastructure_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// metricsApp is the server as main.go wires it, on in-memory stores, with
+// one more route that panics
+type metricsApp struct {
+	e     *echo.Echo
+	token string
+}
+
+func newMetricsApp(t *testing.T) *metricsApp {
+	t.Helper()
+	metrics := infrastructure.NewMetrics()
+	users := repository.NewTimedUserRepository(repository.NewMemoryUserRepository(), metrics.ObserveQuery)
+	tasks := repository.NewTimedTaskRepository(repository.NewMemoryTaskRepository(), metrics.ObserveQuery)
+	auth := usecase.NewAuthUseCase(users, infrastructure.BcryptHasher{Cost: bcrypt.MinCost}, newTokens(t))
+	taskUseCase := usecase.NewTaskUseCaseWithEvents(tasks, infrastructure.NewTaskEventBus())
+	userUseCase := usecase.NewUserUseCase(users)
+
+	e := echo.New()
+	e.Logger.SetOutput(io.Discard) // the panic is on purpose
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(metrics.Middleware())
+	e.Use(middleware.Recover())
+	handler.RegisterRoutes(handler.NewRouteTable(e), handler.Handlers{
+		Auth:         handler.NewAuthHandler(auth),
+		Tasks:        handler.NewTaskHandler(taskUseCase),
+		Users:        handler.NewUserHandler(userUseCase),
+		GraphQL:      handler.NewGraphQLHandler(taskUseCase, userUseCase),
+		Authenticate: handler.RequireUser(auth),
+	})
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	e.GET("/panic", func(echo.Context) error { panic("on purpose") })
+
+	ctx := context.Background()
+	if _, err := auth.Register(ctx, "ada@example.com", "correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	session, err := auth.Login(ctx, "ada@example.com", "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &metricsApp{e: e, token: session.Token}
+}
+
+// do sends a request as the registered user, or anonymously without auth
+func (a *metricsApp) do(ctx context.Context, method, path, body string, auth bool) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if auth {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+a.token)
+	}
+	rec := httptest.NewRecorder()
+	a.e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// scrape returns the metric families GET /metrics serves
+func (a *metricsApp) scrape(t *testing.T) map[string]*dto.MetricFamily {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	a.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return families
+}
+
+// value returns the value of the series of name with exactly labels: the
+// count of a counter or histogram, the level of a gauge, and 0 if there is
+// no such series
+func value(families map[string]*dto.MetricFamily, name string, labels map[string]string) float64 {
+	family := families[name]
+	if family == nil {
+		return 0
+	}
+	for _, m := range family.GetMetric() {
+		if len(m.GetLabel()) != len(labels) {
+			continue
+		}
+		match := true
+		for _, l := range m.GetLabel() {
+			match = match && labels[l.GetName()] == l.GetValue()
+		}
+		if !match {
+			continue
+		}
+		switch {
+		case m.Counter != nil:
+			return m.Counter.GetValue()
+		case m.Gauge != nil:
+			return m.Gauge.GetValue()
+		case m.Histogram != nil:
+			return float64(m.Histogram.GetSampleCount())
+		}
+	}
+	return 0
+}
+
+// request is the labels of an HTTP series
+func request(method, route, status string) map[string]string {
+	labels := map[string]string{"method": method, "route": route}
+	if status != "" {
+		labels["status"] = status
+	}
+	return labels
+}
+
+func query(repository, operation, outcome string) map[string]string {
+	return map[string]string{"repository": repository, "operation": operation, "outcome": outcome}
+}
+
+func TestMetricsCountRequestsAndQueries(t *testing.T) {
+	a := newMetricsApp(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		a.do(ctx, http.MethodPost, "/api/v1/tasks", fmt.Sprintf(`{"title":"Task %d"}`, i), true)
+	}
+	a.do(ctx, http.MethodGet, "/api/v1/tasks/1", "", true)
+	a.do(ctx, http.MethodGet, "/api/v1/tasks/2", "", true)
+	a.do(ctx, http.MethodGet, "/tasks/2", "", true)
+	a.do(ctx, http.MethodGet, "/api/v1/tasks/999", "", true)
+	a.do(ctx, http.MethodGet, "/api/v1/tasks", "", true)
+	a.do(ctx, http.MethodGet, "/api/v1/tasks", "", false)
+	a.do(ctx, http.MethodPost, "/api/v1/tasks/bulk", `{"tasks":[{"title":"A"},{"title":"B"}]}`, true)
+	a.do(ctx, http.MethodGet, "/no/such/page", "", false)
+	a.do(ctx, http.MethodGet, "/panic", "", false)
+
+	// One user lookup per authenticated request
+	authenticated := 3 + 4 + 1 + 1
+	m := a.scrape(t)
+	tests := []struct {
+		name   string
+		metric string
+		labels map[string]string
+		want   float64
+	}{
+		{"creates", "http_requests_total", request("POST", "/api/v1/tasks", "201"), 3},
+		{"reads, by route rather than path", "http_requests_total", request("GET", "/api/v1/tasks/:id", "200"), 3},
+		{"a missing task, by the status its error became", "http_requests_total", request("GET", "/api/v1/tasks/:id", "404"), 1},
+		{"a listing with a token", "http_requests_total", request("GET", "/api/v1/tasks", "200"), 1},
+		{"a listing without one", "http_requests_total", request("GET", "/api/v1/tasks", "401"), 1},
+		{"a path no route matches", "http_requests_total", request("GET", infrastructure.UnmatchedRoute, "404"), 1},
+		{"a panic, as the 500 it became", "http_requests_total", request("GET", "/panic", "500"), 1},
+		{"creates timed", "http_request_duration_seconds", request("POST", "/api/v1/tasks", "201"), 3},
+		{"a missing task timed", "http_request_duration_seconds", request("GET", "/api/v1/tasks/:id", "404"), 1},
+		{"none in flight once answered", "http_requests_in_flight", request("POST", "/api/v1/tasks", ""), 0},
+		{"task Creates", "repository_query_duration_seconds", query("tasks", "Create", "ok"), 3},
+		{"the bulk create as one CreateBatch", "repository_query_duration_seconds", query("tasks", "CreateBatch", "ok"), 1},
+		{"task lookups", "repository_query_duration_seconds", query("tasks", "GetByID", "ok"), 3},
+		{"the missing task's lookup", "repository_query_duration_seconds", query("tasks", "GetByID", "error"), 1},
+		{"user lookups", "repository_query_duration_seconds", query("users", "GetByID", "ok"), float64(authenticated)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := value(m, tt.metric, tt.labels); got != tt.want {
+				t.Errorf("%s%v = %v, want %v", tt.metric, tt.labels, got, tt.want)
+			}
+		})
+	}
+	if m["go_goroutines"] == nil {
+		t.Error("the Go runtime metrics are missing")
+	}
+}
+
+func TestMetricsCountRequestsInFlight(t *testing.T) {
+	a := newMetricsApp(t)
+	stream := request("GET", "/api/v1/tasks/stream", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- a.do(ctx, http.MethodGet, "/api/v1/tasks/stream", "", true) }()
+
+	open := false
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline) && !open; time.Sleep(5 * time.Millisecond) {
+		open = value(a.scrape(t), "http_requests_in_flight", stream) == 1
+	}
+	if !open {
+		t.Error("an open stream is not a request in flight on /api/v1/tasks/stream")
+	}
+	cancel()
+	status := <-done
+	m := a.scrape(t)
+	if value(m, "http_requests_in_flight", stream) != 0 || value(m, "http_requests_total", request("GET", "/api/v1/tasks/stream", fmt.Sprint(status))) != 1 {
+		t.Error("once its client went away, the stream should be no longer in flight, and counted as answered")
+	}
+}
+
+func TestMetricsAreEachServersOwn(t *testing.T) {
+	newMetricsApp(t).do(context.Background(), http.MethodPost, "/api/v1/tasks", `{"title":"Elsewhere"}`, true)
+	if got := value(newMetricsApp(t).scrape(t), "http_requests_total", request("POST", "/api/v1/tasks", "201")); got != 0 {
+		t.Errorf("another server's metrics counted %v creates, want only its own traffic", got)
+	}
+}
@@ -0,0 +1,340 @@
+package infrastructure
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Schema migrations, applied in order and recorded in schema_migrations.
+//
+// Versions 2 and 3 rename tasks.description to tasks.details without
+// downtime, by expand-migrate-contract:
+//
+//  1. expand: add the new column next to the old one. Old and new code both
+//     still work, since nothing they use has changed.
+//  2. migrate: deploy code that writes both columns (repository
+//     DescriptionColumns), backfill the new column in batches
+//     (BackfillDetails), then switch reads, then stop writing the old one.
+//  3. contract: drop the old column, once no running code uses it.
+//
+// Each step is a separate deploy, and each can be rolled back until the
+// contract. cmd/migrate runs them; TestRenamePhases in the repository tests
+// runs every phase to show which combinations of code and schema work.
+//
+// Those two are Manual. The others only add things no running code relies on
+// being absent, so OpenDatabase applies them at startup, even while a manual
+// step before them is still pending. An automatic migration must therefore
+// never depend on a manual one.
+//
+// Up is written for SQLite. A migration whose statement PostgreSQL does not
+// accept, mostly for column types, gives PostgreSQL its own in Postgres.
+
+const (
+	SchemaBaseline    = 1
+	SchemaExpanded    = 2
+	SchemaContracted  = 3
+	SchemaLabels      = 4
+	SchemaUsers       = 5
+	SchemaRoles       = 6
+	SchemaVersions    = 7
+	SchemaOutbox      = 8
+	SchemaIdempotency = 9
+	SchemaAssignees   = 10
+	SchemaArchive     = 11
+	SchemaTenants     = 12
+	SchemaDueDates    = 13
+	SchemaSubtasks    = 14
+)
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Postgres string // Up for PostgreSQL, when it differs
+	Manual   bool   // applied on purpose with cmd/migrate, not at startup
+}
+
+// statement returns the migration's statement for db's driver
+func (m Migration) statement(db *sqlx.DB) string {
+	if m.Postgres != "" && db.DriverName() == DriverPostgres {
+		return m.Postgres
+	}
+	return m.Up
+}
+
+var Migrations = []Migration{
+	{SchemaBaseline, "create tasks", `
+		CREATE TABLE IF NOT EXISTS tasks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			description TEXT,
+			completed BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`, `
+		CREATE TABLE IF NOT EXISTS tasks (
+			id BIGSERIAL PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT,
+			completed BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		)`, false},
+	{SchemaExpanded, "expand: add tasks.details", `ALTER TABLE tasks ADD COLUMN details TEXT`, "", true},
+	{SchemaContracted, "contract: drop tasks.description", `ALTER TABLE tasks DROP COLUMN description`, "", true},
+	{SchemaLabels, "add tasks.priority and task_tags", `
+		ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT 'medium';
+		CREATE TABLE IF NOT EXISTS task_tags (
+			task_id INTEGER NOT NULL REFERENCES tasks(id),
+			tag TEXT NOT NULL,
+			PRIMARY KEY (task_id, tag)
+		);
+		CREATE INDEX IF NOT EXISTS task_tags_tag ON task_tags (tag, task_id)`, `
+		ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT 'medium';
+		CREATE TABLE IF NOT EXISTS task_tags (
+			task_id BIGINT NOT NULL REFERENCES tasks(id),
+			tag TEXT NOT NULL,
+			PRIMARY KEY (task_id, tag)
+		);
+		CREATE INDEX IF NOT EXISTS task_tags_tag ON task_tags (tag, task_id)`, false},
+	// Tasks created before accounts existed get owner 0, which no user has,
+	// so nobody sees them until they are given an owner
+	{SchemaUsers, "add users and tasks.owner_id", `
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+		ALTER TABLE tasks ADD COLUMN owner_id INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS tasks_owner ON tasks (owner_id, created_at)`, `
+		CREATE TABLE IF NOT EXISTS users (
+			id BIGSERIAL PRIMARY KEY,
+			email TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL
+		);
+		ALTER TABLE tasks ADD COLUMN owner_id BIGINT NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS tasks_owner ON tasks (owner_id, created_at)`, false},
+	// Every existing account becomes a plain user; cmd/setrole makes the
+	// first admin
+	{SchemaRoles, "add users.role", `ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`, "", false},
+	// Existing tasks start at version 1, as new ones do
+	{SchemaVersions, "add tasks.version", `ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE tasks ADD COLUMN version BIGINT NOT NULL DEFAULT 1`, false},
+	// Events outlive their task, so task_id is not a reference. An event is
+	// due while next_attempt_at is set: delivering it or giving up on it
+	// clears it.
+	{SchemaOutbox, "add outbox", `
+		CREATE TABLE IF NOT EXISTS outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			task_id INTEGER NOT NULL,
+			owner_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			occurred_at DATETIME NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME,
+			delivered_at DATETIME,
+			last_error TEXT
+		);
+		CREATE INDEX IF NOT EXISTS outbox_due ON outbox (id) WHERE next_attempt_at IS NOT NULL`, `
+		CREATE TABLE IF NOT EXISTS outbox (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			task_id BIGINT NOT NULL,
+			owner_id BIGINT NOT NULL,
+			title TEXT NOT NULL,
+			occurred_at TIMESTAMPTZ NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMPTZ,
+			delivered_at TIMESTAMPTZ,
+			last_error TEXT
+		);
+		CREATE INDEX IF NOT EXISTS outbox_due ON outbox (id) WHERE next_attempt_at IS NOT NULL`, false},
+	// A key is in progress while status is NULL. Expired rows are removed
+	// whenever a key is claimed.
+	{SchemaIdempotency, "add idempotency_keys", `
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			owner_id INTEGER NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			status INTEGER,
+			content_type TEXT,
+			body BLOB,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			PRIMARY KEY (owner_id, idempotency_key)
+		);
+		CREATE INDEX IF NOT EXISTS idempotency_keys_expiry ON idempotency_keys (expires_at)`, `
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			owner_id BIGINT NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			status INTEGER,
+			content_type TEXT,
+			body BYTEA,
+			created_at TIMESTAMPTZ NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (owner_id, idempotency_key)
+		);
+		CREATE INDEX IF NOT EXISTS idempotency_keys_expiry ON idempotency_keys (expires_at)`, false},
+	// Like owner_id, 0 is nobody, so existing tasks start unassigned
+	{SchemaAssignees, "add tasks.assignee_id", `
+		ALTER TABLE tasks ADD COLUMN assignee_id INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS tasks_assignee ON tasks (assignee_id, created_at)`, `
+		ALTER TABLE tasks ADD COLUMN assignee_id BIGINT NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS tasks_assignee ON tasks (assignee_id, created_at)`, false},
+	// An archived task keeps its ID, and its tags as a JSON array: nothing
+	// filters on them. tasks_completed finds the tasks due for archiving.
+	{SchemaArchive, "add archived_tasks", `
+		CREATE TABLE IF NOT EXISTS archived_tasks (
+			id INTEGER PRIMARY KEY,
+			owner_id INTEGER NOT NULL,
+			assignee_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL,
+			priority TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			archived_at DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS archived_tasks_owner ON archived_tasks (owner_id, archived_at);
+		CREATE INDEX IF NOT EXISTS tasks_completed ON tasks (completed, updated_at)`, `
+		CREATE TABLE IF NOT EXISTS archived_tasks (
+			id BIGINT PRIMARY KEY,
+			owner_id BIGINT NOT NULL,
+			assignee_id BIGINT NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL,
+			priority TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			version BIGINT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			archived_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS archived_tasks_owner ON archived_tasks (owner_id, archived_at);
+		CREATE INDEX IF NOT EXISTS tasks_completed ON tasks (completed, updated_at)`, false},
+	// Everything stored so far belongs to the default tenant. An email is
+	// now unique within a tenant, and SQLite cannot change a constraint, so
+	// users is rebuilt with the new one; the IDs it keeps are what tasks
+	// and tokens refer to.
+	{SchemaTenants, "add tenant_id", `
+		ALTER TABLE tasks ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+		CREATE INDEX IF NOT EXISTS tasks_tenant ON tasks (tenant_id, created_at);
+		ALTER TABLE archived_tasks ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+		CREATE INDEX IF NOT EXISTS archived_tasks_tenant ON archived_tasks (tenant_id, archived_at);
+		CREATE TABLE users_by_tenant (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			email TEXT NOT NULL,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'user',
+			created_at DATETIME NOT NULL,
+			UNIQUE (tenant_id, email)
+		);
+		INSERT INTO users_by_tenant (id, email, password_hash, role, created_at)
+			SELECT id, email, password_hash, role, created_at FROM users;
+		DROP TABLE users;
+		ALTER TABLE users_by_tenant RENAME TO users`, `
+		ALTER TABLE tasks ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+		CREATE INDEX IF NOT EXISTS tasks_tenant ON tasks (tenant_id, created_at);
+		ALTER TABLE archived_tasks ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+		CREATE INDEX IF NOT EXISTS archived_tasks_tenant ON archived_tasks (tenant_id, archived_at);
+		ALTER TABLE users ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+		ALTER TABLE users DROP CONSTRAINT users_email_key;
+		ALTER TABLE users ADD CONSTRAINT users_tenant_email_key UNIQUE (tenant_id, email)`, false},
+	// NULL is not due; existing tasks are not
+	{SchemaDueDates, "add tasks.due_at", `
+		ALTER TABLE tasks ADD COLUMN due_at DATETIME`, `
+		ALTER TABLE tasks ADD COLUMN due_at TIMESTAMPTZ`, false},
+	// Like assignee_id, 0 is none, so existing tasks are top-level
+	{SchemaSubtasks, "add tasks.parent_id", `
+		ALTER TABLE tasks ADD COLUMN parent_id INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS tasks_parent ON tasks (parent_id, created_at)`, `
+		ALTER TABLE tasks ADD COLUMN parent_id BIGINT NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS tasks_parent ON tasks (parent_id, created_at)`, false},
+}
+
+// AppliedMigrations returns the versions applied so far
+func AppliedMigrations(db *sqlx.DB) (map[int]bool, error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return nil, err
+	}
+	var versions []int
+	if err := db.Select(&versions, `SELECT version FROM schema_migrations`); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// Migrate applies the pending migrations up to target in order, each in its
+// own transaction. It never goes backwards.
+func Migrate(db *sqlx.DB, target int) error {
+	return migrate(db, func(m Migration) bool { return m.Version <= target })
+}
+
+// MigrateAutomatic applies every pending migration that is not Manual
+func MigrateAutomatic(db *sqlx.DB) error {
+	return migrate(db, func(m Migration) bool { return !m.Manual })
+}
+
+// dataTables are every table the migrations create rows in, each before any
+// it refers to
+var dataTables = []string{"task_tags", "tasks", "archived_tasks", "outbox", "idempotency_keys", "users"}
+
+// ResetDatabase deletes everything stored, in every tenant, in one
+// transaction. The schema, and the migrations applied to it, stay.
+func ResetDatabase(db *sqlx.DB) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, table := range dataTables {
+		if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("reset %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func migrate(db *sqlx.DB, include func(Migration) bool) error {
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range Migrations {
+		if applied[m.Version] || !include(m) {
+			continue
+		}
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(m.statement(db)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.Exec(tx.Rebind(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`), m.Version, m.Name); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/concurrency-example/pubsub"
+)
+
+// TaskEventBuffer is how many events a watcher may fall behind by. Past it,
+// the watcher loses the oldest rather than hold up the writers.
+const TaskEventBuffer = 64
+
+// TaskEventFollowBuffer is how many events a follower may fall behind by
+// before the writers wait for it
+const TaskEventFollowBuffer = 1024
+
+// TaskEventBus is the domain.TaskEvents of a single server: an in-process
+// pubsub.Broker. Each event is published on the topic
+// tasks.<tenant>.<owner>.<type>, so a watcher of one owner subscribes to
+// tasks.<tenant>.<owner>.* and the broker never hands it anyone else's
+// events, nor a watcher of a whole tenant another tenant's.
+type TaskEventBus struct {
+	broker *pubsub.Broker[domain.TaskEvent]
+}
+
+func NewTaskEventBus() *TaskEventBus {
+	return &TaskEventBus{broker: pubsub.New[domain.TaskEvent]()}
+}
+
+func (b *TaskEventBus) Publish(ctx context.Context, event domain.TaskEvent) {
+	topic := fmt.Sprintf("tasks.%s.%d.%s", event.Task.TenantID.OrDefault(), event.Task.OwnerID, event.Type)
+	// Watchers drop rather than block. A follower that is full makes this
+	// wait, until ctx ends: the event is then lost to it, as it is once the
+	// bus is closed and nobody is watching any more.
+	b.broker.Publish(ctx, topic, event)
+}
+
+func (b *TaskEventBus) Subscribe(ctx context.Context, ownerID int64) (<-chan domain.TaskEvent, error) {
+	// AllTenants is *, so it matches every tenant
+	owner := "*"
+	if ownerID != 0 {
+		owner = fmt.Sprint(ownerID)
+	}
+	pattern := fmt.Sprintf("tasks.%s.%s.*", domain.TenantOf(ctx), owner)
+	return b.subscribe(ctx, pattern, pubsub.Options{Buffer: TaskEventBuffer, Policy: pubsub.DropOldest})
+}
+
+func (b *TaskEventBus) Follow(ctx context.Context) (<-chan domain.TaskEvent, error) {
+	return b.subscribe(ctx, "tasks.*.*.*", pubsub.Options{Buffer: TaskEventFollowBuffer, Policy: pubsub.Block})
+}
+
+// subscribe relays the events matching pattern until ctx is done or the bus
+// is closed
+func (b *TaskEventBus) subscribe(ctx context.Context, pattern string, opts pubsub.Options) (<-chan domain.TaskEvent, error) {
+	sub, err := b.broker.Subscribe(pattern, opts)
+	if errors.Is(err, pubsub.ErrClosed) {
+		return nil, domain.ErrTaskEventsStopped
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan domain.TaskEvent)
+	go func() {
+		defer close(events)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case m, ok := <-sub.C():
+				if !ok {
+					return
+				}
+				select {
+				case events <- m.Payload:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Watchers returns how many subscriptions are open
+func (b *TaskEventBus) Watchers() int {
+	return len(b.broker.Subscriptions())
+}
+
+// Close stops carrying events: every subscription's channel is closed, and
+// later ones fail. Call it when the server starts shutting down, so the
+// streams watching end instead of holding the shutdown up.
+func (b *TaskEventBus) Close(ctx context.Context) error {
+	return b.broker.Close(ctx)
+}
@@ -0,0 +1,125 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters InitTracing can send spans to
+const (
+	TracesNone   = "none"
+	TracesStdout = "stdout"
+	TracesOTLP   = "otlp" // OTLP over HTTP
+)
+
+// TracingConfig says where spans go. The OTLP exporter reads its endpoint
+// and headers from the standard OTEL_EXPORTER_OTLP_* variables, by default
+// http://localhost:4318.
+type TracingConfig struct {
+	Exporter    string
+	ServiceName string
+	Output      io.Writer // for stdout, default os.Stdout
+}
+
+// TracingConfigFromEnv reads OTEL_TRACES_EXPORTER, by default none, and
+// OTEL_SERVICE_NAME, by default tasks-api
+func TracingConfigFromEnv() TracingConfig {
+	config := TracingConfig{Exporter: os.Getenv("OTEL_TRACES_EXPORTER"), ServiceName: os.Getenv("OTEL_SERVICE_NAME")}
+	if config.Exporter == "" {
+		config.Exporter = TracesNone
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "tasks-api"
+	}
+	return config
+}
+
+// InitTracing installs the global tracer provider and the W3C trace context
+// propagator, so that the spans the layers start reach the configured
+// exporter and join the traces of the callers that send a traceparent. It
+// returns the function that flushes and stops the exporter. With none, the
+// layers' spans stay no-ops and cost next to nothing.
+func InitTracing(ctx context.Context, config TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case TracesNone:
+		return func(context.Context) error { return nil }, nil
+	case TracesStdout:
+		output := config.Output
+		if output == nil {
+			output = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(output))
+	case TracesOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q (want %s, %s or %s)", config.Exporter, TracesNone, TracesStdout, TracesOTLP)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// TracingMiddleware starts the server span of every request, named after
+// its route like GET /tasks/:id, as a child of the caller's span if the
+// request carries a traceparent. Handlers pass c.Request().Context() down,
+// so the use case and repository spans are its children. Like
+// Metrics.Middleware, it answers a handler's error itself so the status it
+// records is the one sent, and a 5xx marks the span as failed.
+func TracingMiddleware() echo.MiddlewareFunc {
+	tracer := otel.Tracer("github.com/dong-tran/docs/clean-architecture-example/handler")
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			route := c.Path()
+			if route == "" {
+				route = UnmatchedRoute
+			}
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx, span := tracer.Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+				))
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+				span.RecordError(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package infrastructure_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/handler"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
+)
+
+// spans records every span of the tests. The tracers the use cases and
+// repositories hold are taken once, and follow the first provider set
+// globally, so that one is installed before any test runs and kept.
+var spans = tracetest.NewInMemoryExporter()
+
+func init() {
+	recordSpans()
+}
+
+// recordSpans makes spans the global provider's exporter
+func recordSpans() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// tracedApp is the server as main.go wires it, on in-memory stores, with
+// one more route that panics. Its spans are recorded in spans.
+type tracedApp struct {
+	e        *echo.Echo
+	token    string
+	exporter *tracetest.InMemoryExporter
+}
+
+func newTracedApp(t *testing.T) *tracedApp {
+	t.Helper()
+	users := repository.NewTracedUserRepository(repository.NewMemoryUserRepository())
+	tasks := repository.NewTracedTaskRepository(repository.NewMemoryTaskRepository())
+	auth := usecase.NewAuthUseCase(users, infrastructure.BcryptHasher{Cost: bcrypt.MinCost}, newTokens(t))
+	taskUseCase := usecase.NewTaskUseCase(tasks)
+	userUseCase := usecase.NewUserUseCase(users)
+
+	e := echo.New()
+	e.Logger.SetOutput(io.Discard) // the panic is on purpose
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(infrastructure.TracingMiddleware())
+	e.Use(middleware.Recover())
+	handler.RegisterRoutes(handler.NewRouteTable(e), handler.Handlers{
+		Auth:         handler.NewAuthHandler(auth),
+		Tasks:        handler.NewTaskHandler(taskUseCase),
+		Users:        handler.NewUserHandler(userUseCase),
+		GraphQL:      handler.NewGraphQLHandler(taskUseCase, userUseCase),
+		Authenticate: handler.RequireUser(auth),
+	})
+	e.GET("/panic", func(echo.Context) error { panic("on purpose") })
+
+	ctx := context.Background()
+	if _, err := auth.Register(ctx, "ada@example.com", "correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	session, err := auth.Login(ctx, "ada@example.com", "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tracedApp{e: e, token: session.Token, exporter: spans}
+}
+
+// trace sends a request as the registered user and returns its status and
+// the spans it produced. header adds request headers.
+func (a *tracedApp) trace(method, path, body string, header ...string) (int, tracetest.SpanStubs) {
+	a.exporter.Reset()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+a.token)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.e.ServeHTTP(rec, req)
+	return rec.Code, a.exporter.GetSpans()
+}
+
+// tree draws spans as their hierarchy, one span per line, children indented
+// under their parent in the order they started
+func tree(spans tracetest.SpanStubs) string {
+	children := map[trace.SpanID][]tracetest.SpanStub{}
+	ids := map[trace.SpanID]bool{}
+	for _, s := range spans {
+		ids[s.SpanContext.SpanID()] = true
+	}
+	var roots []tracetest.SpanStub
+	for _, s := range spans {
+		if ids[s.Parent.SpanID()] {
+			children[s.Parent.SpanID()] = append(children[s.Parent.SpanID()], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+	var b strings.Builder
+	var draw func(level int, spans []tracetest.SpanStub)
+	draw = func(level int, spans []tracetest.SpanStub) {
+		sort.Slice(spans, func(i, j int) bool { return spans[i].StartTime.Before(spans[j].StartTime) })
+		for _, s := range spans {
+			fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", level), s.Name)
+			draw(level+1, children[s.SpanContext.SpanID()])
+		}
+	}
+	draw(0, roots)
+	return b.String()
+}
+
+// named returns the span called name
+func named(spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	return tracetest.SpanStub{}
+}
+
+func attr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// oneTrace reports whether every span is in the same trace
+func oneTrace(spans tracetest.SpanStubs) bool {
+	for _, s := range spans {
+		if s.SpanContext.TraceID() != spans[0].SpanContext.TraceID() {
+			return false
+		}
+	}
+	return len(spans) > 0
+}
+
+func TestTracingNestsTheLayers(t *testing.T) {
+	a := newTracedApp(t)
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		want         string
+	}{
+		{"a create: authentication and the use case, each over its repository", http.MethodPost, "/api/v1/tasks", `{"title":"Write report"}`, `POST /api/v1/tasks
+  AuthUseCase.Authenticate
+    UserRepository.GetByID
+  TaskUseCase.CreateTask
+    TaskRepository.Create
+`},
+		{"a bulk create stores its batch under its use case", http.MethodPost, "/api/v1/tasks/bulk", `{"tasks":[{"title":"A"},{"title":"B"}]}`, `POST /api/v1/tasks/bulk
+  AuthUseCase.Authenticate
+    UserRepository.GetByID
+  TaskUseCase.BulkCreateTasks
+    TaskRepository.CreateBatch
+`},
+		{"a bulk complete nests each CompleteTask it runs, in order", http.MethodPost, "/api/v1/tasks/bulk/complete", `{"ids":[1,2]}`, `POST /api/v1/tasks/bulk/complete
+  AuthUseCase.Authenticate
+    UserRepository.GetByID
+  TaskUseCase.BulkCompleteTasks
+    TaskUseCase.CompleteTask
+      TaskRepository.GetByID
+      TaskRepository.Update
+    TaskUseCase.CompleteTask
+      TaskRepository.GetByID
+      TaskRepository.Update
+`},
+	}
+	// The cases run in order: the bulk complete needs the tasks created
+	// before it
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, spans := a.trace(tt.method, tt.path, tt.body)
+			if got := tree(spans); got != tt.want || !oneTrace(spans) {
+				t.Errorf("spans, in one trace = %t:\n%s\nwant:\n%s", oneTrace(spans), got, tt.want)
+			}
+			if i > 0 {
+				return
+			}
+			server := named(spans, "POST /api/v1/tasks")
+			if status != http.StatusCreated || server.SpanKind != trace.SpanKindServer || server.Parent.IsValid() ||
+				attr(server, "http.route").AsString() != "/api/v1/tasks" || attr(server, "http.response.status_code").AsInt64() != 201 {
+				t.Errorf("the request span = %+v, want the root, a server span with the route and the status sent", server)
+			}
+			repo := named(spans, "TaskRepository.Create")
+			if repo.SpanKind != trace.SpanKindClient || attr(repo, "task.id").AsInt64() != 1 ||
+				attr(named(spans, "TaskUseCase.CreateTask"), "user.id").AsInt64() != 1 {
+				t.Error("want the repository span a client span with the new task's ID, and the use case's with the actor's")
+			}
+		})
+	}
+}
+
+func TestTracingRecordsErrorsWhereTheyHappened(t *testing.T) {
+	a := newTracedApp(t)
+	status, spans := a.trace(http.MethodGet, "/api/v1/tasks/999", "")
+	useCase, repo, server := named(spans, "TaskUseCase.GetTask"), named(spans, "TaskRepository.GetByID"), named(spans, "GET /api/v1/tasks/:id")
+	if status != http.StatusNotFound || repo.Status.Code != codes.Error {
+		t.Errorf("a missing task = %d with the repository span %v, want 404 and it failed", status, repo.Status)
+	}
+	if useCase.Status.Code != codes.Error || useCase.Status.Description != usecase.ErrTaskNotFound.Error() ||
+		len(useCase.Events) != 1 || useCase.Events[0].Name != "exception" {
+		t.Errorf("the use case span = %v, %v; want it failed, with the error it returned recorded", useCase.Status, useCase.Events)
+	}
+	if server.Status.Code != codes.Unset || attr(server, "http.response.status_code").AsInt64() != 404 {
+		t.Errorf("the request span = %v, want a 404 without failing: the server did nothing wrong", server.Status)
+	}
+
+	status, spans = a.trace(http.MethodGet, "/panic", "")
+	server = named(spans, "GET /panic")
+	if status != http.StatusInternalServerError || server.Status.Code != codes.Error || attr(server, "http.response.status_code").AsInt64() != 500 {
+		t.Errorf("a panic = %d with the request span %v, want it failed as the 500 it became", status, server.Status)
+	}
+}
+
+func TestTracingContinuesTheCallersTrace(t *testing.T) {
+	a := newTracedApp(t)
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	_, spans := a.trace(http.MethodGet, "/api/v1/tasks", "", "traceparent", "00-"+traceID+"-"+parentID+"-01")
+	server := named(spans, "GET /api/v1/tasks")
+	if !oneTrace(spans) || server.SpanContext.TraceID().String() != traceID {
+		t.Errorf("trace %s, want the caller's, %s", server.SpanContext.TraceID(), traceID)
+	}
+	if server.Parent.SpanID().String() != parentID || !server.Parent.IsRemote() {
+		t.Errorf("parent %s, want the caller's span, %s", server.Parent.SpanID(), parentID)
+	}
+	if named(spans, "TaskRepository.List").Parent.SpanID() != named(spans, "TaskUseCase.ListTasks").SpanContext.SpanID() {
+		t.Error("the layers below should still nest under it")
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	// InitTracing sets the global provider, which the other tests use
+	t.Cleanup(recordSpans)
+	var out bytes.Buffer
+	stop, err := infrastructure.InitTracing(context.Background(), infrastructure.TracingConfig{
+		Exporter: infrastructure.TracesStdout, ServiceName: "tracing-test", Output: &out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("tracing-test").Start(context.Background(), "stdout span")
+	span.End()
+	if err := stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"Name":"stdout span"`) || !strings.Contains(out.String(), `"Value":"tracing-test"`) {
+		t.Errorf("stdout = %s, want each span as JSON, with the service name", out.String())
+	}
+}
+
+// collector is an OTLP/HTTP receiver that keeps the spans sent to it
+type collector struct {
+	mu    sync.Mutex
+	path  string
+	names []string
+}
+
+func (col *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.path = r.URL.Path
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				col.names = append(col.names, s.Name)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	// InitTracing sets the global provider, which the other tests use
+	t.Cleanup(recordSpans)
+	col := &collector{}
+	server := httptest.NewServer(col)
+	defer server.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+
+	stop, err := infrastructure.InitTracing(context.Background(), infrastructure.TracingConfig{
+		Exporter: infrastructure.TracesOTLP, ServiceName: "tracing-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("tracing-test").Start(context.Background(), "otlp span")
+	span.End()
+	if err := stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	if col.path != "/v1/traces" || len(col.names) != 1 || col.names[0] != "otlp span" {
+		t.Errorf("the collector got %v at %s, want the span at /v1/traces, flushed on shutdown", col.names, col.path)
+	}
+
+	if _, err := infrastructure.InitTracing(context.Background(), infrastructure.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("an unknown exporter was accepted")
+	}
+}
@@ -1,47 +1,71 @@
 package main
 
 import (
+"context"
+"errors"
+"flag"
 "log"
+"net"
+"net/http"
+"os"
 
-"github.com/dong-tran/docs/clean-architecture-example/handler"
-"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
-"github.com/dong-tran/docs/clean-architecture-example/repository"
-"github.com/dong-tran/docs/clean-architecture-example/usecase"
-"github.com/labstack/echo/v4"
-"github.com/labstack/echo/v4/middleware"
+"github.com/dong-tran/docs/clean-architecture-example/app"
+"github.com/dong-tran/docs/clean-architecture-example/config"
+"github.com/dong-tran/docs/concurrency-example/shutdown"
 )
 
 func main() {
-	// Initialize database (outermost layer)
-	db, err := infrastructure.InitDatabase()
+	// Every setting comes from its default, then the YAML file of -config or
+	// CONFIG_FILE, then the environment, then the flags: -h lists them
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
-	defer db.Close()
-
-	// Dependency injection from outer to inner layers
-	taskRepo := repository.NewTaskRepository(db)
-	taskUseCase := usecase.NewTaskUseCase(taskRepo)
-	taskHandler := handler.NewTaskHandler(taskUseCase)
-
-	// Setup Echo framework
-	e := echo.New()
 
-	// Middleware
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	// Every layer, the routes and what they depend on (app/app.go)
+	a, err := app.New(cfg, app.Options{Logf: log.Printf})
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	e := a.Echo
 
-	// Routes
-	e.POST("/tasks", taskHandler.CreateTask)
-	e.GET("/tasks/:id", taskHandler.GetTask)
-	e.GET("/tasks", taskHandler.GetAllTasks)
-	e.PUT("/tasks/:id", taskHandler.UpdateTask)
-	e.DELETE("/tasks/:id", taskHandler.DeleteTask)
+	// Lifecycle: on SIGINT/SIGTERM the server stops accepting and finishes
+	// the requests in flight before what it depends on stops. Before any of
+	// that, readiness turns 503 for server.drain_delay (default none), for
+	// load balancers to stop sending requests.
+	a.Lifecycle.Register(
+		shutdown.Component{
+			Name:      "http",
+			DependsOn: a.Dependencies,
+			Start: func(context.Context) error {
+				// Listen here so a port in use fails Start rather than the goroutine
+				ln, err := net.Listen("tcp", cfg.Server.Addr)
+				if err != nil {
+					return err
+				}
+				e.Listener = ln
+				go func() {
+					if err := e.Start(""); err != nil && err != http.ErrServerClosed {
+						log.Fatalf("Server stopped: %v", err)
+					}
+				}()
+				return nil
+			},
+			Stop: e.Shutdown,
+		},
+		shutdown.Component{
+			Name:      "readiness",
+			DependsOn: []string{"http"},
+			Stop:      func(ctx context.Context) error { return a.Health.Drain(ctx, cfg.Server.DrainDelay) },
+		},
+	)
 
-	// Start server
-	log.Println("Server starting on :8080")
-	if err := e.Start(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	log.Printf("Server starting on %s", cfg.Server.Addr)
+	if err := a.Lifecycle.Run(context.Background(), cfg.Server.ShutdownTimeout); err != nil {
+		log.Fatalf("Failed to run server: %v", err)
 	}
 }
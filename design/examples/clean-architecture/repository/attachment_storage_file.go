@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// FileAttachmentStorage is a domain.AttachmentStorage in a directory of the
+// local filesystem. Each task has a subdirectory named by its ID, with a
+// file per attachment: a line of JSON describing it, then its content. The
+// file is named by the SHA-256 of the attachment's name, so no name can
+// escape the directory, be too long for it, or clash with another on a
+// case-insensitive filesystem.
+//
+// An attachment is written to a temporary file and renamed into place once
+// complete, so a reader sees the old content or the new, never part of it.
+type FileAttachmentStorage struct {
+	dir string
+}
+
+// fileAttachmentHeader is the first line of an attachment's file
+type fileAttachmentHeader struct {
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// tempPrefix starts the names of files being written; a hash never does
+const tempPrefix = ".upload-"
+
+// NewFileAttachmentStorage returns a storage in dir, creating it if need be
+func NewFileAttachmentStorage(dir string) (*FileAttachmentStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileAttachmentStorage{dir: dir}, nil
+}
+
+func (s *FileAttachmentStorage) taskDir(taskID int64) string {
+	return filepath.Join(s.dir, strconv.FormatInt(taskID, 10))
+}
+
+func (s *FileAttachmentStorage) path(taskID int64, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(s.taskDir(taskID), hex.EncodeToString(sum[:]))
+}
+
+func (s *FileAttachmentStorage) Put(ctx context.Context, attachment domain.Attachment, content io.Reader) (_ domain.Attachment, err error) {
+	dir := s.taskDir(attachment.TaskID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return domain.Attachment{}, err
+	}
+	file, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	header, err := json.Marshal(fileAttachmentHeader{Name: attachment.Name, ContentType: attachment.ContentType, CreatedAt: attachment.CreatedAt})
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	if _, err := file.Write(append(header, '\n')); err != nil {
+		return domain.Attachment{}, err
+	}
+	if attachment.Size, err = io.Copy(file, content); err != nil {
+		return domain.Attachment{}, err
+	}
+	if err := file.Sync(); err != nil {
+		return domain.Attachment{}, err
+	}
+	if err := file.Close(); err != nil {
+		return domain.Attachment{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return domain.Attachment{}, err
+	}
+	if err := os.Rename(file.Name(), s.path(attachment.TaskID, attachment.Name)); err != nil {
+		return domain.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (s *FileAttachmentStorage) Open(ctx context.Context, taskID int64, name string) (domain.Attachment, io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	file, err := os.Open(s.path(taskID, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return domain.Attachment{}, nil, domain.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	attachment, content, err := readAttachment(file, taskID)
+	if err != nil {
+		file.Close()
+		return domain.Attachment{}, nil, err
+	}
+	return attachment, struct {
+		io.Reader
+		io.Closer
+	}{content, file}, nil
+}
+
+// readAttachment reads the header of an attachment's file, and returns the
+// reader its content follows in
+func readAttachment(file *os.File, taskID int64) (domain.Attachment, io.Reader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	content := bufio.NewReader(file)
+	line, err := content.ReadBytes('\n')
+	if err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	var header fileAttachmentHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	return domain.Attachment{
+		TaskID:      taskID,
+		Name:        header.Name,
+		ContentType: header.ContentType,
+		Size:        info.Size() - int64(len(line)),
+		CreatedAt:   header.CreatedAt,
+	}, content, nil
+}
+
+func (s *FileAttachmentStorage) List(ctx context.Context, taskID int64) ([]domain.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(s.taskDir(taskID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []domain.Attachment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]domain.Attachment, 0, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), tempPrefix) {
+			continue
+		}
+		attachment, err := statAttachment(filepath.Join(s.taskDir(taskID), entry.Name()), taskID)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // deleted since the directory was read
+		}
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	return attachments, nil
+}
+
+// statAttachment returns the attachment in the file at path, without its content
+func statAttachment(path string, taskID int64) (domain.Attachment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	defer file.Close()
+	attachment, _, err := readAttachment(file, taskID)
+	return attachment, err
+}
+
+func (s *FileAttachmentStorage) Delete(ctx context.Context, taskID int64, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := os.Remove(s.path(taskID, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return domain.ErrAttachmentNotFound
+	}
+	return err
+}
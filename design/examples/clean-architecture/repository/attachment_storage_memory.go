@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// MemoryAttachmentStorage is a domain.AttachmentStorage kept in memory, for
+// one server without a disk to write to. Its attachments are gone on
+// restart.
+type MemoryAttachmentStorage struct {
+	mu    sync.Mutex
+	files map[int64]map[string]memoryAttachment
+}
+
+type memoryAttachment struct {
+	attachment domain.Attachment
+	content    []byte // never changed once stored, so readers share it
+}
+
+func NewMemoryAttachmentStorage() *MemoryAttachmentStorage {
+	return &MemoryAttachmentStorage{files: map[int64]map[string]memoryAttachment{}}
+}
+
+func (s *MemoryAttachmentStorage) Put(ctx context.Context, attachment domain.Attachment, content io.Reader) (domain.Attachment, error) {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, content); err != nil {
+		return domain.Attachment{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return domain.Attachment{}, err
+	}
+	attachment.Size = int64(buf.Len())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files[attachment.TaskID] == nil {
+		s.files[attachment.TaskID] = map[string]memoryAttachment{}
+	}
+	s.files[attachment.TaskID][attachment.Name] = memoryAttachment{attachment: attachment, content: buf.Bytes()}
+	return attachment, nil
+}
+
+func (s *MemoryAttachmentStorage) Open(ctx context.Context, taskID int64, name string) (domain.Attachment, io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.files[taskID][name]
+	if !ok {
+		return domain.Attachment{}, nil, domain.ErrAttachmentNotFound
+	}
+	return file.attachment, io.NopCloser(bytes.NewReader(file.content)), nil
+}
+
+func (s *MemoryAttachmentStorage) List(ctx context.Context, taskID int64) ([]domain.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attachments := make([]domain.Attachment, 0, len(s.files[taskID]))
+	for _, file := range s.files[taskID] {
+		attachments = append(attachments, file.attachment)
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	return attachments, nil
+}
+
+func (s *MemoryAttachmentStorage) Delete(ctx context.Context, taskID int64, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[taskID][name]; !ok {
+		return domain.ErrAttachmentNotFound
+	}
+	delete(s.files[taskID], name)
+	if len(s.files[taskID]) == 0 {
+		delete(s.files, taskID)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+var errBroken = errors.New("connection reset")
+
+// broken is content that fails after prefix, like an upload whose client
+// goes away
+func broken(prefix string) io.Reader {
+	return io.MultiReader(strings.NewReader(prefix), brokenOff{})
+}
+
+type brokenOff struct{}
+
+func (brokenOff) Read([]byte) (int, error) { return 0, errBroken }
+
+// letters is n bytes of text, made as they are read
+type letters struct{ n int64 }
+
+func (l *letters) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	for i := range p {
+		p[i] = 'a'
+	}
+	l.n -= int64(len(p))
+	return len(p), nil
+}
+
+// readAttachment opens an attachment and reads all of its content
+func readAttachment(s domain.AttachmentStorage, taskID int64, name string) (domain.Attachment, string, error) {
+	attachment, content, err := s.Open(context.Background(), taskID, name)
+	if err != nil {
+		return attachment, "", err
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	return attachment, string(data), err
+}
+
+func attachmentNames(attachments []domain.Attachment) []string {
+	out := []string{}
+	for _, a := range attachments {
+		out = append(out, a.Name)
+	}
+	return out
+}
+
+// attachmentStorages runs test against the in-memory and the filesystem
+// storage
+func attachmentStorages(t *testing.T, test func(t *testing.T, s domain.AttachmentStorage)) {
+	t.Run("memory", func(t *testing.T) { test(t, repository.NewMemoryAttachmentStorage()) })
+	t.Run("file", func(t *testing.T) {
+		s, err := repository.NewFileAttachmentStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, s)
+	})
+}
+
+func TestAttachmentStorage(t *testing.T) {
+	attachmentStorages(t, func(t *testing.T, s domain.AttachmentStorage) {
+		ctx := context.Background()
+		created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		notes := domain.Attachment{TaskID: 1, Name: "notes.txt", ContentType: "text/plain", CreatedAt: created}
+		put := func(a domain.Attachment, content io.Reader) {
+			t.Helper()
+			if _, err := s.Put(ctx, a, content); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stored, err := s.Put(ctx, notes, strings.NewReader("first draft"))
+		want := notes
+		want.Size = int64(len("first draft"))
+		if err != nil || stored != want {
+			t.Errorf("Put = %+v, %v, want the attachment with its size, %+v", stored, err, want)
+		}
+		got, content, err := readAttachment(s, 1, "notes.txt")
+		if err != nil || got.Name != want.Name || got.ContentType != want.ContentType || got.Size != want.Size ||
+			!got.CreatedAt.Equal(created) || content != "first draft" {
+			t.Errorf("Open = %+v, %q, %v, want it with its content", got, content, err)
+		}
+
+		put(notes, strings.NewReader("second draft"))
+		if _, content, _ = readAttachment(s, 1, "notes.txt"); content != "second draft" {
+			t.Errorf("Put under a name in use left %q, want it replaced", content)
+		}
+
+		_, err = s.Put(ctx, notes, broken("third dr"))
+		_, content, _ = readAttachment(s, 1, "notes.txt")
+		if !errors.Is(err, errBroken) || content != "second draft" {
+			t.Errorf("content failing to read = %v and left %q, want the old content kept", err, content)
+		}
+		_, err = s.Put(ctx, domain.Attachment{TaskID: 1, Name: "lost.txt"}, broken("half"))
+		if _, _, openErr := s.Open(ctx, 1, "lost.txt"); !errors.Is(err, errBroken) || !errors.Is(openErr, domain.ErrAttachmentNotFound) {
+			t.Errorf("a new attachment failing to read = %v, and opening it %v, want nothing stored", err, openErr)
+		}
+
+		// Names differing in case, with spaces, accents, a leading dot or
+		// of the longest length are kept apart
+		odd := []string{"Notes.txt", "my report (final).pdf", "résumé ✓.txt", ".hidden", strings.Repeat("n", domain.MaxAttachmentNameLength)}
+		for _, name := range odd {
+			put(domain.Attachment{TaskID: 1, Name: name, ContentType: "text/plain"}, strings.NewReader(name))
+		}
+		for _, name := range odd {
+			if _, content, err := readAttachment(s, 1, name); err != nil || content != name {
+				t.Errorf("%q reads %q, %v, want its own content", name, content, err)
+			}
+		}
+
+		put(domain.Attachment{TaskID: 2, Name: "notes.txt"}, strings.NewReader("another task"))
+		list, err := s.List(ctx, 1)
+		wantNames := []string{".hidden", "Notes.txt", "my report (final).pdf", strings.Repeat("n", domain.MaxAttachmentNameLength), "notes.txt", "résumé ✓.txt"}
+		if err != nil || !reflect.DeepEqual(attachmentNames(list), wantNames) {
+			t.Errorf("List = %q, %v, want the task's attachments by name, %q", attachmentNames(list), err, wantNames)
+		}
+		if list, err = s.List(ctx, 3); err != nil || list == nil || len(list) != 0 {
+			t.Errorf("List of a task without any = %#v, %v, want an empty list", list, err)
+		}
+
+		err = s.Delete(ctx, 1, "notes.txt")
+		_, _, openErr := s.Open(ctx, 1, "notes.txt")
+		_, other, _ := readAttachment(s, 2, "notes.txt")
+		if err != nil || !errors.Is(openErr, domain.ErrAttachmentNotFound) || other != "another task" {
+			t.Errorf("Delete = %v, then Open = %v and the other task's reads %q, want only that task's removed", err, openErr, other)
+		}
+		if err := s.Delete(ctx, 1, "notes.txt"); !errors.Is(err, domain.ErrAttachmentNotFound) {
+			t.Errorf("deleting it again = %v, want %v", err, domain.ErrAttachmentNotFound)
+		}
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, putErr := s.Put(canceled, domain.Attachment{TaskID: 1, Name: "late.txt"}, strings.NewReader("late"))
+		_, _, openErr = s.Open(ctx, 1, "late.txt")
+		_, listErr := s.List(canceled, 1)
+		if !errors.Is(putErr, context.Canceled) || !errors.Is(openErr, domain.ErrAttachmentNotFound) || !errors.Is(listErr, context.Canceled) {
+			t.Errorf("with a canceled context, Put = %v, Open after = %v, List = %v; want nothing stored or listed", putErr, openErr, listErr)
+		}
+
+		// A reader keeps what it opened, whatever is stored after
+		_, opened, err := s.Open(ctx, 2, "notes.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		put(domain.Attachment{TaskID: 2, Name: "notes.txt"}, strings.NewReader("replaced while read"))
+		if err := s.Delete(ctx, 2, "notes.txt"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(opened)
+		opened.Close()
+		if err != nil || string(data) != "another task" {
+			t.Errorf("content opened before a replace and a delete reads %q, %v, want it unchanged", data, err)
+		}
+
+		big := int64(usecase.MaxAttachmentSize)
+		stored, err = s.Put(ctx, domain.Attachment{TaskID: 4, Name: "big.txt"}, &letters{n: big})
+		got, content, _ = readAttachment(s, 4, "big.txt")
+		if err != nil || stored.Size != big || got.Size != big || int64(len(content)) != big || content[big-1] != 'a' {
+			t.Errorf("10 MiB streamed in came back as %d bytes (%v), want all of it", len(content), err)
+		}
+	})
+}
+
+func TestFileAttachmentStorageLayout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := repository.NewFileAttachmentStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../../escape.txt", "notes.txt"} {
+		if _, err := s.Put(ctx, domain.Attachment{TaskID: 7, Name: name, ContentType: "text/plain"}, strings.NewReader("stay")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Put(ctx, domain.Attachment{TaskID: 7, Name: "gone.txt"}, broken("part")); !errors.Is(err, errBroken) {
+		t.Fatal(err)
+	}
+
+	// No name escapes and no upload is left behind: every file is
+	// <task ID>/<64 hex digits>
+	hashed := regexp.MustCompile(`^[0-9]+/[0-9a-f]{64}$`)
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			if !hashed.MatchString(filepath.ToSlash(rel)) {
+				t.Errorf("%s is not in its task's directory, named by a hash", rel)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := repository.NewFileAttachmentStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, content, err := readAttachment(reopened, 7, "../../escape.txt")
+	list, _ := reopened.List(ctx, 7)
+	if err != nil || content != "stay" || len(list) != 2 {
+		t.Errorf("opened again, the directory reads %q, %v with %d attachments, want both", content, err, len(list))
+	}
+}
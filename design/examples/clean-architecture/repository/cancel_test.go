@@ -0,0 +1,128 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// canceled is a context that is already done
+func canceled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// openBusySQLite opens a SQLite database limited to one connection, which
+// hold can take so that every call blocks on the pool until its context ends
+func openBusySQLite(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+// hold takes the database's only connection until the test ends
+func hold(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+}
+
+func TestDoneContextStopsEveryMethod(t *testing.T) {
+	repositories := []struct {
+		name string
+		open func(t *testing.T) (domain.TaskRepository, domain.UserRepository)
+	}{
+		{"memory", func(*testing.T) (domain.TaskRepository, domain.UserRepository) {
+			return repository.NewMemoryTaskRepository(), repository.NewMemoryUserRepository()
+		}},
+		{"sqlite", func(t *testing.T) (domain.TaskRepository, domain.UserRepository) {
+			db := openBusySQLite(t)
+			return repository.NewTaskRepository(db), repository.NewUserRepository(db)
+		}},
+	}
+	for _, r := range repositories {
+		t.Run(r.name, func(t *testing.T) {
+			tasks, users := r.open(t)
+			kept, err := domain.NewTask(1, "kept", "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tasks.Create(context.Background(), kept); err != nil {
+				t.Fatal(err)
+			}
+			done := canceled()
+			fresh, _ := domain.NewTask(1, "never stored", "", time.Now())
+			changed := *kept
+			changed.Title = "changed"
+
+			calls := []struct {
+				name string
+				call func() error
+			}{
+				{"Create", func() error { return tasks.Create(done, fresh) }},
+				{"CreateBatch", func() error { return tasks.CreateBatch(done, []*domain.Task{fresh}) }},
+				{"GetByID", func() error { _, err := tasks.GetByID(done, kept.ID); return err }},
+				{"List", func() error { _, err := tasks.List(done, domain.ListTasksQuery{}); return err }},
+				{"Update", func() error { return tasks.Update(done, &changed) }},
+				{"Delete", func() error { return tasks.Delete(done, kept.ID) }},
+				{"users.Create", func() error { return users.Create(done, domain.NewUser("new@example.com", "hash")) }},
+				{"users.GetByEmail", func() error { _, err := users.GetByEmail(done, "new@example.com"); return err }},
+				{"users.List", func() error { _, err := users.List(done); return err }},
+			}
+			for _, c := range calls {
+				if err := c.call(); !errors.Is(err, context.Canceled) {
+					t.Errorf("%s = %v, want context.Canceled", c.name, err)
+				}
+			}
+			if fresh.ID != 0 {
+				t.Errorf("Create assigned ID %d", fresh.ID)
+			}
+			all, err := tasks.List(context.Background(), domain.ListTasksQuery{})
+			if err != nil || len(all) != 1 || all[0].Title != "kept" {
+				t.Errorf("afterwards the repository holds %d tasks, %v, want only the one it had", len(all), err)
+			}
+		})
+	}
+}
+
+func TestBusyDatabaseGivesUpWithTheContext(t *testing.T) {
+	db := openBusySQLite(t)
+	tasks := repository.NewTaskRepository(db)
+	hold(t, db)
+
+	t.Run("a List returns when canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := tasks.List(ctx, domain.ListTasksQuery{})
+		if !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+			t.Errorf("List = %v after %s, want context.Canceled soon after the cancel", err, time.Since(start))
+		}
+	})
+	t.Run("a Create returns at its deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		task, _ := domain.NewTask(1, "late", "", time.Now())
+		start := time.Now()
+		err := tasks.Create(ctx, task)
+		if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+			t.Errorf("Create = %v after %s, want context.DeadlineExceeded soon after the deadline", err, time.Since(start))
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// The SQL repositories run on SQLite and PostgreSQL, told apart by the driver
+// the *sqlx.DB was opened with. Queries are written with ? placeholders and
+// go through Rebind, which numbers them $1, $2, ... for PostgreSQL. New IDs
+// come from INSERT ... RETURNING id on both, so SQLite runs the same code
+// path PostgreSQL does. What else differs is here.
+
+type dialect int
+
+const (
+	sqliteDialect dialect = iota
+	postgresDialect
+)
+
+func dialectOf(db *sqlx.DB) dialect {
+	if sqlx.BindType(db.DriverName()) == sqlx.DOLLAR {
+		return postgresDialect
+	}
+	return sqliteDialect
+}
+
+// instant wraps a timestamp column or parameter so it compares as an
+// instant. SQLite keeps times as text, in whatever offset they were written
+// with; PostgreSQL's timestamptz already compares that way.
+func (d dialect) instant(expr string) string {
+	if d == postgresDialect {
+		return expr
+	}
+	return "julianday(" + expr + ")"
+}
+
+// foldASCII lowercases the ASCII letters of expr only, as
+// ListTasksQuery.Matches does
+const foldASCII = "translate(%s, 'ABCDEFGHIJKLMNOPQRSTUVWXYZ', 'abcdefghijklmnopqrstuvwxyz')"
+
+// contains is a condition that expr contains the LIKE pattern bound to the
+// next ?, ignoring the case of ASCII letters. SQLite's LIKE already does; in
+// PostgreSQL, LIKE is case-sensitive and ILIKE folds every letter, so both
+// sides are folded by hand.
+func (d dialect) contains(expr string) string {
+	if d == postgresDialect {
+		return fmt.Sprintf(foldASCII, expr) + " LIKE " + fmt.Sprintf(foldASCII, "?") + ` ESCAPE '\'`
+	}
+	return expr + ` LIKE ? ESCAPE '\'`
+}
+
+// isUniqueViolation reports whether err is a UNIQUE constraint failing, in
+// either database
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation"
+}
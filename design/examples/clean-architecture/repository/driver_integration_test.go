@@ -0,0 +1,208 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// These tests run on a database as InitDatabase leaves it on a server's
+// first start: the automatic migrations applied, the manual ones not, so
+// descriptions are still in tasks.description.
+
+// initialized opens config as the server does, and nothing more
+func initialized(t *testing.T, config infrastructure.DatabaseConfig) *sqlx.DB {
+	t.Helper()
+	db, err := infrastructure.InitDatabase(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var driverBase = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// driverTasks returns the same tasks every time it is called. Their times
+// are written in different offsets, which must not change how they compare.
+func driverTasks(t *testing.T) []*domain.Task {
+	t.Helper()
+	east, west := time.FixedZone("+05:00", 5*3600), time.FixedZone("-07:00", -7*3600)
+	specs := []struct {
+		owner       int64
+		title, desc string
+		completed   bool
+		priority    domain.Priority
+		tags        []string
+		created     time.Time
+	}{
+		{1, "Write report", "Quarterly numbers", false, domain.PriorityHigh, []string{"work"}, driverBase},
+		{1, "Buy MILK", "", true, domain.PriorityLow, []string{"errands", "home"}, driverBase.Add(time.Hour).In(east)},
+		{2, "100% done_ish", `back\slash`, false, domain.PriorityMedium, []string{"work"}, driverBase.Add(2 * time.Hour)},
+		{2, "Café visit", "ÉCLAIR", false, domain.PriorityMedium, nil, driverBase.Add(3 * time.Hour).In(west)},
+		{1, "report review", "", false, domain.PriorityMedium, []string{"home", "work"}, driverBase.Add(3 * time.Hour)},
+	}
+	tasks := make([]*domain.Task, len(specs))
+	for i, s := range specs {
+		task, err := domain.NewTask(s.owner, s.title, s.desc, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Completed, task.Priority, task.Tags = s.completed, s.priority, s.tags
+		task.CreatedAt, task.UpdatedAt = s.created, s.created
+		tasks[i] = task
+	}
+	return tasks
+}
+
+// storeDriverTasks stores driverTasks in r, the first with Create and the
+// rest with CreateBatch, and returns them
+func storeDriverTasks(t *testing.T, r domain.TaskRepository) []*domain.Task {
+	t.Helper()
+	tasks := driverTasks(t)
+	if err := r.Create(context.Background(), tasks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateBatch(context.Background(), tasks[1:]); err != nil {
+		t.Fatal(err)
+	}
+	return tasks
+}
+
+func TestInitDatabaseLeavesTheManualMigrations(t *testing.T) {
+	eachDriver(t, func(t *testing.T, config infrastructure.DatabaseConfig) {
+		applied, err := infrastructure.AppliedMigrations(initialized(t, config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range infrastructure.Migrations {
+			if applied[m.Version] == m.Manual {
+				t.Errorf("migration %d (manual: %t) applied: %t", m.Version, m.Manual, applied[m.Version])
+			}
+		}
+	})
+}
+
+// TestListMatchesMemory runs every kind of list query in SQL and against the
+// in-memory repository, which filters with ListTasksQuery.Matches: both must
+// return the same tasks in the same order
+func TestListMatchesMemory(t *testing.T) {
+	completed := func(b bool) *bool { return &b }
+	queries := []struct {
+		name  string
+		query domain.ListTasksQuery
+	}{
+		{"everything, newest first", domain.ListTasksQuery{}},
+		{"one owner", domain.ListTasksQuery{OwnerID: 1}},
+		{"completed", domain.ListTasksQuery{Completed: completed(true)}},
+		{"not completed", domain.ListTasksQuery{Completed: completed(false)}},
+		{"created from, across offsets", domain.ListTasksQuery{CreatedFrom: driverBase.Add(time.Hour)}},
+		{"created before, across offsets", domain.ListTasksQuery{CreatedBefore: driverBase.Add(3 * time.Hour)}},
+		{"search ignores ASCII case", domain.ListTasksQuery{Search: "REPORT"}},
+		{"search in the description", domain.ListTasksQuery{Search: "quarterly"}},
+		{"search for a literal %", domain.ListTasksQuery{Search: "0%"}},
+		{"search for a literal _", domain.ListTasksQuery{Search: "e_i"}},
+		{`search for a literal \`, domain.ListTasksQuery{Search: `k\s`}},
+		{"search does not fold other letters", domain.ListTasksQuery{Search: "éclair"}},
+		{"one tag", domain.ListTasksQuery{Tags: []string{"work"}}},
+		{"every tag", domain.ListTasksQuery{Tags: []string{"home", "work"}}},
+		{"owner, search and tag together", domain.ListTasksQuery{OwnerID: 1, Search: "r", Tags: []string{"work"}}},
+	}
+	memory := repository.NewMemoryTaskRepository()
+	storeDriverTasks(t, memory)
+
+	eachDriver(t, func(t *testing.T, config infrastructure.DatabaseConfig) {
+		repo := repository.NewTaskRepositoryWithColumns(initialized(t, config), repository.DescriptionOnly)
+		stored := storeDriverTasks(t, repo)
+		if ids := foundIDs(stored); !reflect.DeepEqual(ids, []int64{1, 2, 3, 4, 5}) {
+			t.Errorf("Create and CreateBatch numbered the tasks %v, want from 1", ids)
+		}
+		for _, task := range stored {
+			if got, err := repo.GetByID(context.Background(), task.ID); err != nil || !sameTask(got, task) {
+				t.Errorf("GetByID(%d) = %+v, %v; want every field as stored, tags included", task.ID, got, err)
+			}
+		}
+		for _, q := range queries {
+			t.Run(q.name, func(t *testing.T) {
+				query, err := q.query.Normalize()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := repo.List(context.Background(), query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := memory.List(context.Background(), query)
+				if !reflect.DeepEqual(foundIDs(got), foundIDs(want)) {
+					t.Errorf("SQL lists %v, memory %v", foundIDs(got), foundIDs(want))
+				}
+			})
+		}
+	})
+}
+
+func TestFindPageWalksEveryTask(t *testing.T) {
+	eachDriver(t, func(t *testing.T, config infrastructure.DatabaseConfig) {
+		repo := repository.NewTaskRepositoryWithColumns(initialized(t, config), repository.DescriptionOnly)
+		storeDriverTasks(t, repo)
+		var paged []int64
+		cursor := ""
+		for pages := 0; pages < 10; pages++ {
+			page, next, err := repo.FindPage(context.Background(), cursor, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, foundIDs(page)...)
+			if cursor = next; cursor == "" {
+				break
+			}
+		}
+		if !reflect.DeepEqual(paged, []int64{1, 2, 3, 4, 5}) {
+			t.Errorf("FindPage walked %v, want every task in id order", paged)
+		}
+	})
+}
+
+// TestRenameOnEachDriver takes the description -> details rename from expand
+// to contract, the manual migrations and the backfill included
+func TestRenameOnEachDriver(t *testing.T) {
+	eachDriver(t, func(t *testing.T, config infrastructure.DatabaseConfig) {
+		ctx := context.Background()
+		db := initialized(t, config)
+		storeDriverTasks(t, repository.NewTaskRepositoryWithColumns(db, repository.DescriptionOnly))
+
+		if err := infrastructure.Migrate(db, infrastructure.SchemaExpanded); err != nil {
+			t.Fatalf("expand: %v", err)
+		}
+		task, _ := domain.NewTask(1, "Written during the rename", "both columns", time.Now())
+		if err := repository.NewTaskRepositoryWithColumns(db, repository.DualWriteReadDescription).Create(ctx, task); err != nil {
+			t.Fatalf("dual-writing code storing a task: %v", err)
+		}
+		p, err := infrastructure.BackfillDetails(ctx, db, 2, nil)
+		if err != nil || !p.Done || p.Copied != 5 {
+			t.Errorf("the backfill = %s, %v; want the five older descriptions copied", p, err)
+		}
+		if err := infrastructure.ContractReady(db); err != nil {
+			t.Fatalf("after the backfill the contract is not safe: %v", err)
+		}
+		if err := infrastructure.Migrate(db, infrastructure.SchemaContracted); err != nil {
+			t.Fatalf("contract: %v", err)
+		}
+
+		details := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+		if first, err := details.GetByID(ctx, 1); err != nil || first.Description != "Quarterly numbers" {
+			t.Errorf("the details-only repository reads %+v, %v", first, err)
+		}
+		if found, err := details.List(ctx, domain.ListTasksQuery{Search: "QUARTERLY"}); err != nil || !reflect.DeepEqual(foundIDs(found), []int64{1}) {
+			t.Errorf("searching what the backfill copied found %v, %v", foundIDs(found), err)
+		}
+	})
+}
@@ -0,0 +1,276 @@
+// Package fulltext is a domain.TaskSearchIndex on bleve, in a package of its
+// own like repository/mongodb, so that only the programs that search link
+// bleve in.
+package fulltext
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/highlight/format/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// TitleBoost is how much more a word counts in a task's title than in its
+// description
+const TitleBoost = 4
+
+// resetBatch is how many documents Reset writes at a time
+const resetBatch = 1000
+
+// openTimeout is how long Open waits for another process to release the
+// index, such as a server while cmd/reindex runs
+const openTimeout = "1s"
+
+// TaskIndex is a domain.TaskSearchIndex on bleve. Titles and descriptions are
+// analyzed as English: lowercased, without stop words and stemmed, so
+// "reports" finds "report". Each task is a document with its tenant, owner
+// and version; a deleted or archived one is kept as a document without
+// text, so a late update cannot bring it back, even after a restart.
+type TaskIndex struct {
+	index bleve.Index
+	// mu makes Apply's read of a task's version and its write one step
+	mu sync.Mutex
+}
+
+// taskDocument is what is indexed of a task
+type taskDocument struct {
+	ID          int64  `json:"id"`
+	Tenant      string `json:"tenant"`
+	OwnerID     int64  `json:"owner_id"`
+	Version     int64  `json:"version"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Deleted     bool   `json:"deleted"`
+}
+
+func documentOf(task *domain.Task) taskDocument {
+	return taskDocument{
+		ID:          task.ID,
+		Tenant:      string(task.TenantID.OrDefault()),
+		OwnerID:     task.OwnerID,
+		Version:     task.Version,
+		Title:       task.Title,
+		Description: task.Description,
+	}
+}
+
+func indexMapping() mapping.IndexMapping {
+	// Highlights are cut from the stored text, at the words' positions
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+	text.IncludeTermVectors = true
+
+	tenant := bleve.NewKeywordFieldMapping()
+	tenant.Store = false
+	number := bleve.NewNumericFieldMapping()
+	flag := bleve.NewBooleanFieldMapping()
+
+	task := bleve.NewDocumentStaticMapping()
+	task.AddFieldMappingsAt("id", number)
+	task.AddFieldMappingsAt("tenant", tenant)
+	task.AddFieldMappingsAt("owner_id", number)
+	task.AddFieldMappingsAt("version", number)
+	task.AddFieldMappingsAt("title", text)
+	task.AddFieldMappingsAt("description", text)
+	task.AddFieldMappingsAt("deleted", flag)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = task
+	m.DefaultAnalyzer = keyword.Name
+	return m
+}
+
+// Open opens the index in dir, creating it if dir does not exist. It fails
+// if another process has it open.
+func Open(dir string) (*TaskIndex, error) {
+	index, err := bleve.OpenUsing(dir, map[string]interface{}{"bolt_timeout": openTimeout})
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(dir, indexMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TaskIndex{index: index}, nil
+}
+
+// NewMemory returns an empty index kept in memory
+func NewMemory() (*TaskIndex, error) {
+	index, err := bleve.NewMemOnly(indexMapping())
+	if err != nil {
+		return nil, err
+	}
+	return &TaskIndex{index: index}, nil
+}
+
+// Close closes the index. Its signature is that of a shutdown.Component's
+// Stop.
+func (x *TaskIndex) Close(context.Context) error {
+	return x.index.Close()
+}
+
+func docID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func (x *TaskIndex) Reset(ctx context.Context, tasks []*domain.Task) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	// Every document not among tasks goes, tombstones included
+	keep := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		keep[docID(task.ID)] = true
+	}
+	count, err := x.index.DocCount()
+	if err != nil {
+		return err
+	}
+	all := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false)
+	existing, err := x.index.SearchInContext(ctx, all)
+	if err != nil {
+		return err
+	}
+
+	batch := x.index.NewBatch()
+	flush := func(force bool) error {
+		if batch.Size() == 0 || !force && batch.Size() < resetBatch {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := x.index.Batch(batch)
+		batch.Reset()
+		return err
+	}
+	for _, hit := range existing.Hits {
+		if !keep[hit.ID] {
+			batch.Delete(hit.ID)
+			if err := flush(false); err != nil {
+				return err
+			}
+		}
+	}
+	for _, task := range tasks {
+		if err := batch.Index(docID(task.ID), documentOf(task)); err != nil {
+			return err
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+	}
+	return flush(true)
+}
+
+func (x *TaskIndex) Apply(ctx context.Context, event domain.TaskEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	task := &event.Task
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	old, known, err := x.stored(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	gone := event.Type == domain.TaskDeleted || event.Type == domain.TaskArchived
+	if old.Deleted || (known && !gone && task.Version <= old.Version) {
+		return nil
+	}
+	doc := documentOf(task)
+	if gone {
+		doc.Title, doc.Description, doc.Deleted = "", "", true
+	}
+	return x.index.Index(docID(task.ID), doc)
+}
+
+// stored returns the version of task id the index has, and whether it is
+// deleted, and false if it has none
+func (x *TaskIndex) stored(ctx context.Context, id int64) (taskDocument, bool, error) {
+	req := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{docID(id)}))
+	req.Fields = []string{"version", "deleted"}
+	result, err := x.index.SearchInContext(ctx, req)
+	if err != nil || len(result.Hits) == 0 {
+		return taskDocument{}, false, err
+	}
+	var doc taskDocument
+	if version, ok := result.Hits[0].Fields["version"].(float64); ok {
+		doc.Version = int64(version)
+	}
+	doc.Deleted, _ = result.Hits[0].Fields["deleted"].(bool)
+	return doc, true, nil
+}
+
+// field returns q searching field
+func field(q query.FieldableQuery, name string) query.Query {
+	q.SetField(name)
+	return q
+}
+
+func (x *TaskIndex) Search(ctx context.Context, q domain.TaskSearchQuery) (domain.TaskSearchResult, error) {
+	title := bleve.NewMatchQuery(q.Text)
+	title.SetBoost(TitleBoost)
+	// Deleted tasks have no text, so no words can match them
+	must := []query.Query{bleve.NewDisjunctionQuery(
+		field(title, "title"),
+		field(bleve.NewMatchQuery(q.Text), "description"),
+	)}
+	if tenant := domain.TenantOf(ctx); tenant != domain.AllTenants {
+		must = append(must, field(bleve.NewTermQuery(string(tenant)), "tenant"))
+	}
+	if q.OwnerID != 0 {
+		owner, inclusive := float64(q.OwnerID), true
+		must = append(must, field(bleve.NewNumericRangeInclusiveQuery(&owner, &owner, &inclusive, &inclusive), "owner_id"))
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(must...), q.Limit, q.Offset, false)
+	// Best first, then oldest first among equals
+	req.SortBy([]string{"-_score", "id"})
+	req.Fields = []string{"title", "owner_id"}
+	req.Highlight = bleve.NewHighlightWithStyle(html.Name)
+	req.Highlight.AddField("title")
+	req.Highlight.AddField("description")
+	result, err := x.index.SearchInContext(ctx, req)
+	if err != nil {
+		return domain.TaskSearchResult{}, err
+	}
+
+	hits := make([]domain.TaskSearchHit, len(result.Hits))
+	for i, match := range result.Hits {
+		id, err := strconv.ParseInt(match.ID, 10, 64)
+		if err != nil {
+			return domain.TaskSearchResult{}, err
+		}
+		hit := domain.TaskSearchHit{TaskID: id, Score: match.Score, Highlights: map[string][]string{}}
+		hit.Title, _ = match.Fields["title"].(string)
+		if owner, ok := match.Fields["owner_id"].(float64); ok {
+			hit.OwnerID = int64(owner)
+		}
+		// A field that did not match has a fragment too, without marks
+		for name, fragments := range match.Fragments {
+			if len(match.Locations[name]) > 0 {
+				hit.Highlights[name] = fragments
+			}
+		}
+		hits[i] = hit
+	}
+	return domain.TaskSearchResult{Total: int(result.Total), Hits: hits}, nil
+}
+
+func (x *TaskIndex) Count(ctx context.Context) (int, error) {
+	live := bleve.NewBooleanQuery()
+	live.AddMust(bleve.NewMatchAllQuery())
+	live.AddMustNot(field(bleve.NewBoolFieldQuery(true), "deleted"))
+	result, err := x.index.SearchInContext(ctx, bleve.NewSearchRequestOptions(live, 0, 0, false))
+	if err != nil {
+		return 0, err
+	}
+	return int(result.Total), nil
+}
@@ -0,0 +1,234 @@
+package fulltext_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/fulltext"
+)
+
+const acme domain.TenantID = "acme"
+
+func task(id, owner int64, title, description string) *domain.Task {
+	return &domain.Task{ID: id, OwnerID: owner, Title: title, Description: description, Version: 1, TenantID: domain.DefaultTenant}
+}
+
+// hitIDs lists the IDs of the hits, in order
+func hitIDs(result domain.TaskSearchResult) []int64 {
+	out := []int64{}
+	for _, hit := range result.Hits {
+		out = append(out, hit.TaskID)
+	}
+	return out
+}
+
+// searchFor searches index for text in ctx's tenant, the first 10 hits
+func searchFor(t *testing.T, ctx context.Context, index *fulltext.TaskIndex, text string, owner int64) domain.TaskSearchResult {
+	t.Helper()
+	result, err := index.Search(ctx, domain.TaskSearchQuery{Text: text, OwnerID: owner, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func apply(t *testing.T, index *fulltext.TaskIndex, typ domain.TaskEventType, task domain.Task) {
+	t.Helper()
+	if err := index.Apply(context.Background(), domain.TaskEvent{Type: typ, Task: task}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// indexes runs test against an index in memory and one on disk
+func indexes(t *testing.T, test func(t *testing.T, index *fulltext.TaskIndex)) {
+	t.Run("memory", func(t *testing.T) {
+		index, err := fulltext.NewMemory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, index)
+	})
+	t.Run("disk", func(t *testing.T) {
+		index, err := fulltext.Open(filepath.Join(t.TempDir(), "index"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer index.Close(context.Background())
+		test(t, index)
+	})
+}
+
+// reset indexes four tasks of the default tenant, three of them owner 1's,
+// and one of acme
+func reset(t *testing.T, index *fulltext.TaskIndex) {
+	t.Helper()
+	other := task(5, 1, "Quarterly report", "for acme")
+	other.TenantID = acme
+	if err := index.Reset(context.Background(), []*domain.Task{
+		task(1, 1, "Buy milk", "and a report on the fridge"),
+		task(2, 1, "Write the quarterly report", "numbers for Q3"),
+		task(3, 2, "Report", "the <script> tag bug"),
+		task(4, 1, "Plan the offsite", "book the venue"),
+		other,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	indexes(t, func(t *testing.T, index *fulltext.TaskIndex) {
+		ctx := context.Background()
+		reset(t, index)
+		if n, err := index.Count(ctx); err != nil || n != 5 {
+			t.Errorf("Count after Reset = %d, %v, want every task of every tenant, 5", n, err)
+		}
+
+		tests := []struct {
+			name  string
+			ctx   context.Context
+			text  string
+			owner int64
+			total int
+			want  []int64 // in order, if not nil
+		}{
+			{"a word in the title above one in the description, a short title above a long one", ctx, "report", 0, 3, []int64{3, 2, 1}},
+			{"a stemmed word", ctx, "reports", 0, 3, nil},
+			{"in any case", ctx, "REPORT", 0, 3, nil},
+			{"one owner's", ctx, "report", 1, 2, []int64{2, 1}},
+			{"in another tenant, its own only", domain.WithTenant(ctx, acme), "report", 0, 1, []int64{5}},
+			{"in every tenant", domain.WithTenant(ctx, domain.AllTenants), "report", 0, 4, nil},
+			{"a stop word", ctx, "the", 0, 0, nil},
+			{"a word no task has", ctx, "astronaut", 0, 0, nil},
+		}
+		for _, tt := range tests {
+			result := searchFor(t, tt.ctx, index, tt.text, tt.owner)
+			if got := hitIDs(result); result.Total != tt.total || tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searching %s, %q = %v of %d, want %v of %d", tt.name, tt.text, got, result.Total, tt.want, tt.total)
+			}
+		}
+
+		result := searchFor(t, ctx, index, "report", 0)
+		if !(result.Hits[0].Score > result.Hits[1].Score && result.Hits[1].Score > result.Hits[2].Score) {
+			t.Errorf("scores %v, want the best first", result.Hits)
+		}
+		if got := hitIDs(searchFor(t, ctx, index, "quarterly report", 0)); len(got) == 0 || got[0] != 2 {
+			t.Errorf("searching for two words = %v, want the task with both first", got)
+		}
+		result = searchFor(t, ctx, index, "venue", 0)
+		if len(result.Hits) != 1 || result.Hits[0].TaskID != 4 || result.Hits[0].Title != "Plan the offsite" || result.Hits[0].OwnerID != 1 {
+			t.Errorf("a hit = %+v, want the task's ID, title and owner", result.Hits)
+		}
+
+		page, err := index.Search(ctx, domain.TaskSearchQuery{Text: "report", Limit: 2, Offset: 1})
+		if got := hitIDs(page); err != nil || page.Total != 3 || !reflect.DeepEqual(got, []int64{2, 1}) {
+			t.Errorf("the second page of two = %v of %d, %v, want [2 1] of all 3", got, page.Total, err)
+		}
+	})
+}
+
+func TestHighlights(t *testing.T) {
+	indexes(t, func(t *testing.T, index *fulltext.TaskIndex) {
+		ctx := context.Background()
+		reset(t, index)
+		tests := []struct {
+			text string
+			want map[string][]string
+		}{
+			// Only the fields that matched, with the rest escaped
+			{"tag", map[string][]string{"description": {"the &lt;script&gt; <mark>tag</mark> bug"}}},
+			{"venue", map[string][]string{"description": {"book the <mark>venue</mark>"}}},
+		}
+		for _, tt := range tests {
+			result := searchFor(t, ctx, index, tt.text, 0)
+			if len(result.Hits) != 1 || !reflect.DeepEqual(result.Hits[0].Highlights, tt.want) {
+				t.Errorf("the highlights of %q = %+v, want %q", tt.text, result.Hits, tt.want)
+			}
+		}
+		result := searchFor(t, ctx, index, "report", 0)
+		if got := result.Hits[0].Highlights["title"]; !reflect.DeepEqual(got, []string{"<mark>Report</mark>"}) {
+			t.Errorf("the title's highlights = %q, want it marked in its own field", got)
+		}
+	})
+}
+
+// TestApply applies events in any order: a late older version changes
+// nothing, and a deleted task stays gone
+func TestApply(t *testing.T) {
+	indexes(t, func(t *testing.T, index *fulltext.TaskIndex) {
+		ctx := context.Background()
+		reset(t, index)
+		renamed := *task(4, 1, "Plan the offsite retreat", "")
+		renamed.Version = 2
+		late := renamed
+		late.Version = 3
+		steps := []struct {
+			name  string
+			typ   domain.TaskEventType
+			task  domain.Task
+			text  string
+			found int
+		}{
+			{"an update replaces the task's words", domain.TaskUpdated, renamed, "retreat", 1},
+			{"the words it had are gone", domain.TaskUpdated, renamed, "venue", 0},
+			{"an older version, applied late, is ignored", domain.TaskUpdated, *task(4, 1, "Plan the offsite", "book the venue"), "venue", 0},
+			{"a created task is found", domain.TaskCreated, *task(6, 1, "Renew the passport", ""), "passport", 1},
+			{"once if applied twice", domain.TaskCreated, *task(6, 1, "Renew the passport", ""), "passport", 1},
+			{"a deleted task is not", domain.TaskDeleted, renamed, "retreat", 0},
+			{"and stays gone, even for a later version", domain.TaskUpdated, late, "retreat", 0},
+			{"an archived task is gone too", domain.TaskArchived, *task(1, 1, "Buy milk", ""), "milk", 0},
+		}
+		for _, step := range steps {
+			apply(t, index, step.typ, step.task)
+			if got := searchFor(t, ctx, index, step.text, 0).Total; got != step.found {
+				t.Errorf("%s: %q finds %d, want %d", step.name, step.text, got, step.found)
+			}
+		}
+		if n, err := index.Count(ctx); err != nil || n != 4 {
+			t.Errorf("Count = %d, %v, want the deleted and archived tasks left out, 4", n, err)
+		}
+	})
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "index")
+	index, err := fulltext.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Reset(ctx, []*domain.Task{task(1, 1, "Water the plants", ""), task(2, 1, "Pay rent", "")}); err != nil {
+		t.Fatal(err)
+	}
+	apply(t, index, domain.TaskDeleted, *task(2, 1, "Pay rent", ""))
+	if again, err := fulltext.Open(path); err == nil {
+		again.Close(ctx)
+		t.Error("an index open elsewhere was opened again")
+	}
+	if err := index.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err = fulltext.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close(ctx)
+	if got := hitIDs(searchFor(t, ctx, index, "plants", 0)); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("the reopened index finds %v, want the task it had", got)
+	}
+	late := *task(2, 1, "Pay rent", "")
+	late.Version = 2
+	apply(t, index, domain.TaskUpdated, late)
+	if got := searchFor(t, ctx, index, "rent", 0).Total; got != 0 {
+		t.Errorf("a later version of a task deleted before the reopen is found %d times, want it still gone", got)
+	}
+	if err := index.Reset(ctx, []*domain.Task{task(3, 1, "Call the plumber", "")}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := index.Count(ctx); n != 1 || searchFor(t, ctx, index, "plants", 0).Total != 0 {
+		t.Errorf("after Reset, Count = %d, want everything it was not given dropped", n)
+	}
+}
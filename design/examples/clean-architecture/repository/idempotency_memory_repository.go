@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// MemoryIdempotencyRepository is a domain.IdempotencyStore kept in memory,
+// for one server without a database. Its keys are gone on restart.
+type MemoryIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[domain.IdempotencyKey]domain.IdempotencyRecord
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository {
+	return &MemoryIdempotencyRepository{records: map[domain.IdempotencyKey]domain.IdempotencyRecord{}}
+}
+
+func (r *MemoryIdempotencyRepository) Begin(ctx context.Context, key domain.IdempotencyKey, fingerprint string, now, expiresAt time.Time) (*domain.IdempotencyRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, record := range r.records {
+		if !record.ExpiresAt.After(now) {
+			delete(r.records, k)
+		}
+	}
+	if record, ok := r.records[key]; ok {
+		if record.Response != nil {
+			response := *record.Response
+			response.Body = slices.Clone(response.Body)
+			record.Response = &response
+		}
+		return &record, nil
+	}
+	r.records[key] = domain.IdempotencyRecord{Fingerprint: fingerprint, ExpiresAt: expiresAt}
+	return nil, nil
+}
+
+func (r *MemoryIdempotencyRepository) Finish(ctx context.Context, key domain.IdempotencyKey, response domain.StoredResponse, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[key]
+	if !ok {
+		return nil
+	}
+	response.Body = slices.Clone(response.Body)
+	record.Response = &response
+	record.ExpiresAt = expiresAt
+	r.records[key] = record
+	return nil
+}
+
+func (r *MemoryIdempotencyRepository) Abandon(ctx context.Context, key domain.IdempotencyKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if record, ok := r.records[key]; ok && record.Response == nil {
+		delete(r.records, key)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// IdempotencyRepositoryImpl is the domain.IdempotencyStore of the SQL
+// database: the idempotency_keys table, shared by every server instance on
+// the database. A key is claimed by inserting its row, so of two instances
+// claiming it at once the primary key lets one win.
+type IdempotencyRepositoryImpl struct {
+	db      *sqlx.DB
+	dialect dialect
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) *IdempotencyRepositoryImpl {
+	return &IdempotencyRepositoryImpl{db: db, dialect: dialectOf(db)}
+}
+
+type idempotencyRecord struct {
+	Fingerprint string         `db:"fingerprint"`
+	Status      sql.NullInt64  `db:"status"` // NULL while in progress
+	ContentType sql.NullString `db:"content_type"`
+	Body        []byte         `db:"body"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+}
+
+func (r idempotencyRecord) toDomain() *domain.IdempotencyRecord {
+	record := &domain.IdempotencyRecord{Fingerprint: r.Fingerprint, ExpiresAt: r.ExpiresAt}
+	if r.Status.Valid {
+		record.Response = &domain.StoredResponse{
+			Status:      int(r.Status.Int64),
+			ContentType: r.ContentType.String,
+			Body:        r.Body,
+		}
+	}
+	return record
+}
+
+func (r *IdempotencyRepositoryImpl) Begin(ctx context.Context, key domain.IdempotencyKey, fingerprint string, now, expiresAt time.Time) (*domain.IdempotencyRecord, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`
+		DELETE FROM idempotency_keys WHERE `+r.dialect.instant("expires_at")+` <= `+r.dialect.instant("?")), now); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, tx.Rebind(`
+		INSERT INTO idempotency_keys (owner_id, idempotency_key, fingerprint, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (owner_id, idempotency_key) DO NOTHING
+	`), key.OwnerID, key.Key, fingerprint, now, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 1 {
+		if err == nil {
+			err = tx.Commit()
+		}
+		return nil, err
+	}
+
+	var record idempotencyRecord
+	if err := tx.GetContext(ctx, &record, tx.Rebind(`
+		SELECT fingerprint, status, content_type, body, expires_at
+		FROM idempotency_keys
+		WHERE owner_id = ? AND idempotency_key = ?
+	`), key.OwnerID, key.Key); err != nil {
+		return nil, err
+	}
+	return record.toDomain(), tx.Commit()
+}
+
+func (r *IdempotencyRepositoryImpl) Finish(ctx context.Context, key domain.IdempotencyKey, response domain.StoredResponse, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(`
+		UPDATE idempotency_keys SET status = ?, content_type = ?, body = ?, expires_at = ?
+		WHERE owner_id = ? AND idempotency_key = ?
+	`), response.Status, response.ContentType, response.Body, expiresAt, key.OwnerID, key.Key)
+	return err
+}
+
+func (r *IdempotencyRepositoryImpl) Abandon(ctx context.Context, key domain.IdempotencyKey) error {
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(`
+		DELETE FROM idempotency_keys WHERE owner_id = ? AND idempotency_key = ? AND status IS NULL
+	`), key.OwnerID, key.Key)
+	return err
+}
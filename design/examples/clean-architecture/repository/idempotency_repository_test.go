@@ -0,0 +1,68 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+// TestConcurrentClaimsOfOneKey has many requests claim one key at once:
+// exactly one may win
+func TestConcurrentClaimsOfOneKey(t *testing.T) {
+	stores := map[string]func(t *testing.T) domain.IdempotencyStore{
+		"memory": func(*testing.T) domain.IdempotencyStore { return repository.NewMemoryIdempotencyRepository() },
+		"sqlite": func(t *testing.T) domain.IdempotencyStore {
+			// Concurrent claims wait for SQLite's one writer rather than fail
+			db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db") + "?_busy_timeout=10000")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return repository.NewIdempotencyRepository(db)
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			const claimers = 16
+			store := open(t)
+			ctx := context.Background()
+			now := time.Now()
+			key := domain.IdempotencyKey{OwnerID: 1, Key: "race"}
+			var (
+				wg     sync.WaitGroup
+				mu     sync.Mutex
+				won    int
+				failed error
+			)
+			start := make(chan struct{})
+			for i := 0; i < claimers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					record, err := store.Begin(ctx, key, "same request", now, now.Add(time.Minute))
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err != nil:
+						failed = errors.Join(failed, err)
+					case record == nil:
+						won++
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+			if failed != nil || won != 1 {
+				t.Errorf("of %d claims of one key at once, %d won (errors: %v), want 1", claimers, won, failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Package mock has a hand-written mock of domain.TaskRepository, for checking
+// the use cases on their own: every call is recorded, and every method
+// answers with whatever its function field returns.
+package mock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Call is one call made to the mock. Task and Tasks are copies of the
+// arguments as they were at the time of the call, so the caller changing
+// them afterwards does not change what was recorded.
+type Call struct {
+	Method string
+	ID     int64                 // GetByID and Delete
+	IDs    []int64               // GetByIDs
+	Task   *domain.Task          // Create and Update
+	Tasks  []*domain.Task        // CreateBatch
+	Query  domain.ListTasksQuery // List
+}
+
+// TaskRepository is a domain.TaskRepository whose methods call the function
+// field of the same name. A call to a method whose field is nil fails with
+// an error naming it, so a check states every call it expects.
+type TaskRepository struct {
+	CreateFunc      func(ctx context.Context, task *domain.Task) error
+	CreateBatchFunc func(ctx context.Context, tasks []*domain.Task) error
+	GetByIDFunc     func(ctx context.Context, id int64) (*domain.Task, error)
+	GetByIDsFunc    func(ctx context.Context, ids []int64) ([]*domain.Task, error)
+	ListFunc        func(ctx context.Context, query domain.ListTasksQuery) ([]*domain.Task, error)
+	UpdateFunc      func(ctx context.Context, task *domain.Task) error
+	DeleteFunc      func(ctx context.Context, id int64) error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ domain.TaskRepository = (*TaskRepository)(nil)
+
+// Calls returns the calls made so far, in order
+func (r *TaskRepository) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+func (r *TaskRepository) record(call Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func unexpected(method string) error {
+	return fmt.Errorf("mock: unexpected call to TaskRepository.%s", method)
+}
+
+func clone(task *domain.Task) *domain.Task {
+	copied := *task
+	copied.Tags = slices.Clone(task.Tags)
+	return &copied
+}
+
+func (r *TaskRepository) Create(ctx context.Context, task *domain.Task) error {
+	r.record(Call{Method: "Create", Task: clone(task)})
+	if r.CreateFunc == nil {
+		return unexpected("Create")
+	}
+	return r.CreateFunc(ctx, task)
+}
+
+func (r *TaskRepository) CreateBatch(ctx context.Context, tasks []*domain.Task) error {
+	copies := make([]*domain.Task, len(tasks))
+	for i, task := range tasks {
+		copies[i] = clone(task)
+	}
+	r.record(Call{Method: "CreateBatch", Tasks: copies})
+	if r.CreateBatchFunc == nil {
+		return unexpected("CreateBatch")
+	}
+	return r.CreateBatchFunc(ctx, tasks)
+}
+
+func (r *TaskRepository) GetByID(ctx context.Context, id int64) (*domain.Task, error) {
+	r.record(Call{Method: "GetByID", ID: id})
+	if r.GetByIDFunc == nil {
+		return nil, unexpected("GetByID")
+	}
+	return r.GetByIDFunc(ctx, id)
+}
+
+func (r *TaskRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Task, error) {
+	r.record(Call{Method: "GetByIDs", IDs: slices.Clone(ids)})
+	if r.GetByIDsFunc == nil {
+		return nil, unexpected("GetByIDs")
+	}
+	return r.GetByIDsFunc(ctx, ids)
+}
+
+func (r *TaskRepository) List(ctx context.Context, query domain.ListTasksQuery) ([]*domain.Task, error) {
+	r.record(Call{Method: "List", Query: query})
+	if r.ListFunc == nil {
+		return nil, unexpected("List")
+	}
+	return r.ListFunc(ctx, query)
+}
+
+func (r *TaskRepository) Update(ctx context.Context, task *domain.Task) error {
+	r.record(Call{Method: "Update", Task: clone(task)})
+	if r.UpdateFunc == nil {
+		return unexpected("Update")
+	}
+	return r.UpdateFunc(ctx, task)
+}
+
+func (r *TaskRepository) Delete(ctx context.Context, id int64) error {
+	r.record(Call{Method: "Delete", ID: id})
+	if r.DeleteFunc == nil {
+		return unexpected("Delete")
+	}
+	return r.DeleteFunc(ctx, id)
+}
+
+// Stored returns a GetByIDFunc finding tasks by ID, each time as a fresh
+// copy, as a real repository would. Any other ID is not found: sql.ErrNoRows.
+func Stored(tasks ...*domain.Task) func(ctx context.Context, id int64) (*domain.Task, error) {
+	return func(ctx context.Context, id int64) (*domain.Task, error) {
+		for _, task := range tasks {
+			if task.ID == id {
+				return clone(task), nil
+			}
+		}
+		return nil, fmt.Errorf("mock: no task %d: %w", id, sql.ErrNoRows)
+	}
+}
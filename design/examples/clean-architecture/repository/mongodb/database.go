@@ -0,0 +1,36 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// DefaultDatabase is used when the URI does not name a database
+const DefaultDatabase = "tasks"
+
+// Connect connects to the server at uri, such as mongodb://localhost:27017/tasks,
+// and returns the database the URI names. The driver connects lazily, so it
+// pings the server to fail here rather than on the first request.
+func Connect(ctx context.Context, uri string) (*mongo.Database, error) {
+	cs, err := connstring.ParseAndValidate(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(ctx)
+		return nil, err
+	}
+
+	name := cs.Database
+	if name == "" {
+		name = DefaultDatabase
+	}
+	return client.Database(name), nil
+}
@@ -0,0 +1,350 @@
+// Package mongodb stores tasks in MongoDB. TaskRepository implements the same
+// domain.TaskRepository as the SQL and in-memory repositories, so the use
+// cases and handlers run on it unchanged. It is a package of its own so that
+// only the binaries that use it link the MongoDB driver.
+//
+// It has no outbox: storing a task's events with it would take the same
+// multi-document transaction CreateBatch does without. Recorded events are
+// dropped, so a server on MongoDB runs no event dispatcher.
+package mongodb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The domain and the API identify a task by an int64, and MongoDB identifies
+// a document by a 12-byte ObjectID, which does not fit in one. So each
+// document keeps an ObjectID as _id, the key MongoDB and its tools expect,
+// and carries the domain ID in an id field under a unique index. IDs come
+// from a counter document that $inc bumps atomically, so they are sequential
+// across every server sharing the database, as AUTOINCREMENT is in SQL. A
+// batch reserves its whole range with one increment.
+
+const (
+	tasksCollection    = "tasks"
+	countersCollection = "counters"
+)
+
+// Indexes the repository creates and the queries they serve
+var taskIndexes = []mongo.IndexModel{
+	// GetByID, Update and Delete; unique, so no two tasks can share an ID
+	{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetName("id").SetUnique(true)},
+	// A user's tasks, newest first: the listing every request makes
+	{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "id", Value: -1}}, Options: options.Index().SetName("owner_created")},
+	// Tag filters; a multikey index over the tags array
+	{Keys: bson.D{{Key: "tags", Value: 1}}, Options: options.Index().SetName("tags")},
+	// The tasks assigned to a user, newest first
+	{Keys: bson.D{{Key: "assignee_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "id", Value: -1}}, Options: options.Index().SetName("assignee_created")},
+	// The subtasks of a task
+	{Keys: bson.D{{Key: "parent_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "id", Value: -1}}, Options: options.Index().SetName("parent_created")},
+	// A tenant's tasks, newest first: an admin's listing
+	{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "id", Value: -1}}, Options: options.Index().SetName("tenant_created")},
+}
+
+// taskDocument is a task as stored. MongoDB keeps times in UTC to the
+// millisecond, so that is what comes back.
+type taskDocument struct {
+	ObjectID    primitive.ObjectID `bson:"_id,omitempty"`
+	ID          int64              `bson:"id"`
+	OwnerID     int64              `bson:"owner_id"`
+	Title       string             `bson:"title"`
+	Description string             `bson:"description"`
+	Completed   bool               `bson:"completed"`
+	Priority    string             `bson:"priority"`
+	Tags        []string           `bson:"tags"`
+	Version     int64              `bson:"version"`
+	AssigneeID  int64              `bson:"assignee_id"` // absent, so 0, on documents older than assignment
+	ParentID    int64              `bson:"parent_id"`   // absent, so 0, on documents older than subtasks
+	TenantID    string             `bson:"tenant_id"`   // absent, so the default tenant, on documents older than tenants
+	DueAt       time.Time          `bson:"due_at,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+func toDocument(task *domain.Task) taskDocument {
+	return taskDocument{
+		ID:          task.ID,
+		OwnerID:     task.OwnerID,
+		Title:       task.Title,
+		Description: task.Description,
+		Completed:   task.Completed,
+		Priority:    string(task.Priority),
+		Tags:        task.Tags,
+		Version:     task.Version,
+		AssigneeID:  task.AssigneeID,
+		ParentID:    task.ParentID,
+		TenantID:    string(task.TenantID),
+		DueAt:       task.DueAt,
+		CreatedAt:   task.CreatedAt,
+		UpdatedAt:   task.UpdatedAt,
+	}
+}
+
+func (d taskDocument) toDomain() *domain.Task {
+	task := &domain.Task{
+		ID:          d.ID,
+		OwnerID:     d.OwnerID,
+		Title:       d.Title,
+		Description: d.Description,
+		Completed:   d.Completed,
+		Priority:    domain.Priority(d.Priority),
+		Version:     d.Version,
+		AssigneeID:  d.AssigneeID,
+		ParentID:    d.ParentID,
+		TenantID:    domain.TenantID(d.TenantID).OrDefault(),
+		DueAt:       d.DueAt,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+	if len(d.Tags) > 0 {
+		task.Tags = d.Tags
+	}
+	return task
+}
+
+type TaskRepository struct {
+	tasks    *mongo.Collection
+	counters *mongo.Collection
+}
+
+// NewTaskRepository returns a repository on db, creating its indexes first.
+// Creating an index that already exists does nothing, so every server can
+// call it at startup.
+func NewTaskRepository(ctx context.Context, db *mongo.Database) (*TaskRepository, error) {
+	r := &TaskRepository{
+		tasks:    db.Collection(tasksCollection),
+		counters: db.Collection(countersCollection),
+	}
+	if _, err := r.tasks.Indexes().CreateMany(ctx, taskIndexes); err != nil {
+		return nil, fmt.Errorf("create task indexes: %w", err)
+	}
+	return r, nil
+}
+
+// reserveIDs takes n IDs from the counter and returns the first
+func (r *TaskRepository) reserveIDs(ctx context.Context, n int) (int64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.counters.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: tasksCollection}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: int64(n)}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq - int64(n) + 1, nil
+}
+
+// inTenant adds to filter the condition that limits it to the tenant of ctx,
+// as the SQL repository's WHERE clauses are. Documents stored before tenants
+// have no tenant_id and belong to the default tenant.
+func inTenant(ctx context.Context, filter bson.D) bson.D {
+	switch tenant := domain.TenantOf(ctx); tenant {
+	case domain.AllTenants:
+		return filter
+	case domain.DefaultTenant:
+		return append(filter, bson.E{Key: "tenant_id", Value: bson.D{{Key: "$in", Value: bson.A{string(tenant), nil}}}})
+	default:
+		return append(filter, bson.E{Key: "tenant_id", Value: string(tenant)})
+	}
+}
+
+func (r *TaskRepository) Create(ctx context.Context, task *domain.Task) error {
+	return r.CreateBatch(ctx, []*domain.Task{task})
+}
+
+// CreateBatch inserts the tasks with one InsertMany. MongoDB only makes
+// writes to several documents atomic inside a transaction, which needs a
+// replica set, so instead a batch that fails part-way deletes what it
+// inserted. Until it has, readers can see part of it. Its reserved IDs are
+// never reused, as with a rolled-back AUTOINCREMENT.
+func (r *TaskRepository) CreateBatch(ctx context.Context, tasks []*domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	first, err := r.reserveIDs(ctx, len(tasks))
+	if err != nil {
+		return err
+	}
+
+	// The ObjectIDs are made here, so a failed batch knows which documents
+	// are its own
+	docs := make([]interface{}, len(tasks))
+	objectIDs := make(bson.A, len(tasks))
+	tenant := domain.TenantOf(ctx)
+	for i, task := range tasks {
+		if tenant != domain.AllTenants {
+			task.TenantID = tenant
+		}
+		task.TenantID = task.TenantID.OrDefault()
+		doc := toDocument(task)
+		doc.ObjectID, doc.ID = primitive.NewObjectID(), first+int64(i)
+		docs[i], objectIDs[i] = doc, doc.ObjectID
+	}
+	if _, err := r.tasks.InsertMany(ctx, docs); err != nil {
+		batch := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: objectIDs}}}}
+		// Clean up even when ctx is what ended the insert
+		if _, cleanupErr := r.tasks.DeleteMany(context.WithoutCancel(ctx), batch); cleanupErr != nil {
+			return errors.Join(err, fmt.Errorf("remove partial batch: %w", cleanupErr))
+		}
+		return err
+	}
+
+	for i, task := range tasks {
+		task.ID = first + int64(i)
+	}
+	return nil
+}
+
+func (r *TaskRepository) GetByID(ctx context.Context, id int64) (*domain.Task, error) {
+	var doc taskDocument
+	err := r.tasks.FindOne(ctx, inTenant(ctx, bson.D{{Key: "id", Value: id}})).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Not found as the other repositories report it, for the use cases
+		return nil, fmt.Errorf("%w: %w", sql.ErrNoRows, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toDomain(), nil
+}
+
+func (r *TaskRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Task, error) {
+	filter := inTenant(ctx, bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	cursor, err := r.tasks.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []taskDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	tasks := make([]*domain.Task, len(docs))
+	for i, doc := range docs {
+		tasks[i] = doc.toDomain()
+	}
+	return tasks, nil
+}
+
+// List returns the tasks matching query, newest first, with the semantics of
+// ListTasksQuery.Matches
+func (r *TaskRepository) List(ctx context.Context, q domain.ListTasksQuery) ([]*domain.Task, error) {
+	filter := inTenant(ctx, bson.D{})
+	if q.OwnerID != 0 {
+		filter = append(filter, bson.E{Key: "owner_id", Value: q.OwnerID})
+	}
+	if q.AssigneeID != 0 {
+		filter = append(filter, bson.E{Key: "assignee_id", Value: q.AssigneeID})
+	}
+	if len(q.ParentIDs) > 0 {
+		filter = append(filter, bson.E{Key: "parent_id", Value: bson.D{{Key: "$in", Value: q.ParentIDs}}})
+	}
+	if q.Completed != nil {
+		filter = append(filter, bson.E{Key: "completed", Value: *q.Completed})
+	}
+	created := bson.D{}
+	if !q.CreatedFrom.IsZero() {
+		created = append(created, bson.E{Key: "$gte", Value: q.CreatedFrom})
+	}
+	if !q.CreatedBefore.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: q.CreatedBefore})
+	}
+	if len(created) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: created})
+	}
+	if len(q.Tags) > 0 {
+		filter = append(filter, bson.E{Key: "tags", Value: bson.D{{Key: "$all", Value: q.Tags}}})
+	}
+	if q.Search != "" {
+		pattern := primitive.Regex{Pattern: searchPattern(q.Search)}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "title", Value: pattern}},
+			bson.D{{Key: "description", Value: pattern}},
+		}})
+	}
+
+	sort := bson.D{{Key: "created_at", Value: -1}, {Key: "id", Value: -1}}
+	opts := options.Find().SetSort(sort).SetSkip(int64(q.Offset))
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	cursor, err := r.tasks.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []taskDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	tasks := make([]*domain.Task, len(docs))
+	for i, doc := range docs {
+		tasks[i] = doc.toDomain()
+	}
+	return tasks, nil
+}
+
+// searchPattern is a regular expression that finds s, ignoring the case of
+// ASCII letters only. The i option would fold every letter, which
+// ListTasksQuery.Matches does not.
+func searchPattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z':
+			fmt.Fprintf(&b, "[%c%c]", c, c-'a'+'A')
+		case 'A' <= c && c <= 'Z':
+			fmt.Fprintf(&b, "[%c%c]", c-'A'+'a', c)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Update stores the task's changes. The version is part of the filter, and
+// a single-document update is atomic, so of two updates from the same
+// version only the first matches.
+func (r *TaskRepository) Update(ctx context.Context, task *domain.Task) error {
+	doc := toDocument(task)
+	filter := inTenant(ctx, bson.D{{Key: "id", Value: task.ID}, {Key: "version", Value: task.Version}})
+	result, err := r.tasks.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "title", Value: doc.Title},
+			{Key: "description", Value: doc.Description},
+			{Key: "completed", Value: doc.Completed},
+			{Key: "priority", Value: doc.Priority},
+			{Key: "tags", Value: doc.Tags},
+			{Key: "assignee_id", Value: doc.AssigneeID},
+			{Key: "due_at", Value: doc.DueAt},
+			{Key: "updated_at", Value: doc.UpdatedAt},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: int64(1)}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrVersionConflict
+	}
+	task.Version++
+	return nil
+}
+
+func (r *TaskRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.tasks.DeleteOne(ctx, inTenant(ctx, bson.D{{Key: "id", Value: id}}))
+	return err
+}
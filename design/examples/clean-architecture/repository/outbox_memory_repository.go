@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// MemoryOutboxRepository is the domain.Outbox of a MemoryTaskRepository,
+// which adds to it while holding its own lock, so an event is stored with
+// its task. It is gone on restart, like the tasks.
+type MemoryOutboxRepository struct {
+	mu     sync.Mutex
+	events []outboxEntry // in ID order
+	nextID int64
+}
+
+type outboxEntry struct {
+	domain.OutboxEvent
+	next time.Time // zero once delivered or given up on
+}
+
+func NewMemoryOutboxRepository() *MemoryOutboxRepository {
+	return &MemoryOutboxRepository{}
+}
+
+// add stores events of the task taskID
+func (r *MemoryOutboxRepository) add(taskID int64, events []domain.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range events {
+		r.nextID++
+		event.ID = r.nextID
+		event.TaskID = taskID
+		r.events = append(r.events, outboxEntry{OutboxEvent: domain.OutboxEvent{Event: event}, next: event.OccurredAt})
+	}
+}
+
+func (r *MemoryOutboxRepository) Due(ctx context.Context, now time.Time, limit int) ([]domain.OutboxEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []domain.OutboxEvent
+	for _, entry := range r.events {
+		if len(due) == limit {
+			break
+		}
+		if !entry.next.IsZero() && !entry.next.After(now) {
+			due = append(due, entry.OutboxEvent)
+		}
+	}
+	return due, nil
+}
+
+func (r *MemoryOutboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	return r.mark(ctx, id, func(entry *outboxEntry) {
+		entry.next = time.Time{}
+	})
+}
+
+func (r *MemoryOutboxRepository) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	return r.mark(ctx, id, func(entry *outboxEntry) {
+		entry.Attempts++
+		entry.LastError = reason
+		entry.next = retryAt
+	})
+}
+
+// mark changes the event id; marking one that is not stored does nothing,
+// as an UPDATE matching no row does
+func (r *MemoryOutboxRepository) mark(ctx context.Context, id int64, change func(*outboxEntry)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.events {
+		if r.events[i].ID == id {
+			change(&r.events[i])
+			break
+		}
+	}
+	return nil
+}
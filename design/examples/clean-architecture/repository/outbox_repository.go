@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// saveEvents adds a task's pending events to the outbox, in the transaction
+// that stores the task, so they commit or roll back with it
+func saveEvents(ctx context.Context, tx *sqlx.Tx, taskID int64, events []domain.Event) error {
+	for _, event := range events {
+		if _, err := tx.ExecContext(ctx, tx.Rebind(`
+			INSERT INTO outbox (name, task_id, owner_id, title, occurred_at, next_attempt_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`), string(event.Name), taskID, event.OwnerID, event.Title, event.OccurredAt, event.OccurredAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OutboxRepositoryImpl is the domain.Outbox of the SQL task repository: the
+// outbox table TaskRepositoryImpl writes events to
+type OutboxRepositoryImpl struct {
+	db      *sqlx.DB
+	dialect dialect
+}
+
+func NewOutboxRepository(db *sqlx.DB) *OutboxRepositoryImpl {
+	return &OutboxRepositoryImpl{db: db, dialect: dialectOf(db)}
+}
+
+type outboxRecord struct {
+	ID         int64          `db:"id"`
+	Name       string         `db:"name"`
+	TaskID     int64          `db:"task_id"`
+	OwnerID    int64          `db:"owner_id"`
+	Title      string         `db:"title"`
+	OccurredAt time.Time      `db:"occurred_at"`
+	Attempts   int            `db:"attempts"`
+	LastError  sql.NullString `db:"last_error"`
+}
+
+func (r outboxRecord) toDomain() domain.OutboxEvent {
+	return domain.OutboxEvent{
+		Event: domain.Event{
+			ID:         r.ID,
+			Name:       domain.EventName(r.Name),
+			TaskID:     r.TaskID,
+			OwnerID:    r.OwnerID,
+			Title:      r.Title,
+			OccurredAt: r.OccurredAt,
+		},
+		Attempts:  r.Attempts,
+		LastError: r.LastError.String,
+	}
+}
+
+func (r *OutboxRepositoryImpl) Due(ctx context.Context, now time.Time, limit int) ([]domain.OutboxEvent, error) {
+	var records []outboxRecord
+	query := `
+		SELECT id, name, task_id, owner_id, title, occurred_at, attempts, last_error
+		FROM outbox
+		WHERE next_attempt_at IS NOT NULL AND ` + r.dialect.instant("next_attempt_at") + ` <= ` + r.dialect.instant("?") + `
+		ORDER BY id
+		LIMIT ?
+	`
+	if err := r.db.SelectContext(ctx, &records, r.db.Rebind(query), now, limit); err != nil {
+		return nil, err
+	}
+	events := make([]domain.OutboxEvent, len(records))
+	for i, record := range records {
+		events[i] = record.toDomain()
+	}
+	return events, nil
+}
+
+func (r *OutboxRepositoryImpl) MarkDelivered(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(`
+		UPDATE outbox SET next_attempt_at = NULL, delivered_at = ? WHERE id = ?
+	`), time.Now(), id)
+	return err
+}
+
+func (r *OutboxRepositoryImpl) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	next := sql.NullTime{Time: retryAt, Valid: !retryAt.IsZero()}
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(`
+		UPDATE outbox SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?
+	`), reason, next, id)
+	return err
+}
@@ -0,0 +1,192 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+// expectDescription reads task id through r, expecting want as its
+// description
+func expectDescription(r domain.TaskRepository, id int64, want string) error {
+	got, err := r.GetByID(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if got.Description != want {
+		return fmt.Errorf("task %d reads description %q, want %q", id, got.Description, want)
+	}
+	return nil
+}
+
+// roundTrip creates, reads, updates and lists a task through r
+func roundTrip(r domain.TaskRepository) error {
+	ctx := context.Background()
+	task, _ := domain.NewTask(1, "round trip", "first", time.Now())
+	if err := r.Create(ctx, task); err != nil {
+		return err
+	}
+	if err := expectDescription(r, task.ID, "first"); err != nil {
+		return err
+	}
+	task.Update(task.Title, "second", true, time.Now())
+	if err := r.Update(ctx, task); err != nil {
+		return err
+	}
+	if err := expectDescription(r, task.ID, "second"); err != nil {
+		return err
+	}
+	_, err := r.List(ctx, domain.ListTasksQuery{})
+	return err
+}
+
+// handOff writes with one instance and reads with another, as happens while
+// old and new code run side by side during a deploy
+func handOff(writer, reader domain.TaskRepository) error {
+	ctx := context.Background()
+	task, _ := domain.NewTask(1, "hand-off", "written", time.Now())
+	if err := writer.Create(ctx, task); err != nil {
+		return err
+	}
+	if err := expectDescription(reader, task.ID, "written"); err != nil {
+		return err
+	}
+	task.Update(task.Title, "rewritten", false, time.Now())
+	if err := writer.Update(ctx, task); err != nil {
+		return err
+	}
+	return expectDescription(reader, task.ID, "rewritten")
+}
+
+// staleEdit edits a backfilled task with code that only writes description:
+// code reading details never sees the edit
+func staleEdit(creator, editor, reader domain.TaskRepository) error {
+	ctx := context.Background()
+	task, _ := domain.NewTask(1, "edited", "before", time.Now())
+	if err := creator.Create(ctx, task); err != nil {
+		return err
+	}
+	task.Update(task.Title, "after", false, time.Now())
+	if err := editor.Update(ctx, task); err != nil {
+		return err
+	}
+	return expectDescription(reader, task.ID, "after")
+}
+
+// TestRenamePhases takes one SQLite database through the description ->
+// details rename. In every phase it runs the repository in each column mode
+// that could be deployed then, and checks which combinations work,
+// including the ones that must not: new code before expand, old code left
+// running after the backfill, and any code still using description after
+// contract.
+func TestRenamePhases(t *testing.T) {
+	ctx := context.Background()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	old := repository.NewTaskRepositoryWithColumns(db, repository.DescriptionOnly)
+	dual := repository.NewTaskRepositoryWithColumns(db, repository.DualWriteReadDescription)
+	dualNew := repository.NewTaskRepositoryWithColumns(db, repository.DualWriteReadDetails)
+	details := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+
+	type step struct {
+		name   string
+		wantOK bool
+		err    func() error
+	}
+	// run runs the steps of a phase in order
+	run := func(phase string, steps []step) {
+		t.Helper()
+		for _, s := range steps {
+			switch err := s.err(); {
+			case s.wantOK && err != nil:
+				t.Errorf("%s, %s: %v", phase, s.name, err)
+			case !s.wantOK && err == nil:
+				t.Errorf("%s, %s works, want it to fail", phase, s.name)
+			}
+		}
+	}
+
+	run("before expand", []step{
+		{"old code round trip", true, func() error { return roundTrip(old) }},
+		{"dual-write code", false, func() error { return roundTrip(dual) }},
+	})
+	// Existing data the backfill will have to copy
+	const existing = 300
+	for i := range existing {
+		task, _ := domain.NewTask(1, fmt.Sprintf("task %d", i), fmt.Sprintf("description %d", i), time.Now())
+		if err := old.Create(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := infrastructure.Migrate(db, infrastructure.SchemaExpanded); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	run("after expand", []step{
+		{"old code round trip, on instances not yet redeployed", true, func() error { return roundTrip(old) }},
+		{"dual-write round trip", true, func() error { return roundTrip(dual) }},
+		{"old writes, dual-write reads", true, func() error { return handOff(old, dual) }},
+		{"dual-write writes, old reads", true, func() error { return handOff(dual, old) }},
+	})
+
+	killed, cancel := context.WithCancel(ctx)
+	batches := 0
+	p, err := infrastructure.BackfillDetails(killed, db, 50, func(infrastructure.BackfillProgress) {
+		if batches++; batches == 3 {
+			cancel() // the job is killed mid-way
+		}
+	})
+	if err == nil || p.Done {
+		t.Errorf("the backfill killed mid-way = %s, %v, want it interrupted", p, err)
+	}
+	if p, err := infrastructure.BackfillDetails(ctx, db, 50, nil); err != nil || !p.Done {
+		t.Fatalf("the backfill resumed = %s, %v, want it done", p, err)
+	}
+	var missing int
+	if err := db.Get(&missing, `SELECT COUNT(*) FROM tasks WHERE details IS NULL`); err != nil || missing != 0 {
+		t.Errorf("after the backfill, %d rows have no details, %v", missing, err)
+	}
+	run("after the backfill", []step{
+		{"dual-write-read-details round trip", true, func() error { return roundTrip(dualNew) }},
+		{"dual-write writes, dual-write-read-details reads", true, func() error { return handOff(dual, dualNew) }},
+		{"dual-write-read-details writes, dual-write reads, so a rollback stays possible", true, func() error { return handOff(dualNew, dual) }},
+		{"an old instance left running edits, dual-write-read-details reads", false, func() error { return staleEdit(dual, old, dualNew) }},
+	})
+
+	run("no longer writing description", []step{
+		{"details-only round trip", true, func() error { return roundTrip(details) }},
+		{"details-only writes, dual-write-read-details reads", true, func() error { return handOff(details, dualNew) }},
+	})
+	straggler, _ := domain.NewTask(1, "straggler", "written by old code", time.Now())
+	if err := old.Create(ctx, straggler); err != nil {
+		t.Fatal(err)
+	}
+	if err := infrastructure.ContractReady(db); err == nil {
+		t.Error("ContractReady after old code wrote a row = nil, want the row without details reported")
+	}
+	db.MustExec(`UPDATE tasks SET details = description WHERE details IS NULL`) // the backfill's copy, again
+	if err := infrastructure.ContractReady(db); err != nil {
+		t.Fatalf("ContractReady once every row has details = %v", err)
+	}
+
+	if err := infrastructure.Migrate(db, infrastructure.SchemaContracted); err != nil {
+		t.Fatalf("contract: %v", err)
+	}
+	run("after contract", []step{
+		{"details-only round trip", true, func() error { return roundTrip(details) }},
+		{"dual-write code", false, func() error { return roundTrip(dualNew) }},
+		{"old code", false, func() error { return roundTrip(old) }},
+	})
+	if tasks, err := details.List(ctx, domain.ListTasksQuery{}); err != nil || len(tasks) < existing {
+		t.Errorf("after contract, List = %d tasks, %v, want none lost", len(tasks), err)
+	}
+}
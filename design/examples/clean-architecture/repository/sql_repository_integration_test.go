@@ -0,0 +1,368 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+func sameInstant(a, b time.Time) bool {
+	return a.Sub(b).Abs() < time.Microsecond
+}
+
+// sameTask reports whether two tasks hold the same values, times as instants
+func sameTask(a, b *domain.Task) bool {
+	return a.ID == b.ID && a.OwnerID == b.OwnerID && a.Title == b.Title && a.Description == b.Description &&
+		a.Completed == b.Completed && a.Priority == b.Priority && reflect.DeepEqual(a.Tags, b.Tags) &&
+		a.Version == b.Version && sameInstant(a.CreatedAt, b.CreatedAt) && sameInstant(a.UpdatedAt, b.UpdatedAt)
+}
+
+// countRows returns how many rows of table match where
+func countRows(t *testing.T, db *sqlx.DB, table, where string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.Get(&n, db.Rebind(`SELECT COUNT(*) FROM `+table+` WHERE `+where), args...); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// labeledTask returns a valid, unsaved task with a priority and tags
+func labeledTask(t *testing.T, owner int64, title, description string, tags ...string) *domain.Task {
+	t.Helper()
+	task, err := domain.NewTask(owner, title, description, time.Now())
+	if err == nil {
+		err = task.SetPriority(domain.PriorityHigh, time.Now())
+	}
+	if err == nil {
+		err = task.SetTags(tags, time.Now())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestMigrations(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, db *sqlx.DB) {
+		applied, err := infrastructure.AppliedMigrations(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range infrastructure.Migrations {
+			if !applied[m.Version] {
+				t.Errorf("migration %d is not applied", m.Version)
+			}
+		}
+
+		if err := infrastructure.Migrate(db, latest()); err != nil {
+			t.Errorf("applying the migrations again: %v", err)
+		}
+		if again, _ := infrastructure.AppliedMigrations(db); !reflect.DeepEqual(applied, again) {
+			t.Errorf("applying the migrations again changed them: %v, then %v", applied, again)
+		}
+		if err := infrastructure.MigrateAutomatic(db); err != nil {
+			t.Errorf("opening the database again: %v", err)
+		}
+
+		for _, table := range []string{"tasks", "task_tags", "users", "outbox", "idempotency_keys"} {
+			if _, err := db.Exec(`SELECT * FROM ` + table + ` LIMIT 0`); err != nil {
+				t.Errorf("table %s: %v", table, err)
+			}
+		}
+		_, details := db.Exec(`SELECT details FROM tasks LIMIT 0`)
+		_, description := db.Exec(`SELECT description FROM tasks LIMIT 0`)
+		if details != nil || description == nil {
+			t.Error("the rename is not contracted: want tasks.details, and no tasks.description")
+		}
+	})
+}
+
+func TestTaskRepository(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+
+		report := labeledTask(t, 1, "Write the report", "Quarterly numbers, 100%", "work", "q3")
+		report.CreatedAt = report.CreatedAt.In(time.FixedZone("+05:00", 5*3600))
+		milk := labeledTask(t, 2, "Buy milk", "", "home")
+		if err := repo.Create(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Create(ctx, milk); err != nil {
+			t.Fatal(err)
+		}
+		if report.ID != 1 || milk.ID != 2 {
+			t.Errorf("Create numbered the tasks %d and %d, want 1 and 2", report.ID, milk.ID)
+		}
+		if got, err := repo.GetByID(ctx, report.ID); err != nil || !sameTask(got, report) {
+			t.Errorf("GetByID = %+v, %v; want every field as stored: details, priority, tags, version and times", got, err)
+		}
+
+		lists := []struct {
+			name  string
+			query domain.ListTasksQuery
+			want  []int64
+		}{
+			{"search in the details, ignoring ASCII case", domain.ListTasksQuery{Search: "QUARTERLY"}, []int64{1}},
+			{"a % in the search is literal", domain.ListTasksQuery{Search: "0%"}, []int64{1}},
+			{"owner and tag", domain.ListTasksQuery{OwnerID: 2, Tags: []string{"home"}}, []int64{2}},
+		}
+		for _, l := range lists {
+			if found, err := repo.List(ctx, l.query); err != nil || !reflect.DeepEqual(foundIDs(found), l.want) {
+				t.Errorf("List, %s: %v, %v; want %v", l.name, foundIDs(found), err, l.want)
+			}
+		}
+
+		stale := *milk
+		milk.Title, milk.Tags, milk.Completed = "Buy oat milk", []string{"errands", "home"}, true
+		if err := repo.Update(ctx, milk); err != nil || milk.Version != 2 {
+			t.Fatalf("Update: %v, version %d; want version 2", err, milk.Version)
+		}
+		if got, _ := repo.GetByID(ctx, milk.ID); got == nil || !sameTask(got, milk) {
+			t.Errorf("after Update, GetByID = %+v; want the change with the tags replaced", got)
+		}
+		stale.Title = "Buy milk again"
+		if err := repo.Update(ctx, &stale); !errors.Is(err, domain.ErrVersionConflict) {
+			t.Errorf("updating from an old version: %v, want ErrVersionConflict", err)
+		}
+		if got, _ := repo.GetByID(ctx, milk.ID); got == nil || got.Title != "Buy oat milk" {
+			t.Errorf("a conflicting update stored %+v", got)
+		}
+
+		if err := repo.Delete(ctx, milk.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetByID(ctx, milk.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByID after Delete: %v, want sql.ErrNoRows", err)
+		}
+		if n := countRows(t, db, "task_tags", "task_id = ?", milk.ID); n != 0 {
+			t.Errorf("Delete left %d tags behind", n)
+		}
+		if err := repo.Update(ctx, milk); !errors.Is(err, domain.ErrVersionConflict) {
+			t.Errorf("updating a deleted task: %v, want ErrVersionConflict", err)
+		}
+	})
+}
+
+// poison makes inserting a task titled Explodes fail, standing in for a disk
+// or constraint error halfway through a batch
+func poison(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	statement := `CREATE TRIGGER poison BEFORE INSERT ON tasks WHEN NEW.title = 'Explodes'
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END`
+	if db.DriverName() == infrastructure.DriverPostgres {
+		statement = `
+			CREATE FUNCTION poison() RETURNS trigger AS $$
+			BEGIN
+				IF NEW.title = 'Explodes' THEN RAISE EXCEPTION 'disk I/O error'; END IF;
+				RETURN NEW;
+			END $$ LANGUAGE plpgsql;
+			CREATE TRIGGER poison BEFORE INSERT ON tasks FOR EACH ROW EXECUTE FUNCTION poison()`
+	}
+	if _, err := db.Exec(statement); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSQLTaskStore runs the generated store's conformance check on each
+// database; task_store_gen_test.go runs it on SQLite only
+func TestSQLTaskStore(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := domain.WithTenant(context.Background(), "integration")
+		if err := repository.CheckTaskStoreConformance(ctx, repository.NewSQLTaskStore(db), newTaskForStore); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestCreateBatch(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+		poison(t, db)
+		batch := func(titles ...string) []*domain.Task {
+			var tasks []*domain.Task
+			for _, title := range titles {
+				task := labeledTask(t, 1, title, "", "batch")
+				task.Record(domain.TaskCreatedEvent, time.Now())
+				tasks = append(tasks, task)
+			}
+			return tasks
+		}
+
+		if err := repo.CreateBatch(ctx, batch("One", "Explodes", "Three")); err == nil {
+			t.Fatal("the poisoned batch was stored")
+		}
+		for _, table := range []string{"tasks", "task_tags", "outbox"} {
+			if n := countRows(t, db, table, "1 = 1"); n != 0 {
+				t.Errorf("a batch failing on its second insert left %d rows in %s", n, table)
+			}
+		}
+
+		tasks := batch("One", "Two", "Three")
+		if err := repo.CreateBatch(ctx, tasks); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := repo.List(ctx, domain.ListTasksQuery{Tags: []string{"batch"}})
+		if len(stored) != 3 || countRows(t, db, "outbox", "name = ?", string(domain.TaskCreatedEvent)) != 3 {
+			t.Errorf("%d tasks stored, want every task with its tags and events", len(stored))
+		}
+		if ids := foundIDs(tasks); ids[0] <= 0 || ids[1] != ids[0]+1 || ids[2] != ids[1]+1 {
+			t.Errorf("numbered %v, want consecutive ids in order", ids)
+		}
+	})
+}
+
+func TestOutboxRepository(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+		outbox := repository.NewOutboxRepository(db)
+		now := time.Now()
+		due := func(at time.Time) []domain.OutboxEvent {
+			events, err := outbox.Due(ctx, at, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return events
+		}
+
+		task := labeledTask(t, 1, "Write the report", "")
+		task.Record(domain.TaskCreatedEvent, time.Now())
+		if err := repo.Create(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+		stale := *task
+		task.MarkAsCompleted(time.Now())
+		task.Record(domain.TaskCompletedEvent, time.Now())
+		if err := repo.Update(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+		events := due(now.Add(time.Second))
+		if len(events) != 2 || events[0].Name != domain.TaskCreatedEvent || events[1].Name != domain.TaskCompletedEvent ||
+			events[1].TaskID != task.ID || events[1].Title != "Write the report" {
+			t.Fatalf("due %+v; want the task's events, oldest first", events)
+		}
+
+		stale.Record(domain.TaskCompletedEvent, time.Now())
+		if err := repo.Update(ctx, &stale); !errors.Is(err, domain.ErrVersionConflict) {
+			t.Errorf("conflicting update: %v, want ErrVersionConflict", err)
+		}
+		if n := countRows(t, db, "outbox", "1 = 1"); n != 2 {
+			t.Errorf("%d events stored; an update that conflicts should store none", n)
+		}
+
+		first, second := events[0].ID, events[1].ID
+		if err := outbox.MarkFailed(ctx, first, "connection refused", now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if got := due(now.Add(time.Second)); len(got) != 1 || got[0].ID != second {
+			t.Errorf("due %+v; a failed event is not due before its retry", got)
+		}
+		if retried := due(now.Add(2 * time.Hour)); len(retried) != 2 || retried[0].Attempts != 1 || retried[0].LastError != "connection refused" {
+			t.Errorf("due after the retry time %+v; want it with its attempts and last error", retried)
+		}
+		if err := outbox.MarkFailed(ctx, second, "gone", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := outbox.MarkDelivered(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		if got := due(now.Add(24 * time.Hour)); len(got) != 0 || countRows(t, db, "outbox", "1 = 1") != 2 {
+			t.Errorf("due %+v; delivered events and those given up on are never due again, but are kept", got)
+		}
+	})
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		store := repository.NewIdempotencyRepository(db)
+		now := time.Now().UTC().Truncate(time.Second)
+		key := domain.IdempotencyKey{OwnerID: 1, Key: "create-report"}
+
+		if record, err := store.Begin(ctx, key, "fingerprint-a", now, now.Add(time.Minute)); err != nil || record != nil {
+			t.Fatalf("Begin on a new key = %+v, %v; want it claimed", record, err)
+		}
+		if record, err := store.Begin(ctx, key, "fingerprint-b", now, now.Add(time.Minute)); err != nil || record == nil ||
+			record.Fingerprint != "fingerprint-a" || record.Response != nil {
+			t.Errorf("second Begin = %+v, %v; want the claim in progress, with its fingerprint", record, err)
+		}
+		other := domain.IdempotencyKey{OwnerID: 2, Key: key.Key}
+		if record, err := store.Begin(ctx, other, "fingerprint-a", now, now.Add(time.Minute)); err != nil || record != nil {
+			t.Errorf("another user's key of the same name = %+v, %v; want a new claim", record, err)
+		}
+
+		response := domain.StoredResponse{Status: 201, ContentType: "application/json", Body: []byte(`{"id":1}`)}
+		if err := store.Finish(ctx, key, response, now.Add(24*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Abandon(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		record, err := store.Begin(ctx, key, "fingerprint-a", now.Add(time.Hour), now.Add(time.Hour+time.Minute))
+		if err != nil || record == nil || record.Response == nil || !reflect.DeepEqual(*record.Response, response) ||
+			!sameInstant(record.ExpiresAt, now.Add(24*time.Hour)) {
+			t.Errorf("Begin after Finish = %+v, %v; want the response for replay, which Abandon does not remove", record, err)
+		}
+
+		if record, err := store.Begin(ctx, key, "fingerprint-c", now.Add(25*time.Hour), now.Add(26*time.Hour)); err != nil || record != nil {
+			t.Errorf("Begin once expired = %+v, %v; want the key claimed again", record, err)
+		}
+		if err := store.Abandon(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if record, err := store.Begin(ctx, key, "fingerprint-d", now.Add(25*time.Hour), now.Add(26*time.Hour)); err != nil || record != nil {
+			t.Errorf("Begin after Abandon = %+v, %v; want the key free", record, err)
+		}
+	})
+}
+
+func TestUserRepository(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		users := repository.NewUserRepository(db)
+
+		ada := domain.NewUser("ada@example.com", "hash")
+		if err := users.Create(ctx, ada); err != nil || ada.ID != 1 {
+			t.Fatalf("Create: %v, id %d; want id 1", err, ada.ID)
+		}
+		if err := users.Create(ctx, domain.NewUser("ada@example.com", "other")); !errors.Is(err, domain.ErrEmailTaken) {
+			t.Errorf("a second account for an email: %v, want ErrEmailTaken", err)
+		}
+		if err := users.SetRole(ctx, ada.ID, domain.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := users.GetByEmail(ctx, "ada@example.com"); err != nil || !got.IsAdmin() || got.PasswordHash != "hash" {
+			t.Errorf("GetByEmail = %+v, %v; want the admin role stored", got, err)
+		}
+		if _, err := users.GetByID(ctx, 99); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("an unknown account: %v, want ErrUserNotFound", err)
+		}
+		if err := users.SetRole(ctx, 99, domain.RoleAdmin); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("SetRole of an unknown account: %v, want ErrUserNotFound", err)
+		}
+
+		grace := domain.NewUser("grace@example.com", "hash")
+		if err := users.Create(ctx, grace); err != nil {
+			t.Fatal(err)
+		}
+		if all, err := users.List(ctx); err != nil || len(all) != 2 || all[0].ID != ada.ID || all[1].ID != grace.ID {
+			t.Errorf("List = %d accounts, %v; want both in id order", len(all), err)
+		}
+		if some, err := users.GetByIDs(ctx, []int64{grace.ID, 99, ada.ID}); err != nil || len(some) != 2 || some[0].ID != ada.ID || some[1].ID != grace.ID {
+			t.Errorf("GetByIDs = %d accounts, %v; want the two that exist, in id order", len(some), err)
+		}
+	})
+}
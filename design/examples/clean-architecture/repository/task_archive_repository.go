@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// TaskRepositoryImpl is also the domain.TaskArchive of its tasks, in the
+// archived_tasks table. Archiving a task moves its row there in the same
+// transaction that deletes it and its tags, so it is never in both tables
+// nor in neither.
+
+// archivedTaskRow is a row of archived_tasks. It has no completed column,
+// since every archived task is completed, and tags is a JSON array.
+type archivedTaskRow struct {
+	ID          int64     `db:"id"`
+	OwnerID     int64     `db:"owner_id"`
+	AssigneeID  int64     `db:"assignee_id"`
+	Title       string    `db:"title"`
+	Description string    `db:"description"`
+	Priority    string    `db:"priority"`
+	Tags        string    `db:"tags"`
+	Version     int64     `db:"version"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+	ArchivedAt  time.Time `db:"archived_at"`
+	TenantID    string    `db:"tenant_id"`
+}
+
+func (r archivedTaskRow) toDomain() (domain.ArchivedTask, error) {
+	var tags []string
+	if err := json.Unmarshal([]byte(r.Tags), &tags); err != nil {
+		return domain.ArchivedTask{}, err
+	}
+	if len(tags) == 0 {
+		tags = nil // as TaskRepositoryImpl reads a task without tags
+	}
+	return domain.ArchivedTask{
+		Task: domain.Task{
+			ID:          r.ID,
+			OwnerID:     r.OwnerID,
+			AssigneeID:  r.AssigneeID,
+			Title:       r.Title,
+			Description: r.Description,
+			Completed:   true,
+			Priority:    domain.Priority(r.Priority),
+			Tags:        tags,
+			Version:     r.Version,
+			CreatedAt:   r.CreatedAt,
+			UpdatedAt:   r.UpdatedAt,
+			TenantID:    domain.TenantID(r.TenantID),
+		},
+		ArchivedAt: r.ArchivedAt,
+	}, nil
+}
+
+const archivedTaskColumns = "id, owner_id, assignee_id, title, description, priority, tags, version, created_at, updated_at, archived_at, tenant_id"
+
+// Archive reads the tasks due outside the transaction, then moves each one
+// whose version is still the one read. Its first statement updates the row
+// to itself, which locks it until the transaction ends and matches nothing
+// if the task was changed or deleted in between: such a task is skipped.
+func (r *TaskRepositoryImpl) Archive(ctx context.Context, completedBefore, now time.Time, limit int) ([]domain.ArchivedTask, error) {
+	tenant, args := andTenant(ctx)
+	query := `
+		SELECT ` + r.selectColumns() + `
+		FROM tasks
+		WHERE completed = ? AND ` + r.dialect.instant("updated_at") + " < " + r.dialect.instant("?") + tenant + `
+		ORDER BY ` + r.dialect.instant("updated_at") + `, id
+		LIMIT ?
+	`
+	args = append(append([]interface{}{true, completedBefore}, args...), limit)
+	var records []taskRecord
+	if err := r.db.SelectContext(ctx, &records, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	tasks, err := r.toDomain(ctx, records)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	archived := make([]domain.ArchivedTask, 0, len(tasks))
+	for _, task := range tasks {
+		result, err := tx.ExecContext(ctx, tx.Rebind(`UPDATE tasks SET version = version WHERE id = ? AND version = ?`), task.ID, task.Version)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			continue // changed or deleted since it was read
+		}
+
+		tags := task.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		tagsJSON, err := json.Marshal(tags)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(`
+			INSERT INTO archived_tasks (`+archivedTaskColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`), task.ID, task.OwnerID, task.AssigneeID, task.Title, task.Description, string(task.Priority), string(tagsJSON),
+			task.Version, task.CreatedAt, task.UpdatedAt, now, string(task.TenantID.OrDefault())); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM task_tags WHERE task_id = ?`), task.ID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM tasks WHERE id = ?`), task.ID); err != nil {
+			return nil, err
+		}
+		archived = append(archived, domain.ArchivedTask{Task: *task, ArchivedAt: now})
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return archived, nil
+}
+
+func (r *TaskRepositoryImpl) ListArchived(ctx context.Context, ownerID int64) ([]domain.ArchivedTask, error) {
+	tenant, args := andTenant(ctx)
+	query := `SELECT ` + archivedTaskColumns + ` FROM archived_tasks WHERE 1 = 1` + tenant
+	if ownerID != 0 {
+		query += ` AND owner_id = ?`
+		args = append(args, ownerID)
+	}
+	query += ` ORDER BY ` + r.dialect.instant("archived_at") + ` DESC, id DESC`
+	var rows []archivedTaskRow
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	archived := make([]domain.ArchivedTask, len(rows))
+	for i, row := range rows {
+		var err error
+		if archived[i], err = row.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+	return archived, nil
+}
@@ -0,0 +1,142 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+const day = 24 * time.Hour
+
+func archivedTitles(tasks []domain.ArchivedTask) []string {
+	out := []string{}
+	for _, task := range tasks {
+		out = append(out, task.Title)
+	}
+	return out
+}
+
+func TestArchive(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		archive := r.(domain.TaskArchive)
+		now := time.Now().UTC().Truncate(time.Second)
+		create := func(owner int64, title string, completed bool, age time.Duration, tags ...string) *domain.Task {
+			t.Helper()
+			task, err := domain.NewTask(owner, title, "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.Completed, task.Tags = completed, tags
+			task.CreatedAt, task.UpdatedAt = now.Add(-age-day), now.Add(-age)
+			if err := r.Create(ctx, task); err != nil {
+				t.Fatal(err)
+			}
+			return task
+		}
+		report := create(1, "Write the report", true, 40*day, "work")
+		open := create(1, "Buy milk", false, 50*day)
+		recent := create(1, "Call the bank", true, day)
+		create(2, "Make the slides", true, 35*day)
+
+		// Each run archives the completed tasks last changed before the
+		// cutoff, longest ago first; open and recently completed ones stay
+		runs := []struct {
+			name  string
+			at    time.Time
+			limit int
+			want  []string
+		}{
+			{"with a limit of one", now, 1, []string{"Write the report"}},
+			{"then", now.Add(time.Minute), 10, []string{"Make the slides"}},
+			{"and at last", now.Add(2 * time.Minute), 10, []string{}},
+		}
+		for _, run := range runs {
+			archived, err := archive.Archive(ctx, now.Add(-30*day), run.at, run.limit)
+			if err != nil || !reflect.DeepEqual(archivedTitles(archived), run.want) {
+				t.Errorf("%s, Archive = %q, %v, want %q", run.name, archivedTitles(archived), err, run.want)
+			}
+		}
+
+		if _, err := r.GetByID(ctx, report.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByID of an archived task = %v, want %v", err, sql.ErrNoRows)
+		}
+		if got := foundIDs(mustList(t, r)); !reflect.DeepEqual(got, []int64{recent.ID, open.ID}) {
+			t.Errorf("the tasks left = %v, want the open and the recent one", got)
+		}
+
+		lists := []struct {
+			name  string
+			owner int64
+			want  []string
+		}{
+			{"everyone's, most recently archived first", 0, []string{"Make the slides", "Write the report"}},
+			{"one owner's", 1, []string{"Write the report"}},
+			{"an owner with nothing archived", 3, []string{}},
+		}
+		for _, tt := range lists {
+			got, err := archive.ListArchived(ctx, tt.owner)
+			if err != nil || got == nil || !reflect.DeepEqual(archivedTitles(got), tt.want) {
+				t.Errorf("ListArchived of %s = %q, %v, want %q", tt.name, archivedTitles(got), err, tt.want)
+			}
+		}
+		mine, err := archive.ListArchived(ctx, 1)
+		if err != nil || len(mine) != 1 {
+			t.Fatalf("ListArchived(1) = %d tasks, %v", len(mine), err)
+		}
+		got := mine[0]
+		if !got.Completed || got.ID != report.ID || got.Title != report.Title || !reflect.DeepEqual(got.Tags, []string{"work"}) ||
+			got.Version != report.Version || !got.UpdatedAt.Equal(report.UpdatedAt) || !got.ArchivedAt.Equal(now) {
+			t.Errorf("the archived task = %+v, want its fields and tags kept, archived at %v", got, now)
+		}
+	})
+}
+
+func mustList(t *testing.T, r domain.TaskRepository) []*domain.Task {
+	t.Helper()
+	tasks, err := r.List(context.Background(), domain.ListTasksQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tasks
+}
+
+func TestArchiveMigrationOnExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sqlx.Open(infrastructure.DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := infrastructure.Migrate(legacy, infrastructure.SchemaAssignees); err != nil {
+		t.Fatal(err)
+	}
+	long := time.Now().Add(-90 * day)
+	if _, err := legacy.Exec(`INSERT INTO tasks (owner_id, title, details, completed, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		1, "Completed before the archive", "", true, long, long); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	db, err := infrastructure.OpenDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if applied, _ := infrastructure.AppliedMigrations(db); !applied[infrastructure.SchemaArchive] {
+		t.Error("archived_tasks was not added at startup")
+	}
+	r := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+	archived, err := r.Archive(context.Background(), time.Now().Add(-30*day), time.Now(), 10)
+	if got, want := archivedTitles(archived), []string{"Completed before the archive"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Archive = %q, %v, want the task completed before it, %q", got, err, want)
+	}
+}
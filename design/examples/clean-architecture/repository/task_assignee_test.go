@@ -0,0 +1,118 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+func listedTitles(t *testing.T, r domain.TaskRepository, query domain.ListTasksQuery) []string {
+	t.Helper()
+	tasks, err := r.List(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := []string{}
+	for _, task := range tasks {
+		out = append(out, task.Title)
+	}
+	return out
+}
+
+func TestAssignee(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		create := func(owner int64, title string, assignee int64) *domain.Task {
+			t.Helper()
+			task, err := domain.NewTask(owner, title, "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.AssigneeID = assignee
+			if err := r.Create(ctx, task); err != nil {
+				t.Fatal(err)
+			}
+			return task
+		}
+		report := create(1, "Write the report", 2)
+		create(1, "Buy milk", 0)
+		create(3, "Make the slides", 2)
+		if got, err := r.GetByID(ctx, report.ID); err != nil || got.AssigneeID != 2 {
+			t.Errorf("after Create, GetByID = %+v, %v, want the assignee stored", got, err)
+		}
+		batch := []*domain.Task{{OwnerID: 3, Title: "Book the venue", AssigneeID: 1, Version: 1, Priority: domain.PriorityMedium}}
+		if err := r.CreateBatch(ctx, batch); err != nil {
+			t.Fatal(err)
+		}
+		venue, err := r.GetByID(ctx, batch[0].ID)
+		if err != nil || venue.AssigneeID != 1 {
+			t.Errorf("after CreateBatch, GetByID = %+v, %v, want the assignee stored", venue, err)
+		}
+
+		if got, want := listedTitles(t, r, domain.ListTasksQuery{AssigneeID: 2}), []string{"Make the slides", "Write the report"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("listing by assignee = %q, want theirs whoever owns them, %q", got, want)
+		}
+		if got, want := listedTitles(t, r, domain.ListTasksQuery{AssigneeID: 2, OwnerID: 1}), []string{"Write the report"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("listing by assignee and owner = %q, want only the owner's, %q", got, want)
+		}
+
+		venue.Unassign(time.Now())
+		if err := r.Update(ctx, venue); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := r.GetByID(ctx, venue.ID); err != nil || got.AssigneeID != 0 {
+			t.Errorf("after unassigning, GetByID = %+v, %v, want nobody assigned", got, err)
+		}
+		if got := listedTitles(t, r, domain.ListTasksQuery{AssigneeID: 1}); len(got) != 0 {
+			t.Errorf("listing the former assignee's = %q, want none", got)
+		}
+	})
+}
+
+func TestAssigneeMigrationOnExistingDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sqlx.Open(infrastructure.DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := infrastructure.Migrate(legacy, infrastructure.SchemaIdempotency); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := legacy.Exec(`INSERT INTO tasks (owner_id, title, details, completed, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		1, "Written before assignees", "", false, now, now); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	db, err := infrastructure.OpenDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if applied, _ := infrastructure.AppliedMigrations(db); !applied[infrastructure.SchemaAssignees] {
+		t.Error("the assignee column was not added at startup")
+	}
+	r := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+	task, err := r.GetByID(ctx, 1)
+	if err != nil || task.AssigneeID != 0 {
+		t.Fatalf("the existing task = %+v, %v, want it assigned to nobody", task, err)
+	}
+	if err := task.Assign(2, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Update(ctx, task); err != nil {
+		t.Fatalf("assigning it = %v", err)
+	}
+	if got, want := listedTitles(t, r, domain.ListTasksQuery{AssigneeID: 2}), []string{"Written before assignees"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listing its assignee's = %q, want %q", got, want)
+	}
+}
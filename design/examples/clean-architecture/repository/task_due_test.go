@@ -0,0 +1,48 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+func TestTaskDueDate(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		now := time.Now()
+		due := now.Add(24 * time.Hour).Truncate(time.Second)
+		report, err := domain.NewTask(1, "Write the report", "", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := report.SetDue(due, now); err != nil {
+			t.Fatal(err)
+		}
+		plain, err := domain.NewTask(1, "No rush", "", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, task := range []*domain.Task{report, plain} {
+			if err := r.Create(ctx, task); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		read, err := r.GetByID(ctx, report.ID)
+		if err != nil || !read.DueAt.Equal(due) {
+			t.Fatalf("GetByID = due %v, %v, want %v", read.DueAt, err, due)
+		}
+		read.MarkAsCompleted(now)
+		if err := r.Update(ctx, read); err != nil {
+			t.Fatal(err)
+		}
+		if read, err = r.GetByID(ctx, report.ID); err != nil || !read.DueAt.Equal(due) {
+			t.Errorf("after Update, GetByID = due %v, %v, want it kept", read.DueAt, err)
+		}
+		if read, err = r.GetByID(ctx, plain.ID); err != nil || !read.DueAt.IsZero() {
+			t.Errorf("a task that is not due reads as due %v, %v, want none", read.DueAt, err)
+		}
+	})
+}
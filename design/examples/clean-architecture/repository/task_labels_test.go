@@ -0,0 +1,109 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// legacyDatabase creates a database as it was before priorities and tags,
+// migrated up to through with one task in it, and returns its path
+func legacyDatabase(t *testing.T, through int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sqlx.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := infrastructure.Migrate(db, through); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := db.Exec(`INSERT INTO tasks (title, description, completed, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		"Written before tags", "old", false, now, now); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLabelsMigrationOnExistingDatabases(t *testing.T) {
+	tests := []struct {
+		name    string
+		through int
+		columns repository.DescriptionColumns
+	}{
+		{"a baseline database", infrastructure.SchemaBaseline, repository.DescriptionOnly},
+		{"a database half-way through the details rename", infrastructure.SchemaExpanded, repository.DualWriteReadDescription},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := legacyDatabase(t, tt.through)
+			db, err := infrastructure.OpenDatabase(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			applied, err := infrastructure.AppliedMigrations(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !applied[infrastructure.SchemaLabels] {
+				t.Error("priorities and tags were not added at startup")
+			}
+			if applied[tt.through+1] {
+				t.Errorf("the pending manual step %d was applied", tt.through+1)
+			}
+			existing, err := repository.NewTaskRepositoryWithColumns(db, tt.columns).GetByID(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if existing.Priority != domain.PriorityMedium || len(existing.Tags) != 0 {
+				t.Errorf("the existing task reads as %s %v, want medium and untagged", existing.Priority, existing.Tags)
+			}
+			db.Close()
+
+			// Opening again applies nothing twice
+			db, err = infrastructure.OpenDatabase(path)
+			if err != nil {
+				t.Fatalf("reopening: %v", err)
+			}
+			db.Close()
+		})
+	}
+}
+
+func TestDeleteRemovesTags(t *testing.T) {
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := repository.NewTaskRepository(db)
+	task, err := domain.NewTask(1, "Tagged", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.SetTags([]string{"ops", "release"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := r.Create(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	var orphans int
+	if err := db.Get(&orphans, `SELECT COUNT(*) FROM task_tags WHERE task_id NOT IN (SELECT id FROM tasks)`); err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 0 {
+		t.Errorf("%d task_tags rows outlived their task", orphans)
+	}
+}
@@ -0,0 +1,155 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+var (
+	plus7  = time.FixedZone("+07:00", 7*60*60)
+	march1 = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// listFixtures are created in this order; the cases refer to them by title
+var listFixtures = []struct {
+	title, description string
+	completed          bool
+	created            time.Time
+	tags               []string
+}{
+	{"Write the quarterly report", "numbers for Q1", false, march1.Add(9 * time.Hour), []string{"finance", "q1"}},
+	{"Review pull requests", "three waiting", true, march1.Add(24 * time.Hour), []string{"dev"}},
+	{"Fix 100% CPU on the worker", "a loop in the REPORT generator", false, march1.Add(5 * 24 * time.Hour), []string{"dev", "urgent"}},
+	{"Rename user_id to owner_id", "", true, march1.Add(7 * 24 * time.Hour), []string{"dev", "schema"}},
+	{"Renamed userXid by mistake", "", false, march1.Add(8*24*time.Hour + time.Hour), []string{"Dev", "Urgent"}},
+	// 01:00 on the 10th in +07:00 is still the 9th in UTC
+	{"Book the Café for Friday", "team lunch", false, time.Date(2024, 3, 10, 1, 0, 0, 0, plus7), nil},
+	{"Plan the offsite", "agenda and budget", true, march1.Add(14 * 24 * time.Hour), []string{"q1"}},
+}
+
+// listParents makes some fixtures subtasks of earlier ones, by title
+var listParents = map[string]string{
+	"Fix 100% CPU on the worker": "Review pull requests",
+	"Rename user_id to owner_id": "Fix 100% CPU on the worker",
+	"Renamed userXid by mistake": "Review pull requests",
+}
+
+func seedListFixtures(t *testing.T, r domain.TaskRepository) {
+	t.Helper()
+	ids := make(map[string]int64)
+	for _, f := range listFixtures {
+		task, err := domain.NewTask(1, f.title, f.description, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Completed = f.completed
+		if err := task.SetTags(f.tags, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		task.CreatedAt, task.UpdatedAt = f.created, f.created
+		task.ParentID = ids[listParents[f.title]]
+		if err := r.Create(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+		ids[f.title] = task.ID
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// TestListFilters runs the same queries against the in-memory repository,
+// which filters with ListTasksQuery.Matches, and SQLite, which filters in SQL,
+// before and after the description -> details rename. All of them must return
+// the same tasks in the same order.
+func TestListFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query domain.ListTasksQuery
+		want  []string // titles, newest first
+	}{
+		{"no filter lists every task, newest first", domain.ListTasksQuery{}, []string{
+			"Plan the offsite", "Book the Café for Friday", "Renamed userXid by mistake", "Rename user_id to owner_id",
+			"Fix 100% CPU on the worker", "Review pull requests", "Write the quarterly report"}},
+		{"completed=true", domain.ListTasksQuery{Completed: boolPtr(true)}, []string{
+			"Plan the offsite", "Rename user_id to owner_id", "Review pull requests"}},
+		{"completed=false", domain.ListTasksQuery{Completed: boolPtr(false)}, []string{
+			"Book the Café for Friday", "Renamed userXid by mistake", "Fix 100% CPU on the worker", "Write the quarterly report"}},
+		{"created_from is inclusive, created_before exclusive",
+			domain.ListTasksQuery{CreatedFrom: march1.Add(24 * time.Hour), CreatedBefore: march1.Add(7 * 24 * time.Hour)},
+			[]string{"Fix 100% CPU on the worker", "Review pull requests"}},
+		{"dates compare as instants across time zones",
+			domain.ListTasksQuery{CreatedFrom: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), CreatedBefore: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+			[]string{"Book the Café for Friday", "Renamed userXid by mistake"}},
+		{"q ignores ASCII case and searches descriptions too", domain.ListTasksQuery{Search: "report"}, []string{
+			"Fix 100% CPU on the worker", "Write the quarterly report"}},
+		{"q matches a non-ASCII title", domain.ListTasksQuery{Search: "café"}, []string{"Book the Café for Friday"}},
+		{"% in q is literal", domain.ListTasksQuery{Search: "0%"}, []string{"Fix 100% CPU on the worker"}},
+		{"_ in q is literal", domain.ListTasksQuery{Search: "user_id"}, []string{"Rename user_id to owner_id"}},
+		{"filters combine", domain.ListTasksQuery{Search: "rename", Completed: boolPtr(false)}, []string{"Renamed userXid by mistake"}},
+		{"tag", domain.ListTasksQuery{Tags: []string{"q1"}}, []string{"Plan the offsite", "Write the quarterly report"}},
+		{"several tags must all be present", domain.ListTasksQuery{Tags: []string{"dev", "urgent"}}, []string{
+			"Renamed userXid by mistake", "Fix 100% CPU on the worker"}},
+		{"tags with other filters", domain.ListTasksQuery{Tags: []string{"dev"}, Completed: boolPtr(true), Search: "rename"},
+			[]string{"Rename user_id to owner_id"}},
+		{"the subtasks of a task", domain.ListTasksQuery{ParentIDs: []int64{2}}, []string{
+			"Renamed userXid by mistake", "Fix 100% CPU on the worker"}},
+		{"the subtasks of several, with other filters", domain.ListTasksQuery{ParentIDs: []int64{2, 3}, Tags: []string{"dev"}, Completed: boolPtr(true)},
+			[]string{"Rename user_id to owner_id"}},
+		{"a task without subtasks", domain.ListTasksQuery{ParentIDs: []int64{7}}, nil},
+		{"nothing matches", domain.ListTasksQuery{Search: "holiday"}, nil},
+		{"no task has every tag", domain.ListTasksQuery{Tags: []string{"finance", "dev"}}, nil},
+		{"limit keeps the newest", domain.ListTasksQuery{Limit: 2}, []string{"Plan the offsite", "Book the Café for Friday"}},
+		{"offset skips the newest", domain.ListTasksQuery{Limit: 2, Offset: 2}, []string{
+			"Renamed userXid by mistake", "Rename user_id to owner_id"}},
+		{"offset without limit lists the rest", domain.ListTasksQuery{Offset: 5}, []string{
+			"Review pull requests", "Write the quarterly report"}},
+		{"a page of a filtered listing", domain.ListTasksQuery{Completed: boolPtr(false), Limit: 2, Offset: 1}, []string{
+			"Renamed userXid by mistake", "Fix 100% CPU on the worker"}},
+		{"a page past the end is empty", domain.ListTasksQuery{Limit: 2, Offset: 7}, nil},
+	}
+
+	run := func(t *testing.T, r domain.TaskRepository) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tasks, err := r.List(context.Background(), tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, task := range tasks {
+					got = append(got, task.Title)
+				}
+				if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+					t.Errorf("got %q\nwant %q", got, tt.want)
+				}
+			})
+		}
+	}
+
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		seedListFixtures(t, r)
+		run(t, r)
+	})
+	t.Run("sqlite reading details after the rename's backfill", func(t *testing.T) {
+		db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		seedListFixtures(t, repository.NewTaskRepository(db))
+		if err := infrastructure.Migrate(db, infrastructure.SchemaExpanded); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := infrastructure.BackfillDetails(context.Background(), db, 100, func(infrastructure.BackfillProgress) {}); err != nil {
+			t.Fatal(err)
+		}
+		run(t, repository.NewTaskRepositoryWithColumns(db, repository.DualWriteReadDetails))
+	})
+}
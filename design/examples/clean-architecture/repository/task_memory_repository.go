@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// MemoryTaskRepository is a domain.TaskRepository kept in memory, on top of
+// the generated MemoryTaskStore. It filters with ListTasksQuery.Matches and
+// orders like TaskRepositoryImpl, so tests and examples can run the use case
+// without a database. Recorded events go to its own outbox, and archived
+// tasks to its own domain.TaskArchive. Each call only sees the tasks of the
+// tenant of its ctx, as TaskRepositoryImpl does.
+type MemoryTaskRepository struct {
+	store  *MemoryTaskStore
+	outbox *MemoryOutboxRepository
+	// mu makes Update's version check and write one step, and Archive's
+	// choice of tasks and their move, and guards archived
+	mu       sync.Mutex
+	archived []domain.ArchivedTask
+}
+
+func NewMemoryTaskRepository() *MemoryTaskRepository {
+	return &MemoryTaskRepository{store: NewMemoryTaskStore(), outbox: NewMemoryOutboxRepository()}
+}
+
+// Outbox returns the outbox the repository stores recorded events in
+func (r *MemoryTaskRepository) Outbox() *MemoryOutboxRepository {
+	return r.outbox
+}
+
+// The store copies tasks by value, so tags are cloned on the way in and out:
+// a caller changing its slice must not change the stored task. Events go to
+// the outbox rather than the store.
+
+func (r *MemoryTaskRepository) Create(ctx context.Context, task *domain.Task) error {
+	task.TenantID = tenantFor(ctx, task.TenantID)
+	stored := *task
+	stored.Tags = slices.Clone(task.Tags)
+	stored.ClearEvents()
+	if err := r.store.Create(ctx, &stored); err != nil {
+		return err
+	}
+	r.outbox.add(stored.ID, task.PendingEvents())
+	task.ID = stored.ID
+	task.ClearEvents()
+	return nil
+}
+
+// CreateBatch stores every task; in memory nothing can fail part-way
+func (r *MemoryTaskRepository) CreateBatch(ctx context.Context, tasks []*domain.Task) error {
+	for _, task := range tasks {
+		if err := r.Create(ctx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryTaskRepository) GetByID(ctx context.Context, id int64) (*domain.Task, error) {
+	task, err := r.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.TenantOf(ctx).Includes(task.TenantID) {
+		return nil, sql.ErrNoRows
+	}
+	task.Tags = slices.Clone(task.Tags)
+	return task, nil
+}
+
+func (r *MemoryTaskRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Task, error) {
+	tasks := make([]*domain.Task, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		task, err := r.GetByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
+
+func (r *MemoryTaskRepository) Update(ctx context.Context, task *domain.Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.store.GetByID(ctx, task.ID)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil || current.Version != task.Version || !domain.TenantOf(ctx).Includes(current.TenantID) {
+		return domain.ErrVersionConflict
+	}
+	stored := *task
+	stored.TenantID = current.TenantID
+	stored.Tags = slices.Clone(task.Tags)
+	stored.Version++
+	stored.ClearEvents()
+	if err := r.store.Update(ctx, &stored); err != nil {
+		if errors.Is(err, sql.ErrNoRows) { // deleted since the lookup
+			return domain.ErrVersionConflict
+		}
+		return err
+	}
+	r.outbox.add(task.ID, task.PendingEvents())
+	task.Version = stored.Version
+	task.ClearEvents()
+	return nil
+}
+
+func (r *MemoryTaskRepository) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return r.store.Delete(ctx, id)
+}
+
+// List returns the tasks matching query, newest first
+func (r *MemoryTaskRepository) List(ctx context.Context, query domain.ListTasksQuery) ([]*domain.Task, error) {
+	all, err := r.store.List(ctx, TaskCriteria{})
+	if err != nil {
+		return nil, err
+	}
+	tenant := domain.TenantOf(ctx)
+	tasks := make([]*domain.Task, 0, len(all))
+	for _, task := range all {
+		if tenant.Includes(task.TenantID) && query.Matches(task) {
+			task.Tags = slices.Clone(task.Tags)
+			tasks = append(tasks, task)
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+		}
+		return tasks[i].ID > tasks[j].ID
+	})
+	tasks = tasks[min(query.Offset, len(tasks)):]
+	if query.Limit > 0 {
+		tasks = tasks[:min(query.Limit, len(tasks))]
+	}
+	return tasks, nil
+}
+
+// Archive moves the completed tasks last changed before completedBefore,
+// longest ago first, as TaskRepositoryImpl does
+func (r *MemoryTaskRepository) Archive(ctx context.Context, completedBefore, now time.Time, limit int) ([]domain.ArchivedTask, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all, err := r.store.List(ctx, TaskCriteria{})
+	if err != nil {
+		return nil, err
+	}
+	tenant := domain.TenantOf(ctx)
+	var due []*domain.Task
+	for _, task := range all {
+		if tenant.Includes(task.TenantID) && task.Completed && task.UpdatedAt.Before(completedBefore) {
+			due = append(due, task)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if !due[i].UpdatedAt.Equal(due[j].UpdatedAt) {
+			return due[i].UpdatedAt.Before(due[j].UpdatedAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+
+	archived := []domain.ArchivedTask{}
+	for _, task := range due[:min(limit, len(due))] {
+		if err := r.store.Delete(ctx, task.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) { // deleted since the listing
+				continue
+			}
+			return archived, err
+		}
+		a := domain.ArchivedTask{Task: *task, ArchivedAt: now}
+		r.archived = append(r.archived, a)
+		a.Tags = slices.Clone(task.Tags)
+		archived = append(archived, a)
+	}
+	return archived, nil
+}
+
+func (r *MemoryTaskRepository) ListArchived(ctx context.Context, ownerID int64) ([]domain.ArchivedTask, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenant := domain.TenantOf(ctx)
+	archived := []domain.ArchivedTask{}
+	for _, a := range r.archived {
+		if tenant.Includes(a.TenantID) && (ownerID == 0 || a.OwnerID == ownerID) {
+			a.Tags = slices.Clone(a.Tags)
+			archived = append(archived, a)
+		}
+	}
+	sort.SliceStable(archived, func(i, j int) bool {
+		if !archived[i].ArchivedAt.Equal(archived[j].ArchivedAt) {
+			return archived[i].ArchivedAt.After(archived[j].ArchivedAt)
+		}
+		return archived[i].ID > archived[j].ID
+	})
+	return archived, nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// MaxTaskActivity is how many changes MemoryTaskReadModel remembers, for
+// everyone together and for each owner
+const MaxTaskActivity = 100
+
+// MemoryTaskReadModel is a domain.TaskReadModel kept in memory. It keeps
+// what it last saw of each task, so that a change older than that, or one
+// seen twice, can be told apart and ignored, and the counts of each owner,
+// so that a summary does not walk the tasks. Deleted tasks are remembered
+// as such, so a late update cannot bring one back. Counts and activity are
+// kept per tenant, and a query answers for the tenant of its ctx.
+type MemoryTaskReadModel struct {
+	clock domain.Clock
+
+	mu       sync.RWMutex
+	tasks    map[int64]projectedTask
+	counts   map[readModelKey]*domain.TaskSummary
+	activity map[readModelKey][]domain.TaskActivity
+}
+
+// readModelKey is what counts and activity are kept by: a tenant, or
+// AllTenants, and an owner, or 0 for everyone in it
+type readModelKey struct {
+	tenant  domain.TenantID
+	ownerID int64
+}
+
+// keysOf returns the keys a task of owner in tenant is counted under
+func keysOf(tenant domain.TenantID, ownerID int64) []readModelKey {
+	return []readModelKey{{tenant, 0}, {tenant, ownerID}, {domain.AllTenants, 0}, {domain.AllTenants, ownerID}}
+}
+
+type projectedTask struct {
+	tenant    domain.TenantID
+	ownerID   int64
+	version   int64
+	completed bool
+	priority  domain.Priority
+	deleted   bool
+}
+
+// NewMemoryTaskReadModel returns an empty read model. clock may be nil for
+// domain.SystemClock.
+func NewMemoryTaskReadModel(clock domain.Clock) *MemoryTaskReadModel {
+	if clock == nil {
+		clock = domain.SystemClock
+	}
+	return &MemoryTaskReadModel{
+		clock:    clock,
+		tasks:    map[int64]projectedTask{},
+		counts:   map[readModelKey]*domain.TaskSummary{},
+		activity: map[readModelKey][]domain.TaskActivity{},
+	}
+}
+
+func (m *MemoryTaskReadModel) Reset(ctx context.Context, tasks []*domain.Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks = make(map[int64]projectedTask, len(tasks))
+	m.counts = map[readModelKey]*domain.TaskSummary{}
+	for _, task := range tasks {
+		m.store(task.ID, projectionOf(task))
+	}
+	return nil
+}
+
+func projectionOf(task *domain.Task) projectedTask {
+	return projectedTask{tenant: task.TenantID.OrDefault(), ownerID: task.OwnerID, version: task.Version, completed: task.Completed, priority: task.Priority}
+}
+
+func (m *MemoryTaskReadModel) Apply(ctx context.Context, event domain.TaskEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	task := &event.Task
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, known := m.tasks[task.ID]
+	gone := event.Type == domain.TaskDeleted || event.Type == domain.TaskArchived
+	if old.deleted || (known && !gone && task.Version <= old.version) {
+		return nil
+	}
+
+	kind := domain.ActivityCreated
+	next := projectionOf(task)
+	switch event.Type {
+	case domain.TaskDeleted:
+		kind, next.deleted = domain.ActivityDeleted, true
+	case domain.TaskArchived:
+		kind, next.deleted = domain.ActivityArchived, true
+	case domain.TaskUpdated:
+		switch {
+		case known && task.Completed && !old.completed:
+			kind = domain.ActivityCompleted
+		case known && !task.Completed && old.completed:
+			kind = domain.ActivityReopened
+		default:
+			kind = domain.ActivityUpdated
+		}
+	}
+	if known {
+		m.count(old, -1)
+	}
+	m.store(task.ID, next)
+	m.record(next.tenant, domain.TaskActivity{
+		Kind:    kind,
+		TaskID:  task.ID,
+		OwnerID: task.OwnerID,
+		Title:   task.Title,
+		Version: task.Version,
+		At:      m.clock.Now(),
+	})
+	return nil
+}
+
+// store keeps what is known of task id and counts it
+func (m *MemoryTaskReadModel) store(id int64, task projectedTask) {
+	m.tasks[id] = task
+	m.count(task, 1)
+}
+
+// count adds delta to the counts task is in, for its owner and for everyone.
+// Deleted tasks are in none.
+func (m *MemoryTaskReadModel) count(task projectedTask, delta int) {
+	if task.deleted {
+		return
+	}
+	for _, key := range keysOf(task.tenant, task.ownerID) {
+		summary := m.counts[key]
+		if summary == nil {
+			summary = &domain.TaskSummary{ByPriority: map[domain.Priority]int{}}
+			m.counts[key] = summary
+		}
+		summary.Total += delta
+		if task.completed {
+			summary.Completed += delta
+		} else {
+			summary.Open += delta
+		}
+		summary.ByPriority[task.priority] += delta
+	}
+}
+
+// record adds activity in tenant, forgetting the oldest past
+// MaxTaskActivity
+func (m *MemoryTaskReadModel) record(tenant domain.TenantID, activity domain.TaskActivity) {
+	for _, key := range keysOf(tenant, activity.OwnerID) {
+		recent := append(m.activity[key], activity)
+		if len(recent) > MaxTaskActivity {
+			recent = recent[len(recent)-MaxTaskActivity:]
+		}
+		m.activity[key] = recent
+	}
+}
+
+func (m *MemoryTaskReadModel) Summary(ctx context.Context, ownerID int64) (domain.TaskSummary, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.TaskSummary{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	summary := domain.TaskSummary{ByPriority: map[domain.Priority]int{
+		domain.PriorityLow:    0,
+		domain.PriorityMedium: 0,
+		domain.PriorityHigh:   0,
+	}}
+	if counted := m.counts[readModelKey{domain.TenantOf(ctx), ownerID}]; counted != nil {
+		summary.Total, summary.Open, summary.Completed = counted.Total, counted.Open, counted.Completed
+		for priority, n := range counted.ByPriority {
+			summary.ByPriority[priority] = n
+		}
+	}
+	return summary, nil
+}
+
+func (m *MemoryTaskReadModel) RecentActivity(ctx context.Context, ownerID int64, limit int) ([]domain.TaskActivity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	recent := m.activity[readModelKey{domain.TenantOf(ctx), ownerID}]
+	n := min(limit, len(recent))
+	newest := make([]domain.TaskActivity, n)
+	for i := range newest {
+		newest[i] = recent[len(recent)-1-i]
+	}
+	return newest, nil
+}
@@ -0,0 +1,85 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+// readEvent is an event on one of owner 1's tasks
+func readEvent(typ domain.TaskEventType, id, version int64, completed bool) domain.TaskEvent {
+	return domain.TaskEvent{Type: typ, Task: domain.Task{
+		ID: id, OwnerID: 1, Title: fmt.Sprintf("Task %d", id),
+		Completed: completed, Priority: domain.PriorityMedium, Version: version,
+	}}
+}
+
+func TestReadModelProjection(t *testing.T) {
+	ctx := context.Background()
+	model := repository.NewMemoryTaskReadModel(nil)
+	// summary is owner 1's total, open and completed tasks
+	summary := func() string {
+		s, err := model.Summary(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(s.Total, s.Open, s.Completed)
+	}
+	kinds := func() string {
+		activity, err := model.RecentActivity(ctx, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kinds []domain.TaskActivityKind
+		for _, a := range activity {
+			kinds = append(kinds, a.Kind)
+		}
+		return fmt.Sprint(kinds)
+	}
+
+	// The steps apply their events in order, each on what the ones before
+	// left
+	steps := []struct {
+		name     string
+		events   []domain.TaskEvent
+		summary  string
+		activity string
+	}{
+		{"two created tasks count as two open ones",
+			[]domain.TaskEvent{readEvent(domain.TaskCreated, 1, 1, false), readEvent(domain.TaskCreated, 2, 1, false)},
+			"2 2 0", "[created created]"},
+		{"completing one moves it to completed, recorded as completed rather than updated",
+			[]domain.TaskEvent{readEvent(domain.TaskUpdated, 1, 2, true)},
+			"2 1 1", "[completed created created]"},
+		{"an event seen again, or one older than the last, changes nothing",
+			[]domain.TaskEvent{readEvent(domain.TaskUpdated, 1, 2, true), readEvent(domain.TaskCreated, 1, 1, false)},
+			"2 1 1", "[completed created created]"},
+		{"version 4 reopening it wins over a late version 3",
+			[]domain.TaskEvent{readEvent(domain.TaskUpdated, 1, 4, false), readEvent(domain.TaskUpdated, 1, 3, true)},
+			"2 2 0", "[reopened completed created created]"},
+		{"a deleted task stays deleted, even if an update arrives after",
+			[]domain.TaskEvent{readEvent(domain.TaskDeleted, 2, 1, false), readEvent(domain.TaskUpdated, 2, 2, true)},
+			"1 1 0", "[deleted reopened completed created created]"},
+	}
+	for _, step := range steps {
+		for _, e := range step.events {
+			if err := model.Apply(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, activity := summary(), kinds(); got != step.summary || activity != step.activity {
+			t.Errorf("%s: summary %s and activity %s, want %s and %s, newest first", step.name, got, activity, step.summary, step.activity)
+		}
+	}
+
+	others, err := model.Summary(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if others.Total != 0 || others.Open != 0 || others.Completed != 0 || others.ByPriority[domain.PriorityHigh] != 0 || len(others.ByPriority) != 3 {
+		t.Errorf("an owner without tasks = %+v, want zero of everything", others)
+	}
+}
@@ -1,95 +1,460 @@
 package repository
 
 import (
+"context"
 "database/sql"
+"fmt"
+"math"
+"slices"
+"sort"
+"strconv"
+"strings"
+
 "github.com/dong-tran/docs/clean-architecture-example/domain"
 "github.com/jmoiron/sqlx"
 )
 
+// DescriptionColumns selects where a task's description is stored while
+// tasks.description is renamed to tasks.details (see
+// infrastructure/migrations.go). The domain never sees the difference. Roll
+// the setting forward one step per deploy, and only once every instance runs
+// the previous step.
+type DescriptionColumns int
+
+const (
+	DescriptionOnly          DescriptionColumns = iota // before the rename
+	DualWriteReadDescription                           // after expand: write both, read the old column
+	DualWriteReadDetails                               // after backfill: write both, read the new column
+	DetailsOnly                                        // last step before contract
+)
+
+var descriptionColumnNames = []string{"description", "dual-write", "dual-write-read-details", "details"}
+
+func (c DescriptionColumns) String() string {
+	return descriptionColumnNames[c]
+}
+
+func ParseDescriptionColumns(s string) (DescriptionColumns, error) {
+	for i, name := range descriptionColumnNames {
+		if s == name {
+			return DescriptionColumns(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown description columns %q (want one of %s)", s, strings.Join(descriptionColumnNames, ", "))
+}
+
+// written lists the columns a write stores the description in
+func (c DescriptionColumns) written() []string {
+	switch c {
+	case DescriptionOnly:
+		return []string{"description"}
+	case DetailsOnly:
+		return []string{"details"}
+	}
+	return []string{"description", "details"}
+}
+
+// value is the SQL expression for the description. Reading details falls
+// back to description for rows the backfill has not reached yet.
+func (c DescriptionColumns) value() string {
+	switch c {
+	case DualWriteReadDetails:
+		return "COALESCE(details, description)"
+	case DetailsOnly:
+		return "COALESCE(details, '')"
+	}
+	return "description"
+}
+
+// read is the select expression for the description, named details as the
+// generated taskRow maps it (see domain.Task)
+func (c DescriptionColumns) read() string {
+	return c.value() + " AS details"
+}
+
 type TaskRepositoryImpl struct {
-	db *sqlx.DB
+	db      *sqlx.DB
+	dialect dialect
+	columns DescriptionColumns
 }
 
 func NewTaskRepository(db *sqlx.DB) domain.TaskRepository {
-	return &TaskRepositoryImpl{db: db}
+	return NewTaskRepositoryWithColumns(db, DescriptionOnly)
 }
 
-func (r *TaskRepositoryImpl) Create(task *domain.Task) error {
-	query := `
-		INSERT INTO tasks (title, description, completed, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
-	`
-	result, err := r.db.Exec(query,
-task.Title,
-task.Description,
-task.Completed,
-task.CreatedAt,
-task.UpdatedAt,
-)
+func NewTaskRepositoryWithColumns(db *sqlx.DB, columns DescriptionColumns) *TaskRepositoryImpl {
+	return &TaskRepositoryImpl{db: db, dialect: dialectOf(db), columns: columns}
+}
+
+func (r *TaskRepositoryImpl) selectColumns() string {
+	return "id, owner_id, title, " + r.columns.read() + ", completed, priority, version, assignee_id, parent_id, tenant_id, due_at, created_at, updated_at"
+}
+
+// taskRecord adds the columns the generated taskRow does not model. Tags are
+// kept in task_tags, one row per tag.
+type taskRecord struct {
+	taskRow
+	Priority   string       `db:"priority"`
+	Version    int64        `db:"version"`
+	AssigneeID int64        `db:"assignee_id"`
+	ParentID   int64        `db:"parent_id"`
+	DueAt      sql.NullTime `db:"due_at"`
+}
+
+func (r taskRecord) toDomain() *domain.Task {
+	task := r.taskRow.toDomain()
+	task.Priority = domain.Priority(r.Priority)
+	task.Version = r.Version
+	task.AssigneeID = r.AssigneeID
+	task.ParentID = r.ParentID
+	if r.DueAt.Valid {
+		task.DueAt = r.DueAt.Time
+	}
+	return task
+}
+
+// dueAt is the due_at of a task, NULL if it is not due
+func dueAt(task *domain.Task) sql.NullTime {
+	return sql.NullTime{Time: task.DueAt, Valid: !task.DueAt.IsZero()}
+}
+
+// tagQueryBatch keeps the IN list of a tag lookup under SQLite's limit on
+// bound parameters
+const tagQueryBatch = 500
+
+// toDomain converts records to tasks and loads their tags
+func (r *TaskRepositoryImpl) toDomain(ctx context.Context, records []taskRecord) ([]*domain.Task, error) {
+	tasks := make([]*domain.Task, len(records))
+	byID := make(map[int64]*domain.Task, len(records))
+	for i, record := range records {
+		tasks[i] = record.toDomain()
+		byID[record.ID] = tasks[i]
+	}
+	for start := 0; start < len(records); start += tagQueryBatch {
+		batch := records[start:min(start+tagQueryBatch, len(records))]
+		ids := make([]int64, len(batch))
+		for i, record := range batch {
+			ids[i] = record.ID
+		}
+		query, args, err := sqlx.In(`SELECT task_id, tag FROM task_tags WHERE task_id IN (?) ORDER BY tag`, ids)
+		if err != nil {
+			return nil, err
+		}
+		var tags []struct {
+			TaskID int64  `db:"task_id"`
+			Tag    string `db:"tag"`
+		}
+		if err := r.db.SelectContext(ctx, &tags, r.db.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+		for _, t := range tags {
+			task := byID[t.TaskID]
+			task.Tags = append(task.Tags, t.Tag)
+		}
+	}
+	return tasks, nil
+}
+
+// saveTags replaces the tags stored for a task
+func saveTags(ctx context.Context, tx *sqlx.Tx, id int64, tags []string) error {
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM task_tags WHERE task_id = ?`), id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, tx.Rebind(`INSERT INTO task_tags (task_id, tag) VALUES (?, ?)`), id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *TaskRepositoryImpl) Create(ctx context.Context, task *domain.Task) error {
+	return r.CreateBatch(ctx, []*domain.Task{task})
+}
+
+// CreateBatch inserts the tasks in one transaction through a single prepared
+// statement, so a batch costs one commit rather than one per task. IDs are
+// only set once the whole batch is committed. Canceling ctx part-way rolls
+// the batch back.
+func (r *TaskRepositoryImpl) CreateBatch(ctx context.Context, tasks []*domain.Task) error {
+	written := r.columns.written()
+	columns := append([]string{"owner_id", "title"}, written...)
+	columns = append(columns, "completed", "priority", "version", "assignee_id", "parent_id", "tenant_id", "due_at", "created_at", "updated_at")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	id, err := result.LastInsertId()
+	insert, err := tx.PrepareContext(ctx, tx.Rebind(fmt.Sprintf(`
+		INSERT INTO tasks (%s)
+		VALUES (?%s)
+		RETURNING id
+	`, strings.Join(columns, ", "), strings.Repeat(", ?", len(columns)-1))))
 	if err != nil {
 		return err
 	}
+	defer insert.Close()
 
-	task.ID = id
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		task.TenantID = tenantFor(ctx, task.TenantID)
+		args := []interface{}{task.OwnerID, task.Title}
+		for range written {
+			args = append(args, task.Description)
+		}
+		args = append(args, task.Completed, string(task.Priority), task.Version, task.AssigneeID, task.ParentID, string(task.TenantID), dueAt(task), task.CreatedAt, task.UpdatedAt)
+		if err := insert.QueryRowContext(ctx, args...).Scan(&ids[i]); err != nil {
+			return err
+		}
+		if err := saveTags(ctx, tx, ids[i], task.Tags); err != nil {
+			return err
+		}
+		if err := saveEvents(ctx, tx, ids[i], task.PendingEvents()); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for i, task := range tasks {
+		task.ID = ids[i]
+		task.ClearEvents()
+	}
 	return nil
 }
 
-func (r *TaskRepositoryImpl) GetByID(id int64) (*domain.Task, error) {
+func (r *TaskRepositoryImpl) GetByID(ctx context.Context, id int64) (*domain.Task, error) {
+	tenant, args := andTenant(ctx)
 	query := `
-		SELECT id, title, description, completed, created_at, updated_at
+		SELECT ` + r.selectColumns() + `
 		FROM tasks
-		WHERE id = ?
-	`
-	var task domain.Task
-	err := r.db.Get(&task, query, id)
+		WHERE id = ?` + tenant
+	var record taskRecord
+	if err := r.db.GetContext(ctx, &record, r.db.Rebind(query), append([]interface{}{id}, args...)...); err != nil {
+		return nil, err
+	}
+
+	tasks, err := r.toDomain(ctx, []taskRecord{record})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, err
-		}
 		return nil, err
 	}
+	return tasks[0], nil
+}
+
+// taskQueryBatch keeps the IN list of GetByIDs under SQLite's limit on bound
+// parameters, as tagQueryBatch does
+const taskQueryBatch = tagQueryBatch
 
-	return &task, nil
+func (r *TaskRepositoryImpl) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Task, error) {
+	// IN matches a repeated id once per batch, so one split across batches
+	// would come back twice
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	tenant, tenantArgs := andTenant(ctx)
+	var records []taskRecord
+	for start := 0; start < len(ids); start += taskQueryBatch {
+		query, args, err := sqlx.In(`SELECT `+r.selectColumns()+` FROM tasks WHERE id IN (?)`+tenant,
+			append([]interface{}{ids[start:min(start+taskQueryBatch, len(ids))]}, tenantArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		var batch []taskRecord
+		if err := r.db.SelectContext(ctx, &batch, r.db.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+		records = append(records, batch...)
+	}
+	// Batches are in ID order, but rows within one need not be
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return r.toDomain(ctx, records)
 }
 
-func (r *TaskRepositoryImpl) GetAll() ([]*domain.Task, error) {
+// likeEscaper escapes the LIKE wildcards in a search term, so they match
+// themselves
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// List returns the tasks matching query, newest first. It filters in SQL with
+// the same semantics as ListTasksQuery.Matches: created_at is compared and
+// ordered as an instant whatever its stored offset, and search ignores ASCII
+// case only.
+func (r *TaskRepositoryImpl) List(ctx context.Context, q domain.ListTasksQuery) ([]*domain.Task, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if tenant := domain.TenantOf(ctx); tenant != domain.AllTenants {
+		where = append(where, "tenant_id = ?")
+		args = append(args, string(tenant))
+	}
+	if q.OwnerID != 0 {
+		where = append(where, "owner_id = ?")
+		args = append(args, q.OwnerID)
+	}
+	if q.AssigneeID != 0 {
+		where = append(where, "assignee_id = ?")
+		args = append(args, q.AssigneeID)
+	}
+	if len(q.ParentIDs) > 0 {
+		where = append(where, "parent_id IN (?"+strings.Repeat(", ?", len(q.ParentIDs)-1)+")")
+		for _, id := range q.ParentIDs {
+			args = append(args, id)
+		}
+	}
+	if q.Completed != nil {
+		where = append(where, "completed = ?")
+		args = append(args, *q.Completed)
+	}
+	if !q.CreatedFrom.IsZero() {
+		where = append(where, r.dialect.instant("created_at")+" >= "+r.dialect.instant("?"))
+		args = append(args, q.CreatedFrom)
+	}
+	if !q.CreatedBefore.IsZero() {
+		where = append(where, r.dialect.instant("created_at")+" < "+r.dialect.instant("?"))
+		args = append(args, q.CreatedBefore)
+	}
+	if q.Search != "" {
+		pattern := "%" + likeEscaper.Replace(q.Search) + "%"
+		where = append(where, "("+r.dialect.contains("title")+" OR "+r.dialect.contains(r.columns.value())+")")
+		args = append(args, pattern, pattern)
+	}
+	for _, tag := range q.Tags {
+		where = append(where, "id IN (SELECT task_id FROM task_tags WHERE tag = ?)")
+		args = append(args, tag)
+	}
+
 	query := `
-		SELECT id, title, description, completed, created_at, updated_at
+		SELECT ` + r.selectColumns() + `
 		FROM tasks
-		ORDER BY created_at DESC
 	`
-	var tasks []*domain.Task
-	err := r.db.Select(&tasks, query)
-	if err != nil {
+	if len(where) > 0 {
+		query += "WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + r.dialect.instant("created_at") + " DESC, id DESC"
+	if q.Limit > 0 || q.Offset > 0 {
+		// SQLite takes no OFFSET without a LIMIT, and PostgreSQL no
+		// negative LIMIT: "all" is the largest one
+		limit := int64(q.Limit)
+		if limit == 0 {
+			limit = math.MaxInt64
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, q.Offset)
+	}
+	var records []taskRecord
+	if err := r.db.SelectContext(ctx, &records, r.db.Rebind(query), args...); err != nil {
 		return nil, err
 	}
+	return r.toDomain(ctx, records)
+}
 
-	return tasks, nil
+// FindPage returns up to limit tasks in id order after cursor ("" for the
+// first page), and the cursor of the next page, "" after the last one. The
+// cursor is the last id returned, so rows inserted or deleted between pages
+// neither shift nor repeat results. It has the shape of the design-patterns
+// PageRepository, so a PageIterator can walk the whole table lazily.
+func (r *TaskRepositoryImpl) FindPage(ctx context.Context, cursor string, limit int) ([]*domain.Task, string, error) {
+	var after int64
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id < 0 {
+			return nil, "", fmt.Errorf("invalid task page cursor %q", cursor)
+		}
+		after = id
+	}
+	tenant, args := andTenant(ctx)
+	query := `
+		SELECT ` + r.selectColumns() + `
+		FROM tasks
+		WHERE id > ?` + tenant + `
+		ORDER BY id
+		LIMIT ?
+	`
+	args = append(append([]interface{}{after}, args...), limit)
+	var records []taskRecord
+	if err := r.db.SelectContext(ctx, &records, r.db.Rebind(query), args...); err != nil {
+		return nil, "", err
+	}
+
+	tasks, err := r.toDomain(ctx, records)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(tasks) < limit {
+		return tasks, "", nil
+	}
+	return tasks, strconv.FormatInt(tasks[len(tasks)-1].ID, 10), nil
 }
 
-func (r *TaskRepositoryImpl) Update(task *domain.Task) error {
+// Update checks the version and writes in one UPDATE, so of two updates
+// from the same version, whichever runs second matches no row and conflicts
+func (r *TaskRepositoryImpl) Update(ctx context.Context, task *domain.Task) error {
+	set := []string{"title = ?"}
+	args := []interface{}{task.Title}
+	for _, column := range r.columns.written() {
+		set = append(set, column+" = ?")
+		args = append(args, task.Description)
+	}
+	args = append(args, task.Completed, string(task.Priority), task.AssigneeID, dueAt(task), task.UpdatedAt, task.ID, task.Version)
+	tenant, tenantArgs := andTenant(ctx)
+	args = append(args, tenantArgs...)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE tasks
-		SET title = ?, description = ?, completed = ?, updated_at = ?
-		WHERE id = ?
-	`
-	_, err := r.db.Exec(query,
-task.Title,
-task.Description,
-task.Completed,
-task.UpdatedAt,
-task.ID,
-)
-	return err
+		SET ` + strings.Join(set, ", ") + `, completed = ?, priority = ?, assignee_id = ?, due_at = ?, updated_at = ?, version = version + 1
+		WHERE id = ? AND version = ?` + tenant
+	result, err := tx.ExecContext(ctx, tx.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrVersionConflict
+	}
+	if err := saveTags(ctx, tx, task.ID, task.Tags); err != nil {
+		return err
+	}
+	if err := saveEvents(ctx, tx, task.ID, task.PendingEvents()); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	task.Version++
+	task.ClearEvents()
+	return nil
 }
 
-func (r *TaskRepositoryImpl) Delete(id int64) error {
-	query := `DELETE FROM tasks WHERE id = ?`
-	_, err := r.db.Exec(query, id)
-	return err
+func (r *TaskRepositoryImpl) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The task first, so that the tags of another tenant's are left alone
+	tenant, args := andTenant(ctx)
+	result, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM tasks WHERE id = ?`+tenant), append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM task_tags WHERE task_id = ?`), id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,334 @@
+//go:build mongodb
+
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/mongodb"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The MongoDB tests need a server, so they build only with the mongodb tag,
+// and skip unless MONGODB_URI names one:
+//
+//	MONGODB_URI=mongodb://localhost:27017 go test -tags mongodb ./repository
+//
+// Each test works in a database of its own and drops it afterwards.
+
+// mongoDatabase returns a fresh database and a task repository on it
+func mongoDatabase(t *testing.T) (*mongo.Database, *mongodb.TaskRepository) {
+	t.Helper()
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("set MONGODB_URI to test MongoDB")
+	}
+	ctx := context.Background()
+	server, err := mongodb.Connect(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := server.Client().Database(fmt.Sprintf("repository_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		db.Drop(ctx)
+		server.Client().Disconnect(ctx)
+	})
+	repo, err := mongodb.NewTaskRepository(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, repo
+}
+
+var mongoBase = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// mongoTasks returns the same tasks every time it is called. Their times are
+// written in different offsets, which must not change how they compare.
+func mongoTasks(t *testing.T) []*domain.Task {
+	t.Helper()
+	east, west := time.FixedZone("+05:00", 5*3600), time.FixedZone("-07:00", -7*3600)
+	specs := []struct {
+		owner       int64
+		title, desc string
+		completed   bool
+		priority    domain.Priority
+		tags        []string
+		created     time.Time
+	}{
+		{1, "Write report", "Quarterly numbers", false, domain.PriorityHigh, []string{"work"}, mongoBase},
+		{1, "Buy MILK", "", true, domain.PriorityLow, []string{"errands", "home"}, mongoBase.Add(time.Hour).In(east)},
+		{2, "100% done.ish", `back\slash (draft)`, false, domain.PriorityMedium, []string{"work"}, mongoBase.Add(2 * time.Hour)},
+		{2, "Café visit", "ÉCLAIR", false, domain.PriorityMedium, nil, mongoBase.Add(3 * time.Hour).In(west)},
+		{1, "report review", "", false, domain.PriorityMedium, []string{"home", "work"}, mongoBase.Add(3 * time.Hour)},
+	}
+	tasks := make([]*domain.Task, len(specs))
+	for i, s := range specs {
+		task, err := domain.NewTask(s.owner, s.title, s.desc, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Completed, task.Priority, task.Tags = s.completed, s.priority, s.tags
+		task.CreatedAt, task.UpdatedAt = s.created, s.created
+		tasks[i] = task
+	}
+	return tasks
+}
+
+// storeMongoTasks stores mongoTasks in r, the first with Create and the rest
+// with CreateBatch, and returns them
+func storeMongoTasks(t *testing.T, r domain.TaskRepository) []*domain.Task {
+	t.Helper()
+	tasks := mongoTasks(t)
+	if err := r.Create(context.Background(), tasks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateBatch(context.Background(), tasks[1:]); err != nil {
+		t.Fatal(err)
+	}
+	return tasks
+}
+
+// sameMongoTask reports whether two tasks hold the same values, times as
+// instants
+func sameMongoTask(a, b *domain.Task) bool {
+	return a.ID == b.ID && a.OwnerID == b.OwnerID && a.Title == b.Title && a.Description == b.Description &&
+		a.Completed == b.Completed && a.Priority == b.Priority && reflect.DeepEqual(a.Tags, b.Tags) &&
+		a.Version == b.Version && a.CreatedAt.Equal(b.CreatedAt) && a.UpdatedAt.Equal(b.UpdatedAt)
+}
+
+func TestMongoIndexes(t *testing.T) {
+	ctx := context.Background()
+	db, _ := mongoDatabase(t)
+	cursor, err := db.Collection("tasks").Indexes().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		t.Fatal(err)
+	}
+	unique := map[string]bool{}
+	for _, index := range indexes {
+		isUnique, _ := index["unique"].(bool)
+		unique[index["name"].(string)] = isUnique
+	}
+	_, ownerCreated := unique["owner_created"]
+	_, tags := unique["tags"]
+	if !unique["id"] || !ownerCreated || !tags {
+		t.Errorf("indexes %v, want a unique one on id, and owner_created and tags", unique)
+	}
+	if _, err := mongodb.NewTaskRepository(ctx, db); err != nil {
+		t.Errorf("creating the indexes again at the next startup: %v", err)
+	}
+}
+
+func TestMongoIDs(t *testing.T) {
+	ctx := context.Background()
+	db, repo := mongoDatabase(t)
+	stored := storeMongoTasks(t, repo)
+	if ids := foundIDs(stored); !reflect.DeepEqual(ids, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("Create and CreateBatch numbered the tasks %v, want from 1", ids)
+	}
+	var raw bson.M
+	if err := db.Collection("tasks").FindOne(ctx, bson.D{{Key: "id", Value: int64(1)}}).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, isObjectID := raw["_id"].(primitive.ObjectID); !isObjectID {
+		t.Errorf("_id is %T, want an ObjectID beside the domain ID in id", raw["_id"])
+	}
+	var counter bson.M
+	if err := db.Collection("counters").FindOne(ctx, bson.D{{Key: "_id", Value: "tasks"}}).Decode(&counter); err != nil {
+		t.Fatal(err)
+	}
+	if counter["seq"] != int64(5) {
+		t.Errorf("the counter holds %v, want the last ID handed out, 5", counter["seq"])
+	}
+}
+
+// TestMongoListMatchesMemory runs every kind of list query on MongoDB and
+// against the in-memory repository, which filters with
+// ListTasksQuery.Matches: both must return the same tasks in the same order
+func TestMongoListMatchesMemory(t *testing.T) {
+	completed := func(b bool) *bool { return &b }
+	queries := []struct {
+		name  string
+		query domain.ListTasksQuery
+	}{
+		{"everything, newest first", domain.ListTasksQuery{}},
+		{"one owner", domain.ListTasksQuery{OwnerID: 1}},
+		{"completed", domain.ListTasksQuery{Completed: completed(true)}},
+		{"not completed", domain.ListTasksQuery{Completed: completed(false)}},
+		{"created from, across offsets", domain.ListTasksQuery{CreatedFrom: mongoBase.Add(time.Hour)}},
+		{"created before, across offsets", domain.ListTasksQuery{CreatedBefore: mongoBase.Add(3 * time.Hour)}},
+		{"search ignores ASCII case", domain.ListTasksQuery{Search: "REPORT"}},
+		{"search in the description", domain.ListTasksQuery{Search: "quarterly"}},
+		{"search for regexp characters", domain.ListTasksQuery{Search: "0% done."}},
+		{`search for \ and (`, domain.ListTasksQuery{Search: `\slash (`}},
+		{"search does not fold other letters", domain.ListTasksQuery{Search: "éclair"}},
+		{"one tag", domain.ListTasksQuery{Tags: []string{"work"}}},
+		{"every tag", domain.ListTasksQuery{Tags: []string{"home", "work"}}},
+		{"owner, search and tag together", domain.ListTasksQuery{OwnerID: 1, Search: "r", Tags: []string{"work"}}},
+	}
+	ctx := context.Background()
+	_, repo := mongoDatabase(t)
+	memory := repository.NewMemoryTaskRepository()
+	storeMongoTasks(t, memory)
+	for _, task := range storeMongoTasks(t, repo) {
+		if got, err := repo.GetByID(ctx, task.ID); err != nil || !sameMongoTask(got, task) {
+			t.Errorf("GetByID(%d) = %+v, %v; want every field as stored, tags included", task.ID, got, err)
+		}
+	}
+	if _, err := repo.GetByID(ctx, 99); !errors.Is(err, mongo.ErrNoDocuments) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("an unknown ID = %v, want mongo.ErrNoDocuments and sql.ErrNoRows", err)
+	}
+
+	for _, q := range queries {
+		t.Run(q.name, func(t *testing.T) {
+			query, err := q.query.Normalize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := repo.List(ctx, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := memory.List(ctx, query)
+			if !reflect.DeepEqual(foundIDs(got), foundIDs(want)) {
+				t.Errorf("MongoDB lists %v, memory %v", foundIDs(got), foundIDs(want))
+			}
+		})
+	}
+}
+
+func TestMongoUpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	_, repo := mongoDatabase(t)
+	task := storeMongoTasks(t, repo)[1]
+
+	stale := *task
+	task.Title, task.Completed, task.Tags = "Buy oat milk", false, []string{"shopping"}
+	task.UpdatedAt = mongoBase.Add(4 * time.Hour)
+	if err := repo.Update(ctx, task); err != nil || task.Version != 2 {
+		t.Fatalf("Update = %v, version %d; want version 2", err, task.Version)
+	}
+	if got, err := repo.GetByID(ctx, task.ID); err != nil || !sameMongoTask(got, task) {
+		t.Errorf("after Update, GetByID = %+v, %v; want the change with the tags replaced", got, err)
+	}
+	stale.Title = "Buy milk again"
+	if err := repo.Update(ctx, &stale); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("updating from an old version = %v, want ErrVersionConflict", err)
+	}
+	if got, _ := repo.GetByID(ctx, task.ID); got == nil || !sameMongoTask(got, task) {
+		t.Errorf("a conflicting update stored %+v", got)
+	}
+	missing := *task
+	missing.ID = 99
+	if err := repo.Update(ctx, &missing); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("updating an unknown ID = %v, want ErrVersionConflict", err)
+	}
+
+	if err := repo.Delete(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByID(ctx, task.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("GetByID after Delete = %v, want mongo.ErrNoDocuments", err)
+	}
+
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	fresh, _ := domain.NewTask(1, "never stored", "", time.Now())
+	if err := repo.Create(done, fresh); !errors.Is(err, context.Canceled) || fresh.ID != 0 {
+		t.Errorf("Create with a done context = %v, ID %d; want context.Canceled and nothing stored", err, fresh.ID)
+	}
+}
+
+func TestMongoFailedBatchLeavesNothing(t *testing.T) {
+	ctx := context.Background()
+	db, repo := mongoDatabase(t)
+	storeMongoTasks(t, repo)
+
+	// A stray document takes the ID the batch's second task will get
+	if _, err := db.Collection("tasks").InsertOne(ctx, bson.D{{Key: "id", Value: int64(7)}, {Key: "title", Value: "stray"}}); err != nil {
+		t.Fatal(err)
+	}
+	var batch []*domain.Task
+	for _, title := range []string{"first", "second", "third"} {
+		task, _ := domain.NewTask(1, title, "", time.Now())
+		batch = append(batch, task)
+	}
+	if err := repo.CreateBatch(ctx, batch); err == nil || batch[0].ID != 0 {
+		t.Errorf("CreateBatch = %v, first ID %d; want an error and no IDs", err, batch[0].ID)
+	}
+	count := func(filter bson.D) int64 {
+		n, err := db.Collection("tasks").CountDocuments(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(bson.D{{Key: "title", Value: bson.D{{Key: "$in", Value: bson.A{"first", "second", "third"}}}}}); n != 0 {
+		t.Errorf("the failed batch left %d of its tasks", n)
+	}
+	if n := count(bson.D{{Key: "title", Value: "stray"}}); n != 1 {
+		t.Errorf("the failed batch removed the stray document too")
+	}
+}
+
+func TestMongoConcurrentCreatesGetConsecutiveIDs(t *testing.T) {
+	ctx := context.Background()
+	_, repo := mongoDatabase(t)
+	var wg sync.WaitGroup
+	created := make([]int64, 20)
+	for i := range created {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			task, _ := domain.NewTask(1, fmt.Sprintf("concurrent %d", i), "", time.Now())
+			if err := repo.Create(ctx, task); err != nil {
+				t.Error(err)
+				return
+			}
+			created[i] = task.ID
+		}(i)
+	}
+	wg.Wait()
+	sort.Slice(created, func(i, j int) bool { return created[i] < created[j] })
+	for i, id := range created {
+		if id != int64(i+1) {
+			t.Fatalf("20 concurrent creates got IDs %v, want 1 to 20", created)
+		}
+	}
+}
+
+// TestMongoUseCase runs the task use case on MongoDB, as main.go does with
+// MONGODB_URI set
+func TestMongoUseCase(t *testing.T) {
+	ctx := context.Background()
+	_, repo := mongoDatabase(t)
+	uc := usecase.NewTaskUseCase(repo)
+	owner := &domain.User{ID: 1, Role: domain.RoleUser}
+	task, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Through the use case", Tags: []string{"Mongo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mine, err := uc.ListTasks(ctx, owner, domain.ListTasksQuery{Tags: []string{"mongo"}}); err != nil || len(mine) != 1 || mine[0].ID != task.ID {
+		t.Errorf("listing by the normalized tag = %v, %v", foundIDs(mine), err)
+	}
+	if _, err := uc.GetTask(ctx, &domain.User{ID: 2, Role: domain.RoleUser}, task.ID); !errors.Is(err, usecase.ErrTaskNotFound) {
+		t.Errorf("another user's GetTask = %v, want ErrTaskNotFound", err)
+	}
+}
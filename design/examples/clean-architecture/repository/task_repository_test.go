@@ -0,0 +1,110 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+)
+
+// openSQLite opens a migrated SQLite database in the test's temporary directory
+func openSQLite(t *testing.T) domain.TaskRepository {
+	t.Helper()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repository.NewTaskRepository(db)
+}
+
+// taskRepositories runs test against each hand-written TaskRepository
+func taskRepositories(t *testing.T, test func(t *testing.T, r domain.TaskRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, repository.NewMemoryTaskRepository()) })
+	t.Run("sqlite", func(t *testing.T) { test(t, openSQLite(t)) })
+}
+
+// createTasks stores n tasks of owner 1 and returns their IDs
+func createTasks(t *testing.T, r domain.TaskRepository, n int) []int64 {
+	t.Helper()
+	tasks := make([]*domain.Task, n)
+	for i := range tasks {
+		task, err := domain.NewTask(1, "Task", "", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks[i] = task
+	}
+	if err := r.CreateBatch(context.Background(), tasks); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, n)
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func foundIDs(tasks []*domain.Task) []int64 {
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func TestGetByIDs(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		ids := createTasks(t, r, 3)
+		first, err := r.GetByID(ctx, ids[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		first.Priority, first.Tags = domain.PriorityHigh, []string{"bulk"}
+		if err := r.Update(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+
+		found, err := r.GetByIDs(ctx, []int64{ids[2], 9999, ids[1], ids[2]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := foundIDs(found); len(got) != 2 || got[0] != ids[1] || got[1] != ids[2] {
+			t.Fatalf("got ids %v, want %v: the tasks that exist, once each, in ID order", got, ids[1:])
+		}
+		if found[0].Priority != domain.PriorityHigh || len(found[0].Tags) != 1 || found[0].Tags[0] != "bulk" {
+			t.Errorf("got priority %q and tags %v, want them stored", found[0].Priority, found[0].Tags)
+		}
+
+		if found, err := r.GetByIDs(ctx, nil); err != nil || len(found) != 0 {
+			t.Errorf("no ids: got %d tasks, %v", len(found), err)
+		}
+	})
+}
+
+// TestGetByIDsAcrossBatches asks for more IDs than one SQL query may bind,
+// with every ID repeated so that copies land in different batches
+func TestGetByIDsAcrossBatches(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ids := createTasks(t, r, 600)
+		requested := append(append([]int64(nil), ids...), ids...)
+		found, err := r.GetByIDs(context.Background(), append(requested, 999999))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := foundIDs(found)
+		if len(got) != len(ids) {
+			t.Fatalf("got %d tasks for %d distinct ids", len(got), len(ids))
+		}
+		for i := range got {
+			if got[i] != ids[i] {
+				t.Fatalf("task %d has id %d, want %d", i, got[i], ids[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/jmoiron/sqlx"
+)
+
+// The fixtures of the generated store test, task_store_gen_test.go
+
+// openStoreDB opens a SQLite database in the test's temporary directory,
+// migrated to the last version: the generated stores read and write
+// tasks.details, which the manual migrations leave in place of description
+func openStoreDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := infrastructure.Migrate(db, infrastructure.Migrations[len(infrastructure.Migrations)-1].Version); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTaskForStore() *domain.Task {
+	task, err := domain.NewTask(1, "Task", "Stored in tasks.details", time.Now())
+	if err != nil {
+		panic(err)
+	}
+	return task
+}
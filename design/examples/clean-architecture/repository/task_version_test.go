@@ -0,0 +1,50 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// TestVersionMigration opens a database migrated as far as it went before
+// versions, the details rename included, with a task in it
+func TestVersionMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sqlx.Open(infrastructure.DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := infrastructure.Migrate(legacy, infrastructure.SchemaRoles); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := legacy.Exec(`INSERT INTO tasks (owner_id, title, details, completed, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		1, "Written before versions", "", false, now, now); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	db, err := infrastructure.OpenDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if applied, err := infrastructure.AppliedMigrations(db); err != nil || !applied[infrastructure.SchemaVersions] {
+		t.Fatalf("the version column was not added at startup: %v", err)
+	}
+	repo := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+	task, err := repo.GetByID(ctx, 1)
+	if err != nil || task.Version != 1 {
+		t.Fatalf("the existing task reads as %+v, %v; want version 1", task, err)
+	}
+	task.Title = "Updated after versions"
+	if err := repo.Update(ctx, task); err != nil || task.Version != 2 {
+		t.Errorf("Update = %v, version %d; want version 2", err, task.Version)
+	}
+}
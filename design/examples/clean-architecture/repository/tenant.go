@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Every repository scopes what it does to the tenant of its ctx
+// (domain.TenantOf). Rows have a tenant_id; reads, changes and deletes only
+// match the tenant's rows, so a row of another tenant is one that does not
+// exist, and new rows are created in the tenant. domain.AllTenants scopes
+// nothing: only the server's own jobs, such as the archiver, act for it.
+
+// andTenant returns the condition limiting a WHERE clause to the tenant of
+// ctx, " AND tenant_id = ?", and its argument; nothing for AllTenants
+func andTenant(ctx context.Context) (string, []interface{}) {
+	tenant := domain.TenantOf(ctx)
+	if tenant == domain.AllTenants {
+		return "", nil
+	}
+	return " AND tenant_id = ?", []interface{}{string(tenant)}
+}
+
+// tenantFor returns the tenant a row created through ctx belongs to: the
+// tenant of ctx, or for AllTenants, the one the row was given
+func tenantFor(ctx context.Context, given domain.TenantID) domain.TenantID {
+	if tenant := domain.TenantOf(ctx); tenant != domain.AllTenants {
+		return tenant
+	}
+	return given.OrDefault()
+}
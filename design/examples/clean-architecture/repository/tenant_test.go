@@ -0,0 +1,259 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	acme   domain.TenantID = "acme"
+	globex domain.TenantID = "globex"
+)
+
+func tenantTitles(t *testing.T, r domain.TaskRepository, ctx context.Context, query domain.ListTasksQuery) []string {
+	t.Helper()
+	tasks, err := r.List(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := []string{}
+	for _, task := range tasks {
+		out = append(out, task.Title)
+	}
+	return out
+}
+
+// TestTaskTenantIsolation stores a task of owner 1 in each tenant and
+// reaches for it from the other
+func TestTaskTenantIsolation(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		bg := context.Background()
+		inAcme, inGlobex := domain.WithTenant(bg, acme), domain.WithTenant(bg, globex)
+		create := func(ctx context.Context, title string) *domain.Task {
+			t.Helper()
+			task, err := domain.NewTask(1, title, "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.Tags = []string{"shared"}
+			if err := r.Create(ctx, task); err != nil {
+				t.Fatal(err)
+			}
+			return task
+		}
+		plan := create(inAcme, "Acme plan")
+		secret := create(inGlobex, "Globex secret")
+		if plan.TenantID != acme || secret.TenantID != globex {
+			t.Errorf("created in tenants %q and %q, want each in the tenant of its context", plan.TenantID, secret.TenantID)
+		}
+
+		if got, err := r.GetByID(inAcme, plan.ID); err != nil || got.TenantID != acme {
+			t.Errorf("acme's GetByID of its task = %+v, %v, want it with its tenant", got, err)
+		}
+		if _, err := r.GetByID(inAcme, secret.ID); err == nil {
+			t.Error("acme's GetByID of globex's task found it")
+		}
+		lists := []struct {
+			name  string
+			ctx   context.Context
+			query domain.ListTasksQuery
+			want  []string
+		}{
+			{"acme's, whatever the query", inAcme, domain.ListTasksQuery{OwnerID: 1, Tags: []string{"shared"}}, []string{"Acme plan"}},
+			{"globex's", inGlobex, domain.ListTasksQuery{}, []string{"Globex secret"}},
+			{"every tenant's", domain.WithTenant(bg, domain.AllTenants), domain.ListTasksQuery{}, []string{"Globex secret", "Acme plan"}},
+		}
+		for _, tt := range lists {
+			if got := tenantTitles(t, r, tt.ctx, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List of %s = %q, want %q", tt.name, got, tt.want)
+			}
+		}
+
+		stolen := *secret
+		stolen.Title = "Taken over"
+		if err := r.Update(inAcme, &stolen); !errors.Is(err, domain.ErrVersionConflict) {
+			t.Errorf("acme's Update of globex's task = %v, want %v", err, domain.ErrVersionConflict)
+		}
+		r.Delete(inAcme, secret.ID)
+		if got, err := r.GetByID(inGlobex, secret.ID); err != nil || got.Title != "Globex secret" || !reflect.DeepEqual(got.Tags, []string{"shared"}) {
+			t.Errorf("globex's task after acme's Update and Delete = %+v, %v, want it unchanged, tags and all", got, err)
+		}
+	})
+}
+
+func TestArchiveTenantIsolation(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		bg := context.Background()
+		archive := r.(domain.TaskArchive)
+		inAcme, inGlobex := domain.WithTenant(bg, acme), domain.WithTenant(bg, globex)
+		everyone := domain.WithTenant(bg, domain.AllTenants)
+		var ids []int64
+		for _, ctx := range []context.Context{inAcme, inGlobex} {
+			task, err := domain.NewTask(1, string(domain.TenantOf(ctx)), "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.MarkAsCompleted(time.Now())
+			task.UpdatedAt = time.Now().Add(-time.Hour)
+			if err := r.Create(ctx, task); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, task.ID)
+		}
+
+		archived, err := archive.Archive(inAcme, time.Now(), time.Now(), 10)
+		if got := archivedTitles(archived); err != nil || !reflect.DeepEqual(got, []string{"acme"}) {
+			t.Errorf("acme's Archive = %q, %v, want its completed task only", got, err)
+		}
+		if listed, err := archive.ListArchived(inGlobex, 0); err != nil || len(listed) != 0 {
+			t.Errorf("globex's ListArchived = %+v, %v, want none of acme's", listed, err)
+		}
+		if listed, err := archive.ListArchived(inAcme, 0); err != nil || len(listed) != 1 || listed[0].ID != ids[0] || listed[0].TenantID != acme {
+			t.Errorf("acme's ListArchived = %+v, %v, want its task, with its tenant", listed, err)
+		}
+
+		archived, err = archive.Archive(everyone, time.Now(), time.Now(), 10)
+		if err != nil || len(archived) != 1 || archived[0].TenantID != globex {
+			t.Errorf("Archive of every tenant = %+v, %v, want globex's task, in its tenant", archived, err)
+		}
+		if listed, err := archive.ListArchived(inGlobex, 0); err != nil || len(listed) != 1 || listed[0].ID != ids[1] {
+			t.Errorf("globex's ListArchived = %+v, %v, want its task", listed, err)
+		}
+	})
+}
+
+// userRepositories runs test against the in-memory and SQLite user repositories
+func userRepositories(t *testing.T, test func(t *testing.T, users domain.UserRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, repository.NewMemoryUserRepository()) })
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "users.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		test(t, repository.NewUserRepository(db))
+	})
+}
+
+// TestUserTenantIsolation registers the same email in both tenants
+func TestUserTenantIsolation(t *testing.T) {
+	userRepositories(t, func(t *testing.T, users domain.UserRepository) {
+		bg := context.Background()
+		inAcme, inGlobex := domain.WithTenant(bg, acme), domain.WithTenant(bg, globex)
+		register := func(ctx context.Context, email string) (*domain.User, error) {
+			user := domain.NewUser(email, "hash")
+			return user, users.Create(ctx, user)
+		}
+		ann, err := register(inAcme, "ann@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		annAtGlobex, err := register(inGlobex, "ann@example.com")
+		if err != nil || annAtGlobex.ID == ann.ID || annAtGlobex.TenantID != globex {
+			t.Fatalf("registering Ann's email in globex = %+v, %v, want another user, in globex", annAtGlobex, err)
+		}
+		if _, err := register(inAcme, "ann@example.com"); !errors.Is(err, domain.ErrEmailTaken) {
+			t.Errorf("registering it in acme again = %v, want %v", err, domain.ErrEmailTaken)
+		}
+		bob, err := register(inGlobex, "bob@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		byEmail := []struct {
+			name  string
+			ctx   context.Context
+			email string
+			want  int64
+		}{
+			{"acme's Ann", inAcme, "ann@example.com", ann.ID},
+			{"globex's Ann", inGlobex, "ann@example.com", annAtGlobex.ID},
+			{"globex's Bob, from acme", inAcme, "bob@example.com", 0},
+		}
+		for _, tt := range byEmail {
+			got, err := users.GetByEmail(tt.ctx, tt.email)
+			if tt.want == 0 && !errors.Is(err, domain.ErrUserNotFound) || tt.want != 0 && (err != nil || got.ID != tt.want) {
+				t.Errorf("GetByEmail of %s = %+v, %v, want user %d", tt.name, got, err, tt.want)
+			}
+		}
+		if _, err := users.GetByID(inAcme, bob.ID); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("acme's GetByID of globex's Bob = %v, want %v", err, domain.ErrUserNotFound)
+		}
+		if found, err := users.GetByIDs(inAcme, []int64{ann.ID, annAtGlobex.ID, bob.ID}); err != nil || len(found) != 1 || found[0].ID != ann.ID {
+			t.Errorf("acme's GetByIDs of both tenants' users = %+v, %v, want its Ann only", found, err)
+		}
+		if list, err := users.List(inGlobex); err != nil || len(list) != 2 || list[0].ID != annAtGlobex.ID || list[1].ID != bob.ID {
+			t.Errorf("globex's List = %+v, %v, want its two users", list, err)
+		}
+		if err := users.SetRole(inAcme, bob.ID, domain.RoleAdmin); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("acme's SetRole of globex's Bob = %v, want %v", err, domain.ErrUserNotFound)
+		}
+		if got, _ := users.GetByID(inGlobex, bob.ID); got.Role != domain.RoleUser {
+			t.Errorf("Bob's role = %q, want him still a user", got.Role)
+		}
+	})
+}
+
+func TestTenantMigrationOnExistingDatabase(t *testing.T) {
+	bg := context.Background()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sqlx.Open(infrastructure.DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := infrastructure.Migrate(legacy, infrastructure.SchemaArchive); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	result, err := legacy.Exec(`INSERT INTO users (email, password_hash, role, created_at) VALUES (?, ?, ?, ?)`,
+		"old@example.com", "hash", "admin", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID, _ := result.LastInsertId()
+	if _, err := legacy.Exec(`INSERT INTO tasks (owner_id, title, details, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		oldID, "From before tenants", "", now, now); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	db, err := infrastructure.OpenDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if applied, _ := infrastructure.AppliedMigrations(db); !applied[infrastructure.SchemaTenants] {
+		t.Error("tenant_id was not added at startup")
+	}
+
+	users := repository.NewUserRepository(db)
+	old, err := users.GetByEmail(bg, "old@example.com")
+	if err != nil || old.ID != oldID || old.TenantID != domain.DefaultTenant || old.Role != domain.RoleAdmin {
+		t.Errorf("the existing account = %+v, %v, want its ID and role kept, in the default tenant", old, err)
+	}
+	tasks := repository.NewTaskRepositoryWithColumns(db, repository.DetailsOnly)
+	list, err := tasks.List(bg, domain.ListTasksQuery{})
+	if err != nil || len(list) != 1 || list[0].Title != "From before tenants" || list[0].TenantID != domain.DefaultTenant {
+		t.Errorf("the existing tasks = %+v, %v, want the one task, in the default tenant", list, err)
+	}
+	if got := tenantTitles(t, tasks, domain.WithTenant(bg, acme), domain.ListTasksQuery{}); len(got) != 0 {
+		t.Errorf("acme lists %q, want none of the default tenant's tasks", got)
+	}
+
+	again := domain.NewUser("old@example.com", "hash")
+	if err := users.Create(domain.WithTenant(bg, acme), again); err != nil || again.ID <= oldID {
+		t.Errorf("registering the existing email in acme = %v, want a new user", err)
+	}
+	if err := users.Create(bg, domain.NewUser("old@example.com", "hash")); !errors.Is(err, domain.ErrEmailTaken) {
+		t.Errorf("registering it in the default tenant again = %v, want %v", err, domain.ErrEmailTaken)
+	}
+}
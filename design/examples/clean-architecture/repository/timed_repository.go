@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// QueryObserver is told how long each repository operation took and how it
+// ended. repository names the store, such as tasks; operation is the
+// method, such as GetByID.
+type QueryObserver func(repository, operation string, took time.Duration, err error)
+
+// TimedTaskRepository times every operation of the domain.TaskRepository it
+// wraps, whichever that is, and reports it to an observer such as
+// infrastructure.Metrics.ObserveQuery
+type TimedTaskRepository struct {
+	repo    domain.TaskRepository
+	observe QueryObserver
+}
+
+func NewTimedTaskRepository(repo domain.TaskRepository, observe QueryObserver) *TimedTaskRepository {
+	return &TimedTaskRepository{repo: repo, observe: observe}
+}
+
+// time reports an operation that started at start and ended with err
+func (r *TimedTaskRepository) time(operation string, start time.Time, err error) {
+	r.observe("tasks", operation, time.Since(start), err)
+}
+
+func (r *TimedTaskRepository) Create(ctx context.Context, task *domain.Task) error {
+	start := time.Now()
+	err := r.repo.Create(ctx, task)
+	r.time("Create", start, err)
+	return err
+}
+
+func (r *TimedTaskRepository) CreateBatch(ctx context.Context, tasks []*domain.Task) error {
+	start := time.Now()
+	err := r.repo.CreateBatch(ctx, tasks)
+	r.time("CreateBatch", start, err)
+	return err
+}
+
+func (r *TimedTaskRepository) GetByID(ctx context.Context, id int64) (*domain.Task, error) {
+	start := time.Now()
+	task, err := r.repo.GetByID(ctx, id)
+	r.time("GetByID", start, err)
+	return task, err
+}
+
+func (r *TimedTaskRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Task, error) {
+	start := time.Now()
+	tasks, err := r.repo.GetByIDs(ctx, ids)
+	r.time("GetByIDs", start, err)
+	return tasks, err
+}
+
+func (r *TimedTaskRepository) List(ctx context.Context, query domain.ListTasksQuery) ([]*domain.Task, error) {
+	start := time.Now()
+	tasks, err := r.repo.List(ctx, query)
+	r.time("List", start, err)
+	return tasks, err
+}
+
+func (r *TimedTaskRepository) Update(ctx context.Context, task *domain.Task) error {
+	start := time.Now()
+	err := r.repo.Update(ctx, task)
+	r.time("Update", start, err)
+	return err
+}
+
+func (r *TimedTaskRepository) Delete(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := r.repo.Delete(ctx, id)
+	r.time("Delete", start, err)
+	return err
+}
+
+// TimedUserRepository is TimedTaskRepository for a domain.UserRepository
+type TimedUserRepository struct {
+	repo    domain.UserRepository
+	observe QueryObserver
+}
+
+func NewTimedUserRepository(repo domain.UserRepository, observe QueryObserver) *TimedUserRepository {
+	return &TimedUserRepository{repo: repo, observe: observe}
+}
+
+func (r *TimedUserRepository) time(operation string, start time.Time, err error) {
+	r.observe("users", operation, time.Since(start), err)
+}
+
+func (r *TimedUserRepository) Create(ctx context.Context, user *domain.User) error {
+	start := time.Now()
+	err := r.repo.Create(ctx, user)
+	r.time("Create", start, err)
+	return err
+}
+
+func (r *TimedUserRepository) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	start := time.Now()
+	user, err := r.repo.GetByID(ctx, id)
+	r.time("GetByID", start, err)
+	return user, err
+}
+
+func (r *TimedUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	start := time.Now()
+	user, err := r.repo.GetByEmail(ctx, email)
+	r.time("GetByEmail", start, err)
+	return user, err
+}
+
+func (r *TimedUserRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.User, error) {
+	start := time.Now()
+	users, err := r.repo.GetByIDs(ctx, ids)
+	r.time("GetByIDs", start, err)
+	return users, err
+}
+
+func (r *TimedUserRepository) List(ctx context.Context) ([]*domain.User, error) {
+	start := time.Now()
+	users, err := r.repo.List(ctx)
+	r.time("List", start, err)
+	return users, err
+}
+
+func (r *TimedUserRepository) SetRole(ctx context.Context, id int64, role domain.Role) error {
+	start := time.Now()
+	err := r.repo.SetRole(ctx, id, role)
+	r.time("SetRole", start, err)
+	return err
+}
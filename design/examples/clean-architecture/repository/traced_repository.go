@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/dong-tran/docs/clean-architecture-example/repository")
+
+// startSpan starts the client span of a repository operation, such as
+// TaskRepository.GetByID, as a child of the use case's
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TracedTaskRepository runs every operation of the domain.TaskRepository it
+// wraps, whichever that is, in a span of its own
+type TracedTaskRepository struct {
+	repo domain.TaskRepository
+}
+
+func NewTracedTaskRepository(repo domain.TaskRepository) *TracedTaskRepository {
+	return &TracedTaskRepository{repo: repo}
+}
+
+func (r *TracedTaskRepository) Create(ctx context.Context, task *domain.Task) error {
+	ctx, span := startSpan(ctx, "TaskRepository.Create")
+	err := r.repo.Create(ctx, task)
+	span.SetAttributes(attribute.Int64("task.id", task.ID))
+	endSpan(span, err)
+	return err
+}
+
+func (r *TracedTaskRepository) CreateBatch(ctx context.Context, tasks []*domain.Task) error {
+	ctx, span := startSpan(ctx, "TaskRepository.CreateBatch", attribute.Int("tasks.count", len(tasks)))
+	err := r.repo.CreateBatch(ctx, tasks)
+	endSpan(span, err)
+	return err
+}
+
+func (r *TracedTaskRepository) GetByID(ctx context.Context, id int64) (*domain.Task, error) {
+	ctx, span := startSpan(ctx, "TaskRepository.GetByID", attribute.Int64("task.id", id))
+	task, err := r.repo.GetByID(ctx, id)
+	endSpan(span, err)
+	return task, err
+}
+
+func (r *TracedTaskRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Task, error) {
+	ctx, span := startSpan(ctx, "TaskRepository.GetByIDs", attribute.Int("tasks.count", len(ids)))
+	tasks, err := r.repo.GetByIDs(ctx, ids)
+	endSpan(span, err)
+	return tasks, err
+}
+
+func (r *TracedTaskRepository) List(ctx context.Context, query domain.ListTasksQuery) ([]*domain.Task, error) {
+	ctx, span := startSpan(ctx, "TaskRepository.List")
+	tasks, err := r.repo.List(ctx, query)
+	span.SetAttributes(attribute.Int("tasks.count", len(tasks)))
+	endSpan(span, err)
+	return tasks, err
+}
+
+func (r *TracedTaskRepository) Update(ctx context.Context, task *domain.Task) error {
+	ctx, span := startSpan(ctx, "TaskRepository.Update", attribute.Int64("task.id", task.ID))
+	err := r.repo.Update(ctx, task)
+	endSpan(span, err)
+	return err
+}
+
+func (r *TracedTaskRepository) Delete(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "TaskRepository.Delete", attribute.Int64("task.id", id))
+	err := r.repo.Delete(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+// TracedUserRepository is TracedTaskRepository for a domain.UserRepository
+type TracedUserRepository struct {
+	repo domain.UserRepository
+}
+
+func NewTracedUserRepository(repo domain.UserRepository) *TracedUserRepository {
+	return &TracedUserRepository{repo: repo}
+}
+
+func (r *TracedUserRepository) Create(ctx context.Context, user *domain.User) error {
+	ctx, span := startSpan(ctx, "UserRepository.Create")
+	err := r.repo.Create(ctx, user)
+	endSpan(span, err)
+	return err
+}
+
+func (r *TracedUserRepository) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetByID", attribute.Int64("user.id", id))
+	user, err := r.repo.GetByID(ctx, id)
+	endSpan(span, err)
+	return user, err
+}
+
+func (r *TracedUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetByEmail")
+	user, err := r.repo.GetByEmail(ctx, email)
+	endSpan(span, err)
+	return user, err
+}
+
+func (r *TracedUserRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetByIDs", attribute.Int("users.count", len(ids)))
+	users, err := r.repo.GetByIDs(ctx, ids)
+	endSpan(span, err)
+	return users, err
+}
+
+func (r *TracedUserRepository) List(ctx context.Context) ([]*domain.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.List")
+	users, err := r.repo.List(ctx)
+	endSpan(span, err)
+	return users, err
+}
+
+func (r *TracedUserRepository) SetRole(ctx context.Context, id int64, role domain.Role) error {
+	ctx, span := startSpan(ctx, "UserRepository.SetRole", attribute.Int64("user.id", id))
+	err := r.repo.SetRole(ctx, id, role)
+	endSpan(span, err)
+	return err
+}
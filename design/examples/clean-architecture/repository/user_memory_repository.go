@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// MemoryUserRepository is a domain.UserRepository kept in memory, for tests
+// and examples that run without a database. Each method checks ctx before it
+// starts; none of them blocks. Like UserRepositoryImpl, it only sees the
+// users of the tenant of ctx, and an email is unique within a tenant.
+type MemoryUserRepository struct {
+	mu      sync.RWMutex
+	byID    map[int64]domain.User
+	byEmail map[tenantEmail]int64
+	nextID  int64
+}
+
+type tenantEmail struct {
+	tenant domain.TenantID
+	email  string
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{byID: map[int64]domain.User{}, byEmail: map[tenantEmail]int64{}}
+}
+
+// visible returns the user with id if the tenant of ctx includes it
+func (r *MemoryUserRepository) visible(ctx context.Context, id int64) (domain.User, bool) {
+	user, ok := r.byID[id]
+	return user, ok && domain.TenantOf(ctx).Includes(user.TenantID)
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *domain.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.TenantID = tenantFor(ctx, user.TenantID)
+	key := tenantEmail{user.TenantID, user.Email}
+	if _, taken := r.byEmail[key]; taken {
+		return domain.ErrEmailTaken
+	}
+	r.nextID++
+	user.ID = r.nextID
+	r.byID[user.ID] = *user
+	r.byEmail[key] = user.ID
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.visible(ctx, id)
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenant := domain.TenantOf(ctx)
+	if tenant == domain.AllTenants {
+		for _, user := range r.byID {
+			if user.Email == email {
+				return &user, nil
+			}
+		}
+		return nil, domain.ErrUserNotFound
+	}
+	id, ok := r.byEmail[tenantEmail{tenant, email}]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.byID[id]
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := make([]*domain.User, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if user, ok := r.visible(ctx, id); ok && !seen[id] {
+			seen[id] = true
+			users = append(users, &user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context) ([]*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenant := domain.TenantOf(ctx)
+	users := make([]*domain.User, 0, len(r.byID))
+	for _, user := range r.byID {
+		if tenant.Includes(user.TenantID) {
+			user := user
+			users = append(users, &user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *MemoryUserRepository) SetRole(ctx context.Context, id int64, role domain.Role) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.visible(ctx, id)
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	user.Role = role
+	r.byID[id] = user
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type UserRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewUserRepository(db *sqlx.DB) domain.UserRepository {
+	return &UserRepositoryImpl{db: db}
+}
+
+type userRow struct {
+	ID           int64     `db:"id"`
+	Email        string    `db:"email"`
+	PasswordHash string    `db:"password_hash"`
+	Role         string    `db:"role"`
+	CreatedAt    time.Time `db:"created_at"`
+	TenantID     string    `db:"tenant_id"`
+}
+
+const userColumns = "id, email, password_hash, role, created_at, tenant_id"
+
+func (r userRow) toDomain() *domain.User {
+	return &domain.User{ID: r.ID, Email: r.Email, PasswordHash: r.PasswordHash, Role: domain.Role(r.Role), CreatedAt: r.CreatedAt, TenantID: domain.TenantID(r.TenantID)}
+}
+
+// Create relies on the UNIQUE constraint on (users.tenant_id, users.email),
+// so two concurrent registrations of one address in a tenant cannot both
+// succeed
+func (r *UserRepositoryImpl) Create(ctx context.Context, user *domain.User) error {
+	var id int64
+	user.TenantID = tenantFor(ctx, user.TenantID)
+	err := r.db.QueryRowxContext(ctx, r.db.Rebind(`
+		INSERT INTO users (email, password_hash, role, created_at, tenant_id)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`), user.Email, user.PasswordHash, string(user.Role), user.CreatedAt, string(user.TenantID)).Scan(&id)
+	if isUniqueViolation(err) {
+		return domain.ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	user.ID = id
+	return nil
+}
+
+func (r *UserRepositoryImpl) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	return r.get(ctx, `WHERE id = ?`, id)
+}
+
+func (r *UserRepositoryImpl) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return r.get(ctx, `WHERE email = ?`, email)
+}
+
+func (r *UserRepositoryImpl) get(ctx context.Context, where string, arg interface{}) (*domain.User, error) {
+	tenant, args := andTenant(ctx)
+	var row userRow
+	err := r.db.GetContext(ctx, &row, r.db.Rebind(`SELECT `+userColumns+` FROM users `+where+tenant), append([]interface{}{arg}, args...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.toDomain(), nil
+}
+
+// userQueryBatch keeps the IN list of GetByIDs under SQLite's limit on bound
+// parameters, as tagQueryBatch does
+const userQueryBatch = tagQueryBatch
+
+func (r *UserRepositoryImpl) GetByIDs(ctx context.Context, ids []int64) ([]*domain.User, error) {
+	tenant, tenantArgs := andTenant(ctx)
+	var rows []userRow
+	for start := 0; start < len(ids); start += userQueryBatch {
+		query, args, err := sqlx.In(`SELECT `+userColumns+` FROM users WHERE id IN (?)`+tenant,
+			append([]interface{}{ids[start:min(start+userQueryBatch, len(ids))]}, tenantArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		var batch []userRow
+		if err := r.db.SelectContext(ctx, &batch, r.db.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+		rows = append(rows, batch...)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	users := make([]*domain.User, len(rows))
+	for i, row := range rows {
+		users[i] = row.toDomain()
+	}
+	return users, nil
+}
+
+func (r *UserRepositoryImpl) List(ctx context.Context) ([]*domain.User, error) {
+	tenant, args := andTenant(ctx)
+	var rows []userRow
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(`SELECT `+userColumns+` FROM users WHERE 1 = 1`+tenant+` ORDER BY id`), args...); err != nil {
+		return nil, err
+	}
+	users := make([]*domain.User, len(rows))
+	for i, row := range rows {
+		users[i] = row.toDomain()
+	}
+	return users, nil
+}
+
+func (r *UserRepositoryImpl) SetRole(ctx context.Context, id int64, role domain.Role) error {
+	tenant, args := andTenant(ctx)
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE users SET role = ? WHERE id = ?`+tenant), append([]interface{}{string(role), id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
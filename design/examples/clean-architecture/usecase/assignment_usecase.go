@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// A task's owner may assign it to any registered user, themselves included,
+// and unassign it. The assignee may view the task (see canView) but not
+// change it; a completed task cannot be assigned (domain.Task.Assign).
+
+var ErrUnknownAssignee = domain.NewError("TASK_ASSIGNEE_UNKNOWN", domain.KindInvalid, "the assignee is not a registered user")
+
+type AssignmentUseCase struct {
+	tasks *TaskUseCase
+	users domain.UserRepository
+}
+
+// NewAssignmentUseCase returns use cases assigning the tasks tasks looks up
+// and authorizes to the users in users
+func NewAssignmentUseCase(tasks *TaskUseCase, users domain.UserRepository) *AssignmentUseCase {
+	return &AssignmentUseCase{tasks: tasks, users: users}
+}
+
+// AssignTask assigns a task the actor may change to the user with
+// assigneeID. Like UpdateTask, a version other than 0 must be the task's.
+func (uc *AssignmentUseCase) AssignTask(ctx context.Context, actor *domain.User, id, assigneeID, version int64) (_ *domain.Task, err error) {
+	ctx, span := startSpan(ctx, "AssignmentUseCase.AssignTask", actorAttr(actor.ID), taskAttr(id))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	task, err := uc.tasks.modifiable(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && version != task.Version {
+		return nil, domain.ErrVersionConflict
+	}
+	if _, err := uc.users.GetByID(ctx, assigneeID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, ErrUnknownAssignee
+		}
+		return nil, err
+	}
+	if err := task.Assign(assigneeID, uc.tasks.clock.Now()); err != nil {
+		return nil, err
+	}
+	return uc.store(ctx, task)
+}
+
+// UnassignTask leaves a task the actor may change assigned to nobody
+func (uc *AssignmentUseCase) UnassignTask(ctx context.Context, actor *domain.User, id, version int64) (_ *domain.Task, err error) {
+	ctx, span := startSpan(ctx, "AssignmentUseCase.UnassignTask", actorAttr(actor.ID), taskAttr(id))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	task, err := uc.tasks.modifiable(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && version != task.Version {
+		return nil, domain.ErrVersionConflict
+	}
+	task.Unassign(uc.tasks.clock.Now())
+	return uc.store(ctx, task)
+}
+
+func (uc *AssignmentUseCase) store(ctx context.Context, task *domain.Task) (*domain.Task, error) {
+	if err := uc.tasks.taskRepo.Update(ctx, task); err != nil {
+		return nil, err
+	}
+	uc.tasks.publish(ctx, domain.TaskUpdated, task)
+	return task, nil
+}
+
+// ListAssignedTasks returns the tasks assigned to the actor that match
+// query, newest first, whoever owns them. query.OwnerID is ignored.
+func (uc *AssignmentUseCase) ListAssignedTasks(ctx context.Context, actor *domain.User, query domain.ListTasksQuery) (_ []*domain.Task, err error) {
+	ctx, span := startSpan(ctx, "AssignmentUseCase.ListAssignedTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	query, err = query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	query.OwnerID, query.AssigneeID = 0, actor.ID
+	return uc.tasks.taskRepo.List(ctx, query)
+}
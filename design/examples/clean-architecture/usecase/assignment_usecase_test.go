@@ -0,0 +1,148 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// register creates a user in users
+func register(t *testing.T, users domain.UserRepository, email string, role domain.Role) *domain.User {
+	t.Helper()
+	user := domain.NewUser(email, "hash")
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	if role != domain.RoleUser {
+		if err := users.SetRole(context.Background(), user.ID, role); err != nil {
+			t.Fatal(err)
+		}
+		user.Role = role
+	}
+	return user
+}
+
+func taskTitles(tasks []*domain.Task) []string {
+	out := []string{}
+	for _, task := range tasks {
+		out = append(out, task.Title)
+	}
+	return out
+}
+
+func TestAssignment(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	alice := register(t, users, "alice@example.com", domain.RoleUser)
+	bob := register(t, users, "bob@example.com", domain.RoleUser)
+	carol := register(t, users, "carol@example.com", domain.RoleUser)
+	admin := register(t, users, "admin@example.com", domain.RoleAdmin)
+	tasks := usecase.NewTaskUseCase(repository.NewMemoryTaskRepository())
+	assignments := usecase.NewAssignmentUseCase(tasks, users)
+
+	report, err := tasks.CreateTask(ctx, alice, usecase.CreateTaskInput{Title: "Write the report", Tags: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tasks.CreateTask(ctx, alice, usecase.CreateTaskInput{Title: "Buy milk"}); err != nil {
+		t.Fatal(err)
+	}
+	slides, err := tasks.CreateTask(ctx, carol, usecase.CreateTaskInput{Title: "Make the slides"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assigned, err := assignments.AssignTask(ctx, alice, report.ID, bob.ID, report.Version)
+	if err != nil || assigned.AssigneeID != bob.ID || assigned.Version != 2 {
+		t.Fatalf("Alice assigning her task to Bob = %+v, %v, want it assigned as version 2", assigned, err)
+	}
+	if _, err := assignments.AssignTask(ctx, carol, slides.ID, bob.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	refused := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"assigning from a stale version", func() error {
+			_, err := assignments.AssignTask(ctx, alice, report.ID, carol.ID, 1)
+			return err
+		}, domain.ErrVersionConflict},
+		{"assigning to a user who does not exist", func() error {
+			_, err := assignments.AssignTask(ctx, alice, report.ID, 99, 0)
+			return err
+		}, usecase.ErrUnknownAssignee},
+		{"Carol assigning it to herself, who cannot see it", func() error {
+			_, err := assignments.AssignTask(ctx, carol, report.ID, carol.ID, 0)
+			return err
+		}, usecase.ErrTaskNotFound},
+		{"an admin, who sees it but does not own it, assigning it", func() error {
+			_, err := assignments.AssignTask(ctx, admin, report.ID, admin.ID, 0)
+			return err
+		}, usecase.ErrForbidden},
+		{"the assignee changing it", func() error {
+			_, err := tasks.UpdateTask(ctx, bob, usecase.UpdateTaskInput{ID: report.ID, Title: "Bob's report"})
+			return err
+		}, usecase.ErrForbidden},
+		{"the assignee unassigning himself", func() error {
+			_, err := assignments.UnassignTask(ctx, bob, report.ID, 0)
+			return err
+		}, usecase.ErrForbidden},
+		{"the assignee completing it", func() error {
+			_, err := tasks.CompleteTask(ctx, bob, report.ID)
+			return err
+		}, usecase.ErrForbidden},
+		{"listing an empty date range", func() error {
+			_, err := assignments.ListAssignedTasks(ctx, bob, domain.ListTasksQuery{CreatedFrom: time.Now(), CreatedBefore: time.Now().Add(-time.Hour)})
+			return err
+		}, domain.ErrInvalidDateRange},
+	}
+	for _, tt := range refused {
+		if err := tt.call(); !errors.Is(err, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if got, err := tasks.GetTask(ctx, bob, report.ID); err != nil || got.AssigneeID != bob.ID {
+		t.Errorf("Bob getting the task assigned to him = %v, want it", err)
+	}
+	if mine, err := tasks.ListTasks(ctx, bob, domain.ListTasksQuery{}); err != nil || len(mine) != 0 {
+		t.Errorf("Bob's own listing = %q, %v, want only the tasks he owns: none", taskTitles(mine), err)
+	}
+	listings := []struct {
+		name  string
+		actor *domain.User
+		query domain.ListTasksQuery
+		want  []string
+	}{
+		{"Bob's assigned tasks, newest first", bob, domain.ListTasksQuery{}, []string{"Make the slides", "Write the report"}},
+		{"filtered like any listing, and never by owner", bob, domain.ListTasksQuery{Tags: []string{"work"}, OwnerID: carol.ID}, []string{"Write the report"}},
+		{"Alice's, who has none", alice, domain.ListTasksQuery{}, []string{}},
+	}
+	for _, tt := range listings {
+		list, err := assignments.ListAssignedTasks(ctx, tt.actor, tt.query)
+		if err != nil || !reflect.DeepEqual(taskTitles(list), tt.want) {
+			t.Errorf("%s = %q, %v, want %q", tt.name, taskTitles(list), err, tt.want)
+		}
+	}
+
+	if _, err := tasks.CompleteTask(ctx, alice, report.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := assignments.AssignTask(ctx, alice, report.ID, carol.ID, 0); !errors.Is(err, domain.ErrAssignCompleted) {
+		t.Errorf("assigning the completed task to Carol = %v, want %v", err, domain.ErrAssignCompleted)
+	}
+	if unassigned, err := assignments.UnassignTask(ctx, alice, report.ID, 0); err != nil || unassigned.AssigneeID != 0 {
+		t.Errorf("unassigning it = %+v, %v, want it assigned to nobody", unassigned, err)
+	}
+	if _, err := tasks.GetTask(ctx, bob, report.ID); !errors.Is(err, usecase.ErrTaskNotFound) {
+		t.Errorf("Bob getting it once unassigned = %v, want %v", err, usecase.ErrTaskNotFound)
+	}
+}
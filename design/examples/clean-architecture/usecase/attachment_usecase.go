@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Attachments follow the policy of their task: whoever may view the task
+// may list and download them, and whoever may change it may upload and
+// delete them. An upload is checked as it streams to the storage: its type
+// against AttachmentTypes and against what its first bytes look like, and
+// its size against MaxAttachmentSize, so content is never held whole.
+
+// MaxAttachmentSize is the most bytes an attachment can have, 10 MiB
+const MaxAttachmentSize = 10 << 20
+
+// AttachmentTypes are the media types an attachment can have
+var AttachmentTypes = []string{
+	"application/pdf", "image/gif", "image/jpeg", "image/png", "image/webp", "text/csv", "text/plain",
+}
+
+var (
+	ErrAttachmentTooLarge   = domain.NewError("ATTACHMENT_TOO_LARGE", domain.KindInvalid, "an attachment cannot exceed 10 MiB")
+	ErrAttachmentType       = domain.NewError("ATTACHMENT_TYPE_NOT_ALLOWED", domain.KindInvalid, "an attachment must be a PDF, a GIF, JPEG, PNG or WebP image, or CSV or plain text")
+	ErrAttachmentMismatched = domain.NewError("ATTACHMENT_CONTENT_MISMATCH", domain.KindInvalid, "attachment content is not of its declared type")
+)
+
+type AttachmentUseCase struct {
+	tasks   *TaskUseCase
+	storage domain.AttachmentStorage
+}
+
+// NewAttachmentUseCase returns use cases keeping attachments in storage, of
+// the tasks tasks looks up and authorizes
+func NewAttachmentUseCase(tasks *TaskUseCase, storage domain.AttachmentStorage) *AttachmentUseCase {
+	return &AttachmentUseCase{tasks: tasks, storage: storage}
+}
+
+type UploadAttachmentInput struct {
+	TaskID      int64
+	Name        string
+	ContentType string // as the client declared it; parameters are ignored
+	Size        int64  // as the client declared it, -1 if unknown
+	Content     io.Reader
+}
+
+// UploadAttachment stores an attachment of a task the actor may change,
+// replacing any of the same name
+func (uc *AttachmentUseCase) UploadAttachment(ctx context.Context, actor *domain.User, input UploadAttachmentInput) (_ domain.Attachment, err error) {
+	ctx, span := startSpan(ctx, "AttachmentUseCase.UploadAttachment", actorAttr(actor.ID), taskAttr(input.TaskID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if err := domain.ValidateAttachmentName(input.Name); err != nil {
+		return domain.Attachment{}, err
+	}
+	contentType, err := attachmentType(input.ContentType)
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	if input.Size > MaxAttachmentSize {
+		return domain.Attachment{}, ErrAttachmentTooLarge
+	}
+	if _, err := uc.tasks.modifiable(ctx, actor, input.TaskID); err != nil {
+		return domain.Attachment{}, err
+	}
+
+	content := bufio.NewReaderSize(input.Content, sniffLength)
+	head, err := content.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return domain.Attachment{}, err
+	}
+	if !looksLike(contentType, head) {
+		return domain.Attachment{}, ErrAttachmentMismatched
+	}
+	return uc.storage.Put(ctx, domain.Attachment{
+		TaskID:      input.TaskID,
+		Name:        input.Name,
+		ContentType: contentType,
+		CreatedAt:   uc.tasks.clock.Now(),
+	}, &sizeLimit{r: content, left: MaxAttachmentSize})
+}
+
+// OpenAttachment returns an attachment of a task the actor may view, and its
+// content, which the caller closes
+func (uc *AttachmentUseCase) OpenAttachment(ctx context.Context, actor *domain.User, taskID int64, name string) (_ domain.Attachment, _ io.ReadCloser, err error) {
+	ctx, span := startSpan(ctx, "AttachmentUseCase.OpenAttachment", actorAttr(actor.ID), taskAttr(taskID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if err := domain.ValidateAttachmentName(name); err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	if _, err := uc.tasks.visible(ctx, actor, taskID); err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	return uc.storage.Open(ctx, taskID, name)
+}
+
+// ListAttachments returns the attachments of a task the actor may view, by
+// name
+func (uc *AttachmentUseCase) ListAttachments(ctx context.Context, actor *domain.User, taskID int64) (_ []domain.Attachment, err error) {
+	ctx, span := startSpan(ctx, "AttachmentUseCase.ListAttachments", actorAttr(actor.ID), taskAttr(taskID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if _, err := uc.tasks.visible(ctx, actor, taskID); err != nil {
+		return nil, err
+	}
+	return uc.storage.List(ctx, taskID)
+}
+
+// DeleteAttachment removes an attachment of a task the actor may change
+func (uc *AttachmentUseCase) DeleteAttachment(ctx context.Context, actor *domain.User, taskID int64, name string) (err error) {
+	ctx, span := startSpan(ctx, "AttachmentUseCase.DeleteAttachment", actorAttr(actor.ID), taskAttr(taskID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if err := domain.ValidateAttachmentName(name); err != nil {
+		return err
+	}
+	if _, err := uc.tasks.modifiable(ctx, actor, taskID); err != nil {
+		return err
+	}
+	return uc.storage.Delete(ctx, taskID, name)
+}
+
+// attachmentType returns the media type of a declared Content-Type, if it
+// is one of AttachmentTypes
+func attachmentType(declared string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil || !slices.Contains(AttachmentTypes, mediaType) {
+		return "", ErrAttachmentType
+	}
+	return mediaType, nil
+}
+
+// sniffLength is how many bytes http.DetectContentType looks at
+const sniffLength = 512
+
+// looksLike reports whether content starting with head is of contentType,
+// as far as its first bytes tell. Text of any kind sniffs as text/plain,
+// and markup as text/html, so HTML never passes for text or an image.
+func looksLike(contentType string, head []byte) bool {
+	sniffed, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if strings.HasPrefix(contentType, "text/") {
+		return sniffed == "text/plain"
+	}
+	return sniffed == contentType
+}
+
+// sizeLimit reads r until more than left bytes have been read, then fails
+// with ErrAttachmentTooLarge
+type sizeLimit struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *sizeLimit) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, ErrAttachmentTooLarge
+	}
+	// One byte past the limit is enough to tell
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, ErrAttachmentTooLarge
+	}
+	return n, err
+}
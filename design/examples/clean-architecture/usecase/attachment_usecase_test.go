@@ -0,0 +1,205 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// Content that sniffs as each type
+const (
+	pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	pdfHeader = "%PDF-1.7\n"
+)
+
+var errBroken = errors.New("connection reset")
+
+// brokenOff is content failing after a few bytes, like an upload whose
+// client goes away
+type brokenOff struct{}
+
+func (brokenOff) Read([]byte) (int, error) { return 0, errBroken }
+
+// letters is n bytes of text, made as they are read
+type letters struct{ n int64 }
+
+func (l *letters) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	for i := range p {
+		p[i] = 'a'
+	}
+	l.n -= int64(len(p))
+	return len(p), nil
+}
+
+// untouchable records that it was read
+type untouchable struct{ read *bool }
+
+func (u untouchable) Read([]byte) (int, error) {
+	*u.read = true
+	return 0, io.EOF
+}
+
+// newAttachments returns the use cases on a task of Alice's, task 1
+func newAttachments(t *testing.T) (*usecase.AttachmentUseCase, domain.AttachmentStorage) {
+	t.Helper()
+	tasks := repository.NewMemoryTaskRepository()
+	task, err := domain.NewTask(alice.ID, "Write the report", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tasks.Create(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	storage := repository.NewMemoryAttachmentStorage()
+	return usecase.NewAttachmentUseCase(usecase.NewTaskUseCase(tasks), storage), storage
+}
+
+func upload(attachments *usecase.AttachmentUseCase, actor *domain.User, name, contentType string, size int64, content io.Reader) (domain.Attachment, error) {
+	return attachments.UploadAttachment(context.Background(), actor, usecase.UploadAttachmentInput{
+		TaskID: 1, Name: name, ContentType: contentType, Size: size, Content: content,
+	})
+}
+
+func TestUploadAttachment(t *testing.T) {
+	attachments, _ := newAttachments(t)
+	tests := []struct {
+		contentType, name, content string
+		want                       string
+	}{
+		{"image/png", "chart.png", pngHeader, "image/png"},
+		{"image/jpeg", "photo.jpg", "\xff\xd8\xff\xe0\x00\x10JFIF", "image/jpeg"},
+		{"image/gif", "anim.gif", "GIF89a\x01\x00\x01\x00", "image/gif"},
+		{"image/webp", "scan.webp", "RIFF\x24\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"application/pdf", "report.pdf", pdfHeader, "application/pdf"},
+		{"text/csv", "data.csv", "id,title\n1,Write the report\n", "text/csv"},
+		{"text/plain; charset=utf-8", "notes.txt", "Numbers first", "text/plain"},
+		{"text/plain", "empty.txt", "", "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			a, err := upload(attachments, alice, tt.name, tt.contentType, -1, strings.NewReader(tt.content))
+			if err != nil || a.ContentType != tt.want || a.Size != int64(len(tt.content)) {
+				t.Errorf("UploadAttachment = %+v, %v, want it stored as %s of %d bytes", a, err, tt.want, len(tt.content))
+			}
+		})
+	}
+}
+
+func TestUploadAttachmentRefused(t *testing.T) {
+	attachments, _ := newAttachments(t)
+	tests := []struct {
+		name                       string
+		file, contentType, content string
+		want                       error
+	}{
+		{"HTML", "page.html", "text/html", "<html></html>", usecase.ErrAttachmentType},
+		{"no type", "blob", "", "bytes", usecase.ErrAttachmentType},
+		{"bytes of any kind", "blob.bin", "application/octet-stream", "\x00\x01", usecase.ErrAttachmentType},
+		{"a malformed type", "x.txt", "text/", "text", usecase.ErrAttachmentType},
+		{"HTML declared as text", "page.txt", "text/plain", "<!DOCTYPE html><script>alert(1)</script>", usecase.ErrAttachmentMismatched},
+		{"HTML declared as an image", "page.png", "image/png", "<html><body>", usecase.ErrAttachmentMismatched},
+		{"a PDF declared as an image", "report.png", "image/png", pdfHeader, usecase.ErrAttachmentMismatched},
+		{"an empty name", "", "text/plain", "text", domain.ErrInvalidAttachmentName},
+		{"a name with a slash", "a/b.txt", "text/plain", "text", domain.ErrInvalidAttachmentName},
+		{"a name that is ..", "..", "text/plain", "text", domain.ErrInvalidAttachmentName},
+		{"a name with a newline", "a\nb.txt", "text/plain", "text", domain.ErrInvalidAttachmentName},
+		{"a name of 256 bytes", strings.Repeat("n", 256), "text/plain", "text", domain.ErrInvalidAttachmentName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := upload(attachments, alice, tt.file, tt.contentType, -1, strings.NewReader(tt.content)); !errors.Is(err, tt.want) {
+				t.Errorf("UploadAttachment = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadAttachmentSize(t *testing.T) {
+	ctx := context.Background()
+	attachments, storage := newAttachments(t)
+
+	touched := false
+	if _, err := upload(attachments, alice, "big.txt", "text/plain", usecase.MaxAttachmentSize+1, untouchable{&touched}); !errors.Is(err, usecase.ErrAttachmentTooLarge) || touched {
+		t.Errorf("a declared size over 10 MiB = %v, read %v; want %v before any of it is read", err, touched, usecase.ErrAttachmentTooLarge)
+	}
+	_, err := upload(attachments, alice, "big.txt", "text/plain", -1, &letters{n: usecase.MaxAttachmentSize + 1})
+	if _, _, openErr := storage.Open(ctx, 1, "big.txt"); !errors.Is(err, usecase.ErrAttachmentTooLarge) || !errors.Is(openErr, domain.ErrAttachmentNotFound) {
+		t.Errorf("an undeclared size found over 10 MiB while streaming = %v, and opening it %v; want it refused with nothing stored", err, openErr)
+	}
+	if a, err := upload(attachments, alice, "big.txt", "text/plain", usecase.MaxAttachmentSize, &letters{n: usecase.MaxAttachmentSize}); err != nil || a.Size != usecase.MaxAttachmentSize {
+		t.Errorf("exactly 10 MiB = %d bytes, %v, want it stored", a.Size, err)
+	}
+	if _, err := upload(attachments, alice, "short.txt", "text/plain", -1, io.MultiReader(strings.NewReader("Numb"), brokenOff{})); !errors.Is(err, errBroken) {
+		t.Errorf("an upload that breaks off = %v, want how it broke, %v", err, errBroken)
+	}
+	if list, err := attachments.ListAttachments(ctx, alice, 1); err != nil || len(list) != 1 {
+		t.Errorf("ListAttachments = %d attachments, %v, want only the one of 10 MiB", len(list), err)
+	}
+}
+
+func TestAttachmentPolicy(t *testing.T) {
+	ctx := context.Background()
+	attachments, _ := newAttachments(t)
+	put := func(actor *domain.User, taskID int64) error {
+		_, err := attachments.UploadAttachment(ctx, actor, usecase.UploadAttachmentInput{
+			TaskID: taskID, Name: "notes.txt", ContentType: "text/plain", Size: -1, Content: strings.NewReader("text"),
+		})
+		return err
+	}
+	open := func(actor *domain.User) error {
+		_, content, err := attachments.OpenAttachment(ctx, actor, 1, "notes.txt")
+		if err == nil {
+			content.Close()
+		}
+		return err
+	}
+	list := func(actor *domain.User) error {
+		_, err := attachments.ListAttachments(ctx, actor, 1)
+		return err
+	}
+	remove := func(actor *domain.User) error { return attachments.DeleteAttachment(ctx, actor, 1, "notes.txt") }
+
+	// The steps run in order: the owner's upload is what the others reach for
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"the owner uploads", func() error { return put(alice, 1) }, nil},
+		{"the owner downloads", func() error { return open(alice) }, nil},
+		{"the owner lists", func() error { return list(alice) }, nil},
+		{"an upload to a task that does not exist", func() error { return put(alice, 99) }, usecase.ErrTaskNotFound},
+		{"another user uploading", func() error { return put(bob, 1) }, usecase.ErrTaskNotFound},
+		{"another user downloading", func() error { return open(bob) }, usecase.ErrTaskNotFound},
+		{"another user listing", func() error { return list(bob) }, usecase.ErrTaskNotFound},
+		{"another user deleting", func() error { return remove(bob) }, usecase.ErrTaskNotFound},
+		{"an admin downloading", func() error { return open(admin) }, nil},
+		{"an admin listing", func() error { return list(admin) }, nil},
+		{"an admin uploading", func() error { return put(admin, 1) }, usecase.ErrForbidden},
+		{"an admin deleting", func() error { return remove(admin) }, usecase.ErrForbidden},
+		{"a name never uploaded", func() error {
+			_, _, err := attachments.OpenAttachment(ctx, alice, 1, "missing.txt")
+			return err
+		}, domain.ErrAttachmentNotFound},
+		{"the owner deletes", func() error { return remove(alice) }, nil},
+		{"and it is gone", func() error { return open(alice) }, domain.ErrAttachmentNotFound},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Authentication proves who the caller is; the task use cases then act on
+// that user's tasks only. Passwords and tokens are handled through the two
+// ports below, so the use case knows neither bcrypt nor JWT.
+//
+// An account belongs to the tenant of the request that registered it
+// (domain.TenantOf), and only exists in that tenant: the same email can
+// register in another, and logging in or presenting a token anywhere else
+// fails as for an unknown account.
+
+var (
+	ErrInvalidCredentials = domain.NewError("AUTH_INVALID_CREDENTIALS", domain.KindUnauthenticated, "email or password is incorrect")
+	ErrInvalidToken       = domain.NewError("AUTH_TOKEN_INVALID", domain.KindUnauthenticated, "token is invalid or expired")
+)
+
+// PasswordHasher hashes passwords for storage and checks them at login
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Matches reports whether password is the one hash was made from
+	Matches(hash, password string) bool
+}
+
+// TokenService issues the bearer tokens that identify a user and verifies
+// the ones presented back
+type TokenService interface {
+	Issue(user *domain.User) (token string, expiresAt time.Time, err error)
+	// Verify returns the ID of the user the token was issued to, or an error
+	// if it is malformed, tampered with or expired
+	Verify(token string) (userID int64, err error)
+}
+
+type AuthUseCase struct {
+	users  domain.UserRepository
+	hasher PasswordHasher
+	tokens TokenService
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewAuthUseCase(users domain.UserRepository, hasher PasswordHasher, tokens TokenService) *AuthUseCase {
+	return &AuthUseCase{users: users, hasher: hasher, tokens: tokens}
+}
+
+// Session is a successful login
+type Session struct {
+	User      *domain.User
+	Token     string
+	ExpiresAt time.Time
+}
+
+// Register creates an account
+func (uc *AuthUseCase) Register(ctx context.Context, email, password string) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "AuthUseCase.Register")
+	defer endSpan(span, &err)
+	email, err = domain.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := uc.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+	user := domain.NewUser(email, hash)
+	user.TenantID = domain.TenantOf(ctx)
+	if err := uc.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks the credentials and issues a token. An unknown email and a
+// wrong password fail the same way, and take as long, so a caller cannot
+// tell which accounts exist.
+func (uc *AuthUseCase) Login(ctx context.Context, email, password string) (_ *Session, err error) {
+	ctx, span := startSpan(ctx, "AuthUseCase.Login")
+	defer endSpan(span, &err)
+	user, err := uc.lookup(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		uc.hasher.Matches(uc.dummy(), password)
+		return nil, ErrInvalidCredentials
+	}
+	if !uc.hasher.Matches(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	token, expiresAt, err := uc.tokens.Issue(user)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{User: user, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// lookup returns the user with email, or nil if there is none
+func (uc *AuthUseCase) lookup(ctx context.Context, email string) (*domain.User, error) {
+	email, err := domain.NormalizeEmail(email)
+	if err != nil {
+		return nil, nil
+	}
+	user, err := uc.users.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, nil
+	}
+	return user, err
+}
+
+// dummy is a hash to check passwords against when there is no account, so
+// that a miss costs what a hit does
+func (uc *AuthUseCase) dummy() string {
+	uc.dummyOnce.Do(func() {
+		uc.dummyHash, _ = uc.hasher.Hash("not a password anyone has")
+	})
+	return uc.dummyHash
+}
+
+// Authenticate returns the user a token was issued to. A token for an
+// account that no longer exists, or not in the tenant of ctx, is invalid.
+func (uc *AuthUseCase) Authenticate(ctx context.Context, token string) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "AuthUseCase.Authenticate")
+	defer endSpan(span, &err)
+	id, err := uc.tokens.Verify(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	user, err := uc.users.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !domain.TenantOf(ctx).Includes(user.TenantID) {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
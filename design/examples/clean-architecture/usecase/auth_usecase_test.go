@@ -0,0 +1,190 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newAuth builds the auth use case over users, hashing at the lowest bcrypt
+// cost so the tests run quickly
+func newAuth(t *testing.T, users domain.UserRepository) *usecase.AuthUseCase {
+	t.Helper()
+	tokens, err := infrastructure.NewJWTTokens([]byte("auth-test-secret-at-least-32-bytes!"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return usecase.NewAuthUseCase(users, infrastructure.BcryptHasher{Cost: bcrypt.MinCost}, tokens)
+}
+
+// userRepositories runs test against the in-memory and SQLite user repositories
+func userRepositories(t *testing.T, test func(t *testing.T, users domain.UserRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, repository.NewMemoryUserRepository()) })
+	t.Run("sqlite", func(t *testing.T) { test(t, repository.NewUserRepository(openSQLite(t))) })
+}
+
+func TestRegister(t *testing.T) {
+	userRepositories(t, func(t *testing.T, users domain.UserRepository) {
+		ctx := context.Background()
+		auth := newAuth(t, users)
+		alice, err := auth.Register(ctx, " Alice@Example.com ", "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alice.ID == 0 || alice.Email != "alice@example.com" {
+			t.Errorf("registered %d %q, want a new ID and the email normalized", alice.ID, alice.Email)
+		}
+		if alice.PasswordHash == "correct horse" {
+			t.Error("the password was stored as it is")
+		}
+
+		tests := []struct {
+			name, email, password string
+			want                  error
+		}{
+			{"the same email in another case", "ALICE@example.com", "another password", domain.ErrEmailTaken},
+			{"a malformed email", "not an email", "correct horse", domain.ErrInvalidEmail},
+			{"an email with a display name", "Bob <bob@example.com>", "correct horse", domain.ErrInvalidEmail},
+			{"a password under 8 characters", "bob@example.com", "short", domain.ErrPasswordTooShort},
+			{"a password bcrypt would truncate", "bob@example.com", strings.Repeat("x", domain.MaxPasswordLength+1), domain.ErrPasswordTooLong},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := auth.Register(ctx, tt.email, tt.password); !errors.Is(err, tt.want) {
+					t.Errorf("Register = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestLoginAndAuthenticate(t *testing.T) {
+	userRepositories(t, func(t *testing.T, users domain.UserRepository) {
+		ctx := context.Background()
+		auth := newAuth(t, users)
+		alice, err := auth.Register(ctx, "alice@example.com", "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := auth.Login(ctx, "alice@EXAMPLE.com", "correct horse")
+		if err != nil || session.User.ID != alice.ID || session.Token == "" {
+			t.Fatalf("Login = %+v, %v", session, err)
+		}
+		if user, err := auth.Authenticate(ctx, session.Token); err != nil || user.ID != alice.ID {
+			t.Errorf("Authenticate = %+v, %v, want user %d", user, err, alice.ID)
+		}
+
+		// A wrong password and an unknown email must fail alike
+		if _, err := auth.Login(ctx, "alice@example.com", "wrong horse"); !errors.Is(err, usecase.ErrInvalidCredentials) {
+			t.Errorf("Login with a wrong password = %v", err)
+		}
+		if _, err := auth.Login(ctx, "nobody@example.com", "correct horse"); !errors.Is(err, usecase.ErrInvalidCredentials) {
+			t.Errorf("Login with an unknown email = %v", err)
+		}
+
+		// Signed with the same secret, so only the missing account gives it away
+		tokens, err := infrastructure.NewJWTTokens([]byte("auth-test-secret-at-least-32-bytes!"), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, _, _ := tokens.Issue(&domain.User{ID: 9999})
+		if _, err := auth.Authenticate(ctx, token); !errors.Is(err, usecase.ErrInvalidToken) {
+			t.Errorf("Authenticate for a user that does not exist = %v, want ErrInvalidToken", err)
+		}
+	})
+}
+
+// TestOwnershipIsolation has Bob try every use case on Alice's task, on each
+// repository: the mock-based tables check the calls, this that the stored
+// task really is left alone
+func TestOwnershipIsolation(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		uc := usecase.NewTaskUseCase(r)
+		mine, err := uc.CreateTask(ctx, alice, usecase.CreateTaskInput{Title: "Alice's task"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mine.OwnerID != alice.ID {
+			t.Errorf("the task belongs to %d, want its creator", mine.OwnerID)
+		}
+		if _, err := uc.CreateTask(ctx, bob, usecase.CreateTaskInput{Title: "Bob's task"}); err != nil {
+			t.Fatal(err)
+		}
+
+		attempts := []struct {
+			name string
+			run  func() error
+		}{
+			{"get", func() error { _, err := uc.GetTask(ctx, bob, mine.ID); return err }},
+			{"update", func() error {
+				_, err := uc.UpdateTask(ctx, bob, usecase.UpdateTaskInput{ID: mine.ID, Title: "Taken over"})
+				return err
+			}},
+			{"complete", func() error { _, err := uc.CompleteTask(ctx, bob, mine.ID); return err }},
+			{"delete", func() error { return uc.DeleteTask(ctx, bob, mine.ID) }},
+			{"bulk complete", func() error {
+				result, err := uc.BulkCompleteTasks(ctx, bob, []int64{mine.ID})
+				if err != nil || result.Failed != 1 {
+					return err
+				}
+				return result.Items[0].Err
+			}},
+			{"bulk delete", func() error {
+				result, err := uc.BulkDeleteTasks(ctx, bob, []int64{mine.ID})
+				if err != nil || result.Failed != 1 {
+					return err
+				}
+				return result.Items[0].Err
+			}},
+		}
+		for _, a := range attempts {
+			if err := a.run(); !errors.Is(err, usecase.ErrTaskNotFound) {
+				t.Errorf("%s as another user = %v, want ErrTaskNotFound", a.name, err)
+			}
+		}
+		after, err := uc.GetTask(ctx, alice, mine.ID)
+		if err != nil || after.Title != "Alice's task" || after.Completed {
+			t.Errorf("after Bob's attempts the owner reads %+v, %v", after, err)
+		}
+
+		list, err := uc.ListTasks(ctx, bob, domain.ListTasksQuery{})
+		if err != nil || len(list) != 1 || list[0].OwnerID != bob.ID {
+			t.Errorf("Bob's listing = %d tasks, %v, want only his one", len(list), err)
+		}
+		if _, err := uc.ListTasks(ctx, bob, domain.ListTasksQuery{OwnerID: alice.ID}); !errors.Is(err, usecase.ErrForbidden) {
+			t.Errorf("listing another owner's tasks = %v, want ErrForbidden", err)
+		}
+		created, err := uc.BulkCreateTasks(ctx, bob, []usecase.CreateTaskInput{{Title: "Bulk"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bulk, err := uc.GetTask(ctx, bob, created.Items[0].ID); err != nil || bulk.OwnerID != bob.ID {
+			t.Errorf("a bulk-created task = %+v, %v, want it to belong to the caller", bulk, err)
+		}
+	})
+}
+
+func TestTasksFromBeforeAccountsAreVisibleToNobody(t *testing.T) {
+	db := openSQLite(t)
+	now := time.Now()
+	if _, err := db.Exec(`INSERT INTO tasks (title, description, completed, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		"Written before accounts", "", false, now, now); err != nil {
+		t.Fatal(err)
+	}
+	uc := usecase.NewTaskUseCase(repository.NewTaskRepository(db))
+	for _, user := range []*domain.User{alice, bob} {
+		list, err := uc.ListTasks(context.Background(), user, domain.ListTasksQuery{Search: "before accounts"})
+		if err != nil || len(list) != 0 {
+			t.Errorf("user %d lists %d tasks from before accounts, %v", user.ID, len(list), err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// hangUp cancels the request after its nth GetByID, as a client closing the
+// connection part-way through a use case would
+type hangUp struct {
+	domain.TaskRepository
+	after  int
+	calls  int
+	cancel context.CancelFunc
+}
+
+func (r *hangUp) GetByID(ctx context.Context, id int64) (*domain.Task, error) {
+	task, err := r.TaskRepository.GetByID(ctx, id)
+	if r.calls++; r.calls == r.after {
+		r.cancel()
+	}
+	return task, err
+}
+
+// hungUpAfter returns a use case over r whose context is canceled after
+// the nth GetByID, and that context
+func hungUpAfter(r domain.TaskRepository, n int) (*usecase.TaskUseCase, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return usecase.NewTaskUseCase(&hangUp{TaskRepository: r, after: n, cancel: cancel}), ctx
+}
+
+func TestCanceledPartWay(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		background := context.Background()
+		uc := usecase.NewTaskUseCase(r)
+		created, err := uc.BulkCreateTasks(background, owner, []usecase.CreateTaskInput{
+			{Title: "one"}, {Title: "two"}, {Title: "three"}, {Title: "four"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int64, len(created.Items))
+		for i, item := range created.Items {
+			ids[i] = item.ID
+		}
+
+		t.Run("an update canceled after its lookup stores nothing", func(t *testing.T) {
+			hung, ctx := hungUpAfter(r, 1)
+			if _, err := hung.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: ids[0], Title: "renamed"}); !errors.Is(err, context.Canceled) {
+				t.Errorf("UpdateTask = %v, want context.Canceled", err)
+			}
+			if got, err := uc.GetTask(background, owner, ids[0]); err != nil || got.Title != "one" {
+				t.Errorf("the task reads %+v, %v, want it unchanged", got, err)
+			}
+		})
+
+		t.Run("a lookup after the cancel is context.Canceled, not TASK_NOT_FOUND", func(t *testing.T) {
+			hung, ctx := hungUpAfter(r, 1)
+			if _, err := hung.GetTask(ctx, owner, ids[0]); err != nil {
+				t.Errorf("the lookup that finished before the cancel = %v", err)
+			}
+			if _, err := hung.GetTask(ctx, owner, ids[0]); !errors.Is(err, context.Canceled) {
+				t.Errorf("the next lookup = %v, want context.Canceled", err)
+			}
+		})
+
+		t.Run("bulk complete canceled during its second item leaves the rest undone", func(t *testing.T) {
+			hung, ctx := hungUpAfter(r, 2)
+			result, err := hung.BulkCompleteTasks(ctx, owner, ids)
+			if err != nil || result.Succeeded != 1 {
+				t.Fatalf("BulkCompleteTasks = %+v, %v, want one success", result, err)
+			}
+			for _, item := range result.Items[1:] {
+				if !errors.Is(item.Err, context.Canceled) {
+					t.Errorf("item %d = %v, want context.Canceled", item.ID, item.Err)
+				}
+			}
+			var completed []string
+			for _, id := range ids {
+				if task, err := uc.GetTask(background, owner, id); err == nil && task.Completed {
+					completed = append(completed, task.Title)
+				}
+			}
+			if strings.Join(completed, ",") != "one" {
+				t.Errorf("completed %v, want only the first", completed)
+			}
+		})
+	})
+}
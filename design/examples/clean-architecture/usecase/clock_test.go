@@ -0,0 +1,168 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// The tests below run on a fake clock, so every time they check is known
+// exactly, and none of them sleeps
+
+// clockStart is where every fake clock starts: a Monday morning, on the second
+var clockStart = time.Date(2030, 7, 1, 9, 0, 0, 0, time.UTC)
+
+func TestTasksOnTheClock(t *testing.T) {
+	ctx := context.Background()
+	ann := &domain.User{ID: 1, Role: domain.RoleUser, TenantID: domain.DefaultTenant}
+	clock := clocktest.New(clockStart)
+	tasks := usecase.NewTaskUseCaseWithClock(repository.NewMemoryTaskRepository(), nil, clock)
+
+	due := clockStart.Add(48 * time.Hour)
+	task, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Write the report", DueAt: due})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.CreatedAt.Equal(clockStart) || !task.UpdatedAt.Equal(clockStart) {
+		t.Errorf("created at %v, updated at %v, want both at the clock's time", task.CreatedAt, task.UpdatedAt)
+	}
+	clock.Advance(time.Hour)
+	if task, err = tasks.UpdateTask(ctx, ann, usecase.UpdateTaskInput{ID: task.ID, Title: "Write the annual report"}); err != nil {
+		t.Fatal(err)
+	}
+	if !task.CreatedAt.Equal(clockStart) || !task.UpdatedAt.Equal(clockStart.Add(time.Hour)) {
+		t.Errorf("an hour on, updated: created at %v, updated at %v, want UpdatedAt stamped only", task.CreatedAt, task.UpdatedAt)
+	}
+
+	overdue := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"before its due date", clock.Now(), false},
+		{"at it", due, false},
+		{"a second after", due.Add(time.Second), true},
+	}
+	for _, tt := range overdue {
+		if got := task.Overdue(tt.at); got != tt.want {
+			t.Errorf("Overdue %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	clock.Set(due.Add(time.Second))
+	if task, err = tasks.CompleteTask(ctx, ann, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if task.Overdue(clock.Now()) || !task.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("completed late, Overdue = %v, updated at %v, want not overdue, changed at %v", task.Overdue(clock.Now()), task.UpdatedAt, clock.Now())
+	}
+	plain, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Someday"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Overdue(clockStart.AddDate(10, 0, 0)) {
+		t.Error("a task without a due date is overdue ten years on")
+	}
+
+	if _, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Too late", DueAt: clock.Now().Add(-time.Second)}); !errors.Is(err, domain.ErrDueBeforeCreated) {
+		t.Errorf("creating a task due a second before the clock = %v, want %v", err, domain.ErrDueBeforeCreated)
+	}
+	clock.Set(clockStart.Add(-time.Hour))
+	if _, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Back in time", DueAt: clockStart}); err != nil {
+		t.Errorf("with the clock set back, creating a task due at %v = %v, want it accepted", clockStart, err)
+	}
+}
+
+func TestRemindersOnTheClock(t *testing.T) {
+	ctx := context.Background()
+	ann := &domain.User{ID: 1, Role: domain.RoleUser, TenantID: domain.DefaultTenant}
+	clock := clocktest.New(clockStart)
+	store := repository.NewMemoryTaskRepository()
+	tasks := usecase.NewTaskUseCaseWithClock(store, nil, clock)
+	queue, sender := &fakeQueue{}, &fakeSender{}
+	reminders := usecase.NewReminderService(queue, store, nil, sender, usecase.ReminderOptions{Lead: time.Hour, Clock: clock})
+	// last returns the reminder sent last
+	last := func() domain.Reminder {
+		t.Helper()
+		sent := sender.reminders()
+		if len(sent) == 0 {
+			t.Fatal("no reminder was sent")
+		}
+		return sent[len(sent)-1]
+	}
+
+	due := clockStart.Add(5 * time.Hour)
+	task, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Call the bank", DueAt: due})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reminders.Schedule(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	jobs := queue.enqueued()
+	if len(jobs) != 1 || !jobs[0].RunAt.Equal(due.Add(-time.Hour)) {
+		t.Fatalf("enqueued %+v, want a reminder an hour before the due date", jobs)
+	}
+
+	clock.Set(jobs[0].RunAt)
+	if err := reminders.HandleJob(ctx, jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if reminder := last(); reminder.Overdue || !reminder.DueAt.Equal(due) {
+		t.Errorf("the reminder run when due = %+v, want it not overdue", reminder)
+	}
+	clock.Set(due.Add(time.Minute))
+	if err := reminders.HandleJob(ctx, jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if reminder := last(); !reminder.Overdue {
+		t.Errorf("the reminder run late, as after retries = %+v, want it overdue", reminder)
+	}
+
+	soon, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Reply", DueAt: clock.Now().Add(10 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reminders.Schedule(ctx, soon); err != nil {
+		t.Fatal(err)
+	}
+	if jobs = queue.enqueued(); len(jobs) != 2 || !jobs[1].RunAt.Equal(clock.Now()) {
+		t.Errorf("enqueued %+v, want the task due within the lead reminded of at once", jobs)
+	}
+}
+
+func TestArchiverOnTheClock(t *testing.T) {
+	ctx := context.Background()
+	ann := &domain.User{ID: 1, Role: domain.RoleUser, TenantID: domain.DefaultTenant}
+	clock := clocktest.New(clockStart)
+	store := repository.NewMemoryTaskRepository()
+	tasks := usecase.NewTaskUseCaseWithClock(store, nil, clock)
+	archiver := usecase.NewTaskArchiver(store, nil, usecase.ArchiverOptions{Retention: 30 * day, Clock: clock})
+
+	task, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "File the taxes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(day)
+	if _, err := tasks.CompleteTask(ctx, ann, task.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Set(clockStart.Add(31*day - time.Second))
+	if n, err := archiver.ArchiveDue(ctx); err != nil || n != 0 {
+		t.Errorf("a second short of the retention, ArchiveDue = %d, %v, want the task kept", n, err)
+	}
+	clock.Advance(2 * time.Second)
+	if n, err := archiver.ArchiveDue(ctx); err != nil || n != 1 {
+		t.Errorf("a second past the retention, ArchiveDue = %d, %v, want the task archived", n, err)
+	}
+	archived, err := archiver.ListArchivedTasks(ctx, ann, 0)
+	if err != nil || len(archived) != 1 || !archived[0].ArchivedAt.Equal(clock.Now()) {
+		t.Errorf("ListArchivedTasks = %+v, %v, want the task archived at the clock's time", archived, err)
+	}
+}
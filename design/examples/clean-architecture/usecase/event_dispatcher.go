@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// EventHandler reacts to a domain event, such as by calling a webhook.
+// Delivery is at least once: an event whose delivery failed, to any handler,
+// or was cut short by a crash, is delivered again to every handler, with the
+// same Event.ID. Handlers that must act only once skip the IDs they have
+// seen.
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event domain.Event) error
+}
+
+type DispatcherOptions struct {
+	Interval time.Duration // between looks at the outbox, default 1s
+	Batch    int           // events taken per look, default 100
+	// MaxAttempts is how many failed deliveries an event gets before it is
+	// given up on, default 8
+	MaxAttempts int
+	// Backoff is the wait before retrying an event that has failed attempts
+	// times. The default doubles from 1s up to 5m.
+	Backoff func(attempts int) time.Duration
+	Clock   domain.Clock                     // default domain.SystemClock
+	Logf    func(format string, args ...any) // default discards
+}
+
+func backoff(attempts int) time.Duration {
+	wait := time.Second << min(attempts-1, 9)
+	return min(wait, 5*time.Minute)
+}
+
+// EventDispatcher delivers the events of an outbox to its handlers. It looks
+// for due events every Interval, in the goroutine Start starts.
+type EventDispatcher struct {
+	outbox   domain.Outbox
+	handlers []EventHandler
+	opts     DispatcherOptions
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewEventDispatcher(outbox domain.Outbox, opts DispatcherOptions, handlers ...EventHandler) *EventDispatcher {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = 100
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = backoff
+	}
+	if opts.Clock == nil {
+		opts.Clock = domain.SystemClock
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	return &EventDispatcher{outbox: outbox, handlers: handlers, opts: opts}
+}
+
+// DispatchDue delivers the events due now, up to Batch of them, and returns
+// how many were delivered. An event is marked delivered only once every
+// handler has handled it, so a crash in between delivers it again. One that
+// fails is retried after its Backoff, and after MaxAttempts failures is
+// given up on; other events go on meanwhile, so retried events can arrive
+// after later ones. It stops at the first error of the outbox itself.
+func (d *EventDispatcher) DispatchDue(ctx context.Context) (int, error) {
+	now := d.opts.Clock.Now()
+	events, err := d.outbox.Due(ctx, now, d.opts.Batch)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, event := range events {
+		if err := d.deliver(ctx, event.Event); err != nil {
+			if ctx.Err() != nil {
+				// Cut short rather than failed: it is due again next time
+				return delivered, ctx.Err()
+			}
+			attempts := event.Attempts + 1
+			var retryAt time.Time
+			if attempts < d.opts.MaxAttempts {
+				retryAt = now.Add(d.opts.Backoff(attempts))
+				d.opts.Logf("event %d (%s): attempt %d failed, retrying at %s: %v", event.ID, event.Name, attempts, retryAt.Format(time.RFC3339), err)
+			} else {
+				d.opts.Logf("event %d (%s): giving up after %d attempts: %v", event.ID, event.Name, attempts, err)
+			}
+			if err := d.outbox.MarkFailed(ctx, event.ID, err.Error(), retryAt); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if err := d.outbox.MarkDelivered(ctx, event.ID); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+func (d *EventDispatcher) deliver(ctx context.Context, event domain.Event) error {
+	for _, handler := range d.handlers {
+		if err := handler.HandleEvent(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run dispatches due events until ctx is done. A full batch is followed by
+// the next one at once; otherwise it waits Interval. Outbox errors are
+// logged and retried on the next look.
+func (d *EventDispatcher) Run(ctx context.Context) {
+	wait := time.NewTimer(0)
+	defer wait.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait.C:
+		}
+		n, err := d.DispatchDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.opts.Logf("dispatching events: %v", err)
+		}
+		if n == d.opts.Batch {
+			wait.Reset(0)
+		} else {
+			wait.Reset(d.opts.Interval)
+		}
+	}
+}
+
+// Start runs the dispatcher in a goroutine until Stop. Its signature is
+// that of a shutdown.Component's Start; ctx only bounds starting.
+func (d *EventDispatcher) Start(context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancel != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	d.cancel, d.done = cancel, done
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	return nil
+}
+
+// Stop cancels the deliveries in flight, which are then due again on the
+// next Start, and waits for the goroutine to return, or for ctx
+func (d *EventDispatcher) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	cancel, done := d.cancel, d.done
+	d.cancel = nil
+	d.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,441 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+// outboxStore is a task repository and the outbox it writes to
+type outboxStore struct {
+	repo   domain.TaskRepository
+	outbox domain.Outbox
+	db     *sqlx.DB // nil in memory
+}
+
+func sqliteOutboxStore(db *sqlx.DB) outboxStore {
+	return outboxStore{repo: repository.NewTaskRepository(db), outbox: repository.NewOutboxRepository(db), db: db}
+}
+
+// outboxStores runs test against the in-memory and SQLite outboxes
+func outboxStores(t *testing.T, test func(t *testing.T, s outboxStore)) {
+	t.Run("memory", func(t *testing.T) {
+		repo := repository.NewMemoryTaskRepository()
+		test(t, outboxStore{repo: repo, outbox: repo.Outbox()})
+	})
+	t.Run("sqlite", func(t *testing.T) { test(t, sqliteOutboxStore(openSQLite(t))) })
+}
+
+// farFuture is a now at which every pending event is due
+var farFuture = time.Now().Add(24 * time.Hour)
+
+// pending returns the events not yet delivered nor given up on
+func (s outboxStore) pending(t *testing.T) []domain.OutboxEvent {
+	t.Helper()
+	events, err := s.outbox.Due(context.Background(), farFuture, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// drain marks every pending event delivered, for a step to start clean
+func (s outboxStore) drain(t *testing.T) {
+	t.Helper()
+	for _, e := range s.pending(t) {
+		if err := s.outbox.MarkDelivered(context.Background(), e.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// createEvent creates a task and returns the ID of its TaskCreated event
+func (s outboxStore) createEvent(t *testing.T, title string) int64 {
+	t.Helper()
+	if _, err := usecase.NewTaskUseCase(s.repo).CreateTask(context.Background(), owner, usecase.CreateTaskInput{Title: title}); err != nil {
+		t.Fatal(err)
+	}
+	events := s.pending(t)
+	return events[len(events)-1].ID
+}
+
+// eventNames lists the events as name:taskID
+func eventNames(events []domain.OutboxEvent) string {
+	var out []string
+	for _, e := range events {
+		out = append(out, fmt.Sprintf("%s:%d", e.Name, e.TaskID))
+	}
+	return fmt.Sprint(out)
+}
+
+func TestChangesRecordTheirEvents(t *testing.T) {
+	outboxStores(t, func(t *testing.T, s outboxStore) {
+		ctx := context.Background()
+		uc := usecase.NewTaskUseCase(s.repo)
+
+		task, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Write report"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := s.pending(t)
+		if len(events) != 1 || events[0].Name != domain.TaskCreatedEvent || events[0].TaskID != task.ID ||
+			events[0].OwnerID != owner.ID || events[0].Title != "Write report" || events[0].ID <= 0 || events[0].Attempts != 0 {
+			t.Errorf("creating a task stored %+v, want TaskCreated with its new ID", events)
+		}
+		s.drain(t)
+
+		if task, err = uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: task.ID, Title: "Write the report"}); err != nil {
+			t.Fatal(err)
+		}
+		if events := s.pending(t); len(events) != 0 {
+			t.Errorf("retitling stored %s, want nothing", eventNames(events))
+		}
+		if _, err := uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: task.ID, Title: "Write the report", Completed: true}); err != nil {
+			t.Fatal(err)
+		}
+		if events := s.pending(t); len(events) != 1 || events[0].Name != domain.TaskCompletedEvent || events[0].Title != "Write the report" {
+			t.Errorf("completing with an update stored %s, want TaskCompleted", eventNames(events))
+		}
+		s.drain(t)
+		if _, err := uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: task.ID, Title: "Wrote the report", Completed: true}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := uc.CompleteTask(ctx, owner, task.ID); err != nil {
+			t.Fatal(err)
+		}
+		if events := s.pending(t); len(events) != 0 {
+			t.Errorf("updating or completing a completed task stored %s, want nothing more", eventNames(events))
+		}
+
+		other, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Review"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.drain(t)
+		if _, err := uc.CompleteTask(ctx, owner, other.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := uc.DeleteTask(ctx, owner, other.ID); err != nil {
+			t.Fatal(err)
+		}
+		if events := s.pending(t); len(events) != 1 || events[0].Name != domain.TaskCompletedEvent || events[0].TaskID != other.ID {
+			t.Errorf("CompleteTask then DeleteTask stored %s, want TaskCompleted alone", eventNames(events))
+		}
+		s.drain(t)
+
+		result, err := uc.BulkCreateTasks(ctx, owner, []usecase.CreateTaskInput{{Title: "One"}, {Title: ""}, {Title: "Two"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprint([]string{
+			fmt.Sprintf("TaskCreated:%d", result.Items[0].Task.ID),
+			fmt.Sprintf("TaskCreated:%d", result.Items[2].Task.ID),
+		})
+		if got := eventNames(s.pending(t)); got != want {
+			t.Errorf("a bulk create stored %s, want %s: one TaskCreated per task created", got, want)
+		}
+		s.drain(t)
+
+		stale, err := uc.GetTask(ctx, owner, result.Items[0].Task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: stale.ID, Title: "One", Version: stale.Version}); err != nil {
+			t.Fatal(err)
+		}
+		_, err = uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: stale.ID, Title: "One", Completed: true, Version: stale.Version})
+		if events := s.pending(t); !errors.Is(err, domain.ErrVersionConflict) || len(events) != 0 {
+			t.Errorf("a conflicting completion = %v and stored %s, want %v and nothing", err, eventNames(events), domain.ErrVersionConflict)
+		}
+	})
+}
+
+// TestEventsAreStoredWithTheirChange makes the outbox impossible to write
+// to, and shows that the changes whose events would go there are not stored
+// either
+func TestEventsAreStoredWithTheirChange(t *testing.T) {
+	ctx := context.Background()
+	s := sqliteOutboxStore(openSQLite(t))
+	uc := usecase.NewTaskUseCase(s.repo)
+	task, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Stored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.drain(t)
+	before := count(t, s.repo)
+
+	if _, err := s.db.Exec(`ALTER TABLE outbox RENAME TO outbox_away`); err != nil {
+		t.Fatal(err)
+	}
+	_, createErr := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Lost with its event"})
+	result, bulkErr := uc.BulkCreateTasks(ctx, owner, []usecase.CreateTaskInput{{Title: "A"}, {Title: "B"}})
+	_, completeErr := uc.CompleteTask(ctx, owner, task.ID)
+	if _, err := s.db.Exec(`ALTER TABLE outbox_away RENAME TO outbox`); err != nil {
+		t.Fatal(err)
+	}
+
+	if createErr == nil || count(t, s.repo) != before {
+		t.Errorf("a create whose event cannot be stored = %v, and %d tasks, want an error and %d", createErr, count(t, s.repo), before)
+	}
+	if bulkErr != nil || result.Failed != 2 {
+		t.Errorf("a bulk create whose events cannot be stored = %s, %v; want both items failed", outcome(result), bulkErr)
+	}
+	if got, _ := uc.GetTask(ctx, owner, task.ID); completeErr == nil || got.Completed || got.Version != task.Version {
+		t.Errorf("a completion whose event cannot be stored = %v, leaving %+v; want it rolled back", completeErr, got)
+	}
+	if events := s.pending(t); len(events) != 0 {
+		t.Errorf("stored %s for changes that were not", eventNames(events))
+	}
+
+	_, err = uc.CompleteTask(ctx, owner, task.ID)
+	if events := s.pending(t); err != nil || len(events) != 1 || events[0].Name != domain.TaskCompletedEvent {
+		t.Errorf("with the outbox back, completing = %v and stored %s, want TaskCompleted", err, eventNames(events))
+	}
+}
+
+// recordingHandler keeps what it is given, and fails while fail says so
+type recordingHandler struct {
+	mu   sync.Mutex
+	seen []domain.Event
+	fail func(event domain.Event, delivery int) error
+}
+
+func (r *recordingHandler) HandleEvent(ctx context.Context, event domain.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen = append(r.seen, event)
+	if r.fail != nil {
+		return r.fail(event, r.deliveries(event.ID))
+	}
+	return nil
+}
+
+// deliveries returns how many times event id has been delivered; r.mu is
+// held
+func (r *recordingHandler) deliveries(id int64) int {
+	n := 0
+	for _, e := range r.seen {
+		if e.ID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *recordingHandler) count(id int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deliveries(id)
+}
+
+// failFirst fails the first n deliveries of every event
+func failFirst(n int) func(domain.Event, int) error {
+	return func(_ domain.Event, delivery int) error {
+		if delivery <= n {
+			return fmt.Errorf("delivery %d refused", delivery)
+		}
+		return nil
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	outboxStores(t, func(t *testing.T, s outboxStore) {
+		ctx := context.Background()
+		clk := clocktest.New(time.Now().Add(time.Second))
+		opts := usecase.DispatcherOptions{
+			MaxAttempts: 3,
+			Backoff:     func(attempts int) time.Duration { return time.Duration(attempts) * time.Minute },
+			Clock:       clk,
+		}
+
+		log, flaky := &recordingHandler{}, &recordingHandler{fail: failFirst(2)}
+		d := usecase.NewEventDispatcher(s.outbox, opts, log, flaky)
+		id := s.createEvent(t, "Flaky")
+		n, err := d.DispatchDue(ctx)
+		if events := s.pending(t); err != nil || n != 0 || len(events) != 1 || events[0].Attempts != 1 || events[0].LastError != "delivery 1 refused" {
+			t.Errorf("a failed delivery = %d, %v, leaving %+v; want it kept with its attempt and error", n, err, events)
+		}
+		if n, _ := d.DispatchDue(ctx); n != 0 || flaky.count(id) != 1 {
+			t.Errorf("before its backoff, it was retried: %d delivered, %d deliveries", n, flaky.count(id))
+		}
+		clk.Advance(time.Minute)
+		d.DispatchDue(ctx)
+		clk.Advance(time.Minute)
+		d.DispatchDue(ctx)
+		if got := flaky.count(id); got != 2 {
+			t.Errorf("after 1m and 1m more it has %d deliveries, want 2: the retry fails again, so it waits 2m", got)
+		}
+		clk.Advance(time.Minute)
+		if n, _ := d.DispatchDue(ctx); n != 1 || flaky.count(id) != 3 || len(s.pending(t)) != 0 {
+			t.Errorf("after 2m more = %d delivered in %d deliveries, want the third attempt to deliver it", n, flaky.count(id))
+		}
+		if got := log.count(id); got != 3 {
+			t.Errorf("the handler before the failing one saw it %d times, want 3: at least once, not exactly once", got)
+		}
+		log.mu.Lock()
+		if log.seen[0] != log.seen[1] || log.seen[1] != log.seen[2] {
+			t.Errorf("deliveries differ: %+v; want the same ID and contents, so it can skip repeats", log.seen)
+		}
+		log.mu.Unlock()
+
+		broken := &recordingHandler{fail: func(domain.Event, int) error { return errors.New("always down") }}
+		d = usecase.NewEventDispatcher(s.outbox, opts, broken)
+		id = s.createEvent(t, "Hopeless")
+		next := s.createEvent(t, "Behind it")
+		for i := 0; i < 5; i++ {
+			d.DispatchDue(ctx)
+			clk.Advance(time.Hour)
+		}
+		if broken.count(id) != 3 || len(s.pending(t)) != 0 {
+			t.Errorf("an event that keeps failing had %d deliveries, want it given up on after 3", broken.count(id))
+		}
+		if got := broken.count(next); got != 3 {
+			t.Errorf("the event behind it had %d deliveries, want 3: not held up", got)
+		}
+		if s.db == nil {
+			return
+		}
+		var row struct {
+			Attempts  int        `db:"attempts"`
+			LastError string     `db:"last_error"`
+			Next      *time.Time `db:"next_attempt_at"`
+			Delivered *time.Time `db:"delivered_at"`
+		}
+		if err := s.db.Get(&row, `SELECT attempts, last_error, next_attempt_at, delivered_at FROM outbox WHERE id = ?`, id); err != nil {
+			t.Fatal(err)
+		}
+		if row.Attempts != 3 || row.LastError != "always down" || row.Next != nil || row.Delivered != nil {
+			t.Errorf("the given-up event's row = %+v, want it undelivered, with its attempts and last error", row)
+		}
+	})
+}
+
+// forgetful is an outbox that loses MarkDelivered, as a crash right after
+// delivering would
+type forgetful struct {
+	domain.Outbox
+}
+
+func (forgetful) MarkDelivered(context.Context, int64) error {
+	return errors.New("crashed")
+}
+
+func TestDispatcherRedeliversWhatItDidNotMark(t *testing.T) {
+	outboxStores(t, func(t *testing.T, s outboxStore) {
+		ctx := context.Background()
+		h := &recordingHandler{}
+		id := s.createEvent(t, "Delivered twice")
+		_, err := usecase.NewEventDispatcher(forgetful{s.outbox}, usecase.DispatcherOptions{}, h).DispatchDue(ctx)
+		if err == nil || h.count(id) != 1 || len(s.pending(t)) != 1 {
+			t.Errorf("handled but not marked = %v after %d deliveries, want an error and the event still due", err, h.count(id))
+		}
+		n, err := usecase.NewEventDispatcher(s.outbox, usecase.DispatcherOptions{}, h).DispatchDue(ctx)
+		if err != nil || n != 1 || h.count(id) != 2 || len(s.pending(t)) != 0 {
+			t.Errorf("the next dispatch = %d, %v after %d deliveries, want it delivered again", n, err, h.count(id))
+		}
+	})
+}
+
+// waitFor polls until ok or a few seconds have passed
+func waitFor(ok func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if ok() {
+			return true
+		}
+	}
+	return ok()
+}
+
+// stuck is a handler that does not finish until it is canceled
+type stuck struct {
+	once    sync.Once
+	started chan struct{}
+}
+
+func (h *stuck) HandleEvent(ctx context.Context, _ domain.Event) error {
+	h.once.Do(func() { close(h.started) })
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestDispatcherRunsAndRestarts runs the dispatcher as the server does, and
+// restarts it on the same database
+func TestDispatcherRunsAndRestarts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "restart.db")
+	open := func() outboxStore {
+		db, err := infrastructure.OpenDatabase(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return sqliteOutboxStore(db)
+	}
+	s := open()
+
+	h := &recordingHandler{}
+	d := usecase.NewEventDispatcher(s.outbox, usecase.DispatcherOptions{Interval: 10 * time.Millisecond, Batch: 2}, h)
+	if err := d.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The dispatcher may deliver an event before the outbox can be read for
+	// its ID, so the handler is what tells which ones there were
+	for i := 0; i < 5; i++ {
+		if _, err := usecase.NewTaskUseCase(s.repo).CreateTask(ctx, owner, usecase.CreateTaskInput{Title: fmt.Sprintf("Task %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !waitFor(func() bool { return len(s.pending(t)) == 0 }) {
+		t.Errorf("a running dispatcher left %s undelivered", eventNames(s.pending(t)))
+	}
+	h.mu.Lock()
+	seen := append([]domain.Event(nil), h.seen...)
+	h.mu.Unlock()
+	if len(seen) != 5 {
+		t.Errorf("a running dispatcher delivered %d events, want the 5 created", len(seen))
+	}
+	for _, e := range seen {
+		if n := h.count(e.ID); n != 1 {
+			t.Errorf("event %d was delivered %d times, want once", e.ID, n)
+		}
+	}
+	if err := d.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A delivery in flight when the dispatcher stops is not a failure
+	slow := &stuck{started: make(chan struct{})}
+	id := s.createEvent(t, "Interrupted")
+	d = usecase.NewEventDispatcher(s.outbox, usecase.DispatcherOptions{Interval: 10 * time.Millisecond}, slow)
+	if err := d.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-slow.started
+	if err := d.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if events := s.pending(t); len(events) != 1 || events[0].ID != id || events[0].Attempts != 0 {
+		t.Errorf("a delivery cut short by Stop left %+v, want it due without counting as an attempt", events)
+	}
+
+	// Events stored and not yet delivered survive the process
+	s.createEvent(t, "Stored before the restart")
+	s.db.Close()
+	s = open()
+	h = &recordingHandler{}
+	n, err := usecase.NewEventDispatcher(s.outbox, usecase.DispatcherOptions{}, h).DispatchDue(ctx)
+	if err != nil || n != 2 || len(s.pending(t)) != 0 {
+		t.Errorf("after a restart = %d, %v, want the 2 events still due delivered", n, err)
+	}
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Authorization policy. Every decision about who may do what is made here,
+// so the handlers' role checks only turn callers away early and never grant
+// anything on their own.
+//
+//	                        user   admin
+//	own tasks: all          yes    yes
+//	assigned tasks: view    yes    yes
+//	assigned tasks: change  no     no
+//	others' tasks: view     no     yes
+//	others' tasks: change   no     no
+//	view own account        yes    yes
+//	view others' accounts   no     yes
+//	list and set roles      no     yes
+//
+// Admins view everything for support and audits; they change a task only if
+// it is theirs. Assigning a task is changing it: only its owner may.
+//
+// All of it holds within the actor's tenant. Nobody, admins included, sees
+// or changes anything of another tenant: each use case acts for the actor's
+// tenant (actingFor), whatever tenant the request named, so the
+// repositories never return another tenant's data, and canView and
+// canModify check the task's tenant again.
+
+var (
+	ErrForbidden = domain.NewError("AUTH_FORBIDDEN", domain.KindForbidden, "your role does not allow this")
+	ErrOwnRole   = domain.NewError("USER_ROLE_OWN", domain.KindConflict, "admins cannot change their own role")
+)
+
+// actingFor returns ctx acting for the actor's tenant. Every use case that
+// acts for a user calls it first.
+func actingFor(ctx context.Context, actor *domain.User) context.Context {
+	return domain.WithTenant(ctx, actor.TenantID.OrDefault())
+}
+
+func sameTenant(actor *domain.User, task *domain.Task) bool {
+	return actor.TenantID.OrDefault() == task.TenantID.OrDefault()
+}
+
+func canView(actor *domain.User, task *domain.Task) bool {
+	return sameTenant(actor, task) && (task.OwnerID == actor.ID || task.AssigneeID == actor.ID || actor.IsAdmin())
+}
+
+func canModify(actor *domain.User, task *domain.Task) bool {
+	return sameTenant(actor, task) && task.OwnerID == actor.ID
+}
+
+// CanModify reports whether the actor may change the task, as the use cases
+// decide it. It grants nothing: presenters ask it only to offer the caller
+// the actions that would succeed.
+func CanModify(actor *domain.User, task *domain.Task) bool {
+	return canModify(actor, task)
+}
+
+// scopeListing limits a listing to what the actor may view: a user's own
+// tasks, or for an admin, the owner asked for (0 for everyone)
+func scopeListing(actor *domain.User, query domain.ListTasksQuery) (domain.ListTasksQuery, error) {
+	if actor.IsAdmin() {
+		return query, nil
+	}
+	if query.OwnerID != 0 && query.OwnerID != actor.ID {
+		return query, ErrForbidden
+	}
+	query.OwnerID = actor.ID
+	return query, nil
+}
+
+func canViewUser(actor *domain.User, id int64) bool {
+	return id == actor.ID || actor.IsAdmin()
+}
+
+func requireAdmin(actor *domain.User) error {
+	if !actor.IsAdmin() {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// accounts stores a new user and one promoted to admin with SetRole
+func accounts(t *testing.T) (users domain.UserRepository, user, admin *domain.User) {
+	t.Helper()
+	ctx := context.Background()
+	users = repository.NewMemoryUserRepository()
+	user = domain.NewUser("user@example.com", "hash")
+	admin = domain.NewUser("admin@example.com", "hash")
+	for _, u := range []*domain.User{user, admin} {
+		if err := users.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := users.SetRole(ctx, admin.ID, domain.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := users.GetByID(ctx, admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != domain.RoleUser || !admin.IsAdmin() {
+		t.Fatalf("roles %s and %s, want a new account to be a user and SetRole to make an admin", user.Role, admin.Role)
+	}
+	return users, user, admin
+}
+
+func TestTaskPolicy(t *testing.T) {
+	ctx := context.Background()
+	_, user, admin := accounts(t)
+	uc := usecase.NewTaskUseCase(repository.NewMemoryTaskRepository())
+	theirs, err := uc.CreateTask(ctx, user, usecase.CreateTaskInput{Title: "User's task"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admins, err := uc.CreateTask(ctx, admin, usecase.CreateTaskInput{Title: "Admin's task"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() (string, error)
+		want string // the result, if the call succeeds
+		err  error
+	}{
+		{"an admin views another user's task", func() (string, error) {
+			task, err := uc.GetTask(ctx, admin, theirs.ID)
+			if err != nil {
+				return "", err
+			}
+			return task.Title, nil
+		}, "User's task", nil},
+		{"an admin lists everyone's tasks", listed(uc, admin, domain.ListTasksQuery{}), "Admin's task|User's task", nil},
+		{"or one owner's", listed(uc, admin, domain.ListTasksQuery{OwnerID: user.ID}), "User's task", nil},
+		{"an admin cannot update another user's task", func() (string, error) {
+			_, err := uc.UpdateTask(ctx, admin, usecase.UpdateTaskInput{ID: theirs.ID, Title: "Edited by admin"})
+			return "", err
+		}, "", usecase.ErrForbidden},
+		{"nor delete it", func() (string, error) { return "", uc.DeleteTask(ctx, admin, theirs.ID) }, "", usecase.ErrForbidden},
+		{"nor complete it in bulk", func() (string, error) {
+			result, err := uc.BulkCompleteTasks(ctx, admin, []int64{theirs.ID})
+			if err != nil {
+				return "", err
+			}
+			return "", result.Items[0].Err
+		}, "", usecase.ErrForbidden},
+		{"a user does not find the admin's task", func() (string, error) {
+			_, err := uc.GetTask(ctx, user, admins.ID)
+			return "", err
+		}, "", usecase.ErrTaskNotFound},
+		{"nor list another owner's tasks", listed(uc, user, domain.ListTasksQuery{OwnerID: admin.ID}), "", usecase.ErrForbidden},
+		{"but may name themselves", listed(uc, user, domain.ListTasksQuery{OwnerID: user.ID}), "User's task", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.run()
+			if !errors.Is(err, tt.err) || got != tt.want {
+				t.Errorf("got %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+// listed lists as user and returns the titles in alphabetical order
+func listed(uc *usecase.TaskUseCase, user *domain.User, query domain.ListTasksQuery) func() (string, error) {
+	return func() (string, error) {
+		tasks, err := uc.ListTasks(context.Background(), user, query)
+		if err != nil {
+			return "", err
+		}
+		titles := make([]string, len(tasks))
+		for i, task := range tasks {
+			titles[i] = task.Title
+		}
+		slices.Sort(titles)
+		return strings.Join(titles, "|"), nil
+	}
+}
+
+func TestUserPolicy(t *testing.T) {
+	ctx := context.Background()
+	users, user, admin := accounts(t)
+	uc := usecase.NewUserUseCase(users)
+
+	if _, err := uc.ListUsers(ctx, user); !errors.Is(err, usecase.ErrForbidden) {
+		t.Errorf("a user listing accounts = %v, want ErrForbidden", err)
+	}
+	tests := []struct {
+		name   string
+		caller *domain.User
+		id     int64
+		role   string
+		err    error
+	}{
+		{"a user promoting themselves", user, user.ID, "admin", usecase.ErrForbidden},
+		{"an admin changing their own role", admin, admin.ID, "user", usecase.ErrOwnRole},
+		{"a role outside user and admin", admin, user.ID, "root", domain.ErrInvalidRole},
+		{"an unknown user", admin, 9999, "admin", domain.ErrUserNotFound},
+		{"an admin promoting another user", admin, user.ID, "admin", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promoted, err := uc.SetRole(ctx, tt.caller, tt.id, tt.role)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SetRole = %v, want %v", err, tt.err)
+			}
+			if err == nil && string(promoted.Role) != tt.role {
+				t.Errorf("the user's role is %s, want %s", promoted.Role, tt.role)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// A task created with a due date is reminded of to its owner, Lead before
+// it comes due. The ReminderService follows the task events, like the read
+// model, and enqueues a SendReminderJob for each such task; the job queue
+// later runs it through HandleJob, which sends the reminder unless the task
+// has since been completed or deleted.
+
+type ReminderOptions struct {
+	Lead  time.Duration                    // how long before its due date a task is reminded of, default 1h
+	Clock domain.Clock                     // default domain.SystemClock
+	Logf  func(format string, args ...any) // default discards
+}
+
+// reminderPayload is the Payload of a SendReminderJob: the task to read
+// again when the job runs, in its tenant
+type reminderPayload struct {
+	TaskID   int64  `json:"task_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+type ReminderService struct {
+	jobs   domain.JobQueue
+	tasks  domain.TaskRepository
+	events domain.TaskEvents
+	sender domain.ReminderSender
+	opts   ReminderOptions
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewReminderService(jobs domain.JobQueue, tasks domain.TaskRepository, events domain.TaskEvents, sender domain.ReminderSender, opts ReminderOptions) *ReminderService {
+	if opts.Lead <= 0 {
+		opts.Lead = time.Hour
+	}
+	if opts.Clock == nil {
+		opts.Clock = domain.SystemClock
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	return &ReminderService{jobs: jobs, tasks: tasks, events: events, sender: sender, opts: opts}
+}
+
+// Schedule enqueues the reminder of a task created with a due date, to run
+// Lead before it, or now if that has passed. Other tasks have none.
+func (s *ReminderService) Schedule(ctx context.Context, task *domain.Task) (scheduled bool, err error) {
+	if task.DueAt.IsZero() {
+		return false, nil
+	}
+	payload, err := json.Marshal(reminderPayload{TaskID: task.ID, TenantID: string(task.TenantID)})
+	if err != nil {
+		return false, err
+	}
+	runAt := task.DueAt.Add(-s.opts.Lead)
+	if now := s.opts.Clock.Now(); runAt.Before(now) {
+		runAt = now
+	}
+	_, err = s.jobs.Enqueue(ctx, domain.Job{Kind: domain.SendReminderJob, Payload: payload, RunAt: runAt})
+	return err == nil, err
+}
+
+// HandleJob sends the reminder of a SendReminderJob. The task is read again
+// first: one completed since is not reminded of, nor one that can no longer
+// be read, as when it was deleted or archived. One already due, as when the
+// job was retried for long, is reminded of as overdue. A failure to send
+// fails the job, which the queue retries.
+func (s *ReminderService) HandleJob(ctx context.Context, job domain.Job) error {
+	var payload reminderPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+	task, err := s.tasks.GetByID(domain.WithTenant(ctx, domain.TenantID(payload.TenantID)), payload.TaskID)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		s.opts.Logf("reminders: task %d is gone, not reminding: %v", payload.TaskID, err)
+		return nil
+	}
+	if task.Completed {
+		return nil
+	}
+	return s.sender.SendReminder(ctx, domain.Reminder{
+		TaskID:   task.ID,
+		OwnerID:  task.OwnerID,
+		TenantID: task.TenantID,
+		Title:    task.Title,
+		DueAt:    task.DueAt,
+		Overdue:  task.Overdue(s.opts.Clock.Now()),
+	})
+}
+
+// Start follows the task events and schedules the reminders of the tasks
+// created, in a goroutine until Stop. Its signature is that of a
+// shutdown.Component's Start.
+func (s *ReminderService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	events, err := s.events.Follow(runCtx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		for event := range events {
+			if event.Type != domain.TaskCreated {
+				continue
+			}
+			if _, err := s.Schedule(runCtx, &event.Task); err != nil && runCtx.Err() == nil {
+				s.opts.Logf("reminders: scheduling task %d: %v", event.Task.ID, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops scheduling reminders and waits for the goroutine to return, or
+// for ctx. The jobs already enqueued are the queue's.
+func (s *ReminderService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,174 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// fakeQueue records what is enqueued, and runs nothing
+type fakeQueue struct {
+	mu   sync.Mutex
+	jobs []domain.Job
+}
+
+func (q *fakeQueue) Enqueue(_ context.Context, job domain.Job) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job.ID = int64(len(q.jobs) + 1)
+	q.jobs = append(q.jobs, job)
+	return job.ID, nil
+}
+
+func (q *fakeQueue) DeadLetters(context.Context) ([]domain.Job, error) {
+	return nil, nil
+}
+
+func (q *fakeQueue) enqueued() []domain.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]domain.Job(nil), q.jobs...)
+}
+
+// fakeSender records the reminders sent, failing the next fails of them
+type fakeSender struct {
+	mu    sync.Mutex
+	fails int
+	sent  []domain.Reminder
+}
+
+func (s *fakeSender) SendReminder(_ context.Context, r domain.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("mail server unavailable")
+	}
+	s.sent = append(s.sent, r)
+	return nil
+}
+
+func (s *fakeSender) reminders() []domain.Reminder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]domain.Reminder(nil), s.sent...)
+}
+
+func TestReminderService(t *testing.T) {
+	ctx := context.Background()
+	ann := &domain.User{ID: 1, Role: domain.RoleUser, TenantID: domain.DefaultTenant}
+	store := repository.NewMemoryTaskRepository()
+	tasks := usecase.NewTaskUseCase(store)
+	now := time.Now()
+	queue, sender := &fakeQueue{}, &fakeSender{}
+	reminders := usecase.NewReminderService(queue, store, nil, sender, usecase.ReminderOptions{
+		Lead:  time.Hour,
+		Clock: clocktest.New(now),
+	})
+	// create creates a task of Ann's due at due, if it is set
+	create := func(title string, due time.Time) *domain.Task {
+		t.Helper()
+		task, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: title, DueAt: due})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+
+	if scheduled, err := reminders.Schedule(ctx, create("No rush", time.Time{})); err != nil || scheduled || len(queue.enqueued()) != 0 {
+		t.Errorf("Schedule of a task without a due date = %v, %v, with %d jobs, want no reminder", scheduled, err, len(queue.enqueued()))
+	}
+	due := now.Add(3 * time.Hour).Truncate(time.Second)
+	report := create("Write the report", due)
+	if !report.DueAt.Equal(due) {
+		t.Errorf("the task is due at %v, want %v", report.DueAt, due)
+	}
+	scheduled, err := reminders.Schedule(ctx, report)
+	jobs := queue.enqueued()
+	if err != nil || !scheduled || len(jobs) != 1 || jobs[0].Kind != domain.SendReminderJob || !jobs[0].RunAt.Equal(due.Add(-time.Hour)) {
+		t.Fatalf("Schedule of a task due in 3h = %v, %v, enqueuing %+v, want a reminder 1h before it", scheduled, err, jobs)
+	}
+	soon := create("Call back", now.Add(10*time.Minute))
+	reminders.Schedule(ctx, soon)
+	if jobs = queue.enqueued(); len(jobs) != 2 || !jobs[1].RunAt.Equal(now) {
+		t.Fatalf("Schedule of a task due in 10m enqueued %+v, want a reminder now", jobs)
+	}
+	if _, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Too late", DueAt: now.Add(-time.Hour)}); !errors.Is(err, domain.ErrDueBeforeCreated) {
+		t.Errorf("creating a task due in the past = %v, want %v", err, domain.ErrDueBeforeCreated)
+	}
+
+	err = reminders.HandleJob(ctx, jobs[0])
+	sent := sender.reminders()
+	if err != nil || len(sent) != 1 || sent[0].TaskID != report.ID || sent[0].OwnerID != ann.ID ||
+		sent[0].Title != "Write the report" || !sent[0].DueAt.Equal(due) {
+		t.Errorf("HandleJob = %v, sending %+v, want the task's reminder sent to its owner", err, sent)
+	}
+	if _, err := tasks.CompleteTask(ctx, ann, report.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reminders.HandleJob(ctx, jobs[0]); err != nil || len(sender.reminders()) != 1 {
+		t.Errorf("HandleJob of a task completed since = %v, with %d sent, want nothing sent", err, len(sender.reminders()))
+	}
+	if err := tasks.DeleteTask(ctx, ann, soon.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reminders.HandleJob(ctx, jobs[1]); err != nil || len(sender.reminders()) != 1 {
+		t.Errorf("HandleJob of a task deleted since = %v, with %d sent, want nothing sent", err, len(sender.reminders()))
+	}
+
+	sender.fails = 1
+	reminders.Schedule(ctx, create("Renew the passport", due))
+	jobs = queue.enqueued()
+	if err := reminders.HandleJob(ctx, jobs[len(jobs)-1]); err == nil || len(sender.reminders()) != 1 {
+		t.Errorf("HandleJob with a sender that fails = %v, want the error, for the queue to retry", err)
+	}
+}
+
+// TestRemindersThroughTheQueue runs the reminders as the server does: from
+// the events of the tasks created, on a JobQueue, with a sender that fails
+// once
+func TestRemindersThroughTheQueue(t *testing.T) {
+	ctx := context.Background()
+	ann := &domain.User{ID: 1, Role: domain.RoleUser, TenantID: "acme"}
+	store := repository.NewMemoryTaskRepository()
+	events := infrastructure.NewTaskEventBus()
+	defer events.Close(ctx)
+	tasks := usecase.NewTaskUseCaseWithEvents(store, events)
+	queue := infrastructure.NewJobQueue(infrastructure.JobQueueOptions{Backoff: func(int) time.Duration { return 10 * time.Millisecond }})
+	sender := &fakeSender{fails: 1}
+	reminders := usecase.NewReminderService(queue, store, events, sender, usecase.ReminderOptions{})
+	queue.Handle(domain.SendReminderJob, reminders)
+	if err := queue.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Stop(ctx)
+	if err := reminders.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reminders.Stop(ctx)
+
+	if _, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "No rush"}); err != nil {
+		t.Fatal(err)
+	}
+	task, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Pay the rent", DueAt: time.Now().Add(30 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(func() bool { return len(sender.reminders()) == 1 }) {
+		t.Fatal("a task due within the lead was not reminded of, after a failed attempt")
+	}
+	if sent := sender.reminders(); sent[0].TaskID != task.ID || sent[0].TenantID != "acme" {
+		t.Errorf("the reminder = %+v, want task %d's, in its tenant", sent[0], task.ID)
+	}
+	if dead, _ := queue.DeadLetters(ctx); len(dead) != 0 || queue.Pending() != 0 {
+		t.Errorf("%d jobs left pending and %d dead, want none", queue.Pending(), len(dead))
+	}
+}
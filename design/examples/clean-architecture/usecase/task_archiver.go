@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+type ArchiverOptions struct {
+	// Retention is how long a completed task stays a task once it was last
+	// changed, default 30 days. Completing a task is a change, so an
+	// untouched one is archived Retention after it was completed.
+	Retention time.Duration
+	Interval  time.Duration                    // between looks for tasks to archive, default 1h
+	Batch     int                              // tasks archived per transaction, default 100
+	Clock     domain.Clock                     // default domain.SystemClock
+	Logf      func(format string, args ...any) // default discards
+}
+
+// TaskArchiver moves completed tasks to the archive once they are older
+// than Retention, every Interval, in the goroutine Start starts. Each
+// archived task is published as a TaskArchived event, so streams and the
+// read model see it go. It also lists the archive.
+type TaskArchiver struct {
+	archive domain.TaskArchive
+	events  domain.TaskEvents
+	opts    ArchiverOptions
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTaskArchiver returns an archiver of archive publishing to events, which
+// may be nil
+func NewTaskArchiver(archive domain.TaskArchive, events domain.TaskEvents, opts ArchiverOptions) *TaskArchiver {
+	if events == nil {
+		events = noEvents{}
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 30 * 24 * time.Hour
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = 100
+	}
+	if opts.Clock == nil {
+		opts.Clock = domain.SystemClock
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	return &TaskArchiver{archive: archive, events: events, opts: opts}
+}
+
+// ArchiveDue archives the tasks due now, up to Batch of them, and returns
+// how many were archived. It archives for every tenant at once.
+func (a *TaskArchiver) ArchiveDue(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "TaskArchiver.ArchiveDue")
+	defer endSpan(span, &err)
+	ctx = domain.WithTenant(ctx, domain.AllTenants)
+	now := a.opts.Clock.Now()
+	archived, err := a.archive.Archive(ctx, now.Add(-a.opts.Retention), now, a.opts.Batch)
+	if err != nil {
+		return 0, err
+	}
+	for _, task := range archived {
+		event := domain.TaskEvent{Type: domain.TaskArchived, Task: task.Task}
+		event.Task.ClearEvents()
+		a.events.Publish(ctx, event)
+	}
+	return len(archived), nil
+}
+
+// Run archives due tasks until ctx is done. A full batch is followed by the
+// next one at once; otherwise it waits Interval. Errors are logged and
+// retried on the next look.
+func (a *TaskArchiver) Run(ctx context.Context) {
+	wait := time.NewTimer(0)
+	defer wait.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait.C:
+		}
+		n, err := a.ArchiveDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			a.opts.Logf("archiving tasks: %v", err)
+		}
+		if n > 0 {
+			a.opts.Logf("archived %d completed task(s)", n)
+		}
+		if n == a.opts.Batch {
+			wait.Reset(0)
+		} else {
+			wait.Reset(a.opts.Interval)
+		}
+	}
+}
+
+// Start runs the archiver in a goroutine until Stop. Its signature is that
+// of a shutdown.Component's Start; ctx only bounds starting.
+func (a *TaskArchiver) Start(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cancel != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	a.cancel, a.done = cancel, done
+	go func() {
+		defer close(done)
+		a.Run(ctx)
+	}()
+	return nil
+}
+
+// Stop cancels the batch in flight and waits for the goroutine to return,
+// or for ctx
+func (a *TaskArchiver) Stop(ctx context.Context) error {
+	a.mu.Lock()
+	cancel, done := a.cancel, a.done
+	a.cancel = nil
+	a.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ListArchivedTasks returns the archived tasks the actor may view, most
+// recently archived first. It is scoped like ListTasks: a user lists their
+// own; an admin lists everyone's, or one owner's with ownerID.
+func (a *TaskArchiver) ListArchivedTasks(ctx context.Context, actor *domain.User, ownerID int64) (_ []domain.ArchivedTask, err error) {
+	ctx, span := startSpan(ctx, "TaskArchiver.ListArchivedTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	query, err := scopeListing(actor, domain.ListTasksQuery{OwnerID: ownerID})
+	if err != nil {
+		return nil, err
+	}
+	return a.archive.ListArchived(ctx, query.OwnerID)
+}
@@ -0,0 +1,163 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/domain/clocktest"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+const day = 24 * time.Hour
+
+// publishedEvents records what is published, from any goroutine; nothing
+// subscribes to it
+type publishedEvents struct {
+	domain.TaskEvents
+	mu     sync.Mutex
+	events []domain.TaskEvent
+}
+
+func (e *publishedEvents) Publish(_ context.Context, event domain.TaskEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *publishedEvents) all() []domain.TaskEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]domain.TaskEvent(nil), e.events...)
+}
+
+func archivedTitles(tasks []domain.ArchivedTask) []string {
+	out := []string{}
+	for _, task := range tasks {
+		out = append(out, task.Title)
+	}
+	return out
+}
+
+// TestTaskArchiver archives on a fake clock started at the real time, since
+// tasks are stamped with it
+func TestTaskArchiver(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	alice := register(t, users, "alice@example.com", domain.RoleUser)
+	bob := register(t, users, "bob@example.com", domain.RoleUser)
+	admin := register(t, users, "admin@example.com", domain.RoleAdmin)
+	store := repository.NewMemoryTaskRepository()
+	tasks := usecase.NewTaskUseCase(store)
+	published := &publishedEvents{}
+	fake := clocktest.New(time.Now())
+	archiver := usecase.NewTaskArchiver(store, published, usecase.ArchiverOptions{
+		Retention: 30 * day,
+		Interval:  time.Hour,
+		Batch:     1,
+		Clock:     fake,
+	})
+
+	var completed []*domain.Task
+	for _, c := range []struct {
+		owner *domain.User
+		title string
+		done  bool
+	}{
+		{alice, "Write the report", true},
+		{alice, "Buy milk", false},
+		{bob, "Make the slides", true},
+	} {
+		task, err := tasks.CreateTask(ctx, c.owner, usecase.CreateTaskInput{Title: c.title})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.done {
+			if _, err := tasks.CompleteTask(ctx, c.owner, task.ID); err != nil {
+				t.Fatal(err)
+			}
+			completed = append(completed, task)
+		}
+	}
+	report, slides := completed[0], completed[1]
+
+	waits := []struct {
+		name    string
+		advance time.Duration
+		want    int
+	}{
+		{"just completed", 0, 0},
+		{"29 days later", 29 * day, 0},
+		{"31 days later, a batch of one", 2 * day, 1},
+	}
+	for _, w := range waits {
+		fake.Advance(w.advance)
+		if n, err := archiver.ArchiveDue(ctx); err != nil || n != w.want {
+			t.Errorf("%s, ArchiveDue = %d, %v, want %d", w.name, n, err, w.want)
+		}
+	}
+	if sent := published.all(); len(sent) != 1 || sent[0].Type != domain.TaskArchived || sent[0].Task.ID != report.ID {
+		t.Errorf("published %+v, want the report as a task.archived event", sent)
+	}
+
+	readModel := repository.NewMemoryTaskReadModel(fake)
+	all, err := tasks.ListTasks(ctx, admin, domain.ListTasksQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := readModel.Reset(ctx, all); err != nil {
+		t.Fatal(err)
+	}
+	if summary, _ := readModel.Summary(ctx, bob.ID); summary.Total != 1 || summary.Completed != 1 {
+		t.Errorf("Bob's summary = %+v, want his completed task counted", summary)
+	}
+
+	// A full batch is followed by the next at once, so the loop archives the
+	// rest without waiting the hour
+	if err := archiver.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool { return len(published.all()) >= 2 })
+	if err := archiver.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sent := published.all()
+	if len(sent) != 2 || sent[1].Task.ID != slides.ID {
+		t.Fatalf("started, the archiver published %+v, want the rest archived straight away", sent)
+	}
+	if err := readModel.Apply(ctx, sent[1]); err != nil {
+		t.Fatal(err)
+	}
+	summary, _ := readModel.Summary(ctx, bob.ID)
+	activity, _ := readModel.RecentActivity(ctx, bob.ID, 1)
+	if summary.Total != 0 || len(activity) != 1 || activity[0].Kind != domain.ActivityArchived {
+		t.Errorf("the read model counts %d of Bob's tasks with activity %+v, want the archived task dropped and recorded as archived", summary.Total, activity)
+	}
+	if _, err := tasks.GetTask(ctx, alice, report.ID); !errors.Is(err, usecase.ErrTaskNotFound) {
+		t.Errorf("GetTask of an archived task = %v, want %v", err, usecase.ErrTaskNotFound)
+	}
+
+	lists := []struct {
+		name  string
+		actor *domain.User
+		owner int64
+		want  []string
+		err   error
+	}{
+		{"Alice's own", alice, 0, []string{"Write the report"}, nil},
+		{"Alice asking for Bob's", alice, bob.ID, nil, usecase.ErrForbidden},
+		{"an admin asking for everyone's", admin, 0, []string{"Make the slides", "Write the report"}, nil},
+		{"an admin asking for Bob's", admin, bob.ID, []string{"Make the slides"}, nil},
+	}
+	for _, tt := range lists {
+		list, err := archiver.ListArchivedTasks(ctx, tt.actor, tt.owner)
+		if !errors.Is(err, tt.err) || (tt.err == nil && !reflect.DeepEqual(archivedTitles(list), tt.want)) {
+			t.Errorf("%s = %q, %v, want %q, %v", tt.name, archivedTitles(list), err, tt.want, tt.err)
+		}
+	}
+}
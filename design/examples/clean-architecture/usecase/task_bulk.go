@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Bulk operations apply one action to many tasks and report each item on its
+// own. An item that fails does not stop the others: the caller gets every
+// success and the reason for every failure, and retries only what failed.
+//
+// Bulk create validates each input first, then stores every valid task in
+// one batch insert. The batch itself is all or nothing, so if storage fails,
+// every valid item fails with that error and nothing is stored.
+//
+// Get looks every task up at once, with TaskRepository.GetByIDs, and reports
+// each ID in its own item, as Complete and Delete do.
+//
+// Complete and delete stop at the first item they reach after ctx is done.
+// Items already applied stay applied; that item and the rest fail with the
+// context's error, so the caller still learns what happened.
+
+const MaxBulkItems = 100
+
+var (
+	ErrBulkEmpty       = domain.NewError("BULK_EMPTY", domain.KindInvalid, "a bulk request needs at least one item")
+	ErrBulkTooLarge    = domain.NewError("BULK_TOO_LARGE", domain.KindInvalid, "a bulk request cannot have more than 100 items")
+	ErrBulkDuplicateID = domain.NewError("BULK_DUPLICATE_ID", domain.KindInvalid, "task id appears earlier in the same request")
+)
+
+// ItemResult is the outcome of one item, in request order. Task is set for
+// created and completed items, and Err for failed ones.
+type ItemResult struct {
+	Index int
+	ID    int64
+	Task  *domain.Task
+	Err   error
+}
+
+type BulkResult struct {
+	Items     []ItemResult
+	Succeeded int
+	Failed    int
+}
+
+func (r *BulkResult) add(item ItemResult) {
+	r.Items = append(r.Items, item)
+	if item.Err != nil {
+		r.Failed++
+	} else {
+		r.Succeeded++
+	}
+}
+
+func checkBulkSize(n int) error {
+	if n == 0 {
+		return ErrBulkEmpty
+	}
+	if n > MaxBulkItems {
+		return ErrBulkTooLarge
+	}
+	return nil
+}
+
+// BulkCreateTasks creates every valid input for the actor. The error is only
+// for a request that cannot be processed at all; item failures are in the
+// result.
+func (uc *TaskUseCase) BulkCreateTasks(ctx context.Context, actor *domain.User, inputs []CreateTaskInput) (_ BulkResult, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.BulkCreateTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if err := checkBulkSize(len(inputs)); err != nil {
+		return BulkResult{}, err
+	}
+
+	items := make([]ItemResult, len(inputs))
+	var valid []*domain.Task
+	now := uc.clock.Now()
+	for i, input := range inputs {
+		items[i].Index = i
+		task, err := domain.NewTask(actor.ID, input.Title, input.Description, now)
+		if err == nil {
+			err = label(task, input.Priority, input.Tags, now)
+		}
+		if err == nil {
+			err = task.SetDue(input.DueAt, now)
+		}
+		if err == nil {
+			err = uc.attach(ctx, actor, task, input.ParentID)
+		}
+		if err != nil {
+			items[i].Err = err
+			continue
+		}
+		task.Record(domain.TaskCreatedEvent, now)
+		items[i].Task = task
+		valid = append(valid, task)
+	}
+
+	var storeErr error
+	if len(valid) > 0 {
+		storeErr = uc.taskRepo.CreateBatch(ctx, valid)
+	}
+
+	var result BulkResult
+	for _, item := range items {
+		if item.Task != nil {
+			if storeErr != nil {
+				item.Task, item.Err = nil, storeErr
+			} else {
+				item.ID = item.Task.ID
+				uc.publish(ctx, domain.TaskCreated, item.Task)
+			}
+		}
+		result.add(item)
+	}
+	return result, nil
+}
+
+// BulkCompleteTasks marks each task completed, as CompleteTask would.
+// Completing a task that is already completed succeeds.
+func (uc *TaskUseCase) BulkCompleteTasks(ctx context.Context, actor *domain.User, ids []int64) (_ BulkResult, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.BulkCompleteTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	return uc.eachID(ctx, ids, func(id int64) (*domain.Task, error) {
+		return uc.CompleteTask(ctx, actor, id)
+	})
+}
+
+// BulkGetTasks returns each task, as GetTask would, with one lookup for all
+// of them. A task the actor may not view fails like a missing one.
+func (uc *TaskUseCase) BulkGetTasks(ctx context.Context, actor *domain.User, ids []int64) (_ BulkResult, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.BulkGetTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if err := checkBulkSize(len(ids)); err != nil {
+		return BulkResult{}, err
+	}
+	tasks, err := uc.taskRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return BulkResult{}, err
+	}
+	viewable := make(map[int64]*domain.Task, len(tasks))
+	for _, task := range tasks {
+		if canView(actor, task) {
+			viewable[task.ID] = task
+		}
+	}
+	return uc.eachID(ctx, ids, func(id int64) (*domain.Task, error) {
+		if task, ok := viewable[id]; ok {
+			return task, nil
+		}
+		return nil, ErrTaskNotFound
+	})
+}
+
+// BulkDeleteTasks deletes each task, as DeleteTask would
+func (uc *TaskUseCase) BulkDeleteTasks(ctx context.Context, actor *domain.User, ids []int64) (_ BulkResult, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.BulkDeleteTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	return uc.eachID(ctx, ids, func(id int64) (*domain.Task, error) {
+		return nil, uc.DeleteTask(ctx, actor, id)
+	})
+}
+
+// eachID applies action to every id in order, failing repeats of an id
+// instead of applying the action twice
+func (uc *TaskUseCase) eachID(ctx context.Context, ids []int64, action func(id int64) (*domain.Task, error)) (BulkResult, error) {
+	if err := checkBulkSize(len(ids)); err != nil {
+		return BulkResult{}, err
+	}
+
+	var result BulkResult
+	seen := make(map[int64]bool, len(ids))
+	for i, id := range ids {
+		item := ItemResult{Index: i, ID: id}
+		if err := ctx.Err(); err != nil {
+			item.Err = err
+		} else if seen[id] {
+			item.Err = ErrBulkDuplicateID
+		} else {
+			seen[id] = true
+			item.Task, item.Err = action(id)
+		}
+		result.add(item)
+	}
+	return result, nil
+}
@@ -0,0 +1,266 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/mock"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+	"github.com/jmoiron/sqlx"
+)
+
+// owner is the user the bulk operations act for
+var owner = &domain.User{ID: 1, Role: domain.RoleUser}
+
+// openSQLite opens a migrated SQLite database in the test's temporary directory
+func openSQLite(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// taskRepositories runs test against the in-memory and SQLite repositories
+func taskRepositories(t *testing.T, test func(t *testing.T, r domain.TaskRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, repository.NewMemoryTaskRepository()) })
+	t.Run("sqlite", func(t *testing.T) { test(t, repository.NewTaskRepository(openSQLite(t))) })
+}
+
+// outcome summarizes a bulk result as one word per item: ok, or the code of
+// the error, or "uncoded" for an error without one
+func outcome(result usecase.BulkResult) string {
+	words := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		var coded *domain.Error
+		switch {
+		case item.Err == nil:
+			words[i] = "ok"
+		case errors.As(item.Err, &coded):
+			words[i] = string(coded.Code)
+		default:
+			words[i] = "uncoded"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func count(t *testing.T, r domain.TaskRepository) int {
+	t.Helper()
+	tasks, err := r.List(context.Background(), domain.ListTasksQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(tasks)
+}
+
+var mixed = []usecase.CreateTaskInput{
+	{Title: "Valid one"},
+	{Title: ""},
+	{Title: "Valid two", Priority: "high", Tags: []string{"bulk"}},
+	{Title: "Bad priority", Priority: "urgent"},
+	{Title: "Valid three"},
+}
+
+// createMixed creates mixed and returns the IDs of the three valid tasks
+func createMixed(t *testing.T, uc *usecase.TaskUseCase) []int64 {
+	t.Helper()
+	result, err := uc.BulkCreateTasks(context.Background(), owner, mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outcome(result), "ok TASK_TITLE_EMPTY ok TASK_PRIORITY_INVALID ok"; got != want {
+		t.Fatalf("outcome %q, want %q: invalid items fail with their own reason, in request order", got, want)
+	}
+	if result.Succeeded != 3 || result.Failed != 2 {
+		t.Fatalf("%d succeeded and %d failed, want 3 and 2", result.Succeeded, result.Failed)
+	}
+	return []int64{result.Items[0].ID, result.Items[2].ID, result.Items[4].ID}
+}
+
+func TestBulkCreateTasks(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		uc := usecase.NewTaskUseCase(r)
+		ids := createMixed(t, uc)
+		if count(t, r) != 3 {
+			t.Errorf("%d tasks stored, want the 3 valid ones", count(t, r))
+		}
+		if !(ids[0] > 0 && ids[0] < ids[1] && ids[1] < ids[2]) {
+			t.Errorf("new ids %v are not ascending", ids)
+		}
+		stored, err := uc.GetTask(context.Background(), owner, ids[1])
+		if err != nil || stored.Priority != domain.PriorityHigh || !slices.Equal(stored.Tags, []string{"bulk"}) {
+			t.Errorf("stored %+v, %v; want priority and tags kept", stored, err)
+		}
+	})
+}
+
+func TestBulkCompleteTasks(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		uc := usecase.NewTaskUseCase(r)
+		ids := createMixed(t, uc)
+
+		result, err := uc.BulkCompleteTasks(ctx, owner, []int64{ids[0], 9999, ids[0], ids[1]})
+		if got, want := outcome(result), "ok TASK_NOT_FOUND BULK_DUPLICATE_ID ok"; err != nil || got != want {
+			t.Errorf("outcome %q, %v; want %q", got, err, want)
+		}
+		result, _ = uc.BulkCompleteTasks(ctx, owner, []int64{ids[0]})
+		if outcome(result) != "ok" || !result.Items[0].Task.Completed {
+			t.Error("completing a completed task does not succeed")
+		}
+		if third, _ := uc.GetTask(ctx, owner, ids[2]); third == nil || third.Completed {
+			t.Error("a task not named was completed")
+		}
+	})
+}
+
+func TestBulkDeleteTasks(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		uc := usecase.NewTaskUseCase(r)
+		ids := createMixed(t, uc)
+		result, err := uc.BulkDeleteTasks(context.Background(), owner, []int64{ids[1], 9999, ids[1]})
+		if got, want := outcome(result), "ok TASK_NOT_FOUND BULK_DUPLICATE_ID"; err != nil || got != want {
+			t.Errorf("outcome %q, %v; want %q", got, err, want)
+		}
+		if count(t, r) != 2 {
+			t.Errorf("%d tasks left, want 2", count(t, r))
+		}
+	})
+}
+
+func TestBulkItemsFailForTheirOwnReasons(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		uc := usecase.NewTaskUseCase(r)
+		ids := createMixed(t, uc)
+		other := &domain.User{ID: 2, Role: domain.RoleUser}
+		theirs, err := uc.CreateTask(ctx, other, usecase.CreateTaskInput{Title: "Not yours"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Another user's task fails like a missing one, between successes
+		result, err := uc.BulkCompleteTasks(ctx, owner, []int64{ids[0], theirs.ID, 9999, ids[1]})
+		if got, want := outcome(result), "ok TASK_NOT_FOUND TASK_NOT_FOUND ok"; err != nil || got != want {
+			t.Errorf("complete: outcome %q, %v; want %q", got, err, want)
+		}
+		if result.Succeeded != 2 || result.Failed != 2 {
+			t.Errorf("complete: %d succeeded and %d failed, want 2 and 2", result.Succeeded, result.Failed)
+		}
+		for i, item := range result.Items {
+			if item.Index != i {
+				t.Errorf("item %d reports index %d", i, item.Index)
+			}
+		}
+
+		result, err = uc.BulkDeleteTasks(ctx, owner, []int64{theirs.ID, ids[2]})
+		if got, want := outcome(result), "TASK_NOT_FOUND ok"; err != nil || got != want {
+			t.Errorf("delete: outcome %q, %v; want %q", got, err, want)
+		}
+		if kept, err := uc.GetTask(ctx, other, theirs.ID); err != nil || kept.Completed {
+			t.Errorf("the other user's task is now %+v, %v; want it untouched", kept, err)
+		}
+		if count(t, r) != 3 {
+			t.Errorf("%d tasks stored, want the owner's two left and the other user's", count(t, r))
+		}
+	})
+}
+
+func TestBulkGetTasks(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		uc := usecase.NewTaskUseCase(r)
+		ids := createMixed(t, uc)
+		if _, err := uc.BulkDeleteTasks(ctx, owner, []int64{ids[1]}); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := uc.BulkGetTasks(ctx, owner, []int64{ids[2], ids[1], ids[0], ids[2]})
+		if got, want := outcome(result), "ok TASK_NOT_FOUND ok BULK_DUPLICATE_ID"; err != nil || got != want {
+			t.Fatalf("outcome %q, %v; want %q", got, err, want)
+		}
+		if result.Items[0].Task.ID != ids[2] || result.Items[2].Task.ID != ids[0] {
+			t.Error("tasks are not in request order")
+		}
+
+		other := &domain.User{ID: 2, Role: domain.RoleUser}
+		theirs, err := uc.CreateTask(ctx, other, usecase.CreateTaskInput{Title: "Not yours"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, _ = uc.BulkGetTasks(ctx, owner, []int64{theirs.ID, ids[0]})
+		if got := outcome(result); got != "TASK_NOT_FOUND ok" {
+			t.Errorf("outcome %q: another user's task should not be found", got)
+		}
+	})
+}
+
+func TestBulkRejectsEmptyAndOversizedRequests(t *testing.T) {
+	uc := usecase.NewTaskUseCase(repository.NewMemoryTaskRepository())
+	if _, err := uc.BulkCreateTasks(context.Background(), owner, nil); !errors.Is(err, usecase.ErrBulkEmpty) {
+		t.Errorf("empty request: err = %v, want ErrBulkEmpty", err)
+	}
+	if _, err := uc.BulkDeleteTasks(context.Background(), owner, make([]int64, usecase.MaxBulkItems+1)); !errors.Is(err, usecase.ErrBulkTooLarge) {
+		t.Errorf("%d items: err = %v, want ErrBulkTooLarge", usecase.MaxBulkItems+1, err)
+	}
+	full := make([]usecase.CreateTaskInput, usecase.MaxBulkItems)
+	for i := range full {
+		full[i].Title = "Task"
+	}
+	if result, err := uc.BulkCreateTasks(context.Background(), owner, full); err != nil || result.Succeeded != usecase.MaxBulkItems {
+		t.Errorf("%d items: %d succeeded, err = %v; want all of them", usecase.MaxBulkItems, result.Succeeded, err)
+	}
+}
+
+func TestBulkGetTasksMakesOneRepositoryCall(t *testing.T) {
+	ctx := context.Background()
+	m := &mock.TaskRepository{GetByIDsFunc: func(context.Context, []int64) ([]*domain.Task, error) {
+		return []*domain.Task{{ID: 2, OwnerID: owner.ID}, {ID: 3, OwnerID: owner.ID}}, nil
+	}}
+	result, err := usecase.NewTaskUseCase(m).BulkGetTasks(ctx, owner, []int64{3, 1, 2})
+	if got := outcome(result); err != nil || got != "ok TASK_NOT_FOUND ok" {
+		t.Errorf("outcome %q, %v; want the found tasks in request order", got, err)
+	}
+	calls := m.Calls()
+	if len(calls) != 1 || calls[0].Method != "GetByIDs" || !slices.Equal(calls[0].IDs, []int64{3, 1, 2}) {
+		t.Errorf("calls %+v, want one GetByIDs with every id", calls)
+	}
+
+	m.GetByIDsFunc = func(context.Context, []int64) ([]*domain.Task, error) {
+		return nil, errors.New("connection refused")
+	}
+	if _, err := usecase.NewTaskUseCase(m).BulkGetTasks(ctx, owner, []int64{1}); err == nil {
+		t.Error("a failed lookup does not fail the request")
+	}
+}
+
+func TestBulkCreateRollsBackOnStorageFailure(t *testing.T) {
+	db := openSQLite(t)
+	r := repository.NewTaskRepository(db)
+	// A trigger stands in for a disk or constraint error on the third insert
+	if _, err := db.Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON tasks WHEN NEW.title = 'Explodes'
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END`); err != nil {
+		t.Fatal(err)
+	}
+	batch := []usecase.CreateTaskInput{{Title: "First"}, {Title: ""}, {Title: "Second"}, {Title: "Explodes"}}
+	result, err := usecase.NewTaskUseCase(r).BulkCreateTasks(context.Background(), owner, batch)
+	if got, want := outcome(result), "uncoded TASK_TITLE_EMPTY uncoded uncoded"; err != nil || got != want {
+		t.Errorf("outcome %q, %v; want %q: every valid item fails with the storage error", got, err, want)
+	}
+	if result.Succeeded != 0 || result.Items[0].ID != 0 {
+		t.Error("an item is reported with an id")
+	}
+	if count(t, r) != 0 {
+		t.Errorf("%d tasks stored; the inserts before the failure should be rolled back", count(t, r))
+	}
+}
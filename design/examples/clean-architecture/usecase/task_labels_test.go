@@ -0,0 +1,74 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// TestLabelsRoundTrip stores a labelled task, relabels it and reads it back
+// through each repository, so the mock-based tests' assumptions about what a
+// repository keeps hold for the real ones
+func TestLabelsRoundTrip(t *testing.T) {
+	taskRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		uc := usecase.NewTaskUseCase(r)
+		task, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Ship it", Priority: "High", Tags: []string{"release", "Ops", "ops"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		read := func(t *testing.T) *domain.Task {
+			t.Helper()
+			got, err := uc.GetTask(ctx, owner, task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return got
+		}
+		labels := func(task *domain.Task) string {
+			return string(task.Priority) + " " + strings.Join(task.Tags, ",")
+		}
+
+		if got := read(t); labels(got) != "high ops,release" {
+			t.Fatalf("read back as %s, want high ops,release", labels(got))
+		}
+		got := read(t)
+		got.Tags = append(got.Tags, "changed by the caller")
+		if again := read(t); labels(again) != "high ops,release" {
+			t.Errorf("a returned task shares its tags with the stored one: %s", labels(again))
+		}
+
+		steps := []struct {
+			name  string
+			input usecase.UpdateTaskInput
+			err   error
+			want  string // the labels read back afterwards
+		}{
+			{"an update without them keeps them", usecase.UpdateTaskInput{Title: "Ship it", Completed: true}, nil, "high ops,release"},
+			{"an update with them replaces them", usecase.UpdateTaskInput{Title: "Ship it", Priority: "low", Tags: []string{"done"}}, nil, "low done"},
+			{"an empty list removes every tag", usecase.UpdateTaskInput{Title: "Ship it", Tags: []string{}}, nil, "low "},
+			{"an invalid priority stores nothing", usecase.UpdateTaskInput{Title: "Changed", Priority: "urgent"}, domain.ErrInvalidPriority, "low "},
+		}
+		for _, step := range steps {
+			step.input.ID = task.ID
+			if _, err := uc.UpdateTask(ctx, owner, step.input); !errors.Is(err, step.err) {
+				t.Fatalf("%s: UpdateTask = %v, want %v", step.name, err, step.err)
+			}
+			if got := read(t); labels(got) != step.want {
+				t.Errorf("%s: read back as %q, want %q", step.name, labels(got), step.want)
+			}
+		}
+
+		plain, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Plain"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if labels(plain) != "medium " {
+			t.Errorf("a task created without labels is %q, want medium and untagged", labels(plain))
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Reads and writes are split (CQRS). TaskUseCase changes tasks and publishes
+// each change; TaskQueryService projects those changes into a read model
+// and answers from it, never from the repository. The read model is
+// eventually consistent: a change is in it once its event has been applied,
+// a moment after the write returned. It holds every tenant's tasks, and
+// answers each query for the tenant of its ctx.
+
+const (
+	DefaultActivityLimit = 20
+	MaxActivityLimit     = 100
+)
+
+var ErrInvalidActivityLimit = domain.NewError("TASK_ACTIVITY_LIMIT_INVALID", domain.KindInvalid, "activity limit must be 1 to 100")
+
+type TaskQueryService struct {
+	readModel domain.TaskReadModel
+	tasks     domain.TaskRepository
+	events    domain.TaskEvents
+	logf      func(format string, args ...any)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTaskQueryService returns a service answering from readModel, once
+// Start has filled it from tasks and follows events. logf may be nil.
+func NewTaskQueryService(readModel domain.TaskReadModel, tasks domain.TaskRepository, events domain.TaskEvents, logf func(format string, args ...any)) *TaskQueryService {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	return &TaskQueryService{readModel: readModel, tasks: tasks, events: events, logf: logf}
+}
+
+// Summary counts the tasks the actor may view: a user's own, or for an
+// admin, everyone's or ownerID's
+func (s *TaskQueryService) Summary(ctx context.Context, actor *domain.User, ownerID int64) (_ domain.TaskSummary, err error) {
+	ctx, span := startSpan(ctx, "TaskQueryService.Summary", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	query, err := scopeListing(actor, domain.ListTasksQuery{OwnerID: ownerID})
+	if err != nil {
+		return domain.TaskSummary{}, err
+	}
+	return s.readModel.Summary(ctx, query.OwnerID)
+}
+
+// RecentActivity returns up to limit of the latest changes to the tasks the
+// actor may view, scoped like Summary, newest first. A limit of 0 is
+// DefaultActivityLimit.
+func (s *TaskQueryService) RecentActivity(ctx context.Context, actor *domain.User, ownerID int64, limit int) (_ []domain.TaskActivity, err error) {
+	ctx, span := startSpan(ctx, "TaskQueryService.RecentActivity", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if limit == 0 {
+		limit = DefaultActivityLimit
+	}
+	if limit < 0 || limit > MaxActivityLimit {
+		return nil, ErrInvalidActivityLimit
+	}
+	query, err := scopeListing(actor, domain.ListTasksQuery{OwnerID: ownerID})
+	if err != nil {
+		return nil, err
+	}
+	return s.readModel.RecentActivity(ctx, query.OwnerID, limit)
+}
+
+// Start follows the task events and fills the read model from the stored
+// tasks, then applies the events in a goroutine until Stop. It follows
+// first, so no change made while the tasks are read is missed; one already
+// in them is seen again, and ignored. Its signature is that of a
+// shutdown.Component's Start; ctx bounds reading the tasks.
+func (s *TaskQueryService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	events, err := s.events.Follow(runCtx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	tasks, err := s.tasks.List(domain.WithTenant(ctx, domain.AllTenants), domain.ListTasksQuery{})
+	if err == nil {
+		err = s.readModel.Reset(ctx, tasks)
+	}
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		s.project(runCtx, events)
+	}()
+	return nil
+}
+
+// project applies events until the channel closes: on Stop, or when the
+// events stop being carried on shutdown
+func (s *TaskQueryService) project(ctx context.Context, events <-chan domain.TaskEvent) {
+	for event := range events {
+		if err := s.readModel.Apply(ctx, event); err != nil && ctx.Err() == nil {
+			s.logf("read model: applying %s of task %d: %v", event.Type, event.Task.ID, err)
+		}
+	}
+}
+
+// Stop stops applying events and waits for the goroutine to return, or for
+// ctx. The read model keeps answering, from what it had.
+func (s *TaskQueryService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,241 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// counts is a summary without its priorities, to compare at a glance
+type counts struct{ total, open, completed int }
+
+func countsOf(s domain.TaskSummary) counts {
+	return counts{s.Total, s.Open, s.Completed}
+}
+
+// storedCounts counts owner's tasks in the repository, the write side, or
+// everyone's for 0
+func storedCounts(t *testing.T, repo domain.TaskRepository, owner int64) counts {
+	t.Helper()
+	tasks, err := repo.List(context.Background(), domain.ListTasksQuery{OwnerID: owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n counts
+	for _, task := range tasks {
+		n.total++
+		if task.Completed {
+			n.completed++
+		} else {
+			n.open++
+		}
+	}
+	return n
+}
+
+// summarized polls the summary until it equals want, for up to 5 seconds,
+// and returns the last one
+func summarized(t *testing.T, queries *usecase.TaskQueryService, actor *domain.User, want counts) counts {
+	t.Helper()
+	start := time.Now()
+	for {
+		summary, err := queries.Summary(context.Background(), actor, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := countsOf(summary)
+		if got == want || time.Since(start) > 5*time.Second {
+			return got
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// heldReadModel applies nothing while held
+type heldReadModel struct {
+	domain.TaskReadModel
+	mu sync.RWMutex
+}
+
+func (m *heldReadModel) Apply(ctx context.Context, e domain.TaskEvent) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.TaskReadModel.Apply(ctx, e)
+}
+
+func TestReadModelCatchesUp(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryTaskRepository()
+	bus := infrastructure.NewTaskEventBus()
+	defer bus.Close(ctx)
+	tasks := usecase.NewTaskUseCaseWithEvents(repo, bus)
+	model := &heldReadModel{TaskReadModel: repository.NewMemoryTaskReadModel(nil)}
+	queries := usecase.NewTaskQueryService(model, repo, bus, nil)
+	if err := queries.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer queries.Stop(ctx)
+
+	// Hold the read model: writes go on, and reads answer what it had
+	model.mu.Lock()
+	for i := 0; i < 10; i++ {
+		if _, err := tasks.CreateTask(ctx, alice, usecase.CreateTaskInput{Title: fmt.Sprintf("Task %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summary, err := queries.Summary(ctx, alice, 0)
+	if err != nil || countsOf(summary) != (counts{}) {
+		t.Errorf("with the read model held, the summary = %+v, %v; want none counted yet", countsOf(summary), err)
+	}
+	model.mu.Unlock()
+	if got := summarized(t, queries, alice, counts{10, 10, 0}); got != (counts{10, 10, 0}) {
+		t.Errorf("released, it counts %+v, want all ten", got)
+	}
+
+	// Writers at once, on every path that changes tasks
+	var wg sync.WaitGroup
+	for _, user := range []*domain.User{alice, bob} {
+		wg.Add(1)
+		go func(user *domain.User) {
+			defer wg.Done()
+			var inputs []usecase.CreateTaskInput
+			for i := 0; i < 50; i++ {
+				inputs = append(inputs, usecase.CreateTaskInput{Title: fmt.Sprintf("Bulk %d", i)})
+			}
+			result, err := tasks.BulkCreateTasks(ctx, user, inputs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var ids []int64
+			for _, item := range result.Items {
+				ids = append(ids, item.Task.ID)
+			}
+			if _, err := tasks.BulkCompleteTasks(ctx, user, ids[:20]); err != nil {
+				t.Error(err)
+			}
+			if _, err := tasks.BulkDeleteTasks(ctx, user, ids[40:]); err != nil {
+				t.Error(err)
+			}
+			for _, id := range ids[:5] {
+				task, err := tasks.GetTask(ctx, user, id)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := tasks.UpdateTask(ctx, user, usecase.UpdateTaskInput{ID: id, Title: task.Title, Completed: false}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(user)
+	}
+	wg.Wait()
+	for _, owner := range []*domain.User{alice, bob} {
+		want := storedCounts(t, repo, owner.ID)
+		if got := summarized(t, queries, owner, want); got != want {
+			t.Errorf("user %d: after concurrent bulk writes the read model counts %+v, the repository %+v", owner.ID, got, want)
+		}
+	}
+	if want, got := storedCounts(t, repo, 0), summarized(t, queries, admin, storedCounts(t, repo, 0)); got != want {
+		t.Errorf("everyone's counts = %+v, want %+v", got, want)
+	}
+
+	activity, err := queries.RecentActivity(ctx, bob, 0, 3)
+	if err != nil || len(activity) != 3 || activity[0].Kind != domain.ActivityReopened || activity[0].OwnerID != bob.ID {
+		t.Errorf("Bob's latest activity = %+v, %v; want his reopened tasks", activity, err)
+	}
+}
+
+func TestReadModelStartsOnStoredTasks(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryTaskRepository()
+	bus := infrastructure.NewTaskEventBus()
+	defer bus.Close(ctx)
+	tasks := usecase.NewTaskUseCaseWithEvents(repo, bus)
+	for i := 0; i < 5; i++ {
+		task, err := tasks.CreateTask(ctx, alice, usecase.CreateTaskInput{Title: fmt.Sprintf("Before %d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 2 {
+			if _, err := tasks.CompleteTask(ctx, alice, task.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Writes go on while the read model starts
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, err := tasks.CreateTask(ctx, alice, usecase.CreateTaskInput{Title: fmt.Sprintf("During %d", i)}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	queries := usecase.NewTaskQueryService(repository.NewMemoryTaskReadModel(nil), repo, bus, nil)
+	if err := queries.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer queries.Stop(ctx)
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	want := storedCounts(t, repo, alice.ID)
+	if got := summarized(t, queries, alice, want); got != want || want.completed != 2 || want.total <= 5 {
+		t.Errorf("started after 5 tasks were stored and while more were created, it counts %+v, want every one, %+v", got, want)
+	}
+	if err := queries.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tasks.CreateTask(ctx, alice, usecase.CreateTaskInput{Title: "After stop"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if summary, err := queries.Summary(ctx, alice, 0); err != nil || countsOf(summary) != want {
+		t.Errorf("stopped, it counts %+v, %v; want it to answer from what it had, %+v", countsOf(summary), err, want)
+	}
+}
+
+func TestTaskQueryScope(t *testing.T) {
+	ctx := context.Background()
+	queries := usecase.NewTaskQueryService(repository.NewMemoryTaskReadModel(nil), repository.NewMemoryTaskRepository(), nil, nil)
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"a user asking for another user's summary", func() error { _, err := queries.Summary(ctx, alice, bob.ID); return err }, usecase.ErrForbidden},
+		{"a user naming themselves", func() error { _, err := queries.Summary(ctx, alice, alice.ID); return err }, nil},
+		{"an admin reading anyone's activity", func() error { _, err := queries.RecentActivity(ctx, admin, bob.ID, 10); return err }, nil},
+		{"more activities than the limit", func() error {
+			_, err := queries.RecentActivity(ctx, alice, 0, usecase.MaxActivityLimit+1)
+			return err
+		}, usecase.ErrInvalidActivityLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
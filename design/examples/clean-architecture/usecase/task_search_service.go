@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// Full-text search is answered from a search index, projected from the task
+// events like the read model of TaskQueryService. The index may outlive the
+// server, on disk: Start then keeps what it has and only fills an empty one.
+// A change the server made but did not index, because it stopped without
+// shutting down, is missing until the index is rebuilt (cmd/reindex).
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+var ErrInvalidSearchLimit = domain.NewError("TASK_SEARCH_LIMIT_INVALID", domain.KindInvalid, "search limit must be 1 to 100, and offset not negative")
+
+type TaskSearchService struct {
+	index  domain.TaskSearchIndex
+	tasks  domain.TaskRepository
+	events domain.TaskEvents
+	logf   func(format string, args ...any)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTaskSearchService returns a service answering from index, once Start
+// follows events. logf may be nil.
+func NewTaskSearchService(index domain.TaskSearchIndex, tasks domain.TaskRepository, events domain.TaskEvents, logf func(format string, args ...any)) *TaskSearchService {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	return &TaskSearchService{index: index, tasks: tasks, events: events, logf: logf}
+}
+
+// Search returns the tasks the actor may view that match query.Text, best
+// first, scoped like ListTasks: a user's own, or for an admin, everyone's or
+// query.OwnerID's. A Limit of 0 is DefaultSearchLimit.
+func (s *TaskSearchService) Search(ctx context.Context, actor *domain.User, query domain.TaskSearchQuery) (_ domain.TaskSearchResult, err error) {
+	ctx, span := startSpan(ctx, "TaskSearchService.Search", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return domain.TaskSearchResult{}, domain.ErrSearchQueryEmpty
+	}
+	if len(query.Text) > domain.MaxSearchQueryLength {
+		return domain.TaskSearchResult{}, domain.ErrSearchQueryTooLong
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	if query.Limit < 0 || query.Limit > MaxSearchLimit || query.Offset < 0 {
+		return domain.TaskSearchResult{}, ErrInvalidSearchLimit
+	}
+	scoped, err := scopeListing(actor, domain.ListTasksQuery{OwnerID: query.OwnerID})
+	if err != nil {
+		return domain.TaskSearchResult{}, err
+	}
+	query.OwnerID = scoped.OwnerID
+	return s.index.Search(ctx, query)
+}
+
+// Rebuild replaces the whole index with the tasks stored now, of every
+// tenant
+func (s *TaskSearchService) Rebuild(ctx context.Context) (indexed int, err error) {
+	ctx, span := startSpan(ctx, "TaskSearchService.Rebuild")
+	defer endSpan(span, &err)
+	tasks, err := s.tasks.List(domain.WithTenant(ctx, domain.AllTenants), domain.ListTasksQuery{})
+	if err != nil {
+		return 0, err
+	}
+	return len(tasks), s.index.Reset(ctx, tasks)
+}
+
+// Start follows the task events and, if the index is empty, fills it from
+// the stored tasks, then applies the events in a goroutine until Stop. Like
+// TaskQueryService.Start it follows first, so no change is missed while the
+// tasks are read. Its signature is that of a shutdown.Component's Start;
+// ctx bounds filling the index.
+func (s *TaskSearchService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	events, err := s.events.Follow(runCtx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	n, err := s.index.Count(ctx)
+	if err == nil && n == 0 {
+		n, err = s.Rebuild(ctx)
+		s.logf("search: indexed %d tasks", n)
+	}
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		s.project(runCtx, events)
+	}()
+	return nil
+}
+
+// project applies events until the channel closes: on Stop, or when the
+// events stop being carried on shutdown
+func (s *TaskSearchService) project(ctx context.Context, events <-chan domain.TaskEvent) {
+	for event := range events {
+		if err := s.index.Apply(ctx, event); err != nil && ctx.Err() == nil {
+			s.logf("search: indexing %s of task %d: %v", event.Type, event.Task.ID, err)
+		}
+	}
+}
+
+// Stop stops applying events and waits for the goroutine to return, or for
+// ctx. The index keeps answering, from what it had.
+func (s *TaskSearchService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
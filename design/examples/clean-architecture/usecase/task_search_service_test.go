@@ -0,0 +1,102 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/fulltext"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+func TestTaskSearchService(t *testing.T) {
+	ctx := context.Background()
+	ann := &domain.User{ID: 1, Role: domain.RoleUser, TenantID: domain.DefaultTenant}
+	bob := &domain.User{ID: 2, Role: domain.RoleUser, TenantID: domain.DefaultTenant}
+	admin := &domain.User{ID: 3, Role: domain.RoleAdmin, TenantID: domain.DefaultTenant}
+
+	repo := repository.NewMemoryTaskRepository()
+	events := infrastructure.NewTaskEventBus()
+	defer events.Close(ctx)
+	tasks := usecase.NewTaskUseCaseWithEvents(repo, events)
+	if _, err := tasks.CreateTask(ctx, ann, usecase.CreateTaskInput{Title: "Stored before the start"}); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := fulltext.NewMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := usecase.NewTaskSearchService(index, repo, events, nil)
+	if err := search.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer search.Stop(ctx)
+
+	// found is the number of hits, -1 if the search fails
+	found := func(actor *domain.User, text string, owner int64) int {
+		result, err := search.Search(ctx, actor, domain.TaskSearchQuery{Text: text, OwnerID: owner})
+		if err != nil {
+			return -1
+		}
+		return result.Total
+	}
+	if n := found(ann, "stored", 0); n != 1 {
+		t.Errorf("after Start, a stored task is found %d times, want the empty index filled", n)
+	}
+	for _, c := range []struct {
+		owner *domain.User
+		title string
+	}{
+		{ann, "Ann's groceries"},
+		{bob, "Bob's groceries"},
+	} {
+		if _, err := tasks.CreateTask(ctx, c.owner, usecase.CreateTaskInput{Title: c.title, Description: "apples"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !waitFor(func() bool { return found(admin, "apples", 0) == 2 }) {
+		t.Fatal("the tasks created after Start were not indexed")
+	}
+
+	scopes := []struct {
+		name  string
+		actor *domain.User
+		owner int64
+		want  int
+	}{
+		{"a user, their own", ann, 0, 1},
+		{"an admin, everyone's", admin, 0, 2},
+		{"an admin, one owner's", admin, bob.ID, 1},
+	}
+	for _, tt := range scopes {
+		if n := found(tt.actor, "apples", tt.owner); n != tt.want {
+			t.Errorf("searching as %s = %d hits, want %d", tt.name, n, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		name  string
+		actor *domain.User
+		query domain.TaskSearchQuery
+		want  error
+	}{
+		{"another user's tasks", ann, domain.TaskSearchQuery{Text: "apples", OwnerID: bob.ID}, usecase.ErrForbidden},
+		{"blank text", admin, domain.TaskSearchQuery{Text: "  "}, domain.ErrSearchQueryEmpty},
+		{"text too long", admin, domain.TaskSearchQuery{Text: strings.Repeat("a", domain.MaxSearchQueryLength+1)}, domain.ErrSearchQueryTooLong},
+		{"a limit over the most", admin, domain.TaskSearchQuery{Text: "apples", Limit: usecase.MaxSearchLimit + 1}, usecase.ErrInvalidSearchLimit},
+		{"a negative offset", admin, domain.TaskSearchQuery{Text: "apples", Offset: -1}, usecase.ErrInvalidSearchLimit},
+	}
+	for _, tt := range invalid {
+		if _, err := search.Search(ctx, tt.actor, tt.query); !errors.Is(err, tt.want) {
+			t.Errorf("searching %s = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if result, err := search.Search(ctx, admin, domain.TaskSearchQuery{Text: "apples groceries stored"}); err != nil || len(result.Hits) != 3 {
+		t.Errorf("searching with a limit of 0 = %d hits, %v, want the default limit", len(result.Hits), err)
+	}
+}
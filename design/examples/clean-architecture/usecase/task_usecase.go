@@ -1,27 +1,58 @@
 package usecase
 
 import (
+"context"
+"database/sql"
 "errors"
+"fmt"
+"slices"
+"time"
+
 "github.com/dong-tran/docs/clean-architecture-example/domain"
 )
 
 var (
-ErrTaskNotFound = errors.New("task not found")
+ErrTaskNotFound = domain.NewError("TASK_NOT_FOUND", domain.KindNotFound, "task not found")
 )
 
 type TaskUseCase struct {
 	taskRepo domain.TaskRepository
+	events   domain.TaskEvents
+	clock    domain.Clock
 }
 
 func NewTaskUseCase(taskRepo domain.TaskRepository) *TaskUseCase {
+	return NewTaskUseCaseWithEvents(taskRepo, noEvents{})
+}
+
+// NewTaskUseCaseWithEvents returns use cases that publish every change they
+// store to events, and let users watch them (see WatchTasks)
+func NewTaskUseCaseWithEvents(taskRepo domain.TaskRepository, events domain.TaskEvents) *TaskUseCase {
+	return NewTaskUseCaseWithClock(taskRepo, events, domain.SystemClock)
+}
+
+// NewTaskUseCaseWithClock returns use cases that stamp every change with the
+// time of clock, such as a fake one. Nil events carry none.
+func NewTaskUseCaseWithClock(taskRepo domain.TaskRepository, events domain.TaskEvents, clock domain.Clock) *TaskUseCase {
+	if events == nil {
+		events = noEvents{}
+	}
 	return &TaskUseCase{
 		taskRepo: taskRepo,
+		events:   events,
+		clock:    clock,
 	}
 }
 
 type CreateTaskInput struct {
 	Title       string
 	Description string
+	Priority    string // empty means medium
+	Tags        []string
+	DueAt       time.Time // zero means not due
+	// ParentID makes the task a subtask of that one, which the actor must be
+	// allowed to change. 0 means a top-level task.
+	ParentID int64
 }
 
 type UpdateTaskInput struct {
@@ -29,70 +60,243 @@ type UpdateTaskInput struct {
 	Title       string
 	Description string
 	Completed   bool
+	Priority    string   // empty keeps the current priority
+	Tags        []string // nil keeps the current tags, empty removes them
+	// Version is that of the task the change was made to. It conflicts if the
+	// task has changed since; 0 applies the change to the task as it is now.
+	Version int64
+}
+
+// label applies the optional priority and tags of an input, now
+func label(task *domain.Task, priority string, tags []string, now time.Time) error {
+	if priority != "" {
+		p, err := domain.ParsePriority(priority)
+		if err != nil {
+			return err
+		}
+		if err := task.SetPriority(p, now); err != nil {
+			return err
+		}
+	}
+	if tags != nil {
+		return task.SetTags(tags, now)
+	}
+	return nil
 }
 
-func (uc *TaskUseCase) CreateTask(input CreateTaskInput) (*domain.Task, error) {
-	task, err := domain.NewTask(input.Title, input.Description)
+// Every use case acts for a user, the actor, and applies the policy in
+// policy.go. A task the actor may not view is reported as ErrTaskNotFound,
+// exactly like a missing one, so IDs reveal nothing about other accounts.
+
+// visible returns the task with id if the actor may view it. A lookup cut
+// short by ctx is reported as such, and any other failure of the repository
+// is returned wrapped, not as a missing task.
+func (uc *TaskUseCase) visible(ctx context.Context, actor *domain.User, id int64) (*domain.Task, error) {
+	task, err := uc.taskRepo.GetByID(ctx, id)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("get task %d: %w", id, err)
+	}
+	if !canView(actor, task) {
+		return nil, ErrTaskNotFound
 	}
+	return task, nil
+}
 
-	if err := uc.taskRepo.Create(task); err != nil {
-		return nil, err
+// attach makes task a subtask of the task with parentID, unless that is 0.
+// The parent is one the actor may change, so it has the subtask's owner.
+func (uc *TaskUseCase) attach(ctx context.Context, actor *domain.User, task *domain.Task, parentID int64) error {
+	if parentID == 0 {
+		return nil
 	}
+	parent, err := uc.modifiable(ctx, actor, parentID)
+	if err != nil {
+		return err
+	}
+	task.ParentID = parent.ID
+	return nil
+}
 
+// modifiable returns the task with id if the actor may change it
+func (uc *TaskUseCase) modifiable(ctx context.Context, actor *domain.User, id int64) (*domain.Task, error) {
+	task, err := uc.visible(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canModify(actor, task) {
+		return nil, ErrForbidden
+	}
 	return task, nil
 }
 
-func (uc *TaskUseCase) GetTask(id int64) (*domain.Task, error) {
-	task, err := uc.taskRepo.GetByID(id)
+func (uc *TaskUseCase) CreateTask(ctx context.Context, actor *domain.User, input CreateTaskInput) (_ *domain.Task, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.CreateTask", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	now := uc.clock.Now()
+	task, err := domain.NewTask(actor.ID, input.Title, input.Description, now)
 	if err != nil {
-		return nil, ErrTaskNotFound
+		return nil, err
+	}
+	if err := label(task, input.Priority, input.Tags, now); err != nil {
+		return nil, err
+	}
+	if err := task.SetDue(input.DueAt, now); err != nil {
+		return nil, err
+	}
+	if err := uc.attach(ctx, actor, task, input.ParentID); err != nil {
+		return nil, err
 	}
+	task.Record(domain.TaskCreatedEvent, now)
+
+	if err := uc.taskRepo.Create(ctx, task); err != nil {
+		return nil, err
+	}
+	uc.publish(ctx, domain.TaskCreated, task)
+
 	return task, nil
 }
 
-func (uc *TaskUseCase) GetAllTasks() ([]*domain.Task, error) {
-	return uc.taskRepo.GetAll()
+func (uc *TaskUseCase) GetTask(ctx context.Context, actor *domain.User, id int64) (_ *domain.Task, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.GetTask", actorAttr(actor.ID), taskAttr(id))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	return uc.visible(ctx, actor, id)
 }
 
-func (uc *TaskUseCase) UpdateTask(input UpdateTaskInput) (*domain.Task, error) {
-	task, err := uc.taskRepo.GetByID(input.ID)
+// ListTasks returns the tasks matching query, newest first. A user lists
+// their own tasks; an admin lists everyone's, or one owner's with
+// query.OwnerID.
+func (uc *TaskUseCase) ListTasks(ctx context.Context, actor *domain.User, query domain.ListTasksQuery) (_ []*domain.Task, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.ListTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	query, err = query.Normalize()
 	if err != nil {
-		return nil, ErrTaskNotFound
+		return nil, err
+	}
+	if query, err = scopeListing(actor, query); err != nil {
+		return nil, err
 	}
+	return uc.taskRepo.List(ctx, query)
+}
 
-	if err := task.Update(input.Title, input.Description, input.Completed); err != nil {
+func (uc *TaskUseCase) UpdateTask(ctx context.Context, actor *domain.User, input UpdateTaskInput) (_ *domain.Task, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.UpdateTask", actorAttr(actor.ID), taskAttr(input.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	task, err := uc.modifiable(ctx, actor, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Version != 0 && input.Version != task.Version {
+		return nil, domain.ErrVersionConflict
+	}
+
+	now := uc.clock.Now()
+	wasCompleted := task.Completed
+	if err := task.Update(input.Title, input.Description, input.Completed, now); err != nil {
+		return nil, err
+	}
+	if err := label(task, input.Priority, input.Tags, now); err != nil {
 		return nil, err
 	}
+	if task.Completed && !wasCompleted {
+		task.Record(domain.TaskCompletedEvent, now)
+	}
 
-	if err := uc.taskRepo.Update(task); err != nil {
+	if err := uc.taskRepo.Update(ctx, task); err != nil {
 		return nil, err
 	}
+	uc.publish(ctx, domain.TaskUpdated, task)
 
 	return task, nil
 }
 
-func (uc *TaskUseCase) DeleteTask(id int64) error {
-	_, err := uc.taskRepo.GetByID(id)
+func (uc *TaskUseCase) DeleteTask(ctx context.Context, actor *domain.User, id int64) (err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.DeleteTask", actorAttr(actor.ID), taskAttr(id))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	task, err := uc.modifiable(ctx, actor, id)
 	if err != nil {
-		return ErrTaskNotFound
+		return err
 	}
 
-	return uc.taskRepo.Delete(id)
+	if err := uc.taskRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	uc.publish(ctx, domain.TaskDeleted, task)
+	return nil
 }
 
-func (uc *TaskUseCase) CompleteTask(id int64) (*domain.Task, error) {
-	task, err := uc.taskRepo.GetByID(id)
+func (uc *TaskUseCase) CompleteTask(ctx context.Context, actor *domain.User, id int64) (_ *domain.Task, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.CompleteTask", actorAttr(actor.ID), taskAttr(id))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	task, err := uc.modifiable(ctx, actor, id)
 	if err != nil {
-		return nil, ErrTaskNotFound
+		return nil, err
 	}
 
-	task.MarkAsCompleted()
+	// Completing a completed task changes nothing worth telling anyone
+	now := uc.clock.Now()
+	if !task.Completed {
+		task.Record(domain.TaskCompletedEvent, now)
+	}
+	task.MarkAsCompleted(now)
 
-	if err := uc.taskRepo.Update(task); err != nil {
+	if err := uc.taskRepo.Update(ctx, task); err != nil {
 		return nil, err
 	}
+	uc.publish(ctx, domain.TaskUpdated, task)
 
 	return task, nil
 }
+
+// WatchTasks delivers the events of the tasks the actor may view as they
+// happen, until ctx is done: a user watches their own tasks; an admin
+// watches everyone's, or one owner's with ownerID. It is scoped like
+// ListTasks, so a user naming another owner gets ErrForbidden.
+func (uc *TaskUseCase) WatchTasks(ctx context.Context, actor *domain.User, ownerID int64) (_ <-chan domain.TaskEvent, err error) {
+	ctx, span := startSpan(ctx, "TaskUseCase.WatchTasks", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	query, err := scopeListing(actor, domain.ListTasksQuery{OwnerID: ownerID})
+	if err != nil {
+		return nil, err
+	}
+	return uc.events.Subscribe(ctx, query.OwnerID)
+}
+
+// publish reports a stored change. The event gets its own copy of the task,
+// so the caller may go on changing it.
+func (uc *TaskUseCase) publish(ctx context.Context, typ domain.TaskEventType, task *domain.Task) {
+	event := domain.TaskEvent{Type: typ, Task: *task}
+	event.Task.Tags = slices.Clone(task.Tags)
+	event.Task.ClearEvents()
+	uc.events.Publish(ctx, event)
+}
+
+// noEvents is the TaskEvents of use cases built without any: nothing is
+// carried, and watchers see no events until they stop watching
+type noEvents struct{}
+
+func (noEvents) Publish(context.Context, domain.TaskEvent) {}
+
+func (noEvents) Subscribe(ctx context.Context, ownerID int64) (<-chan domain.TaskEvent, error) {
+	ch := make(chan domain.TaskEvent)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+func (e noEvents) Follow(ctx context.Context) (<-chan domain.TaskEvent, error) {
+	return e.Subscribe(ctx, 0)
+}
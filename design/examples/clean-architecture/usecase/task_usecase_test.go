@@ -0,0 +1,588 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/repository/mock"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+// The tests below check every TaskUseCase method on its own, against
+// mock.TaskRepository instead of a real repository. Each method has a table
+// of cases: success, a task that is not found or not the actor's, input the
+// domain rejects, and a repository failing. Each case states the error it
+// expects, what the use case returns, the exact repository calls it makes,
+// and the events it publishes.
+
+var (
+	alice = &domain.User{ID: 1, Role: domain.RoleUser}
+	bob   = &domain.User{ID: 2, Role: domain.RoleUser}
+	admin = &domain.User{ID: 3, Role: domain.RoleAdmin}
+)
+
+// errBoom is the repository failing, with an error the use cases know
+// nothing about
+var errBoom = errors.New("connection reset by peer")
+
+// The stored tasks: Alice's open and completed ones, and Bob's
+var (
+	report = &domain.Task{ID: 1, OwnerID: alice.ID, Title: "Write the report", Priority: domain.PriorityMedium, Tags: []string{"work"}, Version: 2}
+	filed  = &domain.Task{ID: 3, OwnerID: alice.ID, Title: "File taxes", Completed: true, Priority: domain.PriorityHigh, Version: 4}
+	bobs   = &domain.Task{ID: 2, OwnerID: bob.ID, Title: "Bob's task", Priority: domain.PriorityLow, Version: 1}
+)
+
+// testCase is one row of a table. repo sets up the mock's answers; the
+// repository calls and events are compared as text (see describeCalls),
+// and result only if it is set.
+type testCase struct {
+	name     string
+	actor    *domain.User // alice if nil
+	canceled bool         // run with a canceled context
+	repo     func(r *mock.TaskRepository)
+	run      func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error)
+	want     error
+	result   string
+	calls    string
+	events   string
+}
+
+// recordingEvents is a domain.TaskEvents remembering what is published
+type recordingEvents struct {
+	published []string
+}
+
+func (e *recordingEvents) Publish(_ context.Context, event domain.TaskEvent) {
+	e.published = append(e.published, fmt.Sprintf("%s #%d", event.Type, event.Task.ID))
+}
+
+func (e *recordingEvents) Subscribe(ctx context.Context, ownerID int64) (<-chan domain.TaskEvent, error) {
+	ch := make(chan domain.TaskEvent)
+	close(ch)
+	return ch, nil
+}
+
+func (e *recordingEvents) Follow(ctx context.Context) (<-chan domain.TaskEvent, error) {
+	return e.Subscribe(ctx, 0)
+}
+
+func runTable(t *testing.T, cases []testCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &mock.TaskRepository{}
+			if tc.repo != nil {
+				tc.repo(repo)
+			}
+			events := &recordingEvents{}
+			uc := usecase.NewTaskUseCaseWithEvents(repo, events)
+			actor := tc.actor
+			if actor == nil {
+				actor = alice
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.canceled {
+				cancel()
+			}
+			result, err := tc.run(ctx, uc, actor)
+			cancel()
+
+			if tc.want == nil && err != nil {
+				t.Errorf("error %v", err)
+			}
+			if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Errorf("error %v, want %v", err, tc.want)
+			}
+			if tc.result != "" && result != tc.result {
+				t.Errorf("result %s, want %s", result, tc.result)
+			}
+			if calls := describeCalls(repo.Calls()); calls != tc.calls {
+				t.Errorf("calls %q, want %q", calls, tc.calls)
+			}
+			if published := strings.Join(events.published, " "); published != tc.events {
+				t.Errorf("events %q, want %q", published, tc.events)
+			}
+		})
+	}
+}
+
+// describeTask shows what a use case can get wrong about a task: its ID,
+// owner, title, status, labels, version, parent and recorded events
+func describeTask(task *domain.Task) string {
+	status := "open"
+	if task.Completed {
+		status = "done"
+	}
+	text := fmt.Sprintf("#%d owner %d %q %s %s %v v%d", task.ID, task.OwnerID, task.Title, status, task.Priority, task.Tags, task.Version)
+	if task.ParentID != 0 {
+		text += fmt.Sprintf(" parent %d", task.ParentID)
+	}
+	for _, event := range task.PendingEvents() {
+		text += " +" + string(event.Name)
+	}
+	return text
+}
+
+func describeCalls(calls []mock.Call) string {
+	parts := make([]string, len(calls))
+	for i, call := range calls {
+		switch call.Method {
+		case "GetByID", "Delete":
+			parts[i] = fmt.Sprintf("%s(%d)", call.Method, call.ID)
+		case "List":
+			parts[i] = fmt.Sprintf("List(owner %d)", call.Query.OwnerID)
+		case "Create", "Update":
+			parts[i] = fmt.Sprintf("%s(%s)", call.Method, describeTask(call.Task))
+		case "CreateBatch":
+			titles := make([]string, len(call.Tasks))
+			for j, task := range call.Tasks {
+				titles[j] = fmt.Sprintf("%q", task.Title)
+			}
+			parts[i] = fmt.Sprintf("CreateBatch(%s)", strings.Join(titles, " "))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// The answers of a repository that works
+
+func stored(r *mock.TaskRepository) {
+	r.GetByIDFunc = mock.Stored(report, filed, bobs)
+}
+
+func storedAndWritable(r *mock.TaskRepository) {
+	stored(r)
+	r.UpdateFunc = func(_ context.Context, task *domain.Task) error {
+		task.Version++
+		return nil
+	}
+	r.DeleteFunc = func(context.Context, int64) error { return nil }
+}
+
+func numbered(first int64) func(ctx context.Context, tasks []*domain.Task) error {
+	return func(_ context.Context, tasks []*domain.Task) error {
+		for i, task := range tasks {
+			task.ID = first + int64(i)
+		}
+		return nil
+	}
+}
+
+func failing(err error) func(context.Context, *domain.Task) error {
+	return func(context.Context, *domain.Task) error { return err }
+}
+
+// Runs of each method, reporting what it returned
+
+func create(input usecase.CreateTaskInput) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		task, err := uc.CreateTask(ctx, actor, input)
+		if err != nil {
+			return "", err
+		}
+		return describeTask(task), nil
+	}
+}
+
+func get(id int64) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		task, err := uc.GetTask(ctx, actor, id)
+		if err != nil {
+			return "", err
+		}
+		return describeTask(task), nil
+	}
+}
+
+func list(query domain.ListTasksQuery) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		tasks, err := uc.ListTasks(ctx, actor, query)
+		return fmt.Sprintf("%d tasks", len(tasks)), err
+	}
+}
+
+func update(input usecase.UpdateTaskInput) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		task, err := uc.UpdateTask(ctx, actor, input)
+		if err != nil {
+			return "", err
+		}
+		return describeTask(task), nil
+	}
+}
+
+func remove(id int64) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		return "", uc.DeleteTask(ctx, actor, id)
+	}
+}
+
+func complete(id int64) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		task, err := uc.CompleteTask(ctx, actor, id)
+		if err != nil {
+			return "", err
+		}
+		return describeTask(task), nil
+	}
+}
+
+func watch(owner int64) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		events, err := uc.WatchTasks(ctx, actor, owner)
+		if err != nil {
+			return "", err
+		}
+		for range events {
+		}
+		return "watched", nil
+	}
+}
+
+func bulkCreate(titles ...string) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		inputs := make([]usecase.CreateTaskInput, len(titles))
+		for i, title := range titles {
+			inputs[i] = usecase.CreateTaskInput{Title: title}
+		}
+		result, err := uc.BulkCreateTasks(ctx, actor, inputs)
+		return outcome(result), err
+	}
+}
+
+func bulkComplete(ids ...int64) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		result, err := uc.BulkCompleteTasks(ctx, actor, ids)
+		return outcome(result), err
+	}
+}
+
+func bulkDelete(ids ...int64) func(context.Context, *usecase.TaskUseCase, *domain.User) (string, error) {
+	return func(ctx context.Context, uc *usecase.TaskUseCase, actor *domain.User) (string, error) {
+		result, err := uc.BulkDeleteTasks(ctx, actor, ids)
+		return outcome(result), err
+	}
+}
+
+func TestCreateTask(t *testing.T) {
+	tooManyTags := make([]string, domain.MaxTags+1)
+	for i := range tooManyTags {
+		tooManyTags[i] = fmt.Sprintf("tag%d", i)
+	}
+	runTable(t, []testCase{
+		{
+			name: "creates the actor's task with its labels, recording TaskCreated",
+			repo: func(r *mock.TaskRepository) {
+				r.CreateFunc = func(_ context.Context, task *domain.Task) error {
+					task.ID = 7
+					return nil
+				}
+			},
+			run:    create(usecase.CreateTaskInput{Title: "Plan", Priority: "high", Tags: []string{"Work", "home"}}),
+			result: `#7 owner 1 "Plan" open high [home work] v1 +TaskCreated`,
+			calls:  `Create(#0 owner 1 "Plan" open high [home work] v1 +TaskCreated)`,
+			events: "created #7",
+		},
+		{
+			name: "a subtask is created under one of the actor's tasks",
+			repo: func(r *mock.TaskRepository) {
+				stored(r)
+				r.CreateFunc = func(_ context.Context, task *domain.Task) error {
+					task.ID = 7
+					return nil
+				}
+			},
+			run:    create(usecase.CreateTaskInput{Title: "Outline", ParentID: 1}),
+			result: `#7 owner 1 "Outline" open medium [] v1 parent 1 +TaskCreated`,
+			calls:  `GetByID(1) Create(#0 owner 1 "Outline" open medium [] v1 parent 1 +TaskCreated)`,
+			events: "created #7",
+		},
+		{name: "not under a missing task", repo: stored, run: create(usecase.CreateTaskInput{Title: "Outline", ParentID: 9}), want: usecase.ErrTaskNotFound, calls: "GetByID(9)"},
+		{name: "nor under another user's", repo: stored, run: create(usecase.CreateTaskInput{Title: "Outline", ParentID: 2}), want: usecase.ErrTaskNotFound, calls: "GetByID(2)"},
+		{name: "which an admin may view but not add to", actor: admin, repo: stored, run: create(usecase.CreateTaskInput{Title: "Outline", ParentID: 2}), want: usecase.ErrForbidden, calls: "GetByID(2)"},
+		{name: "an empty title stores nothing", run: create(usecase.CreateTaskInput{}), want: domain.ErrEmptyTitle},
+		{name: "neither does a title over 200 characters", run: create(usecase.CreateTaskInput{Title: strings.Repeat("x", 201)}), want: domain.ErrTitleTooLong},
+		{name: "an unknown priority", run: create(usecase.CreateTaskInput{Title: "Plan", Priority: "urgent"}), want: domain.ErrInvalidPriority},
+		{name: "too many tags", run: create(usecase.CreateTaskInput{Title: "Plan", Tags: tooManyTags}), want: domain.ErrTooManyTags},
+		{
+			name:  "the repository's error is returned as is, and nothing is published",
+			repo:  func(r *mock.TaskRepository) { r.CreateFunc = failing(errBoom) },
+			run:   create(usecase.CreateTaskInput{Title: "Plan"}),
+			want:  errBoom,
+			calls: `Create(#0 owner 1 "Plan" open medium [] v1 +TaskCreated)`,
+		},
+	})
+}
+
+func TestGetTask(t *testing.T) {
+	runTable(t, []testCase{
+		{name: "returns the actor's task", repo: stored, run: get(1), result: `#1 owner 1 "Write the report" open medium [work] v2`, calls: "GetByID(1)"},
+		{name: "a missing task is " + string(usecase.ErrTaskNotFound.Code), repo: stored, run: get(9), want: usecase.ErrTaskNotFound, calls: "GetByID(9)"},
+		{name: "so is another user's", repo: stored, run: get(2), want: usecase.ErrTaskNotFound, calls: "GetByID(2)"},
+		{name: "which an admin may view", actor: admin, repo: stored, run: get(2), result: `#2 owner 2 "Bob's task" open low [] v1`, calls: "GetByID(2)"},
+		{
+			name: "a lookup failing is returned, not read as not found",
+			repo: func(r *mock.TaskRepository) {
+				r.GetByIDFunc = func(context.Context, int64) (*domain.Task, error) { return nil, errBoom }
+			},
+			run:   get(1),
+			want:  errBoom,
+			calls: "GetByID(1)",
+		},
+		{
+			name:     "but one cut short by the context is reported as such",
+			canceled: true,
+			repo: func(r *mock.TaskRepository) {
+				r.GetByIDFunc = func(ctx context.Context, _ int64) (*domain.Task, error) { return nil, ctx.Err() }
+			},
+			run:   get(1),
+			want:  context.Canceled,
+			calls: "GetByID(1)",
+		},
+	})
+}
+
+func TestListTasks(t *testing.T) {
+	now := time.Now()
+	listed := func(r *mock.TaskRepository) {
+		r.ListFunc = func(context.Context, domain.ListTasksQuery) ([]*domain.Task, error) {
+			return []*domain.Task{report, filed}, nil
+		}
+	}
+	runTable(t, []testCase{
+		{name: "lists the actor's own tasks", repo: listed, run: list(domain.ListTasksQuery{}), result: "2 tasks", calls: "List(owner 1)"},
+		{name: "a user naming themselves too", repo: listed, run: list(domain.ListTasksQuery{OwnerID: alice.ID}), calls: "List(owner 1)"},
+		{name: "a user naming another owner is forbidden", repo: listed, run: list(domain.ListTasksQuery{OwnerID: bob.ID}), want: usecase.ErrForbidden},
+		{name: "an admin lists everyone's", actor: admin, repo: listed, run: list(domain.ListTasksQuery{}), calls: "List(owner 0)"},
+		{name: "or one owner's", actor: admin, repo: listed, run: list(domain.ListTasksQuery{OwnerID: bob.ID}), calls: "List(owner 2)"},
+		{
+			name: "a date range ending before it starts lists nothing",
+			run:  list(domain.ListTasksQuery{CreatedFrom: now, CreatedBefore: now.Add(-time.Hour)}),
+			want: domain.ErrInvalidDateRange,
+		},
+		{name: "nor does an empty one", run: list(domain.ListTasksQuery{CreatedFrom: now, CreatedBefore: now}), want: domain.ErrInvalidDateRange},
+		{name: "nor does an invalid tag", run: list(domain.ListTasksQuery{Tags: []string{""}}), want: domain.ErrInvalidTag},
+		{name: "nor does a page over the maximum size", run: list(domain.ListTasksQuery{Limit: domain.MaxPageSize + 1}), want: domain.ErrInvalidPage},
+		{name: "nor does a negative limit", run: list(domain.ListTasksQuery{Limit: -1}), want: domain.ErrInvalidPage},
+		{name: "nor does a negative offset", run: list(domain.ListTasksQuery{Offset: -1}), want: domain.ErrInvalidPage},
+		{
+			name: "query tags are normalized like task tags",
+			repo: func(r *mock.TaskRepository) {
+				r.ListFunc = func(_ context.Context, query domain.ListTasksQuery) ([]*domain.Task, error) {
+					if strings.Join(query.Tags, ",") != "dev,urgent" {
+						return nil, fmt.Errorf("listed tags %q", query.Tags)
+					}
+					return nil, nil
+				}
+			},
+			run:   list(domain.ListTasksQuery{Tags: []string{" URGENT", "dev"}}),
+			calls: "List(owner 1)",
+		},
+		{
+			name: "the repository's error is returned as is",
+			repo: func(r *mock.TaskRepository) {
+				r.ListFunc = func(context.Context, domain.ListTasksQuery) ([]*domain.Task, error) { return nil, errBoom }
+			},
+			run:   list(domain.ListTasksQuery{}),
+			want:  errBoom,
+			calls: "List(owner 1)",
+		},
+	})
+}
+
+func TestUpdateTask(t *testing.T) {
+	runTable(t, []testCase{
+		{
+			name:   "completing by update records TaskCompleted",
+			repo:   storedAndWritable,
+			run:    update(usecase.UpdateTaskInput{ID: 1, Title: "Send the report", Completed: true, Priority: "high"}),
+			result: `#1 owner 1 "Send the report" done high [work] v3 +TaskCompleted`,
+			calls:  `GetByID(1) Update(#1 owner 1 "Send the report" done high [work] v2 +TaskCompleted)`,
+			events: "updated #1",
+		},
+		{
+			name:   "updating a completed task records nothing; nil tags are kept, empty ones removed",
+			repo:   storedAndWritable,
+			run:    update(usecase.UpdateTaskInput{ID: 3, Title: "File taxes", Completed: true, Tags: []string{}, Version: 4}),
+			calls:  `GetByID(3) Update(#3 owner 1 "File taxes" done high [] v4)`,
+			events: "updated #3",
+		},
+		{name: "a missing task is not found", repo: storedAndWritable, run: update(usecase.UpdateTaskInput{ID: 9, Title: "x"}), want: usecase.ErrTaskNotFound, calls: "GetByID(9)"},
+		{name: "so is another user's", repo: storedAndWritable, run: update(usecase.UpdateTaskInput{ID: 2, Title: "x"}), want: usecase.ErrTaskNotFound, calls: "GetByID(2)"},
+		{name: "an admin may see it, but not change it", actor: admin, repo: storedAndWritable, run: update(usecase.UpdateTaskInput{ID: 2, Title: "x"}), want: usecase.ErrForbidden, calls: "GetByID(2)"},
+		{name: "an empty title stores nothing", repo: storedAndWritable, run: update(usecase.UpdateTaskInput{ID: 1}), want: domain.ErrEmptyTitle, calls: "GetByID(1)"},
+		{name: "neither does an unknown priority", repo: storedAndWritable, run: update(usecase.UpdateTaskInput{ID: 1, Title: "x", Priority: "urgent"}), want: domain.ErrInvalidPriority, calls: "GetByID(1)"},
+		{name: "nor an invalid tag", repo: storedAndWritable, run: update(usecase.UpdateTaskInput{ID: 1, Title: "x", Tags: []string{strings.Repeat("t", 51)}}), want: domain.ErrInvalidTag, calls: "GetByID(1)"},
+		{name: "a change made to an older version conflicts", repo: storedAndWritable, run: update(usecase.UpdateTaskInput{ID: 1, Title: "x", Version: 1}), want: domain.ErrVersionConflict, calls: "GetByID(1)"},
+		{
+			name: "so does a write the repository finds stale, and nothing is published",
+			repo: func(r *mock.TaskRepository) {
+				stored(r)
+				r.UpdateFunc = failing(domain.ErrVersionConflict)
+			},
+			run:   update(usecase.UpdateTaskInput{ID: 1, Title: "x"}),
+			want:  domain.ErrVersionConflict,
+			calls: `GetByID(1) Update(#1 owner 1 "x" open medium [work] v2)`,
+		},
+		{
+			name: "the repository's error is returned as is",
+			repo: func(r *mock.TaskRepository) {
+				stored(r)
+				r.UpdateFunc = failing(errBoom)
+			},
+			run:   update(usecase.UpdateTaskInput{ID: 1, Title: "x"}),
+			want:  errBoom,
+			calls: `GetByID(1) Update(#1 owner 1 "x" open medium [work] v2)`,
+		},
+	})
+}
+
+func TestDeleteTask(t *testing.T) {
+	runTable(t, []testCase{
+		{name: "deletes the actor's task", repo: storedAndWritable, run: remove(1), calls: "GetByID(1) Delete(1)", events: "deleted #1"},
+		{name: "a missing task is not found", repo: storedAndWritable, run: remove(9), want: usecase.ErrTaskNotFound, calls: "GetByID(9)"},
+		{name: "so is another user's", repo: storedAndWritable, run: remove(2), want: usecase.ErrTaskNotFound, calls: "GetByID(2)"},
+		{name: "an admin cannot delete it", actor: admin, repo: storedAndWritable, run: remove(2), want: usecase.ErrForbidden, calls: "GetByID(2)"},
+		{
+			name: "the repository's error is returned as is",
+			repo: func(r *mock.TaskRepository) {
+				stored(r)
+				r.DeleteFunc = func(context.Context, int64) error { return errBoom }
+			},
+			run:   remove(1),
+			want:  errBoom,
+			calls: "GetByID(1) Delete(1)",
+		},
+	})
+}
+
+func TestCompleteTask(t *testing.T) {
+	runTable(t, []testCase{
+		{
+			name:   "completes an open task, recording TaskCompleted",
+			repo:   storedAndWritable,
+			run:    complete(1),
+			result: `#1 owner 1 "Write the report" done medium [work] v3 +TaskCompleted`,
+			calls:  `GetByID(1) Update(#1 owner 1 "Write the report" done medium [work] v2 +TaskCompleted)`,
+			events: "updated #1",
+		},
+		{
+			name:   "completing a completed one records nothing",
+			repo:   storedAndWritable,
+			run:    complete(3),
+			calls:  `GetByID(3) Update(#3 owner 1 "File taxes" done high [] v4)`,
+			events: "updated #3",
+		},
+		{name: "a missing task is not found", repo: storedAndWritable, run: complete(9), want: usecase.ErrTaskNotFound, calls: "GetByID(9)"},
+		{name: "an admin cannot complete another user's", actor: admin, repo: storedAndWritable, run: complete(2), want: usecase.ErrForbidden, calls: "GetByID(2)"},
+		{
+			name: "the repository's error is returned as is",
+			repo: func(r *mock.TaskRepository) {
+				stored(r)
+				r.UpdateFunc = failing(errBoom)
+			},
+			run:   complete(1),
+			want:  errBoom,
+			calls: `GetByID(1) Update(#1 owner 1 "Write the report" done medium [work] v2 +TaskCompleted)`,
+		},
+	})
+}
+
+func TestWatchTasks(t *testing.T) {
+	runTable(t, []testCase{
+		{name: "watches the actor's tasks, without the repository", run: watch(0), result: "watched"},
+		{name: "a user naming another owner is forbidden", run: watch(bob.ID), want: usecase.ErrForbidden},
+		{name: "an admin may watch one", actor: admin, run: watch(bob.ID), result: "watched"},
+	})
+}
+
+func TestBulkCreateTasksOnMock(t *testing.T) {
+	runTable(t, []testCase{
+		{
+			name:   "stores the valid tasks in one batch; invalid ones fail alone",
+			repo:   func(r *mock.TaskRepository) { r.CreateBatchFunc = numbered(10) },
+			run:    bulkCreate("One", "", "Two"),
+			result: "ok TASK_TITLE_EMPTY ok",
+			calls:  `CreateBatch("One" "Two")`,
+			events: "created #10 created #11",
+		},
+		{name: "with every item invalid, nothing is stored", run: bulkCreate("", ""), result: "TASK_TITLE_EMPTY TASK_TITLE_EMPTY"},
+		{name: "an empty request is rejected", run: bulkCreate(), want: usecase.ErrBulkEmpty},
+		{name: "so is one over 100 items", run: bulkCreate(make([]string, usecase.MaxBulkItems+1)...), want: usecase.ErrBulkTooLarge},
+		{
+			name: "the batch failing fails every valid item with the repository's error",
+			repo: func(r *mock.TaskRepository) {
+				r.CreateBatchFunc = func(context.Context, []*domain.Task) error { return errBoom }
+			},
+			run:    bulkCreate("One", "", "Two"),
+			result: "uncoded TASK_TITLE_EMPTY uncoded",
+			calls:  `CreateBatch("One" "Two")`,
+		},
+	})
+}
+
+func TestBulkCompleteTasksOnMock(t *testing.T) {
+	runTable(t, []testCase{
+		{
+			name:   "completes each task as CompleteTask would, reporting each item",
+			repo:   storedAndWritable,
+			run:    bulkComplete(1, 9, 2, 1),
+			result: "ok TASK_NOT_FOUND TASK_NOT_FOUND BULK_DUPLICATE_ID",
+			calls:  `GetByID(1) Update(#1 owner 1 "Write the report" done medium [work] v2 +TaskCompleted) GetByID(9) GetByID(2)`,
+			events: "updated #1",
+		},
+		{name: "an empty request is rejected", run: bulkComplete(), want: usecase.ErrBulkEmpty},
+		{
+			name: "a failed write fails its item only",
+			repo: func(r *mock.TaskRepository) {
+				stored(r)
+				r.UpdateFunc = func(_ context.Context, task *domain.Task) error {
+					if task.ID == 1 {
+						return errBoom
+					}
+					return nil
+				}
+			},
+			run:    bulkComplete(1, 3),
+			result: "uncoded ok",
+			calls:  `GetByID(1) Update(#1 owner 1 "Write the report" done medium [work] v2 +TaskCompleted) GetByID(3) Update(#3 owner 1 "File taxes" done high [] v4)`,
+			events: "updated #3",
+		},
+	})
+}
+
+func TestBulkDeleteTasksOnMock(t *testing.T) {
+	runTable(t, []testCase{
+		{
+			name:   "deletes each task as DeleteTask would",
+			repo:   storedAndWritable,
+			run:    bulkDelete(1, 2, 3),
+			result: "ok TASK_NOT_FOUND ok",
+			calls:  "GetByID(1) Delete(1) GetByID(2) GetByID(3) Delete(3)",
+			events: "deleted #1 deleted #3",
+		},
+		{name: "an admin deletes none of another user's", actor: admin, repo: storedAndWritable, run: bulkDelete(2), result: "AUTH_FORBIDDEN", calls: "GetByID(2)"},
+		{
+			name: "a failed delete fails its item only",
+			repo: func(r *mock.TaskRepository) {
+				stored(r)
+				r.DeleteFunc = func(_ context.Context, id int64) error {
+					if id == 1 {
+						return errBoom
+					}
+					return nil
+				}
+			},
+			run:    bulkDelete(1, 3),
+			result: "uncoded ok",
+			calls:  "GetByID(1) Delete(1) GetByID(3) Delete(3)",
+			events: "deleted #3",
+		},
+		{name: "a canceled request applies nothing", canceled: true, repo: storedAndWritable, run: bulkDelete(1, 3), result: "uncoded uncoded"},
+	})
+}
@@ -0,0 +1,184 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+func TestVersionsAdvance(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewTaskUseCase(repository.NewMemoryTaskRepository())
+	task, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Draft"})
+	if err != nil || task.Version != 1 {
+		t.Fatalf("CreateTask = version %d, %v; want a new task to be version 1", task.Version, err)
+	}
+
+	steps := []struct {
+		name    string
+		run     func() (*domain.Task, error)
+		err     error
+		version int64 // of the stored task afterwards
+		title   string
+	}{
+		{"an update from the current version", func() (*domain.Task, error) {
+			return uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: task.ID, Title: "Review", Version: 1})
+		}, nil, 2, "Review"},
+		{"an update from an old version", func() (*domain.Task, error) {
+			return uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: task.ID, Title: "Stale", Version: 1})
+		}, domain.ErrVersionConflict, 2, "Review"},
+		{"an update from a version that does not exist yet", func() (*domain.Task, error) {
+			return uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: task.ID, Title: "Ship", Version: 3})
+		}, domain.ErrVersionConflict, 2, "Review"},
+		{"an update without a version applies to the current one", func() (*domain.Task, error) {
+			return uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{ID: task.ID, Title: "Ship"})
+		}, nil, 3, "Ship"},
+		{"completing a task is a change too", func() (*domain.Task, error) {
+			return uc.CompleteTask(ctx, owner, task.ID)
+		}, nil, 4, "Ship"},
+	}
+	for _, step := range steps {
+		if _, err := step.run(); !errors.Is(err, step.err) {
+			t.Fatalf("%s: %v, want %v", step.name, err, step.err)
+		}
+		got, err := uc.GetTask(ctx, owner, task.ID)
+		if err != nil || got.Version != step.version || got.Title != step.title {
+			t.Errorf("%s: stored %q as version %d, %v; want %q as version %d", step.name, got.Title, got.Version, err, step.title, step.version)
+		}
+	}
+}
+
+// lockingRepositories runs test against the in-memory repository and SQLite.
+// SQLite allows one writer at a time; the others wait for it rather than
+// fail with "database is locked".
+func lockingRepositories(t *testing.T, test func(t *testing.T, r domain.TaskRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, repository.NewMemoryTaskRepository()) })
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "tasks.db") + "?_busy_timeout=10000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		test(t, repository.NewTaskRepository(db))
+	})
+}
+
+// writers is how many goroutines race on one task
+const writers = 16
+
+// TestRacingWritersOneWins has every writer read the same version of a task
+// and then update it at once: exactly one may win
+func TestRacingWritersOneWins(t *testing.T) {
+	lockingRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		task, _ := domain.NewTask(owner.ID, "Original", "", time.Now())
+		if err := r.Create(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+
+		var read, start, done sync.WaitGroup
+		var errs [writers]error
+		read.Add(writers)
+		start.Add(1)
+		done.Add(writers)
+		for i := 0; i < writers; i++ {
+			go func(i int) {
+				defer done.Done()
+				mine, err := r.GetByID(ctx, task.ID)
+				read.Done()
+				start.Wait()
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				mine.Title = fmt.Sprintf("writer %d", i)
+				errs[i] = r.Update(ctx, mine)
+			}(i)
+		}
+		read.Wait()
+		start.Done()
+		done.Wait()
+
+		winner, conflicts := -1, 0
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				winner = i
+			case errors.Is(err, domain.ErrVersionConflict):
+				conflicts++
+			default:
+				t.Errorf("writer %d: %v", i, err)
+			}
+		}
+		if winner < 0 || conflicts != writers-1 {
+			t.Fatalf("of %d writers from version 1, %d conflicted; want all but one", writers, conflicts)
+		}
+		got, err := r.GetByID(ctx, task.ID)
+		if err != nil || got.Version != 2 || got.Title != fmt.Sprintf("writer %d", winner) {
+			t.Errorf("the task holds %q as version %d, %v; want writer %d's change as version 2", got.Title, got.Version, err, winner)
+		}
+	})
+}
+
+// TestRetriedIncrementsLoseNothing has every writer add one to a counter
+// kept in a task's description, rereading and retrying whenever it
+// conflicts. Had any write been lost, the total would come up short.
+func TestRetriedIncrementsLoseNothing(t *testing.T) {
+	const increments = 5
+	lockingRepositories(t, func(t *testing.T, r domain.TaskRepository) {
+		ctx := context.Background()
+		uc := usecase.NewTaskUseCase(r)
+		task, err := uc.CreateTask(ctx, owner, usecase.CreateTaskInput{Title: "Counter", Description: "0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		increment := func() error {
+			current, err := uc.GetTask(ctx, owner, task.ID)
+			if err != nil {
+				return err
+			}
+			count, _ := strconv.Atoi(current.Description)
+			_, err = uc.UpdateTask(ctx, owner, usecase.UpdateTaskInput{
+				ID:          task.ID,
+				Title:       current.Title,
+				Description: strconv.Itoa(count + 1),
+				Version:     current.Version,
+			})
+			return err
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for n := 0; n < increments; {
+					switch err := increment(); {
+					case err == nil:
+						n++
+					case !errors.Is(err, domain.ErrVersionConflict):
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		got, err := uc.GetTask(ctx, owner, task.ID)
+		want := writers * increments
+		if err != nil || got.Description != strconv.Itoa(want) || got.Version != int64(1+want) {
+			t.Errorf("the counter is %s as version %d, %v; want %d as version %d", got.Description, got.Version, err, want, 1+want)
+		}
+	})
+}
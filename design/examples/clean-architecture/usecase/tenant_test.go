@@ -0,0 +1,203 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+	"github.com/dong-tran/docs/clean-architecture-example/infrastructure"
+	"github.com/dong-tran/docs/clean-architecture-example/repository"
+	"github.com/dong-tran/docs/clean-architecture-example/usecase"
+)
+
+const (
+	acme   domain.TenantID = "acme"
+	globex domain.TenantID = "globex"
+)
+
+func userEmails(users []*domain.User) []string {
+	out := []string{}
+	for _, u := range users {
+		out = append(out, u.Email)
+	}
+	return out
+}
+
+// TestTenantIsolation runs the use cases on memory repositories for an
+// admin and a user in each tenant
+func TestTenantIsolation(t *testing.T) {
+	bg := context.Background()
+	users := repository.NewMemoryUserRepository()
+	auth := newAuth(t, users)
+	bus := infrastructure.NewTaskEventBus()
+	defer bus.Close(bg)
+	repo := repository.NewMemoryTaskRepository()
+	tasks := usecase.NewTaskUseCaseWithEvents(repo, bus)
+	people := usecase.NewUserUseCase(users)
+	queries := usecase.NewTaskQueryService(repository.NewMemoryTaskReadModel(nil), repo, bus, nil)
+
+	register := func(tenant domain.TenantID, email string, role domain.Role) *domain.User {
+		t.Helper()
+		ctx := domain.WithTenant(bg, tenant)
+		user, err := auth.Register(ctx, email, "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if role != domain.RoleUser {
+			if err := users.SetRole(ctx, user.ID, role); err != nil {
+				t.Fatal(err)
+			}
+			user.Role = role
+		}
+		return user
+	}
+	acmeAdmin := register(acme, "admin@example.com", domain.RoleAdmin)
+	ann := register(acme, "ann@example.com", domain.RoleUser)
+	globexAdmin := register(globex, "admin@example.com", domain.RoleAdmin)
+	gus := register(globex, "gus@example.com", domain.RoleUser)
+	if ann.TenantID != acme || gus.TenantID != globex {
+		t.Errorf("registered in %q and %q, want each in the tenant of its request", ann.TenantID, gus.TenantID)
+	}
+
+	if err := queries.Start(bg); err != nil {
+		t.Fatal(err)
+	}
+	defer queries.Stop(bg)
+	watchCtx, stopWatching := context.WithCancel(bg)
+	defer stopWatching()
+	acmeWatch, err := tasks.WatchTasks(watchCtx, acmeAdmin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := tasks.CreateTask(bg, ann, usecase.CreateTaskInput{Title: "Acme plan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := tasks.CreateTask(domain.WithTenant(bg, acme), gus, usecase.CreateTaskInput{Title: "Globex secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.TenantID != acme || secret.TenantID != globex {
+		t.Errorf("created in %q and %q, want each in its owner's tenant, even by a request naming another", plan.TenantID, secret.TenantID)
+	}
+
+	t.Run("accounts", func(t *testing.T) {
+		session, err := auth.Login(domain.WithTenant(bg, acme), "admin@example.com", "correct horse")
+		if err != nil || session.User.ID != acmeAdmin.ID {
+			t.Fatalf("acme's Login of admin@example.com = %+v, %v, want acme's admin", session, err)
+		}
+		if _, err := auth.Authenticate(domain.WithTenant(bg, acme), session.Token); err != nil {
+			t.Errorf("Authenticate in its tenant = %v", err)
+		}
+		if _, err := auth.Authenticate(domain.WithTenant(bg, globex), session.Token); !errors.Is(err, usecase.ErrInvalidToken) {
+			t.Errorf("Authenticate in the other = %v, want %v", err, usecase.ErrInvalidToken)
+		}
+		if _, err := auth.Login(domain.WithTenant(bg, globex), "ann@example.com", "correct horse"); !errors.Is(err, usecase.ErrInvalidCredentials) {
+			t.Errorf("Ann's Login to globex = %v, want %v", err, usecase.ErrInvalidCredentials)
+		}
+
+		if accounts, err := people.ListUsers(bg, acmeAdmin); err != nil || !reflect.DeepEqual(userEmails(accounts), []string{"admin@example.com", "ann@example.com"}) {
+			t.Errorf("acme's admin lists %q, %v, want acme's accounts only", userEmails(accounts), err)
+		}
+		if _, err := people.SetRole(bg, acmeAdmin, gus.ID, "admin"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("acme's admin making globex's Gus an admin = %v, want %v", err, domain.ErrUserNotFound)
+		}
+		if found, err := people.GetUsers(bg, acmeAdmin, []int64{ann.ID, gus.ID}); err != nil || len(found) != 1 || found[0].ID != ann.ID {
+			t.Errorf("acme's admin looking up Ann and Gus = %+v, %v, want Ann only", found, err)
+		}
+	})
+
+	t.Run("tasks", func(t *testing.T) {
+		lists := []struct {
+			name  string
+			ctx   context.Context
+			query domain.ListTasksQuery
+			want  []string
+		}{
+			{"every task", bg, domain.ListTasksQuery{}, []string{"Acme plan"}},
+			{"with a context naming globex, since the actor's tenant wins", domain.WithTenant(bg, globex), domain.ListTasksQuery{}, []string{"Acme plan"}},
+			{"of globex's Gus", bg, domain.ListTasksQuery{OwnerID: gus.ID}, []string{}},
+		}
+		for _, tt := range lists {
+			list, err := tasks.ListTasks(tt.ctx, acmeAdmin, tt.query)
+			if err != nil || !reflect.DeepEqual(taskTitles(list), tt.want) {
+				t.Errorf("acme's admin listing %s = %q, %v, want %q", tt.name, taskTitles(list), err, tt.want)
+			}
+		}
+
+		// A user of one tenant holding a task of another, as a bug elsewhere
+		// might hand over, is still refused by the policy
+		foreign := &domain.User{ID: gus.ID, TenantID: acme, Role: domain.RoleUser}
+		refused := []struct {
+			name string
+			run  func() error
+		}{
+			{"acme's admin getting globex's task", func() error {
+				_, err := tasks.GetTask(domain.WithTenant(bg, globex), acmeAdmin, secret.ID)
+				return err
+			}},
+			{"globex's admin updating acme's", func() error {
+				_, err := tasks.UpdateTask(bg, globexAdmin, usecase.UpdateTaskInput{ID: plan.ID, Title: "Taken over"})
+				return err
+			}},
+			{"Gus deleting it", func() error { return tasks.DeleteTask(bg, gus, plan.ID) }},
+			{"acme's admin completing globex's", func() error {
+				_, err := tasks.CompleteTask(bg, acmeAdmin, secret.ID)
+				return err
+			}},
+			{"Gus in acme getting his own task", func() error {
+				_, err := tasks.GetTask(bg, foreign, secret.ID)
+				return err
+			}},
+		}
+		for _, tt := range refused {
+			if err := tt.run(); !errors.Is(err, usecase.ErrTaskNotFound) {
+				t.Errorf("%s = %v, want %v, like a missing one", tt.name, err, usecase.ErrTaskNotFound)
+			}
+		}
+	})
+
+	t.Run("streams and the read model", func(t *testing.T) {
+		var events []domain.TaskEvent
+		timeout := time.After(100 * time.Millisecond)
+	receive:
+		for {
+			select {
+			case event := <-acmeWatch:
+				events = append(events, event)
+			case <-timeout:
+				break receive
+			}
+		}
+		if len(events) != 1 || events[0].Task.ID != plan.ID {
+			t.Errorf("acme's admin watching everyone was sent %+v, want acme's task created only", events)
+		}
+
+		waitFor(func() bool {
+			summary, _ := queries.Summary(bg, globexAdmin, 0)
+			return summary.Total == 1
+		})
+		summaries := []struct {
+			name  string
+			actor *domain.User
+			owner int64
+			want  int
+		}{
+			{"acme's", acmeAdmin, 0, 1},
+			{"globex's", globexAdmin, 0, 1},
+			{"acme's Ann, by globex's admin", globexAdmin, ann.ID, 0},
+		}
+		for _, tt := range summaries {
+			if summary, err := queries.Summary(bg, tt.actor, tt.owner); err != nil || summary.Total != tt.want {
+				t.Errorf("the summary of %s = %+v, %v, want %d tasks", tt.name, summary, err, tt.want)
+			}
+		}
+		if activity, err := queries.RecentActivity(bg, globexAdmin, 0, 10); err != nil || len(activity) != 1 || activity[0].TaskID != secret.ID {
+			t.Errorf("globex's activity = %+v, %v, want its task only", activity, err)
+		}
+	})
+}
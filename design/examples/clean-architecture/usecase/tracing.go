@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Each use case runs in a span of its own, a child of the caller's: the
+// handler's request span, and the parent of the repository's. Only the
+// OpenTelemetry API is used here, which does nothing until main installs a
+// provider (infrastructure.InitTracing), so the use cases stay free of any
+// exporter or SDK.
+
+var tracer = otel.Tracer("github.com/dong-tran/docs/clean-architecture-example/usecase")
+
+// startSpan starts the span of the use case name, such as
+// TaskUseCase.CreateTask. Pass its error to endSpan, deferred.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, failed with *err if there is one
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// Attributes of the use case spans
+func actorAttr(id int64) attribute.KeyValue { return attribute.Int64("user.id", id) }
+func taskAttr(id int64) attribute.KeyValue  { return attribute.Int64("task.id", id) }
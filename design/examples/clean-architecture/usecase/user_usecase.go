@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dong-tran/docs/clean-architecture-example/domain"
+)
+
+// UserUseCase manages accounts. Listing them and setting roles are for admins
+// only, and like everything else only reach the actor's tenant.
+type UserUseCase struct {
+	users domain.UserRepository
+}
+
+func NewUserUseCase(users domain.UserRepository) *UserUseCase {
+	return &UserUseCase{users: users}
+}
+
+// GetUsers returns the accounts among ids that exist, in ID order, with one
+// repository call. A user may only look up their own account, which is the
+// owner of every task they can view; an admin may look up anyone's.
+func (uc *UserUseCase) GetUsers(ctx context.Context, actor *domain.User, ids []int64) (_ []*domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserUseCase.GetUsers", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	for _, id := range ids {
+		if !canViewUser(actor, id) {
+			return nil, ErrForbidden
+		}
+	}
+	return uc.users.GetByIDs(ctx, ids)
+}
+
+// ListUsers returns every account in ID order
+func (uc *UserUseCase) ListUsers(ctx context.Context, actor *domain.User) (_ []*domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserUseCase.ListUsers", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if err := requireAdmin(actor); err != nil {
+		return nil, err
+	}
+	return uc.users.List(ctx)
+}
+
+// SetRole changes another user's role. Admins cannot change their own, so
+// the last admin cannot lock everyone out by accident.
+func (uc *UserUseCase) SetRole(ctx context.Context, actor *domain.User, id int64, role string) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserUseCase.SetRole", actorAttr(actor.ID))
+	defer endSpan(span, &err)
+	ctx = actingFor(ctx, actor)
+	if err := requireAdmin(actor); err != nil {
+		return nil, err
+	}
+	r, err := domain.ParseRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if id == actor.ID {
+		return nil, ErrOwnRole
+	}
+
+	if err := uc.users.SetRole(ctx, id, r); err != nil {
+		return nil, err
+	}
+	return uc.users.GetByID(ctx, id)
+}
@@ -0,0 +1,222 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A bulkhead gives each dependency its own fixed share of concurrency, the
+// way a ship's hull is split into compartments so one leak cannot sink it.
+// Without one, every caller shares the same goroutines, connections and
+// timeouts: when one upstream hangs, calls to it pile up until nothing is
+// left for the healthy ones. With one, a hanging upstream can only use up
+// its own MaxConcurrent slots; calls beyond that queue briefly or are
+// rejected at once, and every other dependency carries on untouched.
+
+var (
+	ErrFull    = errors.New("bulkhead: at capacity")
+	ErrTimeout = errors.New("bulkhead: waited too long for a slot")
+)
+
+// Config sizes one compartment. MaxQueue = 0 rejects as soon as every slot is
+// taken; otherwise up to MaxQueue calls wait, each for at most MaxWait (0 means
+// until its context ends).
+type Config struct {
+	MaxConcurrent int
+	MaxQueue      int
+	MaxWait       time.Duration
+}
+
+// Metrics receives every decision a bulkhead makes. Methods are called from
+// the calling goroutines, concurrently, and must not block.
+type Metrics interface {
+	Admitted(name string, waited time.Duration)
+	Rejected(name string, err error)
+	Released(name string, held time.Duration)
+}
+
+type Bulkhead struct {
+	name    string
+	cfg     Config
+	slots   chan struct{}
+	metrics Metrics
+
+	inFlight atomic.Int64
+	queued   atomic.Int64
+	admitted atomic.Int64
+	full     atomic.Int64
+	timedOut atomic.Int64
+	canceled atomic.Int64
+}
+
+// New returns a bulkhead for the dependency name. metrics may be nil.
+func New(name string, cfg Config, metrics Metrics) *Bulkhead {
+	if cfg.MaxConcurrent < 1 {
+		cfg.MaxConcurrent = 1
+	}
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	}
+	return &Bulkhead{name: name, cfg: cfg, slots: make(chan struct{}, cfg.MaxConcurrent), metrics: metrics}
+}
+
+func (b *Bulkhead) Name() string {
+	return b.name
+}
+
+// Acquire takes a slot, queueing for one if the config allows. The returned
+// release must be called exactly once; later calls do nothing.
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	start := time.Now()
+	select {
+	case b.slots <- struct{}{}:
+		return b.admit(start), nil
+	default:
+	}
+
+	if b.queued.Add(1) > int64(b.cfg.MaxQueue) {
+		b.queued.Add(-1)
+		return nil, b.reject(&b.full, ErrFull)
+	}
+	defer b.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if b.cfg.MaxWait > 0 {
+		t := time.NewTimer(b.cfg.MaxWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return b.admit(start), nil
+	case <-timeout:
+		return nil, b.reject(&b.timedOut, ErrTimeout)
+	case <-ctx.Done():
+		return nil, b.reject(&b.canceled, ctx.Err())
+	}
+}
+
+func (b *Bulkhead) admit(start time.Time) func() {
+	b.admitted.Add(1)
+	b.inFlight.Add(1)
+	admittedAt := time.Now()
+	if b.metrics != nil {
+		b.metrics.Admitted(b.name, admittedAt.Sub(start))
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.inFlight.Add(-1)
+			<-b.slots
+			if b.metrics != nil {
+				b.metrics.Released(b.name, time.Since(admittedAt))
+			}
+		})
+	}
+}
+
+func (b *Bulkhead) reject(counter *atomic.Int64, err error) error {
+	counter.Add(1)
+	if b.metrics != nil {
+		b.metrics.Rejected(b.name, err)
+	}
+	return fmt.Errorf("%s: %w", b.name, err)
+}
+
+// Do runs fn inside the bulkhead. The slot is released when fn returns, even
+// if it panics.
+func (b *Bulkhead) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
+
+type Stats struct {
+	Name          string
+	MaxConcurrent int
+	MaxQueue      int
+	InFlight      int64
+	Queued        int64
+	Admitted      int64
+	RejectedFull  int64
+	TimedOut      int64
+	Canceled      int64
+}
+
+func (b *Bulkhead) Stats() Stats {
+	return Stats{
+		Name:          b.name,
+		MaxConcurrent: b.cfg.MaxConcurrent,
+		MaxQueue:      b.cfg.MaxQueue,
+		InFlight:      b.inFlight.Load(),
+		Queued:        b.queued.Load(),
+		Admitted:      b.admitted.Load(),
+		RejectedFull:  b.full.Load(),
+		TimedOut:      b.timedOut.Load(),
+		Canceled:      b.canceled.Load(),
+	}
+}
+
+// Group holds one bulkhead per dependency. Dependencies without their own
+// config get the default, each in a compartment of its own.
+type Group struct {
+	def     Config
+	metrics Metrics
+
+	mu        sync.Mutex
+	configs   map[string]Config
+	bulkheads map[string]*Bulkhead
+}
+
+func NewGroup(def Config, metrics Metrics) *Group {
+	return &Group{def: def, metrics: metrics, configs: make(map[string]Config), bulkheads: make(map[string]*Bulkhead)}
+}
+
+// Configure sizes name's compartment. It only affects a bulkhead not yet
+// created, so call it at startup.
+func (g *Group) Configure(name string, cfg Config) *Group {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.configs[name] = cfg
+	return g
+}
+
+// Get returns name's bulkhead, creating it on first use
+func (g *Group) Get(name string) *Bulkhead {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.bulkheads[name]
+	if !ok {
+		cfg, ok := g.configs[name]
+		if !ok {
+			cfg = g.def
+		}
+		b = New(name, cfg, g.metrics)
+		g.bulkheads[name] = b
+	}
+	return b
+}
+
+func (g *Group) Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	return g.Get(name).Do(ctx, fn)
+}
+
+// Stats returns every bulkhead's stats, sorted by name
+func (g *Group) Stats() []Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := make([]Stats, 0, len(g.bulkheads))
+	for _, b := range g.bulkheads {
+		stats = append(stats, b.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
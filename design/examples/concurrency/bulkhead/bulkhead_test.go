@@ -0,0 +1,241 @@
+package bulkhead_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/bulkhead"
+)
+
+// counter is a Metrics that counts every hook call per dependency
+type counter struct {
+	mu       sync.Mutex
+	admitted map[string]int64
+	rejected map[string]int64
+	released map[string]int64
+}
+
+func newCounter() *counter {
+	return &counter{admitted: map[string]int64{}, rejected: map[string]int64{}, released: map[string]int64{}}
+}
+
+func (c *counter) Admitted(name string, _ time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.admitted[name]++
+}
+
+func (c *counter) Rejected(name string, _ error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rejected[name]++
+}
+
+func (c *counter) Released(name string, _ time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.released[name]++
+}
+
+func TestRejectWhenFull(t *testing.T) {
+	ctx := context.Background()
+	b := bulkhead.New("payments", bulkhead.Config{MaxConcurrent: 2}, nil)
+	r1, _ := b.Acquire(ctx)
+	r2, _ := b.Acquire(ctx)
+
+	t.Run("the third call is rejected at once, naming the dependency", func(t *testing.T) {
+		start := time.Now()
+		_, err := b.Acquire(ctx)
+		if !errors.Is(err, bulkhead.ErrFull) || time.Since(start) >= time.Millisecond {
+			t.Fatalf("Acquire = %v after %s, want ErrFull at once", err, time.Since(start))
+		}
+		if err.Error() != "payments: bulkhead: at capacity" {
+			t.Errorf("error %q does not name the dependency", err)
+		}
+	})
+	t.Run("releasing twice frees one slot, not two", func(t *testing.T) {
+		r1()
+		r1()
+		r3, err := b.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("the released slot: %v", err)
+		}
+		defer r3()
+		if _, err := b.Acquire(ctx); !errors.Is(err, bulkhead.ErrFull) {
+			t.Errorf("a second slot: %v, want ErrFull", err)
+		}
+	})
+	r2()
+
+	t.Run("a call that panics still gives its slot back", func(t *testing.T) {
+		func() {
+			defer func() { recover() }()
+			b.Do(ctx, func(context.Context) error { panic("upstream client bug") })
+		}()
+		if n := b.Stats().InFlight; n != 0 {
+			t.Errorf("InFlight = %d, want 0", n)
+		}
+	})
+}
+
+func TestQueueWhenFull(t *testing.T) {
+	ctx := context.Background()
+	b := bulkhead.New("search", bulkhead.Config{MaxConcurrent: 1, MaxQueue: 2, MaxWait: 50 * time.Millisecond}, nil)
+
+	t.Run("queued calls run once a slot frees up", func(t *testing.T) {
+		hold, _ := b.Acquire(ctx)
+		results := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				results <- b.Do(ctx, func(context.Context) error { return nil })
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		if _, err := b.Acquire(ctx); !errors.Is(err, bulkhead.ErrFull) {
+			t.Errorf("with 1 running and 2 queued, the fourth: %v, want ErrFull", err)
+		}
+		if n := b.Stats().Queued; n != 2 {
+			t.Errorf("Stats().Queued = %d, want 2", n)
+		}
+		hold()
+		for i := 0; i < 2; i++ {
+			if err := <-results; err != nil {
+				t.Errorf("queued call: %v", err)
+			}
+		}
+	})
+
+	t.Run("a queued call gives up after MaxWait", func(t *testing.T) {
+		hold, _ := b.Acquire(ctx)
+		defer hold()
+		start := time.Now()
+		_, err := b.Acquire(ctx)
+		if waited := time.Since(start); !errors.Is(err, bulkhead.ErrTimeout) || waited < 50*time.Millisecond || waited >= 100*time.Millisecond {
+			t.Errorf("Acquire = %v after %s, want ErrTimeout after 50ms", err, waited)
+		}
+	})
+
+	t.Run("or sooner, if its context ends first", func(t *testing.T) {
+		hold, _ := b.Acquire(ctx)
+		defer hold()
+		cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := b.Acquire(cctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Acquire = %v, want DeadlineExceeded", err)
+		}
+	})
+}
+
+// upstream simulates a dependency: healthy ones answer in 5ms, a hanging one
+// only returns when the caller gives up
+func upstream(hanging bool) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if hanging {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type outcome struct {
+	ok, failed atomic.Int64
+}
+
+// storm sends 40 callers at the hanging "reviews" dependency and 8 at the
+// healthy "inventory" and "pricing" ones for 300ms. Each call has a 100ms
+// timeout. do decides which compartment a call runs in.
+func storm(do func(ctx context.Context, dep string, fn func(context.Context) error) error) (healthy, hanging *outcome) {
+	healthy, hanging = &outcome{}, &outcome{}
+	deadline := time.Now().Add(300 * time.Millisecond)
+	var wg sync.WaitGroup
+	caller := func(dep string, o *outcome) {
+		defer wg.Done()
+		for time.Now().Before(deadline) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			err := do(ctx, dep, upstream(dep == "reviews"))
+			cancel()
+			if err != nil {
+				o.failed.Add(1)
+				if errors.Is(err, bulkhead.ErrFull) {
+					time.Sleep(5 * time.Millisecond) // back off instead of spinning
+				}
+				continue
+			}
+			o.ok.Add(1)
+		}
+	}
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go caller("reviews", hanging)
+	}
+	time.Sleep(10 * time.Millisecond) // the hanging calls get there first
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		dep := "inventory"
+		if i%2 == 1 {
+			dep = "pricing"
+		}
+		go caller(dep, healthy)
+	}
+	wg.Wait()
+	return healthy, hanging
+}
+
+// TestHangingDependency runs the same traffic through one shared pool and
+// through a bulkhead per dependency while "reviews" hangs
+func TestHangingDependency(t *testing.T) {
+	shared := bulkhead.New("shared", bulkhead.Config{MaxConcurrent: 24, MaxQueue: 100, MaxWait: 50 * time.Millisecond}, nil)
+	pooled, _ := storm(func(ctx context.Context, _ string, fn func(context.Context) error) error {
+		return shared.Do(ctx, fn)
+	})
+	t.Logf("one pool of 24 for everything: healthy calls %d ok, %d failed", pooled.ok.Load(), pooled.failed.Load())
+
+	metrics := newCounter()
+	group := bulkhead.NewGroup(bulkhead.Config{MaxConcurrent: 8, MaxQueue: 8, MaxWait: 50 * time.Millisecond}, metrics).
+		Configure("reviews", bulkhead.Config{MaxConcurrent: 8})
+	healthy, hanging := storm(group.Do)
+	t.Logf("8 slots per dependency: healthy calls %d ok, %d failed", healthy.ok.Load(), healthy.failed.Load())
+
+	t.Run("in one pool, healthy calls queue behind the hanging ones", func(t *testing.T) {
+		if pooled.failed.Load() == 0 || 4*pooled.ok.Load() >= healthy.ok.Load() {
+			t.Errorf("pooled: %d ok, %d failed; want failures and under a quarter of the bulkheads' %d",
+				pooled.ok.Load(), pooled.failed.Load(), healthy.ok.Load())
+		}
+	})
+	t.Run("with a bulkhead each, every healthy call succeeds", func(t *testing.T) {
+		if healthy.failed.Load() != 0 || healthy.ok.Load() == 0 {
+			t.Errorf("%d ok, %d failed", healthy.ok.Load(), healthy.failed.Load())
+		}
+	})
+	t.Run("every call to the hanging one still fails", func(t *testing.T) {
+		if n := hanging.ok.Load(); n != 0 {
+			t.Errorf("%d succeeded", n)
+		}
+	})
+
+	t.Run("metrics hooks agree with Stats", func(t *testing.T) {
+		for _, s := range group.Stats() {
+			if metrics.admitted[s.Name] != s.Admitted ||
+				metrics.rejected[s.Name] != s.RejectedFull+s.TimedOut+s.Canceled ||
+				metrics.released[s.Name] != s.Admitted || s.InFlight != 0 {
+				t.Errorf("%s: hooks admitted %d, rejected %d, released %d; stats %+v",
+					s.Name, metrics.admitted[s.Name], metrics.rejected[s.Name], metrics.released[s.Name], s)
+			}
+		}
+	})
+	t.Run("rejections are reported against the dependency that caused them", func(t *testing.T) {
+		if metrics.rejected["inventory"] != 0 || metrics.rejected["reviews"] == 0 {
+			t.Errorf("rejected: inventory %d, reviews %d", metrics.rejected["inventory"], metrics.rejected["reviews"])
+		}
+	})
+}
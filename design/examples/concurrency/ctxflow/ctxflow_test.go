@@ -0,0 +1,228 @@
+package ctxflow_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/ctxflow"
+)
+
+// The tests send requests through handler, usecase, repository and an
+// outbound HTTP call to a second service, and read each layer's trace.
+
+type result struct {
+	status int
+	trace  *ctxflow.Trace
+}
+
+type stack struct {
+	api      *httptest.Server
+	pricing  *httptest.Server
+	remote   *ctxflow.Trace
+	checkout *ctxflow.Checkout
+	done     chan result
+}
+
+type latencies struct {
+	handler, usecase, repository, pricing time.Duration
+}
+
+func newStack(t *testing.T, l latencies) *stack {
+	s := &stack{remote: ctxflow.NewTrace(), done: make(chan result, 1)}
+	s.pricing = httptest.NewServer(&ctxflow.PricingService{Latency: l.pricing, Trace: s.remote})
+	s.checkout = &ctxflow.Checkout{
+		Orders:      &ctxflow.OrderRepository{Latency: l.repository},
+		Pricing:     &ctxflow.PricingClient{BaseURL: s.pricing.URL, HTTP: s.pricing.Client(), PropagateRequestID: true},
+		Audit:       &ctxflow.AuditLog{Latency: 20 * time.Millisecond},
+		Timeout:     l.usecase,
+		DetachAudit: true,
+	}
+	s.api = httptest.NewServer(&ctxflow.Handler{
+		Checkout: s.checkout,
+		Timeout:  l.handler,
+		OnDone:   func(status int, t *ctxflow.Trace) { s.done <- result{status, t} },
+	})
+	t.Cleanup(func() {
+		s.api.Close()
+		s.pricing.Close()
+	})
+	return s
+}
+
+// call sends one checkout, giving up after clientTimeout, and returns what
+// the server did with it. The traces are logged if the test fails.
+func (s *stack) call(t *testing.T, clientTimeout time.Duration) (status int, took time.Duration, res result) {
+	ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.api.URL+"/checkout?order=o-1", nil)
+	req.Header.Set(ctxflow.HeaderRequestID, "req-42")
+	start := time.Now()
+	resp, err := s.api.Client().Do(req)
+	took = time.Since(start)
+	if err == nil {
+		status = resp.StatusCode
+		resp.Body.Close()
+	}
+	res = <-s.done
+	t.Cleanup(func() {
+		if t.Failed() {
+			for _, e := range append(res.trace.Events(), s.remote.Events()...) {
+				t.Log(e)
+			}
+		}
+	})
+	return status, took, res
+}
+
+func TestDeadlineShrinksOnTheWayDown(t *testing.T) {
+	s := newStack(t, latencies{handler: 300 * time.Millisecond, usecase: 200 * time.Millisecond, repository: 20 * time.Millisecond, pricing: 20 * time.Millisecond})
+	status, _, res := s.call(t, time.Second)
+	handler, _ := res.trace.Find("handler", "start")
+	usecase, _ := res.trace.Find("usecase", "start")
+	repo, _ := res.trace.Find("repository", "query")
+	call, _ := res.trace.Find("pricing", "call")
+	remote, _ := s.remote.Find("pricing-svc", "received")
+
+	for _, tc := range []struct {
+		name string
+		ok   bool
+	}{
+		{"the request succeeds", status == http.StatusOK},
+		{"the usecase's 200ms budget is shorter than the handler's 300ms, so it applies",
+			handler.Remaining > 250*time.Millisecond && usecase.Remaining <= 200*time.Millisecond},
+		{"each layer sees what is left after the ones before it",
+			repo.Remaining <= usecase.Remaining && call.Remaining < repo.Remaining-15*time.Millisecond},
+		{"the pricing service rebuilds the deadline from a header, not later than the caller's",
+			remote.Remaining > 0 && remote.Remaining <= call.Remaining},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if !tc.ok {
+				t.Errorf("status %d; remaining: handler %s, usecase %s, repository %s, call %s, pricing service %s",
+					status, handler.Remaining, usecase.Remaining, repo.Remaining, call.Remaining, remote.Remaining)
+			}
+		})
+	}
+
+	t.Run("a longer budget further down cannot extend the caller's deadline", func(t *testing.T) {
+		s := newStack(t, latencies{handler: 100 * time.Millisecond, usecase: time.Second, repository: 10 * time.Millisecond, pricing: 10 * time.Millisecond})
+		_, _, res := s.call(t, time.Second)
+		if usecase, _ := res.trace.Find("usecase", "start"); usecase.Remaining > 100*time.Millisecond {
+			t.Errorf("the usecase has %s left, over the handler's 100ms", usecase.Remaining)
+		}
+	})
+}
+
+func TestSlowDependencyTimesOut(t *testing.T) {
+	s := newStack(t, latencies{handler: 300 * time.Millisecond, usecase: 150 * time.Millisecond, repository: 10 * time.Millisecond, pricing: time.Second})
+	status, took, _ := s.call(t, time.Second)
+
+	t.Run("the client gets 504 when the usecase's budget runs out", func(t *testing.T) {
+		if status != http.StatusGatewayTimeout || took >= 250*time.Millisecond {
+			t.Errorf("answered %d after %s, want 504 before 250ms", status, took)
+		}
+	})
+	t.Run("the pricing service stops working on it too", func(t *testing.T) {
+		if gaveUp, ok := s.remote.Find("pricing-svc", "gave up"); !ok || gaveUp.At >= 250*time.Millisecond {
+			t.Errorf("gave up %t at %s, want before 250ms", ok, gaveUp.At)
+		}
+	})
+}
+
+func TestClientHangsUp(t *testing.T) {
+	s := newStack(t, latencies{handler: time.Second, usecase: time.Second, repository: time.Second, pricing: 10 * time.Millisecond})
+	status, took, res := s.call(t, 50*time.Millisecond)
+
+	t.Run("the client gives up after 50ms", func(t *testing.T) {
+		if status != 0 || took >= 100*time.Millisecond {
+			t.Errorf("answered %d after %s", status, took)
+		}
+	})
+	t.Run("the repository query is abandoned as soon as the connection closes", func(t *testing.T) {
+		abandoned, ok := res.trace.Find("repository", "query abandoned")
+		if !ok || !errors.Is(abandoned.Err, context.Canceled) || abandoned.At >= 100*time.Millisecond {
+			t.Errorf("abandoned %t at %s with %v, want Canceled before 100ms", ok, abandoned.At, abandoned.Err)
+		}
+	})
+	t.Run("the handler logs 499, not a timeout", func(t *testing.T) {
+		if res.status != ctxflow.StatusClientClosedRequest {
+			t.Errorf("logged %d", res.status)
+		}
+	})
+	t.Run("later calls are never made", func(t *testing.T) {
+		if _, called := res.trace.Find("pricing", "call"); called {
+			t.Error("the pricing service was called")
+		}
+	})
+}
+
+func TestValues(t *testing.T) {
+	s := newStack(t, latencies{handler: time.Second, usecase: time.Second, repository: 5 * time.Millisecond, pricing: 5 * time.Millisecond})
+
+	for _, tc := range []struct {
+		name      string
+		propagate bool
+		want      string
+	}{
+		{"the request ID reaches the pricing service in a header", true, `received id="req-42"`},
+		{"without the header it is gone: values do not cross the network", false, `received id=""`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s.remote.Reset()
+			s.checkout.Pricing.PropagateRequestID = tc.propagate
+			s.call(t, time.Second)
+			if remote, _ := s.remote.Find("pricing-svc", "received"); remote.What != tc.want {
+				t.Errorf("pricing service %s, want %s", remote.What, tc.want)
+			}
+		})
+	}
+
+	t.Run("a detached audit write outlives the request and keeps its ID", func(t *testing.T) {
+		s.checkout.WaitAudits()
+		written, lost := s.checkout.Audit.Entries()
+		if len(written) != 2 || len(lost) != 0 || written[0] != "req-42 checkout o-1" {
+			t.Errorf("written %q, lost %v", written, lost)
+		}
+	})
+	t.Run("one run under the request's context is canceled when the handler returns", func(t *testing.T) {
+		s.checkout.DetachAudit = false
+		s.call(t, time.Second)
+		s.checkout.WaitAudits()
+		written, lost := s.checkout.Audit.Entries()
+		if len(written) != 2 || len(lost) != 1 || !errors.Is(lost[0], context.Canceled) {
+			t.Errorf("written %q, lost %v; want the third write lost to Canceled", written, lost)
+		}
+	})
+	t.Run("WithoutCancel drops the deadline too", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctxflow.WithRequestID(context.Background(), "req-42"), time.Second)
+		defer cancel()
+		detached := context.WithoutCancel(ctx)
+		if _, ok := detached.Deadline(); ok {
+			t.Error("the detached context has a deadline")
+		}
+		if ctxflow.RequestID(detached) != "req-42" {
+			t.Error("the detached context lost the request ID")
+		}
+	})
+}
+
+func TestKeys(t *testing.T) {
+	ctx := ctxflow.WithRequestID(context.Background(), "req-42")
+
+	t.Run("two packages using the string key \"user\" clobber each other", func(t *testing.T) {
+		type user struct{ name string }
+		ctx := context.WithValue(ctx, "user", &user{"alice"}) // package A
+		ctx = context.WithValue(ctx, "user", "bob")           // package B, same string
+		if u, _ := ctx.Value("user").(*user); u != nil {
+			t.Errorf("package A still finds %v", u)
+		}
+	})
+	t.Run("an unexported key type cannot be collided with from outside", func(t *testing.T) {
+		if id := ctxflow.RequestID(context.WithValue(ctx, "request_id", "spoofed")); id != "req-42" {
+			t.Errorf("RequestID = %q", id)
+		}
+	})
+}
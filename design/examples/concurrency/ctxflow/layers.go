@@ -0,0 +1,255 @@
+package ctxflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// One request's path through the layers of a service:
+//
+//	Handler -> Checkout (usecase) -> OrderRepository
+//	                              -> PricingClient -> pricing service (HTTP)
+//
+// Every layer takes ctx as its first argument and hands it on; none starts
+// from context.Background(). Each may shorten the deadline, never extend it.
+// Blocking work selects on ctx.Done(), so a timeout or a disconnected client
+// stops the whole chain within a moment instead of after the slowest call.
+
+// sleep waits d, or returns early with ctx's error
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type Order struct {
+	ID  string
+	SKU string
+}
+
+// OrderRepository stands in for a database; a real driver honours ctx the
+// same way, cancelling the query on the server
+type OrderRepository struct {
+	Latency time.Duration
+}
+
+func (r *OrderRepository) Find(ctx context.Context, id string) (Order, error) {
+	Record(ctx, "repository", "query", nil)
+	if err := sleep(ctx, r.Latency); err != nil {
+		Record(ctx, "repository", "query abandoned", err)
+		return Order{}, fmt.Errorf("finding order %s: %w", id, err)
+	}
+	return Order{ID: id, SKU: "sku-" + id}, nil
+}
+
+// Headers that carry the request across a process boundary. Context values
+// do not travel over the network; anything the next service needs has to be
+// copied into the request explicitly.
+const (
+	HeaderRequestID = "X-Request-ID"
+	HeaderTimeout   = "X-Request-Timeout-Ms"
+)
+
+// PricingClient calls the pricing service. The request is bound to ctx, so
+// cancelling ctx aborts the call, and the remaining time is sent along so
+// the service can stop at the same moment.
+type PricingClient struct {
+	BaseURL string
+	HTTP    *http.Client
+
+	// PropagateRequestID copies the request ID into a header. Turn it off to
+	// see the ID go missing on the other side.
+	PropagateRequestID bool
+}
+
+func (c *PricingClient) Quote(ctx context.Context, sku string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/price?sku="+sku, nil)
+	if err != nil {
+		return 0, err
+	}
+	if c.PropagateRequestID {
+		req.Header.Set(HeaderRequestID, RequestID(ctx))
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(HeaderTimeout, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+	Record(ctx, "pricing", "call", nil)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		Record(ctx, "pricing", "call abandoned", ctx.Err())
+		return 0, fmt.Errorf("quoting %s: %w", sku, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("quoting %s: %w", sku, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case ctx.Err() != nil:
+		return 0, fmt.Errorf("quoting %s: %s: %w", sku, resp.Status, ctx.Err())
+	case resp.StatusCode == http.StatusGatewayTimeout:
+		// The service ran out of the time it was sent, which is truncated to
+		// the millisecond, so it can give up just before ctx does
+		return 0, fmt.Errorf("quoting %s: %s: %w", sku, resp.Status, context.DeadlineExceeded)
+	default:
+		return 0, fmt.Errorf("quoting %s: %s", sku, resp.Status)
+	}
+	return strconv.ParseInt(string(body), 10, 64)
+}
+
+// PricingService is the remote side. It rebuilds what it can of the caller's
+// context from the headers, into a trace of its own.
+type PricingService struct {
+	Latency time.Duration
+	Trace   *Trace
+}
+
+func (s *PricingService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := WithTrace(r.Context(), s.Trace)
+	if ms, err := strconv.ParseInt(r.Header.Get(HeaderTimeout), 10, 64); err == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+		defer cancel()
+	}
+	ctx = WithRequestID(ctx, r.Header.Get(HeaderRequestID))
+	Record(ctx, "pricing-svc", "received id="+strconv.Quote(RequestID(ctx)), nil)
+	if err := sleep(ctx, s.Latency); err != nil {
+		Record(ctx, "pricing-svc", "gave up", err)
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	Record(ctx, "pricing-svc", "answered", nil)
+	io.WriteString(w, "1999")
+}
+
+// AuditLog writes an entry after the response has gone out. The write is
+// asynchronous, so its context must not be the request's: that one is
+// canceled as soon as the handler returns.
+type AuditLog struct {
+	Latency time.Duration
+
+	mu      sync.Mutex
+	entries []string
+	lost    []error
+}
+
+func (a *AuditLog) Write(ctx context.Context, entry string) {
+	if err := sleep(ctx, a.Latency); err != nil {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.lost = append(a.lost, err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, RequestID(ctx)+" "+entry)
+}
+
+func (a *AuditLog) Entries() (written []string, lost []error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.entries...), append([]error(nil), a.lost...)
+}
+
+type Receipt struct {
+	OrderID string
+	Cents   int64
+}
+
+// Checkout is the usecase. Timeout is its own budget; the caller's deadline
+// still wins if it is sooner.
+type Checkout struct {
+	Orders  *OrderRepository
+	Pricing *PricingClient
+	Audit   *AuditLog
+	Timeout time.Duration
+
+	// DetachAudit runs the audit write under context.WithoutCancel, which
+	// keeps the request's values but not its cancellation or deadline.
+	// Turn it off to see audit entries lost when the handler returns.
+	DetachAudit bool
+	auditing    sync.WaitGroup
+}
+
+func (u *Checkout) Run(ctx context.Context, orderID string) (Receipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
+	defer cancel()
+	Record(ctx, "usecase", "start", nil)
+
+	order, err := u.Orders.Find(ctx, orderID)
+	if err != nil {
+		return Receipt{}, err
+	}
+	cents, err := u.Pricing.Quote(ctx, order.SKU)
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	auditCtx := ctx
+	if u.DetachAudit {
+		auditCtx = context.WithoutCancel(ctx)
+	}
+	u.auditing.Add(1)
+	go func() {
+		defer u.auditing.Done()
+		u.Audit.Write(auditCtx, "checkout "+order.ID)
+	}()
+	return Receipt{OrderID: order.ID, Cents: cents}, nil
+}
+
+// WaitAudits waits for audit writes started so far
+func (u *Checkout) WaitAudits() {
+	u.auditing.Wait()
+}
+
+// StatusClientClosedRequest is nginx's code for a client that hung up before
+// the response; nobody receives it, but it tells logs apart from a timeout
+const StatusClientClosedRequest = 499
+
+type Handler struct {
+	Checkout *Checkout
+	Timeout  time.Duration
+
+	// OnDone sees every request's trace once it has been answered
+	OnDone func(status int, t *Trace)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	trace := NewTrace()
+	ctx := WithTrace(r.Context(), trace)
+	ctx = WithRequestID(ctx, r.Header.Get(HeaderRequestID))
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	Record(ctx, "handler", "start", nil)
+
+	receipt, err := h.Checkout.Run(ctx, r.URL.Query().Get("order"))
+	status := http.StatusOK
+	switch {
+	case err == nil:
+		fmt.Fprintf(w, "%s %d\n", receipt.OrderID, receipt.Cents)
+	case r.Context().Err() != nil:
+		status = StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+		http.Error(w, "timed out", status)
+	default:
+		status = http.StatusBadGateway
+		http.Error(w, err.Error(), status)
+	}
+	Record(ctx, "handler", "answered "+strconv.Itoa(status), err)
+	if h.OnDone != nil {
+		h.OnDone(status, trace)
+	}
+}
@@ -0,0 +1,108 @@
+package ctxflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request-scoped values. Each key is a value of an unexported type, so no
+// other package can read, overwrite or collide with it, even one that uses
+// the same name; a plain string key is shared by every package that picks
+// the same string. Only data that describes the request belongs here (its
+// ID, its trace): dependencies and optional parameters are passed explicitly.
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	traceKey
+)
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request's ID, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Event is one step a layer took, with the time it had left
+type Event struct {
+	At        time.Duration // since the trace started
+	Layer     string
+	What      string
+	Remaining time.Duration // until the deadline; 0 if there is none
+	Err       error
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%6s  %-11s %-22s", e.At.Round(time.Millisecond), e.Layer, e.What)
+	if e.Remaining > 0 {
+		s += fmt.Sprintf(" %s left", e.Remaining.Round(time.Millisecond))
+	}
+	if e.Err != nil {
+		s += fmt.Sprintf(" (%v)", e.Err)
+	}
+	return s
+}
+
+// Trace records what each layer saw of the context as a request passed
+// through it
+type Trace struct {
+	start  time.Time
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewTrace() *Trace {
+	return &Trace{start: time.Now()}
+}
+
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey, t)
+}
+
+// Record adds an event to ctx's trace, if it has one
+func Record(ctx context.Context, layer, what string, err error) {
+	t, _ := ctx.Value(traceKey).(*Trace)
+	if t == nil {
+		return
+	}
+	e := Event{Layer: layer, What: what, Err: err}
+	if deadline, ok := ctx.Deadline(); ok {
+		e.Remaining = time.Until(deadline)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.At = time.Since(t.start)
+	t.events = append(t.events, e)
+}
+
+func (t *Trace) Events() []Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Event(nil), t.events...)
+}
+
+// Reset drops the events recorded so far and restarts the clock
+func (t *Trace) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start = time.Now()
+	t.events = nil
+}
+
+// Find returns the first event from layer whose description starts with what
+func (t *Trace) Find(layer, what string) (Event, bool) {
+	for _, e := range t.Events() {
+		if e.Layer == layer && strings.HasPrefix(e.What, what) {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
@@ -0,0 +1,111 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dong-tran/docs/concurrency-example/errgroup"
+	"github.com/dong-tran/docs/concurrency-example/semaphore"
+)
+
+// ErrNoConnections is returned by DownloadAll when Connections is below 1,
+// which would leave every download waiting for a connection forever
+var ErrNoConnections = errors.New("download: Connections must be at least 1")
+
+// File is one entry of a download manifest
+type File struct {
+	Name   string
+	Size   int64
+	SHA256 string
+}
+
+// Downloader fetches a manifest's files with two bounds: at most Connections
+// requests at once (the errgroup limit), and at most MemoryBudget bytes
+// buffered at once (a weighted semaphore, each file weighing its size).
+// The first failure cancels every download still running and stops new ones
+// from starting.
+type Downloader struct {
+	Client       *http.Client
+	BaseURL      string
+	Connections  int
+	MemoryBudget int64
+	Save         func(f File, body []byte) error
+
+	memory *semaphore.Weighted
+}
+
+func NewDownloader(client *http.Client, baseURL string, connections int, memoryBudget int64, save func(File, []byte) error) *Downloader {
+	return &Downloader{
+		Client:       client,
+		BaseURL:      baseURL,
+		Connections:  connections,
+		MemoryBudget: memoryBudget,
+		Save:         save,
+		memory:       semaphore.NewWeighted(memoryBudget),
+	}
+}
+
+func (d *Downloader) DownloadAll(ctx context.Context, files []File) error {
+	if d.Connections < 1 {
+		return fmt.Errorf("%w, got %d", ErrNoConnections, d.Connections)
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(d.Connections)
+
+	var acquireErr error
+	for _, f := range files {
+		// Reserve the buffer before starting, so a large file waits its turn
+		// instead of pushing the total past the budget
+		if err := d.memory.Acquire(ctx, f.Size); err != nil {
+			acquireErr = fmt.Errorf("%s: %w", f.Name, err)
+			break
+		}
+		f := f
+		g.Go(func() error {
+			defer d.memory.Release(f.Size)
+			return d.fetch(ctx, f)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return acquireErr
+}
+
+func (d *Downloader) fetch(ctx context.Context, f File) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.BaseURL+"/"+f.Name, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", f.Name, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.Size+1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	if int64(len(body)) != f.Size {
+		return fmt.Errorf("%s: got %d bytes, want %d", f.Name, len(body), f.Size)
+	}
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != f.SHA256 {
+		return fmt.Errorf("%s: checksum mismatch", f.Name)
+	}
+	return d.Save(f, body)
+}
+
+// InUse returns the bytes buffered now
+func (d *Downloader) InUse() int64 {
+	return d.memory.InUse()
+}
@@ -0,0 +1,248 @@
+package download_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/download"
+)
+
+// fileServer serves deterministic content for every file in the manifest,
+// 10ms per 100KB, and records what the downloader does to it
+type fileServer struct {
+	files map[string][]byte
+
+	mu sync.Mutex
+	// broken answers 500, but only once another download is provably in
+	// flight: failing is closed when broken is requested, and a download
+	// running then stops waiting for its delay, tells holding and stays until
+	// it is canceled. Set by breakFile.
+	broken  string
+	failing chan struct{}
+	holding chan struct{}
+
+	conns     int
+	peakConns int
+	bytes     int64
+	peakBytes int64
+	requested map[string]bool
+	canceled  int
+}
+
+func newFileServer(n int) (*fileServer, []download.File) {
+	rng := rand.New(rand.NewSource(1))
+	fs := &fileServer{files: map[string][]byte{}, requested: map[string]bool{}}
+	var manifest []download.File
+	for i := 0; i < n; i++ {
+		body := make([]byte, 64<<10+rng.Intn(448<<10))
+		rng.Read(body)
+		name := fmt.Sprintf("part-%02d.bin", i)
+		sum := sha256.Sum256(body)
+		fs.files[name] = body
+		manifest = append(manifest, download.File{Name: name, Size: int64(len(body)), SHA256: hex.EncodeToString(sum[:])})
+	}
+	return fs, manifest
+}
+
+func (fs *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	body := fs.files[name]
+	fs.mu.Lock()
+	broken, failing, holding := name == fs.broken, fs.failing, fs.holding
+	if broken {
+		close(failing)
+	}
+	fs.requested[name] = true
+	fs.conns++
+	fs.bytes += int64(len(body))
+	fs.peakConns = max(fs.peakConns, fs.conns)
+	fs.peakBytes = max(fs.peakBytes, fs.bytes)
+	fs.mu.Unlock()
+	defer func() {
+		fs.mu.Lock()
+		fs.conns--
+		fs.bytes -= int64(len(body))
+		fs.mu.Unlock()
+	}()
+
+	if broken {
+		select {
+		case <-holding:
+			http.Error(w, "disk error", http.StatusInternalServerError)
+		case <-r.Context().Done():
+			fs.cancel()
+		}
+		return
+	}
+	delay := time.Duration(len(body)) * 10 * time.Millisecond / (100 << 10)
+	select {
+	case <-time.After(delay):
+		w.Write(body)
+	case <-failing:
+		select {
+		case holding <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+		fs.cancel()
+	case <-r.Context().Done():
+		fs.cancel()
+	}
+}
+
+func (fs *fileServer) cancel() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.canceled++
+}
+
+// breakFile makes name fail, or no file for ""
+func (fs *fileServer) breakFile(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.broken, fs.failing, fs.holding = name, nil, nil
+	if name != "" {
+		fs.failing, fs.holding = make(chan struct{}), make(chan struct{}, 1)
+	}
+}
+
+// settled waits for in-flight handlers to finish and reports what they saw
+func (fs *fileServer) settled(client *http.Client, baseline int) (requested, canceled int, leaked bool) {
+	client.CloseIdleConnections()
+	leaked = settle(baseline) > baseline
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.requested), fs.canceled, leaked
+}
+
+func (fs *fileServer) reset() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.peakConns, fs.peakBytes, fs.canceled = 0, 0, 0
+	fs.requested = map[string]bool{}
+}
+
+// settle waits for the goroutine count to drop back to baseline
+func settle(baseline int) int {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return runtime.NumGoroutine()
+}
+
+// saver records the files a download saved
+type saver struct {
+	mu    sync.Mutex
+	saved map[string]bool
+}
+
+func (s *saver) save(f download.File, _ []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved[f.Name] = true
+	return nil
+}
+
+func (s *saver) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.saved)
+}
+
+// TestDownloadAll downloads a batch of 24 files from a local server with
+// at most 4 connections and 1 MB buffered at once: cleanly, with a file that
+// fails while others are in flight, and with a caller that gives up
+func TestDownloadAll(t *testing.T) {
+	const budget = 1 << 20
+	fs, manifest := newFileServer(24)
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+	client := srv.Client()
+	files := &saver{saved: map[string]bool{}}
+	d := download.NewDownloader(client, srv.URL, 4, budget, files.save)
+
+	t.Run("a clean batch stays within both bounds", func(t *testing.T) {
+		baseline := runtime.NumGoroutine()
+		err := d.DownloadAll(context.Background(), manifest)
+		_, _, leaked := fs.settled(client, baseline)
+		if err != nil || files.count() != len(manifest) {
+			t.Fatalf("DownloadAll = %v, %d of %d files saved", err, files.count(), len(manifest))
+		}
+		if fs.peakConns > 4 || fs.peakConns < 2 {
+			t.Errorf("peak %d connections, want 2 to 4", fs.peakConns)
+		}
+		if fs.peakBytes > budget {
+			t.Errorf("peak %d bytes in flight, over the %d budget", fs.peakBytes, budget)
+		}
+		if leaked || d.InUse() != 0 {
+			t.Errorf("left behind: goroutines %t, %d bytes held", leaked, d.InUse())
+		}
+	})
+
+	t.Run("a file that fails while others are in flight cancels them", func(t *testing.T) {
+		baseline := runtime.NumGoroutine()
+		fs.reset()
+		fs.breakFile(manifest[6].Name)
+		defer fs.breakFile("")
+		err := d.DownloadAll(context.Background(), manifest)
+		requested, canceled, leaked := fs.settled(client, baseline)
+		if err == nil || !strings.Contains(err.Error(), manifest[6].Name) {
+			t.Errorf("DownloadAll = %v, want the error of %s", err, manifest[6].Name)
+		}
+		if requested >= len(manifest) {
+			t.Errorf("requested %d of %d files, want the ones not yet started never requested", requested, len(manifest))
+		}
+		if canceled == 0 {
+			t.Error("no download in flight was canceled")
+		}
+		if leaked || d.InUse() != 0 {
+			t.Errorf("left behind: goroutines %t, %d bytes held", leaked, d.InUse())
+		}
+	})
+
+	t.Run("the whole batch stops at the caller's deadline", func(t *testing.T) {
+		baseline := runtime.NumGoroutine()
+		fs.reset()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := d.DownloadAll(ctx, manifest)
+		if elapsed := time.Since(start); !errors.Is(err, context.DeadlineExceeded) || elapsed >= 100*time.Millisecond {
+			t.Errorf("DownloadAll = %v after %s, want DeadlineExceeded after 30ms", err, elapsed)
+		}
+		if _, _, leaked := fs.settled(client, baseline); leaked || d.InUse() != 0 {
+			t.Errorf("left behind: goroutines %t, %d bytes held", leaked, d.InUse())
+		}
+	})
+}
+
+func TestConnectionsMustBePositive(t *testing.T) {
+	_, manifest := newFileServer(1)
+	for _, connections := range []int{0, -1} {
+		t.Run(fmt.Sprint(connections), func(t *testing.T) {
+			d := download.NewDownloader(http.DefaultClient, "http://127.0.0.1:0", connections, 1<<20, nil)
+			done := make(chan error, 1)
+			go func() { done <- d.DownloadAll(context.Background(), manifest) }()
+			select {
+			case err := <-done:
+				if !errors.Is(err, download.ErrNoConnections) {
+					t.Errorf("DownloadAll = %v, want ErrNoConnections", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("DownloadAll is stuck waiting for a connection")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package errgroup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Structured concurrency: a Group owns the goroutines started with Go, and
+// Wait does not return until every one of them has. No goroutine outlives
+// the function that started it, and no error is lost.
+//
+// With WithContext, the first error cancels the group's context, so the
+// others stop early instead of finishing work nobody will use. Wait returns
+// that first error; later ones are usually just "context canceled" echoes.
+// SetLimit bounds how many run at once, which makes Go block, or TryGo fail,
+// once the limit is reached.
+
+type Group struct {
+	cancel func(error)
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	errOnce sync.Once
+	err     error
+}
+
+// WithContext returns a group whose context is canceled, with the error as
+// its cause, by the first function that fails or when Wait returns
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit allows at most n functions to run at once; n < 0 removes the
+// limit. It must not be called while functions are running.
+func (g *Group) SetLimit(n int) {
+	if len(g.sem) != 0 {
+		panic(fmt.Sprintf("errgroup: changing the limit while %d functions are running", len(g.sem)))
+	}
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go runs f in a new goroutine, first waiting for room under the limit
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.run(f)
+}
+
+// TryGo runs f only if there is room under the limit now
+func (g *Group) TryGo(f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.run(f)
+	return true
+}
+
+func (g *Group) run(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := f(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// Wait blocks until every function has returned and returns the first error
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
@@ -0,0 +1,90 @@
+package errgroup_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/errgroup"
+)
+
+func TestFirstErrorCancelsTheRest(t *testing.T) {
+	g, ctx := errgroup.WithContext(context.Background())
+	boom := errors.New("boom")
+	var finished atomic.Int32
+	var sawCause atomic.Bool
+	for i := 0; i < 5; i++ {
+		i := i
+		g.Go(func() error {
+			defer finished.Add(1)
+			if i == 2 {
+				time.Sleep(10 * time.Millisecond)
+				return boom
+			}
+			select {
+			case <-ctx.Done():
+				sawCause.Store(context.Cause(ctx) == boom)
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		})
+	}
+	start := time.Now()
+	err := g.Wait()
+	elapsed := time.Since(start)
+
+	t.Run("Wait returns the first error, not the cancellations it caused", func(t *testing.T) {
+		if err != boom {
+			t.Errorf("Wait = %v, want boom", err)
+		}
+	})
+	t.Run("the others stop early, and Wait waits for all of them", func(t *testing.T) {
+		if finished.Load() != 5 || elapsed >= 200*time.Millisecond {
+			t.Errorf("%d of 5 finished after %s", finished.Load(), elapsed)
+		}
+	})
+	t.Run("they can tell why from context.Cause", func(t *testing.T) {
+		if !sawCause.Load() {
+			t.Error("context.Cause was not the error")
+		}
+	})
+}
+
+func TestSetLimit(t *testing.T) {
+	t.Run("at most the limit run at once", func(t *testing.T) {
+		g := &errgroup.Group{}
+		g.SetLimit(3)
+		var running, peak atomic.Int32
+		for i := 0; i < 20; i++ {
+			g.Go(func() error {
+				n := running.Add(1)
+				for p := peak.Load(); n > p; p = peak.Load() {
+					if peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil || peak.Load() != 3 {
+			t.Errorf("Wait = %v, peak %d; want nil and 3", err, peak.Load())
+		}
+	})
+
+	t.Run("TryGo refuses while the group is at its limit", func(t *testing.T) {
+		g := &errgroup.Group{}
+		g.SetLimit(1)
+		release := make(chan struct{})
+		g.Go(func() error { <-release; return nil })
+		if g.TryGo(func() error { return nil }) {
+			t.Error("TryGo ran a second function")
+		}
+		close(release)
+		g.Wait()
+	})
+}
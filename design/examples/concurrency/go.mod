@@ -0,0 +1,3 @@
+module github.com/dong-tran/docs/concurrency-example
+
+go 1.21
@@ -0,0 +1,151 @@
+package lazy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// OncePerKey builds one value per key, on first use, exactly once: a client
+// per upstream, a connection pool per tenant. It is double-checked locking
+// done right:
+//
+//  1. Look the key up under the read lock. Once a key exists this is the
+//     only step, and readers do not block each other.
+//  2. On a miss, take the write lock and look again. Another goroutine may
+//     have created the entry between the two locks; skipping this second
+//     check is what builds the value twice.
+//  3. Insert an unfinished entry and release the lock before building.
+//     Building under the lock would make every other key wait on a slow one.
+//  4. Everyone else waits on the entry's done channel, which is closed after
+//     the value is stored, so they see it fully built.
+//
+// Checking the map without any lock is not a shortcut but a data race.
+// A failed build is not kept: its waiters get the error, and the next Get
+// tries again.
+
+var ErrPanicked = errors.New("lazy: constructor panicked")
+
+type entry[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+type OncePerKey[K comparable, V any] struct {
+	build func(K) (V, error)
+
+	mu      sync.RWMutex
+	entries map[K]*entry[V]
+
+	builds atomic.Int64
+}
+
+func NewOncePerKey[K comparable, V any](build func(K) (V, error)) *OncePerKey[K, V] {
+	return &OncePerKey[K, V]{build: build, entries: make(map[K]*entry[V])}
+}
+
+// Get returns key's value, building it if this is the first call for key
+func (o *OncePerKey[K, V]) Get(key K) (V, error) {
+	o.mu.RLock()
+	e := o.entries[key]
+	o.mu.RUnlock()
+
+	if e == nil {
+		o.mu.Lock()
+		e = o.entries[key]
+		if e == nil {
+			e = &entry[V]{done: make(chan struct{})}
+			o.entries[key] = e
+			o.mu.Unlock()
+			o.construct(key, e)
+		} else {
+			o.mu.Unlock()
+		}
+	}
+
+	<-e.done
+	return e.val, e.err
+}
+
+func (o *OncePerKey[K, V]) construct(key K, e *entry[V]) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.err = fmt.Errorf("%w: %v", ErrPanicked, r)
+		}
+		if e.err != nil {
+			o.mu.Lock()
+			if o.entries[key] == e {
+				delete(o.entries, key)
+			}
+			o.mu.Unlock()
+		}
+		close(e.done)
+	}()
+	o.builds.Add(1)
+	e.val, e.err = o.build(key)
+}
+
+// Peek returns key's value only if it is already built
+func (o *OncePerKey[K, V]) Peek(key K) (V, bool) {
+	o.mu.RLock()
+	e := o.entries[key]
+	o.mu.RUnlock()
+	if e != nil {
+		select {
+		case <-e.done:
+			return e.val, e.err == nil
+		default:
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Delete forgets key and returns its value, if built, so the caller can
+// close it. The next Get builds a new one.
+func (o *OncePerKey[K, V]) Delete(key K) (V, bool) {
+	o.mu.Lock()
+	e := o.entries[key]
+	delete(o.entries, key)
+	o.mu.Unlock()
+	if e != nil {
+		<-e.done
+		return e.val, e.err == nil
+	}
+	var zero V
+	return zero, false
+}
+
+// Range calls fn for every built value, for example to close them all on
+// shutdown. Values still being built are skipped.
+func (o *OncePerKey[K, V]) Range(fn func(key K, val V)) {
+	o.mu.RLock()
+	built := make(map[K]V, len(o.entries))
+	for key, e := range o.entries {
+		select {
+		case <-e.done:
+			if e.err == nil {
+				built[key] = e.val
+			}
+		default:
+		}
+	}
+	o.mu.RUnlock()
+	for key, val := range built {
+		fn(key, val)
+	}
+}
+
+// Len returns the number of keys held, built or being built
+func (o *OncePerKey[K, V]) Len() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.entries)
+}
+
+// Builds returns how many times the constructor has run
+func (o *OncePerKey[K, V]) Builds() int64 {
+	return o.builds.Load()
+}
@@ -0,0 +1,250 @@
+package lazy_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/lazy"
+)
+
+// client stands in for an expensive per-tenant resource
+type client struct {
+	tenant string
+	closed atomic.Bool
+}
+
+type getter interface {
+	Get(key string) (*client, error)
+}
+
+// hammer has 50 goroutines per key ask for 20 keys at once and returns how
+// long it took and whether every goroutine got the same client for its key
+func hammer(g getter) (time.Duration, bool) {
+	const keys, perKey = 20, 50
+	got := make([][]*client, keys)
+	for k := range got {
+		got[k] = make([]*client, perKey)
+	}
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for k := 0; k < keys; k++ {
+		for i := 0; i < perKey; i++ {
+			wg.Add(1)
+			go func(k, i int) {
+				defer wg.Done()
+				<-start
+				got[k][i], _ = g.Get(fmt.Sprintf("tenant-%d", k))
+			}(k, i)
+		}
+	}
+	began := time.Now()
+	close(start)
+	wg.Wait()
+	same := true
+	for k := range got {
+		for _, c := range got[k] {
+			same = same && c != nil && c == got[k][0]
+		}
+	}
+	return time.Since(began), same
+}
+
+// slowBuild takes 10ms, like dialing a connection
+func slowBuild(builds *atomic.Int64) func(string) (*client, error) {
+	return func(tenant string) (*client, error) {
+		builds.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return &client{tenant: tenant}, nil
+	}
+}
+
+// noRecheck checks under the read lock, builds, then stores under the write
+// lock without looking again
+type noRecheck struct {
+	build   func(string) (*client, error)
+	mu      sync.RWMutex
+	clients map[string]*client
+}
+
+func (n *noRecheck) Get(key string) (*client, error) {
+	n.mu.RLock()
+	c := n.clients[key]
+	n.mu.RUnlock()
+	if c != nil {
+		return c, nil
+	}
+	c, err := n.build(key)
+	if err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.clients[key] = c
+	return c, nil
+}
+
+// buildUnderLock holds one lock for the lookup and the build
+type buildUnderLock struct {
+	build   func(string) (*client, error)
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+func (b *buildUnderLock) Get(key string) (*client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.clients[key]; ok {
+		return c, nil
+	}
+	c, err := b.build(key)
+	if err == nil {
+		b.clients[key] = c
+	}
+	return c, err
+}
+
+// TestHammer runs 1000 goroutines over 20 keys through OncePerKey and
+// through two tempting shortcuts
+func TestHammer(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		new   func(build func(string) (*client, error)) getter
+		check func(builds int64, took time.Duration, same bool) error
+	}{
+		{
+			"OncePerKey builds each key once, in parallel",
+			func(build func(string) (*client, error)) getter { return lazy.NewOncePerKey(build) },
+			func(builds int64, took time.Duration, same bool) error {
+				if builds != 20 || !same || took >= 100*time.Millisecond {
+					return fmt.Errorf("%d builds in %s, same clients %t; want 20, under 100ms, the same", builds, took, same)
+				}
+				return nil
+			},
+		},
+		{
+			"skipping the second check builds keys more than once",
+			func(build func(string) (*client, error)) getter {
+				return &noRecheck{build: build, clients: map[string]*client{}}
+			},
+			func(builds int64, _ time.Duration, same bool) error {
+				if builds <= 20 || same {
+					return fmt.Errorf("%d builds, same clients %t; want more than 20 and different clients", builds, same)
+				}
+				return nil
+			},
+		},
+		{
+			"building under the lock is correct, but one key at a time",
+			func(build func(string) (*client, error)) getter {
+				return &buildUnderLock{build: build, clients: map[string]*client{}}
+			},
+			func(builds int64, took time.Duration, _ bool) error {
+				if builds != 20 || took < 200*time.Millisecond {
+					return fmt.Errorf("%d builds in %s; want 20, taking at least 200ms", builds, took)
+				}
+				return nil
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var builds atomic.Int64
+			took, same := hammer(tc.new(slowBuild(&builds)))
+			t.Logf("%d builds in %s", builds.Load(), took)
+			if err := tc.check(builds.Load(), took, same); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSlowKey(t *testing.T) {
+	slow := lazy.NewOncePerKey(func(tenant string) (*client, error) {
+		if tenant == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return &client{tenant: tenant}, nil
+	})
+	go slow.Get("slow")
+	time.Sleep(5 * time.Millisecond)
+
+	t.Run("other keys are not held up while one builds", func(t *testing.T) {
+		start := time.Now()
+		slow.Get("fast")
+		if took := time.Since(start); took >= 20*time.Millisecond {
+			t.Errorf("Get(fast) took %s", took)
+		}
+	})
+	t.Run("Peek does not wait for a value still being built", func(t *testing.T) {
+		if _, built := slow.Peek("slow"); built {
+			t.Error("Peek reported the slow key built")
+		}
+	})
+}
+
+func TestFailures(t *testing.T) {
+	var attempts atomic.Int64
+	flaky := lazy.NewOncePerKey(func(tenant string) (*client, error) {
+		time.Sleep(10 * time.Millisecond)
+		if attempts.Add(1) == 1 {
+			return nil, errors.New("tenant database unreachable")
+		}
+		return &client{tenant: tenant}, nil
+	})
+
+	t.Run("one failed build fails everyone waiting on it", func(t *testing.T) {
+		var errs atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := flaky.Get("acme"); err != nil {
+					errs.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if attempts.Load() != 1 || errs.Load() != 100 {
+			t.Errorf("%d builds, %d errors; want 1 and 100", attempts.Load(), errs.Load())
+		}
+	})
+	t.Run("the failure is not kept: the next Get builds again", func(t *testing.T) {
+		if c, err := flaky.Get("acme"); err != nil || c.tenant != "acme" || attempts.Load() != 2 {
+			t.Errorf("Get = %v, %v after %d builds", c, err, attempts.Load())
+		}
+	})
+	t.Run("a panicking constructor is reported as an error and not kept", func(t *testing.T) {
+		panicky := lazy.NewOncePerKey(func(string) (*client, error) { panic("bad config") })
+		if _, err := panicky.Get("acme"); !errors.Is(err, lazy.ErrPanicked) || panicky.Len() != 0 {
+			t.Errorf("Get = %v with %d kept", err, panicky.Len())
+		}
+	})
+}
+
+func TestClosingWhatWasBuilt(t *testing.T) {
+	var builds atomic.Int64
+	once := lazy.NewOncePerKey(slowBuild(&builds))
+	hammer(once)
+
+	t.Run("Delete hands back the value to close; the next Get builds a new one", func(t *testing.T) {
+		old, _ := once.Delete("tenant-3")
+		old.closed.Store(true)
+		if fresh, _ := once.Get("tenant-3"); fresh == old || once.Builds() != 21 {
+			t.Errorf("Get after Delete: same value %t, %d builds", fresh == old, once.Builds())
+		}
+	})
+	t.Run("Range visits every built value, to close them on shutdown", func(t *testing.T) {
+		closed := 0
+		once.Range(func(_ string, c *client) {
+			c.closed.Store(true)
+			closed++
+		})
+		if closed != 20 {
+			t.Errorf("Range visited %d, want 20", closed)
+		}
+	})
+}
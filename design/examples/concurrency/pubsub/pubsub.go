@@ -0,0 +1,297 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Broker is an in-process publish/subscribe hub. Subscribers pick topics by
+// pattern and each gets its own buffered queue, so one slow subscriber never
+// delays delivery to the others unless it asks to: its Policy decides what
+// happens when its queue is full.
+//
+//   - Block: the publisher waits for room (backpressure), until its context
+//     ends.
+//   - DropOldest: the oldest queued message is discarded to make room, so the
+//     subscriber always sees the most recent messages.
+//
+// Close stops publishing and closes every subscription channel once the
+// in-flight publishes are done; messages already queued can still be read.
+
+var (
+	ErrClosed     = errors.New("pubsub: broker closed")
+	ErrBadPattern = errors.New("pubsub: empty topic or pattern segment")
+)
+
+type Policy int
+
+const (
+	Block Policy = iota
+	DropOldest
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	}
+	return "unknown"
+}
+
+type Message[T any] struct {
+	Topic   string
+	Payload T
+}
+
+type Options struct {
+	Buffer int // queued messages, default 16
+	Policy Policy
+}
+
+type SubscriptionStats struct {
+	Pattern   string
+	Delivered uint64
+	Dropped   uint64
+	Queued    int
+}
+
+type Subscription[T any] struct {
+	pattern []string
+	policy  Policy
+	broker  *Broker[T]
+	ch      chan Message[T]
+	gone    chan struct{} // closed on Unsubscribe, releases blocked publishers
+
+	mu     sync.Mutex // serializes sends with each other and with closing ch
+	closed bool
+	once   sync.Once
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// C returns the channel messages arrive on. It is closed after Unsubscribe or
+// Close; range over it to read until then.
+func (s *Subscription[T]) C() <-chan Message[T] {
+	return s.ch
+}
+
+// Unsubscribe stops delivery and closes C. Messages already queued are still
+// readable. It is safe to call more than once and concurrently with Publish.
+func (s *Subscription[T]) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.gone)
+		s.broker.remove(s)
+		s.closeChannel()
+	})
+}
+
+func (s *Subscription[T]) closeChannel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+func (s *Subscription[T]) Stats() SubscriptionStats {
+	return SubscriptionStats{
+		Pattern:   strings.Join(s.pattern, "."),
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+		Queued:    len(s.ch),
+	}
+}
+
+// deliver queues m according to the subscription's policy
+func (s *Subscription[T]) deliver(ctx context.Context, m Message[T], abort <-chan struct{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	if s.policy == DropOldest {
+		for {
+			select {
+			case s.ch <- m:
+				s.delivered.Add(1)
+				return nil
+			default:
+			}
+			// Full: drop the oldest. The subscriber may have read it in the
+			// meantime, in which case there is room now.
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	}
+	select {
+	case s.ch <- m:
+		s.delivered.Add(1)
+		return nil
+	case <-s.gone:
+		return nil // nobody is listening any more; not the publisher's problem
+	case <-abort:
+		s.dropped.Add(1)
+		return ErrClosed
+	case <-ctx.Done():
+		s.dropped.Add(1)
+		return ctx.Err()
+	}
+}
+
+func (s *Subscription[T]) matches(topic []string) bool {
+	if len(topic) != len(s.pattern) {
+		return false
+	}
+	for i, seg := range s.pattern {
+		if seg != "*" && seg != topic[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type Broker[T any] struct {
+	mu       sync.RWMutex
+	subs     map[*Subscription[T]]struct{}
+	closed   bool
+	inflight sync.WaitGroup
+	abort    chan struct{} // closed when Close gives up waiting
+}
+
+func New[T any]() *Broker[T] {
+	return &Broker[T]{subs: make(map[*Subscription[T]]struct{}), abort: make(chan struct{})}
+}
+
+func split(s string) ([]string, error) {
+	segs := strings.Split(s, ".")
+	for _, seg := range segs {
+		if seg == "" {
+			return nil, ErrBadPattern
+		}
+	}
+	return segs, nil
+}
+
+// Subscribe receives every message whose topic matches pattern. Topics are
+// dot-separated ("orders.created"); a "*" segment in the pattern matches any
+// one segment ("orders.*").
+func (b *Broker[T]) Subscribe(pattern string, opts Options) (*Subscription[T], error) {
+	segs, err := split(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Buffer < 1 {
+		opts.Buffer = 16
+	}
+	s := &Subscription[T]{
+		pattern: segs,
+		policy:  opts.Policy,
+		broker:  b,
+		ch:      make(chan Message[T], opts.Buffer),
+		gone:    make(chan struct{}),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+func (b *Broker[T]) remove(s *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, s)
+}
+
+// Publish delivers payload to every subscription matching topic and returns
+// once each has queued or dropped it. Subscriptions with the Block policy
+// can hold it up; if ctx ends first, the remaining ones miss the message and
+// ctx's error is returned.
+func (b *Broker[T]) Publish(ctx context.Context, topic string, payload T) error {
+	segs, err := split(topic)
+	if err != nil {
+		return err
+	}
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	b.inflight.Add(1)
+	defer b.inflight.Done()
+	var targets []*Subscription[T]
+	for s := range b.subs {
+		if s.matches(segs) {
+			targets = append(targets, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	m := Message[T]{Topic: topic, Payload: payload}
+	for _, s := range targets {
+		if err := s.deliver(ctx, m, b.abort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops accepting publishes and subscriptions, waits for in-flight
+// publishes, then closes every subscription. If ctx ends while a publish is
+// still blocked on a full subscriber, that publish fails with ErrClosed and
+// Close returns ctx's error.
+func (b *Broker[T]) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		close(b.abort)
+		<-done
+	}
+
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+	for s := range subs {
+		s.closeChannel()
+	}
+	return err
+}
+
+// Subscriptions returns the stats of every live subscription
+func (b *Broker[T]) Subscriptions() []SubscriptionStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := make([]SubscriptionStats, 0, len(b.subs))
+	for s := range b.subs {
+		stats = append(stats, s.Stats())
+	}
+	return stats
+}
@@ -0,0 +1,278 @@
+package pubsub_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/pubsub"
+)
+
+// drain reads everything queued on s without waiting for more
+func drain[T any](s *pubsub.Subscription[T]) []T {
+	var got []T
+	for {
+		select {
+		case m, ok := <-s.C():
+			if !ok {
+				return got
+			}
+			got = append(got, m.Payload)
+		default:
+			return got
+		}
+	}
+}
+
+func TestTopics(t *testing.T) {
+	published := []string{"orders.created", "orders.shipped", "payments.captured", "orders.created.eu"}
+	for _, tc := range []struct {
+		name, pattern string
+		want          []string
+	}{
+		{"exact topic", "orders.created", []string{"orders.created"}},
+		{"* matches one segment, not two", "orders.*", []string{"orders.created", "orders.shipped"}},
+		{"other topics are not delivered", "payments.*", []string{"payments.captured"}},
+		{"* matches any first segment", "*.created", []string{"orders.created"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := pubsub.New[string]()
+			defer b.Close(context.Background())
+			sub, err := b.Subscribe(tc.pattern, pubsub.Options{Buffer: len(published)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, topic := range published {
+				if err := b.Publish(context.Background(), topic, topic); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := drain(sub); !slices.Equal(got, tc.want) {
+				t.Errorf("%s got %q, want %q", tc.pattern, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBadPatterns(t *testing.T) {
+	b := pubsub.New[string]()
+	defer b.Close(context.Background())
+	for _, pattern := range []string{"", "orders..created", ".orders", "orders."} {
+		t.Run(fmt.Sprintf("%q", pattern), func(t *testing.T) {
+			if _, err := b.Subscribe(pattern, pubsub.Options{}); !errors.Is(err, pubsub.ErrBadPattern) {
+				t.Errorf("Subscribe(%q) = %v, want ErrBadPattern", pattern, err)
+			}
+		})
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	ctx := context.Background()
+	b := pubsub.New[int]()
+	defer b.Close(ctx)
+	slow, _ := b.Subscribe("prices", pubsub.Options{Buffer: 4, Policy: pubsub.DropOldest})
+	fast, _ := b.Subscribe("prices", pubsub.Options{Buffer: 128})
+	for i := 0; i < 100; i++ {
+		if err := b.Publish(ctx, "prices", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := slow.Stats()
+
+	t.Run("keeps the newest", func(t *testing.T) {
+		if got := drain(slow); !slices.Equal(got, []int{96, 97, 98, 99}) {
+			t.Errorf("slow subscriber got %v, want [96 97 98 99]", got)
+		}
+	})
+	t.Run("counts what it lost", func(t *testing.T) {
+		if stats.Dropped != 96 {
+			t.Errorf("Dropped = %d, want 96", stats.Dropped)
+		}
+	})
+	t.Run("the other subscriber gets everything", func(t *testing.T) {
+		if got := drain(fast); len(got) != 100 {
+			t.Errorf("fast subscriber got %d messages, want 100", len(got))
+		}
+	})
+}
+
+func TestBlock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("slows the publisher to the subscriber's pace and loses nothing", func(t *testing.T) {
+		b := pubsub.New[int]()
+		sub, _ := b.Subscribe("jobs", pubsub.Options{Buffer: 4, Policy: pubsub.Block})
+		var got []int
+		consumed := make(chan struct{})
+		go func() {
+			defer close(consumed)
+			for m := range sub.C() {
+				time.Sleep(time.Millisecond)
+				got = append(got, m.Payload)
+			}
+		}()
+		start := time.Now()
+		for i := 0; i < 50; i++ {
+			if err := b.Publish(ctx, "jobs", i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		elapsed := time.Since(start)
+		b.Close(ctx)
+		<-consumed
+		if elapsed < 40*time.Millisecond {
+			t.Errorf("50 publishes at 1ms per message took %s, want at least 40ms", elapsed)
+		}
+		want := make([]int, 50)
+		for i := range want {
+			want[i] = i
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("subscriber got %v, want 0 to 49 in order", got)
+		}
+	})
+
+	t.Run("a blocked publisher gives up with its context", func(t *testing.T) {
+		b := pubsub.New[int]()
+		defer b.Close(ctx)
+		b.Subscribe("jobs", pubsub.Options{Buffer: 1, Policy: pubsub.Block})
+		b.Publish(ctx, "jobs", 1)
+		tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if err := b.Publish(tctx, "jobs", 2); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Publish to a full subscriber = %v, want DeadlineExceeded", err)
+		}
+	})
+}
+
+func TestConcurrentPublishersAndSubscribers(t *testing.T) {
+	const publishers, perPublisher, subscribers = 8, 1000, 4
+	ctx := context.Background()
+	b := pubsub.New[[2]int]()
+	results := make([][][2]int, subscribers)
+	var readers sync.WaitGroup
+	for i := range results {
+		s, err := b.Subscribe("events.*", pubsub.Options{Buffer: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers.Add(1)
+		go func(i int) {
+			defer readers.Done()
+			for m := range s.C() {
+				results[i] = append(results[i], m.Payload)
+			}
+		}(i)
+	}
+
+	// Churn: subscriptions that come and go while publishing runs
+	stopChurn := make(chan struct{})
+	churned := make(chan int)
+	go func() {
+		n := 0
+		for {
+			select {
+			case <-stopChurn:
+				churned <- n
+				return
+			default:
+			}
+			if s, err := b.Subscribe("events.*", pubsub.Options{Buffer: 1, Policy: pubsub.Block}); err == nil {
+				drain(s)
+				s.Unsubscribe()
+				n++
+			}
+		}
+	}()
+
+	var pubs sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		pubs.Add(1)
+		go func(p int) {
+			defer pubs.Done()
+			for i := 0; i < perPublisher; i++ {
+				b.Publish(ctx, fmt.Sprintf("events.p%d", p), [2]int{p, i})
+			}
+		}(p)
+	}
+	pubs.Wait()
+	close(stopChurn)
+	t.Logf("%d subscriptions churned while publishing", <-churned)
+	b.Close(ctx)
+	readers.Wait()
+
+	for i, r := range results {
+		t.Run(fmt.Sprintf("subscriber %d", i), func(t *testing.T) {
+			if len(r) != publishers*perPublisher {
+				t.Fatalf("got %d messages, want %d", len(r), publishers*perPublisher)
+			}
+			next := make([]int, publishers)
+			for _, m := range r {
+				if m[1] != next[m[0]] {
+					t.Fatalf("publisher %d: got message %d, want %d", m[0], m[1], next[m[0]])
+				}
+				next[m[0]]++
+			}
+		})
+	}
+	if n := len(b.Subscriptions()); n != 0 {
+		t.Errorf("%d subscriptions outlive Close", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	b := pubsub.New[int]()
+	queued, _ := b.Subscribe("t", pubsub.Options{Buffer: 2})
+	full, _ := b.Subscribe("t", pubsub.Options{Buffer: 2})
+	b.Publish(ctx, "t", 1)
+	b.Publish(ctx, "t", 2)
+	blocked := make(chan error)
+	go func() { blocked <- b.Publish(ctx, "t", 3) }() // full and nobody reads
+	time.Sleep(10 * time.Millisecond)
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	err := b.Close(tctx)
+	cancel()
+
+	t.Run("gives up on a subscriber that never reads", func(t *testing.T) {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Close = %v, want DeadlineExceeded", err)
+		}
+	})
+	t.Run("fails the publish it held up", func(t *testing.T) {
+		if err := <-blocked; !errors.Is(err, pubsub.ErrClosed) {
+			t.Errorf("blocked Publish = %v, want ErrClosed", err)
+		}
+	})
+	t.Run("queued messages stay readable, then the channel closes", func(t *testing.T) {
+		var rest []int
+		for m := range queued.C() {
+			rest = append(rest, m.Payload)
+		}
+		if !slices.Equal(rest, []int{1, 2}) {
+			t.Errorf("got %v, want [1 2]", rest)
+		}
+	})
+	t.Run("every subscription channel is closed", func(t *testing.T) {
+		if got := drain(full); len(got) != 2 {
+			t.Errorf("got %v, want 2 messages and a closed channel", got)
+		}
+		if _, ok := <-full.C(); ok {
+			t.Error("channel still open")
+		}
+	})
+	t.Run("publishing after Close fails", func(t *testing.T) {
+		if err := b.Publish(ctx, "t", 4); !errors.Is(err, pubsub.ErrClosed) {
+			t.Errorf("Publish = %v, want ErrClosed", err)
+		}
+	})
+	t.Run("subscribing after Close fails", func(t *testing.T) {
+		if _, err := b.Subscribe("t", pubsub.Options{}); !errors.Is(err, pubsub.ErrClosed) {
+			t.Errorf("Subscribe = %v, want ErrClosed", err)
+		}
+	})
+}
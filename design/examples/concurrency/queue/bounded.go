@@ -0,0 +1,222 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// A bounded buffer between producers and consumers. Put blocks while the
+// buffer is full, so producers slow to the consumers' pace instead of
+// growing memory without limit; Take blocks while it is empty.
+//
+// Close stops new Puts and decides what happens to what is still queued:
+//
+//   - Drain lets consumers finish the queue. Close waits until they have, or
+//     until its context ends, and then abandons whatever is left.
+//   - Abandon drops the queue at once and hands the items back to the caller,
+//     to requeue elsewhere or log. Consumers stop after their current item.
+//
+// Either way nothing is lost silently: every item Put is either taken or
+// returned by Close.
+
+var ErrClosed = errors.New("queue: closed")
+
+type CloseMode int
+
+const (
+	Drain CloseMode = iota
+	Abandon
+)
+
+func (m CloseMode) String() string {
+	switch m {
+	case Drain:
+		return "drain"
+	case Abandon:
+		return "abandon"
+	}
+	return "unknown"
+}
+
+type Bounded[T any] struct {
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	items   []T
+	closed  bool
+	changed chan struct{} // closed and replaced whenever items or closed change
+
+	put, taken, abandoned, putWaits int64
+	highWater                       int
+	depthArea                       float64 // depth integrated over time, in item-seconds
+	lastChange, created             time.Time
+}
+
+// NewBounded returns a buffer holding at most capacity items. now may be nil
+// for time.Now.
+func NewBounded[T any](capacity int, now func() time.Time) *Bounded[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if now == nil {
+		now = time.Now
+	}
+	t := now()
+	return &Bounded[T]{capacity: capacity, now: now, changed: make(chan struct{}), lastChange: t, created: t}
+}
+
+// Put adds v, waiting for room while the buffer is full. It fails with
+// ErrClosed once Close has been called, even while waiting.
+func (b *Bounded[T]) Put(ctx context.Context, v T) error {
+	waited := false
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return ErrClosed
+		}
+		if len(b.items) < b.capacity {
+			b.account()
+			b.items = append(b.items, v)
+			b.put++
+			if waited {
+				b.putWaits++
+			}
+			b.highWater = max(b.highWater, len(b.items))
+			b.signal()
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		waited = true
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Take removes the oldest item, waiting while the buffer is empty. It fails
+// with ErrClosed once the buffer is closed and empty.
+func (b *Bounded[T]) Take(ctx context.Context) (T, error) {
+	for {
+		b.mu.Lock()
+		if len(b.items) > 0 {
+			b.account()
+			v := b.items[0]
+			var zero T
+			b.items[0] = zero
+			b.items = b.items[1:]
+			b.taken++
+			b.signal()
+			b.mu.Unlock()
+			return v, nil
+		}
+		if b.closed {
+			b.mu.Unlock()
+			var zero T
+			return zero, ErrClosed
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Close stops new Puts. With Drain it waits for consumers to empty the buffer
+// until ctx ends; whatever is still queued then, or at once with Abandon, is
+// removed and returned. err is ctx's error if draining did not finish.
+func (b *Bounded[T]) Close(ctx context.Context, mode CloseMode) (abandoned []T, err error) {
+	b.mu.Lock()
+	b.closed = true
+	b.signal()
+	b.mu.Unlock()
+
+	if mode == Drain {
+		if err = b.waitEmpty(ctx); err == nil {
+			return nil, nil
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.account()
+	abandoned, b.items = b.items, nil
+	b.abandoned += int64(len(abandoned))
+	b.signal()
+	return abandoned, err
+}
+
+func (b *Bounded[T]) waitEmpty(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		empty, changed := len(b.items) == 0, b.changed
+		b.mu.Unlock()
+		if empty {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// signal wakes everyone waiting for a change. Called with mu held.
+func (b *Bounded[T]) signal() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// account adds the current depth over the time since the last change, for
+// the time-weighted average. Called with mu held, before the depth changes.
+func (b *Bounded[T]) account() {
+	now := b.now()
+	b.depthArea += float64(len(b.items)) * now.Sub(b.lastChange).Seconds()
+	b.lastChange = now
+}
+
+type Stats struct {
+	Depth     int
+	Capacity  int
+	HighWater int     // deepest the buffer has been
+	AvgDepth  float64 // averaged over time since creation
+	Put       int64
+	Taken     int64
+	Abandoned int64
+	PutWaits  int64 // Puts that had to wait for room: backpressure
+	Closed    bool
+}
+
+func (b *Bounded[T]) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.account()
+	s := Stats{
+		Depth:     len(b.items),
+		Capacity:  b.capacity,
+		HighWater: b.highWater,
+		Put:       b.put,
+		Taken:     b.taken,
+		Abandoned: b.abandoned,
+		PutWaits:  b.putWaits,
+		Closed:    b.closed,
+	}
+	if elapsed := b.lastChange.Sub(b.created).Seconds(); elapsed > 0 {
+		s.AvgDepth = b.depthArea / elapsed
+	}
+	return s
+}
@@ -0,0 +1,228 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/queue"
+)
+
+type job struct {
+	producer, seq int
+}
+
+// workers takes jobs until the buffer is closed and empty, spending work on
+// each
+type workers struct {
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	processed []job
+}
+
+func startWorkers(q *queue.Bounded[job], n int, work time.Duration) *workers {
+	w := &workers{}
+	for i := 0; i < n; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for {
+				j, err := q.Take(context.Background())
+				if errors.Is(err, queue.ErrClosed) {
+					return
+				}
+				time.Sleep(work)
+				w.mu.Lock()
+				w.processed = append(w.processed, j)
+				w.mu.Unlock()
+			}
+		}()
+	}
+	return w
+}
+
+// produce puts perProducer jobs from each of n producers and returns how
+// many each managed before the buffer closed
+func produce(q *queue.Bounded[job], n, perProducer int) []int {
+	put := make([]int, n)
+	var wg sync.WaitGroup
+	for p := 0; p < n; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				if q.Put(context.Background(), job{p, i}) != nil {
+					return
+				}
+				put[p]++
+			}
+		}(p)
+	}
+	wg.Wait()
+	return put
+}
+
+// exactlyOnce reports whether processed and abandoned together hold each
+// of total jobs once
+func exactlyOnce(total int, lists ...[]job) bool {
+	seen := map[job]int{}
+	n := 0
+	for _, l := range lists {
+		for _, j := range l {
+			seen[j]++
+			n++
+		}
+	}
+	for _, c := range seen {
+		if c != 1 {
+			return false
+		}
+	}
+	return n == total
+}
+
+func TestBackpressure(t *testing.T) {
+	q := queue.NewBounded[job](8, nil)
+	w := startWorkers(q, 2, time.Millisecond)
+	start := time.Now()
+	produce(q, 1, 200)
+	elapsed := time.Since(start)
+	q.Close(context.Background(), queue.Drain)
+	w.wg.Wait()
+	st := q.Stats()
+	t.Logf("200 jobs into a buffer of 8 in %s; high water %d, average depth %.1f, %d puts waited",
+		elapsed, st.HighWater, st.AvgDepth, st.PutWaits)
+
+	t.Run("the buffer fills and the producer waits, instead of memory growing", func(t *testing.T) {
+		if st.HighWater != 8 || st.PutWaits == 0 {
+			t.Errorf("high water %d, %d puts waited", st.HighWater, st.PutWaits)
+		}
+	})
+	t.Run("the producer is slowed to the consumers' pace", func(t *testing.T) {
+		if elapsed < 80*time.Millisecond {
+			t.Errorf("200 puts took %s, faster than 2 jobs per ms", elapsed)
+		}
+	})
+	t.Run("every job is processed once, in roughly the order put", func(t *testing.T) {
+		if !exactlyOnce(200, w.processed) {
+			t.Fatal("a job was lost or processed twice")
+		}
+		last := -1
+		for _, j := range w.processed {
+			if j.seq <= last-2 { // two consumers may finish neighbours out of order
+				t.Errorf("job %d after job %d", j.seq, last)
+			}
+			last = max(last, j.seq)
+		}
+	})
+}
+
+func TestDrain(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewBounded[job](64, nil)
+	produce(q, 4, 16)
+	w := startWorkers(q, 4, time.Millisecond)
+	abandoned, err := q.Close(ctx, queue.Drain)
+	w.wg.Wait()
+
+	t.Run("Close waits until consumers have taken everything", func(t *testing.T) {
+		if err != nil || len(abandoned) != 0 {
+			t.Errorf("Close = %d abandoned, %v", len(abandoned), err)
+		}
+	})
+	t.Run("every queued job is processed", func(t *testing.T) {
+		if !exactlyOnce(64, w.processed) {
+			t.Errorf("%d processed, want each of 64 once", len(w.processed))
+		}
+	})
+	t.Run("new jobs are refused", func(t *testing.T) {
+		if err := q.Put(ctx, job{}); !errors.Is(err, queue.ErrClosed) {
+			t.Errorf("Put = %v, want ErrClosed", err)
+		}
+	})
+	t.Run("consumers are told to stop once it is empty", func(t *testing.T) {
+		if _, err := q.Take(ctx); !errors.Is(err, queue.ErrClosed) {
+			t.Errorf("Take = %v, want ErrClosed", err)
+		}
+	})
+}
+
+func TestDrainWithDeadline(t *testing.T) {
+	q := queue.NewBounded[job](64, nil)
+	produce(q, 4, 16)
+	w := startWorkers(q, 2, 5*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	abandoned, err := q.Close(ctx, queue.Drain)
+	elapsed := time.Since(start)
+	w.wg.Wait()
+	t.Logf("after %s: %d processed, %d handed back", elapsed, len(w.processed), len(abandoned))
+
+	t.Run("Close gives up draining at its deadline", func(t *testing.T) {
+		if !errors.Is(err, context.DeadlineExceeded) || elapsed >= 70*time.Millisecond {
+			t.Errorf("Close = %v after %s, want DeadlineExceeded after 50ms", err, elapsed)
+		}
+	})
+	t.Run("and hands back what was left: each job processed or returned, once", func(t *testing.T) {
+		if len(abandoned) == 0 || !exactlyOnce(64, w.processed, abandoned) {
+			t.Errorf("%d processed, %d handed back", len(w.processed), len(abandoned))
+		}
+	})
+}
+
+func TestAbandon(t *testing.T) {
+	q := queue.NewBounded[job](16, nil)
+	w := startWorkers(q, 2, 10*time.Millisecond)
+	putDone := make(chan []int)
+	go func() { putDone <- produce(q, 3, 100) }() // far more than fits, so producers block
+	time.Sleep(30 * time.Millisecond)
+	start := time.Now()
+	abandoned, err := q.Close(context.Background(), queue.Abandon)
+	closeTook := time.Since(start)
+	put := <-putDone
+	w.wg.Wait()
+	stopped := time.Since(start)
+	total := put[0] + put[1] + put[2]
+	t.Logf("%d jobs put; %d processed, %d handed back", total, len(w.processed), len(abandoned))
+
+	t.Run("Close returns the queue at once", func(t *testing.T) {
+		if err != nil || closeTook >= time.Millisecond || len(abandoned) < 10 {
+			t.Errorf("Close = %d abandoned, %v after %s", len(abandoned), err, closeTook)
+		}
+	})
+	t.Run("producers blocked on a full buffer are released with ErrClosed", func(t *testing.T) {
+		if total >= 300 {
+			t.Errorf("all %d jobs were put", total)
+		}
+	})
+	t.Run("consumers stop after the job in hand", func(t *testing.T) {
+		if stopped >= 20*time.Millisecond {
+			t.Errorf("consumers stopped after %s", stopped)
+		}
+	})
+	t.Run("each job put was processed or handed back, once", func(t *testing.T) {
+		if !exactlyOnce(total, w.processed, abandoned) {
+			t.Errorf("%d put, %d processed, %d handed back", total, len(w.processed), len(abandoned))
+		}
+	})
+
+	st := q.Stats()
+	t.Run("a closed buffer is empty", func(t *testing.T) {
+		if st.Depth != 0 || !st.Closed {
+			t.Errorf("depth %d, closed %t", st.Depth, st.Closed)
+		}
+	})
+	t.Run("Put = Taken + Abandoned", func(t *testing.T) {
+		if st.Put != st.Taken+st.Abandoned {
+			t.Errorf("put %d, taken %d, abandoned %d", st.Put, st.Taken, st.Abandoned)
+		}
+	})
+	t.Run("the buffer ran full for most of its life", func(t *testing.T) {
+		if st.HighWater != st.Capacity || st.AvgDepth <= 8 {
+			t.Errorf("high water %d of %d, average depth %.1f", st.HighWater, st.Capacity, st.AvgDepth)
+		}
+	})
+}
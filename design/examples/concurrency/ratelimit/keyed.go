@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Keyed keeps one limiter per key (client IP, API key, tenant), created on
+// first use. Limiters unused for IdleTTL are dropped, at most once per
+// IdleTTL, so a stream of one-off keys cannot grow the map without bound.
+// A dropped key starts over with a fresh limiter, which for both buckets is
+// what it would have refilled to anyway once IdleTTL >= Burst/Rate.
+type Keyed struct {
+	newLimiter func() Limiter
+	idleTTL    time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	limiters  map[string]*keyedLimiter
+	lastSweep time.Time
+}
+
+type keyedLimiter struct {
+	Limiter
+	lastUsed time.Time
+}
+
+// NewKeyed returns a per-key limiter. now may be nil for time.Now.
+func NewKeyed(newLimiter func() Limiter, idleTTL time.Duration, now func() time.Time) *Keyed {
+	if now == nil {
+		now = time.Now
+	}
+	return &Keyed{newLimiter: newLimiter, idleTTL: idleTTL, now: now, limiters: make(map[string]*keyedLimiter), lastSweep: now()}
+}
+
+// Get returns key's limiter, creating it if needed
+func (k *Keyed) Get(key string) Limiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.now()
+	if k.idleTTL > 0 && now.Sub(k.lastSweep) >= k.idleTTL {
+		for key, l := range k.limiters {
+			if now.Sub(l.lastUsed) >= k.idleTTL {
+				delete(k.limiters, key)
+			}
+		}
+		k.lastSweep = now
+	}
+	l, ok := k.limiters[key]
+	if !ok {
+		l = &keyedLimiter{Limiter: k.newLimiter()}
+		k.limiters[key] = l
+	}
+	l.lastUsed = now
+	return l.Limiter
+}
+
+func (k *Keyed) Allow(key string) bool {
+	return k.Get(key).Allow()
+}
+
+func (k *Keyed) Reserve(key string) *Reservation {
+	return k.Get(key).Reserve()
+}
+
+func (k *Keyed) Wait(ctx context.Context, key string) error {
+	return k.Get(key).Wait(ctx)
+}
+
+// Len returns the number of keys tracked
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.limiters)
+}
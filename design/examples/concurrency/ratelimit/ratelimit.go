@@ -0,0 +1,226 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Two classic rate limiters behind one interface:
+//
+//   - TokenBucket holds up to Burst tokens and refills at Rate per second.
+//     Each event takes a token, so after a quiet period a burst of Burst
+//     events goes through at once, and the long-run rate is Rate.
+//   - LeakyBucket lets events out at a steady Rate, one every 1/Rate, never
+//     faster. Up to Capacity events can queue for their turn; a burst becomes
+//     delay instead of passing through.
+//
+// Allow answers now and never waits. Reserve books the next slot and says how
+// long until it is due, so the caller decides whether to wait or give up.
+// Wait blocks until the slot, or fails right away if ctx would end first.
+
+var (
+	ErrLimited      = errors.New("ratelimit: limit exceeded")
+	ErrWouldTimeout = errors.New("ratelimit: wait would exceed the context deadline")
+)
+
+type Limiter interface {
+	Allow() bool
+	Reserve() *Reservation
+	Wait(ctx context.Context) error
+}
+
+// Reservation is a booked slot. If OK, the event may happen after Delay.
+// Cancel gives the slot back when the event will not happen after all.
+type Reservation struct {
+	ok     bool
+	delay  time.Duration
+	cancel func()
+	once   sync.Once
+}
+
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+func (r *Reservation) Delay() time.Duration {
+	return r.delay
+}
+
+func (r *Reservation) Cancel() {
+	if r.ok && r.cancel != nil {
+		r.once.Do(r.cancel)
+	}
+}
+
+// wait sleeps through a reservation, or cancels it if ctx would end first
+func wait(ctx context.Context, r *Reservation) error {
+	if !r.OK() {
+		return ErrLimited
+	}
+	if r.delay <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < r.delay {
+		r.Cancel()
+		return ErrWouldTimeout
+	}
+	t := time.NewTimer(r.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+type Config struct {
+	Rate  float64 // events per second
+	Burst int     // TokenBucket: tokens held; LeakyBucket: events allowed to queue
+	Now   func() time.Time
+}
+
+func (c Config) withDefaults() Config {
+	if c.Burst < 1 {
+		c.Burst = 1
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+type TokenBucket struct {
+	cfg    Config
+	mu     sync.Mutex
+	tokens float64 // negative while reservations are outstanding
+	last   time.Time
+}
+
+// NewTokenBucket starts full, so the first Burst events pass at once
+func NewTokenBucket(cfg Config) *TokenBucket {
+	cfg = cfg.withDefaults()
+	return &TokenBucket{cfg: cfg, tokens: float64(cfg.Burst), last: cfg.Now()}
+}
+
+// refill adds the tokens earned since last. Called with mu held.
+func (b *TokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(float64(b.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*b.cfg.Rate)
+		b.last = now
+	}
+}
+
+func (b *TokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(b.cfg.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Reserve takes a token, going into debt if there is none. The delay is how
+// long the debt takes to refill; with Rate 0 nothing can be reserved.
+func (b *TokenBucket) Reserve() *Reservation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.cfg.Now()
+	b.refill(now)
+	if b.tokens < 1 && b.cfg.Rate <= 0 {
+		return &Reservation{}
+	}
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.cfg.Rate * float64(time.Second))
+	}
+	due := now.Add(delay)
+	return &Reservation{ok: true, delay: delay, cancel: func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		now := b.cfg.Now()
+		if now.Before(due) {
+			b.refill(now)
+			b.tokens = math.Min(float64(b.cfg.Burst), b.tokens+1)
+		}
+	}}
+}
+
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	return wait(ctx, b.Reserve())
+}
+
+// Tokens returns the tokens available now
+func (b *TokenBucket) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(b.cfg.Now())
+	return b.tokens
+}
+
+type LeakyBucket struct {
+	cfg      Config
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time // when the next event may leave the bucket
+}
+
+func NewLeakyBucket(cfg Config) *LeakyBucket {
+	cfg = cfg.withDefaults()
+	b := &LeakyBucket{cfg: cfg, next: cfg.Now()}
+	if cfg.Rate > 0 {
+		b.interval = time.Duration(float64(time.Second) / cfg.Rate)
+	}
+	return b
+}
+
+// Allow passes an event only if its turn is now: nothing is queued and the
+// previous one left at least 1/Rate ago
+func (b *LeakyBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.cfg.Now()
+	if b.cfg.Rate <= 0 || now.Before(b.next) {
+		return false
+	}
+	b.next = now.Add(b.interval)
+	return true
+}
+
+// Reserve queues the event for the next free slot, unless Burst events are
+// already waiting
+func (b *LeakyBucket) Reserve() *Reservation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.cfg.Now()
+	if b.cfg.Rate <= 0 {
+		return &Reservation{}
+	}
+	slot := b.next
+	if slot.Before(now) {
+		slot = now
+	}
+	if queued := int(slot.Sub(now) / b.interval); queued >= b.cfg.Burst {
+		return &Reservation{}
+	}
+	b.next = slot.Add(b.interval)
+	return &Reservation{ok: true, delay: slot.Sub(now), cancel: func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// Only the last slot can be handed back without reordering the queue
+		if b.next.Equal(slot.Add(b.interval)) && b.cfg.Now().Before(slot) {
+			b.next = slot
+		}
+	}}
+}
+
+func (b *LeakyBucket) Wait(ctx context.Context) error {
+	return wait(ctx, b.Reserve())
+}
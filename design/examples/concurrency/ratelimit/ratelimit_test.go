@@ -0,0 +1,259 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/ratelimit"
+)
+
+// The limiters run on a simulated clock, so every count and delay is exact.
+// Only TestWait uses the real clock.
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// burst calls Allow n times at the same instant and counts the passes
+func burst(l ratelimit.Limiter, n int) int {
+	passed := 0
+	for i := 0; i < n; i++ {
+		if l.Allow() {
+			passed++
+		}
+	}
+	return passed
+}
+
+func delays(l ratelimit.Limiter, n int) []string {
+	var out []string
+	for i := 0; i < n; i++ {
+		r := l.Reserve()
+		if !r.OK() {
+			out = append(out, "refused")
+			continue
+		}
+		out = append(out, r.Delay().String())
+	}
+	return out
+}
+
+// tokenBucket and leakyBucket are the two limiters at 10/s with burst 5
+func tokenBucket(now func() time.Time) ratelimit.Limiter {
+	return ratelimit.NewTokenBucket(ratelimit.Config{Rate: 10, Burst: 5, Now: now})
+}
+
+func leakyBucket(now func() time.Time) ratelimit.Limiter {
+	return ratelimit.NewLeakyBucket(ratelimit.Config{Rate: 10, Burst: 5, Now: now})
+}
+
+func TestAllowBurst(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		new               func(now func() time.Time) ratelimit.Limiter
+		first, after100ms int
+	}{
+		{"token bucket lets a full burst through at once", tokenBucket, 5, 1},
+		{"leaky bucket lets one through; the rest would be too soon", leakyBucket, 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := newClock()
+			limiter := tc.new(clk.Now)
+			if got := burst(limiter, 20); got != tc.first {
+				t.Errorf("a burst of 20 passes %d, want %d", got, tc.first)
+			}
+			clk.Advance(100 * time.Millisecond)
+			if got := burst(limiter, 20); got != tc.after100ms {
+				t.Errorf("100ms later %d pass, want %d", got, tc.after100ms)
+			}
+		})
+	}
+
+	t.Run("an idle token bucket refills to Burst, not beyond", func(t *testing.T) {
+		clk := newClock()
+		limiter := tokenBucket(clk.Now)
+		burst(limiter, 20)
+		clk.Advance(time.Hour)
+		if got := burst(limiter, 20); got != 5 {
+			t.Errorf("after an hour %d pass, want 5", got)
+		}
+	})
+}
+
+func TestReserveBurst(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		new  func(now func() time.Time) ratelimit.Limiter
+		want []string
+	}{
+		{"token bucket: the burst is free, then one every 100ms", tokenBucket,
+			[]string{"0s", "0s", "0s", "0s", "0s", "100ms", "200ms", "300ms"}},
+		{"leaky bucket: evenly spaced, and at most 5 waiting", leakyBucket,
+			[]string{"0s", "100ms", "200ms", "300ms", "400ms", "refused", "refused", "refused"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := delays(tc.new(newClock().Now), 8); !slices.Equal(got, tc.want) {
+				t.Errorf("delays %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReserveCancel(t *testing.T) {
+	t.Run("a cancelled last slot of a leaky bucket goes to the next caller", func(t *testing.T) {
+		clk := newClock()
+		leaky := leakyBucket(clk.Now)
+		delays(leaky, 5)
+		if leaky.Reserve().OK() {
+			t.Fatal("a full leaky bucket accepts a reservation")
+		}
+		clk.Advance(100 * time.Millisecond)
+		r := leaky.Reserve()
+		if !r.OK() || r.Delay() != 400*time.Millisecond {
+			t.Fatalf("100ms later: OK %t, delay %s; want a slot in 400ms", r.OK(), r.Delay())
+		}
+		r.Cancel()
+		if r = leaky.Reserve(); !r.OK() || r.Delay() != 400*time.Millisecond {
+			t.Errorf("after Cancel: OK %t, delay %s; want the same slot", r.OK(), r.Delay())
+		}
+	})
+
+	t.Run("cancelling gives a token back, once", func(t *testing.T) {
+		token := ratelimit.NewTokenBucket(ratelimit.Config{Rate: 10, Burst: 1, Now: newClock().Now})
+		token.Allow()
+		r := token.Reserve()
+		r.Cancel()
+		r.Cancel()
+		if got := token.Tokens(); got != 0 {
+			t.Errorf("Tokens = %v, want 0", got)
+		}
+	})
+}
+
+func TestWait(t *testing.T) {
+	t.Run("waits are spaced at the rate", func(t *testing.T) {
+		live := ratelimit.NewTokenBucket(ratelimit.Config{Rate: 50, Burst: 1})
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			if err := live.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 170*time.Millisecond || elapsed >= 400*time.Millisecond {
+			t.Errorf("10 waits at 50/s took %s, want 9 intervals of 20ms", elapsed)
+		}
+	})
+
+	t.Run("a wait that cannot make the deadline fails at once, without a slot", func(t *testing.T) {
+		live := ratelimit.NewTokenBucket(ratelimit.Config{Rate: 50, Burst: 1})
+		live.Allow()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := live.Wait(ctx); !errors.Is(err, ratelimit.ErrWouldTimeout) || time.Since(start) >= 5*time.Millisecond {
+			t.Fatalf("Wait = %v after %s, want ErrWouldTimeout at once", err, time.Since(start))
+		}
+		if err := live.Wait(context.Background()); err != nil || time.Since(start) >= 40*time.Millisecond {
+			t.Errorf("next Wait = %v after %s, want the slot the failed wait did not use", err, time.Since(start))
+		}
+	})
+
+	t.Run("a full leaky bucket does not wait", func(t *testing.T) {
+		full := ratelimit.NewLeakyBucket(ratelimit.Config{Rate: 1, Burst: 1})
+		full.Allow()
+		full.Reserve()
+		if err := full.Wait(context.Background()); !errors.Is(err, ratelimit.ErrLimited) {
+			t.Errorf("Wait = %v, want ErrLimited", err)
+		}
+	})
+}
+
+func TestKeyed(t *testing.T) {
+	clk := newClock()
+	keys := ratelimit.NewKeyed(func() ratelimit.Limiter {
+		return ratelimit.NewTokenBucket(ratelimit.Config{Rate: 1, Burst: 3, Now: clk.Now})
+	}, time.Minute, clk.Now)
+
+	t.Run("a noisy client is limited to its burst", func(t *testing.T) {
+		passed := 0
+		for i := 0; i < 10; i++ {
+			if keys.Allow("10.0.0.1") {
+				passed++
+			}
+		}
+		if passed != 3 {
+			t.Errorf("%d of 10 passed, want 3", passed)
+		}
+	})
+	t.Run("another client is not affected", func(t *testing.T) {
+		if !keys.Allow("10.0.0.2") {
+			t.Error("refused")
+		}
+	})
+	t.Run("idle keys are dropped", func(t *testing.T) {
+		for i := 0; i < 1000; i++ {
+			keys.Allow(fmt.Sprintf("scanner-%d", i))
+		}
+		clk.Advance(2 * time.Minute)
+		keys.Allow("10.0.0.1")
+		if got := keys.Len(); got != 1 {
+			t.Errorf("1002 keys seen, %d kept; want 1", got)
+		}
+	})
+}
+
+// TestConcurrentAllow has 64 goroutines call Allow at one instant: no more
+// pass than one goroutine alone would get
+func TestConcurrentAllow(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		l    ratelimit.Limiter
+		want int64
+	}{
+		{"token bucket", ratelimit.NewTokenBucket(ratelimit.Config{Rate: 100, Burst: 25, Now: newClock().Now}), 25},
+		{"leaky bucket", ratelimit.NewLeakyBucket(ratelimit.Config{Rate: 100, Burst: 25, Now: newClock().Now}), 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var passed atomic.Int64
+			var wg sync.WaitGroup
+			for g := 0; g < 64; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 100; i++ {
+						if tc.l.Allow() {
+							passed.Add(1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if passed.Load() != tc.want {
+				t.Errorf("%d of 6400 passed, want %d", passed.Load(), tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package scatter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Scatter-gather: send one query to several backends at once and assemble
+// whatever has come back by the deadline. An aggregating endpoint (a BFF
+// page, a search across shards) is only as slow as the deadline, not the
+// slowest backend, and one failing backend degrades the answer instead of
+// failing it. Backends marked Required are the exception: without them there
+// is no useful answer, so their failure ends the gather at once.
+//
+// Gather returns at the deadline without waiting for stragglers. Their
+// context is canceled and their results, if any, are dropped.
+
+var ErrTimeout = errors.New("scatter: no answer before the deadline")
+
+type Backend[R any] struct {
+	Name     string
+	Required bool
+	Call     func(ctx context.Context) (R, error)
+}
+
+type Outcome[R any] struct {
+	Backend string
+	Value   R
+	Err     error
+	Latency time.Duration
+}
+
+// RequiredError reports the required backend that failed the gather
+type RequiredError struct {
+	Backend string
+	Err     error
+}
+
+func (e *RequiredError) Error() string {
+	return fmt.Sprintf("scatter: required backend %s failed: %v", e.Backend, e.Err)
+}
+
+func (e *RequiredError) Unwrap() error {
+	return e.Err
+}
+
+type Report[R any] struct {
+	Outcomes []Outcome[R] // one per backend, in the order given
+	Elapsed  time.Duration
+	err      error
+}
+
+// Err is a *RequiredError if a required backend failed, otherwise nil
+func (r Report[R]) Err() error {
+	return r.err
+}
+
+// Partial reports whether any backend is missing from the answer
+func (r Report[R]) Partial() bool {
+	for _, o := range r.Outcomes {
+		if o.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns backend's result, if it answered
+func (r Report[R]) Value(backend string) (R, bool) {
+	for _, o := range r.Outcomes {
+		if o.Backend == backend && o.Err == nil {
+			return o.Value, true
+		}
+	}
+	var zero R
+	return zero, false
+}
+
+// Errors returns the error of every backend that did not answer
+func (r Report[R]) Errors() map[string]error {
+	errs := make(map[string]error)
+	for _, o := range r.Outcomes {
+		if o.Err != nil {
+			errs[o.Backend] = o.Err
+		}
+	}
+	return errs
+}
+
+// Gather calls every backend concurrently and waits until all have answered,
+// a required one has failed, or timeout (if > 0) or ctx runs out
+func Gather[R any](ctx context.Context, timeout time.Duration, backends ...Backend[R]) Report[R] {
+	start := time.Now()
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	type answer struct {
+		i int
+		Outcome[R]
+	}
+	// Buffered for every backend, so stragglers never block after we leave
+	answers := make(chan answer, len(backends))
+	for i, b := range backends {
+		go func(i int, b Backend[R]) {
+			began := time.Now()
+			v, err := b.Call(ctx)
+			answers <- answer{i, Outcome[R]{Backend: b.Name, Value: v, Err: err, Latency: time.Since(began)}}
+		}(i, b)
+	}
+
+	report := Report[R]{Outcomes: make([]Outcome[R], len(backends))}
+	answered := make([]bool, len(backends))
+collect:
+	for pending := len(backends); pending > 0; pending-- {
+		select {
+		case a := <-answers:
+			if a.Err != nil && ctx.Err() != nil {
+				// It gave up because the gather did; reported below with the rest
+				break collect
+			}
+			report.Outcomes[a.i] = a.Outcome
+			answered[a.i] = true
+			if a.Err != nil && backends[a.i].Required {
+				report.err = &RequiredError{Backend: a.Backend, Err: a.Err}
+				break collect
+			}
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	reason := ErrTimeout
+	if report.err != nil {
+		reason = context.Canceled
+	} else if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = ctx.Err()
+	}
+	for i, b := range backends {
+		if !answered[i] {
+			report.Outcomes[i] = Outcome[R]{Backend: b.Name, Err: reason, Latency: time.Since(start)}
+		}
+	}
+	report.Elapsed = time.Since(start)
+	return report
+}
@@ -0,0 +1,213 @@
+package scatter_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/scatter"
+)
+
+// The tests gather a product page from fake backends that are fast, slow,
+// failing or required.
+
+// fake answers after latency with value, or with err; it notices when it is
+// abandoned
+type fake struct {
+	name      string
+	latency   time.Duration
+	err       error
+	required  bool
+	abandoned *atomic.Int32
+}
+
+func (f fake) backend() scatter.Backend[string] {
+	return scatter.Backend[string]{Name: f.name, Required: f.required, Call: func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(f.latency):
+		case <-ctx.Done():
+			f.abandoned.Add(1)
+			return "", ctx.Err()
+		}
+		if f.err != nil {
+			return "", f.err
+		}
+		return f.name + " data", nil
+	}}
+}
+
+func page(abandoned *atomic.Int32, fakes ...fake) []scatter.Backend[string] {
+	var backends []scatter.Backend[string]
+	for _, f := range fakes {
+		f.abandoned = abandoned
+		backends = append(backends, f.backend())
+	}
+	return backends
+}
+
+func names(errs map[string]error) string {
+	var n []string
+	for k := range errs {
+		n = append(n, k)
+	}
+	sort.Strings(n)
+	return strings.Join(n, ",")
+}
+
+// describe logs what each backend did, for a failing test
+func describe(t *testing.T, r scatter.Report[string]) {
+	t.Helper()
+	for _, o := range r.Outcomes {
+		status := "ok"
+		if o.Err != nil {
+			status = o.Err.Error()
+		}
+		t.Logf("%-16s %6s  %s", o.Backend, o.Latency.Round(time.Millisecond), status)
+	}
+	t.Logf("gathered in %s", r.Elapsed.Round(time.Millisecond))
+}
+
+func TestGather(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	for _, tc := range []struct {
+		name        string
+		timeout     time.Duration
+		cancelAfter time.Duration // the caller goes away, if set
+		fakes       []fake
+		abandoned   int32 // calls canceled
+		check       func(r scatter.Report[string]) error
+	}{
+		{
+			name:    "every backend answers, in the time of the slowest",
+			timeout: 200 * time.Millisecond,
+			fakes: []fake{
+				{name: "product", latency: 10 * time.Millisecond, required: true},
+				{name: "reviews", latency: 40 * time.Millisecond},
+				{name: "recommendations", latency: 25 * time.Millisecond},
+			},
+			check: func(r scatter.Report[string]) error {
+				if r.Partial() || r.Err() != nil || len(r.Errors()) != 0 {
+					return fmt.Errorf("partial %t, err %v, errors %v; want complete", r.Partial(), r.Err(), r.Errors())
+				}
+				if r.Elapsed < 40*time.Millisecond || r.Elapsed >= 60*time.Millisecond {
+					return fmt.Errorf("took %s, want the slowest backend's 40ms, not the sum", r.Elapsed)
+				}
+				if v, ok := r.Value("reviews"); !ok || v != "reviews data" {
+					return fmt.Errorf("Value(reviews) = %q, %t", v, ok)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "a slow backend is missing with ErrTimeout, at the deadline",
+			timeout: 100 * time.Millisecond,
+			fakes: []fake{
+				{name: "product", latency: 10 * time.Millisecond, required: true},
+				{name: "reviews", latency: time.Second},
+				{name: "recommendations", latency: 25 * time.Millisecond},
+			},
+			abandoned: 1,
+			check: func(r scatter.Report[string]) error {
+				if !r.Partial() || r.Err() != nil {
+					return fmt.Errorf("partial %t, err %v; want partial, not failed", r.Partial(), r.Err())
+				}
+				if !errors.Is(r.Errors()["reviews"], scatter.ErrTimeout) || names(r.Errors()) != "reviews" {
+					return fmt.Errorf("errors %v, want only reviews with ErrTimeout", r.Errors())
+				}
+				if r.Elapsed < 100*time.Millisecond || r.Elapsed >= 120*time.Millisecond {
+					return fmt.Errorf("took %s, want the 100ms deadline", r.Elapsed)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "a failing backend has its own error, and the others still arrive",
+			timeout: 100 * time.Millisecond,
+			fakes: []fake{
+				{name: "product", latency: 10 * time.Millisecond, required: true},
+				{name: "reviews", latency: 5 * time.Millisecond, err: errors.New("503 from reviews")},
+				{name: "recommendations", latency: 25 * time.Millisecond},
+			},
+			check: func(r scatter.Report[string]) error {
+				if !r.Partial() || r.Err() != nil || fmt.Sprint(r.Errors()["reviews"]) != "503 from reviews" {
+					return fmt.Errorf("partial %t, err %v, errors %v", r.Partial(), r.Err(), r.Errors())
+				}
+				if _, ok := r.Value("recommendations"); !ok || r.Elapsed >= 40*time.Millisecond {
+					return fmt.Errorf("recommendations %t after %s, want it without waiting for the deadline", ok, r.Elapsed)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "a failing required backend fails the gather at once",
+			timeout: 500 * time.Millisecond,
+			fakes: []fake{
+				{name: "product", latency: 10 * time.Millisecond, required: true, err: errors.New("product not found")},
+				{name: "reviews", latency: 300 * time.Millisecond},
+				{name: "recommendations", latency: 200 * time.Millisecond},
+			},
+			abandoned: 2,
+			check: func(r scatter.Report[string]) error {
+				var required *scatter.RequiredError
+				if !errors.As(r.Err(), &required) || required.Backend != "product" {
+					return fmt.Errorf("Err = %v, want a RequiredError for product", r.Err())
+				}
+				if r.Elapsed >= 30*time.Millisecond {
+					return fmt.Errorf("took %s, want no wait for the others", r.Elapsed)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "when the caller goes away, unanswered backends report the cancellation",
+			timeout:     time.Second,
+			cancelAfter: 20 * time.Millisecond,
+			fakes: []fake{
+				{name: "product", latency: 10 * time.Millisecond, required: true},
+				{name: "reviews", latency: 300 * time.Millisecond},
+			},
+			abandoned: 1,
+			check: func(r scatter.Report[string]) error {
+				if !errors.Is(r.Errors()["reviews"], context.Canceled) || r.Elapsed >= 40*time.Millisecond {
+					return fmt.Errorf("reviews %v after %s, want Canceled after 20ms", r.Errors()["reviews"], r.Elapsed)
+				}
+				return nil
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelAfter > 0 {
+				time.AfterFunc(tc.cancelAfter, cancel)
+			}
+			var abandoned atomic.Int32
+			r := scatter.Gather(ctx, tc.timeout, page(&abandoned, tc.fakes...)...)
+			if err := tc.check(r); err != nil {
+				describe(t, r)
+				t.Error(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+			if n := abandoned.Load(); n != tc.abandoned {
+				t.Errorf("%d calls canceled, want %d", n, tc.abandoned)
+			}
+		})
+	}
+
+	t.Run("every backend goroutine exits, since they honour the context", func(t *testing.T) {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > baseline+1 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		// +1: this subtest's own goroutine
+		if n := runtime.NumGoroutine(); n > baseline+1 {
+			t.Errorf("%d goroutines left over", n-baseline-1)
+		}
+	})
+}
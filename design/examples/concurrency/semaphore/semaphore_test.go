@@ -0,0 +1,113 @@
+package semaphore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/semaphore"
+)
+
+// acquired runs Acquire(n) in the background; the channel receives its result
+func acquired(s *semaphore.Weighted, ctx context.Context, n int64) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- s.Acquire(ctx, n) }()
+	return done
+}
+
+// within waits up to d for a result, reporting whether one came
+func within(ch <-chan error, d time.Duration) (error, bool) {
+	select {
+	case err := <-ch:
+		return err, true
+	case <-time.After(d):
+		return nil, false
+	}
+}
+
+func TestWeights(t *testing.T) {
+	ctx := context.Background()
+	s := semaphore.NewWeighted(10)
+	for _, tc := range []struct {
+		name string
+		n    int64
+		want bool
+	}{
+		{"6 of 10", 6, true},
+		{"5 more does not fit", 5, false},
+		{"4 more does", 4, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := s.TryAcquire(tc.n); got != tc.want {
+				t.Errorf("TryAcquire(%d) = %t, want %t", tc.n, got, tc.want)
+			}
+		})
+	}
+	s.Release(10)
+
+	t.Run("a request larger than the semaphore fails instead of waiting forever", func(t *testing.T) {
+		if err := s.Acquire(ctx, 11); !errors.Is(err, semaphore.ErrTooLarge) {
+			t.Errorf("Acquire(11) = %v, want ErrTooLarge", err)
+		}
+	})
+	t.Run("an ended context fails even when there is room", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := s.Acquire(canceled, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("Acquire = %v, want Canceled", err)
+		}
+		if n := s.InUse(); n != 0 {
+			t.Errorf("InUse = %d, want 0", n)
+		}
+	})
+}
+
+func TestWaitersInOrder(t *testing.T) {
+	ctx := context.Background()
+	s := semaphore.NewWeighted(10)
+	s.Acquire(ctx, 8)
+	big := acquired(s, ctx, 10)
+	time.Sleep(5 * time.Millisecond)
+	small := acquired(s, ctx, 1)
+
+	t.Run("a small request waits behind a large one, though 2 are free", func(t *testing.T) {
+		if _, got := within(small, 20*time.Millisecond); got {
+			t.Error("the small request was granted first")
+		}
+	})
+	s.Release(8)
+	t.Run("the large one goes first once there is room", func(t *testing.T) {
+		if _, got := within(big, 20*time.Millisecond); !got {
+			t.Error("not granted")
+		}
+	})
+	s.Release(10)
+	t.Run("then the small one", func(t *testing.T) {
+		if _, got := within(small, 20*time.Millisecond); !got || s.InUse() != 1 {
+			t.Errorf("granted %t, InUse %d; want granted and 1", got, s.InUse())
+		}
+	})
+}
+
+func TestWaiterGivesUp(t *testing.T) {
+	ctx := context.Background()
+	s := semaphore.NewWeighted(10)
+	s.Acquire(ctx, 8)
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	big := acquired(s, tctx, 10)
+	time.Sleep(5 * time.Millisecond)
+	small := acquired(s, ctx, 2)
+
+	t.Run("a waiter whose context ends gives up", func(t *testing.T) {
+		if err, _ := within(big, 100*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Acquire = %v, want DeadlineExceeded", err)
+		}
+	})
+	t.Run("and the one queued behind it moves up and fits", func(t *testing.T) {
+		if _, got := within(small, 20*time.Millisecond); !got || s.InUse() != 10 {
+			t.Errorf("granted %t, InUse %d; want granted and 10", got, s.InUse())
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// An Orchestrator starts a process's components in dependency order and,
+// on SIGINT/SIGTERM, stops them in the reverse order: the HTTP server stops
+// taking requests before the database it queries is closed, and the
+// database is closed before the log it reports to is flushed. Components
+// with nothing between them start and stop concurrently.
+//
+// Every Stop gets its own timeout. One that overruns is reported as a
+// straggler and left behind, so a single stuck component cannot hold up the
+// rest of the shutdown or the process exit. What depends on it still waits
+// for its timeout, never longer.
+
+var (
+	ErrStragglers = errors.New("shutdown: components did not stop in time")
+	ErrCycle      = errors.New("shutdown: dependency cycle")
+)
+
+type Component struct {
+	Name      string
+	DependsOn []string // started before this one, stopped after it
+
+	// Start brings the component up and returns once it is ready; work that
+	// keeps running (a server's accept loop) belongs in a goroutine. Stop
+	// undoes it. Either may be nil.
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+
+	StopTimeout time.Duration // 0 uses the orchestrator's
+}
+
+type Options struct {
+	StopTimeout time.Duration                    // per component, default 10s
+	Logf        func(format string, args ...any) // default: discard
+}
+
+type Orchestrator struct {
+	opts       Options
+	components map[string]Component
+	order      []string // registration order, for stable output
+
+	mu      sync.Mutex
+	started []string
+}
+
+func New(opts Options) *Orchestrator {
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = 10 * time.Second
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	return &Orchestrator{opts: opts, components: make(map[string]Component)}
+}
+
+// Register adds components. Dependencies may be registered later, but must
+// exist by Start.
+func (o *Orchestrator) Register(components ...Component) error {
+	for _, c := range components {
+		if c.Name == "" {
+			return errors.New("shutdown: component without a name")
+		}
+		if _, dup := o.components[c.Name]; dup {
+			return fmt.Errorf("shutdown: component %q registered twice", c.Name)
+		}
+		o.components[c.Name] = c
+		o.order = append(o.order, c.Name)
+	}
+	return nil
+}
+
+// levels groups components so each depends only on earlier levels
+func (o *Orchestrator) levels() ([][]string, error) {
+	level := make(map[string]int, len(o.components))
+	visiting := make(map[string]bool)
+	var visit func(name string, path []string) (int, error)
+	visit = func(name string, path []string) (int, error) {
+		if l, ok := level[name]; ok {
+			return l, nil
+		}
+		c, ok := o.components[name]
+		if !ok {
+			return 0, fmt.Errorf("shutdown: %q depends on unknown component %q", path[len(path)-1], name)
+		}
+		if visiting[name] {
+			return 0, fmt.Errorf("%w: %s", ErrCycle, strings.Join(append(path, name), " -> "))
+		}
+		visiting[name] = true
+		l := 0
+		for _, dep := range c.DependsOn {
+			dl, err := visit(dep, append(path, name))
+			if err != nil {
+				return 0, err
+			}
+			l = max(l, dl+1)
+		}
+		visiting[name] = false
+		level[name] = l
+		return l, nil
+	}
+
+	var levels [][]string
+	for _, name := range o.order {
+		l, err := visit(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		for len(levels) <= l {
+			levels = append(levels, nil)
+		}
+	}
+	for _, name := range o.order {
+		levels[level[name]] = append(levels[level[name]], name)
+	}
+	return levels, nil
+}
+
+// Start starts every component, dependencies first. If one fails, those
+// already started are stopped again and the error is returned.
+func (o *Orchestrator) Start(ctx context.Context) error {
+	levels, err := o.levels()
+	if err != nil {
+		return err
+	}
+	for _, names := range levels {
+		errs := make([]error, len(names))
+		var wg sync.WaitGroup
+		for i, name := range names {
+			c := o.components[name]
+			if c.Start == nil {
+				o.markStarted(name)
+				continue
+			}
+			wg.Add(1)
+			go func(i int, c Component) {
+				defer wg.Done()
+				began := time.Now()
+				if err := c.Start(ctx); err != nil {
+					errs[i] = fmt.Errorf("starting %s: %w", c.Name, err)
+					return
+				}
+				o.markStarted(c.Name)
+				o.opts.Logf("started %s in %s", c.Name, time.Since(began).Round(time.Millisecond))
+			}(i, c)
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			o.Stop(context.Background())
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Orchestrator) markStarted(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = append(o.started, name)
+}
+
+type StopResult struct {
+	Name      string
+	Took      time.Duration
+	Err       error
+	Straggler bool // still running when its timeout ran out
+}
+
+type Report struct {
+	Results []StopResult // in the order the components finished, or gave up
+	Took    time.Duration
+}
+
+// Stragglers names the components that did not stop in time
+func (r Report) Stragglers() []string {
+	var names []string
+	for _, res := range r.Results {
+		if res.Straggler {
+			names = append(names, res.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Err joins every Stop error, and ErrStragglers if any component overran
+func (r Report) Err() error {
+	var errs []error
+	for _, res := range r.Results {
+		if res.Err != nil && !res.Straggler {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", res.Name, res.Err))
+		}
+	}
+	if s := r.Stragglers(); len(s) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrStragglers, strings.Join(s, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// Stop stops the started components, dependents first. ctx bounds the whole
+// shutdown; each component also gets its own StopTimeout.
+func (o *Orchestrator) Stop(ctx context.Context) Report {
+	start := time.Now()
+	levels, _ := o.levels() // validated by Start
+	o.mu.Lock()
+	started := make(map[string]bool, len(o.started))
+	for _, name := range o.started {
+		started[name] = true
+	}
+	o.started = nil
+	o.mu.Unlock()
+
+	var report Report
+	var mu sync.Mutex
+	for l := len(levels) - 1; l >= 0; l-- {
+		var wg sync.WaitGroup
+		for _, name := range levels[l] {
+			c := o.components[name]
+			if !started[name] || c.Stop == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(c Component) {
+				defer wg.Done()
+				res := o.stopOne(ctx, c)
+				mu.Lock()
+				report.Results = append(report.Results, res)
+				mu.Unlock()
+			}(c)
+		}
+		wg.Wait()
+	}
+	report.Took = time.Since(start)
+	return report
+}
+
+func (o *Orchestrator) stopOne(ctx context.Context, c Component) StopResult {
+	timeout := c.StopTimeout
+	if timeout <= 0 {
+		timeout = o.opts.StopTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	began := time.Now()
+	done := make(chan error, 1) // buffered: a straggler finishing late must not block
+	go func() { done <- c.Stop(ctx) }()
+	select {
+	case err := <-done:
+		res := StopResult{Name: c.Name, Took: time.Since(began), Err: err}
+		if err != nil {
+			o.opts.Logf("stopped %s in %s: %v", c.Name, res.Took.Round(time.Millisecond), err)
+		} else {
+			o.opts.Logf("stopped %s in %s", c.Name, res.Took.Round(time.Millisecond))
+		}
+		return res
+	case <-ctx.Done():
+		res := StopResult{Name: c.Name, Took: time.Since(began), Err: ctx.Err(), Straggler: true}
+		o.opts.Logf("gave up on %s after %s: still stopping", c.Name, res.Took.Round(time.Millisecond))
+		return res
+	}
+}
+
+// Run starts every component, waits for SIGINT, SIGTERM or ctx to end, then
+// stops them all within shutdownTimeout. It returns the start error, or the
+// shutdown report's error.
+func (o *Orchestrator) Run(ctx context.Context, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := o.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	stop() // a second signal kills the process the usual way
+	o.opts.Logf("shutting down")
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	report := o.Stop(stopCtx)
+	o.opts.Logf("shut down in %s", report.Took.Round(time.Millisecond))
+	return report.Err()
+}
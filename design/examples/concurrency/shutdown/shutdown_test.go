@@ -0,0 +1,315 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/shutdown"
+)
+
+// The tests start a small service (config, database, cache, repository,
+// HTTP server, worker) through the orchestrator.
+
+// events records what the components did, in order
+type events struct {
+	mu  sync.Mutex
+	log []string
+	at  map[string]time.Time
+}
+
+func (e *events) add(what string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.log = append(e.log, what)
+	e.at[what] = time.Now()
+}
+
+func (e *events) index(what string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, w := range e.log {
+		if w == what {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *events) when(what string) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.at[what]
+}
+
+func newEvents() *events {
+	return &events{at: map[string]time.Time{}}
+}
+
+func (e *events) before(a, b string) bool {
+	i, j := e.index(a), e.index(b)
+	return i >= 0 && j >= 0 && i < j
+}
+
+// component returns a component that records its start and stop and takes
+// stopTook to stop
+func component(ev *events, name string, stopTook time.Duration, deps ...string) shutdown.Component {
+	return shutdown.Component{
+		Name:      name,
+		DependsOn: deps,
+		Start: func(context.Context) error {
+			ev.add("start " + name)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			select {
+			case <-time.After(stopTook):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			ev.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestOrder(t *testing.T) {
+	ev := newEvents()
+	o := shutdown.New(shutdown.Options{StopTimeout: time.Second})
+	o.Register(
+		component(ev, "http", 10*time.Millisecond, "repository"),
+		component(ev, "worker", 30*time.Millisecond, "repository"),
+		component(ev, "repository", 0, "database", "cache"),
+		component(ev, "database", 10*time.Millisecond, "config"),
+		component(ev, "cache", 30*time.Millisecond, "config"),
+		component(ev, "config", 0),
+	)
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	report := o.Stop(context.Background())
+	if err := report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(strings.Join(ev.log, ", "))
+
+	for _, tc := range []struct{ first, then string }{
+		// dependencies start first, whatever order they were registered in
+		{"start config", "start database"},
+		{"start database", "start repository"},
+		{"start cache", "start repository"},
+		{"start repository", "start http"},
+		// and stop last, after everything that uses them
+		{"stop http", "stop repository"},
+		{"stop worker", "stop repository"},
+		{"stop repository", "stop database"},
+		{"stop database", "stop config"},
+	} {
+		t.Run(tc.first+" before "+tc.then, func(t *testing.T) {
+			if !ev.before(tc.first, tc.then) {
+				t.Errorf("order: %s", strings.Join(ev.log, ", "))
+			}
+		})
+	}
+
+	t.Run("components with nothing between them stop together", func(t *testing.T) {
+		if gap := ev.when("stop worker").Sub(ev.when("stop http")); gap >= 25*time.Millisecond || report.Took >= 100*time.Millisecond {
+			t.Errorf("worker stopped %s after http, %s in all; want together, not the 80ms sum", gap, report.Took)
+		}
+	})
+}
+
+func TestSIGTERMWithARequestInFlight(t *testing.T) {
+	var dbOpen atomic.Bool
+	var served atomic.Int32
+	requestStarted := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(50 * time.Millisecond) // a slow query
+		if !dbOpen.Load() {
+			http.Error(w, "database is closed", http.StatusInternalServerError)
+			return
+		}
+		served.Add(1)
+		io.WriteString(w, "ok")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+	var logged []string
+	var logMu sync.Mutex
+	o := shutdown.New(shutdown.Options{
+		StopTimeout: time.Second,
+		Logf: func(format string, args ...any) {
+			logMu.Lock()
+			defer logMu.Unlock()
+			logged = append(logged, fmt.Sprintf(format, args...))
+		},
+	})
+	o.Register(
+		shutdown.Component{
+			Name:  "database",
+			Start: func(context.Context) error { dbOpen.Store(true); return nil },
+			Stop:  func(context.Context) error { dbOpen.Store(false); return nil },
+		},
+		shutdown.Component{
+			Name:      "http",
+			DependsOn: []string{"database"},
+			Start: func(context.Context) error {
+				go srv.Serve(ln)
+				return nil
+			},
+			Stop: srv.Shutdown,
+		},
+	)
+	runDone := make(chan error)
+	go func() { runDone <- o.Run(context.Background(), 5*time.Second) }()
+	status := make(chan int)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/report")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-requestStarted
+	self, _ := os.FindProcess(os.Getpid())
+	self.Signal(syscall.SIGTERM)
+	err = <-runDone
+	logMu.Lock()
+	t.Log(strings.Join(logged, "; "))
+	logMu.Unlock()
+
+	t.Run("the request in flight completes, with the database still open", func(t *testing.T) {
+		if code := <-status; code != http.StatusOK || served.Load() != 1 {
+			t.Errorf("status %d, %d served", code, served.Load())
+		}
+	})
+	t.Run("then the database is closed and Run returns", func(t *testing.T) {
+		if err != nil || dbOpen.Load() {
+			t.Errorf("Run = %v, database open %t", err, dbOpen.Load())
+		}
+	})
+	t.Run("new connections are refused", func(t *testing.T) {
+		if _, err := http.Get("http://" + ln.Addr().String() + "/report"); err == nil {
+			t.Error("a request after shutdown was answered")
+		}
+	})
+}
+
+func TestStopFailures(t *testing.T) {
+	t.Run("a component that will not stop is a straggler, and does not hold up its dependencies", func(t *testing.T) {
+		ev := newEvents()
+		o := shutdown.New(shutdown.Options{StopTimeout: time.Second})
+		stuck := shutdown.Component{
+			Name:        "worker",
+			DependsOn:   []string{"database"},
+			Start:       func(context.Context) error { return nil },
+			Stop:        func(context.Context) error { time.Sleep(time.Second); return nil }, // ignores ctx
+			StopTimeout: 50 * time.Millisecond,
+		}
+		o.Register(component(ev, "database", 0), stuck, component(ev, "http", 0, "database"))
+		o.Start(context.Background())
+		report := o.Stop(context.Background())
+		for _, r := range report.Results {
+			t.Logf("%-9s %6s straggler=%v err=%v", r.Name, r.Took.Round(time.Millisecond), r.Straggler, r.Err)
+		}
+		if !errors.Is(report.Err(), shutdown.ErrStragglers) || fmt.Sprint(report.Stragglers()) != "[worker]" {
+			t.Errorf("Err = %v, stragglers %v; want worker", report.Err(), report.Stragglers())
+		}
+		if report.Took >= 100*time.Millisecond || ev.index("stop database") < 0 {
+			t.Errorf("took %s, database stopped %t; want it stopped after the 50ms timeout", report.Took, ev.index("stop database") >= 0)
+		}
+	})
+
+	t.Run("a Stop error is reported, and the rest still stop", func(t *testing.T) {
+		ev := newEvents()
+		o := shutdown.New(shutdown.Options{})
+		o.Register(
+			shutdown.Component{Name: "a", Start: func(context.Context) error { return nil }, Stop: func(context.Context) error { return errors.New("flush failed") }},
+			component(ev, "b", 0, "a"),
+		)
+		o.Start(context.Background())
+		report := o.Stop(context.Background())
+		if report.Err() == nil || report.Err().Error() != "stopping a: flush failed" || ev.index("stop b") < 0 {
+			t.Errorf("Err = %v, b stopped %t", report.Err(), ev.index("stop b") >= 0)
+		}
+	})
+}
+
+func TestStartFailure(t *testing.T) {
+	ev := newEvents()
+	o := shutdown.New(shutdown.Options{})
+	o.Register(
+		component(ev, "config", 0),
+		component(ev, "database", 0, "config"),
+		shutdown.Component{Name: "cache", DependsOn: []string{"config"}, Start: func(context.Context) error {
+			return errors.New("redis: connection refused")
+		}},
+		component(ev, "http", 0, "database", "cache"),
+	)
+	err := o.Start(context.Background())
+
+	t.Run("Start fails with the component that failed", func(t *testing.T) {
+		if err == nil || !strings.Contains(err.Error(), "starting cache") {
+			t.Errorf("Start = %v", err)
+		}
+	})
+	t.Run("nothing that depends on it is started", func(t *testing.T) {
+		if ev.index("start http") >= 0 {
+			t.Error("http was started")
+		}
+	})
+	t.Run("what was already started is stopped again", func(t *testing.T) {
+		if ev.index("stop database") < 0 || ev.index("stop config") < 0 {
+			t.Errorf("events: %s", strings.Join(ev.log, ", "))
+		}
+	})
+}
+
+func TestBadGraphs(t *testing.T) {
+	ev := newEvents()
+	for _, tc := range []struct {
+		name       string
+		components []shutdown.Component
+		want       func(err error) bool
+	}{
+		{"a cycle", []shutdown.Component{component(ev, "a", 0, "b"), component(ev, "b", 0, "c"), component(ev, "c", 0, "a")},
+			func(err error) bool { return errors.Is(err, shutdown.ErrCycle) }},
+		{"a dependency that does not exist", []shutdown.Component{component(ev, "http", 0, "databse")},
+			func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), `unknown component "databse"`)
+			}},
+	} {
+		t.Run(tc.name+" is refused", func(t *testing.T) {
+			o := shutdown.New(shutdown.Options{})
+			o.Register(tc.components...)
+			if err := o.Start(context.Background()); !tc.want(err) {
+				t.Errorf("Start = %v", err)
+			}
+		})
+	}
+
+	t.Run("a name registered twice is refused", func(t *testing.T) {
+		o := shutdown.New(shutdown.Options{})
+		o.Register(component(ev, "http", 0))
+		if err := o.Register(component(ev, "http", 0)); err == nil {
+			t.Error("Register accepted it")
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Request coalescing. When a popular cache entry expires, every request that
+// misses it at the same moment would go to the backend with the same query,
+// a thundering herd. A Group lets the first caller for a key make the call
+// and has the others wait for its result instead.
+//
+// With a TTL the result is also handed to callers that arrive shortly after
+// the call finished, which smooths the herd that arrives just too late to
+// join. Errors are shared with the callers that waited for them, but never
+// beyond, so the next caller retries.
+//
+// The call runs under a context of its own, with the first caller's values
+// but not its deadline or cancellation: one impatient caller must not fail
+// everybody else. A caller whose context ends stops waiting; the call is
+// only canceled once every caller waiting for it has gone.
+
+var ErrPanicked = errors.New("singleflight: call panicked")
+
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+	expires time.Time // set once done; the result is shared until then
+}
+
+type Group[K comparable, V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	calls     map[K]*call[V]
+	lastSweep time.Time
+
+	executed, joined, reused int64
+}
+
+// New returns a group that shares finished results for ttl; 0 shares them
+// only with the callers that were already waiting. now may be nil for
+// time.Now.
+func New[K comparable, V any](ttl time.Duration, now func() time.Time) *Group[K, V] {
+	if now == nil {
+		now = time.Now
+	}
+	return &Group[K, V]{ttl: ttl, now: now, calls: make(map[K]*call[V]), lastSweep: now()}
+}
+
+// Do returns fn's result for key, calling fn only if no call for key is in
+// flight and no fresh result is held. shared reports whether the result came
+// from another caller's call.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (v V, shared bool, err error) {
+	g.mu.Lock()
+	now := g.now()
+	g.sweep(now)
+	if c, ok := g.calls[key]; ok {
+		switch {
+		case !finished(c):
+			c.waiters++
+			g.joined++
+			g.mu.Unlock()
+			return g.wait(ctx, key, c, true)
+		case now.Before(c.expires):
+			g.reused++
+			g.mu.Unlock()
+			return c.val, true, c.err
+		}
+	}
+	callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c := &call[V]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	g.calls[key] = c
+	g.executed++
+	g.mu.Unlock()
+
+	go g.run(callCtx, key, c, fn)
+	return g.wait(ctx, key, c, false)
+}
+
+func finished[V any](c *call[V]) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (g *Group[K, V]) run(ctx context.Context, key K, c *call[V], fn func(context.Context) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("%w: %v", ErrPanicked, r)
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		c.expires = g.now().Add(g.ttl)
+		if (c.err != nil || g.ttl <= 0) && g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		close(c.done)
+		c.cancel()
+	}()
+	c.val, c.err = fn(ctx)
+}
+
+func (g *Group[K, V]) wait(ctx context.Context, key K, c *call[V], shared bool) (V, bool, error) {
+	select {
+	case <-c.done:
+		return c.val, shared, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		c.waiters--
+		if c.waiters == 0 && !finished(c) {
+			// Nobody wants the result any more; later callers start afresh
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		var zero V
+		return zero, shared, ctx.Err()
+	}
+}
+
+// sweep drops expired results, at most once per TTL, so keys that are never
+// asked for again do not pile up. Called with mu held.
+func (g *Group[K, V]) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < g.ttl {
+		return
+	}
+	for key, c := range g.calls {
+		if finished(c) && !now.Before(c.expires) {
+			delete(g.calls, key)
+		}
+	}
+	g.lastSweep = now
+}
+
+// Forget drops key's fresh result, so the next caller makes a new call.
+// A call in flight is not affected.
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok && finished(c) {
+		delete(g.calls, key)
+	}
+}
+
+// Waiters returns how many callers are waiting on key's call in flight
+func (g *Group[K, V]) Waiters(key K) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok && !finished(c) {
+		return c.waiters
+	}
+	return 0
+}
+
+type Stats struct {
+	Executed int64 // calls made
+	Joined   int64 // callers that waited for a call in flight
+	Reused   int64 // callers served a fresh finished result
+	InFlight int
+}
+
+func (g *Group[K, V]) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := Stats{Executed: g.executed, Joined: g.joined, Reused: g.reused}
+	for _, c := range g.calls {
+		if !finished(c) {
+			s.InFlight++
+		}
+	}
+	return s
+}
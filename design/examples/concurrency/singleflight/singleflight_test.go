@@ -0,0 +1,332 @@
+package singleflight_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/concurrency-example/singleflight"
+)
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// backend is a slow product service. It counts calls per key and the most
+// calls for one key it ever had running at once.
+type backend struct {
+	latency time.Duration
+	fail    atomic.Bool
+
+	mu      sync.Mutex
+	calls   map[string]int
+	running map[string]int
+	overlap int
+	aborted int
+}
+
+func newBackend(latency time.Duration) *backend {
+	return &backend{latency: latency, calls: map[string]int{}, running: map[string]int{}}
+}
+
+func (b *backend) fetch(key string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		b.mu.Lock()
+		b.calls[key]++
+		b.running[key]++
+		b.overlap = max(b.overlap, b.running[key])
+		n := b.calls[key]
+		b.mu.Unlock()
+		defer func() {
+			b.mu.Lock()
+			b.running[key]--
+			b.mu.Unlock()
+		}()
+		select {
+		case <-time.After(b.latency):
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.aborted++
+			b.mu.Unlock()
+			return "", ctx.Err()
+		}
+		if b.fail.Load() {
+			return "", errors.New("backend unavailable")
+		}
+		return fmt.Sprintf("%s#%d", key, n), nil
+	}
+}
+
+func (b *backend) total() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, c := range b.calls {
+		n += c
+	}
+	return n
+}
+
+// herd calls Do for key from n goroutines at once and returns the distinct
+// results and errors
+func herd(g *singleflight.Group[string, string], b *backend, key string, n int) (values map[string]int, errs int) {
+	values = map[string]int{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			v, _, err := g.Do(context.Background(), key, b.fetch(key))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs++
+				return
+			}
+			values[v]++
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return values, errs
+}
+
+func TestHerd(t *testing.T) {
+	b := newBackend(50 * time.Millisecond)
+	g := singleflight.New[string, string](0, nil)
+	values, errs := herd(g, b, "product-1", 1000)
+	st := g.Stats()
+
+	t.Run("1000 identical misses make one backend call", func(t *testing.T) {
+		if n := b.total(); n != 1 {
+			t.Errorf("%d backend calls", n)
+		}
+	})
+	t.Run("every caller gets that call's result", func(t *testing.T) {
+		if errs != 0 || len(values) != 1 || values["product-1#1"] != 1000 {
+			t.Errorf("results %v, %d errors", values, errs)
+		}
+	})
+	t.Run("Stats counts 1 call and 999 joined", func(t *testing.T) {
+		if st.Executed != 1 || st.Joined != 999 || st.InFlight != 0 {
+			t.Errorf("%+v", st)
+		}
+	})
+}
+
+func TestManyKeys(t *testing.T) {
+	b := newBackend(20 * time.Millisecond)
+	g := singleflight.New[string, string](0, nil)
+	var wg sync.WaitGroup
+	var mixed atomic.Bool
+	for k := 0; k < 20; k++ {
+		key := fmt.Sprintf("product-%d", k)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if v, _, _ := g.Do(context.Background(), key, b.fetch(key)); v != key+"#1" {
+					mixed.Store(true)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	if n := b.total(); n != 20 {
+		t.Errorf("1000 callers over 20 keys made %d calls, want one per key", n)
+	}
+	if mixed.Load() {
+		t.Error("a caller got another key's result")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	clk := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := newBackend(5 * time.Millisecond)
+	g := singleflight.New[string, string](time.Second, clk.Now)
+	g.Do(ctx, "product-1", b.fetch("product-1"))
+
+	for _, tc := range []struct {
+		name    string
+		advance time.Duration
+		forget  bool
+		calls   int
+		shared  bool
+		value   string
+	}{
+		{"a caller 900ms after the call gets its result, without a call", 900 * time.Millisecond, false, 1, true, "product-1#1"},
+		{"at 1s the result has expired and a new call is made", 100 * time.Millisecond, false, 2, false, "product-1#2"},
+		{"Forget drops a fresh result early, after a write", 0, true, 3, false, "product-1#3"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clk.Advance(tc.advance)
+			if tc.forget {
+				g.Forget("product-1")
+			}
+			v, shared, err := g.Do(ctx, "product-1", b.fetch("product-1"))
+			if err != nil || v != tc.value || shared != tc.shared || b.total() != tc.calls {
+				t.Errorf("Do = %q, shared %t, %v after %d calls; want %q, shared %t after %d", v, shared, err, b.total(), tc.value, tc.shared, tc.calls)
+			}
+		})
+	}
+
+	t.Run("with no TTL only callers already waiting share", func(t *testing.T) {
+		b := newBackend(5 * time.Millisecond)
+		g := singleflight.New[string, string](0, nil)
+		g.Do(ctx, "product-1", b.fetch("product-1"))
+		g.Do(ctx, "product-1", b.fetch("product-1"))
+		if n := b.total(); n != 2 {
+			t.Errorf("%d calls, want 2", n)
+		}
+	})
+}
+
+func TestErrorsAndPanics(t *testing.T) {
+	ctx := context.Background()
+	b := newBackend(20 * time.Millisecond)
+	g := singleflight.New[string, string](time.Minute, nil)
+
+	t.Run("a failed call fails every caller that waited for it, once", func(t *testing.T) {
+		b.fail.Store(true)
+		defer b.fail.Store(false)
+		if _, errs := herd(g, b, "product-1", 100); b.total() != 1 || errs != 100 {
+			t.Errorf("%d calls, %d errors; want 1 and 100", b.total(), errs)
+		}
+	})
+	t.Run("the error is not kept: the next caller retries", func(t *testing.T) {
+		if _, shared, err := g.Do(ctx, "product-1", b.fetch("product-1")); err != nil || shared || b.total() != 2 {
+			t.Errorf("Do = %v, shared %t after %d calls", err, shared, b.total())
+		}
+	})
+	t.Run("a panicking call fails its callers with ErrPanicked", func(t *testing.T) {
+		_, _, err := g.Do(ctx, "product-2", func(context.Context) (string, error) { panic("nil map") })
+		if !errors.Is(err, singleflight.ErrPanicked) {
+			t.Errorf("Do = %v", err)
+		}
+	})
+	t.Run("and the key works again afterwards", func(t *testing.T) {
+		if v, _, err := g.Do(ctx, "product-2", b.fetch("product-2")); err != nil || v != "product-2#1" {
+			t.Errorf("Do = %q, %v", v, err)
+		}
+	})
+}
+
+func TestCallersThatGiveUp(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("the first caller giving up does not fail the others", func(t *testing.T) {
+		b := newBackend(100 * time.Millisecond)
+		g := singleflight.New[string, string](0, nil)
+		firstCtx, cancelFirst := context.WithCancel(ctx)
+		defer cancelFirst()
+		first := make(chan error, 1)
+		go func() {
+			_, _, err := g.Do(firstCtx, "product-1", b.fetch("product-1"))
+			first <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		others := make(chan error, 9)
+		for i := 0; i < 9; i++ {
+			go func() {
+				_, _, err := g.Do(ctx, "product-1", b.fetch("product-1"))
+				others <- err
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		if n := g.Waiters("product-1"); n != 10 {
+			t.Fatalf("%d callers wait, want 10", n)
+		}
+		cancelFirst()
+		if err := <-first; !errors.Is(err, context.Canceled) {
+			t.Errorf("the first caller got %v, want its own Canceled", err)
+		}
+		for i := 0; i < 9; i++ {
+			if err := <-others; err != nil {
+				t.Errorf("another caller got %v", err)
+			}
+		}
+		if b.total() != 1 || b.aborted != 0 {
+			t.Errorf("%d calls, %d aborted; want the one call to carry on", b.total(), b.aborted)
+		}
+	})
+
+	t.Run("once every caller has gone, the call is canceled", func(t *testing.T) {
+		b := newBackend(time.Second)
+		g := singleflight.New[string, string](0, nil)
+		tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				g.Do(tctx, "product-1", b.fetch("product-1"))
+			}()
+		}
+		wg.Wait()
+		time.Sleep(10 * time.Millisecond)
+		b.mu.Lock()
+		aborted := b.aborted
+		b.mu.Unlock()
+		if aborted != 1 {
+			t.Errorf("%d calls aborted, want 1", aborted)
+		}
+		if n := g.Stats().InFlight; n != 0 {
+			t.Errorf("InFlight = %d: a new caller would join the abandoned call", n)
+		}
+	})
+}
+
+// TestRandomLoad has 64 goroutines call Do over 50 keys with a fixed seed
+// each. No key may ever have two backend calls running at once.
+func TestRandomLoad(t *testing.T) {
+	b := newBackend(time.Millisecond)
+	g := singleflight.New[string, string](2*time.Millisecond, nil)
+	var calls atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < 64; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("product-%d", rng.Intn(50))
+				g.Do(context.Background(), key, b.fetch(key))
+				calls.Add(1)
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+	st := g.Stats()
+	t.Logf("%d Do calls: %d backend calls, %d joined, %d served from TTL", calls.Load(), st.Executed, st.Joined, st.Reused)
+
+	if b.overlap != 1 {
+		t.Errorf("a key had %d backend calls running at once", b.overlap)
+	}
+	if int64(b.total()) != st.Executed || st.Executed+st.Joined+st.Reused != calls.Load() {
+		t.Errorf("%d Do calls, but %d executed (%d backend calls), %d joined and %d reused",
+			calls.Load(), st.Executed, b.total(), st.Joined, st.Reused)
+	}
+}
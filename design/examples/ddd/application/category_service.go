@@ -0,0 +1,138 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// CategoryService keeps the catalog's category tree. The tree and the
+// products are separate aggregates: moving a category changes the tree,
+// and the category of every product under it.
+type CategoryService struct {
+	products   repository.ProductRepository
+	categories repository.CategoryRepository
+}
+
+func NewCategoryService(products repository.ProductRepository, categories repository.CategoryRepository) *CategoryService {
+	return &CategoryService{products: products, categories: categories}
+}
+
+// CreateCategory adds the category at path, such as "Electronics/Laptops".
+// Its parent must exist already.
+func (s *CategoryService) CreateCategory(path string) (model.Category, error) {
+	category, err := model.NewCategory(path)
+	if err != nil {
+		return model.Category{}, err
+	}
+	tree, err := s.categories.Load()
+	if err != nil {
+		return model.Category{}, err
+	}
+	if err := tree.Add(category); err != nil {
+		return model.Category{}, err
+	}
+	return category, s.categories.Save(tree)
+}
+
+// MoveCategory moves the category at path, with its subcategories, under
+// the one at parent, or to the top if parent is empty, and returns where it
+// is now.
+//
+// The products are moved first, then the tree is saved. The move is all or
+// nothing: if a product or the tree cannot be saved, the products already
+// moved are put back under the old path, which the stored tree still has.
+// Only if putting one back fails too does the error say so, with both
+// causes.
+func (s *CategoryService) MoveCategory(path, parent string) (model.Category, error) {
+	category, err := model.NewCategory(path)
+	if err != nil {
+		return model.Category{}, err
+	}
+	var to *model.Category
+	if parent != "" {
+		p, err := model.NewCategory(parent)
+		if err != nil {
+			return model.Category{}, err
+		}
+		to = &p
+	}
+
+	tree, err := s.categories.Load()
+	if err != nil {
+		return model.Category{}, err
+	}
+	from, err := tree.Find(category)
+	if err != nil {
+		return model.Category{}, err
+	}
+	moved, err := tree.Move(from, to)
+	if err != nil {
+		return model.Category{}, err
+	}
+
+	products, err := s.products.FindInCategory(from)
+	if err != nil {
+		return model.Category{}, err
+	}
+	was := make([]model.Category, len(products))
+	for i, product := range products {
+		was[i] = product.Category()
+		rebased, _ := was[i].Rebase(from, moved)
+		product.ChangeCategory(rebased)
+		if err := s.products.Save(product); err != nil {
+			return model.Category{}, s.putBack(products[:i], was, err)
+		}
+	}
+	if err := s.categories.Save(tree); err != nil {
+		return model.Category{}, s.putBack(products, was, err)
+	}
+	return moved, nil
+}
+
+// putBack returns products to the categories they were in before a move
+// that failed with err
+func (s *CategoryService) putBack(products []*model.Product, was []model.Category, err error) error {
+	for i, product := range products {
+		product.ChangeCategory(was[i])
+		if putErr := s.products.Save(product); putErr != nil {
+			err = errors.Join(err, fmt.Errorf("putting %s back in %s: %w", product.ID(), was[i].Path(), putErr))
+		}
+	}
+	return err
+}
+
+// RemoveCategory removes the category at path. It must have neither
+// subcategories nor products.
+func (s *CategoryService) RemoveCategory(path string) error {
+	category, err := model.NewCategory(path)
+	if err != nil {
+		return err
+	}
+	tree, err := s.categories.Load()
+	if err != nil {
+		return err
+	}
+	if err := tree.Remove(category); err != nil {
+		return err
+	}
+	products, err := s.products.FindInCategory(category)
+	if err != nil {
+		return err
+	}
+	if len(products) > 0 {
+		return model.ValidationError("category " + category.Path() + " still has products")
+	}
+	return s.categories.Save(tree)
+}
+
+// GetCategories returns every category, each after its parent
+func (s *CategoryService) GetCategories() ([]model.Category, error) {
+	tree, err := s.categories.Load()
+	if err != nil {
+		return nil, err
+	}
+	return tree.Categories(), nil
+}
@@ -0,0 +1,215 @@
+package application_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/service"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// paths joins the paths of categories with commas
+func paths(categories []model.Category) string {
+	names := make([]string, 0, len(categories))
+	for _, c := range categories {
+		names = append(names, c.Path())
+	}
+	return strings.Join(names, ", ")
+}
+
+// isInvalid reports whether err is a ValidationError
+func isInvalid(err error) bool {
+	var invalid model.ValidationError
+	return errors.As(err, &invalid)
+}
+
+// createCategories creates the categories at paths, in order
+func createCategories(t *testing.T, categories *application.CategoryService, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if _, err := categories.CreateCategory(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// categoryOf returns the path of the stored product's category
+func categoryOf(t *testing.T, products *persistence.MemoryProductRepository, id model.ProductID) string {
+	t.Helper()
+	product, err := products.FindByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return product.Category().Path()
+}
+
+func TestCategoryService(t *testing.T) {
+	products := persistence.NewMemoryProductRepository()
+	categories := application.NewCategoryService(products, persistence.NewMemoryCategoryRepository())
+	catalog := application.NewProductService(products)
+	createCategories(t, categories, "Electronics", "Electronics/Computers", "Electronics/Computers/Laptops", "Office")
+	if _, err := categories.CreateCategory("Garden/Tools"); !isInvalid(err) {
+		t.Errorf("creating a category without its parent = %v, want a ValidationError", err)
+	}
+
+	laptop, err := catalog.CreateProduct(application.CreateProductDTO{Name: "Laptop", Price: 1000, Currency: "EUR", Category: "Electronics/Computers/Laptops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desk, err := catalog.CreateProduct(application.CreateProductDTO{Name: "Desk", Price: 300, Currency: "EUR", Category: "Office"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := categories.MoveCategory("electronics/computers", "Office")
+	if err != nil || moved.Path() != "Office/Computers" {
+		t.Fatalf("MoveCategory = %q, %v, want Office/Computers", moved.Path(), err)
+	}
+	if got := categoryOf(t, products, laptop.ID()); got != "Office/Computers/Laptops" {
+		t.Errorf("after the move, the laptop is in %s, want it moved with its category, at any depth", got)
+	}
+	if got := categoryOf(t, products, desk.ID()); got != "Office" {
+		t.Errorf("after the move, the desk is in %s, want it left in Office", got)
+	}
+	if all, _ := categories.GetCategories(); paths(all) != "Electronics, Office, Office/Computers, Office/Computers/Laptops" {
+		t.Errorf("GetCategories = %s, want the tree saved moved", paths(all))
+	}
+
+	if _, err := categories.MoveCategory("Office", "Office/Computers/Laptops"); !isInvalid(err) {
+		t.Errorf("moving a category under its own subcategory = %v, want a ValidationError", err)
+	}
+	if got := categoryOf(t, products, desk.ID()); got != "Office" {
+		t.Errorf("after a refused move, the desk is in %s, want it left in Office", got)
+	}
+	if _, err := categories.MoveCategory("Garden", ""); !errors.Is(err, model.ErrCategoryNotFound) {
+		t.Errorf("moving an unknown category = %v, want %v", err, model.ErrCategoryNotFound)
+	}
+
+	if err := categories.RemoveCategory("Office/Computers"); !isInvalid(err) {
+		t.Errorf("removing a category with subcategories = %v, want a ValidationError", err)
+	}
+	if err := categories.RemoveCategory("Office/Computers/Laptops"); !isInvalid(err) {
+		t.Errorf("removing a category with products = %v, want a ValidationError", err)
+	}
+	if err := categories.RemoveCategory("Electronics"); err != nil {
+		t.Errorf("removing an empty category = %v, want it removed", err)
+	}
+	if found, err := catalog.GetProductsInCategory("office"); err != nil || len(found) != 2 {
+		t.Errorf("GetProductsInCategory(office) = %d products, %v, want those of the whole subtree", len(found), err)
+	}
+}
+
+func TestPromotionOnACategory(t *testing.T) {
+	products := persistence.NewMemoryProductRepository()
+	laptop, err := application.NewProductService(products).CreateProduct(application.CreateProductDTO{
+		Name: "Laptop", Price: 1000, Currency: "EUR", Category: "Electronics/Computers/Laptops",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	computers, err := model.NewCategory("Electronics/Computers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sale, err := model.NewPercentageOff("Computers week", 10, model.PromotionTerms{Scope: model.InCategory(computers)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	promotions := persistence.NewMemoryPromotionRepository()
+	if err := promotions.Save(sale); err != nil {
+		t.Fatal(err)
+	}
+	quote, err := service.NewPricingServiceWithPromotions(promotions, service.Stack).Quote(laptop, 1, time.Now())
+	if err != nil || quote.Discount.Minor() != 10000 {
+		t.Errorf("Quote = %+v, %v, want the category's promotion to cover the products of its subcategories", quote, err)
+	}
+}
+
+// brokenProducts counts the saves, from 1, and fails those fail refuses
+type brokenProducts struct {
+	*persistence.MemoryProductRepository
+	saves int
+	fail  func(save int) bool
+}
+
+func (r *brokenProducts) Save(product *model.Product) error {
+	if r.saves++; r.fail != nil && r.fail(r.saves) {
+		return errors.New("disk full")
+	}
+	return r.MemoryProductRepository.Save(product)
+}
+
+// brokenCategories fails every save once broken
+type brokenCategories struct {
+	*persistence.MemoryCategoryRepository
+	broken bool
+}
+
+func (r *brokenCategories) Save(tree *model.CategoryTree) error {
+	if r.broken {
+		return errors.New("disk full")
+	}
+	return r.MemoryCategoryRepository.Save(tree)
+}
+
+// TestMoveCategoryIsAllOrNothing fails a move at each save it makes, and
+// checks that the products are all still where the stored tree has them
+func TestMoveCategoryIsAllOrNothing(t *testing.T) {
+	failures := []struct {
+		name       string
+		products   func(save int) bool
+		tree       bool
+		putBackErr bool
+	}{
+		{"the first product's save", func(save int) bool { return save == 1 }, false, false},
+		{"the second product's save", func(save int) bool { return save == 2 }, false, false},
+		{"the last product's save", func(save int) bool { return save == 3 }, false, false},
+		{"the tree's save", nil, true, false},
+		{"the third product's save, and putting the others back", func(save int) bool { return save >= 3 }, false, true},
+	}
+	for _, tt := range failures {
+		products := &brokenProducts{MemoryProductRepository: persistence.NewMemoryProductRepository()}
+		tree := &brokenCategories{MemoryCategoryRepository: persistence.NewMemoryCategoryRepository()}
+		categories := application.NewCategoryService(products, tree)
+		catalog := application.NewProductService(products)
+		createCategories(t, categories, "Electronics", "Electronics/Computers", "Electronics/Computers/Laptops", "Office")
+		computers := map[model.ProductID]string{}
+		for _, p := range []struct{ name, category string }{
+			{"Desktop", "Electronics/Computers"},
+			{"Laptop", "Electronics/Computers/Laptops"},
+			{"Tablet", "Electronics/Computers"},
+		} {
+			product, err := catalog.CreateProduct(application.CreateProductDTO{Name: p.name, Price: 500, Currency: "EUR", Category: p.category})
+			if err != nil {
+				t.Fatal(err)
+			}
+			computers[product.ID()] = p.category
+		}
+
+		products.saves, products.fail, tree.broken = 0, tt.products, tt.tree
+		_, err := categories.MoveCategory("Electronics/Computers", "Office")
+		if err == nil || !strings.Contains(err.Error(), "disk full") || strings.Contains(err.Error(), "putting") != tt.putBackErr {
+			t.Errorf("failing %s, MoveCategory = %v, want the failure, and whether a product could not be put back", tt.name, err)
+		}
+		if all, _ := categories.GetCategories(); paths(all) != "Electronics, Electronics/Computers, Electronics/Computers/Laptops, Office" {
+			t.Errorf("failing %s, the stored tree is %s, want it unmoved", tt.name, paths(all))
+		}
+		if tt.putBackErr {
+			continue
+		}
+		for id, want := range computers {
+			if got := categoryOf(t, products.MemoryProductRepository, id); got != want {
+				t.Errorf("failing %s, a product is in %s, want it put back in %s", tt.name, got, want)
+			}
+		}
+
+		products.fail, tree.broken = nil, false
+		if moved, err := categories.MoveCategory("Electronics/Computers", "Office"); err != nil || moved.Path() != "Office/Computers" {
+			t.Errorf("failing %s, moving again once it works = %q, %v, want Office/Computers", tt.name, moved.Path(), err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// EventHandler reacts to a domain event. It runs after the change that
+// raised the event is saved, so it cannot undo it.
+type EventHandler func(event model.Event)
+
+// EventDispatcher hands domain events to the handlers registered for them,
+// in process and in order: the events in the order the aggregate recorded
+// them, and for each the handlers in the order they were registered.
+type EventDispatcher struct {
+	mu            sync.RWMutex
+	registrations []registration
+}
+
+type registration struct {
+	handler EventHandler
+	names   []string // none for every event
+}
+
+func NewEventDispatcher() *EventDispatcher {
+	return &EventDispatcher{}
+}
+
+// Register adds handler for the events named, such as
+// model.PriceChangedEvent, or for every event if none is named
+func (d *EventDispatcher) Register(handler EventHandler, eventNames ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.registrations = append(d.registrations, registration{handler: handler, names: eventNames})
+}
+
+// Dispatch calls the handlers of each event in turn
+func (d *EventDispatcher) Dispatch(events ...model.Event) {
+	d.mu.RLock()
+	registrations := d.registrations
+	d.mu.RUnlock()
+	for _, event := range events {
+		for _, r := range registrations {
+			if len(r.names) == 0 || slices.Contains(r.names, event.EventName()) {
+				r.handler(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package application_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// eventNames lists the names of events, oldest first
+func eventNames(events []model.Event) string {
+	var out []string
+	for _, event := range events {
+		out = append(out, event.EventName())
+	}
+	return strings.Join(out, " ")
+}
+
+// failingProducts stores nothing
+type failingProducts struct {
+	*persistence.MemoryProductRepository
+}
+
+func (failingProducts) Save(*model.Product) error {
+	return errors.New("disk full")
+}
+
+func TestEventDispatcher(t *testing.T) {
+	d := application.NewEventDispatcher()
+	var calls []string
+	d.Register(func(e model.Event) { calls = append(calls, "all:"+e.EventName()) })
+	d.Register(func(e model.Event) { calls = append(calls, "price:"+e.EventName()) }, model.PriceChangedEvent)
+	d.Register(func(e model.Event) { calls = append(calls, "end:"+e.EventName()) },
+		model.PriceChangedEvent, model.ProductDiscontinuedEvent)
+
+	id := model.NewProductID()
+	d.Dispatch(model.ProductCreated{ID: id}, model.PriceChanged{ID: id}, model.ProductDiscontinued{ID: id})
+	want := "all:product.created " +
+		"all:product.price_changed price:product.price_changed end:product.price_changed " +
+		"all:product.discontinued end:product.discontinued"
+	if got := strings.Join(calls, " "); got != want {
+		t.Errorf("calls = %s, want each event in turn, to its handlers in the order they registered: %s", got, want)
+	}
+}
+
+func TestProductServiceDispatchesEventsAfterSaving(t *testing.T) {
+	repo := persistence.NewMemoryProductRepository()
+	d := application.NewEventDispatcher()
+	var seen []model.Event
+	d.Register(func(e model.Event) {
+		seen = append(seen, e)
+		if stored, err := repo.FindByID(e.ProductID()); err != nil || !stored.UpdatedAt().Equal(e.OccurredAt()) {
+			t.Errorf("%s was dispatched before the change was saved", e.EventName())
+		}
+	})
+	service := application.NewProductServiceWithEvents(repo, d)
+
+	product, err := service.CreateProduct(application.CreateProductDTO{Name: "Lamp", Price: 50, Currency: "EUR", Category: "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.ApplyDiscountToProduct(product.ID(), 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ChangeProductPrice(product.ID(), 60); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.DiscontinueProduct(product.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := eventNames(seen), "product.created product.price_changed product.price_changed product.discontinued"; got != want {
+		t.Fatalf("dispatched %s, want one event per change, in order: %s", got, want)
+	}
+	if discount, _ := seen[1].(model.PriceChanged); discount.OldPrice.Amount() != 50 || discount.NewPrice.Amount() != 45 || discount.NewPrice.Currency() != "EUR" {
+		t.Errorf("the discount dispatched %+v, want a PriceChanged from 50 to 45 EUR", seen[1])
+	}
+	if n := len(product.Events()); n != 0 {
+		t.Errorf("after dispatching, the product holds %d events, want them cleared", n)
+	}
+
+	if _, err := service.ChangeProductPrice(product.ID(), 70); err == nil || len(seen) != 4 {
+		t.Errorf("a change the model refuses = %v, dispatching %d events, want an error and nothing dispatched", err, len(seen)-4)
+	}
+	failing := application.NewProductServiceWithEvents(failingProducts{repo}, d)
+	if _, err := failing.CreateProduct(application.CreateProductDTO{Name: "Rug", Price: 80, Currency: "EUR", Category: "Home"}); err == nil || len(seen) != 4 {
+		t.Errorf("a change that cannot be saved = %v, dispatching %d events, want an error and nothing dispatched", err, len(seen)-4)
+	}
+}
@@ -0,0 +1,116 @@
+package application
+
+import (
+	"errors"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// maxAttempts is how many times a change to an inventory is tried when
+// someone else saves it first: it fails only if that many other changes
+// were saved while it was being made
+const maxAttempts = 100
+
+// InventoryService changes the stock of products. Each change loads one
+// Inventory, changes it and saves it, trying again from a fresh load if
+// another change was saved in between, so concurrent reservations never
+// take more than is on hand.
+//
+// It also keeps the rules that span the Product and Inventory aggregates,
+// reading the product without changing it: only a product in the catalog
+// has stock, and a discontinued one can be restocked no more, nor reserved.
+type InventoryService struct {
+	products    repository.ProductRepository
+	inventories repository.InventoryRepository
+}
+
+func NewInventoryService(products repository.ProductRepository, inventories repository.InventoryRepository) *InventoryService {
+	return &InventoryService{products: products, inventories: inventories}
+}
+
+// Restock adds quantity to the stock of a product, giving it an inventory
+// if it has none
+func (s *InventoryService) Restock(productID model.ProductID, quantity int) (*model.Inventory, error) {
+	if err := s.onSale(productID); err != nil {
+		return nil, err
+	}
+	return s.change(productID, true, func(inventory *model.Inventory) error {
+		return inventory.Restock(quantity)
+	})
+}
+
+// Reserve sets quantity of a product aside for reservationID. It fails with
+// model.ErrInsufficientStock if less is available.
+func (s *InventoryService) Reserve(productID model.ProductID, reservationID model.ReservationID, quantity int) (*model.Inventory, error) {
+	if err := s.onSale(productID); err != nil {
+		return nil, err
+	}
+	return s.change(productID, false, func(inventory *model.Inventory) error {
+		return inventory.Reserve(reservationID, quantity)
+	})
+}
+
+// Release gives the stock reserved for reservationID back. It works for a
+// discontinued product too, as an order for it can still be cancelled.
+func (s *InventoryService) Release(productID model.ProductID, reservationID model.ReservationID) (*model.Inventory, error) {
+	return s.change(productID, false, func(inventory *model.Inventory) error {
+		return inventory.Release(reservationID)
+	})
+}
+
+// Commit takes the stock reserved for reservationID off hand. It works for
+// a discontinued product too, as an order for it can still ship.
+func (s *InventoryService) Commit(productID model.ProductID, reservationID model.ReservationID) (*model.Inventory, error) {
+	return s.change(productID, false, func(inventory *model.Inventory) error {
+		return inventory.Commit(reservationID)
+	})
+}
+
+func (s *InventoryService) GetInventory(productID model.ProductID) (*model.Inventory, error) {
+	return s.inventories.FindByProductID(productID)
+}
+
+// onSale returns an error unless the product is in the catalog and not
+// discontinued
+func (s *InventoryService) onSale(productID model.ProductID) error {
+	product, err := s.products.FindByID(productID)
+	if err != nil {
+		return err
+	}
+	if product.Discontinued() {
+		return model.ValidationError("product is discontinued")
+	}
+	return nil
+}
+
+// change applies apply to the product's inventory and saves it, from a
+// fresh load each time the save finds it changed. Without an inventory,
+// it starts from an empty one, and saves it only if create is set: with
+// nothing on hand and nothing reserved, apply reports why it cannot run.
+func (s *InventoryService) change(productID model.ProductID, create bool, apply func(*model.Inventory) error) (*model.Inventory, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		inventory, err := s.inventories.FindByProductID(productID)
+		if errors.Is(err, repository.ErrInventoryNotFound) {
+			inventory = model.NewInventory(productID)
+		} else if err != nil {
+			return nil, err
+		}
+		if err := apply(inventory); err != nil {
+			return nil, err
+		}
+		if inventory.Version() == 0 && !create {
+			return nil, repository.ErrInventoryNotFound
+		}
+		err = s.inventories.Save(inventory)
+		if errors.Is(err, repository.ErrConcurrentModification) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// As stored, at its new version
+		return s.inventories.FindByProductID(productID)
+	}
+	return nil, repository.ErrConcurrentModification
+}
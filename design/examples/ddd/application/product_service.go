@@ -1,6 +1,8 @@
 package application
 
 import (
+"errors"
+
 "github.com/dong-tran/docs/ddd-example/domain/model"
 "github.com/dong-tran/docs/ddd-example/domain/repository"
 "github.com/dong-tran/docs/ddd-example/domain/service"
@@ -9,13 +11,33 @@ import (
 type ProductService struct {
 	repo           repository.ProductRepository
 	pricingService *service.PricingService
+	events         *EventDispatcher
 }
 
 func NewProductService(repo repository.ProductRepository) *ProductService {
+	return NewProductServiceWithEvents(repo, NewEventDispatcher())
+}
+
+// NewProductServiceWithEvents is NewProductService, dispatching the events
+// of each change to the handlers registered with events once it is saved
+func NewProductServiceWithEvents(repo repository.ProductRepository, events *EventDispatcher) *ProductService {
 	return &ProductService{
 		repo:           repo,
 		pricingService: service.NewPricingService(),
+		events:         events,
+	}
+}
+
+// save stores product, then dispatches the events it recorded. If it
+// cannot be stored, nothing happened, and no event is dispatched.
+func (s *ProductService) save(product *model.Product) error {
+	if err := s.repo.Save(product); err != nil {
+		return err
 	}
+	events := product.Events()
+	product.ClearEvents()
+	s.events.Dispatch(events...)
+	return nil
 }
 
 type CreateProductDTO struct {
@@ -42,7 +64,7 @@ func (s *ProductService) CreateProduct(dto CreateProductDTO) (*model.Product, er
 		return nil, err
 	}
 
-	if err := s.repo.Save(product); err != nil {
+	if err := s.save(product); err != nil {
 		return nil, err
 	}
 
@@ -59,7 +81,156 @@ func (s *ProductService) ApplyDiscountToProduct(productID model.ProductID, disco
 		return err
 	}
 
-	return s.repo.Save(product)
+	return s.save(product)
+}
+
+// ChangeProductPrice sets the price of a product, in the currency it has
+func (s *ProductService) ChangeProductPrice(productID model.ProductID, amount float64) (*model.Product, error) {
+	product, err := s.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := model.NewMoney(amount, product.Price().Currency())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := product.ChangePrice(price); err != nil {
+		return nil, err
+	}
+
+	if err := s.save(product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// DiscontinueProduct takes a product off sale
+func (s *ProductService) DiscontinueProduct(productID model.ProductID) (*model.Product, error) {
+	product, err := s.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := product.Discontinue(); err != nil {
+		return nil, err
+	}
+
+	if err := s.save(product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// AddVariantDTO is a variant to add: Price is in the product's currency
+type AddVariantDTO struct {
+	SKU        string
+	Price      float64
+	Attributes map[string]string
+}
+
+// AddVariant adds a variant to a product. It fails with
+// repository.ErrSKUTaken if another product has the SKU.
+func (s *ProductService) AddVariant(productID model.ProductID, dto AddVariantDTO) (*model.Product, error) {
+	sku, err := model.NewSKU(dto.SKU)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.skuAvailable(sku, productID); err != nil {
+		return nil, err
+	}
+
+	price, err := model.NewMoney(dto.Price, product.Price().Currency())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := product.AddVariant(sku, price, dto.Attributes); err != nil {
+		return nil, err
+	}
+
+	if err := s.save(product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// skuAvailable returns repository.ErrSKUTaken if a product other than id
+// has a variant of sku. The repository checks again when saving; this only
+// fails earlier, before anything is changed.
+func (s *ProductService) skuAvailable(sku model.SKU, id model.ProductID) error {
+	other, err := s.repo.FindBySKU(sku)
+	if errors.Is(err, repository.ErrProductNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if other.ID() != id {
+		return repository.ErrSKUTaken
+	}
+	return nil
+}
+
+// ChangeVariantPrice sets the price of a variant, in its product's currency
+func (s *ProductService) ChangeVariantPrice(productID model.ProductID, sku string, amount float64) (*model.Product, error) {
+	product, err := s.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	variant, err := model.NewSKU(sku)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := model.NewMoney(amount, product.Price().Currency())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := product.ChangeVariantPrice(variant, price); err != nil {
+		return nil, err
+	}
+
+	if err := s.save(product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// RetireVariant takes a variant of a product off sale
+func (s *ProductService) RetireVariant(productID model.ProductID, sku string) (*model.Product, error) {
+	product, err := s.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	variant, err := model.NewSKU(sku)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := product.RetireVariant(variant); err != nil {
+		return nil, err
+	}
+
+	if err := s.save(product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
 }
 
 func (s *ProductService) GetProduct(id model.ProductID) (*model.Product, error) {
@@ -69,3 +240,13 @@ func (s *ProductService) GetProduct(id model.ProductID) (*model.Product, error)
 func (s *ProductService) GetAllProducts() ([]*model.Product, error) {
 	return s.repo.FindAll()
 }
+
+// GetProductsInCategory returns the products of the category at path and of
+// its subcategories, oldest first
+func (s *ProductService) GetProductsInCategory(path string) ([]*model.Product, error) {
+	category, err := model.NewCategory(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindInCategory(category)
+}
@@ -0,0 +1,43 @@
+package application_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+func TestProductServiceVariants(t *testing.T) {
+	service := application.NewProductService(persistence.NewMemoryProductRepository())
+	phone, err := service.CreateProduct(application.CreateProductDTO{Name: "Phone", Price: 500, Currency: "EUR", Category: "Electronics/Phones"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	phoneCase, err := service.CreateProduct(application.CreateProductDTO{Name: "Case", Price: 20, Currency: "EUR", Category: "Electronics/Phones"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product, err := service.AddVariant(phone.ID(), application.AddVariantDTO{SKU: "ph-128", Price: 550, Attributes: map[string]string{"storage": "128GB"}})
+	if err != nil || len(product.Variants()) != 1 || product.Variants()[0].Price().Minor() != 55000 || product.Variants()[0].Price().Currency() != "EUR" {
+		t.Fatalf("AddVariant = %v, want the variant priced in the product's currency, 550.00 EUR", err)
+	}
+	if _, err := service.AddVariant(phoneCase.ID(), application.AddVariantDTO{SKU: "PH-128", Price: 25, Attributes: map[string]string{"color": "black"}}); !errors.Is(err, repository.ErrSKUTaken) {
+		t.Errorf("adding another product's SKU = %v, want %v", err, repository.ErrSKUTaken)
+	}
+	if _, err := service.AddVariant(phone.ID(), application.AddVariantDTO{SKU: "PH_256", Price: 600, Attributes: map[string]string{"storage": "256GB"}}); !isInvalid(err) {
+		t.Errorf("adding a malformed SKU = %v, want a ValidationError", err)
+	}
+	if product, err = service.ChangeVariantPrice(phone.ID(), "ph-128", 499); err != nil || product.Variants()[0].Price().Minor() != 49900 {
+		t.Errorf("ChangeVariantPrice = %v, want it at 499.00", err)
+	}
+	if product, err = service.RetireVariant(phone.ID(), "PH-128"); err != nil || !product.Variants()[0].Retired() {
+		t.Errorf("RetireVariant = %v, want it retired", err)
+	}
+	if _, err := service.RetireVariant(phone.ID(), "PH-512"); !errors.Is(err, model.ErrVariantNotFound) {
+		t.Errorf("retiring an unknown variant = %v, want %v", err, model.ErrVariantNotFound)
+	}
+}
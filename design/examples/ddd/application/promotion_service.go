@@ -0,0 +1,115 @@
+package application
+
+import (
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/domain/service"
+)
+
+// PromotionService configures the promotion rules, and quotes products
+// with them
+type PromotionService struct {
+	products   repository.ProductRepository
+	promotions repository.PromotionRepository
+	pricing    *service.PricingService
+}
+
+func NewPromotionService(products repository.ProductRepository, promotions repository.PromotionRepository, policy service.StackingPolicy) *PromotionService {
+	return &PromotionService{
+		products:   products,
+		promotions: promotions,
+		pricing:    service.NewPricingServiceWithPromotions(promotions, policy),
+	}
+}
+
+// CreatePromotionDTO describes a rule. Kind says which of Percent, Amount
+// and Currency, or Buy and Get, are used. Category or ProductID narrow it
+// to a category or a product; a zero From or Until leaves its period open
+// on that side.
+type CreatePromotionDTO struct {
+	Name      string
+	Kind      model.PromotionKind
+	Percent   float64
+	Amount    float64
+	Currency  string
+	Buy       int
+	Get       int
+	Category  string
+	ProductID string
+	From      time.Time
+	Until     time.Time
+	Stackable bool
+	Priority  int
+}
+
+func (s *PromotionService) CreatePromotion(dto CreatePromotionDTO) (*model.Promotion, error) {
+	terms, err := dto.terms()
+	if err != nil {
+		return nil, err
+	}
+
+	var promotion *model.Promotion
+	switch dto.Kind {
+	case model.PercentageOff:
+		promotion, err = model.NewPercentageOff(dto.Name, dto.Percent, terms)
+	case model.AmountOff:
+		var amount model.Money
+		if amount, err = model.NewMoney(dto.Amount, dto.Currency); err == nil {
+			promotion, err = model.NewAmountOff(dto.Name, amount, terms)
+		}
+	case model.BuyXGetY:
+		promotion, err = model.NewBuyXGetY(dto.Name, dto.Buy, dto.Get, terms)
+	default:
+		err = model.ValidationError("unknown kind of promotion")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.promotions.Save(promotion); err != nil {
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+func (dto CreatePromotionDTO) terms() (model.PromotionTerms, error) {
+	period, err := model.NewPeriod(dto.From, dto.Until)
+	if err != nil {
+		return model.PromotionTerms{}, err
+	}
+	scope := model.AllProducts()
+	switch {
+	case dto.ProductID != "" && dto.Category != "":
+		return model.PromotionTerms{}, model.ValidationError("a promotion covers a category or a product, not both")
+	case dto.ProductID != "":
+		id, err := model.ParseProductID(dto.ProductID)
+		if err != nil {
+			return model.PromotionTerms{}, err
+		}
+		scope = model.ForProduct(id)
+	case dto.Category != "":
+		category, err := model.NewCategory(dto.Category)
+		if err != nil {
+			return model.PromotionTerms{}, err
+		}
+		scope = model.InCategory(category)
+	}
+	return model.PromotionTerms{Scope: scope, Period: period, Stackable: dto.Stackable, Priority: dto.Priority}, nil
+}
+
+func (s *PromotionService) RemovePromotion(id model.PromotionID) error {
+	return s.promotions.Delete(id)
+}
+
+// QuoteProduct prices quantity units of a product at t, with the
+// promotions in effect then
+func (s *PromotionService) QuoteProduct(productID model.ProductID, quantity int, t time.Time) (service.Quote, error) {
+	product, err := s.products.FindByID(productID)
+	if err != nil {
+		return service.Quote{}, err
+	}
+	return s.pricing.Quote(product, quantity, t)
+}
@@ -0,0 +1,30 @@
+package application_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/domain/service"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+func TestPromotionService(t *testing.T) {
+	promotions := application.NewPromotionService(persistence.NewMemoryProductRepository(), persistence.NewMemoryPromotionRepository(), service.Stack)
+	sale, err := promotions.CreatePromotion(application.CreatePromotionDTO{Name: "Sale", Kind: model.PercentageOff, Percent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := promotions.RemovePromotion(sale.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := promotions.RemovePromotion(sale.ID()); !errors.Is(err, repository.ErrPromotionNotFound) {
+		t.Errorf("removing a promotion twice = %v, want %v", err, repository.ErrPromotionNotFound)
+	}
+	if _, err := promotions.QuoteProduct(model.NewProductID(), 1, time.Now()); !errors.Is(err, repository.ErrProductNotFound) {
+		t.Errorf("quoting an unknown product = %v, want %v", err, repository.ErrProductNotFound)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	producthttp "github.com/dong-tran/docs/ddd-example/infrastructure/http"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// The product catalog server: the repository (infrastructure/persistence)
+// under the application service, under the HTTP handlers.
+//
+//	go run cmd/main.go [-addr :8080] [-db products.db | -memory]
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	dsn := flag.String("db", "products.db", "SQLite file to store products in")
+	memory := flag.Bool("memory", false, "keep products in memory instead, until the server stops")
+	flag.Parse()
+
+	var repo repository.ProductRepository
+	if *memory {
+		repo = persistence.NewMemoryProductRepository()
+	} else {
+		db, err := sqlx.Open("sqlite3", *dsn)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+		if err := persistence.CreateSchema(db); err != nil {
+			log.Fatalf("Failed to create schema: %v", err)
+		}
+		repo = persistence.NewSQLProductRepository(db)
+	}
+
+	e := echo.New()
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+	// Domain events are logged here; other handlers register the same way
+	events := application.NewEventDispatcher()
+	events.Register(func(event model.Event) {
+		log.Printf("%s: product %s", event.EventName(), event.ProductID())
+	})
+	producthttp.NewProductHandler(application.NewProductServiceWithEvents(repo, events)).Register(e)
+
+	log.Printf("Server starting on %s", *addr)
+	if err := e.Start(*addr); err != nil {
+		log.Fatalf("Server stopped: %v", err)
+	}
+}
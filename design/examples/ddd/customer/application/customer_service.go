@@ -0,0 +1,138 @@
+// Package application holds the use cases of the customer context
+package application
+
+import (
+	"errors"
+
+	"github.com/dong-tran/docs/ddd-example/customer/domain/model"
+	"github.com/dong-tran/docs/ddd-example/customer/domain/repository"
+)
+
+type CustomerService struct {
+	repo repository.CustomerRepository
+}
+
+func NewCustomerService(repo repository.CustomerRepository) *CustomerService {
+	return &CustomerService{repo: repo}
+}
+
+type AddressDTO struct {
+	Street     string
+	City       string
+	PostalCode string
+	Country    string
+}
+
+type RegisterCustomerDTO struct {
+	Name    string
+	Email   string
+	Address AddressDTO
+}
+
+func (dto AddressDTO) toAddress() (model.Address, error) {
+	return model.NewAddress(dto.Street, dto.City, dto.PostalCode, dto.Country)
+}
+
+// RegisterCustomer registers an active customer. It fails with
+// repository.ErrEmailTaken if another customer has the email.
+func (s *CustomerService) RegisterCustomer(dto RegisterCustomerDTO) (*model.Customer, error) {
+	email, err := model.NewEmail(dto.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := dto.Address.toAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.available(email, model.CustomerID{}); err != nil {
+		return nil, err
+	}
+
+	customer, err := model.NewCustomer(dto.Name, email, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Save(customer); err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+// ChangeEmail moves a customer to another email, unless another customer
+// has it
+func (s *CustomerService) ChangeEmail(id model.CustomerID, address string) (*model.Customer, error) {
+	email, err := model.NewEmail(address)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.available(email, id); err != nil {
+		return nil, err
+	}
+	return s.change(id, func(customer *model.Customer) error {
+		return customer.ChangeEmail(email)
+	})
+}
+
+func (s *CustomerService) MoveCustomer(id model.CustomerID, dto AddressDTO) (*model.Customer, error) {
+	address, err := dto.toAddress()
+	if err != nil {
+		return nil, err
+	}
+	return s.change(id, func(customer *model.Customer) error {
+		return customer.MoveTo(address)
+	})
+}
+
+func (s *CustomerService) SuspendCustomer(id model.CustomerID) (*model.Customer, error) {
+	return s.change(id, (*model.Customer).Suspend)
+}
+
+func (s *CustomerService) ReactivateCustomer(id model.CustomerID) (*model.Customer, error) {
+	return s.change(id, (*model.Customer).Reactivate)
+}
+
+func (s *CustomerService) CloseCustomer(id model.CustomerID) (*model.Customer, error) {
+	return s.change(id, (*model.Customer).Close)
+}
+
+func (s *CustomerService) GetCustomer(id model.CustomerID) (*model.Customer, error) {
+	return s.repo.FindByID(id)
+}
+
+// available returns repository.ErrEmailTaken if a customer other than id
+// has email. The repository checks again when saving; this only fails
+// earlier, before anything is changed.
+func (s *CustomerService) available(email model.Email, id model.CustomerID) error {
+	other, err := s.repo.FindByEmail(email)
+	if errors.Is(err, repository.ErrCustomerNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if other.ID() != id {
+		return repository.ErrEmailTaken
+	}
+	return nil
+}
+
+func (s *CustomerService) change(id model.CustomerID, apply func(*model.Customer) error) (*model.Customer, error) {
+	customer, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apply(customer); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Save(customer); err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
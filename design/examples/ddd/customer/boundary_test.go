@@ -0,0 +1,62 @@
+package customer_test
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The boundary test reads the imports of the source: no package of the
+// customer context imports one of the catalog, nor the other way round.
+
+const module = "github.com/dong-tran/docs/ddd-example"
+
+// imports returns the packages of the module that the packages under dir
+// import, relative to the module
+func imports(t *testing.T, dir string) map[string]bool {
+	t.Helper()
+	found := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if strings.HasPrefix(path, module+"/") {
+				found[strings.TrimPrefix(path, module+"/")] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestBoundary(t *testing.T) {
+	customer := imports(t, ".")
+	if len(customer) == 0 {
+		t.Fatal("the customer context imports none of its own packages; is this the customer directory?")
+	}
+	for path := range customer {
+		if !strings.HasPrefix(path, "customer/") {
+			t.Errorf("the customer context imports %s, want only its own packages", path)
+		}
+	}
+	for _, dir := range []string{"domain", "application", "infrastructure"} {
+		for path := range imports(t, filepath.Join("..", dir)) {
+			if strings.HasPrefix(path, "customer/") {
+				t.Errorf("the catalog's %s packages import %s, want none of the customer context", dir, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package model is the model of the customer context: who buys, where they
+// live and whether they may still order. It shares no types with the
+// catalog's model; where a customer and a product meet, as in an order,
+// each context is referred to by ID only.
+package model
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ValidationError is a rule of the customer model that a value breaks
+type ValidationError string
+
+func (e ValidationError) Error() string {
+	return string(e)
+}
+
+type CustomerID struct {
+	value string
+}
+
+func NewCustomerID() CustomerID {
+	return CustomerID{value: uuid.New().String()}
+}
+
+// ParseCustomerID returns the CustomerID written as s
+func ParseCustomerID(s string) (CustomerID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return CustomerID{}, ValidationError("customer id must be a UUID")
+	}
+	return CustomerID{value: id.String()}, nil
+}
+
+func (id CustomerID) String() string {
+	return id.value
+}
+
+// Email is a value object: an address in lower case, so two customers
+// cannot share one by writing it differently
+type Email struct {
+	address string
+}
+
+func NewEmail(address string) (Email, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil || parsed.Name != "" {
+		return Email{}, ValidationError("email address is invalid")
+	}
+	return Email{address: strings.ToLower(parsed.Address)}, nil
+}
+
+func (e Email) String() string {
+	return e.address
+}
+
+// Address is a value object: a postal address, with a two-letter ISO
+// country code
+type Address struct {
+	street     string
+	city       string
+	postalCode string
+	country    string
+}
+
+func NewAddress(street, city, postalCode, country string) (Address, error) {
+	street, city, postalCode = strings.TrimSpace(street), strings.TrimSpace(city), strings.TrimSpace(postalCode)
+	if street == "" || city == "" || postalCode == "" {
+		return Address{}, ValidationError("address needs a street, a city and a postal code")
+	}
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if len(country) != 2 || strings.Trim(country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return Address{}, ValidationError("country must be a two-letter code")
+	}
+	return Address{street: street, city: city, postalCode: postalCode, country: country}, nil
+}
+
+func (a Address) Street() string     { return a.street }
+func (a Address) City() string       { return a.city }
+func (a Address) PostalCode() string { return a.postalCode }
+func (a Address) Country() string    { return a.country }
+
+// Status is where a customer is in their life cycle: active, suspended for
+// a while, or closed for good
+type Status string
+
+const (
+	StatusActive    Status = "active"
+	StatusSuspended Status = "suspended"
+	StatusClosed    Status = "closed"
+)
+
+// ParseStatus returns the Status written as s, as it comes back from storage
+func ParseStatus(s string) (Status, error) {
+	switch status := Status(s); status {
+	case StatusActive, StatusSuspended, StatusClosed:
+		return status, nil
+	}
+	return "", ValidationError("unknown customer status")
+}
+
+// Customer is an aggregate root. Only an active customer may order; a
+// closed one can no longer change.
+type Customer struct {
+	id        CustomerID
+	name      string
+	email     Email
+	address   Address
+	status    Status
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// NewCustomer registers an active customer
+func NewCustomer(name string, email Email, address Address) (*Customer, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ValidationError("customer name cannot be empty")
+	}
+	now := time.Now()
+	return &Customer{
+		id:        NewCustomerID(),
+		name:      name,
+		email:     email,
+		address:   address,
+		status:    StatusActive,
+		createdAt: now,
+		updatedAt: now,
+	}, nil
+}
+
+// ReconstructCustomer rebuilds a customer that was stored. It is for
+// repositories only.
+func ReconstructCustomer(id CustomerID, name string, email Email, address Address, status Status, createdAt, updatedAt time.Time) *Customer {
+	return &Customer{
+		id:        id,
+		name:      name,
+		email:     email,
+		address:   address,
+		status:    status,
+		createdAt: createdAt,
+		updatedAt: updatedAt,
+	}
+}
+
+func (c *Customer) ID() CustomerID       { return c.id }
+func (c *Customer) Name() string         { return c.name }
+func (c *Customer) Email() Email         { return c.email }
+func (c *Customer) Address() Address     { return c.address }
+func (c *Customer) Status() Status       { return c.status }
+func (c *Customer) CreatedAt() time.Time { return c.createdAt }
+func (c *Customer) UpdatedAt() time.Time { return c.updatedAt }
+
+// CanOrder reports whether the customer may place orders
+func (c *Customer) CanOrder() bool {
+	return c.status == StatusActive
+}
+
+// ChangeEmail moves the customer to another address. Whether another
+// customer has it is for the application service to check.
+func (c *Customer) ChangeEmail(email Email) error {
+	if err := c.open(); err != nil {
+		return err
+	}
+	c.email = email
+	c.touch()
+	return nil
+}
+
+// MoveTo changes the customer's address
+func (c *Customer) MoveTo(address Address) error {
+	if err := c.open(); err != nil {
+		return err
+	}
+	c.address = address
+	c.touch()
+	return nil
+}
+
+// Suspend stops an active customer from ordering, until reactivated
+func (c *Customer) Suspend() error {
+	if c.status != StatusActive {
+		return ValidationError("only an active customer can be suspended")
+	}
+	c.status = StatusSuspended
+	c.touch()
+	return nil
+}
+
+// Reactivate lets a suspended customer order again
+func (c *Customer) Reactivate() error {
+	if c.status != StatusSuspended {
+		return ValidationError("only a suspended customer can be reactivated")
+	}
+	c.status = StatusActive
+	c.touch()
+	return nil
+}
+
+// Close closes the customer's account for good
+func (c *Customer) Close() error {
+	if err := c.open(); err != nil {
+		return err
+	}
+	c.status = StatusClosed
+	c.touch()
+	return nil
+}
+
+func (c *Customer) open() error {
+	if c.status == StatusClosed {
+		return ValidationError("customer is closed")
+	}
+	return nil
+}
+
+func (c *Customer) touch() {
+	c.updatedAt = time.Now()
+}
@@ -0,0 +1,100 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/customer/domain/model"
+)
+
+// isInvalid reports whether err is a ValidationError of the customer context
+func isInvalid(err error) bool {
+	var invalid model.ValidationError
+	return errors.As(err, &invalid)
+}
+
+func TestEmail(t *testing.T) {
+	email, err := model.NewEmail("  Ann.Lee@Example.COM ")
+	if err != nil || email.String() != "ann.lee@example.com" {
+		t.Errorf("NewEmail = %q, %v, want it trimmed, in lower case", email, err)
+	}
+	for _, bad := range []string{"", "ann", "Ann <ann@example.com>", "ann@"} {
+		if _, err := model.NewEmail(bad); !isInvalid(err) {
+			t.Errorf("NewEmail(%q) = %v, want a ValidationError", bad, err)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	home, err := model.NewAddress("1 Main Street", "Springfield", "12345", "us")
+	if err != nil || home.Country() != "US" {
+		t.Errorf("NewAddress = %v, country %q, want the country code in upper case", err, home.Country())
+	}
+	invalid := []struct {
+		name                          string
+		street, city, postal, country string
+	}{
+		{"no city", "1 Main Street", "", "12345", "US"},
+		{"a three-letter country", "1 Main Street", "Springfield", "12345", "USA"},
+	}
+	for _, tt := range invalid {
+		if _, err := model.NewAddress(tt.street, tt.city, tt.postal, tt.country); !isInvalid(err) {
+			t.Errorf("an address with %s = %v, want a ValidationError", tt.name, err)
+		}
+	}
+}
+
+func TestCustomerLifeCycle(t *testing.T) {
+	email, err := model.NewEmail("ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home, err := model.NewAddress("1 Main Street", "Springfield", "12345", "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer, err := model.NewCustomer("Ann Lee", email, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customer.Status() != model.StatusActive || !customer.CanOrder() {
+		t.Errorf("a new customer is %s, want active and able to order", customer.Status())
+	}
+	if err := customer.Reactivate(); !isInvalid(err) {
+		t.Errorf("reactivating an active customer = %v, want a ValidationError", err)
+	}
+
+	if err := customer.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	if customer.Status() != model.StatusSuspended || customer.CanOrder() {
+		t.Errorf("a suspended customer is %s, able to order: %v, want suspended and not", customer.Status(), customer.CanOrder())
+	}
+	if err := customer.Suspend(); !isInvalid(err) {
+		t.Errorf("suspending again = %v, want a ValidationError", err)
+	}
+	if err := customer.Reactivate(); err != nil || !customer.CanOrder() {
+		t.Errorf("Reactivate = %v, want the customer able to order again", err)
+	}
+
+	if err := customer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if customer.Status() != model.StatusClosed || customer.CanOrder() {
+		t.Errorf("a closed customer is %s, able to order: %v, want closed and not", customer.Status(), customer.CanOrder())
+	}
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{"MoveTo", func() error { return customer.MoveTo(home) }},
+		{"ChangeEmail", func() error { return customer.ChangeEmail(email) }},
+		{"Reactivate", customer.Reactivate},
+		{"Close", customer.Close},
+	}
+	for _, tt := range changes {
+		if err := tt.change(); !isInvalid(err) {
+			t.Errorf("%s on a closed customer = %v, want a ValidationError", tt.name, err)
+		}
+	}
+}
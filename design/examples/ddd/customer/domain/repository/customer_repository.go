@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/dong-tran/docs/ddd-example/customer/domain/model"
+)
+
+// ErrCustomerNotFound is returned by FindByID and FindByEmail when there is
+// no such customer
+var ErrCustomerNotFound = errors.New("customer not found")
+
+// ErrEmailTaken is returned by Save when another customer has the email
+var ErrEmailTaken = errors.New("email address is taken")
+
+// CustomerRepository defines the contract for customer persistence. Save
+// inserts a customer or replaces the stored one, and keeps emails unique.
+type CustomerRepository interface {
+	Save(customer *model.Customer) error
+	FindByID(id model.CustomerID) (*model.Customer, error)
+	FindByEmail(email model.Email) (*model.Customer, error)
+}
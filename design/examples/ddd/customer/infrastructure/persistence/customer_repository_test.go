@@ -0,0 +1,112 @@
+package persistence_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/dong-tran/docs/ddd-example/customer/application"
+	"github.com/dong-tran/docs/ddd-example/customer/domain/model"
+	"github.com/dong-tran/docs/ddd-example/customer/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/customer/infrastructure/persistence"
+)
+
+// customerRepositories runs test against both CustomerRepository
+// implementations
+func customerRepositories(t *testing.T, test func(t *testing.T, repo repository.CustomerRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, persistence.NewMemoryCustomerRepository()) })
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "customers.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := persistence.CreateSchema(db); err != nil {
+			t.Fatal(err)
+		}
+		test(t, persistence.NewSQLCustomerRepository(db))
+	})
+}
+
+// register registers a customer at address()
+func register(t *testing.T, service *application.CustomerService, name, email string) *model.Customer {
+	t.Helper()
+	customer, err := service.RegisterCustomer(application.RegisterCustomerDTO{Name: name, Email: email, Address: address()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return customer
+}
+
+// address returns 1 Main Street, Springfield, US
+func address() application.AddressDTO {
+	return application.AddressDTO{Street: "1 Main Street", City: "Springfield", PostalCode: "12345", Country: "us"}
+}
+
+func TestCustomerService(t *testing.T) {
+	customerRepositories(t, func(t *testing.T, repo repository.CustomerRepository) {
+		service := application.NewCustomerService(repo)
+		ann := register(t, service, "Ann Lee", "ann@example.com")
+		found, err := service.GetCustomer(ann.ID())
+		if err != nil || found.Name() != "Ann Lee" || found.Email() != ann.Email() || found.Address() != ann.Address() ||
+			found.Status() != model.StatusActive || !found.CreatedAt().Equal(ann.CreatedAt()) {
+			t.Errorf("GetCustomer = %v, want the customer as registered", err)
+		}
+		if byEmail, err := repo.FindByEmail(ann.Email()); err != nil || byEmail.ID() != ann.ID() {
+			t.Errorf("FindByEmail = %v, want the customer", err)
+		}
+		if _, err := service.GetCustomer(model.NewCustomerID()); !errors.Is(err, repository.ErrCustomerNotFound) {
+			t.Errorf("GetCustomer of an unknown id = %v, want %v", err, repository.ErrCustomerNotFound)
+		}
+
+		moved := address()
+		moved.City = "Shelbyville"
+		bob := register(t, service, "Bob", "bob@example.com")
+		if _, err := service.MoveCustomer(bob.ID(), moved); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.SuspendCustomer(bob.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if found, err := service.GetCustomer(bob.ID()); err != nil || found.Address().City() != "Shelbyville" || found.Status() != model.StatusSuspended {
+			t.Errorf("GetCustomer after a move and a suspension = %v, want both stored", err)
+		}
+		if _, err := service.CloseCustomer(bob.ID()); err != nil {
+			t.Fatal(err)
+		}
+		var invalid model.ValidationError
+		if _, err := service.ReactivateCustomer(bob.ID()); !errors.As(err, &invalid) {
+			t.Errorf("reactivating a closed customer = %v, want a ValidationError", err)
+		}
+	})
+}
+
+func TestCustomerEmailIsUnique(t *testing.T) {
+	customerRepositories(t, func(t *testing.T, repo repository.CustomerRepository) {
+		service := application.NewCustomerService(repo)
+		ann := register(t, service, "Ann Lee", "ann@example.com")
+		bob := register(t, service, "Bob", "bob@example.com")
+
+		if _, err := service.RegisterCustomer(application.RegisterCustomerDTO{Name: "Ann Again", Email: "ANN@example.com", Address: address()}); !errors.Is(err, repository.ErrEmailTaken) {
+			t.Errorf("registering with a taken email, written differently = %v, want %v", err, repository.ErrEmailTaken)
+		}
+		if _, err := service.ChangeEmail(bob.ID(), "ann@example.com"); !errors.Is(err, repository.ErrEmailTaken) {
+			t.Errorf("changing to a taken email = %v, want %v", err, repository.ErrEmailTaken)
+		}
+		taken, err := model.NewEmail("ann@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clash := model.ReconstructCustomer(bob.ID(), "Bob", taken, bob.Address(), model.StatusActive, bob.CreatedAt(), time.Now())
+		if err := repo.Save(clash); !errors.Is(err, repository.ErrEmailTaken) {
+			t.Errorf("saving a customer with a taken email = %v, want the repository to refuse it with %v", err, repository.ErrEmailTaken)
+		}
+		if _, err := service.ChangeEmail(ann.ID(), "ann@example.com"); err != nil {
+			t.Errorf("a customer keeping their own email = %v, want it accepted", err)
+		}
+	})
+}
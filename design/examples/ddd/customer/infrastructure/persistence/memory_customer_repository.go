@@ -0,0 +1,55 @@
+package persistence
+
+import (
+	"sync"
+
+	"github.com/dong-tran/docs/ddd-example/customer/domain/model"
+	"github.com/dong-tran/docs/ddd-example/customer/domain/repository"
+)
+
+// MemoryCustomerRepository keeps customers in memory, for tests and for
+// running the example without a database. It stores copies, as
+// MemoryProductRepository does in the catalog.
+type MemoryCustomerRepository struct {
+	mu        sync.RWMutex
+	customers map[model.CustomerID]model.Customer
+}
+
+var _ repository.CustomerRepository = (*MemoryCustomerRepository)(nil)
+
+func NewMemoryCustomerRepository() *MemoryCustomerRepository {
+	return &MemoryCustomerRepository{customers: map[model.CustomerID]model.Customer{}}
+}
+
+func (r *MemoryCustomerRepository) Save(customer *model.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, stored := range r.customers {
+		if id != customer.ID() && stored.Email() == customer.Email() {
+			return repository.ErrEmailTaken
+		}
+	}
+	r.customers[customer.ID()] = *customer
+	return nil
+}
+
+func (r *MemoryCustomerRepository) FindByID(id model.CustomerID) (*model.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	customer, ok := r.customers[id]
+	if !ok {
+		return nil, repository.ErrCustomerNotFound
+	}
+	return &customer, nil
+}
+
+func (r *MemoryCustomerRepository) FindByEmail(email model.Email) (*model.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, customer := range r.customers {
+		if customer.Email() == email {
+			return &customer, nil
+		}
+	}
+	return nil, repository.ErrCustomerNotFound
+}
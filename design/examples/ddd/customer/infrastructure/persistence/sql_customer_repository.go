@@ -0,0 +1,144 @@
+// Package persistence implements the customer context's repository, in SQL
+// with sqlx and in memory. Its tables are its own: it never reads or joins
+// the catalog's.
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/dong-tran/docs/ddd-example/customer/domain/model"
+	"github.com/dong-tran/docs/ddd-example/customer/domain/repository"
+)
+
+// Schema creates the customers table
+const Schema = `
+CREATE TABLE IF NOT EXISTS customers (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	email       TEXT NOT NULL UNIQUE,
+	street      TEXT NOT NULL,
+	city        TEXT NOT NULL,
+	postal_code TEXT NOT NULL,
+	country     TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	created_at  TIMESTAMP NOT NULL,
+	updated_at  TIMESTAMP NOT NULL
+)`
+
+// CreateSchema creates the customers table if it does not exist
+func CreateSchema(db *sqlx.DB) error {
+	_, err := db.Exec(Schema)
+	return err
+}
+
+type customerRow struct {
+	ID         string    `db:"id"`
+	Name       string    `db:"name"`
+	Email      string    `db:"email"`
+	Street     string    `db:"street"`
+	City       string    `db:"city"`
+	PostalCode string    `db:"postal_code"`
+	Country    string    `db:"country"`
+	Status     string    `db:"status"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// toCustomer rebuilds the aggregate through the value objects' constructors
+func (row customerRow) toCustomer() (*model.Customer, error) {
+	id, err := model.ParseCustomerID(row.ID)
+	if err != nil {
+		return nil, fmt.Errorf("customer %q: %w", row.ID, err)
+	}
+	email, err := model.NewEmail(row.Email)
+	if err != nil {
+		return nil, fmt.Errorf("customer %s: %w", row.ID, err)
+	}
+	address, err := model.NewAddress(row.Street, row.City, row.PostalCode, row.Country)
+	if err != nil {
+		return nil, fmt.Errorf("customer %s: %w", row.ID, err)
+	}
+	status, err := model.ParseStatus(row.Status)
+	if err != nil {
+		return nil, fmt.Errorf("customer %s: %w", row.ID, err)
+	}
+	return model.ReconstructCustomer(id, row.Name, email, address, status, row.CreatedAt, row.UpdatedAt), nil
+}
+
+// SQLCustomerRepository stores customers in the customers table
+type SQLCustomerRepository struct {
+	db *sqlx.DB
+}
+
+var _ repository.CustomerRepository = (*SQLCustomerRepository)(nil)
+
+func NewSQLCustomerRepository(db *sqlx.DB) *SQLCustomerRepository {
+	return &SQLCustomerRepository{db: db}
+}
+
+// Save inserts the customer, or replaces the stored one with the same id.
+// The unique email column backs up the check for another customer with the
+// email, which gives ErrEmailTaken.
+func (r *SQLCustomerRepository) Save(customer *model.Customer) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	err = tx.Get(&taken, tx.Rebind(`SELECT COUNT(*) FROM customers WHERE email = ? AND id <> ?`),
+		customer.Email().String(), customer.ID().String())
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return repository.ErrEmailTaken
+	}
+
+	address := customer.Address()
+	_, err = tx.Exec(tx.Rebind(`
+		INSERT INTO customers (id, name, email, street, city, postal_code, country, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			email = excluded.email,
+			street = excluded.street,
+			city = excluded.city,
+			postal_code = excluded.postal_code,
+			country = excluded.country,
+			status = excluded.status,
+			updated_at = excluded.updated_at`),
+		customer.ID().String(), customer.Name(), customer.Email().String(),
+		address.Street(), address.City(), address.PostalCode(), address.Country(),
+		string(customer.Status()), customer.CreatedAt().UTC(), customer.UpdatedAt().UTC())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLCustomerRepository) FindByID(id model.CustomerID) (*model.Customer, error) {
+	return r.find(`SELECT * FROM customers WHERE id = ?`, id.String())
+}
+
+func (r *SQLCustomerRepository) FindByEmail(email model.Email) (*model.Customer, error) {
+	return r.find(`SELECT * FROM customers WHERE email = ?`, email.String())
+}
+
+func (r *SQLCustomerRepository) find(query string, arg string) (*model.Customer, error) {
+	var row customerRow
+	err := r.db.Get(&row, r.db.Rebind(query), arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.toCustomer()
+}
@@ -0,0 +1,89 @@
+package model
+
+import "strings"
+
+// CategorySeparator separates the names in a category's path
+const CategorySeparator = "/"
+
+// Category is a value object: a place in the catalog's hierarchy, written
+// as its path from the top, such as "Electronics/Computers/Laptops". A
+// category of one name is at the top. Names are compared regardless of
+// case, so "books" and "Books" are the same category.
+type Category struct {
+	path string
+}
+
+// NewCategory returns the category at path. Spaces around each name are
+// dropped; an empty name, or one with the separator, is refused.
+func NewCategory(path string) (Category, error) {
+	if strings.TrimSpace(path) == "" {
+		return Category{}, ValidationError("category name cannot be empty")
+	}
+	names := strings.Split(path, CategorySeparator)
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if names[i] == "" {
+			return Category{}, ValidationError("category path cannot have an empty name")
+		}
+	}
+	return Category{path: strings.Join(names, CategorySeparator)}, nil
+}
+
+// Path is the category's names from the top, joined by CategorySeparator
+func (c Category) Path() string {
+	return c.path
+}
+
+// Name is the category's own name, the last of its path
+func (c Category) Name() string {
+	return c.path[strings.LastIndex(c.path, CategorySeparator)+1:]
+}
+
+// Depth is 1 for a category at the top, 2 for its subcategories, and so on
+func (c Category) Depth() int {
+	return strings.Count(c.path, CategorySeparator) + 1
+}
+
+// Parent returns the category c is in, and false for a category at the top
+func (c Category) Parent() (Category, bool) {
+	i := strings.LastIndex(c.path, CategorySeparator)
+	if i < 0 {
+		return Category{}, false
+	}
+	return Category{path: c.path[:i]}, true
+}
+
+// Child returns the subcategory of c called name
+func (c Category) Child(name string) (Category, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, CategorySeparator) {
+		return Category{}, ValidationError("a subcategory needs a name without " + CategorySeparator)
+	}
+	return Category{path: c.path + CategorySeparator + name}, nil
+}
+
+// Equals reports whether c and other are the same category
+func (c Category) Equals(other Category) bool {
+	return strings.EqualFold(c.path, other.path)
+}
+
+// Contains reports whether other is c or one of its subcategories, at any
+// depth
+func (c Category) Contains(other Category) bool {
+	if len(other.path) == len(c.path) {
+		return c.Equals(other)
+	}
+	return len(other.path) > len(c.path) &&
+		other.path[len(c.path):len(c.path)+len(CategorySeparator)] == CategorySeparator &&
+		strings.EqualFold(other.path[:len(c.path)], c.path)
+}
+
+// Rebase returns c with from, which contains it, replaced by to: where c
+// ends up when from moves to to. It returns false if from does not contain
+// c.
+func (c Category) Rebase(from, to Category) (Category, bool) {
+	if !from.Contains(c) {
+		return Category{}, false
+	}
+	return Category{path: to.path + c.path[len(from.path):]}, true
+}
@@ -0,0 +1,82 @@
+package model_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// category returns the category at path
+func category(t *testing.T, path string) model.Category {
+	t.Helper()
+	c, err := model.NewCategory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// paths joins the paths of categories with commas
+func paths(categories []model.Category) string {
+	names := make([]string, 0, len(categories))
+	for _, c := range categories {
+		names = append(names, c.Path())
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestCategory(t *testing.T) {
+	laptops := category(t, " Electronics / Computers/Laptops ")
+	if laptops.Path() != "Electronics/Computers/Laptops" || laptops.Name() != "Laptops" || laptops.Depth() != 3 {
+		t.Errorf("NewCategory = %q, name %q, depth %d, want the path read name by name, without the spaces around them",
+			laptops.Path(), laptops.Name(), laptops.Depth())
+	}
+	if parent, ok := laptops.Parent(); !ok || parent.Path() != "Electronics/Computers" {
+		t.Errorf("Parent = %q, %v, want the path without the last name", parent.Path(), ok)
+	}
+	top := category(t, "Electronics")
+	if _, ok := top.Parent(); ok || top.Depth() != 1 || top.Name() != "Electronics" {
+		t.Errorf("a category of one name has a parent: %v, depth %d, want it at the top", ok, top.Depth())
+	}
+	if child, err := top.Child("Phones"); err != nil || child.Path() != "Electronics/Phones" {
+		t.Errorf("Child(Phones) = %q, %v, want Electronics/Phones", child.Path(), err)
+	}
+	if _, err := top.Child("Phones/Cases"); !isInvalid(err) {
+		t.Errorf("Child of two names = %v, want a ValidationError", err)
+	}
+	for _, bad := range []string{"", "  ", "/Books", "Books/", "Books//Fiction", "Books/ /Fiction"} {
+		if _, err := model.NewCategory(bad); !isInvalid(err) {
+			t.Errorf("NewCategory(%q) = %v, want a ValidationError", bad, err)
+		}
+	}
+}
+
+func TestCategoryContains(t *testing.T) {
+	contains := []struct {
+		category, other string
+		want            bool
+	}{
+		{"Electronics", "Electronics/Computers/Laptops", true},
+		{"Electronics", "Electronics", true},
+		{"electronics/COMPUTERS", "Electronics/Computers/Laptops", true},
+		{"Electronics/Computers/Laptops", "Electronics", false},
+		{"Book", "Books", false},
+		{"Books", "Bookshelves/Oak", false},
+	}
+	for _, tt := range contains {
+		if got := category(t, tt.category).Contains(category(t, tt.other)); got != tt.want {
+			t.Errorf("%s contains %s: %v, want %v", tt.category, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestCategoryRebase(t *testing.T) {
+	laptops := category(t, "Electronics/Computers/Laptops")
+	if rebased, ok := laptops.Rebase(category(t, "Electronics/Computers"), category(t, "Computing")); !ok || rebased.Path() != "Computing/Laptops" {
+		t.Errorf("Rebase = %q, %v, want Computing/Laptops", rebased.Path(), ok)
+	}
+	if _, ok := category(t, "Electronics").Rebase(laptops, category(t, "Computing")); ok {
+		t.Error("rebasing a category outside from succeeded, want false")
+	}
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrCategoryNotFound is returned for a category that is not in the tree
+var ErrCategoryNotFound = errors.New("category not found")
+
+// CategoryTree is an aggregate root: the catalog's categories, as one
+// hierarchy. Its invariants hold whatever the calls: every category's
+// parent is in the tree, no two categories have the same path, and no
+// category is ever under itself, so the hierarchy has no cycle.
+type CategoryTree struct {
+	// categories by their path in lower case
+	categories map[string]Category
+}
+
+func NewCategoryTree() *CategoryTree {
+	return &CategoryTree{categories: map[string]Category{}}
+}
+
+// ReconstructCategoryTree rebuilds a tree that was stored. It is for
+// repositories only: it makes no checks, since the tree passed them when
+// the categories were added.
+func ReconstructCategoryTree(categories []Category) *CategoryTree {
+	tree := NewCategoryTree()
+	for _, category := range categories {
+		tree.categories[categoryKey(category)] = category
+	}
+	return tree
+}
+
+func categoryKey(c Category) string {
+	return strings.ToLower(c.path)
+}
+
+// Has reports whether category is in the tree
+func (t *CategoryTree) Has(category Category) bool {
+	_, ok := t.categories[categoryKey(category)]
+	return ok
+}
+
+// Find returns category as the tree has it, in the case it was added with
+func (t *CategoryTree) Find(category Category) (Category, error) {
+	found, ok := t.categories[categoryKey(category)]
+	if !ok {
+		return Category{}, ErrCategoryNotFound
+	}
+	return found, nil
+}
+
+// Add adds category. Its parent must be in the tree already.
+func (t *CategoryTree) Add(category Category) error {
+	if t.Has(category) {
+		return ValidationError("category " + category.Path() + " already exists")
+	}
+	if parent, ok := category.Parent(); ok && !t.Has(parent) {
+		return ValidationError("category " + parent.Path() + " must exist before its subcategories")
+	}
+	t.categories[categoryKey(category)] = category
+	return nil
+}
+
+// Remove removes a category without subcategories
+func (t *CategoryTree) Remove(category Category) error {
+	if !t.Has(category) {
+		return ErrCategoryNotFound
+	}
+	if len(t.Children(category)) > 0 {
+		return ValidationError("category " + category.Path() + " still has subcategories")
+	}
+	delete(t.categories, categoryKey(category))
+	return nil
+}
+
+// Move moves category, with its subcategories, under parent, or to the top
+// if parent is nil. It returns where category is now. Moving a category
+// under itself or one of its subcategories would make a cycle, and is
+// refused.
+func (t *CategoryTree) Move(category Category, parent *Category) (Category, error) {
+	from, err := t.Find(category)
+	if err != nil {
+		return Category{}, err
+	}
+	to := Category{path: from.Name()}
+	if parent != nil {
+		if !t.Has(*parent) {
+			return Category{}, ErrCategoryNotFound
+		}
+		if from.Contains(*parent) {
+			return Category{}, ValidationError("category " + from.Path() + " cannot move under itself or its subcategories")
+		}
+		parentAsStored, _ := t.Find(*parent)
+		to, _ = parentAsStored.Child(from.Name())
+	}
+	if to.Equals(from) {
+		return from, nil
+	}
+	if t.Has(to) {
+		return Category{}, ValidationError("category " + to.Path() + " already exists")
+	}
+	for _, moved := range t.Subtree(from) {
+		delete(t.categories, categoryKey(moved))
+		rebased, _ := moved.Rebase(from, to)
+		t.categories[categoryKey(rebased)] = rebased
+	}
+	return to, nil
+}
+
+// Children returns the categories directly under category, by path
+func (t *CategoryTree) Children(category Category) []Category {
+	var children []Category
+	for _, c := range t.categories {
+		if parent, ok := c.Parent(); ok && parent.Equals(category) {
+			children = append(children, c)
+		}
+	}
+	sortByPath(children)
+	return children
+}
+
+// Subtree returns category and every category under it, by path
+func (t *CategoryTree) Subtree(category Category) []Category {
+	var subtree []Category
+	for _, c := range t.categories {
+		if category.Contains(c) {
+			subtree = append(subtree, c)
+		}
+	}
+	sortByPath(subtree)
+	return subtree
+}
+
+// Categories returns every category, by path, so a parent comes before its
+// subcategories
+func (t *CategoryTree) Categories() []Category {
+	categories := make([]Category, 0, len(t.categories))
+	for _, c := range t.categories {
+		categories = append(categories, c)
+	}
+	sortByPath(categories)
+	return categories
+}
+
+// sortByPath orders categories name by name, so "A/B" comes before "A B"
+// as it does before "A2". Each path is split once, not at every comparison.
+func sortByPath(categories []Category) {
+	type keyed struct {
+		names    []string
+		category Category
+	}
+	sorted := make([]keyed, len(categories))
+	for i, c := range categories {
+		sorted[i] = keyed{strings.Split(strings.ToLower(c.path), CategorySeparator), c}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return namesLess(sorted[i].names, sorted[j].names) })
+	for i, k := range sorted {
+		categories[i] = k.category
+	}
+}
+
+func namesLess(an, bn []string) bool {
+	for i := 0; i < len(an) && i < len(bn); i++ {
+		if an[i] != bn[i] {
+			return an[i] < bn[i]
+		}
+	}
+	return len(an) < len(bn)
+}
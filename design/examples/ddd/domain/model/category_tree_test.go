@@ -0,0 +1,156 @@
+package model_test
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// electronicsAndBooks returns a tree of Electronics, with Laptops, Gaming
+// laptops and Phones, and of Books
+func electronicsAndBooks(t *testing.T) *model.CategoryTree {
+	t.Helper()
+	tree := model.NewCategoryTree()
+	for _, path := range []string{"Electronics", "Electronics/Laptops", "Electronics/Phones", "Electronics/Laptops/Gaming", "Books"} {
+		if err := tree.Add(category(t, path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tree
+}
+
+func TestCategoryTree(t *testing.T) {
+	if err := model.NewCategoryTree().Add(category(t, "Electronics/Laptops")); !isInvalid(err) {
+		t.Errorf("adding a subcategory before its parent = %v, want a ValidationError", err)
+	}
+	tree := electronicsAndBooks(t)
+	if err := tree.Add(category(t, "electronics/laptops")); !isInvalid(err) {
+		t.Errorf("adding a category again, in another case = %v, want a ValidationError", err)
+	}
+	queries := []struct {
+		name string
+		got  []model.Category
+		want string
+	}{
+		{"Categories", tree.Categories(), "Books, Electronics, Electronics/Laptops, Electronics/Laptops/Gaming, Electronics/Phones"},
+		{"Children of Electronics", tree.Children(category(t, "Electronics")), "Electronics/Laptops, Electronics/Phones"},
+		{"Subtree of Electronics/Laptops", tree.Subtree(category(t, "Electronics/Laptops")), "Electronics/Laptops, Electronics/Laptops/Gaming"},
+	}
+	for _, tt := range queries {
+		if got := paths(tt.got); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if err := tree.Remove(category(t, "Electronics/Laptops")); !isInvalid(err) {
+		t.Errorf("removing a category with subcategories = %v, want a ValidationError", err)
+	}
+	if err := tree.Remove(category(t, "electronics/laptops/gaming")); err != nil || tree.Has(category(t, "Electronics/Laptops/Gaming")) {
+		t.Errorf("removing one without = %v, want it removed", err)
+	}
+}
+
+func TestCategoryTreeMove(t *testing.T) {
+	tree := electronicsAndBooks(t)
+	books := category(t, "Books")
+	moved, err := tree.Move(category(t, "electronics/laptops"), &books)
+	if err != nil || moved.Path() != "Books/Laptops" {
+		t.Fatalf("Move under Books = %q, %v, want Books/Laptops, in the case it was added with", moved.Path(), err)
+	}
+	if got, want := paths(tree.Categories()), "Books, Books/Laptops, Books/Laptops/Gaming, Electronics, Electronics/Phones"; got != want {
+		t.Errorf("after the move, the tree is %s, want its subcategories moved with it: %s", got, want)
+	}
+	if moved, err = tree.Move(category(t, "Books/Laptops"), nil); err != nil || moved.Path() != "Laptops" || !tree.Has(category(t, "Laptops/Gaming")) {
+		t.Errorf("Move to the top = %q, %v, want Laptops, with its subcategories", moved.Path(), err)
+	}
+
+	if err := tree.Add(category(t, "Electronics/Laptops")); err != nil {
+		t.Fatal(err)
+	}
+	electronics := category(t, "Electronics")
+	if _, err := tree.Move(category(t, "Laptops"), &electronics); !isInvalid(err) || !tree.Has(category(t, "Laptops/Gaming")) {
+		t.Errorf("moving where a category of that name is = %v, want a ValidationError, and nothing moved", err)
+	}
+	if _, err := tree.Move(category(t, "Toys"), nil); !errors.Is(err, model.ErrCategoryNotFound) {
+		t.Errorf("moving an unknown category = %v, want %v", err, model.ErrCategoryNotFound)
+	}
+}
+
+func TestCategoryTreeRefusesCycles(t *testing.T) {
+	tree := electronicsAndBooks(t)
+	before := paths(tree.Categories())
+	cycles := []struct{ category, parent string }{
+		{"Electronics", "Electronics"},
+		{"Electronics", "Electronics/Laptops"},
+		{"Electronics", "Electronics/Laptops/Gaming"},
+		{"Electronics/Laptops", "electronics/laptops/gaming"},
+	}
+	for _, tt := range cycles {
+		parent := category(t, tt.parent)
+		if _, err := tree.Move(category(t, tt.category), &parent); !isInvalid(err) {
+			t.Errorf("moving %s under %s = %v, want a ValidationError", tt.category, tt.parent, err)
+		}
+		if after := paths(tree.Categories()); after != before {
+			t.Errorf("after refusing to move %s under %s, the tree is %s, want it unchanged", tt.category, tt.parent, after)
+		}
+	}
+}
+
+// TestCategoryTreeRandomChanges makes random changes to a tree, and checks
+// its invariants after each: every category's parent is in it, no two
+// categories are the same, and a refused change changes nothing
+func TestCategoryTreeRandomChanges(t *testing.T) {
+	random := rand.New(rand.NewSource(4901))
+	names := []string{"A", "B", "C", "D"}
+	tree := model.NewCategoryTree()
+	moves := 0
+	for i, all := 0, tree.Categories(); i < 5000; i++ {
+		before := paths(all)
+		var err error
+		var change string
+		switch op := random.Intn(3); {
+		case op == 0 || len(all) == 0:
+			added := category(t, names[random.Intn(len(names))])
+			if len(all) > 0 && random.Intn(4) > 0 {
+				added, _ = all[random.Intn(len(all))].Child(added.Name())
+			}
+			change, err = "adding "+added.Path(), tree.Add(added)
+		case op == 1:
+			from := all[random.Intn(len(all))]
+			var to *model.Category
+			change = "moving " + from.Path() + " to the top"
+			if random.Intn(5) > 0 {
+				to = &all[random.Intn(len(all))]
+				change = "moving " + from.Path() + " under " + to.Path()
+			}
+			if _, err = tree.Move(from, to); err == nil {
+				moves++
+			}
+		default:
+			removed := all[random.Intn(len(all))]
+			change, err = "removing "+removed.Path(), tree.Remove(removed)
+		}
+		all = tree.Categories()
+		if after := paths(all); err != nil && after != before {
+			t.Fatalf("change %d, %s, was refused (%v) but changed %s into %s", i, change, err, before, after)
+		}
+
+		seen := map[string]bool{}
+		for _, c := range all {
+			if parent, ok := c.Parent(); ok && !tree.Has(parent) {
+				t.Fatalf("after change %d, %s, %s has no parent in %s", i, change, c.Path(), paths(all))
+			}
+			if key := strings.ToLower(c.Path()); seen[key] {
+				t.Fatalf("after change %d, %s, %s is in the tree twice", i, change, c.Path())
+			} else {
+				seen[key] = true
+			}
+		}
+	}
+	if moves == 0 {
+		t.Error("of 5000 random changes, no move succeeded; the test moves nothing")
+	}
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Event is something that happened to a product. The aggregate records its
+// events as its methods change it; the application service dispatches them
+// once the change is saved (see Product.Events).
+type Event interface {
+	// EventName tells the kind of event, as handlers register for it
+	EventName() string
+	ProductID() ProductID
+	OccurredAt() time.Time
+}
+
+const (
+	ProductCreatedEvent      = "product.created"
+	PriceChangedEvent        = "product.price_changed"
+	ProductDiscontinuedEvent = "product.discontinued"
+)
+
+// ProductCreated is recorded by NewProduct
+type ProductCreated struct {
+	ID       ProductID
+	Name     string
+	Price    Money
+	Category Category
+	At       time.Time
+}
+
+func (e ProductCreated) EventName() string     { return ProductCreatedEvent }
+func (e ProductCreated) ProductID() ProductID  { return e.ID }
+func (e ProductCreated) OccurredAt() time.Time { return e.At }
+
+// PriceChanged is recorded by ChangePrice, and so by a discount
+type PriceChanged struct {
+	ID       ProductID
+	OldPrice Money
+	NewPrice Money
+	At       time.Time
+}
+
+func (e PriceChanged) EventName() string     { return PriceChangedEvent }
+func (e PriceChanged) ProductID() ProductID  { return e.ID }
+func (e PriceChanged) OccurredAt() time.Time { return e.At }
+
+// ProductDiscontinued is recorded by Discontinue
+type ProductDiscontinued struct {
+	ID ProductID
+	At time.Time
+}
+
+func (e ProductDiscontinued) EventName() string     { return ProductDiscontinuedEvent }
+func (e ProductDiscontinued) ProductID() ProductID  { return e.ID }
+func (e ProductDiscontinued) OccurredAt() time.Time { return e.At }
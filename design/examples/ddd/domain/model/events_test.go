@@ -0,0 +1,72 @@
+package model_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// eventNames lists the names of events, oldest first
+func eventNames(events []model.Event) string {
+	var out []string
+	for _, event := range events {
+		out = append(out, event.EventName())
+	}
+	return strings.Join(out, " ")
+}
+
+func TestProductEvents(t *testing.T) {
+	category, err := model.NewCategory("Books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := model.NewProduct("Go in Practice", "", usd(t, 40), category)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := product.Events()
+	if created, ok := events[0].(model.ProductCreated); len(events) != 1 || !ok || created.ID != product.ID() || created.Name != "Go in Practice" ||
+		created.Price != usd(t, 40) || created.Category != category || !created.At.Equal(product.CreatedAt()) {
+		t.Errorf("NewProduct recorded %+v, want ProductCreated with the product's id, name, price, category and creation time", events)
+	}
+
+	if err := product.ChangePrice(usd(t, 35)); err != nil {
+		t.Fatal(err)
+	}
+	if err := product.Discontinue(); err != nil {
+		t.Fatal(err)
+	}
+	events = product.Events()
+	if got, want := eventNames(events), "product.created product.price_changed product.discontinued"; got != want {
+		t.Fatalf("events = %s, want %s, in the order they happened", got, want)
+	}
+	if changed, ok := events[1].(model.PriceChanged); !ok || changed.ID != product.ID() || changed.OldPrice != usd(t, 40) || changed.NewPrice != usd(t, 35) {
+		t.Errorf("PriceChanged = %+v, want the old and the new price", events[1])
+	}
+	if events[2].ProductID() != product.ID() || !events[2].OccurredAt().Equal(product.UpdatedAt()) {
+		t.Errorf("ProductDiscontinued = %+v, want it at the product's last update", events[2])
+	}
+
+	var invalid model.ValidationError
+	if err := product.ChangePrice(usd(t, 30)); !errors.As(err, &invalid) || len(product.Events()) != 3 || product.Price() != usd(t, 35) {
+		t.Errorf("changing a discontinued product's price = %v, want a ValidationError and nothing recorded", err)
+	}
+	if err := product.Discontinue(); !errors.As(err, &invalid) || len(product.Events()) != 3 {
+		t.Errorf("discontinuing it again = %v, want a ValidationError and nothing recorded", err)
+	}
+
+	events[0] = nil
+	if product.Events()[0] == nil {
+		t.Error("changing what Events returned changed the product's events, want a copy")
+	}
+	product.ClearEvents()
+	if n := len(product.Events()); n != 0 {
+		t.Errorf("after ClearEvents, %d events, want none", n)
+	}
+	rebuilt := model.ReconstructProduct(product.ID(), "Go in Practice", "", usd(t, 35), category, product.CreatedAt(), product.UpdatedAt(), true, nil)
+	if len(rebuilt.Events()) != 0 || !rebuilt.Discontinued() {
+		t.Errorf("a reconstructed product has %d events, want none", len(rebuilt.Events()))
+	}
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrInsufficientStock is returned by Reserve when less is available than
+// asked for
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ReservationID names a reservation, such as the order it holds stock for
+type ReservationID string
+
+// Inventory is an aggregate root: the stock of one product. Stock on hand
+// is what is in the warehouse; part of it is reserved, for orders not yet
+// shipped, and the rest is available. Its invariants are that neither is
+// ever negative, and that no more is reserved than is on hand.
+//
+// It is an aggregate of its own rather than part of Product: stock changes
+// with every order, the catalog rarely, and the two are saved and locked
+// apart. Rules across them, such as no reservations for a discontinued
+// product, are the application service's (see application.InventoryService).
+type Inventory struct {
+	productID    ProductID
+	onHand       int
+	reservations map[ReservationID]int
+	// version is the one the inventory was loaded at, 0 for a new one: the
+	// repository refuses to save over a newer one
+	version int64
+}
+
+// NewInventory returns an empty inventory for the product
+func NewInventory(productID ProductID) *Inventory {
+	return &Inventory{productID: productID, reservations: map[ReservationID]int{}}
+}
+
+// ReconstructInventory rebuilds an inventory that was stored, at the version
+// it was stored at. It is for repositories only.
+func ReconstructInventory(productID ProductID, onHand int, reservations map[ReservationID]int, version int64) *Inventory {
+	inventory := NewInventory(productID)
+	inventory.onHand = onHand
+	for id, quantity := range reservations {
+		inventory.reservations[id] = quantity
+	}
+	inventory.version = version
+	return inventory
+}
+
+func (i *Inventory) ProductID() ProductID {
+	return i.productID
+}
+
+func (i *Inventory) OnHand() int {
+	return i.onHand
+}
+
+func (i *Inventory) Reserved() int {
+	reserved := 0
+	for _, quantity := range i.reservations {
+		reserved += quantity
+	}
+	return reserved
+}
+
+// Available is what can still be reserved
+func (i *Inventory) Available() int {
+	return i.onHand - i.Reserved()
+}
+
+func (i *Inventory) Version() int64 {
+	return i.version
+}
+
+// Reservations returns a copy of the reservations, by ID
+func (i *Inventory) Reservations() map[ReservationID]int {
+	reservations := make(map[ReservationID]int, len(i.reservations))
+	for id, quantity := range i.reservations {
+		reservations[id] = quantity
+	}
+	return reservations
+}
+
+// ReservationIDs returns the IDs of the reservations, sorted
+func (i *Inventory) ReservationIDs() []ReservationID {
+	ids := make([]ReservationID, 0, len(i.reservations))
+	for id := range i.reservations {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+	return ids
+}
+
+// Restock adds quantity to the stock on hand
+func (i *Inventory) Restock(quantity int) error {
+	if quantity <= 0 {
+		return ValidationError("quantity must be positive")
+	}
+	i.onHand += quantity
+	return nil
+}
+
+// Reserve sets quantity aside for id, from the available stock
+func (i *Inventory) Reserve(id ReservationID, quantity int) error {
+	if id == "" {
+		return ValidationError("reservation id cannot be empty")
+	}
+	if quantity <= 0 {
+		return ValidationError("quantity must be positive")
+	}
+	if _, ok := i.reservations[id]; ok {
+		return ValidationError("reservation already exists")
+	}
+	if quantity > i.Available() {
+		return ErrInsufficientStock
+	}
+	i.reservations[id] = quantity
+	return nil
+}
+
+// Release gives the stock reserved for id back, as when an order is
+// cancelled
+func (i *Inventory) Release(id ReservationID) error {
+	if _, ok := i.reservations[id]; !ok {
+		return ValidationError("no such reservation")
+	}
+	delete(i.reservations, id)
+	return nil
+}
+
+// Commit takes the stock reserved for id off hand, as when an order ships
+func (i *Inventory) Commit(id ReservationID) error {
+	quantity, ok := i.reservations[id]
+	if !ok {
+		return ValidationError("no such reservation")
+	}
+	delete(i.reservations, id)
+	i.onHand -= quantity
+	return nil
+}
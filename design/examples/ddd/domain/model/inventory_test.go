@@ -0,0 +1,63 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// isInvalid reports whether err is a ValidationError
+func isInvalid(err error) bool {
+	var invalid model.ValidationError
+	return errors.As(err, &invalid)
+}
+
+func TestInventory(t *testing.T) {
+	inventory := model.NewInventory(model.NewProductID())
+	if err := inventory.Restock(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.Restock(0); !isInvalid(err) {
+		t.Errorf("Restock(0) = %v, want a ValidationError", err)
+	}
+	if err := inventory.Reserve("order-1", 6); err != nil {
+		t.Fatal(err)
+	}
+	if inventory.OnHand() != 10 || inventory.Reserved() != 6 || inventory.Available() != 4 {
+		t.Errorf("after reserving 6 of 10: on hand %d, reserved %d, available %d, want 10, 6, 4",
+			inventory.OnHand(), inventory.Reserved(), inventory.Available())
+	}
+	if err := inventory.Reserve("order-2", 5); !errors.Is(err, model.ErrInsufficientStock) || inventory.Available() != 4 {
+		t.Errorf("reserving 5 of the 4 available = %v, want %v and nothing reserved", err, model.ErrInsufficientStock)
+	}
+	if err := inventory.Reserve("order-1", 1); !isInvalid(err) {
+		t.Errorf("reserving again for order-1 = %v, want a ValidationError", err)
+	}
+	if err := inventory.Reserve("order-3", -1); !isInvalid(err) {
+		t.Errorf("reserving -1 = %v, want a ValidationError", err)
+	}
+
+	if err := inventory.Reserve("order-2", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.Commit("order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if inventory.OnHand() != 4 || inventory.Reserved() != 4 || inventory.Available() != 0 {
+		t.Errorf("after committing order-1: on hand %d, reserved %d, available %d, want 4, 4, 0",
+			inventory.OnHand(), inventory.Reserved(), inventory.Available())
+	}
+	if err := inventory.Release("order-2"); err != nil {
+		t.Fatal(err)
+	}
+	if inventory.OnHand() != 4 || inventory.Available() != 4 {
+		t.Errorf("after releasing order-2: on hand %d, available %d, want 4, 4", inventory.OnHand(), inventory.Available())
+	}
+	if err := inventory.Commit("order-2"); !isInvalid(err) {
+		t.Errorf("committing a released reservation = %v, want a ValidationError", err)
+	}
+	if err := inventory.Release("order-1"); !isInvalid(err) {
+		t.Errorf("releasing a committed reservation = %v, want a ValidationError", err)
+	}
+}
@@ -0,0 +1,203 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// Money is a value object: an amount of a currency, kept in the currency's
+// minor units (cents for USD, yen for JPY) so that adding and subtracting
+// never drift the way floats do. Where an amount must be rounded, by
+// NewMoney or Multiply, it is rounded half to even (banker's rounding), so
+// that rounding many amounts does not bias their sum upward.
+//
+// Money is never negative. Amounts of different currencies are not added,
+// subtracted or compared.
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// zeroDecimalCurrencies have no minor unit: an amount is whole units.
+// Every other currency has two decimals.
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true, "VND": true, "CLP": true, "ISK": true}
+
+// scale is how many minor units make one unit of the currency
+func scale(currency string) float64 {
+	if zeroDecimalCurrencies[currency] {
+		return 1
+	}
+	return 100
+}
+
+// NewMoney returns amount units of currency, rounded half to even to the
+// currency's minor unit: 0.125 USD is 0.12, and 0.135 USD is 0.14
+func NewMoney(amount float64, currency string) (Money, error) {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}, ValidationError("money amount must be a number")
+	}
+	if amount < 0 {
+		return Money{}, ValidationError("money amount cannot be negative")
+	}
+	minor, err := round(amount * scale(currency))
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// NewMoneyFromMinor returns minor units of currency: 1999 USD cents is
+// 19.99 USD
+func NewMoneyFromMinor(minor int64, currency string) (Money, error) {
+	if minor < 0 {
+		return Money{}, ValidationError("money amount cannot be negative")
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// round rounds x half to even. The decimal x is written in is what is
+// rounded, not its nearest float: 2.675 USD is 267.49999... cents as a
+// float, but 267.5 as written, and rounds to 268.
+func round(x float64) (int64, error) {
+	if x >= math.MaxInt64 {
+		return 0, ValidationError("money amount is too large")
+	}
+	// Snap to the nearest millionth of a minor unit first, which takes out
+	// the float error of multiplying a decimal by the scale
+	snapped := math.Round(x*1e6) / 1e6
+	return int64(math.RoundToEven(snapped)), nil
+}
+
+// Amount is the amount in units of the currency. It is for display and for
+// other contexts; arithmetic is Money's own.
+func (m Money) Amount() float64 {
+	return float64(m.minor) / scale(m.currency)
+}
+
+// Minor is the amount in minor units of the currency
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+func (m Money) Currency() string {
+	return m.currency
+}
+
+func (m Money) IsZero() bool {
+	return m.minor == 0
+}
+
+// String writes m with the currency's decimals, as "19.99 USD"
+func (m Money) String() string {
+	if scale(m.currency) == 1 {
+		return fmt.Sprintf("%d %s", m.minor, m.currency)
+	}
+	return fmt.Sprintf("%d.%02d %s", m.minor/100, m.minor%100, m.currency)
+}
+
+func (m Money) sameCurrency(other Money) error {
+	if m.currency != other.currency {
+		return ValidationError(fmt.Sprintf("cannot combine %s with %s", m.currency, other.currency))
+	}
+	return nil
+}
+
+// Add returns m plus other, of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	if m.minor > math.MaxInt64-other.minor {
+		return Money{}, ValidationError("money amount is too large")
+	}
+	return Money{minor: m.minor + other.minor, currency: m.currency}, nil
+}
+
+// Subtract returns m less other, of the same currency. As Money is never
+// negative, other cannot be more than m.
+func (m Money) Subtract(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	if other.minor > m.minor {
+		return Money{}, ValidationError("money amount cannot be negative")
+	}
+	return Money{minor: m.minor - other.minor, currency: m.currency}, nil
+}
+
+// Multiply returns m times factor, rounded half to even to the minor unit,
+// as for a share or a rate: 10.05 USD times 0.5 is 5.02
+func (m Money) Multiply(factor float64) (Money, error) {
+	if math.IsNaN(factor) || math.IsInf(factor, 0) || factor < 0 {
+		return Money{}, ValidationError("money can only be multiplied by a positive number")
+	}
+	minor, err := round(float64(m.minor) * factor)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: m.currency}, nil
+}
+
+// Compare returns -1, 0 or 1 as m is less than, equal to or more than
+// other, of the same currency
+func (m Money) Compare(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.minor < other.minor:
+		return -1, nil
+	case m.minor > other.minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Equals reports whether m and other are the same amount of the same
+// currency
+func (m Money) Equals(other Money) bool {
+	return m == other
+}
+
+// Allocate splits m in parts proportional to ratios, losing nothing: the
+// parts add up to m exactly. Each part gets its proportion rounded down,
+// and the minor units left over go one each to the parts that lost the
+// most to rounding, the first of them on a tie. 0.05 USD split 1:1 is 0.03
+// and 0.02; 100 USD split 1:1:1 is 33.34, 33.33 and 33.33.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, ValidationError("allocation needs at least one ratio")
+	}
+	total := int64(0)
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, ValidationError("allocation ratios cannot be negative")
+		}
+		total += int64(ratio)
+	}
+	if total == 0 {
+		return nil, ValidationError("allocation ratios cannot all be zero")
+	}
+
+	parts := make([]Money, len(ratios))
+	remainders := make([]int64, len(ratios))
+	left := m.minor
+	for i, ratio := range ratios {
+		// minor*ratio/total, without overflowing on large amounts
+		share := m.minor/total*int64(ratio) + m.minor%total*int64(ratio)/total
+		remainders[i] = m.minor % total * int64(ratio) % total
+		parts[i] = Money{minor: share, currency: m.currency}
+		left -= share
+	}
+	for ; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		parts[largest].minor++
+		remainders[largest] = -1
+	}
+	return parts, nil
+}
@@ -0,0 +1,221 @@
+package model_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// usd returns amount in US dollars
+func usd(t *testing.T, amount float64) model.Money {
+	t.Helper()
+	money, err := model.NewMoney(amount, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return money
+}
+
+// cents returns minor cents of US dollars
+func cents(t *testing.T, minor int64) model.Money {
+	t.Helper()
+	money, err := model.NewMoneyFromMinor(minor, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return money
+}
+
+func TestNewMoney(t *testing.T) {
+	amounts := []struct {
+		amount   float64
+		currency string
+		minor    int64
+		written  string
+	}{
+		{19.99, "USD", 1999, "19.99 USD"},
+		{0.1 + 0.2, "USD", 30, "0.30 USD"},
+		{999.99, "EUR", 99999, "999.99 EUR"},
+		{0.125, "USD", 12, "0.12 USD"},    // half to even: down to 12
+		{0.135, "USD", 14, "0.14 USD"},    // up to 14
+		{2.675, "USD", 268, "2.68 USD"},   // 267.4999... as a float, 267.5 as written
+		{1.005, "USD", 100, "1.00 USD"},   // 100.5, to even
+		{0.0049, "USD", 0, "0.00 USD"},    // below half a cent
+		{1500, "JPY", 1500, "1500 JPY"},   // yen have no minor unit
+		{1500.5, "JPY", 1500, "1500 JPY"}, // so halves go to the even yen
+		{0, "USD", 0, "0.00 USD"},
+	}
+	for _, tt := range amounts {
+		m, err := model.NewMoney(tt.amount, tt.currency)
+		if err != nil || m.Minor() != tt.minor || m.String() != tt.written {
+			t.Errorf("NewMoney(%v, %s) = %d minor units, %q, %v, want %d, %q", tt.amount, tt.currency, m.Minor(), m, err, tt.minor, tt.written)
+		}
+	}
+	for _, bad := range []float64{-0.01, math.NaN(), math.Inf(1), 1e30} {
+		if _, err := model.NewMoney(bad, "USD"); !isInvalid(err) {
+			t.Errorf("NewMoney(%v) = %v, want a ValidationError", bad, err)
+		}
+	}
+	if _, err := model.NewMoneyFromMinor(-1, "USD"); !isInvalid(err) {
+		t.Errorf("NewMoneyFromMinor(-1) = %v, want a ValidationError", err)
+	}
+	if m := cents(t, 1999); m.Amount() != 19.99 || m != usd(t, 19.99) {
+		t.Errorf("1999 cents = %v, want 19.99 USD, the same Money", m)
+	}
+}
+
+// TestMoneyRoundTrip takes every amount from 0.00 to 1000.00 to its cents,
+// and back
+func TestMoneyRoundTrip(t *testing.T) {
+	for minor := int64(0); minor <= 100000; minor++ {
+		m := usd(t, float64(minor)/100)
+		if back := usd(t, m.Amount()); m.Minor() != minor || back != m {
+			t.Fatalf("%d cents became %d, and back %v", minor, m.Minor(), back)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	if sum, err := usd(t, 0.1).Add(usd(t, 0.2)); err != nil || sum != usd(t, 0.3) {
+		t.Errorf("0.10 + 0.20 = %v, %v, want 0.30", sum, err)
+	}
+	total := usd(t, 0)
+	for i := 0; i < 1000; i++ {
+		total, _ = total.Add(usd(t, 0.01))
+	}
+	if total != usd(t, 10) {
+		t.Errorf("a cent added a thousand times = %v, want 10.00", total)
+	}
+	if diff, err := usd(t, 10).Subtract(usd(t, 0.01)); err != nil || diff.Minor() != 999 {
+		t.Errorf("10.00 - 0.01 = %v, %v, want 9.99", diff, err)
+	}
+	if diff, err := usd(t, 5).Subtract(usd(t, 5)); err != nil || !diff.IsZero() {
+		t.Errorf("5.00 - 5.00 = %v, %v, want zero", diff, err)
+	}
+	if _, err := usd(t, 1).Subtract(usd(t, 1.01)); !isInvalid(err) {
+		t.Errorf("1.00 - 1.01 = %v, want a ValidationError: money is never negative", err)
+	}
+	if _, err := cents(t, math.MaxInt64).Add(cents(t, 1)); !isInvalid(err) {
+		t.Errorf("an overflowing sum = %v, want a ValidationError", err)
+	}
+
+	eur, err := model.NewMoney(1, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errAdd := usd(t, 1).Add(eur)
+	_, errSubtract := usd(t, 1).Subtract(eur)
+	_, errCompare := usd(t, 1).Compare(eur)
+	if !isInvalid(errAdd) || !isInvalid(errSubtract) || !isInvalid(errCompare) {
+		t.Errorf("USD and EUR: Add %v, Subtract %v, Compare %v, want ValidationErrors", errAdd, errSubtract, errCompare)
+	}
+
+	less, _ := usd(t, 1.99).Compare(usd(t, 2))
+	more, _ := usd(t, 2.01).Compare(usd(t, 2))
+	same, _ := usd(t, 2).Compare(cents(t, 200))
+	if less != -1 || more != 1 || same != 0 {
+		t.Errorf("Compare = %d, %d, %d, want -1, 1, 0", less, more, same)
+	}
+	if !usd(t, 2).Equals(cents(t, 200)) || usd(t, 1).Equals(eur) {
+		t.Error("Equals does not compare both the amount and the currency")
+	}
+}
+
+func TestMoneyMultiply(t *testing.T) {
+	products := []struct {
+		minor  int64
+		factor float64
+		want   int64
+	}{
+		{1005, 0.5, 502}, // 502.5 to even
+		{1015, 0.5, 508}, // 507.5 to even
+		{999, 3, 2997},
+		{99999, 0.1, 10000}, // 9999.9
+		{1000, 0, 0},
+		{333, 1.0 / 3, 111},
+	}
+	for _, tt := range products {
+		if m, err := cents(t, tt.minor).Multiply(tt.factor); err != nil || m.Minor() != tt.want {
+			t.Errorf("%d cents × %.4g = %d, %v, want %d", tt.minor, tt.factor, m.Minor(), err, tt.want)
+		}
+	}
+	if _, err := usd(t, 1).Multiply(-1); !isInvalid(err) {
+		t.Errorf("Multiply(-1) = %v, want a ValidationError", err)
+	}
+
+	// halving every odd amount up to 100.00 rounds as often down as up
+	bias := int64(0)
+	for minor := int64(1); minor <= 10000; minor += 2 {
+		half, _ := cents(t, minor).Multiply(0.5)
+		bias += half.Minor()*2 - minor
+	}
+	if bias != 0 {
+		t.Errorf("halving the odd amounts up to 100.00 is off by %d half-cents in all, want no bias", bias)
+	}
+}
+
+// allocate splits m by ratios, in minor units
+func allocate(t *testing.T, m model.Money, ratios ...int) []int64 {
+	t.Helper()
+	split, err := m.Allocate(ratios...)
+	if err != nil {
+		t.Fatalf("%v.Allocate(%v): %v", m, ratios, err)
+	}
+	var parts []int64
+	for _, part := range split {
+		parts = append(parts, part.Minor())
+	}
+	return parts
+}
+
+func TestAllocate(t *testing.T) {
+	splits := []struct {
+		name   string
+		money  model.Money
+		ratios []int
+		want   []int64
+	}{
+		{"100.00 in three", usd(t, 100), []int{1, 1, 1}, []int64{3334, 3333, 3333}},
+		{"0.05 in two", usd(t, 0.05), []int{1, 1}, []int64{3, 2}},
+		{"0.05 as 30:70, the cent where most was lost", usd(t, 0.05), []int{3, 7}, []int64{2, 3}},
+		{"10.00 with a ratio of 0", usd(t, 10), []int{1, 0, 1}, []int64{500, 0, 500}},
+		{"a cent in three", usd(t, 0.01), []int{1, 1, 1}, []int64{1, 0, 0}},
+		{"the largest amount in two", cents(t, math.MaxInt64), []int{1, 1}, []int64{math.MaxInt64/2 + 1, math.MaxInt64 / 2}},
+	}
+	for _, tt := range splits {
+		if got := allocate(t, tt.money, tt.ratios...); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	for _, bad := range [][]int{nil, {0, 0}, {1, -1}} {
+		if _, err := usd(t, 1).Allocate(bad...); !isInvalid(err) {
+			t.Errorf("Allocate(%v) = %v, want a ValidationError", bad, err)
+		}
+	}
+}
+
+// TestAllocateSweep splits every amount up to 100.00 in ratios up to
+// 1:2:3:4: the parts add up to it, each within a cent of its exact share
+func TestAllocateSweep(t *testing.T) {
+	for _, ratios := range [][]int{{1}, {1, 1}, {1, 2}, {1, 1, 1}, {3, 7}, {1, 2, 3, 4}, {0, 5, 1}} {
+		total := 0
+		for _, r := range ratios {
+			total += r
+		}
+		for minor := int64(0); minor <= 10000; minor++ {
+			parts := allocate(t, cents(t, minor), ratios...)
+			sum := int64(0)
+			for i, part := range parts {
+				sum += part
+				if exact := float64(minor) * float64(ratios[i]) / float64(total); math.Abs(float64(part)-exact) >= 1 {
+					t.Fatalf("%d cents as %v = %v: part %d is not within a cent of %.2f", minor, ratios, parts, i, exact)
+				}
+			}
+			if sum != minor {
+				t.Fatalf("%d cents as %v = %v, adding up to %d", minor, ratios, parts, sum)
+			}
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package model
 
 import (
-"errors"
 "time"
 
 "github.com/google/uuid"
@@ -16,6 +15,22 @@ type Product struct {
 	category    Category
 	createdAt   time.Time
 	updatedAt   time.Time
+	// discontinued products are no longer sold: their price is fixed
+	discontinued bool
+	// variants are in the order they were added, retired ones included.
+	// Methods replace the slice rather than change it, so a copy of the
+	// product, as the memory repository keeps, never sees their changes.
+	variants []Variant
+	// events are those recorded since the product was loaded or created
+	events []Event
+}
+
+// ValidationError is a rule of the model that a value breaks. Callers tell
+// it from other errors with errors.As, to report it as the caller's mistake.
+type ValidationError string
+
+func (e ValidationError) Error() string {
+	return string(e)
 }
 
 type ProductID struct {
@@ -26,55 +41,28 @@ func NewProductID() ProductID {
 	return ProductID{value: uuid.New().String()}
 }
 
-func (id ProductID) String() string {
-	return id.value
-}
-
-// Money is a value object
-type Money struct {
-	amount   float64
-	currency string
-}
-
-func NewMoney(amount float64, currency string) (Money, error) {
-	if amount < 0 {
-		return Money{}, errors.New("money amount cannot be negative")
+// ParseProductID returns the ProductID written as s, as it comes back from
+// storage or a request
+func ParseProductID(s string) (ProductID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return ProductID{}, ValidationError("product id must be a UUID")
 	}
-	return Money{amount: amount, currency: currency}, nil
-}
-
-func (m Money) Amount() float64 {
-	return m.amount
+	return ProductID{value: id.String()}, nil
 }
 
-func (m Money) Currency() string {
-	return m.currency
-}
-
-// Category is a value object
-type Category struct {
-	name string
-}
-
-func NewCategory(name string) (Category, error) {
-	if name == "" {
-		return Category{}, errors.New("category name cannot be empty")
-	}
-	return Category{name: name}, nil
-}
-
-func (c Category) Name() string {
-	return c.name
+func (id ProductID) String() string {
+	return id.value
 }
 
 // NewProduct creates a new product aggregate
 func NewProduct(name, description string, price Money, category Category) (*Product, error) {
 	if name == "" {
-		return nil, errors.New("product name cannot be empty")
+		return nil, ValidationError("product name cannot be empty")
 	}
 
 	now := time.Now()
-	return &Product{
+	product := &Product{
 		id:          NewProductID(),
 		name:        name,
 		description: description,
@@ -82,7 +70,27 @@ func NewProduct(name, description string, price Money, category Category) (*Prod
 		category:    category,
 		createdAt:   now,
 		updatedAt:   now,
-	}, nil
+	}
+	product.record(ProductCreated{ID: product.id, Name: name, Price: price, Category: category, At: now})
+	return product, nil
+}
+
+// ReconstructProduct rebuilds a product that was stored, with its identity,
+// timestamps and variants as they were, and no events. It is for
+// repositories only: it makes no checks, since the product passed them when
+// it was created.
+func ReconstructProduct(id ProductID, name, description string, price Money, category Category, createdAt, updatedAt time.Time, discontinued bool, variants []Variant) *Product {
+	return &Product{
+		id:           id,
+		name:         name,
+		description:  description,
+		price:        price,
+		category:     category,
+		createdAt:    createdAt,
+		updatedAt:    updatedAt,
+		discontinued: discontinued,
+		variants:     append([]Variant(nil), variants...),
+	}
 }
 
 func (p *Product) ID() ProductID {
@@ -113,23 +121,177 @@ func (p *Product) UpdatedAt() time.Time {
 	return p.updatedAt
 }
 
+func (p *Product) Discontinued() bool {
+	return p.discontinued
+}
+
+// Events returns the events recorded since the product was loaded or
+// created, oldest first
+func (p *Product) Events() []Event {
+	return append([]Event(nil), p.events...)
+}
+
+// ClearEvents forgets the recorded events, once they are dispatched
+func (p *Product) ClearEvents() {
+	p.events = nil
+}
+
+func (p *Product) record(event Event) {
+	p.events = append(p.events, event)
+}
+
 // ChangePrice is a domain method
 func (p *Product) ChangePrice(newPrice Money) error {
-	if newPrice.amount <= 0 {
-		return errors.New("price must be positive")
+	if p.discontinued {
+		return ValidationError("a discontinued product keeps its price")
+	}
+	if newPrice.minor <= 0 {
+		return ValidationError("price must be positive")
 	}
+	if len(p.variants) > 0 && newPrice.currency != p.price.currency {
+		return ValidationError("a product with variants keeps its currency")
+	}
+	old := p.price
 	p.price = newPrice
 	p.updatedAt = time.Now()
+	p.record(PriceChanged{ID: p.id, OldPrice: old, NewPrice: newPrice, At: p.updatedAt})
 	return nil
 }
 
 // UpdateInfo updates product information
 func (p *Product) UpdateInfo(name, description string) error {
 	if name == "" {
-		return errors.New("product name cannot be empty")
+		return ValidationError("product name cannot be empty")
 	}
 	p.name = name
 	p.description = description
 	p.updatedAt = time.Now()
 	return nil
 }
+
+// ChangeCategory moves the product to category, as when its category moves
+// in the hierarchy. A discontinued product moves too: it stays where the
+// catalog puts it.
+func (p *Product) ChangeCategory(category Category) {
+	p.category = category
+	p.updatedAt = time.Now()
+}
+
+// Discontinue takes the product off sale
+func (p *Product) Discontinue() error {
+	if p.discontinued {
+		return ValidationError("product is already discontinued")
+	}
+	p.discontinued = true
+	p.updatedAt = time.Now()
+	p.record(ProductDiscontinued{ID: p.id, At: p.updatedAt})
+	return nil
+}
+
+// Variants returns the product's variants in the order they were added,
+// retired ones included
+func (p *Product) Variants() []Variant {
+	return append([]Variant(nil), p.variants...)
+}
+
+// Variant returns the variant with sku
+func (p *Product) Variant(sku SKU) (Variant, error) {
+	i := p.variantIndex(sku)
+	if i < 0 {
+		return Variant{}, ErrVariantNotFound
+	}
+	return p.variants[i], nil
+}
+
+func (p *Product) variantIndex(sku SKU) int {
+	for i, variant := range p.variants {
+		if variant.sku == sku {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddVariant adds a variant of the product with its own SKU, price and
+// attributes. The product's invariants hold whatever the calls:
+//   - a SKU is never used twice, even once its variant is retired;
+//   - variants are priced in the product's currency, above zero;
+//   - no two variants on sale have the same attributes, so a customer can
+//     tell them apart;
+//   - a discontinued product gets no new variant.
+func (p *Product) AddVariant(sku SKU, price Money, attributes map[string]string) error {
+	if p.discontinued {
+		return ValidationError("a discontinued product gets no new variant")
+	}
+	if sku == (SKU{}) {
+		return ValidationError("a variant needs a SKU")
+	}
+	if p.variantIndex(sku) >= 0 {
+		return ValidationError("SKU " + sku.String() + " is already used by this product")
+	}
+	if err := p.checkVariantPrice(price); err != nil {
+		return err
+	}
+	attributes, err := newAttributes(attributes)
+	if err != nil {
+		return err
+	}
+	variant := Variant{sku: sku, price: price, attributes: attributes}
+	for _, other := range p.variants {
+		if !other.retired && other.sameAttributes(variant) {
+			return ValidationError("variant " + other.sku.String() + " already has " + describe(attributes))
+		}
+	}
+	p.variants = append(p.Variants(), variant)
+	p.updatedAt = time.Now()
+	return nil
+}
+
+// ChangeVariantPrice sets the price of a variant on sale
+func (p *Product) ChangeVariantPrice(sku SKU, price Money) error {
+	if p.discontinued {
+		return ValidationError("a discontinued product keeps its price")
+	}
+	i := p.variantIndex(sku)
+	if i < 0 {
+		return ErrVariantNotFound
+	}
+	if p.variants[i].retired {
+		return ValidationError("variant " + sku.String() + " is retired")
+	}
+	if err := p.checkVariantPrice(price); err != nil {
+		return err
+	}
+	variants := p.Variants()
+	variants[i].price = price
+	p.variants = variants
+	p.updatedAt = time.Now()
+	return nil
+}
+
+// RetireVariant takes a variant off sale. It stays with the product, and
+// its SKU is not used again.
+func (p *Product) RetireVariant(sku SKU) error {
+	i := p.variantIndex(sku)
+	if i < 0 {
+		return ErrVariantNotFound
+	}
+	if p.variants[i].retired {
+		return ValidationError("variant " + sku.String() + " is already retired")
+	}
+	variants := p.Variants()
+	variants[i].retired = true
+	p.variants = variants
+	p.updatedAt = time.Now()
+	return nil
+}
+
+func (p *Product) checkVariantPrice(price Money) error {
+	if price.currency != p.price.currency {
+		return ValidationError("a variant is priced in its product's currency, " + p.price.currency)
+	}
+	if price.minor <= 0 {
+		return ValidationError("price must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+func TestParseProductID(t *testing.T) {
+	id := model.NewProductID()
+	if parsed, err := model.ParseProductID(id.String()); err != nil || parsed != id {
+		t.Errorf("ParseProductID(%q) = %v, %v, want the same id", id, parsed, err)
+	}
+	if _, err := model.ParseProductID("not-a-uuid"); err == nil {
+		t.Error("ParseProductID of an id that is not a UUID = nil, want an error")
+	}
+}
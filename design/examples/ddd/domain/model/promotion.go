@@ -0,0 +1,191 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PromotionID struct {
+	value string
+}
+
+func NewPromotionID() PromotionID {
+	return PromotionID{value: uuid.New().String()}
+}
+
+func (id PromotionID) String() string {
+	return id.value
+}
+
+// PromotionKind is how a promotion takes money off
+type PromotionKind string
+
+const (
+	// PercentageOff takes a percentage off what is left to pay
+	PercentageOff PromotionKind = "percentage_off"
+	// AmountOff takes a fixed amount off each unit
+	AmountOff PromotionKind = "amount_off"
+	// BuyXGetY gives Y units free for every X bought
+	BuyXGetY PromotionKind = "buy_x_get_y"
+)
+
+// Scope is the products a promotion applies to: every product, those of a
+// category and its subcategories, or one product
+type Scope struct {
+	category  Category
+	productID ProductID
+}
+
+func AllProducts() Scope                 { return Scope{} }
+func InCategory(category Category) Scope { return Scope{category: category} }
+func ForProduct(id ProductID) Scope      { return Scope{productID: id} }
+
+// Covers reports whether the scope includes product
+func (s Scope) Covers(product *Product) bool {
+	switch {
+	case s.productID != ProductID{}:
+		return product.ID() == s.productID
+	case s.category != Category{}:
+		return s.category.Contains(product.Category())
+	}
+	return true
+}
+
+// Period is when a promotion is in effect: from its start, included, to its
+// end, excluded. A zero start or end leaves that side open.
+type Period struct {
+	from  time.Time
+	until time.Time
+}
+
+func NewPeriod(from, until time.Time) (Period, error) {
+	if !from.IsZero() && !until.IsZero() && !until.After(from) {
+		return Period{}, ValidationError("a promotion must end after it starts")
+	}
+	return Period{from: from, until: until}, nil
+}
+
+// Always is a period with no start and no end
+func Always() Period { return Period{} }
+
+func (p Period) From() time.Time  { return p.from }
+func (p Period) Until() time.Time { return p.until }
+
+// Contains reports whether t is in the period
+func (p Period) Contains(t time.Time) bool {
+	return (p.from.IsZero() || !t.Before(p.from)) && (p.until.IsZero() || t.Before(p.until))
+}
+
+// PromotionTerms are what every kind of promotion has: what it covers, when,
+// and how it combines with others. A stackable promotion combines with the
+// other stackable ones that apply, in order of Priority, highest first; an
+// exclusive one applies alone. Priority also settles a tie between
+// promotions worth the same.
+type PromotionTerms struct {
+	Scope     Scope
+	Period    Period
+	Stackable bool
+	Priority  int
+}
+
+// Promotion is an entity: a pricing rule, with an identity so it can be
+// changed or ended. PricingService decides which promotions apply to a
+// sale; each promotion only knows what it is worth.
+type Promotion struct {
+	id      PromotionID
+	name    string
+	kind    PromotionKind
+	percent float64 // PercentageOff
+	amount  Money   // AmountOff, per unit
+	buy     int     // BuyXGetY
+	get     int     // BuyXGetY
+	terms   PromotionTerms
+}
+
+func newPromotion(name string, kind PromotionKind, terms PromotionTerms) (*Promotion, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ValidationError("promotion name cannot be empty")
+	}
+	return &Promotion{id: NewPromotionID(), name: name, kind: kind, terms: terms}, nil
+}
+
+// NewPercentageOff takes percent, from 0 to 100 excluded, off the price
+func NewPercentageOff(name string, percent float64, terms PromotionTerms) (*Promotion, error) {
+	if !(percent > 0 && percent < 100) {
+		return nil, ValidationError("a percentage off must be between 0 and 100")
+	}
+	p, err := newPromotion(name, PercentageOff, terms)
+	if err != nil {
+		return nil, err
+	}
+	p.percent = percent
+	return p, nil
+}
+
+// NewAmountOff takes amount off each unit, down to nothing but no further.
+// It only applies to prices in its currency.
+func NewAmountOff(name string, amount Money, terms PromotionTerms) (*Promotion, error) {
+	if amount.IsZero() {
+		return nil, ValidationError("an amount off must be positive")
+	}
+	p, err := newPromotion(name, AmountOff, terms)
+	if err != nil {
+		return nil, err
+	}
+	p.amount = amount
+	return p, nil
+}
+
+// NewBuyXGetY gives get units free for every buy units paid for: buy 2 get
+// 1 makes every third unit free
+func NewBuyXGetY(name string, buy, get int, terms PromotionTerms) (*Promotion, error) {
+	if buy < 1 || get < 1 {
+		return nil, ValidationError("buy X get Y needs X and Y of at least 1")
+	}
+	p, err := newPromotion(name, BuyXGetY, terms)
+	if err != nil {
+		return nil, err
+	}
+	p.buy, p.get = buy, get
+	return p, nil
+}
+
+func (p *Promotion) ID() PromotionID       { return p.id }
+func (p *Promotion) Name() string          { return p.name }
+func (p *Promotion) Kind() PromotionKind   { return p.kind }
+func (p *Promotion) Terms() PromotionTerms { return p.terms }
+
+// AppliesTo reports whether the promotion covers product at t
+func (p *Promotion) AppliesTo(product *Product, t time.Time) bool {
+	if !p.terms.Period.Contains(t) || !p.terms.Scope.Covers(product) {
+		return false
+	}
+	return p.kind != AmountOff || p.amount.Currency() == product.Price().Currency()
+}
+
+// Discount is what the promotion takes off quantity units at unitPrice,
+// when remaining is what is left to pay after the promotions before it.
+// It is never more than remaining.
+func (p *Promotion) Discount(unitPrice Money, quantity int, remaining Money) (Money, error) {
+	var discount Money
+	var err error
+	switch p.kind {
+	case PercentageOff:
+		discount, err = remaining.Multiply(p.percent / 100)
+	case AmountOff:
+		discount, err = p.amount.Multiply(float64(quantity))
+	case BuyXGetY:
+		free := quantity / (p.buy + p.get) * p.get
+		discount, err = unitPrice.Multiply(float64(free))
+	}
+	if err != nil {
+		return Money{}, err
+	}
+	if more, err := discount.Compare(remaining); err != nil || more > 0 {
+		return remaining, err
+	}
+	return discount, nil
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"errors"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrVariantNotFound is returned for a SKU the product has no variant for
+var ErrVariantNotFound = errors.New("variant not found")
+
+// SKU is a value object: the stock keeping unit of a variant, such as
+// "LAP-13-SLV". It is kept in upper case: groups of letters and digits,
+// joined by single hyphens, 3 to 32 characters in all.
+type SKU struct {
+	value string
+}
+
+var skuFormat = regexp.MustCompile(`^[A-Z0-9]+(-[A-Z0-9]+)*$`)
+
+// NewSKU returns the SKU written as s, in any case
+func NewSKU(s string) (SKU, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	if len(value) < 3 || len(value) > 32 {
+		return SKU{}, ValidationError("a SKU has 3 to 32 characters")
+	}
+	if !skuFormat.MatchString(value) {
+		return SKU{}, ValidationError("a SKU is letters and digits, in groups joined by single hyphens")
+	}
+	return SKU{value: value}, nil
+}
+
+func (s SKU) String() string {
+	return s.value
+}
+
+// Variant is an entity within the Product aggregate: one version of the
+// product that is sold on its own, such as the laptop in silver with 16 GB,
+// with its own SKU and price. It is identified by its SKU, and changed only
+// through its product.
+type Variant struct {
+	sku        SKU
+	price      Money
+	attributes map[string]string
+	retired    bool
+}
+
+// ReconstructVariant rebuilds a variant that was stored, for repositories
+// to pass to ReconstructProduct. Like it, it makes no checks.
+func ReconstructVariant(sku SKU, price Money, attributes map[string]string, retired bool) Variant {
+	return Variant{sku: sku, price: price, attributes: maps.Clone(attributes), retired: retired}
+}
+
+func (v Variant) SKU() SKU {
+	return v.sku
+}
+
+func (v Variant) Price() Money {
+	return v.price
+}
+
+// Attributes returns what sets the variant apart, such as color: silver.
+// The map is a copy.
+func (v Variant) Attributes() map[string]string {
+	return maps.Clone(v.attributes)
+}
+
+// Attribute returns the value of one attribute, and false if the variant
+// has none by that name
+func (v Variant) Attribute(name string) (string, bool) {
+	value, ok := v.attributes[name]
+	return value, ok
+}
+
+// Retired reports whether the variant is no longer sold. It stays with its
+// product, so its SKU is never given to another variant.
+func (v Variant) Retired() bool {
+	return v.retired
+}
+
+// sameAttributes reports whether v and other would look the same to a
+// customer
+func (v Variant) sameAttributes(other Variant) bool {
+	return maps.Equal(v.attributes, other.attributes)
+}
+
+// newAttributes checks the attributes of a new variant, and returns them
+// with the spaces around names and values dropped
+func newAttributes(attributes map[string]string) (map[string]string, error) {
+	if len(attributes) == 0 {
+		return nil, ValidationError("a variant needs at least one attribute")
+	}
+	cleaned := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "" || value == "" {
+			return nil, ValidationError("a variant's attributes need a name and a value")
+		}
+		if _, ok := cleaned[name]; ok {
+			return nil, ValidationError("attribute " + name + " is given twice")
+		}
+		cleaned[name] = value
+	}
+	return cleaned, nil
+}
+
+// describe writes attributes as "color=silver, memory=16GB", by name
+func describe(attributes map[string]string) string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + attributes[name]
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,245 @@
+package model_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// sku returns the SKU s
+func sku(t *testing.T, s string) model.SKU {
+	t.Helper()
+	sku, err := model.NewSKU(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sku
+}
+
+// newLaptop returns a laptop at 1000.00 USD, without variants
+func newLaptop(t *testing.T) *model.Product {
+	t.Helper()
+	laptop, err := model.NewProduct("Laptop", "13 inch", usd(t, 1000), category(t, "Electronics/Laptops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return laptop
+}
+
+// skus lists a product's variants as SKU, or SKU* if retired
+func skus(product *model.Product) string {
+	var names []string
+	for _, variant := range product.Variants() {
+		name := variant.SKU().String()
+		if variant.Retired() {
+			name += "*"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestNewSKU(t *testing.T) {
+	valid := []struct{ in, want string }{
+		{"LAP-13-SLV", "LAP-13-SLV"},
+		{" lap-13-slv ", "LAP-13-SLV"},
+		{"A1B", "A1B"},
+		{strings.Repeat("X", 32), strings.Repeat("X", 32)},
+	}
+	for _, tt := range valid {
+		if got, err := model.NewSKU(tt.in); err != nil || got.String() != tt.want {
+			t.Errorf("NewSKU(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "AB", strings.Repeat("X", 33), "LAP 13", "LAP_13", "-LAP", "LAP-", "LAP--13", "LÄP-13"} {
+		if _, err := model.NewSKU(bad); !isInvalid(err) {
+			t.Errorf("NewSKU(%q) = %v, want a ValidationError", bad, err)
+		}
+	}
+}
+
+func TestAddVariant(t *testing.T) {
+	laptop := newLaptop(t)
+	silver := map[string]string{"color": "silver", "memory": "16GB"}
+	if err := laptop.AddVariant(sku(t, "LAP-13-SLV-16"), usd(t, 1100), silver); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.AddVariant(sku(t, "LAP-13-GRY-16"), usd(t, 1100), map[string]string{" color ": " gray ", "memory": "16GB"}); err != nil {
+		t.Fatal(err)
+	}
+	variant, err := laptop.Variant(sku(t, "lap-13-gry-16"))
+	if color, _ := variant.Attribute("color"); err != nil || color != "gray" || variant.Price() != usd(t, 1100) {
+		t.Errorf("Variant = %v, color %q, %v, want its own SKU, price and trimmed attributes", variant, color, err)
+	}
+	if got := skus(laptop); got != "LAP-13-SLV-16 LAP-13-GRY-16" {
+		t.Errorf("variants = %s, want them in the order they were added", got)
+	}
+
+	silver["color"] = "gold"
+	variant.Attributes()["color"] = "gold"
+	variant, _ = laptop.Variant(sku(t, "LAP-13-SLV-16"))
+	if color, _ := variant.Attribute("color"); color != "silver" {
+		t.Errorf("after changing the attributes passed in and returned, the color is %q, want silver", color)
+	}
+	variants := laptop.Variants()
+	variants[0] = variants[1]
+	if got := skus(laptop); got != "LAP-13-SLV-16 LAP-13-GRY-16" {
+		t.Errorf("after changing the slice Variants returned, variants = %s, want them unchanged", got)
+	}
+
+	eur, err := model.NewMoney(900, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := []struct {
+		name       string
+		sku        model.SKU
+		price      model.Money
+		attributes map[string]string
+	}{
+		{"a SKU the product already has", sku(t, "LAP-13-SLV-16"), usd(t, 900), map[string]string{"color": "blue"}},
+		{"no SKU", model.SKU{}, usd(t, 900), map[string]string{"color": "blue"}},
+		{"a price in another currency", sku(t, "LAP-13-BLU"), eur, map[string]string{"color": "blue"}},
+		{"a price of zero", sku(t, "LAP-13-BLU"), usd(t, 0), map[string]string{"color": "blue"}},
+		{"no attribute", sku(t, "LAP-13-BLU"), usd(t, 900), nil},
+		{"an attribute without a value", sku(t, "LAP-13-BLU"), usd(t, 900), map[string]string{"color": " "}},
+		{"an attribute without a name", sku(t, "LAP-13-BLU"), usd(t, 900), map[string]string{"": "blue"}},
+		{"an attribute given twice", sku(t, "LAP-13-BLU"), usd(t, 900), map[string]string{"color": "blue", "color ": "navy"}},
+		{"the attributes of a variant on sale", sku(t, "LAP-13-SLV-X"), usd(t, 900), map[string]string{"memory": "16GB", "color": "silver"}},
+	}
+	for _, tt := range refused {
+		if err := laptop.AddVariant(tt.sku, tt.price, tt.attributes); !isInvalid(err) || skus(laptop) != "LAP-13-SLV-16 LAP-13-GRY-16" {
+			t.Errorf("a variant with %s = %v, want a ValidationError, and no variant added", tt.name, err)
+		}
+	}
+}
+
+func TestRetireVariant(t *testing.T) {
+	laptop := newLaptop(t)
+	for _, v := range []struct{ sku, color string }{{"LAP-13-SLV-16", "silver"}, {"LAP-13-GRY-16", "gray"}} {
+		if err := laptop.AddVariant(sku(t, v.sku), usd(t, 1100), map[string]string{"color": v.color, "memory": "16GB"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := laptop.RetireVariant(sku(t, "LAP-99")); !errors.Is(err, model.ErrVariantNotFound) {
+		t.Errorf("retiring an unknown SKU = %v, want %v", err, model.ErrVariantNotFound)
+	}
+	if err := laptop.RetireVariant(sku(t, "LAP-13-SLV-16")); err != nil {
+		t.Fatal(err)
+	}
+	if got := skus(laptop); got != "LAP-13-SLV-16* LAP-13-GRY-16" {
+		t.Errorf("after retiring one, variants = %s, want it kept with its product", got)
+	}
+	eur, err := model.NewMoney(950, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := []struct {
+		name string
+		err  error
+		ok   bool
+	}{
+		{"retiring it again", laptop.RetireVariant(sku(t, "LAP-13-SLV-16")), false},
+		{"adding its SKU again", laptop.AddVariant(sku(t, "LAP-13-SLV-16"), usd(t, 1000), map[string]string{"color": "silver", "memory": "32GB"}), false},
+		{"adding its attributes by a new SKU", laptop.AddVariant(sku(t, "LAP-13-SLV-16B"), usd(t, 1050), map[string]string{"color": "silver", "memory": "16GB"}), true},
+		{"changing its price", laptop.ChangeVariantPrice(sku(t, "LAP-13-SLV-16"), usd(t, 900)), false},
+		{"changing the price of one on sale", laptop.ChangeVariantPrice(sku(t, "LAP-13-GRY-16"), usd(t, 950)), true},
+		{"changing its currency", laptop.ChangeVariantPrice(sku(t, "LAP-13-GRY-16"), eur), false},
+		{"changing the product's currency", laptop.ChangePrice(eur), false},
+	}
+	for _, tt := range calls {
+		if tt.ok && tt.err != nil || !tt.ok && !isInvalid(tt.err) {
+			t.Errorf("%s = %v, want accepted: %v", tt.name, tt.err, tt.ok)
+		}
+	}
+}
+
+func TestVariantsOfADiscontinuedProduct(t *testing.T) {
+	laptop := newLaptop(t)
+	if err := laptop.AddVariant(sku(t, "LAP-13-GRY"), usd(t, 1100), map[string]string{"color": "gray"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.Discontinue(); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.AddVariant(sku(t, "LAP-13-RED"), usd(t, 1000), map[string]string{"color": "red"}); !isInvalid(err) {
+		t.Errorf("adding a variant to a discontinued product = %v, want a ValidationError", err)
+	}
+	if err := laptop.ChangeVariantPrice(sku(t, "LAP-13-GRY"), usd(t, 900)); !isInvalid(err) {
+		t.Errorf("changing a variant's price = %v, want a ValidationError", err)
+	}
+	if err := laptop.RetireVariant(sku(t, "LAP-13-GRY")); err != nil {
+		t.Errorf("retiring a variant = %v, want it retired", err)
+	}
+}
+
+// TestVariantRandomCalls makes random calls on products' variants, and
+// checks their invariants after each: SKUs are unique, prices are positive
+// dollars, no two variants on sale have the same attributes, retired ones
+// stay retired, and a refused call changes nothing
+func TestVariantRandomCalls(t *testing.T) {
+	random := rand.New(rand.NewSource(4902))
+	codes := []string{"AAA", "BBB", "CCC", "DDD", "EEE", "FFF"}
+	colors := []string{"red", "blue"}
+	var laptop *model.Product
+	var retired map[model.SKU]bool
+	added := 0
+	for i := 0; i < 5000; i++ {
+		// a new product every 50 calls, before the SKUs run out
+		if i%50 == 0 {
+			laptop, retired = newLaptop(t), map[model.SKU]bool{}
+		}
+		before := fmt.Sprint(laptop.Variants())
+		code := sku(t, codes[random.Intn(len(codes))]+fmt.Sprint(random.Intn(4)))
+		var call string
+		var err error
+		switch random.Intn(3) {
+		case 0:
+			price, _ := model.NewMoney(float64(random.Intn(4)*100), []string{"USD", "USD", "USD", "EUR"}[random.Intn(4)])
+			attributes := map[string]string{"color": colors[random.Intn(len(colors))], "size": fmt.Sprint(random.Intn(6))}
+			call = fmt.Sprintf("AddVariant(%s, %s, %v)", code, price, attributes)
+			if err = laptop.AddVariant(code, price, attributes); err == nil {
+				added++
+			}
+		case 1:
+			call, err = fmt.Sprintf("RetireVariant(%s)", code), laptop.RetireVariant(code)
+		default:
+			price := usd(t, float64(random.Intn(4)*100))
+			call, err = fmt.Sprintf("ChangeVariantPrice(%s, %s)", code, price), laptop.ChangeVariantPrice(code, price)
+		}
+		if after := fmt.Sprint(laptop.Variants()); err != nil && after != before {
+			t.Fatalf("call %d, %s, was refused (%v) but changed the variants from %s to %s", i, call, err, before, after)
+		}
+
+		seen := map[model.SKU]bool{}
+		onSale := map[string]bool{}
+		for _, variant := range laptop.Variants() {
+			price := variant.Price()
+			switch {
+			case seen[variant.SKU()]:
+				t.Fatalf("after call %d, %s, %s is there twice", i, call, variant.SKU())
+			case price.Currency() != "USD" || price.IsZero():
+				t.Fatalf("after call %d, %s, %s is at %s", i, call, variant.SKU(), price)
+			case retired[variant.SKU()] && !variant.Retired():
+				t.Fatalf("after call %d, %s, %s is on sale again", i, call, variant.SKU())
+			}
+			seen[variant.SKU()] = true
+			if variant.Retired() {
+				retired[variant.SKU()] = true
+				continue
+			}
+			attributes := fmt.Sprint(variant.Attributes())
+			if onSale[attributes] {
+				t.Fatalf("after call %d, %s, two variants on sale are %s", i, call, attributes)
+			}
+			onSale[attributes] = true
+		}
+	}
+	if added == 0 {
+		t.Error("of 5000 random calls, no AddVariant succeeded; the test checks nothing")
+	}
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "github.com/dong-tran/docs/ddd-example/domain/model"
+
+// CategoryRepository stores the catalog's CategoryTree, which is one
+// aggregate: Load returns the whole tree, empty if nothing was saved, and
+// Save replaces it. Categories change rarely, and one at a time, so the
+// last save wins.
+type CategoryRepository interface {
+	Load() (*model.CategoryTree, error)
+	Save(tree *model.CategoryTree) error
+}
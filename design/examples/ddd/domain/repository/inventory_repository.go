@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// ErrInventoryNotFound is returned by FindByProductID for a product without
+// an inventory
+var ErrInventoryNotFound = errors.New("inventory not found")
+
+// ErrConcurrentModification is returned by InventoryRepository.Save when
+// the inventory was saved by someone else since it was loaded; load it
+// again, and retry the change
+var ErrConcurrentModification = errors.New("inventory was modified concurrently")
+
+// InventoryRepository stores inventories, one per product. Save is
+// optimistic: it stores the inventory only if the stored one is still at
+// the version it was loaded at, or if there is none for a new inventory.
+type InventoryRepository interface {
+	Save(inventory *model.Inventory) error
+	FindByProductID(id model.ProductID) (*model.Inventory, error)
+}
@@ -1,11 +1,30 @@
 package repository
 
-import "github.com/dong-tran/docs/ddd-example/domain/model"
+import (
+	"errors"
 
-// ProductRepository defines the contract for product persistence
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// ErrProductNotFound is returned by FindByID and Delete for an unknown id,
+// and by FindBySKU for a SKU no product has
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrSKUTaken is returned by Save when another product has a variant with
+// one of the product's SKUs
+var ErrSKUTaken = errors.New("SKU is taken")
+
+// ProductRepository defines the contract for product persistence. Save
+// inserts a product or replaces the stored one, variants included, and
+// keeps SKUs unique across products.
 type ProductRepository interface {
 	Save(product *model.Product) error
 	FindByID(id model.ProductID) (*model.Product, error)
+	// FindBySKU returns the product with a variant of sku, retired or not
+	FindBySKU(sku model.SKU) (*model.Product, error)
 	FindAll() ([]*model.Product, error)
+	// FindInCategory returns the products of category and of every
+	// category under it, oldest first
+	FindInCategory(category model.Category) ([]*model.Product, error)
 	Delete(id model.ProductID) error
 }
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// ErrPromotionNotFound is returned by FindByID and Delete for an unknown id
+var ErrPromotionNotFound = errors.New("promotion not found")
+
+// PromotionRepository holds the promotion rules PricingService applies
+type PromotionRepository interface {
+	Save(promotion *model.Promotion) error
+	FindByID(id model.PromotionID) (*model.Promotion, error)
+	// FindActive returns the promotions in effect at t, whatever they cover
+	FindActive(t time.Time) ([]*model.Promotion, error)
+	Delete(id model.PromotionID) error
+}
@@ -1,28 +1,43 @@
 package service
 
 import (
-"errors"
 "github.com/dong-tran/docs/ddd-example/domain/model"
+"github.com/dong-tran/docs/ddd-example/domain/repository"
 )
 
-// PricingService is a domain service for pricing logic
-type PricingService struct{}
+// PricingService is a domain service for pricing logic: discounts on a
+// product, and quotes with the promotions that apply (see Quote)
+type PricingService struct {
+	promotions repository.PromotionRepository
+	policy     StackingPolicy
+}
 
+// NewPricingService prices without promotions
 func NewPricingService() *PricingService {
-	return &PricingService{}
+	return &PricingService{policy: Stack}
+}
+
+// NewPricingServiceWithPromotions quotes with the rules of promotions,
+// combined as policy says
+func NewPricingServiceWithPromotions(promotions repository.PromotionRepository, policy StackingPolicy) *PricingService {
+	return &PricingService{promotions: promotions, policy: policy}
 }
 
 // ApplyDiscount applies a discount to a product
 func (s *PricingService) ApplyDiscount(product *model.Product, discountPercent float64) error {
 	if discountPercent < 0 || discountPercent > 100 {
-		return errors.New("discount must be between 0 and 100")
+		return model.ValidationError("discount must be between 0 and 100")
 	}
 
+	// The discount is rounded to the cent, half to even, and the price is
+	// what is left of the current one
 	currentPrice := product.Price()
-	discountAmount := currentPrice.Amount() * (discountPercent / 100)
-	newAmount := currentPrice.Amount() - discountAmount
+	discount, err := currentPrice.Multiply(discountPercent / 100)
+	if err != nil {
+		return err
+	}
 
-	newPrice, err := model.NewMoney(newAmount, currentPrice.Currency())
+	newPrice, err := currentPrice.Subtract(discount)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/service"
+)
+
+// item returns an Electronics product priced at amount USD
+func item(t *testing.T, amount float64) *model.Product {
+	t.Helper()
+	price, err := model.NewMoney(amount, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	category, err := model.NewCategory("Electronics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := model.NewProduct("Item", "", price, category)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
+
+func TestApplyDiscount(t *testing.T) {
+	pricing := service.NewPricingService()
+	discounts := []struct {
+		price    float64
+		discount float64
+		want     int64
+	}{
+		{999.99, 10, 89999}, // 99.999 off, rounded to 100.00
+		{19.99, 15, 1699},   // 2.9985 off, rounded to 3.00
+		{0.25, 50, 13},      // 0.125 off, to even: 0.12
+	}
+	for _, tt := range discounts {
+		product := item(t, tt.price)
+		if err := pricing.ApplyDiscount(product, tt.discount); err != nil || product.Price().Minor() != tt.want {
+			t.Errorf("%v%% off %v = %v, %v, want %d cents", tt.discount, tt.price, product.Price(), err, tt.want)
+		}
+	}
+
+	product := item(t, 100)
+	var invalid model.ValidationError
+	if err := pricing.ApplyDiscount(product, 100); !errors.As(err, &invalid) || product.Price().Minor() != 10000 {
+		t.Errorf("a 100%% discount = %v, price %v, want a ValidationError, as a price must be positive", err, product.Price())
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+)
+
+// StackingPolicy is how PricingService combines the promotions that apply
+// to a sale
+type StackingPolicy int
+
+const (
+	// Stack combines the stackable promotions, each taking its part of
+	// what the ones before it left, in order of priority. An exclusive
+	// promotion applies alone. Whichever takes more off wins: the best
+	// exclusive promotion, or the stackable ones together.
+	Stack StackingPolicy = iota
+	// BestOnly applies the one promotion that takes most off, stackable
+	// or not
+	BestOnly
+)
+
+// Quote is the price of quantity units of a product, with the promotions
+// that apply
+type Quote struct {
+	UnitPrice model.Money
+	Quantity  int
+	Subtotal  model.Money // before promotions
+	Discount  model.Money
+	Total     model.Money
+	// Applied are the promotions taken into account, in the order they
+	// were applied
+	Applied []model.PromotionID
+}
+
+// Quote prices quantity units of product at t. Of the promotions in effect
+// at t, those that cover the product apply as the policy says; when two
+// would take off the same, the one of higher priority wins, then the one
+// with the earlier name, so a quote never depends on the order rules were
+// stored in.
+func (s *PricingService) Quote(product *model.Product, quantity int, t time.Time) (Quote, error) {
+	if quantity < 1 {
+		return Quote{}, model.ValidationError("quantity must be at least 1")
+	}
+	unit := product.Price()
+	subtotal, err := unit.Multiply(float64(quantity))
+	if err != nil {
+		return Quote{}, err
+	}
+	quote := Quote{UnitPrice: unit, Quantity: quantity, Subtotal: subtotal, Total: subtotal}
+	quote.Discount, _ = model.NewMoneyFromMinor(0, unit.Currency())
+	if s.promotions == nil {
+		return quote, nil
+	}
+
+	active, err := s.promotions.FindActive(t)
+	if err != nil {
+		return Quote{}, err
+	}
+	var candidates []*model.Promotion
+	for _, promotion := range active {
+		if promotion.AppliesTo(product, t) {
+			candidates = append(candidates, promotion)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].Terms(), candidates[j].Terms()
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return candidates[i].Name() < candidates[j].Name()
+	})
+
+	// The best single promotion, exclusive ones only under Stack
+	var best *model.Promotion
+	bestDiscount := quote.Discount
+	for _, promotion := range candidates {
+		if s.policy == Stack && promotion.Terms().Stackable {
+			continue
+		}
+		discount, err := promotion.Discount(unit, quantity, subtotal)
+		if err != nil {
+			return Quote{}, err
+		}
+		// Candidates are in order of precedence: only more takes its place
+		if more, _ := discount.Compare(bestDiscount); more > 0 {
+			best, bestDiscount = promotion, discount
+		}
+	}
+
+	var stacked []model.PromotionID
+	stackedDiscount := quote.Discount
+	if s.policy == Stack {
+		remaining := subtotal
+		for _, promotion := range candidates {
+			if !promotion.Terms().Stackable {
+				continue
+			}
+			discount, err := promotion.Discount(unit, quantity, remaining)
+			if err != nil {
+				return Quote{}, err
+			}
+			if discount.IsZero() {
+				continue
+			}
+			remaining, _ = remaining.Subtract(discount)
+			stackedDiscount, _ = stackedDiscount.Add(discount)
+			stacked = append(stacked, promotion.ID())
+		}
+	}
+
+	// On a tie, the exclusive promotion: one promotion is simpler to
+	// explain than several
+	switch more, _ := stackedDiscount.Compare(bestDiscount); {
+	case more > 0:
+		quote.Discount, quote.Applied = stackedDiscount, stacked
+	case best != nil:
+		quote.Discount, quote.Applied = bestDiscount, []model.PromotionID{best.ID()}
+	}
+	quote.Total, err = subtotal.Subtract(quote.Discount)
+	return quote, err
+}
@@ -0,0 +1,283 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/service"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+var now = time.Date(2030, 7, 1, 9, 0, 0, 0, time.UTC)
+
+// shop is a catalog of two products, a 20.00 EUR book and a 100.00 EUR
+// lamp, whose promotions apply under a stacking policy
+type shop struct {
+	t          *testing.T
+	promotions *application.PromotionService
+	book, lamp model.ProductID
+}
+
+func newShop(t *testing.T, policy service.StackingPolicy) *shop {
+	t.Helper()
+	products := persistence.NewMemoryProductRepository()
+	catalog := application.NewProductService(products)
+	book, err := catalog.CreateProduct(application.CreateProductDTO{Name: "Novel", Price: 20, Currency: "EUR", Category: "Books"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lamp, err := catalog.CreateProduct(application.CreateProductDTO{Name: "Lamp", Price: 100, Currency: "EUR", Category: "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &shop{
+		t:          t,
+		promotions: application.NewPromotionService(products, persistence.NewMemoryPromotionRepository(), policy),
+		book:       book.ID(),
+		lamp:       lamp.ID(),
+	}
+}
+
+func (s *shop) add(dto application.CreatePromotionDTO) model.PromotionID {
+	s.t.Helper()
+	promotion, err := s.promotions.CreatePromotion(dto)
+	if err != nil {
+		s.t.Fatalf("CreatePromotion(%q): %v", dto.Name, err)
+	}
+	return promotion.ID()
+}
+
+func (s *shop) remove(id model.PromotionID) {
+	s.t.Helper()
+	if err := s.promotions.RemovePromotion(id); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func (s *shop) quote(product model.ProductID, quantity int, at time.Time) service.Quote {
+	s.t.Helper()
+	quote, err := s.promotions.QuoteProduct(product, quantity, at)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return quote
+}
+
+func percent(name string, p float64) application.CreatePromotionDTO {
+	return application.CreatePromotionDTO{Name: name, Kind: model.PercentageOff, Percent: p}
+}
+
+func amountOff(name string, amount float64) application.CreatePromotionDTO {
+	return application.CreatePromotionDTO{Name: name, Kind: model.AmountOff, Amount: amount, Currency: "EUR"}
+}
+
+func stackable(dto application.CreatePromotionDTO, priority int) application.CreatePromotionDTO {
+	dto.Stackable, dto.Priority = true, priority
+	return dto
+}
+
+// applied reports whether exactly ids were applied, in that order
+func applied(quote service.Quote, ids ...model.PromotionID) bool {
+	return fmt.Sprint(quote.Applied) == fmt.Sprint(ids)
+}
+
+func TestPromotionKinds(t *testing.T) {
+	s := newShop(t, service.Stack)
+	if q := s.quote(s.book, 3, now); q.Subtotal.Minor() != 6000 || !q.Discount.IsZero() || q.Total.Minor() != 6000 || !applied(q) {
+		t.Errorf("3 books without promotions = %+v, want 60.00", q)
+	}
+
+	id := s.add(percent("Summer sale", 15))
+	if q := s.quote(s.book, 3, now); q.Discount.Minor() != 900 || q.Total.Minor() != 5100 || !applied(q, id) {
+		t.Errorf("15%% off 60.00 = %+v, want 9.00 off", q)
+	}
+	s.remove(id)
+
+	id = s.add(amountOff("Two euros off", 2))
+	if q := s.quote(s.book, 3, now); q.Discount.Minor() != 600 || !applied(q, id) {
+		t.Errorf("2.00 off each of 3 books = %+v, want 6.00 off", q)
+	}
+	s.remove(id)
+	s.add(amountOff("Thirty off", 30))
+	if q := s.quote(s.book, 1, now); q.Discount.Minor() != 2000 || !q.Total.IsZero() {
+		t.Errorf("30.00 off a 20.00 book = %+v, want it down to nothing, not below", q)
+	}
+}
+
+func TestBuyXGetY(t *testing.T) {
+	s := newShop(t, service.Stack)
+	id := s.add(application.CreatePromotionDTO{Name: "3 for 2", Kind: model.BuyXGetY, Buy: 2, Get: 1})
+	quantities := []struct {
+		quantity int
+		free     int64
+	}{{1, 0}, {2, 0}, {3, 1}, {5, 1}, {6, 2}, {7, 2}}
+	for _, tt := range quantities {
+		if q := s.quote(s.book, tt.quantity, now); q.Discount.Minor() != tt.free*2000 || (tt.free > 0) != applied(q, id) {
+			t.Errorf("buy 2 get 1 on %d books = %+v, want %d free", tt.quantity, q, tt.free)
+		}
+	}
+}
+
+func TestInvalidPromotions(t *testing.T) {
+	s := newShop(t, service.Stack)
+	for _, bad := range []application.CreatePromotionDTO{
+		percent("Too much", 100),
+		percent("", 10),
+		{Name: "Nothing off", Kind: model.AmountOff, Amount: 0, Currency: "EUR"},
+		{Name: "Buy none", Kind: model.BuyXGetY, Buy: 0, Get: 1},
+		{Name: "Mystery", Kind: "mystery"},
+		{Name: "Both", Kind: model.PercentageOff, Percent: 5, Category: "Books", ProductID: s.book.String()},
+		{Name: "Backwards", Kind: model.PercentageOff, Percent: 5, From: now, Until: now.Add(-time.Hour)},
+		{Name: "Malformed product", Kind: model.PercentageOff, Percent: 5, ProductID: "not-an-id"},
+	} {
+		var invalid model.ValidationError
+		if _, err := s.promotions.CreatePromotion(bad); !errors.As(err, &invalid) {
+			t.Errorf("CreatePromotion(%q) = %v, want a ValidationError", bad.Name, err)
+		}
+	}
+	if _, err := s.promotions.QuoteProduct(s.book, 0, now); err == nil {
+		t.Error("a quote for no units succeeded, want an error")
+	}
+}
+
+func TestPromotionScopes(t *testing.T) {
+	s := newShop(t, service.Stack)
+	books := percent("Books week", 10)
+	books.Category = "books"
+	id := s.add(books)
+	if !applied(s.quote(s.book, 1, now), id) || !applied(s.quote(s.lamp, 1, now)) {
+		t.Error("a category promotion does not apply to its category alone, whatever the case")
+	}
+
+	lamp := percent("Lamp deal", 20)
+	lamp.ProductID = s.lamp.String()
+	lampID := s.add(lamp)
+	if !applied(s.quote(s.lamp, 1, now), lampID) || !applied(s.quote(s.book, 1, now), id) {
+		t.Error("a product promotion does not apply to that product alone")
+	}
+
+	s.add(application.CreatePromotionDTO{Name: "Dollar off", Kind: model.AmountOff, Amount: 1, Currency: "USD", Stackable: true})
+	if q := s.quote(s.book, 1, now); !applied(q, id) || q.Discount.Minor() != 200 {
+		t.Errorf("with a USD amount off, a book in EUR = %+v, want the USD one not applied", q)
+	}
+}
+
+func TestPromotionPeriods(t *testing.T) {
+	s := newShop(t, service.Stack)
+	weekend := percent("Weekend", 25)
+	weekend.From, weekend.Until = now, now.Add(48*time.Hour)
+	id := s.add(weekend)
+	times := []struct {
+		name    string
+		at      time.Time
+		applies bool
+	}{
+		{"at its start", now, true},
+		{"before", now.Add(-time.Second), false},
+		{"just before its end", now.Add(48*time.Hour - time.Second), true},
+		{"at its end", now.Add(48 * time.Hour), false},
+	}
+	for _, tt := range times {
+		if got := applied(s.quote(s.book, 1, tt.at), id); got != tt.applies {
+			t.Errorf("a weekend promotion applies %s: %v, want %v", tt.name, got, tt.applies)
+		}
+	}
+	openEnded := percent("From August", 5)
+	openEnded.From = now.AddDate(0, 1, 0)
+	id = s.add(openEnded)
+	if !applied(s.quote(s.book, 1, now.AddDate(5, 0, 0)), id) {
+		t.Error("a promotion without an end stopped applying")
+	}
+}
+
+func TestExclusivePromotions(t *testing.T) {
+	s := newShop(t, service.Stack)
+	s.add(percent("Ten percent", 10))
+	big := s.add(percent("Twenty percent", 20))
+	if q := s.quote(s.lamp, 1, now); !applied(q, big) || q.Discount.Minor() != 2000 {
+		t.Errorf("10%% and 20%%, exclusive = %+v, want the 20%% alone", q)
+	}
+
+	s = newShop(t, service.Stack)
+	s.add(percent("Low", 10))
+	high := s.add(application.CreatePromotionDTO{Name: "High", Kind: model.AmountOff, Amount: 10, Currency: "EUR", Priority: 5})
+	if q := s.quote(s.lamp, 1, now); !applied(q, high) || q.Discount.Minor() != 1000 {
+		t.Errorf("two taking off the same = %+v, want the one of higher priority", q)
+	}
+
+	s = newShop(t, service.Stack)
+	beta := s.add(percent("Beta", 10))
+	alpha := s.add(amountOff("Alpha", 10))
+	if q := s.quote(s.lamp, 1, now); !applied(q, alpha) || q.Discount.Minor() != 1000 {
+		t.Errorf("two taking off the same, of the same priority = %+v, want the one with the earlier name", q)
+	}
+	s.remove(alpha)
+	if q := s.quote(s.lamp, 1, now); !applied(q, beta) {
+		t.Errorf("once the earlier is removed = %+v, want the other", q)
+	}
+}
+
+func TestStackedPromotions(t *testing.T) {
+	s := newShop(t, service.Stack)
+	first := s.add(stackable(percent("Members", 10), 2))
+	second := s.add(stackable(amountOff("Coupon", 5), 1))
+	if q := s.quote(s.lamp, 1, now); !applied(q, first, second) || q.Discount.Minor() != 1000+500 || q.Total.Minor() != 8500 {
+		t.Errorf("10%% then 5.00 off 100.00 = %+v, want them combined, by priority, to 85.00", q)
+	}
+
+	s = newShop(t, service.Stack)
+	second = s.add(stackable(amountOff("Coupon", 5), 2))
+	first = s.add(stackable(percent("Members", 10), 1))
+	if q := s.quote(s.lamp, 1, now); !applied(q, second, first) || q.Discount.Minor() != 500+950 {
+		t.Errorf("5.00 off then 10%% = %+v, want 5.00, then 10%% of the 95.00 left", q)
+	}
+
+	flash := s.add(percent("Flash sale", 15))
+	if q := s.quote(s.lamp, 1, now); !applied(q, flash) || q.Discount.Minor() != 1500 {
+		t.Errorf("an exclusive 15.00 against a stack of 14.50 = %+v, want the exclusive one", q)
+	}
+	s.remove(flash)
+	small := s.add(percent("Small sale", 14))
+	if q := s.quote(s.lamp, 1, now); !applied(q, second, first) || q.Discount.Minor() != 1450 {
+		t.Errorf("an exclusive 14.00 against a stack of 14.50 = %+v, want the stack", q)
+	}
+	s.remove(small)
+	tie := s.add(amountOff("Tie", 14.5))
+	if q := s.quote(s.lamp, 1, now); !applied(q, tie) || q.Discount.Minor() != 1450 {
+		t.Errorf("an exclusive 14.50 against a stack of 14.50 = %+v, want the exclusive one, alone", q)
+	}
+
+	s = newShop(t, service.Stack)
+	s.add(stackable(percent("Half", 50), 2))
+	s.add(stackable(percent("Another half", 50), 1))
+	s.add(stackable(amountOff("Sixty off", 60), 0))
+	if q := s.quote(s.lamp, 1, now); q.Discount.Minor() != 10000 || !q.Total.IsZero() || len(q.Applied) != 3 {
+		t.Errorf("50%%, 50%% then 60.00 off 100.00 = %+v, want 50.00, 25.00, then the 25.00 left", q)
+	}
+}
+
+func TestBestOnly(t *testing.T) {
+	s := newShop(t, service.BestOnly)
+	s.add(stackable(percent("Members", 10), 2))
+	s.add(stackable(amountOff("Coupon", 5), 1))
+	best := s.add(stackable(percent("Twelve percent", 12), 0))
+	if q := s.quote(s.lamp, 1, now); !applied(q, best) || q.Discount.Minor() != 1200 {
+		t.Errorf("under BestOnly = %+v, want only the one taking most off, stackable or not", q)
+	}
+	s.add(amountOff("Exclusive", 11))
+	if q := s.quote(s.lamp, 1, now); !applied(q, best) {
+		t.Errorf("under BestOnly, with an exclusive 11.00 off = %+v, want the 12%% still", q)
+	}
+}
+
+func TestQuoteWithoutPromotions(t *testing.T) {
+	product := item(t, 10)
+	q, err := service.NewPricingService().Quote(product, 2, now)
+	if err != nil || q.Total.Minor() != 2000 || !applied(q) {
+		t.Errorf("Quote of a PricingService without promotions = %+v, %v, want the plain price", q, err)
+	}
+}
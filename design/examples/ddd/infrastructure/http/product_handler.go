@@ -0,0 +1,280 @@
+// Package http serves the application's ProductService over HTTP with Echo.
+// Requests and responses are plain JSON structs: the aggregate never leaves
+// the handlers, so its fields stay unexported and its rules in the model.
+package http
+
+import (
+	"errors"
+	nethttp "net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+type ProductHandler struct {
+	service *application.ProductService
+}
+
+func NewProductHandler(service *application.ProductService) *ProductHandler {
+	return &ProductHandler{service: service}
+}
+
+// Register adds the product routes to e
+func (h *ProductHandler) Register(e *echo.Echo) {
+	e.POST("/products", h.CreateProduct)
+	e.GET("/products", h.GetAllProducts)
+	e.GET("/products/:id", h.GetProduct)
+	e.PUT("/products/:id/price", h.ChangePrice)
+	e.POST("/products/:id/discount", h.ApplyDiscount)
+	e.POST("/products/:id/discontinue", h.DiscontinueProduct)
+	e.POST("/products/:id/variants", h.AddVariant)
+	e.PUT("/products/:id/variants/:sku/price", h.ChangeVariantPrice)
+	e.POST("/products/:id/variants/:sku/retire", h.RetireVariant)
+}
+
+type CreateProductRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Currency    string  `json:"currency"`
+	Category    string  `json:"category"`
+}
+
+// ChangePriceRequest is the new price, in the currency the product has
+type ChangePriceRequest struct {
+	Price float64 `json:"price"`
+}
+
+// DiscountRequest is a discount in percent, from 0 to 100
+type DiscountRequest struct {
+	Discount float64 `json:"discount"`
+}
+
+// AddVariantRequest is a variant: its price is in the product's currency
+type AddVariantRequest struct {
+	SKU        string            `json:"sku"`
+	Price      float64           `json:"price"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+type VariantResponse struct {
+	SKU        string            `json:"sku"`
+	Price      float64           `json:"price"`
+	Attributes map[string]string `json:"attributes"`
+	Retired    bool              `json:"retired"`
+}
+
+type ProductResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	Price        float64   `json:"price"`
+	Currency     string    `json:"currency"`
+	Category     string    `json:"category"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Discontinued bool      `json:"discontinued"`
+	// Variants are in the order they were added, retired ones included
+	Variants []VariantResponse `json:"variants"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func toResponse(product *model.Product) ProductResponse {
+	response := ProductResponse{
+		ID:           product.ID().String(),
+		Name:         product.Name(),
+		Description:  product.Description(),
+		Price:        product.Price().Amount(),
+		Currency:     product.Price().Currency(),
+		Category:     product.Category().Path(),
+		CreatedAt:    product.CreatedAt(),
+		UpdatedAt:    product.UpdatedAt(),
+		Discontinued: product.Discontinued(),
+		Variants:     []VariantResponse{},
+	}
+	for _, variant := range product.Variants() {
+		response.Variants = append(response.Variants, VariantResponse{
+			SKU:        variant.SKU().String(),
+			Price:      variant.Price().Amount(),
+			Attributes: variant.Attributes(),
+			Retired:    variant.Retired(),
+		})
+	}
+	return response
+}
+
+// fail answers with the status err calls for: 404 for an unknown product or
+// variant, 409 for a SKU another product has, 400 for a broken rule of the
+// model, and 500 for anything else, without its message
+func fail(c echo.Context, err error) error {
+	var invalid model.ValidationError
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, model.ErrVariantNotFound):
+		return c.JSON(nethttp.StatusNotFound, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, repository.ErrSKUTaken):
+		return c.JSON(nethttp.StatusConflict, ErrorResponse{Error: err.Error()})
+	case errors.As(err, &invalid):
+		return c.JSON(nethttp.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	default:
+		c.Logger().Error(err)
+		return c.JSON(nethttp.StatusInternalServerError, ErrorResponse{Error: "internal error"})
+	}
+}
+
+func badRequest(c echo.Context) error {
+	return c.JSON(nethttp.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+}
+
+func (h *ProductHandler) CreateProduct(c echo.Context) error {
+	var req CreateProductRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c)
+	}
+
+	product, err := h.service.CreateProduct(application.CreateProductDTO{
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		Currency:    req.Currency,
+		Category:    req.Category,
+	})
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusCreated, toResponse(product))
+}
+
+// GetAllProducts returns every product, or with ?category= those of a
+// category and its subcategories
+func (h *ProductHandler) GetAllProducts(c echo.Context) error {
+	var products []*model.Product
+	var err error
+	if category := c.QueryParam("category"); category != "" {
+		products, err = h.service.GetProductsInCategory(category)
+	} else {
+		products, err = h.service.GetAllProducts()
+	}
+	if err != nil {
+		return fail(c, err)
+	}
+	responses := make([]ProductResponse, 0, len(products))
+	for _, product := range products {
+		responses = append(responses, toResponse(product))
+	}
+	return c.JSON(nethttp.StatusOK, responses)
+}
+
+func (h *ProductHandler) GetProduct(c echo.Context) error {
+	id, err := model.ParseProductID(c.Param("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	product, err := h.service.GetProduct(id)
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusOK, toResponse(product))
+}
+
+func (h *ProductHandler) ChangePrice(c echo.Context) error {
+	id, err := model.ParseProductID(c.Param("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	var req ChangePriceRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c)
+	}
+	product, err := h.service.ChangeProductPrice(id, req.Price)
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusOK, toResponse(product))
+}
+
+func (h *ProductHandler) ApplyDiscount(c echo.Context) error {
+	id, err := model.ParseProductID(c.Param("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	var req DiscountRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c)
+	}
+	if err := h.service.ApplyDiscountToProduct(id, req.Discount); err != nil {
+		return fail(c, err)
+	}
+	product, err := h.service.GetProduct(id)
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusOK, toResponse(product))
+}
+
+func (h *ProductHandler) DiscontinueProduct(c echo.Context) error {
+	id, err := model.ParseProductID(c.Param("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	product, err := h.service.DiscontinueProduct(id)
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusOK, toResponse(product))
+}
+
+// AddVariant is 201 with the product and its new variant
+func (h *ProductHandler) AddVariant(c echo.Context) error {
+	id, err := model.ParseProductID(c.Param("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	var req AddVariantRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c)
+	}
+	product, err := h.service.AddVariant(id, application.AddVariantDTO{
+		SKU:        req.SKU,
+		Price:      req.Price,
+		Attributes: req.Attributes,
+	})
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusCreated, toResponse(product))
+}
+
+func (h *ProductHandler) ChangeVariantPrice(c echo.Context) error {
+	id, err := model.ParseProductID(c.Param("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	var req ChangePriceRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c)
+	}
+	product, err := h.service.ChangeVariantPrice(id, c.Param("sku"), req.Price)
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusOK, toResponse(product))
+}
+
+func (h *ProductHandler) RetireVariant(c echo.Context) error {
+	id, err := model.ParseProductID(c.Param("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	product, err := h.service.RetireVariant(id, c.Param("sku"))
+	if err != nil {
+		return fail(c, err)
+	}
+	return c.JSON(nethttp.StatusOK, toResponse(product))
+}
@@ -0,0 +1,182 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	producthttp "github.com/dong-tran/docs/ddd-example/infrastructure/http"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// The handler tests drive the product routes through Echo, on the in-memory
+// repository
+
+// server returns the product routes of a fresh, empty catalog
+func server() *echo.Echo {
+	e := echo.New()
+	producthttp.NewProductHandler(application.NewProductService(persistence.NewMemoryProductRepository())).Register(e)
+	return e
+}
+
+// call sends body to path and decodes the answer into out, if it is set,
+// returning the status
+func call(t *testing.T, e *echo.Echo, method, path, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Errorf("%s %s answered %d %s, not JSON: %v", method, path, rec.Code, rec.Body, err)
+		}
+	}
+	return rec.Code
+}
+
+// create posts a product, failing the test unless it is created
+func create(t *testing.T, e *echo.Echo, body string) producthttp.ProductResponse {
+	t.Helper()
+	var created producthttp.ProductResponse
+	if status := call(t, e, http.MethodPost, "/products", body, &created); status != http.StatusCreated {
+		t.Fatalf("POST /products %s = %d, want 201", body, status)
+	}
+	return created
+}
+
+func TestCreateAndList(t *testing.T) {
+	e := server()
+	laptop := create(t, e, `{"name":"Laptop","description":"High-performance laptop","price":1000,"currency":"USD","category":"Electronics"}`)
+	if laptop.ID == "" || laptop.Price != 1000 || laptop.Currency != "USD" || laptop.Category != "Electronics" || laptop.CreatedAt.IsZero() {
+		t.Errorf("POST /products = %+v, want the product with its new id", laptop)
+	}
+	var problem producthttp.ErrorResponse
+	if status := call(t, e, http.MethodPost, "/products", `{"name":"","price":5,"currency":"USD","category":"Books"}`, &problem); status != http.StatusBadRequest || problem.Error != "product name cannot be empty" {
+		t.Errorf("a product without a name = %d %q, want 400 with the model's reason", status, problem.Error)
+	}
+	if status := call(t, e, http.MethodPost, "/products", `{"name":`, nil); status != http.StatusBadRequest {
+		t.Errorf("a malformed body = %d, want 400", status)
+	}
+
+	create(t, e, `{"name":"Mouse","price":25,"currency":"USD","category":"Electronics"}`)
+	var all []producthttp.ProductResponse
+	if status := call(t, e, http.MethodGet, "/products", "", &all); status != http.StatusOK || len(all) != 2 || all[0].Name != "Laptop" || all[1].Name != "Mouse" {
+		t.Errorf("GET /products = %d %+v, want both, oldest first", status, all)
+	}
+	create(t, e, `{"name":"Novel","price":12,"currency":"USD","category":"Books/Fiction"}`)
+	if status := call(t, e, http.MethodGet, "/products?category=books", "", &all); status != http.StatusOK || len(all) != 1 || all[0].Category != "Books/Fiction" {
+		t.Errorf("GET /products?category=books = %d %+v, want those of the category and its subcategories", status, all)
+	}
+	if status := call(t, e, http.MethodGet, "/products?category=Books//Fiction", "", &problem); status != http.StatusBadRequest {
+		t.Errorf("a malformed category = %d, want 400", status)
+	}
+	var got producthttp.ProductResponse
+	if status := call(t, e, http.MethodGet, "/products/"+laptop.ID, "", &got); status != http.StatusOK || got.ID != laptop.ID || got.Description != "High-performance laptop" {
+		t.Errorf("GET /products/:id = %d %+v, want the product", status, got)
+	}
+}
+
+func TestPriceAndDiscount(t *testing.T) {
+	e := server()
+	laptop := create(t, e, `{"name":"Laptop","price":1000,"currency":"USD","category":"Electronics"}`)
+	path := "/products/" + laptop.ID
+	var changed producthttp.ProductResponse
+	if status := call(t, e, http.MethodPut, path+"/price", `{"price":800}`, &changed); status != http.StatusOK ||
+		changed.Price != 800 || changed.Currency != "USD" || changed.UpdatedAt.Before(laptop.UpdatedAt) {
+		t.Errorf("PUT /products/:id/price = %d %+v, want the price changed, in the same currency", status, changed)
+	}
+
+	var got producthttp.ProductResponse
+	if status := call(t, e, http.MethodPost, path+"/discount", `{"discount":25}`, &got); status != http.StatusOK || got.Price != 600 {
+		t.Errorf("POST /products/:id/discount of 25%% = %d, price %v, want 600", status, got.Price)
+	}
+	refused := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		reason string
+	}{
+		{"a price of 0", http.MethodPut, "/price", `{"price":0}`, "price must be positive"},
+		{"a discount over 100%", http.MethodPost, "/discount", `{"discount":150}`, "discount must be between 0 and 100"},
+	}
+	for _, tt := range refused {
+		var problem producthttp.ErrorResponse
+		if status := call(t, e, tt.method, path+tt.path, tt.body, &problem); status != http.StatusBadRequest || problem.Error != tt.reason {
+			t.Errorf("%s = %d %q, want 400 %q", tt.name, status, problem.Error, tt.reason)
+		}
+	}
+	if call(t, e, http.MethodGet, path, "", &got); got.Price != 600 {
+		t.Errorf("after the refused changes, the price is %v, want it left at 600", got.Price)
+	}
+
+	if status := call(t, e, http.MethodPost, path+"/discontinue", "", &got); status != http.StatusOK || !got.Discontinued {
+		t.Errorf("POST /products/:id/discontinue = %d %+v, want it off sale", status, got)
+	}
+	var problem producthttp.ErrorResponse
+	if status := call(t, e, http.MethodPut, path+"/price", `{"price":500}`, &problem); status != http.StatusBadRequest || problem.Error != "a discontinued product keeps its price" {
+		t.Errorf("changing a discontinued product's price = %d %q, want 400", status, problem.Error)
+	}
+}
+
+func TestUnknownProducts(t *testing.T) {
+	e := server()
+	unknown := "/products/6f1c2a9e-3b7d-4c55-9e0a-2f8d1b4c7a10"
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		reason string
+	}{
+		{http.MethodGet, unknown, "", http.StatusNotFound, "product not found"},
+		{http.MethodPut, unknown + "/price", `{"price":5}`, http.StatusNotFound, "product not found"},
+		{http.MethodPost, unknown + "/discount", `{"discount":5}`, http.StatusNotFound, "product not found"},
+		{http.MethodPost, unknown + "/discontinue", "", http.StatusNotFound, "product not found"},
+		{http.MethodGet, "/products/42", "", http.StatusBadRequest, "product id must be a UUID"},
+	}
+	for _, tt := range requests {
+		var problem producthttp.ErrorResponse
+		if status := call(t, e, tt.method, tt.path, tt.body, &problem); status != tt.status || problem.Error != tt.reason {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, status, problem.Error, tt.status, tt.reason)
+		}
+	}
+}
+
+func TestVariants(t *testing.T) {
+	e := server()
+	keyboard := create(t, e, `{"name":"Keyboard","price":80,"currency":"USD","category":"Electronics"}`)
+	trackpad := create(t, e, `{"name":"Trackpad","price":60,"currency":"USD","category":"Electronics"}`)
+	if keyboard.Variants == nil || len(keyboard.Variants) != 0 {
+		t.Errorf("a product without variants has %v, want an empty list", keyboard.Variants)
+	}
+
+	var got producthttp.ProductResponse
+	status := call(t, e, http.MethodPost, "/products/"+keyboard.ID+"/variants", `{"sku":"kb-us","price":85,"attributes":{"layout":"US"}}`, &got)
+	if status != http.StatusCreated || len(got.Variants) != 1 || got.Variants[0].SKU != "KB-US" ||
+		got.Variants[0].Price != 85 || got.Variants[0].Attributes["layout"] != "US" {
+		t.Fatalf("POST /products/:id/variants = %d %+v, want 201 with the product and its new variant", status, got)
+	}
+	var problem producthttp.ErrorResponse
+	if status := call(t, e, http.MethodPost, "/products/"+keyboard.ID+"/variants", `{"sku":"KB US","price":85,"attributes":{"layout":"UK"}}`, &problem); status != http.StatusBadRequest {
+		t.Errorf("a malformed SKU = %d, want 400", status)
+	}
+	if status := call(t, e, http.MethodPost, "/products/"+trackpad.ID+"/variants", `{"sku":"KB-US","price":65,"attributes":{"color":"white"}}`, &problem); status != http.StatusConflict || problem.Error != "SKU is taken" {
+		t.Errorf("another product's SKU = %d %q, want 409", status, problem.Error)
+	}
+	if status := call(t, e, http.MethodPut, "/products/"+keyboard.ID+"/variants/kb-us/price", `{"price":90}`, &got); status != http.StatusOK || got.Variants[0].Price != 90 {
+		t.Errorf("PUT /products/:id/variants/:sku/price = %d %+v, want the price changed", status, got.Variants)
+	}
+	if status := call(t, e, http.MethodPost, "/products/"+keyboard.ID+"/variants/KB-US/retire", "", &got); status != http.StatusOK || !got.Variants[0].Retired {
+		t.Errorf("POST /products/:id/variants/:sku/retire = %d %+v, want it off sale", status, got.Variants)
+	}
+	if status := call(t, e, http.MethodPost, "/products/"+keyboard.ID+"/variants/KB-UK/retire", "", &problem); status != http.StatusNotFound || problem.Error != "variant not found" {
+		t.Errorf("retiring an unknown variant = %d %q, want 404", status, problem.Error)
+	}
+}
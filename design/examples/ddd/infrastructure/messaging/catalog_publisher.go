@@ -0,0 +1,64 @@
+// Package messaging publishes the catalog's domain events to other
+// contexts, as integration events
+package messaging
+
+import (
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/integration"
+)
+
+// CatalogPublisher translates the catalog's domain events into the
+// published language of package integration, and publishes them on a bus.
+// It is the catalog's side of the context map: what it publishes is a
+// contract, and the model can change behind it.
+type CatalogPublisher struct {
+	bus *integration.Bus
+	// onError is told of a message that could not be built or that a
+	// subscriber failed on; the change that raised it is saved regardless
+	onError func(error)
+}
+
+func NewCatalogPublisher(bus *integration.Bus, onError func(error)) *CatalogPublisher {
+	return &CatalogPublisher{bus: bus, onError: onError}
+}
+
+// Handle is an application.EventHandler: register it with the dispatcher
+// of the ProductService
+func (p *CatalogPublisher) Handle(event model.Event) {
+	translated, ok := Translate(event)
+	if !ok {
+		return
+	}
+	message, err := integration.NewMessage(translated, event.OccurredAt())
+	if err == nil {
+		err = p.bus.Publish(message)
+	}
+	if err != nil && p.onError != nil {
+		p.onError(err)
+	}
+}
+
+// Translate returns the integration event for a domain event, and false
+// for one that is not published
+func Translate(event model.Event) (integration.Event, bool) {
+	switch e := event.(type) {
+	case model.ProductCreated:
+		return integration.ProductCreated{
+			ProductID: e.ID.String(),
+			Name:      e.Name,
+			Price:     e.Price.Amount(),
+			Currency:  e.Price.Currency(),
+			Category:  e.Category.Path(),
+		}, true
+	case model.PriceChanged:
+		return integration.ProductPriceChanged{
+			ProductID: e.ID.String(),
+			OldPrice:  e.OldPrice.Amount(),
+			NewPrice:  e.NewPrice.Amount(),
+			Currency:  e.NewPrice.Currency(),
+		}, true
+	case model.ProductDiscontinued:
+		return integration.ProductDiscontinued{ProductID: e.ID.String()}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,80 @@
+package messaging_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/messaging"
+	"github.com/dong-tran/docs/ddd-example/integration"
+)
+
+// productEvents returns the events of a book created at 40 USD, repriced at
+// 35.50 and discontinued
+func productEvents(t *testing.T) (*model.Product, []model.Event) {
+	t.Helper()
+	price, err := model.NewMoney(40, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cheaper, err := model.NewMoney(35.5, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	books, err := model.NewCategory("Books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := model.NewProduct("Go in Practice", "", price, books)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := product.ChangePrice(cheaper); err != nil {
+		t.Fatal(err)
+	}
+	if err := product.Discontinue(); err != nil {
+		t.Fatal(err)
+	}
+	return product, product.Events()
+}
+
+func TestTranslate(t *testing.T) {
+	product, events := productEvents(t)
+	id := product.ID().String()
+	translations := []struct {
+		event model.Event
+		want  integration.Event
+	}{
+		{events[0], integration.ProductCreated{ProductID: id, Name: "Go in Practice", Price: 40, Currency: "USD", Category: "Books"}},
+		{events[1], integration.ProductPriceChanged{ProductID: id, OldPrice: 40, NewPrice: 35.5, Currency: "USD"}},
+		{events[2], integration.ProductDiscontinued{ProductID: id}},
+	}
+	for _, tt := range translations {
+		if got, ok := messaging.Translate(tt.event); !ok || got != tt.want {
+			t.Errorf("Translate(%s) = %+v, %v, want %+v", tt.event.EventName(), got, ok, tt.want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	product, events := productEvents(t)
+	changed, _ := messaging.Translate(events[1])
+	m, err := integration.NewMessage(changed, events[1].OccurredAt())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != "catalog.product_price_changed.v1" || m.ID == "" || !m.OccurredAt.Equal(events[1].OccurredAt()) {
+		t.Errorf("NewMessage = %+v, want an id, a versioned type and the time of the event", m)
+	}
+	var wire map[string]any
+	if err := json.Unmarshal(m.Payload, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if wire["product_id"] != product.ID().String() || wire["new_price"] != 35.5 || len(wire) != 4 {
+		t.Errorf("payload = %s, want the JSON of the event alone", m.Payload)
+	}
+	var created integration.ProductCreated
+	if err := m.Decode(&created); err == nil {
+		t.Error("decoding a ProductPriceChanged as a ProductCreated succeeded, want an error")
+	}
+}
@@ -0,0 +1,127 @@
+package persistence_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// category returns the category at path
+func category(t *testing.T, path string) model.Category {
+	t.Helper()
+	c, err := model.NewCategory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// paths joins the paths of categories with commas
+func paths(categories []model.Category) string {
+	names := make([]string, 0, len(categories))
+	for _, c := range categories {
+		names = append(names, c.Path())
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestFindInCategory(t *testing.T) {
+	productRepositories(t, func(t *testing.T, repo repository.ProductRepository) {
+		var laptop model.ProductID
+		for _, p := range []struct{ name, category string }{
+			{"Laptop", "Electronics/Laptops"},
+			{"Gaming laptop", "Electronics/Laptops/Gaming"},
+			{"Phone", "electronics/phones"},
+			{"Radio", "Electronics"},
+			{"Novel", "Books"},
+			{"Atlas", "Bookshelves"},
+			{"Half price", "Sale/50%"},
+			{"Shoes", "Sale/50 off/Shoes"},
+			{"Under score", "Sale/a_b"},
+			{"Kids", "Sale/axb/Kids"},
+		} {
+			product := newProduct(t, p.name, 10)
+			product.ChangeCategory(category(t, p.category))
+			if err := repo.Save(product); err != nil {
+				t.Fatal(err)
+			}
+			if p.name == "Laptop" {
+				laptop = product.ID()
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		queries := []struct {
+			name     string
+			category string
+			want     string
+		}{
+			{"a category and its whole subtree, oldest first, whatever the case", "Electronics", "Laptop, Gaming laptop, Phone, Radio"},
+			{"a subcategory", "ELECTRONICS/laptops", "Laptop, Gaming laptop"},
+			{"a category whose name only starts another's", "Books", "Novel"},
+			{"a name with %", "Sale/50%", "Half price"},
+			{"a name with _", "Sale/a_b", "Under score"},
+			{"an empty category", "Toys", ""},
+		}
+		for _, tt := range queries {
+			found, err := repo.FindInCategory(category(t, tt.category))
+			names := make([]string, 0, len(found))
+			for _, product := range found {
+				names = append(names, product.Name())
+			}
+			if got := strings.Join(names, ", "); err != nil || got != tt.want {
+				t.Errorf("FindInCategory(%s), %s = %q, %v, want %q", tt.category, tt.name, got, err, tt.want)
+			}
+		}
+		if product, err := repo.FindByID(laptop); err != nil || product.Category().Path() != "Electronics/Laptops" {
+			t.Errorf("FindByID = %v, want the product with the path of its category", err)
+		}
+	})
+}
+
+// categoryRepositories runs test against both CategoryRepository
+// implementations
+func categoryRepositories(t *testing.T, test func(t *testing.T, categories repository.CategoryRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, persistence.NewMemoryCategoryRepository()) })
+	t.Run("sqlite", func(t *testing.T) { test(t, persistence.NewSQLCategoryRepository(openSQLite(t))) })
+}
+
+func TestCategoryRepository(t *testing.T) {
+	categoryRepositories(t, func(t *testing.T, categories repository.CategoryRepository) {
+		tree, err := categories.Load()
+		if err != nil || len(tree.Categories()) != 0 {
+			t.Fatalf("Load with nothing saved = %v, want an empty tree", err)
+		}
+		for _, path := range []string{"Electronics", "Electronics/Laptops", "Books"} {
+			if err := tree.Add(category(t, path)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := categories.Save(tree); err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.Add(category(t, "Toys")); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := categories.Load()
+		if got := paths(loaded.Categories()); err != nil || got != "Books, Electronics, Electronics/Laptops" {
+			t.Errorf("Load = %s, %v, want the tree as it was saved", got, err)
+		}
+
+		books := category(t, "Books")
+		if _, err := loaded.Move(category(t, "Electronics"), &books); err != nil {
+			t.Fatal(err)
+		}
+		if err := categories.Save(loaded); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err = categories.Load()
+		if got := paths(loaded.Categories()); err != nil || got != "Books, Books/Electronics, Books/Electronics/Laptops" {
+			t.Errorf("Load after saving again = %s, %v, want it replaced", got, err)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package persistence_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// inventoryRepositories runs test against both pairs of product and
+// inventory repositories
+func inventoryRepositories(t *testing.T, test func(t *testing.T, products repository.ProductRepository, inventories repository.InventoryRepository)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, persistence.NewMemoryProductRepository(), persistence.NewMemoryInventoryRepository())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db := openSQLite(t)
+		db.SetMaxOpenConns(1)
+		test(t, persistence.NewSQLProductRepository(db), persistence.NewSQLInventoryRepository(db))
+	})
+}
+
+// isInvalid reports whether err is a ValidationError
+func isInvalid(err error) bool {
+	var invalid model.ValidationError
+	return errors.As(err, &invalid)
+}
+
+// createProduct adds a Home product to the catalog
+func createProduct(t *testing.T, catalog *application.ProductService, name string) *model.Product {
+	t.Helper()
+	product, err := catalog.CreateProduct(application.CreateProductDTO{Name: name, Price: 30, Currency: "EUR", Category: "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
+
+func TestInventoryRepository(t *testing.T) {
+	inventoryRepositories(t, func(t *testing.T, _ repository.ProductRepository, inventories repository.InventoryRepository) {
+		id := model.NewProductID()
+		first, second := model.NewInventory(id), model.NewInventory(id)
+		if err := first.Restock(5); err != nil {
+			t.Fatal(err)
+		}
+		if err := inventories.Save(first); err != nil {
+			t.Fatal(err)
+		}
+		if err := inventories.Save(second); !errors.Is(err, repository.ErrConcurrentModification) {
+			t.Errorf("saving a second new inventory for the product = %v, want %v", err, repository.ErrConcurrentModification)
+		}
+
+		a, err := inventories.FindByProductID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := inventories.FindByProductID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Reserve("order-1", 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := inventories.Save(a); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Reserve("order-2", 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := inventories.Save(b); !errors.Is(err, repository.ErrConcurrentModification) {
+			t.Errorf("saving over a change made since loading = %v, want %v", err, repository.ErrConcurrentModification)
+		}
+		stored, err := inventories.FindByProductID(id)
+		if err != nil || stored.OnHand() != 5 || stored.Reserved() != 2 || stored.Version() != a.Version()+1 || stored.Reservations()["order-1"] != 2 {
+			t.Errorf("FindByProductID = %v, want the first change, with its reservation, at the next version", err)
+		}
+		if _, err := inventories.FindByProductID(model.NewProductID()); !errors.Is(err, repository.ErrInventoryNotFound) {
+			t.Errorf("FindByProductID of a product without one = %v, want %v", err, repository.ErrInventoryNotFound)
+		}
+	})
+}
+
+func TestInventoryService(t *testing.T) {
+	inventoryRepositories(t, func(t *testing.T, products repository.ProductRepository, inventories repository.InventoryRepository) {
+		catalog := application.NewProductService(products)
+		stock := application.NewInventoryService(products, inventories)
+		product := createProduct(t, catalog, "Chair")
+
+		if _, err := stock.Restock(model.NewProductID(), 5); !errors.Is(err, repository.ErrProductNotFound) {
+			t.Errorf("restocking a product not in the catalog = %v, want %v", err, repository.ErrProductNotFound)
+		}
+		if _, err := stock.Reserve(product.ID(), "order-1", 1); !errors.Is(err, model.ErrInsufficientStock) {
+			t.Errorf("reserving a product never restocked = %v, want %v", err, model.ErrInsufficientStock)
+		}
+		inventory, err := stock.Restock(product.ID(), 5)
+		if err != nil || inventory.OnHand() != 5 || inventory.Version() != 1 {
+			t.Fatalf("Restock = %v, want an inventory of 5 at version 1", err)
+		}
+		if inventory, err = stock.Reserve(product.ID(), "order-1", 2); err != nil || inventory.Available() != 3 {
+			t.Errorf("Reserve = %v, want 3 left available", err)
+		}
+
+		if _, err := catalog.DiscontinueProduct(product.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stock.Reserve(product.ID(), "order-2", 1); !isInvalid(err) {
+			t.Errorf("reserving a discontinued product = %v, want a ValidationError", err)
+		}
+		if _, err := stock.Restock(product.ID(), 1); !isInvalid(err) {
+			t.Errorf("restocking a discontinued product = %v, want a ValidationError", err)
+		}
+		if inventory, err = stock.Commit(product.ID(), "order-1"); err != nil || inventory.OnHand() != 3 || inventory.Reserved() != 0 {
+			t.Errorf("committing what was reserved before it was discontinued = %v, want it shipped", err)
+		}
+	})
+}
+
+// TestInventoryConcurrentReservations has 50 orders reserve from a stock of 20 at
+// once, then ships half the reservations and cancels the rest while
+// restocking
+func TestInventoryConcurrentReservations(t *testing.T) {
+	inventoryRepositories(t, func(t *testing.T, products repository.ProductRepository, inventories repository.InventoryRepository) {
+		stock := application.NewInventoryService(products, inventories)
+		product := createProduct(t, application.NewProductService(products), "Lamp")
+		if _, err := stock.Restock(product.ID(), 20); err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		results := make([]error, 50)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, results[i] = stock.Reserve(product.ID(), model.ReservationID(fmt.Sprintf("order-%02d", i)), 1)
+			}(i)
+		}
+		wg.Wait()
+		var reserved []model.ReservationID
+		insufficient := 0
+		for i, err := range results {
+			switch {
+			case err == nil:
+				reserved = append(reserved, model.ReservationID(fmt.Sprintf("order-%02d", i)))
+			case errors.Is(err, model.ErrInsufficientStock):
+				insufficient++
+			default:
+				t.Errorf("order-%02d: %v", i, err)
+			}
+		}
+		if len(reserved) != 20 || insufficient != 30 {
+			t.Fatalf("50 orders for 20 in stock: %d reserved, %d ErrInsufficientStock, want 20 and 30", len(reserved), insufficient)
+		}
+		inventory, err := stock.GetInventory(product.ID())
+		if err != nil || inventory.Reserved() != 20 || inventory.Available() != 0 || len(inventory.Reservations()) != 20 {
+			t.Errorf("GetInventory = %v, want exactly those 20 reservations stored", err)
+		}
+
+		failed := make(chan error, len(reserved)+5)
+		for i, id := range reserved {
+			wg.Add(1)
+			go func(i int, id model.ReservationID) {
+				defer wg.Done()
+				var err error
+				if i%2 == 0 {
+					_, err = stock.Commit(product.ID(), id)
+				} else {
+					_, err = stock.Release(product.ID(), id)
+				}
+				if err != nil {
+					failed <- err
+				}
+			}(i, id)
+		}
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := stock.Restock(product.ID(), 2); err != nil {
+					failed <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(failed)
+		for err := range failed {
+			t.Errorf("a commit, release or restock failed: %v", err)
+		}
+		inventory, err = stock.GetInventory(product.ID())
+		if err != nil || inventory.OnHand() != 20 || inventory.Reserved() != 0 || inventory.Available() != 20 {
+			t.Errorf("after 10 commits, 10 releases and 5 restocks of 2: %v, want 20 on hand, all of it available", err)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package persistence
+
+import (
+	"sync"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// MemoryCategoryRepository keeps the category tree in memory. Like the
+// other memory repositories, it stores a copy.
+type MemoryCategoryRepository struct {
+	mu         sync.RWMutex
+	categories []model.Category
+}
+
+var _ repository.CategoryRepository = (*MemoryCategoryRepository)(nil)
+
+func NewMemoryCategoryRepository() *MemoryCategoryRepository {
+	return &MemoryCategoryRepository{}
+}
+
+func (r *MemoryCategoryRepository) Load() (*model.CategoryTree, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return model.ReconstructCategoryTree(r.categories), nil
+}
+
+func (r *MemoryCategoryRepository) Save(tree *model.CategoryTree) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.categories = tree.Categories()
+	return nil
+}
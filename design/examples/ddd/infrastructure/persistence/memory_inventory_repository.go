@@ -0,0 +1,52 @@
+package persistence
+
+import (
+	"sync"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// MemoryInventoryRepository keeps inventories in memory, with the same
+// version check on Save as SQLInventoryRepository
+type MemoryInventoryRepository struct {
+	mu          sync.Mutex
+	inventories map[model.ProductID]storedInventory
+}
+
+type storedInventory struct {
+	onHand       int
+	reservations map[model.ReservationID]int
+	version      int64
+}
+
+var _ repository.InventoryRepository = (*MemoryInventoryRepository)(nil)
+
+func NewMemoryInventoryRepository() *MemoryInventoryRepository {
+	return &MemoryInventoryRepository{inventories: map[model.ProductID]storedInventory{}}
+}
+
+func (r *MemoryInventoryRepository) Save(inventory *model.Inventory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.inventories[inventory.ProductID()]
+	if ok && stored.version != inventory.Version() || !ok && inventory.Version() != 0 {
+		return repository.ErrConcurrentModification
+	}
+	r.inventories[inventory.ProductID()] = storedInventory{
+		onHand:       inventory.OnHand(),
+		reservations: inventory.Reservations(),
+		version:      inventory.Version() + 1,
+	}
+	return nil
+}
+
+func (r *MemoryInventoryRepository) FindByProductID(id model.ProductID) (*model.Inventory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.inventories[id]
+	if !ok {
+		return nil, repository.ErrInventoryNotFound
+	}
+	return model.ReconstructInventory(id, stored.onHand, stored.reservations, stored.version), nil
+}
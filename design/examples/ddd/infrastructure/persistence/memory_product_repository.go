@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// MemoryProductRepository keeps products in memory, for tests and for
+// running the example without a database. It stores copies, so a product
+// changed after Save is not changed in the repository until it is saved
+// again, as with SQLProductRepository: the model never changes a product's
+// variants in place, so a copy does not share them. Like it, it does not
+// keep the product's events.
+type MemoryProductRepository struct {
+	mu       sync.RWMutex
+	products map[model.ProductID]model.Product
+}
+
+var _ repository.ProductRepository = (*MemoryProductRepository)(nil)
+
+func NewMemoryProductRepository() *MemoryProductRepository {
+	return &MemoryProductRepository{products: map[model.ProductID]model.Product{}}
+}
+
+// Save returns ErrSKUTaken if another product has a variant with one of
+// the product's SKUs, as SQLProductRepository does
+func (r *MemoryProductRepository) Save(product *model.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, variant := range product.Variants() {
+		if other, ok := r.findBySKU(variant.SKU()); ok && other.ID() != product.ID() {
+			return repository.ErrSKUTaken
+		}
+	}
+	stored := *product
+	stored.ClearEvents()
+	r.products[product.ID()] = stored
+	return nil
+}
+
+func (r *MemoryProductRepository) FindByID(id model.ProductID) (*model.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	product, ok := r.products[id]
+	if !ok {
+		return nil, repository.ErrProductNotFound
+	}
+	return &product, nil
+}
+
+func (r *MemoryProductRepository) FindBySKU(sku model.SKU) (*model.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	product, ok := r.findBySKU(sku)
+	if !ok {
+		return nil, repository.ErrProductNotFound
+	}
+	return &product, nil
+}
+
+func (r *MemoryProductRepository) findBySKU(sku model.SKU) (model.Product, bool) {
+	for _, product := range r.products {
+		if _, err := product.Variant(sku); err == nil {
+			return product, true
+		}
+	}
+	return model.Product{}, false
+}
+
+// FindAll returns the products oldest first, as SQLProductRepository does
+func (r *MemoryProductRepository) FindAll() ([]*model.Product, error) {
+	return r.find(func(*model.Product) bool { return true }), nil
+}
+
+func (r *MemoryProductRepository) FindInCategory(category model.Category) ([]*model.Product, error) {
+	return r.find(func(product *model.Product) bool {
+		return category.Contains(product.Category())
+	}), nil
+}
+
+// find returns copies of the products that match, oldest first
+func (r *MemoryProductRepository) find(match func(*model.Product) bool) []*model.Product {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	products := make([]*model.Product, 0, len(r.products))
+	for _, product := range r.products {
+		product := product
+		if match(&product) {
+			products = append(products, &product)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool {
+		a, b := products[i], products[j]
+		if !a.CreatedAt().Equal(b.CreatedAt()) {
+			return a.CreatedAt().Before(b.CreatedAt())
+		}
+		return a.ID().String() < b.ID().String()
+	})
+	return products
+}
+
+func (r *MemoryProductRepository) Delete(id model.ProductID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.products[id]; !ok {
+		return repository.ErrProductNotFound
+	}
+	delete(r.products, id)
+	return nil
+}
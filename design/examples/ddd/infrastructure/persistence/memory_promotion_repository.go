@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// MemoryPromotionRepository keeps promotion rules in memory. It stores
+// copies, as MemoryProductRepository does.
+type MemoryPromotionRepository struct {
+	mu         sync.RWMutex
+	promotions map[model.PromotionID]model.Promotion
+}
+
+var _ repository.PromotionRepository = (*MemoryPromotionRepository)(nil)
+
+func NewMemoryPromotionRepository() *MemoryPromotionRepository {
+	return &MemoryPromotionRepository{promotions: map[model.PromotionID]model.Promotion{}}
+}
+
+func (r *MemoryPromotionRepository) Save(promotion *model.Promotion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promotions[promotion.ID()] = *promotion
+	return nil
+}
+
+func (r *MemoryPromotionRepository) FindByID(id model.PromotionID) (*model.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	promotion, ok := r.promotions[id]
+	if !ok {
+		return nil, repository.ErrPromotionNotFound
+	}
+	return &promotion, nil
+}
+
+// FindActive returns the promotions in effect at t, by ID
+func (r *MemoryPromotionRepository) FindActive(t time.Time) ([]*model.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var active []*model.Promotion
+	for _, promotion := range r.promotions {
+		if promotion.Terms().Period.Contains(t) {
+			promotion := promotion
+			active = append(active, &promotion)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ID().String() < active[j].ID().String() })
+	return active, nil
+}
+
+func (r *MemoryPromotionRepository) Delete(id model.PromotionID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.promotions[id]; !ok {
+		return repository.ErrPromotionNotFound
+	}
+	delete(r.promotions, id)
+	return nil
+}
@@ -0,0 +1,174 @@
+package persistence_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// openSQLite opens a database in t.TempDir() with the schema created
+func openSQLite(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "products.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := persistence.CreateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// productRepositories runs test against both ProductRepository
+// implementations
+func productRepositories(t *testing.T, test func(t *testing.T, repo repository.ProductRepository)) {
+	t.Run("memory", func(t *testing.T) { test(t, persistence.NewMemoryProductRepository()) })
+	t.Run("sqlite", func(t *testing.T) { test(t, persistence.NewSQLProductRepository(openSQLite(t))) })
+}
+
+// newProduct returns an Electronics product priced in USD
+func newProduct(t *testing.T, name string, amount float64) *model.Product {
+	t.Helper()
+	price, err := model.NewMoney(amount, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	category, err := model.NewCategory("Electronics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := model.NewProduct(name, "A "+name, price, category)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
+
+// sameProduct reports whether a and b are the same product in every field
+func sameProduct(a, b *model.Product) bool {
+	return a.ID() == b.ID() && a.Name() == b.Name() && a.Description() == b.Description() &&
+		a.Price() == b.Price() && a.Category() == b.Category() &&
+		a.CreatedAt().Equal(b.CreatedAt()) && a.UpdatedAt().Equal(b.UpdatedAt())
+}
+
+func TestProductRepository(t *testing.T) {
+	productRepositories(t, func(t *testing.T, repo repository.ProductRepository) {
+		laptop := newProduct(t, "Laptop", 999.99)
+		time.Sleep(time.Millisecond)
+		mouse := newProduct(t, "Mouse", 25)
+		for _, p := range []*model.Product{mouse, laptop} {
+			if err := repo.Save(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if found, err := repo.FindByID(laptop.ID()); err != nil || !sameProduct(found, laptop) {
+			t.Errorf("FindByID = %v, want the product with its id, price, category and timestamps", err)
+		}
+		if all, err := repo.FindAll(); err != nil || len(all) != 2 || all[0].ID() != laptop.ID() || all[1].ID() != mouse.ID() {
+			t.Errorf("FindAll = %d products, %v, want both, oldest first", len(all), err)
+		}
+
+		if err := laptop.UpdateInfo("Laptop Pro", "Faster"); err != nil {
+			t.Fatal(err)
+		}
+		if stale, _ := repo.FindByID(laptop.ID()); stale == nil || stale.Name() != "Laptop" {
+			t.Error("changing a product changed the stored one before it was saved")
+		}
+		if err := repo.Save(laptop); err != nil {
+			t.Fatal(err)
+		}
+		found, err := repo.FindByID(laptop.ID())
+		if all, _ := repo.FindAll(); err != nil || !sameProduct(found, laptop) || len(all) != 2 {
+			t.Errorf("after saving again, FindByID = %v with %d stored, want it replaced", err, len(all))
+		}
+
+		if _, err := repo.FindByID(model.NewProductID()); !errors.Is(err, repository.ErrProductNotFound) {
+			t.Errorf("FindByID of an unknown id = %v, want %v", err, repository.ErrProductNotFound)
+		}
+		if err := repo.Delete(mouse.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.FindByID(mouse.ID()); !errors.Is(err, repository.ErrProductNotFound) {
+			t.Errorf("FindByID of a deleted product = %v, want %v", err, repository.ErrProductNotFound)
+		}
+		if err := repo.Delete(mouse.ID()); !errors.Is(err, repository.ErrProductNotFound) {
+			t.Errorf("deleting it again = %v, want %v", err, repository.ErrProductNotFound)
+		}
+	})
+}
+
+// TestProductServiceOnRepository runs the application service on top of
+// each repository
+func TestProductServiceOnRepository(t *testing.T) {
+	productRepositories(t, func(t *testing.T, repo repository.ProductRepository) {
+		service := application.NewProductService(repo)
+		created, err := service.CreateProduct(application.CreateProductDTO{
+			Name: "Monitor", Price: 200, Currency: "USD", Category: "Electronics",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.ApplyDiscountToProduct(created.ID(), 10); err != nil {
+			t.Fatal(err)
+		}
+		discounted, err := service.GetProduct(created.ID())
+		if err != nil || discounted.Price().Amount() != 180 || !discounted.CreatedAt().Equal(created.CreatedAt()) {
+			t.Errorf("after a 10%% discount, GetProduct = %v, want it at 180, created when it was", err)
+		}
+		if _, err := service.DiscontinueProduct(created.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if discontinued, err := repo.FindByID(created.ID()); err != nil || !discontinued.Discontinued() || len(discontinued.Events()) != 0 {
+			t.Errorf("FindByID of a discontinued product = %v, want it stored as such, without its events", err)
+		}
+	})
+}
+
+func TestSQLProductRepositoryRejectsInvalidRows(t *testing.T) {
+	db := openSQLite(t)
+	id := model.NewProductID()
+	if _, err := db.Exec(`INSERT INTO products (id, name, price_minor, currency, category, created_at, updated_at)
+		VALUES (?, 'Broken', -5, 'USD', 'Electronics', ?, ?)`, id.String(), time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := persistence.NewSQLProductRepository(db).FindByID(id); err == nil || errors.Is(err, repository.ErrProductNotFound) {
+		t.Errorf("FindByID of a row with a negative price = %v, want an error, not a product", err)
+	}
+}
+
+// TestCreateSchemaMigratesAnOlderTable runs CreateSchema on a table from
+// before discontinued products and prices in minor units
+func TestCreateSchemaMigratesAnOlderTable(t *testing.T) {
+	old, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "old.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	old.MustExec(`CREATE TABLE products (id TEXT PRIMARY KEY, name TEXT NOT NULL, description TEXT NOT NULL DEFAULT '',
+		price REAL NOT NULL, currency TEXT NOT NULL, category TEXT NOT NULL, created_at TIMESTAMP NOT NULL, updated_at TIMESTAMP NOT NULL)`)
+	mouse := newProduct(t, "Mouse", 25)
+	old.MustExec(`INSERT INTO products VALUES (?, 'Mouse', '', 25, 'USD', 'Electronics', ?, ?)`,
+		mouse.ID().String(), mouse.CreatedAt().UTC(), mouse.UpdatedAt().UTC())
+
+	if err := persistence.CreateSchema(old); err != nil {
+		t.Fatal(err)
+	}
+	found, err := persistence.NewSQLProductRepository(old).FindByID(mouse.ID())
+	if err != nil || found.Discontinued() || found.Price().Minor() != 2500 {
+		t.Errorf("FindByID after CreateSchema = %v, want the product, not discontinued, at 2500 minor units", err)
+	}
+	if err := persistence.CreateSchema(old); err != nil {
+		t.Errorf("CreateSchema again = %v, want it to run twice", err)
+	}
+}
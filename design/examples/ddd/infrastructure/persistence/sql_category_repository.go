@@ -0,0 +1,66 @@
+package persistence
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// CategorySchema creates the categories table: one row per category, by its
+// path. A category's parent is its path without the last name, so the
+// hierarchy needs no column of its own.
+const CategorySchema = `
+CREATE TABLE IF NOT EXISTS categories (
+	path TEXT PRIMARY KEY
+)`
+
+// SQLCategoryRepository stores the category tree in the categories table
+type SQLCategoryRepository struct {
+	db *sqlx.DB
+}
+
+var _ repository.CategoryRepository = (*SQLCategoryRepository)(nil)
+
+func NewSQLCategoryRepository(db *sqlx.DB) *SQLCategoryRepository {
+	return &SQLCategoryRepository{db: db}
+}
+
+// Load rebuilds the tree from its rows. A row that is not a valid path is
+// reported as an error rather than loaded.
+func (r *SQLCategoryRepository) Load() (*model.CategoryTree, error) {
+	var paths []string
+	if err := r.db.Select(&paths, `SELECT path FROM categories`); err != nil {
+		return nil, err
+	}
+	categories := make([]model.Category, 0, len(paths))
+	for _, path := range paths {
+		category, err := model.NewCategory(path)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return model.ReconstructCategoryTree(categories), nil
+}
+
+// Save replaces the stored tree in one transaction, so a move is never seen
+// half done
+func (r *SQLCategoryRepository) Save(tree *model.CategoryTree) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM categories`); err != nil {
+		return err
+	}
+	insert := tx.Rebind(`INSERT INTO categories (path) VALUES (?)`)
+	for _, category := range tree.Categories() {
+		if _, err := tx.Exec(insert, category.Path()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,118 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// InventorySchema creates the inventory tables: one row per product, with
+// the version Save checks, and one per reservation
+const InventorySchema = `
+CREATE TABLE IF NOT EXISTS inventories (
+	product_id TEXT PRIMARY KEY,
+	on_hand    INTEGER NOT NULL CHECK (on_hand >= 0),
+	version    INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+	product_id     TEXT NOT NULL REFERENCES inventories (product_id),
+	reservation_id TEXT NOT NULL,
+	quantity       INTEGER NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (product_id, reservation_id)
+)`
+
+type reservationRow struct {
+	ReservationID string `db:"reservation_id"`
+	Quantity      int    `db:"quantity"`
+}
+
+// SQLInventoryRepository stores inventories in the inventories and
+// inventory_reservations tables. Save writes both in one transaction, and
+// FindByProductID reads both in one, so an inventory is never seen half
+// saved.
+type SQLInventoryRepository struct {
+	db *sqlx.DB
+}
+
+var _ repository.InventoryRepository = (*SQLInventoryRepository)(nil)
+
+func NewSQLInventoryRepository(db *sqlx.DB) *SQLInventoryRepository {
+	return &SQLInventoryRepository{db: db}
+}
+
+// Save inserts a new inventory, or updates the stored one if it is still at
+// the version the inventory was loaded at, replacing its reservations
+func (r *SQLInventoryRepository) Save(inventory *model.Inventory) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id := inventory.ProductID().String()
+	var result sql.Result
+	if inventory.Version() == 0 {
+		result, err = tx.Exec(tx.Rebind(`
+			INSERT INTO inventories (product_id, on_hand, version) VALUES (?, ?, 1)
+			ON CONFLICT (product_id) DO NOTHING`), id, inventory.OnHand())
+	} else {
+		result, err = tx.Exec(tx.Rebind(`
+			UPDATE inventories SET on_hand = ?, version = version + 1
+			WHERE product_id = ? AND version = ?`), inventory.OnHand(), id, inventory.Version())
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrConcurrentModification
+	}
+
+	if _, err := tx.Exec(tx.Rebind(`DELETE FROM inventory_reservations WHERE product_id = ?`), id); err != nil {
+		return err
+	}
+	reservations := inventory.Reservations()
+	for _, reservation := range inventory.ReservationIDs() {
+		if _, err := tx.Exec(tx.Rebind(`
+			INSERT INTO inventory_reservations (product_id, reservation_id, quantity) VALUES (?, ?, ?)`),
+			id, string(reservation), reservations[reservation]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLInventoryRepository) FindByProductID(id model.ProductID) (*model.Inventory, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var row struct {
+		OnHand  int   `db:"on_hand"`
+		Version int64 `db:"version"`
+	}
+	err = tx.Get(&row, tx.Rebind(`SELECT on_hand, version FROM inventories WHERE product_id = ?`), id.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrInventoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rows []reservationRow
+	if err := tx.Select(&rows, tx.Rebind(`
+		SELECT reservation_id, quantity FROM inventory_reservations WHERE product_id = ?`), id.String()); err != nil {
+		return nil, err
+	}
+	reservations := make(map[model.ReservationID]int, len(rows))
+	for _, reservation := range rows {
+		reservations[model.ReservationID(reservation.ReservationID)] = reservation.Quantity
+	}
+	return model.ReconstructInventory(id, row.OnHand, reservations, row.Version), nil
+}
@@ -0,0 +1,302 @@
+// Package persistence implements the domain repositories: in SQL with sqlx,
+// and in memory.
+package persistence
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// Schema creates the products table. Queries are written with ? and rebound
+// for the driver, so the same repository runs on SQLite and PostgreSQL. The
+// price is in the currency's minor units, as Money keeps it, so it is stored
+// and read back without a float in between.
+const Schema = `
+CREATE TABLE IF NOT EXISTS products (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	description  TEXT NOT NULL DEFAULT '',
+	price_minor  INTEGER NOT NULL,
+	currency     TEXT NOT NULL,
+	category     TEXT NOT NULL,
+	created_at   TIMESTAMP NOT NULL,
+	updated_at   TIMESTAMP NOT NULL,
+	discontinued BOOLEAN NOT NULL DEFAULT FALSE
+)`
+
+// productRow is a products row. The aggregate keeps its fields unexported,
+// so rows are mapped to it here, and back with model.ReconstructProduct.
+type productRow struct {
+	ID           string    `db:"id"`
+	Name         string    `db:"name"`
+	Description  string    `db:"description"`
+	PriceMinor   int64     `db:"price_minor"`
+	Currency     string    `db:"currency"`
+	Category     string    `db:"category"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+	Discontinued bool      `db:"discontinued"`
+}
+
+func toRow(product *model.Product) productRow {
+	return productRow{
+		ID:           product.ID().String(),
+		Name:         product.Name(),
+		Description:  product.Description(),
+		PriceMinor:   product.Price().Minor(),
+		Currency:     product.Price().Currency(),
+		Category:     product.Category().Path(),
+		CreatedAt:    product.CreatedAt().UTC(),
+		UpdatedAt:    product.UpdatedAt().UTC(),
+		Discontinued: product.Discontinued(),
+	}
+}
+
+// toProduct rebuilds the aggregate, going through the value objects'
+// constructors so a row that breaks their rules is reported, not loaded
+func (row productRow) toProduct(variants []model.Variant) (*model.Product, error) {
+	id, err := model.ParseProductID(row.ID)
+	if err != nil {
+		return nil, fmt.Errorf("product %q: %w", row.ID, err)
+	}
+	price, err := model.NewMoneyFromMinor(row.PriceMinor, row.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("product %s: %w", row.ID, err)
+	}
+	category, err := model.NewCategory(row.Category)
+	if err != nil {
+		return nil, fmt.Errorf("product %s: %w", row.ID, err)
+	}
+	return model.ReconstructProduct(id, row.Name, row.Description, price, category, row.CreatedAt, row.UpdatedAt, row.Discontinued, variants), nil
+}
+
+// SQLProductRepository stores products in the products table, and their
+// variants in product_variants. Save writes both in one transaction, and
+// the queries read both in one, so a product is never seen half saved.
+type SQLProductRepository struct {
+	db *sqlx.DB
+}
+
+var _ repository.ProductRepository = (*SQLProductRepository)(nil)
+
+func NewSQLProductRepository(db *sqlx.DB) *SQLProductRepository {
+	return &SQLProductRepository{db: db}
+}
+
+// CreateSchema creates the products, variants, inventory and categories
+// tables if they do not exist. It adds the discontinued column to a products
+// table created before there was one, and moves the prices of tables created
+// when they were a REAL price column to price_minor.
+func CreateSchema(db *sqlx.DB) error {
+	for _, schema := range []string{Schema, VariantSchema, InventorySchema, CategorySchema} {
+		if _, err := db.Exec(schema); err != nil {
+			return err
+		}
+	}
+	columns, err := columnsOf(db, "products")
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(columns, "discontinued") {
+		if _, err := db.Exec(`ALTER TABLE products ADD COLUMN discontinued BOOLEAN NOT NULL DEFAULT FALSE`); err != nil {
+			return err
+		}
+	}
+	if err := migratePrices(db, "products", "id"); err != nil {
+		return err
+	}
+	return migratePrices(db, "product_variants", "sku")
+}
+
+func columnsOf(db *sqlx.DB, table string) ([]string, error) {
+	rows, err := db.Query(`SELECT * FROM ` + table + ` LIMIT 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// migratePrices replaces the price column of table, in units of the
+// currency, with price_minor, in one transaction. Each price goes through
+// NewMoney, so it is rounded to the currency's minor unit as Money rounds,
+// and a price Money would not take stops the migration.
+func migratePrices(db *sqlx.DB, table, key string) error {
+	columns, err := columnsOf(db, table)
+	if err != nil || !slices.Contains(columns, "price") {
+		return err
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var rows []struct {
+		Key      string  `db:"pk"`
+		Price    float64 `db:"price"`
+		Currency string  `db:"currency"`
+	}
+	if err := tx.Select(&rows, `SELECT `+key+` AS pk, price, currency FROM `+table); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	update := tx.Rebind(`UPDATE ` + table + ` SET price_minor = ? WHERE ` + key + ` = ?`)
+	for _, row := range rows {
+		price, err := model.NewMoney(row.Price, row.Currency)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", table, row.Key, err)
+		}
+		if _, err := tx.Exec(update, price.Minor(), row.Key); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`ALTER TABLE ` + table + ` DROP COLUMN price`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Save inserts the product, or replaces the stored one with the same id,
+// variants included. Its creation time is kept as first stored. The SKU
+// key backs up the check for another product's variant with a SKU, which
+// gives ErrSKUTaken.
+func (r *SQLProductRepository) Save(product *model.Product) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := tx.Rebind(`
+		INSERT INTO products (id, name, description, price_minor, currency, category, created_at, updated_at, discontinued)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			description = excluded.description,
+			price_minor = excluded.price_minor,
+			currency = excluded.currency,
+			category = excluded.category,
+			updated_at = excluded.updated_at,
+			discontinued = excluded.discontinued`)
+	row := toRow(product)
+	_, err = tx.Exec(query, row.ID, row.Name, row.Description, row.PriceMinor, row.Currency, row.Category, row.CreatedAt, row.UpdatedAt, row.Discontinued)
+	if err != nil {
+		return err
+	}
+	if err := saveVariants(tx, product); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLProductRepository) FindByID(id model.ProductID) (*model.Product, error) {
+	products, err := r.selectProducts(r.db.Rebind(`SELECT * FROM products WHERE id = ?`), id.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, repository.ErrProductNotFound
+	}
+	return products[0], nil
+}
+
+// FindBySKU returns the product with a variant of sku, retired or not
+func (r *SQLProductRepository) FindBySKU(sku model.SKU) (*model.Product, error) {
+	products, err := r.selectProducts(r.db.Rebind(`
+		SELECT * FROM products
+		WHERE id IN (SELECT product_id FROM product_variants WHERE sku = ?)`), sku.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, repository.ErrProductNotFound
+	}
+	return products[0], nil
+}
+
+// FindAll returns the products oldest first
+func (r *SQLProductRepository) FindAll() ([]*model.Product, error) {
+	return r.selectProducts(`SELECT * FROM products ORDER BY created_at, id`)
+}
+
+// FindInCategory returns the products whose category's path is the
+// category's, or starts with it and a separator. Paths are compared in
+// lower case, as the model compares categories (SQLite lowers ASCII letters
+// only). The path is escaped, so a name with % or _ matches only itself.
+func (r *SQLProductRepository) FindInCategory(category model.Category) ([]*model.Product, error) {
+	path := strings.ToLower(category.Path())
+	under := likeEscaper.Replace(path+model.CategorySeparator) + "%"
+	return r.selectProducts(r.db.Rebind(`
+		SELECT * FROM products
+		WHERE lower(category) = ? OR lower(category) LIKE ? ESCAPE '\'
+		ORDER BY created_at, id`), path, under)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// selectProducts returns the products the query selects, with their
+// variants
+func (r *SQLProductRepository) selectProducts(query string, args ...any) ([]*model.Product, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var rows []productRow
+	if err := tx.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	variants, err := loadVariants(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	products := make([]*model.Product, 0, len(rows))
+	for _, row := range rows {
+		product, err := row.toProduct(variants[row.ID])
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+// Delete removes the product and its variants
+func (r *SQLProductRepository) Delete(id model.ProductID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(tx.Rebind(`DELETE FROM product_variants WHERE product_id = ?`), id.String()); err != nil {
+		return err
+	}
+	result, err := tx.Exec(tx.Rebind(`DELETE FROM products WHERE id = ?`), id.String())
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrProductNotFound
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,121 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+)
+
+// VariantSchema creates the product_variants table: one row per variant,
+// retired ones included, at its position in the product. The SKU is the
+// key, so no two products' variants share one. The price is in minor
+// units, as the product's is, and attributes are a JSON object.
+const VariantSchema = `
+CREATE TABLE IF NOT EXISTS product_variants (
+	sku         TEXT PRIMARY KEY,
+	product_id  TEXT NOT NULL REFERENCES products (id),
+	position    INTEGER NOT NULL,
+	price_minor INTEGER NOT NULL,
+	currency    TEXT NOT NULL,
+	attributes  TEXT NOT NULL,
+	retired     BOOLEAN NOT NULL DEFAULT FALSE
+)`
+
+type variantRow struct {
+	SKU        string `db:"sku"`
+	ProductID  string `db:"product_id"`
+	Position   int    `db:"position"`
+	PriceMinor int64  `db:"price_minor"`
+	Currency   string `db:"currency"`
+	Attributes string `db:"attributes"`
+	Retired    bool   `db:"retired"`
+}
+
+// toVariant rebuilds a variant through the value objects' constructors, so
+// a row that breaks their rules is reported, not loaded
+func (row variantRow) toVariant() (model.Variant, error) {
+	sku, err := model.NewSKU(row.SKU)
+	if err != nil {
+		return model.Variant{}, fmt.Errorf("variant %q: %w", row.SKU, err)
+	}
+	price, err := model.NewMoneyFromMinor(row.PriceMinor, row.Currency)
+	if err != nil {
+		return model.Variant{}, fmt.Errorf("variant %s: %w", row.SKU, err)
+	}
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(row.Attributes), &attributes); err != nil {
+		return model.Variant{}, fmt.Errorf("variant %s: %w", row.SKU, err)
+	}
+	return model.ReconstructVariant(sku, price, attributes, row.Retired), nil
+}
+
+// saveVariants replaces the stored variants of product with its own, in
+// tx. A SKU another product has is ErrSKUTaken.
+func saveVariants(tx *sqlx.Tx, product *model.Product) error {
+	id := product.ID().String()
+	variants := product.Variants()
+	if len(variants) > 0 {
+		skus := make([]string, 0, len(variants))
+		for _, variant := range variants {
+			skus = append(skus, variant.SKU().String())
+		}
+		query, args, err := sqlx.In(`SELECT COUNT(*) FROM product_variants WHERE sku IN (?) AND product_id <> ?`, skus, id)
+		if err != nil {
+			return err
+		}
+		var taken int
+		if err := tx.Get(&taken, tx.Rebind(query), args...); err != nil {
+			return err
+		}
+		if taken > 0 {
+			return repository.ErrSKUTaken
+		}
+	}
+
+	if _, err := tx.Exec(tx.Rebind(`DELETE FROM product_variants WHERE product_id = ?`), id); err != nil {
+		return err
+	}
+	insert := tx.Rebind(`
+		INSERT INTO product_variants (sku, product_id, position, price_minor, currency, attributes, retired)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	for i, variant := range variants {
+		attributes, err := json.Marshal(variant.Attributes())
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(insert, variant.SKU().String(), id, i, variant.Price().Minor(), variant.Price().Currency(), string(attributes), variant.Retired())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadVariants returns the variants of the products with ids, by product
+// id, each product's in order
+func loadVariants(tx *sqlx.Tx, ids []string) (map[string][]model.Variant, error) {
+	variants := map[string][]model.Variant{}
+	if len(ids) == 0 {
+		return variants, nil
+	}
+	query, args, err := sqlx.In(`SELECT * FROM product_variants WHERE product_id IN (?) ORDER BY product_id, position`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var rows []variantRow
+	if err := tx.Select(&rows, tx.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		variant, err := row.toVariant()
+		if err != nil {
+			return nil, err
+		}
+		variants[row.ProductID] = append(variants[row.ProductID], variant)
+	}
+	return variants, nil
+}
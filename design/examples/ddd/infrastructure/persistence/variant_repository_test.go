@@ -0,0 +1,156 @@
+package persistence_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/ddd-example/domain/model"
+	"github.com/dong-tran/docs/ddd-example/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+)
+
+// addVariant adds a variant at amount USD, failing the test if it is refused
+func addVariant(t *testing.T, product *model.Product, code string, amount float64, attributes map[string]string) {
+	t.Helper()
+	price, err := model.NewMoney(amount, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := product.AddVariant(mustSKU(t, code), price, attributes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// retireVariant retires the variant of code, failing the test if it cannot
+func retireVariant(t *testing.T, product *model.Product, code string) {
+	t.Helper()
+	if err := product.RetireVariant(mustSKU(t, code)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustSKU returns the SKU code
+func mustSKU(t *testing.T, code string) model.SKU {
+	t.Helper()
+	sku, err := model.NewSKU(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sku
+}
+
+// skus lists a product's variants as SKU, or SKU* if retired
+func skus(product *model.Product) string {
+	var names []string
+	for _, variant := range product.Variants() {
+		name := variant.SKU().String()
+		if variant.Retired() {
+			name += "*"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+// sameVariants reports whether a and b have the same variants, in every field
+func sameVariants(a, b *model.Product) bool {
+	return fmt.Sprint(a.Variants()) == fmt.Sprint(b.Variants())
+}
+
+func TestProductVariants(t *testing.T) {
+	productRepositories(t, func(t *testing.T, repo repository.ProductRepository) {
+		laptop := newProduct(t, "Laptop", 1000)
+		addVariant(t, laptop, "LAP-13-SLV", 1100, map[string]string{"color": "silver", "memory": "16GB"})
+		addVariant(t, laptop, "LAP-13-GRY", 1150.5, map[string]string{"color": "gray"})
+		addVariant(t, laptop, "LAP-13-BLK", 1200, map[string]string{"color": "black"})
+		retireVariant(t, laptop, "LAP-13-GRY")
+		if err := repo.Save(laptop); err != nil {
+			t.Fatal(err)
+		}
+		found, err := repo.FindByID(laptop.ID())
+		if err != nil || !sameVariants(found, laptop) || skus(found) != "LAP-13-SLV LAP-13-GRY* LAP-13-BLK" {
+			t.Fatalf("FindByID = %v, want the product with its variants, in order, with their prices, attributes and retirement", err)
+		}
+
+		addVariant(t, laptop, "LAP-13-RED", 1000, map[string]string{"color": "red"})
+		if found, _ = repo.FindByID(laptop.ID()); skus(found) != "LAP-13-SLV LAP-13-GRY* LAP-13-BLK" {
+			t.Errorf("a variant added after Save is stored: %s", skus(found))
+		}
+		retireVariant(t, laptop, "LAP-13-BLK")
+		if err := repo.Save(laptop); err != nil {
+			t.Fatal(err)
+		}
+		if found, _ = repo.FindByID(laptop.ID()); !sameVariants(found, laptop) || skus(found) != "LAP-13-SLV LAP-13-GRY* LAP-13-BLK* LAP-13-RED" {
+			t.Errorf("after saving again, the variants are %s, want the whole aggregate stored", skus(found))
+		}
+
+		if bySKU, err := repo.FindBySKU(mustSKU(t, "lap-13-gry")); err != nil || bySKU.ID() != laptop.ID() {
+			t.Errorf("FindBySKU of a retired variant = %v, want its product", err)
+		}
+		if _, err := repo.FindBySKU(mustSKU(t, "LAP-99")); !errors.Is(err, repository.ErrProductNotFound) {
+			t.Errorf("FindBySKU of an unknown SKU = %v, want %v", err, repository.ErrProductNotFound)
+		}
+	})
+}
+
+func TestSKUsAreUniqueAcrossProducts(t *testing.T) {
+	productRepositories(t, func(t *testing.T, repo repository.ProductRepository) {
+		laptop := newProduct(t, "Laptop", 1000)
+		addVariant(t, laptop, "LAP-13-SLV", 1100, map[string]string{"color": "silver"})
+		addVariant(t, laptop, "LAP-13-GRY", 1150, map[string]string{"color": "gray"})
+		retireVariant(t, laptop, "LAP-13-GRY")
+		if err := repo.Save(laptop); err != nil {
+			t.Fatal(err)
+		}
+
+		other := newProduct(t, "Other laptop", 1000)
+		if err := repo.Save(other); err != nil {
+			t.Fatal(err)
+		}
+		addVariant(t, other, "LAP-13-GRY", 900, map[string]string{"color": "gray"})
+		cheaper, err := model.NewMoney(800, "USD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := other.ChangePrice(cheaper); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Save(other); !errors.Is(err, repository.ErrSKUTaken) {
+			t.Errorf("saving another product's SKU, retired = %v, want %v", err, repository.ErrSKUTaken)
+		}
+		if stored, _ := repo.FindByID(other.ID()); len(stored.Variants()) != 0 || stored.Price().Minor() != 100000 {
+			t.Errorf("after the refused save, the product has %s at %v, want nothing of that save stored", skus(stored), stored.Price())
+		}
+
+		if all, err := repo.FindAll(); err != nil || len(all) != 2 || skus(all[0]) != skus(laptop) || skus(all[1]) != "" {
+			t.Errorf("FindAll = %d products, %v, want each with its own variants", len(all), err)
+		}
+		if err := repo.Delete(laptop.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.FindBySKU(mustSKU(t, "LAP-13-SLV")); !errors.Is(err, repository.ErrProductNotFound) {
+			t.Errorf("FindBySKU after Delete = %v, want the variants deleted with the product", err)
+		}
+		if err := repo.Save(other); err != nil {
+			t.Errorf("saving the SKU once its product is deleted = %v, want it free", err)
+		}
+	})
+}
+
+func TestSQLProductRepositoryRejectsInvalidVariants(t *testing.T) {
+	db := openSQLite(t)
+	repo := persistence.NewSQLProductRepository(db)
+	laptop := newProduct(t, "Laptop", 1000)
+	addVariant(t, laptop, "LAP-15", 1300, map[string]string{"size": "15 inch"})
+	if err := repo.Save(laptop); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE product_variants SET sku = 'LAP 15' WHERE product_id = ?`, laptop.ID().String()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.FindByID(laptop.ID()); err == nil || errors.Is(err, repository.ErrProductNotFound) {
+		t.Errorf("FindByID of a product with a malformed SKU = %v, want an error, not a product", err)
+	}
+}
@@ -0,0 +1,50 @@
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Subscriber receives every message published on a bus, and ignores the
+// types it does not know
+type Subscriber func(Message) error
+
+// Bus carries messages between contexts, in process: Publish hands a
+// message to every subscriber in turn. Messages go as JSON, so a broker
+// could take its place without either side changing.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []named
+}
+
+type named struct {
+	name       string
+	subscriber Subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a subscriber, named in the errors it returns
+func (b *Bus) Subscribe(name string, subscriber Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, named{name: name, subscriber: subscriber})
+}
+
+// Publish hands m to every subscriber, even when one fails, and returns
+// their errors
+func (b *Bus) Publish(m Message) error {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+	var errs []error
+	for _, s := range subscribers {
+		if err := s.subscriber(m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
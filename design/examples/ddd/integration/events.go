@@ -0,0 +1,94 @@
+// Package integration is the published language between bounded contexts:
+// the events a context publishes for others, and the bus they travel on.
+//
+// Integration events are not domain events. A domain event is the model's
+// own, and changes with it; an integration event is a contract, versioned
+// in its type and sent as JSON, so a context can change its model without
+// breaking those downstream. Each context translates: the upstream one from
+// its domain events (see infrastructure/messaging), the downstream one into
+// its own model (see orders/infrastructure/catalog).
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The types of the catalog's events. A change that breaks a payload gets a
+// new version, published beside the old one until no one reads it.
+const (
+	ProductCreatedType      = "catalog.product_created.v1"
+	ProductPriceChangedType = "catalog.product_price_changed.v1"
+	ProductDiscontinuedType = "catalog.product_discontinued.v1"
+)
+
+// Message is an integration event as it travels: its payload is the JSON
+// of one of the event types below
+type Message struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Event is an integration event before it is sent
+type Event interface {
+	MessageType() string
+}
+
+// ProductCreated is published when a product enters the catalog. Prices are
+// in units of Currency, as the catalog keeps them. Category is the path of
+// the product's category from the top, such as "Electronics/Laptops".
+type ProductCreated struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Currency  string  `json:"currency"`
+	Category  string  `json:"category"`
+}
+
+// ProductPriceChanged is published when a product's price changes, by a
+// new price or a discount
+type ProductPriceChanged struct {
+	ProductID string  `json:"product_id"`
+	OldPrice  float64 `json:"old_price"`
+	NewPrice  float64 `json:"new_price"`
+	Currency  string  `json:"currency"`
+}
+
+// ProductDiscontinued is published when a product is taken off sale
+type ProductDiscontinued struct {
+	ProductID string `json:"product_id"`
+}
+
+func (ProductCreated) MessageType() string      { return ProductCreatedType }
+func (ProductPriceChanged) MessageType() string { return ProductPriceChangedType }
+func (ProductDiscontinued) MessageType() string { return ProductDiscontinuedType }
+
+// NewMessage wraps event in a Message with a new ID
+func NewMessage(event Event, occurredAt time.Time) (Message, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, fmt.Errorf("integration: %s: %w", event.MessageType(), err)
+	}
+	return Message{
+		ID:         uuid.New().String(),
+		Type:       event.MessageType(),
+		OccurredAt: occurredAt.UTC(),
+		Payload:    payload,
+	}, nil
+}
+
+// Decode reads the payload of m into event, which must be of m's type
+func (m Message) Decode(event Event) error {
+	if event.MessageType() != m.Type {
+		return fmt.Errorf("integration: message %s is %s, not %s", m.ID, m.Type, event.MessageType())
+	}
+	if err := json.Unmarshal(m.Payload, event); err != nil {
+		return fmt.Errorf("integration: message %s: %w", m.ID, err)
+	}
+	return nil
+}
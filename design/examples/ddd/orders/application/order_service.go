@@ -0,0 +1,147 @@
+// Package application holds the use cases of the ordering context
+package application
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/orders/domain/model"
+	"github.com/dong-tran/docs/ddd-example/orders/domain/repository"
+)
+
+// OrderService takes orders at the prices of its price list. The price list
+// follows the catalog through UpdatePrice and WithdrawProduct, which the
+// catalog translator calls for the catalog's integration events; nothing
+// here calls the catalog.
+type OrderService struct {
+	orders repository.OrderRepository
+	prices repository.PriceList
+}
+
+func NewOrderService(orders repository.OrderRepository, prices repository.PriceList) *OrderService {
+	return &OrderService{orders: orders, prices: prices}
+}
+
+// StartOrder starts a draft order for a customer
+func (s *OrderService) StartOrder(customerID model.CustomerID) (*model.Order, error) {
+	order, err := model.NewOrder(customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.orders.Save(order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// AddLine adds quantity of a product to a draft order, at its price in the
+// price list
+func (s *OrderService) AddLine(orderID model.OrderID, productID model.ProductID, quantity int) (*model.Order, error) {
+	price, err := s.prices.Find(productID)
+	if err != nil {
+		return nil, err
+	}
+	return s.change(orderID, func(order *model.Order) error {
+		return order.AddLine(price, quantity)
+	})
+}
+
+// PlaceOrder places a draft order, fixing its prices
+func (s *OrderService) PlaceOrder(orderID model.OrderID) (*model.Order, error) {
+	return s.change(orderID, (*model.Order).Place)
+}
+
+func (s *OrderService) GetOrder(orderID model.OrderID) (*model.Order, error) {
+	return s.orders.FindByID(orderID)
+}
+
+func (s *OrderService) GetPrice(productID model.ProductID) (model.CatalogPrice, error) {
+	return s.prices.Find(productID)
+}
+
+// UpdatePrice records the price of a product as of asOf, unless a newer one
+// is known, and reprices the draft orders with a line for it
+func (s *OrderService) UpdatePrice(productID model.ProductID, price model.Money, asOf time.Time) error {
+	updated, err := model.NewCatalogPrice(productID, price, true, asOf)
+	if err != nil {
+		return err
+	}
+	current, err := s.prices.Find(productID)
+	if err == nil {
+		if !updated.Supersedes(current) {
+			return nil
+		}
+		if !current.Available() {
+			// Once withdrawn, a product stays so: prices published before
+			// it was discontinued, but delivered after, change nothing
+			return nil
+		}
+	} else if !errors.Is(err, repository.ErrPriceNotFound) {
+		return err
+	}
+	if err := s.prices.Save(updated); err != nil {
+		return err
+	}
+	return s.eachDraft(productID, func(order *model.Order) bool {
+		return order.Reprice(productID, price)
+	})
+}
+
+// WithdrawProduct takes a product off sale as of asOf, and drops it from
+// the draft orders
+func (s *OrderService) WithdrawProduct(productID model.ProductID, asOf time.Time) error {
+	current, err := s.prices.Find(productID)
+	if errors.Is(err, repository.ErrPriceNotFound) {
+		// Discontinued before ordering heard of it: nothing to withdraw
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !current.Available() {
+		return nil
+	}
+	if err := s.prices.Save(current.Withdrawn(asOf)); err != nil {
+		return err
+	}
+	return s.eachDraft(productID, func(order *model.Order) bool {
+		return order.Withdraw(productID)
+	})
+}
+
+// eachDraft applies apply to the drafts with a line for the product, and
+// saves those it changed
+func (s *OrderService) eachDraft(productID model.ProductID, apply func(*model.Order) bool) error {
+	drafts, err := s.orders.FindDraftsWithProduct(productID)
+	if err != nil {
+		return err
+	}
+	for _, order := range drafts {
+		if !apply(order) {
+			continue
+		}
+		if err := s.orders.Save(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *OrderService) change(orderID model.OrderID, apply func(*model.Order) error) (*model.Order, error) {
+	order, err := s.orders.FindByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apply(order); err != nil {
+		return nil, err
+	}
+
+	if err := s.orders.Save(order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
@@ -0,0 +1,71 @@
+package orders_test
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The context map test reads the imports of the source: ordering imports
+// only its own packages and the published language, package integration,
+// its model not even that, and the catalog nothing of ordering. Tests may
+// join the contexts, so they are not read.
+
+const module = "github.com/dong-tran/docs/ddd-example"
+
+// imports returns the packages of the module that the code under dir
+// imports, relative to the module
+func imports(t *testing.T, dir string) map[string]bool {
+	t.Helper()
+	found := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if strings.HasPrefix(path, module+"/") {
+				found[strings.TrimPrefix(path, module+"/")] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestContextMap(t *testing.T) {
+	rules := []struct {
+		name    string
+		dir     string
+		allowed []string
+	}{
+		{"ordering", ".", []string{"orders/", "integration"}},
+		{"ordering's model", "domain", []string{"orders/domain/"}},
+		{"the published language", "../integration", nil},
+		{"the catalog's domain", "../domain", []string{"domain/"}},
+		{"the catalog's application", "../application", []string{"domain/", "application"}},
+		{"the catalog's infrastructure", "../infrastructure", []string{"domain/", "application", "infrastructure/", "integration"}},
+	}
+	for _, tt := range rules {
+		for path := range imports(t, tt.dir) {
+			allowed := false
+			for _, prefix := range tt.allowed {
+				allowed = allowed || strings.HasPrefix(path, prefix)
+			}
+			if !allowed {
+				t.Errorf("%s imports %s, want only %v", tt.name, path, tt.allowed)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// CatalogPrice is what ordering knows of a product of the catalog: its
+// price, whether it is on sale, and as of when. It is a value object, kept
+// in the price list and replaced by the next one the catalog publishes.
+type CatalogPrice struct {
+	productID ProductID
+	price     Money
+	available bool
+	asOf      time.Time
+}
+
+func NewCatalogPrice(productID ProductID, price Money, available bool, asOf time.Time) (CatalogPrice, error) {
+	if productID == "" {
+		return CatalogPrice{}, ValidationError("a catalog price needs a product")
+	}
+	return CatalogPrice{productID: productID, price: price, available: available, asOf: asOf}, nil
+}
+
+func (p CatalogPrice) ProductID() ProductID { return p.productID }
+func (p CatalogPrice) Price() Money         { return p.price }
+func (p CatalogPrice) Available() bool      { return p.available }
+func (p CatalogPrice) AsOf() time.Time      { return p.asOf }
+
+// Supersedes reports whether p is newer than current, and should replace
+// it: events may arrive late, or twice, and an older one must not undo a
+// newer one
+func (p CatalogPrice) Supersedes(current CatalogPrice) bool {
+	return p.asOf.After(current.asOf)
+}
+
+// Withdrawn returns p, no longer on sale as of asOf
+func (p CatalogPrice) Withdrawn(asOf time.Time) CatalogPrice {
+	p.available = false
+	p.asOf = asOf
+	return p
+}
@@ -0,0 +1,210 @@
+// Package model is the model of the ordering context: orders, and the
+// prices they are taken at. Products and customers belong to other
+// contexts, and are known here by ID only; what ordering needs of the
+// catalog, a price and whether a product is on sale, it keeps in its own
+// PriceList, fed by the catalog's integration events.
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ValidationError is a rule of the ordering model that a value breaks
+type ValidationError string
+
+func (e ValidationError) Error() string {
+	return string(e)
+}
+
+// ProductID and CustomerID refer to the catalog's products and to
+// customers, as those contexts write their IDs
+type (
+	ProductID  string
+	CustomerID string
+)
+
+type OrderID struct {
+	value string
+}
+
+func NewOrderID() OrderID {
+	return OrderID{value: uuid.New().String()}
+}
+
+func (id OrderID) String() string {
+	return id.value
+}
+
+// Money is a value object: an amount in cents of a three-letter currency.
+// The catalog counts in units with decimals; ordering adds up prices, so it
+// counts in whole cents.
+type Money struct {
+	cents    int64
+	currency string
+}
+
+func NewMoney(cents int64, currency string) (Money, error) {
+	if cents < 0 {
+		return Money{}, ValidationError("money amount cannot be negative")
+	}
+	currency = strings.ToUpper(currency)
+	if len(currency) != 3 {
+		return Money{}, ValidationError("currency must be a three-letter code")
+	}
+	return Money{cents: cents, currency: currency}, nil
+}
+
+func (m Money) Cents() int64     { return m.cents }
+func (m Money) Currency() string { return m.currency }
+
+func (m Money) times(quantity int) Money {
+	return Money{cents: m.cents * int64(quantity), currency: m.currency}
+}
+
+// OrderStatus is draft while the customer fills the order, then placed
+type OrderStatus string
+
+const (
+	OrderDraft  OrderStatus = "draft"
+	OrderPlaced OrderStatus = "placed"
+)
+
+// Line is a product in an order, at the price it is ordered at
+type Line struct {
+	ProductID ProductID
+	Quantity  int
+	UnitPrice Money
+}
+
+// Order is an aggregate root. While a draft, its lines follow the catalog:
+// they are repriced when a price changes, and dropped when a product is
+// discontinued. Once placed, its prices are those the customer agreed to.
+type Order struct {
+	id         OrderID
+	customerID CustomerID
+	lines      []Line
+	status     OrderStatus
+	createdAt  time.Time
+	placedAt   time.Time
+}
+
+// NewOrder starts a draft order for a customer
+func NewOrder(customerID CustomerID) (*Order, error) {
+	if customerID == "" {
+		return nil, ValidationError("an order needs a customer")
+	}
+	return &Order{id: NewOrderID(), customerID: customerID, status: OrderDraft, createdAt: time.Now()}, nil
+}
+
+// ReconstructOrder rebuilds an order that was stored. It is for
+// repositories only.
+func ReconstructOrder(id OrderID, customerID CustomerID, lines []Line, status OrderStatus, createdAt, placedAt time.Time) *Order {
+	return &Order{
+		id:         id,
+		customerID: customerID,
+		lines:      append([]Line(nil), lines...),
+		status:     status,
+		createdAt:  createdAt,
+		placedAt:   placedAt,
+	}
+}
+
+func (o *Order) ID() OrderID            { return o.id }
+func (o *Order) CustomerID() CustomerID { return o.customerID }
+func (o *Order) Status() OrderStatus    { return o.status }
+func (o *Order) CreatedAt() time.Time   { return o.createdAt }
+func (o *Order) PlacedAt() time.Time    { return o.placedAt }
+func (o *Order) Lines() []Line          { return append([]Line(nil), o.lines...) }
+
+func (o *Order) find(id ProductID) *Line {
+	for i := range o.lines {
+		if o.lines[i].ProductID == id {
+			return &o.lines[i]
+		}
+	}
+	return nil
+}
+
+// Total is the sum of the lines, in their currency
+func (o *Order) Total() Money {
+	var total Money
+	for _, line := range o.lines {
+		total.currency = line.UnitPrice.currency
+		total.cents += line.UnitPrice.times(line.Quantity).cents
+	}
+	return total
+}
+
+// AddLine adds quantity of a product at its price in the price list, to the
+// line it already has if there is one. Every line is in one currency.
+func (o *Order) AddLine(price CatalogPrice, quantity int) error {
+	if err := o.draft(); err != nil {
+		return err
+	}
+	if quantity <= 0 {
+		return ValidationError("quantity must be positive")
+	}
+	if !price.Available() {
+		return ValidationError("product is not on sale")
+	}
+	if len(o.lines) > 0 && o.lines[0].UnitPrice.currency != price.Price().currency {
+		return ValidationError("every line of an order is in the same currency")
+	}
+	if line := o.find(price.ProductID()); line != nil {
+		line.Quantity += quantity
+		line.UnitPrice = price.Price()
+		return nil
+	}
+	o.lines = append(o.lines, Line{ProductID: price.ProductID(), Quantity: quantity, UnitPrice: price.Price()})
+	return nil
+}
+
+// Reprice follows a new price of a product, if the order is a draft with a
+// line for it. It reports whether the order changed.
+func (o *Order) Reprice(productID ProductID, price Money) bool {
+	line := o.find(productID)
+	if o.status != OrderDraft || line == nil || line.UnitPrice == price {
+		return false
+	}
+	line.UnitPrice = price
+	return true
+}
+
+// Withdraw drops the line of a product taken off sale, if the order is a
+// draft with one. It reports whether the order changed.
+func (o *Order) Withdraw(productID ProductID) bool {
+	if o.status != OrderDraft || o.find(productID) == nil {
+		return false
+	}
+	lines := o.lines[:0]
+	for _, line := range o.lines {
+		if line.ProductID != productID {
+			lines = append(lines, line)
+		}
+	}
+	o.lines = lines
+	return true
+}
+
+// Place places a draft order with at least one line, at the prices it has
+func (o *Order) Place() error {
+	if err := o.draft(); err != nil {
+		return err
+	}
+	if len(o.lines) == 0 {
+		return ValidationError("an order needs at least one line")
+	}
+	o.status = OrderPlaced
+	o.placedAt = time.Now()
+	return nil
+}
+
+func (o *Order) draft() error {
+	if o.status != OrderDraft {
+		return ValidationError("order is already placed")
+	}
+	return nil
+}
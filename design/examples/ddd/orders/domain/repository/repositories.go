@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/dong-tran/docs/ddd-example/orders/domain/model"
+)
+
+var (
+	// ErrOrderNotFound is returned by OrderRepository.FindByID for an
+	// unknown order
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrPriceNotFound is returned by PriceList.Find for a product the
+	// catalog has not published
+	ErrPriceNotFound = errors.New("product is not in the price list")
+)
+
+type OrderRepository interface {
+	Save(order *model.Order) error
+	FindByID(id model.OrderID) (*model.Order, error)
+	// FindDraftsWithProduct returns the draft orders with a line for the
+	// product
+	FindDraftsWithProduct(id model.ProductID) ([]*model.Order, error)
+}
+
+// PriceList keeps the latest CatalogPrice of each product
+type PriceList interface {
+	Save(price model.CatalogPrice) error
+	Find(id model.ProductID) (model.CatalogPrice, error)
+}
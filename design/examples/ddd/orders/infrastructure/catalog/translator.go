@@ -0,0 +1,76 @@
+// Package catalog is the ordering context's anti-corruption layer toward
+// the catalog. It reads the catalog's integration events, in the published
+// language of package integration, and turns them into the ordering
+// model's own terms: prices in cents, product IDs of its own type, a
+// discontinued product withdrawn from the price list. The catalog is
+// upstream and ordering downstream: ordering follows the catalog, and this
+// package is the only one that knows what the catalog publishes.
+package catalog
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dong-tran/docs/ddd-example/integration"
+	"github.com/dong-tran/docs/ddd-example/orders/application"
+	"github.com/dong-tran/docs/ddd-example/orders/domain/model"
+)
+
+// Translator applies the catalog's messages to the ordering context
+type Translator struct {
+	orders *application.OrderService
+}
+
+func NewTranslator(orders *application.OrderService) *Translator {
+	return &Translator{orders: orders}
+}
+
+// Subscribe has the translator receive the messages of bus
+func (t *Translator) Subscribe(bus *integration.Bus) {
+	bus.Subscribe("orders", t.Handle)
+}
+
+// Handle applies one message. Types it does not know, including later
+// versions of those it does, are ignored; a payload it cannot read is an
+// error.
+func (t *Translator) Handle(m integration.Message) error {
+	switch m.Type {
+	case integration.ProductCreatedType:
+		var event integration.ProductCreated
+		if err := m.Decode(&event); err != nil {
+			return err
+		}
+		return t.updatePrice(event.ProductID, event.Price, event.Currency, m.OccurredAt)
+	case integration.ProductPriceChangedType:
+		var event integration.ProductPriceChanged
+		if err := m.Decode(&event); err != nil {
+			return err
+		}
+		return t.updatePrice(event.ProductID, event.NewPrice, event.Currency, m.OccurredAt)
+	case integration.ProductDiscontinuedType:
+		var event integration.ProductDiscontinued
+		if err := m.Decode(&event); err != nil {
+			return err
+		}
+		return t.orders.WithdrawProduct(model.ProductID(event.ProductID), m.OccurredAt)
+	}
+	return nil
+}
+
+func (t *Translator) updatePrice(productID string, amount float64, currency string, asOf time.Time) error {
+	price, err := Money(amount, currency)
+	if err != nil {
+		return fmt.Errorf("product %s: %w", productID, err)
+	}
+	return t.orders.UpdatePrice(model.ProductID(productID), price, asOf)
+}
+
+// Money translates an amount in units of currency, as the catalog
+// publishes it, into ordering's cents, to the nearest cent
+func Money(amount float64, currency string) (model.Money, error) {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return model.Money{}, model.ValidationError("price is not a number")
+	}
+	return model.NewMoney(int64(math.Round(amount*100)), currency)
+}
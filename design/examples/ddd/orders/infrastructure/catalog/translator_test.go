@@ -0,0 +1,186 @@
+package catalog_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	catalogapp "github.com/dong-tran/docs/ddd-example/application"
+	"github.com/dong-tran/docs/ddd-example/infrastructure/messaging"
+	catalogpersistence "github.com/dong-tran/docs/ddd-example/infrastructure/persistence"
+	"github.com/dong-tran/docs/ddd-example/integration"
+	"github.com/dong-tran/docs/ddd-example/orders/application"
+	"github.com/dong-tran/docs/ddd-example/orders/domain/model"
+	"github.com/dong-tran/docs/ddd-example/orders/domain/repository"
+	"github.com/dong-tran/docs/ddd-example/orders/infrastructure/catalog"
+	"github.com/dong-tran/docs/ddd-example/orders/infrastructure/persistence"
+)
+
+var t0 = time.Date(2030, 7, 1, 9, 0, 0, 0, time.UTC)
+
+// message wraps event as published at at
+func message(t *testing.T, event integration.Event, at time.Time) integration.Message {
+	t.Helper()
+	m, err := integration.NewMessage(event, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// newOrders returns an ordering context in memory, and its translator
+func newOrders() (*application.OrderService, *catalog.Translator) {
+	orders := application.NewOrderService(persistence.NewMemoryOrderRepository(), persistence.NewMemoryPriceList())
+	return orders, catalog.NewTranslator(orders)
+}
+
+// handle passes m to translator, failing the test if it is refused
+func handle(t *testing.T, translator *catalog.Translator, m integration.Message) {
+	t.Helper()
+	if err := translator.Handle(m); err != nil {
+		t.Fatalf("Handle(%s) = %v", m.Type, err)
+	}
+}
+
+func TestTranslatorPrices(t *testing.T) {
+	orders, translator := newOrders()
+	handle(t, translator, message(t, integration.ProductCreated{ProductID: "p1", Price: 19.99, Currency: "usd"}, t0))
+	price, err := orders.GetPrice("p1")
+	if err != nil || price.Price().Cents() != 1999 || price.Price().Currency() != "USD" || !price.Available() || !price.AsOf().Equal(t0) {
+		t.Fatalf("after ProductCreated at 19.99 usd, GetPrice = %+v, %v, want 1999 cents of USD, on sale, as of the event", price, err)
+	}
+	handle(t, translator, message(t, integration.ProductPriceChanged{ProductID: "p1", OldPrice: 19.99, NewPrice: 0.1 + 0.2, Currency: "USD"}, t0.Add(time.Hour)))
+	if price, _ = orders.GetPrice("p1"); price.Price().Cents() != 30 {
+		t.Errorf("after ProductPriceChanged to 0.1+0.2, the price is %d cents, want 30", price.Price().Cents())
+	}
+
+	handle(t, translator, message(t, integration.ProductPriceChanged{ProductID: "p1", NewPrice: 25, Currency: "USD"}, t0.Add(30*time.Minute)))
+	if price, _ = orders.GetPrice("p1"); price.Price().Cents() != 30 {
+		t.Errorf("after a price older than the one known, the price is %d cents, want it left at 30", price.Price().Cents())
+	}
+	latest := message(t, integration.ProductPriceChanged{ProductID: "p1", NewPrice: 12, Currency: "USD"}, t0.Add(2*time.Hour))
+	handle(t, translator, latest)
+	handle(t, translator, latest)
+	if price, _ = orders.GetPrice("p1"); price.Price().Cents() != 1200 || !price.AsOf().Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("after the same message twice, the price is %d cents as of %v, want it applied once", price.Price().Cents(), price.AsOf())
+	}
+
+	handle(t, translator, message(t, integration.ProductDiscontinued{ProductID: "p1"}, t0.Add(3*time.Hour)))
+	handle(t, translator, message(t, integration.ProductPriceChanged{ProductID: "p1", NewPrice: 11, Currency: "USD"}, t0.Add(4*time.Hour)))
+	if price, _ = orders.GetPrice("p1"); price.Available() || price.Price().Cents() != 1200 {
+		t.Errorf("after ProductDiscontinued and a later price, %+v, want it off sale for good", price)
+	}
+	if err := translator.Handle(message(t, integration.ProductDiscontinued{ProductID: "unknown"}, t0)); err != nil {
+		t.Errorf("discontinuing a product ordering never heard of = %v, want nil", err)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	amounts := []struct {
+		units float64
+		cents int64
+	}{{2.675, 268}, {999.99, 99999}, {0, 0}}
+	for _, tt := range amounts {
+		if money, err := catalog.Money(tt.units, "EUR"); err != nil || money.Cents() != tt.cents {
+			t.Errorf("Money(%v, EUR) = %d cents, %v, want %d", tt.units, money.Cents(), err, tt.cents)
+		}
+	}
+}
+
+func TestTranslatorRefusals(t *testing.T) {
+	orders, translator := newOrders()
+	for _, typ := range []string{"catalog.product_renamed.v1", "catalog.product_price_changed.v2"} {
+		if err := translator.Handle(integration.Message{ID: "m1", Type: typ, Payload: []byte(`{}`)}); err != nil {
+			t.Errorf("Handle(%s) = %v, want a type it does not know ignored", typ, err)
+		}
+	}
+	err := translator.Handle(integration.Message{ID: "m3", Type: integration.ProductPriceChangedType, Payload: []byte(`{"new_price":"cheap"}`)})
+	if err == nil || !strings.Contains(err.Error(), "m3") {
+		t.Errorf("a payload it cannot read = %v, want an error naming the message", err)
+	}
+	err = translator.Handle(message(t, integration.ProductCreated{ProductID: "p2", Price: -1, Currency: "USD"}, t0))
+	var invalid model.ValidationError
+	if _, found := orders.GetPrice("p2"); !errors.As(err, &invalid) || !errors.Is(found, repository.ErrPriceNotFound) {
+		t.Errorf("a price ordering's model refuses = %v, then GetPrice = %v, want a ValidationError and nothing recorded", err, found)
+	}
+}
+
+// TestCatalogAndOrderingOnABus runs the catalog and ordering side by side,
+// joined only by a bus: the catalog's service publishes, ordering's
+// translator subscribes
+func TestCatalogAndOrderingOnABus(t *testing.T) {
+	bus := integration.NewBus()
+	var publishErrors []error
+	events := catalogapp.NewEventDispatcher()
+	events.Register(messaging.NewCatalogPublisher(bus, func(err error) { publishErrors = append(publishErrors, err) }).Handle)
+	products := catalogapp.NewProductServiceWithEvents(catalogpersistence.NewMemoryProductRepository(), events)
+	orders, translator := newOrders()
+	translator.Subscribe(bus)
+
+	lamp, err := products.CreateProduct(catalogapp.CreateProductDTO{Name: "Lamp", Price: 50, Currency: "EUR", Category: "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rug, err := products.CreateProduct(catalogapp.CreateProductDTO{Name: "Rug", Price: 120, Currency: "EUR", Category: "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lampID, rugID := model.ProductID(lamp.ID().String()), model.ProductID(rug.ID().String())
+
+	draft := startOrder(t, orders, "customer-1", map[model.ProductID]int{lampID: 2, rugID: 1})
+	placed := startOrder(t, orders, "customer-2", map[model.ProductID]int{lampID: 1})
+	if placed, err = orders.PlaceOrder(placed.ID()); err != nil || placed.Total().Cents() != 5000 {
+		t.Fatalf("PlaceOrder = %v, want it at the price the catalog published, 50.00 EUR", err)
+	}
+
+	if err := products.ApplyDiscountToProduct(lamp.ID(), 10); err != nil {
+		t.Fatal(err)
+	}
+	draft, _ = orders.GetOrder(draft.ID())
+	placed, _ = orders.GetOrder(placed.ID())
+	if draft.Total().Cents() != 2*4500+12000 {
+		t.Errorf("after a 10%% discount on the lamp, the draft is at %d cents, want 2 × 45.00 + 120.00", draft.Total().Cents())
+	}
+	if placed.Total().Cents() != 5000 {
+		t.Errorf("after a 10%% discount on the lamp, the placed order is at %d cents, want it left at 50.00", placed.Total().Cents())
+	}
+
+	if _, err := products.DiscontinueProduct(rug.ID()); err != nil {
+		t.Fatal(err)
+	}
+	draft, _ = orders.GetOrder(draft.ID())
+	if len(draft.Lines()) != 1 || draft.Lines()[0].ProductID != lampID {
+		t.Errorf("after the rug is discontinued, the draft has %+v, want only the lamp", draft.Lines())
+	}
+	var invalid model.ValidationError
+	if _, err := orders.AddLine(draft.ID(), rugID, 1); !errors.As(err, &invalid) {
+		t.Errorf("ordering a discontinued product = %v, want a ValidationError", err)
+	}
+	if len(publishErrors) != 0 {
+		t.Errorf("publishing failed: %v", publishErrors)
+	}
+
+	bus.Subscribe("broken", func(integration.Message) error { return errors.New("unavailable") })
+	_, err = products.ChangeProductPrice(lamp.ID(), 60)
+	draft, _ = orders.GetOrder(draft.ID())
+	if err != nil || len(publishErrors) != 1 || !strings.Contains(publishErrors[0].Error(), "broken") || draft.Total().Cents() != 2*6000 {
+		t.Errorf("with a subscriber failing, ChangeProductPrice = %v, errors %v, draft at %d cents, "+
+			"want the change made, the failure reported, and the draft repriced", err, publishErrors, draft.Total().Cents())
+	}
+}
+
+// startOrder starts an order for customer with the given lines
+func startOrder(t *testing.T, orders *application.OrderService, customer model.CustomerID, lines map[model.ProductID]int) *model.Order {
+	t.Helper()
+	order, err := orders.StartOrder(customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for product, quantity := range lines {
+		if order, err = orders.AddLine(order.ID(), product, quantity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return order
+}
@@ -0,0 +1,95 @@
+// Package persistence keeps the ordering context's orders and price list
+// in memory
+package persistence
+
+import (
+	"sync"
+
+	"github.com/dong-tran/docs/ddd-example/orders/domain/model"
+	"github.com/dong-tran/docs/ddd-example/orders/domain/repository"
+)
+
+// MemoryOrderRepository stores copies of orders, so an order changed after
+// Save is stored only when saved again
+type MemoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders map[model.OrderID]*model.Order
+	ids    []model.OrderID // in the order they were first saved
+}
+
+var _ repository.OrderRepository = (*MemoryOrderRepository)(nil)
+
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{orders: map[model.OrderID]*model.Order{}}
+}
+
+func clone(o *model.Order) *model.Order {
+	return model.ReconstructOrder(o.ID(), o.CustomerID(), o.Lines(), o.Status(), o.CreatedAt(), o.PlacedAt())
+}
+
+func (r *MemoryOrderRepository) Save(order *model.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orders[order.ID()]; !ok {
+		r.ids = append(r.ids, order.ID())
+	}
+	r.orders[order.ID()] = clone(order)
+	return nil
+}
+
+func (r *MemoryOrderRepository) FindByID(id model.OrderID) (*model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, repository.ErrOrderNotFound
+	}
+	return clone(order), nil
+}
+
+func (r *MemoryOrderRepository) FindDraftsWithProduct(id model.ProductID) ([]*model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var drafts []*model.Order
+	for _, orderID := range r.ids {
+		order := r.orders[orderID]
+		if order.Status() != model.OrderDraft {
+			continue
+		}
+		for _, line := range order.Lines() {
+			if line.ProductID == id {
+				drafts = append(drafts, clone(order))
+				break
+			}
+		}
+	}
+	return drafts, nil
+}
+
+type MemoryPriceList struct {
+	mu     sync.RWMutex
+	prices map[model.ProductID]model.CatalogPrice
+}
+
+var _ repository.PriceList = (*MemoryPriceList)(nil)
+
+func NewMemoryPriceList() *MemoryPriceList {
+	return &MemoryPriceList{prices: map[model.ProductID]model.CatalogPrice{}}
+}
+
+func (l *MemoryPriceList) Save(price model.CatalogPrice) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prices[price.ProductID()] = price
+	return nil
+}
+
+func (l *MemoryPriceList) Find(id model.ProductID) (model.CatalogPrice, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	price, ok := l.prices[id]
+	if !ok {
+		return model.CatalogPrice{}, repository.ErrPriceNotFound
+	}
+	return price, nil
+}
@@ -0,0 +1,416 @@
+package behavioral
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blackboard Pattern
+// Independent knowledge sources cooperate on a problem none of them can solve
+// alone by reading and refining a shared blackboard. They never call each
+// other: each one waits until the facts it needs are on the board, adds what
+// it knows, and is done. A controller watches the board and decides when the
+// solution is good enough to accept.
+//
+// The example scores an order for fraud. Some sources derive facts for others
+// (GeoIP turns an IP into a country the mismatch check needs), some add
+// evidence to the score, and one is a slow external lookup. The controller
+// accepts as soon as no outstanding source could change the verdict, so the
+// verdict never depends on goroutine scheduling, only on the order itself.
+
+// Facts are what is known about the order, by name
+type Facts map[string]string
+
+// Evidence is one source's effect on the fraud score
+type Evidence struct {
+	Source string
+	Score  int
+	Reason string
+}
+
+// Contribution is what a knowledge source adds to the board
+type Contribution struct {
+	Facts    Facts     // derived facts for other sources, may be nil
+	Evidence *Evidence // nil when the source has nothing to say about the score
+}
+
+// KnowledgeSource runs once, as soon as every fact in Needs is on the board.
+// Provides and MaxScore describe what it may still add; the controller relies
+// on them to tell when waiting for a source can no longer change the verdict.
+// Run should return soon after ctx is done: the controller cancels it once it
+// has accepted a solution, and waits for every source before returning it.
+type KnowledgeSource struct {
+	Name     string
+	Needs    []string
+	Provides []string
+	MaxScore int // largest absolute Score its evidence can have
+	Run      func(ctx context.Context, facts Facts) Contribution
+}
+
+type FraudVerdict int
+
+const (
+	Approve FraudVerdict = iota
+	ManualReview
+	Reject
+)
+
+func (v FraudVerdict) String() string {
+	switch v {
+	case Approve:
+		return "approve"
+	case ManualReview:
+		return "manual review"
+	case Reject:
+		return "reject"
+	}
+	return "unknown"
+}
+
+const (
+	ReviewScore = 30 // scores from here go to a person
+	RejectScore = 60 // scores from here are rejected outright
+)
+
+func verdictFor(score int) FraudVerdict {
+	switch {
+	case score >= RejectScore:
+		return Reject
+	case score >= ReviewScore:
+		return ManualReview
+	}
+	return Approve
+}
+
+// Blackboard is the shared state. Every write closes changed and replaces it,
+// waking everyone waiting for the board to change.
+type Blackboard struct {
+	mu       sync.Mutex
+	changed  chan struct{}
+	closed   bool
+	facts    Facts
+	evidence []Evidence
+	score    int
+	done     map[string]bool
+}
+
+func NewBlackboard(facts Facts) *Blackboard {
+	b := &Blackboard{changed: make(chan struct{}), facts: Facts{}, done: map[string]bool{}}
+	for k, v := range facts {
+		b.facts[k] = v
+	}
+	return b
+}
+
+// ready returns a copy of the facts if all of needs are known
+func (b *Blackboard) ready(needs []string) (Facts, bool) {
+	for _, n := range needs {
+		if _, ok := b.facts[n]; !ok {
+			return nil, false
+		}
+	}
+	facts := make(Facts, len(b.facts))
+	for k, v := range b.facts {
+		facts[k] = v
+	}
+	return facts, true
+}
+
+// post records a source's contribution; it is dropped once the board is closed
+func (b *Blackboard) post(source string, c Contribution) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	for k, v := range c.Facts {
+		b.facts[k] = v
+	}
+	if c.Evidence != nil {
+		e := *c.Evidence
+		e.Source = source
+		b.evidence = append(b.evidence, e)
+		b.score += e.Score
+	}
+	b.done[source] = true
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *Blackboard) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.changed)
+	}
+}
+
+// BlackboardResult is the accepted solution
+type BlackboardResult struct {
+	Verdict  FraudVerdict
+	Score    int
+	Evidence []Evidence // sorted by source
+	Skipped  []string   // sources that did not contribute, sorted
+	Facts    Facts
+}
+
+// BlackboardController runs the knowledge sources against one board each
+type BlackboardController struct {
+	sources []KnowledgeSource
+}
+
+func NewBlackboardController(sources ...KnowledgeSource) *BlackboardController {
+	return &BlackboardController{sources: sources}
+}
+
+// Assess starts one goroutine per knowledge source on a fresh board and
+// returns once the verdict is settled: either no outstanding source could
+// move the score across a threshold, or none of them can run any more. If ctx
+// ends first, the verdict is taken from the score so far.
+func (c *BlackboardController) Assess(ctx context.Context, facts Facts) BlackboardResult {
+	board := NewBlackboard(facts)
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, ks := range c.sources {
+		wg.Add(1)
+		go func(ks KnowledgeSource) {
+			defer wg.Done()
+			runKnowledgeSource(ctx, board, ks)
+		}(ks)
+	}
+
+	for {
+		board.mu.Lock()
+		settled := c.settled(board)
+		changed := board.changed
+		board.mu.Unlock()
+		if settled {
+			break
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	board.close()
+	cancel()
+	wg.Wait()
+	return c.result(board)
+}
+
+func runKnowledgeSource(ctx context.Context, board *Blackboard, ks KnowledgeSource) {
+	for {
+		board.mu.Lock()
+		if board.closed {
+			board.mu.Unlock()
+			return
+		}
+		facts, ok := board.ready(ks.Needs)
+		changed := board.changed
+		board.mu.Unlock()
+		if ok {
+			board.post(ks.Name, ks.Run(ctx, facts))
+			return
+		}
+		<-changed
+	}
+}
+
+// live returns the sources that have not contributed yet and still can: each
+// fact they need is on the board or provided by another live source.
+// Called with board.mu held.
+func (c *BlackboardController) live(board *Blackboard) []KnowledgeSource {
+	var live []KnowledgeSource
+	for _, ks := range c.sources {
+		if !board.done[ks.Name] {
+			live = append(live, ks)
+		}
+	}
+	for {
+		provided := map[string]bool{}
+		for _, ks := range live {
+			for _, p := range ks.Provides {
+				provided[p] = true
+			}
+		}
+		kept := live[:0:0]
+		for _, ks := range live {
+			reachable := true
+			for _, n := range ks.Needs {
+				if _, ok := board.facts[n]; !ok && !provided[n] {
+					reachable = false
+				}
+			}
+			if reachable {
+				kept = append(kept, ks)
+			}
+		}
+		if len(kept) == len(live) {
+			return live
+		}
+		live = kept
+	}
+}
+
+// settled reports whether the verdict can no longer change. Called with
+// board.mu held.
+func (c *BlackboardController) settled(board *Blackboard) bool {
+	outstanding := 0
+	for _, ks := range c.live(board) {
+		outstanding += ks.MaxScore
+	}
+	return verdictFor(board.score-outstanding) == verdictFor(board.score+outstanding)
+}
+
+func (c *BlackboardController) result(board *Blackboard) BlackboardResult {
+	board.mu.Lock()
+	defer board.mu.Unlock()
+	r := BlackboardResult{
+		Verdict:  verdictFor(board.score),
+		Score:    board.score,
+		Evidence: append([]Evidence(nil), board.evidence...),
+		Facts:    board.facts,
+	}
+	sort.Slice(r.Evidence, func(i, j int) bool { return r.Evidence[i].Source < r.Evidence[j].Source })
+	for _, ks := range c.sources {
+		if !board.done[ks.Name] {
+			r.Skipped = append(r.Skipped, ks.Name)
+		}
+	}
+	sort.Strings(r.Skipped)
+	return r
+}
+
+// Knowledge sources for the demo
+
+func scoreEvidence(score int, reason string) Contribution {
+	return Contribution{Evidence: &Evidence{Score: score, Reason: reason}}
+}
+
+func fraudKnowledgeSources(bureauDelay time.Duration) []KnowledgeSource {
+	geo := map[string]string{"81.2.69.160": "GB", "23.45.67.89": "US", "5.188.10.4": "RU"}
+	disposable := map[string]bool{"mailinator.com": true, "trashmail.io": true}
+	blocklist := map[string]bool{"mallory@example.net": true}
+	history := map[string]int{"c-ada": 12, "c-bob": 2}
+
+	return []KnowledgeSource{
+		{
+			Name: "geoip", Needs: []string{"ip"}, Provides: []string{"ip_country"},
+			Run: func(_ context.Context, f Facts) Contribution {
+				if country, ok := geo[f["ip"]]; ok {
+					return Contribution{Facts: Facts{"ip_country": country}}
+				}
+				return Contribution{}
+			},
+		},
+		{
+			Name: "geo-mismatch", Needs: []string{"ip_country", "card_country"}, MaxScore: 25,
+			Run: func(_ context.Context, f Facts) Contribution {
+				if f["ip_country"] != f["card_country"] {
+					return scoreEvidence(25, fmt.Sprintf("card from %s used from %s", f["card_country"], f["ip_country"]))
+				}
+				return scoreEvidence(0, "card used in its own country")
+			},
+		},
+		{
+			Name: "amount", Needs: []string{"amount"}, MaxScore: 35,
+			Run: func(_ context.Context, f Facts) Contribution {
+				amount, _ := strconv.Atoi(f["amount"])
+				switch {
+				case amount > 5000:
+					return scoreEvidence(35, "amount over 5000")
+				case amount > 1000:
+					return scoreEvidence(20, "amount over 1000")
+				}
+				return scoreEvidence(0, "ordinary amount")
+			},
+		},
+		{
+			Name: "disposable-email", Needs: []string{"email"}, MaxScore: 30,
+			Run: func(_ context.Context, f Facts) Contribution {
+				domain := f["email"][strings.LastIndex(f["email"], "@")+1:]
+				if disposable[domain] {
+					return scoreEvidence(30, "disposable email domain "+domain)
+				}
+				return Contribution{}
+			},
+		},
+		{
+			Name: "history", Needs: []string{"customer"}, Provides: []string{"prior_orders"}, MaxScore: 20,
+			Run: func(_ context.Context, f Facts) Contribution {
+				n := history[f["customer"]]
+				c := Contribution{Facts: Facts{"prior_orders": strconv.Itoa(n)}}
+				if n >= 5 {
+					c.Evidence = &Evidence{Score: -20, Reason: fmt.Sprintf("%d earlier orders", n)}
+				}
+				return c
+			},
+		},
+		{
+			Name: "new-customer-spend", Needs: []string{"prior_orders", "amount"}, MaxScore: 15,
+			Run: func(_ context.Context, f Facts) Contribution {
+				amount, _ := strconv.Atoi(f["amount"])
+				if f["prior_orders"] == "0" && amount > 500 {
+					return scoreEvidence(15, "first order over 500")
+				}
+				return Contribution{}
+			},
+		},
+		{
+			// A slow external service; only worth waiting for when it can
+			// still change the verdict
+			Name: "bureau", Needs: []string{"email"}, MaxScore: 40,
+			Run: func(ctx context.Context, f Facts) Contribution {
+				select {
+				case <-time.After(bureauDelay):
+				case <-ctx.Done():
+					return Contribution{}
+				}
+				if blocklist[f["email"]] {
+					return scoreEvidence(40, "email on the fraud bureau blocklist")
+				}
+				return scoreEvidence(0, "unknown to the fraud bureau")
+			},
+		},
+	}
+}
+
+func DemoBlackboard() {
+	fmt.Fprint(out, "=== Blackboard Pattern Demo ===\n\n")
+
+	controller := NewBlackboardController(fraudKnowledgeSources(50 * time.Millisecond)...)
+	orders := []struct {
+		name  string
+		facts Facts
+	}{
+		{"Regular customer", Facts{"customer": "c-ada", "amount": "80", "email": "ada@example.com",
+			"ip": "81.2.69.160", "card_country": "GB"}},
+		{"Obvious fraud", Facts{"customer": "c-new", "amount": "6400", "email": "x1@mailinator.com",
+			"ip": "5.188.10.4", "card_country": "US"}},
+		{"Borderline, bureau decides", Facts{"customer": "c-new", "amount": "1200", "email": "mallory@example.net",
+			"card_country": "US"}},
+		{"Borderline, unknown IP", Facts{"customer": "c-new", "amount": "1200", "email": "bob@example.com",
+			"card_country": "US"}},
+	}
+
+	for _, o := range orders {
+		r := controller.Assess(context.Background(), o.facts)
+		fmt.Fprintf(out, "%s: %s (score %d)\n", o.name, r.Verdict, r.Score)
+		for _, e := range r.Evidence {
+			fmt.Fprintf(out, "  %-18s %+4d  %s\n", e.Source, e.Score, e.Reason)
+		}
+		if len(r.Skipped) > 0 {
+			fmt.Fprintf(out, "  not waited for: %s\n", strings.Join(r.Skipped, ", "))
+		}
+		fmt.Fprintln(out)
+	}
+}
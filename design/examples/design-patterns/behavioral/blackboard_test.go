@@ -0,0 +1,172 @@
+package behavioral
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlackboardFraudVerdicts(t *testing.T) {
+	controller := NewBlackboardController(fraudKnowledgeSources(20 * time.Millisecond)...)
+	tests := []struct {
+		name        string
+		facts       Facts
+		want        FraudVerdict
+		skipped     string // a source the controller must not wait for, if any
+		waitedFor   string // a source whose evidence the verdict needs, if any
+		unreachable []string
+	}{
+		{
+			name: "a regular customer is approved without the bureau",
+			facts: Facts{"customer": "c-ada", "amount": "80", "email": "ada@example.com",
+				"ip": "81.2.69.160", "card_country": "GB"},
+			want:    Approve,
+			skipped: "bureau",
+		},
+		{
+			name: "obvious fraud is rejected without the bureau",
+			facts: Facts{"customer": "c-new", "amount": "6400", "email": "x1@mailinator.com",
+				"ip": "5.188.10.4", "card_country": "US"},
+			want:    Reject,
+			skipped: "bureau",
+		},
+		{
+			name: "a borderline order waits for the bureau, which rejects it",
+			facts: Facts{"customer": "c-new", "amount": "1200", "email": "mallory@example.net",
+				"card_country": "US"},
+			want:        Reject,
+			waitedFor:   "bureau",
+			unreachable: []string{"geo-mismatch", "geoip"},
+		},
+		{
+			name: "a borderline order the bureau does not know goes to a person",
+			facts: Facts{"customer": "c-new", "amount": "1200", "email": "bob@example.com",
+				"card_country": "US"},
+			want:        ManualReview,
+			waitedFor:   "bureau",
+			unreachable: []string{"geo-mismatch", "geoip"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The goroutines race differently on every run; the verdict must not
+			for i := 0; i < 50; i++ {
+				r := controller.Assess(context.Background(), tt.facts)
+				if r.Verdict != tt.want {
+					t.Fatalf("run %d: %s (score %d), want %s", i, r.Verdict, r.Score, tt.want)
+				}
+				skipped := strings.Join(r.Skipped, ",")
+				if tt.skipped != "" && !strings.Contains(skipped, tt.skipped) {
+					t.Fatalf("run %d waited for %s: skipped only %v", i, tt.skipped, r.Skipped)
+				}
+				if tt.waitedFor != "" && !hasEvidenceFrom(r, tt.waitedFor) {
+					t.Fatalf("run %d decided without %s: %+v", i, tt.waitedFor, r.Evidence)
+				}
+				for _, name := range tt.unreachable {
+					if hasEvidenceFrom(r, name) || !strings.Contains(skipped, name) {
+						t.Fatalf("run %d: %s ran without the facts it needs", i, name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func hasEvidenceFrom(r BlackboardResult, source string) bool {
+	for _, e := range r.Evidence {
+		if e.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBlackboardController(t *testing.T) {
+	t.Run("derived facts reach the sources that need them", func(t *testing.T) {
+		controller := NewBlackboardController(
+			KnowledgeSource{Name: "double", Needs: []string{"half"}, MaxScore: 50,
+				Run: func(_ context.Context, f Facts) Contribution {
+					return scoreEvidence(2*len(f["half"]), "doubled "+f["half"])
+				}},
+			KnowledgeSource{Name: "split", Needs: []string{"word"}, Provides: []string{"half"},
+				Run: func(_ context.Context, f Facts) Contribution {
+					return Contribution{Facts: Facts{"half": f["word"][:len(f["word"])/2]}}
+				}},
+		)
+		r := controller.Assess(context.Background(), Facts{"word": "abcdefghijklmnopqrstuvwxyz0123"})
+		if r.Facts["half"] != "abcdefghijklmno" || r.Score != 30 || r.Verdict != ManualReview || len(r.Skipped) != 0 {
+			t.Errorf("got %+v", r)
+		}
+	})
+
+	t.Run("a source that cannot change the verdict is not waited for", func(t *testing.T) {
+		controller := NewBlackboardController(
+			KnowledgeSource{Name: "certain", MaxScore: 70,
+				Run: func(context.Context, Facts) Contribution { return scoreEvidence(70, "certain") }},
+			KnowledgeSource{Name: "slow", MaxScore: 5,
+				Run: func(ctx context.Context, _ Facts) Contribution {
+					<-ctx.Done()
+					return scoreEvidence(5, "too late")
+				}},
+		)
+		r := controller.Assess(context.Background(), nil)
+		// slow's contribution comes after the board is closed, so it is dropped
+		if r.Verdict != Reject || r.Score != 70 || strings.Join(r.Skipped, ",") != "slow" {
+			t.Errorf("got %+v", r)
+		}
+	})
+
+	t.Run("when ctx ends, the verdict comes from the score so far", func(t *testing.T) {
+		controller := NewBlackboardController(
+			KnowledgeSource{Name: "some", MaxScore: 40,
+				Run: func(context.Context, Facts) Contribution { return scoreEvidence(40, "some") }},
+			KnowledgeSource{Name: "stuck", MaxScore: 40,
+				Run: func(ctx context.Context, _ Facts) Contribution { <-ctx.Done(); return Contribution{} }},
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		r := controller.Assess(ctx, nil)
+		if r.Verdict != ManualReview || r.Score != 40 {
+			t.Errorf("got %s (score %d), want manual review at 40", r.Verdict, r.Score)
+		}
+	})
+
+	t.Run("sources waiting for facts nobody provides do not block", func(t *testing.T) {
+		controller := NewBlackboardController(
+			KnowledgeSource{Name: "orphan", Needs: []string{"never"}, MaxScore: 100,
+				Run: func(context.Context, Facts) Contribution { return scoreEvidence(100, "impossible") }},
+		)
+		done := make(chan BlackboardResult)
+		go func() { done <- controller.Assess(context.Background(), nil) }()
+		select {
+		case r := <-done:
+			if r.Verdict != Approve || strings.Join(r.Skipped, ",") != "orphan" {
+				t.Errorf("got %+v", r)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Assess waited for a source that can never run")
+		}
+	})
+}
+
+func TestDemoBlackboardPrintsEachVerdict(t *testing.T) {
+	buf := captureOutput(t)
+	DemoBlackboard()
+	// Which evidence arrives before the verdict settles can vary; the verdicts cannot
+	var verdicts []string
+	for _, line := range lines(buf) {
+		if name, rest, ok := strings.Cut(line, ": "); ok && !strings.HasPrefix(line, " ") {
+			verdicts = append(verdicts, name+": "+rest[:strings.Index(rest, " (")])
+		}
+	}
+	want := []string{
+		"Regular customer: approve",
+		"Obvious fraud: reject",
+		"Borderline, bureau decides: reject",
+		"Borderline, unknown IP: manual review",
+	}
+	if strings.Join(verdicts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("verdicts:\n%s\nwant:\n%s", strings.Join(verdicts, "\n"), strings.Join(want, "\n"))
+	}
+}
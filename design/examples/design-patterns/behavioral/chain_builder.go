@@ -0,0 +1,173 @@
+package behavioral
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ChainBuilder - builds a Chain of Responsibility from declarative configuration.
+// The Manager -> Director -> CEO chain above is hardcoded; here each approval level
+// is data, so a new level (e.g. VP between Director and CEO) is a config change.
+
+// Unlimited marks a level that can approve any amount of a request type
+const Unlimited = -1
+
+// ApprovalLevel describes one link in the chain: who approves, and up to what amount
+type ApprovalLevel struct {
+	Role   string         `json:"role"`
+	Limits map[string]int `json:"limits"`
+}
+
+// ChainConfig lists approval levels in escalation order
+type ChainConfig struct {
+	Levels []ApprovalLevel `json:"levels"`
+}
+
+// ParseChainConfig decodes a JSON chain definition and validates it
+func ParseChainConfig(data []byte) (*ChainConfig, error) {
+	var cfg ChainConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("chain config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate rejects configurations that would build a broken or misleading chain
+func (c *ChainConfig) Validate() error {
+	if len(c.Levels) == 0 {
+		return errors.New("chain config: at least one level is required")
+	}
+
+	roles := make(map[string]bool)
+	highest := make(map[string]int)
+	for i, level := range c.Levels {
+		if level.Role == "" {
+			return fmt.Errorf("chain config: level %d has no role", i)
+		}
+		if roles[level.Role] {
+			return fmt.Errorf("chain config: duplicate role %q", level.Role)
+		}
+		roles[level.Role] = true
+
+		if len(level.Limits) == 0 {
+			return fmt.Errorf("chain config: role %q approves nothing", level.Role)
+		}
+		for _, requestType := range sortedKeys(level.Limits) {
+			limit := level.Limits[requestType]
+			if limit < 0 && limit != Unlimited {
+				return fmt.Errorf("chain config: role %q has negative %s limit %d", level.Role, requestType, limit)
+			}
+
+			// Escalation must widen authority, otherwise the later level is unreachable
+			previous, seen := highest[requestType]
+			if seen && previous == Unlimited {
+				return fmt.Errorf("chain config: role %q is unreachable for %s (an earlier level is unlimited)", level.Role, requestType)
+			}
+			if seen && limit != Unlimited && limit <= previous {
+				return fmt.Errorf("chain config: role %q %s limit %d must exceed earlier limit %d", level.Role, requestType, limit, previous)
+			}
+			highest[requestType] = limit
+		}
+	}
+	return nil
+}
+
+// ConfigurableApprover is a generic handler whose authority comes from an ApprovalLevel
+type ConfigurableApprover struct {
+	BaseHandler
+	level ApprovalLevel
+}
+
+func (a *ConfigurableApprover) Handle(req *Request) string {
+	if limit, ok := a.level.Limits[req.RequestType]; ok {
+		if limit == Unlimited || req.Amount <= limit {
+			return fmt.Sprintf("%s approved %s request for %d", a.level.Role, req.RequestType, req.Amount)
+		}
+	}
+	return a.BaseHandler.Handle(req)
+}
+
+// ChainBuilder assembles approvers in config order and returns the head of the chain
+type ChainBuilder struct {
+	config ChainConfig
+}
+
+func NewChainBuilder(config ChainConfig) *ChainBuilder {
+	return &ChainBuilder{config: config}
+}
+
+func (b *ChainBuilder) Build() (Handler, error) {
+	if err := b.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var head, tail Handler
+	for _, level := range b.config.Levels {
+		approver := &ConfigurableApprover{level: level}
+		if head == nil {
+			head = approver
+		} else {
+			tail.SetNext(approver)
+		}
+		tail = approver
+	}
+	return head, nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func DemoChainBuilder() {
+	fmt.Fprintln(out, "=== Chain Builder Demo ===")
+	fmt.Fprintln(out)
+
+	// Adding the VP level is a config change only - no new handler type
+	config, err := ParseChainConfig([]byte(`{
+		"levels": [
+			{"role": "Manager",  "limits": {"leave": 3}},
+			{"role": "Director", "limits": {"leave": 7, "purchase": 10000}},
+			{"role": "VP",       "limits": {"leave": 14, "purchase": 25000}},
+			{"role": "CEO",      "limits": {"leave": -1, "purchase": -1}}
+		]
+	}`))
+	if err != nil {
+		fmt.Fprintln(out, "Invalid config:", err)
+		return
+	}
+
+	chain, err := NewChainBuilder(*config).Build()
+	if err != nil {
+		fmt.Fprintln(out, "Failed to build chain:", err)
+		return
+	}
+
+	requests := []*Request{
+		{RequestType: "leave", Amount: 2},
+		{RequestType: "leave", Amount: 10},
+		{RequestType: "leave", Amount: 30},
+		{RequestType: "purchase", Amount: 20000},
+		{RequestType: "purchase", Amount: 50000},
+		{RequestType: "travel", Amount: 1},
+	}
+	for _, req := range requests {
+		fmt.Fprintf(out, "%s request for %d: %s\n", req.RequestType, req.Amount, chain.Handle(req))
+	}
+
+	fmt.Fprintln(out, "\nRejected config:")
+	_, err = NewChainBuilder(ChainConfig{Levels: []ApprovalLevel{
+		{Role: "Manager", Limits: map[string]int{"leave": 5}},
+		{Role: "Director", Limits: map[string]int{"leave": 3}},
+	}}).Build()
+	fmt.Fprintln(out, err)
+}
@@ -0,0 +1,133 @@
+package behavioral
+
+import (
+	"strings"
+	"testing"
+)
+
+const fourLevels = `{
+	"levels": [
+		{"role": "Manager",  "limits": {"leave": 3}},
+		{"role": "Director", "limits": {"leave": 7, "purchase": 10000}},
+		{"role": "VP",       "limits": {"leave": 14, "purchase": 25000}},
+		{"role": "CEO",      "limits": {"leave": -1, "purchase": -1}}
+	]
+}`
+
+func TestChainBuilderEscalates(t *testing.T) {
+	config, err := ParseChainConfig([]byte(fourLevels))
+	if err != nil {
+		t.Fatalf("ParseChainConfig: %v", err)
+	}
+	chain, err := NewChainBuilder(*config).Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	tests := []struct {
+		request Request
+		want    string
+	}{
+		{Request{"leave", 2}, "Manager approved leave request for 2"},
+		{Request{"leave", 3}, "Manager approved leave request for 3"},
+		{Request{"leave", 10}, "VP approved leave request for 10"},
+		{Request{"leave", 30}, "CEO approved leave request for 30"},
+		{Request{"purchase", 100}, "Director approved purchase request for 100"},
+		{Request{"purchase", 20000}, "VP approved purchase request for 20000"},
+		{Request{"purchase", 50000}, "CEO approved purchase request for 50000"},
+		{Request{"travel", 1}, "Request not handled"},
+	}
+	for _, tt := range tests {
+		req := tt.request
+		if got := chain.Handle(&req); got != tt.want {
+			t.Errorf("%s %d: %q, want %q", req.RequestType, req.Amount, got, tt.want)
+		}
+	}
+}
+
+func TestChainConfigRejectsBrokenChains(t *testing.T) {
+	tests := []struct {
+		name    string
+		levels  []ApprovalLevel
+		wantErr string
+	}{
+		{"no levels", nil, "at least one level is required"},
+		{"a level without a role",
+			[]ApprovalLevel{{Limits: map[string]int{"leave": 3}}},
+			"level 0 has no role"},
+		{"the same role twice",
+			[]ApprovalLevel{{"Manager", map[string]int{"leave": 3}}, {"Manager", map[string]int{"leave": 5}}},
+			`duplicate role "Manager"`},
+		{"a role that approves nothing",
+			[]ApprovalLevel{{"Manager", nil}},
+			`role "Manager" approves nothing`},
+		{"a negative limit other than Unlimited",
+			[]ApprovalLevel{{"Manager", map[string]int{"leave": -5}}},
+			"negative leave limit -5"},
+		{"a later level with a lower limit",
+			[]ApprovalLevel{{"Manager", map[string]int{"leave": 5}}, {"Director", map[string]int{"leave": 3}}},
+			`role "Director" leave limit 3 must exceed earlier limit 5`},
+		{"a later level with the same limit",
+			[]ApprovalLevel{{"Manager", map[string]int{"leave": 5}}, {"Director", map[string]int{"leave": 5}}},
+			"must exceed earlier limit 5"},
+		{"a level behind an unlimited one",
+			[]ApprovalLevel{{"CEO", map[string]int{"leave": Unlimited}}, {"Board", map[string]int{"leave": Unlimited}}},
+			`role "Board" is unreachable for leave`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := NewChainBuilder(ChainConfig{Levels: tt.levels}).Build()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Build = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if chain != nil {
+				t.Error("Build returned a chain with the error")
+			}
+		})
+	}
+
+	t.Run("a level may add a request type the earlier ones lack", func(t *testing.T) {
+		_, err := NewChainBuilder(ChainConfig{Levels: []ApprovalLevel{
+			{"Manager", map[string]int{"leave": 3}},
+			{"Director", map[string]int{"leave": 7, "purchase": 100}},
+		}}).Build()
+		if err != nil {
+			t.Errorf("Build = %v", err)
+		}
+	})
+}
+
+func TestParseChainConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"malformed JSON", `{"levels": [`, "chain config: unexpected end of JSON input"},
+		{"a limit that is not a number", `{"levels": [{"role": "Manager", "limits": {"leave": "3"}}]}`, "chain config: json: cannot unmarshal"},
+		{"valid JSON, invalid chain", `{"levels": []}`, "at least one level is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseChainConfig([]byte(tt.json)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseChainConfig = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDemoChainBuilderPrintsEachDecision(t *testing.T) {
+	buf := captureOutput(t)
+	DemoChainBuilder()
+	assertLines(t, buf,
+		"=== Chain Builder Demo ===",
+		"leave request for 2: Manager approved leave request for 2",
+		"leave request for 10: VP approved leave request for 10",
+		"leave request for 30: CEO approved leave request for 30",
+		"purchase request for 20000: VP approved purchase request for 20000",
+		"purchase request for 50000: CEO approved purchase request for 50000",
+		"travel request for 1: Request not handled",
+		"Rejected config:",
+		`chain config: role "Director" leave limit 3 must exceed earlier limit 5`,
+	)
+}
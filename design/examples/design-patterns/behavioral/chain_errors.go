@@ -0,0 +1,284 @@
+package behavioral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Error-handling Chain of Responsibility.
+// A failed operation's error is passed along a chain of handlers. Each one
+// recognizes a class of error and tries to recover from it: retry a transient
+// failure, answer a validation failure with a rejection, serve a fallback, or
+// escalate. A handler that cannot deal with the error passes it on, possibly
+// changed (a retry can fail differently). The chain ends with a Disposition:
+// what happened and what to tell the caller.
+//
+// The classes map onto upstream HTTP statuses (ClassifyStatus), so an API
+// gateway can put a chain behind its proxy and answer from the disposition.
+
+// RetryableError is a transient failure: the same call may succeed later
+type RetryableError struct {
+	Err   error
+	After time.Duration // wait suggested by the failing side, e.g. Retry-After
+}
+
+func (e *RetryableError) Error() string { return "retryable: " + e.Err.Error() }
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// ValidationError is the caller's fault: retrying the same input cannot help
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// FatalError is a failure nobody downstream can recover from
+type FatalError struct {
+	Err error
+}
+
+func (e *FatalError) Error() string { return "fatal: " + e.Err.Error() }
+func (e *FatalError) Unwrap() error { return e.Err }
+
+// ClassifyStatus turns an upstream HTTP response status into a typed error,
+// nil for success
+func ClassifyStatus(status int, retryAfter time.Duration) error {
+	err := fmt.Errorf("upstream answered %d %s", status, http.StatusText(status))
+	switch {
+	case status < 400:
+		return nil
+	case status == http.StatusTooManyRequests, status == http.StatusBadGateway,
+		status == http.StatusServiceUnavailable, status == http.StatusGatewayTimeout:
+		return &RetryableError{Err: err, After: retryAfter}
+	case status < 500:
+		return &ValidationError{Field: "request", Reason: err.Error()}
+	}
+	return &FatalError{Err: err}
+}
+
+type Outcome int
+
+const (
+	Resolved Outcome = iota // the operation succeeded after all
+	Degraded                // a fallback answered instead of the operation
+	Rejected                // the caller must change the request
+	Failed                  // nothing could be done
+)
+
+func (o Outcome) String() string {
+	return [...]string{"resolved", "degraded", "rejected", "failed"}[o]
+}
+
+// Disposition is the chain's verdict on a failure
+type Disposition struct {
+	Outcome    Outcome
+	Status     int // HTTP status to answer with
+	Handler    string
+	Attempts   int
+	RetryAfter time.Duration
+	Err        error // the error as last seen; nil when resolved
+}
+
+func (d Disposition) String() string {
+	s := fmt.Sprintf("%s (%d) by %s after %d attempt(s)", d.Outcome, d.Status, d.Handler, d.Attempts)
+	if d.Err != nil {
+		s += ": " + d.Err.Error()
+	}
+	return s
+}
+
+// Failure is what travels along the chain. Retry runs the failed operation
+// again; handlers update Err and Attempts as they go.
+type Failure struct {
+	Op       string
+	Err      error
+	Attempts int
+	Retry    func(ctx context.Context) error
+}
+
+type ErrorHandler interface {
+	SetNext(ErrorHandler) ErrorHandler
+	Handle(ctx context.Context, f *Failure) Disposition
+}
+
+// BaseErrorHandler passes the failure on; at the end of the chain it fails it
+type BaseErrorHandler struct {
+	next ErrorHandler
+}
+
+func (h *BaseErrorHandler) SetNext(handler ErrorHandler) ErrorHandler {
+	h.next = handler
+	return handler
+}
+
+func (h *BaseErrorHandler) Handle(ctx context.Context, f *Failure) Disposition {
+	if h.next != nil {
+		return h.next.Handle(ctx, f)
+	}
+	return Disposition{Outcome: Failed, Status: http.StatusInternalServerError, Handler: "unhandled", Attempts: f.Attempts, Err: f.Err}
+}
+
+// RetryHandler re-runs retryable failures with exponential backoff, honouring
+// the wait the error suggests. A retry that fails differently passes the new
+// error on, as does running out of attempts or of context.
+type RetryHandler struct {
+	BaseErrorHandler
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func (h *RetryHandler) Handle(ctx context.Context, f *Failure) Disposition {
+	delay := h.Backoff
+	for f.Attempts < h.MaxAttempts && f.Retry != nil {
+		var retryable *RetryableError
+		if !errors.As(f.Err, &retryable) {
+			break
+		}
+		wait := delay
+		if retryable.After > wait {
+			wait = retryable.After
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			f.Err = &RetryableError{Err: ctx.Err()}
+			return h.BaseErrorHandler.Handle(ctx, f)
+		}
+		delay *= 2
+
+		f.Attempts++
+		if f.Err = f.Retry(ctx); f.Err == nil {
+			return Disposition{Outcome: Resolved, Status: http.StatusOK, Handler: "retry", Attempts: f.Attempts}
+		}
+	}
+	return h.BaseErrorHandler.Handle(ctx, f)
+}
+
+// ValidationHandler answers validation errors with a rejection: only the caller can fix them
+type ValidationHandler struct {
+	BaseErrorHandler
+}
+
+func (h *ValidationHandler) Handle(ctx context.Context, f *Failure) Disposition {
+	var invalid *ValidationError
+	if !errors.As(f.Err, &invalid) {
+		return h.BaseErrorHandler.Handle(ctx, f)
+	}
+	return Disposition{Outcome: Rejected, Status: http.StatusUnprocessableEntity, Handler: "validation", Attempts: f.Attempts, Err: f.Err}
+}
+
+// FallbackHandler answers a still-retryable failure some other way, e.g. from
+// a cache. If the fallback fails too, the original failure is passed on.
+type FallbackHandler struct {
+	BaseErrorHandler
+	Fallback func(ctx context.Context, op string) error
+}
+
+func (h *FallbackHandler) Handle(ctx context.Context, f *Failure) Disposition {
+	var retryable *RetryableError
+	if h.Fallback == nil || !errors.As(f.Err, &retryable) {
+		return h.BaseErrorHandler.Handle(ctx, f)
+	}
+	if err := h.Fallback(ctx, f.Op); err != nil {
+		return h.BaseErrorHandler.Handle(ctx, f)
+	}
+	return Disposition{Outcome: Degraded, Status: http.StatusOK, Handler: "fallback", Attempts: f.Attempts, Err: f.Err}
+}
+
+// EscalationHandler ends the chain: it reports what is left through Alert and
+// fails it, as 503 with a Retry-After for transient errors and 500 otherwise
+type EscalationHandler struct {
+	BaseErrorHandler
+	RetryAfter time.Duration
+	Alert      func(op string, err error)
+}
+
+func (h *EscalationHandler) Handle(ctx context.Context, f *Failure) Disposition {
+	if h.Alert != nil {
+		h.Alert(f.Op, f.Err)
+	}
+	d := Disposition{Outcome: Failed, Status: http.StatusInternalServerError, Handler: "escalation", Attempts: f.Attempts, Err: f.Err}
+	var retryable *RetryableError
+	if errors.As(f.Err, &retryable) {
+		d.Status, d.RetryAfter = http.StatusServiceUnavailable, h.RetryAfter
+	}
+	return d
+}
+
+// Recover runs op and, if it fails, lets chain decide what becomes of the failure
+func Recover(ctx context.Context, chain ErrorHandler, name string, op func(ctx context.Context) error) Disposition {
+	err := op(ctx)
+	if err == nil {
+		return Disposition{Outcome: Resolved, Status: http.StatusOK, Handler: "none", Attempts: 1}
+	}
+	return chain.Handle(ctx, &Failure{Op: name, Err: err, Attempts: 1, Retry: op})
+}
+
+func DemoErrorChain() {
+	fmt.Fprintln(out, "=== Error-handling Chain Demo ===")
+	fmt.Fprintln(out)
+
+	var alerts []string
+	chain := &RetryHandler{MaxAttempts: 3, Backoff: time.Millisecond}
+	chain.SetNext(&ValidationHandler{}).
+		SetNext(&FallbackHandler{Fallback: func(ctx context.Context, op string) error {
+			if op == "GET /products/7" {
+				return nil // served from cache
+			}
+			return errors.New("not cached")
+		}}).
+		SetNext(&EscalationHandler{RetryAfter: 5 * time.Second, Alert: func(op string, err error) {
+			alerts = append(alerts, op)
+		}})
+	// Without a terminal handler, whatever is left fails as unhandled
+	bare := &RetryHandler{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	// upstream answers with the given statuses in turn, then keeps the last one
+	upstream := func(statuses ...int) func(ctx context.Context) error {
+		call := 0
+		return func(ctx context.Context) error {
+			status := statuses[len(statuses)-1]
+			if call < len(statuses) {
+				status = statuses[call]
+			}
+			call++
+			return ClassifyStatus(status, 0)
+		}
+	}
+
+	cases := []struct {
+		op    string
+		chain ErrorHandler
+		call  func(ctx context.Context) error
+	}{
+		{"GET /users/1", chain, upstream(200)},
+		{"GET /orders/1", chain, upstream(503, 502, 200)},
+		{"POST /orders", chain, upstream(422)},
+		{"PUT /orders/2", chain, upstream(503, 400)},
+		{"GET /products/7", chain, upstream(503)},
+		{"GET /products/8", chain, upstream(504)},
+		{"DELETE /users/3", chain, upstream(500)},
+		{"GET /orders/9", bare, func(ctx context.Context) error {
+			return errors.New("connection reset") // never classified
+		}},
+	}
+	for _, tc := range cases {
+		d := Recover(context.Background(), tc.chain, tc.op, tc.call)
+		fmt.Fprintf(out, "%-16s -> %s\n", tc.op, d)
+	}
+
+	// A cancelled request stops retrying and is escalated as transient
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := Recover(ctx, chain, "GET /slow", upstream(503))
+	fmt.Fprintf(out, "Cancelled request -> %s\n", d)
+
+	fmt.Fprintf(out, "\nEscalated: %s\n", strings.Join(alerts, ", "))
+}
@@ -0,0 +1,172 @@
+package behavioral
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// upstreamAnswers fails with the given statuses in turn, then keeps the last one
+func upstreamAnswers(calls *int, statuses ...int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		status := statuses[len(statuses)-1]
+		if *calls < len(statuses) {
+			status = statuses[*calls]
+		}
+		*calls++
+		return ClassifyStatus(status, 0)
+	}
+}
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   any // nil, or a pointer to the expected error type
+	}{
+		{200, nil},
+		{304, nil},
+		{400, &ValidationError{}},
+		{404, &ValidationError{}},
+		{422, &ValidationError{}},
+		{429, &RetryableError{}},
+		{500, &FatalError{}},
+		{501, &FatalError{}},
+		{502, &RetryableError{}},
+		{503, &RetryableError{}},
+		{504, &RetryableError{}},
+	}
+	for _, tt := range tests {
+		err := ClassifyStatus(tt.status, time.Second)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("ClassifyStatus(%d) = %v, want nil", tt.status, err)
+			}
+			continue
+		}
+		if err == nil || reflect.TypeOf(err) != reflect.TypeOf(tt.want) {
+			t.Errorf("ClassifyStatus(%d) = %#v, want a %T", tt.status, err, tt.want)
+		}
+		var retryable *RetryableError
+		if errors.As(err, &retryable) && retryable.After != time.Second {
+			t.Errorf("ClassifyStatus(%d) dropped the Retry-After", tt.status)
+		}
+	}
+}
+
+func TestErrorChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		cached    bool
+		want      Outcome
+		status    int
+		handler   string
+		attempts  int
+		escalated bool
+	}{
+		{"success needs no handler", []int{200}, false, Resolved, 200, "none", 1, false},
+		{"transient failures are retried until they pass", []int{503, 502, 200}, false, Resolved, 200, "retry", 3, false},
+		{"a validation failure is rejected without retrying", []int{422}, false, Rejected, 422, "validation", 1, false},
+		{"a retry that fails differently is passed on", []int{503, 400}, false, Rejected, 422, "validation", 2, false},
+		{"the fallback answers once retries run out", []int{503}, true, Degraded, 200, "fallback", 3, false},
+		{"a failed fallback escalates the original failure as 503", []int{504}, false, Failed, 503, "escalation", 3, true},
+		{"a fatal failure escalates as 500", []int{500}, false, Failed, 500, "escalation", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var alerts []string
+			chain := &RetryHandler{MaxAttempts: 3, Backoff: time.Microsecond}
+			chain.SetNext(&ValidationHandler{}).
+				SetNext(&FallbackHandler{Fallback: func(ctx context.Context, op string) error {
+					if tt.cached {
+						return nil
+					}
+					return errors.New("not cached")
+				}}).
+				SetNext(&EscalationHandler{RetryAfter: 5 * time.Second, Alert: func(op string, err error) {
+					alerts = append(alerts, op)
+				}})
+
+			calls := 0
+			d := Recover(context.Background(), chain, "GET /thing", upstreamAnswers(&calls, tt.statuses...))
+			if d.Outcome != tt.want || d.Status != tt.status || d.Handler != tt.handler || d.Attempts != tt.attempts {
+				t.Errorf("got %s, want %s (%d) by %s after %d attempt(s)", d, tt.want, tt.status, tt.handler, tt.attempts)
+			}
+			if calls != d.Attempts {
+				t.Errorf("upstream called %d times for %d attempts", calls, d.Attempts)
+			}
+			if (d.Err == nil) != (tt.want == Resolved) {
+				t.Errorf("Err = %v for a %s outcome", d.Err, d.Outcome)
+			}
+			if escalated := len(alerts) == 1; escalated != tt.escalated {
+				t.Errorf("alerts %v, want escalated=%v", alerts, tt.escalated)
+			}
+			// Only a transient failure tells the caller when to come back
+			var wantAfter time.Duration
+			if tt.status == 503 {
+				wantAfter = 5 * time.Second
+			}
+			if d.RetryAfter != wantAfter {
+				t.Errorf("RetryAfter = %v, want %v", d.RetryAfter, wantAfter)
+			}
+		})
+	}
+
+	t.Run("without a terminal handler the failure ends unhandled", func(t *testing.T) {
+		bare := &RetryHandler{MaxAttempts: 3, Backoff: time.Microsecond}
+		reset := errors.New("connection reset")
+		d := Recover(context.Background(), bare, "GET /thing", func(context.Context) error { return reset })
+		if d.Outcome != Failed || d.Status != 500 || d.Handler != "unhandled" || d.Err != reset {
+			t.Errorf("got %s", d)
+		}
+	})
+
+	t.Run("the retry waits at least as long as the error asks", func(t *testing.T) {
+		calls := 0
+		retry := &RetryHandler{MaxAttempts: 2, Backoff: time.Microsecond}
+		start := time.Now()
+		retry.Handle(context.Background(), &Failure{
+			Err:      &RetryableError{Err: errors.New("slow down"), After: 20 * time.Millisecond},
+			Attempts: 1,
+			Retry:    upstreamAnswers(&calls, 200),
+		})
+		if waited := time.Since(start); waited < 20*time.Millisecond || calls != 1 {
+			t.Errorf("retried %d time(s) after %v, want once after 20ms", calls, waited)
+		}
+	})
+
+	t.Run("a cancelled request stops retrying and escalates as transient", func(t *testing.T) {
+		var alerts []string
+		chain := &RetryHandler{MaxAttempts: 3, Backoff: time.Hour}
+		chain.SetNext(&EscalationHandler{RetryAfter: 5 * time.Second, Alert: func(op string, err error) {
+			alerts = append(alerts, op)
+		}})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		calls := 0
+		d := Recover(ctx, chain, "GET /slow", upstreamAnswers(&calls, 503))
+		if d.Status != 503 || d.Attempts != 1 || calls != 1 || !errors.Is(d.Err, context.Canceled) || len(alerts) != 1 {
+			t.Errorf("got %s after %d call(s), alerts %v", d, calls, alerts)
+		}
+	})
+}
+
+func TestDemoErrorChainPrintsEachDisposition(t *testing.T) {
+	buf := captureOutput(t)
+	DemoErrorChain()
+	assertLines(t, buf,
+		"=== Error-handling Chain Demo ===",
+		"GET /users/1     -> resolved (200) by none after 1 attempt(s)",
+		"GET /orders/1    -> resolved (200) by retry after 3 attempt(s)",
+		"POST /orders     -> rejected (422) by validation after 1 attempt(s): invalid request: upstream answered 422 Unprocessable Entity",
+		"PUT /orders/2    -> rejected (422) by validation after 2 attempt(s): invalid request: upstream answered 400 Bad Request",
+		"GET /products/7  -> degraded (200) by fallback after 3 attempt(s): retryable: upstream answered 503 Service Unavailable",
+		"GET /products/8  -> failed (503) by escalation after 3 attempt(s): retryable: upstream answered 504 Gateway Timeout",
+		"DELETE /users/3  -> failed (500) by escalation after 1 attempt(s): fatal: upstream answered 500 Internal Server Error",
+		"GET /orders/9    -> failed (500) by unhandled after 1 attempt(s): connection reset",
+		"Cancelled request -> failed (503) by escalation after 1 attempt(s): retryable: context canceled",
+		"Escalated: GET /products/8, DELETE /users/3, GET /slow",
+	)
+}
@@ -73,7 +73,7 @@ func (c *CEO) Handle(req *Request) string {
 }
 
 func DemoChainOfResponsibility() {
-	fmt.Println("=== Chain of Responsibility Pattern Demo ===\n")
+	fmt.Fprint(out, "=== Chain of Responsibility Pattern Demo ===\n\n")
 
 	manager := &Manager{}
 	director := &Director{}
@@ -91,6 +91,6 @@ func DemoChainOfResponsibility() {
 
 	for _, req := range requests {
 		result := manager.Handle(req)
-		fmt.Printf("%s request for %d: %s\n", req.RequestType, req.Amount, result)
+		fmt.Fprintf(out, "%s request for %d: %s\n", req.RequestType, req.Amount, result)
 	}
 }
@@ -0,0 +1,53 @@
+package behavioral
+
+import "testing"
+
+func TestApprovalChain(t *testing.T) {
+	manager := &Manager{}
+	manager.SetNext(&Director{}).SetNext(&CEO{})
+
+	tests := []struct {
+		request Request
+		want    string
+	}{
+		{Request{"leave", 2}, "Manager approved 2 day leave"},
+		{Request{"leave", 3}, "Manager approved 3 day leave"},
+		{Request{"leave", 4}, "Director approved 4 day leave"},
+		{Request{"leave", 7}, "Director approved 7 day leave"},
+		{Request{"leave", 10}, "CEO approved 10 day leave"},
+		{Request{"purchase", 5000}, "Director approved $5000 purchase"},
+		{Request{"purchase", 10000}, "Director approved $10000 purchase"},
+		{Request{"purchase", 50000}, "CEO approved $50000 purchase"},
+		{Request{"expense", 10}, "Request not handled"},
+	}
+	for _, tt := range tests {
+		req := tt.request
+		if got := manager.Handle(&req); got != tt.want {
+			t.Errorf("%s %d: %q, want %q", req.RequestType, req.Amount, got, tt.want)
+		}
+	}
+}
+
+func TestShortChainLeavesRequestsUnhandled(t *testing.T) {
+	manager := &Manager{}
+	if got := manager.Handle(&Request{"leave", 10}); got != "Request not handled" {
+		t.Errorf("got %q", got)
+	}
+	manager.SetNext(&Director{})
+	if got := manager.Handle(&Request{"purchase", 50000}); got != "Request not handled" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDemoChainOfResponsibilityPrintsEachDecision(t *testing.T) {
+	buf := captureOutput(t)
+	DemoChainOfResponsibility()
+	assertLines(t, buf,
+		"=== Chain of Responsibility Pattern Demo ===",
+		"leave request for 2: Manager approved 2 day leave",
+		"leave request for 5: Director approved 5 day leave",
+		"leave request for 10: CEO approved 10 day leave",
+		"purchase request for 5000: Director approved $5000 purchase",
+		"purchase request for 50000: CEO approved $50000 purchase",
+	)
+}
@@ -17,12 +17,12 @@ type Light struct {
 
 func (l *Light) On() {
 	l.isOn = true
-	fmt.Println("Light is ON")
+	fmt.Fprintln(out, "Light is ON")
 }
 
 func (l *Light) Off() {
 	l.isOn = false
-	fmt.Println("Light is OFF")
+	fmt.Fprintln(out, "Light is OFF")
 }
 
 // Concrete Commands
@@ -100,18 +100,18 @@ type WriteCommand struct {
 
 func (c *WriteCommand) Execute() {
 	c.editor.Write(c.text)
-	fmt.Printf("Wrote: '%s' -> Text: '%s'\n", c.text, c.editor.GetText())
+	fmt.Fprintf(out, "Wrote: '%s' -> Text: '%s'\n", c.text, c.editor.GetText())
 }
 
 func (c *WriteCommand) Undo() {
 	c.editor.Delete(len(c.text))
-	fmt.Printf("Undid write -> Text: '%s'\n", c.editor.GetText())
+	fmt.Fprintf(out, "Undid write -> Text: '%s'\n", c.editor.GetText())
 }
 
 func DemoCommand() {
-	fmt.Println("=== Command Pattern Demo ===\n")
+	fmt.Fprint(out, "=== Command Pattern Demo ===\n\n")
 
-	fmt.Println("1. Light Control:")
+	fmt.Fprintln(out, "1. Light Control:")
 	light := &Light{}
 	remote := &RemoteControl{}
 
@@ -121,10 +121,10 @@ func DemoCommand() {
 	remote.SetCommand(&LightOffCommand{light: light})
 	remote.PressButton()
 
-	fmt.Println("\nUndo last command:")
+	fmt.Fprintln(out, "\nUndo last command:")
 	remote.PressUndo()
 
-	fmt.Println("\n2. Text Editor:")
+	fmt.Fprintln(out, "\n2. Text Editor:")
 	editor := &TextEditor{}
 	history := []Command{}
 
@@ -136,7 +136,7 @@ func DemoCommand() {
 	cmd2.Execute()
 	history = append(history, cmd2)
 
-	fmt.Println("\nUndoing commands:")
+	fmt.Fprintln(out, "\nUndoing commands:")
 	for i := len(history) - 1; i >= 0; i-- {
 		history[i].Undo()
 	}
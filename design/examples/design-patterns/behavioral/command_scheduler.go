@@ -0,0 +1,353 @@
+package behavioral
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduled Command execution.
+// A command is a request turned into an object, so nothing forces it to run
+// right away: the Scheduler holds commands and executes them at a given time
+// or on a fixed interval. Jobs refer to commands by kind and arguments rather
+// than holding Command values, which lets the whole schedule be saved as JSON
+// and loaded again after a restart. A CommandRegistry turns kinds back into commands.
+
+var (
+	ErrUnknownCommand = errors.New("scheduler: unknown command kind")
+	ErrNoSuchJob      = errors.New("scheduler: no such job")
+)
+
+// CommandFactory builds the command for one run of a job
+type CommandFactory func(args map[string]string) (Command, error)
+
+// CommandRegistry maps job kinds to the factories that build their commands
+type CommandRegistry map[string]CommandFactory
+
+// ScheduledJob is one entry of the schedule, in the form it is saved in
+type ScheduledJob struct {
+	ID        string            `json:"id"`
+	Kind      string            `json:"kind"`
+	Args      map[string]string `json:"args,omitempty"`
+	NextRun   time.Time         `json:"next_run"`
+	Every     time.Duration     `json:"every,omitempty"` // zero runs the job once
+	Runs      int               `json:"runs"`
+	LastError string            `json:"last_error,omitempty"`
+}
+
+type Scheduler struct {
+	registry CommandRegistry
+
+	mu      sync.Mutex
+	jobs    map[string]*ScheduledJob
+	nextID  int
+	running bool
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewScheduler(registry CommandRegistry) *Scheduler {
+	return &Scheduler{
+		registry: registry,
+		jobs:     make(map[string]*ScheduledJob),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// At schedules a single run of kind at t
+func (s *Scheduler) At(t time.Time, kind string, args map[string]string) (string, error) {
+	return s.add(ScheduledJob{Kind: kind, Args: args, NextRun: t})
+}
+
+// After schedules a single run of kind once d has passed
+func (s *Scheduler) After(d time.Duration, kind string, args map[string]string) (string, error) {
+	return s.At(time.Now().Add(d), kind, args)
+}
+
+// Every runs kind every d, the first time d from now
+func (s *Scheduler) Every(d time.Duration, kind string, args map[string]string) (string, error) {
+	if d <= 0 {
+		return "", fmt.Errorf("scheduler: interval must be positive, got %v", d)
+	}
+	return s.add(ScheduledJob{Kind: kind, Args: args, NextRun: time.Now().Add(d), Every: d})
+}
+
+func (s *Scheduler) add(job ScheduledJob) (string, error) {
+	if _, ok := s.registry[job.Kind]; !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownCommand, job.Kind)
+	}
+	s.mu.Lock()
+	s.nextID++
+	job.ID = fmt.Sprintf("job-%d", s.nextID)
+	s.jobs[job.ID] = &job
+	s.mu.Unlock()
+	s.poke()
+	return job.ID, nil
+}
+
+// Cancel removes a job. A run that has already started is not interrupted.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	_, ok := s.jobs[id]
+	delete(s.jobs, id)
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrNoSuchJob, id)
+	}
+	s.poke()
+	return nil
+}
+
+// Jobs returns the pending jobs ordered by next run
+func (s *Scheduler) Jobs() []ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]ScheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].NextRun.Equal(jobs[j].NextRun) {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].NextRun.Before(jobs[j].NextRun)
+	})
+	return jobs
+}
+
+// poke makes the run loop recompute its next deadline
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs due jobs in a background goroutine until Stop
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop(s.stop, s.done)
+}
+
+// Stop waits for a run in progress to finish and keeps the remaining jobs,
+// so the schedule can be saved or started again
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	stop, done := s.stop, s.done
+	s.mu.Unlock()
+	close(stop)
+	<-done
+}
+
+func (s *Scheduler) loop(stop, done chan struct{}) {
+	defer close(done)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.runDue(time.Now())
+
+		wait := time.Hour
+		if next, ok := s.nextRun(); ok {
+			wait = time.Until(next)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) nextRun() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next time.Time
+	for _, job := range s.jobs {
+		if next.IsZero() || job.NextRun.Before(next) {
+			next = job.NextRun
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// runDue executes every job due at now, earliest first. An interval job that
+// missed several runs (the process was stopped) runs once and then continues
+// on its original cadence instead of firing a burst to catch up.
+func (s *Scheduler) runDue(now time.Time) {
+	for _, job := range s.Jobs() {
+		if job.NextRun.After(now) {
+			break
+		}
+		err := s.execute(job)
+
+		s.mu.Lock()
+		current, ok := s.jobs[job.ID]
+		if ok { // not cancelled while running
+			current.Runs++
+			current.LastError = ""
+			if err != nil {
+				current.LastError = err.Error()
+			}
+			if current.Every == 0 {
+				delete(s.jobs, job.ID)
+			} else {
+				for !current.NextRun.After(now) {
+					current.NextRun = current.NextRun.Add(current.Every)
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *Scheduler) execute(job ScheduledJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	factory, ok := s.registry[job.Kind]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownCommand, job.Kind)
+	}
+	cmd, err := factory(job.Args)
+	if err != nil {
+		return err
+	}
+	cmd.Execute()
+	return nil
+}
+
+type savedSchedule struct {
+	NextID int            `json:"next_id"`
+	Jobs   []ScheduledJob `json:"jobs"`
+}
+
+// Save writes the pending jobs as JSON
+func (s *Scheduler) Save(w io.Writer) error {
+	jobs := s.Jobs()
+	s.mu.Lock()
+	saved := savedSchedule{NextID: s.nextID, Jobs: jobs}
+	s.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(saved)
+}
+
+// LoadScheduler restores a saved schedule. Every job kind must be in registry.
+// Jobs whose time passed while nothing was running are due immediately on Start.
+func LoadScheduler(r io.Reader, registry CommandRegistry) (*Scheduler, error) {
+	var saved savedSchedule
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("scheduler: load: %w", err)
+	}
+	s := NewScheduler(registry)
+	s.nextID = saved.NextID
+	for _, job := range saved.Jobs {
+		if _, ok := registry[job.Kind]; !ok {
+			return nil, fmt.Errorf("%w %q (job %s)", ErrUnknownCommand, job.Kind, job.ID)
+		}
+		job := job
+		s.jobs[job.ID] = &job
+	}
+	return s, nil
+}
+
+func DemoCommandScheduler() {
+	fmt.Fprintln(out, "=== Command Scheduler Demo ===")
+	fmt.Fprintln(out)
+
+	// Receivers live outside the schedule; the registry binds kinds to them
+	light := &Light{}
+	editor := &TextEditor{}
+	registry := CommandRegistry{
+		"light.toggle": func(map[string]string) (Command, error) {
+			if light.isOn {
+				return &LightOffCommand{light: light}, nil
+			}
+			return &LightOnCommand{light: light}, nil
+		},
+		"editor.write": func(args map[string]string) (Command, error) {
+			text, ok := args["text"]
+			if !ok {
+				return nil, errors.New("editor.write needs a text argument")
+			}
+			return &WriteCommand{editor: editor, text: text}, nil
+		},
+	}
+
+	// Deadlines sit well apart so scheduling jitter cannot reorder them
+	const tick = 40 * time.Millisecond
+	s := NewScheduler(registry)
+	s.Every(tick, "light.toggle", nil)
+	s.After(tick*3/2, "editor.write", map[string]string{"text": "Hello "})
+	typo, _ := s.After(tick*5/2, "editor.write", map[string]string{"text": "Wrold"})
+	s.After(tick*7/2, "editor.write", map[string]string{"text": "World!"})
+	if _, err := s.After(tick, "editor.bold", nil); err != nil {
+		fmt.Fprintf(out, "Rejected: %v\n", err)
+	}
+	s.Cancel(typo)
+	fmt.Fprintf(out, "Cancelled %s before it ran\n\n", typo)
+
+	fmt.Fprintln(out, "1. Running for two ticks:")
+	s.Start()
+	time.Sleep(tick*2 + tick/4)
+	s.Stop()
+
+	var saved bytes.Buffer
+	if err := s.Save(&saved); err != nil {
+		fmt.Fprintf(out, "Save failed: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "\n2. Stopped and saved %d pending jobs:\n", len(s.Jobs()))
+	for _, job := range s.Jobs() {
+		every := "once"
+		if job.Every > 0 {
+			every = "every " + job.Every.String()
+		}
+		fmt.Fprintf(out, "   %s %-13s %-14s runs=%d\n", job.ID, job.Kind, every, job.Runs)
+	}
+
+	restored, err := LoadScheduler(strings.NewReader(saved.String()), registry)
+	if err != nil {
+		fmt.Fprintf(out, "Load failed: %v\n", err)
+		return
+	}
+	fmt.Fprintln(out, "\n3. Restored and running until the last write:")
+	restored.Start()
+	time.Sleep(tick*2 + tick/2)
+	restored.Stop()
+
+	fmt.Fprintf(out, "\nFinal text: %q\n", editor.GetText())
+	for _, job := range restored.Jobs() {
+		fmt.Fprintf(out, "%s (%s) ran %d times in total\n", job.ID, job.Kind, job.Runs)
+	}
+}
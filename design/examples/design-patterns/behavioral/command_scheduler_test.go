@@ -0,0 +1,182 @@
+package behavioral
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// funcCommand runs f; the scheduler never undoes commands
+type funcCommand func()
+
+func (f funcCommand) Execute() { f() }
+func (f funcCommand) Undo()    {}
+
+// tally returns a registry whose "count" kind records the "name" argument of
+// every run, plus kinds that fail and panic
+func tally() (CommandRegistry, *[]string) {
+	var ran []string
+	return CommandRegistry{
+		"count": func(args map[string]string) (Command, error) {
+			return funcCommand(func() { ran = append(ran, args["name"]) }), nil
+		},
+		"fail": func(map[string]string) (Command, error) {
+			return nil, errors.New("bad args")
+		},
+		"panic": func(map[string]string) (Command, error) {
+			return funcCommand(func() { panic("boom") }), nil
+		},
+	}, &ran
+}
+
+func named(name string) map[string]string { return map[string]string{"name": name} }
+
+func TestSchedulerRejectsBadJobs(t *testing.T) {
+	registry, _ := tally()
+	s := NewScheduler(registry)
+	if _, err := s.After(time.Second, "unknown", nil); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("After(unknown) = %v, want ErrUnknownCommand", err)
+	}
+	if _, err := s.Every(0, "count", nil); err == nil {
+		t.Error("Every(0) was accepted")
+	}
+	if err := s.Cancel("job-1"); !errors.Is(err, ErrNoSuchJob) {
+		t.Errorf("Cancel(job-1) = %v, want ErrNoSuchJob", err)
+	}
+	if jobs := s.Jobs(); len(jobs) != 0 {
+		t.Errorf("rejected jobs were scheduled: %v", jobs)
+	}
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	registry, ran := tally()
+	s := NewScheduler(registry)
+	start := time.Now()
+	s.At(start.Add(3*time.Second), "count", named("c"))
+	s.At(start.Add(1*time.Second), "count", named("a"))
+	every, _ := s.Every(time.Minute, "count", named("tick"))
+	s.At(start.Add(2*time.Second), "count", named("b"))
+	late, _ := s.At(start.Add(time.Hour), "count", named("late"))
+	s.Cancel(late)
+
+	s.runDue(start.Add(2 * time.Second))
+	if want := []string{"a", "b"}; strings.Join(*ran, ",") != strings.Join(want, ",") {
+		t.Errorf("ran %v, want %v in order of their times", *ran, want)
+	}
+	if jobs := s.Jobs(); len(jobs) != 2 || jobs[0].Kind != "count" || jobs[0].Args["name"] != "c" {
+		t.Errorf("pending %v, want c then the interval job", jobs)
+	}
+
+	t.Run("an interval job that missed runs fires once and keeps its cadence", func(t *testing.T) {
+		*ran = nil
+		first := s.Jobs()[1].NextRun
+		s.runDue(first.Add(5*time.Minute + time.Second))
+		if count := strings.Count(strings.Join(*ran, ","), "tick"); count != 1 {
+			t.Errorf("ran %v, want a single tick", *ran)
+		}
+		var job ScheduledJob
+		for _, j := range s.Jobs() {
+			if j.ID == every {
+				job = j
+			}
+		}
+		if want := first.Add(6 * time.Minute); !job.NextRun.Equal(want) || job.Runs != 1 {
+			t.Errorf("next run %v after %d runs, want %v after 1", job.NextRun, job.Runs, want)
+		}
+	})
+}
+
+func TestSchedulerRecordsFailures(t *testing.T) {
+	registry, _ := tally()
+	s := NewScheduler(registry)
+	s.Every(time.Minute, "fail", nil)
+	s.Every(time.Minute, "panic", nil)
+	s.runDue(time.Now().Add(time.Minute))
+
+	want := map[string]string{"fail": "bad args", "panic": "panic: boom"}
+	for _, job := range s.Jobs() {
+		if job.LastError != want[job.Kind] || job.Runs != 1 {
+			t.Errorf("%s: runs=%d error %q, want 1 and %q", job.Kind, job.Runs, job.LastError, want[job.Kind])
+		}
+	}
+}
+
+func TestSchedulerSaveAndLoad(t *testing.T) {
+	registry, ran := tally()
+	s := NewScheduler(registry)
+	now := time.Now()
+	s.Every(time.Minute, "count", named("tick"))
+	s.At(now.Add(time.Hour), "count", named("once"))
+	s.runDue(time.Now().Add(time.Minute))
+
+	var saved bytes.Buffer
+	if err := s.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := LoadScheduler(strings.NewReader(saved.String()), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := restored.Jobs(), s.Jobs()
+	if len(got) != len(want) {
+		t.Fatalf("restored %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Runs != want[i].Runs || !got[i].NextRun.Equal(want[i].NextRun) || got[i].Every != want[i].Every {
+			t.Errorf("restored %+v, want %+v", got[i], want[i])
+		}
+	}
+
+	t.Run("new jobs do not reuse saved IDs", func(t *testing.T) {
+		id, _ := restored.After(time.Second, "count", named("new"))
+		if id != "job-3" {
+			t.Errorf("new job is %s, want job-3", id)
+		}
+	})
+	t.Run("jobs that fell due while stopped run on the next pass", func(t *testing.T) {
+		*ran = nil
+		restored.runDue(now.Add(2 * time.Hour))
+		if strings.Join(*ran, ",") != "new,tick,once" {
+			t.Errorf("ran %q", *ran)
+		}
+	})
+	t.Run("a saved kind missing from the registry fails the load", func(t *testing.T) {
+		_, err := LoadScheduler(strings.NewReader(saved.String()), CommandRegistry{})
+		if !errors.Is(err, ErrUnknownCommand) {
+			t.Errorf("LoadScheduler = %v, want ErrUnknownCommand", err)
+		}
+	})
+	t.Run("malformed JSON fails the load", func(t *testing.T) {
+		if _, err := LoadScheduler(strings.NewReader("{"), registry); err == nil {
+			t.Error("LoadScheduler accepted malformed JSON")
+		}
+	})
+}
+
+func TestSchedulerRunsJobsInTheBackground(t *testing.T) {
+	done := make(chan string, 1)
+	s := NewScheduler(CommandRegistry{
+		"signal": func(args map[string]string) (Command, error) {
+			return funcCommand(func() { done <- args["name"] }), nil
+		},
+	})
+	s.Start()
+	defer s.Stop()
+	// Scheduled after Start, so the loop must wake up for it
+	s.After(10*time.Millisecond, "signal", named("ran"))
+
+	select {
+	case got := <-done:
+		if got != "ran" {
+			t.Errorf("got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job never ran")
+	}
+	s.Stop()
+	if jobs := s.Jobs(); len(jobs) != 0 {
+		t.Errorf("a one-off job is still pending: %v", jobs)
+	}
+}
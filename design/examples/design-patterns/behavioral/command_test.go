@@ -0,0 +1,72 @@
+package behavioral
+
+import "testing"
+
+func TestRemoteControlExecutesAndUndoes(t *testing.T) {
+	buf := captureOutput(t)
+	light := &Light{}
+	remote := &RemoteControl{}
+
+	remote.SetCommand(&LightOnCommand{light: light})
+	remote.PressButton()
+	if !light.isOn {
+		t.Fatal("light is off after LightOnCommand")
+	}
+	remote.SetCommand(&LightOffCommand{light: light})
+	remote.PressButton()
+	if light.isOn {
+		t.Fatal("light is on after LightOffCommand")
+	}
+	assertLines(t, buf, "Light is ON", "Light is OFF")
+
+	remote.PressUndo()
+	if !light.isOn {
+		t.Error("undoing LightOffCommand left the light off")
+	}
+	remote.PressUndo()
+	if light.isOn {
+		t.Error("undoing LightOnCommand left the light on")
+	}
+	assertLines(t, buf, "Light is ON", "Light is OFF")
+
+	remote.PressUndo()
+	assertLines(t, buf)
+}
+
+func TestWriteCommandUndoesInReverseOrder(t *testing.T) {
+	buf := captureOutput(t)
+	editor := &TextEditor{}
+	history := []Command{
+		&WriteCommand{editor: editor, text: "Hello "},
+		&WriteCommand{editor: editor, text: "World!"},
+	}
+	for _, cmd := range history {
+		cmd.Execute()
+	}
+	if got := editor.GetText(); got != "Hello World!" {
+		t.Fatalf("text = %q, want %q", got, "Hello World!")
+	}
+
+	history[1].Undo()
+	if got := editor.GetText(); got != "Hello " {
+		t.Errorf("after one undo, text = %q, want %q", got, "Hello ")
+	}
+	history[0].Undo()
+	if got := editor.GetText(); got != "" {
+		t.Errorf("after two undos, text = %q, want empty", got)
+	}
+	assertLines(t, buf,
+		"Wrote: 'Hello ' -> Text: 'Hello '",
+		"Wrote: 'World!' -> Text: 'Hello World!'",
+		"Undid write -> Text: 'Hello '",
+		"Undid write -> Text: ''",
+	)
+}
+
+func TestTextEditorDeleteStopsAtEmpty(t *testing.T) {
+	editor := &TextEditor{text: "abc"}
+	editor.Delete(10)
+	if got := editor.GetText(); got != "" {
+		t.Errorf("text = %q, want empty", got)
+	}
+}
@@ -0,0 +1,282 @@
+package behavioral
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Finite State Machine - a reusable engine for the State pattern.
+// The VendingMachine in state.go spreads its transitions across one type per state.
+// FSM instead takes a declarative transition table: each row says which event moves
+// the machine from one state to another, optionally guarded by a condition.
+// Rows sharing the same (From, Event) are alternatives tried in table order,
+// and the first whose guard passes is taken.
+
+// Transition is one row of the table. A nil Guard always allows the transition.
+type Transition[S, E comparable] struct {
+	From  S
+	Event E
+	To    S
+	Guard func() error
+}
+
+// Hook runs when the machine leaves or enters a state
+type Hook[S, E comparable] func(from S, event E, to S)
+
+// IllegalTransitionError is returned when no row matches the current state and event
+type IllegalTransitionError[S, E comparable] struct {
+	State S
+	Event E
+}
+
+func (e *IllegalTransitionError[S, E]) Error() string {
+	return fmt.Sprintf("illegal transition: event %v in state %v", e.Event, e.State)
+}
+
+// GuardError is returned when matching rows exist but every guard rejected the event
+type GuardError[S, E comparable] struct {
+	State S
+	Event E
+	Err   error
+}
+
+func (e *GuardError[S, E]) Error() string {
+	return fmt.Sprintf("event %v in state %v rejected: %v", e.Event, e.State, e.Err)
+}
+
+func (e *GuardError[S, E]) Unwrap() error {
+	return e.Err
+}
+
+type fsmKey[S, E comparable] struct {
+	state S
+	event E
+}
+
+// FSM is not safe for concurrent use; hooks must not call Fire.
+type FSM[S, E comparable] struct {
+	current S
+	table   map[fsmKey[S, E]][]Transition[S, E]
+	events  []E
+	onEnter map[S][]Hook[S, E]
+	onExit  map[S][]Hook[S, E]
+}
+
+// NewFSM builds a machine from a transition table. A row placed after an
+// unguarded row with the same (From, Event) could never fire, so it is rejected.
+func NewFSM[S, E comparable](initial S, transitions []Transition[S, E]) (*FSM[S, E], error) {
+	m := &FSM[S, E]{
+		current: initial,
+		table:   make(map[fsmKey[S, E]][]Transition[S, E]),
+		onEnter: make(map[S][]Hook[S, E]),
+		onExit:  make(map[S][]Hook[S, E]),
+	}
+	seen := make(map[E]bool)
+	for _, t := range transitions {
+		key := fsmKey[S, E]{t.From, t.Event}
+		rows := m.table[key]
+		if len(rows) > 0 && rows[len(rows)-1].Guard == nil {
+			return nil, fmt.Errorf("transition %v --%v--> %v is unreachable", t.From, t.Event, t.To)
+		}
+		m.table[key] = append(rows, t)
+		if !seen[t.Event] {
+			seen[t.Event] = true
+			m.events = append(m.events, t.Event)
+		}
+	}
+	return m, nil
+}
+
+func (m *FSM[S, E]) Current() S {
+	return m.current
+}
+
+// OnEnter registers a hook that runs after the machine enters state
+func (m *FSM[S, E]) OnEnter(state S, hook Hook[S, E]) {
+	m.onEnter[state] = append(m.onEnter[state], hook)
+}
+
+// OnExit registers a hook that runs before the machine leaves state
+func (m *FSM[S, E]) OnExit(state S, hook Hook[S, E]) {
+	m.onExit[state] = append(m.onExit[state], hook)
+}
+
+// Fire applies event to the current state. On error the state is unchanged.
+func (m *FSM[S, E]) Fire(event E) error {
+	t, err := m.match(event)
+	if err != nil {
+		return err
+	}
+	from := m.current
+	for _, hook := range m.onExit[from] {
+		hook(from, event, t.To)
+	}
+	m.current = t.To
+	for _, hook := range m.onEnter[t.To] {
+		hook(from, event, t.To)
+	}
+	return nil
+}
+
+// Can reports whether event would currently succeed
+func (m *FSM[S, E]) Can(event E) bool {
+	_, err := m.match(event)
+	return err == nil
+}
+
+// Permitted lists the events that would currently succeed, in table order
+func (m *FSM[S, E]) Permitted() []E {
+	var events []E
+	for _, e := range m.events {
+		if m.Can(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func (m *FSM[S, E]) match(event E) (Transition[S, E], error) {
+	rows, ok := m.table[fsmKey[S, E]{m.current, event}]
+	if !ok {
+		return Transition[S, E]{}, &IllegalTransitionError[S, E]{State: m.current, Event: event}
+	}
+	var rejected []string
+	for _, t := range rows {
+		if t.Guard == nil {
+			return t, nil
+		}
+		err := t.Guard()
+		if err == nil {
+			return t, nil
+		}
+		rejected = append(rejected, err.Error())
+	}
+	return Transition[S, E]{}, &GuardError[S, E]{
+		State: m.current,
+		Event: event,
+		Err:   fmt.Errorf("%s", strings.Join(rejected, "; ")),
+	}
+}
+
+// Vending machine rebuilt on the FSM engine
+
+type VendingState string
+
+const (
+	VendingNoCoin  VendingState = "no coin"
+	VendingHasCoin VendingState = "has coin"
+	VendingSold    VendingState = "sold"
+	VendingSoldOut VendingState = "sold out"
+)
+
+type VendingEvent string
+
+const (
+	EventInsertCoin  VendingEvent = "insert coin"
+	EventEjectCoin   VendingEvent = "eject coin"
+	EventPressButton VendingEvent = "press button"
+	EventDispense    VendingEvent = "dispense"
+	EventRefill      VendingEvent = "refill"
+)
+
+type FSMVendingMachine struct {
+	fsm   *FSM[VendingState, VendingEvent]
+	count int
+}
+
+func NewFSMVendingMachine(count int) *FSMVendingMachine {
+	vm := &FSMVendingMachine{count: count}
+
+	inStock := func() error {
+		if vm.count == 0 {
+			return fmt.Errorf("no items left")
+		}
+		return nil
+	}
+	emptied := func() error {
+		if vm.count > 0 {
+			return fmt.Errorf("%d items left", vm.count)
+		}
+		return nil
+	}
+
+	initial := VendingNoCoin
+	if count == 0 {
+		initial = VendingSoldOut
+	}
+	// The table is the whole machine: every legal move is listed here
+	fsm, err := NewFSM(initial, []Transition[VendingState, VendingEvent]{
+		{From: VendingNoCoin, Event: EventInsertCoin, To: VendingHasCoin},
+		{From: VendingHasCoin, Event: EventEjectCoin, To: VendingNoCoin},
+		{From: VendingHasCoin, Event: EventPressButton, To: VendingSold, Guard: inStock},
+		{From: VendingSold, Event: EventDispense, To: VendingNoCoin, Guard: inStock},
+		{From: VendingSold, Event: EventDispense, To: VendingSoldOut, Guard: emptied},
+		{From: VendingSoldOut, Event: EventRefill, To: VendingNoCoin, Guard: inStock},
+	})
+	if err != nil {
+		panic(err) // the table above is static
+	}
+
+	fsm.OnEnter(VendingSold, func(VendingState, VendingEvent, VendingState) {
+		fmt.Fprintln(out, "Item dispensed")
+		vm.count--
+	})
+	fsm.OnEnter(VendingSoldOut, func(VendingState, VendingEvent, VendingState) {
+		fmt.Fprintln(out, "Machine sold out")
+	})
+	vm.fsm = fsm
+	return vm
+}
+
+func (vm *FSMVendingMachine) InsertCoin() error {
+	return vm.fsm.Fire(EventInsertCoin)
+}
+
+func (vm *FSMVendingMachine) EjectCoin() error {
+	return vm.fsm.Fire(EventEjectCoin)
+}
+
+// PressButton sells an item and immediately completes the dispense step
+func (vm *FSMVendingMachine) PressButton() error {
+	if err := vm.fsm.Fire(EventPressButton); err != nil {
+		return err
+	}
+	return vm.fsm.Fire(EventDispense)
+}
+
+func (vm *FSMVendingMachine) Refill(count int) error {
+	vm.count += count
+	return vm.fsm.Fire(EventRefill)
+}
+
+func (vm *FSMVendingMachine) State() VendingState {
+	return vm.fsm.Current()
+}
+
+func (vm *FSMVendingMachine) GetCount() int {
+	return vm.count
+}
+
+func DemoFSM() {
+	fmt.Fprintln(out, "=== FSM Engine Demo ===")
+	fmt.Fprintln(out)
+
+	vm := NewFSMVendingMachine(2)
+	report := func(action string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "%-14s -> error: %v\n", action, err)
+			return
+		}
+		fmt.Fprintf(out, "%-14s -> state %q, %d left\n", action, vm.State(), vm.GetCount())
+	}
+
+	report("press button", vm.PressButton())
+	report("insert coin", vm.InsertCoin())
+	report("insert coin", vm.InsertCoin())
+	report("press button", vm.PressButton())
+	report("insert coin", vm.InsertCoin())
+	report("press button", vm.PressButton())
+	report("insert coin", vm.InsertCoin())
+	report("refill", vm.Refill(1))
+	fmt.Fprintln(out, "Permitted now:", vm.fsm.Permitted())
+}
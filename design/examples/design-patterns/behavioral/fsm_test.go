@@ -0,0 +1,193 @@
+package behavioral
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type light string
+type press string
+
+func TestFSMEngine(t *testing.T) {
+	t.Run("rows sharing a state and event are tried in order; the first passing guard wins", func(t *testing.T) {
+		var tried []string
+		guard := func(name string, err error) func() error {
+			return func() error { tried = append(tried, name); return err }
+		}
+		m, err := NewFSM[light, press]("off", []Transition[light, press]{
+			{From: "off", Event: "on", To: "dim", Guard: guard("dim", errors.New("too dark"))},
+			{From: "off", Event: "on", To: "bright", Guard: guard("bright", nil)},
+			{From: "off", Event: "on", To: "never"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Fire("on"); err != nil || m.Current() != "bright" {
+			t.Errorf("Fire = %v, now %q; want bright", err, m.Current())
+		}
+		if !reflect.DeepEqual(tried, []string{"dim", "bright"}) {
+			t.Errorf("guards tried %v", tried)
+		}
+	})
+
+	t.Run("a row after an unguarded one with the same state and event is unreachable", func(t *testing.T) {
+		_, err := NewFSM[light, press]("off", []Transition[light, press]{
+			{From: "off", Event: "on", To: "bright"},
+			{From: "off", Event: "on", To: "dim", Guard: func() error { return nil }},
+		})
+		if err == nil || !strings.Contains(err.Error(), "off --on--> dim is unreachable") {
+			t.Errorf("NewFSM = %v", err)
+		}
+	})
+
+	m, _ := NewFSM[light, press]("off", []Transition[light, press]{
+		{From: "off", Event: "on", To: "bright", Guard: func() error { return errors.New("fuse blown") }},
+		{From: "off", Event: "on", To: "dim", Guard: func() error { return errors.New("no bulb") }},
+		{From: "off", Event: "test", To: "off"},
+	})
+	t.Run("an event with no row is illegal, and the state is unchanged", func(t *testing.T) {
+		err := m.Fire("off")
+		var illegal *IllegalTransitionError[light, press]
+		if !errors.As(err, &illegal) || illegal.State != "off" || illegal.Event != "off" || m.Current() != "off" {
+			t.Errorf("Fire = %v, now %q", err, m.Current())
+		}
+	})
+	t.Run("when every guard rejects, the error lists each reason", func(t *testing.T) {
+		err := m.Fire("on")
+		var rejected *GuardError[light, press]
+		if !errors.As(err, &rejected) || rejected.Err.Error() != "fuse blown; no bulb" || m.Current() != "off" {
+			t.Errorf("Fire = %v, now %q", err, m.Current())
+		}
+	})
+	t.Run("Permitted lists only events whose guards pass, in table order", func(t *testing.T) {
+		if got := m.Permitted(); !reflect.DeepEqual(got, []press{"test"}) {
+			t.Errorf("Permitted = %v", got)
+		}
+	})
+
+	t.Run("exit hooks run before the state changes, enter hooks after", func(t *testing.T) {
+		m, _ := NewFSM[light, press]("off", []Transition[light, press]{{From: "off", Event: "on", To: "bright"}})
+		var calls []string
+		m.OnExit("off", func(from light, event press, to light) {
+			calls = append(calls, "exit "+string(from)+" in "+string(m.Current()))
+		})
+		m.OnEnter("bright", func(from light, event press, to light) {
+			calls = append(calls, "enter "+string(to)+" in "+string(m.Current()))
+		})
+		m.Fire("on")
+		if want := []string{"exit off in off", "enter bright in bright"}; !reflect.DeepEqual(calls, want) {
+			t.Errorf("hooks %v, want %v", calls, want)
+		}
+	})
+}
+
+func TestFSMVendingMachine(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		actions   func(vm *FSMVendingMachine) error
+		wantState VendingState
+		wantCount int
+		wantErr   string
+		wantLines []string
+	}{
+		{
+			name:      "a sale takes one item and waits for the next coin",
+			count:     2,
+			actions:   func(vm *FSMVendingMachine) error { vm.InsertCoin(); return vm.PressButton() },
+			wantState: VendingNoCoin,
+			wantCount: 1,
+			wantLines: []string{"Item dispensed"},
+		},
+		{
+			name:      "the last sale sells the machine out",
+			count:     1,
+			actions:   func(vm *FSMVendingMachine) error { vm.InsertCoin(); return vm.PressButton() },
+			wantState: VendingSoldOut,
+			wantCount: 0,
+			wantLines: []string{"Item dispensed", "Machine sold out"},
+		},
+		{
+			name:      "a coin can be ejected",
+			count:     1,
+			actions:   func(vm *FSMVendingMachine) error { vm.InsertCoin(); return vm.EjectCoin() },
+			wantState: VendingNoCoin,
+			wantCount: 1,
+		},
+		{
+			name:      "pressing without a coin is illegal",
+			count:     1,
+			actions:   func(vm *FSMVendingMachine) error { return vm.PressButton() },
+			wantState: VendingNoCoin,
+			wantCount: 1,
+			wantErr:   "illegal transition: event press button in state no coin",
+		},
+		{
+			name:      "a second coin is illegal",
+			count:     1,
+			actions:   func(vm *FSMVendingMachine) error { vm.InsertCoin(); return vm.InsertCoin() },
+			wantState: VendingHasCoin,
+			wantCount: 1,
+			wantErr:   "illegal transition: event insert coin in state has coin",
+		},
+		{
+			name:      "an empty machine starts sold out",
+			count:     0,
+			actions:   func(vm *FSMVendingMachine) error { return vm.InsertCoin() },
+			wantState: VendingSoldOut,
+			wantCount: 0,
+			wantErr:   "illegal transition: event insert coin in state sold out",
+		},
+		{
+			name:      "a refill with nothing in it is rejected by the guard",
+			count:     0,
+			actions:   func(vm *FSMVendingMachine) error { return vm.Refill(0) },
+			wantState: VendingSoldOut,
+			wantCount: 0,
+			wantErr:   "event refill in state sold out rejected: no items left",
+		},
+		{
+			name:      "a refill reopens a sold out machine",
+			count:     0,
+			actions:   func(vm *FSMVendingMachine) error { return vm.Refill(3) },
+			wantState: VendingNoCoin,
+			wantCount: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureOutput(t)
+			vm := NewFSMVendingMachine(tt.count)
+			err := tt.actions(vm)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			if vm.State() != tt.wantState || vm.GetCount() != tt.wantCount {
+				t.Errorf("state %q with %d left, want %q with %d", vm.State(), vm.GetCount(), tt.wantState, tt.wantCount)
+			}
+			assertLines(t, buf, tt.wantLines...)
+		})
+	}
+}
+
+func TestDemoFSMPrintsEachStep(t *testing.T) {
+	buf := captureOutput(t)
+	DemoFSM()
+	assertLines(t, buf,
+		"=== FSM Engine Demo ===",
+		"press button   -> error: illegal transition: event press button in state no coin",
+		`insert coin    -> state "has coin", 2 left`,
+		"insert coin    -> error: illegal transition: event insert coin in state has coin",
+		"Item dispensed",
+		`press button   -> state "no coin", 1 left`,
+		`insert coin    -> state "has coin", 1 left`,
+		"Item dispensed",
+		"Machine sold out",
+		`press button   -> state "sold out", 0 left`,
+		"insert coin    -> error: illegal transition: event insert coin in state sold out",
+		`refill         -> state "no coin", 1 left`,
+		"Permitted now: [insert coin]",
+	)
+}
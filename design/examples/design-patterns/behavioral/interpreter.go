@@ -2,6 +2,7 @@ package behavioral
 
 import (
 "fmt"
+"regexp"
 "strconv"
 "strings"
 )
@@ -20,6 +21,17 @@ func (n *NumberExpression) Interpret() int {
 	return n.value
 }
 
+// VariableExpression names a value supplied at evaluation time (see
+// Evaluate and Compile). Interpret has no environment, so it reads a variable
+// as 0, as unknown tokens always have.
+type VariableExpression struct {
+	name string
+}
+
+func (v *VariableExpression) Interpret() int {
+	return 0
+}
+
 type AddExpression struct {
 	left  Expression
 	right Expression
@@ -60,6 +72,8 @@ func (d *DivideExpression) Interpret() int {
 	return d.left.Interpret() / right
 }
 
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 func Parse(expression string) Expression {
 	tokens := strings.Fields(expression)
 	stack := []Expression{}
@@ -86,6 +100,10 @@ func Parse(expression string) Expression {
 			stack = stack[:len(stack)-2]
 			stack = append(stack, &DivideExpression{left, right})
 		default:
+			if identifier.MatchString(token) {
+				stack = append(stack, &VariableExpression{token})
+				continue
+			}
 			val, _ := strconv.Atoi(token)
 			stack = append(stack, &NumberExpression{val})
 		}
@@ -94,7 +112,7 @@ func Parse(expression string) Expression {
 }
 
 func DemoInterpreter() {
-	fmt.Println("=== Interpreter Pattern Demo ===\n")
+	fmt.Fprint(out, "=== Interpreter Pattern Demo ===\n\n")
 	expressions := []string{
 		"5 3 +",       // 5 + 3 = 8
 		"10 2 -",      // 10 - 2 = 8
@@ -106,6 +124,6 @@ func DemoInterpreter() {
 	for _, expr := range expressions {
 		expression := Parse(expr)
 		result := expression.Interpret()
-		fmt.Printf("Expression: '%s' = %d\n", expr, result)
+		fmt.Fprintf(out, "Expression: '%s' = %d\n", expr, result)
 	}
 }
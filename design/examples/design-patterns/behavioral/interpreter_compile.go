@@ -0,0 +1,180 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Compiling the Interpreter's expression tree to closures.
+// Evaluate walks the tree on every call: a type switch per node, recursion per
+// operand. Compile walks it once and returns a tree of closures that already
+// know what each node does, so evaluation is only calls. On the way it folds
+// subtrees without variables into constants. This is how many real
+// interpreters (template engines, rule engines, query filters) get their
+// first large speed-up before reaching for bytecode.
+//
+// Both evaluate in float64 and agree exactly, errors included: division by
+// zero and unbound variables are errors, unlike Interpret's integer x / 0 == 0.
+
+// Env binds variable names to values
+type Env map[string]float64
+
+// CompiledExpression evaluates a compiled expression in an environment
+type CompiledExpression func(env Env) (float64, error)
+
+var ErrDivisionByZero = errors.New("division by zero")
+
+type UnboundVariableError struct {
+	Name string
+}
+
+func (e *UnboundVariableError) Error() string {
+	return fmt.Sprintf("variable %s is not bound", e.Name)
+}
+
+// Evaluate is the tree-walking evaluator
+func Evaluate(e Expression, env Env) (float64, error) {
+	switch e := e.(type) {
+	case *NumberExpression:
+		return float64(e.value), nil
+	case *VariableExpression:
+		v, ok := env[e.name]
+		if !ok {
+			return 0, &UnboundVariableError{Name: e.name}
+		}
+		return v, nil
+	case *AddExpression:
+		return evaluateBinary(e.left, e.right, env, add)
+	case *SubtractExpression:
+		return evaluateBinary(e.left, e.right, env, subtract)
+	case *MultiplyExpression:
+		return evaluateBinary(e.left, e.right, env, multiply)
+	case *DivideExpression:
+		return evaluateBinary(e.left, e.right, env, divide)
+	}
+	return 0, fmt.Errorf("cannot evaluate %T", e)
+}
+
+func evaluateBinary(left, right Expression, env Env, op func(l, r float64) (float64, error)) (float64, error) {
+	l, err := Evaluate(left, env)
+	if err != nil {
+		return 0, err
+	}
+	r, err := Evaluate(right, env)
+	if err != nil {
+		return 0, err
+	}
+	return op(l, r)
+}
+
+func add(l, r float64) (float64, error)      { return l + r, nil }
+func subtract(l, r float64) (float64, error) { return l - r, nil }
+func multiply(l, r float64) (float64, error) { return l * r, nil }
+
+func divide(l, r float64) (float64, error) {
+	if r == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return l / r, nil
+}
+
+// Compile turns e into a closure. It fails only for expression types it does
+// not know; evaluation errors surface when the closure runs, as with Evaluate.
+func Compile(e Expression) (CompiledExpression, error) {
+	c, _, err := compile(e)
+	return c, err
+}
+
+// compile also reports whether the closure ignores env, so that the caller
+// can fold it
+func compile(e Expression) (CompiledExpression, bool, error) {
+	switch e := e.(type) {
+	case *NumberExpression:
+		return constant(float64(e.value), nil), true, nil
+	case *VariableExpression:
+		name := e.name
+		return func(env Env) (float64, error) {
+			v, ok := env[name]
+			if !ok {
+				return 0, &UnboundVariableError{Name: name}
+			}
+			return v, nil
+		}, false, nil
+	case *AddExpression:
+		return compileBinary(e.left, e.right, add)
+	case *SubtractExpression:
+		return compileBinary(e.left, e.right, subtract)
+	case *MultiplyExpression:
+		return compileBinary(e.left, e.right, multiply)
+	case *DivideExpression:
+		return compileBinary(e.left, e.right, divide)
+	}
+	return nil, false, fmt.Errorf("cannot compile %T", e)
+}
+
+func constant(v float64, err error) CompiledExpression {
+	return func(Env) (float64, error) { return v, err }
+}
+
+func compileBinary(left, right Expression, op func(l, r float64) (float64, error)) (CompiledExpression, bool, error) {
+	l, lConst, err := compile(left)
+	if err != nil {
+		return nil, false, err
+	}
+	r, rConst, err := compile(right)
+	if err != nil {
+		return nil, false, err
+	}
+	c := func(env Env) (float64, error) {
+		lv, err := l(env)
+		if err != nil {
+			return 0, err
+		}
+		rv, err := r(env)
+		if err != nil {
+			return 0, err
+		}
+		return op(lv, rv)
+	}
+	if lConst && rConst {
+		// Fold now; a constant error such as 1 / 0 is kept for run time
+		return constant(c(nil)), true, nil
+	}
+	return c, false, nil
+}
+
+func DemoInterpreterCompile() {
+	fmt.Fprintln(out, "=== Interpreter Compile Demo ===")
+	fmt.Fprintln(out)
+
+	env := Env{"x": 3, "y": 4, "rate": 0.2}
+	sources := []string{
+		"x y +",
+		"x x * y y * + 2 /",
+		"100 rate * 60 24 * 7 * / x *",
+		"y 2 2 - /",
+		"x z +",
+	}
+	for _, src := range sources {
+		tree := Parse(src)
+		compiled, err := Compile(tree)
+		if err != nil {
+			fmt.Fprintf(out, "%-30s compile error: %v\n", src, err)
+			continue
+		}
+		got, err := compiled(env)
+		if err != nil {
+			fmt.Fprintf(out, "%-30s %-34s error: %v\n", src, PrettyPrint(tree), err)
+		} else {
+			fmt.Fprintf(out, "%-30s %-34s = %g\n", src, PrettyPrint(tree), got)
+		}
+	}
+
+	fmt.Fprintln(out, "\nBenchmarkTreeWalk and BenchmarkCompiled in interpreter_compile_test.go time")
+	fmt.Fprintln(out, "both evaluators on one expression.")
+	fmt.Fprintln(out, "\nCompiling pays the tree walk once: each closure already knows its operator")
+	fmt.Fprintln(out, "and operands, and (10 - 5 * 2) * 7 and 2 + 1 were folded to constants, so")
+	fmt.Fprintln(out, "each evaluation does less work as well as less dispatch. The variable is")
+	fmt.Fprintln(out, "still a map lookup in both; resolving names to slots at compile time is the")
+	fmt.Fprintln(out, "next step real interpreters take.")
+}
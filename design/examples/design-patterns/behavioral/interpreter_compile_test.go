@@ -0,0 +1,111 @@
+package behavioral
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompileMatchesTreeWalk(t *testing.T) {
+	sources := []string{
+		"x",
+		"7",
+		"x y +",
+		"x y -",
+		"x x * y y * + 2 /",
+		"100 rate * 60 24 * 7 * / x *",
+		"x x * 3 x * + 2 1 + / 10 5 2 * - 7 * +",
+		"y 2 2 - /",
+		"1 0 /",
+		"x z +",
+	}
+	envs := []Env{
+		{"x": 3, "y": 4, "rate": 0.2},
+		{"x": -1.5, "y": 0, "rate": 1},
+		{"x": 0, "y": 1e9},
+	}
+	for _, src := range sources {
+		t.Run(src, func(t *testing.T) {
+			tree := Parse(src)
+			compiled, err := Compile(tree)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, env := range envs {
+				walked, walkErr := Evaluate(tree, env)
+				got, compiledErr := compiled(env)
+				if walked != got || (walkErr == nil) != (compiledErr == nil) ||
+					(walkErr != nil && walkErr.Error() != compiledErr.Error()) {
+					t.Errorf("env %v: tree walk = %v, %v; compiled = %v, %v", env, walked, walkErr, got, compiledErr)
+				}
+			}
+		})
+	}
+}
+
+func TestCompiledErrors(t *testing.T) {
+	compiled, err := Compile(Parse("y 2 2 - /"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compiled(Env{"y": 1}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("err = %v, want ErrDivisionByZero", err)
+	}
+
+	compiled, err = Compile(Parse("x z +"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unbound *UnboundVariableError
+	if _, err := compiled(Env{"x": 1}); !errors.As(err, &unbound) || unbound.Name != "z" {
+		t.Errorf("err = %v, want z unbound", err)
+	}
+}
+
+func TestDemoInterpreterCompilePrintsEachResult(t *testing.T) {
+	buf := captureOutput(t)
+	DemoInterpreterCompile()
+	printed := lines(buf)
+	want := []string{
+		"=== Interpreter Compile Demo ===",
+		"x y +                          x + y                              = 7",
+		"x x * y y * + 2 /              (x * x + y * y) / 2                = 12.5",
+		"100 rate * 60 24 * 7 * / x *   100 * rate / (60 * 24 * 7) * x     = 0.005952380952380952",
+		"y 2 2 - /                      y / (2 - 2)                        error: division by zero",
+		"x z +                          x + z                              error: variable z is not bound",
+	}
+	// The explanation that follows is prose
+	if len(printed) < len(want) || strings.Join(printed[:len(want)], "\n") != strings.Join(want, "\n") {
+		t.Errorf("printed:\n%s\nwant it to start with:\n%s", strings.Join(printed, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// benchExpression has a variable part and two constant subtrees to fold
+const benchExpression = "x x * 3 x * + 2 1 + / 10 5 2 * - 7 * +"
+
+var evalSink float64
+
+func BenchmarkTreeWalk(b *testing.B) {
+	tree := Parse(benchExpression)
+	env := Env{"x": 0}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		env["x"] = float64(i % 100)
+		v, _ := Evaluate(tree, env)
+		evalSink += v
+	}
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	compiled, err := Compile(Parse(benchExpression))
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := Env{"x": 0}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		env["x"] = float64(i % 100)
+		v, _ := compiled(env)
+		evalSink += v
+	}
+}
@@ -0,0 +1,56 @@
+package behavioral
+
+import "testing"
+
+func TestParseAndInterpret(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       int
+	}{
+		{"5 3 +", 8},
+		{"10 2 -", 8},
+		{"4 5 *", 20},
+		{"20 4 /", 5},
+		{"7 2 /", 3},
+		{"5 0 /", 0},
+		{"5 3 + 2 *", 16},
+		{"10 2 - 3 *", 24},
+		{"2 3 4 * +", 14},
+		{"42", 42},
+		{"x 1 +", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			if got := Parse(tt.expression).Interpret(); got != tt.want {
+				t.Errorf("Interpret() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBuildsTheTree(t *testing.T) {
+	add, ok := Parse("1 2 3 * +").(*AddExpression)
+	if !ok {
+		t.Fatalf("root is %T, want *AddExpression", Parse("1 2 3 * +"))
+	}
+	if _, ok := add.left.(*NumberExpression); !ok {
+		t.Errorf("left is %T, want *NumberExpression", add.left)
+	}
+	if _, ok := add.right.(*MultiplyExpression); !ok {
+		t.Errorf("right is %T, want *MultiplyExpression", add.right)
+	}
+}
+
+func TestDemoInterpreterPrintsEachResult(t *testing.T) {
+	buf := captureOutput(t)
+	DemoInterpreter()
+	assertLines(t, buf,
+		"=== Interpreter Pattern Demo ===",
+		"Expression: '5 3 +' = 8",
+		"Expression: '10 2 -' = 8",
+		"Expression: '4 5 *' = 20",
+		"Expression: '20 4 /' = 5",
+		"Expression: '5 3 + 2 *' = 16",
+		"Expression: '10 2 - 3 *' = 24",
+	)
+}
@@ -0,0 +1,202 @@
+package behavioral
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Visitor over the Interpreter's expression tree.
+// Interpret() is the only operation the AST nodes know. The visitors below add
+// pretty-printing, constant folding and node counting without touching the
+// interpreter: each node only gains an Accept method for double dispatch.
+
+type ExprVisitor interface {
+	VisitNumber(*NumberExpression)
+	VisitVariable(*VariableExpression)
+	VisitAdd(*AddExpression)
+	VisitSubtract(*SubtractExpression)
+	VisitMultiply(*MultiplyExpression)
+	VisitDivide(*DivideExpression)
+}
+
+type visitableExpression interface {
+	Expression
+	Accept(ExprVisitor)
+}
+
+func (n *NumberExpression) Accept(v ExprVisitor)   { v.VisitNumber(n) }
+func (x *VariableExpression) Accept(v ExprVisitor) { v.VisitVariable(x) }
+func (a *AddExpression) Accept(v ExprVisitor)      { v.VisitAdd(a) }
+func (s *SubtractExpression) Accept(v ExprVisitor) { v.VisitSubtract(s) }
+func (m *MultiplyExpression) Accept(v ExprVisitor) { v.VisitMultiply(m) }
+func (d *DivideExpression) Accept(v ExprVisitor)   { v.VisitDivide(d) }
+
+// Walk applies v to e; expressions from outside this package are not visitable
+func Walk(e Expression, v ExprVisitor) {
+	node, ok := e.(visitableExpression)
+	if !ok {
+		panic(fmt.Sprintf("expression %T does not accept visitors", e))
+	}
+	node.Accept(v)
+}
+
+// PrettyPrinter renders infix notation with only the parentheses the grammar needs
+
+type PrettyPrinter struct {
+	out strings.Builder
+}
+
+func PrettyPrint(e Expression) string {
+	p := &PrettyPrinter{}
+	Walk(e, p)
+	return p.out.String()
+}
+
+func precedence(e Expression) int {
+	switch e.(type) {
+	case *AddExpression, *SubtractExpression:
+		return 1
+	case *MultiplyExpression, *DivideExpression:
+		return 2
+	}
+	return 3
+}
+
+// binary prints left op right. The right operand of - and / needs parentheses at
+// equal precedence too, since those operators are not associative.
+func (p *PrettyPrinter) binary(self, left, right Expression, op string, nonAssociative bool) {
+	p.operand(left, precedence(left) < precedence(self))
+	p.out.WriteString(" " + op + " ")
+	rp := precedence(right)
+	p.operand(right, rp < precedence(self) || (nonAssociative && rp == precedence(self)))
+}
+
+func (p *PrettyPrinter) operand(e Expression, parens bool) {
+	if parens {
+		p.out.WriteString("(")
+	}
+	Walk(e, p)
+	if parens {
+		p.out.WriteString(")")
+	}
+}
+
+func (p *PrettyPrinter) VisitNumber(n *NumberExpression) {
+	p.out.WriteString(strconv.Itoa(n.value))
+}
+func (p *PrettyPrinter) VisitVariable(x *VariableExpression) {
+	p.out.WriteString(x.name)
+}
+func (p *PrettyPrinter) VisitAdd(a *AddExpression) {
+	p.binary(a, a.left, a.right, "+", false)
+}
+func (p *PrettyPrinter) VisitSubtract(s *SubtractExpression) {
+	p.binary(s, s.left, s.right, "-", true)
+}
+func (p *PrettyPrinter) VisitMultiply(m *MultiplyExpression) {
+	p.binary(m, m.left, m.right, "*", false)
+}
+func (p *PrettyPrinter) VisitDivide(d *DivideExpression) {
+	p.binary(d, d.left, d.right, "/", true)
+}
+
+// ConstantFolder rebuilds the tree bottom-up, replacing every operator whose
+// operands are both numbers with a single number. Folding uses the nodes' own
+// Interpret, so folded trees keep the interpreter's semantics (x / 0 == 0).
+
+type ConstantFolder struct {
+	result Expression
+}
+
+func Fold(e Expression) Expression {
+	f := &ConstantFolder{}
+	Walk(e, f)
+	return f.result
+}
+
+func (f *ConstantFolder) fold(left, right Expression, build func(l, r Expression) Expression) {
+	l, r := Fold(left), Fold(right)
+	node := build(l, r)
+	_, lConst := l.(*NumberExpression)
+	_, rConst := r.(*NumberExpression)
+	if lConst && rConst {
+		node = &NumberExpression{node.Interpret()}
+	}
+	f.result = node
+}
+
+func (f *ConstantFolder) VisitNumber(n *NumberExpression) {
+	f.result = n
+}
+func (f *ConstantFolder) VisitVariable(x *VariableExpression) {
+	f.result = x
+}
+func (f *ConstantFolder) VisitAdd(a *AddExpression) {
+	f.fold(a.left, a.right, func(l, r Expression) Expression { return &AddExpression{l, r} })
+}
+func (f *ConstantFolder) VisitSubtract(s *SubtractExpression) {
+	f.fold(s.left, s.right, func(l, r Expression) Expression { return &SubtractExpression{l, r} })
+}
+func (f *ConstantFolder) VisitMultiply(m *MultiplyExpression) {
+	f.fold(m.left, m.right, func(l, r Expression) Expression { return &MultiplyExpression{l, r} })
+}
+func (f *ConstantFolder) VisitDivide(d *DivideExpression) {
+	f.fold(d.left, d.right, func(l, r Expression) Expression { return &DivideExpression{l, r} })
+}
+
+// NodeCounter tallies nodes by kind and tracks the tree depth
+
+type NodeCounter struct {
+	Counts   map[string]int
+	Total    int
+	MaxDepth int
+	depth    int
+}
+
+func CountNodes(e Expression) *NodeCounter {
+	c := &NodeCounter{Counts: make(map[string]int)}
+	Walk(e, c)
+	return c
+}
+
+func (c *NodeCounter) visit(kind string, children ...Expression) {
+	c.depth++
+	if c.depth > c.MaxDepth {
+		c.MaxDepth = c.depth
+	}
+	c.Counts[kind]++
+	c.Total++
+	for _, child := range children {
+		Walk(child, c)
+	}
+	c.depth--
+}
+
+func (c *NodeCounter) VisitNumber(n *NumberExpression)     { c.visit("number") }
+func (c *NodeCounter) VisitVariable(x *VariableExpression) { c.visit("variable") }
+func (c *NodeCounter) VisitAdd(a *AddExpression)           { c.visit("add", a.left, a.right) }
+func (c *NodeCounter) VisitSubtract(s *SubtractExpression) { c.visit("subtract", s.left, s.right) }
+func (c *NodeCounter) VisitMultiply(m *MultiplyExpression) { c.visit("multiply", m.left, m.right) }
+func (c *NodeCounter) VisitDivide(d *DivideExpression)     { c.visit("divide", d.left, d.right) }
+
+func DemoInterpreterVisitor() {
+	fmt.Fprintln(out, "=== Interpreter Visitor Demo ===")
+	fmt.Fprintln(out)
+
+	expressions := []string{
+		"5 3 + 2 *",
+		"10 2 3 - -",
+		"10 2 - 3 -",
+		"2 3 4 * +",
+		"8 0 / 7 +",
+		"1 2 + 3 4 - * 5 6 / -",
+	}
+	for _, src := range expressions {
+		tree := Parse(src)
+		folded := Fold(tree)
+		counts := CountNodes(tree)
+		fmt.Fprintf(out, "%-24s infix: %-28s nodes=%d depth=%d folded=%s\n",
+			src, PrettyPrint(tree), counts.Total, counts.MaxDepth, PrettyPrint(folded))
+	}
+}
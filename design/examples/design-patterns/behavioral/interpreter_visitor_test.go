@@ -0,0 +1,120 @@
+package behavioral
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpressionVisitors(t *testing.T) {
+	tests := []struct {
+		src    string
+		infix  string
+		folded string
+		total  int
+		depth  int
+	}{
+		{"5 3 + 2 *", "(5 + 3) * 2", "16", 5, 3},
+		{"10 2 3 - -", "10 - (2 - 3)", "11", 5, 3},
+		{"10 2 - 3 -", "10 - 2 - 3", "5", 5, 3},
+		{"2 3 4 * +", "2 + 3 * 4", "14", 5, 3},
+		{"2 3 + 4 *", "(2 + 3) * 4", "20", 5, 3},
+		{"8 2 4 / /", "8 / (2 / 4)", "0", 5, 3},
+		{"8 0 / 7 +", "8 / 0 + 7", "7", 5, 3},
+		{"1 2 + 3 4 - * 5 6 / -", "(1 + 2) * (3 - 4) - 5 / 6", "-3", 11, 4},
+		{"x 2 3 * +", "x + 2 * 3", "x + 6", 5, 3},
+		{"x 1 + y -", "x + 1 - y", "x + 1 - y", 5, 3},
+		{"2 3 + x *", "(2 + 3) * x", "5 * x", 5, 3},
+		{"7", "7", "7", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tree := Parse(tt.src)
+			if got := PrettyPrint(tree); got != tt.infix {
+				t.Errorf("PrettyPrint = %q, want %q", got, tt.infix)
+			}
+			folded := Fold(tree)
+			if got := PrettyPrint(folded); got != tt.folded {
+				t.Errorf("folded to %q, want %q", got, tt.folded)
+			}
+			if folded.Interpret() != tree.Interpret() {
+				t.Errorf("folding changed the value from %d to %d", tree.Interpret(), folded.Interpret())
+			}
+			if counts := CountNodes(tree); counts.Total != tt.total || counts.MaxDepth != tt.depth {
+				t.Errorf("nodes=%d depth=%d, want %d and %d", counts.Total, counts.MaxDepth, tt.total, tt.depth)
+			}
+		})
+	}
+
+	t.Run("the printed infix form parses back to the same value", func(t *testing.T) {
+		for _, tt := range tests {
+			if strings.ContainsAny(tt.src, "xy") {
+				continue
+			}
+			if got, want := Parse(tt.src).Interpret(), evalInfix(t, tt.infix); got != want {
+				t.Errorf("%s: %d, but %q evaluates to %d", tt.src, got, tt.infix, want)
+			}
+		}
+	})
+
+	t.Run("the counter tallies nodes by kind", func(t *testing.T) {
+		got := CountNodes(Parse("1 2 + 3 4 - * 5 6 / -")).Counts
+		want := map[string]int{"number": 6, "add": 1, "subtract": 2, "multiply": 1, "divide": 1}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Counts = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("folding leaves the original tree alone", func(t *testing.T) {
+		tree := Parse("2 3 + x *")
+		Fold(tree)
+		if got := PrettyPrint(tree); got != "(2 + 3) * x" {
+			t.Errorf("after Fold the tree prints %q", got)
+		}
+	})
+}
+
+// evalInfix evaluates the printer's output with Go's precedence rules, using
+// the interpreter's x / 0 == 0, by converting it back to postfix
+func evalInfix(t *testing.T, infix string) int {
+	t.Helper()
+	prec := map[string]int{"+": 1, "-": 1, "*": 2, "/": 2}
+	var postfix, ops []string
+	tokens := strings.Fields(strings.NewReplacer("(", "( ", ")", " )").Replace(infix))
+	for _, tok := range tokens {
+		switch {
+		case tok == "(":
+			ops = append(ops, tok)
+		case tok == ")":
+			for ops[len(ops)-1] != "(" {
+				postfix, ops = append(postfix, ops[len(ops)-1]), ops[:len(ops)-1]
+			}
+			ops = ops[:len(ops)-1]
+		case prec[tok] > 0:
+			for len(ops) > 0 && ops[len(ops)-1] != "(" && prec[ops[len(ops)-1]] >= prec[tok] {
+				postfix, ops = append(postfix, ops[len(ops)-1]), ops[:len(ops)-1]
+			}
+			ops = append(ops, tok)
+		default:
+			postfix = append(postfix, tok)
+		}
+	}
+	for len(ops) > 0 {
+		postfix, ops = append(postfix, ops[len(ops)-1]), ops[:len(ops)-1]
+	}
+	return Parse(strings.Join(postfix, " ")).Interpret()
+}
+
+func TestDemoInterpreterVisitorPrintsEachExpression(t *testing.T) {
+	buf := captureOutput(t)
+	DemoInterpreterVisitor()
+	assertLines(t, buf,
+		"=== Interpreter Visitor Demo ===",
+		"5 3 + 2 *                infix: (5 + 3) * 2                  nodes=5 depth=3 folded=16",
+		"10 2 3 - -               infix: 10 - (2 - 3)                 nodes=5 depth=3 folded=11",
+		"10 2 - 3 -               infix: 10 - 2 - 3                   nodes=5 depth=3 folded=5",
+		"2 3 4 * +                infix: 2 + 3 * 4                    nodes=5 depth=3 folded=14",
+		"8 0 / 7 +                infix: 8 / 0 + 7                    nodes=5 depth=3 folded=7",
+		"1 2 + 3 4 - * 5 6 / -    infix: (1 + 2) * (3 - 4) - 5 / 6    nodes=11 depth=4 folded=-3",
+	)
+}
@@ -162,9 +162,9 @@ func (i *FilteredUserIterator) Current() interface{} {
 }
 
 func DemoIterator() {
-	fmt.Println("=== Iterator Pattern Demo ===\n")
+	fmt.Fprint(out, "=== Iterator Pattern Demo ===\n\n")
 
-	fmt.Println("1. Book Collection:")
+	fmt.Fprintln(out, "1. Book Collection:")
 	shelf := &BookShelf{}
 	shelf.AddBook("Design Patterns")
 	shelf.AddBook("Clean Code")
@@ -173,10 +173,10 @@ func DemoIterator() {
 	iterator := shelf.CreateIterator()
 	for iterator.HasNext() {
 		book := iterator.Next()
-		fmt.Println("Book:", book)
+		fmt.Fprintln(out, "Book:", book)
 	}
 
-	fmt.Println("\n2. User Collection with Different Iterators:")
+	fmt.Fprintln(out, "\n2. User Collection with Different Iterators:")
 	users := &UserCollection{
 		users: []*User{
 			{Name: "Alice", Age: 25},
@@ -187,24 +187,24 @@ func DemoIterator() {
 		},
 	}
 
-	fmt.Println("\nForward iteration:")
+	fmt.Fprintln(out, "\nForward iteration:")
 	iter := users.CreateIterator()
 	for iter.HasNext() {
 		user := iter.Next().(*User)
-		fmt.Printf("%s (age %d)\n", user.Name, user.Age)
+		fmt.Fprintf(out, "%s (age %d)\n", user.Name, user.Age)
 	}
 
-	fmt.Println("\nReverse iteration:")
+	fmt.Fprintln(out, "\nReverse iteration:")
 	reverseIter := users.CreateReverseIterator()
 	for reverseIter.HasNext() {
 		user := reverseIter.Next().(*User)
-		fmt.Printf("%s (age %d)\n", user.Name, user.Age)
+		fmt.Fprintf(out, "%s (age %d)\n", user.Name, user.Age)
 	}
 
-	fmt.Println("\nFiltered iteration (age >= 18):")
+	fmt.Fprintln(out, "\nFiltered iteration (age >= 18):")
 	filteredIter := users.CreateFilteredIterator(18)
 	for filteredIter.HasNext() {
 		user := filteredIter.Next().(*User)
-		fmt.Printf("%s (age %d)\n", user.Name, user.Age)
+		fmt.Fprintf(out, "%s (age %d)\n", user.Name, user.Age)
 	}
 }
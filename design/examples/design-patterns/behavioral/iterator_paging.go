@@ -0,0 +1,186 @@
+package behavioral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Paging Iterator
+// Walks a repository that returns results a page at a time as if it were one
+// sequence. A page is only requested when the previous one is used up, so a
+// caller that stops early never loads the rest.
+
+// TypedIterator is the type-safe counterpart of Iterator for sources that can
+// fail, in the style of bufio.Scanner and sql.Rows:
+//
+//	for it.Next() {
+//		use(it.Value())
+//	}
+//	if err := it.Err(); err != nil { ... }
+type TypedIterator[T any] interface {
+	Next() bool // advances; false at the end or on error
+	Value() T   // the element Next moved to
+	Err() error // why iteration stopped early, nil at the end
+}
+
+// PageRepository returns up to limit items after cursor ("" for the first
+// page), and the cursor of the next page ("" when there is none). Cursors are
+// opaque to the iterator. The clean-architecture TaskRepositoryImpl.FindPage
+// has this shape, so it is a PageRepository[*domain.Task] as it is.
+type PageRepository[T any] interface {
+	FindPage(ctx context.Context, cursor string, limit int) (items []T, next string, err error)
+}
+
+const DefaultPageSize = 50
+
+type PageIterator[T any] struct {
+	ctx      context.Context
+	repo     PageRepository[T]
+	pageSize int
+
+	page   []T
+	pos    int
+	cursor string
+	last   bool // no page after the current one
+	value  T
+	err    error
+	pages  int
+}
+
+func NewPageIterator[T any](ctx context.Context, repo PageRepository[T], pageSize int) *PageIterator[T] {
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	return &PageIterator[T]{ctx: ctx, repo: repo, pageSize: pageSize}
+}
+
+func (it *PageIterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	// Loop: a repository may return an empty page with a next cursor
+	for it.pos >= len(it.page) {
+		if it.last {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		items, next, err := it.repo.FindPage(it.ctx, it.cursor, it.pageSize)
+		it.pages++
+		if err != nil {
+			it.err = fmt.Errorf("fetching page %d: %w", it.pages, err)
+			return false
+		}
+		if next == it.cursor && next != "" {
+			it.err = fmt.Errorf("fetching page %d: cursor %q did not advance", it.pages, next)
+			return false
+		}
+		it.page, it.pos, it.cursor, it.last = items, 0, next, next == ""
+	}
+	it.value = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+func (it *PageIterator[T]) Value() T {
+	return it.value
+}
+
+func (it *PageIterator[T]) Err() error {
+	return it.err
+}
+
+// Pages returns how many pages have been requested so far
+func (it *PageIterator[T]) Pages() int {
+	return it.pages
+}
+
+// Collect drains it into a slice, returning what was read before any error
+func Collect[T any](it TypedIterator[T]) ([]T, error) {
+	var items []T
+	for it.Next() {
+		items = append(items, it.Value())
+	}
+	return items, it.Err()
+}
+
+// Demo: a fake task repository with keyset cursors, as the SQL one pages by id
+
+type pagedTask struct {
+	ID    int64
+	Title string
+}
+
+type fakeTaskRepository struct {
+	tasks   []pagedTask // sorted by ID
+	calls   []string    // cursors requested
+	failAt  int         // call number that fails, 0 for never
+	failErr error
+}
+
+func (r *fakeTaskRepository) FindPage(ctx context.Context, cursor string, limit int) ([]pagedTask, string, error) {
+	r.calls = append(r.calls, cursor)
+	if len(r.calls) == r.failAt {
+		return nil, "", r.failErr
+	}
+	var after int64
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("bad cursor %q", cursor)
+		}
+		after = id
+	}
+	var page []pagedTask
+	for _, t := range r.tasks {
+		if t.ID > after {
+			page = append(page, t)
+		}
+		if len(page) == limit {
+			break
+		}
+	}
+	if len(page) < limit {
+		return page, "", nil
+	}
+	return page, strconv.FormatInt(page[len(page)-1].ID, 10), nil
+}
+
+func newFakeTaskRepository(n int) *fakeTaskRepository {
+	repo := &fakeTaskRepository{}
+	for i := 1; i <= n; i++ {
+		// ids with gaps, like a table with deleted rows
+		repo.tasks = append(repo.tasks, pagedTask{ID: int64(i * 3), Title: fmt.Sprintf("task %d", i)})
+	}
+	return repo
+}
+
+func DemoPagingIterator() {
+	fmt.Fprint(out, "=== Paging Iterator Demo ===\n\n")
+	ctx := context.Background()
+
+	fmt.Fprintln(out, "1. 23 tasks, pages of 5:")
+	repo := newFakeTaskRepository(23)
+	var it TypedIterator[pagedTask] = NewPageIterator[pagedTask](ctx, repo, 5)
+	tasks, err := Collect(it)
+	fmt.Fprintf(out, "%d tasks, error: %v\n", len(tasks), err)
+	fmt.Fprintf(out, "cursors requested: %q\n", repo.calls)
+
+	fmt.Fprintln(out, "\n2. Pages are fetched lazily:")
+	repo = newFakeTaskRepository(23)
+	lazy := NewPageIterator[pagedTask](ctx, repo, 5)
+	for i := 0; i < 7; i++ {
+		lazy.Next()
+	}
+	fmt.Fprintf(out, "after 7 tasks: %d pages fetched, at %q\n", lazy.Pages(), lazy.Value().Title)
+
+	fmt.Fprintln(out, "\n3. A failing page:")
+	repo = newFakeTaskRepository(23)
+	repo.failAt, repo.failErr = 3, errors.New("connection reset")
+	tasks, err = Collect[pagedTask](NewPageIterator[pagedTask](ctx, repo, 5))
+	fmt.Fprintf(out, "%d tasks kept, error: %v\n", len(tasks), err)
+}
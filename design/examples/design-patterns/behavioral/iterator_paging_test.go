@@ -0,0 +1,151 @@
+package behavioral
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPageIteratorReadsEveryPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		tasks     int
+		pageSize  int
+		wantCalls int
+	}{
+		{"last page short", 23, 5, 5},
+		{"exact multiple, then an empty page", 10, 5, 3},
+		{"one page", 3, 5, 1},
+		{"no tasks", 0, 5, 1},
+		{"default page size", 120, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeTaskRepository(tt.tasks)
+			tasks, err := Collect[pagedTask](NewPageIterator[pagedTask](context.Background(), repo, tt.pageSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != tt.tasks {
+				t.Fatalf("got %d tasks, want %d", len(tasks), tt.tasks)
+			}
+			for i, task := range tasks {
+				if task.ID != int64((i+1)*3) {
+					t.Fatalf("task %d has id %d: out of order or repeated", i, task.ID)
+				}
+			}
+			if len(repo.calls) != tt.wantCalls {
+				t.Errorf("%d pages requested (%q), want %d", len(repo.calls), repo.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPageIteratorIsLazy(t *testing.T) {
+	repo := newFakeTaskRepository(23)
+	it := NewPageIterator[pagedTask](context.Background(), repo, 5)
+	if it.Pages() != 0 {
+		t.Fatal("a page was fetched before the first Next")
+	}
+	for i := 0; i < 7; i++ {
+		if !it.Next() {
+			t.Fatalf("Next %d returned false: %v", i, it.Err())
+		}
+	}
+	if it.Pages() != 2 || it.Value().Title != "task 7" {
+		t.Errorf("after 7 tasks: %d pages, at %q; want 2 pages, at task 7", it.Pages(), it.Value().Title)
+	}
+	if want := []string{"", "15"}; len(repo.calls) != 2 || repo.calls[1] != want[1] {
+		t.Errorf("cursors requested %q, want %q", repo.calls, want)
+	}
+}
+
+func TestPageIteratorStopsOnAFailingPage(t *testing.T) {
+	repo := newFakeTaskRepository(23)
+	repo.failAt, repo.failErr = 3, errors.New("connection reset")
+	it := NewPageIterator[pagedTask](context.Background(), repo, 5)
+	tasks, err := Collect[pagedTask](it)
+	if len(tasks) != 10 {
+		t.Errorf("kept %d tasks, want the 10 of the first two pages", len(tasks))
+	}
+	if !errors.Is(err, repo.failErr) {
+		t.Errorf("err = %v, want it to wrap %v", err, repo.failErr)
+	}
+	if it.Next() || len(repo.calls) != 3 {
+		t.Error("the iterator retried after the error")
+	}
+}
+
+func TestPageIteratorStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := newFakeTaskRepository(23)
+	it := NewPageIterator[pagedTask](ctx, repo, 5)
+	it.Next()
+	cancel()
+	n := 1
+	for it.Next() {
+		n++
+	}
+	if n != 5 {
+		t.Errorf("read %d tasks, want the 5 of the page already fetched", n)
+	}
+	if !errors.Is(it.Err(), context.Canceled) || len(repo.calls) != 1 {
+		t.Errorf("err = %v after %d pages; want context.Canceled after 1", it.Err(), len(repo.calls))
+	}
+}
+
+// scriptedRepository returns its pages in order, whatever the cursor
+type scriptedRepository struct {
+	pages []scriptedPage
+	calls int
+}
+
+type scriptedPage struct {
+	items []int
+	next  string
+}
+
+func (r *scriptedRepository) FindPage(ctx context.Context, cursor string, limit int) ([]int, string, error) {
+	page := r.pages[r.calls]
+	r.calls++
+	return page.items, page.next, nil
+}
+
+func TestPageIteratorSkipsEmptyPagesWithACursor(t *testing.T) {
+	repo := &scriptedRepository{pages: []scriptedPage{
+		{[]int{1, 2}, "a"},
+		{nil, "b"},
+		{[]int{3}, ""},
+	}}
+	items, err := Collect[int](NewPageIterator[int](context.Background(), repo, 2))
+	if err != nil || len(items) != 3 || items[2] != 3 {
+		t.Errorf("got %v, %v; want [1 2 3]", items, err)
+	}
+}
+
+func TestPageIteratorRefusesACursorThatDoesNotAdvance(t *testing.T) {
+	repo := &scriptedRepository{pages: []scriptedPage{
+		{[]int{1}, "a"},
+		{[]int{2}, "a"},
+		{[]int{3}, ""},
+	}}
+	items, err := Collect[int](NewPageIterator[int](context.Background(), repo, 1))
+	if err == nil || len(items) != 1 {
+		t.Errorf("got %v, %v; want [1] and an error", items, err)
+	}
+}
+
+func TestDemoPagingIterator(t *testing.T) {
+	buf := captureOutput(t)
+	DemoPagingIterator()
+	assertLines(t, buf,
+		"=== Paging Iterator Demo ===",
+		"1. 23 tasks, pages of 5:",
+		"23 tasks, error: <nil>",
+		`cursors requested: ["" "15" "30" "45" "60"]`,
+		"2. Pages are fetched lazily:",
+		`after 7 tasks: 2 pages fetched, at "task 7"`,
+		"3. A failing page:",
+		"10 tasks kept, error: fetching page 3: connection reset",
+	)
+}
@@ -0,0 +1,93 @@
+package behavioral
+
+import "testing"
+
+func drain(it Iterator) []interface{} {
+	var items []interface{}
+	for it.HasNext() {
+		items = append(items, it.Next())
+	}
+	return items
+}
+
+func userNames(items []interface{}) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.(*User).Name
+	}
+	return names
+}
+
+func TestBookIterator(t *testing.T) {
+	shelf := &BookShelf{}
+	it := shelf.CreateIterator()
+	if it.HasNext() || it.Next() != nil || it.Current() != nil {
+		t.Error("an empty shelf yields a book")
+	}
+
+	for _, book := range []string{"Design Patterns", "Clean Code", "Refactoring"} {
+		shelf.AddBook(book)
+	}
+	it = shelf.CreateIterator()
+	if it.Current() != nil {
+		t.Error("Current before Next is not nil")
+	}
+	if got := it.Next(); got != "Design Patterns" || it.Current() != "Design Patterns" {
+		t.Errorf("Next = %v, Current = %v", got, it.Current())
+	}
+	rest := drain(it)
+	if len(rest) != 2 || rest[0] != "Clean Code" || rest[1] != "Refactoring" {
+		t.Errorf("rest = %v", rest)
+	}
+	if it.Next() != nil || it.Current() != "Refactoring" {
+		t.Error("an exhausted iterator moved on")
+	}
+}
+
+func TestUserIterators(t *testing.T) {
+	users := &UserCollection{users: []*User{
+		{Name: "Alice", Age: 25},
+		{Name: "Bob", Age: 17},
+		{Name: "Charlie", Age: 30},
+		{Name: "David", Age: 16},
+		{Name: "Eve", Age: 28},
+	}}
+	tests := []struct {
+		name string
+		it   Iterator
+		want []string
+	}{
+		{"forward", users.CreateIterator(), []string{"Alice", "Bob", "Charlie", "David", "Eve"}},
+		{"reverse", users.CreateReverseIterator(), []string{"Eve", "David", "Charlie", "Bob", "Alice"}},
+		{"adults", users.CreateFilteredIterator(18), []string{"Alice", "Charlie", "Eve"}},
+		{"nobody that old", users.CreateFilteredIterator(99), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := userNames(drain(tt.it))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+			if tt.it.Next() != nil {
+				t.Error("Next after the end is not nil")
+			}
+		})
+	}
+}
+
+func TestReverseIteratorCurrent(t *testing.T) {
+	users := &UserCollection{users: []*User{{Name: "Alice"}, {Name: "Bob"}}}
+	it := users.CreateReverseIterator()
+	if it.Current() != nil {
+		t.Error("Current before Next is not nil")
+	}
+	it.Next()
+	if it.Current().(*User).Name != "Bob" {
+		t.Errorf("Current = %v, want Bob", it.Current())
+	}
+}
@@ -5,21 +5,22 @@ import "fmt"
 // Mediator Pattern - Reduces coupling between components by making them communicate through a mediator.
 
 type ChatMediator interface {
-	SendMessage(message string, user User)
-	AddUser(user User)
+	SendMessage(message string, user Colleague)
+	AddUser(user Colleague)
 }
 
-type User interface {
+// Colleague is any participant that talks through the mediator
+type Colleague interface {
 	Send(message string)
 	Receive(message string)
 	GetName() string
 }
 
 type ChatRoom struct {
-	users []User
+	users []Colleague
 }
 
-func (c *ChatRoom) SendMessage(message string, user User) {
+func (c *ChatRoom) SendMessage(message string, user Colleague) {
 	for _, u := range c.users {
 		if u.GetName() != user.GetName() {
 			u.Receive(fmt.Sprintf("[%s]: %s", user.GetName(), message))
@@ -27,9 +28,9 @@ func (c *ChatRoom) SendMessage(message string, user User) {
 	}
 }
 
-func (c *ChatRoom) AddUser(user User) {
+func (c *ChatRoom) AddUser(user Colleague) {
 	c.users = append(c.users, user)
-	fmt.Printf("%s joined the chat\n", user.GetName())
+	fmt.Fprintf(out, "%s joined the chat\n", user.GetName())
 }
 
 type ChatUser struct {
@@ -42,12 +43,12 @@ func NewChatUser(name string, mediator ChatMediator) *ChatUser {
 }
 
 func (u *ChatUser) Send(message string) {
-	fmt.Printf("%s sends: %s\n", u.name, message)
+	fmt.Fprintf(out, "%s sends: %s\n", u.name, message)
 	u.mediator.SendMessage(message, u)
 }
 
 func (u *ChatUser) Receive(message string) {
-	fmt.Printf("%s receives: %s\n", u.name, message)
+	fmt.Fprintf(out, "%s receives: %s\n", u.name, message)
 }
 
 func (u *ChatUser) GetName() string {
@@ -55,7 +56,7 @@ func (u *ChatUser) GetName() string {
 }
 
 func DemoMediator() {
-	fmt.Println("=== Mediator Pattern Demo ===\n")
+	fmt.Fprint(out, "=== Mediator Pattern Demo ===\n\n")
 	chatRoom := &ChatRoom{}
 	alice := NewChatUser("Alice", chatRoom)
 	bob := NewChatUser("Bob", chatRoom)
@@ -63,8 +64,8 @@ func DemoMediator() {
 	chatRoom.AddUser(alice)
 	chatRoom.AddUser(bob)
 	chatRoom.AddUser(charlie)
-	fmt.Println()
+	fmt.Fprintln(out)
 	alice.Send("Hello everyone!")
-	fmt.Println()
+	fmt.Fprintln(out)
 	bob.Send("Hi Alice!")
 }
@@ -0,0 +1,333 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Concurrent Mediator - the ChatRoom above is synchronous and single-room.
+// Here the mediator (ChatHub) owns all routing state inside one goroutine, and every
+// connected client runs its own goroutine fed by a buffered inbox channel.
+// Clients never reference each other: join/leave, room messages and private
+// messages all go through the hub.
+
+var (
+	ErrHubClosed     = errors.New("chat hub is closed")
+	ErrNameTaken     = errors.New("name already connected")
+	ErrNotConnected  = errors.New("client is not connected")
+	ErrNotInRoom     = errors.New("client has not joined the room")
+	ErrUnknownMember = errors.New("recipient is not connected")
+)
+
+type ChatEventKind int
+
+const (
+	ChatJoined ChatEventKind = iota
+	ChatLeft
+	ChatMessage
+	ChatPrivate
+)
+
+func (k ChatEventKind) String() string {
+	switch k {
+	case ChatJoined:
+		return "joined"
+	case ChatLeft:
+		return "left"
+	case ChatMessage:
+		return "message"
+	case ChatPrivate:
+		return "private"
+	}
+	return "unknown"
+}
+
+// ChatEvent is what a client receives from the hub
+type ChatEvent struct {
+	Kind ChatEventKind
+	Room string
+	From string
+	To   string
+	Text string
+}
+
+func (e ChatEvent) String() string {
+	switch e.Kind {
+	case ChatJoined, ChatLeft:
+		return fmt.Sprintf("#%s: %s %s", e.Room, e.From, e.Kind)
+	case ChatPrivate:
+		return fmt.Sprintf("(private) %s -> %s: %s", e.From, e.To, e.Text)
+	}
+	return fmt.Sprintf("#%s [%s]: %s", e.Room, e.From, e.Text)
+}
+
+// ChatHub is the mediator. All state below is owned by the run goroutine.
+type ChatHub struct {
+	ops     chan func()
+	done    chan struct{}
+	clients sync.WaitGroup
+
+	members map[string]*ChatClient
+	rooms   map[string]map[string]bool
+}
+
+func NewChatHub() *ChatHub {
+	h := &ChatHub{
+		ops:     make(chan func()),
+		done:    make(chan struct{}),
+		members: make(map[string]*ChatClient),
+		rooms:   make(map[string]map[string]bool),
+	}
+	go h.run()
+	return h
+}
+
+func (h *ChatHub) run() {
+	for {
+		select {
+		case op := <-h.ops:
+			op()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// do executes fn on the hub goroutine and waits for its result
+func (h *ChatHub) do(fn func() error) error {
+	result := make(chan error, 1)
+	select {
+	case h.ops <- func() { result <- fn() }:
+		return <-result
+	case <-h.done:
+		return ErrHubClosed
+	}
+}
+
+// ChatClient is a colleague with its own delivery goroutine
+type ChatClient struct {
+	name    string
+	hub     *ChatHub
+	inbox   chan ChatEvent
+	dropped atomic.Int64
+}
+
+// Connect registers a client. handler runs on the client's goroutine, one event at a time.
+// Events are dropped (and counted) if the client falls more than buffer events behind,
+// so one slow consumer can never stall the hub.
+func (h *ChatHub) Connect(name string, buffer int, handler func(ChatEvent)) (*ChatClient, error) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	c := &ChatClient{name: name, hub: h, inbox: make(chan ChatEvent, buffer)}
+
+	err := h.do(func() error {
+		if _, taken := h.members[name]; taken {
+			return ErrNameTaken
+		}
+		h.members[name] = c
+		h.clients.Add(1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer h.clients.Done()
+		for event := range c.inbox {
+			handler(event)
+		}
+	}()
+	return c, nil
+}
+
+func (c *ChatClient) Name() string {
+	return c.name
+}
+
+// Dropped reports how many events were discarded because the inbox was full
+func (c *ChatClient) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// deliver is only called from the hub goroutine
+func (c *ChatClient) deliver(e ChatEvent) {
+	select {
+	case c.inbox <- e:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+func (c *ChatClient) Join(room string) error {
+	h := c.hub
+	return h.do(func() error {
+		if h.members[c.name] != c {
+			return ErrNotConnected
+		}
+		members := h.rooms[room]
+		if members == nil {
+			members = make(map[string]bool)
+			h.rooms[room] = members
+		}
+		if members[c.name] {
+			return nil
+		}
+		members[c.name] = true
+		h.broadcast(room, ChatEvent{Kind: ChatJoined, Room: room, From: c.name}, "")
+		return nil
+	})
+}
+
+func (c *ChatClient) Leave(room string) error {
+	h := c.hub
+	return h.do(func() error {
+		if !h.rooms[room][c.name] {
+			return ErrNotInRoom
+		}
+		h.leave(room, c.name)
+		return nil
+	})
+}
+
+// Say sends a message to everyone else in the room
+func (c *ChatClient) Say(room, text string) error {
+	h := c.hub
+	return h.do(func() error {
+		if !h.rooms[room][c.name] {
+			return ErrNotInRoom
+		}
+		h.broadcast(room, ChatEvent{Kind: ChatMessage, Room: room, From: c.name, Text: text}, c.name)
+		return nil
+	})
+}
+
+// Whisper sends a private message to a single connected client
+func (c *ChatClient) Whisper(to, text string) error {
+	h := c.hub
+	return h.do(func() error {
+		if h.members[c.name] != c {
+			return ErrNotConnected
+		}
+		recipient, ok := h.members[to]
+		if !ok {
+			return ErrUnknownMember
+		}
+		recipient.deliver(ChatEvent{Kind: ChatPrivate, From: c.name, To: to, Text: text})
+		return nil
+	})
+}
+
+// Disconnect leaves every room and stops the client's goroutine after its inbox drains
+func (c *ChatClient) Disconnect() error {
+	h := c.hub
+	return h.do(func() error {
+		if h.members[c.name] != c {
+			return ErrNotConnected
+		}
+		h.disconnect(c)
+		return nil
+	})
+}
+
+// Rooms lists the rooms and their members, sorted for stable output
+func (h *ChatHub) Rooms() map[string][]string {
+	out := make(map[string][]string)
+	h.do(func() error {
+		for room, members := range h.rooms {
+			for name := range members {
+				out[room] = append(out[room], name)
+			}
+			sort.Strings(out[room])
+		}
+		return nil
+	})
+	return out
+}
+
+// Close disconnects every client, waits for their pending events to be handled,
+// and stops the hub. Calls made after Close return ErrHubClosed.
+func (h *ChatHub) Close() {
+	err := h.do(func() error {
+		for _, c := range h.members {
+			h.disconnect(c)
+		}
+		close(h.done)
+		return nil
+	})
+	if err == nil {
+		h.clients.Wait()
+	}
+}
+
+func (h *ChatHub) broadcast(room string, e ChatEvent, except string) {
+	for name := range h.rooms[room] {
+		if name != except {
+			h.members[name].deliver(e)
+		}
+	}
+}
+
+func (h *ChatHub) leave(room, name string) {
+	delete(h.rooms[room], name)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+		return
+	}
+	h.broadcast(room, ChatEvent{Kind: ChatLeft, Room: room, From: name}, "")
+}
+
+func (h *ChatHub) disconnect(c *ChatClient) {
+	for room, members := range h.rooms {
+		if members[c.name] {
+			h.leave(room, c.name)
+		}
+	}
+	delete(h.members, c.name)
+	close(c.inbox)
+}
+
+func DemoConcurrentMediator() {
+	fmt.Fprintln(out, "=== Concurrent Mediator Demo ===")
+	fmt.Fprintln(out)
+
+	hub := NewChatHub()
+
+	var mu sync.Mutex
+	printer := func(name string) func(ChatEvent) {
+		return func(e ChatEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(out, "%-8s <- %s\n", name, e)
+		}
+	}
+
+	alice, _ := hub.Connect("Alice", 16, printer("Alice"))
+	bob, _ := hub.Connect("Bob", 16, printer("Bob"))
+	charlie, _ := hub.Connect("Charlie", 16, printer("Charlie"))
+
+	alice.Join("general")
+	bob.Join("general")
+	charlie.Join("general")
+	bob.Join("golang")
+	charlie.Join("golang")
+
+	alice.Say("general", "Hello everyone!")
+	charlie.Say("golang", "Generics or interfaces?")
+	bob.Whisper("Alice", "Want to review my PR?")
+	charlie.Leave("general")
+
+	// The clients may still be printing
+	rooms := hub.Rooms()
+	mu.Lock()
+	fmt.Fprintln(out, "Rooms:", rooms)
+	mu.Unlock()
+
+	// Close waits until every client has handled its queued events
+	hub.Close()
+	fmt.Fprintln(out, "Hub closed:", alice.Say("general", "anyone?"))
+}
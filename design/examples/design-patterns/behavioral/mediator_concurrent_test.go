@@ -0,0 +1,250 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recorder collects the events a client's handler receives
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handle(e ChatEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e.String())
+}
+
+func (r *recorder) got() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func connect(t *testing.T, hub *ChatHub, name string, buffer int) (*ChatClient, *recorder) {
+	t.Helper()
+	r := &recorder{}
+	c, err := hub.Connect(name, buffer, r.handle)
+	if err != nil {
+		t.Fatalf("Connect(%s): %v", name, err)
+	}
+	return c, r
+}
+
+func TestChatHubRoutesThroughTheMediator(t *testing.T) {
+	hub := NewChatHub()
+	alice, aliceGot := connect(t, hub, "Alice", 16)
+	bob, bobGot := connect(t, hub, "Bob", 16)
+	charlie, charlieGot := connect(t, hub, "Charlie", 16)
+
+	alice.Join("general")
+	bob.Join("general")
+	charlie.Join("general")
+	bob.Join("golang")
+	charlie.Join("golang")
+	alice.Say("general", "Hello everyone!")
+	charlie.Say("golang", "Generics or interfaces?")
+	bob.Whisper("Alice", "Want to review my PR?")
+	charlie.Leave("general")
+
+	if got, want := hub.Rooms(), map[string][]string{"general": {"Alice", "Bob"}, "golang": {"Bob", "Charlie"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rooms = %v, want %v", got, want)
+	}
+	// Close waits until every client has handled its queued events
+	hub.Close()
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"Alice", aliceGot.got(), []string{
+			"#general: Alice joined",
+			"#general: Bob joined",
+			"#general: Charlie joined",
+			"(private) Bob -> Alice: Want to review my PR?",
+			"#general: Charlie left",
+		}},
+		{"Bob", bobGot.got(), []string{
+			"#general: Bob joined",
+			"#general: Charlie joined",
+			"#golang: Bob joined",
+			"#golang: Charlie joined",
+			"#general [Alice]: Hello everyone!",
+			"#golang [Charlie]: Generics or interfaces?",
+			"#general: Charlie left",
+		}},
+		{"Charlie", charlieGot.got(), []string{
+			"#general: Charlie joined",
+			"#golang: Charlie joined",
+			"#general [Alice]: Hello everyone!",
+		}},
+	}
+	// Close then disconnects the clients in map order, so the departures it
+	// causes can come in any order
+	for _, tt := range tests {
+		t.Run(tt.name+" hears only what was meant for them", func(t *testing.T) {
+			if len(tt.got) < len(tt.want) || !reflect.DeepEqual(tt.got[:len(tt.want)], tt.want) {
+				t.Errorf("got:\n%v\nwant it to start with:\n%v", tt.got, tt.want)
+			}
+			for _, e := range tt.got[len(tt.want):] {
+				if e != "#general: Alice left" && e != "#golang: Bob left" && e != "#golang: Charlie left" && e != "#general: Bob left" {
+					t.Errorf("after the conversation %s got %q, want only departures from Close", tt.name, e)
+				}
+			}
+		})
+	}
+}
+
+func TestChatHubErrors(t *testing.T) {
+	hub := NewChatHub()
+	alice, _ := connect(t, hub, "Alice", 4)
+	gone, _ := connect(t, hub, "Gone", 4)
+	gone.Disconnect()
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"a taken name", func() error { _, err := hub.Connect("Alice", 4, func(ChatEvent) {}); return err }(), ErrNameTaken},
+		{"saying in a room not joined", alice.Say("general", "hi"), ErrNotInRoom},
+		{"leaving a room not joined", alice.Leave("general"), ErrNotInRoom},
+		{"whispering to nobody", alice.Whisper("Nobody", "psst"), ErrUnknownMember},
+		{"whispering to a client that left", alice.Whisper("Gone", "psst"), ErrUnknownMember},
+		{"joining after disconnecting", gone.Join("general"), ErrNotConnected},
+		{"disconnecting twice", gone.Disconnect(), ErrNotConnected},
+		{"joining twice is not an error", func() error { alice.Join("general"); return alice.Join("general") }(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.want) {
+				t.Errorf("err = %v, want %v", tt.err, tt.want)
+			}
+		})
+	}
+
+	hub.Close()
+	t.Run("every call after Close fails", func(t *testing.T) {
+		if err := alice.Say("general", "anyone?"); !errors.Is(err, ErrHubClosed) {
+			t.Errorf("Say = %v, want ErrHubClosed", err)
+		}
+		if _, err := hub.Connect("Late", 4, func(ChatEvent) {}); !errors.Is(err, ErrHubClosed) {
+			t.Errorf("Connect = %v, want ErrHubClosed", err)
+		}
+	})
+	t.Run("closing twice is safe", func(t *testing.T) {
+		hub.Close()
+	})
+}
+
+func TestChatHubUnderConcurrentClients(t *testing.T) {
+	const clients, messages = 20, 50
+	// user-00 can queue every lobby message, a whisper from each client on
+	// every tenth message, and a join and a departure per client before its
+	// handler runs at all, so an inbox this size never has to drop
+	const inbox = clients*messages + clients*(messages/10+1) + 2*clients
+	hub := NewChatHub()
+	members := make([]*ChatClient, clients)
+	heard := make([]*recorder, clients)
+	for i := range members {
+		members[i], heard[i] = connect(t, hub, fmt.Sprintf("user-%02d", i), inbox)
+		if err := members[i].Join("lobby"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range members {
+		wg.Add(1)
+		go func(c *ChatClient) {
+			defer wg.Done()
+			for m := 0; m < messages; m++ {
+				if err := c.Say("lobby", fmt.Sprint(m)); err != nil {
+					t.Errorf("%s: %v", c.Name(), err)
+					return
+				}
+				if m%10 == 0 {
+					c.Whisper("user-00", "ping")
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+	hub.Close()
+
+	for i, r := range heard {
+		name := members[i].Name()
+		// Each sender's messages arrive in the order it sent them
+		next := map[string]int{}
+		said := 0
+		for _, e := range r.got() {
+			var from string
+			var m int
+			if _, err := fmt.Sscanf(e, "#lobby [%s %d", &from, &m); err != nil {
+				continue
+			}
+			from = from[:len(from)-2] // drop "]:"
+			if m != next[from] {
+				t.Errorf("%s got message %d from %s, want %d", name, m, from, next[from])
+			}
+			next[from] = m + 1
+			said++
+		}
+		if want := (clients - 1) * messages; said != want || members[i].Dropped() != 0 {
+			t.Errorf("%s heard %d messages and dropped %d, want %d and none", name, said, members[i].Dropped(), want)
+		}
+	}
+}
+
+func TestChatHubSlowClientDoesNotStallOthers(t *testing.T) {
+	hub := NewChatHub()
+	release := make(chan struct{})
+	slow, err := hub.Connect("Slow", 1, func(ChatEvent) { <-release })
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, fastGot := connect(t, hub, "Fast", 64)
+	talker, _ := connect(t, hub, "Talker", 64)
+	for _, c := range []*ChatClient{slow, fast, talker} {
+		c.Join("room")
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := talker.Say("room", fmt.Sprint(i)); err != nil {
+			t.Fatalf("Say %d: %v", i, err)
+		}
+	}
+	close(release)
+	hub.Close()
+
+	if slow.Dropped() == 0 {
+		t.Error("the slow client dropped nothing, so its inbox blocked the hub")
+	}
+	if n := fastGot.got(); len(n) < 20 || fast.Dropped() != 0 {
+		t.Errorf("the fast client got %d events and dropped %d", len(n), fast.Dropped())
+	}
+}
+
+func TestDemoConcurrentMediatorReportsTheClosedHub(t *testing.T) {
+	buf := captureOutput(t)
+	DemoConcurrentMediator()
+	printed := lines(buf)
+	for _, want := range []string{
+		"Rooms: map[general:[Alice Bob] golang:[Bob Charlie]]",
+		"Hub closed: chat hub is closed",
+	} {
+		found := false
+		for _, line := range printed {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("the demo did not print %q:\n%v", want, printed)
+		}
+	}
+}
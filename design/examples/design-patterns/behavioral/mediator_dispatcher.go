@@ -0,0 +1,480 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dispatcher Mediator - producers submit jobs and workers run them, but
+// neither side knows the other exists. The Dispatcher decides who runs what:
+// it keeps a FIFO queue per priority, tracks which workers are idle, hands the
+// highest-priority job to the worker that has waited longest, and routes each
+// result back to the producer that submitted the job. Like ChatHub, all of its
+// state is owned by one goroutine; workers and producers run their own.
+//
+// Priorities are strict: high-priority jobs always go first, so a steady
+// stream of them would starve low-priority work.
+
+var (
+	ErrDispatcherClosed = errors.New("dispatcher is closed")
+)
+
+type JobPriority int
+
+const (
+	PriorityLow JobPriority = iota
+	PriorityNormal
+	PriorityHigh
+	priorityLevels
+)
+
+func (p JobPriority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+type DispatchJob struct {
+	ID       int
+	Name     string
+	Priority JobPriority
+	Producer string
+}
+
+// JobResult is sent back to the job's producer
+type JobResult struct {
+	Job    DispatchJob
+	Worker string
+	Err    error
+}
+
+type DispatcherStats struct {
+	Queued    map[JobPriority]int
+	Idle      int
+	Busy      int
+	Completed int
+	Failed    int
+}
+
+// Dispatcher is the mediator. All state below is owned by the run goroutine.
+type Dispatcher struct {
+	ops  chan func()
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	queues    [priorityLevels][]DispatchJob
+	idle      []*Worker
+	workers   map[string]*Worker
+	producers map[string]*Producer
+	nextID    int
+	busy      int
+	completed int
+	failed    int
+	closing   bool
+	drained   chan struct{}
+	final     DispatcherStats // what Stats reports once closed
+}
+
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{
+		ops:       make(chan func()),
+		done:      make(chan struct{}),
+		workers:   make(map[string]*Worker),
+		producers: make(map[string]*Producer),
+		drained:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+func (d *Dispatcher) run() {
+	for {
+		select {
+		case op := <-d.ops:
+			op()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// do executes fn on the dispatcher goroutine and waits for its result
+func (d *Dispatcher) do(fn func() error) error {
+	result := make(chan error, 1)
+	select {
+	case d.ops <- func() { result <- fn() }:
+		return <-result
+	case <-d.done:
+		return ErrDispatcherClosed
+	}
+}
+
+// Worker is a colleague that runs one job at a time on its own goroutine
+type Worker struct {
+	name     string
+	d        *Dispatcher
+	jobs     chan DispatchJob
+	busy     bool
+	stopping bool
+}
+
+// RegisterWorker adds a worker; it becomes available for jobs immediately.
+// handle runs on the worker's goroutine and its error is reported to the producer.
+func (d *Dispatcher) RegisterWorker(name string, handle func(DispatchJob) error) (*Worker, error) {
+	w := &Worker{name: name, d: d, jobs: make(chan DispatchJob, 1)}
+	err := d.do(func() error {
+		if d.closing {
+			return ErrDispatcherClosed
+		}
+		if _, taken := d.workers[name]; taken {
+			return ErrNameTaken
+		}
+		d.workers[name] = w
+		d.wg.Add(1)
+		d.idle = append(d.idle, w)
+		d.dispatch()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer d.wg.Done()
+		for job := range w.jobs {
+			d.finish(w, job, handle(job))
+		}
+	}()
+	return w, nil
+}
+
+func (w *Worker) Name() string {
+	return w.name
+}
+
+// Stop takes the worker out of rotation. A job it is running is finished first.
+func (w *Worker) Stop() error {
+	d := w.d
+	return d.do(func() error {
+		if d.workers[w.name] != w || w.stopping {
+			return ErrNotConnected
+		}
+		if w.busy {
+			w.stopping = true
+			return nil
+		}
+		d.removeWorker(w)
+		return nil
+	})
+}
+
+// Producer is a colleague that submits jobs and receives their results
+type Producer struct {
+	name   string
+	d      *Dispatcher
+	mu     sync.Mutex
+	ready  []JobResult
+	notify chan struct{}
+}
+
+// RegisterProducer adds a producer. onResult runs on the producer's goroutine,
+// one result at a time, in the order the jobs finished.
+func (d *Dispatcher) RegisterProducer(name string, onResult func(JobResult)) (*Producer, error) {
+	p := &Producer{name: name, d: d, notify: make(chan struct{}, 1)}
+	err := d.do(func() error {
+		if d.closing {
+			return ErrDispatcherClosed
+		}
+		if _, taken := d.producers[name]; taken {
+			return ErrNameTaken
+		}
+		d.producers[name] = p
+		d.wg.Add(1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer d.wg.Done()
+		for range p.notify {
+			p.mu.Lock()
+			results := p.ready
+			p.ready = nil
+			p.mu.Unlock()
+			for _, r := range results {
+				onResult(r)
+			}
+		}
+	}()
+	return p, nil
+}
+
+// Submit queues a job and returns its ID
+func (p *Producer) Submit(name string, priority JobPriority) (int, error) {
+	if priority < PriorityLow || priority >= priorityLevels {
+		return 0, fmt.Errorf("unknown priority %d", priority)
+	}
+	d := p.d
+	var id int
+	err := d.do(func() error {
+		if d.closing {
+			return ErrDispatcherClosed
+		}
+		if d.producers[p.name] != p {
+			return ErrNotConnected
+		}
+		d.nextID++
+		id = d.nextID
+		d.queues[priority] = append(d.queues[priority], DispatchJob{ID: id, Name: name, Priority: priority, Producer: p.name})
+		d.dispatch()
+		return nil
+	})
+	return id, err
+}
+
+// deliver never blocks the dispatcher: results wait in the producer's list
+func (p *Producer) deliver(r JobResult) {
+	p.mu.Lock()
+	p.ready = append(p.ready, r)
+	p.mu.Unlock()
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch pairs queued jobs with idle workers, highest priority first
+func (d *Dispatcher) dispatch() {
+	for len(d.idle) > 0 {
+		job, ok := d.next()
+		if !ok {
+			return
+		}
+		w := d.idle[0]
+		d.idle = d.idle[1:]
+		w.busy = true
+		d.busy++
+		w.jobs <- job // buffered and the worker is idle, so this never blocks
+	}
+}
+
+func (d *Dispatcher) next() (DispatchJob, bool) {
+	for p := priorityLevels - 1; p >= PriorityLow; p-- {
+		if q := d.queues[p]; len(q) > 0 {
+			d.queues[p] = q[1:]
+			return q[0], true
+		}
+	}
+	return DispatchJob{}, false
+}
+
+// finish is called by a worker's goroutine when a job is done
+func (d *Dispatcher) finish(w *Worker, job DispatchJob, err error) {
+	d.do(func() error {
+		w.busy = false
+		d.busy--
+		if err != nil {
+			d.failed++
+		} else {
+			d.completed++
+		}
+		d.producers[job.Producer].deliver(JobResult{Job: job, Worker: w.name, Err: err})
+
+		if w.stopping {
+			d.removeWorker(w)
+		} else {
+			d.idle = append(d.idle, w)
+			d.dispatch()
+		}
+		d.checkDrained()
+		return nil
+	})
+}
+
+func (d *Dispatcher) removeWorker(w *Worker) {
+	for i, idle := range d.idle {
+		if idle == w {
+			d.idle = append(d.idle[:i], d.idle[i+1:]...)
+			break
+		}
+	}
+	delete(d.workers, w.name)
+	close(w.jobs)
+	d.checkDrained()
+}
+
+// checkDrained signals Close once nothing is queued or running. Jobs that no
+// worker is left to run fail with ErrDispatcherClosed rather than wait forever.
+func (d *Dispatcher) checkDrained() {
+	if !d.closing || d.busy > 0 {
+		return
+	}
+	if len(d.workers) == 0 {
+		for {
+			job, ok := d.next()
+			if !ok {
+				break
+			}
+			d.failed++
+			d.producers[job.Producer].deliver(JobResult{Job: job, Err: ErrDispatcherClosed})
+		}
+	}
+	for _, q := range d.queues {
+		if len(q) > 0 {
+			return
+		}
+	}
+	select {
+	case <-d.drained:
+	default:
+		close(d.drained)
+	}
+}
+
+func (d *Dispatcher) Stats() DispatcherStats {
+	var s DispatcherStats
+	err := d.do(func() error {
+		s = d.stats()
+		return nil
+	})
+	if err != nil {
+		return d.final
+	}
+	return s
+}
+
+func (d *Dispatcher) stats() DispatcherStats {
+	s := DispatcherStats{Queued: make(map[JobPriority]int), Idle: len(d.idle), Busy: d.busy, Completed: d.completed, Failed: d.failed}
+	for p, q := range d.queues {
+		s.Queued[JobPriority(p)] = len(q)
+	}
+	return s
+}
+
+// Close stops accepting jobs, waits for queued and running ones to finish and
+// their results to be handled, then stops every worker and producer.
+func (d *Dispatcher) Close() {
+	err := d.do(func() error {
+		if d.closing {
+			return ErrDispatcherClosed
+		}
+		d.closing = true
+		d.checkDrained()
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	<-d.drained
+	d.do(func() error {
+		for _, w := range d.workers {
+			close(w.jobs)
+		}
+		for _, p := range d.producers {
+			close(p.notify)
+		}
+		d.final = d.stats()
+		close(d.done)
+		return nil
+	})
+	d.wg.Wait()
+}
+
+func DemoDispatcherMediator() {
+	fmt.Fprintln(out, "=== Dispatcher Mediator Demo ===")
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "1. Priorities (one worker, busy while jobs arrive):")
+	d := NewDispatcher()
+	started, release := make(chan struct{}), make(chan struct{})
+	var order []string
+	d.RegisterWorker("ann", func(job DispatchJob) error {
+		if job.ID == 1 {
+			close(started)
+			<-release
+		}
+		order = append(order, fmt.Sprintf("%s(%s)", job.Name, job.Priority))
+		return nil
+	})
+	web, _ := d.RegisterProducer("web", func(JobResult) {})
+	web.Submit("resize", PriorityNormal)
+	<-started
+	web.Submit("report", PriorityLow)
+	web.Submit("email", PriorityNormal)
+	web.Submit("charge", PriorityHigh)
+	web.Submit("cleanup", PriorityLow)
+	web.Submit("refund", PriorityHigh)
+	s := d.Stats()
+	fmt.Fprintf(out, "   queued high=%d normal=%d low=%d, busy=%d\n", s.Queued[PriorityHigh], s.Queued[PriorityNormal], s.Queued[PriorityLow], s.Busy)
+	close(release)
+	d.Close()
+	fmt.Fprintln(out, "   ran", strings.Join(order, " "))
+
+	fmt.Fprintln(out, "\n2. Concurrency (4 workers, 3 producers x 300 jobs, a worker leaves and one joins):")
+	d = NewDispatcher()
+	const producers, perProducer = 3, 300
+	work := func(job DispatchJob) error {
+		time.Sleep(time.Duration(rand.Intn(50)) * time.Microsecond)
+		if job.Name == "bad-input" {
+			return errors.New("rejected")
+		}
+		return nil
+	}
+	workers := make([]*Worker, 0, 4)
+	for _, name := range []string{"w1", "w2", "w3", "w4"} {
+		w, _ := d.RegisterWorker(name, work)
+		workers = append(workers, w)
+	}
+
+	received := make([]int, producers)
+	var submitters sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		i := i
+		p, _ := d.RegisterProducer(fmt.Sprintf("p%d", i), func(JobResult) {
+			received[i]++ // producer goroutine only
+		})
+		submitters.Add(1)
+		go func() {
+			defer submitters.Done()
+			for j := 0; j < perProducer; j++ {
+				name := "job"
+				if j%100 == 99 {
+					name = "bad-input"
+				}
+				p.Submit(name, JobPriority(rand.Intn(int(priorityLevels))))
+				if i == 0 && j == perProducer/2 {
+					workers[0].Stop()
+					d.RegisterWorker("w5", work)
+				}
+			}
+		}()
+	}
+	submitters.Wait()
+	d.Close()
+	s = d.Stats()
+	fmt.Fprintf(out, "   %d completed, %d failed (the bad-input jobs)\n", s.Completed, s.Failed)
+	fmt.Fprintf(out, "   results received per producer: %v\n", received)
+	_, err := d.RegisterProducer("late", nil)
+	fmt.Fprintln(out, "   a closed dispatcher refuses newcomers:", err)
+
+	fmt.Fprintln(out, "\n3. Close with no workers left:")
+	d = NewDispatcher()
+	batch, _ := d.RegisterProducer("batch", func(r JobResult) {
+		fmt.Fprintf(out, "   #%d %s: %v\n", r.Job.ID, r.Job.Name, r.Err)
+	})
+	batch.Submit("export", PriorityLow)
+	batch.Submit("archive", PriorityLow)
+	d.Close()
+}
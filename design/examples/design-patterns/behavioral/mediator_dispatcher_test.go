@@ -0,0 +1,259 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcherRunsHigherPrioritiesFirst(t *testing.T) {
+	d := NewDispatcher()
+	started, release := make(chan struct{}), make(chan struct{})
+	var order []string
+	d.RegisterWorker("ann", func(job DispatchJob) error {
+		if job.ID == 1 {
+			close(started)
+			<-release
+		}
+		order = append(order, job.Name)
+		return nil
+	})
+	web, _ := d.RegisterProducer("web", func(JobResult) {})
+	web.Submit("resize", PriorityNormal)
+	<-started
+	// ann is busy, so everything below queues
+	for _, job := range []struct {
+		name     string
+		priority JobPriority
+	}{
+		{"report", PriorityLow},
+		{"email", PriorityNormal},
+		{"charge", PriorityHigh},
+		{"cleanup", PriorityLow},
+		{"refund", PriorityHigh},
+	} {
+		web.Submit(job.name, job.priority)
+	}
+	s := d.Stats()
+	if s.Queued[PriorityHigh] != 2 || s.Queued[PriorityNormal] != 1 || s.Queued[PriorityLow] != 2 || s.Busy != 1 || s.Idle != 0 {
+		t.Errorf("Stats = %+v", s)
+	}
+	close(release)
+	d.Close()
+
+	if got, want := strings.Join(order, " "), "resize charge refund email report cleanup"; got != want {
+		t.Errorf("ran %s, want %s", got, want)
+	}
+}
+
+func TestDispatcherUnderConcurrentProducers(t *testing.T) {
+	const producers, perProducer, maxWorkers = 3, 300, 4
+	d := NewDispatcher()
+
+	var inFlight, maxInFlight atomic.Int64
+	var mu sync.Mutex
+	runs := make(map[int]int)
+	perWorker := make(map[string]int)
+	work := func(name string) func(DispatchJob) error {
+		var running atomic.Int32
+		return func(job DispatchJob) error {
+			if running.Add(1) != 1 {
+				t.Errorf("%s got job %d while busy", name, job.ID)
+			}
+			defer running.Add(-1)
+			n := inFlight.Add(1)
+			for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+			}
+			time.Sleep(time.Duration(rand.Intn(50)) * time.Microsecond)
+			inFlight.Add(-1)
+			mu.Lock()
+			runs[job.ID]++
+			perWorker[name]++
+			mu.Unlock()
+			if job.Name == "bad-input" {
+				return errors.New("rejected")
+			}
+			return nil
+		}
+	}
+	workers := make([]*Worker, 0, maxWorkers)
+	for i := 1; i <= maxWorkers; i++ {
+		w, err := d.RegisterWorker(fmt.Sprintf("w%d", i), work(fmt.Sprintf("w%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		workers = append(workers, w)
+	}
+
+	results := make([][]JobResult, producers)
+	var submitters sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		i := i
+		p, err := d.RegisterProducer(fmt.Sprintf("p%d", i), func(r JobResult) {
+			results[i] = append(results[i], r) // producer goroutine only
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		submitters.Add(1)
+		go func() {
+			defer submitters.Done()
+			for j := 0; j < perProducer; j++ {
+				name := "job"
+				if j%100 == 99 {
+					name = "bad-input"
+				}
+				if _, err := p.Submit(name, JobPriority(rand.Intn(int(priorityLevels)))); err != nil {
+					t.Errorf("Submit: %v", err)
+				}
+				// Halfway through, one worker leaves and another joins
+				if i == 0 && j == perProducer/2 {
+					workers[0].Stop()
+					d.RegisterWorker("w5", work("w5"))
+				}
+			}
+		}()
+	}
+	submitters.Wait()
+	d.Close()
+
+	for id := 1; id <= producers*perProducer; id++ {
+		if runs[id] != 1 {
+			t.Errorf("job %d ran %d times, want once", id, runs[id])
+		}
+	}
+	for i, rs := range results {
+		failed := 0
+		for _, r := range rs {
+			if r.Job.Producer != fmt.Sprintf("p%d", i) {
+				t.Errorf("p%d got the result of %s's job %d", i, r.Job.Producer, r.Job.ID)
+			}
+			if r.Err != nil {
+				failed++
+			}
+		}
+		if len(rs) != perProducer || failed != 3 {
+			t.Errorf("p%d got %d results with %d failures, want %d with 3", i, len(rs), failed, perProducer)
+		}
+	}
+	// w1 finishes the job it holds when stopped while w5 may already be running one
+	if n := maxInFlight.Load(); n > maxWorkers+1 {
+		t.Errorf("%d jobs ran at once with %d workers", n, maxWorkers)
+	}
+	if s := d.Stats(); s.Completed != 891 || s.Failed != 9 || s.Busy != 0 {
+		t.Errorf("Stats after Close = %+v, want 891 completed and 9 failed", s)
+	}
+	if perWorker["w5"] == 0 {
+		t.Error("the worker that joined late never ran a job")
+	}
+}
+
+func TestDispatcherWorkerStop(t *testing.T) {
+	d := NewDispatcher()
+	started, release := make(chan struct{}), make(chan struct{})
+	var ran []string
+	w, _ := d.RegisterWorker("ann", func(job DispatchJob) error {
+		if job.ID == 1 {
+			close(started)
+			<-release
+		}
+		ran = append(ran, job.Name)
+		return nil
+	})
+	done := make(chan JobResult, 2)
+	p, _ := d.RegisterProducer("web", func(r JobResult) { done <- r })
+	p.Submit("first", PriorityNormal)
+	<-started
+	p.Submit("second", PriorityNormal)
+
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop while busy = %v", err)
+	}
+	if err := w.Stop(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("second Stop = %v, want ErrNotConnected", err)
+	}
+	close(release)
+	if r := <-done; r.Job.Name != "first" || r.Err != nil || r.Worker != "ann" {
+		t.Errorf("a stopping worker's running job ended with %+v", r)
+	}
+	// The queued job waits for a worker rather than going to the stopped one
+	bob, _ := d.RegisterWorker("bob", func(DispatchJob) error { return nil })
+	if r := <-done; r.Job.Name != "second" || r.Worker != bob.Name() {
+		t.Errorf("the queued job ended with %+v, want it run by bob", r)
+	}
+	d.Close()
+	if strings.Join(ran, " ") != "first" {
+		t.Errorf("ann ran %v after being stopped", ran)
+	}
+}
+
+func TestDispatcherErrors(t *testing.T) {
+	d := NewDispatcher()
+	d.RegisterWorker("ann", func(DispatchJob) error { return nil })
+	p, _ := d.RegisterProducer("web", func(JobResult) {})
+
+	if _, err := d.RegisterWorker("ann", func(DispatchJob) error { return nil }); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("RegisterWorker(ann) again = %v, want ErrNameTaken", err)
+	}
+	if _, err := d.RegisterProducer("web", func(JobResult) {}); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("RegisterProducer(web) again = %v, want ErrNameTaken", err)
+	}
+	for _, priority := range []JobPriority{-1, priorityLevels} {
+		if _, err := p.Submit("job", priority); err == nil {
+			t.Errorf("Submit with priority %d was accepted", priority)
+		}
+	}
+
+	d.Close()
+	if _, err := p.Submit("job", PriorityLow); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("Submit after Close = %v, want ErrDispatcherClosed", err)
+	}
+	if _, err := d.RegisterProducer("late", nil); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("RegisterProducer after Close = %v, want ErrDispatcherClosed", err)
+	}
+	d.Close() // closing twice is safe
+}
+
+func TestDispatcherCloseWithNoWorkersFailsQueuedJobs(t *testing.T) {
+	d := NewDispatcher()
+	var failed []JobResult
+	batch, _ := d.RegisterProducer("batch", func(r JobResult) { failed = append(failed, r) })
+	batch.Submit("export", PriorityLow)
+	batch.Submit("archive", PriorityHigh)
+	d.Close()
+
+	if len(failed) != 2 {
+		t.Fatalf("got %d results, want 2", len(failed))
+	}
+	for _, r := range failed {
+		if !errors.Is(r.Err, ErrDispatcherClosed) || r.Worker != "" {
+			t.Errorf("job %s ended with %+v, want ErrDispatcherClosed", r.Job.Name, r)
+		}
+	}
+	if s := d.Stats(); s.Failed != 2 || s.Queued[PriorityLow]+s.Queued[PriorityHigh] != 0 {
+		t.Errorf("Stats after Close = %+v", s)
+	}
+}
+
+func TestDemoDispatcherMediatorPrintsEachStep(t *testing.T) {
+	buf := captureOutput(t)
+	DemoDispatcherMediator()
+	assertLines(t, buf,
+		"=== Dispatcher Mediator Demo ===",
+		"1. Priorities (one worker, busy while jobs arrive):",
+		"   queued high=2 normal=1 low=2, busy=1",
+		"   ran resize(normal) charge(high) refund(high) email(normal) report(low) cleanup(low)",
+		"2. Concurrency (4 workers, 3 producers x 300 jobs, a worker leaves and one joins):",
+		"   891 completed, 9 failed (the bad-input jobs)",
+		"   results received per producer: [300 300 300]",
+		"   a closed dispatcher refuses newcomers: dispatcher is closed",
+		"3. Close with no workers left:",
+		"   #1 export: dispatcher is closed",
+		"   #2 archive: dispatcher is closed",
+	)
+}
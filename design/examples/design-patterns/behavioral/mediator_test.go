@@ -0,0 +1,45 @@
+package behavioral
+
+import "testing"
+
+// recordingUser is a Colleague that keeps what it receives
+type recordingUser struct {
+	name     string
+	received []string
+}
+
+func (u *recordingUser) Send(string)            {}
+func (u *recordingUser) Receive(message string) { u.received = append(u.received, message) }
+func (u *recordingUser) GetName() string        { return u.name }
+
+func TestChatRoomDeliversToEveryoneButTheSender(t *testing.T) {
+	captureOutput(t)
+	room := &ChatRoom{}
+	alice := NewChatUser("Alice", room)
+	bob := &recordingUser{name: "Bob"}
+	carol := &recordingUser{name: "Carol"}
+	room.AddUser(alice)
+	room.AddUser(bob)
+	room.AddUser(carol)
+
+	alice.Send("Hello everyone!")
+
+	for _, user := range []*recordingUser{bob, carol} {
+		if len(user.received) != 1 || user.received[0] != "[Alice]: Hello everyone!" {
+			t.Errorf("%s received %q", user.name, user.received)
+		}
+	}
+}
+
+func TestChatUserPrintsWhatItSendsAndReceives(t *testing.T) {
+	buf := captureOutput(t)
+	room := &ChatRoom{}
+	alice := NewChatUser("Alice", room)
+	bob := NewChatUser("Bob", room)
+	room.AddUser(alice)
+	room.AddUser(bob)
+	assertLines(t, buf, "Alice joined the chat", "Bob joined the chat")
+
+	bob.Send("Hi Alice!")
+	assertLines(t, buf, "Bob sends: Hi Alice!", "Alice receives: [Bob]: Hi Alice!")
+}
@@ -1,6 +1,12 @@
 package behavioral
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
 
 // Memento Pattern - Saves and restores the previous state of an object.
 
@@ -28,12 +34,27 @@ func (e *Editor) Restore(m *Memento) {
 	e.content = m.state
 }
 
+// History is the caretaker. The zero value keeps every snapshot;
+// NewHistory caps it so long editing sessions don't grow without bound.
 type History struct {
 	mementos []*Memento
+	max      int
+}
+
+func NewHistory(max int) *History {
+	return &History{max: max}
 }
 
 func (h *History) Push(m *Memento) {
 	h.mementos = append(h.mementos, m)
+	if h.max > 0 && len(h.mementos) > h.max {
+		// Forget the oldest snapshot; it can no longer be undone to
+		h.mementos = append(h.mementos[:0], h.mementos[len(h.mementos)-h.max:]...)
+	}
+}
+
+func (h *History) Len() int {
+	return len(h.mementos)
 }
 
 func (h *History) Pop() *Memento {
@@ -46,22 +67,244 @@ func (h *History) Pop() *Memento {
 	return memento
 }
 
+type historyFile struct {
+	Max    int      `json:"max"`
+	States []string `json:"states"`
+}
+
+// SaveFile persists the history so undo state survives a restart
+func (h *History) SaveFile(path string) error {
+	file := historyFile{Max: h.max, States: make([]string, len(h.mementos))}
+	for i, m := range h.mementos {
+		file.States[i] = m.state
+	}
+	return writeJSONFile(path, file)
+}
+
+// LoadHistory restores a history written by SaveFile
+func LoadHistory(path string) (*History, error) {
+	var file historyFile
+	if err := readJSONFile(path, &file); err != nil {
+		return nil, err
+	}
+	h := NewHistory(file.Max)
+	for _, state := range file.States {
+		h.Push(&Memento{state: state})
+	}
+	return h, nil
+}
+
+// TextDiff turns one snapshot into the next by replacing Old with New at Pos.
+// Storing diffs instead of full copies keeps memory proportional to the edits,
+// not to the document size.
+type TextDiff struct {
+	Pos int    `json:"pos"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+func diffText(from, to string) TextDiff {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix &&
+		from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+	return TextDiff{
+		Pos: prefix,
+		Old: from[prefix : len(from)-suffix],
+		New: to[prefix : len(to)-suffix],
+	}
+}
+
+func (d TextDiff) revert(s string) string {
+	return s[:d.Pos] + d.Old + s[d.Pos+len(d.New):]
+}
+
+// DiffHistory is a caretaker that keeps only the latest snapshot in full and
+// a capped stack of diffs leading up to it.
+type DiffHistory struct {
+	latest  string
+	started bool
+	diffs   []TextDiff
+	max     int
+}
+
+func NewDiffHistory(max int) *DiffHistory {
+	return &DiffHistory{max: max}
+}
+
+func (h *DiffHistory) Push(m *Memento) {
+	if h.started {
+		h.diffs = append(h.diffs, diffText(h.latest, m.state))
+		if h.max > 0 && len(h.diffs) > h.max {
+			h.diffs = append(h.diffs[:0], h.diffs[len(h.diffs)-h.max:]...)
+		}
+	}
+	h.latest = m.state
+	h.started = true
+}
+
+// Pop returns the most recent snapshot and rewinds to the one before it
+func (h *DiffHistory) Pop() *Memento {
+	if !h.started {
+		return nil
+	}
+	m := &Memento{state: h.latest}
+	if len(h.diffs) == 0 {
+		h.latest, h.started = "", false
+		return m
+	}
+	last := h.diffs[len(h.diffs)-1]
+	h.diffs = h.diffs[:len(h.diffs)-1]
+	h.latest = last.revert(h.latest)
+	return m
+}
+
+func (h *DiffHistory) Len() int {
+	if !h.started {
+		return 0
+	}
+	return len(h.diffs) + 1
+}
+
+// StoredBytes approximates the memory held by the history
+func (h *DiffHistory) StoredBytes() int {
+	n := len(h.latest)
+	for _, d := range h.diffs {
+		n += len(d.Old) + len(d.New)
+	}
+	return n
+}
+
+type diffHistoryFile struct {
+	Max    int        `json:"max"`
+	Latest *string    `json:"latest,omitempty"`
+	Diffs  []TextDiff `json:"diffs"`
+}
+
+func (h *DiffHistory) SaveFile(path string) error {
+	file := diffHistoryFile{Max: h.max, Diffs: h.diffs}
+	if h.started {
+		latest := h.latest
+		file.Latest = &latest
+	}
+	return writeJSONFile(path, file)
+}
+
+func LoadDiffHistory(path string) (*DiffHistory, error) {
+	var file diffHistoryFile
+	if err := readJSONFile(path, &file); err != nil {
+		return nil, err
+	}
+	h := NewDiffHistory(file.Max)
+	if file.Latest == nil {
+		if len(file.Diffs) > 0 {
+			return nil, errors.New("memento: diff history has diffs but no snapshot")
+		}
+		return h, nil
+	}
+
+	// Validate that every diff can be reverted before accepting the file
+	state := *file.Latest
+	for i := len(file.Diffs) - 1; i >= 0; i-- {
+		d := file.Diffs[i]
+		if d.Pos < 0 || d.Pos+len(d.New) > len(state) || state[d.Pos:d.Pos+len(d.New)] != d.New {
+			return nil, fmt.Errorf("memento: diff %d does not apply to saved state", i)
+		}
+		state = d.revert(state)
+	}
+	h.latest, h.started, h.diffs = *file.Latest, true, file.Diffs
+	return h, nil
+}
+
+// writeJSONFile writes atomically so a crash never leaves a truncated history behind
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".history-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("memento: corrupt history file %s: %w", path, err)
+	}
+	return nil
+}
+
 func DemoMemento() {
-	fmt.Println("=== Memento Pattern Demo ===\n")
+	fmt.Fprint(out, "=== Memento Pattern Demo ===\n\n")
 	editor := &Editor{}
 	history := &History{}
 	editor.Type("First sentence. ")
 	history.Push(editor.Save())
-	fmt.Printf("Saved: '%s'\n", editor.GetContent())
+	fmt.Fprintf(out, "Saved: '%s'\n", editor.GetContent())
 	editor.Type("Second sentence. ")
 	history.Push(editor.Save())
-	fmt.Printf("Saved: '%s'\n", editor.GetContent())
+	fmt.Fprintf(out, "Saved: '%s'\n", editor.GetContent())
 	editor.Type("Third sentence.")
-	fmt.Printf("Current: '%s'\n", editor.GetContent())
-	fmt.Println("\nUndo:")
+	fmt.Fprintf(out, "Current: '%s'\n", editor.GetContent())
+	fmt.Fprintln(out, "\nUndo:")
 	editor.Restore(history.Pop())
-	fmt.Printf("After undo: '%s'\n", editor.GetContent())
-	fmt.Println("\nUndo again:")
+	fmt.Fprintf(out, "After undo: '%s'\n", editor.GetContent())
+	fmt.Fprintln(out, "\nUndo again:")
 	editor.Restore(history.Pop())
-	fmt.Printf("After undo: '%s'\n", editor.GetContent())
+	fmt.Fprintf(out, "After undo: '%s'\n", editor.GetContent())
+
+	fmt.Fprintln(out, "\nBounded history (max 2 snapshots):")
+	bounded := NewHistory(2)
+	for _, word := range []string{"one ", "two ", "three "} {
+		editor.Type(word)
+		bounded.Push(editor.Save())
+	}
+	fmt.Fprintf(out, "Snapshots kept: %d, oldest undo: '%s'\n", bounded.Len(), bounded.mementos[0].state)
+
+	fmt.Fprintln(out, "\nDiff-based history on a large document:")
+	doc := &Editor{}
+	diffs := NewDiffHistory(0)
+	fullCopies := 0
+	for i := 0; i < 100; i++ {
+		doc.Type(fmt.Sprintf("Paragraph %d of a long document. ", i))
+		diffs.Push(doc.Save())
+		fullCopies += len(doc.GetContent())
+	}
+	fmt.Fprintf(out, "Document: %d bytes, 100 snapshots stored in %d bytes (full copies: %d bytes)\n",
+		len(doc.GetContent()), diffs.StoredBytes(), fullCopies)
+
+	fmt.Fprintln(out, "\nPersisting undo history:")
+	path := filepath.Join(os.TempDir(), "memento-demo-history.json")
+	defer os.Remove(path)
+	if err := diffs.SaveFile(path); err != nil {
+		fmt.Fprintln(out, "Save failed:", err)
+		return
+	}
+	restored, err := LoadDiffHistory(path)
+	if err != nil {
+		fmt.Fprintln(out, "Load failed:", err)
+		return
+	}
+	restored.Pop()
+	doc.Restore(restored.Pop())
+	fmt.Fprintf(out, "After restart + undo: %d bytes\n", len(doc.GetContent()))
 }
@@ -0,0 +1,154 @@
+package behavioral
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryKeepsTheNewestSnapshots(t *testing.T) {
+	editor := &Editor{}
+	history := NewHistory(2)
+	for _, word := range []string{"one ", "two ", "three "} {
+		editor.Type(word)
+		history.Push(editor.Save())
+	}
+	if history.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", history.Len())
+	}
+	for _, want := range []string{"one two three ", "one two "} {
+		if got := history.Pop().state; got != want {
+			t.Errorf("Pop = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestDiffHistoryPopsEverySnapshot(t *testing.T) {
+	states := []string{"", "Hello", "Hello world", "Hi world", "Hi there, world"}
+	history := NewDiffHistory(0)
+	for _, s := range states {
+		history.Push(&Memento{state: s})
+	}
+	if history.Len() != len(states) {
+		t.Fatalf("Len = %d, want %d", history.Len(), len(states))
+	}
+	for i := len(states) - 1; i >= 0; i-- {
+		if got := history.Pop().state; got != states[i] {
+			t.Errorf("Pop = %q, want %q", got, states[i])
+		}
+	}
+	if history.Pop() != nil {
+		t.Error("Pop on an empty history returned a snapshot")
+	}
+}
+
+func TestDiffHistoryKeepsTheNewestDiffs(t *testing.T) {
+	history := NewDiffHistory(2)
+	for _, s := range []string{"a", "ab", "abc", "abcd"} {
+		history.Push(&Memento{state: s})
+	}
+	// The latest snapshot plus two diffs back
+	if history.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", history.Len())
+	}
+	for _, want := range []string{"abcd", "abc", "ab"} {
+		if got := history.Pop().state; got != want {
+			t.Errorf("Pop = %q, want %q", got, want)
+		}
+	}
+	if history.Pop() != nil {
+		t.Error("Pop returned a snapshot older than the cap")
+	}
+}
+
+func TestDiffHistoryStoresLessThanCopies(t *testing.T) {
+	doc := &Editor{}
+	history := NewDiffHistory(0)
+	copies := 0
+	for i := 0; i < 50; i++ {
+		doc.Type("A paragraph of a long document. ")
+		history.Push(doc.Save())
+		copies += len(doc.GetContent())
+	}
+	if history.StoredBytes() >= copies/10 {
+		t.Errorf("StoredBytes = %d, full copies take %d", history.StoredBytes(), copies)
+	}
+}
+
+func TestHistoriesSurviveSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	history := NewHistory(3)
+	for _, s := range []string{"a", "ab", "abc"} {
+		history.Push(&Memento{state: s})
+	}
+	path := filepath.Join(dir, "history.json")
+	if err := history.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 3 || loaded.Pop().state != "abc" {
+		t.Error("loaded history differs from the saved one")
+	}
+	// The cap is saved too
+	loaded.Push(&Memento{state: "abd"})
+	loaded.Push(&Memento{state: "abde"})
+	if loaded.Len() != 3 || loaded.Pop().state != "abde" {
+		t.Errorf("after two pushes the loaded history has %d snapshots, want its cap of 3", loaded.Len())
+	}
+
+	diffs := NewDiffHistory(0)
+	for _, s := range []string{"x", "xy", "xyz"} {
+		diffs.Push(&Memento{state: s})
+	}
+	path = filepath.Join(dir, "diffs.json")
+	if err := diffs.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loadedDiffs, err := LoadDiffHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"xyz", "xy", "x"} {
+		if got := loadedDiffs.Pop().state; got != want {
+			t.Errorf("Pop = %q, want %q", got, want)
+		}
+	}
+
+	path = filepath.Join(dir, "empty.json")
+	if err := NewDiffHistory(0).SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if empty, err := LoadDiffHistory(path); err != nil || empty.Len() != 0 {
+		t.Errorf("an empty diff history loads as %v, %v", empty, err)
+	}
+
+	// Files are written through a temporary file renamed into place
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("%d files in the directory, want the 3 saved histories and no leftovers", len(entries))
+	}
+}
+
+func TestLoadDiffHistoryRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"corrupt":         `{"max":`,
+		"diffs, no state": `{"max":0,"diffs":[{"pos":0,"old":"","new":"a"}]}`,
+		"stale diff":      `{"max":0,"latest":"abc","diffs":[{"pos":1,"old":"","new":"zz"}]}`,
+	} {
+		path := filepath.Join(dir, "history.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadDiffHistory(path); err == nil {
+			t.Errorf("%s: loaded without an error", name)
+		}
+	}
+	if _, err := LoadHistory(filepath.Join(dir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("LoadHistory of a missing file = %v, want a not-exist error", err)
+	}
+}
@@ -0,0 +1,25 @@
+package behavioral
+
+import "testing"
+
+func TestEditorRestoresSnapshots(t *testing.T) {
+	editor := &Editor{}
+	history := &History{}
+	editor.Type("First. ")
+	history.Push(editor.Save())
+	editor.Type("Second. ")
+	history.Push(editor.Save())
+	editor.Type("Third.")
+
+	editor.Restore(history.Pop())
+	if got := editor.GetContent(); got != "First. Second. " {
+		t.Errorf("after one undo: %q", got)
+	}
+	editor.Restore(history.Pop())
+	if got := editor.GetContent(); got != "First. " {
+		t.Errorf("after two undos: %q", got)
+	}
+	if history.Pop() != nil {
+		t.Error("Pop on an empty history returned a snapshot")
+	}
+}
@@ -0,0 +1,12 @@
+package behavioral
+
+import (
+	"io"
+	"os"
+)
+
+// out is where the demos, and the objects that narrate what they do (Light,
+// VendingMachine, chat users, ...), print. Tests point it at a buffer with
+// captureOutput, in output_test.go, to assert on the behaviour instead of only
+// printing it.
+var out io.Writer = os.Stdout
@@ -0,0 +1,38 @@
+package behavioral
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// captureOutput points out at a buffer for the rest of the test. Tests that
+// use it must not run in parallel, since out is shared.
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := out
+	out = &buf
+	t.Cleanup(func() { out = previous })
+	return &buf
+}
+
+// lines splits what was printed into lines, without the empty ones
+func lines(buf *bytes.Buffer) []string {
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func assertLines(t *testing.T, buf *bytes.Buffer, want ...string) {
+	t.Helper()
+	got := lines(buf)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("printed:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	buf.Reset()
+}
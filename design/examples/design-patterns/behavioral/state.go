@@ -53,7 +53,7 @@ func (vm *VendingMachine) SetState(state State) {
 
 func (vm *VendingMachine) ReleaseItem() {
 	if vm.count > 0 {
-		fmt.Println("Item dispensed")
+		fmt.Fprintln(out, "Item dispensed")
 		vm.count--
 	}
 }
@@ -64,79 +64,79 @@ func (vm *VendingMachine) GetCount() int {
 
 type NoCoinState struct{}
 func (s *NoCoinState) InsertCoin(vm *VendingMachine) {
-	fmt.Println("Coin inserted")
+	fmt.Fprintln(out, "Coin inserted")
 	vm.SetState(vm.hasCoinState)
 }
 func (s *NoCoinState) EjectCoin(vm *VendingMachine) {
-	fmt.Println("No coin to eject")
+	fmt.Fprintln(out, "No coin to eject")
 }
 func (s *NoCoinState) PressButton(vm *VendingMachine) {
-	fmt.Println("Insert coin first")
+	fmt.Fprintln(out, "Insert coin first")
 }
 func (s *NoCoinState) Dispense(vm *VendingMachine) {
-	fmt.Println("Pay first")
+	fmt.Fprintln(out, "Pay first")
 }
 
 type HasCoinState struct{}
 func (s *HasCoinState) InsertCoin(vm *VendingMachine) {
-	fmt.Println("Coin already inserted")
+	fmt.Fprintln(out, "Coin already inserted")
 }
 func (s *HasCoinState) EjectCoin(vm *VendingMachine) {
-	fmt.Println("Coin ejected")
+	fmt.Fprintln(out, "Coin ejected")
 	vm.SetState(vm.noCoinState)
 }
 func (s *HasCoinState) PressButton(vm *VendingMachine) {
-	fmt.Println("Button pressed")
+	fmt.Fprintln(out, "Button pressed")
 	vm.SetState(vm.soldState)
 }
 func (s *HasCoinState) Dispense(vm *VendingMachine) {
-	fmt.Println("Press button first")
+	fmt.Fprintln(out, "Press button first")
 }
 
 type SoldState struct{}
 func (s *SoldState) InsertCoin(vm *VendingMachine) {
-	fmt.Println("Please wait, dispensing item")
+	fmt.Fprintln(out, "Please wait, dispensing item")
 }
 func (s *SoldState) EjectCoin(vm *VendingMachine) {
-	fmt.Println("Too late, item being dispensed")
+	fmt.Fprintln(out, "Too late, item being dispensed")
 }
 func (s *SoldState) PressButton(vm *VendingMachine) {
-	fmt.Println("Dispensing...")
+	fmt.Fprintln(out, "Dispensing...")
 }
 func (s *SoldState) Dispense(vm *VendingMachine) {
 	vm.ReleaseItem()
 	if vm.GetCount() > 0 {
 		vm.SetState(vm.noCoinState)
 	} else {
-		fmt.Println("Machine sold out")
+		fmt.Fprintln(out, "Machine sold out")
 		vm.SetState(vm.soldOutState)
 	}
 }
 
 type SoldOutState struct{}
 func (s *SoldOutState) InsertCoin(vm *VendingMachine) {
-	fmt.Println("Machine sold out")
+	fmt.Fprintln(out, "Machine sold out")
 }
 func (s *SoldOutState) EjectCoin(vm *VendingMachine) {
-	fmt.Println("No coin to eject")
+	fmt.Fprintln(out, "No coin to eject")
 }
 func (s *SoldOutState) PressButton(vm *VendingMachine) {
-	fmt.Println("Machine sold out")
+	fmt.Fprintln(out, "Machine sold out")
 }
 func (s *SoldOutState) Dispense(vm *VendingMachine) {
-	fmt.Println("No items available")
+	fmt.Fprintln(out, "No items available")
 }
 
 func DemoState() {
-	fmt.Println("=== State Pattern Demo ===\n")
+	fmt.Fprint(out, "=== State Pattern Demo ===\n\n")
 	vm := NewVendingMachine(2)
-	fmt.Printf("Items in machine: %d\n\n", vm.GetCount())
+	fmt.Fprintf(out, "Items in machine: %d\n\n", vm.GetCount())
 	vm.InsertCoin()
 	vm.PressButton()
-	fmt.Println()
+	fmt.Fprintln(out)
 	vm.InsertCoin()
 	vm.PressButton()
-	fmt.Println()
+	fmt.Fprintln(out)
 	vm.InsertCoin()
 	vm.PressButton()
 }
@@ -0,0 +1,236 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+)
+
+// State Pattern - document approval workflow.
+// Draft -> Review -> Approved -> Published, with a rejection loop back to Draft.
+// Each state decides which actions are legal and who may perform them, the way a
+// domain entity in the clean-architecture example guards its own invariants.
+
+var (
+	ErrForbidden         = errors.New("role is not allowed to perform this action")
+	ErrInvalidTransition = errors.New("action is not valid in the current state")
+	ErrSelfApproval      = errors.New("authors cannot approve their own document")
+	ErrReasonRequired    = errors.New("a rejection needs a reason")
+)
+
+type Role int
+
+const (
+	RoleAuthor Role = iota
+	RoleReviewer
+	RolePublisher
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleAuthor:
+		return "author"
+	case RoleReviewer:
+		return "reviewer"
+	case RolePublisher:
+		return "publisher"
+	}
+	return "unknown"
+}
+
+type Actor struct {
+	Name string
+	Role Role
+}
+
+type DocumentState interface {
+	Name() string
+	Edit(doc *Document, by Actor, body string) error
+	Submit(doc *Document, by Actor) error
+	Approve(doc *Document, by Actor) error
+	Reject(doc *Document, by Actor, reason string) error
+	Publish(doc *Document, by Actor) error
+}
+
+// Document is the context whose behavior changes with its state
+type Document struct {
+	Title      string
+	Body       string
+	Author     string
+	Rejections []string
+	History    []string
+	state      DocumentState
+}
+
+func NewDocument(title string, author Actor) *Document {
+	return &Document{Title: title, Author: author.Name, state: draftState{}}
+}
+
+func (d *Document) State() string {
+	return d.state.Name()
+}
+
+func (d *Document) Edit(by Actor, body string) error {
+	return d.state.Edit(d, by, body)
+}
+
+func (d *Document) Submit(by Actor) error {
+	return d.state.Submit(d, by)
+}
+
+func (d *Document) Approve(by Actor) error {
+	return d.state.Approve(d, by)
+}
+
+func (d *Document) Reject(by Actor, reason string) error {
+	return d.state.Reject(d, by, reason)
+}
+
+func (d *Document) Publish(by Actor) error {
+	return d.state.Publish(d, by)
+}
+
+func (d *Document) transition(to DocumentState, by Actor) {
+	d.History = append(d.History, fmt.Sprintf("%s: %s -> %s", by.Name, d.state.Name(), to.Name()))
+	d.state = to
+}
+
+func requireRole(by Actor, role Role) error {
+	if by.Role != role {
+		return fmt.Errorf("%s (%s): %w", by.Name, by.Role, ErrForbidden)
+	}
+	return nil
+}
+
+// invalidActions rejects every action; states embed it and override what they allow
+type invalidActions struct{}
+
+func (invalidActions) Edit(*Document, Actor, string) error {
+	return fmt.Errorf("edit: %w", ErrInvalidTransition)
+}
+func (invalidActions) Submit(*Document, Actor) error {
+	return fmt.Errorf("submit: %w", ErrInvalidTransition)
+}
+func (invalidActions) Approve(*Document, Actor) error {
+	return fmt.Errorf("approve: %w", ErrInvalidTransition)
+}
+func (invalidActions) Reject(*Document, Actor, string) error {
+	return fmt.Errorf("reject: %w", ErrInvalidTransition)
+}
+func (invalidActions) Publish(*Document, Actor) error {
+	return fmt.Errorf("publish: %w", ErrInvalidTransition)
+}
+
+type draftState struct{ invalidActions }
+
+func (draftState) Name() string { return "Draft" }
+
+func (draftState) Edit(d *Document, by Actor, body string) error {
+	if by.Name != d.Author {
+		return fmt.Errorf("%s is not the author: %w", by.Name, ErrForbidden)
+	}
+	d.Body = body
+	return nil
+}
+
+func (draftState) Submit(d *Document, by Actor) error {
+	if by.Name != d.Author {
+		return fmt.Errorf("%s is not the author: %w", by.Name, ErrForbidden)
+	}
+	if d.Body == "" {
+		return errors.New("cannot submit an empty document")
+	}
+	d.transition(reviewState{}, by)
+	return nil
+}
+
+type reviewState struct{ invalidActions }
+
+func (reviewState) Name() string { return "Review" }
+
+func (reviewState) Approve(d *Document, by Actor) error {
+	if err := requireRole(by, RoleReviewer); err != nil {
+		return err
+	}
+	if by.Name == d.Author {
+		return ErrSelfApproval
+	}
+	d.transition(approvedState{}, by)
+	return nil
+}
+
+func (reviewState) Reject(d *Document, by Actor, reason string) error {
+	if err := requireRole(by, RoleReviewer); err != nil {
+		return err
+	}
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	d.Rejections = append(d.Rejections, reason)
+	d.transition(draftState{}, by)
+	return nil
+}
+
+type approvedState struct{ invalidActions }
+
+func (approvedState) Name() string { return "Approved" }
+
+func (approvedState) Publish(d *Document, by Actor) error {
+	if err := requireRole(by, RolePublisher); err != nil {
+		return err
+	}
+	d.transition(publishedState{}, by)
+	return nil
+}
+
+// A publisher can still send an approved document back for rework
+func (approvedState) Reject(d *Document, by Actor, reason string) error {
+	if err := requireRole(by, RolePublisher); err != nil {
+		return err
+	}
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	d.Rejections = append(d.Rejections, reason)
+	d.transition(draftState{}, by)
+	return nil
+}
+
+// publishedState is terminal: every action is invalid
+type publishedState struct{ invalidActions }
+
+func (publishedState) Name() string { return "Published" }
+
+func DemoDocumentWorkflow() {
+	fmt.Fprintln(out, "=== Document Approval Workflow Demo ===")
+	fmt.Fprintln(out)
+
+	alice := Actor{Name: "Alice", Role: RoleAuthor}
+	bob := Actor{Name: "Bob", Role: RoleReviewer}
+	carol := Actor{Name: "Carol", Role: RolePublisher}
+
+	doc := NewDocument("Release notes", alice)
+	step := func(action string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "%-28s ✗ %v\n", action, err)
+			return
+		}
+		fmt.Fprintf(out, "%-28s ✓ now %s\n", action, doc.State())
+	}
+
+	step("Alice submits empty draft", doc.Submit(alice))
+	step("Alice edits", doc.Edit(alice, "v1"))
+	step("Alice submits", doc.Submit(alice))
+	step("Carol approves", doc.Approve(carol))
+	step("Bob rejects", doc.Reject(bob, "missing changelog"))
+	step("Alice edits", doc.Edit(alice, "v2 with changelog"))
+	step("Alice submits", doc.Submit(alice))
+	step("Bob approves", doc.Approve(bob))
+	step("Bob publishes", doc.Publish(bob))
+	step("Carol publishes", doc.Publish(carol))
+	step("Alice edits", doc.Edit(alice, "v3"))
+
+	fmt.Fprintln(out, "\nRejections:", doc.Rejections)
+	for _, h := range doc.History {
+		fmt.Fprintln(out, " ", h)
+	}
+}
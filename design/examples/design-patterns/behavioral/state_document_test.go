@@ -0,0 +1,204 @@
+package behavioral
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var (
+	docAuthor    = Actor{Name: "Alice", Role: RoleAuthor}
+	docReviewer  = Actor{Name: "Bob", Role: RoleReviewer}
+	docPublisher = Actor{Name: "Carol", Role: RolePublisher}
+	otherAuthor  = Actor{Name: "Dave", Role: RoleAuthor}
+)
+
+// documentIn returns a written document by Alice, moved to state along the
+// happy path
+func documentIn(t *testing.T, state string) *Document {
+	t.Helper()
+	doc := NewDocument("Release notes", docAuthor)
+	doc.Body = "v1"
+	steps := []func() error{
+		func() error { return doc.Submit(docAuthor) },
+		func() error { return doc.Approve(docReviewer) },
+		func() error { return doc.Publish(docPublisher) },
+	}
+	for i := 0; doc.State() != state; i++ {
+		if i == len(steps) {
+			t.Fatalf("no document reaches %q", state)
+		}
+		if err := steps[i](); err != nil {
+			t.Fatalf("moving to %q: %v", state, err)
+		}
+	}
+	return doc
+}
+
+type docAction struct {
+	name string
+	do   func(doc *Document, by Actor) error
+}
+
+var (
+	editDoc    = docAction{"edit", func(d *Document, by Actor) error { return d.Edit(by, "new body") }}
+	submitDoc  = docAction{"submit", func(d *Document, by Actor) error { return d.Submit(by) }}
+	approveDoc = docAction{"approve", func(d *Document, by Actor) error { return d.Approve(by) }}
+	rejectDoc  = docAction{"reject", func(d *Document, by Actor) error { return d.Reject(by, "needs work") }}
+	publishDoc = docAction{"publish", func(d *Document, by Actor) error { return d.Publish(by) }}
+)
+
+// TestDocumentTransitions tries every action in every state, by the actor
+// the action is meant for; wantErr nil means it moves the document to want
+func TestDocumentTransitions(t *testing.T) {
+	tests := []struct {
+		from    string
+		action  docAction
+		by      Actor
+		want    string
+		wantErr error
+	}{
+		{"Draft", editDoc, docAuthor, "Draft", nil},
+		{"Draft", submitDoc, docAuthor, "Review", nil},
+		{"Draft", approveDoc, docReviewer, "Draft", ErrInvalidTransition},
+		{"Draft", rejectDoc, docReviewer, "Draft", ErrInvalidTransition},
+		{"Draft", publishDoc, docPublisher, "Draft", ErrInvalidTransition},
+
+		{"Review", editDoc, docAuthor, "Review", ErrInvalidTransition},
+		{"Review", submitDoc, docAuthor, "Review", ErrInvalidTransition},
+		{"Review", approveDoc, docReviewer, "Approved", nil},
+		{"Review", rejectDoc, docReviewer, "Draft", nil},
+		{"Review", publishDoc, docPublisher, "Review", ErrInvalidTransition},
+
+		{"Approved", editDoc, docAuthor, "Approved", ErrInvalidTransition},
+		{"Approved", submitDoc, docAuthor, "Approved", ErrInvalidTransition},
+		{"Approved", approveDoc, docReviewer, "Approved", ErrInvalidTransition},
+		{"Approved", rejectDoc, docPublisher, "Draft", nil},
+		{"Approved", publishDoc, docPublisher, "Published", nil},
+
+		{"Published", editDoc, docAuthor, "Published", ErrInvalidTransition},
+		{"Published", submitDoc, docAuthor, "Published", ErrInvalidTransition},
+		{"Published", approveDoc, docReviewer, "Published", ErrInvalidTransition},
+		{"Published", rejectDoc, docPublisher, "Published", ErrInvalidTransition},
+		{"Published", publishDoc, docPublisher, "Published", ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"/"+tt.action.name, func(t *testing.T) {
+			doc := documentIn(t, tt.from)
+			history := len(doc.History)
+			err := tt.action.do(doc, tt.by)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if doc.State() != tt.want {
+				t.Errorf("now %s, want %s", doc.State(), tt.want)
+			}
+			// Only a change of state is recorded
+			wantHistory := history
+			if tt.want != tt.from {
+				wantHistory++
+			}
+			if len(doc.History) != wantHistory {
+				t.Errorf("history %v", doc.History)
+			}
+		})
+	}
+}
+
+// TestDocumentPermissions checks who may perform the actions a state allows
+func TestDocumentPermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		action  docAction
+		by      Actor
+		wantErr error
+	}{
+		{"only the author edits a draft", "Draft", editDoc, otherAuthor, ErrForbidden},
+		{"only the author submits", "Draft", submitDoc, otherAuthor, ErrForbidden},
+		{"a publisher cannot approve", "Review", approveDoc, docPublisher, ErrForbidden},
+		{"an author cannot approve", "Review", approveDoc, docAuthor, ErrForbidden},
+		{"a reviewer cannot approve their own document", "Review", approveDoc, Actor{Name: "Alice", Role: RoleReviewer}, ErrSelfApproval},
+		{"a publisher cannot reject in review", "Review", rejectDoc, docPublisher, ErrForbidden},
+		{"a reviewer cannot reject once approved", "Approved", rejectDoc, docReviewer, ErrForbidden},
+		{"a reviewer cannot publish", "Approved", publishDoc, docReviewer, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := documentIn(t, tt.from)
+			if err := tt.action.do(doc, tt.by); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if doc.State() != tt.from {
+				t.Errorf("now %s, want it to stay %s", doc.State(), tt.from)
+			}
+		})
+	}
+
+	t.Run("an empty draft cannot be submitted", func(t *testing.T) {
+		doc := NewDocument("Release notes", docAuthor)
+		if err := doc.Submit(docAuthor); err == nil || doc.State() != "Draft" {
+			t.Errorf("Submit = %v, now %s", err, doc.State())
+		}
+	})
+	for _, from := range []string{"Review", "Approved"} {
+		t.Run("a rejection in "+from+" needs a reason", func(t *testing.T) {
+			doc := documentIn(t, from)
+			by := docReviewer
+			if from == "Approved" {
+				by = docPublisher
+			}
+			if err := doc.Reject(by, ""); !errors.Is(err, ErrReasonRequired) || len(doc.Rejections) != 0 {
+				t.Errorf("Reject = %v, rejections %v", err, doc.Rejections)
+			}
+		})
+	}
+}
+
+func TestDocumentRecordsRejectionsAndHistory(t *testing.T) {
+	doc := documentIn(t, "Review")
+	doc.Reject(docReviewer, "missing changelog")
+	doc.Edit(docAuthor, "v2")
+	doc.Submit(docAuthor)
+	doc.Approve(docReviewer)
+	doc.Reject(docPublisher, "wrong release")
+
+	if want := []string{"missing changelog", "wrong release"}; !reflect.DeepEqual(doc.Rejections, want) {
+		t.Errorf("Rejections = %v, want %v", doc.Rejections, want)
+	}
+	want := []string{
+		"Alice: Draft -> Review",
+		"Bob: Review -> Draft",
+		"Alice: Draft -> Review",
+		"Bob: Review -> Approved",
+		"Carol: Approved -> Draft",
+	}
+	if !reflect.DeepEqual(doc.History, want) {
+		t.Errorf("History = %v, want %v", doc.History, want)
+	}
+}
+
+func TestDemoDocumentWorkflowPrintsEachStep(t *testing.T) {
+	buf := captureOutput(t)
+	DemoDocumentWorkflow()
+	assertLines(t, buf,
+		"=== Document Approval Workflow Demo ===",
+		"Alice submits empty draft    ✗ cannot submit an empty document",
+		"Alice edits                  ✓ now Draft",
+		"Alice submits                ✓ now Review",
+		"Carol approves               ✗ Carol (publisher): role is not allowed to perform this action",
+		"Bob rejects                  ✓ now Draft",
+		"Alice edits                  ✓ now Draft",
+		"Alice submits                ✓ now Review",
+		"Bob approves                 ✓ now Approved",
+		"Bob publishes                ✗ Bob (reviewer): role is not allowed to perform this action",
+		"Carol publishes              ✓ now Published",
+		"Alice edits                  ✗ edit: action is not valid in the current state",
+		"Rejections: [missing changelog]",
+		"  Alice: Draft -> Review",
+		"  Bob: Review -> Draft",
+		"  Alice: Draft -> Review",
+		"  Bob: Review -> Approved",
+		"  Carol: Approved -> Published",
+	)
+}
@@ -0,0 +1,258 @@
+package behavioral
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Persisting the State pattern.
+// A state object is behavior, not data, so it cannot be written to disk as
+// is. What gets saved is the state's name plus the context's data (the item
+// count); restoring maps the name back to the state object. A stored record
+// can be stale, hand-edited or written by a buggy version, so restore checks
+// the same invariants the transitions maintain and refuses a combination the
+// machine could never have reached. An order's status should survive a restart
+// the same way: stored by name, checked against the aggregate's rules on load.
+
+// VendingSnapshot is the persisted form of a VendingMachine
+type VendingSnapshot struct {
+	State string `json:"state"`
+	Count int    `json:"count"`
+}
+
+// IllegalStateError rejects a snapshot the machine could not have reached
+type IllegalStateError struct {
+	Snapshot VendingSnapshot
+	Reason   string
+}
+
+func (e *IllegalStateError) Error() string {
+	return fmt.Sprintf("illegal vending machine state %q with %d items: %s", e.Snapshot.State, e.Snapshot.Count, e.Reason)
+}
+
+// ErrNoSnapshot is returned by a store that has nothing saved yet
+var ErrNoSnapshot = errors.New("no saved state")
+
+const (
+	stateNoCoin  = "no_coin"
+	stateHasCoin = "has_coin"
+	stateSold    = "sold"
+	stateSoldOut = "sold_out"
+)
+
+// StateName names the current state for storage
+func (vm *VendingMachine) StateName() string {
+	switch vm.currentState {
+	case vm.noCoinState:
+		return stateNoCoin
+	case vm.hasCoinState:
+		return stateHasCoin
+	case vm.soldState:
+		return stateSold
+	}
+	return stateSoldOut
+}
+
+func (vm *VendingMachine) Snapshot() VendingSnapshot {
+	return VendingSnapshot{State: vm.StateName(), Count: vm.count}
+}
+
+// RestoreVendingMachine rebuilds a machine from a snapshot after checking it
+func RestoreVendingMachine(snap VendingSnapshot) (*VendingMachine, error) {
+	illegal := func(reason string) error {
+		return &IllegalStateError{Snapshot: snap, Reason: reason}
+	}
+	if snap.Count < 0 {
+		return nil, illegal("count cannot be negative")
+	}
+
+	vm := NewVendingMachine(snap.Count)
+	switch snap.State {
+	case stateNoCoin, stateHasCoin:
+		if snap.Count == 0 {
+			return nil, illegal("an empty machine is always sold out")
+		}
+		if snap.State == stateHasCoin {
+			vm.SetState(vm.hasCoinState)
+		}
+	case stateSoldOut:
+		if snap.Count > 0 {
+			return nil, illegal("a machine with items is not sold out")
+		}
+	case stateSold:
+		// PressButton dispenses before returning, so "sold" never outlives a call
+		return nil, illegal("sold is transient and is never saved")
+	default:
+		return nil, illegal("unknown state")
+	}
+	return vm, nil
+}
+
+// StateStore keeps the latest snapshot
+type StateStore interface {
+	Save(snap VendingSnapshot) error
+	Load() (VendingSnapshot, error)
+}
+
+// FileStateStore keeps the snapshot as JSON. Save writes a temporary file and
+// renames it over the old one, so a crash mid-write leaves the previous state.
+type FileStateStore struct {
+	Path string
+}
+
+func (s FileStateStore) Save(snap VendingSnapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+func (s FileStateStore) Load() (VendingSnapshot, error) {
+	var snap VendingSnapshot
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, ErrNoSnapshot
+	}
+	if err != nil {
+		return snap, err
+	}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return snap, fmt.Errorf("corrupt state file %s: %w", s.Path, err)
+	}
+	return snap, nil
+}
+
+type MemoryStateStore struct {
+	mu   sync.Mutex
+	snap *VendingSnapshot
+}
+
+func (s *MemoryStateStore) Save(snap VendingSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap = &snap
+	return nil
+}
+
+func (s *MemoryStateStore) Load() (VendingSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snap == nil {
+		return VendingSnapshot{}, ErrNoSnapshot
+	}
+	return *s.snap, nil
+}
+
+// PersistentVendingMachine saves its snapshot after every action
+type PersistentVendingMachine struct {
+	*VendingMachine
+	store StateStore
+}
+
+// OpenVendingMachine restores the machine saved in store, or starts a new one
+// with initialCount items if nothing is saved. An illegal saved state is an
+// error rather than a silent reset, so it gets looked at.
+func OpenVendingMachine(store StateStore, initialCount int) (*PersistentVendingMachine, error) {
+	snap, err := store.Load()
+	var vm *VendingMachine
+	switch {
+	case errors.Is(err, ErrNoSnapshot):
+		vm = NewVendingMachine(initialCount)
+	case err != nil:
+		return nil, err
+	default:
+		if vm, err = RestoreVendingMachine(snap); err != nil {
+			return nil, err
+		}
+	}
+	p := &PersistentVendingMachine{VendingMachine: vm, store: store}
+	return p, p.save()
+}
+
+func (p *PersistentVendingMachine) save() error {
+	return p.store.Save(p.Snapshot())
+}
+
+func (p *PersistentVendingMachine) InsertCoin() error {
+	p.VendingMachine.InsertCoin()
+	return p.save()
+}
+
+func (p *PersistentVendingMachine) EjectCoin() error {
+	p.VendingMachine.EjectCoin()
+	return p.save()
+}
+
+func (p *PersistentVendingMachine) PressButton() error {
+	p.VendingMachine.PressButton()
+	return p.save()
+}
+
+func DemoStatePersistence() {
+	fmt.Fprintln(out, "=== State Persistence Demo ===")
+	fmt.Fprintln(out)
+
+	dir, err := os.MkdirTemp("", "vending")
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	store := FileStateStore{Path: filepath.Join(dir, "machine.json")}
+
+	fmt.Fprintln(out, "1. First run, 3 items:")
+	vm, err := OpenVendingMachine(store, 3)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return
+	}
+	vm.InsertCoin()
+	vm.PressButton()
+	vm.InsertCoin() // the process stops with a coin inserted
+	saved, _ := os.ReadFile(store.Path)
+	fmt.Fprintf(out, "Saved: %s\n", saved)
+
+	fmt.Fprintln(out, "\n2. Restart:")
+	vm, err = OpenVendingMachine(store, 3)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return
+	}
+	fmt.Fprintf(out, "Restored %q with %d items\n", vm.StateName(), vm.GetCount())
+	vm.PressButton() // the coin from before the restart pays for this
+	vm.InsertCoin()
+	vm.PressButton()
+	fmt.Fprintf(out, "Now %q with %d items\n", vm.StateName(), vm.GetCount())
+
+	fmt.Fprintln(out, "\n3. Tampered or stale snapshots are rejected:")
+	for _, snap := range []VendingSnapshot{
+		{State: stateSoldOut, Count: 5},
+		{State: stateHasCoin, Count: 0},
+		{State: stateSold, Count: 1},
+		{State: "jammed", Count: 1},
+		{State: stateNoCoin, Count: -2},
+	} {
+		bad := &MemoryStateStore{}
+		bad.Save(snap)
+		if _, err := OpenVendingMachine(bad, 3); err != nil {
+			fmt.Fprintln(out, "Rejected:", err)
+		}
+	}
+}
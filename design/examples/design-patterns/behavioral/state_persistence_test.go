@@ -0,0 +1,143 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreVendingMachine(t *testing.T) {
+	tests := []struct {
+		snap       VendingSnapshot
+		wantReason string // empty when the snapshot is legal
+	}{
+		{VendingSnapshot{State: "no_coin", Count: 3}, ""},
+		{VendingSnapshot{State: "has_coin", Count: 1}, ""},
+		{VendingSnapshot{State: "sold_out", Count: 0}, ""},
+		{VendingSnapshot{State: "sold_out", Count: 5}, "a machine with items is not sold out"},
+		{VendingSnapshot{State: "no_coin", Count: 0}, "an empty machine is always sold out"},
+		{VendingSnapshot{State: "has_coin", Count: 0}, "an empty machine is always sold out"},
+		{VendingSnapshot{State: "sold", Count: 1}, "sold is transient and is never saved"},
+		{VendingSnapshot{State: "jammed", Count: 1}, "unknown state"},
+		{VendingSnapshot{State: "", Count: 1}, "unknown state"},
+		{VendingSnapshot{State: "no_coin", Count: -2}, "count cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q with %d", tt.snap.State, tt.snap.Count), func(t *testing.T) {
+			vm, err := RestoreVendingMachine(tt.snap)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("RestoreVendingMachine = %v", err)
+				}
+				if got := vm.Snapshot(); got != tt.snap {
+					t.Errorf("restored to %+v, want %+v", got, tt.snap)
+				}
+				return
+			}
+			var illegal *IllegalStateError
+			if !errors.As(err, &illegal) || illegal.Reason != tt.wantReason || illegal.Snapshot != tt.snap {
+				t.Errorf("RestoreVendingMachine = %v, want the reason %q", err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestFileStateStore(t *testing.T) {
+	dir := t.TempDir()
+	store := FileStateStore{Path: filepath.Join(dir, "machine.json")}
+
+	if _, err := store.Load(); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("Load before any Save = %v, want ErrNoSnapshot", err)
+	}
+	for _, snap := range []VendingSnapshot{{State: "no_coin", Count: 3}, {State: "has_coin", Count: 2}} {
+		if err := store.Save(snap); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Load(); err != nil || got != snap {
+			t.Errorf("Load = %+v, %v, want %+v", got, err, snap)
+		}
+	}
+	// The temporary file is renamed over the saved one, so nothing is left behind
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("the directory holds %d files, want only machine.json", len(entries))
+	}
+
+	t.Run("a corrupt file is an error, not a missing snapshot", func(t *testing.T) {
+		os.WriteFile(store.Path, []byte("{not json"), 0o644)
+		if _, err := store.Load(); err == nil || errors.Is(err, ErrNoSnapshot) {
+			t.Errorf("Load = %v, want a corrupt file error", err)
+		}
+	})
+}
+
+func TestPersistentVendingMachineSurvivesARestart(t *testing.T) {
+	buf := captureOutput(t)
+	store := FileStateStore{Path: filepath.Join(t.TempDir(), "machine.json")}
+
+	vm, err := OpenVendingMachine(store, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Load(); got != (VendingSnapshot{State: "no_coin", Count: 3}) {
+		t.Errorf("a new machine saved %+v", got)
+	}
+	vm.InsertCoin()
+	vm.PressButton()
+	vm.InsertCoin()
+
+	vm, err = OpenVendingMachine(store, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.StateName() != "has_coin" || vm.GetCount() != 2 {
+		t.Errorf("restored %q with %d items, want has_coin with 2", vm.StateName(), vm.GetCount())
+	}
+	vm.PressButton()
+	if got, _ := store.Load(); got != (VendingSnapshot{State: "no_coin", Count: 1}) {
+		t.Errorf("after the sale the store holds %+v", got)
+	}
+	buf.Reset()
+
+	t.Run("an illegal saved state is reported, not reset", func(t *testing.T) {
+		bad := &MemoryStateStore{}
+		bad.Save(VendingSnapshot{State: "sold", Count: 1})
+		var illegal *IllegalStateError
+		if _, err := OpenVendingMachine(bad, 3); !errors.As(err, &illegal) {
+			t.Errorf("OpenVendingMachine = %v, want an IllegalStateError", err)
+		}
+		if got, _ := bad.Load(); got.State != "sold" {
+			t.Errorf("the bad snapshot was overwritten with %+v", got)
+		}
+	})
+}
+
+func TestDemoStatePersistencePrintsEachStep(t *testing.T) {
+	buf := captureOutput(t)
+	DemoStatePersistence()
+	assertLines(t, buf,
+		"=== State Persistence Demo ===",
+		"1. First run, 3 items:",
+		"Coin inserted",
+		"Button pressed",
+		"Item dispensed",
+		"Coin inserted",
+		`Saved: {"state":"has_coin","count":2}`,
+		"2. Restart:",
+		`Restored "has_coin" with 2 items`,
+		"Button pressed",
+		"Item dispensed",
+		"Coin inserted",
+		"Button pressed",
+		"Item dispensed",
+		"Machine sold out",
+		`Now "sold_out" with 0 items`,
+		"3. Tampered or stale snapshots are rejected:",
+		`Rejected: illegal vending machine state "sold_out" with 5 items: a machine with items is not sold out`,
+		`Rejected: illegal vending machine state "has_coin" with 0 items: an empty machine is always sold out`,
+		`Rejected: illegal vending machine state "sold" with 1 items: sold is transient and is never saved`,
+		`Rejected: illegal vending machine state "jammed" with 1 items: unknown state`,
+		`Rejected: illegal vending machine state "no_coin" with -2 items: count cannot be negative`,
+	)
+}
@@ -0,0 +1,103 @@
+package behavioral
+
+import "testing"
+
+func TestVendingMachineTransitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		actions   func(vm *VendingMachine)
+		wantState State
+		wantCount int
+		wantLines []string
+	}{
+		{
+			name:      "a sale takes one item and waits for the next coin",
+			count:     2,
+			actions:   func(vm *VendingMachine) { vm.InsertCoin(); vm.PressButton() },
+			wantState: &NoCoinState{},
+			wantCount: 1,
+			wantLines: []string{"Coin inserted", "Button pressed", "Item dispensed"},
+		},
+		{
+			name:      "the last sale sells the machine out",
+			count:     1,
+			actions:   func(vm *VendingMachine) { vm.InsertCoin(); vm.PressButton() },
+			wantState: &SoldOutState{},
+			wantCount: 0,
+			wantLines: []string{"Coin inserted", "Button pressed", "Item dispensed", "Machine sold out"},
+		},
+		{
+			name:      "a coin can be ejected before pressing",
+			count:     1,
+			actions:   func(vm *VendingMachine) { vm.InsertCoin(); vm.EjectCoin() },
+			wantState: &NoCoinState{},
+			wantCount: 1,
+			wantLines: []string{"Coin inserted", "Coin ejected"},
+		},
+		{
+			name:      "pressing without a coin dispenses nothing",
+			count:     1,
+			actions:   func(vm *VendingMachine) { vm.PressButton() },
+			wantState: &NoCoinState{},
+			wantCount: 1,
+			wantLines: []string{"Insert coin first", "Pay first"},
+		},
+		{
+			name:      "a second coin is refused",
+			count:     1,
+			actions:   func(vm *VendingMachine) { vm.InsertCoin(); vm.InsertCoin() },
+			wantState: &HasCoinState{},
+			wantCount: 1,
+			wantLines: []string{"Coin inserted", "Coin already inserted"},
+		},
+		{
+			name:      "an empty machine starts sold out",
+			count:     0,
+			actions:   func(vm *VendingMachine) { vm.InsertCoin(); vm.PressButton() },
+			wantState: &SoldOutState{},
+			wantCount: 0,
+			wantLines: []string{"Machine sold out", "Machine sold out", "No items available"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureOutput(t)
+			vm := NewVendingMachine(tt.count)
+			tt.actions(vm)
+			if got, want := stateName(vm.currentState), stateName(tt.wantState); got != want {
+				t.Errorf("state = %s, want %s", got, want)
+			}
+			if vm.GetCount() != tt.wantCount {
+				t.Errorf("count = %d, want %d", vm.GetCount(), tt.wantCount)
+			}
+			assertLines(t, buf, tt.wantLines...)
+		})
+	}
+}
+
+func stateName(s State) string {
+	switch s.(type) {
+	case *NoCoinState:
+		return "NoCoin"
+	case *HasCoinState:
+		return "HasCoin"
+	case *SoldState:
+		return "Sold"
+	case *SoldOutState:
+		return "SoldOut"
+	}
+	return "unknown"
+}
+
+func TestDemoStateSellsOutAfterTwoItems(t *testing.T) {
+	buf := captureOutput(t)
+	DemoState()
+	assertLines(t, buf,
+		"=== State Pattern Demo ===",
+		"Items in machine: 2",
+		"Coin inserted", "Button pressed", "Item dispensed",
+		"Coin inserted", "Button pressed", "Item dispensed", "Machine sold out",
+		"Machine sold out", "Machine sold out", "No items available",
+	)
+}
@@ -0,0 +1,213 @@
+package behavioral
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Strategy with runtime selection - sorting algorithms.
+// No single sort is best for every input: insertion sort wins on small or
+// nearly sorted slices, quicksort on large random ones, and merge sort when equal
+// elements must keep their order. StrategySelector inspects the data and picks
+// the algorithm; callers only ever see SortStrategy.
+
+type SortStrategy[T any] interface {
+	Name() string
+	Sort(data []T, less func(a, b T) bool)
+}
+
+// InsertionSort is O(n²) in general but O(n) on sorted input, with no allocation
+type InsertionSort[T any] struct{}
+
+func (InsertionSort[T]) Name() string { return "insertion" }
+
+func (InsertionSort[T]) Sort(data []T, less func(a, b T) bool) {
+	for i := 1; i < len(data); i++ {
+		for j := i; j > 0 && less(data[j], data[j-1]); j-- {
+			data[j], data[j-1] = data[j-1], data[j]
+		}
+	}
+}
+
+// QuickSort is O(n log n) on average, in place, not stable
+type QuickSort[T any] struct{}
+
+func (QuickSort[T]) Name() string { return "quick" }
+
+func (q QuickSort[T]) Sort(data []T, less func(a, b T) bool) {
+	for len(data) > 1 {
+		p := partition(data, less)
+		// Recurse into the smaller side to bound stack depth
+		if p+1 < len(data)-p-1 {
+			q.Sort(data[:p+1], less)
+			data = data[p+1:]
+		} else {
+			q.Sort(data[p+1:], less)
+			data = data[:p+1]
+		}
+	}
+}
+
+// partition is Hoare's scheme around the middle element, which stays close to
+// the median on sorted and nearly sorted input. Afterwards data[:p+1] holds no
+// element greater than data[p+1:].
+func partition[T any](data []T, less func(a, b T) bool) int {
+	pivot := data[(len(data)-1)/2] // lower middle, so p never reaches the last index
+	i, j := -1, len(data)
+	for {
+		for i++; less(data[i], pivot); i++ {
+		}
+		for j--; less(pivot, data[j]); j-- {
+		}
+		if i >= j {
+			return j
+		}
+		data[i], data[j] = data[j], data[i]
+	}
+}
+
+// MergeSort is O(n log n) in every case and stable, at the cost of an n-sized buffer
+type MergeSort[T any] struct{}
+
+func (MergeSort[T]) Name() string { return "merge" }
+
+func (MergeSort[T]) Sort(data []T, less func(a, b T) bool) {
+	buf := make([]T, len(data))
+	for width := 1; width < len(data); width *= 2 {
+		for lo := 0; lo < len(data)-width; lo += 2 * width {
+			mid, hi := lo+width, min(lo+2*width, len(data))
+			copy(buf[lo:hi], data[lo:hi])
+			i, j := lo, mid
+			for k := lo; k < hi; k++ {
+				// Take from the right half only when strictly smaller, keeping equal elements in order
+				if j < hi && (i >= mid || less(buf[j], buf[i])) {
+					data[k] = buf[j]
+					j++
+				} else {
+					data[k] = buf[i]
+					i++
+				}
+			}
+		}
+	}
+}
+
+// StrategySelector picks a sort for a particular slice
+type StrategySelector[T any] struct {
+	SmallMax     int     // slices up to this length use insertion sort
+	NearlySorted float64 // at most this fraction of out-of-order neighbours also uses insertion sort
+	Stable       bool    // equal elements must keep their order
+}
+
+func DefaultStrategySelector[T any]() StrategySelector[T] {
+	return StrategySelector[T]{SmallMax: 32, NearlySorted: 0.01}
+}
+
+func (s StrategySelector[T]) Select(data []T, less func(a, b T) bool) SortStrategy[T] {
+	if len(data) <= s.SmallMax {
+		return InsertionSort[T]{}
+	}
+	descents := 0
+	for i := 1; i < len(data); i++ {
+		if less(data[i], data[i-1]) {
+			descents++
+		}
+	}
+	if float64(descents) <= s.NearlySorted*float64(len(data)) {
+		return InsertionSort[T]{}
+	}
+	if s.Stable {
+		return MergeSort[T]{}
+	}
+	return QuickSort[T]{}
+}
+
+// Sort sorts data with the selected strategy and returns its name
+func (s StrategySelector[T]) Sort(data []T, less func(a, b T) bool) string {
+	strategy := s.Select(data, less)
+	strategy.Sort(data, less)
+	return strategy.Name()
+}
+
+func isSorted[T any](data []T, less func(a, b T) bool) bool {
+	for i := 1; i < len(data); i++ {
+		if less(data[i], data[i-1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Datasets for comparing strategies
+
+func randomInts(n int, rng *rand.Rand) []int {
+	data := make([]int, n)
+	for i := range data {
+		data[i] = rng.Intn(n * 10)
+	}
+	return data
+}
+
+// nearlySortedInts is ascending with about 1 in 200 neighbours swapped, like a
+// log whose entries arrive slightly out of order
+func nearlySortedInts(n int, rng *rand.Rand) []int {
+	data := make([]int, n)
+	for i := range data {
+		data[i] = i
+	}
+	for k := 0; k < n/200+1; k++ {
+		i := rng.Intn(n - 1)
+		data[i], data[i+1] = data[i+1], data[i]
+	}
+	return data
+}
+
+func reversedInts(n int, _ *rand.Rand) []int {
+	data := make([]int, n)
+	for i := range data {
+		data[i] = n - i
+	}
+	return data
+}
+
+func DemoSortStrategies() {
+	fmt.Fprintln(out, "=== Strategy Pattern Demo: Sorting ===")
+	fmt.Fprintln(out)
+
+	less := func(a, b int) bool { return a < b }
+	selector := DefaultStrategySelector[int]()
+	rng := rand.New(rand.NewSource(1))
+
+	datasets := []struct {
+		name string
+		gen  func(int, *rand.Rand) []int
+	}{
+		{"random", randomInts},
+		{"nearly-sorted", nearlySortedInts},
+		{"reversed", reversedInts},
+	}
+
+	fmt.Fprintln(out, "1. The selector's pick per input:")
+	for _, size := range []int{16, 1000, 20000} {
+		for _, ds := range datasets {
+			data := ds.gen(size, rng)
+			picked := selector.Sort(data, less)
+			fmt.Fprintf(out, "  %-14s n=%-6d %-10s sorted: %v\n", ds.name, size, picked, isSorted(data, less))
+		}
+	}
+	fmt.Fprintln(out, "BenchmarkSort in strategy_sort_test.go times every strategy on these inputs.")
+
+	fmt.Fprintln(out, "\n2. Stable selection keeps equal keys in arrival order:")
+	type order struct {
+		ID       int
+		Priority int
+	}
+	orders := []order{{1, 2}, {2, 1}, {3, 2}, {4, 1}, {5, 2}}
+	for i := 6; i <= 40; i++ {
+		orders = append(orders, order{i, i % 3})
+	}
+	byPriority := func(a, b order) bool { return a.Priority < b.Priority }
+	stable := StrategySelector[order]{SmallMax: 8, Stable: true}
+	fmt.Fprintf(out, "Selected: %s\n", stable.Sort(orders, byPriority))
+	fmt.Fprintln(out, "First five:", orders[:5])
+}
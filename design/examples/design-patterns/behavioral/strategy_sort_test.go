@@ -0,0 +1,126 @@
+package behavioral
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var sortDatasets = []struct {
+	name string
+	gen  func(int, *rand.Rand) []int
+}{
+	{"random", randomInts},
+	{"nearly-sorted", nearlySortedInts},
+	{"reversed", reversedInts},
+}
+
+func sortStrategies() []SortStrategy[int] {
+	return []SortStrategy[int]{InsertionSort[int]{}, QuickSort[int]{}, MergeSort[int]{}}
+}
+
+func intLess(a, b int) bool { return a < b }
+
+func TestSortStrategiesSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{0, 1, 2, 3, 17, 500} {
+		for _, ds := range sortDatasets {
+			if size < 2 && ds.name == "nearly-sorted" {
+				continue // nearlySortedInts swaps neighbours and needs at least two
+			}
+			input := ds.gen(size, rng)
+			for _, s := range sortStrategies() {
+				data := append([]int(nil), input...)
+				s.Sort(data, intLess)
+				if !isSorted(data, intLess) {
+					t.Errorf("%s on %s n=%d: %v", s.Name(), ds.name, size, data)
+				}
+			}
+		}
+	}
+}
+
+func TestStableStrategiesKeepEqualKeysInOrder(t *testing.T) {
+	type item struct{ key, seq int }
+	rng := rand.New(rand.NewSource(2))
+	input := make([]item, 300)
+	for i := range input {
+		input[i] = item{key: rng.Intn(5), seq: i}
+	}
+	byKey := func(a, b item) bool { return a.key < b.key }
+	for _, s := range []SortStrategy[item]{InsertionSort[item]{}, MergeSort[item]{}} {
+		data := append([]item(nil), input...)
+		s.Sort(data, byKey)
+		for i := 1; i < len(data); i++ {
+			if data[i].key == data[i-1].key && data[i].seq < data[i-1].seq {
+				t.Fatalf("%s reordered equal keys at %d: %v, %v", s.Name(), i, data[i-1], data[i])
+			}
+		}
+	}
+}
+
+func TestStrategySelectorPicks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name   string
+		data   []int
+		stable bool
+		want   string
+	}{
+		{"small", randomInts(16, rng), false, "insertion"},
+		{"nearly sorted", nearlySortedInts(20000, rng), false, "insertion"},
+		{"large random", randomInts(1000, rng), false, "quick"},
+		{"large random, stable", randomInts(1000, rng), true, "merge"},
+		{"reversed", reversedInts(1000, rng), false, "quick"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := DefaultStrategySelector[int]()
+			selector.Stable = tt.stable
+			if got := selector.Sort(tt.data, intLess); got != tt.want {
+				t.Errorf("picked %s, want %s", got, tt.want)
+			}
+			if !isSorted(tt.data, intLess) {
+				t.Error("data not sorted")
+			}
+		})
+	}
+}
+
+// BenchmarkSort times every strategy, and the selector, on each dataset and
+// size. Each op sorts a fresh copy of the input; the copy is O(n) and is
+// included in the time. Insertion sort is skipped where it is O(n²) and large.
+func BenchmarkSort(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{16, 1000, 20000} {
+		for _, ds := range sortDatasets {
+			input := ds.gen(size, rng)
+			work := make([]int, size)
+			strategies := sortStrategies()
+			selector := DefaultStrategySelector[int]()
+			for _, s := range append(strategies, selectorStrategy{selector}) {
+				if s.Name() == "insertion" && size > 1000 && ds.name != "nearly-sorted" {
+					continue
+				}
+				b.Run(fmt.Sprintf("%s/%s/n=%d", s.Name(), ds.name, size), func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						copy(work, input)
+						s.Sort(work, intLess)
+					}
+				})
+			}
+		}
+	}
+}
+
+// selectorStrategy lets the selector be benchmarked next to the fixed strategies
+type selectorStrategy struct {
+	StrategySelector[int]
+}
+
+func (selectorStrategy) Name() string { return "selector" }
+
+func (s selectorStrategy) Sort(data []int, less func(a, b int) bool) {
+	s.StrategySelector.Sort(data, less)
+}
@@ -1,90 +1,275 @@
 package behavioral
 
-import "fmt"
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
 // Template Method Pattern - Defines skeleton of algorithm, deferring some steps to subclasses.
+// BaseProcessor fixes the order read -> process -> write. CSVProcessor and
+// JSONProcessor supply reading and writing their file format; both inherit the
+// processing step, which filters rows and renames fields as configured.
+
+// Record is one row: CSV values are strings, JSON values keep their JSON types
+type Record map[string]interface{}
 
 type DataProcessor interface {
-	ReadData() string
-	ProcessData(data string) string
-	WriteData(data string)
-	Process()
+	ReadData() ([]Record, error)
+	ProcessData(records []Record) []Record
+	WriteData(records []Record) error
+	Process() error
+}
+
+// Transform configures the shared processing step
+type Transform struct {
+	Keep   func(Record) bool // rows it rejects are dropped; nil keeps all
+	Rename map[string]string // old field name -> new
+}
+
+// FieldEquals keeps rows whose field prints as value
+func FieldEquals(field, value string) func(Record) bool {
+	return func(r Record) bool { return fmt.Sprint(r[field]) == value }
+}
+
+func (t Transform) renamed(field string) string {
+	if to, ok := t.Rename[field]; ok {
+		return to
+	}
+	return field
 }
 
 type BaseProcessor struct {
 	processor DataProcessor
+	transform Transform
 }
 
-func (b *BaseProcessor) Process() {
-	data := b.processor.ReadData()
-	processed := b.processor.ProcessData(data)
-	b.processor.WriteData(processed)
+func (b *BaseProcessor) Process() error {
+	records, err := b.processor.ReadData()
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	processed := b.processor.ProcessData(records)
+	if err := b.processor.WriteData(processed); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// ProcessData is the default processing step: filter, then rename
+func (b *BaseProcessor) ProcessData(records []Record) []Record {
+	kept := make([]Record, 0, len(records))
+	for _, r := range records {
+		if b.transform.Keep != nil && !b.transform.Keep(r) {
+			continue
+		}
+		row := make(Record, len(r))
+		for field, v := range r {
+			row[b.transform.renamed(field)] = v
+		}
+		kept = append(kept, row)
+	}
+	fmt.Fprintf(out, "Processed: kept %d of %d rows, renamed %d field(s)\n", len(kept), len(records), len(b.transform.Rename))
+	return kept
 }
 
 type CSVProcessor struct {
 	BaseProcessor
-	filename string
+	input, output string
+	columns       []string // header of the input, to keep column order on output
 }
 
-func NewCSVProcessor(filename string) *CSVProcessor {
-	p := &CSVProcessor{filename: filename}
-	p.BaseProcessor.processor = p
+func NewCSVProcessor(input, output string, transform Transform) *CSVProcessor {
+	p := &CSVProcessor{input: input, output: output}
+	p.BaseProcessor = BaseProcessor{processor: p, transform: transform}
 	return p
 }
 
-func (c *CSVProcessor) ReadData() string {
-	fmt.Printf("Reading CSV file: %s\n", c.filename)
-	return "csv_data"
-}
+func (c *CSVProcessor) ReadData() ([]Record, error) {
+	f, err := os.Open(c.input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-func (c *CSVProcessor) ProcessData(data string) string {
-	fmt.Printf("Processing CSV data: %s\n", data)
-	return "processed_" + data
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s: no header row", c.input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.columns = header
+	var records []Record
+	for {
+		row, err := r.Read() // rows with a different field count are an error
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec := make(Record, len(header))
+		for i, field := range header {
+			rec[field] = row[i]
+		}
+		records = append(records, rec)
+	}
+	fmt.Fprintf(out, "Read %d CSV rows from %s\n", len(records), filepath.Base(c.input))
+	return records, nil
 }
 
-func (c *CSVProcessor) WriteData(data string) {
-	fmt.Printf("Writing CSV result: %s\n", data)
+func (c *CSVProcessor) WriteData(records []Record) error {
+	columns := make([]string, len(c.columns))
+	for i, field := range c.columns {
+		columns[i] = c.transform.renamed(field)
+	}
+	f, err := os.Create(c.output)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write(columns)
+	for _, r := range records {
+		row := make([]string, len(columns))
+		for i, field := range columns {
+			row[i] = fmt.Sprint(r[field])
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %d CSV rows to %s\n", len(records), filepath.Base(c.output))
+	return nil
 }
 
-func (c *CSVProcessor) Process() {
-	c.BaseProcessor.Process()
+func (c *CSVProcessor) Process() error {
+	return c.BaseProcessor.Process()
 }
 
 type JSONProcessor struct {
 	BaseProcessor
-	filename string
+	input, output string
 }
 
-func NewJSONProcessor(filename string) *JSONProcessor {
-	p := &JSONProcessor{filename: filename}
-	p.BaseProcessor.processor = p
+func NewJSONProcessor(input, output string, transform Transform) *JSONProcessor {
+	p := &JSONProcessor{input: input, output: output}
+	p.BaseProcessor = BaseProcessor{processor: p, transform: transform}
 	return p
 }
 
-func (j *JSONProcessor) ReadData() string {
-	fmt.Printf("Reading JSON file: %s\n", j.filename)
-	return "json_data"
+// ReadData expects an array of objects
+func (j *JSONProcessor) ReadData() ([]Record, error) {
+	f, err := os.Open(j.input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber() // keep 12.50 as written instead of rounding through float64
+	var records []Record
+	if err := dec.Decode(&records); err != nil {
+		return nil, fmt.Errorf("%s: %w", j.input, err)
+	}
+	fmt.Fprintf(out, "Read %d JSON records from %s\n", len(records), filepath.Base(j.input))
+	return records, nil
 }
 
-func (j *JSONProcessor) ProcessData(data string) string {
-	fmt.Printf("Processing JSON data: %s\n", data)
-	return "processed_" + data
+func (j *JSONProcessor) WriteData(records []Record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(j.output, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %d JSON records to %s\n", len(records), filepath.Base(j.output))
+	return nil
 }
 
-func (j *JSONProcessor) WriteData(data string) {
-	fmt.Printf("Writing JSON result: %s\n", data)
+func (j *JSONProcessor) Process() error {
+	return j.BaseProcessor.Process()
 }
 
-func (j *JSONProcessor) Process() {
-	j.BaseProcessor.Process()
+// templateFixtures writes the demo's input files into dir
+func templateFixtures(dir string) error {
+	files := map[string]string{
+		"customers.csv": "id,name,status,country\n" +
+			"1,Ada,active,UK\n" +
+			"2,Linus,inactive,FI\n" +
+			"3,Grace,active,US\n" +
+			"4,\"Hopper, Jr\",active,US\n",
+		"orders.json": `[
+  {"order_id": 1, "customer": "Ada", "total": 12.50, "status": "paid"},
+  {"order_id": 2, "customer": "Grace", "total": 99.90, "status": "pending"},
+  {"order_id": 3, "customer": "Linus", "total": 5, "status": "paid"}
+]`,
+		"ragged.csv": "id,name\n1,Ada\n2\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func DemoTemplateMethod() {
-	fmt.Println("=== Template Method Pattern Demo ===\n")
-	fmt.Println("Processing CSV:")
-	csvProcessor := NewCSVProcessor("data.csv")
-	csvProcessor.Process()
-	fmt.Println("\nProcessing JSON:")
-	jsonProcessor := NewJSONProcessor("data.json")
-	jsonProcessor.Process()
+	fmt.Fprint(out, "=== Template Method Pattern Demo ===\n\n")
+
+	dir, err := os.MkdirTemp("", "template-method")
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err := templateFixtures(dir); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	fmt.Fprintln(out, "Processing CSV (active customers, name -> full_name):")
+	csvProcessor := NewCSVProcessor(path("customers.csv"), path("active.csv"), Transform{
+		Keep:   FieldEquals("status", "active"),
+		Rename: map[string]string{"name": "full_name"},
+	})
+	if err := csvProcessor.Process(); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+	}
+	written, _ := os.ReadFile(path("active.csv"))
+	fmt.Fprintf(out, "%s", written)
+
+	fmt.Fprintln(out, "\nProcessing JSON (paid orders, total -> amount):")
+	jsonProcessor := NewJSONProcessor(path("orders.json"), path("paid.json"), Transform{
+		Keep:   FieldEquals("status", "paid"),
+		Rename: map[string]string{"total": "amount"},
+	})
+	if err := jsonProcessor.Process(); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+	}
+	written, _ = os.ReadFile(path("paid.json"))
+	fmt.Fprintf(out, "%s", written)
+
+	fmt.Fprintln(out, "\nFailures stop the skeleton before anything is written:")
+	for _, p := range []DataProcessor{
+		NewCSVProcessor(path("missing.csv"), path("never.csv"), Transform{}),
+		NewCSVProcessor(path("ragged.csv"), path("never.csv"), Transform{}),
+		NewJSONProcessor(path("customers.csv"), path("never.json"), Transform{}),
+	} {
+		err := p.Process()
+		fmt.Fprintln(out, "Error:", strings.ReplaceAll(fmt.Sprint(err), dir+string(filepath.Separator), ""))
+	}
 }
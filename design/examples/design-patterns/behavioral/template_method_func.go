@@ -0,0 +1,139 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Template Method - functional variant.
+// template_method.go fixes the skeleton in BaseProcessor and lets each processor
+// fill in the steps by embedding it and wiring itself back in as p.processor.
+// Here the skeleton is a plain function: the required steps are arguments and the
+// optional hooks are functional options with sensible defaults, so a variant only
+// overrides what it needs and nothing has to be embedded or back-referenced.
+//
+// Embedding suits a family of processors sharing state and many steps; functions
+// suit one-off pipelines and hooks that are decided at the call site.
+
+var ErrEmptyData = errors.New("no data to process")
+
+type pipelineHooks struct {
+	preProcess  func(string) string
+	validate    func(string) error
+	postProcess func(string) string
+}
+
+// PipelineHook overrides one optional step of RunPipeline
+type PipelineHook func(*pipelineHooks)
+
+// WithPreProcess replaces the default whitespace trimming
+func WithPreProcess(fn func(string) string) PipelineHook {
+	return func(h *pipelineHooks) { h.preProcess = fn }
+}
+
+// WithValidate replaces the default non-empty check
+func WithValidate(fn func(string) error) PipelineHook {
+	return func(h *pipelineHooks) { h.validate = fn }
+}
+
+// WithPostProcess transforms the result before it is written (default: unchanged)
+func WithPostProcess(fn func(string) string) PipelineHook {
+	return func(h *pipelineHooks) { h.postProcess = fn }
+}
+
+// RunPipeline is the template method: read -> pre-process -> validate ->
+// process -> post-process -> write. The order never changes; only the steps do.
+func RunPipeline(
+	read func() (string, error),
+	process func(string) string,
+	write func(string) error,
+	hooks ...PipelineHook,
+) error {
+	h := pipelineHooks{
+		preProcess: strings.TrimSpace,
+		validate: func(data string) error {
+			if data == "" {
+				return ErrEmptyData
+			}
+			return nil
+		},
+		postProcess: func(data string) string { return data },
+	}
+	for _, hook := range hooks {
+		hook(&h)
+	}
+
+	data, err := read()
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	data = h.preProcess(data)
+	if err := h.validate(data); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+	result := h.postProcess(process(data))
+	if err := write(result); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func DemoFunctionalTemplateMethod() {
+	fmt.Fprintln(out, "=== Functional Template Method Demo ===")
+	fmt.Fprintln(out)
+
+	readFrom := func(filename, content string) func() (string, error) {
+		return func() (string, error) {
+			fmt.Fprintf(out, "Reading %s\n", filename)
+			return content, nil
+		}
+	}
+	process := func(data string) string { return "processed_" + data }
+	write := func(data string) error {
+		fmt.Fprintf(out, "Writing result: %s\n", data)
+		return nil
+	}
+
+	fmt.Fprintln(out, "CSV with default hooks:")
+	if err := RunPipeline(readFrom("data.csv", "  csv_data\n"), process, write); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+	}
+
+	fmt.Fprintln(out, "\nJSON with custom validate and post-process:")
+	err := RunPipeline(readFrom("data.json", `{"id":1}`), process, write,
+		WithValidate(func(data string) error {
+			if !strings.HasPrefix(data, "{") {
+				return errors.New("not a JSON object")
+			}
+			return nil
+		}),
+		WithPostProcess(strings.ToUpper),
+	)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+	}
+
+	fmt.Fprintln(out, "\nEmpty input stops before processing:")
+	if err := RunPipeline(readFrom("empty.csv", "   "), process, write); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+	}
+
+	fmt.Fprintln(out, "\nSame skeleton, embedding-based (template_method.go):")
+	dir, err := os.MkdirTemp("", "template-method")
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err := templateFixtures(dir); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return
+	}
+	p := NewCSVProcessor(filepath.Join(dir, "customers.csv"), filepath.Join(dir, "out.csv"), Transform{})
+	if err := p.Process(); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+	}
+}
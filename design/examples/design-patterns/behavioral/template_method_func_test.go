@@ -0,0 +1,149 @@
+package behavioral
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pipeline records the order in which RunPipeline calls its steps
+type pipeline struct {
+	calls   []string
+	input   string
+	readErr error
+	written string
+}
+
+func (p *pipeline) read() (string, error) {
+	p.calls = append(p.calls, "read")
+	return p.input, p.readErr
+}
+
+func (p *pipeline) process(data string) string {
+	p.calls = append(p.calls, "process")
+	return "processed_" + data
+}
+
+func (p *pipeline) write(data string) error {
+	p.calls = append(p.calls, "write")
+	p.written = data
+	return nil
+}
+
+func TestRunPipeline(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name      string
+		input     string
+		readErr   error
+		hooks     func(p *pipeline) []PipelineHook
+		wantCalls []string
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "the defaults trim the input and pass the result through",
+			input:     "  csv_data\n",
+			wantCalls: []string{"read", "process", "write"},
+			want:      "processed_csv_data",
+		},
+		{
+			name:  "every hook runs in its fixed place",
+			input: "data",
+			hooks: func(p *pipeline) []PipelineHook {
+				return []PipelineHook{
+					// Passed in a different order than they run
+					WithPostProcess(func(s string) string { p.calls = append(p.calls, "post"); return strings.ToUpper(s) }),
+					WithValidate(func(string) error { p.calls = append(p.calls, "validate"); return nil }),
+					WithPreProcess(func(s string) string { p.calls = append(p.calls, "pre"); return s + "!" }),
+				}
+			},
+			wantCalls: []string{"read", "pre", "validate", "process", "post", "write"},
+			want:      "PROCESSED_DATA!",
+		},
+		{
+			name:      "blank input stops at the default validation",
+			input:     "   ",
+			wantCalls: []string{"read"},
+			wantErr:   ErrEmptyData,
+		},
+		{
+			name:  "a custom validation replaces the default",
+			input: `{"id":1}`,
+			hooks: func(*pipeline) []PipelineHook {
+				return []PipelineHook{WithValidate(func(data string) error {
+					if !strings.HasPrefix(data, "[") {
+						return boom
+					}
+					return nil
+				})}
+			},
+			wantCalls: []string{"read"},
+			wantErr:   boom,
+		},
+		{
+			name:      "a failed read stops everything",
+			readErr:   boom,
+			wantCalls: []string{"read"},
+			wantErr:   boom,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pipeline{input: tt.input, readErr: tt.readErr}
+			var hooks []PipelineHook
+			if tt.hooks != nil {
+				hooks = tt.hooks(p)
+			}
+			err := RunPipeline(p.read, p.process, p.write, hooks...)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(p.calls, tt.wantCalls) {
+				t.Errorf("calls %v, want %v", p.calls, tt.wantCalls)
+			}
+			if p.written != tt.want {
+				t.Errorf("wrote %q, want %q", p.written, tt.want)
+			}
+		})
+	}
+
+	t.Run("errors name the step that failed", func(t *testing.T) {
+		p := &pipeline{input: "data"}
+		failWrite := func(string) error { return boom }
+		for _, tc := range []struct {
+			err  error
+			want string
+		}{
+			{RunPipeline(func() (string, error) { return "", boom }, p.process, p.write), "read: boom"},
+			{RunPipeline(p.read, p.process, p.write, WithValidate(func(string) error { return boom })), "validate: boom"},
+			{RunPipeline(p.read, p.process, failWrite), "write: boom"},
+		} {
+			if tc.err == nil || tc.err.Error() != tc.want {
+				t.Errorf("err = %v, want %q", tc.err, tc.want)
+			}
+		}
+	})
+}
+
+func TestDemoFunctionalTemplateMethodPrintsEachRun(t *testing.T) {
+	buf := captureOutput(t)
+	DemoFunctionalTemplateMethod()
+	assertLines(t, buf,
+		"=== Functional Template Method Demo ===",
+		"CSV with default hooks:",
+		"Reading data.csv",
+		"Writing result: processed_csv_data",
+		"JSON with custom validate and post-process:",
+		"Reading data.json",
+		`Writing result: PROCESSED_{"ID":1}`,
+		"Empty input stops before processing:",
+		"Reading empty.csv",
+		"Error: validate: no data to process",
+		"Same skeleton, embedding-based (template_method.go):",
+		"Read 4 CSV rows from customers.csv",
+		"Processed: kept 4 of 4 rows, renamed 0 field(s)",
+		"Wrote 4 CSV rows to out.csv",
+	)
+}
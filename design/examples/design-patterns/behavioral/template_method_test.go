@@ -0,0 +1,245 @@
+package behavioral
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixture writes content to name in dir and returns its path
+func fixture(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const customersCSV = "id,name,status,country\n" +
+	"1,Ada,active,UK\n" +
+	"2,Linus,inactive,FI\n" +
+	"3,Grace,active,US\n" +
+	"4,\"Hopper, Jr\",active,US\n"
+
+func TestCSVProcessor(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform Transform
+		want      string
+	}{
+		{
+			name:      "no transform copies the rows",
+			transform: Transform{},
+			want:      customersCSV,
+		},
+		{
+			name:      "rows the filter rejects are dropped",
+			transform: Transform{Keep: FieldEquals("country", "US")},
+			want:      "id,name,status,country\n3,Grace,active,US\n4,\"Hopper, Jr\",active,US\n",
+		},
+		{
+			name:      "renamed columns keep their place",
+			transform: Transform{Rename: map[string]string{"name": "full_name", "id": "customer_id"}},
+			want: "customer_id,full_name,status,country\n" +
+				"1,Ada,active,UK\n2,Linus,inactive,FI\n3,Grace,active,US\n4,\"Hopper, Jr\",active,US\n",
+		},
+		{
+			name: "the filter sees the original field names",
+			transform: Transform{
+				Keep:   FieldEquals("status", "active"),
+				Rename: map[string]string{"status": "state"},
+			},
+			want: "id,name,state,country\n1,Ada,active,UK\n3,Grace,active,US\n4,\"Hopper, Jr\",active,US\n",
+		},
+		{
+			name:      "a filter that rejects everything leaves the header",
+			transform: Transform{Keep: FieldEquals("country", "DE")},
+			want:      "id,name,status,country\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureOutput(t)
+			dir := t.TempDir()
+			output := filepath.Join(dir, "out.csv")
+			p := NewCSVProcessor(fixture(t, dir, "customers.csv", customersCSV), output, tt.transform)
+			if err := p.Process(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, output); got != tt.want {
+				t.Errorf("wrote:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONProcessor(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	input := fixture(t, dir, "orders.json", `[
+  {"order_id": 1, "customer": "Ada", "total": 12.50, "status": "paid"},
+  {"order_id": 2, "customer": "Grace", "total": 99.90, "status": "pending"},
+  {"order_id": 3, "customer": "Linus", "total": 5, "status": "paid"}
+]`)
+	output := filepath.Join(dir, "paid.json")
+	p := NewJSONProcessor(input, output, Transform{
+		Keep:   FieldEquals("status", "paid"),
+		Rename: map[string]string{"total": "amount"},
+	})
+	if err := p.Process(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[
+  {
+    "amount": 12.50,
+    "customer": "Ada",
+    "order_id": 1,
+    "status": "paid"
+  },
+  {
+    "amount": 5,
+    "customer": "Linus",
+    "order_id": 3,
+    "status": "paid"
+  }
+]
+`
+	// Numbers are written as they were read, 12.50 included
+	if got := readFile(t, output); got != want {
+		t.Errorf("wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTemplateMethodStopsAtTheFailingStep(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		p       func(output string) DataProcessor
+		wantErr string
+	}{
+		{
+			name: "a missing input",
+			p: func(output string) DataProcessor {
+				return NewCSVProcessor(filepath.Join(dir, "missing.csv"), output, Transform{})
+			},
+			wantErr: "read: open ",
+		},
+		{
+			name: "an empty CSV has no header",
+			p: func(output string) DataProcessor {
+				return NewCSVProcessor(fixture(t, dir, "empty.csv", ""), output, Transform{})
+			},
+			wantErr: "no header row",
+		},
+		{
+			name: "a ragged CSV row",
+			p: func(output string) DataProcessor {
+				return NewCSVProcessor(fixture(t, dir, "ragged.csv", "id,name\n1,Ada\n2\n"), output, Transform{})
+			},
+			wantErr: "read: record on line 3: wrong number of fields",
+		},
+		{
+			name: "JSON that is not an array of objects",
+			p: func(output string) DataProcessor {
+				return NewJSONProcessor(fixture(t, dir, "object.json", `{"id": 1}`), output, Transform{})
+			},
+			wantErr: "read: ",
+		},
+		{
+			name: "a CSV file given to the JSON processor",
+			p: func(output string) DataProcessor {
+				return NewJSONProcessor(fixture(t, dir, "customers.csv", customersCSV), output, Transform{})
+			},
+			wantErr: "invalid character 'i'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureOutput(t)
+			output := filepath.Join(dir, "never")
+			err := tt.p(output).Process()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Process = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(output); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("a failed read still wrote %s", output)
+			}
+		})
+	}
+
+	t.Run("a failed write is reported as such", func(t *testing.T) {
+		captureOutput(t)
+		p := NewCSVProcessor(fixture(t, dir, "ok.csv", customersCSV), filepath.Join(dir, "no-such-dir", "out.csv"), Transform{})
+		if err := p.Process(); err == nil || !strings.HasPrefix(err.Error(), "write: ") {
+			t.Errorf("Process = %v, want a write error", err)
+		}
+	})
+}
+
+func TestBaseProcessorRunsTheStepsInOrder(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	var steps []string
+	p := NewCSVProcessor(fixture(t, dir, "in.csv", "id\n1\n2\n"), filepath.Join(dir, "out.csv"), Transform{
+		Keep: func(r Record) bool { steps = append(steps, "keep "+r["id"].(string)); return true },
+	})
+	if err := p.Process(); err != nil {
+		t.Fatal(err)
+	}
+	// The CSV processor inherits the shared processing step
+	if want := []string{"keep 1", "keep 2"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps %v, want %v", steps, want)
+	}
+}
+
+func TestDemoTemplateMethodPrintsEachRun(t *testing.T) {
+	buf := captureOutput(t)
+	DemoTemplateMethod()
+	assertLines(t, buf,
+		"=== Template Method Pattern Demo ===",
+		"Processing CSV (active customers, name -> full_name):",
+		"Read 4 CSV rows from customers.csv",
+		"Processed: kept 3 of 4 rows, renamed 1 field(s)",
+		"Wrote 3 CSV rows to active.csv",
+		"id,full_name,status,country",
+		"1,Ada,active,UK",
+		"3,Grace,active,US",
+		`4,"Hopper, Jr",active,US`,
+		"Processing JSON (paid orders, total -> amount):",
+		"Read 3 JSON records from orders.json",
+		"Processed: kept 2 of 3 rows, renamed 1 field(s)",
+		"Wrote 2 JSON records to paid.json",
+		"[",
+		"  {",
+		`    "amount": 12.50,`,
+		`    "customer": "Ada",`,
+		`    "order_id": 1,`,
+		`    "status": "paid"`,
+		"  },",
+		"  {",
+		`    "amount": 5,`,
+		`    "customer": "Linus",`,
+		`    "order_id": 3,`,
+		`    "status": "paid"`,
+		"  }",
+		"]",
+		"Failures stop the skeleton before anything is written:",
+		"Error: read: open missing.csv: no such file or directory",
+		"Error: read: record on line 3: wrong number of fields",
+		"Error: read: customers.csv: invalid character 'i' looking for beginning of value",
+	)
+}
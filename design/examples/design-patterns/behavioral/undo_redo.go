@@ -0,0 +1,232 @@
+package behavioral
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Undo/Redo combining Command and Memento.
+// Every edit is a command that can be replayed, and most can revert themselves,
+// which makes stepping back one edit cheap. Every N commands the manager also
+// saves a Memento checkpoint. Jumping many versions at once, or undoing an edit
+// that cannot be inverted, restores the nearest earlier checkpoint and replays
+// the commands after it instead.
+
+var ErrNoSuchVersion = errors.New("undo: no such version")
+
+// EditCommand changes an Editor's content. Apply must be deterministic so that
+// replaying from a checkpoint reproduces the same text.
+type EditCommand interface {
+	Apply(e *Editor)
+}
+
+// ReversibleEdit is an EditCommand that can undo itself without a snapshot
+type ReversibleEdit interface {
+	EditCommand
+	Revert(e *Editor)
+}
+
+type InsertText struct {
+	Pos  int
+	Text string
+
+	at int // Pos clamped to the text by Apply
+}
+
+func (c *InsertText) Apply(e *Editor) {
+	c.at = clampPos(c.Pos, e.content)
+	e.content = e.content[:c.at] + c.Text + e.content[c.at:]
+}
+
+func (c *InsertText) Revert(e *Editor) {
+	e.content = e.content[:c.at] + e.content[c.at+len(c.Text):]
+}
+
+type DeleteText struct {
+	Pos    int
+	Length int
+
+	at      int    // captured by Apply so Revert can put
+	removed string // the deleted text back
+}
+
+func (c *DeleteText) Apply(e *Editor) {
+	c.at = clampPos(c.Pos, e.content)
+	end := clampPos(c.at+c.Length, e.content)
+	c.removed = e.content[c.at:end]
+	e.content = e.content[:c.at] + e.content[end:]
+}
+
+func (c *DeleteText) Revert(e *Editor) {
+	e.content = e.content[:c.at] + c.removed + e.content[c.at:]
+}
+
+// CollapseSpaces trims the text and squeezes whitespace runs into one space.
+// The original spacing is lost, so undoing it needs a checkpoint.
+type CollapseSpaces struct{}
+
+func (c *CollapseSpaces) Apply(e *Editor) {
+	e.content = strings.Join(strings.Fields(e.content), " ")
+}
+
+func clampPos(pos int, s string) int {
+	if pos < 0 {
+		return 0
+	}
+	if pos > len(s) {
+		return len(s)
+	}
+	return pos
+}
+
+// UndoStats counts how undo and navigation were carried out
+type UndoStats struct {
+	Reverts  int // commands undone by their own Revert
+	Restores int // checkpoints restored
+	Replayed int // commands re-applied after a checkpoint
+}
+
+// UndoManager records commands applied to an Editor. Version n means the first
+// n commands of the log are applied; commands after it can be redone.
+type UndoManager struct {
+	editor      *Editor
+	every       int
+	log         []EditCommand
+	version     int
+	checkpoints map[int]*Memento
+	stats       UndoStats
+}
+
+// NewUndoManager checkpoints the editor's current content as version 0 and
+// then after every `every` commands
+func NewUndoManager(editor *Editor, every int) *UndoManager {
+	if every < 1 {
+		every = 1
+	}
+	return &UndoManager{
+		editor:      editor,
+		every:       every,
+		checkpoints: map[int]*Memento{0: editor.Save()},
+	}
+}
+
+// Execute applies cmd and discards any commands that could have been redone
+func (m *UndoManager) Execute(cmd EditCommand) {
+	for v := range m.checkpoints {
+		if v > m.version {
+			delete(m.checkpoints, v)
+		}
+	}
+	m.log = m.log[:m.version]
+
+	cmd.Apply(m.editor)
+	m.log = append(m.log, cmd)
+	m.version++
+	if m.version%m.every == 0 {
+		m.checkpoints[m.version] = m.editor.Save()
+	}
+}
+
+// Undo steps back one command. It returns false when there is nothing to undo.
+func (m *UndoManager) Undo() bool {
+	if m.version == 0 {
+		return false
+	}
+	if r, ok := m.log[m.version-1].(ReversibleEdit); ok {
+		r.Revert(m.editor)
+		m.stats.Reverts++
+		m.version--
+		return true
+	}
+	m.restore(m.version - 1)
+	return true
+}
+
+// Redo re-applies the next command. It returns false when there is nothing to redo.
+func (m *UndoManager) Redo() bool {
+	if m.version == len(m.log) {
+		return false
+	}
+	m.log[m.version].Apply(m.editor)
+	m.version++
+	return true
+}
+
+// GoTo moves to any recorded version via the nearest checkpoint at or before it
+func (m *UndoManager) GoTo(version int) error {
+	if version < 0 || version > len(m.log) {
+		return fmt.Errorf("%w: %d (have 0..%d)", ErrNoSuchVersion, version, len(m.log))
+	}
+	m.restore(version)
+	return nil
+}
+
+func (m *UndoManager) restore(version int) {
+	from := version - version%m.every
+	for m.checkpoints[from] == nil {
+		// Checkpoints past the last Execute were dropped; fall back to an earlier one
+		from -= m.every
+	}
+	m.editor.Restore(m.checkpoints[from])
+	m.stats.Restores++
+	for _, cmd := range m.log[from:version] {
+		cmd.Apply(m.editor)
+		m.stats.Replayed++
+	}
+	m.version = version
+}
+
+func (m *UndoManager) Version() int { return m.version }
+
+func (m *UndoManager) Len() int { return len(m.log) }
+
+func (m *UndoManager) Checkpoints() int { return len(m.checkpoints) }
+
+func (m *UndoManager) Stats() UndoStats { return m.stats }
+
+func DemoUndoRedo() {
+	fmt.Fprintln(out, "=== Undo/Redo (Command + Memento) Demo ===")
+	fmt.Fprintln(out)
+
+	editor := &Editor{}
+	undo := NewUndoManager(editor, 4)
+	undo.Execute(&InsertText{Pos: 0, Text: "The   quick fox"})
+	undo.Execute(&InsertText{Pos: 11, Text: " brown"})
+	undo.Execute(&InsertText{Pos: 21, Text: " jumps  over"})
+	undo.Execute(&DeleteText{Pos: 3, Length: 2})
+	undo.Execute(&CollapseSpaces{})
+	undo.Execute(&InsertText{Pos: 100, Text: " the lazy dog."})
+	undo.Execute(&DeleteText{Pos: 0, Length: 4})
+	fmt.Fprintf(out, "v%d: '%s' (%d checkpoints)\n", undo.Version(), editor.GetContent(), undo.Checkpoints())
+
+	fmt.Fprintln(out, "\n1. Undo a reversible edit (command wins: no snapshot needed):")
+	undo.Undo()
+	fmt.Fprintf(out, "v%d: '%s' %+v\n", undo.Version(), editor.GetContent(), undo.Stats())
+
+	fmt.Fprintln(out, "\n2. Undo through CollapseSpaces (memento wins: spacing can't be inverted):")
+	undo.Undo()
+	undo.Undo()
+	fmt.Fprintf(out, "v%d: '%s' %+v\n", undo.Version(), editor.GetContent(), undo.Stats())
+
+	fmt.Fprintln(out, "\n3. Redo:")
+	undo.Redo()
+	undo.Redo()
+	fmt.Fprintf(out, "v%d: '%s'\n", undo.Version(), editor.GetContent())
+
+	fmt.Fprintln(out, "\n4. Jump to v2 (checkpoint v0 + 2 replayed commands):")
+	if err := undo.GoTo(2); err != nil {
+		fmt.Fprintln(out, "GoTo failed:", err)
+		return
+	}
+	fmt.Fprintf(out, "v%d: '%s' %+v\n", undo.Version(), editor.GetContent(), undo.Stats())
+
+	fmt.Fprintln(out, "\n5. Only recorded versions can be reached:")
+	fmt.Fprintln(out, "Out of range:", undo.GoTo(42))
+
+	fmt.Fprintln(out, "\n6. A new edit after undo discards the redo tail:")
+	undo.GoTo(3)
+	undo.Execute(&InsertText{Pos: 0, Text: ">> "})
+	fmt.Fprintf(out, "v%d of %d: '%s', redo available: %v\n",
+		undo.Version(), undo.Len(), editor.GetContent(), undo.Redo())
+}
@@ -0,0 +1,153 @@
+package behavioral
+
+import (
+	"errors"
+	"testing"
+)
+
+// edits is the demo's script; CollapseSpaces is the fifth command
+var edits = []func() EditCommand{
+	func() EditCommand { return &InsertText{Pos: 0, Text: "The   quick fox"} },
+	func() EditCommand { return &InsertText{Pos: 11, Text: " brown"} },
+	func() EditCommand { return &InsertText{Pos: 21, Text: " jumps  over"} },
+	func() EditCommand { return &DeleteText{Pos: 3, Length: 2} },
+	func() EditCommand { return &CollapseSpaces{} },
+	func() EditCommand { return &InsertText{Pos: 100, Text: " the lazy dog."} },
+	func() EditCommand { return &DeleteText{Pos: 0, Length: 4} },
+}
+
+// edited runs the script and returns the content after each version
+func edited(every int) (*UndoManager, *Editor, []string) {
+	editor := &Editor{}
+	undo := NewUndoManager(editor, every)
+	versions := []string{editor.GetContent()}
+	for _, edit := range edits {
+		undo.Execute(edit())
+		versions = append(versions, editor.GetContent())
+	}
+	return undo, editor, versions
+}
+
+func TestUndoManagerReachesEveryVersion(t *testing.T) {
+	for _, every := range []int{1, 2, 3, 4, 100} {
+		_, _, versions := edited(every)
+		for target := range versions {
+			for start := range versions {
+				undo, editor, _ := edited(every)
+				undo.GoTo(start)
+				if err := undo.GoTo(target); err != nil {
+					t.Fatalf("every=%d: GoTo(%d): %v", every, target, err)
+				}
+				if editor.GetContent() != versions[target] || undo.Version() != target {
+					t.Errorf("every=%d: v%d -> v%d gave v%d %q, want %q",
+						every, start, target, undo.Version(), editor.GetContent(), versions[target])
+				}
+			}
+		}
+	}
+
+	t.Run("undo then redo walks back and forth through the same versions", func(t *testing.T) {
+		undo, editor, versions := edited(4)
+		for v := len(versions) - 2; v >= 0; v-- {
+			if !undo.Undo() || editor.GetContent() != versions[v] {
+				t.Fatalf("undo to v%d gave %q, want %q", v, editor.GetContent(), versions[v])
+			}
+		}
+		if undo.Undo() {
+			t.Error("Undo at v0 = true, want false")
+		}
+		for v := 1; v < len(versions); v++ {
+			if !undo.Redo() || editor.GetContent() != versions[v] {
+				t.Fatalf("redo to v%d gave %q, want %q", v, editor.GetContent(), versions[v])
+			}
+		}
+		if undo.Redo() {
+			t.Error("Redo at the last version = true, want false")
+		}
+	})
+}
+
+func TestUndoManagerPicksRevertOrRestore(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  int
+		every int
+		want  UndoStats
+	}{
+		{"a reversible edit reverts itself", 7, 4, UndoStats{Reverts: 1}},
+		{"CollapseSpaces restores the checkpoint at v4", 5, 4, UndoStats{Restores: 1}},
+		{"CollapseSpaces restores v3 and replays one command", 5, 3, UndoStats{Restores: 1, Replayed: 1}},
+		{"CollapseSpaces replays from v0 without later checkpoints", 5, 100, UndoStats{Restores: 1, Replayed: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			undo, _, _ := edited(tt.every)
+			undo.GoTo(tt.from)
+			before := undo.Stats()
+			undo.Undo()
+			got := undo.Stats()
+			got.Reverts -= before.Reverts
+			got.Restores -= before.Restores
+			got.Replayed -= before.Replayed
+			if got != tt.want || undo.Version() != tt.from-1 {
+				t.Errorf("undo from v%d: %+v to v%d, want %+v", tt.from, got, undo.Version(), tt.want)
+			}
+		})
+	}
+}
+
+func TestUndoManagerExecuteDiscardsTheRedoTail(t *testing.T) {
+	undo, editor, versions := edited(2)
+	undo.GoTo(3)
+	undo.Execute(&InsertText{Pos: 0, Text: ">> "})
+
+	if undo.Version() != 4 || undo.Len() != 4 || undo.Redo() {
+		t.Errorf("v%d of %d, want v4 of 4 with nothing to redo", undo.Version(), undo.Len())
+	}
+	// Checkpoints at v0, v2 and the new v4; the old v4 and v6 are gone
+	if undo.Checkpoints() != 3 {
+		t.Errorf("%d checkpoints, want 3", undo.Checkpoints())
+	}
+	undo.GoTo(3)
+	if editor.GetContent() != versions[3] {
+		t.Errorf("v3 = %q, want %q", editor.GetContent(), versions[3])
+	}
+	undo.GoTo(4)
+	if want := ">> " + versions[3]; editor.GetContent() != want {
+		t.Errorf("v4 = %q, want %q", editor.GetContent(), want)
+	}
+}
+
+func TestUndoManagerRejectsUnknownVersions(t *testing.T) {
+	undo, editor, _ := edited(4)
+	content := editor.GetContent()
+	for _, v := range []int{-1, 8, 42} {
+		if err := undo.GoTo(v); !errors.Is(err, ErrNoSuchVersion) {
+			t.Errorf("GoTo(%d) = %v, want ErrNoSuchVersion", v, err)
+		}
+	}
+	if undo.Version() != 7 || editor.GetContent() != content {
+		t.Errorf("a failed GoTo moved to v%d %q", undo.Version(), editor.GetContent())
+	}
+}
+
+func TestDemoUndoRedoPrintsEachStep(t *testing.T) {
+	buf := captureOutput(t)
+	DemoUndoRedo()
+	assertLines(t, buf,
+		"=== Undo/Redo (Command + Memento) Demo ===",
+		"v7: 'quick brown fox jumps over the lazy dog.' (2 checkpoints)",
+		"1. Undo a reversible edit (command wins: no snapshot needed):",
+		"v6: 'The quick brown fox jumps over the lazy dog.' {Reverts:1 Restores:0 Replayed:0}",
+		"2. Undo through CollapseSpaces (memento wins: spacing can't be inverted):",
+		"v4: 'The quick brown fox jumps  over' {Reverts:2 Restores:1 Replayed:0}",
+		"3. Redo:",
+		"v6: 'The quick brown fox jumps over the lazy dog.'",
+		"4. Jump to v2 (checkpoint v0 + 2 replayed commands):",
+		"v2: 'The   quick brown fox' {Reverts:2 Restores:2 Replayed:2}",
+		"5. Only recorded versions can be reached:",
+		"Out of range: undo: no such version: 42 (have 0..7)",
+		"6. A new edit after undo discards the redo tail:",
+		"v4 of 4: '>> The   quick brown fox jumps  over', redo available: false",
+	)
+}
@@ -89,7 +89,7 @@ func (j *JSONExporter) VisitTriangle(t *Triangle) string {
 }
 
 func DemoVisitor() {
-	fmt.Println("=== Visitor Pattern Demo ===\n")
+	fmt.Fprint(out, "=== Visitor Pattern Demo ===\n\n")
 	shapes := []Shape{
 		&Circle{Radius: 5},
 		&Rectangle{Width: 4, Height: 6},
@@ -98,16 +98,16 @@ func DemoVisitor() {
 	areaCalc := &AreaCalculator{}
 	perimeterCalc := &PerimeterCalculator{}
 	jsonExporter := &JSONExporter{}
-	fmt.Println("Calculating Areas:")
+	fmt.Fprintln(out, "Calculating Areas:")
 	for _, shape := range shapes {
-		fmt.Println(shape.Accept(areaCalc))
+		fmt.Fprintln(out, shape.Accept(areaCalc))
 	}
-	fmt.Println("\nCalculating Perimeters:")
+	fmt.Fprintln(out, "\nCalculating Perimeters:")
 	for _, shape := range shapes {
-		fmt.Println(shape.Accept(perimeterCalc))
+		fmt.Fprintln(out, shape.Accept(perimeterCalc))
 	}
-	fmt.Println("\nExporting to JSON:")
+	fmt.Fprintln(out, "\nExporting to JSON:")
 	for _, shape := range shapes {
-		fmt.Println(shape.Accept(jsonExporter))
+		fmt.Fprintln(out, shape.Accept(jsonExporter))
 	}
 }
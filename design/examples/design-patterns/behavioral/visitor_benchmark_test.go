@@ -0,0 +1,161 @@
+package behavioral
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Visitor dispatch vs type switch - measured.
+// The same total-area computation over the same []Shape, dispatched four ways:
+//   - Visitor: double dispatch through Accept and the Visitor interface
+//   - type switch: one function switching on the concrete type
+//   - registry: DynamicVisitor's reflect.Type map lookup (visitor_registry.go)
+//   - Visitor + Sprintf: the original AreaCalculator, which formats a string per shape
+//
+// Run with: go test ./behavioral -run '^$' -bench 'Visitor|TypeSwitch|Registry'
+//
+// On small, cache-resident slices the type switch wins: Visitor adds a second
+// interface call per element (and one allocation for the visitor itself). On
+// large slices both are bound by memory access and the gap closes. The registry
+// pays a map lookup per element for open extension, and any real per-element
+// work, like formatting a string, outweighs all of the dispatch differences.
+
+// areaSum implements Visitor by accumulating instead of formatting
+type areaSum struct {
+	total float64
+}
+
+func (a *areaSum) VisitCircle(c *Circle) string {
+	a.total += math.Pi * c.Radius * c.Radius
+	return ""
+}
+
+func (a *areaSum) VisitRectangle(r *Rectangle) string {
+	a.total += r.Width * r.Height
+	return ""
+}
+
+func (a *areaSum) VisitTriangle(t *Triangle) string {
+	a.total += 0.5 * t.Base * t.Height
+	return ""
+}
+
+func totalAreaVisitor(shapes []Shape) float64 {
+	sum := &areaSum{}
+	for _, s := range shapes {
+		s.Accept(sum)
+	}
+	return sum.total
+}
+
+func totalAreaSwitch(shapes []Shape) float64 {
+	total := 0.0
+	for _, s := range shapes {
+		switch s := s.(type) {
+		case *Circle:
+			total += math.Pi * s.Radius * s.Radius
+		case *Rectangle:
+			total += s.Width * s.Height
+		case *Triangle:
+			total += 0.5 * s.Base * s.Height
+		}
+	}
+	return total
+}
+
+func totalAreaRegistry(v *DynamicVisitor[float64], shapes []Shape) float64 {
+	total := 0.0
+	for _, s := range shapes {
+		area, _ := v.Visit(s)
+		total += area
+	}
+	return total
+}
+
+func formatAreas(shapes []Shape) int {
+	calc := &AreaCalculator{}
+	n := 0
+	for _, s := range shapes {
+		n += len(s.Accept(calc))
+	}
+	return n
+}
+
+func randomShapes(n int, rng *rand.Rand) []Shape {
+	shapes := make([]Shape, n)
+	for i := range shapes {
+		switch rng.Intn(3) {
+		case 0:
+			shapes[i] = &Circle{Radius: rng.Float64() * 10}
+		case 1:
+			shapes[i] = &Rectangle{Width: rng.Float64() * 10, Height: rng.Float64() * 10}
+		default:
+			shapes[i] = &Triangle{Base: rng.Float64() * 10, Height: rng.Float64() * 10}
+		}
+	}
+	return shapes
+}
+
+var benchSizes = []int{10, 1000, 100000}
+
+// benchShapes runs bench once per size over the same generated shapes
+func benchShapes(b *testing.B, bench func(b *testing.B, shapes []Shape)) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range benchSizes {
+		shapes := randomShapes(size, rng)
+		b.Run(fmt.Sprintf("shapes=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			bench(b, shapes)
+		})
+	}
+}
+
+var areaSink float64
+
+func BenchmarkVisitor(b *testing.B) {
+	benchShapes(b, func(b *testing.B, shapes []Shape) {
+		for i := 0; i < b.N; i++ {
+			areaSink += totalAreaVisitor(shapes)
+		}
+	})
+}
+
+func BenchmarkTypeSwitch(b *testing.B) {
+	benchShapes(b, func(b *testing.B, shapes []Shape) {
+		for i := 0; i < b.N; i++ {
+			areaSink += totalAreaSwitch(shapes)
+		}
+	})
+}
+
+func BenchmarkRegistry(b *testing.B) {
+	registry := NewAreaVisitor()
+	benchShapes(b, func(b *testing.B, shapes []Shape) {
+		for i := 0; i < b.N; i++ {
+			areaSink += totalAreaRegistry(registry, shapes)
+		}
+	})
+}
+
+func BenchmarkVisitorSprintf(b *testing.B) {
+	benchShapes(b, func(b *testing.B, shapes []Shape) {
+		for i := 0; i < b.N; i++ {
+			areaSink += float64(formatAreas(shapes))
+		}
+	})
+}
+
+func TestAreaDispatchesAgree(t *testing.T) {
+	shapes := randomShapes(1000, rand.New(rand.NewSource(1)))
+	visitor := totalAreaVisitor(shapes)
+	for name, got := range map[string]float64{
+		"type switch": totalAreaSwitch(shapes),
+		"registry":    totalAreaRegistry(NewAreaVisitor(), shapes),
+	} {
+		if math.Abs(got-visitor) > 1e-6*visitor {
+			t.Errorf("%s total %v, visitor total %v", name, got, visitor)
+		}
+	}
+}
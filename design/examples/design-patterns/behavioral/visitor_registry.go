@@ -0,0 +1,161 @@
+package behavioral
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Visitor with extensible element registration.
+// The Visitor interface in visitor.go names every shape, so a new shape means
+// editing the interface and every visitor. DynamicVisitor dispatches on the
+// element's dynamic type through a registry of typed handlers instead: a new
+// element type only registers its own handlers, and existing visitors, shapes
+// and handlers stay untouched. The trade-off is that a missing handler is found
+// at runtime (NoVisitHandlerError) rather than by the compiler.
+
+// NoVisitHandlerError is returned for element types nobody registered a handler for
+type NoVisitHandlerError struct {
+	Visitor string
+	Type    reflect.Type
+}
+
+func (e *NoVisitHandlerError) Error() string {
+	return fmt.Sprintf("%s visitor has no handler for %v", e.Visitor, e.Type)
+}
+
+// DynamicVisitor maps element types to handlers producing R
+type DynamicVisitor[R any] struct {
+	name     string
+	handlers map[reflect.Type]func(any) R
+}
+
+func NewDynamicVisitor[R any](name string) *DynamicVisitor[R] {
+	return &DynamicVisitor[R]{name: name, handlers: make(map[reflect.Type]func(any) R)}
+}
+
+// Register adds (or replaces) the handler for element type T.
+// It is a function rather than a method because Go methods cannot take type parameters.
+func Register[T any, R any](v *DynamicVisitor[R], handle func(T) R) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	v.handlers[t] = func(element any) R {
+		return handle(element.(T))
+	}
+}
+
+// Visit dispatches element to the handler registered for its dynamic type
+func (v *DynamicVisitor[R]) Visit(element any) (R, error) {
+	handle, ok := v.handlers[reflect.TypeOf(element)]
+	if !ok {
+		var zero R
+		return zero, &NoVisitHandlerError{Visitor: v.name, Type: reflect.TypeOf(element)}
+	}
+	return handle(element), nil
+}
+
+// Handles lists the registered element types, sorted by name
+func (v *DynamicVisitor[R]) Handles() []string {
+	names := make([]string, 0, len(v.handlers))
+	for t := range v.handlers {
+		names = append(names, t.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Built-in visitors cover the shapes from visitor.go
+
+func NewAreaVisitor() *DynamicVisitor[float64] {
+	v := NewDynamicVisitor[float64]("area")
+	Register(v, func(c *Circle) float64 { return math.Pi * c.Radius * c.Radius })
+	Register(v, func(r *Rectangle) float64 { return r.Width * r.Height })
+	Register(v, func(t *Triangle) float64 { return 0.5 * t.Base * t.Height })
+	return v
+}
+
+func NewPerimeterVisitor() *DynamicVisitor[float64] {
+	v := NewDynamicVisitor[float64]("perimeter")
+	Register(v, func(c *Circle) float64 { return 2 * math.Pi * c.Radius })
+	Register(v, func(r *Rectangle) float64 { return 2 * (r.Width + r.Height) })
+	Register(v, func(t *Triangle) float64 { return 3 * t.Base })
+	return v
+}
+
+// Third-party extension: a polygon registered without touching anything above.
+// Polygon does not implement Shape; it never needed an Accept method.
+
+type Point struct {
+	X, Y float64
+}
+
+type Polygon struct {
+	Vertices []Point
+}
+
+// RegisterPolygon plugs Polygon into the built-in visitors
+func RegisterPolygon(area, perimeter *DynamicVisitor[float64]) {
+	Register(area, func(p *Polygon) float64 {
+		// Shoelace formula
+		sum := 0.0
+		for i, a := range p.Vertices {
+			b := p.Vertices[(i+1)%len(p.Vertices)]
+			sum += a.X*b.Y - b.X*a.Y
+		}
+		return math.Abs(sum) / 2
+	})
+	Register(perimeter, func(p *Polygon) float64 {
+		sum := 0.0
+		for i, a := range p.Vertices {
+			b := p.Vertices[(i+1)%len(p.Vertices)]
+			sum += math.Hypot(b.X-a.X, b.Y-a.Y)
+		}
+		return sum
+	})
+}
+
+func DemoVisitorRegistry() {
+	fmt.Fprintln(out, "=== Visitor Registry Demo ===")
+	fmt.Fprintln(out)
+
+	area := NewAreaVisitor()
+	perimeter := NewPerimeterVisitor()
+	square := &Polygon{Vertices: []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}}}
+
+	elements := []any{
+		&Circle{Radius: 5},
+		&Rectangle{Width: 4, Height: 6},
+		&Triangle{Base: 3, Height: 4},
+		square,
+	}
+
+	visitAll := func() {
+		for _, e := range elements {
+			a, err := area.Visit(e)
+			if err != nil {
+				fmt.Fprintf(out, "%-22T error: %v\n", e, err)
+				continue
+			}
+			p, _ := perimeter.Visit(e)
+			fmt.Fprintf(out, "%-22T area=%7.2f perimeter=%6.2f\n", e, a, p)
+		}
+	}
+
+	fmt.Fprintln(out, "Before registering Polygon:")
+	visitAll()
+
+	RegisterPolygon(area, perimeter)
+	fmt.Fprintln(out, "\nAfter RegisterPolygon:")
+	visitAll()
+
+	// A brand new operation is just another registry
+	names := NewDynamicVisitor[string]("name")
+	Register(names, func(c *Circle) string { return "circle" })
+	Register(names, func(p *Polygon) string { return fmt.Sprintf("%d-gon", len(p.Vertices)) })
+	fmt.Fprintln(out, "\nName visitor handles:", names.Handles())
+	for _, e := range elements {
+		if name, err := names.Visit(e); err == nil {
+			fmt.Fprintln(out, " ", name)
+		}
+	}
+}
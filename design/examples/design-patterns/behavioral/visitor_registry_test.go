@@ -0,0 +1,86 @@
+package behavioral
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDynamicVisitorWithoutAHandler(t *testing.T) {
+	_, err := NewAreaVisitor().Visit(&Polygon{})
+	var missing *NoVisitHandlerError
+	if !errors.As(err, &missing) {
+		t.Fatalf("Visit(*Polygon) = %v, want a *NoVisitHandlerError", err)
+	}
+	if missing.Visitor != "area" || missing.Type != reflect.TypeOf(&Polygon{}) {
+		t.Errorf("error names visitor %q and type %v, want area and *behavioral.Polygon", missing.Visitor, missing.Type)
+	}
+	if got, want := err.Error(), "area visitor has no handler for *behavioral.Polygon"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	// Handlers match the exact dynamic type, so a value is not a pointer
+	if _, err := NewAreaVisitor().Visit(Circle{Radius: 1}); !errors.As(err, &missing) {
+		t.Errorf("Visit(Circle) = %v, want a *NoVisitHandlerError", err)
+	}
+}
+
+func TestRegisterPolygon(t *testing.T) {
+	area, perimeter := NewAreaVisitor(), NewPerimeterVisitor()
+	RegisterPolygon(area, perimeter)
+
+	square := &Polygon{Vertices: []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}}}
+	if got, err := area.Visit(square); err != nil || got != 16 {
+		t.Errorf("area of a 4x4 square = %v, %v, want 16", got, err)
+	}
+	if got, err := perimeter.Visit(square); err != nil || got != 16 {
+		t.Errorf("perimeter of a 4x4 square = %v, %v, want 16", got, err)
+	}
+
+	t.Run("the built-in shapes are unchanged", func(t *testing.T) {
+		tests := []struct {
+			shape           any
+			area, perimeter float64
+		}{
+			{&Circle{Radius: 5}, math.Pi * 25, math.Pi * 10},
+			{&Rectangle{Width: 4, Height: 6}, 24, 20},
+			{&Triangle{Base: 3, Height: 4}, 6, 9},
+		}
+		for _, tt := range tests {
+			a, _ := area.Visit(tt.shape)
+			p, _ := perimeter.Visit(tt.shape)
+			if a != tt.area || p != tt.perimeter {
+				t.Errorf("%T: area %v, perimeter %v, want %v and %v", tt.shape, a, p, tt.area, tt.perimeter)
+			}
+		}
+	})
+
+	t.Run("other visitors do not learn polygons", func(t *testing.T) {
+		if _, err := NewAreaVisitor().Visit(square); err == nil {
+			t.Error("a new area visitor handles *Polygon after RegisterPolygon on another one")
+		}
+	})
+}
+
+func TestDynamicVisitorHandles(t *testing.T) {
+	area, perimeter := NewAreaVisitor(), NewPerimeterVisitor()
+	want := []string{"*behavioral.Circle", "*behavioral.Rectangle", "*behavioral.Triangle"}
+	if got := area.Handles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Handles() = %v, want %v", got, want)
+	}
+
+	RegisterPolygon(area, perimeter)
+	want = []string{"*behavioral.Circle", "*behavioral.Polygon", "*behavioral.Rectangle", "*behavioral.Triangle"}
+	if got := perimeter.Handles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after RegisterPolygon Handles() = %v, want %v sorted by name", got, want)
+	}
+
+	// Registering a type again replaces its handler
+	names := NewDynamicVisitor[string]("name")
+	Register(names, func(*Circle) string { return "circle" })
+	Register(names, func(*Circle) string { return "round" })
+	if got, _ := names.Visit(&Circle{}); got != "round" || len(names.Handles()) != 1 {
+		t.Errorf("after re-registering *Circle got %q from %v, want round from one handler", got, names.Handles())
+	}
+}
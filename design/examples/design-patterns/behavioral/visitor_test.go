@@ -0,0 +1,49 @@
+package behavioral
+
+import "testing"
+
+func TestVisitorsOnEachShape(t *testing.T) {
+	shapes := []Shape{
+		&Circle{Radius: 5},
+		&Rectangle{Width: 4, Height: 6},
+		&Triangle{Base: 3, Height: 4},
+	}
+	tests := []struct {
+		visitor Visitor
+		want    []string
+	}{
+		{&AreaCalculator{}, []string{"Circle area: 78.54", "Rectangle area: 24.00", "Triangle area: 6.00"}},
+		{&PerimeterCalculator{}, []string{"Circle perimeter: 31.42", "Rectangle perimeter: 20.00", "Triangle perimeter: 9.00"}},
+		{&JSONExporter{}, []string{
+			`{"type": "circle", "radius": 5.00}`,
+			`{"type": "rectangle", "width": 4.00, "height": 6.00}`,
+			`{"type": "triangle", "base": 3.00, "height": 4.00}`,
+		}},
+	}
+	for _, tt := range tests {
+		for i, shape := range shapes {
+			if got := shape.Accept(tt.visitor); got != tt.want[i] {
+				t.Errorf("%T on %T = %q, want %q", tt.visitor, shape, got, tt.want[i])
+			}
+		}
+	}
+}
+
+// countingVisitor checks that Accept calls the method for its own type
+type countingVisitor struct {
+	circles, rectangles, triangles int
+}
+
+func (v *countingVisitor) VisitCircle(*Circle) string       { v.circles++; return "" }
+func (v *countingVisitor) VisitRectangle(*Rectangle) string { v.rectangles++; return "" }
+func (v *countingVisitor) VisitTriangle(*Triangle) string   { v.triangles++; return "" }
+
+func TestAcceptDispatchesOnTheElementType(t *testing.T) {
+	v := &countingVisitor{}
+	for _, shape := range []Shape{&Circle{}, &Rectangle{}, &Rectangle{}, &Triangle{}, &Triangle{}, &Triangle{}} {
+		shape.Accept(v)
+	}
+	if v.circles != 1 || v.rectangles != 2 || v.triangles != 3 {
+		t.Errorf("visited %d circles, %d rectangles, %d triangles; want 1, 2, 3", v.circles, v.rectangles, v.triangles)
+	}
+}
@@ -1,10 +1,24 @@
 package main
 
 import (
+"context"
+"encoding/json"
+"fmt"
+"github.com/dong-tran/docs/concurrency-example/lazy"
+"github.com/dong-tran/docs/concurrency-example/ratelimit"
+"github.com/dong-tran/docs/concurrency-example/scatter"
+"github.com/dong-tran/docs/concurrency-example/shutdown"
+"github.com/dong-tran/docs/microservices-example/resilience"
 "github.com/labstack/echo/v4"
 "github.com/labstack/echo/v4/middleware"
 "io"
+"log"
+"net"
 "net/http"
+"os"
+"strconv"
+"strings"
+"time"
 )
 
 func main() {
@@ -13,32 +27,239 @@ func main() {
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
+	// Per-client rate limit, selected by RATE_LIMIT (off when unset):
+	//   token  token bucket: bursts up to RATE_LIMIT_BURST, then RATE_LIMIT_RPS
+	//   leaky  leaky bucket: never faster than RATE_LIMIT_RPS
+	var limited *resilience.RateLimit
+	if algorithm := os.Getenv("RATE_LIMIT"); algorithm != "" {
+		cfg := ratelimit.Config{Rate: envFloat("RATE_LIMIT_RPS", 20), Burst: int(envFloat("RATE_LIMIT_BURST", 40))}
+		newLimiter := func() ratelimit.Limiter { return ratelimit.NewTokenBucket(cfg) }
+		if algorithm == "leaky" {
+			newLimiter = func() ratelimit.Limiter { return ratelimit.NewLeakyBucket(cfg) }
+		}
+		limited = resilience.NewRateLimit(ratelimit.NewKeyed(newLimiter, 10*time.Minute, nil), nil)
+		e.Use(limited.Middleware())
+	}
+
+	// Overload protection per route, selected by ADMISSION_MODE:
+	//   queue    (default) bounded admission queue sized by Little's law
+	//   shed     same slots, but no queue: reject as soon as they are full
+	//   adaptive concurrency limit discovered from latency (gradient algorithm)
+	//   priority one slot pool for all routes, shared by request class with weighted fair queueing
+	mode := os.Getenv("ADMISSION_MODE")
+	var stats []func() interface{}
+	var scheduled echo.MiddlewareFunc
+	if mode == "priority" {
+		scheduler := resilience.NewPriorityScheduler(resilience.SchedulerConfig{
+			MaxConcurrent: resilience.ConcurrencyFor(700, 100*time.Millisecond),
+			Classes: map[resilience.RequestClass]resilience.ClassConfig{
+				resilience.ClassInteractive: {Weight: 8, MaxQueue: 100, WaitBudget: 500 * time.Millisecond},
+				resilience.ClassAdmin:       {Weight: 4, MaxQueue: 10, WaitBudget: 500 * time.Millisecond},
+				resilience.ClassBatch:       {Weight: 1, MaxQueue: 200, WaitBudget: 5 * time.Second},
+			},
+		})
+		var rules []resilience.ClassRule
+		for _, svc := range []string{"users", "products", "orders"} {
+			rules = append(rules,
+				resilience.ClassRule{PathPrefix: "/api/" + svc + "/admin", Class: resilience.ClassAdmin},
+				resilience.ClassRule{PathPrefix: "/api/" + svc + "/export", Class: resilience.ClassBatch})
+		}
+		scheduled = scheduler.Middleware(resilience.NewClassifier(resilience.ClassInteractive, rules...))
+		stats = append(stats, func() interface{} { return scheduler.Stats() })
+	}
+	limit := func(name string, peakRPS float64, latency time.Duration) echo.MiddlewareFunc {
+		if scheduled != nil {
+			return scheduled
+		}
+		if mode == "adaptive" {
+			l := resilience.NewAdaptiveLimiter(name, &resilience.Gradient{Smoothing: 0.2, Drift: 0.0002},
+				resilience.ConcurrencyFor(peakRPS, latency), 1, 1000)
+			stats = append(stats, func() interface{} { return l.Stats() })
+			return l.Middleware()
+		}
+		cfg := resilience.SizeAdmission(peakRPS, latency, 500*time.Millisecond)
+		if mode == "shed" {
+			cfg.MaxQueue = 0
+		}
+		a := resilience.NewAdmission(name, cfg)
+		stats = append(stats, func() interface{} { return a.Stats() })
+		return a.Middleware()
+	}
+	users := limit("users", 200, 50*time.Millisecond)
+	products := limit("products", 400, 50*time.Millisecond)
+	orders := limit("orders", 100, 100*time.Millisecond)
+	dashboard := limit("dashboard", 100, 300*time.Millisecond)
+
+	e.GET("/metrics", func(c echo.Context) error {
+		routes := make([]interface{}, len(stats))
+		for i, s := range stats {
+			routes[i] = s()
+		}
+		metrics := map[string]interface{}{
+			"mode":   mode,
+			"routes": routes,
+		}
+		if limited != nil {
+			metrics["rate_limit"] = limited.Stats()
+		}
+		return c.JSON(http.StatusOK, metrics)
+	})
+
+	// One client per upstream, built on first use, so each has its own
+	// connection pool and a slow upstream cannot tie up the others' connections
+	clients := lazy.NewOncePerKey(func(target string) (*http.Client, error) {
+		return &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				MaxConnsPerHost:     256,
+				MaxIdleConnsPerHost: 64,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		}, nil
+	})
+
 	// Route to User Service
 	e.Any("/api/users/*", func(c echo.Context) error {
-return proxy(c, "http://localhost:8081")
-})
+return proxy(c, clients, "http://localhost:8081")
+}, users)
 
 	// Route to Product Service
 	e.Any("/api/products/*", func(c echo.Context) error {
-return proxy(c, "http://localhost:8082")
-})
+return proxy(c, clients, "http://localhost:8082")
+}, products)
 
 	// Route to Order Service
 	e.Any("/api/orders/*", func(c echo.Context) error {
-return proxy(c, "http://localhost:8083")
-})
+return proxy(c, clients, "http://localhost:8083")
+}, orders)
+
+	// Dashboard aggregated from all three services at once
+	e.GET("/api/dashboard/:userID", func(c echo.Context) error {
+		return userDashboard(c, clients)
+	}, dashboard)
+
+	// Lifecycle: on SIGINT/SIGTERM stop accepting and finish the requests in
+	// flight, then close the upstream connections they were using
+	app := shutdown.New(shutdown.Options{StopTimeout: 10 * time.Second, Logf: log.Printf})
+	app.Register(
+		shutdown.Component{
+			Name: "upstreams",
+			Stop: func(context.Context) error {
+				clients.Range(func(_ string, client *http.Client) { client.CloseIdleConnections() })
+				return nil
+			},
+		},
+		shutdown.Component{
+			Name:      "http",
+			DependsOn: []string{"upstreams"},
+			Start: func(context.Context) error {
+				ln, err := net.Listen("tcp", ":8080")
+				if err != nil {
+					return err
+				}
+				e.Listener = ln
+				go func() {
+					if err := e.Start(""); err != nil && err != http.ErrServerClosed {
+						log.Fatalf("Gateway stopped: %v", err)
+					}
+				}()
+				return nil
+			},
+			Stop: e.Shutdown,
+		},
+	)
+	if err := app.Run(context.Background(), 15*time.Second); err != nil {
+		log.Fatalf("Gateway: %v", err)
+	}
+}
+
+// userDashboard gathers a user's profile, recent orders and products in
+// parallel within 300ms. The profile is required; without it the page fails
+// with 502. Orders and products degrade: a slow or failing service leaves
+// its section null and is listed under "errors".
+func userDashboard(c echo.Context, clients *lazy.OncePerKey[string, *http.Client]) error {
+	userID := c.Param("userID")
+	backend := func(name, target, path string, required bool) scatter.Backend[json.RawMessage] {
+		return scatter.Backend[json.RawMessage]{Name: name, Required: required, Call: func(ctx context.Context) (json.RawMessage, error) {
+			return fetchJSON(ctx, clients, target, path)
+		}}
+	}
+	report := scatter.Gather(c.Request().Context(), 300*time.Millisecond,
+		backend("user", "http://localhost:8081", "/users/"+userID, true),
+		backend("orders", "http://localhost:8083", "/orders?user_id="+userID, false),
+		backend("products", "http://localhost:8082", "/products", false),
+	)
+	if err := report.Err(); err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
 
-	e.Start(":8080")
+	page := map[string]interface{}{"partial": report.Partial()}
+	for _, o := range report.Outcomes {
+		page[o.Backend] = o.Value
+	}
+	if errs := report.Errors(); len(errs) > 0 {
+		messages := make(map[string]string, len(errs))
+		for name, err := range errs {
+			messages[name] = err.Error()
+		}
+		page["errors"] = messages
+	}
+	return c.JSON(http.StatusOK, page)
 }
 
-func proxy(c echo.Context, target string) error {
+// fetchJSON GETs path from target and returns the body, which must be JSON
+func fetchJSON(ctx context.Context, clients *lazy.OncePerKey[string, *http.Client], target, path string) (json.RawMessage, error) {
+	client, err := clients.Get(target)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s%s: %s", target, path, resp.Status)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("%s%s: response is not JSON", target, path)
+	}
+	return body, nil
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// proxy forwards the request to target, dropping the gateway's /api prefix
+func proxy(c echo.Context, clients *lazy.OncePerKey[string, *http.Client], target string) error {
 	req := c.Request()
-	resp, err := http.DefaultClient.Do(&http.Request{
-		Method: req.Method,
-		URL:    req.URL,
-		Header: req.Header,
-		Body:   req.Body,
-	})
+	url := target + strings.TrimPrefix(req.URL.Path, "/api")
+	if req.URL.RawQuery != "" {
+		url += "?" + req.URL.RawQuery
+	}
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, url, req.Body)
+	if err != nil {
+		return err
+	}
+	out.Header = req.Header.Clone()
+
+	client, err := clients.Get(target)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(out)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache is a TTL cache shared by a service's handlers. It knows nothing about
+// statistics or hot keys: every access is published to its observers, and
+// Stats, HotKeys and HotTTL are observers. Add another (metrics export,
+// tracing) without touching the cache.
+
+type AccessKind string
+
+const (
+	Hit        AccessKind = "hit"
+	Miss       AccessKind = "miss"
+	Store      AccessKind = "store"
+	Expire     AccessKind = "expire" // an expired entry was found and dropped
+	Extend     AccessKind = "extend" // an entry's TTL was pushed back
+	Invalidate AccessKind = "invalidate"
+)
+
+// AccessEvent describes one access to one key. StoredAt and ExpiresAt
+// describe the entry as it is after the access, when there is one.
+type AccessEvent struct {
+	Key       string
+	Kind      AccessKind
+	At        time.Time
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Observer is notified synchronously after each access, outside the cache's
+// lock, so it may call back into the cache. It must be cheap: it runs on the
+// request path.
+type Observer interface {
+	OnAccess(AccessEvent)
+}
+
+type ObserverFunc func(AccessEvent)
+
+func (f ObserverFunc) OnAccess(e AccessEvent) { f(e) }
+
+type Config struct {
+	TTL time.Duration
+	Now func() time.Time // defaults to time.Now; simulations pass their own clock
+}
+
+type entry[V any] struct {
+	value     V
+	storedAt  time.Time
+	expiresAt time.Time
+}
+
+type Cache[V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.RWMutex
+	entries   map[string]entry[V]
+	observers []Observer
+}
+
+func New[V any](cfg Config) *Cache[V] {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Cache[V]{ttl: cfg.TTL, now: cfg.Now, entries: make(map[string]entry[V])}
+}
+
+// Subscribe adds an observer; subscribe them all before serving traffic
+func (c *Cache[V]) Subscribe(o Observer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observers = append(c.observers, o)
+}
+
+func (c *Cache[V]) notify(e AccessEvent) {
+	c.mu.RLock()
+	observers := c.observers
+	c.mu.RUnlock()
+	for _, o := range observers {
+		o.OnAccess(e)
+	}
+}
+
+func (c *Cache[V]) Get(key string) (V, bool) {
+	now := c.now()
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if ok && !now.Before(e.expiresAt) {
+		c.mu.Lock()
+		// Another caller may have stored a fresh value in between
+		if cur, still := c.entries[key]; still && cur.expiresAt == e.expiresAt {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		c.notify(AccessEvent{Key: key, Kind: Expire, At: now})
+		ok = false
+	}
+	if !ok {
+		c.notify(AccessEvent{Key: key, Kind: Miss, At: now})
+		var zero V
+		return zero, false
+	}
+	c.notify(AccessEvent{Key: key, Kind: Hit, At: now, StoredAt: e.storedAt, ExpiresAt: e.expiresAt})
+	return e.value, true
+}
+
+func (c *Cache[V]) Set(key string, value V) {
+	now := c.now()
+	e := entry[V]{value: value, storedAt: now, expiresAt: now.Add(c.ttl)}
+	c.mu.Lock()
+	c.entries[key] = e
+	c.mu.Unlock()
+	c.notify(AccessEvent{Key: key, Kind: Store, At: now, StoredAt: e.storedAt, ExpiresAt: e.expiresAt})
+}
+
+// GetOrLoad returns the cached value or loads and stores it. Concurrent
+// misses on one key each load; the loader should be idempotent.
+func (c *Cache[V]) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context, key string) (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	v, err := load(ctx, key)
+	if err != nil {
+		return v, err
+	}
+	c.Set(key, v)
+	return v, nil
+}
+
+// ExtendUntil pushes key's expiry back to until, if the entry exists and
+// would otherwise expire earlier
+func (c *Cache[V]) ExtendUntil(key string, until time.Time) bool {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok || !until.After(e.expiresAt) {
+		c.mu.Unlock()
+		return false
+	}
+	e.expiresAt = until
+	c.entries[key] = e
+	c.mu.Unlock()
+	c.notify(AccessEvent{Key: key, Kind: Extend, At: c.now(), StoredAt: e.storedAt, ExpiresAt: until})
+	return true
+}
+
+func (c *Cache[V]) Invalidate(key string) {
+	c.mu.Lock()
+	_, ok := c.entries[key]
+	delete(c.entries, key)
+	c.mu.Unlock()
+	if ok {
+		c.notify(AccessEvent{Key: key, Kind: Invalidate, At: c.now()})
+	}
+}
+
+func (c *Cache[V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats counts accesses by kind
+
+type Stats struct {
+	mu     sync.Mutex
+	counts map[AccessKind]int64
+}
+
+func NewStats() *Stats {
+	return &Stats{counts: make(map[AccessKind]int64)}
+}
+
+func (s *Stats) OnAccess(e AccessEvent) {
+	s.mu.Lock()
+	s.counts[e.Kind]++
+	s.mu.Unlock()
+}
+
+type StatsSnapshot struct {
+	Counts   map[AccessKind]int64 `json:"counts"`
+	HitRatio float64              `json:"hit_ratio"`
+}
+
+func (s *Stats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[AccessKind]int64, len(s.counts))
+	for k, v := range s.counts {
+		counts[k] = v
+	}
+	snap := StatsSnapshot{Counts: counts}
+	if reads := counts[Hit] + counts[Miss]; reads > 0 {
+		snap.HitRatio = float64(counts[Hit]) / float64(reads)
+	}
+	return snap
+}
+
+// HotKeys finds the most read keys with the space-saving algorithm (Metwally
+// et al.): it monitors at most capacity keys whatever the key space. A read of
+// an unmonitored key, when all slots are taken, replaces the least-counted key
+// and inherits its count, recorded as the new key's possible overcount
+// (Error). Every key read more than reads/capacity times is guaranteed to be
+// monitored, and Count - Error never exceeds its true count.
+//
+// Only hits and misses count as reads; the cache's own stores and extensions
+// do not.
+
+type HotKey struct {
+	Key   string  `json:"key"`
+	Count int64   `json:"count"` // estimated reads, never an underestimate
+	Error int64   `json:"error"` // how much of Count may belong to evicted keys
+	Share float64 `json:"share"` // Count / all reads
+}
+
+type counter struct {
+	key   string
+	count int64
+	err   int64
+	index int
+}
+
+// counterHeap is a min-heap on count, so the slot to replace is at the root
+type counterHeap []*counter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+type HotKeys struct {
+	capacity int
+
+	mu       sync.Mutex
+	reads    int64
+	counters map[string]*counter
+	heap     counterHeap
+}
+
+func NewHotKeys(capacity int) *HotKeys {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &HotKeys{capacity: capacity, counters: make(map[string]*counter, capacity)}
+}
+
+func (h *HotKeys) OnAccess(e AccessEvent) {
+	if e.Kind != Hit && e.Kind != Miss {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reads++
+
+	if c, ok := h.counters[e.Key]; ok {
+		c.count++
+		heap.Fix(&h.heap, c.index)
+		return
+	}
+	if len(h.heap) < h.capacity {
+		c := &counter{key: e.Key, count: 1}
+		h.counters[e.Key] = c
+		heap.Push(&h.heap, c)
+		return
+	}
+	min := h.heap[0]
+	delete(h.counters, min.key)
+	min.key, min.err = e.Key, min.count
+	min.count++
+	h.counters[e.Key] = min
+	heap.Fix(&h.heap, 0)
+}
+
+// Top returns the n keys with the highest estimated counts
+func (h *HotKeys) Top(n int) []HotKey {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]HotKey, 0, len(h.heap))
+	for _, c := range h.heap {
+		keys = append(keys, HotKey{Key: c.key, Count: c.count, Error: c.err, Share: float64(c.count) / float64(h.reads)})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if n < len(keys) {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// IsHot reports whether key certainly receives at least share of all reads,
+// counting only reads guaranteed to be its own
+func (h *HotKeys) IsHot(key string, share float64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.counters[key]
+	return ok && h.reads > 0 && float64(c.count-c.err) >= share*float64(h.reads)
+}
+
+// Reads is the number of reads observed
+func (h *HotKeys) Reads() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reads
+}
+
+// HotTTL keeps hot entries cached longer. On a hit to a key with at least
+// MinShare of reads, it extends the entry to TTL from now, once less than half
+// of that is left. MaxAge bounds how long any value is served after it was
+// stored, so a hot key still gets reloaded eventually.
+type HotTTL[V any] struct {
+	Cache    *Cache[V]
+	Hot      *HotKeys
+	MinShare float64
+	TTL      time.Duration
+	MaxAge   time.Duration
+}
+
+func (t *HotTTL[V]) OnAccess(e AccessEvent) {
+	if e.Kind != Hit || e.ExpiresAt.Sub(e.At) > t.TTL/2 || !t.Hot.IsHot(e.Key, t.MinShare) {
+		return
+	}
+	until := e.At.Add(t.TTL)
+	if limit := e.StoredAt.Add(t.MaxAge); until.After(limit) {
+		until = limit
+	}
+	t.Cache.ExtendUntil(e.Key, until)
+}
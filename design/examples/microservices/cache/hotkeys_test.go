@@ -0,0 +1,138 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/microservices-example/cache"
+)
+
+// The tests replay a skewed (Zipf) read stream against a cache on a simulated
+// clock, with a fixed seed, and compare the hot-key estimates with exact counts.
+
+const (
+	reads    = 200000
+	capacity = 100
+)
+
+// zipfStream returns reads keys drawn from n distinct ones with exponent skew
+func zipfStream(skew float64, n uint64) []string {
+	stream := make([]string, reads)
+	zipf := rand.NewZipf(rand.New(rand.NewSource(1)), skew, 1, n-1)
+	for i := range stream {
+		stream[i] = fmt.Sprintf("product-%d", zipf.Uint64())
+	}
+	return stream
+}
+
+type replayed struct {
+	loadsByKey map[string]int
+	stats      *cache.Stats
+	detector   *cache.HotKeys
+}
+
+// replay reads every key of stream through GetOrLoad, one simulated
+// millisecond apart, with a 30s TTL and optionally HotTTL
+func replay(stream []string, extend bool) replayed {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := cache.New[string](cache.Config{TTL: 30 * time.Second, Now: func() time.Time { return now }})
+	r := replayed{loadsByKey: make(map[string]int), stats: cache.NewStats(), detector: cache.NewHotKeys(capacity)}
+	c.Subscribe(r.stats)
+	c.Subscribe(r.detector)
+	if extend {
+		c.Subscribe(&cache.HotTTL[string]{Cache: c, Hot: r.detector, MinShare: 0.01, TTL: 2 * time.Minute, MaxAge: 5 * time.Minute})
+	}
+	load := func(ctx context.Context, key string) (string, error) {
+		r.loadsByKey[key]++
+		return key, nil
+	}
+	for _, key := range stream {
+		c.GetOrLoad(context.Background(), key, load)
+		now = now.Add(time.Millisecond)
+	}
+	return r
+}
+
+func counts(stream []string) map[string]int64 {
+	exact := make(map[string]int64)
+	for _, k := range stream {
+		exact[k]++
+	}
+	return exact
+}
+
+func TestHotKeysGuarantees(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		skew float64
+		keys uint64
+	}{
+		{"Zipf 1.1 over 10000 keys", 1.1, 10000},
+		{"Zipf 1.5 over 10000 keys", 1.5, 10000},
+		{"Zipf 1.01 over 100000 keys", 1.01, 100000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stream := zipfStream(tc.skew, tc.keys)
+			exact := counts(stream)
+			detector := replay(stream, false).detector
+			monitored := make(map[string]cache.HotKey)
+			for _, k := range detector.Top(capacity) {
+				monitored[k.Key] = k
+				if k.Count < exact[k.Key] || k.Count-k.Error > exact[k.Key] {
+					t.Errorf("%s: estimate %d (error %d) does not bound the exact count %d", k.Key, k.Count, k.Error, exact[k.Key])
+				}
+			}
+			threshold := int64(reads / capacity)
+			frequent := 0
+			for key, n := range exact {
+				if n <= threshold {
+					continue
+				}
+				frequent++
+				if _, ok := monitored[key]; !ok {
+					t.Errorf("%s was read %d times (> %d) but is not monitored", key, n, threshold)
+				}
+			}
+			t.Logf("%d distinct keys, %d read more than %d times", len(exact), frequent, threshold)
+		})
+	}
+}
+
+func TestHotTTL(t *testing.T) {
+	stream := zipfStream(1.1, 10000)
+	exact := counts(stream)
+	plain, hot := replay(stream, false), replay(stream, true)
+
+	// A hot key's reload is a burst of concurrent misses in production; those
+	// are the loads HotTTL exists to remove
+	frequentLoads := func(r replayed) int {
+		n := 0
+		for key, loads := range r.loadsByKey {
+			if exact[key] > reads/capacity {
+				n += loads
+			}
+		}
+		return n
+	}
+	p, h := frequentLoads(plain), frequentLoads(hot)
+	t.Logf("loads of frequently read keys: %d with plain TTLs, %d with HotTTL", p, h)
+
+	t.Run("extending hot entries removes most reloads of frequent keys", func(t *testing.T) {
+		if 4*h > p {
+			t.Errorf("%d loads with HotTTL, %d without; want under a quarter", h, p)
+		}
+	})
+	t.Run("without HotTTL nothing is extended", func(t *testing.T) {
+		if n := plain.stats.Snapshot().Counts[cache.Extend]; n != 0 {
+			t.Errorf("%d extends", n)
+		}
+	})
+	t.Run("the hit ratio is no worse", func(t *testing.T) {
+		if hr, pr := hot.stats.Snapshot().HitRatio, plain.stats.Snapshot().HitRatio; hr < pr {
+			t.Errorf("hit ratio %.3f with HotTTL, %.3f without", hr, pr)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Load generator for the gateway.
+// It is open-loop: requests are started at a fixed rate whether or not earlier
+// ones have finished, like real users, so overload shows up as queueing delay
+// and shed requests instead of the generator quietly slowing down.
+//
+//	go run ./cmd/loadgen -url http://localhost:8080/api/orders/1 -rps 300 -duration 10s
+
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/api/orders/1", "target URL")
+	method := flag.String("method", http.MethodGet, "HTTP method")
+	body := flag.String("body", "", "request body (sent as JSON)")
+	rps := flag.Float64("rps", 100, "requests started per second")
+	duration := flag.Duration("duration", 10*time.Second, "how long to generate load")
+	timeout := flag.Duration("timeout", 5*time.Second, "per-request timeout")
+	var headers headerFlags
+	flag.Var(&headers, "H", "extra header \"Name: value\" (repeatable)")
+	flag.Parse()
+
+	if *rps <= 0 {
+		log.Fatal("-rps must be positive")
+	}
+	client := &http.Client{Timeout: *timeout}
+	results := make(chan result, 1024)
+	var wg sync.WaitGroup
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *rps))
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+	started := time.Now()
+
+	go func() {
+		defer close(results)
+	loop:
+		for {
+			select {
+			case <-ticker.C:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results <- send(client, *method, *url, *body, headers)
+				}()
+			case <-deadline:
+				break loop
+			}
+		}
+		wg.Wait()
+	}()
+
+	statuses := make(map[int]int)
+	errs := make(map[string]int)
+	var latencies []time.Duration
+	for r := range results {
+		if r.err != nil {
+			errs[r.err.Error()]++
+			continue
+		}
+		statuses[r.status]++
+		latencies = append(latencies, r.latency)
+	}
+	report(time.Since(started), statuses, errs, latencies)
+}
+
+func send(client *http.Client, method, url, body string, headers headerFlags) result {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return result{err: err}
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{err: err}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return result{status: resp.StatusCode, latency: time.Since(start)}
+}
+
+func report(elapsed time.Duration, statuses map[int]int, errs map[string]int, latencies []time.Duration) {
+	total := len(latencies)
+	for _, n := range errs {
+		total += n
+	}
+	fmt.Printf("Requests: %d in %s (%.1f req/s)\n", total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Printf("  %d: %d\n", code, statuses[code])
+	}
+	for msg, n := range errs {
+		fmt.Printf("  error %q: %d\n", msg, n)
+	}
+
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	pct := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	fmt.Printf("Latency: p50=%s p90=%s p99=%s max=%s\n",
+		pct(0.50).Round(time.Microsecond), pct(0.90).Round(time.Microsecond),
+		pct(0.99).Round(time.Microsecond), latencies[len(latencies)-1].Round(time.Microsecond))
+}
+
+type headerFlags []string
+
+func (h *headerFlags) String() string     { return strings.Join(*h, ", ") }
+func (h *headerFlags) Set(v string) error { *h = append(*h, v); return nil }
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Scaffold generates a new microservice skeleton that follows the same
+// layering as the other examples (domain, usecase, repository, handler, main).
+//
+//	go run ./cmd/scaffold -manifest cmd/scaffold/manifest.example.json
+//
+// The generated service is written next to the existing services
+// (e.g. ./inventory-service) and can be started with `go run main.go`.
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+const modulePath = "github.com/dong-tran/docs/microservices-example"
+
+// Manifest describes the service to generate
+type Manifest struct {
+	Service string  `json:"service"`
+	Port    int     `json:"port"`
+	Entity  string  `json:"entity"`
+	Fields  []Field `json:"fields"`
+}
+
+// Field describes a single entity attribute
+type Field struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+var supportedTypes = map[string]string{
+	"string":  `""`,
+	"int":     "0",
+	"int64":   "0",
+	"float64": "0",
+	"bool":    "false",
+}
+
+// Validate checks the manifest before anything is written to disk
+func (m *Manifest) Validate() error {
+	if m.Service == "" {
+		return errors.New("manifest: service is required")
+	}
+	if strings.ContainsAny(m.Service, " /\\") {
+		return fmt.Errorf("manifest: invalid service name %q", m.Service)
+	}
+	if m.Port <= 0 || m.Port > 65535 {
+		return fmt.Errorf("manifest: invalid port %d", m.Port)
+	}
+	if m.Entity == "" || !unicode.IsUpper(rune(m.Entity[0])) {
+		return fmt.Errorf("manifest: entity %q must be an exported Go identifier", m.Entity)
+	}
+	if len(m.Fields) == 0 {
+		return errors.New("manifest: at least one field is required")
+	}
+	seen := make(map[string]bool)
+	for _, f := range m.Fields {
+		if f.Name == "" || !unicode.IsUpper(rune(f.Name[0])) {
+			return fmt.Errorf("manifest: field %q must be an exported Go identifier", f.Name)
+		}
+		if f.Name == "ID" {
+			return errors.New("manifest: ID is generated and must not be declared")
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("manifest: duplicate field %q", f.Name)
+		}
+		seen[f.Name] = true
+		if _, ok := supportedTypes[f.Type]; !ok {
+			return fmt.Errorf("manifest: field %q has unsupported type %q", f.Name, f.Type)
+		}
+	}
+	return nil
+}
+
+// templateData is the view passed to every template
+type templateData struct {
+	Manifest
+	Module   string
+	Package  string
+	Var      string
+	Resource string
+}
+
+func newTemplateData(m Manifest) templateData {
+	dir := m.Service + "-service"
+	return templateData{
+		Manifest: m,
+		Module:   modulePath + "/" + dir,
+		Package:  strings.ToLower(m.Entity),
+		Var:      lowerFirst(m.Entity),
+		Resource: strings.ToLower(m.Entity) + "s",
+	}
+}
+
+var funcs = template.FuncMap{
+	"json":  func(f Field) string { return snakeCase(f.Name) },
+	"zero":  func(f Field) string { return supportedTypes[f.Type] },
+	"lower": lowerFirst,
+}
+
+// output pairs a template with its destination relative to the service dir
+type output struct {
+	template string
+	path     string
+}
+
+func outputs(d templateData) []output {
+	return []output{
+		{"domain.go.tmpl", filepath.Join("domain", d.Package+".go")},
+		{"usecase.go.tmpl", filepath.Join("usecase", d.Package+"_usecase.go")},
+		{"usecase_test.go.tmpl", filepath.Join("usecase", d.Package+"_usecase_test.go")},
+		{"repository.go.tmpl", filepath.Join("repository", d.Package+"_repository.go")},
+		{"handler.go.tmpl", filepath.Join("handler", d.Package+"_handler.go")},
+		{"main.go.tmpl", "main.go"},
+	}
+}
+
+// Generate renders every template into dir, refusing to overwrite files
+func Generate(m Manifest, dir string, force bool) ([]string, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("scaffold").Funcs(funcs).ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	data := newTemplateData(m)
+	// Check every destination first, so a refusal leaves no half-written service
+	if !force {
+		for _, o := range outputs(data) {
+			path := filepath.Join(dir, o.path)
+			if _, err := os.Stat(path); err == nil {
+				return nil, fmt.Errorf("%s already exists (use -force to overwrite)", path)
+			}
+		}
+	}
+
+	var written []string
+	for _, o := range outputs(data) {
+		path := filepath.Join(dir, o.path)
+		var buf strings.Builder
+		if err := tmpl.ExecuteTemplate(&buf, o.template, data); err != nil {
+			return written, fmt.Errorf("render %s: %w", o.template, err)
+		}
+		src, err := format.Source([]byte(buf.String()))
+		if err != nil {
+			return written, fmt.Errorf("format %s: %w", o.path, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+func loadManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// lowerFirst lowercases the leading word, keeping acronyms intact: SKU -> sku, SKUCode -> skuCode
+func lowerFirst(s string) string {
+	r := []rune(s)
+	for i := range r {
+		if !unicode.IsUpper(r[i]) || (i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// snakeCase converts a Go field name to a JSON key: UnitPrice -> unit_price, SKUCode -> sku_code
+func snakeCase(s string) string {
+	r := []rune(s)
+	var b strings.Builder
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			prevLower := i > 0 && unicode.IsLower(r[i-1])
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if i > 0 && (prevLower || (nextLower && unicode.IsUpper(r[i-1]))) {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func main() {
+	manifestPath := flag.String("manifest", "", "path to the service manifest (JSON)")
+	out := flag.String("out", ".", "directory in which <service>-service is created")
+	force := flag.Bool("force", false, "overwrite existing files")
+	flag.Parse()
+
+	if *manifestPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m, err := loadManifest(*manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	dir := filepath.Join(*out, m.Service+"-service")
+	files, err := Generate(m, dir, *force)
+	if err != nil {
+		log.Fatalf("Failed to generate service: %v", err)
+	}
+
+	for _, f := range files {
+		fmt.Println("created", f)
+	}
+	fmt.Printf("\nRun it with: cd %s && go run main.go\n", dir)
+}
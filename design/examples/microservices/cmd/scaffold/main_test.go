@@ -0,0 +1,159 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNameHelpers(t *testing.T) {
+	tests := []struct {
+		in, lower, snake string
+	}{
+		{"Item", "item", "item"},
+		{"UnitPrice", "unitPrice", "unit_price"},
+		{"SKU", "sku", "sku"},
+		{"SKUCode", "skuCode", "sku_code"},
+		{"ProductID", "productID", "product_id"},
+		{"HTTPServerURL", "httpServerURL", "http_server_url"},
+		{"A", "a", "a"},
+		{"already", "already", "already"},
+	}
+	for _, tt := range tests {
+		if got := lowerFirst(tt.in); got != tt.lower {
+			t.Errorf("lowerFirst(%q) = %q, want %q", tt.in, got, tt.lower)
+		}
+		if got := snakeCase(tt.in); got != tt.snake {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.in, got, tt.snake)
+		}
+	}
+}
+
+func exampleManifest(t *testing.T) Manifest {
+	t.Helper()
+	m, err := loadManifest("manifest.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManifestValidate(t *testing.T) {
+	if m := exampleManifest(t); m.Validate() != nil {
+		t.Fatalf("the example manifest is invalid: %v", m.Validate())
+	}
+
+	tests := []struct {
+		name   string
+		change func(m *Manifest)
+		want   string
+	}{
+		{"no service", func(m *Manifest) { m.Service = "" }, "service is required"},
+		{"a service name with a path", func(m *Manifest) { m.Service = "../inventory" }, `invalid service name "../inventory"`},
+		{"port 0", func(m *Manifest) { m.Port = 0 }, "invalid port 0"},
+		{"a port out of range", func(m *Manifest) { m.Port = 70000 }, "invalid port 70000"},
+		{"an unexported entity", func(m *Manifest) { m.Entity = "item" }, `entity "item" must be an exported Go identifier`},
+		{"no fields", func(m *Manifest) { m.Fields = nil }, "at least one field is required"},
+		{"an unexported field", func(m *Manifest) { m.Fields[0].Name = "name" }, `field "name" must be an exported Go identifier`},
+		{"a declared ID", func(m *Manifest) { m.Fields[0].Name = "ID" }, "ID is generated"},
+		{"a duplicate field", func(m *Manifest) { m.Fields[1].Name = m.Fields[0].Name }, `duplicate field "Name"`},
+		{"an unsupported type", func(m *Manifest) { m.Fields[2].Type = "time.Time" }, `field "Quantity" has unsupported type "time.Time"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := exampleManifest(t)
+			tt.change(&m)
+			err := m.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "inventory-service")
+	files, err := Generate(exampleManifest(t), dir, false)
+	if err != nil {
+		t.Fatalf("Generate = %v", err)
+	}
+	if len(files) != 6 {
+		t.Errorf("Generate wrote %d files, want 6: %v", len(files), files)
+	}
+	for _, f := range files {
+		if _, err := parser.ParseFile(token.NewFileSet(), f, nil, parser.AllErrors); err != nil {
+			t.Errorf("%s does not parse: %v", f, err)
+		}
+	}
+
+	t.Run("existing files are not overwritten", func(t *testing.T) {
+		// Only main.go, the last file generated, is in the way
+		dir := filepath.Join(t.TempDir(), "inventory-service")
+		main := filepath.Join(dir, "main.go")
+		os.MkdirAll(dir, 0o755)
+		os.WriteFile(main, []byte("// edited by hand\n"), 0o644)
+
+		written, err := Generate(exampleManifest(t), dir, false)
+		if err == nil || !strings.Contains(err.Error(), "already exists") || len(written) != 0 {
+			t.Fatalf("Generate = %v, %v, want an error before writing anything", written, err)
+		}
+		if src, _ := os.ReadFile(main); string(src) != "// edited by hand\n" {
+			t.Error("Generate overwrote main.go")
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("Generate left %d entries in %s, want only main.go", len(entries), dir)
+		}
+		if written, err := Generate(exampleManifest(t), dir, true); err != nil || len(written) != 6 {
+			t.Fatalf("Generate with force = %v, %v, want all 6 files written", written, err)
+		}
+	})
+
+	t.Run("an invalid manifest writes nothing", func(t *testing.T) {
+		m := exampleManifest(t)
+		m.Port = 0
+		empty := filepath.Join(root, "empty-service")
+		if _, err := Generate(m, empty, false); err == nil {
+			t.Fatal("Generate = nil, want the validation error")
+		}
+		if _, err := os.Stat(empty); !os.IsNotExist(err) {
+			t.Errorf("Generate created %s for an invalid manifest", empty)
+		}
+	})
+
+	t.Run("the service vets and passes its own test", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("runs the go command")
+		}
+		// Make the temp dir a copy of this module: its go.mod, so the generated
+		// import paths resolve, and the lifecycle package the service imports
+		mod, err := os.ReadFile("../../go.mod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		module, err := filepath.Abs("../..")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mod = []byte(strings.ReplaceAll(string(mod), "=> ../", "=> "+filepath.Dir(module)+string(filepath.Separator)))
+		sum, _ := os.ReadFile("../../go.sum")
+		os.WriteFile(filepath.Join(root, "go.mod"), mod, 0o644)
+		os.WriteFile(filepath.Join(root, "go.sum"), sum, 0o644)
+		if err := os.Symlink(filepath.Join(module, "lifecycle"), filepath.Join(root, "lifecycle")); err != nil {
+			t.Skipf("cannot link the lifecycle package: %v", err)
+		}
+
+		for _, args := range [][]string{{"vet", "./inventory-service/..."}, {"test", "./inventory-service/..."}} {
+			cmd := exec.Command("go", args...)
+			cmd.Dir = root
+			cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+			}
+		}
+	})
+}
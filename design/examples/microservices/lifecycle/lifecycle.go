@@ -0,0 +1,197 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Service lifecycle: starting -> warming -> ready -> draining.
+// A freshly started process has empty connection pools, cold caches and
+// templates that have never run, so its first requests are much slower than
+// the rest. The Manager runs warm-up steps after the listener is up and only
+// reports ready (GET /ready) once they finish; until then the Gate middleware
+// turns real traffic away so the load balancer keeps sending it elsewhere.
+
+type State int32
+
+const (
+	StateStarting State = iota
+	StateWarming
+	StateReady
+	StateDraining
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateWarming:
+		return "warming"
+	case StateReady:
+		return "ready"
+	case StateDraining:
+		return "draining"
+	}
+	return "unknown"
+}
+
+// syntheticKey marks warm-up requests so they pass the Gate and can be left
+// out of request metrics. Only SyntheticRequests sets it: a request from the
+// network cannot, so a client cannot skip the Gate by claiming to be warm-up
+// traffic the way it could with a header.
+type syntheticKey struct{}
+
+// IsSynthetic reports whether c is a warm-up request sent by
+// SyntheticRequests. It fits echo's Skipper, e.g. to keep warm-up traffic out
+// of the access log.
+func IsSynthetic(c echo.Context) bool {
+	synthetic, _ := c.Request().Context().Value(syntheticKey{}).(bool)
+	return synthetic
+}
+
+// Step is one unit of warm-up work. A failing Optional step is logged in the
+// report but does not keep the service from becoming ready.
+type Step struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Optional bool
+	Timeout  time.Duration // zero means no per-step limit
+}
+
+type StepResult struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+	Optional   bool    `json:"optional,omitempty"`
+}
+
+type Manager struct {
+	state atomic.Int32
+
+	mu      sync.Mutex
+	steps   []Step
+	results []StepResult
+	warmed  time.Duration
+}
+
+func New() *Manager {
+	return &Manager{}
+}
+
+func (m *Manager) Add(steps ...Step) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.steps = append(m.steps, steps...)
+}
+
+// Warm runs every step in order and marks the service ready. It stops at the
+// first required step that fails and leaves the service not ready.
+func (m *Manager) Warm(ctx context.Context) error {
+	m.mu.Lock()
+	steps := append([]Step(nil), m.steps...)
+	m.results = nil
+	m.mu.Unlock()
+
+	m.state.Store(int32(StateWarming))
+	start := time.Now()
+	for _, step := range steps {
+		stepStart := time.Now()
+		err := runStep(ctx, step)
+		result := StepResult{
+			Name:       step.Name,
+			DurationMs: float64(time.Since(stepStart).Microseconds()) / 1000,
+			Optional:   step.Optional,
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		m.mu.Lock()
+		m.results = append(m.results, result)
+		m.mu.Unlock()
+
+		if err != nil && !step.Optional {
+			return fmt.Errorf("warm-up step %q: %w", step.Name, err)
+		}
+	}
+
+	m.mu.Lock()
+	m.warmed = time.Since(start)
+	m.mu.Unlock()
+	m.state.CompareAndSwap(int32(StateWarming), int32(StateReady))
+	return nil
+}
+
+func runStep(ctx context.Context, step Step) error {
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+	return step.Run(ctx)
+}
+
+// Drain marks the service as shutting down so readiness fails before the
+// server stops accepting connections
+func (m *Manager) Drain() {
+	m.state.Store(int32(StateDraining))
+}
+
+func (m *Manager) State() State {
+	return State(m.state.Load())
+}
+
+func (m *Manager) Ready() bool {
+	return m.State() == StateReady
+}
+
+// Report returns the result of each warm-up step that has run
+func (m *Manager) Report() []StepResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]StepResult(nil), m.results...)
+}
+
+// ReadyHandler answers the readiness probe: 200 once warm, 503 otherwise
+func (m *Manager) ReadyHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		m.mu.Lock()
+		body := map[string]interface{}{
+			"state":     m.State().String(),
+			"warmup":    append([]StepResult(nil), m.results...),
+			"warmup_ms": m.warmed.Milliseconds(),
+		}
+		m.mu.Unlock()
+
+		status := http.StatusOK
+		if !m.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		return c.JSON(status, body)
+	}
+}
+
+// Gate rejects requests with 503 until the service is ready, except synthetic
+// warm-up requests and the paths listed in always (e.g. /health, /ready)
+func (m *Manager) Gate(always ...string) echo.MiddlewareFunc {
+	open := make(map[string]bool, len(always))
+	for _, p := range always {
+		open[p] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if m.Ready() || open[c.Request().URL.Path] || IsSynthetic(c) {
+				return next(c)
+			}
+			c.Response().Header().Set("Retry-After", "1")
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error": "service is " + m.State().String(),
+			})
+		}
+	}
+}
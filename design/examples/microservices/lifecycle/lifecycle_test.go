@@ -0,0 +1,95 @@
+package lifecycle_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dong-tran/docs/microservices-example/lifecycle"
+	"github.com/labstack/echo/v4"
+)
+
+// newServer gates a route behind life and counts the requests that reach it
+// as real traffic
+func newServer(life *lifecycle.Manager) (*echo.Echo, *int) {
+	real := 0
+	e := echo.New()
+	e.Use(life.Gate("/ready"))
+	e.GET("/ready", life.ReadyHandler())
+	e.GET("/items", func(c echo.Context) error {
+		if !lifecycle.IsSynthetic(c) {
+			real++
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	return e, &real
+}
+
+func get(h http.Handler, path string, header http.Header) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestGate(t *testing.T) {
+	life := lifecycle.New()
+	e, real := newServer(life)
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		header http.Header
+		want   int
+	}{
+		{"real traffic is turned away while warming", "/items", nil, http.StatusServiceUnavailable},
+		{"a client claiming to be warm-up traffic is turned away too", "/items",
+			http.Header{"X-Synthetic-Request": {"warmup"}}, http.StatusServiceUnavailable},
+		{"the readiness probe always answers", "/ready", nil, http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := get(e, tc.path, tc.header); got != tc.want {
+				t.Errorf("GET %s = %d, want %d", tc.path, got, tc.want)
+			}
+		})
+	}
+
+	life.Add(lifecycle.SyntheticRequests(e, 3, "GET /items"))
+	t.Run("warm-up requests pass the gate and are not counted as real", func(t *testing.T) {
+		if err := life.Warm(context.Background()); err != nil {
+			t.Fatalf("Warm = %v", err)
+		}
+		if *real != 0 {
+			t.Errorf("%d warm-up requests counted as real", *real)
+		}
+	})
+	t.Run("once ready, real traffic gets through", func(t *testing.T) {
+		if got := get(e, "/items", nil); got != http.StatusOK || *real != 1 {
+			t.Errorf("GET /items = %d with %d real requests, want 200 and 1", got, *real)
+		}
+	})
+	t.Run("draining fails readiness", func(t *testing.T) {
+		life.Drain()
+		if got := get(e, "/ready", nil); got != http.StatusServiceUnavailable {
+			t.Errorf("GET /ready = %d, want 503", got)
+		}
+	})
+}
+
+func TestWarmStopsAtARequiredStep(t *testing.T) {
+	life := lifecycle.New()
+	life.Add(
+		lifecycle.Step{Name: "optional", Optional: true, Run: func(context.Context) error { return context.Canceled }},
+		lifecycle.Step{Name: "required", Run: func(context.Context) error { return context.Canceled }},
+	)
+	if err := life.Warm(context.Background()); err == nil || life.Ready() {
+		t.Errorf("Warm = %v, ready %t; want the required step's error and not ready", err, life.Ready())
+	}
+	if report := life.Report(); len(report) != 2 || report[0].Error == "" {
+		t.Errorf("Report = %+v, want both steps with errors", report)
+	}
+}
@@ -0,0 +1,136 @@
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Ready-made warm-up steps for the usual sources of cold-start latency
+
+// WarmConnections opens n pool connections at once and pings each, so the
+// first n concurrent requests find an established connection instead of each
+// paying for a TCP + TLS + auth handshake. The pool must keep at least n idle
+// connections (db.SetMaxIdleConns) or the extra ones are closed on release.
+func WarmConnections(db *sql.DB, n int) Step {
+	return Step{
+		Name: fmt.Sprintf("db connections (%d)", n),
+		Run: func(ctx context.Context) error {
+			conns := make([]*sql.Conn, 0, n)
+			defer func() {
+				for _, c := range conns {
+					c.Close() // returns it to the pool, still open
+				}
+			}()
+			for i := 0; i < n; i++ {
+				// Hold each connection so the next iteration has to open a new one
+				c, err := db.Conn(ctx)
+				if err != nil {
+					return err
+				}
+				conns = append(conns, c)
+				if err := c.PingContext(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// PrimeCache loads hot keys ahead of traffic, using up to parallel loaders
+func PrimeCache(name string, keys []string, parallel int, load func(ctx context.Context, key string) error) Step {
+	if parallel < 1 {
+		parallel = 1
+	}
+	return Step{
+		Name:     fmt.Sprintf("cache %s (%d keys)", name, len(keys)),
+		Optional: true, // a cold cache is slower, not broken
+		Run: func(ctx context.Context) error {
+			var (
+				wg       sync.WaitGroup
+				mu       sync.Mutex
+				failures []error
+			)
+			next := make(chan string)
+			for i := 0; i < parallel; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for key := range next {
+						if err := load(ctx, key); err != nil {
+							mu.Lock()
+							failures = append(failures, fmt.Errorf("%s: %w", key, err))
+							mu.Unlock()
+						}
+					}
+				}()
+			}
+			for _, key := range keys {
+				if ctx.Err() != nil {
+					break
+				}
+				next <- key
+			}
+			close(next)
+			wg.Wait()
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return errors.Join(failures...)
+		},
+	}
+}
+
+// CompileTemplates executes every template in t once against data. html/template
+// escapes a template lazily, on its first Execute, which makes the first page
+// render several times slower than the rest; this does that work up front.
+// Errors from the data itself (e.g. a nil field) are ignored, escaping errors are not.
+func CompileTemplates(t *template.Template, data interface{}) Step {
+	return Step{
+		Name: "templates",
+		Run: func(ctx context.Context) error {
+			for _, tmpl := range t.Templates() {
+				err := t.ExecuteTemplate(io.Discard, tmpl.Name(), data)
+				var escapeErr *template.Error
+				if errors.As(err, &escapeErr) {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// SyntheticRequests sends rounds of requests through h in-process, exercising
+// routing, middleware, handlers and everything behind them. Targets are
+// "METHOD /path" strings. Any 5xx response fails the step.
+func SyntheticRequests(h http.Handler, rounds int, targets ...string) Step {
+	return Step{
+		Name: fmt.Sprintf("synthetic requests (%d x %d)", rounds, len(targets)),
+		Run: func(ctx context.Context) error {
+			for i := 0; i < rounds; i++ {
+				for _, target := range targets {
+					method, path, ok := strings.Cut(target, " ")
+					if !ok {
+						return fmt.Errorf("target %q: want \"METHOD /path\"", target)
+					}
+					req := httptest.NewRequest(method, path, nil).WithContext(context.WithValue(ctx, syntheticKey{}, true))
+					rec := httptest.NewRecorder()
+					h.ServeHTTP(rec, req)
+					if rec.Code >= http.StatusInternalServerError {
+						return fmt.Errorf("%s: status %d", target, rec.Code)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
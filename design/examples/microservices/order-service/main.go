@@ -1,8 +1,15 @@
 package main
 
 import (
+"context"
+"github.com/dong-tran/docs/concurrency-example/shutdown"
+"github.com/dong-tran/docs/microservices-example/lifecycle"
 "github.com/labstack/echo/v4"
+"log"
+"net"
 "net/http"
+"os"
+"time"
 )
 
 type Order struct {
@@ -15,6 +22,11 @@ type Order struct {
 func main() {
 	e := echo.New()
 
+	// Readiness: real traffic is turned away until warm-up has run
+	life := lifecycle.New()
+	e.Use(life.Gate("/ready"))
+	e.GET("/ready", life.ReadyHandler())
+
 	e.POST("/orders", func(c echo.Context) error {
 var order Order
 if err := c.Bind(&order); err != nil {
@@ -34,5 +46,65 @@ Total:     999.99,
 return c.JSON(http.StatusOK, order)
 })
 
-	e.Start(":8083")
+	// Recent orders, optionally for one user
+	e.GET("/orders", func(c echo.Context) error {
+		userID := c.QueryParam("user_id")
+		if userID == "" {
+			userID = "user-1"
+		}
+		orders := []Order{
+			{ID: "order-122", UserID: userID, ProductID: "product-2", Total: 49.50},
+			{ID: "order-123", UserID: userID, ProductID: "product-1", Total: 999.99},
+		}
+		return c.JSON(http.StatusOK, orders)
+	})
+
+	// Warm-up exercises the request path before reporting ready. SKIP_WARMUP=1
+	// starts cold, to compare first-request latency.
+	if os.Getenv("SKIP_WARMUP") == "" {
+		life.Add(lifecycle.SyntheticRequests(e, 20, "GET /orders", "GET /orders/warmup"))
+	}
+
+	// Lifecycle: serve, then warm up; on SIGINT/SIGTERM fail readiness and
+	// finish the requests in flight
+	app := shutdown.New(shutdown.Options{StopTimeout: 10 * time.Second, Logf: log.Printf})
+	app.Register(
+		shutdown.Component{
+			Name: "http",
+			Start: func(context.Context) error {
+				ln, err := net.Listen("tcp", ":8083")
+				if err != nil {
+					return err
+				}
+				e.Listener = ln
+				go func() {
+					if err := e.Start(""); err != nil && err != http.ErrServerClosed {
+						log.Fatalf("Order service stopped: %v", err)
+					}
+				}()
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				life.Drain()
+				return e.Shutdown(ctx)
+			},
+		},
+		shutdown.Component{
+			Name:      "warmup",
+			DependsOn: []string{"http"},
+			Start: func(ctx context.Context) error {
+				if err := life.Warm(ctx); err != nil {
+					return err
+				}
+				for _, step := range life.Report() {
+					log.Printf("warm-up %s: %.1fms %s", step.Name, step.DurationMs, step.Error)
+				}
+				log.Println("Order service ready")
+				return nil
+			},
+		},
+	)
+	if err := app.Run(context.Background(), 15*time.Second); err != nil {
+		log.Fatalf("Order service: %v", err)
+	}
 }
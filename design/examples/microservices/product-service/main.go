@@ -1,8 +1,17 @@
 package main
 
 import (
+"context"
+"github.com/dong-tran/docs/concurrency-example/shutdown"
+"github.com/dong-tran/docs/microservices-example/cache"
+"github.com/dong-tran/docs/microservices-example/lifecycle"
 "github.com/labstack/echo/v4"
+"log"
+"net"
 "net/http"
+"os"
+"strconv"
+"time"
 )
 
 type Product struct {
@@ -11,14 +20,51 @@ type Product struct {
 	Price float64 `json:"price"`
 }
 
+// hotProducts are loaded into the cache before the service reports ready
+var hotProducts = []string{"1", "2"}
+
+// loadProduct stands in for the catalog database: correct, but slow
+func loadProduct(ctx context.Context, id string) (Product, error) {
+	select {
+	case <-time.After(20 * time.Millisecond):
+	case <-ctx.Done():
+		return Product{}, ctx.Err()
+	}
+	return Product{ID: id, Name: "Laptop", Price: 999.99}, nil
+}
+
 func main() {
 	e := echo.New()
 
+	// Product lookups are cached for 30s. Keys that take at least 5% of reads
+	// are kept for up to 5 minutes instead, as long as they stay hot.
+	products := cache.New[Product](cache.Config{TTL: 30 * time.Second})
+	stats := cache.NewStats()
+	hot := cache.NewHotKeys(100)
+	products.Subscribe(stats)
+	products.Subscribe(hot)
+	products.Subscribe(&cache.HotTTL[Product]{Cache: products, Hot: hot, MinShare: 0.05, TTL: 2 * time.Minute, MaxAge: 5 * time.Minute})
+
+	// Readiness: real traffic is turned away until the hot products are cached
+	life := lifecycle.New()
+	e.Use(life.Gate("/ready"))
+	e.GET("/ready", life.ReadyHandler())
+	// SKIP_WARMUP=1 starts with a cold cache, to compare first-request latency
+	if os.Getenv("SKIP_WARMUP") == "" {
+		life.Add(lifecycle.PrimeCache("products", hotProducts, 4, func(ctx context.Context, id string) error {
+			product, err := loadProduct(ctx, id)
+			if err != nil {
+				return err
+			}
+			products.Set(id, product)
+			return nil
+		}))
+	}
+
 	e.GET("/products/:id", func(c echo.Context) error {
-product := Product{
-ID:    c.Param("id"),
-Name:  "Laptop",
-Price: 999.99,
+product, err := products.GetOrLoad(c.Request().Context(), c.Param("id"), loadProduct)
+if err != nil {
+return err
 }
 return c.JSON(http.StatusOK, product)
 })
@@ -31,5 +77,60 @@ products := []Product{
 return c.JSON(http.StatusOK, products)
 })
 
-	e.Start(":8082")
+	// GET /debug/cache?top=N - hit ratio, access counts and the N hottest keys
+	e.GET("/debug/cache", func(c echo.Context) error {
+top, err := strconv.Atoi(c.QueryParam("top"))
+if err != nil || top < 1 {
+top = 10
+}
+return c.JSON(http.StatusOK, map[string]interface{}{
+"entries":  products.Len(),
+"reads":    hot.Reads(),
+"stats":    stats.Snapshot(),
+"hot_keys": hot.Top(top),
+})
+})
+
+	// Lifecycle: serve, then prime the cache; on SIGINT/SIGTERM fail readiness
+	// and finish the requests in flight
+	app := shutdown.New(shutdown.Options{StopTimeout: 10 * time.Second, Logf: log.Printf})
+	app.Register(
+		shutdown.Component{
+			Name: "http",
+			Start: func(context.Context) error {
+				ln, err := net.Listen("tcp", ":8082")
+				if err != nil {
+					return err
+				}
+				e.Listener = ln
+				go func() {
+					if err := e.Start(""); err != nil && err != http.ErrServerClosed {
+						log.Fatalf("Product service stopped: %v", err)
+					}
+				}()
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				life.Drain()
+				return e.Shutdown(ctx)
+			},
+		},
+		shutdown.Component{
+			Name:      "warmup",
+			DependsOn: []string{"http"},
+			Start: func(ctx context.Context) error {
+				if err := life.Warm(ctx); err != nil {
+					return err
+				}
+				for _, step := range life.Report() {
+					log.Printf("warm-up %s: %.1fms %s", step.Name, step.DurationMs, step.Error)
+				}
+				log.Println("Product service ready")
+				return nil
+			},
+		},
+	)
+	if err := app.Run(context.Background(), 15*time.Second); err != nil {
+		log.Fatalf("Product service: %v", err)
+	}
 }
@@ -0,0 +1,179 @@
+package region
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bus carries replicated records between regions over links with a fixed
+// one-way latency. A link can be partitioned: messages sent meanwhile are
+// kept, in order, and delivered once it heals, as a durable queue between
+// data centres would. Each link delivers one message at a time, in the order
+// they were sent.
+
+type Bus struct {
+	mu      sync.Mutex
+	idle    *sync.Cond
+	members map[string]func(Record)
+	links   map[[2]string]*link
+	pending int
+}
+
+func NewBus() *Bus {
+	b := &Bus{members: make(map[string]func(Record)), links: make(map[[2]string]*link)}
+	b.idle = sync.NewCond(&b.mu)
+	return b
+}
+
+// Join registers the region that will apply what is sent to it
+func (b *Bus) Join(region string, deliver func(Record)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.members[region] = deliver
+}
+
+// Connect links a and b in both directions
+func (b *Bus) Connect(a, c string, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range [][2]string{{a, c}, {c, a}} {
+		l := &link{bus: b, to: key[1], latency: latency}
+		l.cond = sync.NewCond(&l.mu)
+		b.links[key] = l
+		go l.run()
+	}
+}
+
+// Publish sends r from region to every region it is linked to
+func (b *Bus) Publish(from string, r Record) {
+	b.mu.Lock()
+	var out []*link
+	for key, l := range b.links {
+		if key[0] == from {
+			out = append(out, l)
+		}
+	}
+	b.pending += len(out)
+	b.mu.Unlock()
+	for _, l := range out {
+		l.send(r)
+	}
+}
+
+// Partition cuts the link between a and c in both directions
+func (b *Bus) Partition(a, c string) {
+	b.setDown(a, c, true)
+}
+
+func (b *Bus) Heal(a, c string) {
+	b.setDown(a, c, false)
+}
+
+func (b *Bus) setDown(a, c string, down bool) {
+	b.mu.Lock()
+	links := []*link{b.links[[2]string{a, c}], b.links[[2]string{c, a}]}
+	b.mu.Unlock()
+	for _, l := range links {
+		if l != nil {
+			l.mu.Lock()
+			l.down = down
+			l.mu.Unlock()
+			l.cond.Broadcast()
+		}
+	}
+}
+
+// Wait blocks until every message sent so far has been delivered, or ctx
+// ends. Messages held by a partition are not delivered until it heals.
+func (b *Bus) Wait(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.idle.Broadcast()
+	})
+	defer stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.pending > 0 && ctx.Err() == nil {
+		b.idle.Wait()
+	}
+	return ctx.Err()
+}
+
+// Close stops every link; undelivered messages are dropped
+func (b *Bus) Close() {
+	b.mu.Lock()
+	links := b.links
+	b.mu.Unlock()
+	for _, l := range links {
+		l.mu.Lock()
+		l.closed = true
+		l.mu.Unlock()
+		l.cond.Broadcast()
+	}
+}
+
+func (b *Bus) delivered(to string, r Record) {
+	b.mu.Lock()
+	deliver := b.members[to]
+	b.mu.Unlock()
+	if deliver != nil {
+		deliver(r)
+	}
+	b.mu.Lock()
+	b.pending--
+	b.idle.Broadcast()
+	b.mu.Unlock()
+}
+
+type envelope struct {
+	record Record
+	sentAt time.Time
+}
+
+type link struct {
+	bus     *Bus
+	to      string
+	latency time.Duration
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []envelope
+	down   bool
+	closed bool
+}
+
+func (l *link) send(r Record) {
+	l.mu.Lock()
+	l.queue = append(l.queue, envelope{record: r, sentAt: time.Now()})
+	l.mu.Unlock()
+	l.cond.Signal()
+}
+
+func (l *link) run() {
+	for {
+		l.mu.Lock()
+		for (len(l.queue) == 0 || l.down) && !l.closed {
+			l.cond.Wait()
+		}
+		if l.closed {
+			l.mu.Unlock()
+			return
+		}
+		next := l.queue[0]
+		l.mu.Unlock()
+
+		time.Sleep(time.Until(next.sentAt.Add(l.latency)))
+
+		l.mu.Lock()
+		if l.down || l.closed {
+			// cut while in flight: keep it for when the link heals
+			l.mu.Unlock()
+			continue
+		}
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+		l.bus.delivered(l.to, next.record)
+	}
+}
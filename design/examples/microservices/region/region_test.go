@@ -0,0 +1,277 @@
+package region_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/microservices-example/region"
+	"github.com/labstack/echo/v4"
+)
+
+// The tests run the product service in two regions, eu and us, in one
+// process, with a gateway in eu in front of both:
+//
+//	client -> gateway (eu) -> product-service eu  <- bus ->  product-service us
+//	                       \-> product-service us
+//
+// Both regions and the gateway read one simulated clock; us's runs a minute
+// behind. The clock only moves when a test advances it, so which write is
+// "later" and when a cooldown ends never depend on scheduling.
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+const (
+	skew     = time.Minute
+	cooldown = 300 * time.Millisecond
+)
+
+type regionServer struct {
+	store   *region.Store
+	service *region.Service
+	server  *httptest.Server
+}
+
+func startRegion(t *testing.T, bus *region.Bus, name string, now func() time.Time) *regionServer {
+	store := region.NewStore(name, now, func(r region.Record) { bus.Publish(name, r) })
+	bus.Join(name, func(r region.Record) { store.Apply(r) })
+	service := region.NewService(store)
+	e := echo.New()
+	e.HideBanner = true
+	service.Register(e)
+	s := &regionServer{store: store, service: service, server: httptest.NewServer(e)}
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+type world struct {
+	clk    *clock
+	bus    *region.Bus
+	eu, us *regionServer
+	router *region.Router
+	api    string
+}
+
+func newWorld(t *testing.T) *world {
+	w := &world{clk: &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}, bus: region.NewBus()}
+	t.Cleanup(w.bus.Close)
+	w.bus.Connect("eu", "us", time.Millisecond)
+	w.eu = startRegion(t, w.bus, "eu", w.clk.Now)
+	w.us = startRegion(t, w.bus, "us", func() time.Time { return w.clk.Now().Add(-skew) })
+
+	w.router = region.NewRouter(region.RouterConfig{Local: "eu", FailureThreshold: 2, Cooldown: cooldown, Now: w.clk.Now},
+		region.Endpoint{Region: "eu", URL: w.eu.server.URL},
+		region.Endpoint{Region: "us", URL: w.us.server.URL})
+	gw := echo.New()
+	gw.Any("/api/products/*", w.router.Handler("/api"))
+	gateway := httptest.NewServer(gw)
+	t.Cleanup(gateway.Close)
+	w.api = gateway.URL + "/api"
+	return w
+}
+
+// settle waits until the bus has delivered everything in flight
+func (w *world) settle(t *testing.T) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := w.bus.Wait(ctx); err != nil {
+		t.Fatalf("replication does not settle: %v", err)
+	}
+}
+
+func (w *world) conflicts() (eu, us int) {
+	return w.eu.store.Stats().Conflicts, w.us.store.Stats().Conflicts
+}
+
+type result struct {
+	record region.Record
+	region string // region that served it
+	status int
+}
+
+func call(t *testing.T, method, url string, body interface{}) result {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, url, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	r := result{status: resp.StatusCode, region: resp.Header.Get("X-Served-By-Region")}
+	if r.region == "" {
+		r.region = resp.Header.Get("X-Region")
+	}
+	json.NewDecoder(resp.Body).Decode(&r.record)
+	return r
+}
+
+func put(t *testing.T, base, id string, price float64) result {
+	t.Helper()
+	return call(t, http.MethodPut, base+"/products/"+id, region.Product{Name: "Laptop", Price: price})
+}
+
+func get(t *testing.T, base, id string) result {
+	t.Helper()
+	return call(t, http.MethodGet, base+"/products/"+id, nil)
+}
+
+func TestReplication(t *testing.T) {
+	w := newWorld(t)
+
+	t.Run("a write goes to the local region and replicates to the other", func(t *testing.T) {
+		if r := put(t, w.api, "1", 999); r.status != http.StatusOK || r.region != "eu" {
+			t.Fatalf("PUT answered %d from %q, want 200 from eu", r.status, r.region)
+		}
+		w.settle(t)
+		if r := get(t, w.us.server.URL, "1"); r.record.Product.Price != 999 || r.record.Version.String() != "{eu:1}" {
+			t.Errorf("us has %.2f %s, want 999 {eu:1}", r.record.Product.Price, r.record.Version)
+		}
+	})
+
+	t.Run("a later write from the region with the slow clock wins", func(t *testing.T) {
+		w.clk.Advance(time.Second)
+		put(t, w.us.server.URL, "1", 899)
+		w.settle(t)
+		// By the clocks us wrote a minute before eu; last-write-wins alone would keep 999
+		if r := get(t, w.api, "1"); r.record.Product.Price != 899 {
+			t.Errorf("eu serves %.2f %s, want 899", r.record.Product.Price, r.record.Version)
+		}
+		if eu, us := w.conflicts(); eu != 0 || us != 0 {
+			t.Errorf("conflicts: eu %d, us %d; want none, the vectors show us saw eu's write", eu, us)
+		}
+	})
+}
+
+func TestPartition(t *testing.T) {
+	t.Run("both regions write: they diverge, then converge on one winner", func(t *testing.T) {
+		w := newWorld(t)
+		put(t, w.api, "1", 999)
+		w.settle(t)
+
+		w.bus.Partition("eu", "us")
+		put(t, w.api, "1", 850)
+		w.clk.Advance(10 * time.Millisecond)
+		put(t, w.us.server.URL, "1", 870)
+		a, b := get(t, w.api, "1"), get(t, w.us.server.URL, "1")
+		if a.status != http.StatusOK || b.status != http.StatusOK || a.record.Product.Price != 850 || b.record.Product.Price != 870 {
+			t.Errorf("cut off, eu serves %d %.2f and us %d %.2f; want both up, with 850 and 870",
+				a.status, a.record.Product.Price, b.status, b.record.Product.Price)
+		}
+
+		w.bus.Heal("eu", "us")
+		w.settle(t)
+		a, b = get(t, w.eu.server.URL, "1"), get(t, w.us.server.URL, "1")
+		if a.record.Product != b.record.Product || a.record.Version.String() != b.record.Version.String() {
+			t.Errorf("after healing eu holds %.2f %s, us %.2f %s", a.record.Product.Price, a.record.Version, b.record.Product.Price, b.record.Version)
+		}
+		if eu, us := w.conflicts(); eu != 1 || us != 1 {
+			t.Errorf("conflicts: eu %d, us %d; want each detected once", eu, us)
+		}
+		// us wrote later, but its clock says earlier: the limit of last-write-wins
+		if a.record.Origin != "eu" {
+			t.Errorf("the %s write survived, want eu's", a.record.Origin)
+		}
+	})
+
+	t.Run("one region writes: the other catches up in order, without conflicts", func(t *testing.T) {
+		w := newWorld(t)
+		put(t, w.api, "2", 29.99)
+		w.settle(t)
+
+		w.bus.Partition("eu", "us")
+		put(t, w.api, "2", 24.99)
+		put(t, w.api, "2", 19.99)
+		if r := get(t, w.us.server.URL, "2"); r.record.Product.Price != 29.99 {
+			t.Errorf("cut off, us serves %.2f, want the old 29.99", r.record.Product.Price)
+		}
+
+		w.bus.Heal("eu", "us")
+		w.settle(t)
+		if r := get(t, w.us.server.URL, "2"); r.record.Product.Price != 19.99 {
+			t.Errorf("us serves %.2f %s, want 19.99", r.record.Product.Price, r.record.Version)
+		}
+		if eu, us := w.conflicts(); eu != 0 || us != 0 {
+			t.Errorf("conflicts: eu %d, us %d; want none", eu, us)
+		}
+	})
+}
+
+func TestFailover(t *testing.T) {
+	w := newWorld(t)
+	put(t, w.api, "1", 999)
+	w.settle(t)
+	w.eu.service.SetFailing(true)
+
+	t.Run("reads fail over to us, and eu is skipped after 2 failures", func(t *testing.T) {
+		var served, tried []string
+		for i := 0; i < 3; i++ {
+			resp, err := w.router.Forward(context.Background(), http.MethodGet, "/products/1", nil, nil)
+			if err != nil {
+				t.Fatalf("request %d: %v", i+1, err)
+			}
+			var regions []string
+			for _, at := range resp.Attempts {
+				regions = append(regions, at.Region)
+			}
+			served = append(served, resp.Region)
+			tried = append(tried, strings.Join(regions, "+"))
+		}
+		if got := strings.Join(served, ","); got != "us,us,us" {
+			t.Errorf("served by %s, want us each time", got)
+		}
+		if tried[2] != "us" {
+			t.Errorf("tried %v, want eu left out of the third request", tried)
+		}
+	})
+	t.Run("writes fail over too, since PUT is idempotent", func(t *testing.T) {
+		if r := put(t, w.api, "3", 5); r.region != "us" {
+			t.Errorf("served by %q, want us", r.region)
+		}
+	})
+
+	w.eu.service.SetFailing(false)
+	t.Run("a recovered eu is still skipped during the cooldown", func(t *testing.T) {
+		if r := get(t, w.api, "1"); r.region != "us" {
+			t.Errorf("served by %q, want us", r.region)
+		}
+	})
+	t.Run("after the cooldown traffic goes back to eu", func(t *testing.T) {
+		w.clk.Advance(cooldown)
+		if r := get(t, w.api, "1"); r.region != "eu" {
+			t.Errorf("served by %q, want eu", r.region)
+		}
+	})
+	t.Run("the write us took during the outage reached eu", func(t *testing.T) {
+		w.settle(t)
+		if r := get(t, w.eu.server.URL, "3"); r.status != http.StatusOK {
+			t.Errorf("GET from eu = %d", r.status)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package region
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Router is the gateway's routing policy across regions. Requests go to the
+// local region. A region that errors or answers 5xx FailureThreshold times in
+// a row is skipped for Cooldown, and requests go to the nearest healthy
+// region instead; after the cooldown it gets traffic again, and one success
+// brings it back. Only idempotent requests are retried in another region:
+// a POST that timed out may still have been applied.
+
+// Endpoint is one region as seen from the gateway. Latency is added to every
+// call, standing in for the distance to a remote region.
+type Endpoint struct {
+	Region  string
+	URL     string
+	Latency time.Duration
+}
+
+type RouterConfig struct {
+	Local            string
+	FailureThreshold int           // default 3
+	Cooldown         time.Duration // default 5s
+	Timeout          time.Duration // per attempt, default 2s
+	Now              func() time.Time
+}
+
+// Attempt is one region tried for a request
+type Attempt struct {
+	Region string `json:"region"`
+	Status int    `json:"status,omitempty"`
+	Err    string `json:"error,omitempty"`
+}
+
+type Response struct {
+	Region   string // region that answered
+	Status   int
+	Header   http.Header
+	Body     []byte
+	Attempts []Attempt
+}
+
+type EndpointStats struct {
+	Region    string     `json:"region"`
+	Served    int        `json:"served"`
+	Failed    int        `json:"failed"`
+	DownUntil *time.Time `json:"down_until,omitempty"` // while skipped
+}
+
+type endpointState struct {
+	Endpoint
+	failures  int // consecutive
+	downUntil time.Time
+	served    int
+	failed    int
+}
+
+type Router struct {
+	cfg    RouterConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	endpoints []*endpointState
+}
+
+func NewRouter(cfg RouterConfig, endpoints ...Endpoint) *Router {
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 3
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	r := &Router{cfg: cfg, client: &http.Client{}}
+	for _, e := range endpoints {
+		r.endpoints = append(r.endpoints, &endpointState{Endpoint: e})
+	}
+	return r
+}
+
+// Order returns the regions in the order a request would try them: healthy
+// before skipped, local first, then nearest first. Skipped regions stay at
+// the end as a last resort.
+func (r *Router) Order() []Endpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.cfg.Now()
+	states := append([]*endpointState(nil), r.endpoints...)
+	sort.SliceStable(states, func(i, j int) bool {
+		a, b := states[i], states[j]
+		if downA, downB := now.Before(a.downUntil), now.Before(b.downUntil); downA != downB {
+			return downB
+		}
+		if localA, localB := a.Region == r.cfg.Local, b.Region == r.cfg.Local; localA != localB {
+			return localA
+		}
+		return a.Latency < b.Latency
+	})
+	order := make([]Endpoint, len(states))
+	for i, s := range states {
+		order[i] = s.Endpoint
+	}
+	return order
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// Forward sends the request to the first region in Order that answers
+// without a 5xx. If every region fails, the last failure is returned.
+func (r *Router) Forward(ctx context.Context, method, path string, header http.Header, body []byte) (*Response, error) {
+	order := r.Order()
+	if !idempotent(method) {
+		order = order[:1]
+	}
+	var attempts []Attempt
+	var last *Response
+	var lastErr error
+	for _, e := range order {
+		resp, err := r.try(ctx, e, method, path, header, body)
+		a := Attempt{Region: e.Region}
+		if err != nil {
+			a.Err = err.Error()
+		} else {
+			a.Status = resp.Status
+		}
+		attempts = append(attempts, a)
+		failed := err != nil || resp.Status >= 500
+		r.record(e.Region, failed)
+		if !failed {
+			resp.Attempts = attempts
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		last, lastErr = resp, err
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("all regions failed, last: %w", lastErr)
+	}
+	last.Attempts = attempts
+	return last, nil
+}
+
+func (r *Router) try(ctx context.Context, e Endpoint, method, path string, header http.Header, body []byte) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	select {
+	case <-time.After(e.Latency):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, e.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Region: e.Region, Status: resp.StatusCode, Header: resp.Header, Body: data}, nil
+}
+
+func (r *Router) record(region string, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.endpoints {
+		if s.Region != region {
+			continue
+		}
+		if !failed {
+			s.served++
+			s.failures = 0
+			s.downUntil = time.Time{}
+			return
+		}
+		s.failed++
+		if s.failures++; s.failures >= r.cfg.FailureThreshold {
+			s.downUntil = r.cfg.Now().Add(r.cfg.Cooldown)
+		}
+	}
+}
+
+func (r *Router) Stats() []EndpointStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make([]EndpointStats, len(r.endpoints))
+	for i, s := range r.endpoints {
+		stats[i] = EndpointStats{Region: s.Region, Served: s.served, Failed: s.failed}
+		if until := s.downUntil; r.cfg.Now().Before(until) {
+			stats[i].DownUntil = &until
+		}
+	}
+	return stats
+}
+
+// Handler proxies to the regions, dropping prefix from the path. The answer
+// carries the region that served it in X-Served-By-Region.
+func (r *Router) Handler(prefix string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		path := strings.TrimPrefix(req.URL.Path, prefix)
+		if req.URL.RawQuery != "" {
+			path += "?" + req.URL.RawQuery
+		}
+		resp, err := r.Forward(req.Context(), req.Method, path, req.Header, body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+		}
+		c.Response().Header().Set("X-Served-By-Region", resp.Region)
+		return c.Blob(resp.Status, resp.Header.Get("Content-Type"), resp.Body)
+	}
+}
@@ -0,0 +1,62 @@
+package region
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Service is the product service of one region, serving its own Store. Every
+// answer names the region in X-Region. SetFailing makes it answer 503 to
+// everything, to drill failover without stopping the server.
+type Service struct {
+	Store   *Store
+	failing atomic.Bool
+}
+
+func NewService(store *Store) *Service {
+	return &Service{Store: store}
+}
+
+func (s *Service) SetFailing(failing bool) {
+	s.failing.Store(failing)
+}
+
+func (s *Service) Register(e *echo.Echo) {
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set("X-Region", s.Store.Region())
+			if s.failing.Load() {
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "region unavailable")
+			}
+			return next(c)
+		}
+	})
+
+	e.GET("/products/:id", func(c echo.Context) error {
+		r, ok := s.Store.Get(c.Param("id"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "product not found")
+		}
+		return c.JSON(http.StatusOK, r)
+	})
+
+	// PUT /products/:id writes locally; other regions see it once it has
+	// been replicated
+	e.PUT("/products/:id", func(c echo.Context) error {
+		var p Product
+		if err := c.Bind(&p); err != nil {
+			return err
+		}
+		p.ID = c.Param("id")
+		return c.JSON(http.StatusOK, s.Store.Put(p))
+	})
+
+	e.GET("/debug/replication", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"region": s.Store.Region(),
+			"stats":  s.Store.Stats(),
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package region
+
+import (
+	"sync"
+	"time"
+)
+
+// Store is one region's copy of the product catalog. Local writes are
+// published for the other regions to Apply. Concurrent writes (neither
+// version vector dominates) are resolved by last-write-wins on UpdatedAt, with
+// the origin region breaking ties, so every region picks the same winner
+// without talking to the others and all copies converge once the messages
+// are through.
+
+type Product struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// Record is a product as replicated between regions
+type Record struct {
+	Product   Product       `json:"product"`
+	Version   VersionVector `json:"version"`
+	UpdatedAt time.Time     `json:"updated_at"` // wall clock of the region that wrote it
+	Origin    string        `json:"origin"`     // region that wrote it
+}
+
+// wins reports whether r beats other under last-write-wins
+func (r Record) wins(other Record) bool {
+	if !r.UpdatedAt.Equal(other.UpdatedAt) {
+		return r.UpdatedAt.After(other.UpdatedAt)
+	}
+	return r.Origin > other.Origin
+}
+
+type Resolution string
+
+const (
+	Accepted     Resolution = "accepted"     // the incoming write saw ours; take it
+	Stale        Resolution = "stale"        // we have seen the incoming write already
+	ConflictWon  Resolution = "conflict-won" // concurrent, and the incoming write wins
+	ConflictLost Resolution = "conflict-lost"
+)
+
+type ReplicationStats struct {
+	LocalWrites int `json:"local_writes"`
+	Accepted    int `json:"accepted"`
+	Stale       int `json:"stale"`
+	Conflicts   int `json:"conflicts"`
+}
+
+type Store struct {
+	region  string
+	now     func() time.Time
+	publish func(Record)
+
+	mu      sync.Mutex
+	records map[string]Record
+	stats   ReplicationStats
+}
+
+// NewStore returns region's store. now is its wall clock (nil for
+// time.Now), publish sends local writes to the other regions.
+func NewStore(region string, now func() time.Time, publish func(Record)) *Store {
+	if now == nil {
+		now = time.Now
+	}
+	return &Store{region: region, now: now, publish: publish, records: make(map[string]Record)}
+}
+
+func (s *Store) Region() string {
+	return s.region
+}
+
+func (s *Store) Get(id string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	return r, ok
+}
+
+// Put writes p locally, as a write that has seen everything this region has
+func (s *Store) Put(p Product) Record {
+	s.mu.Lock()
+	version := s.records[p.ID].Version.Clone()
+	version[s.region]++
+	r := Record{Product: p, Version: version, UpdatedAt: s.now(), Origin: s.region}
+	s.records[p.ID] = r
+	s.stats.LocalWrites++
+	s.mu.Unlock()
+
+	if s.publish != nil {
+		s.publish(r)
+	}
+	return r
+}
+
+// Apply merges a write replicated from another region
+func (s *Store) Apply(in Record) Resolution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.records[in.Product.ID]
+	if !ok {
+		s.records[in.Product.ID] = in
+		s.stats.Accepted++
+		return Accepted
+	}
+
+	switch in.Version.Compare(current.Version) {
+	case After:
+		s.records[in.Product.ID] = in
+		s.stats.Accepted++
+		return Accepted
+	case Before, Equal:
+		s.stats.Stale++
+		return Stale
+	}
+
+	s.stats.Conflicts++
+	winner, res := current, ConflictLost
+	if in.wins(current) {
+		winner, res = in, ConflictWon
+	}
+	// The merged vector has seen both writes, so neither comes back as new
+	winner.Version = current.Version.Merge(in.Version)
+	s.records[in.Product.ID] = winner
+	return res
+}
+
+func (s *Store) Stats() ReplicationStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
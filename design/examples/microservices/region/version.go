@@ -0,0 +1,93 @@
+package region
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VersionVector counts the writes each region has made to a record. Comparing
+// two vectors tells whether one write saw the other (it happened after it) or
+// whether they were made independently, e.g. on both sides of a partition.
+// Timestamps cannot tell these apart; vectors can, whatever the clocks say.
+type VersionVector map[string]uint64
+
+type Ordering int
+
+const (
+	Equal Ordering = iota
+	Before
+	After
+	Concurrent
+)
+
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "equal"
+	case Before:
+		return "before"
+	case After:
+		return "after"
+	case Concurrent:
+		return "concurrent"
+	}
+	return "unknown"
+}
+
+// Compare returns how v relates to other: Before means every write v has
+// seen, other has seen too, plus at least one more
+func (v VersionVector) Compare(other VersionVector) Ordering {
+	less, greater := false, false
+	for r, n := range v {
+		if n > other[r] {
+			greater = true
+		}
+	}
+	for r, n := range other {
+		if n > v[r] {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// Merge returns a vector that has seen every write either vector has seen
+func (v VersionVector) Merge(other VersionVector) VersionVector {
+	merged := v.Clone()
+	for r, n := range other {
+		if n > merged[r] {
+			merged[r] = n
+		}
+	}
+	return merged
+}
+
+func (v VersionVector) Clone() VersionVector {
+	c := make(VersionVector, len(v))
+	for r, n := range v {
+		c[r] = n
+	}
+	return c
+}
+
+func (v VersionVector) String() string {
+	regions := make([]string, 0, len(v))
+	for r := range v {
+		regions = append(regions, r)
+	}
+	sort.Strings(regions)
+	parts := make([]string, len(regions))
+	for i, r := range regions {
+		parts[i] = fmt.Sprintf("%s:%d", r, v[r])
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
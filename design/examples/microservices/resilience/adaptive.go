@@ -0,0 +1,196 @@
+package resilience
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Adaptive concurrency limiting.
+// Admission uses a fixed MaxConcurrent (a static bulkhead) that has to be sized
+// up front. AdaptiveLimiter discovers the limit instead: it watches request
+// latency and lets the allowed number of in-flight requests grow while latency
+// stays flat and shrink as soon as the backend starts to queue. Requests over
+// the current limit are rejected immediately.
+
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+// LimitAlgorithm computes the next limit from one completed request
+type LimitAlgorithm interface {
+	Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64
+}
+
+// AIMD grows the limit by one per window of successful requests and cuts it by
+// Backoff when a request fails or exceeds Timeout (like TCP congestion control).
+// It backs off at most once per window, so one burst of slow responses counts
+// as a single congestion signal.
+type AIMD struct {
+	Timeout time.Duration
+	Backoff float64 // multiplicative decrease, e.g. 0.9
+
+	mu           sync.Mutex
+	sinceBackoff float64
+}
+
+func (a *AIMD) Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sinceBackoff++
+
+	if dropped || rtt > a.Timeout {
+		if a.sinceBackoff < limit {
+			return limit
+		}
+		a.sinceBackoff = 0
+		return limit * a.Backoff
+	}
+	// Only grow when the limit is actually being used
+	if float64(inFlight)*2 >= limit {
+		return limit + 1/limit
+	}
+	return limit
+}
+
+// Gradient compares recent latency with the no-load latency. When requests take
+// longer than the baseline the backend is queueing, and the limit shrinks in
+// proportion; otherwise it grows by a small queue allowance (sqrt of the limit).
+// The baseline is the minimum latency seen, allowed to creep upward by Drift per
+// sample so it can follow a backend whose no-load latency genuinely increased.
+type Gradient struct {
+	Smoothing float64 // weight of each new limit, e.g. 0.2
+	Drift     float64 // baseline creep per sample, e.g. 0.001
+
+	mu       sync.Mutex
+	baseline float64
+	recent   float64
+}
+
+func (g *Gradient) Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sample := float64(rtt)
+	if g.baseline == 0 {
+		g.baseline, g.recent = sample, sample
+	}
+	g.baseline = math.Min(g.baseline*(1+g.Drift), sample)
+	g.recent = ema(g.recent, sample, 0.1)
+
+	if dropped {
+		return limit * 0.9
+	}
+	if float64(inFlight)*2 < limit {
+		return limit // app-limited: latency says nothing about capacity
+	}
+	gradient := math.Max(0.5, math.Min(1, g.baseline/g.recent))
+	next := limit*gradient + math.Sqrt(limit)
+	return limit*(1-g.Smoothing) + next*g.Smoothing
+}
+
+func ema(avg, sample, weight float64) float64 {
+	return avg*(1-weight) + sample*weight
+}
+
+type AdaptiveLimiter struct {
+	name      string
+	algorithm LimitAlgorithm
+	min, max  float64
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	accepted int64
+	rejected int64
+	dropped  int64
+}
+
+func NewAdaptiveLimiter(name string, algorithm LimitAlgorithm, initial, min, max int) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		name:      name,
+		algorithm: algorithm,
+		limit:     float64(initial),
+		min:       float64(min),
+		max:       float64(max),
+	}
+}
+
+// Acquire admits a request if in-flight is under the current limit. The caller
+// must report the outcome through done, which also feeds the algorithm.
+func (l *AdaptiveLimiter) Acquire() (done func(dropped bool), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		l.rejected++
+		return nil, ErrLimitExceeded
+	}
+	l.inFlight++
+	l.accepted++
+	start := time.Now()
+
+	return func(dropped bool) {
+		l.Observe(time.Since(start), dropped)
+	}, nil
+}
+
+// Observe completes one in-flight request that took rtt
+func (l *AdaptiveLimiter) Observe(rtt time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if dropped {
+		l.dropped++
+	}
+	next := l.algorithm.Update(l.limit, rtt, l.inFlight, dropped)
+	l.limit = math.Max(l.min, math.Min(l.max, next))
+	l.inFlight--
+}
+
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Middleware rejects over-limit requests with 503 and counts 5xx responses as drops
+func (l *AdaptiveLimiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			done, err := l.Acquire()
+			if err != nil {
+				c.Response().Header().Set("Retry-After", "1")
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{
+					"error": err.Error(),
+					"route": l.name,
+				})
+			}
+			err = next(c)
+			done(err != nil || c.Response().Status >= http.StatusInternalServerError)
+			return err
+		}
+	}
+}
+
+type AdaptiveStats struct {
+	Route    string `json:"route"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	Accepted int64  `json:"accepted"`
+	Rejected int64  `json:"rejected"`
+	Dropped  int64  `json:"dropped"`
+}
+
+func (l *AdaptiveLimiter) Stats() AdaptiveStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return AdaptiveStats{
+		Route:    l.name,
+		Limit:    int(l.limit),
+		InFlight: l.inFlight,
+		Accepted: l.accepted,
+		Rejected: l.rejected,
+		Dropped:  l.dropped,
+	}
+}
@@ -0,0 +1,116 @@
+package resilience_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/microservices-example/resilience"
+)
+
+// The adaptive limiters run against a simulated backend whose capacity changes
+// over time. The backend serves up to capacity requests at baseLatency; beyond
+// that the extra requests queue, so latency grows with concurrency / capacity.
+// Demand is unlimited, so the limiter is always saturated and the limit is the
+// only thing standing between clients and the backend's queue. Latencies are
+// reported through Observe instead of measured, so every run is the same.
+
+type phase struct {
+	name        string
+	rounds      int
+	capacity    int
+	baseLatency time.Duration
+}
+
+var phases = []phase{
+	{"healthy", 150, 20, 10 * time.Millisecond},
+	{"degraded", 150, 8, 20 * time.Millisecond},
+	{"recovered", 150, 40, 10 * time.Millisecond},
+}
+
+func backendLatency(p phase, inFlight int, rng *rand.Rand) time.Duration {
+	load := float64(inFlight) / float64(p.capacity)
+	if load < 1 {
+		load = 1
+	}
+	jitter := 0.9 + 0.2*rng.Float64()
+	return time.Duration(float64(p.baseLatency) * load * jitter)
+}
+
+// simulate runs one round per step: admit as many requests as the limit
+// allows, then complete them all with the latency the backend would produce at
+// that load. It returns the limit after every 30 rounds of each phase.
+func simulate(limiter *resilience.AdaptiveLimiter) map[string][]int {
+	rng := rand.New(rand.NewSource(1))
+	limits := map[string][]int{}
+	for _, p := range phases {
+		for round := 1; round <= p.rounds; round++ {
+			admitted := 0
+			for {
+				if _, err := limiter.Acquire(); err != nil {
+					break
+				}
+				admitted++
+			}
+			for i := 0; i < admitted; i++ {
+				limiter.Observe(backendLatency(p, admitted, rng), false)
+			}
+			if round%30 == 0 {
+				limits[p.name] = append(limits[p.name], limiter.Limit())
+			}
+		}
+	}
+	return limits
+}
+
+func TestAdaptiveLimiterFollowsCapacity(t *testing.T) {
+	aimd := simulate(resilience.NewAdaptiveLimiter("aimd",
+		&resilience.AIMD{Timeout: 25 * time.Millisecond, Backoff: 0.9}, 10, 1, 200))
+	gradient := simulate(resilience.NewAdaptiveLimiter("gradient",
+		&resilience.Gradient{Smoothing: 0.2, Drift: 0.0002}, 10, 1, 200))
+	t.Logf("AIMD (timeout 25ms, backoff 0.9): %v", aimd)
+	t.Logf("Gradient (smoothing 0.2, drift 0.0002): %v", gradient)
+
+	for _, tc := range []struct {
+		name   string
+		limits map[string][]int
+	}{
+		{"AIMD", aimd},
+		{"Gradient", gradient},
+	} {
+		t.Run(tc.name+" backs off within 30 rounds when capacity drops", func(t *testing.T) {
+			for i, limit := range tc.limits["degraded"] {
+				if limit < 8 || limit > 12 {
+					t.Errorf("degraded limit %d at round %d, want near the capacity of 8", limit, 30*(i+1))
+				}
+			}
+		})
+	}
+
+	t.Run("Gradient ends every phase within 2x of the capacity", func(t *testing.T) {
+		for _, p := range phases {
+			limits := gradient[p.name]
+			if last := limits[len(limits)-1]; last < p.capacity || last > 2*p.capacity {
+				t.Errorf("%s: limit %d, capacity %d", p.name, last, p.capacity)
+			}
+		}
+	})
+	t.Run("AIMD settles where latency reaches its timeout, not at the capacity", func(t *testing.T) {
+		// 25ms is 2.5x the healthy latency, so the backend queues up to 2.5x capacity
+		limits := aimd["healthy"]
+		if last := limits[len(limits)-1]; last < 2*20 || last > 55 {
+			t.Errorf("healthy limit %d, want about 2.5x the capacity of 20", last)
+		}
+	})
+	t.Run("AIMD recovers only linearly", func(t *testing.T) {
+		limits := aimd["recovered"]
+		for i := 1; i < len(limits); i++ {
+			if step := limits[i] - limits[i-1]; step < 10 || step > 20 {
+				t.Errorf("recovered limit went %d -> %d over 30 rounds, want a steady climb", limits[i-1], limits[i])
+			}
+		}
+		if gradient["recovered"][0] <= limits[0] {
+			t.Errorf("after 30 rounds Gradient is at %d and AIMD at %d, want Gradient ahead", gradient["recovered"][0], limits[0])
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Soft rate limiting - a bounded admission queue per route.
+// A hard limiter answers 429 the moment the route is at capacity. Admission
+// instead lets up to MaxConcurrent requests run and parks up to MaxQueue more
+// for at most WaitBudget, so short bursts are absorbed as latency instead of
+// errors. Requests beyond the queue, or that outwait the budget, are shed.
+// MaxQueue = 0 gives plain load shedding, for comparison.
+
+var (
+	ErrQueueFull   = errors.New("admission queue is full")
+	ErrWaitTimeout = errors.New("waited longer than the route's budget")
+)
+
+type AdmissionConfig struct {
+	MaxConcurrent int
+	MaxQueue      int
+	WaitBudget    time.Duration
+}
+
+// Little's law: L = λ·W. The average number of requests in a stage equals the
+// arrival rate times the time each one spends there.
+
+// ConcurrencyFor is the number of in-flight slots needed to serve rps requests
+// per second that each take latency
+func ConcurrencyFor(rps float64, latency time.Duration) int {
+	return int(math.Ceil(rps * latency.Seconds()))
+}
+
+// QueueFor is the queue length that holds the requests arriving during one wait budget
+func QueueFor(rps float64, waitBudget time.Duration) int {
+	return int(math.Ceil(rps * waitBudget.Seconds()))
+}
+
+// SizeAdmission derives a config from expected peak load
+func SizeAdmission(peakRPS float64, latency, waitBudget time.Duration) AdmissionConfig {
+	return AdmissionConfig{
+		MaxConcurrent: ConcurrencyFor(peakRPS, latency),
+		MaxQueue:      QueueFor(peakRPS, waitBudget),
+		WaitBudget:    waitBudget,
+	}
+}
+
+type Admission struct {
+	name  string
+	cfg   AdmissionConfig
+	slots chan struct{}
+
+	inFlight     atomic.Int64
+	queued       atomic.Int64
+	admitted     atomic.Int64
+	waited       atomic.Int64
+	shedFull     atomic.Int64
+	shedTimeout  atomic.Int64
+	canceled     atomic.Int64
+	waitNanos    atomic.Int64
+	maxWaitNanos atomic.Int64
+}
+
+func NewAdmission(name string, cfg AdmissionConfig) *Admission {
+	if cfg.MaxConcurrent < 1 {
+		cfg.MaxConcurrent = 1
+	}
+	return &Admission{name: name, cfg: cfg, slots: make(chan struct{}, cfg.MaxConcurrent)}
+}
+
+// Acquire takes an in-flight slot, queueing within the wait budget if none is free
+func (a *Admission) Acquire(ctx context.Context) (release func(), err error) {
+	release = func() {
+		a.inFlight.Add(-1)
+		<-a.slots
+	}
+
+	select {
+	case a.slots <- struct{}{}:
+		a.admitted.Add(1)
+		a.inFlight.Add(1)
+		return release, nil
+	default:
+	}
+
+	if a.queued.Add(1) > int64(a.cfg.MaxQueue) {
+		a.queued.Add(-1)
+		a.shedFull.Add(1)
+		return nil, ErrQueueFull
+	}
+	defer a.queued.Add(-1)
+
+	start := time.Now()
+	timer := time.NewTimer(a.cfg.WaitBudget)
+	defer timer.Stop()
+
+	select {
+	case a.slots <- struct{}{}:
+		a.recordWait(time.Since(start))
+		a.admitted.Add(1)
+		a.waited.Add(1)
+		a.inFlight.Add(1)
+		return release, nil
+	case <-timer.C:
+		a.shedTimeout.Add(1)
+		return nil, ErrWaitTimeout
+	case <-ctx.Done():
+		a.canceled.Add(1)
+		return nil, ctx.Err()
+	}
+}
+
+func (a *Admission) recordWait(d time.Duration) {
+	a.waitNanos.Add(int64(d))
+	for {
+		max := a.maxWaitNanos.Load()
+		if int64(d) <= max || a.maxWaitNanos.CompareAndSwap(max, int64(d)) {
+			return
+		}
+	}
+}
+
+// Middleware applies admission control to a route
+func (a *Admission) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			release, err := a.Acquire(c.Request().Context())
+			if err != nil {
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return err
+				}
+				c.Response().Header().Set("Retry-After", "1")
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{
+					"error": err.Error(),
+					"route": a.name,
+				})
+			}
+			defer release()
+			return next(c)
+		}
+	}
+}
+
+type AdmissionStats struct {
+	Route         string  `json:"route"`
+	MaxConcurrent int     `json:"max_concurrent"`
+	MaxQueue      int     `json:"max_queue"`
+	WaitBudgetMs  int64   `json:"wait_budget_ms"`
+	InFlight      int64   `json:"in_flight"`
+	Queued        int64   `json:"queued"`
+	Admitted      int64   `json:"admitted"`
+	AdmittedAfter int64   `json:"admitted_after_wait"`
+	ShedQueueFull int64   `json:"shed_queue_full"`
+	ShedTimeout   int64   `json:"shed_wait_timeout"`
+	Canceled      int64   `json:"canceled"`
+	AvgWaitMs     float64 `json:"avg_wait_ms"`
+	MaxWaitMs     float64 `json:"max_wait_ms"`
+}
+
+func (a *Admission) Stats() AdmissionStats {
+	s := AdmissionStats{
+		Route:         a.name,
+		MaxConcurrent: a.cfg.MaxConcurrent,
+		MaxQueue:      a.cfg.MaxQueue,
+		WaitBudgetMs:  a.cfg.WaitBudget.Milliseconds(),
+		InFlight:      a.inFlight.Load(),
+		Queued:        a.queued.Load(),
+		Admitted:      a.admitted.Load(),
+		AdmittedAfter: a.waited.Load(),
+		ShedQueueFull: a.shedFull.Load(),
+		ShedTimeout:   a.shedTimeout.Load(),
+		Canceled:      a.canceled.Load(),
+		MaxWaitMs:     float64(a.maxWaitNanos.Load()) / 1e6,
+	}
+	if s.AdmittedAfter > 0 {
+		s.AvgWaitMs = float64(a.waitNanos.Load()) / float64(s.AdmittedAfter) / 1e6
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/microservices-example/resilience"
+)
+
+// The admission tests hold the only slot of a route and park requests
+// behind it, waiting for Stats to show them queued before going on, so what
+// is shed does not depend on timing.
+
+// holdOnlySlot returns an admission of one slot, and the release of that slot
+func holdOnlySlot(t *testing.T, queue int, budget time.Duration) (*resilience.Admission, func()) {
+	t.Helper()
+	a := resilience.NewAdmission("orders", resilience.AdmissionConfig{MaxConcurrent: 1, MaxQueue: queue, WaitBudget: budget})
+	release, err := a.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("the first Acquire: %v", err)
+	}
+	return a, release
+}
+
+// park starts an Acquire that queues, returning where its outcome is sent
+func park(t *testing.T, a *resilience.Admission, ctx context.Context) <-chan error {
+	t.Helper()
+	want := a.Stats().Queued + 1
+	done := make(chan error, 1)
+	go func() {
+		release, err := a.Acquire(ctx)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	for deadline := time.Now().Add(time.Second); a.Stats().Queued < want; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests queued, want %d", a.Stats().Queued, want)
+		}
+	}
+	return done
+}
+
+func TestAdmissionShedsWhenTheQueueIsFull(t *testing.T) {
+	a, release := holdOnlySlot(t, 1, time.Minute)
+	waiter := park(t, a, context.Background())
+	if _, err := a.Acquire(context.Background()); !errors.Is(err, resilience.ErrQueueFull) {
+		t.Errorf("Acquire with the queue full = %v, want %v", err, resilience.ErrQueueFull)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	release()
+	if err := <-waiter; err != nil {
+		t.Fatalf("the queued request, once the slot is free = %v, want it admitted", err)
+	}
+	s := a.Stats()
+	if s.Admitted != 2 || s.AdmittedAfter != 1 || s.ShedQueueFull != 1 || s.ShedTimeout != 0 || s.Canceled != 0 {
+		t.Errorf("stats = %+v, want 2 admitted, 1 of them after waiting, and 1 shed as the queue was full", s)
+	}
+	if s.InFlight != 0 || s.Queued != 0 {
+		t.Errorf("stats = %+v, want nothing in flight or queued once all is released", s)
+	}
+	if s.MaxWaitMs < 5 || s.AvgWaitMs != s.MaxWaitMs {
+		t.Errorf("waits: avg %vms, max %vms, want the one wait of at least 5ms", s.AvgWaitMs, s.MaxWaitMs)
+	}
+}
+
+func TestAdmissionWithoutAQueueSheds(t *testing.T) {
+	a, release := holdOnlySlot(t, 0, time.Minute)
+	defer release()
+	if _, err := a.Acquire(context.Background()); !errors.Is(err, resilience.ErrQueueFull) {
+		t.Errorf("Acquire with MaxQueue 0 and no free slot = %v, want %v at once", err, resilience.ErrQueueFull)
+	}
+}
+
+func TestAdmissionWaitBudget(t *testing.T) {
+	a, release := holdOnlySlot(t, 1, 20*time.Millisecond)
+	defer release()
+	start := time.Now()
+	_, err := a.Acquire(context.Background())
+	if waited := time.Since(start); !errors.Is(err, resilience.ErrWaitTimeout) || waited < 20*time.Millisecond {
+		t.Errorf("Acquire behind a held slot = %v after %v, want %v after the 20ms budget", err, waited, resilience.ErrWaitTimeout)
+	}
+	if s := a.Stats(); s.ShedTimeout != 1 || s.Queued != 0 || s.AdmittedAfter != 0 {
+		t.Errorf("stats = %+v, want 1 shed on timeout and nothing left queued", s)
+	}
+}
+
+func TestAdmissionCancel(t *testing.T) {
+	a, release := holdOnlySlot(t, 1, time.Minute)
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := park(t, a, ctx)
+	cancel()
+	if err := <-waiter; !errors.Is(err, context.Canceled) {
+		t.Errorf("a queued request whose context is canceled = %v, want %v", err, context.Canceled)
+	}
+	if s := a.Stats(); s.Canceled != 1 || s.Queued != 0 || s.ShedTimeout != 0 {
+		t.Errorf("stats = %+v, want 1 canceled and nothing left queued", s)
+	}
+}
+
+func TestSizeAdmission(t *testing.T) {
+	sizes := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"200 rps of 50ms", resilience.ConcurrencyFor(200, 50*time.Millisecond), 10},
+		{"10 rps of 150ms, rounded up", resilience.ConcurrencyFor(10, 150*time.Millisecond), 2},
+		{"a 250ms budget at 200 rps", resilience.QueueFor(200, 250*time.Millisecond), 50},
+		{"no load", resilience.QueueFor(0, time.Second), 0},
+	}
+	for _, tt := range sizes {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+
+	cfg := resilience.SizeAdmission(200, 50*time.Millisecond, 250*time.Millisecond)
+	if want := (resilience.AdmissionConfig{MaxConcurrent: 10, MaxQueue: 50, WaitBudget: 250 * time.Millisecond}); cfg != want {
+		t.Errorf("SizeAdmission = %+v, want %+v", cfg, want)
+	}
+	if s := resilience.NewAdmission("idle", resilience.SizeAdmission(0, time.Second, time.Second)).Stats(); s.MaxConcurrent != 1 {
+		t.Errorf("an admission sized for no load has %d slots, want at least 1", s.MaxConcurrent)
+	}
+}
@@ -0,0 +1,309 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Priority scheduling - one pool of in-flight slots shared by request classes.
+// While slots are free every request runs immediately. Once the gateway is
+// saturated, each class waits in its own queue and freed slots are handed out
+// by weighted fair queueing: a class with weight 8 gets eight slots for every
+// one given to a class with weight 1 while both have requests waiting. A flood
+// of batch exports therefore slows interactive traffic by at most its share
+// instead of queueing in front of it.
+
+type RequestClass int
+
+const (
+	ClassInteractive RequestClass = iota
+	ClassBatch
+	ClassAdmin
+	numClasses
+)
+
+func (c RequestClass) String() string {
+	switch c {
+	case ClassInteractive:
+		return "interactive"
+	case ClassBatch:
+		return "batch"
+	case ClassAdmin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+// RequestClassHeader lets a client opt into a lower class, e.g. a batch job
+// marking its own requests. Admin cannot be claimed through the header.
+const RequestClassHeader = "X-Request-Class"
+
+// ClassRule assigns Class to requests whose path starts with PathPrefix and,
+// if Method is set, use that method
+type ClassRule struct {
+	Method     string
+	PathPrefix string
+	Class      RequestClass
+}
+
+type Classifier func(r *http.Request) RequestClass
+
+// NewClassifier returns the class of the first matching rule, or fallback.
+// A "batch" RequestClassHeader downgrades an interactive request.
+func NewClassifier(fallback RequestClass, rules ...ClassRule) Classifier {
+	return func(r *http.Request) RequestClass {
+		class := fallback
+		for _, rule := range rules {
+			if rule.Method != "" && rule.Method != r.Method {
+				continue
+			}
+			if strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+				class = rule.Class
+				break
+			}
+		}
+		if class == ClassInteractive && strings.EqualFold(r.Header.Get(RequestClassHeader), ClassBatch.String()) {
+			class = ClassBatch
+		}
+		return class
+	}
+}
+
+type ClassConfig struct {
+	Weight     int
+	MaxQueue   int
+	WaitBudget time.Duration
+}
+
+type SchedulerConfig struct {
+	MaxConcurrent int
+	Classes       map[RequestClass]ClassConfig
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+	queued  time.Time
+}
+
+type classQueue struct {
+	cfg     ClassConfig
+	waiters []*waiter
+	pass    float64 // virtual finish time of the last slot handed to this class
+
+	inFlight    int
+	admitted    int64
+	waited      int64
+	shedFull    int64
+	shedTimeout int64
+	canceled    int64
+	waitNanos   int64
+	maxWait     time.Duration
+}
+
+type PriorityScheduler struct {
+	mu       sync.Mutex
+	max      int
+	inFlight int
+	vtime    float64
+	classes  [numClasses]*classQueue
+}
+
+// NewPriorityScheduler creates the shared pool. Classes missing from cfg get
+// weight 1 and no queue.
+func NewPriorityScheduler(cfg SchedulerConfig) *PriorityScheduler {
+	if cfg.MaxConcurrent < 1 {
+		cfg.MaxConcurrent = 1
+	}
+	s := &PriorityScheduler{max: cfg.MaxConcurrent}
+	for c := range s.classes {
+		cc := cfg.Classes[RequestClass(c)]
+		if cc.Weight < 1 {
+			cc.Weight = 1
+		}
+		s.classes[c] = &classQueue{cfg: cc}
+	}
+	return s
+}
+
+// Acquire takes a slot for class, queueing within the class's wait budget if
+// the gateway is saturated
+func (s *PriorityScheduler) Acquire(ctx context.Context, class RequestClass) (release func(), err error) {
+	if class < 0 || class >= numClasses {
+		class = ClassInteractive
+	}
+	q := s.classes[class]
+	release = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		q.inFlight--
+		s.inFlight--
+		s.dispatch()
+	}
+
+	s.mu.Lock()
+	if s.inFlight < s.max {
+		s.inFlight++
+		q.inFlight++
+		q.admitted++
+		s.mu.Unlock()
+		return release, nil
+	}
+	if len(q.waiters) >= q.cfg.MaxQueue {
+		q.shedFull++
+		s.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	if len(q.waiters) == 0 {
+		// A class returning from idle must not spend credit it banked while idle
+		q.pass = max(q.pass, s.vtime)
+	}
+	w := &waiter{ready: make(chan struct{}), queued: time.Now()}
+	q.waiters = append(q.waiters, w)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(q.cfg.WaitBudget)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-timer.C:
+		err = ErrWaitTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.granted {
+		// The slot was handed over while we were giving up; use it
+		return release, nil
+	}
+	q.remove(w)
+	if err == ErrWaitTimeout {
+		q.shedTimeout++
+	} else {
+		q.canceled++
+	}
+	return nil, err
+}
+
+// dispatch hands free slots to waiting requests, always picking the class with
+// the smallest virtual time. Must be called with s.mu held.
+func (s *PriorityScheduler) dispatch() {
+	for s.inFlight < s.max {
+		var next *classQueue
+		for _, q := range s.classes {
+			if len(q.waiters) > 0 && (next == nil || q.pass < next.pass) {
+				next = q
+			}
+		}
+		if next == nil {
+			return
+		}
+		w := next.waiters[0]
+		next.waiters = next.waiters[1:]
+		s.vtime = next.pass
+		next.pass += 1 / float64(next.cfg.Weight)
+
+		wait := time.Since(w.queued)
+		next.admitted++
+		next.waited++
+		next.waitNanos += int64(wait)
+		next.maxWait = max(next.maxWait, wait)
+		next.inFlight++
+		s.inFlight++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+func (q *classQueue) remove(w *waiter) {
+	for i, other := range q.waiters {
+		if other == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Middleware schedules every request through s, classified by classify
+func (s *PriorityScheduler) Middleware(classify Classifier) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			class := classify(c.Request())
+			c.Response().Header().Set(RequestClassHeader, class.String())
+
+			release, err := s.Acquire(c.Request().Context(), class)
+			if err != nil {
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return err
+				}
+				c.Response().Header().Set("Retry-After", "1")
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{
+					"error": err.Error(),
+					"class": class.String(),
+				})
+			}
+			defer release()
+			return next(c)
+		}
+	}
+}
+
+type ClassStats struct {
+	Class         string  `json:"class"`
+	Weight        int     `json:"weight"`
+	MaxQueue      int     `json:"max_queue"`
+	WaitBudgetMs  int64   `json:"wait_budget_ms"`
+	InFlight      int     `json:"in_flight"`
+	Queued        int     `json:"queued"`
+	Admitted      int64   `json:"admitted"`
+	AdmittedAfter int64   `json:"admitted_after_wait"`
+	ShedQueueFull int64   `json:"shed_queue_full"`
+	ShedTimeout   int64   `json:"shed_wait_timeout"`
+	Canceled      int64   `json:"canceled"`
+	AvgWaitMs     float64 `json:"avg_wait_ms"`
+	MaxWaitMs     float64 `json:"max_wait_ms"`
+}
+
+type SchedulerStats struct {
+	MaxConcurrent int          `json:"max_concurrent"`
+	InFlight      int          `json:"in_flight"`
+	Classes       []ClassStats `json:"classes"`
+}
+
+func (s *PriorityScheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := SchedulerStats{MaxConcurrent: s.max, InFlight: s.inFlight}
+	for c, q := range s.classes {
+		cs := ClassStats{
+			Class:         RequestClass(c).String(),
+			Weight:        q.cfg.Weight,
+			MaxQueue:      q.cfg.MaxQueue,
+			WaitBudgetMs:  q.cfg.WaitBudget.Milliseconds(),
+			InFlight:      q.inFlight,
+			Queued:        len(q.waiters),
+			Admitted:      q.admitted,
+			AdmittedAfter: q.waited,
+			ShedQueueFull: q.shedFull,
+			ShedTimeout:   q.shedTimeout,
+			Canceled:      q.canceled,
+			MaxWaitMs:     float64(q.maxWait) / 1e6,
+		}
+		if q.waited > 0 {
+			cs.AvgWaitMs = float64(q.waitNanos) / float64(q.waited) / 1e6
+		}
+		stats.Classes = append(stats.Classes, cs)
+	}
+	return stats
+}
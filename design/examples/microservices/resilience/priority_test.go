@@ -0,0 +1,188 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/microservices-example/resilience"
+)
+
+// The scheduler tests saturate a pool of one slot, queue requests behind it
+// and hand the slot on one release at a time, so the order in which classes
+// are served does not depend on timing.
+
+type grant struct {
+	class   resilience.RequestClass
+	release func()
+}
+
+type pool struct {
+	t      *testing.T
+	s      *resilience.PriorityScheduler
+	held   func()
+	grants chan grant
+	queued int
+}
+
+// saturate takes the only slot of a scheduler with the given classes
+func saturate(t *testing.T, classes map[resilience.RequestClass]resilience.ClassConfig) *pool {
+	s := resilience.NewPriorityScheduler(resilience.SchedulerConfig{MaxConcurrent: 1, Classes: classes})
+	held, err := s.Acquire(context.Background(), resilience.ClassInteractive)
+	if err != nil {
+		t.Fatalf("the first Acquire: %v", err)
+	}
+	return &pool{t: t, s: s, held: held, grants: make(chan grant, 1000)}
+}
+
+// enqueue adds n waiting requests of class, one at a time so they queue in order
+func (p *pool) enqueue(class resilience.RequestClass, n int) {
+	p.enqueueAs(class, class, n)
+}
+
+// enqueueAs queues n requests of class under the scheduling class as
+func (p *pool) enqueueAs(class, as resilience.RequestClass, n int) {
+	for i := 0; i < n; i++ {
+		go func() {
+			if release, err := p.s.Acquire(context.Background(), as); err == nil {
+				p.grants <- grant{class, release}
+			}
+		}()
+		p.queued++
+		for deadline := time.Now().Add(time.Second); p.waiting() < p.queued; {
+			if time.Now().After(deadline) {
+				p.t.Fatalf("%d requests queued, want %d", p.waiting(), p.queued)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func (p *pool) waiting() int {
+	n := 0
+	for _, c := range p.s.Stats().Classes {
+		n += c.Queued
+	}
+	return n
+}
+
+// serve releases the slot n times and returns the class each one went to
+func (p *pool) serve(n int) []resilience.RequestClass {
+	var order []resilience.RequestClass
+	for i := 0; i < n; i++ {
+		p.held()
+		g := <-p.grants
+		p.queued--
+		order = append(order, g.class)
+		p.held = g.release
+	}
+	return order
+}
+
+func count(order []resilience.RequestClass, class resilience.RequestClass) int {
+	n := 0
+	for _, c := range order {
+		if c == class {
+			n++
+		}
+	}
+	return n
+}
+
+var weighted = map[resilience.RequestClass]resilience.ClassConfig{
+	resilience.ClassInteractive: {Weight: 8, MaxQueue: 100, WaitBudget: time.Minute},
+	resilience.ClassAdmin:       {Weight: 4, MaxQueue: 10, WaitBudget: time.Minute},
+	resilience.ClassBatch:       {Weight: 1, MaxQueue: 200, WaitBudget: time.Minute},
+}
+
+func TestPrioritySchedulerShares(t *testing.T) {
+	t.Run("a FIFO queue serves a batch flood ahead of the interactive request behind it", func(t *testing.T) {
+		fifo := saturate(t, map[resilience.RequestClass]resilience.ClassConfig{
+			resilience.ClassInteractive: {Weight: 1, MaxQueue: 300, WaitBudget: time.Minute},
+		})
+		// One queue: every request is scheduled as interactive, whatever it is
+		fifo.enqueueAs(resilience.ClassBatch, resilience.ClassInteractive, 50)
+		fifo.enqueueAs(resilience.ClassInteractive, resilience.ClassInteractive, 1)
+		order := fifo.serve(51)
+		if count(order[:50], resilience.ClassBatch) != 50 || order[50] != resilience.ClassInteractive {
+			t.Errorf("served %v, want the interactive request last", order)
+		}
+	})
+
+	t.Run("with weights, an interactive request overtakes the batch queue", func(t *testing.T) {
+		p := saturate(t, weighted)
+		p.enqueue(resilience.ClassBatch, 50)
+		p.enqueue(resilience.ClassInteractive, 1)
+		if order := p.serve(2); count(order, resilience.ClassInteractive) != 1 {
+			t.Errorf("the first two slots went to %v, want one of them interactive", order)
+		}
+		p.serve(p.queued)
+	})
+
+	t.Run("while both wait, interactive gets 8 slots for every batch one", func(t *testing.T) {
+		p := saturate(t, weighted)
+		p.enqueue(resilience.ClassBatch, 40)
+		p.enqueue(resilience.ClassInteractive, 40)
+		order := p.serve(36)
+		if i, b := count(order, resilience.ClassInteractive), count(order, resilience.ClassBatch); i != 32 || b != 4 {
+			t.Errorf("36 slots: %d interactive, %d batch; want 32 and 4", i, b)
+		}
+		p.serve(p.queued)
+	})
+
+	t.Run("a class back from idle does not spend credit banked while idle", func(t *testing.T) {
+		p := saturate(t, weighted)
+		p.enqueue(resilience.ClassInteractive, 20)
+		p.serve(20)
+		p.enqueue(resilience.ClassInteractive, 20)
+		p.enqueue(resilience.ClassBatch, 20)
+		order := p.serve(18)
+		if b := count(order, resilience.ClassBatch); b != 2 {
+			t.Errorf("18 slots after batch was idle: %d batch, want 2", b)
+		}
+		p.serve(p.queued)
+	})
+}
+
+func TestPrioritySchedulerSheds(t *testing.T) {
+	t.Run("a full class queue rejects at once, without touching the others", func(t *testing.T) {
+		p := saturate(t, weighted)
+		p.enqueue(resilience.ClassAdmin, 10)
+		if _, err := p.s.Acquire(context.Background(), resilience.ClassAdmin); !errors.Is(err, resilience.ErrQueueFull) {
+			t.Errorf("the 11th admin request: %v, want ErrQueueFull", err)
+		}
+		p.enqueue(resilience.ClassInteractive, 1)
+		p.serve(p.queued)
+		for _, c := range p.s.Stats().Classes {
+			if want := map[string]int64{"admin": 1}[c.Class]; c.ShedQueueFull != want {
+				t.Errorf("%s shed %d, want %d", c.Class, c.ShedQueueFull, want)
+			}
+		}
+	})
+
+	t.Run("a request gives up after its class's wait budget", func(t *testing.T) {
+		p := saturate(t, map[resilience.RequestClass]resilience.ClassConfig{
+			resilience.ClassBatch: {Weight: 1, MaxQueue: 10, WaitBudget: 20 * time.Millisecond},
+		})
+		defer p.held()
+		if _, err := p.s.Acquire(context.Background(), resilience.ClassBatch); !errors.Is(err, resilience.ErrWaitTimeout) {
+			t.Errorf("Acquire = %v, want ErrWaitTimeout", err)
+		}
+	})
+
+	t.Run("or when its context ends, which is not counted as shed", func(t *testing.T) {
+		p := saturate(t, weighted)
+		defer p.held()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := p.s.Acquire(ctx, resilience.ClassBatch); !errors.Is(err, context.Canceled) {
+			t.Errorf("Acquire = %v, want Canceled", err)
+		}
+		for _, c := range p.s.Stats().Classes {
+			if c.Class == "batch" && (c.Canceled != 1 || c.ShedTimeout != 0) {
+				t.Errorf("batch: canceled %d, shed %d; want 1 and 0", c.Canceled, c.ShedTimeout)
+			}
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package resilience
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/dong-tran/docs/concurrency-example/ratelimit"
+	"github.com/labstack/echo/v4"
+)
+
+// Hard rate limiting per client, in front of admission control. Admission
+// protects the backends from the total load; this keeps one client from
+// taking all of it. A request over its client's limit gets 429 at once, with
+// Retry-After saying when the next one would pass; it is never queued here.
+
+type RateLimit struct {
+	limiters *ratelimit.Keyed
+	key      func(echo.Context) string
+
+	allowed atomic.Int64
+	limited atomic.Int64
+}
+
+// NewRateLimit limits each key, by default the client IP, through limiters
+func NewRateLimit(limiters *ratelimit.Keyed, key func(echo.Context) string) *RateLimit {
+	if key == nil {
+		key = func(c echo.Context) string { return c.RealIP() }
+	}
+	return &RateLimit{limiters: limiters, key: key}
+}
+
+func (rl *RateLimit) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := rl.limiters.Reserve(rl.key(c))
+			if !r.OK() || r.Delay() > 0 {
+				r.Cancel()
+				rl.limited.Add(1)
+				if r.OK() {
+					c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(r.Delay().Seconds()))))
+				}
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": ratelimit.ErrLimited.Error()})
+			}
+			rl.allowed.Add(1)
+			return next(c)
+		}
+	}
+}
+
+type RateLimitStats struct {
+	Clients int   `json:"clients"`
+	Allowed int64 `json:"allowed"`
+	Limited int64 `json:"limited"`
+}
+
+func (rl *RateLimit) Stats() RateLimitStats {
+	return RateLimitStats{Clients: rl.limiters.Len(), Allowed: rl.allowed.Load(), Limited: rl.limited.Load()}
+}
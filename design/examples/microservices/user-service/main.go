@@ -1,8 +1,15 @@
 package main
 
 import (
+"context"
+"github.com/dong-tran/docs/concurrency-example/shutdown"
+"github.com/dong-tran/docs/microservices-example/lifecycle"
 "github.com/labstack/echo/v4"
+"log"
+"net"
 "net/http"
+"os"
+"time"
 )
 
 type User struct {
@@ -14,6 +21,11 @@ type User struct {
 func main() {
 	e := echo.New()
 
+	// Readiness: real traffic is turned away until warm-up has run
+	life := lifecycle.New()
+	e.Use(life.Gate("/ready"))
+	e.GET("/ready", life.ReadyHandler())
+
 	e.GET("/users/:id", func(c echo.Context) error {
 user := User{
 ID:    c.Param("id"),
@@ -32,5 +44,52 @@ if err := c.Bind(&user); err != nil {
 		return c.JSON(http.StatusCreated, user)
 	})
 
-	e.Start(":8081")
+	// Warm-up exercises the request path before reporting ready. SKIP_WARMUP=1
+	// starts cold, to compare first-request latency.
+	if os.Getenv("SKIP_WARMUP") == "" {
+		life.Add(lifecycle.SyntheticRequests(e, 20, "GET /users/warmup"))
+	}
+
+	// Lifecycle: serve, then warm up; on SIGINT/SIGTERM fail readiness and
+	// finish the requests in flight
+	app := shutdown.New(shutdown.Options{StopTimeout: 10 * time.Second, Logf: log.Printf})
+	app.Register(
+		shutdown.Component{
+			Name: "http",
+			Start: func(context.Context) error {
+				ln, err := net.Listen("tcp", ":8081")
+				if err != nil {
+					return err
+				}
+				e.Listener = ln
+				go func() {
+					if err := e.Start(""); err != nil && err != http.ErrServerClosed {
+						log.Fatalf("User service stopped: %v", err)
+					}
+				}()
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				life.Drain()
+				return e.Shutdown(ctx)
+			},
+		},
+		shutdown.Component{
+			Name:      "warmup",
+			DependsOn: []string{"http"},
+			Start: func(ctx context.Context) error {
+				if err := life.Warm(ctx); err != nil {
+					return err
+				}
+				for _, step := range life.Report() {
+					log.Printf("warm-up %s: %.1fms %s", step.Name, step.DurationMs, step.Error)
+				}
+				log.Println("User service ready")
+				return nil
+			},
+		},
+	)
+	if err := app.Run(context.Background(), 15*time.Second); err != nil {
+		log.Fatalf("User service: %v", err)
+	}
 }
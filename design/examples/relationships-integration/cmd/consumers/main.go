@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/broker"
+)
+
+// Competing consumers demo.
+// Order events for a handful of customers are published to one topic and
+// processed by a consumer group whose members join and leave mid-stream.
+// shared/broker/group_test.go checks that each customer's events stay in order.
+
+const (
+	topic      = "order.events"
+	customers  = 12
+	perCust    = 50
+	partitions = 6
+)
+
+type orderEvent struct {
+	Customer string
+	Seq      int
+}
+
+// progress counts handled events across all members
+type progress struct {
+	mu   sync.Mutex
+	done int
+}
+
+func (p *progress) handler(ctx context.Context, m *broker.Message) error {
+	time.Sleep(time.Millisecond) // simulated work
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	return nil
+}
+
+func (p *progress) processed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done
+}
+
+func printStats(label string, g *broker.ConsumerGroup) {
+	fmt.Printf("\n%s (generation %d)\n", label, g.Generation())
+	for _, s := range g.Stats() {
+		fmt.Printf("  %-10s partitions=%v processed=%d\n", s.Name, s.Partitions, s.Processed)
+	}
+}
+
+func main() {
+	b := broker.New(broker.DefaultRetryPolicy)
+	defer b.Close()
+
+	work := &progress{}
+	group := b.NewConsumerGroup(topic, "order-processors", partitions)
+
+	workerA, _ := group.Join("worker-a", work.handler)
+	group.Join("worker-b", work.handler)
+	printStats("Two members", group)
+
+	total := customers * perCust
+	go func() {
+		for seq := 1; seq <= perCust; seq++ {
+			for c := 1; c <= customers; c++ {
+				customer := fmt.Sprintf("customer-%02d", c)
+				b.Publish(topic, orderEvent{Customer: customer, Seq: seq}, broker.WithKey(customer))
+			}
+		}
+	}()
+
+	waitFor := func(n int) {
+		for work.processed() < n {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor(total / 3)
+	group.Join("worker-c", work.handler)
+	printStats("worker-c joined", group)
+
+	waitFor(2 * total / 3)
+	workerA.Leave()
+	printStats("worker-a left", group)
+
+	waitFor(total)
+	printStats("Finished", group)
+	group.Close()
+
+	fmt.Printf("\nProcessed %d/%d events\n", work.processed(), total)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/broker"
+)
+
+// At-least-once vs effectively-once.
+// The same stream of payment messages is fed to two processors. Both "charge"
+// the customer (the side effect) and then ack. A fault injector drops some acks
+// after the charge, as if the consumer crashed between the two steps, so the
+// broker redelivers those messages.
+//
+//   - The naive processor charges again on every redelivery: duplicate charges.
+//   - The inbox processor records handled message IDs and skips redeliveries,
+//     so every payment is charged exactly once even though delivery is at-least-once.
+//
+// shared/broker/inbox_test.go asserts the counts with a fixed set of lost acks.
+
+const (
+	payments     = 200
+	lostAckRatio = 0.25
+)
+
+type payment struct {
+	OrderID string
+	Amount  float64
+}
+
+// ledger counts side effects per order
+type ledger struct {
+	mu      sync.Mutex
+	charges map[string]int
+}
+
+func newLedger() *ledger {
+	return &ledger{charges: make(map[string]int)}
+}
+
+func (l *ledger) charge(ctx context.Context, m *broker.Message) error {
+	p := m.Payload.(payment)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.charges[p.OrderID]++
+	return nil
+}
+
+func (l *ledger) summary() (total, duplicates int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, n := range l.charges {
+		total += n
+		duplicates += n - 1
+	}
+	return total, duplicates
+}
+
+// run consumes topic until every payment has been acked once, dropping acks at random
+func run(b *broker.Broker, topic string, handler broker.Handler, seed int64) (redeliveries int) {
+	rng := rand.New(rand.NewSource(seed))
+	acked := make(map[string]bool)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for len(acked) < payments {
+		d, err := b.Receive(ctx, topic)
+		if err != nil {
+			panic(err)
+		}
+		if d.Attempt > 1 {
+			redeliveries++
+		}
+		if err := handler(ctx, d.Message); err != nil {
+			d.Nack()
+			continue
+		}
+		if rng.Float64() < lostAckRatio {
+			d.Nack() // the side effect happened, but the broker never hears about it
+			continue
+		}
+		d.Ack()
+		acked[d.ID] = true
+	}
+	return redeliveries
+}
+
+func main() {
+	b := broker.New(broker.RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+	})
+	defer b.Close()
+
+	// Publish the same messages (same IDs) to both topics
+	for i := 1; i <= payments; i++ {
+		id := fmt.Sprintf("msg-%03d", i)
+		p := payment{OrderID: fmt.Sprintf("order-%03d", i), Amount: float64(i)}
+		b.Publish("payments.naive", p, broker.WithID(id))
+		b.Publish("payments.inbox", p, broker.WithID(id))
+	}
+
+	naive := newLedger()
+	naiveRedeliveries := run(b, "payments.naive", naive.charge, 42)
+
+	deduped := newLedger()
+	inbox := broker.NewInbox(payments)
+	inboxRedeliveries := run(b, "payments.inbox", broker.Deduplicate(inbox, deduped.charge), 42)
+
+	fmt.Println("=== At-least-once vs effectively-once ===")
+	fmt.Println()
+	for _, r := range []struct {
+		name         string
+		l            *ledger
+		redeliveries int
+	}{
+		{"at-least-once (naive)", naive, naiveRedeliveries},
+		{"effectively-once (inbox)", deduped, inboxRedeliveries},
+	} {
+		total, dup := r.l.summary()
+		fmt.Printf("%-26s redeliveries=%-3d charges=%-3d duplicates=%d\n", r.name, r.redeliveries, total, dup)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/dong-tran/docs/integration-example/graphql"
+)
+
+// Subscribes to order status changes and prints them until interrupted:
+//
+//	go run ./cmd/graphql-client -token customer-token
+//	go run ./cmd/graphql-client -token admin-token -order <order-id>
+func main() {
+	url := flag.String("url", "ws://localhost:8080/graphql/ws", "GraphQL WebSocket endpoint")
+	token := flag.String("token", "customer-token", "token sent in connection_init")
+	orderID := flag.String("order", "", "only follow this order")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := graphql.Dial(ctx, *url, *token)
+	if err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	vars := map[string]interface{}{}
+	if *orderID != "" {
+		vars["orderId"] = *orderID
+	}
+	sub, err := client.Subscribe(`subscription($orderId: ID) {
+		orderStatusChanged(orderId: $orderId) { orderId customerId status occurredAt }
+	}`, vars)
+	if err != nil {
+		log.Fatalf("subscribe: %v", err)
+	}
+	fmt.Println("Listening for order status changes...")
+
+	for {
+		select {
+		case r, ok := <-sub.Results:
+			if !ok {
+				if err := client.Err(); err != nil {
+					log.Fatalf("connection closed: %v", err)
+				}
+				fmt.Println("Subscription completed")
+				return
+			}
+			if len(r.Errors) > 0 {
+				fmt.Printf("errors: %s\n", r.Errors)
+				continue
+			}
+			fmt.Printf("%s\n", r.Data)
+		case <-ctx.Done():
+			sub.Stop()
+			return
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+"context"
 "log"
+"os"
+"time"
 
+"github.com/dong-tran/docs/integration-example/domain/order"
+"github.com/dong-tran/docs/integration-example/graphql"
 "github.com/dong-tran/docs/integration-example/handler"
 "github.com/dong-tran/docs/integration-example/infrastructure"
 "github.com/dong-tran/docs/integration-example/repository"
+"github.com/dong-tran/docs/integration-example/shared/broker"
+"github.com/dong-tran/docs/integration-example/shared/i18n"
 "github.com/dong-tran/docs/integration-example/shared/patterns"
 "github.com/dong-tran/docs/integration-example/usecase"
 "github.com/labstack/echo/v4"
@@ -13,37 +20,123 @@ import (
 )
 
 func main() {
+	// Every component built below is recorded in the dependency graph served
+	// at /debug/di-graph; the wiring itself stays plain constructor calls
+	graph := patterns.NewDependencyGraph()
+
 	// Initialize infrastructure
 	db, err := infrastructure.InitDatabase()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	patterns.Provide(graph, "db", "infrastructure", patterns.Singleton, db)
+
+	// Message catalogs for error responses and notifications
+	messages, err := i18n.Default()
+	if err != nil {
+		log.Fatalf("Failed to load message catalogs: %v", err)
+	}
+	messages.OnMissing = func(locale, key string) {
+		log.Printf("i18n: no message for %q in %s or its fallbacks", key, locale)
+	}
+	patterns.Provide(graph, "messages", "shared", patterns.Singleton, messages)
+
+	// Order workflow policy; ORDER_WORKFLOW points at a custom policy file,
+	// checked with `go run ./cmd/ops workflow validate <file>` before deploying
+	workflow := order.DefaultWorkflow()
+	if path := os.Getenv("ORDER_WORKFLOW"); path != "" {
+		if workflow, err = order.LoadWorkflow(path); err != nil {
+			log.Fatalf("Failed to load order workflow: %v", err)
+		}
+		log.Printf("Order workflow loaded from %s", path)
+	}
+	patterns.Provide(graph, "workflow", "domain", patterns.Singleton, workflow)
+
+	// Setup event system (Observer pattern), recording events for tracing
+	eventStore := patterns.Provide(graph, "eventStore", "shared", patterns.Singleton, patterns.NewMemoryEventStore(10000))
+	eventPublisher := patterns.Provide(graph, "eventPublisher", "shared", patterns.Singleton,
+		patterns.NewEventPublisher(patterns.WithRecorder(eventStore)), "eventStore")
+	subscribe := func(name string, h patterns.EventHandler, opts ...patterns.HandlerOption) {
+		eventPublisher.SubscribeHandler(name, h, opts...)
+		graph.Subscribe("eventPublisher", name)
+	}
+	subscribe("email", patterns.Provide(graph, "email", "infrastructure", patterns.Singleton,
+		&infrastructure.EmailNotificationHandler{Messages: messages}, "messages"), patterns.WithTimeout(2*time.Second))
+	subscribe("logging", patterns.Provide(graph, "logging", "infrastructure", patterns.Singleton, &infrastructure.LoggingHandler{}))
+	subscribe("analytics", patterns.Provide(graph, "analytics", "infrastructure", patterns.Singleton,
+		&infrastructure.AnalyticsHandler{}), patterns.WithTimeout(500*time.Millisecond))
+	subscribe("fraud-check", patterns.Provide(graph, "fraud-check", "infrastructure", patterns.Singleton,
+		&infrastructure.FraudCheckHandler{Publisher: eventPublisher, Threshold: 10000}, "eventPublisher"))
 
-	// Setup event system (Observer pattern)
-	eventPublisher := patterns.NewEventPublisher()
-	eventPublisher.Subscribe(&infrastructure.EmailNotificationHandler{})
-	eventPublisher.Subscribe(&infrastructure.LoggingHandler{})
-	eventPublisher.Subscribe(&infrastructure.AnalyticsHandler{})
+	// GraphQL layer: order status projection and live subscriptions
+	statusFeed := patterns.Provide(graph, "graphql-status-feed", "graphql", patterns.Singleton, graphql.NewStatusFeed(32))
+	subscribe("graphql-status-feed", statusFeed)
+	schema := patterns.Provide(graph, "graphqlSchema", "graphql", patterns.Singleton, graphql.NewSchema(statusFeed), "graphql-status-feed")
+	graphqlServer := patterns.Provide(graph, "graphqlServer", "graphql", patterns.Singleton, graphql.NewServer(schema, graphql.StaticTokens{
+		"admin-token":    {Admin: true},
+		"customer-token": {CustomerID: "customer-123"},
+	}), "graphqlSchema")
+
+	// Setup message broker for deferred work (payment reminders)
+	messageBroker := patterns.Provide(graph, "messageBroker", "shared", patterns.Singleton, broker.New(broker.DefaultRetryPolicy))
+	defer messageBroker.Close()
+	subscribe("payment-reminder", patterns.Provide(graph, "payment-reminder", "infrastructure", patterns.Singleton,
+		&infrastructure.PaymentReminderScheduler{
+			Broker: messageBroker,
+			Delay:  30 * time.Minute,
+		}, "messageBroker"))
+	go messageBroker.Consume(context.Background(), infrastructure.PaymentReminderTopic, infrastructure.PaymentReminderConsumer)
+	graph.Add(patterns.Component{Name: "payment-reminder-consumer", Layer: "infrastructure", Type: "infrastructure.PaymentReminderConsumer", Lifecycle: patterns.Background}, "messageBroker")
 
 	// Setup factories (Factory pattern)
-	paymentFactory := patterns.NewPaymentFactory()
+	paymentFactory := patterns.Provide(graph, "paymentFactory", "shared", patterns.Singleton, patterns.NewPaymentFactory())
 
 	// Dependency injection (DIP)
-	orderRepo := repository.NewOrderRepository(db)
-	orderUseCase := usecase.NewOrderUseCase(orderRepo, paymentFactory, eventPublisher)
-	orderHandler := handler.NewOrderHandler(orderUseCase)
+	orderRepo := patterns.Provide(graph, "orderRepo", "repository", patterns.Singleton, repository.NewOrderRepository(db), "db")
+	orderUseCase := patterns.Provide(graph, "orderUseCase", "usecase", patterns.Singleton,
+		usecase.NewOrderUseCase(orderRepo, paymentFactory, eventPublisher, usecase.WithWorkflow(workflow)),
+		"orderRepo", "paymentFactory", "eventPublisher", "workflow")
+	orderHandler := patterns.Provide(graph, "orderHandler", "handler", patterns.Singleton, handler.NewOrderHandler(orderUseCase), "orderUseCase")
+	traceHandler := patterns.Provide(graph, "traceHandler", "handler", patterns.Singleton, handler.NewTraceHandler(eventStore), "eventStore")
+	snapshotter := patterns.Provide(graph, "snapshotter", "infrastructure", patterns.Singleton,
+		infrastructure.NewSnapshotter(db, eventStore, statusFeed), "db", "eventStore", "graphql-status-feed")
+	snapshotHandler := patterns.Provide(graph, "snapshotHandler", "handler", patterns.Singleton, handler.NewSnapshotHandler(snapshotter), "snapshotter")
+	diGraphHandler := patterns.Provide(graph, "diGraphHandler", "handler", patterns.Singleton, handler.NewDIGraphHandler(graph))
 
 	// Setup Echo
 	e := echo.New()
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
+	e.Use(handler.CorrelationID())
+	e.Use(handler.Localize(messages))
+	e.Use(handler.Roles(handler.StaticRoles{
+		"admin-token":    "admin",
+		"staff-token":    "staff",
+		"customer-token": "customer",
+	}))
+	graph.Add(patterns.Component{Name: "correlationID", Layer: "handler", Type: "echo.MiddlewareFunc", Lifecycle: patterns.PerRequest})
+	graph.Add(patterns.Component{Name: "localize", Layer: "handler", Type: "echo.MiddlewareFunc", Lifecycle: patterns.PerRequest}, "messages")
+	graph.Add(patterns.Component{Name: "roles", Layer: "handler", Type: "echo.MiddlewareFunc", Lifecycle: patterns.PerRequest})
 
 	// Routes
 	e.POST("/orders", orderHandler.CreateOrder)
+	e.POST("/orders/import", orderHandler.ImportOrders)
 	e.GET("/orders/:id", orderHandler.GetOrder)
 	e.POST("/orders/:id/payment", orderHandler.ProcessPayment)
+	e.POST("/orders/:id/ship", orderHandler.ShipOrder)
+	e.POST("/orders/:id/actions/:action", orderHandler.PerformAction)
+	e.GET("/admin/traces/:correlationId", traceHandler.GetTrace)
+	e.GET("/admin/snapshot", snapshotHandler.Export)
+	e.POST("/admin/snapshot", snapshotHandler.Import)
+	e.POST("/graphql", graphqlServer.Query)
+	e.GET("/graphql/ws", graphqlServer.Subscriptions)
+	e.GET("/debug/di-graph", diGraphHandler.GetGraph)
+
+	if err := graph.Validate(); err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("🚀 Integration Example Server starting on :8080")
 	log.Println("📚 Demonstrates: Clean Architecture + DDD + SOLID + Design Patterns + Microservices concepts")
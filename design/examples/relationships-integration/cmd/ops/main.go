@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+// ops is the operator CLI for the order service.
+//
+//	go run ./cmd/ops workflow validate [policy.json...]
+//	go run ./cmd/ops workflow show [policy.json]
+//	go run ./cmd/ops di graph [-addr URL] [-format text|dot|json]
+//
+// validate checks workflow policy files against the order domain's invariants
+// before they are deployed via ORDER_WORKFLOW, printing every violation and
+// exiting non-zero if any file fails. Without arguments it checks the built-in
+// policy. show prints the transitions a policy allows, state by state.
+//
+// di graph fetches the dependency graph from a running server's
+// /debug/di-graph and prints it by layer, or as DOT for Graphviz:
+//
+//	go run ./cmd/ops di graph -format dot | dot -Tsvg > di.svg
+
+const usage = `usage:
+  ops workflow validate [policy.json...]
+  ops workflow show [policy.json]
+  ops di graph [-addr URL] [-format text|dot|json]`
+
+func main() {
+	args := os.Args[1:]
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch args[0] + " " + args[1] {
+	case "workflow validate":
+		os.Exit(validate(args[2:]))
+	case "workflow show":
+		os.Exit(show(args[2:]))
+	case "di graph":
+		os.Exit(diGraph(args[2:]))
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func load(path string) (*order.Workflow, error) {
+	if path == "" {
+		return order.DefaultWorkflow(), nil
+	}
+	return order.LoadWorkflow(path)
+}
+
+func validate(paths []string) int {
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	status := 0
+	for _, path := range paths {
+		name := path
+		if name == "" {
+			name = "built-in policy"
+		}
+		w, err := load(path)
+		var policyErr *order.PolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			fmt.Printf("FAIL %s: %d violation(s)\n", name, len(policyErr.Violations))
+			for _, v := range policyErr.Violations {
+				fmt.Println("  - " + v)
+			}
+			status = 1
+		case err != nil:
+			fmt.Printf("FAIL %s: %v\n", name, err)
+			status = 1
+		default:
+			fmt.Printf("ok   %s: %d states, %d transitions\n", name, len(w.States), len(w.Transitions))
+		}
+	}
+	return status
+}
+
+func show(paths []string) int {
+	path := ""
+	if len(paths) > 0 {
+		path = paths[0]
+	}
+	w, err := load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, state := range w.States {
+		fmt.Println(state)
+		found := false
+		for _, t := range w.Transitions {
+			for _, from := range t.From {
+				if from != state {
+					continue
+				}
+				found = true
+				roles := "anyone"
+				if len(t.Roles) > 0 {
+					roles = strings.Join(t.Roles, ", ")
+				}
+				emits := ""
+				if t.Emits != "" {
+					emits = ", emits " + t.Emits
+				}
+				fmt.Printf("  %-10s -> %-12s (%s%s)\n", t.Action, t.To, roles, emits)
+			}
+		}
+		if !found {
+			fmt.Println("  (final)")
+		}
+	}
+	return 0
+}
+
+func diGraph(args []string) int {
+	fs := flag.NewFlagSet("di graph", flag.ContinueOnError)
+	addr := fs.String("addr", "http://localhost:8080", "server base URL")
+	format := fs.String("format", "text", "text, dot or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "dot" && *format != "json" {
+		fmt.Fprintln(os.Stderr, "format must be text, dot or json")
+		return 2
+	}
+
+	query := "json"
+	if *format == "dot" {
+		query = "dot"
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(*addr, "/") + "/debug/di-graph?format=" + query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	if *format != "text" {
+		os.Stdout.Write(body)
+		return 0
+	}
+
+	var graph patterns.DependencyGraphSnapshot
+	if err := json.Unmarshal(body, &graph); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	deps := make(map[string][]string)
+	for _, d := range graph.Dependencies {
+		to := d.To
+		if d.Kind == patterns.Subscribes {
+			to = "~" + to
+		}
+		deps[d.From] = append(deps[d.From], to)
+	}
+	// Print inner layers first, in the order the dependency rule points
+	layers := []string{"domain", "usecase", "repository", "infrastructure", "shared", "graphql", "handler"}
+	known := strings.Join(layers, " ")
+	for _, c := range graph.Components {
+		if !strings.Contains(" "+known+" ", " "+c.Layer+" ") {
+			known += " " + c.Layer
+			layers = append(layers, c.Layer)
+		}
+	}
+	for _, layer := range layers {
+		fmt.Println(layer)
+		for _, c := range graph.Components {
+			if c.Layer != layer {
+				continue
+			}
+			line := fmt.Sprintf("  %-26s %-44s %s", c.Name, c.Type, c.Lifecycle)
+			if len(deps[c.Name]) > 0 {
+				line += "  -> " + strings.Join(deps[c.Name], ", ")
+			}
+			fmt.Println(line)
+		}
+	}
+	fmt.Println("\n~name: subscribed after construction")
+	return 0
+}
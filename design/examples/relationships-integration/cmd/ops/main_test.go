@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stdout runs f and returns what it printed
+func stdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = saved }()
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestWorkflowValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(good, []byte(`{
+		"initial": "PENDING",
+		"states": ["PENDING", "PAID", "SHIPPED", "DELIVERED", "CANCELLED"],
+		"roles": ["staff"],
+		"transitions": [
+			{"action": "pay", "from": ["PENDING"], "to": "PAID", "emits": "OrderPaid"},
+			{"action": "ship", "from": ["PAID"], "to": "SHIPPED", "roles": ["staff"], "emits": "OrderShipped"},
+			{"action": "deliver", "from": ["SHIPPED"], "to": "DELIVERED"},
+			{"action": "cancel", "from": ["PENDING", "PAID"], "to": "CANCELLED"}
+		]
+	}`), 0o644)
+	os.WriteFile(bad, []byte(`{
+		"initial": "PENDING",
+		"states": ["PENDING", "PAID", "SHIPPED", "DELIVERED", "CANCELLED"],
+		"roles": [],
+		"transitions": [
+			{"action": "pay", "from": ["PENDING"], "to": "PAID", "emits": "OrderPaid"},
+			{"action": "ship", "from": ["PENDING", "PAID"], "to": "SHIPPED", "roles": ["staff"], "emits": "OrderShipped"},
+			{"action": "deliver", "from": ["SHIPPED"], "to": "DELIVERED"},
+			{"action": "cancel", "from": ["PENDING", "PAID"], "to": "CANCELLED"}
+		]
+	}`), 0o644)
+
+	tests := []struct {
+		name   string
+		paths  []string
+		status int
+		want   []string
+	}{
+		{"the built-in policy", nil, 0, []string{"ok   built-in policy: 5 states, 4 transitions"}},
+		{"a valid file", []string{good}, 0, []string{"ok   " + good + ": 5 states, 4 transitions"}},
+		{"every violation of a bad file", []string{good, bad}, 1, []string{
+			"ok   " + good,
+			"FAIL " + bad + ": 2 violation(s)",
+			`  - transition 2 (ship): role "staff" is not declared`,
+			"  - SHIPPED is reachable without passing through PAID",
+		}},
+		{"a missing file", []string{filepath.Join(dir, "missing.json")}, 1, []string{"FAIL " + filepath.Join(dir, "missing.json") + ": open"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := -1
+			out := stdout(t, func() { status = validate(tt.paths) })
+			if status != tt.status {
+				t.Errorf("validate exited %d, want %d", status, tt.status)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
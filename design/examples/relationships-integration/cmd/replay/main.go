@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+// Replayable event history.
+// A ReplayBus keeps the last 100 events. Late subscribers, standing in for an
+// analytics sink attached to a running service, catch up from that history and
+// then continue with live events. Every subscriber checks that the events it
+// receives are consecutive: no gap, no duplicate, nothing out of order, even
+// when it subscribes while another goroutine keeps publishing.
+
+const capacity = 100
+
+// sequenceChecker expects event payloads 1, 2, 3, ... starting anywhere
+type sequenceChecker struct {
+	name  string
+	delay time.Duration
+
+	mu    sync.Mutex
+	first int
+	last  int
+	err   error
+}
+
+func (c *sequenceChecker) Handle(ctx context.Context, event patterns.Event) error {
+	if c.delay > 0 {
+		time.Sleep(c.delay)
+	}
+	n := event.Data.(int)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.first == 0:
+		c.first = n
+	case n != c.last+1 && c.err == nil:
+		c.err = fmt.Errorf("%s: got %d after %d", c.name, n, c.last)
+	}
+	c.last = n
+	return nil
+}
+
+func (c *sequenceChecker) report(want int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		log.Fatal(c.err)
+	}
+	if c.last != want {
+		log.Fatalf("%s: last event %d, want %d", c.name, c.last, want)
+	}
+	fmt.Printf("  %-10s received %d..%d in order\n", c.name, c.first, c.last)
+}
+
+func main() {
+	ctx := context.Background()
+	bus := patterns.NewReplayBus(capacity)
+	published := 0
+	publish := func(n int) {
+		for i := 0; i < n; i++ {
+			published++
+			if err := bus.Publish(ctx, patterns.Event{Type: "OrderCreated", Data: published}); err != nil {
+				log.Fatalf("publish: %v", err)
+			}
+		}
+	}
+
+	fmt.Printf("=== Replay from history (capacity %d) ===\n", capacity)
+	publish(150)
+	fmt.Printf("Published %d events, last seq %d\n", published, bus.LastSeq())
+
+	recent := &sequenceChecker{name: "last-20"}
+	if err := bus.SubscribeReplayLast("last-20", recent, 20); err != nil {
+		log.Fatal(err)
+	}
+	resumed := &sequenceChecker{name: "from-#140"}
+	if err := bus.SubscribeFrom("from-#140", resumed, 140); err != nil {
+		log.Fatal(err)
+	}
+	err := bus.SubscribeFrom("from-#10", &sequenceChecker{name: "from-#10"}, 10)
+	if !errors.Is(err, patterns.ErrHistoryEvicted) {
+		log.Fatalf("subscribing from an evicted seq: got %v", err)
+	}
+	fmt.Printf("Subscribing from #10 is refused: %v\n", err)
+
+	publish(10)
+	fmt.Printf("Published 10 more live events:\n")
+	recent.report(published)
+	resumed.report(published)
+
+	fmt.Println("\n=== Subscribing while publishing ===")
+	var stop atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for !stop.Load() {
+			publish(1)
+		}
+	}()
+
+	late := []*sequenceChecker{
+		{name: "fast"},
+		{name: "slow", delay: 50 * time.Microsecond},
+	}
+	for _, c := range late {
+		time.Sleep(5 * time.Millisecond)
+		if err := bus.SubscribeReplayLast(c.name, c, capacity); err != nil {
+			log.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	stop.Store(true)
+	<-done
+
+	fmt.Printf("Published up to %d while subscribing:\n", published)
+	for _, c := range late {
+		c.report(published)
+	}
+}
@@ -0,0 +1,75 @@
+package order
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Domain errors carry stable codes (ORDER_ALREADY_PAID, ...) that API clients
+// branch on; messages may change, codes may not. Kind is what went wrong in
+// transport-neutral terms, mapped to an HTTP status by the handler layer.
+
+type Code string
+
+type Kind int
+
+const (
+	KindInvalid   Kind = iota // the input breaks a business rule
+	KindNotFound              // the order does not exist
+	KindConflict              // the order's status does not allow the operation
+	KindForbidden             // the caller's role may not perform the operation
+	KindInternal              // a failure the client cannot fix
+)
+
+// Error is a coded domain error; errors.Is compares codes
+type Error struct {
+	Code    Code
+	Kind    Kind
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var codes = map[Code]*Error{}
+
+// NewError declares a coded sentinel error; a duplicate code panics at startup
+func NewError(code Code, kind Kind, message string) *Error {
+	if existing, ok := codes[code]; ok {
+		panic(fmt.Sprintf("order: error code %s declared twice (%q and %q)", code, existing.Message, message))
+	}
+	e := &Error{Code: code, Kind: kind, Message: message}
+	codes[code] = e
+	return e
+}
+
+var (
+	ErrNegativeAmount       = NewError("MONEY_NEGATIVE_AMOUNT", KindInvalid, "amount cannot be negative")
+	ErrCurrencyMismatch     = NewError("MONEY_CURRENCY_MISMATCH", KindInvalid, "currency mismatch")
+	ErrInvalidQuantity      = NewError("ORDER_ITEM_INVALID_QUANTITY", KindInvalid, "quantity must be positive")
+	ErrEmptyOrder           = NewError("ORDER_EMPTY", KindInvalid, "order must have at least one item")
+	ErrOrderNotFound        = NewError("ORDER_NOT_FOUND", KindNotFound, "order not found")
+	ErrOrderAlreadyPaid     = NewError("ORDER_ALREADY_PAID", KindConflict, "order has already been paid")
+	ErrOrderCancelled       = NewError("ORDER_CANCELLED", KindConflict, "order has been cancelled")
+	ErrOrderNotPaid         = NewError("ORDER_NOT_PAID", KindConflict, "only paid orders can be shipped")
+	ErrOrderAlreadyShipped  = NewError("ORDER_ALREADY_SHIPPED", KindConflict, "cannot cancel shipped or delivered orders")
+	ErrPaymentUnsupported   = NewError("PAYMENT_METHOD_UNSUPPORTED", KindInvalid, "unsupported payment method")
+	ErrTransitionNotAllowed = NewError("ORDER_TRANSITION_NOT_ALLOWED", KindConflict, "the order workflow does not allow this action now")
+	ErrActionForbidden      = NewError("ORDER_ACTION_FORBIDDEN", KindForbidden, "not allowed to perform this action")
+)
+
+// Codes returns every declared error, ordered by code
+func Codes() []*Error {
+	errs := make([]*Error, 0, len(codes))
+	for _, e := range codes {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Code < errs[j].Code })
+	return errs
+}
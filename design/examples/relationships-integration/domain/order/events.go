@@ -17,3 +17,13 @@ type OrderShippedEvent struct {
 	OrderID        string
 	TrackingNumber string
 }
+
+// OrderStatusChangedEvent is published for workflow actions without a
+// dedicated event (cancel, deliver, or ones a custom policy adds), under the
+// event type the policy names
+type OrderStatusChangedEvent struct {
+	OrderID string
+	Action  string
+	From    OrderStatus
+	To      OrderStatus
+}
@@ -1,7 +1,6 @@
 package order
 
 import (
-"errors"
 "time"
 
 "github.com/google/uuid"
@@ -52,7 +51,7 @@ type Money struct {
 
 func NewMoney(amount float64, currency string) (Money, error) {
 	if amount < 0 {
-		return Money{}, errors.New("amount cannot be negative")
+		return Money{}, ErrNegativeAmount
 	}
 	if currency == "" {
 		currency = "USD"
@@ -70,7 +69,7 @@ func (m Money) Currency() string {
 
 func (m Money) Add(other Money) (Money, error) {
 	if m.currency != other.currency {
-		return Money{}, errors.New("currency mismatch")
+		return Money{}, ErrCurrencyMismatch
 	}
 	return NewMoney(m.amount+other.amount, m.currency)
 }
@@ -96,7 +95,7 @@ type OrderItem struct {
 
 func NewOrderItem(productID, productName string, quantity int, price Money) (*OrderItem, error) {
 	if quantity <= 0 {
-		return nil, errors.New("quantity must be positive")
+		return nil, ErrInvalidQuantity
 	}
 	return &OrderItem{
 		productID:   productID,
@@ -114,7 +113,7 @@ func (i *OrderItem) Total() Money {
 // NewOrder - Factory method for creating orders
 func NewOrder(customerID CustomerID, items []OrderItem) (*Order, error) {
 	if len(items) == 0 {
-		return nil, errors.New("order must have at least one item")
+		return nil, ErrEmptyOrder
 	}
 
 	total, err := NewMoney(0, "USD")
@@ -172,32 +171,19 @@ func (o *Order) UpdatedAt() time.Time {
 	return o.updatedAt
 }
 
-// MarkAsPaid - Domain method with business rules
+// MarkAsPaid - Domain method with business rules.
+// These follow the default workflow as a trusted caller; use cases go through
+// Fire with the configured workflow and the caller's role.
 func (o *Order) MarkAsPaid() error {
-	if o.status != OrderStatusPending {
-		return errors.New("only pending orders can be marked as paid")
-	}
-	o.status = OrderStatusPaid
-	o.updatedAt = time.Now()
-	return nil
+	return o.perform(ActionPay)
 }
 
 // Ship - Domain method
 func (o *Order) Ship() error {
-	if o.status != OrderStatusPaid {
-		return errors.New("only paid orders can be shipped")
-	}
-	o.status = OrderStatusShipped
-	o.updatedAt = time.Now()
-	return nil
+	return o.perform(ActionShip)
 }
 
 // Cancel - Domain method
 func (o *Order) Cancel() error {
-	if o.status == OrderStatusShipped || o.status == OrderStatusDelivered {
-		return errors.New("cannot cancel shipped or delivered orders")
-	}
-	o.status = OrderStatusCancelled
-	o.updatedAt = time.Now()
-	return nil
+	return o.perform(ActionCancel)
 }
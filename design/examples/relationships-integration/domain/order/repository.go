@@ -4,6 +4,8 @@ package order
 // Defined in domain layer but implemented in infrastructure (DIP)
 type OrderRepository interface {
 	Save(order *Order) error
+	// SaveAll stores every order or none of them
+	SaveAll(orders []*Order) error
 	FindByID(id OrderID) (*Order, error)
 	FindByCustomerID(customerID CustomerID) ([]*Order, error)
 	Update(order *Order) error
@@ -0,0 +1,345 @@
+package order
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Workflow policy - which actions move an order between statuses, who may
+// perform them and which event each one emits. It is loaded from a JSON file
+// (see workflow.json for the default), so a workshop can add states such as
+// ON_HOLD, actions such as hold/release, or tighten roles without recompiling.
+//
+// A policy can reshape the flow but not break the rules the rest of the code
+// relies on; ParseWorkflow rejects one that does:
+//
+//   - orders start PENDING and every built-in status is declared
+//   - pay, ship and cancel exist and lead to PAID, SHIPPED and CANCELLED,
+//     pay and ship emitting OrderPaid and OrderShipped (use cases, event
+//     handlers and the GraphQL projection depend on them)
+//   - a pending order can be paid, and SHIPPED cannot be reached without PAID
+//   - CANCELLED and DELIVERED are final; a shipped order never gets back to
+//     PENDING or PAID and can never be cancelled
+//   - every state is reachable, and an action means one thing per state
+//   - OrderPaid and OrderShipped are emitted only on the way into PAID and SHIPPED
+
+type Action string
+
+const (
+	ActionPay    Action = "pay"
+	ActionShip   Action = "ship"
+	ActionCancel Action = "cancel"
+)
+
+type Transition struct {
+	Action Action        `json:"action"`
+	From   []OrderStatus `json:"from"`
+	To     OrderStatus   `json:"to"`
+	Roles  []string      `json:"roles,omitempty"` // empty: any caller
+	Emits  string        `json:"emits,omitempty"` // event type published after the change
+}
+
+// Allows reports whether role may perform the transition
+func (t Transition) Allows(role string) bool {
+	if len(t.Roles) == 0 {
+		return true
+	}
+	for _, r := range t.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Workflow is a validated policy; build one with ParseWorkflow or LoadWorkflow
+type Workflow struct {
+	Initial     OrderStatus   `json:"initial"`
+	States      []OrderStatus `json:"states"`
+	Roles       []string      `json:"roles"`
+	Transitions []Transition  `json:"transitions"`
+
+	next map[OrderStatus]map[Action]Transition
+}
+
+// PolicyError lists every invariant a policy breaks, not just the first
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "invalid order workflow: " + strings.Join(e.Violations, "; ")
+}
+
+//go:embed workflow.json
+var defaultPolicy []byte
+
+var defaultWorkflow = mustParseWorkflow(defaultPolicy)
+
+// DefaultWorkflow is the policy in workflow.json, used when none is configured
+func DefaultWorkflow() *Workflow {
+	return defaultWorkflow
+}
+
+func mustParseWorkflow(data []byte) *Workflow {
+	w, err := ParseWorkflow(data)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+func ParseWorkflow(data []byte) (*Workflow, error) {
+	var w Workflow
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// A misspelled "role" must not silently open a transition to everyone
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&w); err != nil {
+		return nil, fmt.Errorf("order workflow: %w", err)
+	}
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func LoadWorkflow(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := ParseWorkflow(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+var (
+	statePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+	eventPattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+)
+
+var builtinStatuses = []OrderStatus{
+	OrderStatusPending, OrderStatusPaid, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled,
+}
+
+var builtinActions = []struct {
+	action Action
+	to     OrderStatus
+	emits  string
+}{
+	{ActionPay, OrderStatusPaid, "OrderPaid"},
+	{ActionShip, OrderStatusShipped, "OrderShipped"},
+	{ActionCancel, OrderStatusCancelled, ""},
+}
+
+func (w *Workflow) validate() error {
+	var violations []string
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	states := make(map[OrderStatus]bool)
+	for _, s := range w.States {
+		if !statePattern.MatchString(string(s)) {
+			fail("state %q is not UPPER_SNAKE_CASE", s)
+		}
+		if states[s] {
+			fail("state %s is declared twice", s)
+		}
+		states[s] = true
+	}
+	for _, s := range builtinStatuses {
+		if !states[s] {
+			fail("built-in state %s is not declared", s)
+		}
+	}
+	if w.Initial != OrderStatusPending {
+		fail("initial state is %q, but new orders start %s", w.Initial, OrderStatusPending)
+	}
+	roles := make(map[string]bool)
+	for _, r := range w.Roles {
+		if r == "" {
+			fail("empty role name")
+		}
+		roles[r] = true
+	}
+
+	w.next = make(map[OrderStatus]map[Action]Transition)
+	edges := make(map[OrderStatus][]OrderStatus)
+	for i, t := range w.Transitions {
+		name := fmt.Sprintf("transition %d (%s)", i+1, t.Action)
+		if t.Action == "" {
+			fail("%s has no action", name)
+		}
+		if !states[t.To] {
+			fail("%s: target %q is not a declared state", name, t.To)
+		}
+		if len(t.From) == 0 {
+			fail("%s has no source states", name)
+		}
+		for _, r := range t.Roles {
+			if !roles[r] {
+				fail("%s: role %q is not declared", name, r)
+			}
+		}
+		if t.Emits != "" && !eventPattern.MatchString(t.Emits) {
+			fail("%s: event %q is not UpperCamelCase", name, t.Emits)
+		}
+		for _, from := range t.From {
+			if !states[from] {
+				fail("%s: source %q is not a declared state", name, from)
+				continue
+			}
+			if _, dup := w.next[from][t.Action]; dup {
+				fail("%s: %s from %s is already defined", name, t.Action, from)
+				continue
+			}
+			if w.next[from] == nil {
+				w.next[from] = make(map[Action]Transition)
+			}
+			w.next[from][t.Action] = t
+			edges[from] = append(edges[from], t.To)
+		}
+	}
+
+	for _, b := range builtinActions {
+		found := false
+		for _, t := range w.Transitions {
+			if t.Action != b.action {
+				continue
+			}
+			found = true
+			if t.To != b.to {
+				fail("%s must lead to %s, not %s", b.action, b.to, t.To)
+			}
+			if b.emits != "" && t.Emits != b.emits {
+				fail("%s must emit %s", b.action, b.emits)
+			}
+		}
+		if !found {
+			fail("built-in action %s is missing", b.action)
+		}
+	}
+	if _, ok := w.next[OrderStatusPending][ActionPay]; !ok {
+		fail("%s orders must be payable", OrderStatusPending)
+	}
+	for _, t := range w.Transitions {
+		if t.Emits == "OrderPaid" && t.To != OrderStatusPaid || t.Emits == "OrderShipped" && t.To != OrderStatusShipped {
+			fail("%s emits %s but leads to %s", t.Action, t.Emits, t.To)
+		}
+	}
+
+	for _, s := range []OrderStatus{OrderStatusCancelled, OrderStatusDelivered} {
+		if len(w.next[s]) > 0 {
+			fail("%s is final but has outgoing transitions", s)
+		}
+	}
+	if reachable(edges, OrderStatusPending, OrderStatusPaid)[OrderStatusShipped] {
+		fail("%s is reachable without passing through %s", OrderStatusShipped, OrderStatusPaid)
+	}
+	afterShipping := reachable(edges, OrderStatusShipped, "")
+	for _, s := range []OrderStatus{OrderStatusPending, OrderStatusPaid, OrderStatusCancelled} {
+		if afterShipping[s] {
+			fail("a shipped order can reach %s", s)
+		}
+	}
+	fromInitial := reachable(edges, OrderStatusPending, "")
+	for _, s := range w.States {
+		if states[s] && !fromInitial[s] {
+			fail("state %s is unreachable from %s", s, OrderStatusPending)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// reachable returns the states reachable from start without entering avoid
+func reachable(edges map[OrderStatus][]OrderStatus, start, avoid OrderStatus) map[OrderStatus]bool {
+	seen := map[OrderStatus]bool{start: true}
+	queue := []OrderStatus{start}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, to := range edges[s] {
+			if !seen[to] && to != avoid {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return seen
+}
+
+// Permitted lists the actions role may perform on an order in status, sorted
+func (w *Workflow) Permitted(status OrderStatus, role string) []Action {
+	var actions []Action
+	for action, t := range w.next[status] {
+		if t.Allows(role) {
+			actions = append(actions, action)
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i] < actions[j] })
+	return actions
+}
+
+// Check returns the transition action takes from status, or why role may not perform it
+func (w *Workflow) Check(status OrderStatus, action Action, role string) (Transition, error) {
+	t, ok := w.next[status][action]
+	if !ok {
+		return t, w.rejection(status, action)
+	}
+	if !t.Allows(role) {
+		return t, fmt.Errorf("%w: %s requires role %s", ErrActionForbidden, action, strings.Join(t.Roles, " or "))
+	}
+	return t, nil
+}
+
+// rejection keeps the established codes for the built-in actions on built-in
+// statuses, so clients branching on ORDER_ALREADY_PAID keep working
+func (w *Workflow) rejection(status OrderStatus, action Action) error {
+	switch {
+	case action == ActionPay && status == OrderStatusCancelled:
+		return ErrOrderCancelled
+	case action == ActionPay && (status == OrderStatusPaid || status == OrderStatusShipped || status == OrderStatusDelivered):
+		return ErrOrderAlreadyPaid
+	case action == ActionShip && (status == OrderStatusPending || status == OrderStatusCancelled):
+		return ErrOrderNotPaid
+	case action == ActionCancel && (status == OrderStatusShipped || status == OrderStatusDelivered):
+		return ErrOrderAlreadyShipped
+	}
+	return fmt.Errorf("%w: cannot %s an order that is %s", ErrTransitionNotAllowed, action, status)
+}
+
+// Fire performs action as role, if the workflow allows it
+func (o *Order) Fire(w *Workflow, action Action, role string) (Transition, error) {
+	t, err := w.Check(o.status, action, role)
+	if err != nil {
+		return t, err
+	}
+	o.status = t.To
+	o.updatedAt = time.Now()
+	return t, nil
+}
+
+// perform applies action under the default workflow without a role check
+func (o *Order) perform(action Action) error {
+	t, ok := defaultWorkflow.next[o.status][action]
+	if !ok {
+		return defaultWorkflow.rejection(o.status, action)
+	}
+	o.status = t.To
+	o.updatedAt = time.Now()
+	return nil
+}
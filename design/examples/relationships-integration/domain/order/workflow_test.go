@@ -0,0 +1,242 @@
+package order_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+)
+
+// policy returns the default workflow as a fresh, editable value
+func policy(t *testing.T) *order.Workflow {
+	t.Helper()
+	data, err := json.Marshal(order.DefaultWorkflow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w order.Workflow
+	if err := json.Unmarshal(data, &w); err != nil {
+		t.Fatal(err)
+	}
+	return &w
+}
+
+// parse round-trips w through JSON, as a policy file would arrive
+func parse(t *testing.T, w *order.Workflow) (*order.Workflow, error) {
+	t.Helper()
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order.ParseWorkflow(data)
+}
+
+// transition returns the first transition for action in w
+func transition(t *testing.T, w *order.Workflow, action order.Action) *order.Transition {
+	t.Helper()
+	for i := range w.Transitions {
+		if w.Transitions[i].Action == action {
+			return &w.Transitions[i]
+		}
+	}
+	t.Fatalf("the policy has no %s transition", action)
+	return nil
+}
+
+func TestDefaultWorkflow(t *testing.T) {
+	w := order.DefaultWorkflow()
+	if want := []order.OrderStatus{"PENDING", "PAID", "SHIPPED", "DELIVERED", "CANCELLED"}; !reflect.DeepEqual(w.States, want) {
+		t.Errorf("States = %v, want %v", w.States, want)
+	}
+	tests := []struct {
+		status order.OrderStatus
+		role   string
+		want   []order.Action
+	}{
+		{order.OrderStatusPending, "customer", []order.Action{"cancel", "pay"}},
+		{order.OrderStatusPending, "", []order.Action{"pay"}},
+		{order.OrderStatusPaid, "staff", []order.Action{"ship"}},
+		{order.OrderStatusPaid, "admin", []order.Action{"cancel", "ship"}},
+		{order.OrderStatusShipped, "staff", []order.Action{"deliver"}},
+		{order.OrderStatusDelivered, "admin", nil},
+		{order.OrderStatusCancelled, "admin", nil},
+	}
+	for _, tt := range tests {
+		if got := w.Permitted(tt.status, tt.role); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Permitted(%s, %q) = %v, want %v", tt.status, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestParseWorkflowRejectsUnknownFields(t *testing.T) {
+	// "role" for "roles" would otherwise open shipping to anyone
+	data := `{"initial":"PENDING","states":["PENDING","PAID","SHIPPED","DELIVERED","CANCELLED"],"roles":["staff"],
+		"transitions":[{"action":"ship","from":["PAID"],"to":"SHIPPED","role":["staff"],"emits":"OrderShipped"}]}`
+	_, err := order.ParseWorkflow([]byte(data))
+	var policyErr *order.PolicyError
+	if err == nil || errors.As(err, &policyErr) || !strings.Contains(err.Error(), `unknown field "role"`) {
+		t.Errorf("ParseWorkflow = %v, want a decoding error naming the unknown field", err)
+	}
+}
+
+func TestParseWorkflowViolations(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, w *order.Workflow)
+		want   string
+	}{
+		{"a built-in state is missing", func(t *testing.T, w *order.Workflow) {
+			w.States = []order.OrderStatus{"PENDING", "PAID", "SHIPPED", "CANCELLED"}
+		}, "built-in state DELIVERED is not declared"},
+		{"orders start elsewhere", func(t *testing.T, w *order.Workflow) {
+			w.Initial = order.OrderStatusPaid
+		}, `initial state is "PAID", but new orders start PENDING`},
+		{"SHIPPED is reachable without PAID", func(t *testing.T, w *order.Workflow) {
+			transition(t, w, order.ActionShip).From = []order.OrderStatus{"PENDING", "PAID"}
+		}, "SHIPPED is reachable without passing through PAID"},
+		{"a shipped order reaches PENDING", func(t *testing.T, w *order.Workflow) {
+			w.Transitions = append(w.Transitions, order.Transition{Action: "reopen", From: []order.OrderStatus{"SHIPPED"}, To: "PENDING"})
+		}, "a shipped order can reach PENDING"},
+		{"a shipped order reaches PAID", func(t *testing.T, w *order.Workflow) {
+			w.Transitions = append(w.Transitions, order.Transition{Action: "unship", From: []order.OrderStatus{"SHIPPED"}, To: "PAID"})
+		}, "a shipped order can reach PAID"},
+		{"a shipped order reaches CANCELLED", func(t *testing.T, w *order.Workflow) {
+			cancel := transition(t, w, order.ActionCancel)
+			cancel.From = append(cancel.From, "SHIPPED")
+		}, "a shipped order can reach CANCELLED"},
+		{"a final state has outgoing transitions", func(t *testing.T, w *order.Workflow) {
+			w.Transitions = append(w.Transitions, order.Transition{Action: "restore", From: []order.OrderStatus{"CANCELLED"}, To: "PENDING"})
+		}, "CANCELLED is final but has outgoing transitions"},
+		{"an action means two things in one state", func(t *testing.T, w *order.Workflow) {
+			w.Transitions = append(w.Transitions, *transition(t, w, order.ActionPay))
+		}, "transition 5 (pay): pay from PENDING is already defined"},
+		{"a role is not declared", func(t *testing.T, w *order.Workflow) {
+			transition(t, w, order.ActionShip).Roles = []string{"staff", "courier"}
+		}, `transition 2 (ship): role "courier" is not declared`},
+		{"pay emits the wrong event", func(t *testing.T, w *order.Workflow) {
+			transition(t, w, order.ActionPay).Emits = "PaymentReceived"
+		}, "pay must emit OrderPaid"},
+		{"another action emits OrderShipped", func(t *testing.T, w *order.Workflow) {
+			transition(t, w, "deliver").Emits = "OrderShipped"
+		}, "deliver emits OrderShipped but leads to DELIVERED"},
+		{"a built-in action is missing", func(t *testing.T, w *order.Workflow) {
+			w.Transitions = w.Transitions[:3]
+		}, "built-in action cancel is missing"},
+		{"a state is unreachable", func(t *testing.T, w *order.Workflow) {
+			w.States = append(w.States, "ON_HOLD")
+		}, "state ON_HOLD is unreachable from PENDING"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := policy(t)
+			tt.change(t, w)
+			_, err := parse(t, w)
+			var policyErr *order.PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("ParseWorkflow = %v, want a *PolicyError", err)
+			}
+			found := false
+			for _, v := range policyErr.Violations {
+				found = found || v == tt.want
+			}
+			if !found {
+				t.Errorf("violations = %q, want one to be %q", policyErr.Violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyErrorListsEveryViolation(t *testing.T) {
+	w := policy(t)
+	transition(t, w, order.ActionShip).Roles = []string{"courier"}
+	transition(t, w, order.ActionPay).Emits = "PaymentReceived"
+	w.Transitions = append(w.Transitions, order.Transition{Action: "restore", From: []order.OrderStatus{"DELIVERED"}, To: "PENDING"})
+
+	_, err := parse(t, w)
+	var policyErr *order.PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("ParseWorkflow = %v, want a *PolicyError", err)
+	}
+	want := []string{
+		`transition 2 (ship): role "courier" is not declared`,
+		"pay must emit OrderPaid",
+		"DELIVERED is final but has outgoing transitions",
+		"a shipped order can reach PENDING",
+		"a shipped order can reach PAID",
+		"a shipped order can reach CANCELLED",
+	}
+	if !reflect.DeepEqual(policyErr.Violations, want) {
+		t.Errorf("violations:\n%q\nwant:\n%q", policyErr.Violations, want)
+	}
+	if got := err.Error(); got != "invalid order workflow: "+strings.Join(want, "; ") {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestParseWorkflowAcceptsAnExtendedPolicy(t *testing.T) {
+	w := policy(t)
+	w.States = append(w.States, "ON_HOLD")
+	w.Transitions = append(w.Transitions,
+		order.Transition{Action: "hold", From: []order.OrderStatus{"PAID"}, To: "ON_HOLD", Roles: []string{"staff"}, Emits: "OrderHeld"},
+		order.Transition{Action: "release", From: []order.OrderStatus{"ON_HOLD"}, To: "PAID", Roles: []string{"staff"}},
+	)
+	got, err := parse(t, w)
+	if err != nil {
+		t.Fatalf("ParseWorkflow = %v", err)
+	}
+	if actions := got.Permitted("ON_HOLD", "staff"); !reflect.DeepEqual(actions, []order.Action{"release"}) {
+		t.Errorf("Permitted(ON_HOLD, staff) = %v, want [release]", actions)
+	}
+}
+
+func TestWorkflowCheck(t *testing.T) {
+	w := policy(t)
+	w.States = append(w.States, "ON_HOLD")
+	w.Transitions = append(w.Transitions,
+		order.Transition{Action: "hold", From: []order.OrderStatus{"PAID"}, To: "ON_HOLD", Roles: []string{"staff"}},
+		order.Transition{Action: "release", From: []order.OrderStatus{"ON_HOLD"}, To: "PAID", Roles: []string{"staff"}},
+	)
+	w, err := parse(t, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status order.OrderStatus
+		action order.Action
+		role   string
+		want   error
+	}{
+		{order.OrderStatusPaid, order.ActionPay, "customer", order.ErrOrderAlreadyPaid},
+		{order.OrderStatusShipped, order.ActionPay, "customer", order.ErrOrderAlreadyPaid},
+		{order.OrderStatusDelivered, order.ActionPay, "customer", order.ErrOrderAlreadyPaid},
+		{order.OrderStatusCancelled, order.ActionPay, "customer", order.ErrOrderCancelled},
+		{order.OrderStatusPending, order.ActionShip, "staff", order.ErrOrderNotPaid},
+		{order.OrderStatusCancelled, order.ActionShip, "staff", order.ErrOrderNotPaid},
+		{order.OrderStatusShipped, order.ActionCancel, "admin", order.ErrOrderAlreadyShipped},
+		{order.OrderStatusDelivered, order.ActionCancel, "admin", order.ErrOrderAlreadyShipped},
+		// Statuses and actions a policy adds get the generic code
+		{"ON_HOLD", order.ActionPay, "customer", order.ErrTransitionNotAllowed},
+		{order.OrderStatusDelivered, "deliver", "staff", order.ErrTransitionNotAllowed},
+		{order.OrderStatusPaid, "release", "staff", order.ErrTransitionNotAllowed},
+		{order.OrderStatusPaid, order.ActionShip, "customer", order.ErrActionForbidden},
+		{order.OrderStatusPaid, order.ActionShip, "", order.ErrActionForbidden},
+		{order.OrderStatusPaid, order.ActionShip, "staff", nil},
+		{order.OrderStatusPending, order.ActionPay, "", nil},
+		{"ON_HOLD", "release", "staff", nil},
+	}
+	for _, tt := range tests {
+		_, err := w.Check(tt.status, tt.action, tt.role)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Check(%s, %s, %q) = %v, want %v", tt.status, tt.action, tt.role, err, tt.want)
+		}
+	}
+
+	ship, err := w.Check(order.OrderStatusPaid, order.ActionShip, "admin")
+	if err != nil || ship.To != order.OrderStatusShipped || ship.Emits != "OrderShipped" {
+		t.Errorf("Check(PAID, ship, admin) = %+v, %v, want the transition to SHIPPED emitting OrderShipped", ship, err)
+	}
+}
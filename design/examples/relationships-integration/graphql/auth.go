@@ -0,0 +1,64 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+)
+
+// Connection-level auth: a WebSocket client authenticates once in its
+// connection_init payload, and every subscription on that connection runs as
+// the resulting Principal. Customers only see their own orders; admins see all.
+
+var (
+	ErrUnauthenticated = errors.New("missing or invalid token")
+	ErrForbidden       = errors.New("not allowed to access this order")
+)
+
+type Principal struct {
+	CustomerID string
+	Admin      bool
+}
+
+type Authenticator interface {
+	Authenticate(token string) (Principal, error)
+}
+
+// StaticTokens maps bearer tokens to principals, enough for the example
+type StaticTokens map[string]Principal
+
+func (t StaticTokens) Authenticate(token string) (Principal, error) {
+	p, ok := t[token]
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+	return p, nil
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func principalFrom(ctx context.Context) (Principal, error) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+	return p, nil
+}
+
+// authorize narrows the filter to what the principal may see
+func authorize(p Principal, filter StatusFilter, owner string) (StatusFilter, error) {
+	if p.Admin {
+		return filter, nil
+	}
+	if filter.CustomerID != "" && filter.CustomerID != p.CustomerID {
+		return filter, ErrForbidden
+	}
+	if owner != "" && owner != p.CustomerID {
+		return filter, ErrForbidden
+	}
+	filter.CustomerID = p.CustomerID
+	return filter, nil
+}
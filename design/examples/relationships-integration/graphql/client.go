@@ -0,0 +1,178 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client is a minimal programmatic graphql-transport-ws client, used by the
+// subscription demo and handy for scripting against the server.
+
+var ErrClientClosed = errors.New("graphql client is closed")
+
+// Result is one "next" payload, or the errors that ended the operation
+type Result struct {
+	Data   json.RawMessage   `json:"data"`
+	Errors []json.RawMessage `json:"errors"`
+}
+
+type ClientSubscription struct {
+	ID      string
+	Results <-chan Result
+	client  *Client
+}
+
+type Client struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	subs    map[string]chan Result
+	nextID  int
+	err     error
+	done    chan struct{}
+}
+
+// Dial connects and authenticates with token, returning once the server acks
+func Dial(ctx context.Context, url, token string) (*Client, error) {
+	dialer := websocket.Dialer{Subprotocols: []string{Subprotocol}}
+	conn, _, err := dialer.DialContext(ctx, url, http.Header{})
+	if err != nil {
+		return nil, err
+	}
+
+	init, _ := json.Marshal(map[string]string{"token": token})
+	if err := conn.WriteJSON(wsMessage{Type: msgConnectionInit, Payload: init}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var ack wsMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connection rejected: %w", err)
+	}
+	if ack.Type != msgConnectionAck {
+		conn.Close()
+		return nil, fmt.Errorf("expected %s, got %s", msgConnectionAck, ack.Type)
+	}
+
+	c := &Client{conn: conn, subs: make(map[string]chan Result), done: make(chan struct{})}
+	go c.read()
+	return c, nil
+}
+
+func (c *Client) read() {
+	defer close(c.done)
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			c.shutdown(err)
+			return
+		}
+		switch msg.Type {
+		case msgNext:
+			var r Result
+			json.Unmarshal(msg.Payload, &r)
+			c.deliver(msg.ID, r, false)
+		case msgError:
+			var r Result
+			json.Unmarshal(msg.Payload, &r.Errors)
+			c.deliver(msg.ID, r, true)
+		case msgComplete:
+			c.deliver(msg.ID, Result{}, true)
+		case msgPing:
+			c.send(wsMessage{Type: msgPong})
+		}
+	}
+}
+
+func (c *Client) deliver(id string, r Result, last bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.subs[id]
+	if !ok {
+		return
+	}
+	if r.Data != nil || r.Errors != nil {
+		ch <- r
+	}
+	if last {
+		close(ch)
+		delete(c.subs, id)
+	}
+}
+
+func (c *Client) shutdown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	for id, ch := range c.subs {
+		close(ch)
+		delete(c.subs, id)
+	}
+}
+
+// Subscribe starts an operation. Results closes when the server completes it,
+// it fails, or the connection drops (see Err).
+func (c *Client) Subscribe(query string, variables map[string]interface{}) (*ClientSubscription, error) {
+	payload, err := json.Marshal(request{Query: query, Variables: variables})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	ch := make(chan Result, 16)
+	c.subs[id] = ch
+	c.mu.Unlock()
+
+	if err := c.send(wsMessage{ID: id, Type: msgSubscribe, Payload: payload}); err != nil {
+		return nil, err
+	}
+	return &ClientSubscription{ID: id, Results: ch, client: c}, nil
+}
+
+// Stop asks the server to end the operation
+func (s *ClientSubscription) Stop() error {
+	c := s.client
+	c.mu.Lock()
+	if ch, ok := c.subs[s.ID]; ok {
+		close(ch)
+		delete(c.subs, s.ID)
+	}
+	c.mu.Unlock()
+	return c.send(wsMessage{ID: s.ID, Type: msgComplete})
+}
+
+func (c *Client) send(msg wsMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(msg)
+}
+
+// Err reports why the connection ended, e.g. a *websocket.CloseError with the server's close code
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) Close() error {
+	c.writeMu.Lock()
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.writeMu.Unlock()
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
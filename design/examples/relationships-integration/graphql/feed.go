@@ -0,0 +1,153 @@
+package graphql
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+// StatusFeed projects order domain events into current order statuses and
+// streams every change to the GraphQL subscriptions that match it.
+// It is registered on the EventPublisher like any other event handler.
+
+type StatusChange struct {
+	OrderID    string            `json:"order_id"`
+	CustomerID string            `json:"customer_id"`
+	Status     order.OrderStatus `json:"status"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+// StatusFilter selects changes for one order and/or one customer; empty fields match anything
+type StatusFilter struct {
+	OrderID    string
+	CustomerID string
+}
+
+func (f StatusFilter) matches(c StatusChange) bool {
+	return (f.OrderID == "" || f.OrderID == c.OrderID) &&
+		(f.CustomerID == "" || f.CustomerID == c.CustomerID)
+}
+
+type feedSubscriber struct {
+	filter StatusFilter
+	ch     chan StatusChange
+}
+
+type StatusFeed struct {
+	mu          sync.RWMutex
+	orders      map[string]StatusChange
+	subscribers map[*feedSubscriber]struct{}
+	buffer      int
+}
+
+// NewStatusFeed creates a feed. A subscriber that falls more than buffer
+// changes behind misses changes rather than blocking event publishing.
+func NewStatusFeed(buffer int) *StatusFeed {
+	return &StatusFeed{
+		orders:      make(map[string]StatusChange),
+		subscribers: make(map[*feedSubscriber]struct{}),
+		buffer:      buffer,
+	}
+}
+
+func (f *StatusFeed) Handle(ctx context.Context, event patterns.Event) error {
+	change, ok := f.project(event)
+	if !ok {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.orders[change.OrderID] = change
+	for sub := range f.subscribers {
+		if !sub.filter.matches(change) {
+			continue
+		}
+		select {
+		case sub.ch <- change:
+		default:
+		}
+	}
+	return nil
+}
+
+func (f *StatusFeed) project(event patterns.Event) (StatusChange, bool) {
+	change := StatusChange{OccurredAt: event.OccurredAt}
+	switch data := event.Data.(type) {
+	case order.OrderCreatedEvent:
+		change.OrderID, change.CustomerID, change.Status = data.OrderID, data.CustomerID, order.OrderStatusPending
+		return change, true
+	case order.OrderPaidEvent:
+		change.OrderID, change.Status = data.OrderID, order.OrderStatusPaid
+	case order.OrderShippedEvent:
+		change.OrderID, change.Status = data.OrderID, order.OrderStatusShipped
+	case order.OrderStatusChangedEvent:
+		change.OrderID, change.Status = data.OrderID, data.To
+	default:
+		return change, false
+	}
+
+	// Later events don't carry the customer; take it from the projection
+	f.mu.RLock()
+	change.CustomerID = f.orders[change.OrderID].CustomerID
+	f.mu.RUnlock()
+	return change, true
+}
+
+// Current returns the latest known status of an order
+func (f *StatusFeed) Current(orderID string) (StatusChange, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	c, ok := f.orders[orderID]
+	return c, ok
+}
+
+// Subscribe streams matching changes until ctx is done, then closes the channel
+func (f *StatusFeed) Subscribe(ctx context.Context, filter StatusFilter) <-chan StatusChange {
+	sub := &feedSubscriber{filter: filter, ch: make(chan StatusChange, f.buffer)}
+
+	f.mu.Lock()
+	f.subscribers[sub] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		delete(f.subscribers, sub)
+		close(sub.ch)
+		f.mu.Unlock()
+	}()
+	return sub.ch
+}
+
+// Snapshot returns the projection sorted by order ID
+func (f *StatusFeed) Snapshot() []StatusChange {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make([]StatusChange, 0, len(f.orders))
+	for _, c := range f.orders {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OrderID < out[j].OrderID })
+	return out
+}
+
+// Restore loads a projection snapshot without notifying subscribers
+func (f *StatusFeed) Restore(changes []StatusChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range changes {
+		f.orders[c.OrderID] = c
+	}
+}
+
+// Len reports how many orders the projection tracks
+func (f *StatusFeed) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.orders)
+}
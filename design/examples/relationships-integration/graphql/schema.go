@@ -0,0 +1,103 @@
+package graphql
+
+import (
+	"context"
+	"time"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+const Schema = `
+schema {
+	query: Query
+	subscription: Subscription
+}
+
+type Query {
+	order(id: ID!): OrderStatus
+}
+
+type Subscription {
+	# Without arguments a customer receives changes for all of their orders
+	orderStatusChanged(orderId: ID, customerId: ID): OrderStatus!
+}
+
+type OrderStatus {
+	orderId: ID!
+	customerId: ID!
+	status: String!
+	occurredAt: String!
+}
+`
+
+// Resolver is the root resolver for queries and subscriptions
+type Resolver struct {
+	feed *StatusFeed
+}
+
+func NewSchema(feed *StatusFeed) *graphqlgo.Schema {
+	return graphqlgo.MustParseSchema(Schema, &Resolver{feed: feed})
+}
+
+func (r *Resolver) Order(ctx context.Context, args struct{ ID graphqlgo.ID }) (*statusResolver, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	change, ok := r.feed.Current(string(args.ID))
+	if !ok {
+		return nil, nil
+	}
+	if _, err := authorize(p, StatusFilter{}, change.CustomerID); err != nil {
+		return nil, err
+	}
+	return &statusResolver{change}, nil
+}
+
+func (r *Resolver) OrderStatusChanged(ctx context.Context, args struct {
+	OrderID    *graphqlgo.ID
+	CustomerID *graphqlgo.ID
+}) (<-chan *statusResolver, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter StatusFilter
+	var owner string
+	if args.OrderID != nil {
+		filter.OrderID = string(*args.OrderID)
+		if current, ok := r.feed.Current(filter.OrderID); ok {
+			owner = current.CustomerID
+		}
+	}
+	if args.CustomerID != nil {
+		filter.CustomerID = string(*args.CustomerID)
+	}
+	if filter, err = authorize(p, filter, owner); err != nil {
+		return nil, err
+	}
+
+	changes := r.feed.Subscribe(ctx, filter)
+	out := make(chan *statusResolver)
+	go func() {
+		defer close(out)
+		for change := range changes {
+			select {
+			case out <- &statusResolver{change}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+type statusResolver struct {
+	c StatusChange
+}
+
+func (r *statusResolver) OrderID() graphqlgo.ID    { return graphqlgo.ID(r.c.OrderID) }
+func (r *statusResolver) CustomerID() graphqlgo.ID { return graphqlgo.ID(r.c.CustomerID) }
+func (r *statusResolver) Status() string           { return string(r.c.Status) }
+func (r *statusResolver) OccurredAt() string       { return r.c.OccurredAt.UTC().Format(time.RFC3339Nano) }
@@ -0,0 +1,269 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/labstack/echo/v4"
+)
+
+// Transports for the GraphQL schema:
+//   - POST /graphql       queries, authenticated with "Authorization: Bearer <token>"
+//   - GET  /graphql/ws    subscriptions over the graphql-transport-ws protocol,
+//     authenticated once with {"token": "..."} in the connection_init payload
+
+const Subprotocol = "graphql-transport-ws"
+
+// graphql-transport-ws message types
+const (
+	msgConnectionInit = "connection_init"
+	msgConnectionAck  = "connection_ack"
+	msgPing           = "ping"
+	msgPong           = "pong"
+	msgSubscribe      = "subscribe"
+	msgNext           = "next"
+	msgError          = "error"
+	msgComplete       = "complete"
+)
+
+// graphql-transport-ws close codes
+const (
+	closeBadRequest      = 4400
+	closeUnauthorized    = 4401
+	closeInitTimeout     = 4408
+	closeSubscriberTaken = 4409
+	closeTooManyInits    = 4429
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type Server struct {
+	schema      *graphqlgo.Schema
+	auth        Authenticator
+	upgrader    websocket.Upgrader
+	InitTimeout time.Duration
+}
+
+func NewServer(schema *graphqlgo.Schema, auth Authenticator) *Server {
+	return &Server{
+		schema: schema,
+		auth:   auth,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{Subprotocol},
+			CheckOrigin:  func(*http.Request) bool { return true },
+		},
+		InitTimeout: 10 * time.Second,
+	}
+}
+
+// Query - POST /graphql
+func (s *Server) Query(c echo.Context) error {
+	token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	p, err := s.auth.Authenticate(token)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	var req request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	ctx := withPrincipal(c.Request().Context(), p)
+	return c.JSON(http.StatusOK, s.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// Subscriptions - GET /graphql/ws
+func (s *Server) Subscriptions(c echo.Context) error {
+	conn, err := s.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return nil // the upgrader already wrote the HTTP error
+	}
+	if conn.Subprotocol() != Subprotocol {
+		conn.Close()
+		return nil
+	}
+	sc := &serverConn{server: s, conn: conn, ops: make(map[string]context.CancelFunc)}
+	sc.serve(c.Request().Context())
+	return nil
+}
+
+type serverConn struct {
+	server    *Server
+	conn      *websocket.Conn
+	writeMu   sync.Mutex
+	mu        sync.Mutex
+	ops       map[string]context.CancelFunc
+	principal *Principal
+}
+
+func (sc *serverConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		sc.conn.Close()
+	}()
+
+	initTimer := time.AfterFunc(sc.server.InitTimeout, func() {
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		if sc.principal == nil {
+			sc.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	for {
+		var msg wsMessage
+		if err := sc.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case msgConnectionInit:
+			if !sc.init(msg.Payload) {
+				return
+			}
+		case msgPing:
+			sc.send(wsMessage{Type: msgPong})
+		case msgPong:
+		case msgSubscribe:
+			if !sc.subscribe(ctx, msg) {
+				return
+			}
+		case msgComplete:
+			sc.mu.Lock()
+			if stop, ok := sc.ops[msg.ID]; ok {
+				stop()
+				delete(sc.ops, msg.ID)
+			}
+			sc.mu.Unlock()
+		default:
+			sc.close(closeBadRequest, "Unknown message type")
+			return
+		}
+	}
+}
+
+func (sc *serverConn) init(payload json.RawMessage) bool {
+	var params struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(payload, &params)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.principal != nil {
+		sc.close(closeTooManyInits, "Too many initialisation requests")
+		return false
+	}
+	p, err := sc.server.auth.Authenticate(params.Token)
+	if err != nil {
+		sc.close(closeUnauthorized, "Unauthorized")
+		return false
+	}
+	sc.principal = &p
+	sc.send(wsMessage{Type: msgConnectionAck})
+	return true
+}
+
+func (sc *serverConn) subscribe(ctx context.Context, msg wsMessage) bool {
+	var req request
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+		sc.close(closeBadRequest, "Invalid subscribe message")
+		return false
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.principal == nil {
+		sc.close(closeUnauthorized, "Unauthorized")
+		return false
+	}
+	if _, exists := sc.ops[msg.ID]; exists {
+		sc.close(closeSubscriberTaken, "Subscriber for "+msg.ID+" already exists")
+		return false
+	}
+
+	opCtx, stop := context.WithCancel(withPrincipal(ctx, *sc.principal))
+	responses, err := sc.server.schema.Subscribe(opCtx, req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		stop()
+		sc.sendErrors(msg.ID, err.Error())
+		return true
+	}
+	sc.ops[msg.ID] = stop
+	go sc.stream(msg.ID, responses)
+	return true
+}
+
+// stream forwards one operation's results. A response with errors and no data
+// (e.g. a forbidden subscription) ends the operation with an error message.
+func (sc *serverConn) stream(id string, responses <-chan interface{}) {
+	for r := range responses {
+		resp, ok := r.(*graphqlgo.Response)
+		if !ok {
+			continue
+		}
+		if resp.Data == nil && len(resp.Errors) > 0 {
+			payload, _ := json.Marshal(resp.Errors)
+			sc.send(wsMessage{ID: id, Type: msgError, Payload: payload})
+			sc.finish(id)
+			return
+		}
+		payload, _ := json.Marshal(resp)
+		sc.send(wsMessage{ID: id, Type: msgNext, Payload: payload})
+	}
+	// Only report completion if the client didn't stop the operation itself
+	if sc.finish(id) {
+		sc.send(wsMessage{ID: id, Type: msgComplete})
+	}
+}
+
+// finish forgets the operation, reporting false if it was already gone
+func (sc *serverConn) finish(id string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	stop, ok := sc.ops[id]
+	if ok {
+		stop()
+		delete(sc.ops, id)
+	}
+	return ok
+}
+
+func (sc *serverConn) sendErrors(id string, messages ...string) {
+	errs := make([]map[string]string, len(messages))
+	for i, m := range messages {
+		errs[i] = map[string]string{"message": m}
+	}
+	payload, _ := json.Marshal(errs)
+	sc.send(wsMessage{ID: id, Type: msgError, Payload: payload})
+}
+
+func (sc *serverConn) send(msg wsMessage) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	sc.conn.WriteJSON(msg)
+}
+
+func (sc *serverConn) close(code int, reason string) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	deadline := time.Now().Add(time.Second)
+	sc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	sc.conn.Close()
+}
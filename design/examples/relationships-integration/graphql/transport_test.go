@@ -0,0 +1,259 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/graphql"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+const statusSubscription = `subscription($orderId: ID, $customerId: ID) {
+	orderStatusChanged(orderId: $orderId, customerId: $customerId) { orderId customerId status }
+}`
+
+type status struct {
+	OrderID    string `json:"orderId"`
+	CustomerID string `json:"customerId"`
+	Status     string `json:"status"`
+}
+
+// serve starts the GraphQL transports over httptest and returns the feed
+// behind them and the WebSocket URL
+func serve(t *testing.T) (*graphql.StatusFeed, string) {
+	t.Helper()
+	feed := graphql.NewStatusFeed(32)
+	server := graphql.NewServer(graphql.NewSchema(feed), graphql.StaticTokens{
+		"admin-token": {Admin: true},
+		"alice-token": {CustomerID: "alice"},
+		"bob-token":   {CustomerID: "bob"},
+	})
+	e := echo.New()
+	e.GET("/graphql/ws", server.Subscriptions)
+	ts := httptest.NewServer(e)
+	t.Cleanup(ts.Close)
+	return feed, "ws" + strings.TrimPrefix(ts.URL, "http") + "/graphql/ws"
+}
+
+func dial(t *testing.T, url, token string) *graphql.Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	client, err := graphql.Dial(ctx, url, token)
+	if err != nil {
+		t.Fatalf("Dial(%s) = %v", token, err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// subscribe starts a status subscription and returns once the server has
+// registered it: the server handles a connection's messages in order, so an
+// invalid operation sent afterwards fails only once the first is running
+func subscribe(t *testing.T, client *graphql.Client, variables map[string]interface{}) *graphql.ClientSubscription {
+	t.Helper()
+	sub, err := client.Subscribe(statusSubscription, variables)
+	if err != nil {
+		t.Fatalf("Subscribe(%v) = %v", variables, err)
+	}
+	barrier, err := client.Subscribe(`subscription { unknownField }`, nil)
+	if err != nil {
+		t.Fatalf("Subscribe(barrier) = %v", err)
+	}
+	for range barrier.Results {
+	}
+	return sub
+}
+
+// publish feeds order events to the status projection as the publisher would
+func publish(t *testing.T, feed *graphql.StatusFeed, events ...interface{}) {
+	t.Helper()
+	for _, data := range events {
+		if err := feed.Handle(context.Background(), patterns.Event{Data: data, OccurredAt: time.Now()}); err != nil {
+			t.Fatalf("Handle(%T) = %v", data, err)
+		}
+	}
+}
+
+// cancel ends an order; tests publish it last and received stops there
+func cancel(orderID string) order.OrderStatusChangedEvent {
+	return order.OrderStatusChangedEvent{OrderID: orderID, Action: "cancel", To: order.OrderStatusCancelled}
+}
+
+// received collects the statuses sent to sub until the first cancellation
+func received(t *testing.T, sub *graphql.ClientSubscription) []status {
+	t.Helper()
+	var got []status
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case r, ok := <-sub.Results:
+			if !ok {
+				t.Fatalf("subscription ended after %v", got)
+			}
+			if len(r.Errors) > 0 {
+				t.Fatalf("subscription errors: %s", r.Errors)
+			}
+			var data struct {
+				OrderStatusChanged status `json:"orderStatusChanged"`
+			}
+			if err := json.Unmarshal(r.Data, &data); err != nil {
+				t.Fatalf("decoding %s: %v", r.Data, err)
+			}
+			if data.OrderStatusChanged.Status == string(order.OrderStatusCancelled) {
+				return got
+			}
+			got = append(got, data.OrderStatusChanged)
+		case <-timeout:
+			t.Fatalf("timed out after %v", got)
+		}
+	}
+}
+
+// rejected waits for sub to end with an error and returns its messages
+func rejected(t *testing.T, sub *graphql.ClientSubscription) string {
+	t.Helper()
+	select {
+	case r, ok := <-sub.Results:
+		if !ok || len(r.Errors) == 0 {
+			t.Fatalf("subscription got %s, want it rejected", r.Data)
+		}
+		if _, open := <-sub.Results; open {
+			t.Errorf("subscription still open after its error")
+		}
+		return string(r.Errors[0])
+	case <-time.After(time.Second):
+		t.Fatalf("subscription not rejected")
+	}
+	return ""
+}
+
+func orders(statuses []status) []string {
+	out := make([]string, len(statuses))
+	for i, s := range statuses {
+		out[i] = s.OrderID + ":" + s.Status
+	}
+	return out
+}
+
+func TestBadTokenIsRejected(t *testing.T) {
+	_, url := serve(t)
+	for _, token := range []string{"", "wrong-token"} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		client, err := graphql.Dial(ctx, url, token)
+		cancel()
+		if err == nil {
+			client.Close()
+			t.Fatalf("Dial(%q) accepted", token)
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != 4401 {
+			t.Errorf("Dial(%q) = %v, want close code 4401", token, err)
+		}
+	}
+}
+
+func TestSubscribeBeforeInitIsRejected(t *testing.T) {
+	_, url := serve(t)
+	dialer := websocket.Dialer{Subprotocols: []string{graphql.Subprotocol}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial = %v", err)
+	}
+	defer conn.Close()
+
+	subscribe := map[string]interface{}{"id": "1", "type": "subscribe", "payload": map[string]string{"query": statusSubscription}}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		t.Fatalf("WriteJSON = %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != 4401 {
+		t.Errorf("read after subscribe = %v, want close code 4401", err)
+	}
+}
+
+func TestSubscriptionFilters(t *testing.T) {
+	feed, url := serve(t)
+	publish(t, feed, order.OrderCreatedEvent{OrderID: "o1", CustomerID: "alice"})
+
+	tests := []struct {
+		name      string
+		variables map[string]interface{}
+		want      []string
+	}{
+		{"everything", nil, []string{"o1:PAID", "o2:PENDING", "o3:PENDING", "o2:SHIPPED"}},
+		{"one order", map[string]interface{}{"orderId": "o2"}, []string{"o2:PENDING", "o2:SHIPPED"}},
+		{"one customer", map[string]interface{}{"customerId": "alice"}, []string{"o1:PAID", "o3:PENDING"}},
+		{"order and customer", map[string]interface{}{"orderId": "o3", "customerId": "alice"}, []string{"o3:PENDING"}},
+	}
+
+	// Every subscriber shares one connection; each gets only its own matches
+	admin := dial(t, url, "admin-token")
+	subs := make([]*graphql.ClientSubscription, len(tests))
+	for i, tt := range tests {
+		subs[i] = subscribe(t, admin, tt.variables)
+	}
+	publish(t, feed,
+		order.OrderPaidEvent{OrderID: "o1"},
+		order.OrderCreatedEvent{OrderID: "o2", CustomerID: "bob"},
+		order.OrderCreatedEvent{OrderID: "o3", CustomerID: "alice"},
+		order.OrderShippedEvent{OrderID: "o2"},
+		cancel("o2"),
+		cancel("o3"),
+	)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orders(received(t, subs[i])); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("received %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomersSeeOnlyTheirOwnOrders(t *testing.T) {
+	feed, url := serve(t)
+	publish(t, feed, order.OrderCreatedEvent{OrderID: "bobs-order", CustomerID: "bob"})
+
+	alice := dial(t, url, "alice-token")
+	mine := subscribe(t, alice, nil)
+
+	// Asking for another customer's orders, by customer or by order, is refused
+	for _, variables := range []map[string]interface{}{
+		{"customerId": "bob"},
+		{"orderId": "bobs-order"},
+	} {
+		sub, err := alice.Subscribe(statusSubscription, variables)
+		if err != nil {
+			t.Fatalf("Subscribe(%v) = %v", variables, err)
+		}
+		if msg := rejected(t, sub); !strings.Contains(msg, graphql.ErrForbidden.Error()) {
+			t.Errorf("Subscribe(%v) rejected with %s, want %q", variables, msg, graphql.ErrForbidden)
+		}
+	}
+
+	publish(t, feed,
+		order.OrderPaidEvent{OrderID: "bobs-order"},
+		order.OrderCreatedEvent{OrderID: "alices-order", CustomerID: "alice"},
+		order.OrderCreatedEvent{OrderID: "bobs-second", CustomerID: "bob"},
+		order.OrderShippedEvent{OrderID: "alices-order"},
+		cancel("bobs-order"),
+		cancel("alices-order"),
+	)
+	want := []string{"alices-order:PENDING", "alices-order:SHIPPED"}
+	if got := orders(received(t, mine)); !reflect.DeepEqual(got, want) {
+		t.Errorf("alice received %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+	"github.com/labstack/echo/v4"
+)
+
+// DIGraphHandler serves the dependency graph recorded by the composition root
+type DIGraphHandler struct {
+	graph *patterns.DependencyGraph
+}
+
+func NewDIGraphHandler(graph *patterns.DependencyGraph) *DIGraphHandler {
+	return &DIGraphHandler{graph: graph}
+}
+
+// GetGraph - GET /debug/di-graph?format=json|dot
+func (h *DIGraphHandler) GetGraph(c echo.Context) error {
+	snapshot := h.graph.Snapshot()
+	switch c.QueryParam("format") {
+	case "", "json":
+		return c.JSON(http.StatusOK, snapshot)
+	case "dot":
+		return c.Blob(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(snapshot.DOT()))
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": "format must be json or dot"})
+}
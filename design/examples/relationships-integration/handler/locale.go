@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/shared/i18n"
+	"github.com/labstack/echo/v4"
+)
+
+const messagesKey = "i18n.bundle"
+
+// Localize picks the response language from Accept-Language and puts it in the
+// request context, where error responses and event handlers find it
+func Localize(bundle *i18n.Bundle) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			locale := bundle.Negotiate(c.Request().Header.Get("Accept-Language"))
+			c.Set(messagesKey, bundle)
+			c.Response().Header().Set("Content-Language", locale)
+			c.Response().Header().Add("Vary", "Accept-Language")
+			ctx := i18n.WithLocale(c.Request().Context(), locale)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// localizeError translates the coded part of an error's text, keeping any
+// context wrapped around it ("order 3: ...")
+func localizeError(c echo.Context, coded *order.Error, detail string) string {
+	bundle, ok := c.Get(messagesKey).(*i18n.Bundle)
+	if !ok {
+		return detail
+	}
+	msg := bundle.Message(i18n.LocaleFrom(c.Request().Context()), "error."+string(coded.Code), nil)
+	return strings.Replace(detail, coded.Message, msg, 1)
+}
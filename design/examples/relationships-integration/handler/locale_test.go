@@ -0,0 +1,74 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/handler"
+	"github.com/dong-tran/docs/integration-example/repository"
+	"github.com/dong-tran/docs/integration-example/shared/i18n"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+	"github.com/dong-tran/docs/integration-example/usecase"
+)
+
+// TestEveryErrorCodeHasAMessage covers the domain codes and the ones the
+// HTTP layer declares, which this package registers when it is loaded
+func TestEveryErrorCodeHasAMessage(t *testing.T) {
+	bundle, err := i18n.Default()
+	if err != nil {
+		t.Fatalf("i18n.Default() = %v", err)
+	}
+	base := bundle.Catalog(bundle.DefaultLocale())
+	for _, e := range order.Codes() {
+		if _, ok := base["error."+string(e.Code)]; !ok {
+			t.Errorf("%s: no message for error code %s", bundle.DefaultLocale(), e.Code)
+		}
+	}
+}
+
+func TestLocalizedErrorResponses(t *testing.T) {
+	bundle, err := i18n.Default()
+	if err != nil {
+		t.Fatalf("i18n.Default() = %v", err)
+	}
+	// The real order endpoints; the repository stub never reaches a database
+	orders := handler.NewOrderHandler(usecase.NewOrderUseCase(
+		repository.NewOrderRepository(nil), patterns.NewPaymentFactory(), patterns.NewEventPublisher()))
+	e := echo.New()
+	e.Use(handler.Localize(bundle))
+	e.GET("/orders/:id", orders.GetOrder)
+	e.POST("/orders", orders.CreateOrder)
+	e.POST("/orders/import", orders.ImportOrders)
+
+	badItem := `{"customer_id":"c1","items":[{"product_id":"p1","quantity":0,"price":5}]}`
+	goodItem := `{"customer_id":"c1","items":[{"product_id":"p1","quantity":1,"price":5}]}`
+	tests := []struct {
+		method, path, body, acceptLanguage string
+		language, want                     string
+	}{
+		{http.MethodGet, "/orders/42", "", "vi-VN,vi;q=0.9", "vi", "không tìm thấy đơn hàng"},
+		{http.MethodGet, "/orders/42", "", "fr", "en", "order not found"},
+		{http.MethodGet, "/orders/42", "", "", "en", "order not found"},
+		{http.MethodPost, "/orders", `{"customer_id":"c1","items":[]}`, "vi", "vi", "đơn hàng phải có ít nhất một sản phẩm"},
+		{http.MethodPost, "/orders/import", `{"orders":[` + goodItem + `,` + badItem + `]}`, "vi", "vi", "order 1: số lượng phải lớn hơn 0"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Language"); got != tt.language {
+			t.Errorf("%s %s [%s]: Content-Language = %q, want %q", tt.method, tt.path, tt.acceptLanguage, got, tt.language)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+			t.Errorf("%s %s [%s]: %d %s, want %q in it", tt.method, tt.path, tt.acceptLanguage, rec.Code, body, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 "net/http"
 
+"github.com/dong-tran/docs/integration-example/domain/order"
 "github.com/dong-tran/docs/integration-example/usecase"
 "github.com/labstack/echo/v4"
 )
@@ -29,23 +30,17 @@ type OrderItemRequest struct {
 	Currency    string  `json:"currency"`
 }
 
-type ProcessPaymentRequest struct {
-	PaymentMethod string `json:"payment_method"`
+type ImportOrdersRequest struct {
+	Orders []CreateOrderRequest `json:"orders"`
 }
 
-func (h *OrderHandler) CreateOrder(c echo.Context) error {
-	var req CreateOrderRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
-	}
-
-	// Convert to DTO
+func (r CreateOrderRequest) toDTO() usecase.CreateOrderDTO {
 	dto := usecase.CreateOrderDTO{
-		CustomerID: req.CustomerID,
-		Items:      make([]usecase.OrderItemDTO, len(req.Items)),
+		CustomerID: r.CustomerID,
+		Items:      make([]usecase.OrderItemDTO, len(r.Items)),
 	}
 
-	for i, item := range req.Items {
+	for i, item := range r.Items {
 		dto.Items[i] = usecase.OrderItemDTO{
 			ProductID:   item.ProductID,
 			ProductName: item.ProductName,
@@ -54,10 +49,26 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 			Currency:    item.Currency,
 		}
 	}
+	return dto
+}
+
+type ProcessPaymentRequest struct {
+	PaymentMethod string `json:"payment_method"`
+}
+
+type ShipOrderRequest struct {
+	TrackingNumber string `json:"tracking_number"`
+}
+
+func (h *OrderHandler) CreateOrder(c echo.Context) error {
+	var req CreateOrderRequest
+	if err := c.Bind(&req); err != nil {
+		return writeError(c, ErrInvalidRequest)
+	}
 
-	order, err := h.orderUseCase.CreateOrder(dto)
+	order, err := h.orderUseCase.CreateOrder(c.Request().Context(), req.toDTO())
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return writeError(c, err)
 	}
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
@@ -69,27 +80,80 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 	})
 }
 
+// ImportOrders creates many orders at once; either all are created or none
+func (h *OrderHandler) ImportOrders(c echo.Context) error {
+	var req ImportOrdersRequest
+	if err := c.Bind(&req); err != nil || len(req.Orders) == 0 {
+		return writeError(c, ErrInvalidRequest)
+	}
+
+	dtos := make([]usecase.CreateOrderDTO, len(req.Orders))
+	for i, o := range req.Orders {
+		dtos[i] = o.toDTO()
+	}
+
+	orders, err := h.orderUseCase.ImportOrders(c.Request().Context(), dtos)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID().String()
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+"created": len(ids),
+		"ids":     ids,
+	})
+}
+
 func (h *OrderHandler) ProcessPayment(c echo.Context) error {
 	orderID := c.Param("id")
 	
 	var req ProcessPaymentRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return writeError(c, ErrInvalidRequest)
 	}
 
-	if err := h.orderUseCase.ProcessPayment(orderID, req.PaymentMethod); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := h.orderUseCase.ProcessPayment(c.Request().Context(), orderID, req.PaymentMethod); err != nil {
+		return writeError(c, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "payment processed"})
 }
 
+func (h *OrderHandler) ShipOrder(c echo.Context) error {
+	var req ShipOrderRequest
+	if err := c.Bind(&req); err != nil || req.TrackingNumber == "" {
+		return writeError(c, ErrInvalidRequest)
+	}
+
+	if err := h.orderUseCase.ShipOrder(c.Request().Context(), c.Param("id"), req.TrackingNumber); err != nil {
+		return writeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "order shipped"})
+}
+
+// PerformAction runs a workflow action such as cancel or deliver - POST /orders/:id/actions/:action
+func (h *OrderHandler) PerformAction(c echo.Context) error {
+	ord, err := h.orderUseCase.PerformAction(c.Request().Context(), c.Param("id"), order.Action(c.Param("action")))
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+"id":     ord.ID().String(),
+		"status": ord.Status(),
+	})
+}
+
 func (h *OrderHandler) GetOrder(c echo.Context) error {
 	orderID := c.Param("id")
 	
 	order, err := h.orderUseCase.GetOrder(orderID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+		return writeError(c, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
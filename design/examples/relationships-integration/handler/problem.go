@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/labstack/echo/v4"
+)
+
+// Order endpoints answer errors with RFC 7807 problem details. The code field
+// is the domain error code, so clients branch on ORDER_ALREADY_PAID rather
+// than on the wording of the message.
+
+const ProblemContentType = "application/problem+json"
+
+var (
+	ErrInvalidRequest = order.NewError("REQUEST_INVALID", order.KindInvalid, "invalid request")
+	ErrInternal       = order.NewError("INTERNAL_ERROR", order.KindInternal, "internal error")
+)
+
+type Problem struct {
+	Type     string     `json:"type"`
+	Title    string     `json:"title"`
+	Status   int        `json:"status"`
+	Detail   string     `json:"detail,omitempty"`
+	Code     order.Code `json:"code"`
+	Instance string     `json:"instance,omitempty"`
+}
+
+func statusOf(kind order.Kind) int {
+	switch kind {
+	case order.KindInvalid:
+		return http.StatusBadRequest
+	case order.KindNotFound:
+		return http.StatusNotFound
+	case order.KindConflict:
+		return http.StatusConflict
+	case order.KindForbidden:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError answers with the problem for err. The detail is the full error
+// text, which for coded errors may add context ("order 3: quantity must be
+// positive"); uncoded errors become INTERNAL_ERROR and their text is only logged.
+// Behind the Localize middleware the message is in the negotiated language.
+func writeError(c echo.Context, err error) error {
+	var coded *order.Error
+	detail := err.Error()
+	if !errors.As(err, &coded) {
+		c.Logger().Error(err)
+		coded, detail = ErrInternal, ErrInternal.Message
+	}
+	detail = localizeError(c, coded, detail)
+	status := statusOf(coded.Kind)
+	body, mErr := json.Marshal(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Code:     coded.Code,
+		Instance: c.Request().URL.Path,
+	})
+	if mErr != nil {
+		return mErr
+	}
+	return c.Blob(status, ProblemContentType, body)
+}
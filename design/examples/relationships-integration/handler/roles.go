@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/dong-tran/docs/integration-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// StaticRoles maps bearer tokens to workflow roles, enough for the example
+type StaticRoles map[string]string
+
+// Roles puts the caller's role in the request context, where the use cases
+// check it against the workflow policy. A request without a known token is
+// anonymous and may only perform actions the policy leaves open to anyone.
+func Roles(tokens StaticRoles) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := strings.TrimPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if role, ok := tokens[token]; ok {
+				ctx := usecase.WithRole(c.Request().Context(), role)
+				c.SetRequest(c.Request().WithContext(ctx))
+			}
+			return next(c)
+		}
+	}
+}
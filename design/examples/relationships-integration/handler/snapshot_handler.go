@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/dong-tran/docs/integration-example/infrastructure"
+	"github.com/dong-tran/docs/integration-example/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+const SnapshotIDHeader = "X-Snapshot-ID"
+
+// SnapshotRole is the role a caller needs to export or import a snapshot
+const SnapshotRole = "admin"
+
+// SnapshotHandler exports and imports the full demo state. Both read or
+// replace every order, so only callers with SnapshotRole may use them.
+type SnapshotHandler struct {
+	snapshotter *infrastructure.Snapshotter
+}
+
+func NewSnapshotHandler(snapshotter *infrastructure.Snapshotter) *SnapshotHandler {
+	return &SnapshotHandler{snapshotter: snapshotter}
+}
+
+// forbidden answers 403 to a caller without SnapshotRole
+func forbidden(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{"error": "snapshots require the " + SnapshotRole + " role"})
+}
+
+// Export - GET /admin/snapshot
+func (h *SnapshotHandler) Export(c echo.Context) error {
+	if usecase.RoleFrom(c.Request().Context()) != SnapshotRole {
+		return forbidden(c)
+	}
+	var buf bytes.Buffer
+	id, err := h.snapshotter.Export(&buf)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set(SnapshotIDHeader, id)
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="snapshot-`+id[:12]+`.tar.gz"`)
+	return c.Blob(http.StatusOK, "application/gzip", buf.Bytes())
+}
+
+// Import - POST /admin/snapshot with the archive as the request body
+func (h *SnapshotHandler) Import(c echo.Context) error {
+	if usecase.RoleFrom(c.Request().Context()) != SnapshotRole {
+		return forbidden(c)
+	}
+	id, manifest, err := h.snapshotter.Import(c.Request().Body)
+	switch {
+	case errors.Is(err, infrastructure.ErrSnapshotNotEmpty):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, infrastructure.ErrSnapshotCorrupt), errors.Is(err, infrastructure.ErrSnapshotUnsupported):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":       id,
+		"manifest": manifest,
+	})
+}
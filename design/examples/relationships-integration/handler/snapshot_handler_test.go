@@ -0,0 +1,73 @@
+package handler_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/dong-tran/docs/integration-example/graphql"
+	"github.com/dong-tran/docs/integration-example/handler"
+	"github.com/dong-tran/docs/integration-example/infrastructure"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+func TestSnapshotHandlerStatuses(t *testing.T) {
+	// serve returns a server backed by an empty instance and its database
+	serve := func(t *testing.T) (*echo.Echo, func() error) {
+		db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "orders.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		snapshots := handler.NewSnapshotHandler(infrastructure.NewSnapshotter(db, patterns.NewMemoryEventStore(0), graphql.NewStatusFeed(1)))
+		e := echo.New()
+		e.Use(handler.Roles(handler.StaticRoles{"admin-token": "admin", "staff-token": "staff"}))
+		e.GET("/admin/snapshot", snapshots.Export)
+		e.POST("/admin/snapshot", snapshots.Import)
+		return e, db.Close
+	}
+	call := func(e *echo.Echo, method, token string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/snapshot", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	e, _ := serve(t)
+	exported := call(e, http.MethodGet, "admin-token", nil)
+	if exported.Code != http.StatusOK || exported.Header().Get(handler.SnapshotIDHeader) == "" {
+		t.Fatalf("export: status %d, ID %q", exported.Code, exported.Header().Get(handler.SnapshotIDHeader))
+	}
+	archive := exported.Body.Bytes()
+
+	tests := []struct {
+		name, method, token string
+		body                []byte
+		closeDB             bool
+		want                int
+	}{
+		{"export without a token", http.MethodGet, "", nil, false, http.StatusForbidden},
+		{"import as staff", http.MethodPost, "staff-token", archive, false, http.StatusForbidden},
+		{"import of a corrupt archive", http.MethodPost, "admin-token", []byte("not a snapshot"), false, http.StatusBadRequest},
+		{"import when the database fails", http.MethodPost, "admin-token", archive, true, http.StatusInternalServerError},
+		{"import into an empty instance", http.MethodPost, "admin-token", archive, false, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, closeDB := serve(t)
+			if tt.closeDB {
+				closeDB()
+			}
+			if rec := call(e, tt.method, tt.token, tt.body); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+	"github.com/dong-tran/docs/integration-example/usecase"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const CorrelationHeader = "X-Correlation-ID"
+
+// CorrelationID middleware tags each request with a correlation ID, taken from
+// the incoming header or generated, so the events it causes can be traced.
+func CorrelationID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := c.Request().Header.Get(CorrelationHeader)
+			if id == "" {
+				id = uuid.New().String()
+			}
+			c.Response().Header().Set(CorrelationHeader, id)
+			ctx := patterns.WithCorrelationID(c.Request().Context(), id)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// TraceRole is the role a caller needs to read a trace
+const TraceRole = "admin"
+
+// TraceHandler serves the event flow graph behind the admin "trace this order" view.
+// A trace carries every event payload and handler error, so only admins may read it.
+type TraceHandler struct {
+	store *patterns.MemoryEventStore
+}
+
+func NewTraceHandler(store *patterns.MemoryEventStore) *TraceHandler {
+	return &TraceHandler{store: store}
+}
+
+// GetTrace - GET /admin/traces/:correlationId
+func (h *TraceHandler) GetTrace(c echo.Context) error {
+	if usecase.RoleFrom(c.Request().Context()) != TraceRole {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "traces require the " + TraceRole + " role"})
+	}
+	graph, err := h.store.Trace(c.Param("correlationId"))
+	if errors.Is(err, patterns.ErrTraceNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, graph)
+}
@@ -0,0 +1,43 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/dong-tran/docs/integration-example/handler"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+func TestGetTraceRequiresTheAdminRole(t *testing.T) {
+	store := patterns.NewMemoryEventStore(0)
+	store.RecordEvent(patterns.Event{ID: "e1", Type: "OrderCreated", CorrelationID: "req-1"})
+	e := echo.New()
+	e.Use(handler.Roles(handler.StaticRoles{"admin-token": "admin", "staff-token": "staff"}))
+	e.GET("/admin/traces/:correlationId", handler.NewTraceHandler(store).GetTrace)
+
+	tests := []struct {
+		name, token, path string
+		want              int
+	}{
+		{"anonymous", "", "/admin/traces/req-1", http.StatusForbidden},
+		{"staff", "staff-token", "/admin/traces/req-1", http.StatusForbidden},
+		{"admin", "admin-token", "/admin/traces/req-1", http.StatusOK},
+		{"admin, unknown correlation", "admin-token", "/admin/traces/req-2", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -6,7 +6,12 @@ _ "github.com/mattn/go-sqlite3"
 )
 
 func InitDatabase() (*sqlx.DB, error) {
-	db, err := sqlx.Open("sqlite3", "./orders.db")
+	return OpenDatabase("./orders.db")
+}
+
+// OpenDatabase opens the SQLite database at path, creating the schema if needed
+func OpenDatabase(path string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("sqlite3", path)
 	if err != nil {
 		return nil, err
 	}
@@ -1,26 +1,109 @@
 package infrastructure
 
 import (
-"fmt"
-"github.com/dong-tran/docs/integration-example/shared/patterns"
+	"context"
+	"fmt"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/shared/i18n"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
 )
 
-// Event handlers demonstrating Observer pattern
+// Event handlers demonstrating Observer pattern.
+// Each handler checks its context first so a cancelled request or an
+// expired handler timeout is reported instead of silently ignored.
 
-type EmailNotificationHandler struct{}
+// EmailNotificationHandler renders the "notification.<EventType>" template in
+// the locale of the request that caused the event. Without Messages, or for an
+// event type that has no template, it prints the raw event as before.
+type EmailNotificationHandler struct {
+	Messages *i18n.Bundle
+}
 
-func (h *EmailNotificationHandler) OnEvent(event patterns.Event) {
+func (h *EmailNotificationHandler) Handle(ctx context.Context, event patterns.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	locale := i18n.LocaleFrom(ctx)
+	if h.Messages != nil {
+		if _, _, ok := h.Messages.Lookup(locale, "notification."+event.Type); ok {
+			if locale == "" {
+				locale = h.Messages.DefaultLocale()
+			}
+			msg := h.Messages.Message(locale, "notification."+event.Type, notificationArgs(event.Data))
+			fmt.Printf("📧 Email [%s]: %s\n", locale, msg)
+			return nil
+		}
+	}
 	fmt.Printf("📧 Email Handler: %s - %+v\n", event.Type, event.Data)
+	return nil
+}
+
+// notificationArgs exposes event fields to templates under snake_case names
+func notificationArgs(data interface{}) map[string]interface{} {
+	switch e := data.(type) {
+	case order.OrderCreatedEvent:
+		return map[string]interface{}{"order_id": e.OrderID, "customer_id": e.CustomerID, "total": fmt.Sprintf("%.2f", e.Total)}
+	case order.OrderPaidEvent:
+		return map[string]interface{}{"order_id": e.OrderID, "payment_method": e.PaymentMethod, "amount": fmt.Sprintf("%.2f", e.Amount)}
+	case order.OrderShippedEvent:
+		return map[string]interface{}{"order_id": e.OrderID, "tracking_number": e.TrackingNumber}
+	case order.OrderStatusChangedEvent:
+		return map[string]interface{}{"order_id": e.OrderID, "action": e.Action, "from": e.From, "to": e.To}
+	}
+	return nil
 }
 
 type LoggingHandler struct{}
 
-func (h *LoggingHandler) OnEvent(event patterns.Event) {
+func (h *LoggingHandler) Handle(ctx context.Context, event patterns.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fmt.Printf("📝 Logger: %s - %+v\n", event.Type, event.Data)
+	return nil
 }
 
 type AnalyticsHandler struct{}
 
-func (h *AnalyticsHandler) OnEvent(event patterns.Event) {
+func (h *AnalyticsHandler) Handle(ctx context.Context, event patterns.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fmt.Printf("📊 Analytics: %s - %+v\n", event.Type, event.Data)
+	return nil
+}
+
+// HandleBatch records a whole batch in one go, the way an analytics sink takes bulk inserts
+func (h *AnalyticsHandler) HandleBatch(ctx context.Context, events []patterns.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, event := range events {
+		counts[event.Type]++
+	}
+	fmt.Printf("📊 Analytics: batch of %d events %v\n", len(events), counts)
+	return nil
+}
+
+// FraudCheckHandler assesses new orders and publishes the result as a follow-up event.
+// Publishing with the handler's ctx links the new event to the one that caused it.
+type FraudCheckHandler struct {
+	Publisher *patterns.EventPublisher
+	Threshold float64
+}
+
+func (h *FraudCheckHandler) Handle(ctx context.Context, event patterns.Event) error {
+	created, ok := event.Data.(order.OrderCreatedEvent)
+	if !ok {
+		return nil
+	}
+	return h.Publisher.Publish(ctx, patterns.Event{
+		Type: "OrderRiskAssessed",
+		Data: map[string]interface{}{
+			"order_id": created.OrderID,
+			"flagged":  created.Total > h.Threshold,
+		},
+	})
 }
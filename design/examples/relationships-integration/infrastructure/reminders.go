@@ -0,0 +1,44 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/shared/broker"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+// Payment reminders bridge the synchronous event system to the broker:
+// an OrderCreated event schedules a delayed reminder, which a consumer
+// picks up later unless the order was paid in the meantime.
+
+const PaymentReminderTopic = "payment.reminder"
+
+type PaymentReminderScheduler struct {
+	Broker *broker.Broker
+	Delay  time.Duration
+}
+
+func (h *PaymentReminderScheduler) Handle(ctx context.Context, event patterns.Event) error {
+	created, ok := event.Data.(order.OrderCreatedEvent)
+	if !ok {
+		return nil
+	}
+	_, err := h.Broker.Publish(PaymentReminderTopic, created,
+		broker.WithKey(created.OrderID),
+		broker.WithDelay(h.Delay),
+	)
+	return err
+}
+
+// PaymentReminderConsumer sends a reminder for each due message
+func PaymentReminderConsumer(ctx context.Context, m *broker.Message) error {
+	created, ok := m.Payload.(order.OrderCreatedEvent)
+	if !ok {
+		return fmt.Errorf("unexpected payload %T", m.Payload)
+	}
+	fmt.Printf("⏰ Reminder: order %s (%.2f) awaiting payment, attempt %d\n", created.OrderID, created.Total, m.Attempt)
+	return nil
+}
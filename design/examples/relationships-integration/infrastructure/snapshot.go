@@ -0,0 +1,289 @@
+package infrastructure
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/graphql"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+	"github.com/jmoiron/sqlx"
+)
+
+// Snapshots export the whole demo state (orders, recorded events and the order
+// status projection) as a deterministic, content-addressed archive and import it
+// into a fresh instance. The archive is a gzipped tar holding:
+//
+//	manifest.json          format version and one entry per part
+//	objects/<sha256>       each part's JSON, named by the hash of its content
+//
+// Every part is serialized in a stable order and tar headers carry no timestamps
+// or owners, so the same state always produces byte-identical archives. The
+// snapshot ID is the SHA-256 of manifest.json.
+
+const SnapshotFormatVersion = 1
+
+var (
+	ErrSnapshotNotEmpty    = errors.New("snapshot import requires an empty instance")
+	ErrSnapshotCorrupt     = errors.New("snapshot archive is corrupt")
+	ErrSnapshotUnsupported = errors.New("snapshot format is not supported")
+)
+
+type SnapshotManifest struct {
+	FormatVersion int            `json:"format_version"`
+	Parts         []SnapshotPart `json:"parts"`
+}
+
+type SnapshotPart struct {
+	Name  string `json:"name"`
+	Hash  string `json:"hash"`
+	Size  int    `json:"size"`
+	Count int    `json:"count"`
+}
+
+// snapshotOrder mirrors a row of the orders table
+type snapshotOrder struct {
+	ID          string          `db:"id" json:"id"`
+	CustomerID  string          `db:"customer_id" json:"customer_id"`
+	Items       json.RawMessage `db:"items" json:"items"`
+	TotalAmount float64         `db:"total_amount" json:"total_amount"`
+	Currency    string          `db:"currency" json:"currency"`
+	Status      string          `db:"status" json:"status"`
+	CreatedAt   string          `db:"created_at" json:"created_at"`
+	UpdatedAt   string          `db:"updated_at" json:"updated_at"`
+}
+
+type snapshotState struct {
+	Orders      []snapshotOrder
+	Events      []patterns.RecordedEvent
+	Projections []graphql.StatusChange
+}
+
+// snapshotMigrations upgrade state exported by older format versions, keyed by
+// the version they upgrade from. Add an entry whenever SnapshotFormatVersion changes.
+var snapshotMigrations = map[int]func(*snapshotState) error{}
+
+type Snapshotter struct {
+	db     *sqlx.DB
+	events *patterns.MemoryEventStore
+	feed   *graphql.StatusFeed
+}
+
+func NewSnapshotter(db *sqlx.DB, events *patterns.MemoryEventStore, feed *graphql.StatusFeed) *Snapshotter {
+	return &Snapshotter{db: db, events: events, feed: feed}
+}
+
+// Export writes the archive to w and returns the snapshot ID
+func (s *Snapshotter) Export(w io.Writer) (string, error) {
+	var state snapshotState
+	if err := s.db.Select(&state.Orders, `SELECT * FROM orders ORDER BY id`); err != nil {
+		return "", err
+	}
+	events, err := s.events.Export()
+	if err != nil {
+		return "", err
+	}
+	state.Events = events
+	state.Projections = s.feed.Snapshot()
+
+	manifest := SnapshotManifest{FormatVersion: SnapshotFormatVersion}
+	objects := make(map[string][]byte)
+	for _, part := range []struct {
+		name  string
+		value interface{}
+		count int
+	}{
+		{"orders", state.Orders, len(state.Orders)},
+		{"events", state.Events, len(state.Events)},
+		{"projections", state.Projections, len(state.Projections)},
+	} {
+		content, err := json.MarshalIndent(part.value, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", part.name, err)
+		}
+		hash := sha256Hex(content)
+		objects[hash] = content
+		manifest.Parts = append(manifest.Parts, SnapshotPart{Name: part.name, Hash: hash, Size: len(content), Count: part.count})
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	gz := gzip.NewWriter(w) // zero header: no name or mtime, so output is stable
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, "manifest.json", manifestJSON); err != nil {
+		return "", err
+	}
+	for _, part := range manifest.Parts {
+		if err := writeTarFile(tw, "objects/"+part.Hash, objects[part.Hash]); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return sha256Hex(manifestJSON), nil
+}
+
+// Import verifies every object against its hash, then loads the state.
+// Nothing is written unless the whole archive is valid.
+func (s *Snapshotter) Import(r io.Reader) (string, *SnapshotManifest, error) {
+	if err := s.requireEmpty(); err != nil {
+		return "", nil, err
+	}
+
+	files, err := readTarFiles(r)
+	if err != nil {
+		return "", nil, err
+	}
+	manifestJSON, ok := files["manifest.json"]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: missing manifest.json", ErrSnapshotCorrupt)
+	}
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if manifest.FormatVersion > SnapshotFormatVersion {
+		return "", nil, fmt.Errorf("%w: format %d is newer than %d", ErrSnapshotUnsupported, manifest.FormatVersion, SnapshotFormatVersion)
+	}
+
+	var state snapshotState
+	targets := map[string]interface{}{
+		"orders":      &state.Orders,
+		"events":      &state.Events,
+		"projections": &state.Projections,
+	}
+	for _, part := range manifest.Parts {
+		content, ok := files["objects/"+part.Hash]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: missing object for %s", ErrSnapshotCorrupt, part.Name)
+		}
+		if sha256Hex(content) != part.Hash {
+			return "", nil, fmt.Errorf("%w: %s does not match its hash", ErrSnapshotCorrupt, part.Name)
+		}
+		target, ok := targets[part.Name]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unknown part %s", ErrSnapshotCorrupt, part.Name)
+		}
+		if err := json.Unmarshal(content, target); err != nil {
+			return "", nil, fmt.Errorf("%w: %s: %v", ErrSnapshotCorrupt, part.Name, err)
+		}
+	}
+
+	for v := manifest.FormatVersion; v < SnapshotFormatVersion; v++ {
+		migrate, ok := snapshotMigrations[v]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: no migration from format %d", ErrSnapshotUnsupported, v)
+		}
+		if err := migrate(&state); err != nil {
+			return "", nil, fmt.Errorf("%w: migrating from format %d: %v", ErrSnapshotCorrupt, v, err)
+		}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, o := range state.Orders {
+		_, err := tx.NamedExec(`INSERT INTO orders (id, customer_id, items, total_amount, currency, status, created_at, updated_at)
+			VALUES (:id, :customer_id, :items, :total_amount, :currency, :status, :created_at, :updated_at)`, o)
+		if err != nil {
+			tx.Rollback()
+			return "", nil, fmt.Errorf("order %s: %w", o.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", nil, err
+	}
+	s.events.Import(state.Events)
+	s.feed.Restore(state.Projections)
+
+	return sha256Hex(manifestJSON), &manifest, nil
+}
+
+func (s *Snapshotter) requireEmpty() error {
+	var orders int
+	if err := s.db.Get(&orders, `SELECT COUNT(*) FROM orders`); err != nil {
+		return err
+	}
+	if orders > 0 || s.events.Len() > 0 || s.feed.Len() > 0 {
+		return ErrSnapshotNotEmpty
+	}
+	return nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeTarFile(tw *tar.Writer, name string, content []byte) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(content)),
+		ModTime:  time.Unix(0, 0),
+		Typeflag: tar.TypeReg,
+		Format:   tar.FormatUSTAR,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// An archive holds a manifest and one object per part, so anything much
+// bigger than that is not one of ours and is refused before it fills memory
+const (
+	maxSnapshotFile    = 64 << 20
+	maxSnapshotSize    = 256 << 20
+	maxSnapshotEntries = 16
+)
+
+func readTarFiles(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	var entries, total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > maxSnapshotFile {
+			return nil, fmt.Errorf("%w: unexpected entry %s", ErrSnapshotCorrupt, hdr.Name)
+		}
+		entries, total = entries+1, total+hdr.Size
+		if entries > maxSnapshotEntries || total > maxSnapshotSize {
+			return nil, fmt.Errorf("%w: more than %d entries or %d bytes", ErrSnapshotCorrupt, maxSnapshotEntries, maxSnapshotSize)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+		}
+		files[path.Clean(hdr.Name)] = buf.Bytes()
+	}
+}
@@ -0,0 +1,271 @@
+package infrastructure_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/domain/order"
+	"github.com/dong-tran/docs/integration-example/graphql"
+	"github.com/dong-tran/docs/integration-example/infrastructure"
+	"github.com/dong-tran/docs/integration-example/repository"
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+	"github.com/jmoiron/sqlx"
+)
+
+// instance is one server's state: its database, event store and projection
+type instance struct {
+	db     *sqlx.DB
+	events *patterns.MemoryEventStore
+	feed   *graphql.StatusFeed
+	*infrastructure.Snapshotter
+}
+
+func newInstance(t *testing.T) *instance {
+	t.Helper()
+	db, err := infrastructure.OpenDatabase(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	events := patterns.NewMemoryEventStore(0)
+	feed := graphql.NewStatusFeed(1)
+	return &instance{db, events, feed, infrastructure.NewSnapshotter(db, events, feed)}
+}
+
+// seeded returns an instance holding two paid orders, saved by the order
+// repository, with their events and projection
+func seeded(t *testing.T) (*instance, []string) {
+	t.Helper()
+	in := newInstance(t)
+	orders := repository.NewOrderRepository(in.db)
+	price, _ := order.NewMoney(5, "USD")
+	item, _ := order.NewOrderItem("p1", "Pen", 2, price)
+	at := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 2; i++ {
+		ord, err := order.NewOrder(order.NewCustomerID("customer-1"), []order.OrderItem{*item})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := orders.Save(ord); err != nil {
+			t.Fatal(err)
+		}
+		id := ord.ID().String()
+		ids = append(ids, id)
+
+		created := patterns.Event{ID: id + "-created", Type: "OrderCreated", CorrelationID: id, OccurredAt: at,
+			Data: order.OrderCreatedEvent{OrderID: id, CustomerID: "customer-1", Total: 10}}
+		paid := patterns.Event{ID: id + "-paid", Type: "OrderPaid", CorrelationID: id, CausationID: created.ID, OccurredAt: at.Add(time.Second),
+			Data: order.OrderPaidEvent{OrderID: id, PaymentMethod: "card", Amount: 10}}
+		for _, event := range []patterns.Event{created, paid} {
+			in.events.RecordEvent(event)
+			// 1001µs only survives the round trip through float milliseconds if rounded
+			in.events.RecordHandled(patterns.HandlerRecord{EventID: event.ID, Handler: "email", StartedAt: event.OccurredAt, Duration: 1001 * time.Microsecond})
+			in.feed.Handle(context.Background(), event)
+		}
+	}
+	return in, ids
+}
+
+func export(t *testing.T, in *instance) (string, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	id, err := in.Export(&buf)
+	if err != nil {
+		t.Fatalf("Export = %v", err)
+	}
+	return id, buf.Bytes()
+}
+
+// unpack returns the entries of an archive by name
+func unpack(t *testing.T, archive []byte) map[string][]byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name], _ = io.ReadAll(tr)
+	}
+}
+
+// pack builds an archive from named entries, manifest first
+func pack(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	write := func(name string, content []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(content)
+	}
+	write("manifest.json", files["manifest.json"])
+	for name, content := range files {
+		if name != "manifest.json" {
+			write(name, content)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// withPart replaces one part's content and rehashes it in the manifest, so
+// the archive stays consistent
+func withPart(t *testing.T, archive []byte, part string, content []byte) []byte {
+	t.Helper()
+	files := unpack(t, archive)
+	var manifest infrastructure.SnapshotManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range manifest.Parts {
+		if p.Name == part {
+			delete(files, "objects/"+p.Hash)
+			sum := sha256.Sum256(content)
+			manifest.Parts[i].Hash = hex.EncodeToString(sum[:])
+			files["objects/"+manifest.Parts[i].Hash] = content
+		}
+	}
+	files["manifest.json"], _ = json.Marshal(manifest)
+	return pack(t, files)
+}
+
+// requireEmpty fails unless the import left in untouched
+func requireEmpty(t *testing.T, in *instance) {
+	t.Helper()
+	var orders int
+	if err := in.db.Get(&orders, `SELECT COUNT(*) FROM orders`); err != nil {
+		t.Fatal(err)
+	}
+	if orders != 0 || in.events.Len() != 0 || in.feed.Len() != 0 {
+		t.Errorf("a failed import wrote %d orders, %d events and %d projections", orders, in.events.Len(), in.feed.Len())
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	source, ids := seeded(t)
+	id, archive := export(t, source)
+	if again, _ := export(t, source); again != id {
+		t.Errorf("exporting the same state twice gave %s and %s", id, again)
+	}
+
+	target := newInstance(t)
+	imported, manifest, err := target.Import(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Import = %v", err)
+	}
+	if imported != id {
+		t.Errorf("Import returned ID %s, want %s", imported, id)
+	}
+	counts := map[string]int{}
+	for _, p := range manifest.Parts {
+		counts[p.Name] = p.Count
+	}
+	if counts["orders"] != 2 || counts["events"] != 4 || counts["projections"] != 2 {
+		t.Errorf("manifest counts = %v, want 2 orders, 4 events and 2 projections", counts)
+	}
+	if status, ok := target.feed.Current(ids[0]); !ok || status.Status != order.OrderStatusPaid {
+		t.Errorf("imported projection of %s = %+v, %v, want PAID", ids[0], status, ok)
+	}
+	graph, err := target.events.Trace(ids[0])
+	if err != nil || len(graph.Edges) != 1 {
+		t.Errorf("imported trace of %s = %+v, %v, want the created -> paid edge", ids[0], graph, err)
+	}
+
+	reexported, again := export(t, target)
+	if reexported != id || !bytes.Equal(again, archive) {
+		t.Errorf("export -> import -> export gave ID %s, want %s and byte-identical archives", reexported, id)
+	}
+}
+
+func TestSnapshotImportRejects(t *testing.T) {
+	source, _ := seeded(t)
+	_, archive := export(t, source)
+
+	tamper := func(t *testing.T) []byte {
+		files := unpack(t, archive)
+		for name, content := range files {
+			if strings.HasPrefix(name, "objects/") {
+				files[name] = bytes.Replace(content, []byte("customer-1"), []byte("customer-2"), 1)
+				break
+			}
+		}
+		return pack(t, files)
+	}
+	newer := func(t *testing.T) []byte {
+		files := unpack(t, archive)
+		files["manifest.json"] = bytes.Replace(files["manifest.json"], []byte(`"format_version": 1`), []byte(`"format_version": 2`), 1)
+		return pack(t, files)
+	}
+	tooManyEntries := func(t *testing.T) []byte {
+		files := unpack(t, archive)
+		for i := 0; i < 20; i++ {
+			files["padding/"+string(rune('a'+i))] = nil
+		}
+		return pack(t, files)
+	}
+
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) []byte
+		want    error
+	}{
+		{"an object that does not match its hash", tamper, infrastructure.ErrSnapshotCorrupt},
+		{"a part that is not the JSON it claims", func(t *testing.T) []byte {
+			return withPart(t, archive, "events", []byte(`{"not":"a list"}`))
+		}, infrastructure.ErrSnapshotCorrupt},
+		{"an archive with too many entries", tooManyEntries, infrastructure.ErrSnapshotCorrupt},
+		{"something that is not gzip", func(t *testing.T) []byte { return []byte("not a snapshot") }, infrastructure.ErrSnapshotCorrupt},
+		{"a newer format version", newer, infrastructure.ErrSnapshotUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newInstance(t)
+			if _, _, err := target.Import(bytes.NewReader(tt.archive(t))); !errors.Is(err, tt.want) {
+				t.Fatalf("Import = %v, want %v", err, tt.want)
+			}
+			requireEmpty(t, target)
+		})
+	}
+
+	t.Run("orders that fail to insert are rolled back", func(t *testing.T) {
+		// The second order reuses the first one's ID
+		orders := `[{"id":"order-1","customer_id":"c","items":[],"total_amount":1,"currency":"USD","status":"PENDING","created_at":"","updated_at":""},
+			{"id":"order-1","customer_id":"c","items":[],"total_amount":1,"currency":"USD","status":"PENDING","created_at":"","updated_at":""}]`
+		target := newInstance(t)
+		_, _, err := target.Import(bytes.NewReader(withPart(t, archive, "orders", []byte(orders))))
+		if err == nil || errors.Is(err, infrastructure.ErrSnapshotCorrupt) {
+			t.Fatalf("Import = %v, want the insert error", err)
+		}
+		requireEmpty(t, target)
+	})
+
+	t.Run("an instance that already holds data", func(t *testing.T) {
+		if _, _, err := source.Import(bytes.NewReader(archive)); !errors.Is(err, infrastructure.ErrSnapshotNotEmpty) {
+			t.Errorf("Import = %v, want ErrSnapshotNotEmpty", err)
+		}
+	})
+}
@@ -1,7 +1,6 @@
 package repository
 
 import (
-"database/sql"
 "encoding/json"
 
 "github.com/dong-tran/docs/integration-example/domain/order"
@@ -28,14 +27,41 @@ type orderDB struct {
 	UpdatedAt   string  `db:"updated_at"`
 }
 
-func (r *OrderRepositoryImpl) Save(ord *order.Order) error {
-	itemsJSON, _ := json.Marshal(ord.Items())
-	
-	query := `
+const insertOrderQuery = `
 		INSERT INTO orders (id, customer_id, items, total_amount, currency, status, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := r.db.Exec(query,
+
+func (r *OrderRepositoryImpl) Save(ord *order.Order) error {
+	_, err := r.db.Exec(insertOrderQuery, insertOrderArgs(ord)...)
+	return err
+}
+
+// SaveAll inserts the orders in one transaction
+func (r *OrderRepositoryImpl) SaveAll(orders []*order.Order) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Preparex(insertOrderQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, ord := range orders {
+		if _, err := stmt.Exec(insertOrderArgs(ord)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertOrderArgs(ord *order.Order) []interface{} {
+	itemsJSON, _ := json.Marshal(ord.Items())
+	return []interface{}{
 ord.ID().String(),
 		ord.CustomerID().String(),
 		itemsJSON,
@@ -44,13 +70,12 @@ ord.ID().String(),
 		string(ord.Status()),
 		ord.CreatedAt(),
 		ord.UpdatedAt(),
-	)
-	return err
+	}
 }
 
 func (r *OrderRepositoryImpl) FindByID(id order.OrderID) (*order.Order, error) {
 	// Implementation details...
-	return nil, sql.ErrNoRows
+	return nil, order.ErrOrderNotFound
 }
 
 func (r *OrderRepositoryImpl) FindByCustomerID(customerID order.CustomerID) ([]*order.Order, error) {
@@ -0,0 +1,338 @@
+package broker
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// In-process message broker.
+// Unlike the EventPublisher (synchronous fan-out to handlers), the broker queues
+// messages per topic so consumers pull them at their own pace. Messages carry a
+// priority and an optional delivery time, and failed deliveries are redelivered
+// with exponential backoff until they are dead-lettered.
+
+var (
+	ErrClosed  = errors.New("broker is closed")
+	ErrSettled = errors.New("delivery already acked or nacked")
+)
+
+// Message is a unit of work queued on a topic
+type Message struct {
+	ID          string
+	Topic       string
+	Key         string
+	Payload     interface{}
+	Headers     map[string]string
+	Priority    int       // higher is delivered first
+	DeliverAt   time.Time // zero means immediately
+	PublishedAt time.Time
+	Attempt     int // 1 on first delivery
+
+	seq uint64
+}
+
+// PublishOption customizes a message before it is queued
+type PublishOption func(*Message)
+
+func WithPriority(priority int) PublishOption {
+	return func(m *Message) { m.Priority = priority }
+}
+
+// WithDelay schedules the message to become deliverable after d
+func WithDelay(d time.Duration) PublishOption {
+	return func(m *Message) { m.DeliverAt = time.Now().Add(d) }
+}
+
+// WithDeliverAt schedules the message for an absolute time
+func WithDeliverAt(t time.Time) PublishOption {
+	return func(m *Message) { m.DeliverAt = t }
+}
+
+func WithKey(key string) PublishOption {
+	return func(m *Message) { m.Key = key }
+}
+
+func WithHeader(name, value string) PublishOption {
+	return func(m *Message) {
+		if m.Headers == nil {
+			m.Headers = make(map[string]string)
+		}
+		m.Headers[name] = value
+	}
+}
+
+// WithID sets an explicit message ID, e.g. to make a republish recognizable as a duplicate
+func WithID(id string) PublishOption {
+	return func(m *Message) { m.ID = id }
+}
+
+// RetryPolicy controls redelivery of nacked messages
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// Backoff returns the delay before the delivery following the given attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+		if time.Duration(d) >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(d)
+}
+
+type topicQueue struct {
+	ready  readyQueue
+	signal chan struct{} // closed and replaced whenever a message becomes ready
+}
+
+type Broker struct {
+	mu          sync.Mutex
+	seq         uint64
+	topics      map[string]*topicQueue
+	delayed     delayQueue
+	deadLetters []*Message
+	retry       RetryPolicy
+	closed      bool
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(retry RetryPolicy) *Broker {
+	b := &Broker{
+		topics: make(map[string]*topicQueue),
+		retry:  retry,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.schedule()
+	return b
+}
+
+// Publish queues a message on topic. Delayed messages are held until DeliverAt.
+func (b *Broker) Publish(topic string, payload interface{}, opts ...PublishOption) (*Message, error) {
+	m := &Message{Topic: topic, Payload: payload, PublishedAt: time.Now()}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	b.enqueue(m)
+	return m, nil
+}
+
+// enqueue must be called with b.mu held
+func (b *Broker) enqueue(m *Message) {
+	b.seq++
+	m.seq = b.seq
+	if m.DeliverAt.After(time.Now()) {
+		heap.Push(&b.delayed, m)
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+	b.makeReady(m)
+}
+
+// makeReady must be called with b.mu held
+func (b *Broker) makeReady(m *Message) {
+	q := b.topic(m.Topic)
+	heap.Push(&q.ready, m)
+	close(q.signal)
+	q.signal = make(chan struct{})
+}
+
+func (b *Broker) topic(name string) *topicQueue {
+	q, ok := b.topics[name]
+	if !ok {
+		q = &topicQueue{signal: make(chan struct{})}
+		b.topics[name] = q
+	}
+	return q
+}
+
+// schedule moves delayed messages to their topic once they are due
+func (b *Broker) schedule() {
+	defer b.wg.Done()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		for b.delayed.Len() > 0 && !b.delayed[0].DeliverAt.After(now) {
+			b.makeReady(heap.Pop(&b.delayed).(*Message))
+		}
+		wait := time.Hour
+		if b.delayed.Len() > 0 {
+			wait = b.delayed[0].DeliverAt.Sub(now)
+		}
+		b.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-b.wake:
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// Delivery is a received message that must be acked or nacked exactly once
+type Delivery struct {
+	*Message
+	broker *Broker
+	once   sync.Once
+}
+
+// Receive blocks until a message is ready on topic, ctx is done, or the broker closes
+func (b *Broker) Receive(ctx context.Context, topic string) (*Delivery, error) {
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return nil, ErrClosed
+		}
+		q := b.topic(topic)
+		if q.ready.Len() > 0 {
+			m := heap.Pop(&q.ready).(*Message)
+			m.Attempt++
+			b.mu.Unlock()
+			return &Delivery{Message: m, broker: b}, nil
+		}
+		signal := q.signal
+		b.mu.Unlock()
+
+		select {
+		case <-signal:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-b.done:
+			return nil, ErrClosed
+		}
+	}
+}
+
+// Ack confirms the message was processed
+func (d *Delivery) Ack() error {
+	return d.settle(func() {})
+}
+
+// Nack reports a failed processing attempt. The message is redelivered after the
+// retry policy's backoff, or dead-lettered once MaxAttempts is reached.
+func (d *Delivery) Nack() error {
+	return d.settle(func() {
+		b := d.broker
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if d.Attempt >= b.retry.MaxAttempts || b.closed {
+			b.deadLetters = append(b.deadLetters, d.Message)
+			return
+		}
+		retry := *d.Message
+		retry.DeliverAt = time.Now().Add(b.retry.Backoff(d.Attempt))
+		b.enqueue(&retry)
+	})
+}
+
+func (d *Delivery) settle(fn func()) error {
+	err := ErrSettled
+	d.once.Do(func() {
+		fn()
+		err = nil
+	})
+	return err
+}
+
+// Handler processes one message; returning an error nacks it
+type Handler func(ctx context.Context, m *Message) error
+
+// Consume receives from topic until ctx is done, acking or nacking each message
+func (b *Broker) Consume(ctx context.Context, topic string, handler Handler) error {
+	for {
+		d, err := b.Receive(ctx, topic)
+		if err != nil {
+			return err
+		}
+		if err := handler(ctx, d.Message); err != nil {
+			d.Nack()
+			continue
+		}
+		d.Ack()
+	}
+}
+
+// Pending counts ready and scheduled messages for topic
+func (b *Broker) Pending(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := 0
+	if q, ok := b.topics[topic]; ok {
+		n = q.ready.Len()
+	}
+	for _, m := range b.delayed {
+		if m.Topic == topic {
+			n++
+		}
+	}
+	return n
+}
+
+// DeadLetters returns messages that exhausted their retries
+func (b *Broker) DeadLetters() []*Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*Message(nil), b.deadLetters...)
+}
+
+// Close stops delivery. Blocked receivers return ErrClosed.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.done)
+	b.mu.Unlock()
+	b.wg.Wait()
+}
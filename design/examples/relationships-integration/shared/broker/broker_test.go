@@ -0,0 +1,294 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/broker"
+)
+
+// fastRetry redelivers within milliseconds so tests stay quick
+var fastRetry = broker.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     40 * time.Millisecond,
+	Multiplier:     2,
+}
+
+func newBroker(t *testing.T, retry broker.RetryPolicy) *broker.Broker {
+	t.Helper()
+	b := broker.New(retry)
+	t.Cleanup(b.Close)
+	return b
+}
+
+func publish(t *testing.T, b *broker.Broker, topic string, payload interface{}, opts ...broker.PublishOption) *broker.Message {
+	t.Helper()
+	m, err := b.Publish(topic, payload, opts...)
+	if err != nil {
+		t.Fatalf("Publish(%v) = %v", payload, err)
+	}
+	return m
+}
+
+// receive waits up to a second for the next delivery on topic
+func receive(t *testing.T, b *broker.Broker, topic string) *broker.Delivery {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	d, err := b.Receive(ctx, topic)
+	if err != nil {
+		t.Fatalf("Receive(%s) = %v", topic, err)
+	}
+	return d
+}
+
+// nothingReady fails if a message is deliverable on topic within d
+func nothingReady(t *testing.T, b *broker.Broker, topic string, d time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	if got, err := b.Receive(ctx, topic); err == nil {
+		t.Fatalf("Receive(%s) = %v, want nothing ready yet", topic, got.Payload)
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	for _, m := range []struct {
+		payload  string
+		priority int
+	}{
+		{"low-1", 0}, {"high-1", 5}, {"low-2", 0}, {"urgent", 9}, {"high-2", 5}, {"negative", -1},
+	} {
+		publish(t, b, "jobs", m.payload, broker.WithPriority(m.priority))
+	}
+	if got := b.Pending("jobs"); got != 6 {
+		t.Errorf("Pending = %d, want 6", got)
+	}
+
+	want := []string{"urgent", "high-1", "high-2", "low-1", "low-2", "negative"}
+	for _, w := range want {
+		d := receive(t, b, "jobs")
+		if d.Payload != w {
+			t.Errorf("received %v, want %s", d.Payload, w)
+		}
+		if err := d.Ack(); err != nil {
+			t.Errorf("Ack = %v", err)
+		}
+	}
+	if got := b.Pending("jobs"); got != 0 {
+		t.Errorf("Pending after receiving all = %d, want 0", got)
+	}
+}
+
+func TestTopicsAreSeparate(t *testing.T) {
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	publish(t, b, "a", "for a")
+	publish(t, b, "b", "for b")
+
+	if d := receive(t, b, "b"); d.Payload != "for b" || d.Topic != "b" {
+		t.Errorf("received %v on %s, want for b on b", d.Payload, d.Topic)
+	}
+	if got := b.Pending("a"); got != 1 {
+		t.Errorf("Pending(a) = %d, want 1", got)
+	}
+}
+
+func TestMessageOptions(t *testing.T) {
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	m := publish(t, b, "jobs", "payload", broker.WithID("job-1"), broker.WithKey("order-7"),
+		broker.WithHeader("tenant", "acme"), broker.WithHeader("trace", "t-1"))
+
+	d := receive(t, b, "jobs")
+	if d.Message != m {
+		t.Errorf("received a different message than published")
+	}
+	if d.ID != "job-1" || d.Key != "order-7" || d.Headers["tenant"] != "acme" || d.Headers["trace"] != "t-1" {
+		t.Errorf("message = %+v, want its ID, key and headers kept", d.Message)
+	}
+	if d.Attempt != 1 {
+		t.Errorf("Attempt = %d, want 1", d.Attempt)
+	}
+	if d.PublishedAt.IsZero() {
+		t.Errorf("PublishedAt not set")
+	}
+	if other := publish(t, b, "jobs", "payload"); other.ID == "" {
+		t.Errorf("message without WithID got no ID")
+	}
+}
+
+func TestDelayedDelivery(t *testing.T) {
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	start := time.Now()
+	publish(t, b, "jobs", "later", broker.WithDelay(80*time.Millisecond), broker.WithPriority(9))
+	publish(t, b, "jobs", "at", broker.WithDeliverAt(start.Add(40*time.Millisecond)))
+	publish(t, b, "jobs", "past", broker.WithDeliverAt(start.Add(-time.Hour)))
+	publish(t, b, "jobs", "now")
+	if got := b.Pending("jobs"); got != 4 {
+		t.Errorf("Pending = %d, want 4 counting the scheduled messages", got)
+	}
+
+	// A delayed message is not delivered early, whatever its priority
+	for _, want := range []string{"past", "now"} {
+		if d := receive(t, b, "jobs"); d.Payload != want {
+			t.Errorf("received %v, want %s", d.Payload, want)
+		}
+	}
+	nothingReady(t, b, "jobs", 20*time.Millisecond)
+
+	for _, w := range []struct {
+		payload string
+		after   time.Duration
+	}{
+		{"at", 40 * time.Millisecond},
+		{"later", 80 * time.Millisecond},
+	} {
+		d := receive(t, b, "jobs")
+		if d.Payload != w.payload {
+			t.Errorf("received %v, want %s", d.Payload, w.payload)
+		}
+		if took := time.Since(start); took < w.after {
+			t.Errorf("%s delivered after %v, want at least %v", w.payload, took, w.after)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := broker.RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for _, tt := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	} {
+		if got := policy.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestNackRedeliversWithBackoff(t *testing.T) {
+	b := newBroker(t, fastRetry)
+	publish(t, b, "jobs", "flaky")
+
+	last := time.Now()
+	for attempt := 1; attempt <= 2; attempt++ {
+		d := receive(t, b, "jobs")
+		if d.Attempt != attempt {
+			t.Fatalf("Attempt = %d, want %d", d.Attempt, attempt)
+		}
+		if attempt > 1 {
+			if waited := time.Since(last); waited < fastRetry.Backoff(attempt-1) {
+				t.Errorf("attempt %d came after %v, want at least %v", attempt, waited, fastRetry.Backoff(attempt-1))
+			}
+		}
+		if err := d.Nack(); err != nil {
+			t.Fatalf("Nack = %v", err)
+		}
+		last = time.Now()
+		if got := b.Pending("jobs"); got != 1 {
+			t.Errorf("Pending after nack = %d, want 1", got)
+		}
+	}
+
+	d := receive(t, b, "jobs")
+	if d.Attempt != 3 {
+		t.Fatalf("Attempt = %d, want 3", d.Attempt)
+	}
+	if err := d.Ack(); err != nil {
+		t.Fatalf("Ack = %v", err)
+	}
+	if got := b.DeadLetters(); len(got) != 0 {
+		t.Errorf("DeadLetters = %d messages, want none after the ack", len(got))
+	}
+}
+
+func TestDeadLettering(t *testing.T) {
+	b := newBroker(t, fastRetry)
+	m := publish(t, b, "jobs", "poison")
+
+	// The backoffs add up to 30ms; after that nothing is left to consume
+	var attempts []int
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := b.Consume(ctx, "jobs", func(ctx context.Context, m *broker.Message) error {
+		attempts = append(attempts, m.Attempt)
+		return errors.New("cannot process")
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Consume = %v, want context.DeadlineExceeded", err)
+	}
+
+	if len(attempts) != fastRetry.MaxAttempts || attempts[len(attempts)-1] != fastRetry.MaxAttempts {
+		t.Errorf("attempts = %v, want 1..%d", attempts, fastRetry.MaxAttempts)
+	}
+	dead := b.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != m.ID || dead[0].Attempt != fastRetry.MaxAttempts {
+		t.Fatalf("DeadLetters = %+v, want poison after %d attempts", dead, fastRetry.MaxAttempts)
+	}
+	if got := b.Pending("jobs"); got != 0 {
+		t.Errorf("Pending = %d, want 0", got)
+	}
+}
+
+func TestSettleOnce(t *testing.T) {
+	b := newBroker(t, fastRetry)
+	publish(t, b, "jobs", "once")
+
+	d := receive(t, b, "jobs")
+	if err := d.Ack(); err != nil {
+		t.Fatalf("Ack = %v", err)
+	}
+	if err := d.Ack(); !errors.Is(err, broker.ErrSettled) {
+		t.Errorf("second Ack = %v, want ErrSettled", err)
+	}
+	if err := d.Nack(); !errors.Is(err, broker.ErrSettled) {
+		t.Errorf("Nack after Ack = %v, want ErrSettled", err)
+	}
+	nothingReady(t, b, "jobs", 3*fastRetry.InitialBackoff)
+}
+
+func TestClose(t *testing.T) {
+	b := broker.New(broker.DefaultRetryPolicy)
+
+	received := make(chan error)
+	go func() {
+		_, err := b.Receive(context.Background(), "jobs")
+		received <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	b.Close()
+
+	if err := <-received; !errors.Is(err, broker.ErrClosed) {
+		t.Errorf("blocked Receive = %v, want ErrClosed", err)
+	}
+	if _, err := b.Publish("jobs", "late"); !errors.Is(err, broker.ErrClosed) {
+		t.Errorf("Publish after Close = %v, want ErrClosed", err)
+	}
+	if _, err := b.Receive(context.Background(), "jobs"); !errors.Is(err, broker.ErrClosed) {
+		t.Errorf("Receive after Close = %v, want ErrClosed", err)
+	}
+	b.Close()
+}
+
+func TestNackAfterCloseDeadLetters(t *testing.T) {
+	b := newBroker(t, fastRetry)
+	publish(t, b, "jobs", "in flight")
+	d := receive(t, b, "jobs")
+	b.Close()
+
+	if err := d.Nack(); err != nil {
+		t.Fatalf("Nack = %v", err)
+	}
+	if dead := b.DeadLetters(); len(dead) != 1 || dead[0].Attempt != 1 {
+		t.Errorf("DeadLetters = %+v, want the in-flight message", dead)
+	}
+}
@@ -0,0 +1,257 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Consumer groups - competing consumers sharing one topic.
+// A group pulls from the topic and routes every message to a partition chosen by
+// hashing its Key. Each partition is processed by one goroutine, so messages with
+// the same key are handled one at a time, in publish order, no matter which member
+// currently owns the partition. Members joining or leaving trigger a rebalance that
+// reassigns partitions round-robin; the partition goroutine picks up the new owner
+// before its next message, so ownership moves without reordering.
+//
+// A nacked message goes back to the broker for a delayed retry and can therefore
+// be overtaken by later messages with the same key.
+
+var (
+	ErrGroupClosed  = errors.New("consumer group is closed")
+	ErrMemberExists = errors.New("member already joined the group")
+	ErrNoSuchMember = errors.New("member is not in the group")
+)
+
+type ConsumerGroup struct {
+	broker *Broker
+	topic  string
+	name   string
+
+	mu         sync.Mutex
+	ownerReady *sync.Cond
+	members    map[string]*GroupMember
+	owners     []*GroupMember // indexed by partition
+	generation int
+	closed     bool
+
+	partitions []chan *Delivery
+	ctx        context.Context
+	cancel     context.CancelFunc
+	dispatch   sync.WaitGroup
+	workers    sync.WaitGroup
+}
+
+// GroupMember is one consumer instance in a group
+type GroupMember struct {
+	name      string
+	group     *ConsumerGroup
+	handler   Handler
+	processed atomic.Int64
+}
+
+// MemberStats describes a member's share of the work
+type MemberStats struct {
+	Name       string
+	Partitions []int
+	Processed  int64
+}
+
+// NewConsumerGroup starts consuming topic into the given number of partitions.
+// Messages wait in their partition until at least one member has joined.
+func (b *Broker) NewConsumerGroup(topic, name string, partitions int) *ConsumerGroup {
+	if partitions < 1 {
+		partitions = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &ConsumerGroup{
+		broker:     b,
+		topic:      topic,
+		name:       name,
+		members:    make(map[string]*GroupMember),
+		owners:     make([]*GroupMember, partitions),
+		partitions: make([]chan *Delivery, partitions),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	g.ownerReady = sync.NewCond(&g.mu)
+
+	for i := range g.partitions {
+		g.partitions[i] = make(chan *Delivery, 64)
+		g.workers.Add(1)
+		go g.work(i)
+	}
+	g.dispatch.Add(1)
+	go g.run()
+	return g
+}
+
+func (g *ConsumerGroup) Name() string {
+	return g.name
+}
+
+// Partition returns the partition a key is routed to
+func (g *ConsumerGroup) Partition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(g.partitions)))
+}
+
+func (g *ConsumerGroup) run() {
+	defer g.dispatch.Done()
+	for {
+		d, err := g.broker.Receive(g.ctx, g.topic)
+		if err != nil {
+			return
+		}
+		key := d.Key
+		if key == "" {
+			key = d.ID
+		}
+		select {
+		case g.partitions[g.Partition(key)] <- d:
+		case <-g.ctx.Done():
+			d.Nack()
+			return
+		}
+	}
+}
+
+func (g *ConsumerGroup) work(partition int) {
+	defer g.workers.Done()
+	for d := range g.partitions[partition] {
+		member := g.owner(partition)
+		if member == nil {
+			d.Nack()
+			continue
+		}
+		// handlers get a fresh context so messages drained during Close still complete
+		if err := member.handler(context.Background(), d.Message); err != nil {
+			d.Nack()
+		} else {
+			d.Ack()
+		}
+		member.processed.Add(1)
+	}
+}
+
+// owner blocks until the partition is assigned; nil means the group closed
+func (g *ConsumerGroup) owner(partition int) *GroupMember {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.owners[partition] == nil && !g.closed {
+		g.ownerReady.Wait()
+	}
+	return g.owners[partition]
+}
+
+// Join adds a member and rebalances partitions across all members
+func (g *ConsumerGroup) Join(name string, handler Handler) (*GroupMember, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil, ErrGroupClosed
+	}
+	if _, ok := g.members[name]; ok {
+		return nil, ErrMemberExists
+	}
+	m := &GroupMember{name: name, group: g, handler: handler}
+	g.members[name] = m
+	g.rebalance()
+	return m, nil
+}
+
+func (m *GroupMember) Name() string {
+	return m.name
+}
+
+// Leave removes the member. Its partitions move to the remaining members;
+// a message it is currently handling finishes first.
+func (m *GroupMember) Leave() error {
+	g := m.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.members[m.name] != m {
+		return ErrNoSuchMember
+	}
+	delete(g.members, m.name)
+	g.rebalance()
+	return nil
+}
+
+// rebalance must be called with g.mu held
+func (g *ConsumerGroup) rebalance() {
+	names := make([]string, 0, len(g.members))
+	for name := range g.members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for p := range g.owners {
+		if len(names) == 0 {
+			g.owners[p] = nil
+			continue
+		}
+		g.owners[p] = g.members[names[p%len(names)]]
+	}
+	g.generation++
+	g.ownerReady.Broadcast()
+}
+
+// Generation counts rebalances since the group was created
+func (g *ConsumerGroup) Generation() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.generation
+}
+
+// Stats reports partition ownership and processed counts, sorted by member name.
+// Members that already left are not included.
+func (g *ConsumerGroup) Stats() []MemberStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := make([]MemberStats, 0, len(g.members))
+	index := make(map[*GroupMember]int)
+	for _, m := range g.members {
+		index[m] = len(stats)
+		stats = append(stats, MemberStats{Name: m.name, Processed: m.processed.Load()})
+	}
+	for p, owner := range g.owners {
+		if owner != nil {
+			i := index[owner]
+			stats[i].Partitions = append(stats[i].Partitions, p)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Processed reports how many messages the member has handled
+func (m *GroupMember) Processed() int64 {
+	return m.processed.Load()
+}
+
+// Close stops pulling from the topic, lets members finish the messages already
+// routed to partitions, and returns any that have no owner to the broker.
+func (g *ConsumerGroup) Close() {
+	g.cancel()
+	g.dispatch.Wait()
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return
+	}
+	g.closed = true
+	g.ownerReady.Broadcast()
+	g.mu.Unlock()
+
+	for _, ch := range g.partitions {
+		close(ch)
+	}
+	g.workers.Wait()
+}
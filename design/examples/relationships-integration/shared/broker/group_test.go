@@ -0,0 +1,226 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/broker"
+)
+
+type orderEvent struct {
+	Customer string
+	Seq      int
+}
+
+// orderingCheck records the last sequence seen per key across all members
+type orderingCheck struct {
+	mu         sync.Mutex
+	last       map[string]int
+	violations []string
+	done       int
+}
+
+func newOrderingCheck() *orderingCheck {
+	return &orderingCheck{last: make(map[string]int)}
+}
+
+func (c *orderingCheck) handler(ctx context.Context, m *broker.Message) error {
+	e := m.Payload.(orderEvent)
+	time.Sleep(100 * time.Microsecond) // simulated work
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.Seq != c.last[e.Customer]+1 {
+		c.violations = append(c.violations, fmt.Sprintf("%s: %d after %d", e.Customer, e.Seq, c.last[e.Customer]))
+	}
+	c.last[e.Customer] = e.Seq
+	c.done++
+	return nil
+}
+
+// waitFor blocks until n messages were handled, failing after five seconds
+func (c *orderingCheck) waitFor(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		done := c.done
+		c.mu.Unlock()
+		if done >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("handled %d messages, want %d", done, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func partitions(g *broker.ConsumerGroup) map[string][]int {
+	out := make(map[string][]int)
+	for _, s := range g.Stats() {
+		out[s.Name] = s.Partitions
+	}
+	return out
+}
+
+func TestConsumerGroupKeepsKeyOrderAcrossRebalances(t *testing.T) {
+	const customers, perCustomer = 12, 50
+	total := customers * perCustomer
+
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	group := b.NewConsumerGroup("order.events", "order-processors", 6)
+	check := newOrderingCheck()
+
+	workerA, err := group.Join("worker-a", check.handler)
+	if err != nil {
+		t.Fatalf("Join(worker-a) = %v", err)
+	}
+	workerB, err := group.Join("worker-b", check.handler)
+	if err != nil {
+		t.Fatalf("Join(worker-b) = %v", err)
+	}
+
+	go func() {
+		for seq := 1; seq <= perCustomer; seq++ {
+			for c := 1; c <= customers; c++ {
+				customer := fmt.Sprintf("customer-%02d", c)
+				b.Publish("order.events", orderEvent{Customer: customer, Seq: seq}, broker.WithKey(customer))
+			}
+		}
+	}()
+
+	check.waitFor(t, total/3)
+	workerC, err := group.Join("worker-c", check.handler)
+	if err != nil {
+		t.Fatalf("Join(worker-c) = %v", err)
+	}
+	check.waitFor(t, 2*total/3)
+	if err := workerA.Leave(); err != nil {
+		t.Fatalf("Leave(worker-a) = %v", err)
+	}
+	check.waitFor(t, total)
+	group.Close()
+
+	if len(check.violations) > 0 {
+		t.Errorf("per-key ordering violated: %v", check.violations)
+	}
+	if check.done != total {
+		t.Errorf("handled %d messages, want %d", check.done, total)
+	}
+	var processed int64
+	for _, m := range []*broker.GroupMember{workerA, workerB, workerC} {
+		if m.Processed() == 0 {
+			t.Errorf("%s processed nothing", m.Name())
+		}
+		processed += m.Processed()
+	}
+	if processed != int64(total) {
+		t.Errorf("members processed %d messages, want %d", processed, total)
+	}
+	if got := group.Generation(); got != 4 {
+		t.Errorf("Generation = %d, want 4 after three joins and a leave", got)
+	}
+}
+
+func TestConsumerGroupRebalance(t *testing.T) {
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	group := b.NewConsumerGroup("order.events", "order-processors", 6)
+	defer group.Close()
+	handler := func(ctx context.Context, m *broker.Message) error { return nil }
+
+	workers := make(map[string]*broker.GroupMember)
+	join := func(name string) {
+		t.Helper()
+		m, err := group.Join(name, handler)
+		if err != nil {
+			t.Fatalf("Join(%s) = %v", name, err)
+		}
+		workers[name] = m
+	}
+
+	// Partitions go round-robin over the members sorted by name
+	steps := []struct {
+		name   string
+		change func()
+		want   map[string][]int
+	}{
+		{"one member", func() { join("worker-b") }, map[string][]int{"worker-b": {0, 1, 2, 3, 4, 5}}},
+		{"two members", func() { join("worker-a") }, map[string][]int{"worker-a": {0, 2, 4}, "worker-b": {1, 3, 5}}},
+		{"three members", func() { join("worker-c") }, map[string][]int{"worker-a": {0, 3}, "worker-b": {1, 4}, "worker-c": {2, 5}}},
+		{"worker-a left", func() { workers["worker-a"].Leave() }, map[string][]int{"worker-b": {0, 2, 4}, "worker-c": {1, 3, 5}}},
+	}
+	for i, step := range steps {
+		step.change()
+		if got := partitions(group); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: partitions = %v, want %v", step.name, got, step.want)
+		}
+		if got := group.Generation(); got != i+1 {
+			t.Errorf("%s: Generation = %d, want %d", step.name, got, i+1)
+		}
+	}
+
+	if _, err := group.Join("worker-b", handler); !errors.Is(err, broker.ErrMemberExists) {
+		t.Errorf("joining twice = %v, want ErrMemberExists", err)
+	}
+	if err := workers["worker-a"].Leave(); !errors.Is(err, broker.ErrNoSuchMember) {
+		t.Errorf("leaving twice = %v, want ErrNoSuchMember", err)
+	}
+	if got := group.Generation(); got != len(steps) {
+		t.Errorf("Generation after refused changes = %d, want %d", got, len(steps))
+	}
+
+	group.Close()
+	if _, err := group.Join("worker-d", handler); !errors.Is(err, broker.ErrGroupClosed) {
+		t.Errorf("Join after Close = %v, want ErrGroupClosed", err)
+	}
+}
+
+func TestConsumerGroupPartition(t *testing.T) {
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	group := b.NewConsumerGroup("order.events", "order-processors", 6)
+	defer group.Close()
+
+	used := make(map[int]bool)
+	for c := 0; c < 100; c++ {
+		key := fmt.Sprintf("customer-%02d", c)
+		p := group.Partition(key)
+		if p < 0 || p >= 6 {
+			t.Fatalf("Partition(%s) = %d, want 0..5", key, p)
+		}
+		if again := group.Partition(key); again != p {
+			t.Errorf("Partition(%s) = %d then %d", key, p, again)
+		}
+		used[p] = true
+	}
+	if len(used) != 6 {
+		t.Errorf("100 keys used partitions %v, want all 6", used)
+	}
+}
+
+func TestConsumerGroupWaitsForAMember(t *testing.T) {
+	b := newBroker(t, broker.DefaultRetryPolicy)
+	group := b.NewConsumerGroup("order.events", "order-processors", 3)
+	check := newOrderingCheck()
+	for seq := 1; seq <= 10; seq++ {
+		b.Publish("order.events", orderEvent{Customer: "customer-01", Seq: seq}, broker.WithKey("customer-01"))
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if check.done != 0 {
+		t.Fatalf("handled %d messages without a member", check.done)
+	}
+	if _, err := group.Join("late", check.handler); err != nil {
+		t.Fatalf("Join = %v", err)
+	}
+	check.waitFor(t, 10)
+	group.Close()
+	if len(check.violations) > 0 {
+		t.Errorf("per-key ordering violated: %v", check.violations)
+	}
+}
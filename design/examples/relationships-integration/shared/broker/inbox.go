@@ -0,0 +1,103 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Inbox pattern - effectively-once processing on top of at-least-once delivery.
+// The broker redelivers any message that is not acked, so a consumer that crashes
+// (or loses its ack) after performing a side effect will perform it again.
+// The inbox records processed message IDs: a redelivered message is recognized
+// and acked without running the handler a second time.
+
+// ErrInFlight is returned when the same message is already being handled elsewhere.
+// The delivery is nacked and retried once the other attempt finishes.
+var ErrInFlight = errors.New("message is already being processed")
+
+type inboxState int
+
+const (
+	inboxProcessing inboxState = iota
+	inboxDone
+)
+
+// Inbox remembers the most recent processed message IDs. Capacity bounds memory;
+// a duplicate arriving after its ID was evicted is processed again, so capacity
+// should cover the longest redelivery window.
+type Inbox struct {
+	mu       sync.Mutex
+	capacity int
+	state    map[string]inboxState
+	order    []string // completed IDs, oldest first
+}
+
+func NewInbox(capacity int) *Inbox {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Inbox{capacity: capacity, state: make(map[string]inboxState)}
+}
+
+// Begin claims id for processing. It returns false if id was already processed.
+func (i *Inbox) Begin(id string) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	switch st, ok := i.state[id]; {
+	case !ok:
+		i.state[id] = inboxProcessing
+		return true, nil
+	case st == inboxDone:
+		return false, nil
+	default:
+		return false, ErrInFlight
+	}
+}
+
+// Complete marks a claimed id as processed
+func (i *Inbox) Complete(id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.state[id] = inboxDone
+	i.order = append(i.order, id)
+	if len(i.order) > i.capacity {
+		delete(i.state, i.order[0])
+		i.order = i.order[1:]
+	}
+}
+
+// Abort releases a claim after a failed attempt so the retry is processed
+func (i *Inbox) Abort(id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.state[id] == inboxProcessing {
+		delete(i.state, id)
+	}
+}
+
+// Processed reports whether id is recorded as done
+func (i *Inbox) Processed(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.state[id] == inboxDone
+}
+
+// Deduplicate wraps handler so each message ID is handled successfully at most once
+func Deduplicate(inbox *Inbox, handler Handler) Handler {
+	return func(ctx context.Context, m *Message) error {
+		first, err := inbox.Begin(m.ID)
+		if err != nil {
+			return err
+		}
+		if !first {
+			return nil
+		}
+		if err := handler(ctx, m); err != nil {
+			inbox.Abort(m.ID)
+			return err
+		}
+		inbox.Complete(m.ID)
+		return nil
+	}
+}
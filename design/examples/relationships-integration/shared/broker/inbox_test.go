@@ -0,0 +1,186 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/broker"
+)
+
+type payment struct {
+	OrderID string
+	Amount  float64
+}
+
+// ledger counts side effects per order
+type ledger struct {
+	mu      sync.Mutex
+	charges map[string]int
+}
+
+func newLedger() *ledger {
+	return &ledger{charges: make(map[string]int)}
+}
+
+func (l *ledger) charge(ctx context.Context, m *broker.Message) error {
+	p := m.Payload.(payment)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.charges[p.OrderID]++
+	return nil
+}
+
+func (l *ledger) summary() (total, duplicates int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, n := range l.charges {
+		total += n
+		duplicates += n - 1
+	}
+	return total, duplicates
+}
+
+// processPayments publishes n payments to topic and consumes them until each
+// is acked. The first ack of every payment lost(i) picks is dropped after the
+// handler ran, as if the consumer crashed in between, so it is redelivered.
+func processPayments(t *testing.T, topic string, n int, lost func(i int) bool, handler broker.Handler) (redeliveries int) {
+	t.Helper()
+	b := newBroker(t, broker.RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+	})
+	drop := make(map[string]bool)
+	for i := 1; i <= n; i++ {
+		id := fmt.Sprintf("msg-%03d", i)
+		publish(t, b, topic, payment{OrderID: fmt.Sprintf("order-%03d", i), Amount: float64(i)}, broker.WithID(id))
+		drop[id] = lost(i)
+	}
+
+	acked := make(map[string]bool)
+	for len(acked) < n {
+		d := receive(t, b, topic)
+		if d.Attempt > 1 {
+			redeliveries++
+		}
+		if err := handler(context.Background(), d.Message); err != nil {
+			d.Nack()
+			continue
+		}
+		if drop[d.ID] {
+			drop[d.ID] = false
+			d.Nack() // the side effect happened, but the broker never hears about it
+			continue
+		}
+		d.Ack()
+		acked[d.ID] = true
+	}
+	if dead := b.DeadLetters(); len(dead) > 0 {
+		t.Errorf("%d payments dead-lettered", len(dead))
+	}
+	return redeliveries
+}
+
+func TestInboxMakesRedeliveriesEffectivelyOnce(t *testing.T) {
+	const payments = 200
+	lost := func(i int) bool { return i%20 < 7 } // 70 lost acks
+
+	tests := []struct {
+		name       string
+		handler    func(*ledger) broker.Handler
+		charges    int
+		duplicates int
+	}{
+		{"at-least-once", func(l *ledger) broker.Handler { return l.charge }, 270, 70},
+		{"effectively-once", func(l *ledger) broker.Handler {
+			return broker.Deduplicate(broker.NewInbox(payments), l.charge)
+		}, 200, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLedger()
+			redeliveries := processPayments(t, "payments", payments, lost, tt.handler(l))
+			if redeliveries != 70 {
+				t.Errorf("redeliveries = %d, want 70", redeliveries)
+			}
+			charges, duplicates := l.summary()
+			if charges != tt.charges || duplicates != tt.duplicates {
+				t.Errorf("charges = %d with %d duplicates, want %d with %d", charges, duplicates, tt.charges, tt.duplicates)
+			}
+		})
+	}
+}
+
+func TestInbox(t *testing.T) {
+	inbox := broker.NewInbox(2)
+
+	if first, err := inbox.Begin("a"); !first || err != nil {
+		t.Fatalf("Begin(a) = %v, %v, want true", first, err)
+	}
+	if _, err := inbox.Begin("a"); !errors.Is(err, broker.ErrInFlight) {
+		t.Errorf("Begin(a) while in flight = %v, want ErrInFlight", err)
+	}
+	if inbox.Processed("a") {
+		t.Errorf("Processed(a) before Complete")
+	}
+	inbox.Complete("a")
+	if first, err := inbox.Begin("a"); first || err != nil {
+		t.Errorf("Begin(a) after Complete = %v, %v, want false", first, err)
+	}
+
+	// An aborted claim can be taken again
+	inbox.Begin("b")
+	inbox.Abort("b")
+	if first, err := inbox.Begin("b"); !first || err != nil {
+		t.Errorf("Begin(b) after Abort = %v, %v, want true", first, err)
+	}
+	inbox.Complete("b")
+	inbox.Abort("b")
+	if !inbox.Processed("b") {
+		t.Errorf("Abort undid a completed message")
+	}
+
+	// Past capacity the oldest ID is forgotten
+	inbox.Begin("c")
+	inbox.Complete("c")
+	for id, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if got := inbox.Processed(id); got != want {
+			t.Errorf("Processed(%s) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	inbox := broker.NewInbox(10)
+	calls := 0
+	failing := true
+	handler := broker.Deduplicate(inbox, func(ctx context.Context, m *broker.Message) error {
+		calls++
+		if failing {
+			return errors.New("declined")
+		}
+		return nil
+	})
+	m := &broker.Message{ID: "msg-1"}
+
+	if err := handler(context.Background(), m); err == nil {
+		t.Fatalf("failing handler acked")
+	}
+	if inbox.Processed(m.ID) {
+		t.Errorf("failed message recorded as processed")
+	}
+	failing = false
+	for i := 0; i < 3; i++ {
+		if err := handler(context.Background(), m); err != nil {
+			t.Fatalf("attempt %d = %v", i+2, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2: the failure and the first success", calls)
+	}
+}
@@ -0,0 +1,59 @@
+package broker
+
+import "container/heap"
+
+// readyQueue orders deliverable messages: higher Priority first,
+// then FIFO (by publish sequence) within the same priority.
+type readyQueue []*Message
+
+func (q readyQueue) Len() int { return len(q) }
+
+func (q readyQueue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q readyQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *readyQueue) Push(x interface{}) { *q = append(*q, x.(*Message)) }
+
+func (q *readyQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	m := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return m
+}
+
+// delayQueue orders scheduled messages by the time they become deliverable
+type delayQueue []*Message
+
+func (q delayQueue) Len() int { return len(q) }
+
+func (q delayQueue) Less(i, j int) bool {
+	if !q[i].DeliverAt.Equal(q[j].DeliverAt) {
+		return q[i].DeliverAt.Before(q[j].DeliverAt)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q delayQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *delayQueue) Push(x interface{}) { *q = append(*q, x.(*Message)) }
+
+func (q *delayQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	m := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return m
+}
+
+var (
+	_ heap.Interface = (*readyQueue)(nil)
+	_ heap.Interface = (*delayQueue)(nil)
+)
@@ -0,0 +1,283 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Localization - message catalogs per locale, picked by Accept-Language.
+// A lookup walks a fallback chain: the requested tag (vi-VN), its base
+// language (vi), then the bundle's default locale. A key missing from every
+// catalog in the chain renders as the key itself, so a gap shows up in the
+// output instead of as an empty string, and is reported to OnMissing.
+//
+// Messages use named placeholders: "order {order_id} was paid".
+
+// Catalog maps message keys to templates for one locale
+type Catalog map[string]string
+
+type Bundle struct {
+	defaultLocale string
+
+	mu       sync.RWMutex
+	catalogs map[string]Catalog
+
+	// OnMissing, if set, is called when a key is not found in any catalog of the chain
+	OnMissing func(locale, key string)
+}
+
+func NewBundle(defaultLocale string) *Bundle {
+	return &Bundle{defaultLocale: Canonical(defaultLocale), catalogs: make(map[string]Catalog)}
+}
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+// Default returns a bundle with the catalogs shipped in locales/, English first
+func Default() (*Bundle, error) {
+	b := NewBundle("en")
+	if err := b.LoadFS(builtin, "locales"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Add merges c into the catalog for locale
+func (b *Bundle) Add(locale string, c Catalog) {
+	locale = Canonical(locale)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	existing, ok := b.catalogs[locale]
+	if !ok {
+		existing = make(Catalog, len(c))
+		b.catalogs[locale] = existing
+	}
+	for k, v := range c {
+		existing[k] = v
+	}
+}
+
+// LoadFS adds every <locale>.json in dir, each a flat object of key -> template
+func (b *Bundle) LoadFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var c Catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			return fmt.Errorf("i18n: %s: %w", file, err)
+		}
+		b.Add(strings.TrimSuffix(path.Base(file), ".json"), c)
+	}
+	return nil
+}
+
+func (b *Bundle) DefaultLocale() string {
+	return b.defaultLocale
+}
+
+// Locales lists the locales that have a catalog, sorted
+func (b *Bundle) Locales() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	locales := make([]string, 0, len(b.catalogs))
+	for l := range b.catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Catalog returns a copy of the catalog for locale
+func (b *Bundle) Catalog(locale string) Catalog {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	c := make(Catalog)
+	for k, v := range b.catalogs[Canonical(locale)] {
+		c[k] = v
+	}
+	return c
+}
+
+// Chain is the order in which catalogs are searched for locale
+func (b *Bundle) Chain(locale string) []string {
+	var chain []string
+	add := func(l string) {
+		for _, seen := range chain {
+			if seen == l {
+				return
+			}
+		}
+		chain = append(chain, l)
+	}
+	if locale = Canonical(locale); locale != "" {
+		add(locale)
+		if base, _, ok := strings.Cut(locale, "-"); ok {
+			add(base)
+		}
+	}
+	add(b.defaultLocale)
+	return chain
+}
+
+// Lookup finds the template for key and the locale it came from
+func (b *Bundle) Lookup(locale, key string) (string, string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, l := range b.Chain(locale) {
+		if msg, ok := b.catalogs[l][key]; ok {
+			return msg, l, true
+		}
+	}
+	return "", "", false
+}
+
+// Message renders key for locale with args filling its placeholders
+func (b *Bundle) Message(locale, key string, args map[string]interface{}) string {
+	msg, _, ok := b.Lookup(locale, key)
+	if !ok {
+		if b.OnMissing != nil {
+			b.OnMissing(Canonical(locale), key)
+		}
+		return key
+	}
+	return Format(msg, args)
+}
+
+// Format replaces {name} with args[name]. Placeholders without an argument are kept.
+func Format(msg string, args map[string]interface{}) string {
+	if len(args) == 0 || !strings.Contains(msg, "{") {
+		return msg
+	}
+	var sb strings.Builder
+	for {
+		open := strings.IndexByte(msg, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(msg[open:], '}')
+		if end < 0 {
+			break
+		}
+		name := msg[open+1 : open+end]
+		sb.WriteString(msg[:open])
+		if v, ok := args[name]; ok {
+			fmt.Fprint(&sb, v)
+		} else {
+			sb.WriteString(msg[open : open+end+1])
+		}
+		msg = msg[open+end+1:]
+	}
+	sb.WriteString(msg)
+	return sb.String()
+}
+
+// Placeholders returns the placeholder names used in msg, sorted
+func Placeholders(msg string) []string {
+	var names []string
+	for {
+		open := strings.IndexByte(msg, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(msg[open:], '}')
+		if end < 0 {
+			break
+		}
+		names = append(names, msg[open+1:open+end])
+		msg = msg[open+end+1:]
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Negotiate picks the best supported locale for an Accept-Language header,
+// e.g. "vi-VN,vi;q=0.9,en;q=0.5". A tag matches a catalog for itself or for
+// its base language; nothing acceptable yields the default locale.
+func (b *Bundle) Negotiate(acceptLanguage string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == "*" {
+			break
+		}
+		if _, ok := b.catalogs[tag]; ok {
+			return tag
+		}
+		if base, _, ok := strings.Cut(tag, "-"); ok {
+			if _, ok := b.catalogs[base]; ok {
+				return base
+			}
+		}
+	}
+	return b.defaultLocale
+}
+
+// parseAcceptLanguage returns the tags ordered by quality, dropping q=0
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, weighted{Canonical(tag), q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	out := make([]string, len(tags))
+	for i, t := range tags {
+		out[i] = t.tag
+	}
+	return out
+}
+
+// Canonical normalizes a language tag: "VI_vn" -> "vi-VN"
+func Canonical(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	lang, region, ok := strings.Cut(tag, "-")
+	if !ok {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
+
+type localeKey struct{}
+
+// WithLocale attaches the negotiated locale to ctx, so work done on behalf of
+// a request (event handlers, notifications) speaks the requester's language
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFrom returns the locale carried by ctx, or "" for the bundle default
+func LocaleFrom(ctx context.Context) string {
+	l, _ := ctx.Value(localeKey{}).(string)
+	return l
+}
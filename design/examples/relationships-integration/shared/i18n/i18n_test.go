@@ -0,0 +1,178 @@
+package i18n_test
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dong-tran/docs/integration-example/shared/i18n"
+)
+
+func defaultBundle(t *testing.T) *i18n.Bundle {
+	t.Helper()
+	bundle, err := i18n.Default()
+	if err != nil {
+		t.Fatalf("Default() = %v", err)
+	}
+	return bundle
+}
+
+// TestBuiltinCatalogs checks every shipped catalog against the default one.
+// Keys a locale has not translated yet are allowed: they fall back.
+func TestBuiltinCatalogs(t *testing.T) {
+	bundle := defaultBundle(t)
+	if got, want := bundle.Locales(), []string{"en", "vi"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Locales() = %v, want %v", got, want)
+	}
+	base := bundle.Catalog(bundle.DefaultLocale())
+	if len(base) == 0 {
+		t.Fatalf("default catalog %s is empty", bundle.DefaultLocale())
+	}
+
+	for _, locale := range bundle.Locales() {
+		t.Run(locale, func(t *testing.T) {
+			catalog := bundle.Catalog(locale)
+			missing := 0
+			for key, msg := range base {
+				translated, ok := catalog[key]
+				if !ok {
+					missing++
+					continue
+				}
+				if got, want := i18n.Placeholders(translated), i18n.Placeholders(msg); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s uses %v, default uses %v", key, got, want)
+				}
+			}
+			for key := range catalog {
+				if _, ok := base[key]; !ok {
+					t.Errorf("%s is not in the default catalog", key)
+				}
+			}
+			if missing > 0 {
+				t.Logf("%d/%d keys fall back to %s", missing, len(base), bundle.DefaultLocale())
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	bundle := defaultBundle(t)
+	bundle.Add("vi-VN", i18n.Catalog{"greeting": "Xin chào, {name}!"})
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"vi", "vi"},
+		{"VI_vn", "vi-VN"},
+		{"vi-VN,vi;q=0.9,en;q=0.8", "vi-VN"},
+		{"vi-CH", "vi"},
+		{"fr-CA, fr;q=0.9, vi;q=0.5", "vi"},
+		{"en;q=0.5, vi", "vi"},
+		{"de, fr", "en"},
+		{"en;q=0.1, vi;q=0", "en"},
+		{"vi;q=0", "en"},
+		{"vi;q=abc, en", "en"},
+		{"*, vi", "en"},
+	}
+	for _, tt := range tests {
+		if got := bundle.Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	bundle := i18n.NewBundle("en")
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"", []string{"en"}},
+		{"en", []string{"en"}},
+		{"en-GB", []string{"en-GB", "en"}},
+		{"vi", []string{"vi", "en"}},
+		{"vi_vn", []string{"vi-VN", "vi", "en"}},
+	}
+	for _, tt := range tests {
+		if got := bundle.Chain(tt.locale); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Chain(%q) = %v, want %v", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestMissingKeys(t *testing.T) {
+	bundle := defaultBundle(t)
+	var reported []string
+	bundle.OnMissing = func(locale, key string) { reported = append(reported, locale+":"+key) }
+	bundle.Add("vi-VN", i18n.Catalog{"greeting": "Xin chào, {name}!"})
+	args := map[string]interface{}{"name": "Lan"}
+
+	tests := []struct {
+		locale, key, want string
+	}{
+		{"vi-VN", "greeting", "Xin chào, Lan!"},
+		{"vi", "greeting", "greeting"},
+		{"en", "greeting", "greeting"},
+		{"vi-VN", "error.ORDER_NOT_FOUND", "không tìm thấy đơn hàng"},
+		{"fr", "error.ORDER_NOT_FOUND", "order not found"},
+	}
+	for _, tt := range tests {
+		if got := bundle.Message(tt.locale, tt.key, args); got != tt.want {
+			t.Errorf("Message(%s, %s) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+	if want := []string{"vi:greeting", "en:greeting"}; !reflect.DeepEqual(reported, want) {
+		t.Errorf("OnMissing reported %v, want %v", reported, want)
+	}
+
+	if _, from, ok := bundle.Lookup("vi-VN", "error.ORDER_NOT_FOUND"); !ok || from != "vi" {
+		t.Errorf("Lookup(vi-VN) found it in %q, want vi", from)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		msg  string
+		args map[string]interface{}
+		want string
+	}{
+		{"order {order_id} was paid", map[string]interface{}{"order_id": 42}, "order 42 was paid"},
+		{"{a} and {b}", map[string]interface{}{"a": 1}, "1 and {b}"},
+		{"no placeholders", map[string]interface{}{"a": 1}, "no placeholders"},
+		{"{a}", nil, "{a}"},
+		{"unclosed {a", map[string]interface{}{"a": 1}, "unclosed {a"},
+	}
+	for _, tt := range tests {
+		if got := i18n.Format(tt.msg, tt.args); got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+	if got, want := i18n.Placeholders("{b} then {a}, {b}"), []string{"a", "b", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Placeholders = %v, want %v", got, want)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	bundle := i18n.NewBundle("en")
+	fsys := fstest.MapFS{
+		"locales/en.json":    {Data: []byte(`{"hello": "hello"}`)},
+		"locales/vi_VN.json": {Data: []byte(`{"hello": "xin chào"}`)},
+		"locales/notes.txt":  {Data: []byte(`ignored`)},
+	}
+	if err := bundle.LoadFS(fsys, "locales"); err != nil {
+		t.Fatalf("LoadFS = %v", err)
+	}
+	if got, want := bundle.Locales(), []string{"en", "vi-VN"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Locales() = %v, want %v", got, want)
+	}
+	if got := bundle.Message("vi-VN", "hello", nil); got != "xin chào" {
+		t.Errorf("Message(vi-VN, hello) = %q", got)
+	}
+
+	fsys["locales/fr.json"] = &fstest.MapFile{Data: []byte(`{"hello": `)}
+	if err := i18n.NewBundle("en").LoadFS(fsys, "locales"); err == nil {
+		t.Errorf("LoadFS accepted a malformed catalog")
+	}
+}
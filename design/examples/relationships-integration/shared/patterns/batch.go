@@ -0,0 +1,226 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Batch publishing - the events of one use-case execution go out together.
+// A use case stages events in an EventBatch while it works. Nothing is
+// delivered until Flush, which runs once the state change has committed;
+// Discard drops the events if it rolled back. The batch plays the outbox's
+// part in-process: handlers never see an event for a change that did not commit.
+//
+// PublishBatch also spreads the fixed cost of delivery. Publish takes a
+// subscription snapshot, a timeout context and a goroutine per handler per
+// event; PublishBatch takes them once per handler per batch, and handlers
+// implementing BatchEventHandler get the whole batch in a single call.
+
+// BatchEventHandler is implemented by handlers that can process several events
+// at once, e.g. with one write. An error fails the whole batch for that handler.
+type BatchEventHandler interface {
+	EventHandler
+	HandleBatch(ctx context.Context, events []Event) error
+}
+
+// ErrBatchAborted is reported for the events a handler never received because
+// it failed on an earlier event of the same batch
+var ErrBatchAborted = errors.New("not delivered: handler failed earlier in the batch")
+
+// ErrBatchClosed is returned when flushing a batch that was already flushed or discarded
+var ErrBatchClosed = errors.New("event batch already flushed or discarded")
+
+// PublishBatch delivers events to every handler in subscription order. Each
+// handler sees the events in the order given and stops at its first failure,
+// so it never handles an event whose predecessor it rejected. All events share
+// one correlation ID. A handler's timeout covers the batch, scaled by its length.
+func (p *EventPublisher) PublishBatch(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	stamped := make([]Event, len(events))
+	stamped[0] = stamp(ctx, events[0])
+	ctx = WithCorrelationID(ctx, stamped[0].CorrelationID)
+	for i := 1; i < len(events); i++ {
+		stamped[i] = stamp(ctx, events[i])
+	}
+
+	p.mu.RLock()
+	if p.history != nil {
+		p.history.append(stamped...)
+	}
+	subs := append([]subscription(nil), p.subscriptions...)
+	p.mu.RUnlock()
+
+	if p.recorder != nil {
+		for _, event := range stamped {
+			p.recorder.RecordEvent(event)
+		}
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		records := deliverBatch(ctx, sub, stamped)
+		if p.recorder != nil {
+			for _, record := range records {
+				p.recorder.RecordHandled(record)
+			}
+		}
+		if _, ok := sub.handler.(BatchEventHandler); ok {
+			if err := records[0].Err; err != nil {
+				errs = append(errs, &HandlerError{Handler: sub.name, Event: fmt.Sprintf("batch of %d", len(stamped)), Err: err})
+			}
+			continue
+		}
+		for i, record := range records {
+			if record.Err != nil {
+				errs = append(errs, &HandlerError{Handler: sub.name, Event: stamped[i].Type, Err: record.Err})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliverBatch runs one handler over the batch in a single goroutine and
+// returns a record per event. As with deliver, a handler still running at the
+// deadline is abandoned; events it had finished keep their outcome.
+func deliverBatch(ctx context.Context, sub subscription, events []Event) []HandlerRecord {
+	ctx, cancel := context.WithTimeout(ctx, sub.timeout*time.Duration(len(events)))
+	defer cancel()
+
+	records := make([]HandlerRecord, len(events))
+	for i, event := range events {
+		records[i] = HandlerRecord{EventID: event.ID, Handler: sub.name}
+	}
+	// records[:finished] are written by the goroutine before it advances finished
+	var finished atomic.Int32
+	done := make(chan struct{})
+
+	bh, isBatch := sub.handler.(BatchEventHandler)
+	go func() {
+		defer close(done)
+		i := 0
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("panic: %v", r)
+				if isBatch {
+					i = 0
+				}
+				for j := i; j < len(records); j++ {
+					records[j].Err = ErrBatchAborted
+					if isBatch || j == i {
+						records[j].Err = err
+					}
+				}
+				finished.Store(int32(len(records)))
+			}
+		}()
+
+		if isBatch {
+			started := time.Now()
+			err := bh.HandleBatch(ctx, events)
+			for i = range records {
+				records[i].StartedAt, records[i].Duration, records[i].Err = started, time.Since(started), err
+			}
+			finished.Store(int32(len(records)))
+			return
+		}
+
+		var failed error
+		for i = range events {
+			if failed != nil {
+				records[i].Err = ErrBatchAborted
+			} else {
+				records[i].StartedAt = time.Now()
+				failed = sub.handler.Handle(withCause(ctx, events[i]), events[i])
+				records[i].Duration = time.Since(records[i].StartedAt)
+				records[i].Err = failed
+			}
+			finished.Store(int32(i + 1))
+		}
+	}()
+
+	select {
+	case <-done:
+		return records
+	case <-ctx.Done():
+	}
+
+	n := int(finished.Load())
+	result := append([]HandlerRecord(nil), records[:n]...)
+	for i := n; i < len(events); i++ {
+		record := HandlerRecord{EventID: events[i].ID, Handler: sub.name, Err: ErrBatchAborted}
+		if i == n {
+			record.Err = ctx.Err()
+		}
+		result = append(result, record)
+	}
+	if isBatch {
+		for i := range result {
+			result[i].Err = ctx.Err()
+		}
+	}
+	return result
+}
+
+// EventBatch collects the events of one unit of work until it commits
+type EventBatch struct {
+	publisher *EventPublisher
+	ctx       context.Context
+
+	mu     sync.Mutex
+	events []Event
+	closed bool
+}
+
+// NewBatch starts a batch whose events will be published with ctx
+func (p *EventPublisher) NewBatch(ctx context.Context) *EventBatch {
+	return &EventBatch{publisher: p, ctx: ctx}
+}
+
+// Add stages events in order. Adding to a flushed or discarded batch is a bug
+// in the caller and panics, since the events could never be delivered.
+func (b *EventBatch) Add(events ...Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		panic("patterns: Add on a flushed or discarded EventBatch")
+	}
+	b.events = append(b.events, events...)
+}
+
+// Len returns the number of staged events
+func (b *EventBatch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.events)
+}
+
+// Flush publishes the staged events with PublishBatch. Call it after the
+// change that produced them has committed; a batch flushes at most once.
+func (b *EventBatch) Flush() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBatchClosed
+	}
+	b.closed = true
+	events := b.events
+	b.events = nil
+	b.mu.Unlock()
+
+	return b.publisher.PublishBatch(b.ctx, events)
+}
+
+// Discard drops the staged events. It is a no-op after Flush, so it can be
+// deferred right after NewBatch to cover every early return.
+func (b *EventBatch) Discard() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.events = nil
+}
@@ -0,0 +1,183 @@
+package patterns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+const handlers = 4
+
+// orderChecker fails if it sees sequence numbers out of order
+type orderChecker struct {
+	mu   sync.Mutex
+	last int
+	seen int
+}
+
+func (c *orderChecker) Handle(ctx context.Context, event patterns.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seq := event.Data.(int)
+	if seq != c.last+1 {
+		return fmt.Errorf("got event %d after %d", seq, c.last)
+	}
+	c.last = seq
+	c.seen++
+	return nil
+}
+
+func (c *orderChecker) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last, c.seen = 0, 0
+}
+
+// bulkSink takes batches in one call
+type bulkSink struct {
+	batches *int
+}
+
+func (bulkSink) Handle(ctx context.Context, event patterns.Event) error { return nil }
+
+func (s bulkSink) HandleBatch(ctx context.Context, events []patterns.Event) error {
+	if s.batches != nil {
+		*s.batches++
+	}
+	return nil
+}
+
+// newPublisher has the same shape as the server's: several plain handlers
+// and one BatchEventHandler
+func newPublisher(checker *orderChecker, sink bulkSink) *patterns.EventPublisher {
+	p := patterns.NewEventPublisher()
+	noop := patterns.EventHandlerFunc(func(ctx context.Context, event patterns.Event) error { return nil })
+	for i := 0; i < handlers-2; i++ {
+		p.SubscribeHandler(fmt.Sprintf("noop-%d", i), noop)
+	}
+	p.SubscribeHandler("bulk-sink", sink)
+	p.SubscribeHandler("order-checker", checker)
+	return p
+}
+
+func makeEvents(n int) []patterns.Event {
+	events := make([]patterns.Event, n)
+	for i := range events {
+		events[i] = patterns.Event{Type: "OrderCreated", Data: i + 1}
+	}
+	return events
+}
+
+func TestPublishAndBatchKeepOrder(t *testing.T) {
+	ctx := context.Background()
+	checker := &orderChecker{}
+	batches := 0
+	p := newPublisher(checker, bulkSink{batches: &batches})
+	events := makeEvents(50)
+
+	for _, event := range events {
+		if err := p.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish = %v", err)
+		}
+	}
+	if checker.seen != len(events) {
+		t.Errorf("Publish x %d: %d delivered, want all", len(events), checker.seen)
+	}
+
+	checker.reset()
+	batch := p.NewBatch(ctx)
+	batch.Add(events[:20]...)
+	batch.Add(events[20:]...)
+	if got := batch.Len(); got != len(events) {
+		t.Errorf("Len = %d, want %d", got, len(events))
+	}
+	if checker.seen != 0 {
+		t.Errorf("%d events delivered before Flush", checker.seen)
+	}
+	if err := batch.Flush(); err != nil {
+		t.Fatalf("Flush = %v", err)
+	}
+	if checker.seen != len(events) || batches != 1 {
+		t.Errorf("Flush: %d delivered in %d batches, want %d in 1", checker.seen, batches, len(events))
+	}
+	if err := batch.Flush(); !errors.Is(err, patterns.ErrBatchClosed) {
+		t.Errorf("second Flush = %v, want ErrBatchClosed", err)
+	}
+}
+
+func TestDiscardedBatch(t *testing.T) {
+	checker := &orderChecker{}
+	p := newPublisher(checker, bulkSink{})
+
+	rolledBack := p.NewBatch(context.Background())
+	rolledBack.Add(makeEvents(10)...)
+	rolledBack.Discard()
+	if checker.seen != 0 {
+		t.Errorf("discarded batch delivered %d events", checker.seen)
+	}
+	if err := rolledBack.Flush(); !errors.Is(err, patterns.ErrBatchClosed) {
+		t.Errorf("Flush after Discard = %v, want ErrBatchClosed", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Add after Discard did not panic")
+		}
+	}()
+	rolledBack.Add(makeEvents(1)...)
+}
+
+func TestBatchStopsAtTheFirstFailure(t *testing.T) {
+	checker := &orderChecker{}
+	p := newPublisher(checker, bulkSink{})
+	events := makeEvents(5)
+	events[1], events[2] = events[2], events[1] // 1, 3, 2, 4, 5
+
+	err := p.PublishBatch(context.Background(), events)
+	var herr *patterns.HandlerError
+	if !errors.As(err, &herr) || herr.Handler != "order-checker" {
+		t.Fatalf("PublishBatch = %v, want the order checker's failure", err)
+	}
+	if !errors.Is(err, patterns.ErrBatchAborted) {
+		t.Errorf("PublishBatch = %v, want the events after the failure aborted", err)
+	}
+	if checker.seen != 1 {
+		t.Errorf("order checker handled %d events, want 1", checker.seen)
+	}
+}
+
+// BenchmarkPublishBatch compares publishing the events of a bulk operation
+// one by one with publishing them as one batch. Handlers do no work of their
+// own, so ns/event is the publisher's overhead: Publish starts a goroutine
+// and a timeout per handler per event, PublishBatch one per handler per batch.
+func BenchmarkPublishBatch(b *testing.B) {
+	ctx := context.Background()
+	checker := &orderChecker{}
+	p := newPublisher(checker, bulkSink{})
+
+	for _, n := range []int{1, 10, 100, 1000} {
+		events := makeEvents(n)
+		b.Run(fmt.Sprintf("Publish/events=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				checker.reset()
+				for _, event := range events {
+					p.Publish(ctx, event)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/event")
+		})
+		b.Run(fmt.Sprintf("PublishBatch/events=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				checker.reset()
+				p.PublishBatch(ctx, events)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/event")
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package patterns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Dependency graph - introspection for hand-written dependency injection.
+// cmd/main.go is the composition root: it constructs every component and
+// passes each one its dependencies, with no container in between. Recording
+// each construction in a DependencyGraph as it happens keeps that wiring
+// plain Go while letting readers (and /debug/di-graph) see how the layers
+// compose once the process is running.
+
+type Lifecycle string
+
+const (
+	Singleton  Lifecycle = "singleton"   // built once at startup, lives as long as the process
+	PerRequest Lifecycle = "per-request" // middleware: runs again for every request
+	Background Lifecycle = "background"  // runs in its own goroutine until shutdown
+)
+
+// Dependency kinds
+const (
+	DependsOn  = "depends-on" // passed to the constructor
+	Subscribes = "subscribes" // registered with the component after construction
+)
+
+type Component struct {
+	Name      string    `json:"name"`
+	Layer     string    `json:"layer"`
+	Type      string    `json:"type"`
+	Lifecycle Lifecycle `json:"lifecycle"`
+}
+
+type Dependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+type DependencyGraph struct {
+	mu         sync.RWMutex
+	components []Component
+	deps       []Dependency
+	index      map[string]bool
+}
+
+func NewDependencyGraph() *DependencyGraph {
+	return &DependencyGraph{index: make(map[string]bool)}
+}
+
+// Provide records v as a component built from deps and returns it unchanged,
+// so the composition root reads like plain construction:
+//
+//	repo := patterns.Provide(g, "orderRepo", "repository", patterns.Singleton, repository.NewOrderRepository(db), "db")
+func Provide[T any](g *DependencyGraph, name, layer string, lifecycle Lifecycle, v T, deps ...string) T {
+	g.Add(Component{Name: name, Layer: layer, Type: fmt.Sprintf("%T", v), Lifecycle: lifecycle}, deps...)
+	return v
+}
+
+// Add records a component and what it depends on. Dependencies may name
+// components that are added later; Validate checks they all exist.
+func (g *DependencyGraph) Add(c Component, deps ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.index[c.Name] {
+		g.index[c.Name] = true
+		g.components = append(g.components, c)
+	}
+	for _, d := range deps {
+		g.deps = append(g.deps, Dependency{From: c.Name, To: d, Kind: DependsOn})
+	}
+}
+
+// Subscribe records that subscriber was registered with publisher after both
+// were built, e.g. an event handler with the event publisher
+func (g *DependencyGraph) Subscribe(publisher, subscriber string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.deps = append(g.deps, Dependency{From: publisher, To: subscriber, Kind: Subscribes})
+}
+
+// Validate reports dependencies on components that were never recorded,
+// which means the graph no longer describes the wiring
+func (g *DependencyGraph) Validate() error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var missing []string
+	for _, d := range g.deps {
+		for _, name := range []string{d.From, d.To} {
+			if !g.index[name] {
+				missing = append(missing, fmt.Sprintf("%s -> %s: unknown component %q", d.From, d.To, name))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("dependency graph: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// DependencyGraphSnapshot is the serialized form served as JSON
+type DependencyGraphSnapshot struct {
+	Components   []Component  `json:"nodes"`
+	Dependencies []Dependency `json:"edges"`
+}
+
+func (g *DependencyGraph) Snapshot() DependencyGraphSnapshot {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return DependencyGraphSnapshot{
+		Components:   append([]Component(nil), g.components...),
+		Dependencies: append([]Dependency(nil), g.deps...),
+	}
+}
+
+// DOT renders the graph for Graphviz, one cluster per layer. Constructor
+// dependencies are solid, subscriptions dashed.
+func (s DependencyGraphSnapshot) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph di {\n\trankdir=LR;\n\tnode [shape=box, fontname=\"Helvetica\"];\n")
+
+	layers := make(map[string][]Component)
+	var names []string
+	for _, c := range s.Components {
+		if _, ok := layers[c.Layer]; !ok {
+			names = append(names, c.Layer)
+		}
+		layers[c.Layer] = append(layers[c.Layer], c)
+	}
+	sort.Strings(names)
+	for i, layer := range names {
+		fmt.Fprintf(&b, "\tsubgraph cluster_%d {\n\t\tlabel=%q;\n", i, layer)
+		for _, c := range layers[layer] {
+			style := ""
+			switch c.Lifecycle {
+			case PerRequest:
+				style = ", style=rounded"
+			case Background:
+				style = ", style=bold"
+			}
+			fmt.Fprintf(&b, "\t\t%q [label=%q%s];\n", c.Name, fmt.Sprintf("%s\n%s\n(%s)", c.Name, c.Type, c.Lifecycle), style)
+		}
+		b.WriteString("\t}\n")
+	}
+	for _, d := range s.Dependencies {
+		style := ""
+		if d.Kind == Subscribes {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(&b, "\t%q -> %q%s;\n", d.From, d.To, style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
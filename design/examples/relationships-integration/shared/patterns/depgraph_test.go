@@ -0,0 +1,107 @@
+package patterns_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+type orderRepository struct{}
+
+// wiring records a small composition root: a repository, a use case built
+// from it, a publisher and a handler subscribed to the publisher
+func wiring() *patterns.DependencyGraph {
+	g := patterns.NewDependencyGraph()
+	g.Add(patterns.Component{Name: "db", Layer: "infrastructure", Type: "*sqlx.DB", Lifecycle: patterns.Singleton})
+	repo := patterns.Provide(g, "orderRepo", "repository", patterns.Singleton, &orderRepository{}, "db")
+	patterns.Provide(g, "orderUseCase", "usecase", patterns.Singleton, repo, "orderRepo", "eventPublisher")
+	g.Add(patterns.Component{Name: "eventPublisher", Layer: "shared", Type: "*patterns.EventPublisher", Lifecycle: patterns.Singleton})
+	g.Add(patterns.Component{Name: "emailHandler", Layer: "infrastructure", Type: "*infrastructure.EmailHandler", Lifecycle: patterns.Background})
+	g.Subscribe("eventPublisher", "emailHandler")
+	return g
+}
+
+func TestDependencyGraphValidate(t *testing.T) {
+	if err := wiring().Validate(); err != nil {
+		t.Errorf("Validate = %v, want dependencies added later to count", err)
+	}
+
+	g := wiring()
+	g.Add(patterns.Component{Name: "orderHandler", Layer: "handler"}, "orderUseCase", "paymentUseCase")
+	g.Subscribe("eventPublisher", "smsHandler")
+	err := g.Validate()
+	if err == nil {
+		t.Fatal("Validate = nil, want the unknown components reported")
+	}
+	for _, want := range []string{
+		`orderHandler -> paymentUseCase: unknown component "paymentUseCase"`,
+		`eventPublisher -> smsHandler: unknown component "smsHandler"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want it to report %s", err, want)
+		}
+	}
+}
+
+func TestDependencyGraphSnapshot(t *testing.T) {
+	g := wiring()
+	// Adding a name twice keeps the first component; the dependencies are still recorded
+	g.Add(patterns.Component{Name: "orderRepo", Layer: "handler", Type: "other"}, "eventPublisher")
+	snap := g.Snapshot()
+
+	var names []string
+	for _, c := range snap.Components {
+		names = append(names, c.Name)
+	}
+	if want := []string{"db", "orderRepo", "orderUseCase", "eventPublisher", "emailHandler"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("components = %v, want %v in the order added", names, want)
+	}
+	if repo := snap.Components[1]; repo.Layer != "repository" || repo.Type != "*patterns_test.orderRepository" {
+		t.Errorf("orderRepo = %+v, want the first Add, typed by Provide", repo)
+	}
+	want := []patterns.Dependency{
+		{From: "orderRepo", To: "db", Kind: patterns.DependsOn},
+		{From: "orderUseCase", To: "orderRepo", Kind: patterns.DependsOn},
+		{From: "orderUseCase", To: "eventPublisher", Kind: patterns.DependsOn},
+		{From: "eventPublisher", To: "emailHandler", Kind: patterns.Subscribes},
+		{From: "orderRepo", To: "eventPublisher", Kind: patterns.DependsOn},
+	}
+	if !reflect.DeepEqual(snap.Dependencies, want) {
+		t.Errorf("dependencies = %v, want %v", snap.Dependencies, want)
+	}
+
+	// The snapshot is a copy
+	g.Add(patterns.Component{Name: "late"})
+	if len(snap.Components) != 5 {
+		t.Errorf("a later Add changed the snapshot to %d components", len(snap.Components))
+	}
+}
+
+func TestDependencyGraphDOT(t *testing.T) {
+	dot := wiring().Snapshot().DOT()
+
+	if !strings.HasPrefix(dot, "digraph di {") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("DOT is not a digraph:\n%s", dot)
+	}
+	// Layers are sorted, so cluster numbers are stable
+	for i, layer := range []string{"infrastructure", "repository", "shared", "usecase"} {
+		cluster := "subgraph cluster_" + string(rune('0'+i)) + " {\n\t\tlabel=\"" + layer + "\";"
+		if !strings.Contains(dot, cluster) {
+			t.Errorf("DOT has no cluster %d for %s:\n%s", i, layer, dot)
+		}
+	}
+	if n := strings.Count(dot, "subgraph cluster_"); n != 4 {
+		t.Errorf("DOT has %d clusters, want one per layer (4)", n)
+	}
+	for _, want := range []string{
+		"\t\"orderRepo\" -> \"db\";\n",
+		"\t\"eventPublisher\" -> \"emailHandler\" [style=dashed];\n",
+		"\"emailHandler\" [label=\"emailHandler\\n*infrastructure.EmailHandler\\n(background)\", style=bold];",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT does not contain %q:\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package patterns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryEventStore keeps published events and handler outcomes so a
+// correlation ID can be traced after the fact. It evicts the oldest events
+// once capacity is reached.
+
+var ErrTraceNotFound = errors.New("no events recorded for correlation ID")
+
+type storedEvent struct {
+	event    Event
+	handlers []HandlerRecord
+}
+
+type MemoryEventStore struct {
+	mu            sync.RWMutex
+	capacity      int
+	order         []string
+	events        map[string]*storedEvent
+	byCorrelation map[string][]string
+}
+
+func NewMemoryEventStore(capacity int) *MemoryEventStore {
+	return &MemoryEventStore{
+		capacity:      capacity,
+		events:        make(map[string]*storedEvent),
+		byCorrelation: make(map[string][]string),
+	}
+}
+
+func (s *MemoryEventStore) RecordEvent(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events[event.ID] = &storedEvent{event: event}
+	s.order = append(s.order, event.ID)
+	s.byCorrelation[event.CorrelationID] = append(s.byCorrelation[event.CorrelationID], event.ID)
+
+	if s.capacity > 0 && len(s.order) > s.capacity {
+		s.evict(s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *MemoryEventStore) evict(id string) {
+	stored, ok := s.events[id]
+	if !ok {
+		return
+	}
+	delete(s.events, id)
+	corr := stored.event.CorrelationID
+	ids := s.byCorrelation[corr]
+	for i, other := range ids {
+		if other == id {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(s.byCorrelation, corr)
+	} else {
+		s.byCorrelation[corr] = ids
+	}
+}
+
+func (s *MemoryEventStore) RecordHandled(record HandlerRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.events[record.EventID]; ok {
+		stored.handlers = append(stored.handlers, record)
+	}
+}
+
+// TraceGraph is the causal graph of one correlation ID, shaped for graph rendering
+type TraceGraph struct {
+	CorrelationID string      `json:"correlation_id"`
+	Nodes         []TraceNode `json:"nodes"`
+	Edges         []TraceEdge `json:"edges"`
+	Roots         []string    `json:"roots"`
+}
+
+type TraceNode struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Data       interface{}    `json:"data"`
+	Handlers   []TraceHandler `json:"handlers"`
+}
+
+type TraceHandler struct {
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// TraceEdge points from a cause to the event its handler published
+type TraceEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Trace reconstructs the causal graph for correlationID in publish order.
+// Events whose cause is unknown (or was evicted) are reported as roots.
+func (s *MemoryEventStore) Trace(correlationID string) (*TraceGraph, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, ok := s.byCorrelation[correlationID]
+	if !ok {
+		return nil, ErrTraceNotFound
+	}
+
+	graph := &TraceGraph{
+		CorrelationID: correlationID,
+		Nodes:         make([]TraceNode, 0, len(ids)),
+		Edges:         make([]TraceEdge, 0),
+		Roots:         make([]string, 0),
+	}
+	for _, id := range ids {
+		stored := s.events[id]
+		node := TraceNode{
+			ID:         id,
+			Type:       stored.event.Type,
+			OccurredAt: stored.event.OccurredAt,
+			Data:       stored.event.Data,
+			Handlers:   make([]TraceHandler, 0, len(stored.handlers)),
+		}
+		for _, h := range stored.handlers {
+			th := TraceHandler{
+				Name:       h.Handler,
+				StartedAt:  h.StartedAt,
+				DurationMs: float64(h.Duration.Microseconds()) / 1000,
+			}
+			if h.Err != nil {
+				th.Error = h.Err.Error()
+			}
+			node.Handlers = append(node.Handlers, th)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		cause := stored.event.CausationID
+		if _, known := s.events[cause]; cause != "" && known {
+			graph.Edges = append(graph.Edges, TraceEdge{From: cause, To: id})
+		} else {
+			graph.Roots = append(graph.Roots, id)
+		}
+	}
+	return graph, nil
+}
+
+// RecordedEvent is the portable form of a stored event, used for snapshots.
+// Data is kept as JSON, so imported events carry json.RawMessage payloads.
+type RecordedEvent struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Data          json.RawMessage `json:"data"`
+	CorrelationID string          `json:"correlation_id"`
+	CausationID   string          `json:"causation_id,omitempty"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Handlers      []TraceHandler  `json:"handlers"`
+}
+
+// Export returns every stored event in publish order
+func (s *MemoryEventStore) Export() ([]RecordedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]RecordedEvent, 0, len(s.order))
+	for _, id := range s.order {
+		stored := s.events[id]
+		data, err := json.Marshal(stored.event.Data)
+		if err != nil {
+			return nil, fmt.Errorf("event %s: %w", id, err)
+		}
+		rec := RecordedEvent{
+			ID:            id,
+			Type:          stored.event.Type,
+			Data:          data,
+			CorrelationID: stored.event.CorrelationID,
+			CausationID:   stored.event.CausationID,
+			OccurredAt:    stored.event.OccurredAt,
+			Handlers:      make([]TraceHandler, 0, len(stored.handlers)),
+		}
+		for _, h := range stored.handlers {
+			th := TraceHandler{Name: h.Handler, StartedAt: h.StartedAt, DurationMs: float64(h.Duration.Microseconds()) / 1000}
+			if h.Err != nil {
+				th.Error = h.Err.Error()
+			}
+			rec.Handlers = append(rec.Handlers, th)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// Len reports how many events are stored
+func (s *MemoryEventStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.order)
+}
+
+// Import appends exported events, preserving their IDs and causal links
+func (s *MemoryEventStore) Import(events []RecordedEvent) {
+	for _, rec := range events {
+		s.RecordEvent(Event{
+			ID:            rec.ID,
+			Type:          rec.Type,
+			Data:          rec.Data,
+			CorrelationID: rec.CorrelationID,
+			CausationID:   rec.CausationID,
+			OccurredAt:    rec.OccurredAt,
+		})
+		for _, h := range rec.Handlers {
+			record := HandlerRecord{
+				EventID:   rec.ID,
+				Handler:   h.Name,
+				StartedAt: h.StartedAt,
+				// Rounded, or 1.001ms comes back as 1.000999ms and exports differently
+				Duration: time.Duration(math.Round(h.DurationMs * float64(time.Millisecond))),
+			}
+			if h.Error != "" {
+				record.Err = errors.New(h.Error)
+			}
+			s.RecordHandled(record)
+		}
+	}
+}
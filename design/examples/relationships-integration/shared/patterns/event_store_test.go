@@ -0,0 +1,146 @@
+package patterns_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+var errDeclined = errors.New("card declined")
+
+// fraudCheck publishes OrderRiskAssessed from inside its OrderCreated handler,
+// the way FraudCheckHandler does
+func fraudCheck(publisher *patterns.EventPublisher) patterns.EventHandlerFunc {
+	return func(ctx context.Context, event patterns.Event) error {
+		if event.Type != "OrderCreated" {
+			return nil
+		}
+		return publisher.Publish(ctx, patterns.Event{Type: "OrderRiskAssessed", Data: "low"})
+	}
+}
+
+func TestTraceLinksHandlerPublishedEventsToTheirCause(t *testing.T) {
+	store := patterns.NewMemoryEventStore(0)
+	publisher := patterns.NewEventPublisher(patterns.WithRecorder(store))
+	publisher.SubscribeHandler("fraud", fraudCheck(publisher))
+	publisher.SubscribeHandler("payments", patterns.EventHandlerFunc(func(ctx context.Context, event patterns.Event) error {
+		if event.Type == "OrderRiskAssessed" {
+			return errDeclined
+		}
+		return nil
+	}))
+
+	ctx := patterns.WithCorrelationID(context.Background(), "req-1")
+	publisher.Publish(ctx, patterns.Event{Type: "OrderCreated", Data: "order-1"})
+
+	graph, err := store.Trace("req-1")
+	if err != nil {
+		t.Fatalf("Trace = %v", err)
+	}
+	if len(graph.Nodes) != 2 {
+		t.Fatalf("Trace has %d nodes, want 2: %+v", len(graph.Nodes), graph.Nodes)
+	}
+	created, assessed := graph.Nodes[0], graph.Nodes[1]
+	if created.Type != "OrderCreated" || assessed.Type != "OrderRiskAssessed" {
+		t.Fatalf("nodes are %s, %s, want OrderCreated, OrderRiskAssessed in publish order", created.Type, assessed.Type)
+	}
+	if want := []patterns.TraceEdge{{From: created.ID, To: assessed.ID}}; !reflect.DeepEqual(graph.Edges, want) {
+		t.Errorf("Edges = %v, want %v", graph.Edges, want)
+	}
+	if want := []string{created.ID}; !reflect.DeepEqual(graph.Roots, want) {
+		t.Errorf("Roots = %v, want %v", graph.Roots, want)
+	}
+
+	var handled []string
+	for _, h := range assessed.Handlers {
+		handled = append(handled, h.Name+":"+h.Error)
+	}
+	if want := []string{"fraud:", "payments:" + errDeclined.Error()}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("OrderRiskAssessed handlers = %v, want %v", handled, want)
+	}
+}
+
+func TestPublishWithoutCorrelationStartsItsOwnTrace(t *testing.T) {
+	store := patterns.NewMemoryEventStore(0)
+	publisher := patterns.NewEventPublisher(patterns.WithRecorder(store))
+	publisher.SubscribeHandler("fraud", fraudCheck(publisher))
+
+	publisher.Publish(context.Background(), patterns.Event{Type: "OrderCreated"})
+
+	events, err := store.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("stored %d events, want 2", len(events))
+	}
+	root, child := events[0], events[1]
+	if root.CorrelationID != root.ID || root.CausationID != "" {
+		t.Errorf("root correlation %q, causation %q, want its own ID %q and none", root.CorrelationID, root.CausationID, root.ID)
+	}
+	if child.CorrelationID != root.ID || child.CausationID != root.ID {
+		t.Errorf("child correlation %q, causation %q, want both %q", child.CorrelationID, child.CausationID, root.ID)
+	}
+}
+
+func TestTraceAfterEviction(t *testing.T) {
+	record := func(store *patterns.MemoryEventStore, id, correlation, cause string) {
+		store.RecordEvent(patterns.Event{ID: id, Type: "OrderEvent", CorrelationID: correlation, CausationID: cause})
+	}
+
+	t.Run("an event whose cause was evicted is a root", func(t *testing.T) {
+		store := patterns.NewMemoryEventStore(2)
+		record(store, "e1", "req-1", "")
+		record(store, "e2", "req-1", "e1")
+		record(store, "e3", "req-2", "")
+
+		graph, err := store.Trace("req-1")
+		if err != nil {
+			t.Fatalf("Trace = %v", err)
+		}
+		if len(graph.Nodes) != 1 || graph.Nodes[0].ID != "e2" {
+			t.Fatalf("Nodes = %+v, want only e2", graph.Nodes)
+		}
+		if len(graph.Edges) != 0 || !reflect.DeepEqual(graph.Roots, []string{"e2"}) {
+			t.Errorf("Edges = %v, Roots = %v, want no edges and root e2", graph.Edges, graph.Roots)
+		}
+	})
+
+	t.Run("evicting the last event of a correlation forgets it", func(t *testing.T) {
+		store := patterns.NewMemoryEventStore(1)
+		record(store, "e1", "req-1", "")
+		record(store, "e2", "req-2", "")
+
+		if _, err := store.Trace("req-1"); !errors.Is(err, patterns.ErrTraceNotFound) {
+			t.Errorf("Trace(req-1) = %v, want ErrTraceNotFound", err)
+		}
+		if store.Len() != 1 {
+			t.Errorf("Len = %d, want 1", store.Len())
+		}
+	})
+
+	t.Run("outcomes of evicted events are ignored", func(t *testing.T) {
+		store := patterns.NewMemoryEventStore(1)
+		record(store, "e1", "req-1", "")
+		record(store, "e2", "req-1", "")
+		store.RecordHandled(patterns.HandlerRecord{EventID: "e1", Handler: "late"})
+
+		graph, err := store.Trace("req-1")
+		if err != nil {
+			t.Fatalf("Trace = %v", err)
+		}
+		if len(graph.Nodes) != 1 || len(graph.Nodes[0].Handlers) != 0 {
+			t.Errorf("Nodes = %+v, want e2 alone with no handlers", graph.Nodes)
+		}
+	})
+}
+
+func TestTraceOfAnUnknownCorrelation(t *testing.T) {
+	store := patterns.NewMemoryEventStore(0)
+	if _, err := store.Trace("nobody"); !errors.Is(err, patterns.ErrTraceNotFound) {
+		t.Errorf("Trace = %v, want ErrTraceNotFound", err)
+	}
+}
@@ -1,29 +1,194 @@
 package patterns
 
-// Observer Pattern - notifies multiple subscribers of events
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Observer Pattern - notifies multiple subscribers of events.
+// Publish stamps ID and OccurredAt, and links the event to its cause:
+// CorrelationID groups everything triggered by one request, and CausationID
+// names the event whose handler published this one.
 type Event struct {
-	Type string
-	Data interface{}
+	ID            string
+	Type          string
+	Data          interface{}
+	CorrelationID string
+	CausationID   string
+	OccurredAt    time.Time
+}
+
+// EventHandler receives events with a context and acknowledges them:
+// returning nil acks the event, returning an error reports a failed delivery.
+// Handlers must honor ctx cancellation; each one runs under its own timeout.
+type EventHandler interface {
+	Handle(ctx context.Context, event Event) error
+}
+
+// EventHandlerFunc lets plain functions act as handlers
+type EventHandlerFunc func(ctx context.Context, event Event) error
+
+func (f EventHandlerFunc) Handle(ctx context.Context, event Event) error {
+	return f(ctx, event)
 }
 
+// EventObserver is the original fire-and-forget observer interface.
+// It is still accepted by Subscribe through ObserverAdapter.
 type EventObserver interface {
 	OnEvent(event Event)
 }
 
+// ObserverAdapter is the compatibility shim that lets an EventObserver act as an EventHandler.
+// Legacy observers cannot fail, so the adapter acks unless the context is already done.
+type ObserverAdapter struct {
+	Observer EventObserver
+}
+
+func (a ObserverAdapter) Handle(ctx context.Context, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.Observer.OnEvent(event)
+	return nil
+}
+
+// HandlerError reports which handler failed to ack which event
+type HandlerError struct {
+	Handler string
+	Event   string
+	Err     error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("handler %s failed on %s: %v", e.Handler, e.Event, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+const DefaultHandlerTimeout = 5 * time.Second
+
+type subscription struct {
+	name    string
+	handler EventHandler
+	timeout time.Duration
+}
+
+// HandlerOption customizes a single subscription
+type HandlerOption func(*subscription)
+
+// WithTimeout bounds how long the publisher waits for the handler to ack
+func WithTimeout(d time.Duration) HandlerOption {
+	return func(s *subscription) {
+		s.timeout = d
+	}
+}
+
 type EventPublisher struct {
-	observers []EventObserver
+	mu            sync.RWMutex
+	subscriptions []subscription
+	recorder      EventRecorder
+	history       *eventHistory // set by NewReplayBus
 }
 
-func NewEventPublisher() *EventPublisher {
-	return &EventPublisher{observers: make([]EventObserver, 0)}
+// PublisherOption customizes an EventPublisher
+type PublisherOption func(*EventPublisher)
+
+// WithRecorder sends every published event and handler outcome to r
+func WithRecorder(r EventRecorder) PublisherOption {
+	return func(p *EventPublisher) {
+		p.recorder = r
+	}
 }
 
+func NewEventPublisher(opts ...PublisherOption) *EventPublisher {
+	p := &EventPublisher{subscriptions: make([]subscription, 0)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Subscribe registers a legacy observer through the compatibility shim
 func (p *EventPublisher) Subscribe(observer EventObserver) {
-	p.observers = append(p.observers, observer)
+	p.SubscribeHandler(fmt.Sprintf("%T", observer), ObserverAdapter{Observer: observer})
+}
+
+// SubscribeHandler registers a context-aware handler under a name used in error reports
+func (p *EventPublisher) SubscribeHandler(name string, handler EventHandler, opts ...HandlerOption) {
+	sub := subscription{name: name, handler: handler, timeout: DefaultHandlerTimeout}
+	for _, opt := range opts {
+		opt(&sub)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscriptions = append(p.subscriptions, sub)
 }
 
-func (p *EventPublisher) Publish(event Event) {
-	for _, observer := range p.observers {
-		observer.OnEvent(event)
+// Publish delivers the event to every handler in subscription order.
+// A failing or slow handler does not stop delivery to the others; all
+// failures are returned together as *HandlerError values.
+func (p *EventPublisher) Publish(ctx context.Context, event Event) error {
+	event = stamp(ctx, event)
+
+	p.mu.RLock()
+	if p.history != nil {
+		p.history.append(event)
+	}
+	subs := append([]subscription(nil), p.subscriptions...)
+	p.mu.RUnlock()
+
+	if p.recorder != nil {
+		p.recorder.RecordEvent(event)
+	}
+	// Events published by handlers are caused by this one
+	ctx = withCause(ctx, event)
+
+	var errs []error
+	for _, sub := range subs {
+		started := time.Now()
+		err := deliver(ctx, sub, event)
+		if p.recorder != nil {
+			p.recorder.RecordHandled(HandlerRecord{
+				EventID:   event.ID,
+				Handler:   sub.name,
+				StartedAt: started,
+				Duration:  time.Since(started),
+				Err:       err,
+			})
+		}
+		if err != nil {
+			errs = append(errs, &HandlerError{Handler: sub.name, Event: event.Type, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver runs one handler under its timeout. A handler that ignores its context
+// is abandoned once the deadline passes, so it can never block the publisher.
+func deliver(ctx context.Context, sub subscription, event Event) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, sub.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- sub.handler.Handle(ctx, event)
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
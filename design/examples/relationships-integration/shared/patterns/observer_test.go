@@ -0,0 +1,195 @@
+package patterns_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+// legacyObserver is an observer written before handlers could fail
+type legacyObserver struct {
+	mu  sync.Mutex
+	got []patterns.Event
+}
+
+func (o *legacyObserver) OnEvent(event patterns.Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.got = append(o.got, event)
+}
+
+func (o *legacyObserver) received() []patterns.Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]patterns.Event(nil), o.got...)
+}
+
+// stuck ignores its context until released, as a handler blocked on I/O would
+func stuck(release <-chan struct{}) patterns.EventHandlerFunc {
+	return func(context.Context, patterns.Event) error {
+		<-release
+		return nil
+	}
+}
+
+// handlerErrors returns the *HandlerError values joined in err, in order
+func handlerErrors(t *testing.T, err error) []*patterns.HandlerError {
+	t.Helper()
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Publish = %v, want errors joined by errors.Join", err)
+	}
+	var out []*patterns.HandlerError
+	for _, e := range joined.Unwrap() {
+		var handlerErr *patterns.HandlerError
+		if !errors.As(e, &handlerErr) {
+			t.Fatalf("joined error %v is not a *HandlerError", e)
+		}
+		out = append(out, handlerErr)
+	}
+	return out
+}
+
+func TestPublishReportsEveryFailingHandler(t *testing.T) {
+	errDeclined := errors.New("card declined")
+	var ran atomic.Int32
+	ok := patterns.EventHandlerFunc(func(context.Context, patterns.Event) error {
+		ran.Add(1)
+		return nil
+	})
+	failing := patterns.EventHandlerFunc(func(context.Context, patterns.Event) error {
+		ran.Add(1)
+		return errDeclined
+	})
+
+	publisher := patterns.NewEventPublisher()
+	publisher.SubscribeHandler("audit", ok)
+	publisher.SubscribeHandler("payment", failing)
+	publisher.SubscribeHandler("email", ok)
+	publisher.SubscribeHandler("inventory", patterns.EventHandlerFunc(func(context.Context, patterns.Event) error {
+		ran.Add(1)
+		panic("out of stock")
+	}))
+	publisher.SubscribeHandler("refund", failing)
+	publisher.SubscribeHandler("analytics", ok)
+
+	err := publisher.Publish(context.Background(), patterns.Event{Type: "OrderPaid"})
+	if n := ran.Load(); n != 6 {
+		t.Errorf("%d handlers ran, want all 6 despite the failures", n)
+	}
+	if !errors.Is(err, errDeclined) {
+		t.Errorf("Publish = %v, want it to wrap the handler's error", err)
+	}
+
+	got := handlerErrors(t, err)
+	want := []struct{ handler, message string }{
+		{"payment", "card declined"},
+		{"inventory", "panic: out of stock"},
+		{"refund", "card declined"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Publish joined %d errors, want one per failing handler (%d): %v", len(got), len(want), err)
+	}
+	for i, w := range want {
+		if got[i].Handler != w.handler || got[i].Event != "OrderPaid" || got[i].Err.Error() != w.message {
+			t.Errorf("error %d = %+v, want handler %s, event OrderPaid and %q", i, got[i], w.handler, w.message)
+		}
+	}
+	if msg := got[1].Error(); msg != "handler inventory failed on OrderPaid: panic: out of stock" {
+		t.Errorf("Error() = %q", msg)
+	}
+
+	if err := patterns.NewEventPublisher().Publish(context.Background(), patterns.Event{}); err != nil {
+		t.Errorf("Publish without handlers = %v, want nil", err)
+	}
+}
+
+func TestPublishAbandonsAHandlerAtItsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	var after atomic.Bool
+
+	publisher := patterns.NewEventPublisher()
+	publisher.SubscribeHandler("stuck", stuck(release), patterns.WithTimeout(20*time.Millisecond))
+	publisher.SubscribeHandler("after", patterns.EventHandlerFunc(func(context.Context, patterns.Event) error {
+		after.Store(true)
+		return nil
+	}))
+
+	started := time.Now()
+	err := publisher.Publish(context.Background(), patterns.Event{Type: "OrderCreated"})
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Publish took %v, want it to give up on the handler after 20ms", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish = %v, want context.DeadlineExceeded", err)
+	}
+	if got := handlerErrors(t, err); len(got) != 1 || got[0].Handler != "stuck" {
+		t.Errorf("Publish = %v, want one error, from stuck", err)
+	}
+	if !after.Load() {
+		t.Error("the handler after the stuck one did not run")
+	}
+}
+
+func TestPublishAbandonsAHandlerAtTheDefaultTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for DefaultHandlerTimeout")
+	}
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	publisher := patterns.NewEventPublisher()
+	publisher.SubscribeHandler("stuck", stuck(release))
+
+	started := time.Now()
+	err := publisher.Publish(context.Background(), patterns.Event{Type: "OrderCreated"})
+	elapsed := time.Since(started)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed < patterns.DefaultHandlerTimeout || elapsed > patterns.DefaultHandlerTimeout+time.Second {
+		t.Errorf("Publish gave up after %v, want %v", elapsed, patterns.DefaultHandlerTimeout)
+	}
+}
+
+func TestObserverAdapter(t *testing.T) {
+	observer := &legacyObserver{}
+	publisher := patterns.NewEventPublisher()
+	publisher.Subscribe(observer)
+
+	if err := publisher.Publish(context.Background(), patterns.Event{Type: "OrderCreated", Data: 42}); err != nil {
+		t.Fatalf("Publish = %v, want a legacy observer to ack", err)
+	}
+	got := observer.received()
+	if len(got) != 1 || got[0].Type != "OrderCreated" || got[0].Data != 42 || got[0].ID == "" || got[0].OccurredAt.IsZero() {
+		t.Fatalf("observer received %+v, want the stamped OrderCreated event", got)
+	}
+
+	t.Run("a done context is not delivered", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := publisher.Publish(ctx, patterns.Event{Type: "OrderPaid"})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Publish = %v, want context.Canceled", err)
+		}
+		// Subscribe names the handler after the observer's type
+		if got := handlerErrors(t, err); len(got) != 1 || !strings.Contains(got[0].Handler, "legacyObserver") {
+			t.Errorf("Publish = %v, want one error naming the observer's type", err)
+		}
+		if n := len(observer.received()); n != 1 {
+			t.Errorf("observer received %d events, want only the first", n)
+		}
+
+		adapter := patterns.ObserverAdapter{Observer: observer}
+		if err := adapter.Handle(ctx, patterns.Event{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Handle = %v, want context.Canceled", err)
+		}
+	})
+}
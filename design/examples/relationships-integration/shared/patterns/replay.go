@@ -0,0 +1,295 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReplayBus - an EventPublisher that remembers what it published.
+// Every event gets a sequence number and the last capacity events are kept.
+// A handler that subscribes late (a projection rebuilt after a restart, an
+// analytics sink attached to a running service) replays that history before
+// it receives live events, so it sees one gap-free sequence in publish order.
+// Replayed events reach only the new handler, so it should be a projection or
+// sink: a handler that publishes follow-up events would publish them again.
+
+// ErrHistoryEvicted means the requested sequence number is older than the
+// oldest event still kept, so a replay from it would have a gap
+var ErrHistoryEvicted = errors.New("events before the requested sequence were evicted")
+
+// SequencedEvent is an event with its position in the bus history, starting at 1
+type SequencedEvent struct {
+	Seq   uint64
+	Event Event
+}
+
+// eventHistory is a ring buffer of the most recent events
+type eventHistory struct {
+	mu    sync.Mutex
+	buf   []SequencedEvent
+	head  int // index of the oldest event
+	count int
+	next  uint64
+}
+
+func newEventHistory(capacity int) *eventHistory {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &eventHistory{buf: make([]SequencedEvent, capacity), next: 1}
+}
+
+// append assigns consecutive sequence numbers, so a batch stays contiguous
+func (h *eventHistory) append(events ...Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, event := range events {
+		h.buf[(h.head+h.count)%len(h.buf)] = SequencedEvent{Seq: h.next, Event: event}
+		h.next++
+		if h.count < len(h.buf) {
+			h.count++
+		} else {
+			h.head = (h.head + 1) % len(h.buf)
+		}
+	}
+}
+
+// since returns the kept events with Seq >= from, oldest first
+func (h *eventHistory) since(from uint64) ([]SequencedEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	oldest := h.next - uint64(h.count)
+	if from < oldest {
+		return nil, fmt.Errorf("%w: asked for %d, oldest kept is %d", ErrHistoryEvicted, from, oldest)
+	}
+	return h.slice(from), nil
+}
+
+// last returns up to the n most recent events, oldest first
+func (h *eventHistory) last(n int) []SequencedEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n > h.count {
+		n = h.count
+	}
+	return h.slice(h.next - uint64(n))
+}
+
+func (h *eventHistory) slice(from uint64) []SequencedEvent {
+	if from >= h.next {
+		return nil
+	}
+	oldest := h.next - uint64(h.count)
+	out := make([]SequencedEvent, 0, h.next-from)
+	for i := int(from - oldest); i < h.count; i++ {
+		out = append(out, h.buf[(h.head+i)%len(h.buf)])
+	}
+	return out
+}
+
+func (h *eventHistory) lastSeq() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.next - 1
+}
+
+// ReplayBus embeds the publisher, so bus.EventPublisher can be handed to
+// anything that publishes, like FraudCheckHandler
+type ReplayBus struct {
+	*EventPublisher
+}
+
+// NewReplayBus creates a publisher that keeps the last capacity events
+func NewReplayBus(capacity int, opts ...PublisherOption) *ReplayBus {
+	p := NewEventPublisher(opts...)
+	p.history = newEventHistory(capacity)
+	return &ReplayBus{EventPublisher: p}
+}
+
+// LastSeq returns the sequence number of the latest published event, 0 if none.
+// A subscriber that detaches can later resume with SubscribeFrom(LastSeq()+1).
+func (b *ReplayBus) LastSeq() uint64 {
+	return b.history.lastSeq()
+}
+
+// History returns the kept events with Seq >= from, oldest first
+func (b *ReplayBus) History(from uint64) ([]SequencedEvent, error) {
+	return b.history.since(from)
+}
+
+// SubscribeReplayLast subscribes handler after replaying up to the last n events
+func (b *ReplayBus) SubscribeReplayLast(name string, handler EventHandler, n int, opts ...HandlerOption) error {
+	return b.subscribeReplay(name, handler, func() ([]SequencedEvent, error) {
+		if n <= 0 {
+			return nil, nil
+		}
+		return b.history.last(n), nil
+	}, opts...)
+}
+
+// SubscribeFrom subscribes handler after replaying every event from seq on
+// (seq 1 is the first event ever published). It fails with ErrHistoryEvicted,
+// without subscribing, if seq is no longer kept; a seq beyond the latest
+// event replays nothing.
+func (b *ReplayBus) SubscribeFrom(name string, handler EventHandler, seq uint64, opts ...HandlerOption) error {
+	return b.subscribeReplay(name, handler, func() ([]SequencedEvent, error) {
+		return b.history.since(seq)
+	}, opts...)
+}
+
+// subscribeReplay takes the history snapshot and adds the subscription under
+// the publisher's write lock. Publish records an event and snapshots the
+// subscriptions under the read lock, so every event is either in the replay
+// or delivered live, never both and never neither. Live events published
+// during the replay are queued, however long it takes, and acked to their
+// publisher; they are delivered in order once the replay is done. Failures
+// of replayed and queued events are returned but keep the subscription.
+func (b *ReplayBus) subscribeReplay(name string, handler EventHandler, history func() ([]SequencedEvent, error), opts ...HandlerOption) error {
+	sub := subscription{name: name, handler: handler, timeout: DefaultHandlerTimeout}
+	for _, opt := range opts {
+		opt(&sub)
+	}
+	gate := &replayGate{handler: handler}
+	live := sub
+	live.handler = gate
+	if batch, ok := handler.(BatchEventHandler); ok {
+		live.handler = &batchReplayGate{replayGate: gate, batch: batch}
+	}
+
+	b.mu.Lock()
+	replay, err := history()
+	if err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	b.subscriptions = append(b.subscriptions, live)
+	b.mu.Unlock()
+
+	var errs []error
+	report := func(event Event, records ...HandlerRecord) {
+		for _, record := range records {
+			if b.recorder != nil {
+				b.recorder.RecordHandled(record)
+			}
+		}
+		if err := records[0].Err; err != nil {
+			errs = append(errs, &HandlerError{Handler: sub.name, Event: event.Type, Err: err})
+		}
+	}
+	for _, seq := range replay {
+		event := seq.Event
+		started := time.Now()
+		err := deliver(withCause(context.Background(), event), sub, event)
+		event.Type = fmt.Sprintf("%s (replay #%d)", event.Type, seq.Seq)
+		report(event, HandlerRecord{EventID: event.ID, Handler: sub.name, StartedAt: started, Duration: time.Since(started), Err: err})
+	}
+
+	// Live events keep queuing while the queue flushes; the gate opens once
+	// it is empty, so none overtakes one queued before it
+	gate.flushing()
+	for {
+		queued, ok := gate.next()
+		if !ok {
+			break
+		}
+		if queued.batch {
+			report(Event{Type: fmt.Sprintf("batch of %d", len(queued.events))}, deliverBatch(queued.ctx, sub, queued.events)...)
+		} else {
+			event := queued.events[0]
+			started := time.Now()
+			err := deliver(queued.ctx, sub, event)
+			report(event, HandlerRecord{EventID: event.ID, Handler: sub.name, StartedAt: started, Duration: time.Since(started), Err: err})
+		}
+		close(queued.done)
+	}
+	return errors.Join(errs...)
+}
+
+// replayGate queues live events until the subscriber has caught up. During
+// the replay a queued event is acked at once. Once the queue is flushing,
+// the publisher waits for its event, up to its timeout, so one that keeps
+// publishing faster than the subscriber handles cannot keep it from
+// catching up. Its event stays queued either way, even if the publisher has
+// already reported the timeout.
+type replayGate struct {
+	handler EventHandler
+
+	mu       sync.Mutex
+	replayed bool
+	caughtUp bool
+	queue    []queuedDelivery
+}
+
+// queuedDelivery is a live delivery held back until the replay is done. Its
+// context keeps the publisher's values, such as the cause, but not its
+// deadline: the delivery gets a new timeout when it runs.
+type queuedDelivery struct {
+	ctx    context.Context
+	events []Event
+	batch  bool
+	done   chan struct{}
+}
+
+// hold queues a delivery unless the subscriber has caught up, and acks it
+func (g *replayGate) hold(ctx context.Context, events []Event, batch bool) bool {
+	g.mu.Lock()
+	if g.caughtUp {
+		g.mu.Unlock()
+		return false
+	}
+	done := make(chan struct{})
+	g.queue = append(g.queue, queuedDelivery{ctx: context.WithoutCancel(ctx), events: events, batch: batch, done: done})
+	replayed := g.replayed
+	g.mu.Unlock()
+
+	if replayed {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+	return true
+}
+
+// flushing marks the end of the replay
+func (g *replayGate) flushing() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.replayed = true
+}
+
+// next takes the oldest queued delivery; once there is none, the gate opens
+func (g *replayGate) next() (queuedDelivery, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.queue) == 0 {
+		g.caughtUp = true
+		return queuedDelivery{}, false
+	}
+	queued := g.queue[0]
+	g.queue[0] = queuedDelivery{}
+	g.queue = g.queue[1:]
+	return queued, true
+}
+
+func (g *replayGate) Handle(ctx context.Context, event Event) error {
+	if g.hold(ctx, []Event{event}, false) {
+		return nil
+	}
+	return g.handler.Handle(ctx, event)
+}
+
+type batchReplayGate struct {
+	*replayGate
+	batch BatchEventHandler
+}
+
+func (g *batchReplayGate) HandleBatch(ctx context.Context, events []Event) error {
+	if g.hold(ctx, append([]Event(nil), events...), true) {
+		return nil
+	}
+	return g.batch.HandleBatch(ctx, events)
+}
@@ -0,0 +1,245 @@
+package patterns_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dong-tran/docs/integration-example/shared/patterns"
+)
+
+// sequence records the int payloads it receives, optionally slowly
+type sequence struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	got     []int
+	batches int
+}
+
+func (s *sequence) Handle(ctx context.Context, event patterns.Event) error {
+	if s.delay > 0 {
+		time.Sleep(s.delay)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, event.Data.(int))
+	return nil
+}
+
+// batchSequence also takes whole batches in one call
+type batchSequence struct {
+	*sequence
+}
+
+func (s batchSequence) HandleBatch(ctx context.Context, events []patterns.Event) error {
+	s.mu.Lock()
+	s.batches++
+	s.mu.Unlock()
+	for _, event := range events {
+		if err := s.Handle(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check fails unless s received first..last, each once and in order
+func (s *sequence) check(t *testing.T, first, last int) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.got) != last-first+1 {
+		t.Fatalf("received %d events, want %d (%d..%d)", len(s.got), last-first+1, first, last)
+	}
+	for i, n := range s.got {
+		if n != first+i {
+			t.Fatalf("event %d = %d, want %d", i, n, first+i)
+		}
+	}
+}
+
+// replayBus publishes n events numbered from 1 on a bus keeping capacity
+func replayBus(t *testing.T, capacity, n int) (*patterns.ReplayBus, func(int)) {
+	t.Helper()
+	bus := patterns.NewReplayBus(capacity)
+	var mu sync.Mutex
+	published := 0
+	publish := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := 0; i < n; i++ {
+			published++
+			if err := bus.Publish(context.Background(), patterns.Event{Type: "OrderCreated", Data: published}); err != nil {
+				t.Errorf("Publish(%d) = %v", published, err)
+			}
+		}
+	}
+	publish(n)
+	return bus, publish
+}
+
+func TestSubscribeFromEvictedSeq(t *testing.T) {
+	bus, publish := replayBus(t, 100, 150)
+	if got := bus.LastSeq(); got != 150 {
+		t.Fatalf("LastSeq() = %d, want 150", got)
+	}
+
+	for _, seq := range []uint64{1, 10, 50} {
+		s := &sequence{}
+		if err := bus.SubscribeFrom("late", s, seq); !errors.Is(err, patterns.ErrHistoryEvicted) {
+			t.Errorf("SubscribeFrom(%d) = %v, want ErrHistoryEvicted", seq, err)
+		}
+		publish(1)
+		if len(s.got) != 0 {
+			t.Errorf("refused subscriber from %d received %v", seq, s.got)
+		}
+	}
+	if _, err := bus.History(10); !errors.Is(err, patterns.ErrHistoryEvicted) {
+		t.Errorf("History(10) = %v, want ErrHistoryEvicted", err)
+	}
+}
+
+func TestReplayThenLive(t *testing.T) {
+	bus, publish := replayBus(t, 100, 150)
+
+	tests := []struct {
+		name      string
+		subscribe func(patterns.EventHandler) error
+		first     int
+	}{
+		{"last 20", func(h patterns.EventHandler) error { return bus.SubscribeReplayLast("last-20", h, 20) }, 131},
+		{"last 0", func(h patterns.EventHandler) error { return bus.SubscribeReplayLast("last-0", h, 0) }, 151},
+		{"more than kept", func(h patterns.EventHandler) error { return bus.SubscribeReplayLast("last-500", h, 500) }, 51},
+		{"from #140", func(h patterns.EventHandler) error { return bus.SubscribeFrom("from-140", h, 140) }, 140},
+		{"from the oldest kept", func(h patterns.EventHandler) error { return bus.SubscribeFrom("from-51", h, 51) }, 51},
+		{"from beyond the latest", func(h patterns.EventHandler) error { return bus.SubscribeFrom("from-151", h, 151) }, 151},
+	}
+	subscribers := make([]*sequence, len(tests))
+	for i, tt := range tests {
+		subscribers[i] = &sequence{}
+		if err := tt.subscribe(subscribers[i]); err != nil {
+			t.Fatalf("%s: subscribe = %v", tt.name, err)
+		}
+	}
+
+	publish(10)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscribers[i].check(t, tt.first, 160)
+		})
+	}
+}
+
+func TestSubscribeWhilePublishing(t *testing.T) {
+	bus, publish := replayBus(t, 100, 0)
+	var stop atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for !stop.Load() {
+			publish(1)
+		}
+	}()
+
+	late := []*sequence{{}, {delay: 50 * time.Microsecond}}
+	for _, s := range late {
+		time.Sleep(5 * time.Millisecond)
+		// The publisher queues thousands of events during the slow replay and
+		// then waits for each to flush; on a loaded machine that can outlast
+		// the default timeout, which would fail Publish but not the ordering
+		if err := bus.SubscribeReplayLast("late", s, 100, patterns.WithTimeout(time.Minute)); err != nil {
+			t.Fatalf("SubscribeReplayLast = %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	stop.Store(true)
+	<-done
+
+	last := int(bus.LastSeq())
+	for _, s := range late {
+		s.mu.Lock()
+		first := 0
+		if len(s.got) > 0 {
+			first = s.got[0]
+		}
+		s.mu.Unlock()
+		s.check(t, first, last)
+	}
+}
+
+func TestSlowReplayKeepsLiveEvents(t *testing.T) {
+	bus, publish := replayBus(t, 100, 20)
+
+	// The replay takes about 100ms, twice the handler timeout, and every
+	// live event published meanwhile has to wait for it
+	s := &sequence{delay: 5 * time.Millisecond}
+	subscribed := make(chan error)
+	go func() {
+		subscribed <- bus.SubscribeFrom("slow", s, 1, patterns.WithTimeout(50*time.Millisecond))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	started := time.Now()
+	publish(5)
+	if took := time.Since(started); took > 50*time.Millisecond {
+		t.Errorf("publishing during the replay took %v, want it acked without waiting", took)
+	}
+	if err := <-subscribed; err != nil {
+		t.Fatalf("SubscribeFrom = %v", err)
+	}
+	publish(5)
+	s.check(t, 1, 30)
+}
+
+func TestBatchPublishedDuringReplay(t *testing.T) {
+	bus, _ := replayBus(t, 100, 10)
+
+	s := batchSequence{&sequence{delay: 5 * time.Millisecond}}
+	subscribed := make(chan error)
+	go func() {
+		subscribed <- bus.SubscribeFrom("batch", s, 1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	batch := []patterns.Event{{Type: "OrderCreated", Data: 11}, {Type: "OrderCreated", Data: 12}, {Type: "OrderCreated", Data: 13}}
+	if err := bus.PublishBatch(context.Background(), batch); err != nil {
+		t.Fatalf("PublishBatch = %v", err)
+	}
+	if err := <-subscribed; err != nil {
+		t.Fatalf("SubscribeFrom = %v", err)
+	}
+	s.check(t, 1, 13)
+	if s.batches != 1 {
+		t.Errorf("HandleBatch called %d times, want 1", s.batches)
+	}
+}
+
+func TestReplayFailuresKeepTheSubscription(t *testing.T) {
+	bus, publish := replayBus(t, 100, 3)
+	boom := errors.New("boom")
+	var got []int
+	handler := patterns.EventHandlerFunc(func(ctx context.Context, event patterns.Event) error {
+		got = append(got, event.Data.(int))
+		if event.Data.(int) == 2 {
+			return boom
+		}
+		return nil
+	})
+
+	err := bus.SubscribeFrom("flaky", handler, 1)
+	var herr *patterns.HandlerError
+	if !errors.As(err, &herr) || !errors.Is(err, boom) {
+		t.Fatalf("SubscribeFrom = %v, want a HandlerError wrapping boom", err)
+	}
+	if want := "OrderCreated (replay #2)"; herr.Event != want {
+		t.Errorf("HandlerError.Event = %q, want %q", herr.Event, want)
+	}
+	publish(1)
+	if len(got) != 4 || got[3] != 4 {
+		t.Errorf("handled %v, want 1..4", got)
+	}
+}
@@ -0,0 +1,67 @@
+package patterns
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event tracing - correlation and causation IDs travel in the context.
+// An HTTP request gets a correlation ID at the edge; every event published while
+// serving it shares that ID, and events published from inside a handler also
+// record the event that caused them. Together they form a causal graph.
+
+type correlationKey struct{}
+type causationKey struct{}
+
+// WithCorrelationID attaches a correlation ID to ctx
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, if any
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+func withCause(ctx context.Context, event Event) context.Context {
+	ctx = WithCorrelationID(ctx, event.CorrelationID)
+	return context.WithValue(ctx, causationKey{}, event.ID)
+}
+
+// stamp fills in the identity and causal links of an event about to be published
+func stamp(ctx context.Context, event Event) Event {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID = CorrelationID(ctx)
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID = event.ID
+	}
+	if event.CausationID == "" {
+		event.CausationID, _ = ctx.Value(causationKey{}).(string)
+	}
+	return event
+}
+
+// HandlerRecord is the outcome of delivering one event to one handler
+type HandlerRecord struct {
+	EventID   string
+	Handler   string
+	StartedAt time.Time
+	Duration  time.Duration
+	Err       error
+}
+
+// EventRecorder observes the publisher itself, e.g. to keep an event store
+type EventRecorder interface {
+	RecordEvent(event Event)
+	RecordHandled(record HandlerRecord)
+}
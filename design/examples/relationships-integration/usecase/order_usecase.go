@@ -1,6 +1,10 @@
 package usecase
 
 import (
+"context"
+"fmt"
+"log"
+
 "github.com/dong-tran/docs/integration-example/domain/order"
 "github.com/dong-tran/docs/integration-example/shared/patterns"
 )
@@ -11,18 +15,52 @@ type OrderUseCase struct {
 	orderRepo      order.OrderRepository
 	paymentFactory *patterns.PaymentFactory
 	eventPublisher *patterns.EventPublisher
+	workflow       *order.Workflow
+}
+
+type Option func(*OrderUseCase)
+
+// WithWorkflow replaces the default order workflow policy
+func WithWorkflow(w *order.Workflow) Option {
+	return func(uc *OrderUseCase) {
+		uc.workflow = w
+	}
 }
 
 func NewOrderUseCase(
 orderRepo order.OrderRepository,
 paymentFactory *patterns.PaymentFactory,
 eventPublisher *patterns.EventPublisher,
+opts ...Option,
 ) *OrderUseCase {
-	return &OrderUseCase{
+	uc := &OrderUseCase{
 		orderRepo:      orderRepo,
 		paymentFactory: paymentFactory,
 		eventPublisher: eventPublisher,
+		workflow:       order.DefaultWorkflow(),
 	}
+	for _, opt := range opts {
+		opt(uc)
+	}
+	return uc
+}
+
+// Workflow is the policy the use cases enforce
+func (uc *OrderUseCase) Workflow() *order.Workflow {
+	return uc.workflow
+}
+
+type roleKey struct{}
+
+// WithRole attaches the caller's role, checked against the workflow policy
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFrom returns the caller's role, "" for an anonymous caller
+func RoleFrom(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
 }
 
 // CreateOrderDTO - Input DTO
@@ -40,7 +78,51 @@ type OrderItemDTO struct {
 }
 
 // CreateOrder - Use case method
-func (uc *OrderUseCase) CreateOrder(dto CreateOrderDTO) (*order.Order, error) {
+func (uc *OrderUseCase) CreateOrder(ctx context.Context, dto CreateOrderDTO) (*order.Order, error) {
+	newOrder, err := newOrderFromDTO(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	// Persist
+	if err := uc.orderRepo.Save(newOrder); err != nil {
+		return nil, err
+	}
+
+	// Publish domain event
+	uc.publish(ctx, orderCreated(newOrder))
+
+	return newOrder, nil
+}
+
+// ImportOrders - Bulk use case: all orders are stored in one transaction and
+// their OrderCreated events are published as one batch once it commits
+func (uc *OrderUseCase) ImportOrders(ctx context.Context, dtos []CreateOrderDTO) ([]*order.Order, error) {
+	batch := uc.eventPublisher.NewBatch(ctx)
+	defer batch.Discard()
+
+	orders := make([]*order.Order, 0, len(dtos))
+	for i, dto := range dtos {
+		newOrder, err := newOrderFromDTO(dto)
+		if err != nil {
+			return nil, fmt.Errorf("order %d: %w", i, err)
+		}
+		orders = append(orders, newOrder)
+		batch.Add(orderCreated(newOrder))
+	}
+
+	if err := uc.orderRepo.SaveAll(orders); err != nil {
+		return nil, err
+	}
+
+	if err := batch.Flush(); err != nil {
+		log.Printf("batch of %d events not acknowledged by all handlers: %v", len(orders), err)
+	}
+	return orders, nil
+}
+
+// newOrderFromDTO converts the input DTO into a new Order aggregate
+func newOrderFromDTO(dto CreateOrderDTO) (*order.Order, error) {
 	// Convert DTOs to domain objects
 	customerID := order.NewCustomerID(dto.CustomerID)
 	
@@ -64,41 +146,37 @@ price,
 	}
 
 	// Create order using domain logic
-	newOrder, err := order.NewOrder(customerID, items)
-	if err != nil {
-		return nil, err
-	}
-
-	// Persist
-	if err := uc.orderRepo.Save(newOrder); err != nil {
-		return nil, err
-	}
+	return order.NewOrder(customerID, items)
+}
 
-	// Publish domain event
-	uc.eventPublisher.Publish(patterns.Event{
+func orderCreated(ord *order.Order) patterns.Event {
+	return patterns.Event{
 Type: "OrderCreated",
 Data: order.OrderCreatedEvent{
-OrderID:    newOrder.ID().String(),
-			CustomerID: newOrder.CustomerID().String(),
-			Total:      newOrder.TotalAmount().Amount(),
+OrderID:    ord.ID().String(),
+			CustomerID: ord.CustomerID().String(),
+			Total:      ord.TotalAmount().Amount(),
 		},
-	})
-
-	return newOrder, nil
+	}
 }
 
 // ProcessPayment - Use case using Strategy pattern
-func (uc *OrderUseCase) ProcessPayment(orderID string, paymentMethod string) error {
+func (uc *OrderUseCase) ProcessPayment(ctx context.Context, orderID string, paymentMethod string) error {
 	// Get order
 	ord, err := uc.orderRepo.FindByID(order.OrderID{})
 	if err != nil {
 		return err
 	}
 
+	// Check the workflow before charging anything
+	if _, err := uc.workflow.Check(ord.Status(), order.ActionPay, RoleFrom(ctx)); err != nil {
+		return err
+	}
+
 	// Use Factory to create payment strategy (Factory + Strategy patterns)
 	paymentStrategy, err := uc.paymentFactory.CreatePayment(paymentMethod)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w %q", order.ErrPaymentUnsupported, paymentMethod)
 	}
 
 	// Process payment using strategy
@@ -107,7 +185,8 @@ func (uc *OrderUseCase) ProcessPayment(orderID string, paymentMethod string) err
 	}
 
 	// Update order status (domain logic)
-	if err := ord.MarkAsPaid(); err != nil {
+	transition, err := ord.Fire(uc.workflow, order.ActionPay, RoleFrom(ctx))
+	if err != nil {
 		return err
 	}
 
@@ -117,8 +196,8 @@ func (uc *OrderUseCase) ProcessPayment(orderID string, paymentMethod string) err
 	}
 
 	// Publish event
-	uc.eventPublisher.Publish(patterns.Event{
-Type: "OrderPaid",
+	uc.publish(ctx, patterns.Event{
+Type: transition.Emits,
 Data: order.OrderPaidEvent{
 OrderID:       ord.ID().String(),
 			PaymentMethod: paymentStrategy.GetName(),
@@ -140,13 +219,14 @@ func (uc *OrderUseCase) GetCustomerOrders(customerID string) ([]*order.Order, er
 }
 
 // ShipOrder - Use case
-func (uc *OrderUseCase) ShipOrder(orderID string, trackingNumber string) error {
+func (uc *OrderUseCase) ShipOrder(ctx context.Context, orderID string, trackingNumber string) error {
 	ord, err := uc.orderRepo.FindByID(order.OrderID{})
 	if err != nil {
 		return err
 	}
 
-	if err := ord.Ship(); err != nil {
+	transition, err := ord.Fire(uc.workflow, order.ActionShip, RoleFrom(ctx))
+	if err != nil {
 		return err
 	}
 
@@ -154,8 +234,8 @@ func (uc *OrderUseCase) ShipOrder(orderID string, trackingNumber string) error {
 		return err
 	}
 
-	uc.eventPublisher.Publish(patterns.Event{
-Type: "OrderShipped",
+	uc.publish(ctx, patterns.Event{
+Type: transition.Emits,
 Data: order.OrderShippedEvent{
 OrderID:        ord.ID().String(),
 			TrackingNumber: trackingNumber,
@@ -164,3 +244,50 @@ OrderID:        ord.ID().String(),
 
 	return nil
 }
+
+// PerformAction - Use case for workflow actions that carry no data of their
+// own: cancel, deliver, and any action a custom policy adds. Pay and ship go
+// through ProcessPayment and ShipOrder, which need a payment method and a
+// tracking number.
+func (uc *OrderUseCase) PerformAction(ctx context.Context, orderID string, action order.Action) (*order.Order, error) {
+	if action == order.ActionPay || action == order.ActionShip {
+		return nil, fmt.Errorf("%w: %s has its own endpoint", order.ErrTransitionNotAllowed, action)
+	}
+
+	ord, err := uc.orderRepo.FindByID(order.OrderID{})
+	if err != nil {
+		return nil, err
+	}
+
+	from := ord.Status()
+	transition, err := ord.Fire(uc.workflow, action, RoleFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.orderRepo.Update(ord); err != nil {
+		return nil, err
+	}
+
+	if transition.Emits != "" {
+		uc.publish(ctx, patterns.Event{
+Type: transition.Emits,
+Data: order.OrderStatusChangedEvent{
+OrderID: ord.ID().String(),
+				Action:  string(action),
+				From:    from,
+				To:      ord.Status(),
+			},
+		})
+	}
+
+	return ord, nil
+}
+
+// publish notifies subscribers after the state change is persisted.
+// Delivery failures are reported but don't undo the already committed change.
+func (uc *OrderUseCase) publish(ctx context.Context, event patterns.Event) {
+	if err := uc.eventPublisher.Publish(ctx, event); err != nil {
+		log.Printf("event %s not acknowledged by all handlers: %v", event.Type, err)
+	}
+}